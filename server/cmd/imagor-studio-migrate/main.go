@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/cshum/imagor-studio/server/internal/config"
 	"github.com/cshum/imagor-studio/server/internal/database"
@@ -18,9 +19,16 @@ func main() {
 		os.Exit(1)
 	}
 
+	// backup and restore take the archive path, the remaining arguments
+	// configure the database as for migration commands
+	command, archivePath, args, err := parseCommand(os.Args[1:])
+	if err != nil {
+		logger.Fatal("Invalid command", zap.Error(err))
+	}
+
 	// Load configuration using the existing config system
 	// This supports CLI args, environment variables, and .env files
-	cfg, err := config.Load(nil, nil)
+	cfg, err := config.Load(args, nil)
 	if err != nil {
 		logger.Fatal("Failed to load configuration", zap.Error(err))
 	}
@@ -40,6 +48,19 @@ func main() {
 	// Create migration service
 	service := migrator.NewService(db, logger)
 
+	switch command {
+	case "backup":
+		if err := service.Backup(archivePath); err != nil {
+			logger.Fatal("Backup failed", zap.Error(err))
+		}
+		return
+	case "restore":
+		if err := service.Restore(cfg.DatabaseURL, archivePath); err != nil {
+			logger.Fatal("Restore failed", zap.Error(err))
+		}
+		return
+	}
+
 	// Execute migration command (validation happens inside service)
 	if err := service.Execute(cfg); err != nil {
 		logger.Fatal("Migration command failed",
@@ -50,3 +71,39 @@ func main() {
 	logger.Info("Migration command completed successfully",
 		zap.String("command", cfg.MigrateCommand))
 }
+
+// parseCommand splits the backup and restore subcommands and their archive
+// flag, --out and --in respectively, from args. Other arguments are
+// returned as they are.
+func parseCommand(args []string) (command, archivePath string, rest []string, err error) {
+	if len(args) == 0 || (args[0] != "backup" && args[0] != "restore") {
+		return "", "", args, nil
+	}
+	command = args[0]
+	name := "out"
+	if command == "restore" {
+		name = "in"
+	}
+	rest = []string{}
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--"+name || arg == "-"+name:
+			if i+1 >= len(args) {
+				return "", "", nil, fmt.Errorf("--%s requires a file", name)
+			}
+			archivePath = args[i+1]
+			i++
+		case strings.HasPrefix(arg, "--"+name+"="):
+			archivePath = strings.TrimPrefix(arg, "--"+name+"=")
+		case strings.HasPrefix(arg, "-"+name+"="):
+			archivePath = strings.TrimPrefix(arg, "-"+name+"=")
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	if archivePath == "" {
+		return "", "", nil, fmt.Errorf("usage: imagor-studio-migrate %s --%s <file.tar.gz>", command, name)
+	}
+	return command, archivePath, rest, nil
+}
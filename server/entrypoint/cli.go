@@ -0,0 +1,68 @@
+package entrypoint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/cshum/imagor-studio/server/internal/bootstrap"
+	"github.com/cshum/imagor-studio/server/internal/cli"
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"go.uber.org/zap"
+)
+
+// runCLI runs an operator subcommand such as user reset-password,
+// returning the process exit code
+func runCLI(args []string, stdout, stderr io.Writer) int {
+	inv, configArgs, err := cli.Parse(args)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		if errors.Is(err, cli.ErrUsage) {
+			return 2
+		}
+		return 1
+	}
+
+	logger, err := newCLILogger()
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to initialize logger: %v\n", err)
+		return 1
+	}
+	defer func() { _ = logger.Sync() }()
+
+	cfg, err := config.Load(configArgs, nil)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to load configuration: %v\n", err)
+		return 1
+	}
+	services, err := bootstrap.InitializeCLI(cfg, logger, configArgs)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	defer func() { _ = services.Close() }()
+
+	err = inv.Run(context.Background(), cli.Env{
+		Users:    services.UserStore,
+		Registry: services.RegistryStore,
+		Storage:  services.StorageProvider.GetStorage(),
+		Out:      stdout,
+	})
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// newCLILogger logs warnings only, so server logs do not mix with the
+// command output
+func newCLILogger() (*zap.Logger, error) {
+	if shouldUseDebugLogging() {
+		return zap.NewDevelopment()
+	}
+	logConfig := zap.NewProductionConfig()
+	logConfig.Level = zap.NewAtomicLevelAt(zap.WarnLevel)
+	return logConfig.Build()
+}
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/cshum/imagor-studio/server/internal/bootstrap"
+	"github.com/cshum/imagor-studio/server/internal/cli"
 	"github.com/cshum/imagor-studio/server/internal/config"
 	internalserver "github.com/cshum/imagor-studio/server/internal/server"
 	"github.com/cshum/imagor-studio/server/pkg/management"
@@ -37,6 +38,10 @@ func RunCloudWithFactoriesAndArgs(embedFS fs.FS, args []string, factories manage
 }
 
 func run(embedFS fs.FS, mode Mode, args []string, factories management.CloudFactories) {
+	if mode == ModeSelfHosted && len(args) > 0 && cli.IsCommand(args[0]) {
+		os.Exit(runCLI(args, os.Stdout, os.Stderr))
+	}
+
 	logger, err := newLoggerFromEnv()
 	if err != nil {
 		fmt.Printf("Failed to initialize logger: %v\n", err)
@@ -47,6 +52,9 @@ func run(embedFS fs.FS, mode Mode, args []string, factories management.CloudFact
 	if err != nil {
 		logger.Fatal("Failed to load configuration", zap.Error(err))
 	}
+	if cfg.ValidateConfig {
+		os.Exit(runValidateConfig(cfg, args, os.Stdout))
+	}
 
 	cloudConfig := management.CloudConfig{}
 	if internalserver.Mode(mode) == internalserver.ModeCloud && factories.ConfigLoader != nil {
@@ -0,0 +1,55 @@
+package entrypoint
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/cshum/imagor-studio/server/internal/bootstrap"
+	"github.com/cshum/imagor-studio/server/internal/config"
+)
+
+// runValidateConfig prints the report of bootstrap.ValidateConfig,
+// returning the process exit code
+func runValidateConfig(cfg *config.Config, args []string, out io.Writer) int {
+	logger, err := newCLILogger()
+	if err != nil {
+		fmt.Fprintf(out, "Failed to initialize logger: %v\n", err)
+		return 1
+	}
+	defer func() { _ = logger.Sync() }()
+
+	report := bootstrap.ValidateConfig(cfg, logger, args)
+	printConfigReport(out, report)
+	if !report.OK() {
+		return 1
+	}
+	return 0
+}
+
+func printConfigReport(out io.Writer, report *bootstrap.ConfigReport) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CHECK\tSTATUS\tDETAIL")
+	for _, check := range report.Checks {
+		status, detail := "ok", check.Detail
+		switch {
+		case check.Err != nil:
+			status, detail = "FAIL", check.Err.Error()
+		case check.Warning:
+			status = "warn"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", check.Name, status, detail)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "SETTING\tSOURCE\tVALUE")
+	for _, value := range report.Config.Values() {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", value.Name, value.Source, value.Value)
+	}
+	_ = w.Flush()
+
+	if report.OK() {
+		fmt.Fprintln(out, "\nConfiguration is valid")
+	} else {
+		fmt.Fprintln(out, "\nConfiguration is invalid")
+	}
+}
@@ -0,0 +1,185 @@
+// Package adminrecovery issues one-time tokens that reset an admin password
+// when every admin account is locked out.
+//
+// A token is only ever printed to the server log, so redeeming it proves
+// access to the host. Tokens are issued on demand, either at startup via
+// --admin-recovery or by creating the file given by --admin-recovery-file,
+// which is removed once the token is issued. Only the SHA-256 hash of the
+// token is kept, in memory on the instance that printed it; a token expires
+// after its TTL, after a single successful use, or after too many failed
+// attempts. Every step is written to the audit logger.
+package adminrecovery
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultTTL is how long an issued token stays valid
+	DefaultTTL = 15 * time.Minute
+	// DefaultMaxAttempts is the number of wrong tokens after which the
+	// current token is revoked
+	DefaultMaxAttempts = 5
+)
+
+var (
+	// ErrNoToken is returned when no token has been issued or it was revoked
+	ErrNoToken = errors.New("no admin recovery token is active")
+	// ErrInvalidToken is returned for a wrong or expired token
+	ErrInvalidToken = errors.New("invalid or expired admin recovery token")
+)
+
+// Manager issues and redeems recovery tokens
+type Manager struct {
+	audit       *zap.Logger
+	ttl         time.Duration
+	maxAttempts int
+	flagFile    string
+	now         func() time.Time
+
+	mu        sync.Mutex
+	hash      []byte
+	expiresAt time.Time
+	attempts  int
+}
+
+// Option configures a Manager
+type Option func(*Manager)
+
+// WithTTL sets how long an issued token stays valid
+func WithTTL(ttl time.Duration) Option {
+	return func(m *Manager) {
+		if ttl > 0 {
+			m.ttl = ttl
+		}
+	}
+}
+
+// WithMaxAttempts sets the number of wrong tokens after which the token is revoked
+func WithMaxAttempts(n int) Option {
+	return func(m *Manager) {
+		if n > 0 {
+			m.maxAttempts = n
+		}
+	}
+}
+
+// WithFlagFile sets the file whose presence requests a new token on Sync
+func WithFlagFile(path string) Option {
+	return func(m *Manager) {
+		m.flagFile = path
+	}
+}
+
+// New creates a recovery token manager writing audit entries to logger
+func New(logger *zap.Logger, opts ...Option) *Manager {
+	m := &Manager{
+		audit:       logger.Named("audit").With(zap.String("component", "admin_recovery")),
+		ttl:         DefaultTTL,
+		maxAttempts: DefaultMaxAttempts,
+		now:         time.Now,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Issue creates a new token, replacing any active one, and prints it to the log
+func (m *Manager) Issue(reason string) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate admin recovery token: %w", err)
+	}
+	token := hex.EncodeToString(buf)
+	sum := sha256.Sum256([]byte(token))
+
+	m.mu.Lock()
+	m.hash = sum[:]
+	m.expiresAt = m.now().Add(m.ttl)
+	m.attempts = 0
+	expiresAt := m.expiresAt
+	m.mu.Unlock()
+
+	m.audit.Warn("Admin recovery token issued, POST it to /api/auth/admin-recovery to reset an admin password",
+		zap.String("event", "issued"),
+		zap.String("reason", reason),
+		zap.String("token", token),
+		zap.Time("expiresAt", expiresAt))
+	return token, nil
+}
+
+// Sync issues a token when the flag file exists and removes the file
+func (m *Manager) Sync() error {
+	if m.flagFile == "" {
+		return nil
+	}
+	if _, err := os.Stat(m.flagFile); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to check admin recovery flag file: %w", err)
+	}
+	// Remove first so a file we cannot delete does not issue a token every sync
+	if err := os.Remove(m.flagFile); err != nil {
+		return fmt.Errorf("failed to remove admin recovery flag file: %w", err)
+	}
+	_, err := m.Issue("flag file " + m.flagFile)
+	return err
+}
+
+// Active reports whether a token can currently be redeemed
+func (m *Manager) Active() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.hash != nil && m.now().Before(m.expiresAt)
+}
+
+// Redeem checks token and runs reset. The token is consumed only when reset
+// succeeds, so a mistyped username does not burn it. remoteAddr is recorded
+// in the audit log.
+func (m *Manager) Redeem(token, remoteAddr string, reset func() error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.hash == nil {
+		m.audit.Warn("Admin recovery attempted without an active token",
+			zap.String("event", "rejected"), zap.String("remoteAddr", remoteAddr))
+		return ErrNoToken
+	}
+	sum := sha256.Sum256([]byte(token))
+	if !m.now().Before(m.expiresAt) || subtle.ConstantTimeCompare(sum[:], m.hash) != 1 {
+		m.attempts++
+		revoked := m.attempts >= m.maxAttempts || !m.now().Before(m.expiresAt)
+		if revoked {
+			m.hash = nil
+		}
+		m.audit.Warn("Admin recovery rejected",
+			zap.String("event", "rejected"),
+			zap.String("remoteAddr", remoteAddr),
+			zap.Int("attempts", m.attempts),
+			zap.Bool("revoked", revoked))
+		return ErrInvalidToken
+	}
+	if err := reset(); err != nil {
+		m.audit.Warn("Admin recovery token accepted but reset failed",
+			zap.String("event", "reset_failed"),
+			zap.String("remoteAddr", remoteAddr),
+			zap.Error(err))
+		return err
+	}
+	m.hash = nil
+	m.audit.Warn("Admin recovery token redeemed",
+		zap.String("event", "redeemed"), zap.String("remoteAddr", remoteAddr))
+	return nil
+}
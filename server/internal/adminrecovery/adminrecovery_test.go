@@ -0,0 +1,84 @@
+package adminrecovery
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func noop() error { return nil }
+
+func TestManager_IssueAndRedeem(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	m := New(zap.New(core))
+	assert.False(t, m.Active())
+	assert.ErrorIs(t, m.Redeem("anything", "127.0.0.1", noop), ErrNoToken)
+
+	token, err := m.Issue("test")
+	require.NoError(t, err)
+	assert.Len(t, token, 64)
+	assert.True(t, m.Active())
+
+	issued := logs.FilterField(zap.String("event", "issued")).All()
+	require.Len(t, issued, 1)
+	assert.Equal(t, "audit", issued[0].LoggerName)
+	assert.Equal(t, token, issued[0].ContextMap()["token"])
+
+	// A failed reset keeps the token
+	resetErr := errors.New("user not found")
+	assert.ErrorIs(t, m.Redeem(token, "127.0.0.1", func() error { return resetErr }), resetErr)
+	assert.True(t, m.Active())
+
+	called := false
+	require.NoError(t, m.Redeem(token, "127.0.0.1", func() error { called = true; return nil }))
+	assert.True(t, called)
+	assert.False(t, m.Active(), "tokens are single use")
+	assert.ErrorIs(t, m.Redeem(token, "127.0.0.1", noop), ErrNoToken)
+	assert.Len(t, logs.FilterField(zap.String("event", "redeemed")).All(), 1)
+}
+
+func TestManager_RevokedAfterFailedAttempts(t *testing.T) {
+	m := New(zap.NewNop(), WithMaxAttempts(2))
+	token, err := m.Issue("test")
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, m.Redeem("wrong", "", noop), ErrInvalidToken)
+	assert.True(t, m.Active())
+	assert.ErrorIs(t, m.Redeem("wrong", "", noop), ErrInvalidToken)
+	assert.False(t, m.Active())
+	assert.ErrorIs(t, m.Redeem(token, "", noop), ErrNoToken)
+}
+
+func TestManager_Expiry(t *testing.T) {
+	m := New(zap.NewNop(), WithTTL(time.Minute))
+	now := time.Now()
+	m.now = func() time.Time { return now }
+	token, err := m.Issue("test")
+	require.NoError(t, err)
+
+	now = now.Add(2 * time.Minute)
+	assert.False(t, m.Active())
+	assert.ErrorIs(t, m.Redeem(token, "", noop), ErrInvalidToken)
+	assert.ErrorIs(t, m.Redeem(token, "", noop), ErrNoToken)
+}
+
+func TestManager_SyncFlagFile(t *testing.T) {
+	flagFile := filepath.Join(t.TempDir(), "recover")
+	m := New(zap.NewNop(), WithFlagFile(flagFile))
+
+	require.NoError(t, m.Sync())
+	assert.False(t, m.Active())
+
+	require.NoError(t, os.WriteFile(flagFile, nil, 0600))
+	require.NoError(t, m.Sync())
+	assert.True(t, m.Active())
+	_, err := os.Stat(flagFile)
+	assert.True(t, os.IsNotExist(err), "flag file is removed once the token is issued")
+}
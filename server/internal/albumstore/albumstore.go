@@ -0,0 +1,453 @@
+// Package albumstore persists albums: named, ordered collections of files
+// independent of the folder structure. Albums only reference file paths, so
+// a file can be in any number of albums without being copied.
+package albumstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cshum/imagor-studio/server/internal/model"
+	"github.com/cshum/imagor-studio/server/pkg/uuid"
+	"github.com/uptrace/bun"
+	"go.uber.org/zap"
+)
+
+const (
+	maxNameLength = 200
+	// MaxItems is the largest number of files in an album
+	MaxItems = 10000
+)
+
+var (
+	ErrNotFound = errors.New("album not found")
+	ErrInvalid  = errors.New("invalid album")
+)
+
+// Album is an album with its item count and cover, the first item
+type Album struct {
+	ID        string
+	Name      string
+	CreatedBy string
+	ItemCount int
+	// CoverPath is the path of the first item, empty for an empty album
+	CoverPath string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Item is a file in an album
+type Item struct {
+	Path    string
+	AddedAt time.Time
+}
+
+type Store interface {
+	// List returns every album in scope ordered by name
+	List(ctx context.Context, scope string) ([]*Album, error)
+	Get(ctx context.Context, scope, id string) (*Album, error)
+	Create(ctx context.Context, scope, name, createdBy string) (*Album, error)
+	Rename(ctx context.Context, scope, id, name string) (*Album, error)
+	// Delete removes an album, leaving its files in place
+	Delete(ctx context.Context, scope, id string) error
+	// Items returns the files of an album in order
+	Items(ctx context.Context, scope, id string) ([]Item, error)
+	// AddItems appends files to an album, skipping files already in it
+	AddItems(ctx context.Context, scope, id string, paths []string) (*Album, error)
+	// RemoveItems removes files from an album, ignoring files not in it
+	RemoveItems(ctx context.Context, scope, id string, paths []string) (*Album, error)
+	// Reorder sets the order of an album's files. paths must list every
+	// file of the album exactly once.
+	Reorder(ctx context.Context, scope, id string, paths []string) (*Album, error)
+	// MoveFilePath rewrites album items of a file, or of every file below a
+	// folder, after it has been moved
+	MoveFilePath(ctx context.Context, scope, oldPath, newPath string) error
+	// RemoveFilePath drops album items of a file, or of every file below a
+	// folder, after it has been deleted
+	RemoveFilePath(ctx context.Context, scope, path string) error
+}
+
+type store struct {
+	db     *bun.DB
+	logger *zap.Logger
+}
+
+func New(db *bun.DB, logger *zap.Logger) Store {
+	return &store{db: db, logger: logger}
+}
+
+// normalizeName trims an album name, rejecting empty and overlong names
+func normalizeName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("%w: name must not be empty", ErrInvalid)
+	}
+	if utf8.RuneCountInString(name) > maxNameLength {
+		return "", fmt.Errorf("%w: name must be at most %d characters", ErrInvalid, maxNameLength)
+	}
+	return name, nil
+}
+
+func (s *store) List(ctx context.Context, scope string) ([]*Album, error) {
+	var rows []model.Album
+	if err := s.db.NewSelect().Model(&rows).
+		Where("scope = ?", scope).
+		OrderExpr("LOWER(name) ASC, created_at ASC").
+		Scan(ctx); err != nil {
+		return nil, fmt.Errorf("error listing albums: %w", err)
+	}
+	return s.hydrate(ctx, s.db, rows)
+}
+
+func (s *store) Get(ctx context.Context, scope, id string) (*Album, error) {
+	row, err := getAlbum(ctx, s.db, scope, id)
+	if err != nil {
+		return nil, err
+	}
+	albums, err := s.hydrate(ctx, s.db, []model.Album{*row})
+	if err != nil {
+		return nil, err
+	}
+	return albums[0], nil
+}
+
+func (s *store) Create(ctx context.Context, scope, name, createdBy string) (*Album, error) {
+	name, err := normalizeName(name)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	row := &model.Album{
+		ID:        uuid.GenerateUUID(),
+		Scope:     scope,
+		Name:      name,
+		CreatedBy: createdBy,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if _, err := s.db.NewInsert().Model(row).Exec(ctx); err != nil {
+		return nil, fmt.Errorf("error creating album: %w", err)
+	}
+	return &Album{ID: row.ID, Name: row.Name, CreatedBy: createdBy, CreatedAt: now, UpdatedAt: now}, nil
+}
+
+func (s *store) Rename(ctx context.Context, scope, id, name string) (*Album, error) {
+	name, err := normalizeName(name)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.db.NewUpdate().Model((*model.Album)(nil)).
+		Set("name = ?", name).
+		Set("updated_at = ?", time.Now().UTC()).
+		Where("scope = ?", scope).
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error renaming album: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, ErrNotFound
+	}
+	return s.Get(ctx, scope, id)
+}
+
+func (s *store) Delete(ctx context.Context, scope, id string) error {
+	return s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		res, err := tx.NewDelete().Model((*model.Album)(nil)).
+			Where("scope = ?", scope).
+			Where("id = ?", id).
+			Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("error deleting album: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return ErrNotFound
+		}
+		if _, err := tx.NewDelete().Model((*model.AlbumItem)(nil)).
+			Where("album_id = ?", id).
+			Exec(ctx); err != nil {
+			return fmt.Errorf("error deleting album items: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *store) Items(ctx context.Context, scope, id string) ([]Item, error) {
+	if _, err := getAlbum(ctx, s.db, scope, id); err != nil {
+		return nil, err
+	}
+	rows, err := albumItems(ctx, s.db, id)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]Item, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, Item{Path: row.FilePath, AddedAt: row.AddedAt})
+	}
+	return items, nil
+}
+
+func (s *store) AddItems(ctx context.Context, scope, id string, paths []string) (*Album, error) {
+	err := s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := getAlbum(ctx, tx, scope, id); err != nil {
+			return err
+		}
+		rows, err := albumItems(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		existing := make(map[string]bool, len(rows))
+		position := 0
+		for _, row := range rows {
+			existing[row.FilePath] = true
+			position = max(position, row.Position+1)
+		}
+		now := time.Now().UTC()
+		var added []model.AlbumItem
+		for _, p := range paths {
+			if existing[p] {
+				continue
+			}
+			existing[p] = true
+			added = append(added, model.AlbumItem{
+				ID:       uuid.GenerateUUID(),
+				AlbumID:  id,
+				Scope:    scope,
+				FilePath: p,
+				Position: position,
+				AddedAt:  now,
+			})
+			position++
+		}
+		if len(added) == 0 {
+			return nil
+		}
+		if len(rows)+len(added) > MaxItems {
+			return fmt.Errorf("%w: albums hold at most %d files", ErrInvalid, MaxItems)
+		}
+		if _, err := tx.NewInsert().Model(&added).Exec(ctx); err != nil {
+			return fmt.Errorf("error adding album items: %w", err)
+		}
+		return touch(ctx, tx, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, scope, id)
+}
+
+func (s *store) RemoveItems(ctx context.Context, scope, id string, paths []string) (*Album, error) {
+	err := s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := getAlbum(ctx, tx, scope, id); err != nil {
+			return err
+		}
+		if len(paths) == 0 {
+			return nil
+		}
+		res, err := tx.NewDelete().Model((*model.AlbumItem)(nil)).
+			Where("album_id = ?", id).
+			Where("file_path IN (?)", bun.In(paths)).
+			Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("error removing album items: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return nil
+		}
+		return touch(ctx, tx, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, scope, id)
+}
+
+func (s *store) Reorder(ctx context.Context, scope, id string, paths []string) (*Album, error) {
+	err := s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := getAlbum(ctx, tx, scope, id); err != nil {
+			return err
+		}
+		rows, err := albumItems(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		ids := make(map[string]string, len(rows))
+		for _, row := range rows {
+			ids[row.FilePath] = row.ID
+		}
+		if len(paths) != len(rows) {
+			return fmt.Errorf("%w: order must list all %d files of the album", ErrInvalid, len(rows))
+		}
+		for position, p := range paths {
+			itemID, ok := ids[p]
+			if !ok {
+				return fmt.Errorf("%w: %q is not in the album or listed twice", ErrInvalid, p)
+			}
+			delete(ids, p)
+			if _, err := tx.NewUpdate().Model((*model.AlbumItem)(nil)).
+				Set("position = ?", position).
+				Where("id = ?", itemID).
+				Exec(ctx); err != nil {
+				return fmt.Errorf("error reordering album: %w", err)
+			}
+		}
+		return touch(ctx, tx, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, scope, id)
+}
+
+func (s *store) MoveFilePath(ctx context.Context, scope, oldPath, newPath string) error {
+	return s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		rows, err := itemsUnder(ctx, tx, scope, oldPath)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			moved := newPath + strings.TrimPrefix(row.FilePath, oldPath)
+			// The album may already contain the destination
+			exists, err := tx.NewSelect().Model((*model.AlbumItem)(nil)).
+				Where("album_id = ?", row.AlbumID).
+				Where("file_path = ?", moved).
+				Exists(ctx)
+			if err != nil {
+				return fmt.Errorf("error moving album items: %w", err)
+			}
+			if exists {
+				_, err = tx.NewDelete().Model((*model.AlbumItem)(nil)).
+					Where("id = ?", row.ID).
+					Exec(ctx)
+			} else {
+				_, err = tx.NewUpdate().Model((*model.AlbumItem)(nil)).
+					Set("file_path = ?", moved).
+					Where("id = ?", row.ID).
+					Exec(ctx)
+			}
+			if err != nil {
+				return fmt.Errorf("error moving album items: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+func (s *store) RemoveFilePath(ctx context.Context, scope, path string) error {
+	if _, err := s.db.NewDelete().Model((*model.AlbumItem)(nil)).
+		Where("scope = ?", scope).
+		Where("(file_path = ? OR substr(file_path, 1, ?) = ?)", path, len(path)+1, path+"/").
+		Exec(ctx); err != nil {
+		return fmt.Errorf("error removing album items: %w", err)
+	}
+	return nil
+}
+
+func getAlbum(ctx context.Context, db bun.IDB, scope, id string) (*model.Album, error) {
+	var row model.Album
+	err := db.NewSelect().Model(&row).
+		Where("scope = ?", scope).
+		Where("id = ?", id).
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error getting album: %w", err)
+	}
+	return &row, nil
+}
+
+// albumItems returns the items of an album in order
+func albumItems(ctx context.Context, db bun.IDB, albumID string) ([]model.AlbumItem, error) {
+	var rows []model.AlbumItem
+	if err := db.NewSelect().Model(&rows).
+		Where("album_id = ?", albumID).
+		Order("position ASC", "added_at ASC").
+		Scan(ctx); err != nil {
+		return nil, fmt.Errorf("error listing album items: %w", err)
+	}
+	return rows, nil
+}
+
+// itemsUnder returns album items of path itself and any file below it
+func itemsUnder(ctx context.Context, db bun.IDB, scope, path string) ([]model.AlbumItem, error) {
+	var rows []model.AlbumItem
+	if err := db.NewSelect().Model(&rows).
+		Where("scope = ?", scope).
+		Where("(file_path = ? OR substr(file_path, 1, ?) = ?)", path, len(path)+1, path+"/").
+		Scan(ctx); err != nil {
+		return nil, fmt.Errorf("error listing album items: %w", err)
+	}
+	return rows, nil
+}
+
+// touch bumps the update time of an album after its items changed
+func touch(ctx context.Context, db bun.IDB, id string) error {
+	if _, err := db.NewUpdate().Model((*model.Album)(nil)).
+		Set("updated_at = ?", time.Now().UTC()).
+		Where("id = ?", id).
+		Exec(ctx); err != nil {
+		return fmt.Errorf("error updating album: %w", err)
+	}
+	return nil
+}
+
+// hydrate adds item counts and covers to album rows
+func (s *store) hydrate(ctx context.Context, db bun.IDB, rows []model.Album) ([]*Album, error) {
+	albums := make([]*Album, 0, len(rows))
+	if len(rows) == 0 {
+		return albums, nil
+	}
+	ids := make([]string, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+	}
+	var counts []struct {
+		AlbumID string `bun:"album_id"`
+		Count   int    `bun:"count"`
+	}
+	if err := db.NewSelect().Model((*model.AlbumItem)(nil)).
+		ColumnExpr("album_id, COUNT(*) AS count").
+		Where("album_id IN (?)", bun.In(ids)).
+		GroupExpr("album_id").
+		Scan(ctx, &counts); err != nil {
+		return nil, fmt.Errorf("error counting album items: %w", err)
+	}
+	countByID := make(map[string]int, len(counts))
+	for _, c := range counts {
+		countByID[c.AlbumID] = c.Count
+	}
+	// The cover is the item with the lowest position of each album
+	var covers []model.AlbumItem
+	if err := db.NewSelect().Model(&covers).
+		Where("album_id IN (?)", bun.In(ids)).
+		Where("position = (?)", db.NewSelect().
+			TableExpr("album_items AS first").
+			ColumnExpr("MIN(first.position)").
+			Where("first.album_id = albi.album_id")).
+		Scan(ctx); err != nil {
+		return nil, fmt.Errorf("error getting album covers: %w", err)
+	}
+	coverByID := make(map[string]string, len(covers))
+	for _, c := range covers {
+		coverByID[c.AlbumID] = c.FilePath
+	}
+	for _, row := range rows {
+		albums = append(albums, &Album{
+			ID:        row.ID,
+			Name:      row.Name,
+			CreatedBy: row.CreatedBy,
+			ItemCount: countByID[row.ID],
+			CoverPath: coverByID[row.ID],
+			CreatedAt: row.CreatedAt,
+			UpdatedAt: row.UpdatedAt,
+		})
+	}
+	return albums, nil
+}
@@ -0,0 +1,121 @@
+package albumstore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const scope = "system:global"
+
+func setupTestStore(t *testing.T) Store {
+	t.Helper()
+	db := testutil.NewDB(t)
+
+	return New(db, zap.NewNop())
+}
+
+func itemPaths(t *testing.T, s Store, id string) []string {
+	t.Helper()
+	items, err := s.Items(context.Background(), scope, id)
+	require.NoError(t, err)
+	paths := make([]string, 0, len(items))
+	for _, item := range items {
+		paths = append(paths, item.Path)
+	}
+	return paths
+}
+
+func TestCreateRenameDelete(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	_, err := s.Create(ctx, scope, "  ", "user-1")
+	assert.ErrorIs(t, err, ErrInvalid)
+
+	trip, err := s.Create(ctx, scope, " Trip ", "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, "Trip", trip.Name)
+	_, err = s.Create(ctx, scope, "best of", "user-1")
+	require.NoError(t, err)
+	_, err = s.Create(ctx, "space:other", "Elsewhere", "user-1")
+	require.NoError(t, err)
+
+	albums, err := s.List(ctx, scope)
+	require.NoError(t, err)
+	require.Len(t, albums, 2)
+	assert.Equal(t, "best of", albums[0].Name)
+	assert.Equal(t, "Trip", albums[1].Name)
+
+	renamed, err := s.Rename(ctx, scope, trip.ID, "Summer trip")
+	require.NoError(t, err)
+	assert.Equal(t, "Summer trip", renamed.Name)
+	_, err = s.Rename(ctx, "space:other", trip.ID, "Nope")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, s.Delete(ctx, scope, trip.ID))
+	_, err = s.Get(ctx, scope, trip.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, s.Delete(ctx, scope, trip.ID), ErrNotFound)
+}
+
+func TestItems_AddRemoveReorder(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	album, err := s.Create(ctx, scope, "Trip", "user-1")
+	require.NoError(t, err)
+	other, err := s.Create(ctx, scope, "Other", "user-1")
+	require.NoError(t, err)
+
+	album, err = s.AddItems(ctx, scope, album.ID, []string{"a.jpg", "b.jpg", "a.jpg"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, album.ItemCount)
+	assert.Equal(t, "a.jpg", album.CoverPath)
+	album, err = s.AddItems(ctx, scope, album.ID, []string{"b.jpg", "c.jpg"})
+	require.NoError(t, err)
+	assert.Equal(t, 3, album.ItemCount)
+	assert.Equal(t, []string{"a.jpg", "b.jpg", "c.jpg"}, itemPaths(t, s, album.ID))
+
+	// The same file can be in several albums
+	_, err = s.AddItems(ctx, scope, other.ID, []string{"a.jpg"})
+	require.NoError(t, err)
+
+	_, err = s.Reorder(ctx, scope, album.ID, []string{"c.jpg", "a.jpg"})
+	assert.ErrorIs(t, err, ErrInvalid)
+	_, err = s.Reorder(ctx, scope, album.ID, []string{"c.jpg", "a.jpg", "a.jpg"})
+	assert.ErrorIs(t, err, ErrInvalid)
+	album, err = s.Reorder(ctx, scope, album.ID, []string{"c.jpg", "a.jpg", "b.jpg"})
+	require.NoError(t, err)
+	assert.Equal(t, "c.jpg", album.CoverPath)
+	assert.Equal(t, []string{"c.jpg", "a.jpg", "b.jpg"}, itemPaths(t, s, album.ID))
+
+	album, err = s.RemoveItems(ctx, scope, album.ID, []string{"c.jpg", "missing.jpg"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, album.ItemCount)
+	assert.Equal(t, "a.jpg", album.CoverPath)
+	assert.Equal(t, []string{"a.jpg"}, itemPaths(t, s, other.ID))
+
+	_, err = s.AddItems(ctx, scope, "missing", []string{"a.jpg"})
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestMoveAndRemoveFilePath(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	album, err := s.Create(ctx, scope, "Trip", "user-1")
+	require.NoError(t, err)
+	_, err = s.AddItems(ctx, scope, album.ID, []string{"photos/a.jpg", "photos/nested/b.jpg", "archive/a.jpg", "photos-2024/c.jpg"})
+	require.NoError(t, err)
+
+	require.NoError(t, s.MoveFilePath(ctx, scope, "photos", "archive"))
+	assert.Equal(t, []string{"archive/nested/b.jpg", "archive/a.jpg", "photos-2024/c.jpg"}, itemPaths(t, s, album.ID))
+
+	require.NoError(t, s.RemoveFilePath(ctx, scope, "archive"))
+	assert.Equal(t, []string{"photos-2024/c.jpg"}, itemPaths(t, s, album.ID))
+}
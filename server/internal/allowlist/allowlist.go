@@ -0,0 +1,247 @@
+// Package allowlist restricts the GraphQL root fields each role may execute.
+//
+// An allow-list is a set of root field paths such as "Query.listFiles",
+// "Mutation.uploadFile" or "Subscription.fileChanged", stored per role in the system registry so every
+// replica enforces the same lists. A role without an allow-list is not
+// restricted. The admin role can never be restricted, so an administrator
+// cannot lock themselves out of managing the lists. Introspection fields
+// (those starting with "__") are always allowed.
+package allowlist
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/pkg/auth"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+// KeyPrefix prefixes the registry key of each role's allow-list
+const KeyPrefix = "config.graphql_allowlist_"
+
+// Roles lists the roles that can be restricted
+var Roles = []string{"guest", "user"}
+
+var (
+	// ErrUnknownRole is returned for roles that cannot be restricted
+	ErrUnknownRole = errors.New("allow-lists can only be set for the guest and user roles")
+	// ErrInvalidField is returned for paths that are not a Query, Mutation or
+	// Subscription field
+	ErrInvalidField = errors.New("invalid root field")
+)
+
+// Key returns the registry key holding the allow-list of role
+func Key(role string) string {
+	return KeyPrefix + role
+}
+
+// IsRestrictable reports whether role can have an allow-list
+func IsRestrictable(role string) bool {
+	for _, r := range Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Store caches the allow-lists of the system registry
+type Store struct {
+	registryStore registrystore.Store
+	logger        *zap.Logger
+
+	mu    sync.RWMutex
+	lists map[string]map[string]struct{}
+}
+
+// New creates an allow-list store, call Sync to load the stored lists
+func New(registryStore registrystore.Store, logger *zap.Logger) *Store {
+	return &Store{
+		registryStore: registryStore,
+		logger:        logger,
+		lists:         make(map[string]map[string]struct{}),
+	}
+}
+
+// Sync reloads the allow-lists from the registry, so changes made on other
+// replicas take effect
+func (s *Store) Sync() error {
+	return s.Load(context.Background())
+}
+
+// Load reads the allow-lists of all roles from the registry
+func (s *Store) Load(ctx context.Context) error {
+	keys := make([]string, 0, len(Roles))
+	for _, role := range Roles {
+		keys = append(keys, Key(role))
+	}
+	entries, err := s.registryStore.GetMulti(ctx, registrystore.SystemOwnerID, keys)
+	if err != nil {
+		return fmt.Errorf("failed to load GraphQL allow-lists: %w", err)
+	}
+	lists := make(map[string]map[string]struct{})
+	for _, entry := range entries {
+		if entry == nil || !strings.HasPrefix(entry.Key, KeyPrefix) {
+			continue
+		}
+		var fields []string
+		if err := json.Unmarshal([]byte(entry.Value), &fields); err != nil {
+			s.logger.Warn("Ignoring malformed GraphQL allow-list", zap.String("key", entry.Key), zap.Error(err))
+			continue
+		}
+		lists[strings.TrimPrefix(entry.Key, KeyPrefix)] = toSet(fields)
+	}
+	s.mu.Lock()
+	s.lists = lists
+	s.mu.Unlock()
+	return nil
+}
+
+// Get returns the sorted allow-list of role, reporting whether role is restricted
+func (s *Store) Get(role string) ([]string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	set, ok := s.lists[role]
+	if !ok {
+		return nil, false
+	}
+	fields := make([]string, 0, len(set))
+	for field := range set {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields, true
+}
+
+// Set restricts role to fields. An empty list blocks every root field.
+func (s *Store) Set(ctx context.Context, role string, fields []string) ([]string, error) {
+	if !IsRestrictable(role) {
+		return nil, ErrUnknownRole
+	}
+	set := toSet(fields)
+	normalized := make([]string, 0, len(set))
+	for field := range set {
+		normalized = append(normalized, field)
+	}
+	sort.Strings(normalized)
+	data, err := json.Marshal(normalized)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.registryStore.Set(ctx, registrystore.SystemOwnerID, Key(role), string(data), false); err != nil {
+		return nil, fmt.Errorf("failed to save GraphQL allow-list: %w", err)
+	}
+	s.mu.Lock()
+	s.lists[role] = set
+	s.mu.Unlock()
+	return normalized, nil
+}
+
+// Clear lifts the restriction of role
+func (s *Store) Clear(ctx context.Context, role string) error {
+	if !IsRestrictable(role) {
+		return ErrUnknownRole
+	}
+	// DeleteMulti is idempotent, clearing an unrestricted role is not an error
+	if err := s.registryStore.DeleteMulti(ctx, registrystore.SystemOwnerID, []string{Key(role)}); err != nil {
+		return fmt.Errorf("failed to clear GraphQL allow-list: %w", err)
+	}
+	s.mu.Lock()
+	delete(s.lists, role)
+	s.mu.Unlock()
+	return nil
+}
+
+// Allowed reports whether role may execute the root field at path
+func (s *Store) Allowed(role, path string) bool {
+	if !IsRestrictable(role) {
+		return true
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	set, ok := s.lists[role]
+	if !ok {
+		return true
+	}
+	_, ok = set[path]
+	return ok
+}
+
+// Check returns a FORBIDDEN error when the role of the session of ctx may
+// not execute the root field at path. Requests without a session are not
+// checked. Transports other than GraphQL call it for the root field their
+// operation stands for, a nil Store allows everything.
+func (s *Store) Check(ctx context.Context, path string) error {
+	if s == nil {
+		return nil
+	}
+	claims, err := auth.GetClaimsFromContext(ctx)
+	if err != nil {
+		return nil
+	}
+	if !s.Allowed(claims.Role, path) {
+		return &gqlerror.Error{
+			Message:    "operation " + path + " is not allowed for role " + claims.Role,
+			Extensions: map[string]interface{}{"code": "FORBIDDEN"},
+		}
+	}
+	return nil
+}
+
+// FieldMiddleware rejects root fields outside the allow-list of the caller's role
+func (s *Store) FieldMiddleware() graphql.FieldMiddleware {
+	return func(ctx context.Context, next graphql.Resolver) (interface{}, error) {
+		fc := graphql.GetFieldContext(ctx)
+		if fc == nil || !isRootType(fc.Object) || strings.HasPrefix(fc.Field.Name, "__") {
+			return next(ctx)
+		}
+		if err := s.Check(ctx, fc.Object+"."+fc.Field.Name); err != nil {
+			return nil, err
+		}
+		return next(ctx)
+	}
+}
+
+// Validate checks that every path names a Query, Mutation or Subscription
+// field of schema
+func Validate(schema *ast.Schema, fields []string) error {
+	for _, field := range fields {
+		typeName, name, ok := strings.Cut(strings.TrimSpace(field), ".")
+		var def *ast.Definition
+		switch typeName {
+		case "Query":
+			def = schema.Query
+		case "Mutation":
+			def = schema.Mutation
+		case "Subscription":
+			def = schema.Subscription
+		}
+		if !ok || def == nil || def.Fields.ForName(name) == nil {
+			return fmt.Errorf("%w: %q", ErrInvalidField, field)
+		}
+	}
+	return nil
+}
+
+func isRootType(name string) bool {
+	return name == "Query" || name == "Mutation" || name == "Subscription"
+}
+
+func toSet(fields []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(fields))
+	for _, field := range fields {
+		if field = strings.TrimSpace(field); field != "" {
+			set[field] = struct{}{}
+		}
+	}
+	return set
+}
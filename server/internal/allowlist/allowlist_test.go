@@ -0,0 +1,165 @@
+package allowlist
+
+import (
+	"context"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/pkg/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+	"go.uber.org/zap"
+)
+
+// mockRegistryStore is an in-memory registry store for a single owner
+type mockRegistryStore struct {
+	data map[string]string
+}
+
+func newMockRegistryStore() *mockRegistryStore {
+	return &mockRegistryStore{data: make(map[string]string)}
+}
+
+func (m *mockRegistryStore) List(ctx context.Context, ownerID string, prefix *string) ([]*registrystore.Registry, error) {
+	return nil, nil
+}
+
+func (m *mockRegistryStore) Get(ctx context.Context, ownerID, key string) (*registrystore.Registry, error) {
+	if value, ok := m.data[key]; ok {
+		return &registrystore.Registry{Key: key, Value: value}, nil
+	}
+	return nil, nil
+}
+
+func (m *mockRegistryStore) GetMulti(ctx context.Context, ownerID string, keys []string) ([]*registrystore.Registry, error) {
+	var result []*registrystore.Registry
+	for _, key := range keys {
+		if value, ok := m.data[key]; ok {
+			result = append(result, &registrystore.Registry{Key: key, Value: value})
+		}
+	}
+	return result, nil
+}
+
+func (m *mockRegistryStore) Set(ctx context.Context, ownerID, key, value string, isEncrypted bool) (*registrystore.Registry, error) {
+	m.data[key] = value
+	return &registrystore.Registry{Key: key, Value: value}, nil
+}
+
+func (m *mockRegistryStore) SetMulti(ctx context.Context, ownerID string, entries []*registrystore.Registry) ([]*registrystore.Registry, error) {
+	for _, entry := range entries {
+		m.data[entry.Key] = entry.Value
+	}
+	return entries, nil
+}
+
+func (m *mockRegistryStore) Delete(ctx context.Context, ownerID, key string) error {
+	delete(m.data, key)
+	return nil
+}
+
+func (m *mockRegistryStore) DeleteMulti(ctx context.Context, ownerID string, keys []string) error {
+	for _, key := range keys {
+		delete(m.data, key)
+	}
+	return nil
+}
+
+func TestStore_SetAndAllowed(t *testing.T) {
+	ctx := context.Background()
+	registry := newMockRegistryStore()
+	s := New(registry, zap.NewNop())
+
+	assert.True(t, s.Allowed("guest", "Mutation.deleteFile"), "roles without a list are unrestricted")
+
+	fields, err := s.Set(ctx, "guest", []string{" Query.listFiles", "Query.statFile", "Query.listFiles", ""})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Query.listFiles", "Query.statFile"}, fields)
+	assert.JSONEq(t, `["Query.listFiles","Query.statFile"]`, registry.data[Key("guest")])
+
+	assert.True(t, s.Allowed("guest", "Query.listFiles"))
+	assert.False(t, s.Allowed("guest", "Mutation.deleteFile"))
+	assert.True(t, s.Allowed("user", "Mutation.deleteFile"))
+	assert.True(t, s.Allowed("admin", "Mutation.deleteFile"))
+
+	_, err = s.Set(ctx, "admin", []string{"Query.listFiles"})
+	assert.ErrorIs(t, err, ErrUnknownRole)
+
+	require.NoError(t, s.Clear(ctx, "guest"))
+	assert.True(t, s.Allowed("guest", "Mutation.deleteFile"))
+	_, restricted := s.Get("guest")
+	assert.False(t, restricted)
+}
+
+func TestStore_Load(t *testing.T) {
+	registry := newMockRegistryStore()
+	registry.data[Key("guest")] = `[]`
+	registry.data[Key("user")] = `not json`
+	s := New(registry, zap.NewNop())
+	require.NoError(t, s.Sync())
+
+	fields, restricted := s.Get("guest")
+	assert.True(t, restricted)
+	assert.Empty(t, fields)
+	assert.False(t, s.Allowed("guest", "Query.listFiles"), "an empty list blocks every field")
+
+	_, restricted = s.Get("user")
+	assert.False(t, restricted, "malformed lists are ignored")
+}
+
+func TestStore_FieldMiddleware(t *testing.T) {
+	ctx := context.Background()
+	s := New(newMockRegistryStore(), zap.NewNop())
+	_, err := s.Set(ctx, "guest", []string{"Query.listFiles"})
+	require.NoError(t, err)
+	middleware := s.FieldMiddleware()
+	next := func(ctx context.Context) (interface{}, error) { return "ok", nil }
+
+	call := func(role, object, field string) error {
+		fieldCtx := graphql.WithFieldContext(ctx, &graphql.FieldContext{
+			Object: object,
+			Field:  graphql.CollectedField{Field: &ast.Field{Name: field}},
+		})
+		if role != "" {
+			fieldCtx = auth.SetClaimsInContext(fieldCtx, &auth.Claims{Role: role})
+		}
+		_, err := middleware(fieldCtx, next)
+		return err
+	}
+
+	assert.NoError(t, call("guest", "Query", "listFiles"))
+	assert.NoError(t, call("guest", "Query", "__schema"))
+	assert.NoError(t, call("guest", "FileItem", "name"), "only root fields are checked")
+	assert.NoError(t, call("admin", "Mutation", "deleteFile"))
+	assert.NoError(t, call("", "Mutation", "deleteFile"), "requests without claims are left to the resolvers")
+	assert.ErrorContains(t, call("guest", "Mutation", "deleteFile"), "not allowed for role guest")
+}
+
+func TestValidate(t *testing.T) {
+	schema := gqlparser.MustLoadSchema(&ast.Source{Input: `
+		type Query { listFiles: String }
+		type Mutation { deleteFile: Boolean }
+		type Subscription { fileChanged: String }
+	`})
+	assert.NoError(t, Validate(schema, []string{"Query.listFiles", "Mutation.deleteFile", "Subscription.fileChanged"}))
+	assert.ErrorIs(t, Validate(schema, []string{"Query.deleteFile"}), ErrInvalidField)
+	assert.ErrorIs(t, Validate(schema, []string{"listFiles"}), ErrInvalidField)
+	assert.ErrorIs(t, Validate(schema, []string{"Subscription.x"}), ErrInvalidField)
+}
+
+func TestStore_Check(t *testing.T) {
+	ctx := context.Background()
+	s := New(newMockRegistryStore(), zap.NewNop())
+	_, err := s.Set(ctx, "user", []string{"Query.listFiles"})
+	require.NoError(t, err)
+	userCtx := auth.SetClaimsInContext(ctx, &auth.Claims{Role: "user"})
+
+	assert.NoError(t, s.Check(userCtx, "Query.listFiles"))
+	assert.ErrorContains(t, s.Check(userCtx, "Mutation.uploadFile"), "not allowed for role user")
+	assert.NoError(t, s.Check(ctx, "Mutation.uploadFile"), "requests without claims are left to the handlers")
+	var nilStore *Store
+	assert.NoError(t, nilStore.Check(userCtx, "Mutation.uploadFile"))
+}
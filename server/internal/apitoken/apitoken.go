@@ -0,0 +1,291 @@
+// Package apitoken persists personal access tokens: long-lived tokens users
+// mint for scripts, accepted on the GraphQL endpoint alongside JWT sessions.
+//
+// Tokens are random strings starting with TokenPrefix. Only their SHA-256
+// hash is stored, so a token is shown once when created. A token session
+// acts as its user with the role the user has when the request is made,
+// limited to the scopes granted to the token.
+package apitoken
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cshum/imagor-studio/server/internal/model"
+	"github.com/cshum/imagor-studio/server/pkg/auth"
+	"github.com/cshum/imagor-studio/server/pkg/uuid"
+	"github.com/uptrace/bun"
+	"go.uber.org/zap"
+)
+
+const (
+	// TokenPrefix starts every token, telling them apart from JWTs
+	TokenPrefix = "ist_"
+	// MaxTokensPerUser caps the tokens a user can hold at once
+	MaxTokensPerUser = 50
+	// MaxNameLength is the longest token name in characters
+	MaxNameLength = 100
+
+	// displayPrefixLength is how much of a token is kept to identify it
+	displayPrefixLength = len(TokenPrefix) + 6
+	// lastUsedInterval throttles last use updates to one per interval
+	lastUsedInterval = time.Minute
+)
+
+// Scopes lists the scopes a token can be granted
+var Scopes = []string{"read", "write", "admin"}
+
+var (
+	ErrNotFound = errors.New("API token not found")
+	ErrInvalid  = errors.New("invalid API token")
+	// ErrUnauthorized is returned by Authenticate for unknown, expired or
+	// revoked tokens and tokens of deactivated users
+	ErrUnauthorized = errors.New("invalid or expired API token")
+)
+
+// Token is a personal access token, without its secret
+type Token struct {
+	ID     string
+	UserID string
+	Name   string
+	// Prefix is the start of the token, shown to tell tokens apart
+	Prefix string
+	Scopes []string
+	// ExpiresAt is nil for tokens that never expire
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	CreatedAt  time.Time
+}
+
+type Store interface {
+	// Create mints a token for a user, returning it with its secret. orgID
+	// is the organization of the session creating it, empty when self-hosted.
+	Create(ctx context.Context, userID, orgID, name string, scopes []string, expiresAt *time.Time) (*Token, string, error)
+	// List returns the tokens of a user, newest first
+	List(ctx context.Context, userID string) ([]*Token, error)
+	// Revoke deletes a token of a user
+	Revoke(ctx context.Context, userID, id string) error
+	// Authenticate returns the session claims of a token
+	Authenticate(ctx context.Context, secret string) (*auth.Claims, error)
+}
+
+type store struct {
+	db     *bun.DB
+	logger *zap.Logger
+}
+
+func New(db *bun.DB, logger *zap.Logger) Store {
+	return &store{db: db, logger: logger}
+}
+
+// IsToken reports whether s has the form of an API token rather than a JWT
+func IsToken(s string) bool {
+	return strings.HasPrefix(s, TokenPrefix)
+}
+
+func hashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func generateToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate API token: %w", err)
+	}
+	return TokenPrefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// normalizeScopes validates and de-duplicates scopes, keeping the order of
+// Scopes
+func normalizeScopes(scopes []string) ([]string, error) {
+	requested := make(map[string]bool, len(scopes))
+	for _, scope := range scopes {
+		requested[strings.TrimSpace(scope)] = true
+	}
+	normalized := make([]string, 0, len(requested))
+	for _, scope := range Scopes {
+		if requested[scope] {
+			normalized = append(normalized, scope)
+			delete(requested, scope)
+		}
+	}
+	for scope := range requested {
+		return nil, fmt.Errorf("%w: unknown scope %q", ErrInvalid, scope)
+	}
+	if len(normalized) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", ErrInvalid)
+	}
+	return normalized, nil
+}
+
+// roleScopes returns the scopes a session of role is granted at login
+func roleScopes(role string) []string {
+	if role == "admin" {
+		return []string{"read", "write", "admin"}
+	}
+	return []string{"read", "write"}
+}
+
+func (s *store) Create(ctx context.Context, userID, orgID, name string, scopes []string, expiresAt *time.Time) (*Token, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", fmt.Errorf("%w: name must not be empty", ErrInvalid)
+	}
+	if utf8.RuneCountInString(name) > MaxNameLength {
+		return nil, "", fmt.Errorf("%w: name must be at most %d characters", ErrInvalid, MaxNameLength)
+	}
+	scopes, err := normalizeScopes(scopes)
+	if err != nil {
+		return nil, "", err
+	}
+	now := time.Now().UTC()
+	if expiresAt != nil {
+		if !expiresAt.After(now) {
+			return nil, "", fmt.Errorf("%w: expiresAt must be in the future", ErrInvalid)
+		}
+		utc := expiresAt.UTC()
+		expiresAt = &utc
+	}
+	count, err := s.db.NewSelect().Model((*model.APIToken)(nil)).Where("user_id = ?", userID).Count(ctx)
+	if err != nil {
+		return nil, "", fmt.Errorf("error counting API tokens: %w", err)
+	}
+	if count >= MaxTokensPerUser {
+		return nil, "", fmt.Errorf("%w: at most %d tokens per user, revoke unused ones", ErrInvalid, MaxTokensPerUser)
+	}
+	secret, err := generateToken()
+	if err != nil {
+		return nil, "", err
+	}
+	row := &model.APIToken{
+		ID:        uuid.GenerateUUID(),
+		UserID:    userID,
+		OrgID:     orgID,
+		Name:      name,
+		TokenHash: hashToken(secret),
+		Prefix:    secret[:displayPrefixLength],
+		Scopes:    strings.Join(scopes, ","),
+		ExpiresAt: expiresAt,
+		CreatedAt: now,
+	}
+	if _, err := s.db.NewInsert().Model(row).Exec(ctx); err != nil {
+		return nil, "", fmt.Errorf("error creating API token: %w", err)
+	}
+	return toToken(row), secret, nil
+}
+
+func (s *store) List(ctx context.Context, userID string) ([]*Token, error) {
+	var rows []model.APIToken
+	if err := s.db.NewSelect().Model(&rows).
+		Where("user_id = ?", userID).
+		Order("created_at DESC", "id DESC").
+		Scan(ctx); err != nil {
+		return nil, fmt.Errorf("error listing API tokens: %w", err)
+	}
+	tokens := make([]*Token, 0, len(rows))
+	for i := range rows {
+		tokens = append(tokens, toToken(&rows[i]))
+	}
+	return tokens, nil
+}
+
+func (s *store) Revoke(ctx context.Context, userID, id string) error {
+	res, err := s.db.NewDelete().Model((*model.APIToken)(nil)).
+		Where("id = ?", id).
+		Where("user_id = ?", userID).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("error revoking API token: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *store) Authenticate(ctx context.Context, secret string) (*auth.Claims, error) {
+	if !IsToken(secret) {
+		return nil, ErrUnauthorized
+	}
+	var row model.APIToken
+	if err := s.db.NewSelect().Model(&row).
+		Where("token_hash = ?", hashToken(secret)).
+		Scan(ctx); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUnauthorized
+		}
+		return nil, fmt.Errorf("error authenticating API token: %w", err)
+	}
+	now := time.Now().UTC()
+	if row.ExpiresAt != nil && !now.Before(*row.ExpiresAt) {
+		return nil, ErrUnauthorized
+	}
+	var user model.User
+	if err := s.db.NewSelect().Model(&user).
+		Column("id", "role", "is_active").
+		Where("id = ?", row.UserID).
+		Scan(ctx); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUnauthorized
+		}
+		return nil, fmt.Errorf("error authenticating API token: %w", err)
+	}
+	if !user.IsActive {
+		return nil, ErrUnauthorized
+	}
+	// Scopes the user lost since the token was created are not granted
+	allowed := make(map[string]bool)
+	for _, scope := range roleScopes(user.Role) {
+		allowed[scope] = true
+	}
+	var scopes []string
+	for _, scope := range strings.Split(row.Scopes, ",") {
+		if allowed[scope] {
+			scopes = append(scopes, scope)
+		}
+	}
+	if len(scopes) == 0 {
+		return nil, ErrUnauthorized
+	}
+	if row.LastUsedAt == nil || now.Sub(*row.LastUsedAt) >= lastUsedInterval {
+		if _, err := s.db.NewUpdate().Model((*model.APIToken)(nil)).
+			Set("last_used_at = ?", now).
+			Where("id = ?", row.ID).
+			Exec(ctx); err != nil {
+			s.logger.Warn("Failed to record API token use", zap.String("id", row.ID), zap.Error(err))
+		}
+	}
+	claims := &auth.Claims{
+		UserID: row.UserID,
+		OrgID:  row.OrgID,
+		Role:   user.Role,
+		Scopes: scopes,
+		Kind:   auth.APITokenKind,
+	}
+	claims.Subject = row.UserID
+	claims.ID = row.ID
+	return claims, nil
+}
+
+func toToken(row *model.APIToken) *Token {
+	return &Token{
+		ID:         row.ID,
+		UserID:     row.UserID,
+		Name:       row.Name,
+		Prefix:     row.Prefix,
+		Scopes:     strings.Split(row.Scopes, ","),
+		ExpiresAt:  row.ExpiresAt,
+		LastUsedAt: row.LastUsedAt,
+		CreatedAt:  row.CreatedAt,
+	}
+}
@@ -0,0 +1,119 @@
+package apitoken
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/model"
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/cshum/imagor-studio/server/pkg/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+	"go.uber.org/zap"
+)
+
+func setupTestStore(t *testing.T) (Store, *bun.DB) {
+	t.Helper()
+	db := testutil.NewDB(t)
+
+	for _, u := range []*model.User{
+		{ID: "alice", Username: "alice", DisplayName: "Alice", Role: "admin", IsActive: true},
+		{ID: "bob", Username: "bob", DisplayName: "Bob", Role: "user", IsActive: true},
+	} {
+		_, err := db.NewInsert().Model(u).Exec(context.Background())
+		require.NoError(t, err)
+	}
+	return New(db, zap.NewNop()), db
+}
+
+func TestCreateListRevoke(t *testing.T) {
+	s, _ := setupTestStore(t)
+	ctx := context.Background()
+
+	_, _, err := s.Create(ctx, "alice", "", " ", []string{"read"}, nil)
+	assert.ErrorIs(t, err, ErrInvalid)
+	_, _, err = s.Create(ctx, "alice", "", "cron", []string{"root"}, nil)
+	assert.ErrorIs(t, err, ErrInvalid)
+	_, _, err = s.Create(ctx, "alice", "", "cron", nil, nil)
+	assert.ErrorIs(t, err, ErrInvalid)
+	past := time.Now().Add(-time.Hour)
+	_, _, err = s.Create(ctx, "alice", "", "cron", []string{"read"}, &past)
+	assert.ErrorIs(t, err, ErrInvalid)
+
+	token, secret, err := s.Create(ctx, "alice", "", " camera import ", []string{"write", "read", "write"}, nil)
+	require.NoError(t, err)
+	assert.True(t, IsToken(secret))
+	assert.Equal(t, "camera import", token.Name)
+	assert.Equal(t, []string{"read", "write"}, token.Scopes)
+	assert.Equal(t, secret[:len(token.Prefix)], token.Prefix)
+	assert.Nil(t, token.ExpiresAt)
+
+	tokens, err := s.List(ctx, "alice")
+	require.NoError(t, err)
+	require.Len(t, tokens, 1)
+	assert.Equal(t, token.ID, tokens[0].ID)
+	tokens, err = s.List(ctx, "bob")
+	require.NoError(t, err)
+	assert.Empty(t, tokens)
+
+	assert.ErrorIs(t, s.Revoke(ctx, "bob", token.ID), ErrNotFound)
+	require.NoError(t, s.Revoke(ctx, "alice", token.ID))
+	_, err = s.Authenticate(ctx, secret)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+}
+
+func TestAuthenticate(t *testing.T) {
+	s, db := setupTestStore(t)
+	ctx := context.Background()
+
+	_, secret, err := s.Create(ctx, "bob", "org-1", "cron", []string{"read", "write"}, nil)
+	require.NoError(t, err)
+	claims, err := s.Authenticate(ctx, secret)
+	require.NoError(t, err)
+	assert.Equal(t, "bob", claims.UserID)
+	assert.Equal(t, "org-1", claims.OrgID)
+	assert.Equal(t, "user", claims.Role)
+	assert.Equal(t, []string{"read", "write"}, claims.Scopes)
+	assert.Equal(t, auth.APITokenKind, claims.Kind)
+	tokens, err := s.List(ctx, "bob")
+	require.NoError(t, err)
+	assert.NotNil(t, tokens[0].LastUsedAt)
+
+	_, err = s.Authenticate(ctx, secret+"x")
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	_, err = s.Authenticate(ctx, "not-a-token")
+	assert.ErrorIs(t, err, ErrUnauthorized)
+
+	// Scopes follow the current role of the user
+	_, adminSecret, err := s.Create(ctx, "alice", "", "admin script", []string{"read", "admin"}, nil)
+	require.NoError(t, err)
+	_, err = db.NewUpdate().Model((*model.User)(nil)).Set("role = ?", "user").Where("id = ?", "alice").Exec(ctx)
+	require.NoError(t, err)
+	claims, err = s.Authenticate(ctx, adminSecret)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"read"}, claims.Scopes)
+
+	// Deactivated users lose their tokens
+	_, err = db.NewUpdate().Model((*model.User)(nil)).Set("is_active = ?", false).Where("id = ?", "bob").Exec(ctx)
+	require.NoError(t, err)
+	_, err = s.Authenticate(ctx, secret)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+}
+
+func TestAuthenticate_Expired(t *testing.T) {
+	s, db := setupTestStore(t)
+	ctx := context.Background()
+
+	expiresAt := time.Now().Add(time.Hour)
+	token, secret, err := s.Create(ctx, "bob", "", "cron", []string{"read"}, &expiresAt)
+	require.NoError(t, err)
+	_, err = s.Authenticate(ctx, secret)
+	require.NoError(t, err)
+
+	_, err = db.NewUpdate().Model((*model.APIToken)(nil)).Set("expires_at = ?", time.Now().Add(-time.Minute).UTC()).Where("id = ?", token.ID).Exec(ctx)
+	require.NoError(t, err)
+	_, err = s.Authenticate(ctx, secret)
+	assert.ErrorIs(t, err, ErrUnauthorized)
+}
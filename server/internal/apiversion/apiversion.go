@@ -0,0 +1,183 @@
+// Package apiversion tracks the public GraphQL API version, its changelog and
+// the deprecated schema elements still served for older clients.
+//
+// The schema is the contract: renamed or retired fields stay in the schema
+// with a @deprecated directive and a DEPRECATED changelog entry until they
+// are REMOVED in a later version. Compatibility mode, on by default, keeps
+// serving those fields so embedded frontends pinned to an older release keep
+// working; strict mode rejects them so integrators can test against the
+// current contract.
+package apiversion
+
+import (
+	"context"
+	"sort"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Current is the API version served by this build. Bump it together with a
+// changelog entry whenever the schema changes.
+const Current = 2
+
+// ChangeKind classifies a schema change
+type ChangeKind string
+
+const (
+	ChangeAdded      ChangeKind = "added"
+	ChangeChanged    ChangeKind = "changed"
+	ChangeDeprecated ChangeKind = "deprecated"
+	ChangeRemoved    ChangeKind = "removed"
+)
+
+// Change is a single changelog entry. Path uses Type.field or
+// Type.field(arg) notation, e.g. "Query.listFiles(systemTags)".
+type Change struct {
+	Version     int
+	Kind        ChangeKind
+	Path        string
+	Description string
+	// Replacement names the schema element to migrate to, for deprecations
+	Replacement string
+}
+
+// Deprecation describes a deprecated schema element still being served
+type Deprecation struct {
+	Path   string
+	Reason string
+	// DeprecatedIn and Replacement come from the matching changelog entry,
+	// zero values when the changelog has none
+	DeprecatedIn int
+	Replacement  string
+}
+
+// ChangesSince returns changelog entries newer than version, oldest first
+func ChangesSince(version int) []Change {
+	changes := make([]Change, 0, len(Changelog))
+	for _, change := range Changelog {
+		if change.Version > version {
+			changes = append(changes, change)
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Version < changes[j].Version
+	})
+	return changes
+}
+
+// Deprecations lists every deprecated field, argument and enum value in the
+// schema, sorted by path
+func Deprecations(schema *ast.Schema) []Deprecation {
+	if schema == nil {
+		return nil
+	}
+	deprecatedIn := make(map[string]Change)
+	for _, change := range Changelog {
+		if change.Kind == ChangeDeprecated {
+			deprecatedIn[change.Path] = change
+		}
+	}
+
+	var result []Deprecation
+	add := func(path string, directives ast.DirectiveList) {
+		reason, ok := deprecationReason(directives)
+		if !ok {
+			return
+		}
+		deprecation := Deprecation{Path: path, Reason: reason}
+		if change, ok := deprecatedIn[path]; ok {
+			deprecation.DeprecatedIn = change.Version
+			deprecation.Replacement = change.Replacement
+		}
+		result = append(result, deprecation)
+	}
+
+	for name, def := range schema.Types {
+		if def.BuiltIn {
+			continue
+		}
+		for _, field := range def.Fields {
+			fieldPath := name + "." + field.Name
+			add(fieldPath, field.Directives)
+			for _, arg := range field.Arguments {
+				add(fieldPath+"("+arg.Name+")", arg.Directives)
+			}
+		}
+		for _, value := range def.EnumValues {
+			add(name+"."+value.Name, value.Directives)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Path < result[j].Path
+	})
+	return result
+}
+
+// FieldMiddleware rejects deprecated fields, and deprecated arguments given
+// a value, when compatibility mode is off. Introspection fields are always
+// allowed.
+func FieldMiddleware(compatMode bool) graphql.FieldMiddleware {
+	return func(ctx context.Context, next graphql.Resolver) (interface{}, error) {
+		if compatMode {
+			return next(ctx)
+		}
+		fc := graphql.GetFieldContext(ctx)
+		if fc == nil || fc.Field.Definition == nil {
+			return next(ctx)
+		}
+		if reason, ok := deprecationReason(fc.Field.Definition.Directives); ok {
+			return nil, &gqlerror.Error{
+				Message: "field " + fc.Object + "." + fc.Field.Name + " is deprecated and disabled by strict API mode: " + reason,
+				Extensions: map[string]interface{}{
+					"code":       "DEPRECATED_FIELD",
+					"apiVersion": Current,
+				},
+			}
+		}
+		for _, arg := range fc.Field.Arguments {
+			def := fc.Field.Definition.Arguments.ForName(arg.Name)
+			if def == nil || !argumentGiven(ctx, arg.Value) {
+				continue
+			}
+			if reason, ok := deprecationReason(def.Directives); ok {
+				return nil, &gqlerror.Error{
+					Message: "argument " + fc.Object + "." + fc.Field.Name + "(" + arg.Name + ") is deprecated and disabled by strict API mode: " + reason,
+					Extensions: map[string]interface{}{
+						"code":       "DEPRECATED_ARGUMENT",
+						"apiVersion": Current,
+					},
+				}
+			}
+		}
+		return next(ctx)
+	}
+}
+
+// argumentGiven reports whether an argument is given a value, null literals
+// and variables left unset or null counting as not given
+func argumentGiven(ctx context.Context, value *ast.Value) bool {
+	if value == nil || value.Kind == ast.NullValue {
+		return false
+	}
+	if value.Kind != ast.Variable {
+		return true
+	}
+	if !graphql.HasOperationContext(ctx) {
+		return true
+	}
+	return graphql.GetOperationContext(ctx).Variables[value.Raw] != nil
+}
+
+func deprecationReason(directives ast.DirectiveList) (string, bool) {
+	directive := directives.ForName("deprecated")
+	if directive == nil {
+		return "", false
+	}
+	reason := "No longer supported"
+	if arg := directive.Arguments.ForName("reason"); arg != nil && arg.Value != nil && arg.Value.Raw != "" {
+		reason = arg.Value.Raw
+	}
+	return reason, true
+}
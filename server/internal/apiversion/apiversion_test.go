@@ -0,0 +1,171 @@
+package apiversion
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+const testSchema = `
+type Query {
+  files(path: String, dir: String @deprecated(reason: "Use path")): [String!]!
+  listImages: [String!]! @deprecated(reason: "Use files")
+  legacy: String @deprecated
+}
+
+enum Sort {
+  NAME
+  DATE @deprecated(reason: "Use MODIFIED_TIME")
+  MODIFIED_TIME
+}
+`
+
+func TestDeprecations(t *testing.T) {
+	schema := gqlparser.MustLoadSchema(&ast.Source{Name: "test.graphql", Input: testSchema})
+
+	saved := Changelog
+	t.Cleanup(func() { Changelog = saved })
+	Changelog = []Change{
+		{Version: 3, Kind: ChangeDeprecated, Path: "Query.listImages", Replacement: "Query.files"},
+	}
+
+	deprecations := Deprecations(schema)
+	require.Len(t, deprecations, 4)
+	assert.Equal(t, Deprecation{Path: "Query.files(dir)", Reason: "Use path"}, deprecations[0])
+	assert.Equal(t, Deprecation{Path: "Query.legacy", Reason: "No longer supported"}, deprecations[1])
+	assert.Equal(t, Deprecation{Path: "Query.listImages", Reason: "Use files", DeprecatedIn: 3, Replacement: "Query.files"}, deprecations[2])
+	assert.Equal(t, Deprecation{Path: "Sort.DATE", Reason: "Use MODIFIED_TIME"}, deprecations[3])
+
+	assert.Nil(t, Deprecations(nil))
+}
+
+func TestChangesSince(t *testing.T) {
+	saved := Changelog
+	t.Cleanup(func() { Changelog = saved })
+	Changelog = []Change{
+		{Version: 3, Kind: ChangeAdded, Path: "Query.c"},
+		{Version: 2, Kind: ChangeAdded, Path: "Query.b"},
+		{Version: 1, Kind: ChangeAdded, Path: "Query.a"},
+	}
+
+	changes := ChangesSince(1)
+	require.Len(t, changes, 2)
+	assert.Equal(t, "Query.b", changes[0].Path)
+	assert.Equal(t, "Query.c", changes[1].Path)
+	assert.Len(t, ChangesSince(0), 3)
+	assert.Empty(t, ChangesSince(Current+100))
+}
+
+// Every changelog entry must point at an element in the served schema,
+// unless it records a removal
+func TestChangelogMatchesSchema(t *testing.T) {
+	schema := gql.NewExecutableSchema(gql.Config{}).Schema()
+	for _, change := range Changelog {
+		assert.LessOrEqual(t, change.Version, Current, change.Path)
+		if change.Kind == ChangeRemoved {
+			continue
+		}
+		typeName, rest, ok := strings.Cut(change.Path, ".")
+		require.True(t, ok, change.Path)
+		def := schema.Types[typeName]
+		require.NotNil(t, def, change.Path)
+		fieldName, arg, hasArg := strings.Cut(strings.TrimSuffix(rest, ")"), "(")
+		if def.Kind == ast.Enum {
+			assert.NotNil(t, def.EnumValues.ForName(fieldName), change.Path)
+			continue
+		}
+		field := def.Fields.ForName(fieldName)
+		require.NotNil(t, field, change.Path)
+		if hasArg {
+			assert.NotNil(t, field.Arguments.ForName(arg), change.Path)
+		}
+	}
+}
+
+func TestFieldMiddleware(t *testing.T) {
+	schema := gqlparser.MustLoadSchema(&ast.Source{Name: "test.graphql", Input: testSchema})
+	query := schema.Query
+	next := func(ctx context.Context) (interface{}, error) { return "ok", nil }
+
+	fieldContext := func(name string) context.Context {
+		return graphql.WithFieldContext(context.Background(), &graphql.FieldContext{
+			Object: "Query",
+			Field: graphql.CollectedField{Field: &ast.Field{
+				Name:       name,
+				Definition: query.Fields.ForName(name),
+			}},
+		})
+	}
+
+	// Compatibility mode serves deprecated fields
+	res, err := FieldMiddleware(true)(fieldContext("listImages"), next)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", res)
+
+	// Strict mode rejects them
+	_, err = FieldMiddleware(false)(fieldContext("listImages"), next)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "DEPRECATED_FIELD", gqlErr.Extensions["code"])
+	assert.Contains(t, gqlErr.Message, "Use files")
+
+	// Current fields pass through in strict mode
+	res, err = FieldMiddleware(false)(fieldContext("files"), next)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", res)
+}
+
+func TestFieldMiddleware_DeprecatedArguments(t *testing.T) {
+	schema := gql.NewExecutableSchema(gql.Config{}).Schema()
+	next := func(ctx context.Context) (interface{}, error) { return "ok", nil }
+
+	fieldContext := func(query string, variables map[string]interface{}) context.Context {
+		doc, errs := gqlparser.LoadQuery(schema, query)
+		require.Empty(t, errs, query)
+		field := doc.Operations[0].SelectionSet[0].(*ast.Field)
+		ctx := graphql.WithOperationContext(context.Background(), &graphql.OperationContext{Variables: variables})
+		return graphql.WithFieldContext(ctx, &graphql.FieldContext{
+			Object: "Query",
+			Field:  graphql.CollectedField{Field: field},
+		})
+	}
+
+	// The offset arguments of the served schema are deprecated
+	ctx := fieldContext(`{ listFiles(path: "", offset: 20) { totalCount } }`, nil)
+	_, err := FieldMiddleware(false)(ctx, next)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "DEPRECATED_ARGUMENT", gqlErr.Extensions["code"])
+	assert.Contains(t, gqlErr.Message, "Query.listFiles(offset)")
+	res, err := FieldMiddleware(true)(ctx, next)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", res)
+
+	query := `query($offset: Int) { users(offset: $offset) { totalCount } }`
+	_, err = FieldMiddleware(false)(fieldContext(query, map[string]interface{}{"offset": 10}), next)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "DEPRECATED_ARGUMENT", gqlErr.Extensions["code"])
+
+	// Arguments left out, null or unset pass, even when they have a default
+	for _, tc := range []struct {
+		query     string
+		variables map[string]interface{}
+	}{
+		{`{ users { totalCount } }`, nil},
+		{`{ listFiles(path: "", offset: null, limit: 10) { totalCount } }`, nil},
+		{query, nil},
+		{query, map[string]interface{}{"offset": nil}},
+	} {
+		res, err := FieldMiddleware(false)(fieldContext(tc.query, tc.variables), next)
+		require.NoError(t, err, tc.query)
+		assert.Equal(t, "ok", res)
+	}
+}
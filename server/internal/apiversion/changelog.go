@@ -0,0 +1,209 @@
+package apiversion
+
+// Changelog lists schema changes by API version. Version 1 is the schema as
+// first published; keep entries append-only.
+var Changelog = []Change{
+	{Version: 2, Kind: ChangeAdded, Path: "Query.listFiles(systemTags)", Description: "Filter files by system classification tags"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.listFiles(excludeSystemTags)", Description: "Exclude files by system classification tags"},
+	{Version: 2, Kind: ChangeAdded, Path: "FileItem.systemTags", Description: "System classification tags such as screenshot or scan"},
+	{Version: 2, Kind: ChangeAdded, Path: "FileStat.systemTags", Description: "System classification tags such as screenshot or scan"},
+	{Version: 2, Kind: ChangeAdded, Path: "StorageStatus.pendingConfig", Description: "Storage configuration awaiting validation"},
+	{Version: 2, Kind: ChangeAdded, Path: "StorageStatus.lastRollback", Description: "Most recent rejected storage configuration"},
+	{Version: 2, Kind: ChangeChanged, Path: "Mutation.configureS3Storage", Description: "Configuration is staged and applied once validated"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.operation", Description: "Poll progress of an async operation"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.cancelOperation", Description: "Cancel a running async operation"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.deleteFolderAsync", Description: "Delete a folder as an async operation"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.serverInfo", Description: "Server version and update advisory"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.checkForUpdates", Description: "Check for a newer release"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.apiVersion", Description: "API version, compatibility mode and deprecations"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.apiChangelog", Description: "Schema changes since a given API version"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.operations", Description: "List recent async operations"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.runDatabaseMaintenance", Description: "Run SQLite WAL checkpoint, vacuum and integrity check"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.videoPlayback", Description: "Negotiate direct or HLS playback for a video"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.tags", Description: "List hierarchical tags"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.fileTags", Description: "Tags attached to a file"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.filesByTag", Description: "Files matching a tag, optionally including descendants"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.createTag", Description: "Create a hierarchical tag"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.renameTag", Description: "Rename a tag and its descendants"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.mergeTags", Description: "Merge one tag into another"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.deleteTag", Description: "Delete a tag and its descendants"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.addTagAlias", Description: "Add a synonym for a tag"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.removeTagAlias", Description: "Remove a synonym from a tag"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.setFileTags", Description: "Replace the tags of a file"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.uploadDestination", Description: "Preview where a routed upload will be stored"},
+	{Version: 2, Kind: ChangeAdded, Path: "PresignedUpload.path", Description: "Storage path of the upload after routing"},
+	{Version: 2, Kind: ChangeChanged, Path: "Mutation.uploadFile", Description: "Bare filenames are placed by the user's upload routing settings"},
+	{Version: 2, Kind: ChangeChanged, Path: "Mutation.requestUpload", Description: "Bare filenames are placed by the user's upload routing settings"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.createBulkDownload", Description: "Token and URL list for external download managers"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.revokeBulkDownload", Description: "Revoke a bulk download token"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.compareImages", Description: "Alignment and diff visualization URLs for two images"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.storageCostEstimate", Description: "Estimated monthly storage and egress cost per top-level folder"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.processingQueue", Description: "Processing slots and queue wait times per priority class"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.copyFiles", Description: "Copy several files in one request"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.moveFiles", Description: "Move several files in one request"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.operationAllowLists", Description: "Per-role GraphQL operation allow-lists"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.setOperationAllowList", Description: "Restrict a role to a set of root fields"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.clearOperationAllowList", Description: "Lift the operation allow-list of a role"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.renameFile", Description: "Rename a file or folder in place"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.deleteFolder", Description: "Delete a folder, recursively after confirmation, with progress for large folders"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.searchFiles", Description: "Search files by name and system tags below a path"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.fileMetadata", Description: "EXIF metadata of an image: capture date, camera, GPS and exposure"},
+	{Version: 2, Kind: ChangeAdded, Path: "SortOption.CAPTURE_DATE", Description: "Sort listings by EXIF capture date"},
+	{Version: 2, Kind: ChangeAdded, Path: "FileItem.captureTime", Description: "EXIF capture date of listed files"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.configureSFTPStorage", Description: "Configure SFTP storage authenticated with a private key"},
+	{Version: 2, Kind: ChangeAdded, Path: "StorageType.SFTP", Description: "SFTP storage backend"},
+	{Version: 2, Kind: ChangeAdded, Path: "StorageStatus.sftpConfig", Description: "Active SFTP storage configuration, without credentials"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.storageMounts", Description: "Storage mounts served as top level folders, without credentials"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.configureStorageMount", Description: "Add or replace a named storage mount"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.removeStorageMount", Description: "Remove a named storage mount"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.setUserHomePath", Description: "Scope a user's storage access to a home path"},
+	{Version: 2, Kind: ChangeAdded, Path: "User.homePath", Description: "Storage path the user is scoped to"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.createShareLink", Description: "Create an expiring read-only link to a folder or file"},
+	{Version: 2, Kind: ChangeAdded, Path: "ShareLink.passwordProtected", Description: "Whether opening the link requires a password"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.createShareLink(password)", Description: "Require a password to open the link"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.prepareDownload", Description: "Short-lived token for a zip archive of the selected files and folders"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.chunkedUpload", Description: "Progress of a chunked upload for resuming"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.startChunkedUpload", Description: "Begin a resumable upload sent in chunks"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.uploadChunk", Description: "Send one chunk of a chunked upload"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.completeChunkedUpload", Description: "Assemble a chunked upload into the storage"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.abortChunkedUpload", Description: "Discard a chunked upload"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.imageEdit", Description: "Edit saved for an image"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.saveImageEdit", Description: "Save a non-destructive edit of an image"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.resetImageEdit", Description: "Discard the saved edit of an image"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.exportEdit", Description: "Render an image with its saved edit and write it to storage"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.convertImages", Description: "Convert images to another format in the background"},
+	{Version: 2, Kind: ChangeAdded, Path: "Operation.startedAt", Description: "When a worker picked up a queued operation"},
+	{Version: 2, Kind: ChangeAdded, Path: "Subscription.operationUpdated", Description: "Follow an operation until it finishes"},
+	{Version: 2, Kind: ChangeAdded, Path: "Subscription.fileChanged", Description: "Files created, deleted or moved below a folder"},
+	{Version: 2, Kind: ChangeAdded, Path: "Subscription.storageStatusChanged", Description: "Storage status changes"},
+	{Version: 2, Kind: ChangeAdded, Path: "FileChangeKind.UPDATED", Description: "Files written in place outside of the server, with file storage watching"},
+	{Version: 2, Kind: ChangeAdded, Path: "FileList.etag", Description: "Fingerprint of the folder listing when listing caching is enabled"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.refreshFolder", Description: "Drop the cached listing of a folder and list it again"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.listFiles(after)", Description: "Cursor paging for listFiles, continuing after the endCursor of the previous page"},
+	{Version: 2, Kind: ChangeAdded, Path: "FileList.endCursor", Description: "Cursor of the next page of a cursor listing"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.videoStream", Description: "Progressive MP4 stream remuxing or transcoding videos the browser cannot play"},
+	{Version: 2, Kind: ChangeAdded, Path: "FileItem.previewSpriteUrl", Description: "Sprite of video frames for hover scrubbing"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.duplicateGroups", Description: "Groups of identical files by content hash as of the last duplicate scan"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.scanDuplicates", Description: "Hash files below a folder in the background for duplicate detection"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.resolveDuplicates", Description: "Delete chosen copies of duplicate files, keeping at least one"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.similarImages", Description: "Images that look alike by perceptual hash, as of the last duplicate scan"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.listFavorites", Description: "Files and folders starred by the current user"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.setFavorite", Description: "Star or unstar a file or folder for the current user"},
+	{Version: 2, Kind: ChangeAdded, Path: "FileItem.isFavorite", Description: "Whether the current user starred the item"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.tagFile", Description: "Add tags to a file, keeping its other tags"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.untagFile", Description: "Remove tags from a file"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.listFiles(tags)", Description: "Filter files by user tags, including descendant tags"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.searchFiles(tags)", Description: "Restrict search results to files with user tags"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.albums", Description: "Albums with item counts and cover thumbnails"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.album", Description: "An album by ID"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.albumItems", Description: "Files of an album in album order"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.createAlbum", Description: "Create a virtual album"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.renameAlbum", Description: "Rename an album"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.deleteAlbum", Description: "Delete an album, keeping its files"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.addAlbumItems", Description: "Add files to an album"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.removeAlbumItems", Description: "Remove files from an album"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.reorderAlbumItems", Description: "Set the order of an album's files"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.comments", Description: "Comments on a file"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.addComment", Description: "Comment on a file"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.editComment", Description: "Edit your own comment"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.deleteComment", Description: "Delete a comment as its author or an admin"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.rateFile", Description: "Rate a file from 1 to 5 stars, or clear the rating"},
+	{Version: 2, Kind: ChangeAdded, Path: "FileItem.rating", Description: "The current user's star rating of the file"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.listFiles(minRating)", Description: "Filter files by the current user's minimum star rating"},
+	{Version: 2, Kind: ChangeAdded, Path: "SortOption.RATING", Description: "Sort listings by the current user's star rating"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.auditLog", Description: "Recorded mutations, for admins"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.apiTokens", Description: "Personal access tokens of the current user"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.createApiToken", Description: "Mint a personal access token for scripts"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.revokeApiToken", Description: "Revoke a personal access token"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.sessions", Description: "Active login sessions of a user"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.revokeSession", Description: "End a login session and its tokens"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.createBackup", Description: "Back up users, settings, tags, albums and favorites, for admins"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.scanStatus", Description: "Schedule and latest run of library scans, for admins"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.triggerScan", Description: "Start a library scan refreshing listings, metadata and hashes, for admins"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.importFromUrl", Description: "Download an image or video from a URL into the storage"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.timeline", Description: "Photos bucketed by capture date, paged for virtualized scrolling"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.geoClusters", Description: "Photos with a GPS position clustered for a map view"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.ingestLabels", Description: "Store labels from an external classification service as tags, for API tokens"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.people", Description: "People recognized by face detection, with cover faces"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.personPhotos", Description: "Photos showing a person"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.scanFaces", Description: "Detect faces and group them into people in the background"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.namePerson", Description: "Name a person recognized by face detection"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.mergePeople", Description: "Merge two people recognized as one"},
+	{Version: 2, Kind: ChangeAdded, Path: "FileItem.isRaw", Description: "Flags camera RAW files, whose thumbnails render from their embedded preview"},
+	{Version: 2, Kind: ChangeAdded, Path: "FileItem.livePhotoVideoUrl", Description: "Motion video of Apple Live Photos, whose MOV is no longer listed separately"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.thumbnailPresets", Description: "Thumbnail sizes and formats listings link to"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.setThumbnailPresets", Description: "Configure thumbnail presets, replacing the built-in grid, preview and full by name"},
+	{Version: 2, Kind: ChangeAdded, Path: "ThumbnailUrls.presets", Description: "Thumbnail URL of every preset by name"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.setFolderCover", Description: "Choose the image shown for a folder in folder grids"},
+	{Version: 2, Kind: ChangeAdded, Path: "FileItem.coverPath", Description: "Cover image chosen for a folder"},
+	{Version: 2, Kind: ChangeAdded, Path: "FileItem.coverThumbnailUrls", Description: "Thumbnails of the cover image of a folder"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.folderSettings", Description: "Description, default sort, pinned children and hidden flag of a folder"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.setFolderSettings", Description: "Save the settings of a folder"},
+	{Version: 2, Kind: ChangeChanged, Path: "Query.listFiles", Description: "Applies the sort, pinned children and hidden folders of the folder settings"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.folderTree", Description: "Nested folders with child counts down to a depth"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.storageStats", Description: "Size, file count and extension breakdown of a folder from library scans"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.storageQuotas", Description: "Storage quotas with their current usage"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.setStorageQuota", Description: "Bound the bytes uploaded by a user or stored below a folder"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.uploadFileWithResult", Description: "Uploads a file, returning the stored path and whether an identical existing file was used instead"},
+	{Version: 2, Kind: ChangeChanged, Path: "Mutation.uploadFile", Description: "Rejects or skips uploads identical to an existing file when upload-dedupe is reject or link"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.createPresignedUpload", Description: "Starts an upload sent straight to S3, with a single PUT URL or presigned multipart part URLs"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.finalizeUpload", Description: "Completes a direct upload, validating its size and running upload post-processing"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.abortPresignedUpload", Description: "Discards a direct upload and its multipart parts"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.downloadUrl", Description: "Returns an expiring URL downloading an original file, presigned by S3 or streamed by the server with Range support"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.effectiveConfig", Description: "Admin-only listing of every config setting with its effective value, source and ignored registry values"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.rotateEncryptionKey", Description: "Admin-only re-encryption of encrypted settings of all owners with a new key, in one transaction"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.tenants", Description: "Server admin listing of tenants with their storage roots and member counts"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.createTenant", Description: "Creates a tenant isolating the files, users and settings of its members below a storage root"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.deleteTenant", Description: "Deletes a tenant without members"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.setUserTenant", Description: "Moves a user into a tenant, or out of tenants with a null tenantId"},
+	{Version: 2, Kind: ChangeAdded, Path: "User.tenantId", Description: "Tenant the user is a member of"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.webhooks", Description: "Admin-only listing of webhooks notified of file, album and share link events"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.webhookDeliveries", Description: "Admin-only delivery log of webhooks with status, attempts and last response"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.createWebhook", Description: "Registers a URL receiving signed JSON posts of the events it subscribes to"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.updateWebhook", Description: "Changes the URL, events, secret or active state of a webhook"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.deleteWebhook", Description: "Removes a webhook and its delivery log"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.testNotification", Description: "Sends a test notification to the email, Telegram or ntfy channels configured in the registry"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.networkAcls", Description: "Admin-only allow and deny lists of client networks per route group"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.setNetworkAcl", Description: "Restricts the client networks reaching the auth, graphql, imagor or shares routes"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.clearNetworkAcl", Description: "Lifts the network ACL of a route group"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.queryLimits", Description: "Admin-only complexity, depth and run time limits of GraphQL operations"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.setQueryLimits", Description: "Limits the complexity, depth and run time of the GraphQL operations of non-admin callers"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.auditLog(after)", Description: "Continues the audit log after the endCursor of the previous page"},
+	{Version: 2, Kind: ChangeAdded, Path: "AuditLogPage.endCursor", Description: "Cursor of the next page of the audit log, null on the last page"},
+	{Version: 2, Kind: ChangeDeprecated, Path: "Query.auditLog(offset)", Description: "Offsets skip or repeat entries recorded between pages", Replacement: "Query.auditLog(after)"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.webhookDeliveries(after)", Description: "Continues the delivery log after the endCursor of the previous page"},
+	{Version: 2, Kind: ChangeAdded, Path: "WebhookDeliveryPage.endCursor", Description: "Cursor of the next page of the delivery log, null on the last page"},
+	{Version: 2, Kind: ChangeDeprecated, Path: "Query.webhookDeliveries(offset)", Description: "Offsets skip or repeat deliveries made between pages", Replacement: "Query.webhookDeliveries(after)"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.users(after)", Description: "Continues the user listing after the endCursor of the previous page"},
+	{Version: 2, Kind: ChangeAdded, Path: "UserList.endCursor", Description: "Cursor of the next page of users, null on the last page"},
+	{Version: 2, Kind: ChangeDeprecated, Path: "Query.users(offset)", Description: "Offsets skip or repeat users created between pages", Replacement: "Query.users(after)"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.people(after)", Description: "Continues the people listing after the endCursor of the previous page"},
+	{Version: 2, Kind: ChangeAdded, Path: "PersonList.endCursor", Description: "Cursor of the next page of people, null on the last page"},
+	{Version: 2, Kind: ChangeDeprecated, Path: "Query.people(offset)", Description: "Offsets skip or repeat people as scans change the ranking", Replacement: "Query.people(after)"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.personPhotos(after)", Description: "Continues the files of a person after the endCursor of the previous page"},
+	{Version: 2, Kind: ChangeAdded, Path: "PersonPhotoList.endCursor", Description: "Cursor of the next page of files showing a person, null on the last page"},
+	{Version: 2, Kind: ChangeDeprecated, Path: "Query.personPhotos(offset)", Description: "Offsets skip or repeat files scanned between pages", Replacement: "Query.personPhotos(after)"},
+	{Version: 2, Kind: ChangeDeprecated, Path: "Query.listFiles(offset)", Description: "Offsets skip or repeat files added or removed between pages", Replacement: "Query.listFiles(after)"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.listUserRegistry(after)", Description: "Lists the entries with keys after the last key of the previous page"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.listUserRegistry(limit)", Description: "Bounds the entries of a page"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.listSystemRegistry(after)", Description: "Lists the entries with keys after the last key of the previous page"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.listSystemRegistry(limit)", Description: "Bounds the entries of a page"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.listFiles(skipMetadata)", Description: "Lists files without the size and modified time the storage looks up entry by entry"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.exportSystemRegistry", Description: "Exports the system settings as JSON, for admins"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.importSystemRegistry", Description: "Imports system settings exported as JSON, for admins"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.getPreferences", Description: "Returns the UI preferences of the current user"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.setPreferences", Description: "Validates and saves the UI preferences of the current user"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.castUrl", Description: "Returns a URL casting a file to a TV, Chromecast or DLNA renderer"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.s3AccessKeys", Description: "Lists the S3 gateway access keys of the current user"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.createS3AccessKey", Description: "Issues an access key signing requests to the S3 gateway"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.revokeS3AccessKey", Description: "Revokes an S3 gateway access key of the current user"},
+	{Version: 2, Kind: ChangeAdded, Path: "Query.changesSince", Description: "Returns the files and folders changed since a sync token, for clients mirroring the library"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.autoUpload", Description: "Backs up files from a phone into capture date folders, skipping content already backed up"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.organizeFiles", Description: "Plans and runs moving photos into folders named after their capture date and camera"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.uploadFile(onCollision)", Description: "Chooses whether an upload to a path already taken overwrites, renames or is rejected"},
+	{Version: 2, Kind: ChangeAdded, Path: "Mutation.uploadFileWithResult(onCollision)", Description: "Chooses whether an upload to a path already taken overwrites, renames or is rejected"},
+	{Version: 2, Kind: ChangeChanged, Path: "Mutation.uploadFile", Description: "Normalizes the names of new files and folders to Unicode NFC, rejecting names longer than 255 bytes or with backslashes, and denies paths with control characters"},
+	{Version: 2, Kind: ChangeChanged, Path: "Mutation.uploadFile", Description: "Fails with the malware_found reason when the upload scanner finds malware in the upload"},
+	{Version: 2, Kind: ChangeChanged, Path: "Mutation.createPresignedUpload", Description: "Fails with NOT_AVAILABLE while uploads are scanned for malware, clients fall back to chunked uploads"},
+	{Version: 2, Kind: ChangeChanged, Path: "Mutation.uploadFile", Description: "Fails with the executable_content or content_type_mismatch reason when the content of the upload does not match its extension, and with extension_not_allowed for extensions outside --upload-allowed-extensions"},
+}
@@ -0,0 +1,85 @@
+// Package attemptlimit locks out keys after too many failed attempts, such as
+// the wrong passwords sent for a shared link. Failures are counted in memory
+// over a fixed window: every server instance counts on its own, and a
+// restart resets them.
+package attemptlimit
+
+import (
+	"sync"
+	"time"
+)
+
+type entry struct {
+	failures int
+	reset    time.Time
+}
+
+// Limiter counts the failed attempts of keys
+type Limiter struct {
+	max    int
+	window time.Duration
+	now    func() time.Time
+
+	mu        sync.Mutex
+	entries   map[string]*entry
+	nextSweep time.Time
+}
+
+// New creates a limiter locking a key out once it failed max times within
+// window, until the window is over
+func New(max int, window time.Duration) *Limiter {
+	return &Limiter{
+		max:     max,
+		window:  window,
+		now:     time.Now,
+		entries: make(map[string]*entry),
+	}
+}
+
+// Check returns the time until key may be tried again, 0 when it may be
+// tried now
+func (l *Limiter) Check(key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	e, ok := l.entries[key]
+	if !ok || !now.Before(e.reset) || e.failures < l.max {
+		return 0
+	}
+	return e.reset.Sub(now)
+}
+
+// Fail counts a failed attempt of key. The window starts with the first
+// failure.
+func (l *Limiter) Fail(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.sweep(now)
+	e, ok := l.entries[key]
+	if !ok || !now.Before(e.reset) {
+		e = &entry{reset: now.Add(l.window)}
+		l.entries[key] = e
+	}
+	e.failures++
+}
+
+// Reset forgets the failures of key, after it succeeded
+func (l *Limiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.entries, key)
+}
+
+// sweep drops the entries whose window is over, at most once a window
+func (l *Limiter) sweep(now time.Time) {
+	if now.Before(l.nextSweep) {
+		return
+	}
+	for key, e := range l.entries {
+		if !now.Before(e.reset) {
+			delete(l.entries, key)
+		}
+	}
+	l.nextSweep = now.Add(l.window)
+}
@@ -0,0 +1,48 @@
+package attemptlimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := New(3, 10*time.Minute)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		assert.Zero(t, l.Check("link:a"))
+		l.Fail("link:a")
+	}
+	now = now.Add(4 * time.Minute)
+	assert.Equal(t, 6*time.Minute, l.Check("link:a"))
+
+	// Keys are counted apart
+	assert.Zero(t, l.Check("link:b"))
+
+	// The lockout ends with the window started by the first failure
+	now = now.Add(6 * time.Minute)
+	assert.Zero(t, l.Check("link:a"))
+	l.Fail("link:a")
+	assert.Zero(t, l.Check("link:a"))
+
+	l.Fail("link:b")
+	l.Fail("link:b")
+	l.Reset("link:b")
+	l.Fail("link:b")
+	l.Fail("link:b")
+	assert.Zero(t, l.Check("link:b"))
+}
+
+func TestLimiter_Sweep(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := New(3, time.Minute)
+	l.now = func() time.Time { return now }
+
+	l.Fail("ip:1")
+	now = now.Add(time.Minute)
+	l.Fail("ip:2")
+	assert.Len(t, l.entries, 1)
+}
@@ -0,0 +1,212 @@
+// Package auditlog records every executed GraphQL mutation, who ran it, when,
+// from where and on which path, for admins to review.
+//
+// Entries are written by FieldMiddleware once a mutation returns, whether it
+// succeeded or not. Only paths and identifiers are kept from the arguments,
+// never contents, passwords or storage credentials.
+package auditlog
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/model"
+	"github.com/cshum/imagor-studio/server/internal/pagination"
+	"github.com/cshum/imagor-studio/server/pkg/uuid"
+	"github.com/uptrace/bun"
+	"go.uber.org/zap"
+)
+
+// MaxLimit caps the entries returned by List
+const MaxLimit = 500
+
+// Entry is one recorded mutation
+type Entry struct {
+	ID        string
+	CreatedAt time.Time
+	UserID    string
+	Role      string
+	Operation string
+	SpaceID   string
+	Path      string
+	DestPath  string
+	PathCount int
+	Target    string
+	ClientIP  string
+	Error     string
+}
+
+// Filter narrows List, zero fields match every entry
+type Filter struct {
+	UserID    string
+	Operation string
+	// Path matches entries on the path or below it, as source or destination
+	Path       string
+	Since      time.Time
+	Until      time.Time
+	FailedOnly bool
+}
+
+type Store interface {
+	Record(ctx context.Context, entry *Entry) error
+	// List returns the entries matching filter, newest first, and their
+	// total count
+	List(ctx context.Context, filter Filter, offset, limit int) ([]*Entry, int, error)
+	// ListAfter returns the entries matching filter after the entry at
+	// after, from the newest when nil, their total count and whether more
+	// follow the page
+	ListAfter(ctx context.Context, filter Filter, after *pagination.Key, limit int) ([]*Entry, int, bool, error)
+	// Prune deletes entries recorded before cutoff, returning how many
+	Prune(ctx context.Context, cutoff time.Time) (int, error)
+}
+
+type store struct {
+	db     *bun.DB
+	logger *zap.Logger
+}
+
+func New(db *bun.DB, logger *zap.Logger) Store {
+	return &store{db: db, logger: logger}
+}
+
+func (s *store) Record(ctx context.Context, entry *Entry) error {
+	if entry.ID == "" {
+		entry.ID = uuid.GenerateUUID()
+	}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now().UTC()
+	}
+	row := &model.AuditEntry{
+		ID:        entry.ID,
+		CreatedAt: entry.CreatedAt,
+		UserID:    entry.UserID,
+		Role:      entry.Role,
+		Operation: entry.Operation,
+		SpaceID:   entry.SpaceID,
+		Path:      entry.Path,
+		DestPath:  entry.DestPath,
+		PathCount: entry.PathCount,
+		Target:    entry.Target,
+		ClientIP:  entry.ClientIP,
+		Error:     entry.Error,
+	}
+	if _, err := s.db.NewInsert().Model(row).Exec(ctx); err != nil {
+		return fmt.Errorf("error recording audit entry: %w", err)
+	}
+	return nil
+}
+
+func (s *store) List(ctx context.Context, filter Filter, offset, limit int) ([]*Entry, int, error) {
+	if limit <= 0 || limit > MaxLimit {
+		limit = MaxLimit
+	}
+	var rows []model.AuditEntry
+	total, err := s.filter(s.db.NewSelect().Model(&rows), filter).
+		Order("created_at DESC", "id DESC").
+		Offset(max(offset, 0)).
+		Limit(limit).
+		ScanAndCount(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error listing audit entries: %w", err)
+	}
+	return toEntries(rows), total, nil
+}
+
+func (s *store) ListAfter(ctx context.Context, filter Filter, after *pagination.Key, limit int) ([]*Entry, int, bool, error) {
+	if limit <= 0 || limit > MaxLimit {
+		limit = MaxLimit
+	}
+	total, err := s.filter(s.db.NewSelect().Model((*model.AuditEntry)(nil)), filter).Count(ctx)
+	if err != nil {
+		return nil, 0, false, fmt.Errorf("error counting audit entries: %w", err)
+	}
+	// One more row than the page tells whether another page follows
+	var rows []model.AuditEntry
+	if err := after.After(s.filter(s.db.NewSelect().Model(&rows), filter)).
+		Order("created_at DESC", "id DESC").
+		Limit(limit + 1).
+		Scan(ctx); err != nil {
+		return nil, 0, false, fmt.Errorf("error listing audit entries: %w", err)
+	}
+	more := len(rows) > limit
+	if more {
+		rows = rows[:limit]
+	}
+	return toEntries(rows), total, more, nil
+}
+
+func (s *store) filter(q *bun.SelectQuery, filter Filter) *bun.SelectQuery {
+	if filter.UserID != "" {
+		q = q.Where("user_id = ?", filter.UserID)
+	}
+	if filter.Operation != "" {
+		q = q.Where("operation = ?", filter.Operation)
+	}
+	if p := strings.Trim(filter.Path, "/"); p != "" {
+		q = q.WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.
+				Where("path = ? OR substr(path, 1, ?) = ?", p, len(p)+1, p+"/").
+				WhereOr("dest_path = ? OR substr(dest_path, 1, ?) = ?", p, len(p)+1, p+"/")
+		})
+	}
+	if !filter.Since.IsZero() {
+		q = q.Where("created_at >= ?", filter.Since.UTC())
+	}
+	if !filter.Until.IsZero() {
+		q = q.Where("created_at < ?", filter.Until.UTC())
+	}
+	if filter.FailedOnly {
+		q = q.Where("error <> ''")
+	}
+	return q
+}
+
+func toEntries(rows []model.AuditEntry) []*Entry {
+	entries := make([]*Entry, 0, len(rows))
+	for i := range rows {
+		row := &rows[i]
+		entries = append(entries, &Entry{
+			ID:        row.ID,
+			CreatedAt: row.CreatedAt,
+			UserID:    row.UserID,
+			Role:      row.Role,
+			Operation: row.Operation,
+			SpaceID:   row.SpaceID,
+			Path:      row.Path,
+			DestPath:  row.DestPath,
+			PathCount: row.PathCount,
+			Target:    row.Target,
+			ClientIP:  row.ClientIP,
+			Error:     row.Error,
+		})
+	}
+	return entries
+}
+
+func (s *store) Prune(ctx context.Context, cutoff time.Time) (int, error) {
+	res, err := s.db.NewDelete().Model((*model.AuditEntry)(nil)).
+		Where("created_at < ?", cutoff.UTC()).
+		Exec(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error pruning audit entries: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// NewPruneFunc returns a sync function deleting entries older than
+// retention, for use with the server sync loop
+func NewPruneFunc(store Store, retention time.Duration, logger *zap.Logger) func() error {
+	return func() error {
+		pruned, err := store.Prune(context.Background(), time.Now().Add(-retention))
+		if err != nil {
+			return err
+		}
+		if pruned > 0 {
+			logger.Info("Pruned audit log", zap.Int("entries", pruned), zap.Duration("retention", retention))
+		}
+		return nil
+	}
+}
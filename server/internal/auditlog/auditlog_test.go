@@ -0,0 +1,186 @@
+package auditlog
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/cshum/imagor-studio/server/internal/pagination"
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/cshum/imagor-studio/server/pkg/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/ast"
+	"go.uber.org/zap"
+)
+
+func setupTestStore(t *testing.T) Store {
+	t.Helper()
+	db := testutil.NewDB(t)
+
+	return New(db, zap.NewNop())
+}
+
+func operations(entries []*Entry) []string {
+	ops := make([]string, len(entries))
+	for i, e := range entries {
+		ops[i] = e.Operation
+	}
+	return ops
+}
+
+func TestStore_ListAndPrune(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	for _, e := range []*Entry{
+		{CreatedAt: now.Add(-48 * time.Hour), UserID: "alice", Operation: "uploadFile", Path: "photos/a.jpg", PathCount: 1},
+		{CreatedAt: now.Add(-2 * time.Hour), UserID: "bob", Operation: "moveFile", Path: "inbox/b.jpg", DestPath: "photos/b.jpg", PathCount: 1},
+		{CreatedAt: now.Add(-time.Hour), UserID: "alice", Operation: "deleteFile", Path: "photos-old/c.jpg", PathCount: 1, Error: "not found"},
+		{CreatedAt: now, UserID: "admin", Operation: "createUser", Target: "carol"},
+	} {
+		require.NoError(t, s.Record(ctx, e))
+		assert.NotEmpty(t, e.ID)
+	}
+
+	entries, total, err := s.List(ctx, Filter{}, 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 4, total)
+	assert.Equal(t, []string{"createUser", "deleteFile", "moveFile", "uploadFile"}, operations(entries))
+
+	entries, total, err = s.List(ctx, Filter{}, 1, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 4, total)
+	assert.Equal(t, []string{"deleteFile", "moveFile"}, operations(entries))
+
+	entries, _, err = s.List(ctx, Filter{UserID: "alice"}, 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"deleteFile", "uploadFile"}, operations(entries))
+
+	// Matches the path as source or destination, not sibling prefixes
+	entries, _, err = s.List(ctx, Filter{Path: "/photos/"}, 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"moveFile", "uploadFile"}, operations(entries))
+
+	entries, _, err = s.List(ctx, Filter{UserID: "alice", Path: "photos"}, 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"uploadFile"}, operations(entries))
+
+	entries, _, err = s.List(ctx, Filter{Since: now.Add(-3 * time.Hour), Until: now}, 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"deleteFile", "moveFile"}, operations(entries))
+
+	entries, _, err = s.List(ctx, Filter{FailedOnly: true}, 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"deleteFile"}, operations(entries))
+	assert.Equal(t, "not found", entries[0].Error)
+
+	pruned, err := s.Prune(ctx, now.Add(-24*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, pruned)
+	_, total, err = s.List(ctx, Filter{}, 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 3, total)
+}
+
+func TestStore_ListAfter(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	// Entries recorded in the same instant are ordered by ID
+	for _, e := range []*Entry{
+		{ID: "a", CreatedAt: now.Add(-time.Hour), Operation: "uploadFile"},
+		{ID: "b", CreatedAt: now, Operation: "moveFile"},
+		{ID: "c", CreatedAt: now, Operation: "deleteFile"},
+		{ID: "d", CreatedAt: now.Add(time.Hour), Operation: "createUser"},
+	} {
+		require.NoError(t, s.Record(ctx, e))
+	}
+
+	entries, total, more, err := s.ListAfter(ctx, Filter{}, nil, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 4, total)
+	assert.True(t, more)
+	assert.Equal(t, []string{"createUser", "deleteFile"}, operations(entries))
+
+	// An entry recorded meanwhile does not shift the next page
+	require.NoError(t, s.Record(ctx, &Entry{CreatedAt: now.Add(2 * time.Hour), Operation: "createTag"}))
+	last := entries[len(entries)-1]
+	entries, total, more, err = s.ListAfter(ctx, Filter{}, &pagination.Key{CreatedAt: last.CreatedAt, ID: last.ID}, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 5, total)
+	assert.False(t, more)
+	assert.Equal(t, []string{"moveFile", "uploadFile"}, operations(entries))
+
+	entries, total, more, err = s.ListAfter(ctx, Filter{Operation: "moveFile"}, nil, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	assert.False(t, more)
+	assert.Equal(t, []string{"moveFile"}, operations(entries))
+}
+
+type fileTransfer struct {
+	SourcePath string
+	DestPath   string
+}
+
+func TestFieldMiddleware(t *testing.T) {
+	s := setupTestStore(t)
+	mw := FieldMiddleware(s, zap.NewNop())
+	ctx := auth.SetClaimsInContext(WithClientIP(context.Background(), "192.0.2.1"), &auth.Claims{UserID: "alice", Role: "user"})
+
+	run := func(object, field string, args map[string]interface{}, err error) {
+		t.Helper()
+		fieldCtx := graphql.WithFieldContext(ctx, &graphql.FieldContext{
+			Object: object,
+			Field:  graphql.CollectedField{Field: &ast.Field{Name: field}},
+			Args:   args,
+		})
+		_, gotErr := mw(fieldCtx, func(ctx context.Context) (interface{}, error) { return true, err })
+		assert.Equal(t, err, gotErr)
+	}
+	space := "space-1"
+	run("Query", "listFiles", map[string]interface{}{"path": "photos"}, nil)
+	run("Mutation", "moveFile", map[string]interface{}{"sourcePath": "inbox/a.jpg", "destPath": "photos/a.jpg", "spaceID": &space}, nil)
+	run("Mutation", "moveFiles", map[string]interface{}{"items": []*fileTransfer{{SourcePath: "a.jpg", DestPath: "b.jpg"}, {SourcePath: "c.jpg", DestPath: "d.jpg"}}}, nil)
+	run("Mutation", "deleteTag", map[string]interface{}{"id": "tag-1"}, errors.New("tag not found"))
+
+	entries, total, err := s.List(context.Background(), Filter{}, 0, 0)
+	require.NoError(t, err)
+	require.Equal(t, 3, total)
+	byOperation := make(map[string]*Entry)
+	for _, e := range entries {
+		byOperation[e.Operation] = e
+		assert.Equal(t, "alice", e.UserID)
+		assert.Equal(t, "user", e.Role)
+		assert.Equal(t, "192.0.2.1", e.ClientIP)
+	}
+	move := byOperation["moveFile"]
+	assert.Equal(t, "inbox/a.jpg", move.Path)
+	assert.Equal(t, "photos/a.jpg", move.DestPath)
+	assert.Equal(t, "space-1", move.SpaceID)
+	assert.Equal(t, 1, move.PathCount)
+	assert.Equal(t, "a.jpg", byOperation["moveFiles"].Path)
+	assert.Equal(t, 2, byOperation["moveFiles"].PathCount)
+	assert.Equal(t, "tag-1", byOperation["deleteTag"].Target)
+	assert.Equal(t, "tag not found", byOperation["deleteTag"].Error)
+	assert.Empty(t, byOperation["moveFile"].Error)
+}
+
+func TestClientIPMiddleware(t *testing.T) {
+	var ip string
+	handler := ClientIPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip = ClientIPFromContext(r.Context())
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/api/query", nil)
+	req.RemoteAddr = "198.51.100.7:52311"
+	req.Header.Set("X-Forwarded-For", "203.0.113.9")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "198.51.100.7", ip)
+}
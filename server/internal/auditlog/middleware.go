@@ -0,0 +1,144 @@
+package auditlog
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/cshum/imagor-studio/server/pkg/auth"
+	"go.uber.org/zap"
+)
+
+// maxErrorLength truncates recorded error messages
+const maxErrorLength = 500
+
+type contextKey struct{}
+
+// WithClientIP returns a context carrying the address of the client
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, contextKey{}, ip)
+}
+
+// ClientIPFromContext returns the client address set by WithClientIP
+func ClientIPFromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(contextKey{}).(string)
+	return ip
+}
+
+// ClientIPMiddleware records the remote address of each request for
+// FieldMiddleware. Behind a reverse proxy the proxy address is recorded
+// unless the proxy is trusted, see clientip.
+func ClientIPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := r.RemoteAddr
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			ip = host
+		}
+		next.ServeHTTP(w, r.WithContext(WithClientIP(r.Context(), ip)))
+	})
+}
+
+// Argument names holding the paths, destination and target of mutations
+var (
+	pathArgs   = []string{"path", "sourcePath", "imagePath", "templatePath", "paths", "items"}
+	destArgs   = []string{"destPath", "destFolder"}
+	targetArgs = []string{"id", "userId", "key", "role", "name", "username", "email", "token", "invitationId", "provider", "sourceID"}
+)
+
+// FieldMiddleware records every executed mutation in store. Recording
+// failures are logged and never fail the mutation.
+func FieldMiddleware(store Store, logger *zap.Logger) graphql.FieldMiddleware {
+	return func(ctx context.Context, next graphql.Resolver) (interface{}, error) {
+		fc := graphql.GetFieldContext(ctx)
+		if fc == nil || fc.Object != "Mutation" {
+			return next(ctx)
+		}
+		res, err := next(ctx)
+
+		entry := &Entry{Operation: fc.Field.Name}
+		entry.SpaceID = stringArg(fc.Args["spaceID"])
+		for _, name := range pathArgs {
+			if path, count := pathsArg(fc.Args[name]); count > 0 {
+				entry.Path, entry.PathCount = path, count
+				break
+			}
+		}
+		for _, name := range destArgs {
+			if dest := stringArg(fc.Args[name]); dest != "" {
+				entry.DestPath = dest
+				break
+			}
+		}
+		for _, name := range targetArgs {
+			if target := stringArg(fc.Args[name]); target != "" {
+				entry.Target = target
+				break
+			}
+		}
+		RecordOperation(ctx, store, logger, entry, err)
+		return res, err
+	}
+}
+
+// RecordOperation records entry in store, completed with the session and
+// client of ctx and the error the operation returned. It is how operations
+// of transports other than GraphQL are recorded, and failures are logged
+// rather than failing the operation.
+func RecordOperation(ctx context.Context, store Store, logger *zap.Logger, entry *Entry, err error) {
+	entry.ClientIP = ClientIPFromContext(ctx)
+	if claims, claimsErr := auth.GetClaimsFromContext(ctx); claimsErr == nil {
+		entry.UserID = claims.UserID
+		entry.Role = claims.Role
+	}
+	if err != nil {
+		entry.Error = err.Error()
+		if len(entry.Error) > maxErrorLength {
+			entry.Error = entry.Error[:maxErrorLength]
+		}
+	}
+	// Recorded after a cancelled request too, the operation may have run
+	recordCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if recordErr := store.Record(recordCtx, entry); recordErr != nil {
+		logger.Warn("Failed to record audit entry", zap.String("operation", entry.Operation), zap.Error(recordErr))
+	}
+}
+
+// stringArg returns a string or *string argument, empty for other types
+func stringArg(v interface{}) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case *string:
+		if s != nil {
+			return *s
+		}
+	}
+	return ""
+}
+
+// pathsArg returns the first path of a path argument and how many it
+// holds. Lists of strings and of inputs with a SourcePath field, as taken
+// by moveFiles and copyFiles, are supported.
+func pathsArg(v interface{}) (string, int) {
+	if s := stringArg(v); s != "" {
+		return s, 1
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice || rv.Len() == 0 {
+		return "", 0
+	}
+	first := reflect.Indirect(rv.Index(0))
+	switch first.Kind() {
+	case reflect.String:
+		return first.String(), rv.Len()
+	case reflect.Struct:
+		if f := first.FieldByName("SourcePath"); f.IsValid() && f.Kind() == reflect.String {
+			return f.String(), rv.Len()
+		}
+	}
+	return "", rv.Len()
+}
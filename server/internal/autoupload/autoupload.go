@@ -0,0 +1,205 @@
+// Package autoupload places the photos and videos phones back up to the
+// server automatically, and remembers what each user backed up so a phone
+// sending the same content again is told it is already there.
+//
+// Backed up files go to a folder named after their capture time, such as
+// 2026/10, following a template a user can set in their registry and admins
+// in the system registry as the default. Names taken by other content are
+// suffixed with a number, as phones reuse names like IMG_0001.JPG.
+package autoupload
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/model"
+	"github.com/cshum/imagor-studio/server/pkg/uuid"
+	"github.com/uptrace/bun"
+	"go.uber.org/zap"
+)
+
+const (
+	// FolderTemplateKey holds the folder template, read from the user
+	// registry first and the system registry as the admin-provided default
+	FolderTemplateKey = "config.auto_upload_folder_template"
+	// DefaultFolderTemplate files backups by year and month
+	DefaultFolderTemplate = "{year}/{month}"
+	// MaxDeviceIDLength is the longest device ID in characters
+	MaxDeviceIDLength = 100
+)
+
+// ErrInvalid is returned for invalid templates, device IDs and filenames
+var ErrInvalid = errors.New("invalid auto upload")
+
+// placeholders lists the placeholders of folder templates
+var placeholders = []string{"{year}", "{month}", "{day}", "{device}"}
+
+// Template names the folder of a backed up file
+type Template string
+
+// ParseTemplate validates a folder template. Placeholders are {year},
+// {month} and {day} of the capture time and {device} for the device ID.
+func ParseTemplate(s string) (Template, error) {
+	s = strings.Trim(strings.TrimSpace(s), "/")
+	if s == "" {
+		return "", fmt.Errorf("%w: folder template is empty", ErrInvalid)
+	}
+	rest := s
+	for _, p := range placeholders {
+		rest = strings.ReplaceAll(rest, p, "")
+	}
+	if strings.ContainsAny(rest, "{}") {
+		return "", fmt.Errorf("%w: unknown placeholder in folder template %q", ErrInvalid, s)
+	}
+	for _, segment := range strings.Split(s, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return "", fmt.Errorf("%w: invalid folder template %q", ErrInvalid, s)
+		}
+	}
+	return Template(s), nil
+}
+
+// Folder returns the folder of a file captured at capturedAt on device
+func (t Template) Folder(capturedAt time.Time, device string) string {
+	return strings.NewReplacer(
+		"{year}", capturedAt.Format("2006"),
+		"{month}", capturedAt.Format("01"),
+		"{day}", capturedAt.Format("02"),
+		"{device}", device,
+	).Replace(string(t))
+}
+
+// NormalizeDeviceID validates a device ID and makes it safe as a folder
+// name, replacing slashes and control characters
+func NormalizeDeviceID(id string) (string, error) {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return "", fmt.Errorf("%w: device ID is required", ErrInvalid)
+	}
+	if len([]rune(id)) > MaxDeviceIDLength {
+		return "", fmt.Errorf("%w: device ID is longer than %d characters", ErrInvalid, MaxDeviceIDLength)
+	}
+	id = strings.Map(func(r rune) rune {
+		if r < ' ' || r == '/' || r == '\\' || r == 0x7f {
+			return '_'
+		}
+		return r
+	}, id)
+	if strings.Trim(id, ".") == "" {
+		return "", fmt.Errorf("%w: invalid device ID", ErrInvalid)
+	}
+	return id, nil
+}
+
+// NormalizeFilename returns the base name of a filename sent by a phone,
+// rejecting names that cannot be stored
+func NormalizeFilename(filename string) (string, error) {
+	name := path.Base(strings.ReplaceAll(strings.TrimSpace(filename), "\\", "/"))
+	if name == "" || name == "." || name == ".." || name == "/" {
+		return "", fmt.Errorf("%w: invalid filename %q", ErrInvalid, filename)
+	}
+	return name, nil
+}
+
+// Candidate returns the n-th name tried for filename, the name itself
+// first, then IMG_0001 (2).JPG and so on
+func Candidate(filename string, n int) string {
+	if n <= 1 {
+		return filename
+	}
+	ext := path.Ext(filename)
+	return strings.TrimSuffix(filename, ext) + " (" + strconv.Itoa(n) + ")" + ext
+}
+
+// Record is a file a user backed up
+type Record struct {
+	UserID string
+	// Scope is the file metadata scope of the storage backed up to
+	Scope    string
+	Hash     string
+	Path     string
+	DeviceID string
+	// Filename is the name of the file on the device
+	Filename   string
+	CapturedAt time.Time
+	CreatedAt  time.Time
+}
+
+type Store interface {
+	// Find returns the latest file backed up by a user in scope with the
+	// content hash, nil when there is none
+	Find(ctx context.Context, userID, scope, hash string) (*Record, error)
+	// Record remembers a file backed up, replacing an earlier record of the
+	// same content
+	Record(ctx context.Context, record *Record) error
+}
+
+type store struct {
+	db     *bun.DB
+	logger *zap.Logger
+}
+
+func New(db *bun.DB, logger *zap.Logger) Store {
+	return &store{db: db, logger: logger}
+}
+
+func (s *store) Find(ctx context.Context, userID, scope, hash string) (*Record, error) {
+	row := new(model.AutoUpload)
+	err := s.db.NewSelect().Model(row).
+		Where("user_id = ?", userID).
+		Where("scope = ?", scope).
+		Where("hash = ?", hash).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error finding auto upload: %w", err)
+	}
+	return &Record{
+		UserID:     row.UserID,
+		Scope:      row.Scope,
+		Hash:       row.Hash,
+		Path:       row.Path,
+		DeviceID:   row.DeviceID,
+		Filename:   row.Filename,
+		CapturedAt: row.CapturedAt,
+		CreatedAt:  row.CreatedAt,
+	}, nil
+}
+
+func (s *store) Record(ctx context.Context, record *Record) error {
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now().UTC()
+	}
+	row := &model.AutoUpload{
+		ID:         uuid.GenerateUUID(),
+		UserID:     record.UserID,
+		Scope:      record.Scope,
+		Hash:       record.Hash,
+		Path:       record.Path,
+		DeviceID:   record.DeviceID,
+		Filename:   record.Filename,
+		CapturedAt: record.CapturedAt.UTC(),
+		CreatedAt:  record.CreatedAt,
+	}
+	return s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewDelete().Model((*model.AutoUpload)(nil)).
+			Where("user_id = ?", record.UserID).
+			Where("scope = ?", record.Scope).
+			Where("hash = ?", record.Hash).
+			Exec(ctx); err != nil {
+			return fmt.Errorf("error recording auto upload: %w", err)
+		}
+		if _, err := tx.NewInsert().Model(row).Exec(ctx); err != nil {
+			return fmt.Errorf("error recording auto upload: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,100 @@
+package autoupload
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func setupTestStore(t *testing.T) Store {
+	t.Helper()
+	db := testutil.NewDB(t)
+
+	return New(db, zap.NewNop())
+}
+
+func TestParseTemplate(t *testing.T) {
+	capturedAt := time.Date(2026, 3, 7, 12, 0, 0, 0, time.UTC)
+
+	tmpl, err := ParseTemplate(DefaultFolderTemplate)
+	require.NoError(t, err)
+	assert.Equal(t, "2026/03", tmpl.Folder(capturedAt, "pixel"))
+
+	tmpl, err = ParseTemplate(" /Phones/{device}/{year}-{month}-{day}/ ")
+	require.NoError(t, err)
+	assert.Equal(t, "Phones/pixel/2026-03-07", tmpl.Folder(capturedAt, "pixel"))
+
+	for _, invalid := range []string{"", "/", "{year}/{hour}", "{year", "../{year}", "a//b", "./{year}"} {
+		_, err := ParseTemplate(invalid)
+		assert.ErrorIs(t, err, ErrInvalid, invalid)
+	}
+}
+
+func TestNormalizeDeviceID(t *testing.T) {
+	id, err := NormalizeDeviceID(" Alice's Pixel/8 ")
+	require.NoError(t, err)
+	assert.Equal(t, "Alice's Pixel_8", id)
+
+	for _, invalid := range []string{"", "  ", "..", string(make([]rune, MaxDeviceIDLength+1))} {
+		_, err := NormalizeDeviceID(invalid)
+		assert.ErrorIs(t, err, ErrInvalid, invalid)
+	}
+}
+
+func TestNormalizeFilename(t *testing.T) {
+	name, err := NormalizeFilename("DCIM/Camera/IMG_0001.JPG")
+	require.NoError(t, err)
+	assert.Equal(t, "IMG_0001.JPG", name)
+	name, err = NormalizeFilename(`C:\Photos\IMG_0002.HEIC`)
+	require.NoError(t, err)
+	assert.Equal(t, "IMG_0002.HEIC", name)
+
+	for _, invalid := range []string{"", "..", "/"} {
+		_, err := NormalizeFilename(invalid)
+		assert.ErrorIs(t, err, ErrInvalid, invalid)
+	}
+}
+
+func TestCandidate(t *testing.T) {
+	assert.Equal(t, "IMG_0001.JPG", Candidate("IMG_0001.JPG", 1))
+	assert.Equal(t, "IMG_0001 (2).JPG", Candidate("IMG_0001.JPG", 2))
+	assert.Equal(t, "README (3)", Candidate("README", 3))
+}
+
+func TestFindRecord(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+	capturedAt := time.Date(2026, 3, 7, 12, 0, 0, 0, time.UTC)
+
+	record, err := s.Find(ctx, "alice", "system", "abc")
+	require.NoError(t, err)
+	assert.Nil(t, record)
+
+	require.NoError(t, s.Record(ctx, &Record{UserID: "alice", Scope: "system", Hash: "abc", Path: "2026/03/IMG_0001.JPG", DeviceID: "pixel", Filename: "IMG_0001.JPG", CapturedAt: capturedAt}))
+	record, err = s.Find(ctx, "alice", "system", "abc")
+	require.NoError(t, err)
+	require.NotNil(t, record)
+	assert.Equal(t, "2026/03/IMG_0001.JPG", record.Path)
+	assert.Equal(t, "pixel", record.DeviceID)
+	assert.True(t, capturedAt.Equal(record.CapturedAt))
+
+	// Records are per user and storage
+	record, err = s.Find(ctx, "bob", "system", "abc")
+	require.NoError(t, err)
+	assert.Nil(t, record)
+	record, err = s.Find(ctx, "alice", "space:1", "abc")
+	require.NoError(t, err)
+	assert.Nil(t, record)
+
+	// Backing up the same content again replaces the record
+	require.NoError(t, s.Record(ctx, &Record{UserID: "alice", Scope: "system", Hash: "abc", Path: "2026/03/IMG_0001 (2).JPG", DeviceID: "ipad", Filename: "IMG_0001.JPG", CapturedAt: capturedAt}))
+	record, err = s.Find(ctx, "alice", "system", "abc")
+	require.NoError(t, err)
+	assert.Equal(t, "2026/03/IMG_0001 (2).JPG", record.Path)
+	assert.Equal(t, "ipad", record.DeviceID)
+}
@@ -0,0 +1,294 @@
+// Package backup dumps the user data of the database to a gzipped tar
+// archive and restores it into a fresh instance.
+//
+// An archive holds manifest.json and one JSON file per table with its rows.
+// Only data users create is included: accounts, settings, tags, albums,
+// favorites, comments and ratings. Caches, jobs, sessions and other state
+// that is rebuilt or expires are left out. Encrypted settings are left out
+// too, as they are encrypted with a key bound to the database they were
+// written to.
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/migrations"
+	"github.com/cshum/imagor-studio/server/internal/model"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/migrate"
+)
+
+const (
+	// FormatVersion is the archive layout version written by Write
+	FormatVersion = 1
+
+	manifestName = "manifest.json"
+	// insertChunkSize bounds the rows inserted by a single statement
+	insertChunkSize = 500
+	// maxEntrySize bounds archive entries read by Restore
+	maxEntrySize = 1 << 30
+)
+
+var (
+	// ErrNotEmpty is returned by Restore when the database has users already
+	ErrNotEmpty = errors.New("restore requires a database without users")
+	// ErrIncompatible is returned by Restore for archives it cannot read
+	ErrIncompatible = errors.New("incompatible backup")
+)
+
+// Manifest describes a backup archive
+type Manifest struct {
+	FormatVersion int       `json:"formatVersion"`
+	CreatedAt     time.Time `json:"createdAt"`
+	// Migration is the latest migration applied to the backed up database
+	Migration string `json:"migration"`
+	// Tables counts the rows of each table in the archive
+	Tables map[string]int `json:"tables"`
+}
+
+type table struct {
+	name string
+	// rows returns a pointer to an empty slice of the table model
+	rows func() interface{}
+	// filter restricts the rows backed up, nil for all rows
+	filter func(*bun.SelectQuery) *bun.SelectQuery
+}
+
+// tables lists the backed up tables, referenced tables first
+var tables = []table{
+	{name: "users", rows: func() interface{} { return &[]model.User{} }},
+	{name: "registry", rows: func() interface{} { return &[]model.Registry{} }, filter: func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.Where("is_encrypted = ?", false)
+	}},
+	{name: "tags", rows: func() interface{} { return &[]model.Tag{} }},
+	{name: "tag_aliases", rows: func() interface{} { return &[]model.TagAlias{} }},
+	{name: "file_tags", rows: func() interface{} { return &[]model.FileTag{} }},
+	{name: "albums", rows: func() interface{} { return &[]model.Album{} }},
+	{name: "album_items", rows: func() interface{} { return &[]model.AlbumItem{} }},
+	{name: "favorites", rows: func() interface{} { return &[]model.Favorite{} }},
+	{name: "comments", rows: func() interface{} { return &[]model.Comment{} }},
+	{name: "ratings", rows: func() interface{} { return &[]model.Rating{} }},
+}
+
+// latestMigration returns the name of the latest migration applied to db
+func latestMigration(ctx context.Context, db *bun.DB) (string, error) {
+	migrator := migrate.NewMigrator(db, migrations.Migrations)
+	if err := migrator.Init(ctx); err != nil {
+		return "", fmt.Errorf("failed to initialize migrator: %w", err)
+	}
+	ms, err := migrator.MigrationsWithStatus(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get migration status: %w", err)
+	}
+	latest := ""
+	for _, m := range ms {
+		if m.GroupID > 0 && m.Name > latest {
+			latest = m.Name
+		}
+	}
+	return latest, nil
+}
+
+// Write dumps the backed up tables of db to w as a gzipped tar archive
+func Write(ctx context.Context, db *bun.DB, w io.Writer) (*Manifest, error) {
+	migration, err := latestMigration(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &Manifest{
+		FormatVersion: FormatVersion,
+		CreatedAt:     time.Now().UTC(),
+		Migration:     migration,
+		Tables:        make(map[string]int, len(tables)),
+	}
+	entries := make([][]byte, len(tables))
+	for i, t := range tables {
+		rows := t.rows()
+		q := db.NewSelect().Model(rows)
+		if t.filter != nil {
+			q = t.filter(q)
+		}
+		if err := q.Scan(ctx); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", t.name, err)
+		}
+		if entries[i], err = json.Marshal(rows); err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", t.name, err)
+		}
+		manifest.Tables[t.name] = reflect.ValueOf(rows).Elem().Len()
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeEntry(tw, manifestName, data, manifest.CreatedAt); err != nil {
+		return nil, err
+	}
+	for i, t := range tables {
+		if err := writeEntry(tw, t.name+".json", entries[i], manifest.CreatedAt); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+func writeEntry(tw *tar.Writer, name string, data []byte, modified time.Time) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0o600,
+		Size:    int64(len(data)),
+		ModTime: modified,
+	}); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// Restore loads an archive written by Write into db, which must be
+// migrated at least as far as the backed up database and have no users.
+// Settings db has already are kept. The restore is all or nothing.
+func Restore(ctx context.Context, db *bun.DB, r io.Reader) (*Manifest, error) {
+	manifest, entries, err := readArchive(r)
+	if err != nil {
+		return nil, err
+	}
+	migration, err := latestMigration(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	if manifest.Migration > migration {
+		return nil, fmt.Errorf("%w: taken at migration %s, run the pending migrations first", ErrIncompatible, manifest.Migration)
+	}
+	users, err := db.NewSelect().Model((*model.User)(nil)).Count(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count users: %w", err)
+	}
+	if users > 0 {
+		return nil, ErrNotEmpty
+	}
+
+	err = db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		for _, t := range tables {
+			data, ok := entries[t.name+".json"]
+			if !ok {
+				continue
+			}
+			rows := t.rows()
+			if err := json.Unmarshal(data, rows); err != nil {
+				return fmt.Errorf("%w: invalid %s: %v", ErrIncompatible, t.name, err)
+			}
+			if t.name == "registry" {
+				if err := skipExistingSettings(ctx, tx, rows.(*[]model.Registry)); err != nil {
+					return err
+				}
+			}
+			if err := insertRows(ctx, tx, rows); err != nil {
+				return fmt.Errorf("failed to restore %s: %w", t.name, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+func readArchive(r io.Reader) (*Manifest, map[string][]byte, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrIncompatible, err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	entries := make(map[string][]byte)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrIncompatible, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if header.Size > maxEntrySize {
+			return nil, nil, fmt.Errorf("%w: %s is too large", ErrIncompatible, header.Name)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrIncompatible, err)
+		}
+		entries[header.Name] = data
+	}
+	data, ok := entries[manifestName]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %s is missing", ErrIncompatible, manifestName)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, nil, fmt.Errorf("%w: invalid %s: %v", ErrIncompatible, manifestName, err)
+	}
+	if manifest.FormatVersion != FormatVersion {
+		return nil, nil, fmt.Errorf("%w: unsupported format version %d", ErrIncompatible, manifest.FormatVersion)
+	}
+	return &manifest, entries, nil
+}
+
+// skipExistingSettings drops the settings db has already, such as those
+// written on its first start
+func skipExistingSettings(ctx context.Context, tx bun.Tx, rows *[]model.Registry) error {
+	var existing []model.Registry
+	if err := tx.NewSelect().Model(&existing).Column("owner_id", "key").Scan(ctx); err != nil {
+		return fmt.Errorf("failed to read registry: %w", err)
+	}
+	set := make(map[[2]string]bool, len(existing))
+	for _, e := range existing {
+		set[[2]string{e.OwnerID, e.Key}] = true
+	}
+	kept := (*rows)[:0]
+	for _, row := range *rows {
+		if !set[[2]string{row.OwnerID, row.Key}] {
+			kept = append(kept, row)
+		}
+	}
+	*rows = kept
+	return nil
+}
+
+// insertRows inserts the rows of a pointer to a model slice in chunks
+func insertRows(ctx context.Context, tx bun.Tx, rows interface{}) error {
+	v := reflect.ValueOf(rows).Elem()
+	for i := 0; i < v.Len(); i += insertChunkSize {
+		end := i + insertChunkSize
+		if end > v.Len() {
+			end = v.Len()
+		}
+		chunk := reflect.New(v.Type())
+		chunk.Elem().Set(v.Slice(i, end))
+		if _, err := tx.NewInsert().Model(chunk.Interface()).Exec(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
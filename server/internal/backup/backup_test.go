@@ -0,0 +1,93 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/model"
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+)
+
+func insert(t *testing.T, db *bun.DB, rows ...interface{}) {
+	t.Helper()
+	for _, row := range rows {
+		_, err := db.NewInsert().Model(row).Exec(context.Background())
+		require.NoError(t, err)
+	}
+}
+
+func TestWriteRestore(t *testing.T) {
+	ctx := context.Background()
+	source := testutil.NewDB(t)
+	now := time.Now().UTC().Truncate(time.Second)
+	insert(t, source,
+		&model.User{ID: "u1", DisplayName: "Alice", Username: "alice", HashedPassword: "hash", Role: "admin", IsActive: true, CreatedAt: now, UpdatedAt: now},
+		&model.Registry{ID: "r1", OwnerID: "system:global", Key: "config.app_title", Value: "Photos", CreatedAt: now, UpdatedAt: now},
+		&model.Registry{ID: "r2", OwnerID: "system:global", Key: "config.jwt_secret", Value: "sealed", IsEncrypted: true, CreatedAt: now, UpdatedAt: now},
+		&model.Registry{ID: "r3", OwnerID: "system:global", Key: "config.app_default_language", Value: "de", CreatedAt: now, UpdatedAt: now},
+		&model.Tag{ID: "t1", Scope: "system:global", Name: "Dogs", Path: "Dogs", PathKey: "dogs", CreatedAt: now, UpdatedAt: now},
+		&model.FileTag{ID: "ft1", Scope: "system:global", FilePath: "a.jpg", TagID: "t1", CreatedAt: now},
+		&model.Album{ID: "a1", Scope: "system:global", Name: "Trip", CreatedBy: "u1", CreatedAt: now, UpdatedAt: now},
+		&model.AlbumItem{ID: "ai1", AlbumID: "a1", Scope: "system:global", FilePath: "a.jpg", AddedAt: now},
+		&model.Favorite{ID: "f1", UserID: "u1", Scope: "system:global", FilePath: "a.jpg", CreatedAt: now},
+	)
+
+	var buf bytes.Buffer
+	manifest, err := Write(ctx, source, &buf)
+	require.NoError(t, err)
+	assert.Equal(t, FormatVersion, manifest.FormatVersion)
+	assert.NotEmpty(t, manifest.Migration)
+	assert.Equal(t, 1, manifest.Tables["users"])
+	assert.Equal(t, 2, manifest.Tables["registry"])
+
+	target := testutil.NewDB(t)
+	// Settings written by the fresh instance are kept
+	insert(t, target, &model.Registry{ID: "r9", OwnerID: "system:global", Key: "config.app_default_language", Value: "en", CreatedAt: now, UpdatedAt: now})
+	restored, err := Restore(ctx, target, bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, manifest.Tables, restored.Tables)
+
+	var user model.User
+	require.NoError(t, target.NewSelect().Model(&user).Where("id = ?", "u1").Scan(ctx))
+	assert.Equal(t, "alice", user.Username)
+	assert.Equal(t, "hash", user.HashedPassword)
+
+	var settings []model.Registry
+	require.NoError(t, target.NewSelect().Model(&settings).Order("key").Scan(ctx))
+	require.Len(t, settings, 2)
+	assert.Equal(t, "en", settings[0].Value)
+	assert.Equal(t, "Photos", settings[1].Value)
+
+	for _, m := range []interface{}{(*model.Tag)(nil), (*model.FileTag)(nil), (*model.Album)(nil), (*model.AlbumItem)(nil), (*model.Favorite)(nil)} {
+		count, err := target.NewSelect().Model(m).Count(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+	}
+
+	// A second restore would duplicate the data
+	_, err = Restore(ctx, target, bytes.NewReader(buf.Bytes()))
+	assert.ErrorIs(t, err, ErrNotEmpty)
+}
+
+func TestRestore_Incompatible(t *testing.T) {
+	ctx := context.Background()
+	db := testutil.NewDB(t)
+
+	_, err := Restore(ctx, db, bytes.NewReader([]byte("not an archive")))
+	assert.ErrorIs(t, err, ErrIncompatible)
+
+	// Backups of newer schemas need the pending migrations first
+	source := testutil.NewDB(t)
+	var buf bytes.Buffer
+	_, err = Write(ctx, source, &buf)
+	require.NoError(t, err)
+	_, err = db.NewDelete().Table("bun_migrations").Where("name = (SELECT MAX(name) FROM bun_migrations)").Exec(ctx)
+	require.NoError(t, err)
+	_, err = Restore(ctx, db, bytes.NewReader(buf.Bytes()))
+	assert.ErrorIs(t, err, ErrIncompatible)
+}
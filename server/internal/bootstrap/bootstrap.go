@@ -6,16 +6,39 @@ import (
 	"encoding/base64"
 	"fmt"
 
+	"github.com/cshum/imagor-studio/server/internal/albumstore"
+	"github.com/cshum/imagor-studio/server/internal/apitoken"
+	"github.com/cshum/imagor-studio/server/internal/auditlog"
+	"github.com/cshum/imagor-studio/server/internal/autoupload"
+	"github.com/cshum/imagor-studio/server/internal/commentstore"
 	"github.com/cshum/imagor-studio/server/internal/config"
 	"github.com/cshum/imagor-studio/server/internal/database"
+	"github.com/cshum/imagor-studio/server/internal/dedupe"
+	"github.com/cshum/imagor-studio/server/internal/faces"
+	"github.com/cshum/imagor-studio/server/internal/favoritestore"
+	"github.com/cshum/imagor-studio/server/internal/filejournal"
+	"github.com/cshum/imagor-studio/server/internal/filemeta"
+	"github.com/cshum/imagor-studio/server/internal/foldercover"
+	"github.com/cshum/imagor-studio/server/internal/foldersettings"
+	"github.com/cshum/imagor-studio/server/internal/imageedit"
 	"github.com/cshum/imagor-studio/server/internal/imagorprovider"
+	"github.com/cshum/imagor-studio/server/internal/jobqueue"
 	"github.com/cshum/imagor-studio/server/internal/license"
 	"github.com/cshum/imagor-studio/server/internal/managementruntime"
 	"github.com/cshum/imagor-studio/server/internal/migrator"
 	"github.com/cshum/imagor-studio/server/internal/noop"
+	"github.com/cshum/imagor-studio/server/internal/operation"
+	"github.com/cshum/imagor-studio/server/internal/ratingstore"
 	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/internal/s3key"
+	"github.com/cshum/imagor-studio/server/internal/sessionstore"
+	"github.com/cshum/imagor-studio/server/internal/sharestore"
 	"github.com/cshum/imagor-studio/server/internal/storageprovider"
+	"github.com/cshum/imagor-studio/server/internal/storagestats"
+	"github.com/cshum/imagor-studio/server/internal/tagstore"
+	"github.com/cshum/imagor-studio/server/internal/tenantstore"
 	"github.com/cshum/imagor-studio/server/internal/userstore"
+	"github.com/cshum/imagor-studio/server/internal/webhook"
 	"github.com/cshum/imagor-studio/server/pkg/auth"
 	"github.com/cshum/imagor-studio/server/pkg/billing"
 	"github.com/cshum/imagor-studio/server/pkg/encryption"
@@ -41,8 +64,31 @@ type Services struct {
 	Storage                 storage.Storage
 	StorageProvider         *storageprovider.Provider
 	ImagorProvider          *imagorprovider.Provider
+	ProcessingScheduler     *jobqueue.Scheduler // nil on processing nodes
 	RegistryStore           registrystore.Store
 	UserStore               userstore.Store
+	TagStore                tagstore.Store
+	FavoriteStore           favoritestore.Store
+	AlbumStore              albumstore.Store
+	TenantStore             tenantstore.Store // nil unless tenants are enabled
+	CommentStore            commentstore.Store
+	RatingStore             ratingstore.Store
+	AuditLog                auditlog.Store
+	WebhookStore            webhook.Store
+	FileJournal             filejournal.Store
+	AutoUploadStore         autoupload.Store
+	APITokenStore           apitoken.Store
+	S3KeyStore              s3key.Store
+	SessionStore            sessionstore.Store
+	ShareStore              sharestore.Store
+	FileMetaStore           filemeta.Store
+	DuplicateStore          dedupe.Store
+	FaceStore               faces.Store
+	ImageEditStore          imageedit.Store
+	FolderCoverStore        foldercover.Store
+	FolderSettingsStore     foldersettings.Store
+	StorageStatsStore       storagestats.Store
+	OperationStore          operation.Store
 	OrgStore                org.OrgStore                    // nil in self-hosted; set in cloud multi-tenant mode
 	SpaceStore              space.SpaceStore                // nil in self-hosted; set in cloud multi-tenant mode
 	SpaceInviteStore        space.SpaceInviteStore          // nil when invitation storage is unavailable
@@ -146,6 +192,9 @@ func initializeRuntimeMode(cfg *config.Config, logger *zap.Logger, args []string
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize storage: %w", err)
 	}
+	if err := storageProvider.SyncMounts(); err != nil {
+		logger.Warn("Failed to load storage mounts", zap.Error(err))
+	}
 
 	// Get the current storage instance
 	stor := storageProvider.GetStorage()
@@ -153,6 +202,71 @@ func initializeRuntimeMode(cfg *config.Config, logger *zap.Logger, args []string
 	// Initialize user store
 	userStore := userstore.New(db, logger)
 
+	// Initialize tag store
+	tagStore := tagstore.New(db, logger)
+
+	// Initialize favorite store
+	favoriteStore := favoritestore.New(db, logger)
+
+	// Initialize album store
+	albumStore := albumstore.New(db, logger)
+
+	// Initialize tenant store
+	var tenantStore tenantstore.Store
+	if cfg.TenantsEnabled {
+		tenantStore = tenantstore.New(db, logger)
+	}
+
+	// Initialize comment store
+	commentStore := commentstore.New(db, logger)
+
+	// Initialize rating store
+	ratingStore := ratingstore.New(db, logger)
+
+	// Initialize audit log
+	auditLog := auditlog.New(db, logger)
+
+	// Initialize webhook store
+	webhookStore := webhook.New(db, logger)
+
+	// Initialize the journal of file changes
+	fileJournal := filejournal.New(db, logger)
+
+	// Initialize the record of phone backups
+	autoUploadStore := autoupload.New(db, logger)
+
+	// Initialize API token store
+	apiTokenStore := apitoken.New(db, logger)
+
+	// Initialize S3 access key store
+	s3KeyStore := s3key.New(db, logger)
+
+	// Initialize login session store
+	sessionStore := sessionstore.New(db, logger, cfg.SessionExpiration)
+
+	// Initialize shared link store
+	shareStore := sharestore.New(db, logger)
+
+	// Initialize file metadata cache
+	fileMetaStore := filemeta.NewStore(db, logger)
+
+	// Initialize content hash store for duplicate detection
+	duplicateStore := dedupe.NewStore(db, logger)
+
+	// Initialize face store for people albums
+	faceStore := faces.NewStore(db, logger)
+
+	// Initialize image edit store
+	imageEditStore := imageedit.NewStore(db, logger)
+
+	// Initialize folder cover store
+	folderCoverStore := foldercover.NewStore(db, logger)
+	folderSettingsStore := foldersettings.NewStore(db, logger)
+	storageStatsStore := storagestats.NewStore(db, logger)
+
+	// Initialize operation store, shared by all server replicas
+	operationStore := operation.NewStore(db, logger)
+
 	var (
 		orgStore             org.OrgStore
 		spaceStore           space.SpaceStore
@@ -224,7 +338,9 @@ func initializeRuntimeMode(cfg *config.Config, logger *zap.Logger, args []string
 	loader := imagorprovider.NewStorageLoader(storageProvider)
 
 	// Initialize imagor provider with the management-node loader.
-	imagorProvider := imagorprovider.New(logger, registryStore, enhancedCfg, loader)
+	processingScheduler := newProcessingScheduler(enhancedCfg)
+	imagorProvider := imagorprovider.New(logger, registryStore, enhancedCfg, loader,
+		imagorprovider.WithProcessorDecorator(processingScheduler))
 
 	// Initialize imagor with config (will use disabled if not configured)
 	err = imagorProvider.Initialize()
@@ -250,8 +366,31 @@ func initializeRuntimeMode(cfg *config.Config, logger *zap.Logger, args []string
 		Storage:                 stor,
 		StorageProvider:         storageProvider,
 		ImagorProvider:          imagorProvider,
+		ProcessingScheduler:     processingScheduler,
 		RegistryStore:           registryStore,
 		UserStore:               userStore,
+		TagStore:                tagStore,
+		FavoriteStore:           favoriteStore,
+		AlbumStore:              albumStore,
+		TenantStore:             tenantStore,
+		CommentStore:            commentStore,
+		RatingStore:             ratingStore,
+		AuditLog:                auditLog,
+		WebhookStore:            webhookStore,
+		FileJournal:             fileJournal,
+		AutoUploadStore:         autoUploadStore,
+		APITokenStore:           apiTokenStore,
+		S3KeyStore:              s3KeyStore,
+		SessionStore:            sessionStore,
+		ShareStore:              shareStore,
+		FileMetaStore:           fileMetaStore,
+		DuplicateStore:          duplicateStore,
+		FaceStore:               faceStore,
+		ImageEditStore:          imageEditStore,
+		FolderCoverStore:        folderCoverStore,
+		FolderSettingsStore:     folderSettingsStore,
+		StorageStatsStore:       storageStatsStore,
+		OperationStore:          operationStore,
 		OrgStore:                orgStore,
 		SpaceStore:              spaceStore,
 		SpaceInviteStore:        spaceInviteStore,
@@ -299,7 +438,9 @@ func initializeEmbeddedMode(cfg *config.Config, logger *zap.Logger) (*Services,
 	stor := storageProvider.GetStorage()
 
 	// Initialize imagor provider with no-op registry store, config, and storage provider
-	imagorProvider := imagorprovider.New(logger, registryStore, cfg, imagorprovider.NewStorageLoader(storageProvider))
+	processingScheduler := newProcessingScheduler(cfg)
+	imagorProvider := imagorprovider.New(logger, registryStore, cfg, imagorprovider.NewStorageLoader(storageProvider),
+		imagorprovider.WithProcessorDecorator(processingScheduler))
 
 	// Initialize imagor with config (will use disabled if not configured)
 	err = imagorProvider.Initialize()
@@ -319,20 +460,21 @@ func initializeEmbeddedMode(cfg *config.Config, logger *zap.Logger) (*Services,
 	)
 
 	return &Services{
-		DB:                 nil, // No database in embedded mode
-		TokenManager:       tokenManager,
-		Storage:            stor,
-		StorageProvider:    storageProvider,
-		ImagorProvider:     imagorProvider,
-		RegistryStore:      registryStore,
-		UserStore:          userStore,
-		SpaceInviteStore:   nil,
-		InviteSender:       nil,
-		SignupVerification: nil,
-		LicenseService:     licenseService,
-		Encryption:         nil, // No encryption service in embedded mode
-		Config:             cfg,
-		Logger:             logger,
+		DB:                  nil, // No database in embedded mode
+		TokenManager:        tokenManager,
+		Storage:             stor,
+		StorageProvider:     storageProvider,
+		ImagorProvider:      imagorProvider,
+		ProcessingScheduler: processingScheduler,
+		RegistryStore:       registryStore,
+		UserStore:           userStore,
+		SpaceInviteStore:    nil,
+		InviteSender:        nil,
+		SignupVerification:  nil,
+		LicenseService:      licenseService,
+		Encryption:          nil, // No encryption service in embedded mode
+		Config:              cfg,
+		Logger:              logger,
 	}, nil
 }
 
@@ -416,3 +558,9 @@ func generateSecureJWTSecret() (string, error) {
 	// Encode as base64 for safe storage and transmission
 	return base64.StdEncoding.EncodeToString(bytes), nil
 }
+
+// newProcessingScheduler creates the priority scheduler wrapping the imagor
+// processors, so interactive thumbnails are served ahead of background work
+func newProcessingScheduler(cfg *config.Config) *jobqueue.Scheduler {
+	return jobqueue.New(cfg.ProcessingConcurrency, jobqueue.WithReservedSlots(cfg.ProcessingReservedSlots))
+}
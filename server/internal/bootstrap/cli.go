@@ -0,0 +1,77 @@
+package bootstrap
+
+import (
+	"fmt"
+
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/internal/storageprovider"
+	"github.com/cshum/imagor-studio/server/internal/userstore"
+	"github.com/cshum/imagor-studio/server/pkg/encryption"
+	"github.com/uptrace/bun"
+	"go.uber.org/zap"
+)
+
+// CLIServices are the stores used by the command line subcommands
+type CLIServices struct {
+	DB              *bun.DB
+	RegistryStore   registrystore.Store
+	UserStore       userstore.Store
+	StorageProvider *storageprovider.Provider
+	Config          *config.Config
+}
+
+// Close closes the database connection
+func (s *CLIServices) Close() error {
+	return s.DB.Close()
+}
+
+// InitializeCLI connects to the database of a self-hosted server and sets up
+// the stores operators manage from the command line, without starting any
+// of the server services. Migrations are applied as on server start.
+func InitializeCLI(cfg *config.Config, logger *zap.Logger, args []string) (*CLIServices, error) {
+	if cfg.EmbeddedMode {
+		return nil, fmt.Errorf("embedded mode has no database to manage")
+	}
+	db, err := initializeDatabase(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+	initOK := false
+	defer func() {
+		if !initOK {
+			_ = db.Close()
+		}
+	}()
+	if err := runMigrationsIfNeeded(db, cfg, logger, nil); err != nil {
+		return nil, fmt.Errorf("failed to run migrations: %w", err)
+	}
+
+	encryptionService := encryption.NewService(cfg.DatabaseURL)
+	registryStore := registrystore.New(db, logger, encryptionService)
+	if err := resolveJWTSecret(cfg, registryStore); err != nil {
+		return nil, fmt.Errorf("failed to resolve JWT secret: %w", err)
+	}
+	encryptionService.SetJWTKey(cfg.JWTSecret)
+
+	enhancedCfg, err := config.Load(args, registryStore)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply registry values to config: %w", err)
+	}
+	if enhancedCfg.JWTSecret == "" {
+		enhancedCfg.JWTSecret = cfg.JWTSecret
+	}
+	storageProvider := storageprovider.New(logger, registryStore, enhancedCfg)
+	if err := storageProvider.InitializeWithConfig(enhancedCfg); err != nil {
+		return nil, fmt.Errorf("failed to initialize storage: %w", err)
+	}
+
+	initOK = true
+	return &CLIServices{
+		DB:              db,
+		RegistryStore:   registryStore,
+		UserStore:       userstore.New(db, logger),
+		StorageProvider: storageProvider,
+		Config:          enhancedCfg,
+	}, nil
+}
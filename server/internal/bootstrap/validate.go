@@ -0,0 +1,192 @@
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/database"
+	"github.com/cshum/imagor-studio/server/internal/imagorprovider"
+	"github.com/cshum/imagor-studio/server/internal/migrations"
+	"github.com/cshum/imagor-studio/server/internal/noop"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/internal/storageprovider"
+	"github.com/cshum/imagor-studio/server/pkg/encryption"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/migrate"
+	"go.uber.org/zap"
+)
+
+// ConfigCheck is the outcome of a step of ValidateConfig
+type ConfigCheck struct {
+	Name   string
+	Detail string
+	// Warning marks checks that pass but need attention
+	Warning bool
+	Err     error
+}
+
+// ConfigReport is the outcome of ValidateConfig
+type ConfigReport struct {
+	Checks []ConfigCheck
+	// Config is the effective configuration, with registry values applied
+	// when the registry could be read
+	Config *config.Config
+}
+
+// OK reports whether every check passed
+func (r *ConfigReport) OK() bool {
+	for _, check := range r.Checks {
+		if check.Err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *ConfigReport) add(name, detail string, err error) {
+	r.Checks = append(r.Checks, ConfigCheck{Name: name, Detail: detail, Err: err})
+}
+
+func (r *ConfigReport) warn(name, detail string) {
+	r.Checks = append(r.Checks, ConfigCheck{Name: name, Detail: detail, Warning: true})
+}
+
+// ValidateConfig runs the startup steps of the server in dry-run mode:
+// connecting to the database, reading the registry, creating the storage and
+// imagor, without writing anything. Missing SQLite databases are not created,
+// pending migrations are reported rather than applied and a missing JWT
+// secret is not generated.
+func ValidateConfig(cfg *config.Config, logger *zap.Logger, args []string) *ConfigReport {
+	report := &ConfigReport{Config: cfg}
+	if cfg.EmbeddedMode {
+		report.add("mode", "embedded, no database", nil)
+		validateStorageAndImagor(report, noop.NewRegistryStore(), cfg, logger)
+		return report
+	}
+
+	if dbCfg, err := database.ParseDatabaseURL(cfg.DatabaseURL); err == nil && dbCfg.Type == "sqlite" && !strings.Contains(dbCfg.Path, ":memory:") {
+		if _, err := os.Stat(strings.SplitN(dbCfg.Path, "?", 2)[0]); errors.Is(err, os.ErrNotExist) {
+			report.warn("database", dbCfg.Path+" does not exist, created at startup")
+			validateStorageAndImagor(report, noop.NewRegistryStore(), cfg, logger)
+			return report
+		}
+	}
+	db, err := initializeDatabase(cfg)
+	if err == nil {
+		err = db.PingContext(context.Background())
+	}
+	report.add("database", config.MaskValue("database-url", cfg.DatabaseURL), err)
+	if err != nil {
+		return report
+	}
+	defer func() { _ = db.Close() }()
+
+	if exists, err := tableExists(context.Background(), db, "bun_migrations"); err != nil || !exists {
+		if err != nil {
+			report.add("migrations", "", fmt.Errorf("failed to read migration status: %w", err))
+			return report
+		}
+		// The registry does not exist yet, check the rest without it
+		report.warn("migrations", "database not initialized, migrations run at startup for SQLite or --force-auto-migrate, otherwise by imagor-studio-migrate")
+		validateStorageAndImagor(report, noop.NewRegistryStore(), cfg, logger)
+		return report
+	}
+	ms, err := migrate.NewMigrator(db, migrations.Migrations).MigrationsWithStatus(context.Background())
+	if err != nil {
+		report.add("migrations", "", fmt.Errorf("failed to read migration status: %w", err))
+		return report
+	}
+	pending := len(ms.Unapplied())
+	detail := fmt.Sprintf("%d applied, %d pending", len(ms)-pending, pending)
+	if pending > 0 {
+		report.warn("migrations", detail+", applied at startup for SQLite or --force-auto-migrate, otherwise by imagor-studio-migrate")
+	} else {
+		report.add("migrations", detail, nil)
+	}
+
+	encryptionService := encryption.NewService(cfg.DatabaseURL)
+	registryStore := registrystore.New(db, logger, encryptionService)
+	jwtSecret := cfg.JWTSecret
+	if jwtSecret != "" {
+		report.add("jwt secret", "set", nil)
+	} else {
+		entry, err := registryStore.Get(context.Background(), registrystore.SystemOwnerID, "config.jwt_secret")
+		switch {
+		case err != nil:
+			report.add("jwt secret", "", fmt.Errorf("failed to read registry: %w", err))
+			return report
+		case entry == nil || entry.Value == "":
+			report.warn("jwt secret", "not set, generated at startup")
+		default:
+			jwtSecret = entry.Value
+			report.add("jwt secret", "from registry", nil)
+		}
+	}
+	encryptionService.SetJWTKey(jwtSecret)
+
+	enhancedCfg, err := config.Load(args, registryStore)
+	if err != nil {
+		report.add("registry", "", fmt.Errorf("failed to apply registry values to config: %w", err))
+		return report
+	}
+	if enhancedCfg.JWTSecret == "" {
+		enhancedCfg.JWTSecret = jwtSecret
+	}
+	report.Config = enhancedCfg
+	prefix := "config."
+	entries, err := registryStore.List(context.Background(), registrystore.SystemOwnerID, &prefix)
+	report.add("registry", fmt.Sprintf("%d system settings", len(entries)), err)
+
+	validateStorageAndImagor(report, registryStore, enhancedCfg, logger)
+	return report
+}
+
+// tableExists reports whether the database has the table
+func tableExists(ctx context.Context, db *bun.DB, name string) (bool, error) {
+	q := db.NewSelect().ColumnExpr("1")
+	switch db.Dialect().Name() {
+	case dialect.SQLite:
+		q = q.TableExpr("sqlite_master").Where("type = 'table' AND name = ?", name)
+	case dialect.MySQL:
+		q = q.TableExpr("information_schema.tables").Where("table_schema = DATABASE() AND table_name = ?", name)
+	default:
+		q = q.TableExpr("information_schema.tables").Where("table_schema = current_schema() AND table_name = ?", name)
+	}
+	return q.Exists(ctx)
+}
+
+// validateStorageAndImagor creates the storage and lists its root, then
+// creates imagor and shuts it down again
+func validateStorageAndImagor(report *ConfigReport, registryStore registrystore.Store, cfg *config.Config, logger *zap.Logger) {
+	storageProvider := storageprovider.New(logger, registryStore, cfg)
+	stor, err := storageProvider.NewStorageFromConfig(cfg)
+	if err == nil {
+		_, err = stor.List(context.Background(), "", storage.ListOptions{Limit: 1})
+	}
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		report.warn("storage", fmt.Sprintf("%s, %v", cfg.StorageType, err))
+	default:
+		report.add("storage", cfg.StorageType, err)
+	}
+	if err == nil {
+		_ = storageProvider.InitializeWithConfig(cfg)
+	}
+
+	imagorProvider := imagorprovider.New(logger, registryStore, cfg, imagorprovider.NewStorageLoader(storageProvider))
+	err = imagorProvider.Initialize()
+	detail := "unsigned URLs"
+	if imagorCfg := imagorProvider.Config(); imagorCfg != nil && imagorCfg.Secret != "" {
+		detail = "signed URLs, " + imagorCfg.SignerType
+	}
+	report.add("imagor", detail, err)
+	if err == nil {
+		_ = imagorProvider.Shutdown(context.Background())
+	}
+}
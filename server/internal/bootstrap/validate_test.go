@@ -0,0 +1,69 @@
+package bootstrap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func checkStatuses(report *ConfigReport) map[string]string {
+	statuses := make(map[string]string)
+	for _, check := range report.Checks {
+		switch {
+		case check.Err != nil:
+			statuses[check.Name] = "fail"
+		case check.Warning:
+			statuses[check.Name] = "warn"
+		default:
+			statuses[check.Name] = "ok"
+		}
+	}
+	return statuses
+}
+
+func TestValidateConfig(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "imagor-studio.db")
+	args := []string{"--database-url", "sqlite:" + dbPath, "--file-storage-base-dir", dir}
+	cfg, err := config.Load(args, nil)
+	require.NoError(t, err)
+
+	// Dry runs leave a missing database alone
+	report := ValidateConfig(cfg, zap.NewNop(), args)
+	assert.True(t, report.OK())
+	assert.Equal(t, map[string]string{"database": "warn", "storage": "ok", "imagor": "ok"}, checkStatuses(report))
+	_, err = os.Stat(dbPath)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	services, err := InitializeCLI(cfg, zap.NewNop(), args)
+	require.NoError(t, err)
+	_, err = services.RegistryStore.Set(t.Context(), "system:global", "config.app_title", "Photos", false)
+	require.NoError(t, err)
+	require.NoError(t, services.Close())
+
+	report = ValidateConfig(cfg, zap.NewNop(), args)
+	assert.True(t, report.OK())
+	assert.Equal(t, map[string]string{
+		"database": "ok", "migrations": "ok", "jwt secret": "ok", "registry": "ok", "storage": "ok", "imagor": "ok",
+	}, checkStatuses(report))
+	assert.Equal(t, "Photos", report.Config.AppTitle, "registry values are applied")
+}
+
+func TestValidateConfigFailures(t *testing.T) {
+	dir := t.TempDir()
+	// The storage base dir is a file
+	baseDir := filepath.Join(dir, "gallery")
+	require.NoError(t, os.WriteFile(baseDir, []byte("x"), 0o644))
+	args := []string{"--database-url", "sqlite:" + filepath.Join(dir, "imagor-studio.db"), "--file-storage-base-dir", baseDir}
+	cfg, err := config.Load(args, nil)
+	require.NoError(t, err)
+
+	report := ValidateConfig(cfg, zap.NewNop(), args)
+	assert.False(t, report.OK())
+	assert.Equal(t, "fail", checkStatuses(report)["storage"])
+}
@@ -0,0 +1,92 @@
+package bulkdownload
+
+import (
+	"archive/zip"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// ArchiveTTL is the validity of tokens issued for a single zip download.
+// A transfer in progress is not interrupted when its token expires.
+const ArchiveTTL = 15 * time.Minute
+
+const archiveSegment = "archive"
+
+// ArchivePath returns the zip download path of a token relative to the
+// server origin. name is the archive file name without extension.
+func (h *Handler) ArchivePath(tokenID, name string) string {
+	name = strings.Trim(strings.ReplaceAll(name, "/", "_"), ". ")
+	if name == "" {
+		name = "download"
+	}
+	return h.basePath + "/" + tokenID + "/" + archiveSegment + "/" + url.PathEscape(name+".zip")
+}
+
+// serveArchive streams the token files as a zip archive, reading one file
+// at a time from storage. Files are stored uncompressed, as images and
+// videos are compressed already. Entries are named relative to the folder
+// the files have in common.
+func (h *Handler) serveArchive(w http.ResponseWriter, r *http.Request, token *Token, name string) {
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	prefix := commonDir(token.Files)
+	modified := time.Now()
+	zw := zip.NewWriter(w)
+	for _, f := range token.Files {
+		if err := writeArchiveEntry(r, zw, token, f, strings.TrimPrefix(f.Path, prefix), modified); err != nil {
+			// The status line is sent already, abort so the client does
+			// not take the truncated archive for a complete one
+			panic(http.ErrAbortHandler)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		panic(http.ErrAbortHandler)
+	}
+}
+
+func writeArchiveEntry(r *http.Request, zw *zip.Writer, token *Token, f File, name string, modified time.Time) error {
+	reader, err := token.storage.Get(r.Context(), f.Path)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	header := &zip.FileHeader{
+		Name:               name,
+		Method:             zip.Store,
+		Modified:           modified,
+		UncompressedSize64: uint64(f.Size),
+	}
+	entry, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(entry, reader)
+	return err
+}
+
+// commonDir returns the longest folder prefix, with trailing slash, shared
+// by all files
+func commonDir(files []File) string {
+	if len(files) == 0 {
+		return ""
+	}
+	prefix := path.Dir(files[0].Path)
+	for _, f := range files[1:] {
+		for prefix != "." && !strings.HasPrefix(f.Path, prefix+"/") {
+			prefix = path.Dir(prefix)
+		}
+	}
+	if prefix == "." || prefix == "/" {
+		return ""
+	}
+	return prefix + "/"
+}
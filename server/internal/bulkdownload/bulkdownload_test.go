@@ -0,0 +1,233 @@
+package bulkdownload
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStorage(t *testing.T, files map[string]string) *filestorage.FileStorage {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		full := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(full), 0755))
+		require.NoError(t, os.WriteFile(full, []byte(content), 0644))
+	}
+	stor, err := filestorage.New(dir)
+	require.NoError(t, err)
+	return stor
+}
+
+func TestManager_CreateAndExpire(t *testing.T) {
+	now := time.Now()
+	m := NewManager(WithTTL(time.Hour), WithMaxFiles(2), WithMaxTokens(1))
+	m.now = func() time.Time { return now }
+
+	_, err := m.Create(nil, "user-1", nil)
+	assert.ErrorIs(t, err, ErrEmptySelection)
+	_, err = m.Create(nil, "user-1", []File{{Path: "a"}, {Path: "b"}, {Path: "c"}})
+	assert.ErrorIs(t, err, ErrTooManyFiles)
+
+	token, err := m.Create(nil, "user-1", []File{{Path: "a", Size: 2}, {Path: "b", Size: 3}})
+	require.NoError(t, err)
+	assert.Len(t, token.ID, 48)
+	assert.Equal(t, int64(5), token.TotalBytes())
+	assert.Equal(t, now.Add(time.Hour), token.ExpiresAt)
+
+	_, err = m.Create(nil, "user-1", []File{{Path: "a"}})
+	assert.ErrorIs(t, err, ErrTooManyTokens)
+
+	got, err := m.Get(token.ID)
+	require.NoError(t, err)
+	assert.Same(t, token, got)
+
+	now = now.Add(time.Hour)
+	_, err = m.Get(token.ID)
+	assert.ErrorIs(t, err, ErrTokenNotFound)
+	// Expired tokens no longer count towards the limit
+	_, err = m.Create(nil, "user-1", []File{{Path: "a"}})
+	assert.NoError(t, err)
+}
+
+func TestManager_Revoke(t *testing.T) {
+	m := NewManager()
+	token, err := m.Create(nil, "user-1", []File{{Path: "a"}})
+	require.NoError(t, err)
+	m.Revoke(token.ID)
+	_, err = m.Get(token.ID)
+	assert.ErrorIs(t, err, ErrTokenNotFound)
+}
+
+func TestHandler_List(t *testing.T) {
+	m := NewManager()
+	h := NewHandler(m, "/api/downloads/")
+	token, err := m.Create(nil, "user-1", []File{{Path: "album/a b.jpg"}, {Path: "c.mp4"}})
+	require.NoError(t, err)
+	assert.Equal(t, "/api/downloads/"+token.ID+"?format=aria2", h.ListPath(token.ID, FormatAria2))
+
+	req := httptest.NewRequest(http.MethodGet, "/"+token.ID, nil)
+	req.Host = "studio.example.com"
+	req.Header.Set("X-Forwarded-Proto", "https")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	base := "https://studio.example.com/api/downloads/" + token.ID + "/files/"
+	assert.Equal(t, base+"album/a%20b.jpg\n"+base+"c.mp4\n", rec.Body.String())
+
+	req = httptest.NewRequest(http.MethodGet, "/"+token.ID+"?format=aria2", nil)
+	req.Host = "localhost:8000"
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "http://localhost:8000/api/downloads/"+token.ID+"/files/album/a%20b.jpg\n  out=album/a b.jpg\n")
+
+	req = httptest.NewRequest(http.MethodGet, "/"+token.ID+"?format=zip", nil)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/unknown", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestHandler_ServeFileWithRange(t *testing.T) {
+	stor := newTestStorage(t, map[string]string{"album/a b.jpg": "0123456789", "secret.txt": "no"})
+	m := NewManager()
+	h := NewHandler(m, "/api/downloads")
+	token, err := m.Create(stor, "user-1", []File{{Path: "album/a b.jpg", Size: 10}})
+	require.NoError(t, err)
+	fileURL := "/" + token.ID + "/files/album/a%20b.jpg"
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, fileURL, nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "0123456789", rec.Body.String())
+	assert.Equal(t, "bytes", rec.Header().Get("Accept-Ranges"))
+	assert.Equal(t, "image/jpeg", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Header().Get("Content-Disposition"), `filename="a b.jpg"`)
+
+	req := httptest.NewRequest(http.MethodGet, fileURL, nil)
+	req.Header.Set("Range", "bytes=4-")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusPartialContent, rec.Code)
+	assert.Equal(t, "456789", rec.Body.String())
+	assert.Equal(t, "bytes 4-9/10", rec.Header().Get("Content-Range"))
+
+	req = httptest.NewRequest(http.MethodHead, fileURL, nil)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "10", rec.Header().Get("Content-Length"))
+
+	// Files outside the selection are not served
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/"+token.ID+"/files/secret.txt", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestHandler_ServeMedia(t *testing.T) {
+	stor := newTestStorage(t, map[string]string{"clips/beach.mp4": "0123456789", "photo.jpg": "jpeg"})
+	m := NewManager()
+	h := NewHandler(m, "/api/downloads")
+	token, err := m.Create(stor, "user-1", []File{{Path: "clips/beach.mp4", Size: 10}, {Path: "photo.jpg", Size: 4}})
+	require.NoError(t, err)
+	mediaPath := h.MediaPath(token.ID, "clips/beach.mp4")
+	assert.Equal(t, "/api/downloads/"+token.ID+"/media/clips/beach.mp4", mediaPath)
+
+	req := httptest.NewRequest(http.MethodGet, strings.TrimPrefix(mediaPath, "/api/downloads"), nil)
+	req.Header.Set("Range", "bytes=2-5")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusPartialContent, rec.Code)
+	assert.Equal(t, "2345", rec.Body.String())
+	assert.Equal(t, "video/mp4", rec.Header().Get("Content-Type"))
+	assert.Empty(t, rec.Header().Get("Content-Disposition"))
+	assert.Equal(t, "*", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, []string{"Streaming"}, rec.Header()["transferMode.dlna.org"])
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodHead, "/"+token.ID+"/media/photo.jpg", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "image/jpeg", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "4", rec.Header().Get("Content-Length"))
+	assert.Equal(t, []string{"Interactive"}, rec.Header()["transferMode.dlna.org"])
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/"+token.ID+"/media/other.mp4", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestHandler_ServeStoredContentType(t *testing.T) {
+	stor := newTestStorage(t, map[string]string{"photo.jpg": "png"})
+	m := NewManager()
+	h := NewHandler(m, "/api/downloads")
+	token, err := m.Create(stor, "user-1", []File{{Path: "photo.jpg", Size: 3, ContentType: "image/png"}})
+	require.NoError(t, err)
+
+	// The content type recorded on upload wins over the extension
+	for _, kind := range []string{"files", "media"} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/"+token.ID+"/"+kind+"/photo.jpg", nil))
+		require.Equal(t, http.StatusOK, rec.Code, kind)
+		assert.Equal(t, "image/png", rec.Header().Get("Content-Type"), kind)
+	}
+}
+
+func TestHandler_ServeArchive(t *testing.T) {
+	stor := newTestStorage(t, map[string]string{"albums/summer/a.jpg": "aaa", "albums/summer/day 2/b.jpg": "bb", "secret.txt": "no"})
+	m := NewManager()
+	h := NewHandler(m, "/api/downloads")
+	token, err := m.CreateWithTTL(stor, "user-1", []File{
+		{Path: "albums/summer/a.jpg", Size: 3},
+		{Path: "albums/summer/day 2/b.jpg", Size: 2},
+	}, ArchiveTTL)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(ArchiveTTL), token.ExpiresAt, time.Minute)
+	archivePath := h.ArchivePath(token.ID, "summer 2024")
+	assert.Equal(t, "/api/downloads/"+token.ID+"/archive/summer%202024.zip", archivePath)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, strings.TrimPrefix(archivePath, "/api/downloads"), nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/zip", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Header().Get("Content-Disposition"), `filename="summer 2024.zip"`)
+
+	zr, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	require.NoError(t, err)
+	contents := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		require.NoError(t, err)
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		require.NoError(t, err)
+		contents[f.Name] = string(data)
+	}
+	assert.Equal(t, map[string]string{"a.jpg": "aaa", "day 2/b.jpg": "bb"}, contents)
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/"+token.ID+"/archive/summer", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestCommonDir(t *testing.T) {
+	assert.Equal(t, "", commonDir(nil))
+	assert.Equal(t, "albums/", commonDir([]File{{Path: "albums/a.jpg"}}))
+	assert.Equal(t, "albums/", commonDir([]File{{Path: "albums/summer/a.jpg"}, {Path: "albums/winter/b.jpg"}}))
+	assert.Equal(t, "", commonDir([]File{{Path: "albums/a.jpg"}, {Path: "albums2/b.jpg"}}))
+	assert.Equal(t, "", commonDir([]File{{Path: "a.jpg"}, {Path: "albums/b.jpg"}}))
+}
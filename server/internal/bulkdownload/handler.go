@@ -0,0 +1,165 @@
+package bulkdownload
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+)
+
+// Listing formats served at the token root
+const (
+	// FormatURLs lists one URL per line, usable with wget -i or curl
+	FormatURLs = "urls"
+	// FormatAria2 is an aria2c --input-file keeping the folder layout
+	FormatAria2 = "aria2"
+)
+
+const filesSegment = "files"
+
+// Handler serves token listings, files, media and zip archives. It expects
+// the mount prefix to be stripped, i.e. it sees /<token>,
+// /<token>/files/<path>, /<token>/media/<path> and /<token>/archive/<name>.zip.
+type Handler struct {
+	manager *Manager
+	// basePath is the public mount path, used to build absolute URLs
+	basePath string
+}
+
+// NewHandler creates a handler for tokens of m mounted at basePath
+func NewHandler(m *Manager, basePath string) *Handler {
+	return &Handler{manager: m, basePath: "/" + strings.Trim(basePath, "/")}
+}
+
+// Manager returns the token manager backing the handler
+func (h *Handler) Manager() *Manager {
+	return h.manager
+}
+
+// ListPath returns the listing path of a token relative to the server origin
+func (h *Handler) ListPath(tokenID, format string) string {
+	p := h.basePath + "/" + tokenID
+	if format != "" && format != FormatURLs {
+		p += "?format=" + url.QueryEscape(format)
+	}
+	return p
+}
+
+// FilePath returns the download path of a file relative to the server origin
+func (h *Handler) FilePath(tokenID, filePath string) string {
+	return h.basePath + "/" + tokenID + "/" + filesSegment + "/" + escapePath(filePath)
+}
+
+// escapePath escapes each segment of a file path
+func escapePath(filePath string) string {
+	segments := strings.Split(strings.Trim(filePath, "/"), "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	tokenID, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	token, err := h.manager.Get(tokenID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Header().Set("X-Robots-Tag", "noindex")
+
+	if rest == "" {
+		h.serveList(w, r, token)
+		return
+	}
+	if name, ok := strings.CutPrefix(rest, archiveSegment+"/"); ok && strings.HasSuffix(name, ".zip") && !strings.Contains(name, "/") {
+		h.serveArchive(w, r, token, name)
+		return
+	}
+	if filePath, ok := strings.CutPrefix(rest, mediaSegment+"/"); ok {
+		file, ok := token.file(filePath)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		h.serveMedia(w, r, token, file)
+		return
+	}
+	filePath, ok := strings.CutPrefix(rest, filesSegment+"/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	file, ok := token.file(filePath)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	h.serveFile(w, r, token, file)
+}
+
+func (h *Handler) serveList(w http.ResponseWriter, r *http.Request, token *Token) {
+	origin := requestOrigin(r)
+	format := r.URL.Query().Get("format")
+	var b strings.Builder
+	switch format {
+	case "", FormatURLs:
+		for _, f := range token.Files {
+			b.WriteString(origin + h.FilePath(token.ID, f.Path) + "\n")
+		}
+	case FormatAria2:
+		for _, f := range token.Files {
+			b.WriteString(origin + h.FilePath(token.ID, f.Path) + "\n")
+			b.WriteString("  out=" + f.Path + "\n")
+		}
+	default:
+		http.Error(w, fmt.Sprintf("unsupported format %q", format), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = io.WriteString(w, b.String())
+}
+
+// serveFile streams a file with Range support so transfers can be resumed
+// and split into parallel segments
+func (h *Handler) serveFile(w http.ResponseWriter, r *http.Request, token *Token, file File) {
+	name := path.Base(file.Path)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	if file.ContentType != "" {
+		w.Header().Set("Content-Type", file.ContentType)
+	} else if ct := mime.TypeByExtension(path.Ext(name)); ct != "" {
+		w.Header().Set("Content-Type", ct)
+	} else {
+		w.Header().Set("Content-Type", "application/octet-stream")
+	}
+	content := storage.NewLazyReadSeeker(r.Context(), func(ctx context.Context) (io.ReadCloser, error) {
+		return token.storage.Get(ctx, file.Path)
+	}, file.Size)
+	defer content.Close()
+	http.ServeContent(w, r, "", time.Time{}, content)
+}
+
+// requestOrigin returns the scheme and host the client used to reach us
+func requestOrigin(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+	host := r.Host
+	if forwarded := r.Header.Get("X-Forwarded-Host"); forwarded != "" {
+		host = strings.TrimSpace(strings.Split(forwarded, ",")[0])
+	}
+	return scheme + "://" + host
+}
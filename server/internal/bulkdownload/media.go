@@ -0,0 +1,68 @@
+package bulkdownload
+
+import (
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+)
+
+const mediaSegment = "media"
+
+// mediaTypes are the content types of media files cast receivers play,
+// looked up when the system has no MIME type for the extension
+var mediaTypes = map[string]string{
+	".jpg": "image/jpeg", ".jpeg": "image/jpeg", ".png": "image/png",
+	".gif": "image/gif", ".webp": "image/webp", ".bmp": "image/bmp",
+	".mp4": "video/mp4", ".m4v": "video/mp4", ".webm": "video/webm",
+}
+
+// MediaType returns the content type of a file by its extension,
+// application/octet-stream when unknown
+func MediaType(name string) string {
+	ext := strings.ToLower(path.Ext(name))
+	if contentType, ok := mediaTypes[ext]; ok {
+		return contentType
+	}
+	if contentType := mime.TypeByExtension(ext); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+// MediaPath returns the path playing a file of a token inline, relative to
+// the server origin
+func (h *Handler) MediaPath(tokenID, filePath string) string {
+	return h.basePath + "/" + tokenID + "/" + mediaSegment + "/" + escapePath(filePath)
+}
+
+// serveMedia streams a file inline for players and cast receivers such as
+// Chromecast and DLNA renderers, with Range support for seeking
+func (h *Handler) serveMedia(w http.ResponseWriter, r *http.Request, token *Token, file File) {
+	contentType := file.ContentType
+	if contentType == "" {
+		contentType = MediaType(file.Path)
+	}
+	header := w.Header()
+	header.Set("Content-Type", contentType)
+	// Cast receivers load media from their own origin
+	header.Set("Access-Control-Allow-Origin", "*")
+	header.Set("Access-Control-Expose-Headers", "Accept-Ranges, Content-Length, Content-Range")
+	// DLNA renderers match these headers case-sensitively
+	transferMode := "Streaming"
+	if strings.HasPrefix(contentType, "image/") {
+		transferMode = "Interactive"
+	}
+	header["transferMode.dlna.org"] = []string{transferMode}
+	header["contentFeatures.dlna.org"] = []string{"DLNA.ORG_OP=01;DLNA.ORG_CI=0"}
+	content := storage.NewLazyReadSeeker(r.Context(), func(ctx context.Context) (io.ReadCloser, error) {
+		return token.storage.Get(ctx, file.Path)
+	}, file.Size)
+	defer content.Close()
+	http.ServeContent(w, r, "", time.Time{}, content)
+}
@@ -0,0 +1,393 @@
+// Package chunkupload assembles large uploads sent in fixed-size chunks, so
+// a failed request only resends one chunk and an interrupted upload resumes
+// from the chunks received. Chunks are spooled to a local directory until
+// the upload completes; uploads idle for longer than the TTL are removed.
+package chunkupload
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultChunkSize is the size of every chunk but the last, small enough
+	// to be sent within the server read timeout on slow connections
+	DefaultChunkSize = 4 << 20
+	// DefaultTTL is how long an upload stays resumable without new chunks
+	DefaultTTL = 24 * time.Hour
+	// DefaultMaxUploads bounds the uploads in progress
+	DefaultMaxUploads = 100
+)
+
+var (
+	ErrUploadNotFound = errors.New("upload not found or expired")
+	ErrInvalidSize    = errors.New("upload size must be greater than 0")
+	ErrInvalidChunk   = errors.New("invalid chunk index")
+	ErrChunkSize      = errors.New("chunk size does not match")
+	ErrIncomplete     = errors.New("upload has missing chunks")
+	ErrTooManyUploads = errors.New("too many uploads in progress")
+	ErrCompleting     = errors.New("upload is being completed")
+)
+
+// Upload is an upload in progress
+type Upload struct {
+	ID          string
+	OwnerID     string
+	Path        string
+	SpaceID     string
+	ContentType string
+	Size        int64
+	ChunkSize   int64
+	UpdatedAt   time.Time
+
+	received  map[int]bool
+	completed bool
+}
+
+// ChunkCount returns the number of chunks making up the upload
+func (u *Upload) ChunkCount() int {
+	return int((u.Size + u.ChunkSize - 1) / u.ChunkSize)
+}
+
+// chunkLength returns the expected size of the chunk at index
+func (u *Upload) chunkLength(index int) int64 {
+	if index == u.ChunkCount()-1 {
+		return u.Size - int64(index)*u.ChunkSize
+	}
+	return u.ChunkSize
+}
+
+// Manager tracks the uploads in progress. Upload state is kept in memory, an
+// upload does not survive a restart.
+type Manager struct {
+	dir        string
+	ttl        time.Duration
+	chunkSize  int64
+	maxUploads int
+	now        func() time.Time
+
+	mu      sync.Mutex
+	uploads map[string]*Upload
+}
+
+// Option configures a Manager
+type Option func(*Manager)
+
+// WithTTL sets how long an upload stays resumable without new chunks
+func WithTTL(d time.Duration) Option {
+	return func(m *Manager) {
+		if d > 0 {
+			m.ttl = d
+		}
+	}
+}
+
+// WithChunkSize sets the chunk size of new uploads
+func WithChunkSize(n int64) Option {
+	return func(m *Manager) {
+		if n > 0 {
+			m.chunkSize = n
+		}
+	}
+}
+
+// WithMaxUploads limits the uploads in progress
+func WithMaxUploads(n int) Option {
+	return func(m *Manager) {
+		if n > 0 {
+			m.maxUploads = n
+		}
+	}
+}
+
+// NewManager creates a manager spooling chunks below dir. Uploads left over
+// by a previous run are removed.
+func NewManager(dir string, opts ...Option) (*Manager, error) {
+	m := &Manager{
+		dir:        dir,
+		ttl:        DefaultTTL,
+		chunkSize:  DefaultChunkSize,
+		maxUploads: DefaultMaxUploads,
+		now:        time.Now,
+		uploads:    make(map[string]*Upload),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("create upload directory: %w", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read upload directory: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() && isUploadID(entry.Name()) {
+			_ = os.RemoveAll(filepath.Join(dir, entry.Name()))
+		}
+	}
+	return m, nil
+}
+
+// TTL returns how long an upload stays resumable without new chunks
+func (m *Manager) TTL() time.Duration {
+	return m.ttl
+}
+
+// Start begins an upload of size bytes to path
+func (m *Manager) Start(ownerID, path, spaceID, contentType string, size int64) (*Upload, error) {
+	if size <= 0 {
+		return nil, ErrInvalidSize
+	}
+	id, err := newUploadID()
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	m.sweepLocked(now)
+	if len(m.uploads) >= m.maxUploads {
+		return nil, ErrTooManyUploads
+	}
+	if err := os.Mkdir(filepath.Join(m.dir, id), 0700); err != nil {
+		return nil, fmt.Errorf("create upload directory: %w", err)
+	}
+	upload := &Upload{
+		ID:          id,
+		OwnerID:     ownerID,
+		Path:        path,
+		SpaceID:     spaceID,
+		ContentType: contentType,
+		Size:        size,
+		ChunkSize:   m.chunkSize,
+		UpdatedAt:   now,
+		received:    make(map[int]bool),
+	}
+	m.uploads[id] = upload
+	return upload.snapshot(), nil
+}
+
+// Get returns an upload in progress
+func (m *Manager) Get(id string) (*Upload, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	upload, err := m.getLocked(id)
+	if err != nil {
+		return nil, err
+	}
+	return upload.snapshot(), nil
+}
+
+// Received returns the indexes of the chunks received so far, in order
+func (u *Upload) Received() []int {
+	indexes := make([]int, 0, len(u.received))
+	for index := range u.received {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+// WriteChunk stores the chunk at index read from r, replacing the chunk
+// when it was received before. The chunk must have the exact expected size.
+func (m *Manager) WriteChunk(id string, index int, r io.Reader) (*Upload, error) {
+	m.mu.Lock()
+	upload, err := m.getLocked(id)
+	if err == nil && upload.completed {
+		err = ErrCompleting
+	}
+	if err != nil {
+		m.mu.Unlock()
+		return nil, err
+	}
+	if index < 0 || index >= upload.ChunkCount() {
+		m.mu.Unlock()
+		return nil, ErrInvalidChunk
+	}
+	expected := upload.chunkLength(index)
+	upload.UpdatedAt = m.now()
+	m.mu.Unlock()
+
+	// Spool outside the lock, the chunk becomes visible once renamed in place
+	tmp, err := os.CreateTemp(filepath.Join(m.dir, id), "chunk-*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("spool chunk: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	n, err := io.Copy(tmp, io.LimitReader(r, expected+1))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("spool chunk: %w", err)
+	}
+	if n != expected {
+		return nil, fmt.Errorf("%w: got %d bytes, expected %d", ErrChunkSize, n, expected)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	upload, err = m.getLocked(id)
+	if err == nil && upload.completed {
+		err = ErrCompleting
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), m.chunkPath(id, index)); err != nil {
+		return nil, fmt.Errorf("spool chunk: %w", err)
+	}
+	upload.received[index] = true
+	upload.UpdatedAt = m.now()
+	return upload.snapshot(), nil
+}
+
+// Open marks a fully received upload as completed and returns a reader of
+// its content in chunk order. The upload must be removed once consumed.
+func (m *Manager) Open(id string) (*Upload, io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	upload, err := m.getLocked(id)
+	if err == nil && upload.completed {
+		err = ErrCompleting
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(upload.received) != upload.ChunkCount() {
+		return nil, nil, ErrIncomplete
+	}
+	upload.completed = true
+	upload.UpdatedAt = m.now()
+	return upload.snapshot(), &chunkReader{m: m, id: id, count: upload.ChunkCount()}, nil
+}
+
+// Reopen makes a completed upload writable again, after assembling it
+// failed, so the client may retry
+func (m *Manager) Reopen(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if upload, ok := m.uploads[id]; ok {
+		upload.completed = false
+	}
+}
+
+// Remove discards an upload and its chunks
+func (m *Manager) Remove(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.removeLocked(id)
+}
+
+// Sweep removes the uploads idle for longer than the TTL. Called by the
+// server sync loop.
+func (m *Manager) Sweep() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweepLocked(m.now())
+	return nil
+}
+
+func (m *Manager) sweepLocked(now time.Time) {
+	for id, upload := range m.uploads {
+		if now.Sub(upload.UpdatedAt) >= m.ttl {
+			m.removeLocked(id)
+		}
+	}
+}
+
+func (m *Manager) getLocked(id string) (*Upload, error) {
+	upload, ok := m.uploads[id]
+	if !ok {
+		return nil, ErrUploadNotFound
+	}
+	if m.now().Sub(upload.UpdatedAt) >= m.ttl {
+		m.removeLocked(id)
+		return nil, ErrUploadNotFound
+	}
+	return upload, nil
+}
+
+func (m *Manager) removeLocked(id string) {
+	delete(m.uploads, id)
+	_ = os.RemoveAll(filepath.Join(m.dir, id))
+}
+
+func (m *Manager) chunkPath(id string, index int) string {
+	return filepath.Join(m.dir, id, "chunk-"+strconv.Itoa(index))
+}
+
+// snapshot copies the upload so callers can read it without the lock
+func (u *Upload) snapshot() *Upload {
+	c := *u
+	c.received = make(map[int]bool, len(u.received))
+	for index := range u.received {
+		c.received[index] = true
+	}
+	return &c
+}
+
+// chunkReader reads the chunks of an upload in order, one file at a time
+type chunkReader struct {
+	m     *Manager
+	id    string
+	count int
+	next  int
+	file  *os.File
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if r.file == nil {
+			if r.next >= r.count {
+				return 0, io.EOF
+			}
+			file, err := os.Open(r.m.chunkPath(r.id, r.next))
+			if err != nil {
+				return 0, err
+			}
+			r.file = file
+			r.next++
+		}
+		n, err := r.file.Read(p)
+		if err == io.EOF {
+			_ = r.file.Close()
+			r.file = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (r *chunkReader) Close() error {
+	if r.file != nil {
+		return r.file.Close()
+	}
+	return nil
+}
+
+func isUploadID(name string) bool {
+	b, err := hex.DecodeString(name)
+	return err == nil && len(b) == 16
+}
+
+func newUploadID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,107 @@
+package chunkupload
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManager_ChunksAssembleInOrder(t *testing.T) {
+	m, err := NewManager(t.TempDir(), WithChunkSize(4))
+	require.NoError(t, err)
+
+	upload, err := m.Start("user-1", "videos/clip.mp4", "", "video/mp4", 10)
+	require.NoError(t, err)
+	assert.Len(t, upload.ID, 32)
+	assert.Equal(t, 3, upload.ChunkCount())
+
+	// Chunks may arrive out of order and be resent
+	_, err = m.WriteChunk(upload.ID, 2, strings.NewReader("89"))
+	require.NoError(t, err)
+	_, err = m.WriteChunk(upload.ID, 0, strings.NewReader("xxxx"))
+	require.NoError(t, err)
+	_, _, err = m.Open(upload.ID)
+	assert.ErrorIs(t, err, ErrIncomplete)
+	_, err = m.WriteChunk(upload.ID, 0, strings.NewReader("0123"))
+	require.NoError(t, err)
+	upload, err = m.WriteChunk(upload.ID, 1, strings.NewReader("4567"))
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 2}, upload.Received())
+
+	upload, reader, err := m.Open(upload.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "videos/clip.mp4", upload.Path)
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+	assert.Equal(t, "0123456789", string(content))
+
+	// No more chunks while completing
+	_, err = m.WriteChunk(upload.ID, 0, strings.NewReader("0123"))
+	assert.ErrorIs(t, err, ErrCompleting)
+
+	m.Remove(upload.ID)
+	_, err = m.Get(upload.ID)
+	assert.ErrorIs(t, err, ErrUploadNotFound)
+	assert.NoDirExists(t, filepath.Join(m.dir, upload.ID))
+}
+
+func TestManager_RejectsInvalidChunks(t *testing.T) {
+	m, err := NewManager(t.TempDir(), WithChunkSize(4), WithMaxUploads(1))
+	require.NoError(t, err)
+
+	_, err = m.Start("user-1", "a.mp4", "", "", 0)
+	assert.ErrorIs(t, err, ErrInvalidSize)
+	upload, err := m.Start("user-1", "a.mp4", "", "", 6)
+	require.NoError(t, err)
+	_, err = m.Start("user-1", "b.mp4", "", "", 6)
+	assert.ErrorIs(t, err, ErrTooManyUploads)
+
+	_, err = m.WriteChunk(upload.ID, 2, strings.NewReader("01"))
+	assert.ErrorIs(t, err, ErrInvalidChunk)
+	_, err = m.WriteChunk(upload.ID, 0, strings.NewReader("012"))
+	assert.ErrorIs(t, err, ErrChunkSize)
+	_, err = m.WriteChunk(upload.ID, 1, strings.NewReader("012"))
+	assert.ErrorIs(t, err, ErrChunkSize)
+	_, err = m.WriteChunk("unknown", 0, strings.NewReader("0123"))
+	assert.ErrorIs(t, err, ErrUploadNotFound)
+
+	upload, err = m.Get(upload.ID)
+	require.NoError(t, err)
+	assert.Empty(t, upload.Received())
+}
+
+func TestManager_SweepsIdleUploads(t *testing.T) {
+	dir := t.TempDir()
+	leftover := filepath.Join(dir, "0123456789abcdef0123456789abcdef")
+	require.NoError(t, os.MkdirAll(leftover, 0700))
+	unrelated := filepath.Join(dir, "keep")
+	require.NoError(t, os.MkdirAll(unrelated, 0700))
+
+	m, err := NewManager(dir, WithTTL(time.Hour))
+	require.NoError(t, err)
+	assert.NoDirExists(t, leftover)
+	assert.DirExists(t, unrelated)
+
+	now := time.Now()
+	m.now = func() time.Time { return now }
+	idle, err := m.Start("user-1", "a.mp4", "", "", 10)
+	require.NoError(t, err)
+	now = now.Add(30 * time.Minute)
+	active, err := m.Start("user-1", "b.mp4", "", "", 10)
+	require.NoError(t, err)
+
+	now = now.Add(45 * time.Minute)
+	require.NoError(t, m.Sweep())
+	_, err = m.Get(idle.ID)
+	assert.ErrorIs(t, err, ErrUploadNotFound)
+	assert.NoDirExists(t, filepath.Join(dir, idle.ID))
+	_, err = m.Get(active.ID)
+	assert.NoError(t, err)
+}
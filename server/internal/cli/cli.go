@@ -0,0 +1,369 @@
+// Package cli implements the operator subcommands of imagor-studio, run
+// against the database and storage of a self-hosted server without the web
+// UI, e.g. to recover a locked-out admin or script configuration:
+//
+//	imagor-studio user reset-password admin
+//	imagor-studio registry set config.app_title "Photos" --database-url=...
+//
+// Positional arguments come first, then the flags of the subcommand. Other
+// flags configure the server as for the serve command.
+package cli
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/internal/userstore"
+	"github.com/cshum/imagor-studio/server/pkg/auth"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/cshum/imagor-studio/server/pkg/uuid"
+	"github.com/cshum/imagor-studio/server/pkg/validation"
+)
+
+// ErrUsage is returned for invalid invocations, wrapped with the usage
+var ErrUsage = errors.New("usage")
+
+// Env holds what subcommands run against
+type Env struct {
+	Users    userstore.Store
+	Registry registrystore.Store
+	Storage  storage.Storage
+	Out      io.Writer
+}
+
+// command is a subcommand like user create
+type command struct {
+	// args describes the positional arguments and flags for the usage
+	args string
+	// minArgs and maxArgs bound the positional arguments
+	minArgs, maxArgs int
+	// flags are the flag names accepted, true for flags without value
+	flags map[string]bool
+	run   func(ctx context.Context, env Env, args []string, flags map[string]string) error
+}
+
+var commands = map[string]map[string]command{
+	"user": {
+		"list": {
+			args: "[--search <text>]", maxArgs: 0,
+			flags: map[string]bool{"search": false},
+			run:   listUsers,
+		},
+		"create": {
+			args: "<username> [--password <password>] [--display-name <name>] [--role user|admin]", minArgs: 1, maxArgs: 1,
+			flags: map[string]bool{"password": false, "display-name": false, "role": false},
+			run:   createUser,
+		},
+		"reset-password": {
+			args: "<username> [--password <password>]", minArgs: 1, maxArgs: 1,
+			flags: map[string]bool{"password": false},
+			run:   resetPassword,
+		},
+	},
+	"registry": {
+		"list": {args: "[prefix]", maxArgs: 1, run: listRegistry},
+		"get":  {args: "<key>", minArgs: 1, maxArgs: 1, run: getRegistry},
+		"set": {
+			args: "<key> <value> [--encrypted]", minArgs: 2, maxArgs: 2,
+			flags: map[string]bool{"encrypted": true},
+			run:   setRegistry,
+		},
+		"delete": {args: "<key>", minArgs: 1, maxArgs: 1, run: deleteRegistry},
+	},
+	"storage": {
+		"test": {run: testStorage},
+	},
+}
+
+// IsCommand reports whether name is a subcommand group such as user
+func IsCommand(name string) bool {
+	_, ok := commands[name]
+	return ok
+}
+
+// Invocation is a parsed subcommand
+type Invocation struct {
+	group, name string
+	cmd         command
+	args        []string
+	flags       map[string]string
+}
+
+// Parse parses a subcommand invocation, returning it with the remaining
+// arguments, which configure the server
+func Parse(args []string) (*Invocation, []string, error) {
+	if len(args) == 0 || !IsCommand(args[0]) {
+		return nil, nil, usageError("")
+	}
+	group := args[0]
+	if len(args) < 2 {
+		return nil, nil, usageError(group)
+	}
+	cmd, ok := commands[group][args[1]]
+	if !ok {
+		return nil, nil, usageError(group)
+	}
+	inv := &Invocation{group: group, name: args[1], cmd: cmd, flags: make(map[string]string)}
+	rest := args[2:]
+	for len(rest) > 0 && !strings.HasPrefix(rest[0], "-") {
+		inv.args = append(inv.args, rest[0])
+		rest = rest[1:]
+	}
+	var configArgs []string
+	for i := 0; i < len(rest); i++ {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(rest[i], "-"), "=")
+		noValue, ok := cmd.flags[name]
+		if !ok {
+			configArgs = append(configArgs, rest[i])
+			continue
+		}
+		switch {
+		case noValue && hasValue:
+			return nil, nil, fmt.Errorf("--%s takes no value", name)
+		case noValue:
+			value = "true"
+		case !hasValue:
+			if i+1 >= len(rest) {
+				return nil, nil, fmt.Errorf("--%s requires a value", name)
+			}
+			i++
+			value = rest[i]
+		}
+		inv.flags[name] = value
+	}
+	if len(inv.args) < cmd.minArgs || len(inv.args) > cmd.maxArgs {
+		return nil, nil, fmt.Errorf("%w: imagor-studio %s %s %s", ErrUsage, group, inv.name, cmd.args)
+	}
+	return inv, configArgs, nil
+}
+
+// Run runs the subcommand
+func (inv *Invocation) Run(ctx context.Context, env Env) error {
+	return inv.cmd.run(ctx, env, inv.args, inv.flags)
+}
+
+// usageError lists the subcommands of group, or every subcommand
+func usageError(group string) error {
+	var groups []string
+	if group != "" {
+		groups = []string{group}
+	} else {
+		for name := range commands {
+			groups = append(groups, name)
+		}
+		sort.Strings(groups)
+	}
+	var b strings.Builder
+	for _, g := range groups {
+		var names []string
+		for name := range commands[g] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			b.WriteString(strings.TrimRight(fmt.Sprintf("\n  imagor-studio %s %s %s", g, name, commands[g][name].args), " "))
+		}
+	}
+	return fmt.Errorf("%w:%s", ErrUsage, b.String())
+}
+
+func listUsers(ctx context.Context, env Env, _ []string, flags map[string]string) error {
+	users, _, err := env.Users.List(ctx, 0, 0, flags["search"])
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(env.Out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "USERNAME\tROLE\tACTIVE\tDISPLAY NAME\tID")
+	for _, user := range users {
+		fmt.Fprintf(w, "%s\t%s\t%t\t%s\t%s\n", user.Username, user.Role, user.IsActive, user.DisplayName, user.ID)
+	}
+	return w.Flush()
+}
+
+func createUser(ctx context.Context, env Env, args []string, flags map[string]string) error {
+	username := validation.NormalizeUsername(args[0])
+	if err := validation.ValidateUsername(username); err != nil {
+		return err
+	}
+	displayName := validation.NormalizeDisplayName(flags["display-name"])
+	if displayName == "" {
+		displayName = username
+	}
+	if err := validation.ValidateDisplayName(displayName); err != nil {
+		return err
+	}
+	role := flags["role"]
+	if role == "" {
+		role = "user"
+	}
+	if role != "user" && role != "admin" {
+		return fmt.Errorf("invalid role: %s (valid roles: user, admin)", role)
+	}
+	password, generated, err := passwordFlag(flags)
+	if err != nil {
+		return err
+	}
+	hashedPassword, err := auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	user, err := env.Users.Create(ctx, displayName, username, hashedPassword, role)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(env.Out, "Created %s %s (%s)\n", user.Role, user.Username, user.ID)
+	if generated {
+		fmt.Fprintf(env.Out, "Password: %s\n", password)
+	}
+	return nil
+}
+
+// resetPassword sets the password of a user and reactivates the account,
+// so a deactivated admin can sign in again
+func resetPassword(ctx context.Context, env Env, args []string, flags map[string]string) error {
+	username := validation.NormalizeUsername(args[0])
+	users, _, err := env.Users.List(ctx, 0, 0, username)
+	if err != nil {
+		return err
+	}
+	var user *userstore.User
+	for _, u := range users {
+		if strings.EqualFold(u.Username, username) {
+			user = u
+		}
+	}
+	if user == nil {
+		return fmt.Errorf("user not found: %s", username)
+	}
+	password, generated, err := passwordFlag(flags)
+	if err != nil {
+		return err
+	}
+	hashedPassword, err := auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	if err := env.Users.UpdatePassword(ctx, user.ID, hashedPassword); err != nil {
+		return err
+	}
+	if !user.IsActive {
+		if err := env.Users.SetActive(ctx, user.ID, true); err != nil {
+			return err
+		}
+		fmt.Fprintf(env.Out, "Reactivated %s\n", user.Username)
+	}
+	fmt.Fprintf(env.Out, "Reset the password of %s\n", user.Username)
+	if generated {
+		fmt.Fprintf(env.Out, "Password: %s\n", password)
+	}
+	return nil
+}
+
+// passwordFlag returns the --password flag, or a generated password when
+// it is not set
+func passwordFlag(flags map[string]string) (string, bool, error) {
+	if password, ok := flags["password"]; ok {
+		return password, false, validation.ValidatePassword(password)
+	}
+	buf := make([]byte, 18)
+	if _, err := rand.Read(buf); err != nil {
+		return "", false, err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), true, nil
+}
+
+// listRegistry prints the system registry, masking encrypted values
+func listRegistry(ctx context.Context, env Env, args []string, _ map[string]string) error {
+	var prefix *string
+	if len(args) > 0 {
+		prefix = &args[0]
+	}
+	entries, err := env.Registry.List(ctx, registrystore.SystemOwnerID, prefix)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(env.Out, 0, 0, 2, ' ', 0)
+	for _, entry := range entries {
+		value := entry.Value
+		if entry.IsEncrypted {
+			value = "(encrypted)"
+		}
+		fmt.Fprintf(w, "%s\t%s\n", entry.Key, value)
+	}
+	return w.Flush()
+}
+
+func getRegistry(ctx context.Context, env Env, args []string, _ map[string]string) error {
+	entry, err := env.Registry.Get(ctx, registrystore.SystemOwnerID, args[0])
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		return fmt.Errorf("registry key not found: %s", args[0])
+	}
+	fmt.Fprintln(env.Out, entry.Value)
+	return nil
+}
+
+func setRegistry(ctx context.Context, env Env, args []string, flags map[string]string) error {
+	if _, err := env.Registry.Set(ctx, registrystore.SystemOwnerID, args[0], args[1], flags["encrypted"] == "true"); err != nil {
+		return err
+	}
+	fmt.Fprintf(env.Out, "Set %s, restart running servers to apply it\n", args[0])
+	return nil
+}
+
+func deleteRegistry(ctx context.Context, env Env, args []string, _ map[string]string) error {
+	if err := env.Registry.Delete(ctx, registrystore.SystemOwnerID, args[0]); err != nil {
+		return err
+	}
+	fmt.Fprintf(env.Out, "Deleted %s\n", args[0])
+	return nil
+}
+
+// testStorage checks the configured storage can be written, read, listed
+// and deleted from, using a probe file it removes again
+func testStorage(ctx context.Context, env Env, _ []string, _ map[string]string) error {
+	probe := path.Join("__imagor_probe__", uuid.GenerateUUID()+".txt")
+	content := []byte("ok")
+	if err := env.Storage.Put(ctx, probe, bytes.NewReader(content)); err != nil {
+		return fmt.Errorf("failed to write probe file: %w", err)
+	}
+	defer func() { _ = env.Storage.Delete(ctx, probe) }()
+	fmt.Fprintln(env.Out, "write: ok")
+
+	reader, err := env.Storage.Get(ctx, probe)
+	if err != nil {
+		return fmt.Errorf("failed to read probe file: %w", err)
+	}
+	body, err := io.ReadAll(reader)
+	_ = reader.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read probe file: %w", err)
+	}
+	if !bytes.Equal(body, content) {
+		return fmt.Errorf("probe file content mismatch")
+	}
+	fmt.Fprintln(env.Out, "read: ok")
+
+	if _, err := env.Storage.List(ctx, "", storage.ListOptions{Limit: 1}); err != nil {
+		return fmt.Errorf("failed to list storage: %w", err)
+	}
+	fmt.Fprintln(env.Out, "list: ok")
+
+	if err := env.Storage.Delete(ctx, probe); err != nil {
+		return fmt.Errorf("failed to delete probe file: %w", err)
+	}
+	fmt.Fprintln(env.Out, "delete: ok")
+	return nil
+}
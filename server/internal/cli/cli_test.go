@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/cshum/imagor-studio/server/internal/userstore"
+	"github.com/cshum/imagor-studio/server/pkg/auth"
+	"github.com/cshum/imagor-studio/server/pkg/encryption"
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func setupTestEnv(t *testing.T) (Env, *bytes.Buffer) {
+	t.Helper()
+	db := testutil.NewDB(t)
+
+	stor, err := filestorage.New(t.TempDir())
+	require.NoError(t, err)
+	out := &bytes.Buffer{}
+	return Env{
+		Users:    userstore.New(db, zap.NewNop()),
+		Registry: registrystore.New(db, zap.NewNop(), encryption.NewServiceWithJwtLey(":memory:", "test-secret")),
+		Storage:  stor,
+		Out:      out,
+	}, out
+}
+
+func run(t *testing.T, env Env, args ...string) error {
+	t.Helper()
+	inv, configArgs, err := Parse(args)
+	require.NoError(t, err)
+	assert.Empty(t, configArgs)
+	return inv.Run(context.Background(), env)
+}
+
+func TestParse(t *testing.T) {
+	inv, configArgs, err := Parse([]string{"user", "create", "alice", "--role", "admin", "--database-url=sqlite:./a.db", "--password=secret123", "--debug"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alice"}, inv.args)
+	assert.Equal(t, map[string]string{"role": "admin", "password": "secret123"}, inv.flags)
+	assert.Equal(t, []string{"--database-url=sqlite:./a.db", "--debug"}, configArgs)
+
+	inv, _, err = Parse([]string{"registry", "set", "config.app_title", "Photos", "--encrypted"})
+	require.NoError(t, err)
+	assert.Equal(t, "true", inv.flags["encrypted"])
+
+	for _, args := range [][]string{
+		{"user"},
+		{"user", "remove", "alice"},
+		{"user", "create"},
+		{"registry", "get", "a", "b"},
+	} {
+		_, _, err := Parse(args)
+		assert.ErrorIs(t, err, ErrUsage, args)
+	}
+	_, _, err = Parse([]string{"user", "create", "alice", "--role"})
+	assert.ErrorContains(t, err, "--role requires a value")
+	_, _, err = Parse([]string{"registry", "set", "a", "b", "--encrypted=false"})
+	assert.ErrorContains(t, err, "--encrypted takes no value")
+}
+
+func TestUserCommands(t *testing.T) {
+	env, out := setupTestEnv(t)
+	ctx := context.Background()
+
+	require.NoError(t, run(t, env, "user", "create", "Alice", "--role", "admin"))
+	assert.Contains(t, out.String(), "Created admin alice")
+	assert.Contains(t, out.String(), "Password: ")
+	assert.Error(t, run(t, env, "user", "create", "bob", "--role", "owner"))
+	assert.Error(t, run(t, env, "user", "create", "bob", "--password", "short"))
+
+	user, err := env.Users.GetByUsername(ctx, "alice")
+	require.NoError(t, err)
+	require.NoError(t, env.Users.SetActive(ctx, user.ID, false))
+
+	out.Reset()
+	require.NoError(t, run(t, env, "user", "reset-password", "ALICE", "--password", "new-password"))
+	assert.Contains(t, out.String(), "Reactivated alice")
+	assert.NotContains(t, out.String(), "Password: ")
+	user, err = env.Users.GetByUsername(ctx, "alice")
+	require.NoError(t, err)
+	assert.NoError(t, auth.CheckPassword(user.HashedPassword, "new-password"))
+
+	assert.ErrorContains(t, run(t, env, "user", "reset-password", "nobody"), "user not found")
+
+	out.Reset()
+	require.NoError(t, run(t, env, "user", "list"))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[1], "alice")
+}
+
+func TestRegistryCommands(t *testing.T) {
+	env, out := setupTestEnv(t)
+
+	require.NoError(t, run(t, env, "registry", "set", "config.app_title", "Photos"))
+	require.NoError(t, run(t, env, "registry", "set", "config.aws_secret_access_key", "s3cret", "--encrypted"))
+
+	out.Reset()
+	require.NoError(t, run(t, env, "registry", "get", "config.aws_secret_access_key"))
+	assert.Equal(t, "s3cret\n", out.String())
+
+	out.Reset()
+	require.NoError(t, run(t, env, "registry", "list", "config."))
+	assert.Contains(t, out.String(), "Photos")
+	assert.Contains(t, out.String(), "(encrypted)")
+	assert.NotContains(t, out.String(), "s3cret")
+
+	require.NoError(t, run(t, env, "registry", "delete", "config.app_title"))
+	assert.ErrorContains(t, run(t, env, "registry", "get", "config.app_title"), "not found")
+}
+
+func TestStorageTest(t *testing.T) {
+	env, out := setupTestEnv(t)
+
+	require.NoError(t, run(t, env, "storage", "test"))
+	assert.Equal(t, "write: ok\nread: ok\nlist: ok\ndelete: ok\n", out.String())
+}
@@ -0,0 +1,113 @@
+// Package clientip resolves the address of the client of a request behind
+// trusted reverse proxies. Forwarded headers are only trusted from the
+// configured proxies, without any the client is the remote address of the
+// request.
+package clientip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// Proxies are the networks of the trusted reverse proxies
+type Proxies struct {
+	prefixes []netip.Prefix
+}
+
+// Parse parses a comma-separated list of IP addresses and CIDR networks.
+// It returns nil for an empty list, trusting no proxy.
+func Parse(value string) (*Proxies, error) {
+	var prefixes []netip.Prefix
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if strings.Contains(entry, "/") {
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid network %q: %w", entry, err)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %q: %w", entry, err)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	if len(prefixes) == 0 {
+		return nil, nil
+	}
+	return &Proxies{prefixes: prefixes}, nil
+}
+
+// trusted reports whether ip is the address of a trusted proxy
+func (p *Proxies) trusted(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range p.prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the address of the client of r. When the request comes
+// from a trusted proxy, X-Forwarded-For is read from the right and the
+// first address that is not a trusted proxy is the client, so addresses
+// prepended by the client itself are ignored.
+func (p *Proxies) ClientIP(r *http.Request) string {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	if p == nil || !p.trusted(ip) {
+		return ip
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		if _, err := netip.ParseAddr(hop); err != nil {
+			// A malformed hop cannot be trusted further, the nearest
+			// valid address stands for the client
+			return ip
+		}
+		ip = hop
+		if !p.trusted(hop) {
+			return ip
+		}
+	}
+	return ip
+}
+
+// Middleware sets the remote address of requests from trusted proxies to
+// the address of their client, so every handler reading RemoteAddr sees the
+// client. A nil Proxies changes nothing.
+func (p *Proxies) Middleware(next http.Handler) http.Handler {
+	if p == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := p.ClientIP(r)
+		_, port, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			port = "0"
+		}
+		r = r.WithContext(r.Context())
+		r.RemoteAddr = net.JoinHostPort(ip, port)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,72 @@
+package clientip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRequest(remoteAddr string, forwardedFor ...string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/imagor/x", nil)
+	r.RemoteAddr = remoteAddr
+	for _, value := range forwardedFor {
+		r.Header.Add("X-Forwarded-For", value)
+	}
+	return r
+}
+
+func TestParse(t *testing.T) {
+	p, err := Parse("")
+	require.NoError(t, err)
+	assert.Nil(t, p)
+
+	p, err = Parse("10.0.0.0/8, 192.168.1.1,::1")
+	require.NoError(t, err)
+	assert.True(t, p.trusted("10.1.2.3"))
+	assert.True(t, p.trusted("192.168.1.1"))
+	assert.True(t, p.trusted("::1"))
+	assert.False(t, p.trusted("192.168.1.2"))
+
+	_, err = Parse("10.0.0.0/33")
+	assert.Error(t, err)
+	_, err = Parse("proxy.local")
+	assert.Error(t, err)
+}
+
+func TestClientIP(t *testing.T) {
+	p, err := Parse("10.0.0.0/8")
+	require.NoError(t, err)
+
+	// Forwarded headers of untrusted peers are ignored
+	assert.Equal(t, "203.0.113.7", p.ClientIP(newRequest("203.0.113.7:4000", "198.51.100.1")))
+	var none *Proxies
+	assert.Equal(t, "10.0.0.1", none.ClientIP(newRequest("10.0.0.1:4000", "198.51.100.1")))
+
+	// The first untrusted hop from the right is the client
+	assert.Equal(t, "198.51.100.1", p.ClientIP(newRequest("10.0.0.1:4000", "192.0.2.9, 198.51.100.1, 10.0.0.2")))
+	assert.Equal(t, "198.51.100.1", p.ClientIP(newRequest("10.0.0.1:4000", "192.0.2.9", "198.51.100.1")))
+	// Only proxies in the chain
+	assert.Equal(t, "10.0.0.2", p.ClientIP(newRequest("10.0.0.1:4000", "10.0.0.2")))
+	// A malformed hop stops the walk
+	assert.Equal(t, "10.0.0.2", p.ClientIP(newRequest("10.0.0.1:4000", "198.51.100.1, garbage, 10.0.0.2")))
+	// No header
+	assert.Equal(t, "10.0.0.1", p.ClientIP(newRequest("10.0.0.1:4000")))
+}
+
+func TestMiddleware(t *testing.T) {
+	p, err := Parse("10.0.0.1")
+	require.NoError(t, err)
+	var remoteAddr string
+	handler := p.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remoteAddr = r.RemoteAddr
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), newRequest("10.0.0.1:4000", "2001:db8::1"))
+	assert.Equal(t, "[2001:db8::1]:4000", remoteAddr)
+
+	var none *Proxies
+	next := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	assert.NotNil(t, none.Middleware(next))
+}
@@ -0,0 +1,194 @@
+// Package commentstore persists comments left on files, kept per scope and
+// following files that are moved.
+package commentstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cshum/imagor-studio/server/internal/model"
+	"github.com/cshum/imagor-studio/server/pkg/uuid"
+	"github.com/uptrace/bun"
+	"go.uber.org/zap"
+)
+
+// MaxTextLength is the longest comment in characters
+const MaxTextLength = 10000
+
+var (
+	ErrNotFound = errors.New("comment not found")
+	ErrInvalid  = errors.New("invalid comment")
+)
+
+// Comment is a comment on a file
+type Comment struct {
+	ID         string
+	Path       string
+	AuthorID   string
+	AuthorName string
+	Text       string
+	CreatedAt  time.Time
+	// EditedAt is nil for comments never edited
+	EditedAt *time.Time
+}
+
+type Store interface {
+	// List returns the comments of a file, oldest first
+	List(ctx context.Context, scope, filePath string) ([]*Comment, error)
+	Get(ctx context.Context, scope, id string) (*Comment, error)
+	Add(ctx context.Context, scope, filePath, authorID, authorName, text string) (*Comment, error)
+	// Update replaces the text of a comment, recording the edit time
+	Update(ctx context.Context, scope, id, text string) (*Comment, error)
+	Delete(ctx context.Context, scope, id string) error
+	// MoveFilePath rewrites comments of a file, or of every file below a
+	// folder, after it has been moved
+	MoveFilePath(ctx context.Context, scope, oldPath, newPath string) error
+	// RemoveFilePath drops comments of a file, or of every file below a
+	// folder, after it has been deleted
+	RemoveFilePath(ctx context.Context, scope, path string) error
+}
+
+type store struct {
+	db     *bun.DB
+	logger *zap.Logger
+}
+
+func New(db *bun.DB, logger *zap.Logger) Store {
+	return &store{db: db, logger: logger}
+}
+
+// normalizeText trims a comment, rejecting empty and overlong text
+func normalizeText(text string) (string, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", fmt.Errorf("%w: text must not be empty", ErrInvalid)
+	}
+	if utf8.RuneCountInString(text) > MaxTextLength {
+		return "", fmt.Errorf("%w: text must be at most %d characters", ErrInvalid, MaxTextLength)
+	}
+	return text, nil
+}
+
+func toComment(row *model.Comment) *Comment {
+	return &Comment{
+		ID:         row.ID,
+		Path:       row.FilePath,
+		AuthorID:   row.AuthorID,
+		AuthorName: row.AuthorName,
+		Text:       row.Text,
+		CreatedAt:  row.CreatedAt,
+		EditedAt:   row.EditedAt,
+	}
+}
+
+func (s *store) List(ctx context.Context, scope, filePath string) ([]*Comment, error) {
+	var rows []model.Comment
+	if err := s.db.NewSelect().Model(&rows).
+		Where("scope = ?", scope).
+		Where("file_path = ?", filePath).
+		Order("created_at ASC", "id ASC").
+		Scan(ctx); err != nil {
+		return nil, fmt.Errorf("error listing comments: %w", err)
+	}
+	comments := make([]*Comment, 0, len(rows))
+	for i := range rows {
+		comments = append(comments, toComment(&rows[i]))
+	}
+	return comments, nil
+}
+
+func (s *store) Get(ctx context.Context, scope, id string) (*Comment, error) {
+	var row model.Comment
+	err := s.db.NewSelect().Model(&row).
+		Where("scope = ?", scope).
+		Where("id = ?", id).
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error getting comment: %w", err)
+	}
+	return toComment(&row), nil
+}
+
+func (s *store) Add(ctx context.Context, scope, filePath, authorID, authorName, text string) (*Comment, error) {
+	text, err := normalizeText(text)
+	if err != nil {
+		return nil, err
+	}
+	row := &model.Comment{
+		ID:         uuid.GenerateUUID(),
+		Scope:      scope,
+		FilePath:   filePath,
+		AuthorID:   authorID,
+		AuthorName: authorName,
+		Text:       text,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if _, err := s.db.NewInsert().Model(row).Exec(ctx); err != nil {
+		return nil, fmt.Errorf("error adding comment: %w", err)
+	}
+	return toComment(row), nil
+}
+
+func (s *store) Update(ctx context.Context, scope, id, text string) (*Comment, error) {
+	text, err := normalizeText(text)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.db.NewUpdate().Model((*model.Comment)(nil)).
+		Set("text = ?", text).
+		Set("edited_at = ?", time.Now().UTC()).
+		Where("scope = ?", scope).
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error updating comment: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, ErrNotFound
+	}
+	return s.Get(ctx, scope, id)
+}
+
+func (s *store) Delete(ctx context.Context, scope, id string) error {
+	res, err := s.db.NewDelete().Model((*model.Comment)(nil)).
+		Where("scope = ?", scope).
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("error deleting comment: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *store) MoveFilePath(ctx context.Context, scope, oldPath, newPath string) error {
+	// Unlike tags, comments never collide, so every row is rewritten in place
+	if _, err := s.db.NewUpdate().Model((*model.Comment)(nil)).
+		Set("file_path = ? || substr(file_path, ?)", newPath, len(oldPath)+1).
+		Where("scope = ?", scope).
+		Where("(file_path = ? OR substr(file_path, 1, ?) = ?)", oldPath, len(oldPath)+1, oldPath+"/").
+		Exec(ctx); err != nil {
+		return fmt.Errorf("error moving comments: %w", err)
+	}
+	return nil
+}
+
+func (s *store) RemoveFilePath(ctx context.Context, scope, path string) error {
+	if _, err := s.db.NewDelete().Model((*model.Comment)(nil)).
+		Where("scope = ?", scope).
+		Where("(file_path = ? OR substr(file_path, 1, ?) = ?)", path, len(path)+1, path+"/").
+		Exec(ctx); err != nil {
+		return fmt.Errorf("error removing comments: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package commentstore
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const scope = "system:global"
+
+func setupTestStore(t *testing.T) Store {
+	t.Helper()
+	db := testutil.NewDB(t)
+
+	return New(db, zap.NewNop())
+}
+
+func commentTexts(t *testing.T, s Store, filePath string) []string {
+	t.Helper()
+	comments, err := s.List(context.Background(), scope, filePath)
+	require.NoError(t, err)
+	texts := make([]string, 0, len(comments))
+	for _, c := range comments {
+		texts = append(texts, c.Text)
+	}
+	return texts
+}
+
+func TestAddUpdateDelete(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	_, err := s.Add(ctx, scope, "a.jpg", "user-1", "Alice", "   ")
+	assert.ErrorIs(t, err, ErrInvalid)
+	_, err = s.Add(ctx, scope, "a.jpg", "user-1", "Alice", strings.Repeat("x", MaxTextLength+1))
+	assert.ErrorIs(t, err, ErrInvalid)
+
+	first, err := s.Add(ctx, scope, "a.jpg", "user-1", "Alice", " Grandma's birthday ")
+	require.NoError(t, err)
+	assert.Equal(t, "Grandma's birthday", first.Text)
+	assert.Nil(t, first.EditedAt)
+	_, err = s.Add(ctx, scope, "a.jpg", "user-2", "Bob", "Taken in 1998")
+	require.NoError(t, err)
+	_, err = s.Add(ctx, scope, "b.jpg", "user-2", "Bob", "Other file")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Grandma's birthday", "Taken in 1998"}, commentTexts(t, s, "a.jpg"))
+
+	edited, err := s.Update(ctx, scope, first.ID, "Grandma's 80th birthday")
+	require.NoError(t, err)
+	assert.Equal(t, "Grandma's 80th birthday", edited.Text)
+	assert.NotNil(t, edited.EditedAt)
+	assert.Equal(t, "Alice", edited.AuthorName)
+
+	_, err = s.Update(ctx, "space:other", first.ID, "nope")
+	assert.ErrorIs(t, err, ErrNotFound)
+	require.NoError(t, s.Delete(ctx, scope, first.ID))
+	assert.ErrorIs(t, s.Delete(ctx, scope, first.ID), ErrNotFound)
+	assert.Equal(t, []string{"Taken in 1998"}, commentTexts(t, s, "a.jpg"))
+}
+
+func TestMoveAndRemoveFilePath(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	for _, p := range []string{"photos/a.jpg", "photos/nested/b.jpg", "photos-2024/c.jpg"} {
+		_, err := s.Add(ctx, scope, p, "user-1", "Alice", "note on "+p)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, s.MoveFilePath(ctx, scope, "photos", "archive/2023"))
+	assert.Empty(t, commentTexts(t, s, "photos/a.jpg"))
+	assert.Equal(t, []string{"note on photos/a.jpg"}, commentTexts(t, s, "archive/2023/a.jpg"))
+	assert.Equal(t, []string{"note on photos/nested/b.jpg"}, commentTexts(t, s, "archive/2023/nested/b.jpg"))
+	assert.Equal(t, []string{"note on photos-2024/c.jpg"}, commentTexts(t, s, "photos-2024/c.jpg"))
+
+	require.NoError(t, s.RemoveFilePath(ctx, scope, "archive"))
+	assert.Empty(t, commentTexts(t, s, "archive/2023/a.jpg"))
+	assert.Len(t, commentTexts(t, s, "photos-2024/c.jpg"), 1)
+}
@@ -0,0 +1,55 @@
+package compression
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Route groups compression is configured for
+const (
+	GroupAPI    = "api"    // JSON responses of /api/, GraphQL included
+	GroupStatic = "static" // embedded frontend
+	GroupImagor = "imagor" // /imagor/ responses, only SVG and metadata compress
+)
+
+// Groups lists the route groups in display order
+var Groups = []string{GroupAPI, GroupStatic, GroupImagor}
+
+// DefaultGroups are compressed unless configured otherwise, images gain
+// next to nothing from it
+const DefaultGroups = GroupAPI + "," + GroupStatic
+
+var ErrUnknownGroup = errors.New("unknown compression route group")
+
+// GroupOf returns the route group of a request path. Imagor style paths
+// served at the root fall in the static group, their content types are
+// not compressible but for SVG.
+func GroupOf(path string) string {
+	switch {
+	case strings.HasPrefix(path, "/imagor/"):
+		return GroupImagor
+	case strings.HasPrefix(path, "/api/"):
+		return GroupAPI
+	default:
+		return GroupStatic
+	}
+}
+
+// ParseGroups parses a comma separated list of route groups, empty or
+// "none" for no compression
+func ParseGroups(s string) (map[string]bool, error) {
+	groups := make(map[string]bool)
+	for _, group := range strings.Split(s, ",") {
+		group = strings.ToLower(strings.TrimSpace(group))
+		if group == "" || group == "none" {
+			continue
+		}
+		if !slices.Contains(Groups, group) {
+			return nil, fmt.Errorf("%w: %s (supported: %s)", ErrUnknownGroup, group, strings.Join(Groups, ", "))
+		}
+		groups[group] = true
+	}
+	return groups, nil
+}
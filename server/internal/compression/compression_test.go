@@ -0,0 +1,164 @@
+package compression
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseGroups(t *testing.T) {
+	groups, err := ParseGroups(DefaultGroups)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{GroupAPI: true, GroupStatic: true}, groups)
+
+	groups, err = ParseGroups(" Imagor , ")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{GroupImagor: true}, groups)
+
+	for _, s := range []string{"", "none"} {
+		groups, err = ParseGroups(s)
+		require.NoError(t, err)
+		assert.Empty(t, groups)
+	}
+
+	_, err = ParseGroups("api,thumbnails")
+	assert.ErrorIs(t, err, ErrUnknownGroup)
+}
+
+func TestGroupOf(t *testing.T) {
+	assert.Equal(t, GroupAPI, GroupOf("/api/query"))
+	assert.Equal(t, GroupAPI, GroupOf("/api/auth/login"))
+	assert.Equal(t, GroupImagor, GroupOf("/imagor/unsafe/photo.jpg"))
+	assert.Equal(t, GroupStatic, GroupOf("/assets/index-abc123.js"))
+	assert.Equal(t, GroupStatic, GroupOf("/"))
+}
+
+func TestAccepts(t *testing.T) {
+	assert.True(t, Accepts("gzip, deflate, br", "gzip"))
+	assert.True(t, Accepts("br;q=1.0, GZIP;q=0.5", "gzip"))
+	assert.True(t, Accepts("*", "br"))
+	assert.False(t, Accepts("gzip;q=0", "gzip"))
+	assert.False(t, Accepts("deflate", "gzip"))
+	assert.False(t, Accepts("", "gzip"))
+}
+
+func serve(t *testing.T, groups map[string]bool, path, acceptEncoding string, handler http.HandlerFunc) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	rr := httptest.NewRecorder()
+	Middleware(groups)(handler).ServeHTTP(rr, req)
+	return rr
+}
+
+func gunzip(t *testing.T, rr *httptest.ResponseRecorder) string {
+	t.Helper()
+	zr, err := gzip.NewReader(rr.Body)
+	require.NoError(t, err)
+	body, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestMiddleware(t *testing.T) {
+	groups := map[string]bool{GroupAPI: true}
+	large := strings.Repeat(`{"name":"photo.jpg"},`, 200)
+	jsonHandler := func(body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("ETag", `"v1"`)
+			_, _ = io.WriteString(w, body[:len(body)/2])
+			_, _ = io.WriteString(w, body[len(body)/2:])
+		}
+	}
+
+	t.Run("compresses large responses", func(t *testing.T) {
+		rr := serve(t, groups, "/api/query", "gzip, br", jsonHandler(large))
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "gzip", rr.Header().Get("Content-Encoding"))
+		assert.Equal(t, "Accept-Encoding", rr.Header().Get("Vary"))
+		assert.Equal(t, `W/"v1"`, rr.Header().Get("ETag"))
+		assert.Less(t, rr.Body.Len(), len(large))
+		assert.Equal(t, large, gunzip(t, rr))
+	})
+
+	t.Run("leaves small responses alone", func(t *testing.T) {
+		rr := serve(t, groups, "/api/query", "gzip", jsonHandler(`{"data":{}}`))
+		assert.Empty(t, rr.Header().Get("Content-Encoding"))
+		assert.Equal(t, "Accept-Encoding", rr.Header().Get("Vary"))
+		assert.Equal(t, `"v1"`, rr.Header().Get("ETag"))
+		assert.Equal(t, `{"data":{}}`, rr.Body.String())
+	})
+
+	t.Run("client without gzip", func(t *testing.T) {
+		rr := serve(t, groups, "/api/query", "", jsonHandler(large))
+		assert.Empty(t, rr.Header().Get("Content-Encoding"))
+		assert.Equal(t, large, rr.Body.String())
+	})
+
+	t.Run("incompressible content types", func(t *testing.T) {
+		rr := serve(t, groups, "/api/query", "gzip", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "image/jpeg")
+			_, _ = io.WriteString(w, large)
+		})
+		assert.Empty(t, rr.Header().Get("Content-Encoding"))
+		assert.Equal(t, large, rr.Body.String())
+	})
+
+	t.Run("sniffs the content type", func(t *testing.T) {
+		rr := serve(t, groups, "/api/query", "gzip", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, "<!DOCTYPE html>"+large)
+		})
+		assert.Equal(t, "text/html; charset=utf-8", rr.Header().Get("Content-Type"))
+		assert.Equal(t, "gzip", rr.Header().Get("Content-Encoding"))
+	})
+
+	t.Run("already encoded and bodiless responses", func(t *testing.T) {
+		rr := serve(t, groups, "/api/query", "gzip", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/javascript")
+			w.Header().Set("Content-Encoding", "br")
+			_, _ = io.WriteString(w, large)
+		})
+		assert.Equal(t, "br", rr.Header().Get("Content-Encoding"))
+		assert.Equal(t, large, rr.Body.String())
+
+		rr = serve(t, groups, "/api/query", "gzip", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotModified)
+		})
+		assert.Equal(t, http.StatusNotModified, rr.Code)
+		assert.Empty(t, rr.Header().Get("Content-Encoding"))
+	})
+
+	t.Run("streams flushed responses", func(t *testing.T) {
+		rr := serve(t, groups, "/api/query", "gzip", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = io.WriteString(w, `{"data":`)
+			w.(http.Flusher).Flush()
+			_, _ = io.WriteString(w, `{}}`)
+		})
+		assert.True(t, rr.Flushed)
+		assert.Equal(t, "gzip", rr.Header().Get("Content-Encoding"))
+		assert.Equal(t, `{"data":{}}`, gunzip(t, rr))
+	})
+
+	t.Run("disabled groups", func(t *testing.T) {
+		var acceptEncoding string
+		rr := serve(t, groups, "/assets/index.js", "gzip, br", func(w http.ResponseWriter, r *http.Request) {
+			acceptEncoding = r.Header.Get("Accept-Encoding")
+			w.Header().Set("Content-Type", "application/javascript")
+			_, _ = io.WriteString(w, large)
+		})
+		assert.Empty(t, acceptEncoding, "handlers do not serve precompressed content either")
+		assert.Empty(t, rr.Header().Get("Content-Encoding"))
+		assert.Empty(t, rr.Header().Get("Vary"))
+		assert.Equal(t, large, rr.Body.String())
+	})
+}
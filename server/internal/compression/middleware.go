@@ -0,0 +1,224 @@
+package compression
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// MinSize is the size below which responses are sent as they are, the
+// gzip framing would eat most of the savings
+const MinSize = 1024
+
+var gzipWriters = sync.Pool{New: func() any {
+	return gzip.NewWriter(io.Discard)
+}}
+
+// Middleware compresses the compressible responses of the enabled route
+// groups with gzip when the client accepts it. Accept-Encoding is removed
+// from the requests of the other groups, so handlers serving precompressed
+// content leave them uncompressed too.
+func Middleware(groups map[string]bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !groups[GroupOf(r.URL.Path)] {
+				if r.Header.Get("Accept-Encoding") != "" {
+					r = r.Clone(r.Context())
+					r.Header.Del("Accept-Encoding")
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+			// WebSocket upgrades are not compressed
+			if r.Header.Get("Upgrade") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Add("Vary", "Accept-Encoding")
+			if r.Method == http.MethodHead || !Accepts(r.Header.Get("Accept-Encoding"), "gzip") {
+				next.ServeHTTP(w, r)
+				return
+			}
+			gw := &gzipResponseWriter{ResponseWriter: w}
+			defer gw.close()
+			next.ServeHTTP(gw, r)
+		})
+	}
+}
+
+// Accepts reports whether an Accept-Encoding header accepts a content
+// coding
+func Accepts(acceptEncoding, coding string) bool {
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != coding && name != "*" {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.ReplaceAll(params, " ", ""), "q="); ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// compressible reports whether responses of a content type gain from
+// compression. Server-sent events are left alone to keep them streaming.
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case mediaType == "text/event-stream":
+		return false
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"),
+		strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/x-ndjson", "application/javascript",
+		"application/xml", "application/wasm":
+		return true
+	}
+	return false
+}
+
+// gzipResponseWriter holds back the first MinSize bytes of the response to
+// decide whether it is worth compressing
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	status  int
+	buf     []byte
+	gz      *gzip.Writer
+	started bool
+}
+
+func (w *gzipResponseWriter) WriteHeader(statusCode int) {
+	if w.started || w.status != 0 {
+		return
+	}
+	if statusCode < http.StatusOK {
+		w.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	w.status = statusCode
+	// Bodiless and partial responses, and those known to be small, are sent
+	// as they are
+	if statusCode == http.StatusNoContent || statusCode == http.StatusNotModified ||
+		statusCode == http.StatusPartialContent {
+		_ = w.start(false)
+		return
+	}
+	if n, err := strconv.Atoi(w.Header().Get("Content-Length")); err == nil && n < MinSize {
+		_ = w.start(false)
+	}
+}
+
+func (w *gzipResponseWriter) Write(data []byte) (int, error) {
+	if w.started {
+		if w.gz != nil {
+			return w.gz.Write(data)
+		}
+		return w.ResponseWriter.Write(data)
+	}
+	w.buf = append(w.buf, data...)
+	if len(w.buf) >= MinSize {
+		if err := w.start(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+// start sends the headers and the held back bytes, compressed when
+// compress is set and the content type is compressible
+func (w *gzipResponseWriter) start(compress bool) error {
+	w.started = true
+	h := w.Header()
+	if h.Get("Content-Type") == "" && len(w.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	if compress && h.Get("Content-Encoding") == "" && compressible(h.Get("Content-Type")) {
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+		h.Del("Accept-Ranges")
+		// The compressed bytes differ, the representation does not
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+		w.gz = gzipWriters.Get().(*gzip.Writer)
+		w.gz.Reset(w.ResponseWriter)
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.gz != nil {
+		_, err = w.gz.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+func (w *gzipResponseWriter) close() {
+	if !w.started {
+		if w.status == 0 && len(w.buf) == 0 {
+			// Nothing was written, let net/http send its implicit response
+			return
+		}
+		_ = w.start(false)
+	}
+	if w.gz != nil {
+		_ = w.gz.Close()
+		w.gz.Reset(io.Discard)
+		gzipWriters.Put(w.gz)
+		w.gz = nil
+	}
+}
+
+// Flush sends what is held back and compressed so far, so streamed
+// responses keep streaming
+func (w *gzipResponseWriter) Flush() {
+	if !w.started {
+		_ = w.start(true)
+	}
+	if w.gz != nil {
+		_ = w.gz.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack lets WebSocket upgrades through the wrapper
+func (w *gzipResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	return h.Hijack()
+}
+
+// Unwrap exposes the wrapped writer to http.ResponseController
+func (w *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
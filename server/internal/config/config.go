@@ -4,13 +4,30 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/cshum/imagor-studio/server/internal/bulkdownload"
+	"github.com/cshum/imagor-studio/server/internal/chunkupload"
+	"github.com/cshum/imagor-studio/server/internal/clientip"
+	"github.com/cshum/imagor-studio/server/internal/compression"
 	"github.com/cshum/imagor-studio/server/internal/database"
+	"github.com/cshum/imagor-studio/server/internal/faces"
+	"github.com/cshum/imagor-studio/server/internal/fswatch"
+	"github.com/cshum/imagor-studio/server/internal/hls"
+	"github.com/cshum/imagor-studio/server/internal/listcache"
+	"github.com/cshum/imagor-studio/server/internal/operation"
 	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/internal/scheduler"
+	"github.com/cshum/imagor-studio/server/internal/secrets"
+	"github.com/cshum/imagor-studio/server/internal/urlimport"
+	"github.com/cshum/imagor-studio/server/internal/videostream"
+	"github.com/cshum/imagor-studio/server/internal/virusscan"
 	"github.com/peterbourgon/ff/v3"
 )
 
@@ -21,7 +38,9 @@ type Config struct {
 	DBMaxIdleConns    int
 	DBConnMaxLifetime time.Duration
 	DBConnMaxIdleTime time.Duration
-	StorageType       string
+	// SQLiteMaintenanceInterval schedules WAL checkpoint, vacuum and integrity checks; 0 disables
+	SQLiteMaintenanceInterval time.Duration
+	StorageType               string
 
 	// JWT Configuration
 	JWTSecret     string
@@ -37,6 +56,10 @@ type Config struct {
 
 	// Embedded Mode Configuration
 	EmbeddedMode bool // Enable embedded mode (stateless, no database)
+	// EmbedTokenSecret authenticates host servers minting viewer tokens of
+	// the embedded editor through /api/embed/token; empty disables it.
+	// Set via --embed-token-secret / EMBED_TOKEN_SECRET env var.
+	EmbedTokenSecret string
 
 	// Migration Configuration
 	ForceAutoMigrate bool   // Force auto-migration even for PostgreSQL/MySQL
@@ -46,6 +69,13 @@ type Config struct {
 	FileStorageBaseDir          string
 	FileStorageMkdirPermissions os.FileMode
 	FileStorageWritePermissions os.FileMode
+	// FileStorageWatch reports changes made directly in the file storage
+	// directory, by rsync or a camera sync app, to fileChanged subscribers.
+	// Set via --file-storage-watch / FILE_STORAGE_WATCH env var.
+	FileStorageWatch bool
+	// FileStorageWatchInterval is the rescan interval used when inotify is
+	// unavailable or runs out of watches.
+	FileStorageWatchInterval time.Duration
 
 	// S3 Storage
 	S3StorageBucket           string
@@ -58,12 +88,34 @@ type Config struct {
 	AWSSessionToken           string
 	S3StorageBaseDir          string
 
+	// SFTP Storage
+	SFTPStorageHost                 string
+	SFTPStoragePort                 int
+	SFTPStorageUser                 string
+	SFTPStoragePrivateKey           string // PEM encoded private key
+	SFTPStoragePrivateKeyPassphrase string
+	SFTPStorageHostKey              string // SHA256 host key fingerprint, unverified when empty
+	SFTPStorageBaseDir              string
+
 	// Imagor Configuration
 	ImagorSecret         string // Imagor secret key
 	ImagorSignerType     string // Signer algorithm: "sha1", "sha256", "sha512"
 	ImagorSignerTruncate int    // Signer truncation length
 	ImagorCacheSizeBytes int64  // imagor in-memory decoded-image cache size in bytes
 
+	// ImagorResultStorage persists generated thumbnails, "file" below
+	// ImagorResultStorageBaseDir or "s3" in ImagorResultStorageBucket under
+	// the ImagorResultStorageBaseDir prefix; empty disables. Results older
+	// than ImagorResultStorageExpiration are regenerated, 0 keeps them.
+	// Set via --imagor-result-storage / IMAGOR_RESULT_STORAGE,
+	// --imagor-result-storage-base-dir / IMAGOR_RESULT_STORAGE_BASE_DIR,
+	// --imagor-result-storage-bucket / IMAGOR_RESULT_STORAGE_BUCKET and
+	// --imagor-result-storage-expiration / IMAGOR_RESULT_STORAGE_EXPIRATION env vars.
+	ImagorResultStorage           string
+	ImagorResultStorageBaseDir    string
+	ImagorResultStorageBucket     string // defaults to s3-storage-bucket
+	ImagorResultStorageExpiration time.Duration
+
 	// Application Configuration
 	AppTitle                  string // Custom application title
 	AppUrl                    string // Frontend application URL (used for post-OAuth redirect to /auth/callback)
@@ -84,9 +136,253 @@ type Config struct {
 	// Set via --app-frame-ancestors / APP_FRAME_ANCESTORS env var.
 	AppFrameAncestors string
 
+	// Built-in TLS termination, with a static certificate or certificates
+	// issued by ACME (Let's Encrypt) for TLSACMEDomains, a comma-separated
+	// list, cached in TLSACMECacheDir. While TLS is on, the port serves
+	// HTTPS and TLSHTTPPort serves HTTP-01 challenges and redirects to
+	// HTTPS, 0 disables that listener.
+	// Set via --tls-cert-file / TLS_CERT_FILE, --tls-key-file / TLS_KEY_FILE,
+	// --tls-acme-domains / TLS_ACME_DOMAINS, --tls-acme-email / TLS_ACME_EMAIL,
+	// --tls-acme-cache-dir / TLS_ACME_CACHE_DIR, --tls-acme-directory-url /
+	// TLS_ACME_DIRECTORY_URL and --tls-http-port / TLS_HTTP_PORT env vars.
+	TLSCertFile         string
+	TLSKeyFile          string
+	TLSACMEDomains      string
+	TLSACMEEmail        string
+	TLSACMECacheDir     string
+	TLSACMEDirectoryURL string // empty for Let's Encrypt production
+	TLSHTTPPort         int
+
+	// UpdateCheckEnabled opts in to periodic GitHub release checks.
+	// Set via --update-check-enabled / UPDATE_CHECK_ENABLED env var.
+	UpdateCheckEnabled bool
+
+	// HLS on-demand transcoding for videos the browser cannot decode.
+	// Requires ffmpeg and ffprobe. Set via --hls-enabled / HLS_ENABLED env var.
+	HLSEnabled              bool
+	HLSFFmpegPath           string // ffmpeg binary, ffprobe is looked up next to it
+	HLSCacheDir             string // transcoded segments cache
+	HLSMaxTranscodes        int    // concurrent transcodes across all users
+	HLSMaxTranscodesPerUser int    // concurrent transcodes started by one user
+
+	// Progressive MP4 streams of videos the browser cannot play, for players
+	// without HLS. Uses the HLS ffmpeg binary, the codecs and bitrates are
+	// set in the registry. Set via --video-stream-enabled / VIDEO_STREAM_ENABLED env var.
+	VideoStreamEnabled      bool
+	VideoStreamCacheDir     string // finished renditions kept on local disk
+	VideoStreamMaxProcesses int    // concurrent ffmpeg processes
+
+	// WebDAVEnabled serves the default storage over WebDAV at /dav, for
+	// clients authenticating with an API token as Basic auth password.
+	// Set via --webdav-enabled / WEBDAV_ENABLED env var.
+	WebDAVEnabled bool
+
+	// S3GatewayEnabled serves the default storage over the S3 API at /s3,
+	// as one bucket named S3GatewayBucket, for clients signing requests
+	// with S3 access keys. Writes are rejected unless S3GatewayWritable.
+	// Set via --s3-gateway-enabled / S3_GATEWAY_ENABLED env var.
+	S3GatewayEnabled  bool
+	S3GatewayBucket   string
+	S3GatewayWritable bool
+
+	// GRPCPort serves the storage gRPC service over HTTP/2, for programs
+	// authenticating with an API token, with TLS while TLS is on. 0 disables.
+	// Set via --grpc-port / GRPC_PORT env var.
+	GRPCPort int
+
+	// BulkDownloadTTL is how long a bulk download token stays valid.
+	// Set via --bulk-download-ttl / BULK_DOWNLOAD_TTL env var.
+	BulkDownloadTTL time.Duration
+
+	// Chunked uploads are spooled to ChunkUploadDir until completed, and
+	// discarded after ChunkUploadTTL without new chunks.
+	// Set via --chunk-upload-dir / CHUNK_UPLOAD_DIR and --chunk-upload-ttl / CHUNK_UPLOAD_TTL env vars.
+	ChunkUploadDir string
+	ChunkUploadTTL time.Duration
+
+	// importFromUrl downloads are limited to URLImportMaxBytes and
+	// URLImportTimeout, and refused for private network addresses unless
+	// URLImportAllowPrivateNetworks is set.
+	// Set via --url-import-max-bytes / URL_IMPORT_MAX_BYTES, --url-import-timeout /
+	// URL_IMPORT_TIMEOUT and --url-import-allow-private-networks /
+	// URL_IMPORT_ALLOW_PRIVATE_NETWORKS env vars.
+	URLImportMaxBytes             int64
+	URLImportTimeout              time.Duration
+	URLImportAllowPrivateNetworks bool
+
+	// LabelHookURL receives new images of the default storage for external
+	// classification, whose labels come back through ingestLabels; empty
+	// disables. Requests are signed with LabelHookSecret when set, and link
+	// images below LabelHookImageBaseURL, the app URL when empty.
+	// Set via --label-hook-url / LABEL_HOOK_URL, --label-hook-secret /
+	// LABEL_HOOK_SECRET and --label-hook-image-base-url /
+	// LABEL_HOOK_IMAGE_BASE_URL env vars.
+	LabelHookURL          string
+	LabelHookSecret       string
+	LabelHookImageBaseURL string
+
+	// Folder listings are cached for ListCacheTTL, 0 disables caching. At
+	// most ListCacheMaxItems entries are held in memory, ListCachePersist
+	// also keeps listings in the database across restarts.
+	// Set via --list-cache-ttl / LIST_CACHE_TTL, --list-cache-max-items /
+	// LIST_CACHE_MAX_ITEMS and --list-cache-persist / LIST_CACHE_PERSIST env vars.
+	ListCacheTTL      time.Duration
+	ListCacheMaxItems int
+	ListCachePersist  bool
+
+	// DuplicateScanInterval schedules content hash scans of the default
+	// storage for duplicateGroups, 0 disables; scans can also be started
+	// with scanDuplicates. Set via --duplicate-scan-interval / DUPLICATE_SCAN_INTERVAL env var.
+	DuplicateScanInterval time.Duration
+
+	// UploadDedupe decides what uploadFile does with content identical to a
+	// file hashed for duplicate detection: "allow" stores it anyway, "reject"
+	// fails the upload and "link" skips the write, answering with the
+	// existing file. Set via --upload-dedupe / UPLOAD_DEDUPE env var.
+	UploadDedupe string
+
+	// UploadCollision decides what uploads to a path already taken do when
+	// the request does not say: "overwrite" replaces the file, "rename"
+	// stores the upload under a numbered name and "reject" fails it. Set via --upload-collision / UPLOAD_COLLISION env var.
+	UploadCollision string
+
+	// UploadScanner names the backend scanning uploads for malware before
+	// they are stored, "clamd" for ClamAV at UploadScannerURL such as
+	// unix:///run/clamav/clamd.ctl or tcp://clamav:3310, "http" for a
+	// scanning service or a backend built in with virusscan.RegisterScanner;
+	// empty disables. Infected uploads are rejected and kept in
+	// UploadQuarantineDir when set. Set via --upload-scanner / UPLOAD_SCANNER,
+	// --upload-scanner-url / UPLOAD_SCANNER_URL and --upload-quarantine-dir /
+	// UPLOAD_QUARANTINE_DIR env vars.
+	UploadScanner       string
+	UploadScannerURL    string
+	UploadQuarantineDir string
+
+	// UploadAllowedExtensions is a comma-separated list of the extensions
+	// uploads may have, such as ".jpg,.png,.mp4"; empty allows any. Whatever
+	// the list, upload content must match its extension. Set via
+	// --upload-allowed-extensions / UPLOAD_ALLOWED_EXTENSIONS env var.
+	UploadAllowedExtensions string
+
+	// FaceDetector names the backend detecting faces for people albums,
+	// "http" for a service at FaceDetectorURL or a backend built in with
+	// faces.RegisterDetector; empty disables. Faces match a known person
+	// above the FaceMatchThreshold cosine similarity. FaceScanInterval
+	// schedules scans of the default storage, 0 disables; scans can also be
+	// started with scanFaces. Set via --face-detector / FACE_DETECTOR,
+	// --face-detector-url / FACE_DETECTOR_URL, --face-match-threshold /
+	// FACE_MATCH_THRESHOLD and --face-scan-interval / FACE_SCAN_INTERVAL env vars.
+	FaceDetector       string
+	FaceDetectorURL    string
+	FaceMatchThreshold float64
+	FaceScanInterval   time.Duration
+
+	// LibraryScanSchedule is the cron expression of library scans, which
+	// refresh cached listings, metadata and hashes of the default storage;
+	// empty disables. Read from the registry on every check, so a change
+	// applies without a restart. Set via --library-scan-schedule /
+	// LIBRARY_SCAN_SCHEDULE env var.
+	LibraryScanSchedule string
+
+	// AuditLogRetention is how long recorded mutations are kept in the
+	// audit log, 0 keeps them forever.
+	// Set via --audit-log-retention / AUDIT_LOG_RETENTION env var.
+	AuditLogRetention time.Duration
+
+	// WebhookDeliveryRetention is how long webhook deliveries are kept in
+	// the delivery log, 0 keeps them forever.
+	// Set via --webhook-delivery-retention / WEBHOOK_DELIVERY_RETENTION env var.
+	WebhookDeliveryRetention time.Duration
+
+	// FileJournalRetention is how long changes of the storage are kept for
+	// sync clients, 0 keeps them forever. Clients that last synced longer
+	// ago list the library again.
+	// Set via --file-journal-retention / FILE_JOURNAL_RETENTION env var.
+	FileJournalRetention time.Duration
+
+	// SessionExpiration is how long a login session lasts without its
+	// refresh token being used.
+	// Set via --session-expiration / SESSION_EXPIRATION env var.
+	SessionExpiration time.Duration
+
+	// RateLimitRequests and RateLimitBandwidth softly limit the requests per
+	// minute and megabytes per hour of each user, shared link and anonymous
+	// client, 0 for no limit. Admins are not limited.
+	// Set via --rate-limit-requests / RATE_LIMIT_REQUESTS and
+	// --rate-limit-bandwidth / RATE_LIMIT_BANDWIDTH env vars.
+	RateLimitRequests  int
+	RateLimitBandwidth int
+
+	// TrustedProxies is a comma-separated list of the IP addresses and CIDR
+	// networks of reverse proxies whose X-Forwarded-For header is trusted
+	// for the client address. Empty trusts no proxy.
+	// Set via --trusted-proxies / TRUSTED_PROXIES env var.
+	TrustedProxies string
+
+	// Compression lists the route groups whose responses are gzip
+	// compressed and whose assets precompressed at build are served:
+	// "api", "static" and "imagor", empty or "none" compresses nothing.
+	// Set via --compression / COMPRESSION env var.
+	Compression string
+
+	// OperationWorkers limits the background operations (batch conversion,
+	// bulk changes, maintenance) running at once, later ones are queued.
+	// Set via --operation-workers / OPERATION_WORKERS env var.
+	OperationWorkers int
+
+	// Image processing slots shared by all requests, by priority class.
+	// Set via --processing-concurrency / PROCESSING_CONCURRENCY env var, 0 = number of CPUs.
+	ProcessingConcurrency   int
+	ProcessingReservedSlots int // slots kept for interactive requests, -1 = a quarter of the slots
+
+	// Admin recovery prints a one-time token to the log that resets an admin password.
+	// Set via --admin-recovery / ADMIN_RECOVERY env var to issue one at startup, or
+	// create the file given by --admin-recovery-file / ADMIN_RECOVERY_FILE at runtime.
+	AdminRecovery     bool
+	AdminRecoveryFile string
+
+	// TenantsEnabled isolates the users of each tenant in their own storage
+	// root with their own settings, so one server can host several separate
+	// libraries. Set via --tenants-enabled / TENANTS_ENABLED env var.
+	TenantsEnabled bool
+
+	// ValidateConfig checks the configuration, database, storage and imagor
+	// setup, prints a report of the effective values and exits without
+	// starting the server. Set via --validate-config / VALIDATE_CONFIG env var.
+	ValidateConfig bool
+
+	// APICompatMode keeps serving deprecated GraphQL fields and arguments for older embedded
+	// frontends. Disable to reject them and test integrations against the current API.
+	// Set via --api-compat-mode / API_COMPAT_MODE env var.
+	APICompatMode bool
+
+	// OpenTelemetry tracing of GraphQL resolvers, storage calls and image
+	// processing, exported over OTLP/HTTP; off unless an endpoint is set.
+	// Set via --otel-exporter-otlp-endpoint / OTEL_EXPORTER_OTLP_ENDPOINT,
+	// --otel-exporter-otlp-headers / OTEL_EXPORTER_OTLP_HEADERS,
+	// --otel-service-name / OTEL_SERVICE_NAME and
+	// --otel-traces-sample-ratio / OTEL_TRACES_SAMPLE_RATIO env vars.
+	OTelExporterOTLPEndpoint string
+	OTelExporterOTLPHeaders  string // comma-separated key=value pairs
+	OTelServiceName          string
+	OTelTracesSampleRatio    float64
+
+	// Config values can reference secrets instead of holding them, as
+	// file:///run/secrets/..., vault://<path>#<key> or awssm://<secret-id>#<key>,
+	// resolved at load time and fetched again after SecretsCacheTTL, 0 never.
+	// Set via --secrets-cache-ttl / SECRETS_CACHE_TTL, --vault-addr / VAULT_ADDR,
+	// --vault-token / VAULT_TOKEN and --vault-namespace / VAULT_NAMESPACE env vars.
+	SecretsCacheTTL time.Duration
+	VaultAddr       string
+	VaultToken      string
+	VaultNamespace  string
+
 	// Internal tracking for config overrides
 	overriddenFlags map[string]string
-	flagSet         *flag.FlagSet // Private field to access flag values
+	secretRefs      map[string]string // secret references of resolved flags
+	flagSources     map[string]string // where flags were set, see Values
+	encryptedFlags  map[string]bool   // flags set from the registry, true for encrypted values
+	flagSet         *flag.FlagSet     // Private field to access flag values
 }
 
 const DefaultS3HTTPMaxIdleConnsPerHost = 100
@@ -104,7 +400,8 @@ func Load(args []string, registryStore registrystore.Store) (*Config, error) {
 		dbMaxIdleConns    = fs.Int("db-max-idle-conns", database.DefaultPostgresMaxIdleConns, "maximum number of idle database connections for PostgreSQL")
 		dbConnMaxLifetime = fs.String("db-conn-max-lifetime", database.DefaultPostgresConnMaxLifetime.String(), "maximum lifetime of a PostgreSQL connection")
 		dbConnMaxIdleTime = fs.String("db-conn-max-idle-time", database.DefaultPostgresConnMaxIdleTime.String(), "maximum idle time of a PostgreSQL connection")
-		storageType       = fs.String("storage-type", "", "storage type: file or s3 (auto-detected if not specified)")
+		sqliteMaintenance = fs.String("sqlite-maintenance-interval", database.DefaultSQLiteMaintenanceInterval.String(), "interval between SQLite WAL checkpoint, vacuum and integrity check runs; 0 disables")
+		storageType       = fs.String("storage-type", "", "storage type: file, s3 or sftp (auto-detected if not specified)")
 		jwtSecret         = fs.String("jwt-secret", "", "secret key for JWT signing")
 		jwtExpiration     = fs.String("jwt-expiration", "168h", "JWT token expiration duration")
 		licenseKey        = fs.String("license-key", "", "license key for activation")
@@ -113,12 +410,23 @@ func Load(args []string, registryStore registrystore.Store) (*Config, error) {
 		publicPreviewEnabled  = fs.Bool("public-preview-enabled", false, "enable public preview session issuance")
 		publicPreviewSpaceKey = fs.String("public-preview-space-key", "", "space key used for public preview sessions")
 		embeddedMode          = fs.Bool("embedded-mode", false, "enable embedded mode (stateless, no database)")
+		embedTokenSecret      = fs.String("embed-token-secret", "", "secret host servers sign /api/embed/token requests with in embedded mode, empty disables the endpoint")
 		forceAutoMigrate      = fs.Bool("force-auto-migrate", false, "force auto-migration even for PostgreSQL/MySQL (use with caution in multi-instance environments)")
-		migrateCommand        = fs.String("migrate-command", "up", "migration command: up, down, status, reset")
+		migrateCommand        = fs.String("migrate-command", "up", "migration command: up, down, status, reset, rotate-encryption-key")
+
+		tlsCertFile         = fs.String("tls-cert-file", "", "PEM certificate served over HTTPS, with tls-key-file")
+		tlsKeyFile          = fs.String("tls-key-file", "", "PEM private key of tls-cert-file")
+		tlsACMEDomains      = fs.String("tls-acme-domains", "", "comma-separated domains to obtain certificates for from ACME (Let's Encrypt), serving HTTPS")
+		tlsACMEEmail        = fs.String("tls-acme-email", "", "contact email of the ACME account, for expiry notices")
+		tlsACMECacheDir     = fs.String("tls-acme-cache-dir", "./acme-certs", "directory keeping ACME certificates and account keys across restarts")
+		tlsACMEDirectoryURL = fs.String("tls-acme-directory-url", "", "ACME directory URL, e.g. the Let's Encrypt staging directory; empty for Let's Encrypt")
+		tlsHTTPPort         = fs.Int("tls-http-port", 80, "plain HTTP port answering ACME HTTP-01 challenges and redirecting to HTTPS while TLS is on; 0 disables")
 
 		fileStorageBaseDir          = fs.String("file-storage-base-dir", "/app/gallery", "base directory for file storage")
 		fileStorageMkdirPermissions = fs.String("file-storage-mkdir-permissions", "0755", "directory creation permissions")
 		fileStorageWritePermissions = fs.String("file-storage-write-permissions", "0644", "file write permissions")
+		fileStorageWatch            = fs.Bool("file-storage-watch", false, "notify clients of changes made directly in the file storage directory")
+		fileStorageWatchInterval    = fs.Duration("file-storage-watch-interval", fswatch.DefaultPollInterval, "file storage rescan interval when inotify is unavailable")
 
 		awsRegion                 = fs.String("aws-region", "", "AWS region")
 		awsAccessKeyID            = fs.String("aws-access-key-id", "", "AWS access key ID (optional)")
@@ -130,11 +438,24 @@ func Load(args []string, registryStore registrystore.Store) (*Config, error) {
 		s3ForcePathStyle          = fs.Bool("s3-force-path-style", false, "S3 force path style (optional)")
 		s3StorageBaseDir          = fs.String("s3-storage-base-dir", "", "S3 base directory (optional)")
 
+		sftpStorageHost                 = fs.String("sftp-storage-host", "", "SFTP server host")
+		sftpStoragePort                 = fs.Int("sftp-storage-port", 22, "SFTP server port")
+		sftpStorageUser                 = fs.String("sftp-storage-user", "", "SFTP user")
+		sftpStoragePrivateKey           = fs.String("sftp-storage-private-key", "", "PEM encoded SFTP private key")
+		sftpStoragePrivateKeyPassphrase = fs.String("sftp-storage-private-key-passphrase", "", "SFTP private key passphrase (optional)")
+		sftpStorageHostKey              = fs.String("sftp-storage-host-key", "", "SFTP host key SHA256 fingerprint (optional, unverified when empty)")
+		sftpStorageBaseDir              = fs.String("sftp-storage-base-dir", "", "SFTP base directory (optional)")
+
 		imagorSecret         = fs.String("imagor-secret", "", "secret key for imagor")
 		imagorSignerType     = fs.String("imagor-signer-type", "sha1", "imagor signer algorithm: sha1, sha256, sha512")
 		imagorSignerTruncate = fs.Int("imagor-signer-truncate", 0, "imagor signer truncation length")
 		vipsCacheSize        = fs.String("vips-cache-size", "", "imagor in-memory decoded-image cache size in bytes (matches imagor VIPS_CACHE_SIZE)")
 
+		imagorResultStorage           = fs.String("imagor-result-storage", "", "storage persisting generated thumbnails: file or s3, empty disables")
+		imagorResultStorageBaseDir    = fs.String("imagor-result-storage-base-dir", "", "directory of the file result storage, or key prefix of the s3 result storage")
+		imagorResultStorageBucket     = fs.String("imagor-result-storage-bucket", "", "S3 bucket of the s3 result storage, defaults to s3-storage-bucket")
+		imagorResultStorageExpiration = fs.Duration("imagor-result-storage-expiration", 0, "time stored thumbnails are served before being regenerated, 0 keeps them")
+
 		appTitle                  = fs.String("app-title", "", "custom application title (license required)")
 		appUrl                    = fs.String("app-url", "", "frontend application URL used for post-OAuth redirect (license required for branding)")
 		appHomeTitle              = fs.String("app-home-title", "", "custom home page title")
@@ -144,9 +465,98 @@ func Load(args []string, registryStore registrystore.Store) (*Config, error) {
 
 		corsOrigins       = fs.String("cors-origins", "", "comma-separated allowed CORS origins; empty = allow all (*). Example: https://app.imagor.net")
 		appFrameAncestors = fs.String("app-frame-ancestors", "", "comma-separated origins allowed to embed the app in an iframe; empty = derive from APP_URL and non-wildcard CORS origins")
+
+		updateCheckEnabled = fs.Bool("update-check-enabled", false, "periodically check GitHub for new releases; keep disabled for air-gapped installs")
+		apiCompatMode      = fs.Bool("api-compat-mode", true, "serve deprecated GraphQL fields and arguments for older clients; disable to reject them")
+
+		hlsEnabled              = fs.Bool("hls-enabled", false, "enable on-demand HLS transcoding for videos the browser cannot play (requires ffmpeg)")
+		hlsFFmpegPath           = fs.String("hls-ffmpeg-path", "ffmpeg", "ffmpeg binary used for HLS transcoding and video streams, ffprobe is expected alongside")
+		hlsCacheDir             = fs.String("hls-cache-dir", filepath.Join(os.TempDir(), "imagor-studio-hls"), "directory of HLS segments while transcoding, and of finished transcodes without imagor result storage")
+		hlsMaxTranscodes        = fs.Int("hls-max-transcodes", hls.DefaultMaxTranscodes, "maximum concurrent HLS transcodes")
+		hlsMaxTranscodesPerUser = fs.Int("hls-max-transcodes-per-user", hls.DefaultMaxTranscodesPerUser, "maximum concurrent HLS transcodes started by one user")
+
+		videoStreamEnabled      = fs.Bool("video-stream-enabled", false, "enable MP4 streams remuxing or transcoding videos the browser cannot play (requires ffmpeg)")
+		videoStreamCacheDir     = fs.String("video-stream-cache-dir", filepath.Join(os.TempDir(), "imagor-studio-stream"), "directory caching video stream renditions")
+		videoStreamMaxProcesses = fs.Int("video-stream-max-processes", videostream.DefaultMaxProcesses, "maximum concurrent ffmpeg processes for video streams")
+
+		webdavEnabled = fs.Bool("webdav-enabled", false, "serve the storage over WebDAV at /dav, authenticated with API tokens")
+
+		s3GatewayEnabled  = fs.Bool("s3-gateway-enabled", false, "serve the storage over an S3 compatible API at /s3, authenticated with S3 access keys")
+		s3GatewayBucket   = fs.String("s3-gateway-bucket", "gallery", "bucket name the S3 gateway serves the storage as")
+		s3GatewayWritable = fs.Bool("s3-gateway-writable", false, "allow uploads and deletes through the S3 gateway")
+
+		grpcPort = fs.Int("grpc-port", 0, "port serving the storage gRPC API for bulk operations, authenticated with API tokens; 0 disables")
+
+		bulkDownloadTTL = fs.Duration("bulk-download-ttl", bulkdownload.DefaultTTL, "validity of bulk download tokens for external download managers")
+
+		chunkUploadDir = fs.String("chunk-upload-dir", filepath.Join(os.TempDir(), "imagor-studio-uploads"), "directory spooling chunked uploads until completed")
+		chunkUploadTTL = fs.Duration("chunk-upload-ttl", chunkupload.DefaultTTL, "time a chunked upload is kept without new chunks")
+
+		urlImportMaxBytes             = fs.Int64("url-import-max-bytes", urlimport.DefaultMaxBytes, "largest file importFromUrl downloads, in bytes")
+		urlImportTimeout              = fs.Duration("url-import-timeout", urlimport.DefaultTimeout, "time an importFromUrl download may take")
+		urlImportAllowPrivateNetworks = fs.Bool("url-import-allow-private-networks", false, "let importFromUrl download from loopback and private network addresses")
+
+		labelHookURL          = fs.String("label-hook-url", "", "webhook receiving new images for external classification, empty disables")
+		labelHookSecret       = fs.String("label-hook-secret", "", "secret signing label hook requests with HMAC-SHA256")
+		labelHookImageBaseURL = fs.String("label-hook-image-base-url", "", "server URL the classification service downloads images from, defaults to app-url")
+
+		listCacheTTL      = fs.Duration("list-cache-ttl", 0, "time folder listings are served from cache, 0 disables the cache")
+		listCacheMaxItems = fs.Int("list-cache-max-items", listcache.DefaultMaxItems, "file entries of cached folder listings held in memory")
+		listCachePersist  = fs.Bool("list-cache-persist", false, "keep cached folder listings in the database across restarts")
+
+		duplicateScanInterval = fs.Duration("duplicate-scan-interval", 0, "interval between content hash scans of the storage for duplicate detection, 0 disables")
+		uploadDedupe          = fs.String("upload-dedupe", "allow", "uploads identical to a hashed file: allow stores them, reject fails them, link skips the write and returns the existing file")
+		uploadCollision       = fs.String("upload-collision", "overwrite", "uploads to a path already taken: overwrite replaces the file, rename stores them under a numbered name, reject fails them")
+		uploadScanner         = fs.String("upload-scanner", "", "malware scanning backend for uploads, \"clamd\" or \"http\", empty disables")
+		uploadScannerURL      = fs.String("upload-scanner-url", "", "URL of the upload scanner, e.g. unix:///run/clamav/clamd.ctl, tcp://clamav:3310 or http://scanner:8080/scan")
+		uploadQuarantineDir   = fs.String("upload-quarantine-dir", "", "local directory keeping infected uploads for review, empty discards them")
+		uploadAllowedExts     = fs.String("upload-allowed-extensions", "", "comma-separated extensions uploads may have, e.g. \".jpg,.png,.mp4\"; empty allows any")
+
+		faceDetector       = fs.String("face-detector", "", "face detection backend for people albums, e.g. \"http\", empty disables")
+		faceDetectorURL    = fs.String("face-detector-url", "", "URL of the face detection service or model of the face detector")
+		faceMatchThreshold = fs.Float64("face-match-threshold", faces.DefaultMatchThreshold, "cosine similarity of face embeddings above which faces are taken for the same person")
+		faceScanInterval   = fs.Duration("face-scan-interval", 0, "interval between face scans of the storage, 0 disables")
+
+		libraryScanSchedule = fs.String("library-scan-schedule", "", "cron expression of library scans refreshing listings, metadata and hashes of the storage, e.g. \"0 3 * * *\", empty disables")
+
+		auditLogRetention = fs.Duration("audit-log-retention", 90*24*time.Hour, "time recorded mutations are kept in the audit log, 0 keeps them forever")
+
+		webhookDeliveryRetention = fs.Duration("webhook-delivery-retention", 30*24*time.Hour, "time webhook deliveries are kept in the delivery log, 0 keeps them forever")
+
+		fileJournalRetention = fs.Duration("file-journal-retention", 30*24*time.Hour, "time changes of the storage are kept for changesSince, 0 keeps them forever")
+
+		sessionExpiration = fs.Duration("session-expiration", 30*24*time.Hour, "time a login session lasts without its refresh token being used")
+
+		rateLimitRequests  = fs.Int("rate-limit-requests", 0, "requests per minute allowed to each user, shared link or anonymous client, 0 for no limit")
+		rateLimitBandwidth = fs.Int("rate-limit-bandwidth", 0, "megabytes per hour served to each user, shared link or anonymous client, 0 for no limit")
+		trustedProxies     = fs.String("trusted-proxies", "", "comma-separated IP addresses or CIDR networks of reverse proxies trusted for X-Forwarded-For, empty trusts none")
+
+		compressionGroups = fs.String("compression", compression.DefaultGroups, "comma separated route groups whose responses are compressed: api, static, imagor; empty or \"none\" disables")
+
+		operationWorkers = fs.Int("operation-workers", operation.DefaultWorkers, "background operations running at once, later ones are queued")
+
+		processingConcurrency   = fs.Int("processing-concurrency", 0, "concurrent image processing jobs; 0 = number of CPUs")
+		processingReservedSlots = fs.Int("processing-reserved-slots", -1, "processing slots reserved for interactive requests over previews and backfills; -1 = a quarter of the slots")
+
+		adminRecovery     = fs.Bool("admin-recovery", false, "print a one-time admin recovery token to the log at startup")
+		adminRecoveryFile = fs.String("admin-recovery-file", "", "file checked every 30s; when it exists it is removed and an admin recovery token is printed to the log")
+
+		tenantsEnabled = fs.Bool("tenants-enabled", false, "isolate the users of each tenant in the tenant's storage root with their own settings")
+
+		validateConfig = fs.Bool("validate-config", false, "check the configuration, database, storage and imagor setup, print the effective values and exit")
+
+		otelEndpoint    = fs.String("otel-exporter-otlp-endpoint", "", "OTLP/HTTP collector base URL receiving traces, e.g. http://localhost:4318; empty disables tracing")
+		otelHeaders     = fs.String("otel-exporter-otlp-headers", "", "comma-separated key=value headers sent with exported traces")
+		otelServiceName = fs.String("otel-service-name", "imagor-studio", "service name reported in traces")
+		otelSampleRatio = fs.Float64("otel-traces-sample-ratio", 1, "fraction of requests traced, between 0 and 1")
+
+		secretsCacheTTL = fs.Duration("secrets-cache-ttl", secrets.DefaultTTL, "how long secrets referenced by file://, vault:// or awssm:// values are cached before they are fetched again; 0 caches them until restart")
+		vaultAddr       = fs.String("vault-addr", "", "HashiCorp Vault address for vault:// secret references")
+		vaultToken      = fs.String("vault-token", "", "HashiCorp Vault token for vault:// secret references")
+		vaultNamespace  = fs.String("vault-namespace", "", "HashiCorp Vault namespace for vault:// secret references")
 	)
 
-	_ = fs.String("config", ".env", "config file (optional)")
+	_ = fs.String("config", ".env", "config file (optional): .env, or YAML (.yaml, .yml) or TOML (.toml)")
 
 	// Prepare ff options
 	if args == nil {
@@ -156,7 +566,7 @@ func Load(args []string, registryStore registrystore.Store) (*Config, error) {
 	ffOptions := []ff.Option{
 		ff.WithEnvVars(),
 		ff.WithConfigFileFlag("config"),
-		ff.WithConfigFileParser(ff.EnvParser),
+		ff.WithConfigFileParser(configFileParser(fs)),
 		ff.WithIgnoreUndefined(true),
 		ff.WithAllowMissingConfigFile(true),
 	}
@@ -171,6 +581,16 @@ func Load(args []string, registryStore registrystore.Store) (*Config, error) {
 			return nil, fmt.Errorf("error parsing configuration: %w", err)
 		}
 	}
+	if err := checkConfigFile(fs); err != nil {
+		return nil, err
+	}
+	if *secretsCacheTTL < 0 {
+		return nil, fmt.Errorf("secrets-cache-ttl must not be negative")
+	}
+	secretRefs, err := resolveSecretReferences(fs, *secretsCacheTTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve secret: %w", err)
+	}
 
 	// Track overridden flags AFTER flag parsing
 	overriddenFlags := make(map[string]string)
@@ -179,8 +599,9 @@ func Load(args []string, registryStore registrystore.Store) (*Config, error) {
 	})
 
 	// Apply registry values if registry store is provided
+	registryFlags := make(map[string]bool)
 	if registryStore != nil {
-		if err := applyRegistryValues(fs, overriddenFlags, registryStore); err != nil {
+		if err := applyRegistryValues(fs, overriddenFlags, registryStore, registryFlags); err != nil {
 			return nil, fmt.Errorf("failed to apply registry values: %w", err)
 		}
 	}
@@ -214,6 +635,131 @@ func Load(args []string, registryStore registrystore.Store) (*Config, error) {
 	if dbConnIdleTime <= 0 {
 		return nil, fmt.Errorf("db-conn-max-idle-time must be greater than 0")
 	}
+
+	sqliteMaintenanceInterval, err := time.ParseDuration(*sqliteMaintenance)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sqlite-maintenance-interval: %w", err)
+	}
+	if sqliteMaintenanceInterval < 0 {
+		return nil, fmt.Errorf("sqlite-maintenance-interval must not be negative")
+	}
+	if *urlImportMaxBytes <= 0 {
+		return nil, fmt.Errorf("url-import-max-bytes must be greater than 0")
+	}
+	if *urlImportTimeout <= 0 {
+		return nil, fmt.Errorf("url-import-timeout must be greater than 0")
+	}
+	if *labelHookURL != "" {
+		if u, err := url.Parse(*labelHookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("label-hook-url must be an absolute http or https URL")
+		}
+		if *labelHookImageBaseURL == "" && *appUrl == "" {
+			return nil, fmt.Errorf("label-hook-image-base-url or app-url is required with label-hook-url")
+		}
+	}
+	if *duplicateScanInterval < 0 {
+		return nil, fmt.Errorf("duplicate-scan-interval must not be negative")
+	}
+	if (strings.TrimSpace(*tlsCertFile) == "") != (strings.TrimSpace(*tlsKeyFile) == "") {
+		return nil, fmt.Errorf("tls-cert-file and tls-key-file must be set together")
+	}
+	if strings.TrimSpace(*tlsCertFile) != "" && strings.TrimSpace(*tlsACMEDomains) != "" {
+		return nil, fmt.Errorf("tls-cert-file and tls-acme-domains cannot be set together")
+	}
+	if *tlsHTTPPort < 0 || *tlsHTTPPort > 65535 {
+		return nil, fmt.Errorf("tls-http-port must be between 0 and 65535")
+	}
+	if *rateLimitRequests < 0 {
+		return nil, fmt.Errorf("rate-limit-requests must not be negative")
+	}
+	if *rateLimitBandwidth < 0 {
+		return nil, fmt.Errorf("rate-limit-bandwidth must not be negative")
+	}
+	if _, err := clientip.Parse(*trustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid trusted-proxies: %w", err)
+	}
+	if _, err := compression.ParseGroups(*compressionGroups); err != nil {
+		return nil, fmt.Errorf("invalid compression: %w", err)
+	}
+	switch *uploadDedupe {
+	case "allow", "reject", "link":
+	default:
+		return nil, fmt.Errorf("unsupported upload-dedupe: %s (supported: allow, reject, link)", *uploadDedupe)
+	}
+	switch *uploadCollision {
+	case "overwrite", "rename", "reject":
+	default:
+		return nil, fmt.Errorf("unsupported upload-collision: %s (supported: overwrite, rename, reject)", *uploadCollision)
+	}
+	if *uploadScanner != "" {
+		if !slices.Contains(virusscan.Scanners(), *uploadScanner) {
+			return nil, fmt.Errorf("upload-scanner must be one of %s", strings.Join(virusscan.Scanners(), ", "))
+		}
+		if _, err := virusscan.NewScanner(*uploadScanner, virusscan.Options{URL: *uploadScannerURL}); err != nil {
+			return nil, fmt.Errorf("upload-scanner-url: %w", err)
+		}
+	}
+	if *faceDetector != "" {
+		if !slices.Contains(faces.Detectors(), *faceDetector) {
+			return nil, fmt.Errorf("face-detector must be one of %s", strings.Join(faces.Detectors(), ", "))
+		}
+		if *faceDetector == "http" {
+			if u, err := url.Parse(*faceDetectorURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return nil, fmt.Errorf("face-detector-url must be an absolute http or https URL")
+			}
+		}
+	}
+	if *faceMatchThreshold <= 0 || *faceMatchThreshold > 1 {
+		return nil, fmt.Errorf("face-match-threshold must be greater than 0 and at most 1")
+	}
+	if *faceScanInterval < 0 {
+		return nil, fmt.Errorf("face-scan-interval must not be negative")
+	}
+	if strings.TrimSpace(*libraryScanSchedule) != "" {
+		if _, err := scheduler.Parse(*libraryScanSchedule); err != nil {
+			return nil, fmt.Errorf("library-scan-schedule: %w", err)
+		}
+	}
+	if *auditLogRetention < 0 {
+		return nil, fmt.Errorf("audit-log-retention must not be negative")
+	}
+	if *webhookDeliveryRetention < 0 {
+		return nil, fmt.Errorf("webhook-delivery-retention must not be negative")
+	}
+	if *fileJournalRetention < 0 {
+		return nil, fmt.Errorf("file-journal-retention must not be negative")
+	}
+	if *sessionExpiration <= 0 {
+		return nil, fmt.Errorf("session-expiration must be greater than 0")
+	}
+	switch *imagorResultStorage {
+	case "":
+	case "file":
+		if strings.TrimSpace(*imagorResultStorageBaseDir) == "" {
+			return nil, fmt.Errorf("imagor-result-storage-base-dir is required when imagor-result-storage is file")
+		}
+	case "s3":
+		if *imagorResultStorageBucket == "" && *s3StorageBucket == "" {
+			return nil, fmt.Errorf("imagor-result-storage-bucket is required when imagor-result-storage is s3")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported imagor-result-storage: %s (supported: file, s3)", *imagorResultStorage)
+	}
+	if *imagorResultStorageExpiration < 0 {
+		return nil, fmt.Errorf("imagor-result-storage-expiration must not be negative")
+	}
+	if *s3GatewayEnabled && !validBucketName(*s3GatewayBucket) {
+		return nil, fmt.Errorf("invalid s3-gateway-bucket %q: 3 to 63 lowercase letters, digits, dots and hyphens", *s3GatewayBucket)
+	}
+	if *grpcPort < 0 || *grpcPort > 65535 {
+		return nil, fmt.Errorf("grpc-port must be between 0 and 65535")
+	}
+	if *grpcPort != 0 && *grpcPort == portInt {
+		return nil, fmt.Errorf("grpc-port must differ from port")
+	}
+	if *otelSampleRatio < 0 || *otelSampleRatio > 1 {
+		return nil, fmt.Errorf("otel-traces-sample-ratio must be between 0 and 1")
+	}
 	if *dbMaxOpenConns <= 0 {
 		return nil, fmt.Errorf("db-max-open-conns must be greater than 0")
 	}
@@ -249,54 +795,143 @@ func Load(args []string, registryStore registrystore.Store) (*Config, error) {
 	}
 
 	cfg := &Config{
-		Port:                        portInt,
-		DatabaseURL:                 *databaseURL,
-		DBMaxOpenConns:              *dbMaxOpenConns,
-		DBMaxIdleConns:              *dbMaxIdleConns,
-		DBConnMaxLifetime:           dbConnLifetime,
-		DBConnMaxIdleTime:           dbConnIdleTime,
-		JWTSecret:                   *jwtSecret,
-		JWTExpiration:               jwtExp,
-		LicenseKey:                  *licenseKey,
-		AllowGuestMode:              *allowGuestMode,
-		PublicPreviewEnabled:        *publicPreviewEnabled,
-		PublicPreviewSpaceKey:       strings.TrimSpace(*publicPreviewSpaceKey),
-		EmbeddedMode:                *embeddedMode,
-		ForceAutoMigrate:            *forceAutoMigrate,
-		MigrateCommand:              *migrateCommand,
-		StorageType:                 *storageType,
-		FileStorageBaseDir:          *fileStorageBaseDir,
-		FileStorageMkdirPermissions: os.FileMode(mkdirPerm),
-		FileStorageWritePermissions: os.FileMode(writePerm),
-		S3StorageBucket:             *s3StorageBucket,
-		AWSRegion:                   *awsRegion,
-		S3Endpoint:                  *s3Endpoint,
-		S3HTTPMaxIdleConnsPerHost:   *s3HTTPMaxIdleConnsPerHost,
-		S3ForcePathStyle:            *s3ForcePathStyle,
-		AWSAccessKeyID:              *awsAccessKeyID,
-		AWSSecretAccessKey:          *awsSecretAccessKey,
-		AWSSessionToken:             *awsSessionToken,
-		S3StorageBaseDir:            *s3StorageBaseDir,
-		ImagorSecret:                *imagorSecret,
-		ImagorSignerType:            *imagorSignerType,
-		ImagorSignerTruncate:        *imagorSignerTruncate,
-		ImagorCacheSizeBytes:        imagorCacheSizeBytes,
-		AppTitle:                    *appTitle,
-		AppUrl:                      *appUrl,
-		AppHomeTitle:                *appHomeTitle,
-		AppDefaultSortBy:            *appDefaultSortBy,
-		AppDefaultSortOrder:         *appDefaultSortOrder,
-		AppVideoThumbnailPosition:   *appVideoThumbnailPosition,
-		CORSOrigins:                 *corsOrigins,
-		AppFrameAncestors:           strings.TrimSpace(*appFrameAncestors),
-		overriddenFlags:             overriddenFlags,
-		flagSet:                     fs, // Store the flagSet for later use
+		Port:                            portInt,
+		DatabaseURL:                     *databaseURL,
+		DBMaxOpenConns:                  *dbMaxOpenConns,
+		DBMaxIdleConns:                  *dbMaxIdleConns,
+		DBConnMaxLifetime:               dbConnLifetime,
+		DBConnMaxIdleTime:               dbConnIdleTime,
+		SQLiteMaintenanceInterval:       sqliteMaintenanceInterval,
+		JWTSecret:                       *jwtSecret,
+		JWTExpiration:                   jwtExp,
+		LicenseKey:                      *licenseKey,
+		AllowGuestMode:                  *allowGuestMode,
+		PublicPreviewEnabled:            *publicPreviewEnabled,
+		PublicPreviewSpaceKey:           strings.TrimSpace(*publicPreviewSpaceKey),
+		EmbeddedMode:                    *embeddedMode,
+		EmbedTokenSecret:                *embedTokenSecret,
+		TLSCertFile:                     strings.TrimSpace(*tlsCertFile),
+		TLSKeyFile:                      strings.TrimSpace(*tlsKeyFile),
+		TLSACMEDomains:                  strings.TrimSpace(*tlsACMEDomains),
+		TLSACMEEmail:                    strings.TrimSpace(*tlsACMEEmail),
+		TLSACMECacheDir:                 *tlsACMECacheDir,
+		TLSACMEDirectoryURL:             strings.TrimSpace(*tlsACMEDirectoryURL),
+		TLSHTTPPort:                     *tlsHTTPPort,
+		ForceAutoMigrate:                *forceAutoMigrate,
+		MigrateCommand:                  *migrateCommand,
+		StorageType:                     *storageType,
+		FileStorageBaseDir:              *fileStorageBaseDir,
+		FileStorageMkdirPermissions:     os.FileMode(mkdirPerm),
+		FileStorageWritePermissions:     os.FileMode(writePerm),
+		FileStorageWatch:                *fileStorageWatch,
+		FileStorageWatchInterval:        *fileStorageWatchInterval,
+		S3StorageBucket:                 *s3StorageBucket,
+		AWSRegion:                       *awsRegion,
+		S3Endpoint:                      *s3Endpoint,
+		S3HTTPMaxIdleConnsPerHost:       *s3HTTPMaxIdleConnsPerHost,
+		S3ForcePathStyle:                *s3ForcePathStyle,
+		AWSAccessKeyID:                  *awsAccessKeyID,
+		AWSSecretAccessKey:              *awsSecretAccessKey,
+		AWSSessionToken:                 *awsSessionToken,
+		S3StorageBaseDir:                *s3StorageBaseDir,
+		SFTPStorageHost:                 *sftpStorageHost,
+		SFTPStoragePort:                 *sftpStoragePort,
+		SFTPStorageUser:                 *sftpStorageUser,
+		SFTPStoragePrivateKey:           *sftpStoragePrivateKey,
+		SFTPStoragePrivateKeyPassphrase: *sftpStoragePrivateKeyPassphrase,
+		SFTPStorageHostKey:              *sftpStorageHostKey,
+		SFTPStorageBaseDir:              *sftpStorageBaseDir,
+		ImagorSecret:                    *imagorSecret,
+		ImagorSignerType:                *imagorSignerType,
+		ImagorSignerTruncate:            *imagorSignerTruncate,
+		ImagorCacheSizeBytes:            imagorCacheSizeBytes,
+		ImagorResultStorage:             *imagorResultStorage,
+		ImagorResultStorageBaseDir:      strings.TrimSpace(*imagorResultStorageBaseDir),
+		ImagorResultStorageBucket:       *imagorResultStorageBucket,
+		ImagorResultStorageExpiration:   *imagorResultStorageExpiration,
+		AppTitle:                        *appTitle,
+		AppUrl:                          *appUrl,
+		AppHomeTitle:                    *appHomeTitle,
+		AppDefaultSortBy:                *appDefaultSortBy,
+		AppDefaultSortOrder:             *appDefaultSortOrder,
+		AppVideoThumbnailPosition:       *appVideoThumbnailPosition,
+		CORSOrigins:                     *corsOrigins,
+		AppFrameAncestors:               strings.TrimSpace(*appFrameAncestors),
+		UpdateCheckEnabled:              *updateCheckEnabled,
+		APICompatMode:                   *apiCompatMode,
+		HLSEnabled:                      *hlsEnabled,
+		HLSFFmpegPath:                   *hlsFFmpegPath,
+		HLSCacheDir:                     *hlsCacheDir,
+		HLSMaxTranscodes:                *hlsMaxTranscodes,
+		HLSMaxTranscodesPerUser:         *hlsMaxTranscodesPerUser,
+		VideoStreamEnabled:              *videoStreamEnabled,
+		VideoStreamCacheDir:             *videoStreamCacheDir,
+		VideoStreamMaxProcesses:         *videoStreamMaxProcesses,
+		WebDAVEnabled:                   *webdavEnabled,
+		S3GatewayEnabled:                *s3GatewayEnabled,
+		S3GatewayBucket:                 *s3GatewayBucket,
+		S3GatewayWritable:               *s3GatewayWritable,
+		GRPCPort:                        *grpcPort,
+		BulkDownloadTTL:                 *bulkDownloadTTL,
+		ChunkUploadDir:                  *chunkUploadDir,
+		ChunkUploadTTL:                  *chunkUploadTTL,
+		URLImportMaxBytes:               *urlImportMaxBytes,
+		URLImportTimeout:                *urlImportTimeout,
+		URLImportAllowPrivateNetworks:   *urlImportAllowPrivateNetworks,
+		LabelHookURL:                    *labelHookURL,
+		LabelHookSecret:                 *labelHookSecret,
+		LabelHookImageBaseURL:           *labelHookImageBaseURL,
+		ListCacheTTL:                    *listCacheTTL,
+		ListCacheMaxItems:               *listCacheMaxItems,
+		ListCachePersist:                *listCachePersist,
+		DuplicateScanInterval:           *duplicateScanInterval,
+		UploadDedupe:                    *uploadDedupe,
+		UploadCollision:                 *uploadCollision,
+		UploadScanner:                   *uploadScanner,
+		UploadScannerURL:                *uploadScannerURL,
+		UploadQuarantineDir:             *uploadQuarantineDir,
+		UploadAllowedExtensions:         *uploadAllowedExts,
+		FaceDetector:                    *faceDetector,
+		FaceDetectorURL:                 *faceDetectorURL,
+		FaceMatchThreshold:              *faceMatchThreshold,
+		FaceScanInterval:                *faceScanInterval,
+		LibraryScanSchedule:             strings.TrimSpace(*libraryScanSchedule),
+		AuditLogRetention:               *auditLogRetention,
+		WebhookDeliveryRetention:        *webhookDeliveryRetention,
+		FileJournalRetention:            *fileJournalRetention,
+		SessionExpiration:               *sessionExpiration,
+		RateLimitRequests:               *rateLimitRequests,
+		RateLimitBandwidth:              *rateLimitBandwidth,
+		TrustedProxies:                  *trustedProxies,
+		Compression:                     *compressionGroups,
+		OTelExporterOTLPEndpoint:        strings.TrimSpace(*otelEndpoint),
+		OTelExporterOTLPHeaders:         *otelHeaders,
+		OTelServiceName:                 *otelServiceName,
+		OTelTracesSampleRatio:           *otelSampleRatio,
+		OperationWorkers:                *operationWorkers,
+		ProcessingConcurrency:           *processingConcurrency,
+		ProcessingReservedSlots:         *processingReservedSlots,
+		AdminRecovery:                   *adminRecovery,
+		AdminRecoveryFile:               strings.TrimSpace(*adminRecoveryFile),
+		TenantsEnabled:                  *tenantsEnabled,
+		ValidateConfig:                  *validateConfig,
+		SecretsCacheTTL:                 *secretsCacheTTL,
+		VaultAddr:                       *vaultAddr,
+		VaultToken:                      *vaultToken,
+		VaultNamespace:                  *vaultNamespace,
+		overriddenFlags:                 overriddenFlags,
+		secretRefs:                      secretRefs,
+		flagSources:                     flagSources(fs, args, overriddenFlags, registryFlags),
+		encryptedFlags:                  registryFlags,
+		flagSet:                         fs, // Store the flagSet for later use
 	}
 
 	// Auto-populate storage type if not explicitly set
 	if cfg.StorageType == "" {
 		if cfg.S3StorageBucket != "" {
 			cfg.StorageType = "s3"
+		} else if cfg.SFTPStorageHost != "" {
+			cfg.StorageType = "sftp"
 		} else {
 			cfg.StorageType = "file" // default fallback
 		}
@@ -324,8 +959,16 @@ func (c *Config) validateStorageConfig() error {
 			return fmt.Errorf("s3-storage-bucket is required when storage-type is s3")
 		}
 		return nil
+	case "sftp":
+		if c.SFTPStorageHost == "" {
+			return fmt.Errorf("sftp-storage-host is required when storage-type is sftp")
+		}
+		if c.SFTPStorageUser == "" {
+			return fmt.Errorf("sftp-storage-user is required when storage-type is sftp")
+		}
+		return nil
 	default:
-		return fmt.Errorf("unsupported storage-type: %s (supported: file, s3)", c.StorageType)
+		return fmt.Errorf("unsupported storage-type: %s (supported: file, s3, sftp)", c.StorageType)
 	}
 }
 
@@ -362,6 +1005,13 @@ func (c *Config) GetByRegistryKey(registryKey string) (effectiveValue string, is
 
 	// Check if this flag was overridden by CLI/env
 	if value, overridden := c.overriddenFlags[flagName]; overridden {
+		// Referenced secrets are fetched again once the cached value expired,
+		// so rotated secrets reach the values read at runtime
+		if ref, ok := c.secretRefs[flagName]; ok {
+			if secret, err := secretResolver.Resolve(context.Background(), ref); err == nil {
+				return secret, true
+			}
+		}
 		return value, true
 	}
 	// Check default flag value
@@ -373,13 +1023,19 @@ func (c *Config) GetByRegistryKey(registryKey string) (effectiveValue string, is
 	return "", false
 }
 
+// TLSEnabled reports whether the server terminates TLS itself
+func (c *Config) TLSEnabled() bool {
+	return c.TLSCertFile != "" || c.TLSACMEDomains != ""
+}
+
 // IsEmbeddedMode returns whether embedded mode is enabled
 func (c *Config) IsEmbeddedMode() bool {
 	return c.EmbeddedMode
 }
 
-// applyRegistryValues applies registry values to flags that weren't overridden by CLI/env
-func applyRegistryValues(flagSet *flag.FlagSet, overriddenFlags map[string]string, registryStore registrystore.Store) error {
+// applyRegistryValues applies registry values to flags that weren't overridden by CLI/env,
+// recording the flags set in applied, true for encrypted values
+func applyRegistryValues(flagSet *flag.FlagSet, overriddenFlags map[string]string, registryStore registrystore.Store, applied map[string]bool) error {
 	ctx := context.Background()
 	prefix := "config."
 	entries, err := registryStore.List(ctx, registrystore.SystemOwnerID, &prefix)
@@ -402,9 +1058,26 @@ func applyRegistryValues(flagSet *flag.FlagSet, overriddenFlags map[string]strin
 				if err := f.Value.Set(entry.Value); err != nil {
 					return fmt.Errorf("failed to set flag %s to value %s: %w", flagName, entry.Value, err)
 				}
+				applied[flagName] = entry.IsEncrypted
 			}
 		}
 	}
 
 	return nil
 }
+
+// validBucketName reports whether name is a valid S3 bucket name
+func validBucketName(name string) bool {
+	if len(name) < 3 || len(name) > 63 {
+		return false
+	}
+	for i, c := range name {
+		switch {
+		case 'a' <= c && c <= 'z', '0' <= c && c <= '9':
+		case (c == '-' || c == '.') && i > 0 && i < len(name)-1:
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -40,6 +40,28 @@ func TestLoadBasic(t *testing.T) {
 	assert.Equal(t, database.DefaultPostgresMaxIdleConns, cfg.DBMaxIdleConns)
 	assert.Equal(t, database.DefaultPostgresConnMaxLifetime, cfg.DBConnMaxLifetime)
 	assert.Equal(t, database.DefaultPostgresConnMaxIdleTime, cfg.DBConnMaxIdleTime)
+	assert.Equal(t, 90*24*time.Hour, cfg.AuditLogRetention)
+	assert.Equal(t, 30*24*time.Hour, cfg.WebhookDeliveryRetention)
+	assert.Equal(t, 30*24*time.Hour, cfg.FileJournalRetention)
+	assert.Empty(t, cfg.EmbedTokenSecret)
+	assert.Equal(t, 30*24*time.Hour, cfg.SessionExpiration)
+	assert.Zero(t, cfg.RateLimitRequests)
+	assert.Zero(t, cfg.RateLimitBandwidth)
+	assert.Equal(t, "api,static", cfg.Compression)
+	assert.False(t, cfg.TLSEnabled())
+	assert.Equal(t, "./acme-certs", cfg.TLSACMECacheDir)
+	assert.Equal(t, 80, cfg.TLSHTTPPort)
+	assert.Empty(t, cfg.OTelExporterOTLPEndpoint)
+	assert.Equal(t, "imagor-studio", cfg.OTelServiceName)
+	assert.Equal(t, 1.0, cfg.OTelTracesSampleRatio)
+	assert.Empty(t, cfg.LibraryScanSchedule)
+	assert.Equal(t, int64(200<<20), cfg.URLImportMaxBytes)
+	assert.Equal(t, 2*time.Minute, cfg.URLImportTimeout)
+	assert.False(t, cfg.URLImportAllowPrivateNetworks)
+	assert.Empty(t, cfg.LabelHookURL)
+	assert.Empty(t, cfg.LabelHookImageBaseURL)
+	assert.Empty(t, cfg.FaceDetector)
+	assert.Equal(t, 0.5, cfg.FaceMatchThreshold)
 }
 
 func TestLoadWithDBPoolEnvVars(t *testing.T) {
@@ -215,6 +237,8 @@ func TestValidateStorageConfig(t *testing.T) {
 		name                    string
 		storageType             string
 		s3Bucket                string
+		sftpHost                string
+		sftpUser                string
 		httpMaxIdleConnsPerHost int
 		expectError             bool
 	}{
@@ -246,6 +270,25 @@ func TestValidateStorageConfig(t *testing.T) {
 			httpMaxIdleConnsPerHost: 0,
 			expectError:             true,
 		},
+		{
+			name:        "valid sftp storage",
+			storageType: "sftp",
+			sftpHost:    "sftp.example.com",
+			sftpUser:    "imagor",
+			expectError: false,
+		},
+		{
+			name:        "invalid sftp storage - missing host",
+			storageType: "sftp",
+			sftpUser:    "imagor",
+			expectError: true,
+		},
+		{
+			name:        "invalid sftp storage - missing user",
+			storageType: "sftp",
+			sftpHost:    "sftp.example.com",
+			expectError: true,
+		},
 		{
 			name:                    "invalid negative s3 http max idle conns per host",
 			storageType:             "file",
@@ -267,6 +310,8 @@ func TestValidateStorageConfig(t *testing.T) {
 			cfg := &Config{
 				StorageType:               tt.storageType,
 				S3StorageBucket:           tt.s3Bucket,
+				SFTPStorageHost:           tt.sftpHost,
+				SFTPStorageUser:           tt.sftpUser,
 				S3HTTPMaxIdleConnsPerHost: tt.httpMaxIdleConnsPerHost,
 			}
 
@@ -435,6 +480,66 @@ func TestConfigWithVIPSCacheSize(t *testing.T) {
 	assert.Equal(t, int64(536870912), cfg.ImagorCacheSizeBytes)
 }
 
+func TestConfigWithImagorResultStorage(t *testing.T) {
+	cfg, err := Load([]string{
+		"--imagor-result-storage", "s3",
+		"--s3-storage-bucket", "photos",
+		"--imagor-result-storage-base-dir", "thumbnails",
+		"--imagor-result-storage-expiration", "720h",
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "s3", cfg.ImagorResultStorage)
+	assert.Equal(t, "thumbnails", cfg.ImagorResultStorageBaseDir)
+	assert.Empty(t, cfg.ImagorResultStorageBucket, "the storage bucket is used")
+	assert.Equal(t, 720*time.Hour, cfg.ImagorResultStorageExpiration)
+}
+
+func TestConfigWithUploadDedupe(t *testing.T) {
+	cfg, err := Load([]string{"--port", "8080"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "allow", cfg.UploadDedupe)
+
+	cfg, err = Load([]string{"--upload-dedupe", "link"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "link", cfg.UploadDedupe)
+}
+
+func TestConfigWithUploadCollision(t *testing.T) {
+	cfg, err := Load([]string{"--port", "8080"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "overwrite", cfg.UploadCollision)
+
+	cfg, err = Load([]string{"--upload-collision", "rename"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "rename", cfg.UploadCollision)
+}
+
+func TestConfigWithUploadScanner(t *testing.T) {
+	cfg, err := Load([]string{"--port", "8080"}, nil)
+	require.NoError(t, err)
+	assert.Empty(t, cfg.UploadScanner)
+
+	cfg, err = Load([]string{
+		"--upload-scanner", "clamd",
+		"--upload-scanner-url", "unix:///run/clamav/clamd.ctl",
+		"--upload-quarantine-dir", "/var/lib/imagor-studio/quarantine",
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "clamd", cfg.UploadScanner)
+	assert.Equal(t, "unix:///run/clamav/clamd.ctl", cfg.UploadScannerURL)
+	assert.Equal(t, "/var/lib/imagor-studio/quarantine", cfg.UploadQuarantineDir)
+}
+
+func TestConfigWithUploadAllowedExtensions(t *testing.T) {
+	cfg, err := Load([]string{"--port", "8080"}, nil)
+	require.NoError(t, err)
+	assert.Empty(t, cfg.UploadAllowedExtensions)
+
+	cfg, err = Load([]string{"--upload-allowed-extensions", ".jpg,.png,.mp4"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, ".jpg,.png,.mp4", cfg.UploadAllowedExtensions)
+}
+
 func TestConfigUsesDefaultImagorCacheSize(t *testing.T) {
 	cfg, err := Load([]string{"--port", "8080"}, nil)
 	require.NoError(t, err)
@@ -558,6 +663,131 @@ func TestConfigInvalidValues(t *testing.T) {
 			args:          []string{"--file-storage-write-permissions", "invalid", "--jwt-secret", "test"},
 			errorContains: "invalid file-storage-write-permissions",
 		},
+		{
+			name:          "negative audit log retention",
+			args:          []string{"--audit-log-retention", "-1h", "--jwt-secret", "test"},
+			errorContains: "audit-log-retention must not be negative",
+		},
+		{
+			name:          "negative webhook delivery retention",
+			args:          []string{"--webhook-delivery-retention", "-1h", "--jwt-secret", "test"},
+			errorContains: "webhook-delivery-retention must not be negative",
+		},
+		{
+			name:          "zero session expiration",
+			args:          []string{"--session-expiration", "0s", "--jwt-secret", "test"},
+			errorContains: "session-expiration must be greater than 0",
+		},
+		{
+			name:          "tls cert without key",
+			args:          []string{"--tls-cert-file", "cert.pem", "--jwt-secret", "test"},
+			errorContains: "tls-cert-file and tls-key-file must be set together",
+		},
+		{
+			name:          "tls cert with acme",
+			args:          []string{"--tls-cert-file", "cert.pem", "--tls-key-file", "key.pem", "--tls-acme-domains", "example.com", "--jwt-secret", "test"},
+			errorContains: "tls-cert-file and tls-acme-domains cannot be set together",
+		},
+		{
+			name:          "negative rate limit",
+			args:          []string{"--rate-limit-requests", "-1", "--jwt-secret", "test"},
+			errorContains: "rate-limit-requests must not be negative",
+		},
+		{
+			name:          "unknown compression group",
+			args:          []string{"--compression", "api,thumbnails", "--jwt-secret", "test"},
+			errorContains: "unknown compression route group: thumbnails",
+		},
+		{
+			name:          "invalid S3 gateway bucket",
+			args:          []string{"--s3-gateway-enabled", "--s3-gateway-bucket", "My_Photos", "--jwt-secret", "test"},
+			errorContains: "invalid s3-gateway-bucket",
+		},
+		{
+			name:          "gRPC port taken by the HTTP port",
+			args:          []string{"--port", "9000", "--grpc-port", "9000", "--jwt-secret", "test"},
+			errorContains: "grpc-port must differ from port",
+		},
+		{
+			name:          "trace sample ratio above 1",
+			args:          []string{"--otel-traces-sample-ratio", "1.5", "--jwt-secret", "test"},
+			errorContains: "otel-traces-sample-ratio must be between 0 and 1",
+		},
+		{
+			name:          "invalid library scan schedule",
+			args:          []string{"--library-scan-schedule", "0 25 * * *", "--jwt-secret", "test"},
+			errorContains: "library-scan-schedule: hour",
+		},
+		{
+			name:          "zero url import size",
+			args:          []string{"--url-import-max-bytes", "0", "--jwt-secret", "test"},
+			errorContains: "url-import-max-bytes must be greater than 0",
+		},
+		{
+			name:          "relative label hook url",
+			args:          []string{"--label-hook-url", "/hook", "--jwt-secret", "test"},
+			errorContains: "label-hook-url must be an absolute http or https URL",
+		},
+		{
+			name:          "label hook without image base url",
+			args:          []string{"--label-hook-url", "http://tagger:8080/hook", "--jwt-secret", "test"},
+			errorContains: "label-hook-image-base-url or app-url is required",
+		},
+		{
+			name:          "unknown face detector",
+			args:          []string{"--face-detector", "magic", "--jwt-secret", "test"},
+			errorContains: "face-detector must be one of http",
+		},
+		{
+			name:          "http face detector without url",
+			args:          []string{"--face-detector", "http", "--jwt-secret", "test"},
+			errorContains: "face-detector-url must be an absolute http or https URL",
+		},
+		{
+			name:          "unknown upload dedupe mode",
+			args:          []string{"--upload-dedupe", "skip", "--jwt-secret", "test"},
+			errorContains: "unsupported upload-dedupe: skip",
+		},
+		{
+			name:          "unknown upload collision policy",
+			args:          []string{"--upload-collision", "skip", "--jwt-secret", "test"},
+			errorContains: "unsupported upload-collision: skip",
+		},
+		{
+			name:          "unknown upload scanner",
+			args:          []string{"--upload-scanner", "magic", "--jwt-secret", "test"},
+			errorContains: "upload-scanner must be one of clamd, http",
+		},
+		{
+			name:          "clamd upload scanner without port",
+			args:          []string{"--upload-scanner", "clamd", "--upload-scanner-url", "tcp://clamav", "--jwt-secret", "test"},
+			errorContains: "upload-scanner-url: clamd URL must include a port",
+		},
+		{
+			name:          "http upload scanner without url",
+			args:          []string{"--upload-scanner", "http", "--jwt-secret", "test"},
+			errorContains: "upload-scanner-url: upload scanner URL must be an absolute http(s) URL",
+		},
+		{
+			name:          "unknown imagor result storage",
+			args:          []string{"--imagor-result-storage", "ftp", "--jwt-secret", "test"},
+			errorContains: "unsupported imagor-result-storage: ftp",
+		},
+		{
+			name:          "file imagor result storage without base dir",
+			args:          []string{"--imagor-result-storage", "file", "--jwt-secret", "test"},
+			errorContains: "imagor-result-storage-base-dir is required",
+		},
+		{
+			name:          "s3 imagor result storage without bucket",
+			args:          []string{"--imagor-result-storage", "s3", "--jwt-secret", "test"},
+			errorContains: "imagor-result-storage-bucket is required",
+		},
+		{
+			name:          "face match threshold out of range",
+			args:          []string{"--face-match-threshold", "1.5", "--jwt-secret", "test"},
+			errorContains: "face-match-threshold must be greater than 0",
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,345 @@
+package config
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/peterbourgon/ff/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// configFileEntry is a value of a structured config file. Nested keys are
+// kept as their path, e.g. storage.type
+type configFileEntry struct {
+	key   []string
+	value string
+	line  int
+}
+
+// isStructuredConfigFile reports whether the config file is YAML or TOML
+// rather than a .env file
+func isStructuredConfigFile(file string) bool {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml", ".toml":
+		return true
+	}
+	return false
+}
+
+// configFileParser parses the file of the config flag by its extension,
+// YAML or TOML files, or .env files otherwise. Keys of structured files
+// take the flag name, registry key or env var form, nested keys are joined,
+// so storage_type, storage-type and storage: {type: ...} are the same.
+// Unlike .env files, unknown keys are rejected.
+func configFileParser(fs *flag.FlagSet) ff.ConfigFileParser {
+	return func(r io.Reader, set func(name, value string) error) error {
+		file := fs.Lookup("config").Value.String()
+		var entries []configFileEntry
+		var err error
+		switch strings.ToLower(filepath.Ext(file)) {
+		case ".yaml", ".yml":
+			entries, err = parseYAMLConfig(r)
+		case ".toml":
+			entries, err = parseTOMLConfig(r)
+		default:
+			return ff.EnvParser(r, set)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		for _, entry := range entries {
+			key := strings.Join(entry.key, ".")
+			name := flagNameForConfigKey(entry.key)
+			if fs.Lookup(name) == nil {
+				return fmt.Errorf("%s:%d: unknown key %q%s", file, entry.line, key, suggestFlag(fs, name))
+			}
+			if err := set(name, entry.value); err != nil {
+				return fmt.Errorf("%s:%d: %w", file, entry.line, err)
+			}
+		}
+		return nil
+	}
+}
+
+// checkConfigFile fails for missing YAML and TOML config files, which are
+// always given explicitly, unlike the optional default .env file
+func checkConfigFile(fs *flag.FlagSet) error {
+	file := fs.Lookup("config").Value.String()
+	if !isStructuredConfigFile(file) {
+		return nil
+	}
+	if _, err := os.Stat(file); err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+	return nil
+}
+
+// flagNameForConfigKey maps a config file key to its flag name, e.g.
+// config.storage_type, STORAGE_TYPE and storage.type to storage-type
+func flagNameForConfigKey(key []string) string {
+	name := GetFlagNameForRegistryKey(strings.Join(key, "."))
+	return strings.ReplaceAll(name, ".", "-")
+}
+
+// suggestFlag returns a hint naming the flag closest to an unknown one
+func suggestFlag(fs *flag.FlagSet, name string) string {
+	best, bestDistance := "", 3
+	fs.VisitAll(func(f *flag.Flag) {
+		if d := editDistance(name, f.Name); d < bestDistance {
+			best, bestDistance = f.Name, d
+		}
+	})
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (did you mean %q?)", best)
+}
+
+// editDistance is the Levenshtein distance of a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// parseYAMLConfig flattens a YAML mapping into config entries. Lists
+// become comma-separated values and null values are skipped.
+func parseYAMLConfig(r io.Reader) ([]configFileEntry, error) {
+	var doc yaml.Node
+	if err := yaml.NewDecoder(r).Decode(&doc); err != nil {
+		if err == io.EOF {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("line %d: expected a mapping of config keys", root.Line)
+	}
+	var entries []configFileEntry
+	return entries, walkYAMLConfig(root, nil, &entries)
+}
+
+func walkYAMLConfig(node *yaml.Node, prefix []string, entries *[]configFileEntry) error {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], node.Content[i+1]
+		if valueNode.Kind == yaml.AliasNode {
+			valueNode = valueNode.Alias
+		}
+		key := append(append([]string{}, prefix...), keyNode.Value)
+		switch valueNode.Kind {
+		case yaml.MappingNode:
+			if err := walkYAMLConfig(valueNode, key, entries); err != nil {
+				return err
+			}
+		case yaml.SequenceNode:
+			values := make([]string, 0, len(valueNode.Content))
+			for _, item := range valueNode.Content {
+				if item.Kind != yaml.ScalarNode {
+					return fmt.Errorf("line %d: lists of %s may only hold plain values", item.Line, strings.Join(key, "."))
+				}
+				values = append(values, item.Value)
+			}
+			*entries = append(*entries, configFileEntry{key: key, value: strings.Join(values, ","), line: keyNode.Line})
+		case yaml.ScalarNode:
+			if valueNode.Tag == "!!null" {
+				continue
+			}
+			*entries = append(*entries, configFileEntry{key: key, value: valueNode.Value, line: keyNode.Line})
+		}
+	}
+	return nil
+}
+
+var (
+	tomlBareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	tomlNumber  = regexp.MustCompile(`^[+-]?([0-9_]+(\.[0-9_]+)?([eE][+-]?[0-9_]+)?|inf|nan)$`)
+)
+
+// parseTOMLConfig parses the TOML subset config files need: key/value
+// pairs, dotted keys, [table] headers, strings, numbers, booleans and
+// single line arrays. Multi-line strings, inline tables and arrays of tables
+// are rejected.
+func parseTOMLConfig(r io.Reader) ([]configFileEntry, error) {
+	var entries []configFileEntry
+	var table []string
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || text[0] == '#' {
+			continue
+		}
+		if strings.HasPrefix(text, "[[") {
+			return nil, fmt.Errorf("line %d: arrays of tables are not supported", line)
+		}
+		if text[0] == '[' {
+			end := strings.IndexByte(text, ']')
+			if end < 0 || !isTOMLLineEnd(text[end+1:]) {
+				return nil, fmt.Errorf("line %d: invalid table header", line)
+			}
+			key, err := parseTOMLKey(text[1:end])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			table = key
+			continue
+		}
+		eq := strings.IndexByte(text, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("line %d: expected key = value", line)
+		}
+		key, err := parseTOMLKey(text[:eq])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		value, rest, err := parseTOMLValue(strings.TrimSpace(text[eq+1:]), false)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if !isTOMLLineEnd(rest) {
+			return nil, fmt.Errorf("line %d: unexpected %q after value", line, strings.TrimSpace(rest))
+		}
+		entries = append(entries, configFileEntry{
+			key:   append(append([]string{}, table...), key...),
+			value: value,
+			line:  line,
+		})
+	}
+	return entries, scanner.Err()
+}
+
+// isTOMLLineEnd reports whether s holds nothing but a comment
+func isTOMLLineEnd(s string) bool {
+	s = strings.TrimSpace(s)
+	return s == "" || s[0] == '#'
+}
+
+// parseTOMLKey splits a dotted key, unquoting quoted parts
+func parseTOMLKey(s string) ([]string, error) {
+	var key []string
+	s = strings.TrimSpace(s)
+	for s != "" {
+		var part string
+		switch s[0] {
+		case '"', '\'':
+			value, rest, err := parseTOMLValue(s, true)
+			if err != nil {
+				return nil, err
+			}
+			part, s = value, strings.TrimSpace(rest)
+		default:
+			end := strings.IndexByte(s, '.')
+			if end < 0 {
+				end = len(s)
+			}
+			part, s = strings.TrimSpace(s[:end]), s[end:]
+			if !tomlBareKey.MatchString(part) {
+				return nil, fmt.Errorf("invalid key %q", part)
+			}
+		}
+		key = append(key, part)
+		if s != "" {
+			if s[0] != '.' {
+				return nil, fmt.Errorf("invalid key %q", s)
+			}
+			s = strings.TrimSpace(s[1:])
+		}
+	}
+	if len(key) == 0 {
+		return nil, fmt.Errorf("missing key")
+	}
+	return key, nil
+}
+
+// parseTOMLValue parses the value at the start of s, returning it as the
+// flag value with the rest of s. Arrays become comma-separated values.
+func parseTOMLValue(s string, inArray bool) (string, string, error) {
+	if s == "" {
+		return "", "", fmt.Errorf("missing value")
+	}
+	if strings.HasPrefix(s, `"""`) || strings.HasPrefix(s, "'''") {
+		return "", "", fmt.Errorf("multi-line strings are not supported")
+	}
+	switch s[0] {
+	case '"':
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				i++
+			case '"':
+				value, err := strconv.Unquote(s[:i+1])
+				if err != nil {
+					return "", "", fmt.Errorf("invalid string %s", s[:i+1])
+				}
+				return value, s[i+1:], nil
+			}
+		}
+		return "", "", fmt.Errorf("unterminated string")
+	case '\'':
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated string")
+		}
+		return s[1 : end+1], s[end+2:], nil
+	case '[':
+		if inArray {
+			return "", "", fmt.Errorf("nested arrays are not supported")
+		}
+		var values []string
+		rest := strings.TrimSpace(s[1:])
+		for {
+			if rest == "" {
+				return "", "", fmt.Errorf("arrays must close on the line they start")
+			}
+			if rest[0] == ']' {
+				return strings.Join(values, ","), rest[1:], nil
+			}
+			value, next, err := parseTOMLValue(rest, true)
+			if err != nil {
+				return "", "", err
+			}
+			values = append(values, value)
+			rest = strings.TrimSpace(next)
+			if strings.HasPrefix(rest, ",") {
+				rest = strings.TrimSpace(rest[1:])
+			} else if !strings.HasPrefix(rest, "]") {
+				return "", "", fmt.Errorf("expected , or ] in array")
+			}
+		}
+	case '{':
+		return "", "", fmt.Errorf("inline tables are not supported")
+	}
+	end := strings.IndexAny(s, " \t,]#")
+	if end < 0 {
+		end = len(s)
+	}
+	token := s[:end]
+	if token != "true" && token != "false" && !tomlNumber.MatchString(token) {
+		return "", "", fmt.Errorf("invalid value %s, strings must be quoted", token)
+	}
+	return strings.ReplaceAll(token, "_", ""), s[end:], nil
+}
@@ -0,0 +1,141 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(file, []byte(content), 0o644))
+	return file
+}
+
+func TestLoadYAMLConfigFile(t *testing.T) {
+	file := writeConfigFile(t, "config.yaml", `
+port: 9000
+jwt_secret: test-secret
+allow-guest-mode: true
+# Nested keys are joined with the parent
+jwt:
+  expiration: 24h
+config.storage_type: file
+file:
+  storage_base_dir: /srv/gallery
+cors_origins:
+  - https://a.example.com
+  - https://b.example.com
+app_title: ~
+`)
+	cfg, err := Load([]string{"--config", file}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 9000, cfg.Port)
+	assert.Equal(t, "test-secret", cfg.JWTSecret)
+	assert.True(t, cfg.AllowGuestMode)
+	assert.Equal(t, 24*time.Hour, cfg.JWTExpiration)
+	assert.Equal(t, "file", cfg.StorageType)
+	assert.Equal(t, "/srv/gallery", cfg.FileStorageBaseDir)
+	assert.Equal(t, "https://a.example.com,https://b.example.com", cfg.CORSOrigins)
+
+	// Config file values count as set, so registry values do not apply
+	value, isSet := cfg.GetByRegistryKey("config.port")
+	assert.Equal(t, "9000", value)
+	assert.True(t, isSet)
+}
+
+func TestLoadTOMLConfigFile(t *testing.T) {
+	file := writeConfigFile(t, "config.toml", `
+# imagor-studio
+port = 9_000
+jwt_secret = "test-secret" # inline comment
+allow_guest_mode = true
+cors_origins = ["https://a.example.com", 'https://b.example.com']
+
+[jwt]
+expiration = "24h"
+
+[file]
+storage-base-dir = 'C:\gallery'
+`)
+	cfg, err := Load([]string{"--config", file}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 9000, cfg.Port)
+	assert.Equal(t, "test-secret", cfg.JWTSecret)
+	assert.True(t, cfg.AllowGuestMode)
+	assert.Equal(t, "https://a.example.com,https://b.example.com", cfg.CORSOrigins)
+	assert.Equal(t, 24*time.Hour, cfg.JWTExpiration)
+	assert.Equal(t, `C:\gallery`, cfg.FileStorageBaseDir)
+}
+
+func TestConfigFilePrecedence(t *testing.T) {
+	file := writeConfigFile(t, "config.yml", "port: 9000\njwt_secret: file-secret\n")
+	t.Setenv("JWT_SECRET", "env-secret")
+
+	cfg, err := Load([]string{"--config", file, "--port", "9001"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 9001, cfg.Port, "flags take precedence over the config file")
+	assert.Equal(t, "env-secret", cfg.JWTSecret, "env vars take precedence over the config file")
+}
+
+func TestConfigFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		err     string
+	}{
+		{
+			name:    "unknown yaml key",
+			file:    "config.yaml",
+			content: "port: 9000\nstorage_typ: file\n",
+			err:     `config.yaml:2: unknown key "storage_typ" (did you mean "storage-type"?)`,
+		},
+		{
+			name:    "unknown toml key",
+			file:    "config.toml",
+			content: "[aws]\nregion = \"a\"\nflavour = \"b\"\n",
+			err:     `config.toml:3: unknown key "aws.flavour"`,
+		},
+		{
+			name:    "invalid value",
+			file:    "config.yaml",
+			content: "port: 9000\nallow_guest_mode: maybe\n",
+			err:     "config.yaml:2:",
+		},
+		{
+			name:    "unquoted toml string",
+			file:    "config.toml",
+			content: "jwt_expiration = 24h\n",
+			err:     "config.toml: line 1: invalid value 24h, strings must be quoted",
+		},
+		{
+			name:    "toml inline table",
+			file:    "config.toml",
+			content: "s3 = { bucket = \"a\" }\n",
+			err:     "inline tables are not supported",
+		},
+		{
+			name:    "yaml list",
+			file:    "config.yaml",
+			content: "- port\n",
+			err:     "expected a mapping of config keys",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := writeConfigFile(t, tt.file, tt.content)
+			_, err := Load([]string{"--config", file}, nil)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+
+	_, err := Load([]string{"--config", filepath.Join(t.TempDir(), "missing.yaml")}, nil)
+	assert.ErrorContains(t, err, "config file")
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/secrets"
+)
+
+// secretResolver is shared by every Load, so each secret is fetched once per
+// TTL however often the config is loaded
+var secretResolver = secrets.NewResolver(secrets.Options{TTL: secrets.DefaultTTL})
+
+// resolveSecretReferences replaces the values of set flags that reference a
+// secret with the secret, returning the references by flag name. Registry
+// values are taken as they are.
+func resolveSecretReferences(fs *flag.FlagSet, ttl time.Duration) (map[string]string, error) {
+	ctx := context.Background()
+	lookup := func(name string) string {
+		if f := fs.Lookup(name); f != nil {
+			return f.Value.String()
+		}
+		return ""
+	}
+	opts := secrets.Options{
+		TTL:            ttl,
+		VaultAddr:      lookup("vault-addr"),
+		VaultNamespace: lookup("vault-namespace"),
+	}
+	refs := make(map[string]string)
+
+	// The Vault token is needed for vault:// references, but may itself be a
+	// Docker secret
+	token := lookup("vault-token")
+	if secrets.IsReference(token) {
+		if strings.HasPrefix(token, "vault://") {
+			return nil, fmt.Errorf("vault-token can not reference a Vault secret")
+		}
+		secretResolver.SetOptions(opts)
+		resolved, err := secretResolver.Resolve(ctx, token)
+		if err != nil {
+			return nil, fmt.Errorf("vault-token: %w", err)
+		}
+		refs["vault-token"] = token
+		token = resolved
+		if err := fs.Set("vault-token", token); err != nil {
+			return nil, err
+		}
+	}
+	opts.VaultToken = token
+	secretResolver.SetOptions(opts)
+
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		value := f.Value.String()
+		if err != nil || f.Name == "vault-token" || !secrets.IsReference(value) {
+			return
+		}
+		var resolved string
+		if resolved, err = secretResolver.Resolve(ctx, value); err != nil {
+			err = fmt.Errorf("%s: %w", f.Name, err)
+			return
+		}
+		if err = f.Value.Set(resolved); err != nil {
+			err = fmt.Errorf("%s: %w", f.Name, err)
+			return
+		}
+		refs[f.Name] = value
+	})
+	if err != nil {
+		return nil, err
+	}
+	return refs, nil
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadSecretReferences(t *testing.T) {
+	dir := t.TempDir()
+	jwtFile := filepath.Join(dir, "jwt_secret")
+	tokenFile := filepath.Join(dir, "vault_token")
+	require.NoError(t, os.WriteFile(jwtFile, []byte("file-secret\n"), 0o600))
+	require.NoError(t, os.WriteFile(tokenFile, []byte("root\n"), 0o600))
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data": {"data": {"secret_access_key": "vault-secret"}, "metadata": {}}}`))
+	}))
+	defer vault.Close()
+
+	t.Setenv("JWT_SECRET", "file://"+jwtFile)
+	cfg, err := Load([]string{
+		"--vault-addr", vault.URL,
+		"--vault-token", "file://" + tokenFile,
+		"--aws-secret-access-key", "vault://secret/data/imagor-studio#secret_access_key",
+		"--secrets-cache-ttl", "1ns",
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "file-secret", cfg.JWTSecret)
+	assert.Equal(t, "root", cfg.VaultToken)
+	assert.Equal(t, "vault-secret", cfg.AWSSecretAccessKey)
+
+	// Runtime reads pick up rotated secrets once the cached value expired
+	require.NoError(t, os.WriteFile(jwtFile, []byte("rotated-secret\n"), 0o600))
+	value, isSet := cfg.GetByRegistryKey("config.jwt_secret")
+	assert.True(t, isSet)
+	assert.Equal(t, "rotated-secret", value)
+}
+
+func TestLoadSecretReferenceErrors(t *testing.T) {
+	_, err := Load([]string{"--jwt-secret", "file://" + filepath.Join(t.TempDir(), "missing")}, nil)
+	assert.ErrorContains(t, err, "jwt-secret")
+
+	_, err = Load([]string{"--vault-token", "vault://secret/data/token#value"}, nil)
+	assert.ErrorContains(t, err, "vault-token can not reference a Vault secret")
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"flag"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Sources of effective config values, by precedence
+const (
+	ValueSourceCLI      = "cli"
+	ValueSourceEnv      = "env"
+	ValueSourceFile     = "file"
+	ValueSourceRegistry = "registry"
+	ValueSourceDefault  = "default"
+)
+
+// Value is the effective value of a config flag
+type Value struct {
+	Name string
+	// Value is masked for secrets and encrypted registry values
+	Value  string
+	Masked bool
+	Source string
+	// Default is the value used when the flag is set nowhere
+	Default string
+}
+
+// Values returns the effective value of every config flag, sorted by name,
+// with secrets masked
+func (c *Config) Values() []Value {
+	if c.flagSet == nil {
+		return nil
+	}
+	var values []Value
+	c.flagSet.VisitAll(func(f *flag.Flag) {
+		source, ok := c.flagSources[f.Name]
+		if !ok {
+			source = ValueSourceDefault
+		}
+		value := MaskValue(f.Name, f.Value.String())
+		if c.encryptedFlags[f.Name] && value != "" {
+			value = maskedValue
+		}
+		values = append(values, Value{
+			Name:    f.Name,
+			Value:   value,
+			Masked:  value != f.Value.String(),
+			Source:  source,
+			Default: MaskValue(f.Name, f.DefValue),
+		})
+	})
+	return values
+}
+
+// flagSources tells where each flag was set: on the command line, by env
+// var, by the config file or from the registry
+func flagSources(fs *flag.FlagSet, args []string, overridden map[string]string, registryFlags map[string]bool) map[string]string {
+	cli := make(map[string]bool)
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		name, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		cli[name] = true
+	}
+	sources := make(map[string]string)
+	for name := range overridden {
+		switch {
+		case cli[name]:
+			sources[name] = ValueSourceCLI
+		case os.Getenv(envVarForFlag(name)) != "":
+			sources[name] = ValueSourceEnv
+		default:
+			sources[name] = ValueSourceFile
+		}
+	}
+	for name := range registryFlags {
+		sources[name] = ValueSourceRegistry
+	}
+	return sources
+}
+
+// envVarForFlag returns the env var of a flag as ff maps them, e.g.
+// STORAGE_TYPE for storage-type
+func envVarForFlag(name string) string {
+	return strings.NewReplacer("-", "_", ".", "_", "/", "_").Replace(strings.ToUpper(name))
+}
+
+const maskedValue = "********"
+
+// isSecretFlag reports whether a flag holds a credential
+func isSecretFlag(name string) bool {
+	if name == "otel-exporter-otlp-headers" {
+		return true
+	}
+	for _, word := range []string{"secret", "password", "passphrase", "token", "private-key", "license-key"} {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// MaskValue hides the value of secret flags, and the password of URLs such
+// as database-url
+func MaskValue(name, value string) string {
+	if value == "" {
+		return ""
+	}
+	if isSecretFlag(name) {
+		return maskedValue
+	}
+	if strings.HasSuffix(name, "-url") {
+		if u, err := url.Parse(value); err == nil && u.User != nil {
+			return u.Redacted()
+		}
+	}
+	return value
+}
@@ -0,0 +1,159 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// DefaultSQLiteMaintenanceInterval is how often the scheduled SQLite
+// maintenance job runs
+const DefaultSQLiteMaintenanceInterval = 24 * time.Hour
+
+// SQLite auto_vacuum modes
+const (
+	sqliteAutoVacuumNone        = 0
+	sqliteAutoVacuumIncremental = 2
+)
+
+// maxIntegrityErrors caps how many integrity_check problems are reported
+const maxIntegrityErrors = 100
+
+// MaintenanceStep identifies a step of the SQLite maintenance job
+type MaintenanceStep string
+
+const (
+	StepCheckpoint MaintenanceStep = "checkpoint"
+	StepVacuum     MaintenanceStep = "vacuum"
+	StepIntegrity  MaintenanceStep = "integrity_check"
+)
+
+// MaintenanceSteps lists the steps in the order they run
+var MaintenanceSteps = []MaintenanceStep{StepCheckpoint, StepVacuum, StepIntegrity}
+
+// MaintenanceReport summarises a SQLite maintenance run
+type MaintenanceReport struct {
+	// CheckpointBusy is set when readers prevented a full WAL checkpoint
+	CheckpointBusy     bool
+	WALFrames          int
+	CheckpointedFrames int
+
+	PageSize        int
+	FreePagesBefore int
+	FreePagesAfter  int
+	// ConvertedToIncremental is set when the database was switched to
+	// auto_vacuum=INCREMENTAL, which requires a one-off full VACUUM
+	ConvertedToIncremental bool
+
+	IntegrityOK     bool
+	IntegrityErrors []string
+}
+
+// ReclaimedBytes returns the space returned to the filesystem by vacuuming
+func (r *MaintenanceReport) ReclaimedBytes() int64 {
+	if r.FreePagesBefore <= r.FreePagesAfter {
+		return 0
+	}
+	return int64(r.FreePagesBefore-r.FreePagesAfter) * int64(r.PageSize)
+}
+
+// IsSQLite reports whether db uses the SQLite dialect
+func IsSQLite(db *bun.DB) bool {
+	return db != nil && db.Dialect().Name() == dialect.SQLite
+}
+
+// MaintainSQLite checkpoints and truncates the WAL, reclaims free pages with
+// an incremental vacuum and runs integrity_check. onStep, if not nil, is
+// called after each completed step. Context cancellation is honoured between
+// steps. Databases created without auto_vacuum are converted to incremental
+// mode on the first run that finds free pages.
+func MaintainSQLite(ctx context.Context, db *bun.DB, onStep func(step MaintenanceStep, report *MaintenanceReport)) (*MaintenanceReport, error) {
+	if !IsSQLite(db) {
+		return nil, fmt.Errorf("database maintenance is only supported for SQLite")
+	}
+	report := &MaintenanceReport{}
+	done := func(step MaintenanceStep) {
+		if onStep != nil {
+			onStep(step, report)
+		}
+	}
+
+	// wal_checkpoint returns (busy, log frames, checkpointed frames)
+	var busy int
+	if err := db.QueryRowContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)").
+		Scan(&busy, &report.WALFrames, &report.CheckpointedFrames); err != nil {
+		return report, fmt.Errorf("wal checkpoint failed: %w", err)
+	}
+	report.CheckpointBusy = busy != 0
+	done(StepCheckpoint)
+	if err := ctx.Err(); err != nil {
+		return report, err
+	}
+
+	if err := vacuumSQLite(ctx, db, report); err != nil {
+		return report, err
+	}
+	done(StepVacuum)
+	if err := ctx.Err(); err != nil {
+		return report, err
+	}
+
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("PRAGMA integrity_check(%d)", maxIntegrityErrors))
+	if err != nil {
+		return report, fmt.Errorf("integrity check failed: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return report, fmt.Errorf("integrity check failed: %w", err)
+		}
+		if line != "ok" {
+			report.IntegrityErrors = append(report.IntegrityErrors, line)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return report, fmt.Errorf("integrity check failed: %w", err)
+	}
+	report.IntegrityOK = len(report.IntegrityErrors) == 0
+	done(StepIntegrity)
+	return report, nil
+}
+
+func vacuumSQLite(ctx context.Context, db *bun.DB, report *MaintenanceReport) error {
+	var autoVacuum int
+	if err := db.QueryRowContext(ctx, "PRAGMA page_size").Scan(&report.PageSize); err != nil {
+		return fmt.Errorf("failed to read page size: %w", err)
+	}
+	if err := db.QueryRowContext(ctx, "PRAGMA freelist_count").Scan(&report.FreePagesBefore); err != nil {
+		return fmt.Errorf("failed to read freelist count: %w", err)
+	}
+	if err := db.QueryRowContext(ctx, "PRAGMA auto_vacuum").Scan(&autoVacuum); err != nil {
+		return fmt.Errorf("failed to read auto_vacuum mode: %w", err)
+	}
+
+	switch {
+	case autoVacuum == sqliteAutoVacuumIncremental:
+		if _, err := db.ExecContext(ctx, "PRAGMA incremental_vacuum"); err != nil {
+			return fmt.Errorf("incremental vacuum failed: %w", err)
+		}
+	case autoVacuum == sqliteAutoVacuumNone && report.FreePagesBefore > 0:
+		// Switching auto_vacuum mode only takes effect after a full VACUUM,
+		// later runs then reclaim pages incrementally
+		if _, err := db.ExecContext(ctx, "PRAGMA auto_vacuum = INCREMENTAL"); err != nil {
+			return fmt.Errorf("failed to enable incremental auto_vacuum: %w", err)
+		}
+		if _, err := db.ExecContext(ctx, "VACUUM"); err != nil {
+			return fmt.Errorf("vacuum failed: %w", err)
+		}
+		report.ConvertedToIncremental = true
+	}
+
+	if err := db.QueryRowContext(ctx, "PRAGMA freelist_count").Scan(&report.FreePagesAfter); err != nil {
+		return fmt.Errorf("failed to read freelist count: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaintainSQLite(t *testing.T) {
+	db, err := Connect("sqlite:" + filepath.Join(t.TempDir(), "maintenance.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	require.True(t, IsSQLite(db))
+
+	ctx := context.Background()
+	_, err = db.ExecContext(ctx, "CREATE TABLE blobs (id INTEGER PRIMARY KEY, data TEXT)")
+	require.NoError(t, err)
+	payload := strings.Repeat("x", 4096)
+	for i := 0; i < 200; i++ {
+		_, err = db.ExecContext(ctx, "INSERT INTO blobs (data) VALUES (?)", payload)
+		require.NoError(t, err)
+	}
+	_, err = db.ExecContext(ctx, "DELETE FROM blobs")
+	require.NoError(t, err)
+
+	var steps []MaintenanceStep
+	report, err := MaintainSQLite(ctx, db, func(step MaintenanceStep, _ *MaintenanceReport) {
+		steps = append(steps, step)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, MaintenanceSteps, steps)
+	assert.False(t, report.CheckpointBusy)
+	assert.Greater(t, report.FreePagesBefore, 0)
+	assert.Equal(t, 0, report.FreePagesAfter)
+	assert.True(t, report.ConvertedToIncremental)
+	assert.Greater(t, report.ReclaimedBytes(), int64(0))
+	assert.True(t, report.IntegrityOK)
+	assert.Empty(t, report.IntegrityErrors)
+
+	var autoVacuum int
+	require.NoError(t, db.QueryRowContext(ctx, "PRAGMA auto_vacuum").Scan(&autoVacuum))
+	assert.Equal(t, sqliteAutoVacuumIncremental, autoVacuum)
+
+	// Later runs reclaim pages incrementally without another full vacuum
+	for i := 0; i < 50; i++ {
+		_, err = db.ExecContext(ctx, "INSERT INTO blobs (data) VALUES (?)", payload)
+		require.NoError(t, err)
+	}
+	_, err = db.ExecContext(ctx, "DELETE FROM blobs")
+	require.NoError(t, err)
+
+	report, err = MaintainSQLite(ctx, db, nil)
+	require.NoError(t, err)
+	assert.False(t, report.ConvertedToIncremental)
+	assert.Equal(t, 0, report.FreePagesAfter)
+	assert.True(t, report.IntegrityOK)
+}
+
+func TestMaintainSQLite_Cancelled(t *testing.T) {
+	db, err := Connect("sqlite:" + filepath.Join(t.TempDir(), "cancelled.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err = MaintainSQLite(ctx, db, func(step MaintenanceStep, _ *MaintenanceReport) {
+		cancel()
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,26 @@
+package database
+
+import (
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// OnConflictUpdate makes an insert update the given columns of the row
+// already holding its unique key, conflict, instead of failing. The row is
+// written in a single statement, so concurrent writers cannot interleave
+// as they could between a delete and an insert. MySQL takes the key from
+// the unique index the row conflicts on.
+func OnConflictUpdate(q *bun.InsertQuery, conflict string, columns ...string) *bun.InsertQuery {
+	if q.DB().Dialect().Name() == dialect.MySQL {
+		q = q.On("DUPLICATE KEY UPDATE")
+		for _, column := range columns {
+			q = q.Set("? = VALUES(?)", bun.Ident(column), bun.Ident(column))
+		}
+		return q
+	}
+	q = q.On("CONFLICT (" + conflict + ") DO UPDATE")
+	for _, column := range columns {
+		q = q.Set("? = EXCLUDED.?", bun.Ident(column), bun.Ident(column))
+	}
+	return q
+}
@@ -0,0 +1,58 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/mysqldialect"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/driver/sqliteshim"
+)
+
+type upsertRow struct {
+	bun.BaseModel `bun:"table:upsert_rows"`
+
+	ID    string `bun:"id,pk"`
+	Key   string `bun:"key,notnull"`
+	Value string `bun:"value,notnull"`
+}
+
+func TestOnConflictUpdate(t *testing.T) {
+	ctx := context.Background()
+	db, err := Connect("sqlite::memory:")
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.NewCreateTable().Model((*upsertRow)(nil)).Exec(ctx)
+	require.NoError(t, err)
+	_, err = db.NewCreateIndex().Model((*upsertRow)(nil)).Index("idx_upsert_rows_key").Unique().Column("key").Exec(ctx)
+	require.NoError(t, err)
+
+	for _, row := range []*upsertRow{{ID: "1", Key: "a", Value: "first"}, {ID: "2", Key: "a", Value: "second"}} {
+		_, err := OnConflictUpdate(db.NewInsert().Model(row), "key", "value").Exec(ctx)
+		require.NoError(t, err)
+	}
+	var rows []upsertRow
+	require.NoError(t, db.NewSelect().Model(&rows).Scan(ctx))
+	require.Len(t, rows, 1)
+	assert.Equal(t, "1", rows[0].ID, "the existing row is updated in place")
+	assert.Equal(t, "second", rows[0].Value)
+}
+
+func TestOnConflictUpdate_Dialects(t *testing.T) {
+	sqldb, err := sql.Open(sqliteshim.ShimName, ":memory:")
+	require.NoError(t, err)
+	defer sqldb.Close()
+	row := &upsertRow{ID: "1", Key: "a", Value: "v"}
+
+	pg := bun.NewDB(sqldb, pgdialect.New())
+	assert.Contains(t, OnConflictUpdate(pg.NewInsert().Model(row), "key", "value").String(),
+		`ON CONFLICT (key) DO UPDATE SET "value" = EXCLUDED."value"`)
+
+	mysql := bun.NewDB(sqldb, mysqldialect.New())
+	assert.Contains(t, OnConflictUpdate(mysql.NewInsert().Model(row), "key", "value").String(),
+		"ON DUPLICATE KEY UPDATE `value` = VALUES(`value`)")
+}
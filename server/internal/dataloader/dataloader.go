@@ -0,0 +1,193 @@
+// Package dataloader batches and caches lookups made while resolving a
+// request.
+//
+// Resolvers building a list look up the same settings for every item, and
+// fields resolved concurrently look up different keys of the same table. A
+// Loader collects the keys loaded within a short window into a single batch
+// fetch and remembers the results, so a listing of a thousand files costs a
+// constant number of lookups instead of one per file. Loaders are meant to
+// live for one request: create them per operation and drop them with it,
+// the cache is never refreshed.
+package dataloader
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultWait is how long a batch collects keys before it is fetched
+const DefaultWait = time.Millisecond
+
+// BatchFunc fetches the values of keys. Keys missing from the result are
+// loaded as the zero value, an error fails every key of the batch.
+type BatchFunc[K comparable, V any] func(ctx context.Context, keys []K) (map[K]V, error)
+
+// Loader batches and caches the loads of values by key
+type Loader[K comparable, V any] struct {
+	fetch    BatchFunc[K, V]
+	wait     time.Duration
+	maxBatch int
+
+	mu    sync.Mutex
+	cache map[K]*result[V]
+	batch *batch[K, V]
+}
+
+type result[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+type batch[K comparable, V any] struct {
+	keys    []K
+	results []*result[V]
+	full    chan struct{}
+}
+
+// Option configures a Loader
+type Option func(*options)
+
+type options struct {
+	wait     time.Duration
+	maxBatch int
+}
+
+// WithWait sets how long a batch collects keys before it is fetched, zero
+// fetches each batch as soon as the goroutine filling it yields
+func WithWait(wait time.Duration) Option {
+	return func(o *options) {
+		o.wait = wait
+	}
+}
+
+// WithMaxBatch bounds the keys of a batch, a full batch is fetched without
+// waiting. Zero does not bound batches.
+func WithMaxBatch(maxBatch int) Option {
+	return func(o *options) {
+		o.maxBatch = maxBatch
+	}
+}
+
+// New creates a loader fetching batches with fetch
+func New[K comparable, V any](fetch BatchFunc[K, V], opts ...Option) *Loader[K, V] {
+	o := options{wait: DefaultWait}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Loader[K, V]{
+		fetch:    fetch,
+		wait:     o.wait,
+		maxBatch: o.maxBatch,
+		cache:    make(map[K]*result[V]),
+	}
+}
+
+// Load returns the value of key, fetching it with the other keys loaded
+// meanwhile unless an earlier load cached it
+func (l *Loader[K, V]) Load(ctx context.Context, key K) (V, error) {
+	r := l.enqueue(ctx, key)
+	select {
+	case <-r.done:
+		return r.value, r.err
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+// LoadMany returns the values of keys, fetched in as few batches as the
+// batch size allows
+func (l *Loader[K, V]) LoadMany(ctx context.Context, keys []K) (map[K]V, error) {
+	results := make([]*result[V], len(keys))
+	for i, key := range keys {
+		results[i] = l.enqueue(ctx, key)
+	}
+	values := make(map[K]V, len(keys))
+	for i, r := range results {
+		select {
+		case <-r.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if r.err != nil {
+			return nil, r.err
+		}
+		values[keys[i]] = r.value
+	}
+	return values, nil
+}
+
+// Prime caches the value of key, replacing a cached one
+func (l *Loader[K, V]) Prime(key K, value V) {
+	r := &result[V]{done: make(chan struct{}), value: value}
+	close(r.done)
+	l.mu.Lock()
+	l.cache[key] = r
+	l.mu.Unlock()
+}
+
+// Clear drops the cached value of key, the next load fetches it again
+func (l *Loader[K, V]) Clear(key K) {
+	l.mu.Lock()
+	delete(l.cache, key)
+	l.mu.Unlock()
+}
+
+// enqueue returns the cached result of key, or adds key to the pending batch
+func (l *Loader[K, V]) enqueue(ctx context.Context, key K) *result[V] {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if r, ok := l.cache[key]; ok {
+		return r
+	}
+	r := &result[V]{done: make(chan struct{})}
+	l.cache[key] = r
+	if l.batch == nil {
+		l.batch = &batch[K, V]{full: make(chan struct{})}
+		go l.run(ctx, l.batch)
+	}
+	b := l.batch
+	b.keys = append(b.keys, key)
+	b.results = append(b.results, r)
+	if l.maxBatch > 0 && len(b.keys) >= l.maxBatch {
+		l.batch = nil
+		close(b.full)
+	}
+	return r
+}
+
+// run fetches a batch once the wait is over or the batch is full
+func (l *Loader[K, V]) run(ctx context.Context, b *batch[K, V]) {
+	timer := time.NewTimer(l.wait)
+	select {
+	case <-timer.C:
+		l.mu.Lock()
+		if l.batch == b {
+			l.batch = nil
+		}
+		l.mu.Unlock()
+	case <-b.full:
+		timer.Stop()
+	}
+
+	// Loads of the request are canceled with it, the fetch of a batch
+	// shared by several of them is not
+	values, err := l.fetch(context.WithoutCancel(ctx), b.keys)
+	if err != nil {
+		// Failures are not cached, the next load tries again
+		l.mu.Lock()
+		for i, key := range b.keys {
+			if l.cache[key] == b.results[i] {
+				delete(l.cache, key)
+			}
+		}
+		l.mu.Unlock()
+	}
+	for i, key := range b.keys {
+		r := b.results[i]
+		r.value, r.err = values[key], err
+		close(r.done)
+	}
+}
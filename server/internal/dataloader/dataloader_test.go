@@ -0,0 +1,111 @@
+package dataloader
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoader_BatchesConcurrentLoads(t *testing.T) {
+	var fetches atomic.Int32
+	l := New(func(ctx context.Context, keys []int) (map[int]int, error) {
+		fetches.Add(1)
+		values := make(map[int]int, len(keys))
+		for _, k := range keys {
+			values[k] = k * 10
+		}
+		return values, nil
+	}, WithWait(10*time.Millisecond))
+
+	var wg sync.WaitGroup
+	for i := range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := l.Load(context.Background(), i%10)
+			assert.NoError(t, err)
+			assert.Equal(t, (i%10)*10, value)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), fetches.Load())
+
+	// Cached values are not fetched again
+	values, err := l.LoadMany(context.Background(), []int{1, 2, 3})
+	require.NoError(t, err)
+	assert.Equal(t, map[int]int{1: 10, 2: 20, 3: 30}, values)
+	assert.Equal(t, int32(1), fetches.Load())
+}
+
+func TestLoader_MaxBatch(t *testing.T) {
+	var mu sync.Mutex
+	var sizes []int
+	l := New(func(ctx context.Context, keys []int) (map[int]int, error) {
+		mu.Lock()
+		sizes = append(sizes, len(keys))
+		mu.Unlock()
+		return nil, nil
+	}, WithMaxBatch(2), WithWait(time.Hour))
+
+	values, err := l.LoadMany(context.Background(), []int{1, 2, 3, 4})
+	require.NoError(t, err)
+	assert.Equal(t, map[int]int{1: 0, 2: 0, 3: 0, 4: 0}, values)
+	assert.Equal(t, []int{2, 2}, sizes)
+}
+
+func TestLoader_ErrorsAreNotCached(t *testing.T) {
+	var fetches atomic.Int32
+	fail := errors.New("unavailable")
+	l := New(func(ctx context.Context, keys []string) (map[string]string, error) {
+		if fetches.Add(1) == 1 {
+			return nil, fail
+		}
+		return map[string]string{"a": "value"}, nil
+	})
+
+	_, err := l.Load(context.Background(), "a")
+	assert.ErrorIs(t, err, fail)
+	value, err := l.Load(context.Background(), "a")
+	require.NoError(t, err)
+	assert.Equal(t, "value", value)
+	assert.Equal(t, int32(2), fetches.Load())
+}
+
+func TestLoader_PrimeAndClear(t *testing.T) {
+	var fetches atomic.Int32
+	l := New(func(ctx context.Context, keys []string) (map[string]string, error) {
+		fetches.Add(1)
+		return map[string]string{"a": "fetched"}, nil
+	})
+
+	l.Prime("a", "primed")
+	value, err := l.Load(context.Background(), "a")
+	require.NoError(t, err)
+	assert.Equal(t, "primed", value)
+	assert.Zero(t, fetches.Load())
+
+	l.Clear("a")
+	value, err = l.Load(context.Background(), "a")
+	require.NoError(t, err)
+	assert.Equal(t, "fetched", value)
+}
+
+func TestLoader_CanceledLoad(t *testing.T) {
+	release := make(chan struct{})
+	l := New(func(ctx context.Context, keys []string) (map[string]string, error) {
+		<-release
+		return map[string]string{"a": "value"}, nil
+	})
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := l.Load(ctx, "a")
+	assert.ErrorIs(t, err, context.Canceled)
+}
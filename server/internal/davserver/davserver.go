@@ -0,0 +1,337 @@
+// Package davserver serves the gallery storage over WebDAV, so users can
+// mount it in Finder, Explorer or any WebDAV client and manage files with
+// native tools.
+//
+// Every operation goes through a Gallery, which checks the permissions of
+// the request session and keeps tags, albums and other records in step
+// with files written, moved or deleted. Uploads are spooled to a temporary
+// file until the client finishes sending them, as the storage needs their
+// size up front.
+package davserver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"go.uber.org/zap"
+	"golang.org/x/net/webdav"
+)
+
+// Gallery is the storage as seen by the session of a request. Names are
+// slash separated paths from the root the session sees, without leading
+// slash, the root being empty.
+type Gallery interface {
+	// Storage checks read access to name and returns the storage and key
+	// it is read at
+	Storage(ctx context.Context, name string) (storage.Storage, string, error)
+	// Writable checks write access to name
+	Writable(ctx context.Context, name string) error
+	// Put writes size bytes of content to the file name
+	Put(ctx context.Context, name string, content io.Reader, size int64) error
+	// Mkdir creates the folder name
+	Mkdir(ctx context.Context, name string) error
+	// Remove deletes the file or folder name with its contents
+	Remove(ctx context.Context, name string) error
+	// Move moves the file or folder oldName to newName
+	Move(ctx context.Context, oldName, newName string) error
+}
+
+// NewHandler returns a WebDAV handler of gallery mounted at prefix
+func NewHandler(gallery Gallery, prefix string, logger *zap.Logger) http.Handler {
+	handler := &webdav.Handler{
+		Prefix:     strings.TrimSuffix(prefix, "/"),
+		FileSystem: &fileSystem{gallery: gallery},
+		LockSystem: webdav.NewMemLS(),
+		Logger: func(r *http.Request, err error) {
+			if err != nil {
+				logger.Debug("WebDAV request failed",
+					zap.String("method", r.Method), zap.String("path", r.URL.Path), zap.Error(err))
+			}
+		},
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Files copied with native tools take longer than the server
+		// timeouts meant for API requests
+		rc := http.NewResponseController(w)
+		_ = rc.SetReadDeadline(time.Time{})
+		_ = rc.SetWriteDeadline(time.Time{})
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// fileSystem adapts a Gallery to webdav.FileSystem
+type fileSystem struct {
+	gallery Gallery
+}
+
+// cleanName returns the gallery name of a WebDAV path
+func cleanName(name string) string {
+	return strings.Trim(path.Clean("/"+name), "/")
+}
+
+// stat returns the info of name, folders of object stores included
+func (f *fileSystem) stat(ctx context.Context, name string) (storage.Storage, storage.FileInfo, error) {
+	stor, key, err := f.gallery.Storage(ctx, name)
+	if err != nil {
+		return nil, storage.FileInfo{}, err
+	}
+	info, err := statKey(ctx, stor, key)
+	return stor, info, err
+}
+
+// statKey returns the info of key, reporting every failure as not existing.
+// Object stores have no folders but placeholders or keys below them.
+func statKey(ctx context.Context, stor storage.Storage, key string) (storage.FileInfo, error) {
+	if key == "" {
+		return storage.FileInfo{IsDir: true}, nil
+	}
+	if info, err := stor.Stat(ctx, key); err == nil {
+		info.Path = key
+		return info, nil
+	}
+	folder := storage.FileInfo{Name: path.Base(key), Path: key, IsDir: true}
+	if info, err := stor.Stat(ctx, key+"/"); err == nil && info.IsDir {
+		folder.ModifiedTime = info.ModifiedTime
+		return folder, nil
+	}
+	result, err := stor.List(ctx, key, storage.ListOptions{Limit: 1, ShowHidden: true, SkipMetadata: true})
+	if err == nil && len(result.Items) > 0 {
+		return folder, nil
+	}
+	return storage.FileInfo{}, os.ErrNotExist
+}
+
+func (f *fileSystem) Mkdir(ctx context.Context, name string, _ os.FileMode) error {
+	name = cleanName(name)
+	if name == "" {
+		return os.ErrExist
+	}
+	if _, _, err := f.stat(ctx, name); err == nil {
+		return os.ErrExist
+	}
+	if parent := path.Dir(name); parent != "." {
+		if _, info, err := f.stat(ctx, parent); err != nil || !info.IsDir {
+			return os.ErrNotExist
+		}
+	}
+	return f.gallery.Mkdir(ctx, name)
+}
+
+func (f *fileSystem) OpenFile(ctx context.Context, name string, flag int, _ os.FileMode) (webdav.File, error) {
+	name = cleanName(name)
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+		return f.create(ctx, name, flag)
+	}
+	stor, info, err := f.stat(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	file := &readFile{ctx: ctx, stor: stor, info: info}
+	if !info.IsDir {
+		file.content = storage.NewLazyReadSeeker(ctx, func(ctx context.Context) (io.ReadCloser, error) {
+			return stor.Get(ctx, info.Path)
+		}, info.Size)
+	}
+	return file, nil
+}
+
+// create opens name for writing, replacing the file on Close
+func (f *fileSystem) create(ctx context.Context, name string, flag int) (webdav.File, error) {
+	if name == "" || flag&os.O_APPEND != 0 {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrInvalid}
+	}
+	if err := f.gallery.Writable(ctx, name); err != nil {
+		return nil, err
+	}
+	if _, info, err := f.stat(ctx, name); err == nil && info.IsDir {
+		return nil, &os.PathError{Op: "open", Path: name, Err: errors.New("is a folder")}
+	}
+	tmp, err := os.CreateTemp("", "imagor-studio-dav-*")
+	if err != nil {
+		return nil, err
+	}
+	return &writeFile{ctx: ctx, gallery: f.gallery, name: name, tmp: tmp}, nil
+}
+
+func (f *fileSystem) RemoveAll(ctx context.Context, name string) error {
+	name = cleanName(name)
+	if name == "" {
+		return &os.PathError{Op: "remove", Path: "/", Err: os.ErrInvalid}
+	}
+	if _, _, err := f.stat(ctx, name); err != nil {
+		return err
+	}
+	return f.gallery.Remove(ctx, name)
+}
+
+func (f *fileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	oldName, newName = cleanName(oldName), cleanName(newName)
+	if oldName == "" || newName == "" {
+		return &os.PathError{Op: "rename", Path: "/", Err: os.ErrInvalid}
+	}
+	return f.gallery.Move(ctx, oldName, newName)
+}
+
+func (f *fileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	_, info, err := f.stat(ctx, cleanName(name))
+	if err != nil {
+		return nil, err
+	}
+	return fileInfo{info}, nil
+}
+
+// readFile is a file or folder opened for reading
+type readFile struct {
+	ctx  context.Context
+	stor storage.Storage
+	info storage.FileInfo
+	// content is nil for folders
+	content *storage.LazyReadSeeker
+}
+
+func (f *readFile) Read(p []byte) (int, error) {
+	if f.content == nil {
+		return 0, &os.PathError{Op: "read", Path: f.info.Path, Err: errors.New("is a folder")}
+	}
+	return f.content.Read(p)
+}
+
+func (f *readFile) Seek(offset int64, whence int) (int64, error) {
+	if f.content == nil {
+		return 0, &os.PathError{Op: "seek", Path: f.info.Path, Err: errors.New("is a folder")}
+	}
+	return f.content.Seek(offset, whence)
+}
+
+func (f *readFile) Write([]byte) (int, error) {
+	return 0, &os.PathError{Op: "write", Path: f.info.Path, Err: os.ErrPermission}
+}
+
+// Readdir lists the folder, leaving out hidden files as the gallery does
+func (f *readFile) Readdir(int) ([]fs.FileInfo, error) {
+	if !f.info.IsDir {
+		return nil, &os.PathError{Op: "readdir", Path: f.info.Path, Err: errors.New("not a folder")}
+	}
+	result, err := f.stor.List(f.ctx, f.info.Path, storage.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]fs.FileInfo, len(result.Items))
+	for i, item := range result.Items {
+		infos[i] = fileInfo{item}
+	}
+	return infos, nil
+}
+
+func (f *readFile) Stat() (fs.FileInfo, error) {
+	return fileInfo{f.info}, nil
+}
+
+func (f *readFile) Close() error {
+	if f.content == nil {
+		return nil
+	}
+	return f.content.Close()
+}
+
+// writeFile spools a file written by a client, putting it to the gallery
+// on Close
+type writeFile struct {
+	ctx     context.Context
+	gallery Gallery
+	name    string
+	tmp     *os.File
+}
+
+func (f *writeFile) Write(p []byte) (int, error) {
+	return f.tmp.Write(p)
+}
+
+func (f *writeFile) Read(p []byte) (int, error) {
+	return f.tmp.Read(p)
+}
+
+func (f *writeFile) Seek(offset int64, whence int) (int64, error) {
+	return f.tmp.Seek(offset, whence)
+}
+
+func (f *writeFile) Readdir(int) ([]fs.FileInfo, error) {
+	return nil, &os.PathError{Op: "readdir", Path: f.name, Err: errors.New("not a folder")}
+}
+
+func (f *writeFile) Stat() (fs.FileInfo, error) {
+	info, err := f.tmp.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return fileInfo{storage.FileInfo{
+		Name:         path.Base(f.name),
+		Path:         f.name,
+		Size:         info.Size(),
+		ModifiedTime: info.ModTime(),
+	}}, nil
+}
+
+func (f *writeFile) Close() error {
+	defer os.Remove(f.tmp.Name())
+	defer f.tmp.Close()
+	size, err := f.tmp.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if _, err := f.tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return f.gallery.Put(f.ctx, f.name, f.tmp, size)
+}
+
+// fileInfo adapts storage.FileInfo to os.FileInfo, with the content type
+// and ETag WebDAV would otherwise read the file for
+type fileInfo struct {
+	info storage.FileInfo
+}
+
+func (i fileInfo) Name() string {
+	if i.info.Name == "" {
+		return "/"
+	}
+	return i.info.Name
+}
+
+func (i fileInfo) Size() int64 { return i.info.Size }
+
+func (i fileInfo) Mode() fs.FileMode {
+	if i.info.IsDir {
+		return fs.ModeDir | 0755
+	}
+	return 0644
+}
+
+func (i fileInfo) ModTime() time.Time { return i.info.ModifiedTime }
+
+func (i fileInfo) IsDir() bool { return i.info.IsDir }
+
+func (i fileInfo) Sys() any { return nil }
+
+func (i fileInfo) ContentType(context.Context) (string, error) {
+	if contentType := mime.TypeByExtension(path.Ext(i.info.Name)); contentType != "" {
+		return contentType, nil
+	}
+	return "application/octet-stream", nil
+}
+
+func (i fileInfo) ETag(context.Context) (string, error) {
+	if i.info.ETag == "" {
+		return "", webdav.ErrNotImplemented
+	}
+	return `"` + i.info.ETag + `"`, nil
+}
@@ -0,0 +1,124 @@
+package davserver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// testGallery serves a file storage, read only below "locked"
+type testGallery struct {
+	stor  storage.Storage
+	moves []string
+}
+
+func (g *testGallery) Storage(_ context.Context, name string) (storage.Storage, string, error) {
+	return g.stor, name, nil
+}
+
+func (g *testGallery) Writable(_ context.Context, name string) error {
+	if strings.HasPrefix(name, "locked") {
+		return errors.New("insufficient permission")
+	}
+	return nil
+}
+
+func (g *testGallery) Put(ctx context.Context, name string, content io.Reader, _ int64) error {
+	return g.stor.Put(ctx, name, content)
+}
+
+func (g *testGallery) Mkdir(ctx context.Context, name string) error {
+	return g.stor.CreateFolder(ctx, name)
+}
+
+func (g *testGallery) Remove(ctx context.Context, name string) error {
+	return g.stor.Delete(ctx, name)
+}
+
+func (g *testGallery) Move(ctx context.Context, oldName, newName string) error {
+	g.moves = append(g.moves, oldName+" -> "+newName)
+	return g.stor.Move(ctx, oldName, newName)
+}
+
+func newTestHandler(t *testing.T) (http.Handler, *testGallery, string) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range map[string]string{"album/a.jpg": "0123456789", "locked/b.jpg": "b", "album/.hidden": "x"} {
+		full := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(full), 0755))
+		require.NoError(t, os.WriteFile(full, []byte(content), 0644))
+	}
+	stor, err := filestorage.New(dir)
+	require.NoError(t, err)
+	gallery := &testGallery{stor: stor}
+	return NewHandler(gallery, "/dav/", zap.NewNop()), gallery, dir
+}
+
+func serve(h http.Handler, method, target string, body io.Reader, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, body)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandler_Read(t *testing.T) {
+	h, _, _ := newTestHandler(t)
+
+	rec := serve(h, "PROPFIND", "/dav/album/", nil, map[string]string{"Depth": "1"})
+	require.Equal(t, http.StatusMultiStatus, rec.Code)
+	body := rec.Body.String()
+	assert.Contains(t, body, "/dav/album/a.jpg")
+	assert.Contains(t, body, "<D:getcontentlength>10</D:getcontentlength>")
+	assert.Contains(t, body, "image/jpeg")
+	assert.NotContains(t, body, ".hidden", "hidden files are not listed")
+
+	rec = serve(h, http.MethodGet, "/dav/album/a.jpg", nil, map[string]string{"Range": "bytes=2-4"})
+	require.Equal(t, http.StatusPartialContent, rec.Code)
+	assert.Equal(t, "234", rec.Body.String())
+
+	rec = serve(h, "PROPFIND", "/dav/missing.jpg", nil, map[string]string{"Depth": "0"})
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestHandler_Write(t *testing.T) {
+	h, gallery, dir := newTestHandler(t)
+
+	rec := serve(h, "MKCOL", "/dav/trip", nil, nil)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	rec = serve(h, "MKCOL", "/dav/missing/trip", nil, nil)
+	assert.Equal(t, http.StatusConflict, rec.Code, "parents are not created")
+
+	rec = serve(h, http.MethodPut, "/dav/trip/c.jpg", strings.NewReader("uploaded"), nil)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	data, err := os.ReadFile(filepath.Join(dir, "trip", "c.jpg"))
+	require.NoError(t, err)
+	assert.Equal(t, "uploaded", string(data))
+
+	rec = serve(h, http.MethodPut, "/dav/locked/c.jpg", strings.NewReader("denied"), nil)
+	assert.NotEqual(t, http.StatusCreated, rec.Code)
+	assert.NoFileExists(t, filepath.Join(dir, "locked", "c.jpg"))
+
+	rec = serve(h, "MOVE", "/dav/trip/c.jpg", nil, map[string]string{"Destination": "http://example.com/dav/album/c.jpg"})
+	require.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, []string{"trip/c.jpg -> album/c.jpg"}, gallery.moves)
+	assert.FileExists(t, filepath.Join(dir, "album", "c.jpg"))
+
+	rec = serve(h, http.MethodDelete, "/dav/trip", nil, nil)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	assert.NoDirExists(t, filepath.Join(dir, "trip"))
+}
@@ -0,0 +1,104 @@
+// Package dbmaintenance runs SQLite maintenance as a tracked operation, both
+// on a schedule and on demand from the admin API.
+package dbmaintenance
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cshum/imagor-studio/server/internal/database"
+	"github.com/cshum/imagor-studio/server/internal/operation"
+	"github.com/uptrace/bun"
+	"go.uber.org/zap"
+)
+
+// OperationKind identifies maintenance runs in the operations API
+const OperationKind = "database_maintenance"
+
+// SystemOwnerID owns scheduled runs, they are visible to admins only
+const SystemOwnerID = "system"
+
+// Job runs SQLite maintenance through the operation manager
+type Job struct {
+	db         *bun.DB
+	operations *operation.Manager
+	logger     *zap.Logger
+}
+
+// New returns a maintenance job, or nil when db is not SQLite
+func New(db *bun.DB, operations *operation.Manager, logger *zap.Logger) *Job {
+	if !database.IsSQLite(db) {
+		return nil
+	}
+	return &Job{db: db, operations: operations, logger: logger}
+}
+
+// Start begins a maintenance run on behalf of ownerID. If a run is already in
+// progress it is returned instead of starting another one.
+func (j *Job) Start(ctx context.Context, ownerID string) operation.Operation {
+	op, started := j.operations.StartExclusive(ctx, OperationKind, ownerID, j.run)
+	if started {
+		j.logger.Info("Database maintenance started", zap.String("id", op.ID), zap.String("owner", ownerID))
+	}
+	return op
+}
+
+// Sync starts a scheduled run, for use with the server sync loop
+func (j *Job) Sync() error {
+	j.Start(context.Background(), SystemOwnerID)
+	return nil
+}
+
+func (j *Job) run(ctx context.Context, progress *operation.Progress) error {
+	progress.SetTotal(len(database.MaintenanceSteps))
+	progress.SetMessage("Checkpointing WAL")
+
+	report, err := database.MaintainSQLite(ctx, j.db, func(step database.MaintenanceStep, report *database.MaintenanceReport) {
+		switch step {
+		case database.StepCheckpoint:
+			result := fmt.Sprintf("checkpoint: %d of %d WAL frames checkpointed", report.CheckpointedFrames, report.WALFrames)
+			if report.CheckpointBusy {
+				result += " (busy, WAL not fully truncated)"
+			}
+			progress.SetMessage("Vacuuming free pages")
+			progress.Advance(1, result)
+		case database.StepVacuum:
+			result := fmt.Sprintf("vacuum: %d free pages reclaimed, %d bytes", report.FreePagesBefore-report.FreePagesAfter, report.ReclaimedBytes())
+			if report.ConvertedToIncremental {
+				result += " (converted to incremental auto_vacuum)"
+			}
+			progress.SetMessage("Checking integrity")
+			progress.Advance(1, result)
+		case database.StepIntegrity:
+			if report.IntegrityOK {
+				progress.Advance(1, "integrity_check: ok")
+				return
+			}
+			results := make([]string, 0, len(report.IntegrityErrors))
+			for _, line := range report.IntegrityErrors {
+				results = append(results, "integrity_check: "+line)
+			}
+			progress.Advance(1, results...)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if !report.IntegrityOK {
+		j.logger.Warn("SQLite integrity check detected corruption, restore from a backup",
+			zap.Int("problems", len(report.IntegrityErrors)),
+			zap.Strings("details", report.IntegrityErrors))
+		return fmt.Errorf("integrity check found %d problems", len(report.IntegrityErrors))
+	}
+	if report.CheckpointBusy {
+		j.logger.Warn("SQLite WAL checkpoint was blocked by active readers",
+			zap.Int("walFrames", report.WALFrames),
+			zap.Int("checkpointedFrames", report.CheckpointedFrames))
+	}
+	progress.SetMessage("Completed")
+	j.logger.Info("Database maintenance completed",
+		zap.Int("checkpointedFrames", report.CheckpointedFrames),
+		zap.Int64("reclaimedBytes", report.ReclaimedBytes()))
+	return nil
+}
@@ -0,0 +1,54 @@
+package dbmaintenance
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/database"
+	"github.com/cshum/imagor-studio/server/internal/operation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestNew_RequiresSQLite(t *testing.T) {
+	assert.Nil(t, New(nil, operation.NewManager(zap.NewNop()), zap.NewNop()))
+}
+
+func TestJob_Start(t *testing.T) {
+	db, err := database.Connect("sqlite:" + filepath.Join(t.TempDir(), "job.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	ops := operation.NewManager(zap.NewNop())
+	job := New(db, ops, zap.NewNop())
+	require.NotNil(t, job)
+
+	started := job.Start(context.Background(), "admin-1")
+	assert.Equal(t, OperationKind, started.Kind)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	op, err := ops.Wait(ctx, started.ID)
+	require.NoError(t, err)
+	assert.Equal(t, operation.StatusSucceeded, op.Status)
+	assert.Equal(t, 3, op.Total)
+	assert.Equal(t, 3, op.Completed)
+	require.Len(t, op.Results, 3)
+	assert.Contains(t, op.Results[0], "checkpoint:")
+	assert.Contains(t, op.Results[1], "vacuum:")
+	assert.Equal(t, "integrity_check: ok", op.Results[2])
+
+	require.NoError(t, job.Sync())
+	runs := ops.List(OperationKind)
+	require.Len(t, runs, 2)
+	for _, run := range runs {
+		if run.ID != started.ID {
+			assert.Equal(t, SystemOwnerID, run.OwnerID)
+			_, err = ops.Wait(ctx, run.ID)
+			require.NoError(t, err)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+// Package dedupe finds duplicate files by content hash, and similar images
+// by perceptual hash.
+//
+// A scan hashes every file below a folder with SHA-256, and images with
+// dHash, and records the hashes per scope. Files unchanged since the
+// previous scan, going by fingerprint, keep their hashes, so rescans only
+// read new and modified files. Duplicate groups and similar images are then
+// answered from the store without touching storage.
+package dedupe
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/cshum/imagor-studio/server/internal/operation"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"go.uber.org/zap"
+)
+
+// OperationKind identifies hash scans in the operations API
+const OperationKind = "duplicate_scan"
+
+// maxFailureResults caps the failed files listed in a scan's results
+const maxFailureResults = 100
+
+// Entry is the recorded hash of a file
+type Entry struct {
+	Path string
+	Size int64
+	// Fingerprint identifies the file version that was hashed
+	Fingerprint string
+	Hash        string
+	// Perceptual is the hex encoded perceptual hash of an image, empty for
+	// other files
+	Perceptual string
+}
+
+// Group is a set of files with identical content
+type Group struct {
+	Hash  string
+	Size  int64
+	Paths []string
+}
+
+// Fingerprint identifies a version of a file, preferring the ETag
+func Fingerprint(info storage.FileInfo) string {
+	if info.ETag != "" {
+		return info.ETag
+	}
+	return fmt.Sprintf("%d-%d", info.Size, info.ModifiedTime.UnixNano())
+}
+
+// HashFile returns the hex encoded SHA-256 of a file's content
+func HashFile(ctx context.Context, stor storage.Storage, path string) (string, error) {
+	reader, err := stor.Get(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	return HashContent(reader)
+}
+
+// HashContent returns the hex encoded SHA-256 of content, read to the end
+func HashContent(content io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, content); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Scanner records content hashes of storage files
+type Scanner struct {
+	store  Store
+	logger *zap.Logger
+}
+
+// NewScanner returns a scanner recording hashes in store
+func NewScanner(store Store, logger *zap.Logger) *Scanner {
+	return &Scanner{store: store, logger: logger}
+}
+
+// Store returns the store hashes are recorded in
+func (s *Scanner) Store() Store {
+	return s.store
+}
+
+// Scan hashes the files below root, reporting through progress. With a
+// perceptual hasher, images also get a perceptual hash; images it fails on
+// are left without one. Hidden and empty files are skipped, and hashes of
+// files no longer present are dropped. A file failing to hash does not stop
+// the others; the scan fails at the end when any did.
+func (s *Scanner) Scan(ctx context.Context, stor storage.Storage, scope, root string, perceptual PerceptualHasher, progress *operation.Progress) error {
+	progress.SetMessage("Listing files")
+	files, err := listFiles(ctx, stor, root)
+	if err != nil {
+		return err
+	}
+	known, err := s.store.List(ctx, scope, root)
+	if err != nil {
+		return err
+	}
+	previous := make(map[string]Entry, len(known))
+	for _, entry := range known {
+		previous[entry.Path] = entry
+	}
+
+	progress.SetTotal(len(files))
+	progress.SetMessage("Hashing files")
+	var hashed, failed int
+	for _, info := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var err error
+		entry, ok := previous[info.Path]
+		delete(previous, info.Path)
+		fingerprint := Fingerprint(info)
+		changed := !ok || entry.Fingerprint != fingerprint
+		wantPerceptual := perceptual != nil && IsPerceptualImage(info.Path)
+		if !changed && (!wantPerceptual || entry.Perceptual != "") {
+			progress.Advance(1)
+			continue
+		}
+		if changed {
+			entry = Entry{Path: info.Path, Size: info.Size, Fingerprint: fingerprint}
+			entry.Hash, err = HashFile(ctx, stor, info.Path)
+		}
+		if err == nil && wantPerceptual {
+			if hash, perr := perceptual(ctx, info.Path); perr == nil {
+				entry.Perceptual = FormatPerceptual(hash)
+			} else if ctx.Err() == nil {
+				s.logger.Debug("Failed to compute perceptual hash", zap.String("path", info.Path), zap.Error(perr))
+			}
+		}
+		if err == nil {
+			err = s.store.Put(ctx, scope, entry)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			failed++
+			s.logger.Warn("Failed to hash file", zap.String("path", info.Path), zap.Error(err))
+			if failed <= maxFailureResults {
+				progress.Advance(1, info.Path+": "+err.Error())
+			} else {
+				progress.Advance(1)
+			}
+			continue
+		}
+		hashed++
+		progress.Advance(1)
+	}
+
+	// Whatever is left was deleted or moved away since the last scan
+	removed := make([]string, 0, len(previous))
+	for p := range previous {
+		removed = append(removed, p)
+	}
+	if err := s.store.Remove(ctx, scope, removed); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files failed to hash", failed, len(files))
+	}
+	progress.SetMessage(fmt.Sprintf("Scanned %d files, %d hashed", len(files), hashed))
+	return nil
+}
+
+// listFiles returns the non-empty files below root outside hidden folders,
+// in one flat listing when the backend supports it
+func listFiles(ctx context.Context, stor storage.Storage, root string) ([]storage.FileInfo, error) {
+	var files []storage.FileInfo
+	visit := func(info storage.FileInfo) error {
+		if info.Size > 0 && !hasHiddenSegment(strings.TrimPrefix(info.Path, root)) {
+			files = append(files, info)
+		}
+		return nil
+	}
+	if walker, ok := stor.(storage.Walker); ok {
+		if err := walker.Walk(ctx, root, visit); err != nil {
+			return nil, fmt.Errorf("failed to list files: %w", err)
+		}
+		return files, nil
+	}
+	var walk func(dir string) error
+	walk = func(dir string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		result, err := stor.List(ctx, dir, storage.ListOptions{ShowHidden: true})
+		if err != nil {
+			return fmt.Errorf("failed to list files: %w", err)
+		}
+		for _, item := range result.Items {
+			if item.IsDir {
+				if storage.IsHiddenFile(item.Name) {
+					continue
+				}
+				if err := walk(item.Path); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := visit(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(root); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// hasHiddenSegment reports whether any element of p starts with "."
+func hasHiddenSegment(p string) bool {
+	for _, segment := range strings.Split(p, "/") {
+		if storage.IsHiddenFile(segment) {
+			return true
+		}
+	}
+	return false
+}
+
+// Job scans the default storage on a schedule through the operation manager
+type Job struct {
+	scanner    *Scanner
+	storage    func() storage.Storage
+	perceptual PerceptualHasher
+	operations *operation.Manager
+	logger     *zap.Logger
+}
+
+// NewJob returns a job scanning the storage returned by stor. perceptual
+// may be nil to record content hashes only.
+func NewJob(scanner *Scanner, stor func() storage.Storage, perceptual PerceptualHasher, operations *operation.Manager, logger *zap.Logger) *Job {
+	return &Job{scanner: scanner, storage: stor, perceptual: perceptual, operations: operations, logger: logger}
+}
+
+// Start begins a scan of the whole default storage on behalf of ownerID. If
+// a scan is already in progress it is returned instead of starting another.
+func (j *Job) Start(ctx context.Context, ownerID string) operation.Operation {
+	op, started := j.operations.StartExclusive(ctx, OperationKind, ownerID, func(ctx context.Context, progress *operation.Progress) error {
+		stor := j.storage()
+		if stor == nil {
+			return fmt.Errorf("storage is not configured")
+		}
+		return j.scanner.Scan(ctx, stor, registrystore.SystemOwnerID, "", j.perceptual, progress)
+	})
+	if started {
+		j.logger.Info("Duplicate scan started", zap.String("id", op.ID), zap.String("owner", ownerID))
+	}
+	return op
+}
+
+// Sync starts a scheduled scan, for use with the server sync loop
+func (j *Job) Sync() error {
+	j.Start(context.Background(), registrystore.SystemOwnerID)
+	return nil
+}
@@ -0,0 +1,213 @@
+package dedupe
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/operation"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const scope = registrystore.SystemOwnerID
+
+func setupTestStore(t *testing.T) Store {
+	t.Helper()
+	db := testutil.NewDB(t)
+
+	return NewStore(db, zap.NewNop())
+}
+
+func writeFile(t *testing.T, baseDir, path, content string) {
+	t.Helper()
+	fullPath := filepath.Join(baseDir, path)
+	require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0755))
+	require.NoError(t, os.WriteFile(fullPath, []byte(content), 0644))
+}
+
+func scan(t *testing.T, job *Job) operation.Operation {
+	t.Helper()
+	started := job.Start(context.Background(), "admin")
+	op, err := job.operations.Wait(context.Background(), started.ID)
+	require.NoError(t, err)
+	return op
+}
+
+func TestStore_DuplicateGroups(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	for _, e := range []Entry{
+		{Path: "a/one.jpg", Size: 10, Fingerprint: "v1", Hash: "small"},
+		{Path: "b/one.jpg", Size: 10, Fingerprint: "v1", Hash: "small"},
+		{Path: "a/big.mp4", Size: 900, Fingerprint: "v1", Hash: "big"},
+		{Path: "a/big copy.mp4", Size: 900, Fingerprint: "v1", Hash: "big"},
+		{Path: "a/unique.png", Size: 20, Fingerprint: "v1", Hash: "unique"},
+	} {
+		require.NoError(t, s.Put(ctx, scope, e))
+	}
+	require.NoError(t, s.Put(ctx, "space:other", Entry{Path: "a/unique.png", Size: 20, Fingerprint: "v1", Hash: "unique"}))
+
+	groups, err := s.DuplicateGroups(ctx, scope, "", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []Group{
+		{Hash: "big", Size: 900, Paths: []string{"a/big copy.mp4", "a/big.mp4"}},
+		{Hash: "small", Size: 10, Paths: []string{"a/one.jpg", "b/one.jpg"}},
+	}, groups)
+
+	groups, err = s.DuplicateGroups(ctx, scope, "", 1)
+	require.NoError(t, err)
+	require.Len(t, groups, 1)
+	assert.Equal(t, "big", groups[0].Hash)
+
+	// Only copies below the folder count
+	groups, err = s.DuplicateGroups(ctx, scope, "a", 10)
+	require.NoError(t, err)
+	require.Len(t, groups, 1)
+	assert.Equal(t, "big", groups[0].Hash)
+
+	got, err := s.Get(ctx, scope, []string{"a/one.jpg", "missing.jpg"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]Entry{"a/one.jpg": {Path: "a/one.jpg", Size: 10, Fingerprint: "v1", Hash: "small"}}, got)
+
+	copies, err := s.GetByHash(ctx, scope, "big")
+	require.NoError(t, err)
+	require.Len(t, copies, 2)
+	assert.Equal(t, "a/big copy.mp4", copies[0].Path)
+
+	require.NoError(t, s.RemoveFilePath(ctx, scope, "a"))
+	entries, err := s.List(ctx, scope, "")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "b/one.jpg", entries[0].Path)
+}
+
+func TestJob_Scan(t *testing.T) {
+	baseDir := t.TempDir()
+	writeFile(t, baseDir, "photos/a.jpg", "same")
+	writeFile(t, baseDir, "backup/a.jpg", "same")
+	writeFile(t, baseDir, "photos/b.jpg", "different")
+	writeFile(t, baseDir, "photos/empty.jpg", "")
+	writeFile(t, baseDir, "photos/none.jpg", "")
+	writeFile(t, baseDir, ".imagor-studio/a.jpg", "same")
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+	s := setupTestStore(t)
+	job := NewJob(NewScanner(s, zap.NewNop()), func() storage.Storage { return stor }, nil, operation.NewManager(zap.NewNop()), zap.NewNop())
+
+	op := scan(t, job)
+	assert.Equal(t, operation.StatusSucceeded, op.Status)
+	assert.Equal(t, 3, op.Total)
+	assert.Equal(t, "Scanned 3 files, 3 hashed", op.Message)
+	groups, err := s.DuplicateGroups(context.Background(), scope, "", 10)
+	require.NoError(t, err)
+	require.Len(t, groups, 1)
+	assert.Equal(t, []string{"backup/a.jpg", "photos/a.jpg"}, groups[0].Paths)
+	assert.Equal(t, int64(4), groups[0].Size)
+
+	// Unchanged files are not hashed again, deleted ones are dropped
+	require.NoError(t, os.Remove(filepath.Join(baseDir, "backup/a.jpg")))
+	writeFile(t, baseDir, "photos/c.jpg", "different")
+	op = scan(t, job)
+	assert.Equal(t, "Scanned 3 files, 1 hashed", op.Message)
+	groups, err = s.DuplicateGroups(context.Background(), scope, "", 10)
+	require.NoError(t, err)
+	require.Len(t, groups, 1)
+	assert.Equal(t, []string{"photos/b.jpg", "photos/c.jpg"}, groups[0].Paths)
+}
+
+func TestJob_ScanPerceptual(t *testing.T) {
+	baseDir := t.TempDir()
+	writeFile(t, baseDir, "photos/a.jpg", "one")
+	writeFile(t, baseDir, "photos/b.JPG", "two")
+	writeFile(t, baseDir, "photos/broken.png", "three")
+	writeFile(t, baseDir, "notes.txt", "four")
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+	s := setupTestStore(t)
+	var calls []string
+	perceptual := func(ctx context.Context, path string) (uint64, error) {
+		calls = append(calls, path)
+		if path == "photos/broken.png" {
+			return 0, assert.AnError
+		}
+		return 0xff, nil
+	}
+
+	// Images hashed before perceptual hashing was enabled are not read again
+	op := scan(t, NewJob(NewScanner(s, zap.NewNop()), func() storage.Storage { return stor }, nil, operation.NewManager(zap.NewNop()), zap.NewNop()))
+	assert.Equal(t, "Scanned 4 files, 4 hashed", op.Message)
+	job := NewJob(NewScanner(s, zap.NewNop()), func() storage.Storage { return stor }, perceptual, operation.NewManager(zap.NewNop()), zap.NewNop())
+	op = scan(t, job)
+	assert.Equal(t, operation.StatusSucceeded, op.Status)
+	assert.ElementsMatch(t, []string{"photos/a.jpg", "photos/b.JPG", "photos/broken.png"}, calls)
+
+	entries, err := s.ListPerceptual(context.Background(), scope, "photos")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	for _, e := range entries {
+		assert.Equal(t, "00000000000000ff", e.Perceptual)
+		assert.NotEmpty(t, e.Hash)
+	}
+
+	// Only images left without a perceptual hash are retried
+	calls = nil
+	scan(t, job)
+	assert.Equal(t, []string{"photos/broken.png"}, calls)
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+// gradient renders shade over normalized coordinates
+func gradient(width, height int, shade func(u, v float64) float64) image.Image {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			img.SetGray(x, y, color.Gray{Y: uint8(shade(float64(x)/float64(width), float64(y)/float64(height)))})
+		}
+	}
+	return img
+}
+
+func TestDHash(t *testing.T) {
+	wave := func(u, v float64) float64 {
+		return 100 + 90*math.Sin(u*5)*math.Cos(v*3+1)
+	}
+	original, err := DHash(encodePNG(t, gradient(360, 320, wave)))
+	require.NoError(t, err)
+	// Downscaled and brightened copy
+	copied, err := DHash(encodePNG(t, gradient(90, 80, func(u, v float64) float64 { return wave(u, v) + 20 })))
+	require.NoError(t, err)
+	// Mirrored image
+	mirrored, err := DHash(encodePNG(t, gradient(360, 320, func(u, v float64) float64 { return wave(1-u, v) })))
+	require.NoError(t, err)
+
+	assert.LessOrEqual(t, Distance(original, copied), 4)
+	assert.Greater(t, Distance(original, mirrored), 20)
+
+	_, err = DHash([]byte("not an image"))
+	assert.Error(t, err)
+
+	parsed, err := ParsePerceptual(FormatPerceptual(original))
+	require.NoError(t, err)
+	assert.Equal(t, original, parsed)
+	assert.True(t, IsPerceptualImage("a/B.HEIC"))
+	assert.False(t, IsPerceptualImage("a/b.mp4"))
+}
@@ -0,0 +1,115 @@
+package dedupe
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	_ "image/png"
+	"math/bits"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/cshum/imagor/imagorpath"
+)
+
+// PerceptualHasher returns the perceptual hash of an image file, see DHash
+type PerceptualHasher func(ctx context.Context, path string) (uint64, error)
+
+// MaxDistance is the largest Hamming distance between perceptual hashes
+const MaxDistance = 64
+
+// dHash samples a 9x8 grid, comparing each cell to its right neighbour
+const (
+	dHashWidth  = 9
+	dHashHeight = 8
+)
+
+// perceptualExtensions are the image files perceptual hashes are computed
+// for, matching the formats imagor decodes
+var perceptualExtensions = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".webp": true, ".avif": true,
+	".gif": true, ".tif": true, ".tiff": true, ".heic": true, ".heif": true,
+	".bmp": true,
+}
+
+// IsPerceptualImage reports whether p is an image perceptual hashes are
+// computed for
+func IsPerceptualImage(p string) bool {
+	return perceptualExtensions[strings.ToLower(path.Ext(p))]
+}
+
+// PerceptualParams renders the 9x8 grayscale PNG a perceptual hash is
+// computed from, so imagor decodes any format and the server only decodes
+// a few bytes of PNG
+func PerceptualParams() imagorpath.Params {
+	return imagorpath.Params{
+		Width:   dHashWidth,
+		Height:  dHashHeight,
+		Stretch: true,
+		Filters: imagorpath.Filters{
+			{Name: "grayscale"},
+			{Name: "format", Args: "png"},
+		},
+	}
+}
+
+// DHash returns the difference hash of an encoded PNG or JPEG image. Each bit
+// tells whether a cell of a 9x8 grayscale grid is brighter than the next
+// cell to its right, so resizing, recompression and small edits flip few
+// bits. Larger images are averaged down to the grid.
+func DHash(data []byte) (uint64, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode image: %w", err)
+	}
+	bounds := img.Bounds()
+	if bounds.Dx() == 0 || bounds.Dy() == 0 {
+		return 0, fmt.Errorf("empty image")
+	}
+	var grid [dHashHeight][dHashWidth]float64
+	for gy := range dHashHeight {
+		y0 := bounds.Min.Y + gy*bounds.Dy()/dHashHeight
+		y1 := max(bounds.Min.Y+(gy+1)*bounds.Dy()/dHashHeight, y0+1)
+		for gx := range dHashWidth {
+			x0 := bounds.Min.X + gx*bounds.Dx()/dHashWidth
+			x1 := max(bounds.Min.X+(gx+1)*bounds.Dx()/dHashWidth, x0+1)
+			var sum float64
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					sum += float64(color.Gray16Model.Convert(img.At(x, y)).(color.Gray16).Y)
+				}
+			}
+			grid[gy][gx] = sum / float64((y1-y0)*(x1-x0))
+		}
+	}
+	var hash uint64
+	for gy := range dHashHeight {
+		for gx := range dHashWidth - 1 {
+			hash <<= 1
+			if grid[gy][gx] > grid[gy][gx+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash, nil
+}
+
+// Distance is the number of differing bits between two perceptual hashes,
+// 0 for visually identical images
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// FormatPerceptual encodes a perceptual hash as 16 hex digits
+func FormatPerceptual(hash uint64) string {
+	return fmt.Sprintf("%016x", hash)
+}
+
+// ParsePerceptual decodes a hash encoded by FormatPerceptual
+func ParsePerceptual(s string) (uint64, error) {
+	return strconv.ParseUint(s, 16, 64)
+}
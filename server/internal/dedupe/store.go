@@ -0,0 +1,205 @@
+package dedupe
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/database"
+	"github.com/cshum/imagor-studio/server/internal/model"
+	"github.com/cshum/imagor-studio/server/pkg/uuid"
+	"github.com/uptrace/bun"
+	"go.uber.org/zap"
+)
+
+// Store records content hashes per scope and file path
+type Store interface {
+	// List returns the recorded hashes of files below root, every file of
+	// the scope when root is empty
+	List(ctx context.Context, scope, root string) ([]Entry, error)
+	// Get returns the recorded hashes of the given files, keyed by path
+	Get(ctx context.Context, scope string, paths []string) (map[string]Entry, error)
+	// GetByHash returns every file recorded with hash
+	GetByHash(ctx context.Context, scope, hash string) ([]Entry, error)
+	// ListPerceptual returns the files below root that have a perceptual
+	// hash, every file of the scope when root is empty
+	ListPerceptual(ctx context.Context, scope, root string) ([]Entry, error)
+	// Put replaces the recorded hash of entry.Path
+	Put(ctx context.Context, scope string, entry Entry) error
+	// Remove drops the recorded hashes of the given files
+	Remove(ctx context.Context, scope string, paths []string) error
+	// RemoveFilePath drops the recorded hash of a file, or of every file
+	// below a folder
+	RemoveFilePath(ctx context.Context, scope, path string) error
+	// DuplicateGroups returns up to limit groups of files below root sharing
+	// a hash, largest files first
+	DuplicateGroups(ctx context.Context, scope, root string, limit int) ([]Group, error)
+}
+
+// chunkSize keeps IN lists below the SQLite parameter limit
+const chunkSize = 500
+
+type store struct {
+	db     *bun.DB
+	logger *zap.Logger
+}
+
+func NewStore(db *bun.DB, logger *zap.Logger) Store {
+	return &store{db: db, logger: logger}
+}
+
+// below restricts q to root and the files under it
+func below(q *bun.SelectQuery, root string) *bun.SelectQuery {
+	if root == "" {
+		return q
+	}
+	return q.Where("(file_path = ? OR substr(file_path, 1, ?) = ?)", root, len(root)+1, root+"/")
+}
+
+func toEntry(row model.FileHash) Entry {
+	return Entry{Path: row.FilePath, Size: row.Size, Fingerprint: row.Fingerprint, Hash: row.Hash, Perceptual: row.PerceptualHash}
+}
+
+func (s *store) List(ctx context.Context, scope, root string) ([]Entry, error) {
+	var rows []model.FileHash
+	if err := below(s.db.NewSelect().Model(&rows).Where("scope = ?", scope), root).Scan(ctx); err != nil {
+		return nil, fmt.Errorf("error listing file hashes: %w", err)
+	}
+	entries := make([]Entry, 0, len(rows))
+	for _, row := range rows {
+		entries = append(entries, toEntry(row))
+	}
+	return entries, nil
+}
+
+func (s *store) Get(ctx context.Context, scope string, paths []string) (map[string]Entry, error) {
+	result := make(map[string]Entry)
+	for start := 0; start < len(paths); start += chunkSize {
+		end := min(start+chunkSize, len(paths))
+		var rows []model.FileHash
+		if err := s.db.NewSelect().Model(&rows).
+			Where("scope = ?", scope).
+			Where("file_path IN (?)", bun.In(paths[start:end])).
+			Scan(ctx); err != nil {
+			return nil, fmt.Errorf("error getting file hashes: %w", err)
+		}
+		for _, row := range rows {
+			result[row.FilePath] = toEntry(row)
+		}
+	}
+	return result, nil
+}
+
+func (s *store) GetByHash(ctx context.Context, scope, hash string) ([]Entry, error) {
+	var rows []model.FileHash
+	if err := s.db.NewSelect().Model(&rows).
+		Where("scope = ?", scope).
+		Where("hash = ?", hash).
+		Order("file_path ASC").
+		Scan(ctx); err != nil {
+		return nil, fmt.Errorf("error getting file hashes: %w", err)
+	}
+	entries := make([]Entry, 0, len(rows))
+	for _, row := range rows {
+		entries = append(entries, toEntry(row))
+	}
+	return entries, nil
+}
+
+func (s *store) ListPerceptual(ctx context.Context, scope, root string) ([]Entry, error) {
+	var rows []model.FileHash
+	q := s.db.NewSelect().Model(&rows).
+		Where("scope = ?", scope).
+		Where("perceptual_hash IS NOT NULL")
+	if err := below(q, root).Scan(ctx); err != nil {
+		return nil, fmt.Errorf("error listing perceptual hashes: %w", err)
+	}
+	entries := make([]Entry, 0, len(rows))
+	for _, row := range rows {
+		entries = append(entries, toEntry(row))
+	}
+	return entries, nil
+}
+
+func (s *store) Put(ctx context.Context, scope string, entry Entry) error {
+	row := &model.FileHash{
+		ID:             uuid.GenerateUUID(),
+		Scope:          scope,
+		FilePath:       entry.Path,
+		Size:           entry.Size,
+		Fingerprint:    entry.Fingerprint,
+		Hash:           entry.Hash,
+		PerceptualHash: entry.Perceptual,
+		HashedAt:       time.Now().UTC(),
+	}
+	if _, err := database.OnConflictUpdate(s.db.NewInsert().Model(row), "scope, file_path",
+		"size", "fingerprint", "hash", "perceptual_hash", "hashed_at").Exec(ctx); err != nil {
+		return fmt.Errorf("error saving file hash: %w", err)
+	}
+	return nil
+}
+
+func (s *store) Remove(ctx context.Context, scope string, paths []string) error {
+	for start := 0; start < len(paths); start += chunkSize {
+		end := min(start+chunkSize, len(paths))
+		if _, err := s.db.NewDelete().Model((*model.FileHash)(nil)).
+			Where("scope = ?", scope).
+			Where("file_path IN (?)", bun.In(paths[start:end])).
+			Exec(ctx); err != nil {
+			return fmt.Errorf("error removing file hashes: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *store) RemoveFilePath(ctx context.Context, scope, path string) error {
+	if _, err := s.db.NewDelete().Model((*model.FileHash)(nil)).
+		Where("scope = ?", scope).
+		Where("(file_path = ? OR substr(file_path, 1, ?) = ?)", path, len(path)+1, path+"/").
+		Exec(ctx); err != nil {
+		return fmt.Errorf("error removing file hashes: %w", err)
+	}
+	return nil
+}
+
+func (s *store) DuplicateGroups(ctx context.Context, scope, root string, limit int) ([]Group, error) {
+	var hashes []struct {
+		Hash string `bun:"hash"`
+		Size int64  `bun:"size"`
+	}
+	q := s.db.NewSelect().Model((*model.FileHash)(nil)).
+		Column("hash").
+		ColumnExpr("MAX(size) AS size").
+		Where("scope = ?", scope)
+	if err := below(q, root).
+		Group("hash").
+		Having("COUNT(*) > 1").
+		OrderExpr("MAX(size) DESC, hash ASC").
+		Limit(limit).
+		Scan(ctx, &hashes); err != nil {
+		return nil, fmt.Errorf("error finding duplicate files: %w", err)
+	}
+	if len(hashes) == 0 {
+		return nil, nil
+	}
+	keys := make([]string, 0, len(hashes))
+	groups := make([]Group, 0, len(hashes))
+	index := make(map[string]int, len(hashes))
+	for i, h := range hashes {
+		keys = append(keys, h.Hash)
+		groups = append(groups, Group{Hash: h.Hash, Size: h.Size})
+		index[h.Hash] = i
+	}
+	var rows []model.FileHash
+	q = s.db.NewSelect().Model(&rows).
+		Where("scope = ?", scope).
+		Where("hash IN (?)", bun.In(keys))
+	if err := below(q, root).Order("file_path ASC").Scan(ctx); err != nil {
+		return nil, fmt.Errorf("error finding duplicate files: %w", err)
+	}
+	for _, row := range rows {
+		g := &groups[index[row.Hash]]
+		g.Paths = append(g.Paths, row.FilePath)
+	}
+	return groups, nil
+}
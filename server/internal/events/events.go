@@ -0,0 +1,101 @@
+// Package events fans out changes made through this server to GraphQL
+// subscriptions.
+//
+// Delivery is best effort and local to the replica: a subscriber that falls
+// behind misses events rather than slowing down the mutation publishing them,
+// so clients should refetch when they (re)subscribe.
+package events
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// Kind is the kind of change an event reports
+type Kind string
+
+const (
+	// FileCreated is a file uploaded, copied or converted, or a folder created
+	FileCreated Kind = "file_created"
+	// FileUpdated is a file written in place outside of the server
+	FileUpdated Kind = "file_updated"
+	// FileDeleted is a file or folder deleted
+	FileDeleted Kind = "file_deleted"
+	// FileMoved is a file or folder moved or renamed, from OldPath to Path
+	FileMoved Kind = "file_moved"
+	// StorageChanged is the storage configuration saved, applied or rolled back
+	StorageChanged Kind = "storage_changed"
+)
+
+// bufferSize is the events queued per subscriber before dropping
+const bufferSize = 64
+
+// Event is a change published to subscribers
+type Event struct {
+	Kind Kind
+	// Scope is the file metadata scope of the storage, "" for storage events
+	Scope   string
+	Path    string
+	OldPath string
+	// Uploaded marks a FileCreated event of a file uploaded by a client
+	Uploaded bool
+}
+
+// Within reports whether the event touches folder or anything below it
+func (e Event) Within(folder string) bool {
+	return PathWithin(e.Path, folder) || PathWithin(e.OldPath, folder)
+}
+
+// PathWithin reports whether p is folder or below it, the storage root
+// being ""
+func PathWithin(p, folder string) bool {
+	folder = strings.Trim(folder, "/")
+	if p == "" {
+		return false
+	}
+	return folder == "" || p == folder || strings.HasPrefix(p, folder+"/")
+}
+
+// Broker publishes events to subscribers
+type Broker struct {
+	mu   sync.Mutex
+	subs map[chan Event]func(Event) bool
+}
+
+// NewBroker creates a broker
+func NewBroker() *Broker {
+	return &Broker{subs: make(map[chan Event]func(Event) bool)}
+}
+
+// Publish sends e to the subscribers it matches without blocking
+func (b *Broker) Publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch, match := range b.subs {
+		if !match(e) {
+			continue
+		}
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// Subscribe delivers the events matching filter until ctx is done, then
+// closes the channel
+func (b *Broker) Subscribe(ctx context.Context, filter func(Event) bool) <-chan Event {
+	ch := make(chan Event, bufferSize)
+	b.mu.Lock()
+	b.subs[ch] = filter
+	b.mu.Unlock()
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		delete(b.subs, ch)
+		close(ch)
+		b.mu.Unlock()
+	}()
+	return ch
+}
@@ -0,0 +1,59 @@
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvent_Within(t *testing.T) {
+	for _, tc := range []struct {
+		event  Event
+		folder string
+		want   bool
+	}{
+		{Event{Path: "photos/a.jpg"}, "photos", true},
+		{Event{Path: "photos/2024/a.jpg"}, "/photos/", true},
+		{Event{Path: "photos"}, "photos", true},
+		{Event{Path: "photos-old/a.jpg"}, "photos", false},
+		{Event{Path: "docs/a.jpg", OldPath: "photos/a.jpg"}, "photos", true},
+		{Event{Path: "a.jpg"}, "", true},
+		{Event{Kind: StorageChanged}, "", false},
+	} {
+		assert.Equal(t, tc.want, tc.event.Within(tc.folder), "%+v in %q", tc.event, tc.folder)
+	}
+}
+
+func TestBroker(t *testing.T) {
+	b := NewBroker()
+	ctx, cancel := context.WithCancel(context.Background())
+	photos := b.Subscribe(ctx, func(e Event) bool { return e.Within("photos") })
+	all := b.Subscribe(context.Background(), func(Event) bool { return true })
+
+	b.Publish(Event{Kind: FileCreated, Path: "photos/a.jpg"})
+	b.Publish(Event{Kind: FileDeleted, Path: "docs/b.pdf"})
+
+	assert.Equal(t, "photos/a.jpg", (<-photos).Path)
+	assert.Equal(t, "photos/a.jpg", (<-all).Path)
+	assert.Equal(t, "docs/b.pdf", (<-all).Path)
+
+	cancel()
+	select {
+	case _, ok := <-photos:
+		assert.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("subscription not closed")
+	}
+}
+
+func TestBroker_DropsWhenSubscriberFallsBehind(t *testing.T) {
+	b := NewBroker()
+	ch := b.Subscribe(context.Background(), func(Event) bool { return true })
+	for i := 0; i < bufferSize+10; i++ {
+		b.Publish(Event{Kind: FileCreated, Path: "a.jpg"})
+	}
+	require.Len(t, ch, bufferSize)
+}
@@ -0,0 +1,215 @@
+// Package faces detects faces in images and groups them into people.
+//
+// Detection is pluggable: a Detector turns an image into faces, each with a
+// box and an embedding vector. The built-in "http" detector posts images to
+// an external service, other backends such as an embedded model register
+// themselves with RegisterDetector. Faces are matched to people by the
+// cosine similarity of their embeddings to each person's centroid, a face
+// matching no one starts a new person. Users then name people and merge
+// the ones the matching split.
+//
+// As with duplicate scans, images unchanged since the previous scan are not
+// sent to the detector again.
+package faces
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cshum/imagor/imagorpath"
+)
+
+// OperationKind identifies face scans in the operations API
+const OperationKind = "face_scan"
+
+const (
+	// DefaultMatchThreshold is the cosine similarity above which a face is
+	// taken for a known person, suiting common face recognition models
+	DefaultMatchThreshold = 0.5
+	// renderSize bounds the width and height of images sent to detectors
+	renderSize = 1024
+)
+
+// imageExtensions are the images scanned for faces, the formats imagor
+// decodes
+var imageExtensions = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".webp": true, ".avif": true,
+	".gif": true, ".tif": true, ".tiff": true, ".heic": true, ".heif": true,
+	".bmp": true,
+}
+
+// IsImage reports whether p is an image scanned for faces
+func IsImage(p string) bool {
+	return imageExtensions[strings.ToLower(path.Ext(p))]
+}
+
+// RenderParams returns the imagor params rendering an image for detection,
+// a JPEG at most renderSize pixels wide or high
+func RenderParams() imagorpath.Params {
+	return imagorpath.Params{
+		FitIn:   true,
+		Width:   renderSize,
+		Height:  renderSize,
+		Filters: imagorpath.Filters{{Name: "format", Args: "jpeg"}},
+	}
+}
+
+// Renderer returns the image at path encoded for a detector
+type Renderer func(ctx context.Context, path string) ([]byte, error)
+
+// Box is the area of a face relative to the image size, 0 to 1 from the top
+// left corner
+type Box struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// Validate reports a box reaching outside of the image
+func (b Box) Validate() error {
+	if b.X < 0 || b.Y < 0 || b.Width <= 0 || b.Height <= 0 || b.X+b.Width > 1.0001 || b.Y+b.Height > 1.0001 {
+		return fmt.Errorf("face box %v is outside of the image", b)
+	}
+	return nil
+}
+
+// Face is a face found by a detector
+type Face struct {
+	Box        Box       `json:"box"`
+	Confidence float64   `json:"confidence"`
+	Embedding  []float32 `json:"embedding"`
+}
+
+// Detector finds the faces in an image
+type Detector interface {
+	Detect(ctx context.Context, image []byte) ([]Face, error)
+}
+
+// Options configures a detector created by NewDetector
+type Options struct {
+	// URL is the service or model location, as the backend defines it
+	URL string
+	// Timeout bounds one detection, 0 for the backend default
+	Timeout time.Duration
+}
+
+// Factory creates a detector backend
+type Factory func(options Options) (Detector, error)
+
+var (
+	detectorsMu sync.RWMutex
+	detectors   = map[string]Factory{
+		"http": func(options Options) (Detector, error) {
+			detector, err := NewHTTPDetector(options.URL, options.Timeout)
+			if err != nil {
+				return nil, err
+			}
+			return detector, nil
+		},
+	}
+)
+
+// RegisterDetector makes a detector backend available by name, for backends
+// built in optionally such as embedded models. It panics if the name is
+// taken.
+func RegisterDetector(name string, factory Factory) {
+	detectorsMu.Lock()
+	defer detectorsMu.Unlock()
+	if _, ok := detectors[name]; ok {
+		panic("faces: detector already registered: " + name)
+	}
+	detectors[name] = factory
+}
+
+// Detectors returns the names of the registered detector backends
+func Detectors() []string {
+	detectorsMu.RLock()
+	defer detectorsMu.RUnlock()
+	names := make([]string, 0, len(detectors))
+	for name := range detectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewDetector creates the detector backend registered as name
+func NewDetector(name string, options Options) (Detector, error) {
+	detectorsMu.RLock()
+	factory, ok := detectors[name]
+	detectorsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown face detector %q, available: %s", name, strings.Join(Detectors(), ", "))
+	}
+	return factory(options)
+}
+
+// normalize scales v to unit length, reporting false for a zero vector
+func normalize(v []float32) ([]float32, bool) {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 || math.IsNaN(sum) || math.IsInf(sum, 0) {
+		return nil, false
+	}
+	norm := math.Sqrt(sum)
+	result := make([]float32, len(v))
+	for i, x := range v {
+		result[i] = float32(float64(x) / norm)
+	}
+	return result, true
+}
+
+// Similarity returns the cosine similarity of two embeddings, 0 when their
+// dimensions differ, such as after switching detection models
+func Similarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
+
+// encodeEmbedding returns the base64 encoded little endian float32 vector
+func encodeEmbedding(v []float32) string {
+	buf := make([]byte, 4*len(v))
+	for i, x := range v {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(x))
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
+
+// decodeEmbedding reverses encodeEmbedding
+func decodeEmbedding(s string) ([]float32, error) {
+	buf, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(buf)%4 != 0 {
+		return nil, errors.New("embedding is not a float32 vector")
+	}
+	v := make([]float32, len(buf)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+	return v, nil
+}
@@ -0,0 +1,275 @@
+package faces
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/operation"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const scope = registrystore.SystemOwnerID
+
+func setupTestStore(t *testing.T) Store {
+	t.Helper()
+	db := testutil.NewDB(t)
+
+	return NewStore(db, zap.NewNop())
+}
+
+func writeFile(t *testing.T, baseDir, path, content string) {
+	t.Helper()
+	fullPath := filepath.Join(baseDir, path)
+	require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0755))
+	require.NoError(t, os.WriteFile(fullPath, []byte(content), 0644))
+}
+
+// face returns a confident face with a one-hot style embedding, faces of
+// the same person sharing the dominant dimension
+func face(person int, x float64) Face {
+	embedding := make([]float32, 4)
+	embedding[person] = 1
+	embedding[(person+1)%4] = 0.1
+	return Face{Box: Box{X: x, Y: 0.1, Width: 0.2, Height: 0.2}, Confidence: 0.9, Embedding: embedding}
+}
+
+// contentDetector returns the faces listed for the image content
+type contentDetector map[string][]Face
+
+func (d contentDetector) Detect(_ context.Context, image []byte) ([]Face, error) {
+	faces, ok := d[string(image)]
+	if !ok {
+		return nil, fmt.Errorf("unknown image %q", image)
+	}
+	return faces, nil
+}
+
+func TestSimilarity(t *testing.T) {
+	assert.InDelta(t, 1, Similarity([]float32{1, 2}, []float32{2, 4}), 1e-9)
+	assert.InDelta(t, 0, Similarity([]float32{1, 0}, []float32{0, 1}), 1e-9)
+	assert.InDelta(t, -1, Similarity([]float32{1, 0}, []float32{-1, 0}), 1e-9)
+	assert.Equal(t, float64(0), Similarity([]float32{1, 0}, []float32{1, 0, 0}))
+
+	v := []float32{0.25, -1.5, 3}
+	decoded, err := decodeEmbedding(encodeEmbedding(v))
+	require.NoError(t, err)
+	assert.Equal(t, v, decoded)
+}
+
+func TestNewDetector(t *testing.T) {
+	assert.Contains(t, Detectors(), "http")
+	_, err := NewDetector("http", Options{URL: "not a url"})
+	assert.Error(t, err)
+	_, err = NewDetector("missing", Options{})
+	assert.ErrorContains(t, err, "unknown face detector")
+
+	RegisterDetector("test", func(Options) (Detector, error) { return contentDetector{}, nil })
+	detector, err := NewDetector("test", Options{})
+	require.NoError(t, err)
+	assert.IsType(t, contentDetector{}, detector)
+	assert.Panics(t, func() { RegisterDetector("test", nil) })
+}
+
+func TestHTTPDetector(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, "image/jpeg", r.Header.Get("Content-Type"))
+		switch string(body) {
+		case "face":
+			_ = json.NewEncoder(w).Encode(httpResponse{Faces: []Face{face(0, 0.1)}})
+		case "outside":
+			_ = json.NewEncoder(w).Encode(httpResponse{Faces: []Face{{Box: Box{X: 0.9, Width: 0.5, Height: 0.1}, Embedding: []float32{1}}}})
+		default:
+			http.Error(w, "bad image", http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+	detector, err := NewHTTPDetector(server.URL, 0)
+	require.NoError(t, err)
+
+	faces, err := detector.Detect(context.Background(), []byte("face"))
+	require.NoError(t, err)
+	assert.Equal(t, []Face{face(0, 0.1)}, faces)
+
+	_, err = detector.Detect(context.Background(), []byte("outside"))
+	assert.ErrorContains(t, err, "outside of the image")
+	_, err = detector.Detect(context.Background(), []byte("junk"))
+	assert.ErrorContains(t, err, "400")
+}
+
+func TestStore_People(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	require.NoError(t, s.ReplaceFaces(ctx, scope, "a/1.jpg", "v1", []Face{face(0, 0.1), face(1, 0.5)}, DefaultMatchThreshold))
+	require.NoError(t, s.ReplaceFaces(ctx, scope, "a/2.jpg", "v1", []Face{face(0, 0.1)}, DefaultMatchThreshold))
+	require.NoError(t, s.ReplaceFaces(ctx, scope, "b/3.jpg", "v1", []Face{face(0, 0.2)}, DefaultMatchThreshold))
+	require.NoError(t, s.ReplaceFaces(ctx, scope, "b/empty.jpg", "v1", nil, DefaultMatchThreshold))
+	require.NoError(t, s.ReplaceFaces(ctx, "space:other", "a/1.jpg", "v1", []Face{face(2, 0.1)}, DefaultMatchThreshold))
+
+	scanned, err := s.Scanned(ctx, scope, "b")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"b/3.jpg": "v1", "b/empty.jpg": "v1"}, scanned)
+
+	people, total, err := s.People(ctx, scope, "", 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	require.Len(t, people, 2)
+	assert.Equal(t, 3, people[0].FaceCount)
+	assert.Equal(t, 1, people[1].FaceCount)
+	assert.Equal(t, "a/1.jpg", people[1].Cover.FilePath)
+	assert.Equal(t, 0.5, people[1].Cover.Box.X)
+	first, second := people[0].ID, people[1].ID
+
+	// Named people come first
+	require.NoError(t, s.NamePerson(ctx, scope, second, "Bob"))
+	people, _, err = s.People(ctx, scope, "", 0, 1)
+	require.NoError(t, err)
+	require.Len(t, people, 1)
+	assert.Equal(t, "Bob", people[0].Name)
+
+	// Counts and covers are limited to the root
+	people, total, err = s.People(ctx, scope, "b", 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, first, people[0].ID)
+	assert.Equal(t, "b/3.jpg", people[0].Cover.FilePath)
+	_, err = s.Person(ctx, scope, second, "b")
+	assert.ErrorIs(t, err, ErrPersonNotFound)
+
+	files, total, err := s.PersonFiles(ctx, scope, first, "", 1, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Equal(t, []string{"a/2.jpg", "b/3.jpg"}, files)
+	files, total, more, err := s.PersonFilesAfter(ctx, scope, first, "", "a/1.jpg", 1)
+	require.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.True(t, more)
+	assert.Equal(t, []string{"a/2.jpg"}, files)
+	files, _, more, err = s.PersonFilesAfter(ctx, scope, first, "", "a/2.jpg", 1)
+	require.NoError(t, err)
+	assert.False(t, more)
+	assert.Equal(t, []string{"b/3.jpg"}, files)
+
+	// People continue after the last person of the previous page
+	people, total, more, err = s.PeopleAfter(ctx, scope, "", nil, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.True(t, more)
+	require.Len(t, people, 1)
+	assert.Equal(t, second, people[0].ID)
+	people, _, more, err = s.PeopleAfter(ctx, scope, "", &people[0], 1)
+	require.NoError(t, err)
+	assert.False(t, more)
+	require.Len(t, people, 1)
+	assert.Equal(t, first, people[0].ID)
+
+	require.NoError(t, s.MergePeople(ctx, scope, second, first))
+	person, err := s.Person(ctx, scope, first, "")
+	require.NoError(t, err)
+	assert.Equal(t, "Bob", person.Name)
+	assert.Equal(t, 4, person.FaceCount)
+	_, err = s.Person(ctx, scope, second, "")
+	assert.ErrorIs(t, err, ErrPersonNotFound)
+	assert.ErrorIs(t, s.NamePerson(ctx, "space:other", first, "Eve"), ErrPersonNotFound)
+
+	// Removing every face of a person drops the person
+	require.NoError(t, s.RemoveFilePath(ctx, scope, "a"))
+	require.NoError(t, s.Remove(ctx, scope, []string{"b/3.jpg"}))
+	people, total, err = s.People(ctx, scope, "", 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 0, total)
+	assert.Empty(t, people)
+	_, err = s.Person(ctx, scope, first, "")
+	assert.ErrorIs(t, err, ErrPersonNotFound)
+	scanned, err = s.Scanned(ctx, scope, "")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"b/empty.jpg": "v1"}, scanned)
+}
+
+func TestStore_ReplaceFacesRescan(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	require.NoError(t, s.ReplaceFaces(ctx, scope, "a.jpg", "v1", []Face{face(0, 0.1)}, DefaultMatchThreshold))
+	require.NoError(t, s.ReplaceFaces(ctx, scope, "b.jpg", "v1", []Face{face(0, 0.1)}, DefaultMatchThreshold))
+	// The edited photo now shows someone else
+	require.NoError(t, s.ReplaceFaces(ctx, scope, "a.jpg", "v2", []Face{face(3, 0.1)}, DefaultMatchThreshold))
+
+	people, total, err := s.People(ctx, scope, "", 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.Equal(t, 1, people[0].FaceCount)
+	assert.Equal(t, 1, people[1].FaceCount)
+	scanned, err := s.Scanned(ctx, scope, "")
+	require.NoError(t, err)
+	assert.Equal(t, "v2", scanned["a.jpg"])
+}
+
+func TestJob_Scan(t *testing.T) {
+	baseDir := t.TempDir()
+	writeFile(t, baseDir, "photos/a.jpg", "alice")
+	writeFile(t, baseDir, "photos/b.jpg", "alice and bob")
+	writeFile(t, baseDir, "photos/c.jpg", "landscape")
+	writeFile(t, baseDir, "photos/notes.txt", "alice")
+	writeFile(t, baseDir, ".imagor-studio/a.jpg", "alice")
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+	detector := contentDetector{
+		"alice":         {face(0, 0.1)},
+		"alice and bob": {face(0, 0.1), face(1, 0.5), {Box: Box{X: 0.8, Y: 0.8, Width: 0.1, Height: 0.1}, Confidence: 0.2, Embedding: []float32{0, 0, 1, 0}}},
+		"landscape":     nil,
+	}
+	s := setupTestStore(t)
+	var rendered []string
+	render := func(ctx context.Context, p string) ([]byte, error) {
+		rendered = append(rendered, p)
+		reader, err := stor.Get(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		return io.ReadAll(reader)
+	}
+	operations := operation.NewManager(zap.NewNop())
+	job := NewJob(NewScanner(s, detector, 0, zap.NewNop()), func() storage.Storage { return stor }, render, operations, zap.NewNop())
+	scan := func() operation.Operation {
+		started := job.Start(context.Background(), "admin")
+		op, err := operations.Wait(context.Background(), started.ID)
+		require.NoError(t, err)
+		return op
+	}
+
+	op := scan()
+	assert.Equal(t, operation.StatusSucceeded, op.Status)
+	assert.Equal(t, "Scanned 3 images, 3 faces found in 3 new or changed", op.Message)
+	people, total, err := s.People(context.Background(), scope, "", 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.Equal(t, 2, people[0].FaceCount)
+
+	// Unchanged images are not detected again, deleted ones are dropped
+	rendered = nil
+	require.NoError(t, os.Remove(filepath.Join(baseDir, "photos/b.jpg")))
+	writeFile(t, baseDir, "photos/d.jpg", "unknown")
+	op = scan()
+	assert.Equal(t, operation.StatusFailed, op.Status)
+	assert.Equal(t, []string{"photos/d.jpg"}, rendered)
+	people, total, err = s.People(context.Background(), scope, "", 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, 1, people[0].FaceCount)
+}
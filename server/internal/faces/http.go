@@ -0,0 +1,76 @@
+package faces
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	defaultHTTPTimeout = 60 * time.Second
+	// maxResponseBytes bounds the detector response read
+	maxResponseBytes = 16 << 20
+)
+
+// HTTPDetector posts images to an external detection service. The service
+// receives the JPEG as the request body and responds with JSON:
+//
+//	{"faces": [{"box": {"x": 0.1, "y": 0.2, "width": 0.3, "height": 0.4},
+//	            "confidence": 0.98, "embedding": [0.12, -0.03, ...]}]}
+type HTTPDetector struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPDetector returns a detector posting to serviceURL
+func NewHTTPDetector(serviceURL string, timeout time.Duration) (*HTTPDetector, error) {
+	u, err := url.Parse(serviceURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("face detector URL must be an absolute http(s) URL: %q", serviceURL)
+	}
+	if timeout <= 0 {
+		timeout = defaultHTTPTimeout
+	}
+	return &HTTPDetector{url: serviceURL, client: &http.Client{Timeout: timeout}}, nil
+}
+
+type httpResponse struct {
+	Faces []Face `json:"faces"`
+}
+
+// Detect implements Detector
+func (d *HTTPDetector) Detect(ctx context.Context, image []byte) ([]Face, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url, bytes.NewReader(image))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "image/jpeg")
+	req.Header.Set("Accept", "application/json")
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("face detector responded %s", resp.Status)
+	}
+	var result httpResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(&result); err != nil {
+		return nil, fmt.Errorf("invalid face detector response: %w", err)
+	}
+	for _, face := range result.Faces {
+		if err := face.Box.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid face detector response: %w", err)
+		}
+		if len(face.Embedding) == 0 {
+			return nil, errors.New("invalid face detector response: face without embedding")
+		}
+	}
+	return result.Faces, nil
+}
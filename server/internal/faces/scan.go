@@ -0,0 +1,209 @@
+package faces
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cshum/imagor-studio/server/internal/dedupe"
+	"github.com/cshum/imagor-studio/server/internal/operation"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"go.uber.org/zap"
+)
+
+// maxFailureResults caps the failed images listed in a scan's results
+const maxFailureResults = 100
+
+// minConfidence is the detector confidence below which faces are ignored
+const minConfidence = 0.5
+
+// Scanner detects faces in images and records them in a store
+type Scanner struct {
+	store     Store
+	detector  Detector
+	threshold float64
+	logger    *zap.Logger
+}
+
+// NewScanner returns a scanner matching faces to people at the cosine
+// similarity threshold, DefaultMatchThreshold when 0
+func NewScanner(store Store, detector Detector, threshold float64, logger *zap.Logger) *Scanner {
+	if threshold <= 0 {
+		threshold = DefaultMatchThreshold
+	}
+	return &Scanner{store: store, detector: detector, threshold: threshold, logger: logger}
+}
+
+// Store returns the store faces are recorded in
+func (s *Scanner) Store() Store {
+	return s.store
+}
+
+// Scan detects the faces in the images below root, rendered for the
+// detector by render, reporting through progress. Images unchanged since
+// their last scan are skipped, and faces of images no longer present are
+// dropped. An image failing does not stop the others; the scan fails at the
+// end when any did.
+func (s *Scanner) Scan(ctx context.Context, stor storage.Storage, scope, root string, render Renderer, progress *operation.Progress) error {
+	progress.SetMessage("Listing images")
+	var images []storage.FileInfo
+	visit := func(info storage.FileInfo) error {
+		if info.Size > 0 && IsImage(info.Path) && !hasHiddenSegment(strings.TrimPrefix(info.Path, root)) {
+			images = append(images, info)
+		}
+		return nil
+	}
+	if err := walk(ctx, stor, root, visit); err != nil {
+		return err
+	}
+	previous, err := s.store.Scanned(ctx, scope, root)
+	if err != nil {
+		return err
+	}
+
+	progress.SetTotal(len(images))
+	progress.SetMessage("Detecting faces")
+	var scanned, failed, found int
+	for _, info := range images {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		fingerprint := dedupe.Fingerprint(info)
+		known, ok := previous[info.Path]
+		delete(previous, info.Path)
+		if ok && known == fingerprint {
+			progress.Advance(1)
+			continue
+		}
+		var faces []Face
+		image, err := render(ctx, info.Path)
+		if err == nil {
+			faces, err = s.detector.Detect(ctx, image)
+		}
+		if err == nil {
+			faces = confident(faces)
+			err = s.store.ReplaceFaces(ctx, scope, info.Path, fingerprint, faces, s.threshold)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			failed++
+			s.logger.Warn("Failed to detect faces", zap.String("path", info.Path), zap.Error(err))
+			if failed <= maxFailureResults {
+				progress.Advance(1, info.Path+": "+err.Error())
+			} else {
+				progress.Advance(1)
+			}
+			continue
+		}
+		scanned++
+		found += len(faces)
+		progress.Advance(1)
+	}
+
+	// Whatever is left was deleted or moved away since the last scan
+	removed := make([]string, 0, len(previous))
+	for p := range previous {
+		removed = append(removed, p)
+	}
+	if err := s.store.Remove(ctx, scope, removed); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d images failed face detection", failed, len(images))
+	}
+	progress.SetMessage(fmt.Sprintf("Scanned %d images, %d faces found in %d new or changed", len(images), found, scanned))
+	return nil
+}
+
+// confident drops faces detected below minConfidence
+func confident(faces []Face) []Face {
+	result := faces[:0]
+	for _, face := range faces {
+		if face.Confidence >= minConfidence {
+			result = append(result, face)
+		}
+	}
+	return result
+}
+
+// walk calls fn for the files below root outside hidden folders, in one
+// flat listing when the backend supports it
+func walk(ctx context.Context, stor storage.Storage, root string, fn func(storage.FileInfo) error) error {
+	if walker, ok := stor.(storage.Walker); ok {
+		if err := walker.Walk(ctx, root, fn); err != nil {
+			return fmt.Errorf("failed to list images: %w", err)
+		}
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	result, err := stor.List(ctx, root, storage.ListOptions{ShowHidden: true})
+	if err != nil {
+		return fmt.Errorf("failed to list images: %w", err)
+	}
+	for _, item := range result.Items {
+		if item.IsDir {
+			if storage.IsHiddenFile(item.Name) {
+				continue
+			}
+			if err := walk(ctx, stor, item.Path, fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasHiddenSegment reports whether any element of p starts with "."
+func hasHiddenSegment(p string) bool {
+	for _, segment := range strings.Split(p, "/") {
+		if storage.IsHiddenFile(segment) {
+			return true
+		}
+	}
+	return false
+}
+
+// Job scans the default storage on a schedule through the operation manager
+type Job struct {
+	scanner    *Scanner
+	storage    func() storage.Storage
+	render     Renderer
+	operations *operation.Manager
+	logger     *zap.Logger
+}
+
+// NewJob returns a job scanning the storage returned by stor
+func NewJob(scanner *Scanner, stor func() storage.Storage, render Renderer, operations *operation.Manager, logger *zap.Logger) *Job {
+	return &Job{scanner: scanner, storage: stor, render: render, operations: operations, logger: logger}
+}
+
+// Start begins a scan of the whole default storage on behalf of ownerID. If
+// a scan is already in progress it is returned instead of starting another.
+func (j *Job) Start(ctx context.Context, ownerID string) operation.Operation {
+	op, started := j.operations.StartExclusive(ctx, OperationKind, ownerID, func(ctx context.Context, progress *operation.Progress) error {
+		stor := j.storage()
+		if stor == nil {
+			return fmt.Errorf("storage is not configured")
+		}
+		return j.scanner.Scan(ctx, stor, registrystore.SystemOwnerID, "", j.render, progress)
+	})
+	if started {
+		j.logger.Info("Face scan started", zap.String("id", op.ID), zap.String("owner", ownerID))
+	}
+	return op
+}
+
+// Sync starts a scheduled scan, for use with the server sync loop
+func (j *Job) Sync() error {
+	j.Start(context.Background(), registrystore.SystemOwnerID)
+	return nil
+}
@@ -0,0 +1,588 @@
+package faces
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/database"
+	"github.com/cshum/imagor-studio/server/internal/model"
+	"github.com/cshum/imagor-studio/server/pkg/uuid"
+	"github.com/uptrace/bun"
+	"go.uber.org/zap"
+)
+
+// ErrPersonNotFound is returned for a person missing from the scope
+var ErrPersonNotFound = errors.New("person not found")
+
+// Person is a group of faces taken for one person
+type Person struct {
+	ID string
+	// Name is empty until a user names the person
+	Name      string
+	FaceCount int
+	// Cover is the most confidently detected face of the person
+	Cover *FaceRef
+}
+
+// FaceRef locates a recorded face
+type FaceRef struct {
+	FilePath   string
+	Box        Box
+	Confidence float64
+}
+
+// Store records faces and people per scope
+type Store interface {
+	// Scanned returns the fingerprints of the images below root scanned
+	// for faces, keyed by path, every image of the scope when root is empty
+	Scanned(ctx context.Context, scope, root string) (map[string]string, error)
+	// ReplaceFaces records the faces found in an image, replacing those of
+	// a previous scan. Each face joins the person whose centroid is the
+	// most similar above threshold, or starts a new person.
+	ReplaceFaces(ctx context.Context, scope, path, fingerprint string, faces []Face, threshold float64) error
+	// Remove drops the faces and scans of the given files
+	Remove(ctx context.Context, scope string, paths []string) error
+	// RemoveFilePath drops the faces and scans of a file, or of every file
+	// below a folder
+	RemoveFilePath(ctx context.Context, scope, path string) error
+	// People returns the people with faces below root, named people first
+	// then by face count, with the total
+	People(ctx context.Context, scope, root string, offset, limit int) ([]Person, int, error)
+	// PeopleAfter returns the people below root listed after the given
+	// person, a person of the previous page with the name and face count it
+	// was listed with, from the first when nil. It returns the total and
+	// whether more follow the page.
+	PeopleAfter(ctx context.Context, scope, root string, after *Person, limit int) ([]Person, int, bool, error)
+	// Person returns a person with the faces below root counted
+	Person(ctx context.Context, scope, id, root string) (*Person, error)
+	// NamePerson sets the name of a person, empty to clear it
+	NamePerson(ctx context.Context, scope, id, name string) error
+	// MergePeople moves the faces of source to target and drops source
+	MergePeople(ctx context.Context, scope, sourceID, targetID string) error
+	// PersonFiles returns the files below root showing a person, by path,
+	// with the total
+	PersonFiles(ctx context.Context, scope, id, root string, offset, limit int) ([]string, int, error)
+	// PersonFilesAfter returns the files below root showing a person with
+	// paths after the given one, from the first when empty, with the total
+	// and whether more follow the page
+	PersonFilesAfter(ctx context.Context, scope, id, root, after string, limit int) ([]string, int, bool, error)
+}
+
+// chunkSize keeps IN lists below the SQLite parameter limit
+const chunkSize = 500
+
+type store struct {
+	db     *bun.DB
+	logger *zap.Logger
+}
+
+func NewStore(db *bun.DB, logger *zap.Logger) Store {
+	return &store{db: db, logger: logger}
+}
+
+// belowExpr is the condition restricting file_path to root and the files
+// under it
+const belowExpr = "(file_path = ? OR substr(file_path, 1, ?) = ?)"
+
+// below restricts q to root and the files under it
+func below(q *bun.SelectQuery, root string) *bun.SelectQuery {
+	if root == "" {
+		return q
+	}
+	return q.Where(belowExpr, root, len(root)+1, root+"/")
+}
+
+func (s *store) Scanned(ctx context.Context, scope, root string) (map[string]string, error) {
+	var rows []model.FaceScan
+	if err := below(s.db.NewSelect().Model(&rows).Where("scope = ?", scope), root).Scan(ctx); err != nil {
+		return nil, fmt.Errorf("error listing face scans: %w", err)
+	}
+	result := make(map[string]string, len(rows))
+	for _, row := range rows {
+		result[row.FilePath] = row.Fingerprint
+	}
+	return result, nil
+}
+
+func (s *store) ReplaceFaces(ctx context.Context, scope, path, fingerprint string, faces []Face, threshold float64) error {
+	now := time.Now().UTC()
+	return s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if err := removeFaces(ctx, tx, scope, func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Where("file_path = ?", path)
+		}); err != nil {
+			return err
+		}
+
+		var people []model.Person
+		if err := tx.NewSelect().Model(&people).Where("scope = ?", scope).Scan(ctx); err != nil {
+			return fmt.Errorf("error listing people: %w", err)
+		}
+		centroids := make([][]float32, len(people))
+		for i, p := range people {
+			centroid, err := decodeEmbedding(p.Centroid)
+			if err != nil {
+				s.logger.Warn("Invalid person centroid", zap.String("person", p.ID), zap.Error(err))
+			}
+			centroids[i] = centroid
+		}
+		// Two faces in one photo are two people
+		matched := make(map[int]bool)
+		for _, face := range faces {
+			embedding, ok := normalize(face.Embedding)
+			if !ok {
+				continue
+			}
+			best, bestSimilarity := -1, threshold
+			for i, centroid := range centroids {
+				if matched[i] {
+					continue
+				}
+				if similarity := Similarity(embedding, centroid); similarity >= bestSimilarity {
+					best, bestSimilarity = i, similarity
+				}
+			}
+			var personID string
+			if best >= 0 {
+				p := &people[best]
+				n := float32(p.FaceCount)
+				centroid := centroids[best]
+				for i := range centroid {
+					centroid[i] = (centroid[i]*n + embedding[i]) / (n + 1)
+				}
+				p.FaceCount++
+				if _, err := tx.NewUpdate().Model((*model.Person)(nil)).
+					Set("centroid = ?", encodeEmbedding(centroid)).
+					Set("face_count = ?", p.FaceCount).
+					Set("updated_at = ?", now).
+					Where("id = ?", p.ID).
+					Exec(ctx); err != nil {
+					return fmt.Errorf("error updating person: %w", err)
+				}
+				matched[best] = true
+				personID = p.ID
+			} else {
+				p := model.Person{
+					ID:        uuid.GenerateUUID(),
+					Scope:     scope,
+					Centroid:  encodeEmbedding(embedding),
+					FaceCount: 1,
+					CreatedAt: now,
+					UpdatedAt: now,
+				}
+				if _, err := tx.NewInsert().Model(&p).Exec(ctx); err != nil {
+					return fmt.Errorf("error creating person: %w", err)
+				}
+				people = append(people, p)
+				centroids = append(centroids, embedding)
+				matched[len(people)-1] = true
+				personID = p.ID
+			}
+			row := &model.Face{
+				ID:         uuid.GenerateUUID(),
+				Scope:      scope,
+				FilePath:   path,
+				PersonID:   personID,
+				BoxX:       face.Box.X,
+				BoxY:       face.Box.Y,
+				BoxWidth:   face.Box.Width,
+				BoxHeight:  face.Box.Height,
+				Confidence: face.Confidence,
+				Embedding:  encodeEmbedding(embedding),
+				DetectedAt: now,
+			}
+			if _, err := tx.NewInsert().Model(row).Exec(ctx); err != nil {
+				return fmt.Errorf("error saving face: %w", err)
+			}
+		}
+
+		if _, err := database.OnConflictUpdate(tx.NewInsert().Model(&model.FaceScan{
+			ID:          uuid.GenerateUUID(),
+			Scope:       scope,
+			FilePath:    path,
+			Fingerprint: fingerprint,
+			ScannedAt:   now,
+		}), "scope, file_path", "fingerprint", "scanned_at").Exec(ctx); err != nil {
+			return fmt.Errorf("error saving face scan: %w", err)
+		}
+		return nil
+	})
+}
+
+// removeFaces deletes the faces of scope matched by where, then refreshes
+// the people they belonged to
+func removeFaces(ctx context.Context, tx bun.Tx, scope string, where func(*bun.SelectQuery) *bun.SelectQuery) error {
+	var personIDs []string
+	if err := where(tx.NewSelect().Model((*model.Face)(nil)).
+		Column("person_id").
+		Distinct().
+		Where("scope = ?", scope)).
+		Scan(ctx, &personIDs); err != nil {
+		return fmt.Errorf("error listing faces: %w", err)
+	}
+	if len(personIDs) == 0 {
+		return nil
+	}
+	ids := tx.NewSelect().Model((*model.Face)(nil)).Column("id").Where("scope = ?", scope)
+	if _, err := tx.NewDelete().Model((*model.Face)(nil)).
+		Where("id IN (?)", where(ids)).
+		Exec(ctx); err != nil {
+		return fmt.Errorf("error removing faces: %w", err)
+	}
+	return refreshPeople(ctx, tx, personIDs)
+}
+
+// refreshPeople recomputes the centroid and face count of people from their
+// faces, dropping people left without any
+func refreshPeople(ctx context.Context, tx bun.Tx, ids []string) error {
+	for _, id := range ids {
+		var rows []model.Face
+		if err := tx.NewSelect().Model(&rows).
+			Column("embedding").
+			Where("person_id = ?", id).
+			Scan(ctx); err != nil {
+			return fmt.Errorf("error listing faces: %w", err)
+		}
+		if len(rows) == 0 {
+			if _, err := tx.NewDelete().Model((*model.Person)(nil)).Where("id = ?", id).Exec(ctx); err != nil {
+				return fmt.Errorf("error removing person: %w", err)
+			}
+			continue
+		}
+		var centroid []float32
+		for _, row := range rows {
+			embedding, err := decodeEmbedding(row.Embedding)
+			if err != nil {
+				continue
+			}
+			if centroid == nil {
+				centroid = make([]float32, len(embedding))
+			}
+			if len(embedding) != len(centroid) {
+				continue
+			}
+			for i := range centroid {
+				centroid[i] += embedding[i] / float32(len(rows))
+			}
+		}
+		if _, err := tx.NewUpdate().Model((*model.Person)(nil)).
+			Set("centroid = ?", encodeEmbedding(centroid)).
+			Set("face_count = ?", len(rows)).
+			Set("updated_at = ?", time.Now().UTC()).
+			Where("id = ?", id).
+			Exec(ctx); err != nil {
+			return fmt.Errorf("error updating person: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *store) Remove(ctx context.Context, scope string, paths []string) error {
+	for start := 0; start < len(paths); start += chunkSize {
+		chunk := paths[start:min(start+chunkSize, len(paths))]
+		if err := s.remove(ctx, scope, func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Where("file_path IN (?)", bun.In(chunk))
+		}, func(q *bun.DeleteQuery) *bun.DeleteQuery {
+			return q.Where("file_path IN (?)", bun.In(chunk))
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *store) RemoveFilePath(ctx context.Context, scope, path string) error {
+	return s.remove(ctx, scope, func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.Where(belowExpr, path, len(path)+1, path+"/")
+	}, func(q *bun.DeleteQuery) *bun.DeleteQuery {
+		return q.Where(belowExpr, path, len(path)+1, path+"/")
+	})
+}
+
+// remove drops the faces and scans of the files matched by the conditions
+func (s *store) remove(ctx context.Context, scope string, selectWhere func(*bun.SelectQuery) *bun.SelectQuery, deleteWhere func(*bun.DeleteQuery) *bun.DeleteQuery) error {
+	return s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if err := removeFaces(ctx, tx, scope, selectWhere); err != nil {
+			return err
+		}
+		if _, err := deleteWhere(tx.NewDelete().Model((*model.FaceScan)(nil)).Where("scope = ?", scope)).Exec(ctx); err != nil {
+			return fmt.Errorf("error removing face scans: %w", err)
+		}
+		return nil
+	})
+}
+
+// faceCount is the number of faces of a person below a root
+type faceCount struct {
+	PersonID string `bun:"person_id"`
+	Count    int    `bun:"count"`
+}
+
+// countFaces returns the faces below root per person, of the given people
+// or everyone in the scope when ids is nil
+func (s *store) countFaces(ctx context.Context, scope, root string, ids []string) ([]faceCount, error) {
+	var counts []faceCount
+	q := s.db.NewSelect().Model((*model.Face)(nil)).
+		Column("person_id").
+		ColumnExpr("COUNT(*) AS count").
+		Where("scope = ?", scope)
+	if ids != nil {
+		q = q.Where("person_id IN (?)", bun.In(ids))
+	}
+	if err := below(q, root).Group("person_id").Scan(ctx, &counts); err != nil {
+		return nil, fmt.Errorf("error counting faces: %w", err)
+	}
+	return counts, nil
+}
+
+// toPeople returns the people of counts with their names and covers below
+// root, in the order of counts
+func (s *store) toPeople(ctx context.Context, scope, root string, counts []faceCount, names map[string]string) ([]Person, error) {
+	if len(counts) == 0 {
+		return nil, nil
+	}
+	ids := make([]string, len(counts))
+	for i, c := range counts {
+		ids[i] = c.PersonID
+	}
+	var faces []model.Face
+	q := s.db.NewSelect().Model(&faces).
+		Column("person_id", "file_path", "box_x", "box_y", "box_width", "box_height", "confidence").
+		Where("scope = ?", scope).
+		Where("person_id IN (?)", bun.In(ids))
+	if err := below(q, root).Order("confidence DESC", "file_path ASC").Scan(ctx); err != nil {
+		return nil, fmt.Errorf("error listing faces: %w", err)
+	}
+	covers := make(map[string]*FaceRef, len(ids))
+	for _, f := range faces {
+		if covers[f.PersonID] == nil {
+			covers[f.PersonID] = &FaceRef{
+				FilePath:   f.FilePath,
+				Box:        Box{X: f.BoxX, Y: f.BoxY, Width: f.BoxWidth, Height: f.BoxHeight},
+				Confidence: f.Confidence,
+			}
+		}
+	}
+	people := make([]Person, len(counts))
+	for i, c := range counts {
+		people[i] = Person{ID: c.PersonID, Name: names[c.PersonID], FaceCount: c.Count, Cover: covers[c.PersonID]}
+	}
+	return people, nil
+}
+
+func (s *store) People(ctx context.Context, scope, root string, offset, limit int) ([]Person, int, error) {
+	counts, names, err := s.rankPeople(ctx, scope, root)
+	if err != nil {
+		return nil, 0, err
+	}
+	total := len(counts)
+	if offset >= total {
+		return nil, total, nil
+	}
+	people, err := s.toPeople(ctx, scope, root, counts[offset:min(offset+limit, total)], names)
+	return people, total, err
+}
+
+func (s *store) PeopleAfter(ctx context.Context, scope, root string, after *Person, limit int) ([]Person, int, bool, error) {
+	counts, names, err := s.rankPeople(ctx, scope, root)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	total := len(counts)
+	start := 0
+	if after != nil {
+		// The ranking of the person when listed, the person may have been
+		// named, merged or rescanned since
+		start = sort.Search(total, func(i int) bool {
+			return ranksBefore(after.Name, after.FaceCount, after.ID,
+				names[counts[i].PersonID], counts[i].Count, counts[i].PersonID)
+		})
+	}
+	end := min(start+limit, total)
+	people, err := s.toPeople(ctx, scope, root, counts[start:end], names)
+	return people, total, end < total, err
+}
+
+// rankPeople returns the face counts below root in the order people are
+// listed, with the names of named people
+func (s *store) rankPeople(ctx context.Context, scope, root string) ([]faceCount, map[string]string, error) {
+	counts, err := s.countFaces(ctx, scope, root, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	var named []model.Person
+	if err := s.db.NewSelect().Model(&named).
+		Column("id", "name").
+		Where("scope = ?", scope).
+		Where("name IS NOT NULL").
+		Scan(ctx); err != nil {
+		return nil, nil, fmt.Errorf("error listing people: %w", err)
+	}
+	names := make(map[string]string, len(named))
+	for _, p := range named {
+		names[p.ID] = p.Name
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		return ranksBefore(names[counts[i].PersonID], counts[i].Count, counts[i].PersonID,
+			names[counts[j].PersonID], counts[j].Count, counts[j].PersonID)
+	})
+	return counts, names, nil
+}
+
+// ranksBefore reports whether person a is listed before person b, named
+// people first, then by face count, name and ID
+func ranksBefore(nameA string, countA int, idA string, nameB string, countB int, idB string) bool {
+	if (nameA != "") != (nameB != "") {
+		return nameA != ""
+	}
+	if countA != countB {
+		return countA > countB
+	}
+	if nameA != nameB {
+		return nameA < nameB
+	}
+	return idA < idB
+}
+
+func (s *store) getPerson(ctx context.Context, db bun.IDB, scope, id string) (*model.Person, error) {
+	var p model.Person
+	if err := db.NewSelect().Model(&p).
+		Where("scope = ?", scope).
+		Where("id = ?", id).
+		Scan(ctx); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrPersonNotFound
+		}
+		return nil, fmt.Errorf("error getting person: %w", err)
+	}
+	return &p, nil
+}
+
+func (s *store) Person(ctx context.Context, scope, id, root string) (*Person, error) {
+	p, err := s.getPerson(ctx, s.db, scope, id)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := s.countFaces(ctx, scope, root, []string{id})
+	if err != nil {
+		return nil, err
+	}
+	if len(counts) == 0 {
+		return nil, ErrPersonNotFound
+	}
+	people, err := s.toPeople(ctx, scope, root, counts, map[string]string{p.ID: p.Name})
+	if err != nil {
+		return nil, err
+	}
+	return &people[0], nil
+}
+
+func (s *store) NamePerson(ctx context.Context, scope, id, name string) error {
+	if _, err := s.getPerson(ctx, s.db, scope, id); err != nil {
+		return err
+	}
+	var value interface{}
+	if name != "" {
+		value = name
+	}
+	if _, err := s.db.NewUpdate().Model((*model.Person)(nil)).
+		Set("name = ?", value).
+		Set("updated_at = ?", time.Now().UTC()).
+		Where("scope = ?", scope).
+		Where("id = ?", id).
+		Exec(ctx); err != nil {
+		return fmt.Errorf("error naming person: %w", err)
+	}
+	return nil
+}
+
+func (s *store) MergePeople(ctx context.Context, scope, sourceID, targetID string) error {
+	return s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		source, err := s.getPerson(ctx, tx, scope, sourceID)
+		if err != nil {
+			return err
+		}
+		target, err := s.getPerson(ctx, tx, scope, targetID)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.NewUpdate().Model((*model.Face)(nil)).
+			Set("person_id = ?", target.ID).
+			Where("person_id = ?", source.ID).
+			Exec(ctx); err != nil {
+			return fmt.Errorf("error merging people: %w", err)
+		}
+		// The name given to either is kept, the target's first
+		if target.Name == "" && source.Name != "" {
+			if _, err := tx.NewUpdate().Model((*model.Person)(nil)).
+				Set("name = ?", source.Name).
+				Where("id = ?", target.ID).
+				Exec(ctx); err != nil {
+				return fmt.Errorf("error merging people: %w", err)
+			}
+		}
+		return refreshPeople(ctx, tx, []string{source.ID, target.ID})
+	})
+}
+
+func (s *store) PersonFiles(ctx context.Context, scope, id, root string, offset, limit int) ([]string, int, error) {
+	total, err := s.countPersonFiles(ctx, scope, id, root)
+	if err != nil {
+		return nil, 0, err
+	}
+	var paths []string
+	if err := s.personFiles(scope, id, root).
+		Column("file_path").
+		Group("file_path").
+		Order("file_path ASC").
+		Offset(offset).
+		Limit(limit).
+		Scan(ctx, &paths); err != nil {
+		return nil, 0, fmt.Errorf("error listing person files: %w", err)
+	}
+	return paths, total, nil
+}
+
+func (s *store) PersonFilesAfter(ctx context.Context, scope, id, root, after string, limit int) ([]string, int, bool, error) {
+	total, err := s.countPersonFiles(ctx, scope, id, root)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	q := s.personFiles(scope, id, root)
+	if after != "" {
+		q = q.Where("file_path > ?", after)
+	}
+	// One more row than the page tells whether another page follows
+	var paths []string
+	if err := q.
+		Column("file_path").
+		Group("file_path").
+		Order("file_path ASC").
+		Limit(limit+1).
+		Scan(ctx, &paths); err != nil {
+		return nil, 0, false, fmt.Errorf("error listing person files: %w", err)
+	}
+	more := len(paths) > limit
+	if more {
+		paths = paths[:limit]
+	}
+	return paths, total, more, nil
+}
+
+// personFiles selects the faces of a person below root
+func (s *store) personFiles(scope, id, root string) *bun.SelectQuery {
+	return below(s.db.NewSelect().Model((*model.Face)(nil)).
+		Where("scope = ?", scope).
+		Where("person_id = ?", id), root)
+}
+
+func (s *store) countPersonFiles(ctx context.Context, scope, id, root string) (int, error) {
+	total, err := s.db.NewSelect().TableExpr("(?) AS person_files", s.personFiles(scope, id, root).ColumnExpr("DISTINCT file_path")).Count(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error counting person files: %w", err)
+	}
+	return total, nil
+}
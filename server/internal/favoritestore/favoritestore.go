@@ -0,0 +1,178 @@
+// Package favoritestore persists the files and folders each user starred,
+// so favorites follow the user across devices.
+package favoritestore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/model"
+	"github.com/cshum/imagor-studio/server/pkg/uuid"
+	"github.com/uptrace/bun"
+	"go.uber.org/zap"
+)
+
+// containsChunkSize keeps IN lists below the SQLite parameter limit
+const containsChunkSize = 500
+
+// Favorite is a starred file or folder
+type Favorite struct {
+	Path      string
+	CreatedAt time.Time
+}
+
+type Store interface {
+	// Set stars or unstars a path for a user. Starring a path twice keeps
+	// the original time.
+	Set(ctx context.Context, userID, scope, filePath string, favorite bool) error
+	// List returns the favorites of a user in scope, most recent first
+	List(ctx context.Context, userID, scope string) ([]Favorite, error)
+	// Contains returns which of paths the user starred
+	Contains(ctx context.Context, userID, scope string, paths []string) (map[string]bool, error)
+	// MoveFilePath rewrites the favorites of every user for a file, or for
+	// every file below a folder, after it has been moved
+	MoveFilePath(ctx context.Context, scope, oldPath, newPath string) error
+	// RemoveFilePath drops the favorites of every user for a file, or for
+	// every file below a folder, after it has been deleted
+	RemoveFilePath(ctx context.Context, scope, path string) error
+}
+
+type store struct {
+	db     *bun.DB
+	logger *zap.Logger
+}
+
+func New(db *bun.DB, logger *zap.Logger) Store {
+	return &store{db: db, logger: logger}
+}
+
+func (s *store) Set(ctx context.Context, userID, scope, filePath string, favorite bool) error {
+	if !favorite {
+		if _, err := s.db.NewDelete().Model((*model.Favorite)(nil)).
+			Where("user_id = ?", userID).
+			Where("scope = ?", scope).
+			Where("file_path = ?", filePath).
+			Exec(ctx); err != nil {
+			return fmt.Errorf("error removing favorite: %w", err)
+		}
+		return nil
+	}
+	return s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		exists, err := tx.NewSelect().Model((*model.Favorite)(nil)).
+			Where("user_id = ?", userID).
+			Where("scope = ?", scope).
+			Where("file_path = ?", filePath).
+			Exists(ctx)
+		if err != nil {
+			return fmt.Errorf("error checking favorite: %w", err)
+		}
+		if exists {
+			return nil
+		}
+		if _, err := tx.NewInsert().Model(&model.Favorite{
+			ID:        uuid.GenerateUUID(),
+			UserID:    userID,
+			Scope:     scope,
+			FilePath:  filePath,
+			CreatedAt: time.Now().UTC(),
+		}).Exec(ctx); err != nil {
+			return fmt.Errorf("error saving favorite: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *store) List(ctx context.Context, userID, scope string) ([]Favorite, error) {
+	var rows []model.Favorite
+	if err := s.db.NewSelect().Model(&rows).
+		Where("user_id = ?", userID).
+		Where("scope = ?", scope).
+		Order("created_at DESC", "file_path ASC").
+		Scan(ctx); err != nil {
+		return nil, fmt.Errorf("error listing favorites: %w", err)
+	}
+	favorites := make([]Favorite, 0, len(rows))
+	for _, row := range rows {
+		favorites = append(favorites, Favorite{Path: row.FilePath, CreatedAt: row.CreatedAt})
+	}
+	return favorites, nil
+}
+
+func (s *store) Contains(ctx context.Context, userID, scope string, paths []string) (map[string]bool, error) {
+	result := make(map[string]bool)
+	for start := 0; start < len(paths); start += containsChunkSize {
+		end := min(start+containsChunkSize, len(paths))
+		var starred []string
+		if err := s.db.NewSelect().Model((*model.Favorite)(nil)).
+			Column("file_path").
+			Where("user_id = ?", userID).
+			Where("scope = ?", scope).
+			Where("file_path IN (?)", bun.In(paths[start:end])).
+			Scan(ctx, &starred); err != nil {
+			return nil, fmt.Errorf("error getting favorites: %w", err)
+		}
+		for _, p := range starred {
+			result[p] = true
+		}
+	}
+	return result, nil
+}
+
+func (s *store) MoveFilePath(ctx context.Context, scope, oldPath, newPath string) error {
+	return s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		rows, err := favoritesUnder(ctx, tx, scope, oldPath)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			moved := newPath + strings.TrimPrefix(row.FilePath, oldPath)
+			// The user may already have starred the destination
+			exists, err := tx.NewSelect().Model((*model.Favorite)(nil)).
+				Where("user_id = ?", row.UserID).
+				Where("scope = ?", scope).
+				Where("file_path = ?", moved).
+				Exists(ctx)
+			if err != nil {
+				return fmt.Errorf("error moving favorites: %w", err)
+			}
+			if exists {
+				_, err = tx.NewDelete().Model((*model.Favorite)(nil)).
+					Where("id = ?", row.ID).
+					Exec(ctx)
+			} else {
+				_, err = tx.NewUpdate().Model((*model.Favorite)(nil)).
+					Set("file_path = ?", moved).
+					Where("id = ?", row.ID).
+					Exec(ctx)
+			}
+			if err != nil {
+				return fmt.Errorf("error moving favorites: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+func (s *store) RemoveFilePath(ctx context.Context, scope, path string) error {
+	if _, err := s.db.NewDelete().Model((*model.Favorite)(nil)).
+		Where("scope = ?", scope).
+		Where("(file_path = ? OR substr(file_path, 1, ?) = ?)", path, len(path)+1, path+"/").
+		Exec(ctx); err != nil {
+		return fmt.Errorf("error removing favorites: %w", err)
+	}
+	return nil
+}
+
+// favoritesUnder returns favorites of path itself and any file below it
+func favoritesUnder(ctx context.Context, db bun.IDB, scope, path string) ([]model.Favorite, error) {
+	var rows []model.Favorite
+	if err := db.NewSelect().Model(&rows).
+		Where("scope = ?", scope).
+		Where("(file_path = ? OR substr(file_path, 1, ?) = ?)", path, len(path)+1, path+"/").
+		Scan(ctx); err != nil {
+		return nil, fmt.Errorf("error listing favorites: %w", err)
+	}
+	return rows, nil
+}
@@ -0,0 +1,72 @@
+package favoritestore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const scope = "system:global"
+
+func setupTestStore(t *testing.T) Store {
+	t.Helper()
+	db := testutil.NewDB(t)
+
+	return New(db, zap.NewNop())
+}
+
+func favoritePaths(t *testing.T, s Store, userID string) []string {
+	t.Helper()
+	favorites, err := s.List(context.Background(), userID, scope)
+	require.NoError(t, err)
+	paths := make([]string, 0, len(favorites))
+	for _, f := range favorites {
+		paths = append(paths, f.Path)
+	}
+	return paths
+}
+
+func TestStore_SetAndList(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	require.NoError(t, s.Set(ctx, "alice", scope, "photos/a.jpg", true))
+	require.NoError(t, s.Set(ctx, "alice", scope, "photos/a.jpg", true))
+	require.NoError(t, s.Set(ctx, "alice", scope, "albums", true))
+	require.NoError(t, s.Set(ctx, "bob", scope, "photos/b.jpg", true))
+	require.NoError(t, s.Set(ctx, "alice", "space:other", "photos/c.jpg", true))
+
+	assert.ElementsMatch(t, []string{"albums", "photos/a.jpg"}, favoritePaths(t, s, "alice"))
+	assert.Equal(t, []string{"photos/b.jpg"}, favoritePaths(t, s, "bob"))
+
+	starred, err := s.Contains(ctx, "alice", scope, []string{"photos/a.jpg", "photos/b.jpg", "albums"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"photos/a.jpg": true, "albums": true}, starred)
+
+	require.NoError(t, s.Set(ctx, "alice", scope, "photos/a.jpg", false))
+	require.NoError(t, s.Set(ctx, "alice", scope, "photos/missing.jpg", false))
+	assert.Equal(t, []string{"albums"}, favoritePaths(t, s, "alice"))
+}
+
+func TestStore_MoveAndRemove(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	require.NoError(t, s.Set(ctx, "alice", scope, "photos/a.jpg", true))
+	require.NoError(t, s.Set(ctx, "alice", scope, "photos/nested/b.jpg", true))
+	require.NoError(t, s.Set(ctx, "alice", scope, "archive/a.jpg", true))
+	require.NoError(t, s.Set(ctx, "bob", scope, "photos/a.jpg", true))
+	require.NoError(t, s.Set(ctx, "bob", scope, "photos-2024/c.jpg", true))
+
+	require.NoError(t, s.MoveFilePath(ctx, scope, "photos", "archive"))
+	assert.ElementsMatch(t, []string{"archive/a.jpg", "archive/nested/b.jpg"}, favoritePaths(t, s, "alice"))
+	assert.ElementsMatch(t, []string{"archive/a.jpg", "photos-2024/c.jpg"}, favoritePaths(t, s, "bob"))
+
+	require.NoError(t, s.RemoveFilePath(ctx, scope, "archive"))
+	assert.Empty(t, favoritePaths(t, s, "alice"))
+	assert.Equal(t, []string{"photos-2024/c.jpg"}, favoritePaths(t, s, "bob"))
+}
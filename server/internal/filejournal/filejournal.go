@@ -0,0 +1,376 @@
+// Package filejournal keeps a numbered journal of the changes of the default
+// storage, for sync clients such as a mobile app or a backup tool to fetch
+// what changed since they last synced instead of listing the whole library.
+//
+// Changes made through the server are recorded from the file events it
+// publishes. Those are best effort and never see files copied into the
+// storage behind the server's back on storages that are not watched, so
+// library scans also diff the files they find against those of the previous
+// scan, and record the differences no recorded event accounts for. A change
+// may be recorded twice, by an event and by a scan, so clients must apply
+// changes idempotently.
+package filejournal
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/events"
+	"github.com/cshum/imagor-studio/server/internal/filemeta"
+	"github.com/cshum/imagor-studio/server/internal/model"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/uptrace/bun"
+	"go.uber.org/zap"
+)
+
+// MaxLimit caps the changes returned by List
+const MaxLimit = 1000
+
+// snapshotBatchSize is the snapshot rows written per statement
+const snapshotBatchSize = 500
+
+// Entry is a recorded change
+type Entry struct {
+	// Seq numbers changes in the order they were recorded
+	Seq int64
+	// Kind is the kind of change, events.StorageChanged when the default
+	// storage was reconfigured and every earlier change is void
+	Kind events.Kind
+	Path string
+	// OldPath is where a moved file or folder was before
+	OldPath   string
+	ChangedAt time.Time
+}
+
+type Store interface {
+	// Record appends a change to the journal. A StorageChanged event also
+	// drops the snapshot of the library scans, as the files it lists are
+	// no longer those of the storage.
+	Record(ctx context.Context, e events.Event) error
+	// Latest returns the number of the last change recorded, 0 when none
+	Latest(ctx context.Context) (int64, error)
+	// List returns up to limit changes numbered after after and up to
+	// until, oldest first, that touch folder or anything below it, the
+	// storage root being "". Storage reconfigurations are always returned.
+	// It also reports whether more changes follow the page.
+	List(ctx context.Context, after, until int64, folder string, limit int) ([]*Entry, bool, error)
+	// Reconcile records the differences between files, the files found by
+	// a library scan started at scannedAt, and those of the previous scan,
+	// skipping those the changes recorded meanwhile account for, and
+	// returns how many were recorded. The first scan only takes a snapshot.
+	Reconcile(ctx context.Context, files []storage.FileInfo, scannedAt time.Time) (int, error)
+	// Prune deletes changes recorded before cutoff, returning how many
+	Prune(ctx context.Context, cutoff time.Time) (int, error)
+}
+
+type store struct {
+	db     *bun.DB
+	logger *zap.Logger
+}
+
+func New(db *bun.DB, logger *zap.Logger) Store {
+	return &store{db: db, logger: logger}
+}
+
+func (s *store) Record(ctx context.Context, e events.Event) error {
+	row := &model.FileJournalEntry{
+		Kind:      string(e.Kind),
+		Path:      e.Path,
+		OldPath:   e.OldPath,
+		ChangedAt: time.Now().UTC(),
+	}
+	return s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewInsert().Model(row).Exec(ctx); err != nil {
+			return fmt.Errorf("error recording file change: %w", err)
+		}
+		if e.Kind != events.StorageChanged {
+			return nil
+		}
+		if _, err := tx.NewDelete().Model((*model.FileJournalSnapshot)(nil)).Where("1 = 1").Exec(ctx); err != nil {
+			return fmt.Errorf("error dropping file snapshot: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *store) Latest(ctx context.Context) (int64, error) {
+	var seq int64
+	if err := s.db.NewSelect().Model((*model.FileJournalEntry)(nil)).
+		ColumnExpr("COALESCE(MAX(seq), 0)").
+		Scan(ctx, &seq); err != nil {
+		return 0, fmt.Errorf("error reading latest file change: %w", err)
+	}
+	return seq, nil
+}
+
+func (s *store) List(ctx context.Context, after, until int64, folder string, limit int) ([]*Entry, bool, error) {
+	if limit <= 0 || limit > MaxLimit {
+		limit = MaxLimit
+	}
+	var rows []model.FileJournalEntry
+	q := s.db.NewSelect().Model(&rows).
+		Where("seq > ?", after).
+		Where("seq <= ?", until)
+	if folder = strings.Trim(folder, "/"); folder != "" {
+		q = q.WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.
+				Where("kind = ?", string(events.StorageChanged)).
+				WhereOr("path = ? OR substr(path, 1, ?) = ?", folder, len(folder)+1, folder+"/").
+				WhereOr("old_path = ? OR substr(old_path, 1, ?) = ?", folder, len(folder)+1, folder+"/")
+		})
+	}
+	// One more row than the page tells whether another page follows
+	if err := q.Order("seq ASC").Limit(limit + 1).Scan(ctx); err != nil {
+		return nil, false, fmt.Errorf("error listing file changes: %w", err)
+	}
+	more := len(rows) > limit
+	if more {
+		rows = rows[:limit]
+	}
+	return toEntries(rows), more, nil
+}
+
+func (s *store) Reconcile(ctx context.Context, files []storage.FileInfo, scannedAt time.Time) (int, error) {
+	scannedAt = scannedAt.UTC()
+	var recorded int
+	err := s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var snapshot []model.FileJournalSnapshot
+		if err := tx.NewSelect().Model(&snapshot).Scan(ctx); err != nil {
+			return fmt.Errorf("error reading file snapshot: %w", err)
+		}
+		previous := make(map[string]string, len(snapshot))
+		var previousScan time.Time
+		for _, row := range snapshot {
+			if row.Path == "" {
+				previousScan = row.ScannedAt
+				continue
+			}
+			previous[row.Path] = row.Fingerprint
+		}
+
+		var upserts []model.FileJournalSnapshot
+		var changes []model.FileJournalEntry
+		var recent *recentChanges
+		if !previousScan.IsZero() {
+			var rows []model.FileJournalEntry
+			if err := tx.NewSelect().Model(&rows).
+				Where("changed_at >= ?", previousScan).
+				Where("kind <> ?", string(events.StorageChanged)).
+				Scan(ctx); err != nil {
+				return fmt.Errorf("error listing file changes: %w", err)
+			}
+			recent = newRecentChanges(rows)
+		}
+		now := time.Now().UTC()
+		seen := make(map[string]bool, len(files))
+		for _, info := range files {
+			seen[info.Path] = true
+			fingerprint := filemeta.Fingerprint(info)
+			old, existed := previous[info.Path]
+			if existed && old == fingerprint {
+				continue
+			}
+			upserts = append(upserts, model.FileJournalSnapshot{Path: info.Path, Fingerprint: fingerprint, ScannedAt: scannedAt})
+			if recent == nil || recent.written(info.Path, info.ModifiedTime) {
+				continue
+			}
+			kind := events.FileCreated
+			if existed {
+				kind = events.FileUpdated
+			}
+			changes = append(changes, model.FileJournalEntry{Kind: string(kind), Path: info.Path, ChangedAt: now})
+		}
+		var removed []string
+		for p := range previous {
+			if !seen[p] {
+				removed = append(removed, p)
+			}
+		}
+		sort.Strings(removed)
+		for _, p := range removed {
+			if recent == nil || recent.removed(p) {
+				continue
+			}
+			changes = append(changes, model.FileJournalEntry{Kind: string(events.FileDeleted), Path: p, ChangedAt: now})
+		}
+
+		upserts = append(upserts, model.FileJournalSnapshot{Path: "", ScannedAt: scannedAt})
+		for start := 0; start < len(upserts); start += snapshotBatchSize {
+			batch := upserts[start:min(start+snapshotBatchSize, len(upserts))]
+			if _, err := tx.NewDelete().Model((*model.FileJournalSnapshot)(nil)).
+				Where("path IN (?)", bun.In(snapshotPaths(batch))).
+				Exec(ctx); err != nil {
+				return fmt.Errorf("error updating file snapshot: %w", err)
+			}
+			if _, err := tx.NewInsert().Model(&batch).Exec(ctx); err != nil {
+				return fmt.Errorf("error updating file snapshot: %w", err)
+			}
+		}
+		for start := 0; start < len(removed); start += snapshotBatchSize {
+			if _, err := tx.NewDelete().Model((*model.FileJournalSnapshot)(nil)).
+				Where("path IN (?)", bun.In(removed[start:min(start+snapshotBatchSize, len(removed))])).
+				Exec(ctx); err != nil {
+				return fmt.Errorf("error updating file snapshot: %w", err)
+			}
+		}
+		for start := 0; start < len(changes); start += snapshotBatchSize {
+			batch := changes[start:min(start+snapshotBatchSize, len(changes))]
+			if _, err := tx.NewInsert().Model(&batch).Exec(ctx); err != nil {
+				return fmt.Errorf("error recording file changes: %w", err)
+			}
+		}
+		recorded = len(changes)
+		return nil
+	})
+	return recorded, err
+}
+
+func snapshotPaths(rows []model.FileJournalSnapshot) []string {
+	paths := make([]string, len(rows))
+	for i, row := range rows {
+		paths[i] = row.Path
+	}
+	return paths
+}
+
+// recentChanges are the changes recorded since the previous scan, which the
+// differences found by a scan are checked against
+type recentChanges struct {
+	// writes holds when a path was last created, updated or moved to
+	writes map[string]time.Time
+	// moves holds when a path was last moved to, the files of a moved
+	// folder moving along
+	moves map[string]time.Time
+	// removals holds the paths deleted or moved away, the files of a
+	// folder going along
+	removals map[string]bool
+}
+
+func newRecentChanges(rows []model.FileJournalEntry) *recentChanges {
+	r := &recentChanges{
+		writes:   make(map[string]time.Time),
+		moves:    make(map[string]time.Time),
+		removals: make(map[string]bool),
+	}
+	for _, row := range rows {
+		switch events.Kind(row.Kind) {
+		case events.FileDeleted:
+			r.removals[row.Path] = true
+		case events.FileMoved:
+			r.removals[row.OldPath] = true
+			latest(r.moves, row.Path, row.ChangedAt)
+			latest(r.writes, row.Path, row.ChangedAt)
+		default:
+			latest(r.writes, row.Path, row.ChangedAt)
+		}
+	}
+	return r
+}
+
+func latest(times map[string]time.Time, p string, at time.Time) {
+	if at.After(times[p]) {
+		times[p] = at
+	}
+}
+
+// written reports whether a change recorded no earlier than the file was
+// last modified accounts for it. Files added to a folder are not accounted
+// for by the creation of the folder, only by moving it.
+func (r *recentChanges) written(p string, modifiedTime time.Time) bool {
+	if at, ok := r.writes[p]; ok && !at.Before(modifiedTime) {
+		return true
+	}
+	for q := parent(p); q != "."; q = parent(q) {
+		if at, ok := r.moves[q]; ok && !at.Before(modifiedTime) {
+			return true
+		}
+	}
+	return false
+}
+
+// removed reports whether the file or a folder it was in was deleted or
+// moved away
+func (r *recentChanges) removed(p string) bool {
+	for q := p; q != "."; q = parent(q) {
+		if r.removals[q] {
+			return true
+		}
+	}
+	return false
+}
+
+// parent returns the folder of p, "." for a path at the storage root
+func parent(p string) string {
+	if i := strings.LastIndex(p, "/"); i >= 0 {
+		return p[:i]
+	}
+	return "."
+}
+
+func toEntries(rows []model.FileJournalEntry) []*Entry {
+	entries := make([]*Entry, 0, len(rows))
+	for i := range rows {
+		row := &rows[i]
+		entries = append(entries, &Entry{
+			Seq:       row.Seq,
+			Kind:      events.Kind(row.Kind),
+			Path:      row.Path,
+			OldPath:   row.OldPath,
+			ChangedAt: row.ChangedAt,
+		})
+	}
+	return entries
+}
+
+func (s *store) Prune(ctx context.Context, cutoff time.Time) (int, error) {
+	res, err := s.db.NewDelete().Model((*model.FileJournalEntry)(nil)).
+		Where("changed_at < ?", cutoff.UTC()).
+		Exec(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error pruning file changes: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// NewPruneFunc returns a sync function deleting changes older than
+// retention, for use with the server sync loop
+func NewPruneFunc(store Store, retention time.Duration, logger *zap.Logger) func() error {
+	return func() error {
+		pruned, err := store.Prune(context.Background(), time.Now().Add(-retention))
+		if err != nil {
+			return err
+		}
+		if pruned > 0 {
+			logger.Info("Pruned file journal", zap.Int("changes", pruned), zap.Duration("retention", retention))
+		}
+		return nil
+	}
+}
+
+// Recorder records the file events of the server in a journal
+type Recorder struct {
+	store  Store
+	logger *zap.Logger
+}
+
+// NewRecorder returns a recorder writing to store
+func NewRecorder(store Store, logger *zap.Logger) *Recorder {
+	return &Recorder{store: store, logger: logger}
+}
+
+// Run records the changes of files in scope and the storage
+// reconfigurations until ctx is done
+func (r *Recorder) Run(ctx context.Context, broker *events.Broker, scope string) {
+	changes := broker.Subscribe(ctx, func(e events.Event) bool {
+		return e.Kind == events.StorageChanged || e.Scope == scope
+	})
+	for e := range changes {
+		if err := r.store.Record(ctx, e); err != nil && ctx.Err() == nil {
+			r.logger.Warn("Failed to record file change", zap.String("kind", string(e.Kind)), zap.String("path", e.Path), zap.Error(err))
+		}
+	}
+}
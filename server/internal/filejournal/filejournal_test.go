@@ -0,0 +1,226 @@
+package filejournal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/events"
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func setupTestStore(t *testing.T) Store {
+	t.Helper()
+	db := testutil.NewDB(t)
+
+	return New(db, zap.NewNop())
+}
+
+type change struct {
+	Kind    events.Kind
+	Path    string
+	OldPath string
+}
+
+func listChanges(t *testing.T, s Store, after int64, folder string) []change {
+	t.Helper()
+	latest, err := s.Latest(context.Background())
+	require.NoError(t, err)
+	entries, more, err := s.List(context.Background(), after, latest, folder, 0)
+	require.NoError(t, err)
+	assert.False(t, more)
+	var changes []change
+	for _, e := range entries {
+		changes = append(changes, change{Kind: e.Kind, Path: e.Path, OldPath: e.OldPath})
+	}
+	return changes
+}
+
+func file(p string, size int, modified time.Time) storage.FileInfo {
+	return storage.FileInfo{Path: p, Size: int64(size), ModifiedTime: modified}
+}
+
+func TestRecordList(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	latest, err := s.Latest(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, latest)
+
+	require.NoError(t, s.Record(ctx, events.Event{Kind: events.FileCreated, Path: "photos/a.jpg"}))
+	require.NoError(t, s.Record(ctx, events.Event{Kind: events.FileCreated, Path: "docs/b.pdf"}))
+	require.NoError(t, s.Record(ctx, events.Event{Kind: events.FileMoved, Path: "archive/a.jpg", OldPath: "photos/a.jpg"}))
+	require.NoError(t, s.Record(ctx, events.Event{Kind: events.FileDeleted, Path: "photos2/c.jpg"}))
+
+	assert.Equal(t, []change{
+		{Kind: events.FileCreated, Path: "photos/a.jpg"},
+		{Kind: events.FileMoved, Path: "archive/a.jpg", OldPath: "photos/a.jpg"},
+	}, listChanges(t, s, 0, "/photos/"))
+	assert.Equal(t, []change{
+		{Kind: events.FileDeleted, Path: "photos2/c.jpg"},
+	}, listChanges(t, s, 2, "photos2"))
+	assert.Len(t, listChanges(t, s, 0, ""), 4)
+
+	latest, err = s.Latest(ctx)
+	require.NoError(t, err)
+	assert.EqualValues(t, 4, latest)
+
+	entries, more, err := s.List(ctx, 0, latest, "", 3)
+	require.NoError(t, err)
+	assert.True(t, more)
+	require.Len(t, entries, 3)
+	entries, more, err = s.List(ctx, entries[2].Seq, latest, "", 3)
+	require.NoError(t, err)
+	assert.False(t, more)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "photos2/c.jpg", entries[0].Path)
+
+	// Changes after until are left for the next call
+	entries, _, err = s.List(ctx, 0, 2, "", 0)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	// Storage reconfigurations are listed whatever the folder
+	require.NoError(t, s.Record(ctx, events.Event{Kind: events.StorageChanged}))
+	assert.Equal(t, []change{{Kind: events.StorageChanged}}, listChanges(t, s, 4, "photos"))
+}
+
+func TestReconcile(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+	day := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	// The first scan only takes a snapshot
+	n, err := s.Reconcile(ctx, []storage.FileInfo{
+		file("photos/a.jpg", 10, day),
+		file("photos/b.jpg", 20, day),
+		file("photos/c.jpg", 30, day),
+	}, time.Now())
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	assert.Empty(t, listChanges(t, s, 0, ""))
+
+	n, err = s.Reconcile(ctx, []storage.FileInfo{
+		file("photos/a.jpg", 10, day),
+		file("photos/b.jpg", 25, day.Add(time.Hour)),
+		file("photos/d.jpg", 40, day),
+	}, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, []change{
+		{Kind: events.FileUpdated, Path: "photos/b.jpg"},
+		{Kind: events.FileCreated, Path: "photos/d.jpg"},
+		{Kind: events.FileDeleted, Path: "photos/c.jpg"},
+	}, listChanges(t, s, 0, ""))
+
+	// Nothing changed since
+	n, err = s.Reconcile(ctx, []storage.FileInfo{
+		file("photos/a.jpg", 10, day),
+		file("photos/b.jpg", 25, day.Add(time.Hour)),
+		file("photos/d.jpg", 40, day),
+	}, time.Now())
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func TestReconcileSkipsRecordedChanges(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+	past := time.Now().Add(-time.Hour)
+
+	_, err := s.Reconcile(ctx, []storage.FileInfo{
+		file("photos/a.jpg", 10, past),
+		file("photos/b.jpg", 20, past),
+		file("albums/c.jpg", 30, past),
+		file("albums/d.jpg", 40, past),
+	}, time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+
+	require.NoError(t, s.Record(ctx, events.Event{Kind: events.FileCreated, Path: "photos/new.jpg"}))
+	require.NoError(t, s.Record(ctx, events.Event{Kind: events.FileDeleted, Path: "photos/a.jpg"}))
+	require.NoError(t, s.Record(ctx, events.Event{Kind: events.FileMoved, Path: "trips", OldPath: "albums"}))
+	require.NoError(t, s.Record(ctx, events.Event{Kind: events.FileCreated, Path: "drafts"}))
+	latest, err := s.Latest(ctx)
+	require.NoError(t, err)
+
+	n, err := s.Reconcile(ctx, []storage.FileInfo{
+		file("photos/b.jpg", 20, past),
+		file("photos/new.jpg", 50, past),
+		file("trips/c.jpg", 30, past),
+		file("trips/d.jpg", 40, past),
+		// Copied in behind the server's back after the folder was created
+		file("drafts/e.jpg", 60, time.Now().Add(time.Minute)),
+	}, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []change{{Kind: events.FileCreated, Path: "drafts/e.jpg"}}, listChanges(t, s, latest, ""))
+}
+
+func TestStorageChangeDropsSnapshot(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	_, err := s.Reconcile(ctx, []storage.FileInfo{file("a.jpg", 10, time.Now())}, time.Now())
+	require.NoError(t, err)
+	require.NoError(t, s.Record(ctx, events.Event{Kind: events.StorageChanged}))
+
+	// The files of the new storage are a new snapshot rather than changes
+	n, err := s.Reconcile(ctx, []storage.FileInfo{file("b.jpg", 10, time.Now())}, time.Now())
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func TestPrune(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	require.NoError(t, s.Record(ctx, events.Event{Kind: events.FileCreated, Path: "a.jpg"}))
+	require.NoError(t, s.Record(ctx, events.Event{Kind: events.FileCreated, Path: "b.jpg"}))
+
+	pruned, err := s.Prune(ctx, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, pruned)
+	pruned, err = s.Prune(ctx, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 2, pruned)
+
+	// Numbers keep increasing once every change is pruned, so tokens of
+	// sync clients do not point at later changes
+	require.NoError(t, s.Record(ctx, events.Event{Kind: events.FileCreated, Path: "c.jpg"}))
+	latest, err := s.Latest(ctx)
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, latest)
+}
+
+func TestRecorder(t *testing.T) {
+	s := setupTestStore(t)
+	broker := events.NewBroker()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		NewRecorder(s, zap.NewNop()).Run(ctx, broker, "system")
+	}()
+
+	// Wait for the subscription before publishing
+	time.Sleep(50 * time.Millisecond)
+	broker.Publish(events.Event{Kind: events.FileCreated, Scope: "space:1", Path: "other.jpg"})
+	broker.Publish(events.Event{Kind: events.FileCreated, Scope: "system", Path: "a.jpg"})
+	broker.Publish(events.Event{Kind: events.StorageChanged})
+	require.Eventually(t, func() bool {
+		latest, err := s.Latest(context.Background())
+		return err == nil && latest == 2
+	}, time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+
+	assert.Equal(t, []change{
+		{Kind: events.FileCreated, Path: "a.jpg"},
+		{Kind: events.StorageChanged},
+	}, listChanges(t, s, 0, ""))
+}
@@ -0,0 +1,229 @@
+// Package filemeta extracts photo metadata (capture date, camera, GPS and
+// exposure settings) from the imagor meta response and caches it in the
+// database, so repeat lookups do not read the image again.
+package filemeta
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+)
+
+const (
+	// exifTimeLayout is the EXIF date format, e.g. "2024:05:01 12:34:56"
+	exifTimeLayout = "2006:01:02 15:04:05"
+	// localTimeLayout formats capture times without a known UTC offset
+	localTimeLayout = "2006-01-02T15:04:05"
+)
+
+// exifExtensions are the formats that commonly carry EXIF
+var exifExtensions = map[string]bool{
+	".jpg": true, ".jpeg": true, ".tif": true, ".tiff": true, ".heic": true,
+	".heif": true, ".webp": true, ".png": true, ".avif": true, ".dng": true,
+}
+
+// HasExif reports whether a file name has an extension that commonly
+// carries EXIF, worth extracting metadata for when sorting by capture date
+func HasExif(name string) bool {
+	return exifExtensions[strings.ToLower(path.Ext(name))]
+}
+
+// Fingerprint identifies a version of a file, preferring the ETag. Cached
+// metadata is keyed by it, so a changed file is read again.
+func Fingerprint(info storage.FileInfo) string {
+	if info.ETag != "" {
+		return info.ETag
+	}
+	return fmt.Sprintf("%d-%d", info.Size, info.ModifiedTime.UnixNano())
+}
+
+// Metadata is the subset of image metadata exposed to clients. Fields are
+// zero when the image does not carry them.
+type Metadata struct {
+	Format      string `json:"format,omitempty"`
+	Width       int    `json:"width,omitempty"`
+	Height      int    `json:"height,omitempty"`
+	Orientation int    `json:"orientation,omitempty"`
+	// CaptureTime is RFC3339 when the EXIF offset is known, otherwise the
+	// camera's local time without a zone, e.g. "2024-05-01T12:34:56"
+	CaptureTime  string   `json:"captureTime,omitempty"`
+	CameraMake   string   `json:"cameraMake,omitempty"`
+	CameraModel  string   `json:"cameraModel,omitempty"`
+	LensModel    string   `json:"lensModel,omitempty"`
+	Software     string   `json:"software,omitempty"`
+	ISO          int      `json:"iso,omitempty"`
+	Aperture     float64  `json:"aperture,omitempty"`
+	ExposureTime string   `json:"exposureTime,omitempty"`
+	FocalLength  float64  `json:"focalLength,omitempty"`
+	Latitude     *float64 `json:"latitude,omitempty"`
+	Longitude    *float64 `json:"longitude,omitempty"`
+	Altitude     *float64 `json:"altitude,omitempty"`
+}
+
+// imagorMeta is the JSON returned by the imagor meta endpoint. EXIF values
+// are the libvips string forms with the tag prefix and description removed,
+// e.g. "FNumber": "28/10".
+type imagorMeta struct {
+	Format      string            `json:"format"`
+	Width       int               `json:"width"`
+	Height      int               `json:"height"`
+	Orientation int               `json:"orientation"`
+	Exif        map[string]string `json:"exif"`
+}
+
+// CaptureTimeValue parses CaptureTime, reading times without an offset as UTC
+func (m *Metadata) CaptureTimeValue() (time.Time, bool) {
+	if m == nil || m.CaptureTime == "" {
+		return time.Time{}, false
+	}
+	if t, err := time.Parse(time.RFC3339, m.CaptureTime); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse(localTimeLayout, m.CaptureTime); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// LocalCaptureTime returns CaptureTime as the wall time where the photo was
+// taken, e.g. "2024-05-01T12:34:56", or "" when not recorded. The timeline
+// buckets photos by it, so they fall on the day they were taken there.
+func (m *Metadata) LocalCaptureTime() string {
+	if m == nil || m.CaptureTime == "" {
+		return ""
+	}
+	if t, err := time.Parse(time.RFC3339, m.CaptureTime); err == nil {
+		return t.Format(localTimeLayout)
+	}
+	if _, err := time.Parse(localTimeLayout, m.CaptureTime); err == nil {
+		return m.CaptureTime
+	}
+	return ""
+}
+
+// Parse extracts Metadata from an imagor meta response body
+func Parse(body []byte) (*Metadata, error) {
+	var meta imagorMeta
+	if err := json.Unmarshal(body, &meta); err != nil {
+		return nil, fmt.Errorf("failed to parse meta response: %w", err)
+	}
+	exif := meta.Exif
+	m := &Metadata{
+		Format:       meta.Format,
+		Width:        meta.Width,
+		Height:       meta.Height,
+		Orientation:  meta.Orientation,
+		CameraMake:   exif["Make"],
+		CameraModel:  exif["Model"],
+		LensModel:    exif["LensModel"],
+		Software:     exif["Software"],
+		ExposureTime: exif["ExposureTime"],
+	}
+	if m.Orientation == 0 {
+		m.Orientation, _ = strconv.Atoi(exif["Orientation"])
+	}
+	m.CaptureTime = captureTime(exif)
+	if iso := firstNonEmpty(exif["ISOSpeedRatings"], exif["PhotographicSensitivity"]); iso != "" {
+		m.ISO, _ = strconv.Atoi(strings.Fields(iso)[0])
+	}
+	if v, ok := parseRational(exif["FNumber"]); ok {
+		m.Aperture = round(v, 1)
+	}
+	if v, ok := parseRational(exif["FocalLength"]); ok {
+		m.FocalLength = round(v, 1)
+	}
+	m.Latitude = parseCoordinate(exif["GPSLatitude"], exif["GPSLatitudeRef"], "S")
+	m.Longitude = parseCoordinate(exif["GPSLongitude"], exif["GPSLongitudeRef"], "W")
+	if v, ok := parseRational(exif["GPSAltitude"]); ok {
+		if strings.HasPrefix(exif["GPSAltitudeRef"], "1") {
+			v = -v // below sea level
+		}
+		v = round(v, 1)
+		m.Altitude = &v
+	}
+	return m, nil
+}
+
+func captureTime(exif map[string]string) string {
+	candidates := []struct{ value, offset string }{
+		{exif["DateTimeOriginal"], exif["OffsetTimeOriginal"]},
+		{exif["DateTimeDigitized"], exif["OffsetTimeDigitized"]},
+		{exif["DateTime"], exif["OffsetTime"]},
+	}
+	for _, c := range candidates {
+		t, err := time.Parse(exifTimeLayout, c.value)
+		if err != nil {
+			continue
+		}
+		if c.offset != "" {
+			if zoned, err := time.Parse(exifTimeLayout+"-07:00", c.value+c.offset); err == nil {
+				return zoned.Format(time.RFC3339)
+			}
+		}
+		return t.Format(localTimeLayout)
+	}
+	return ""
+}
+
+// parseRational parses "28/10" or a plain decimal
+func parseRational(s string) (float64, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, false
+	}
+	num, den, found := strings.Cut(s, "/")
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, false
+	}
+	if !found {
+		return n, true
+	}
+	d, err := strconv.ParseFloat(den, 64)
+	if err != nil || d == 0 {
+		return 0, false
+	}
+	return n / d, true
+}
+
+// parseCoordinate converts "51/1 30/1 1234/100" degrees, minutes and seconds
+// into signed decimal degrees, negative when ref equals negativeRef
+func parseCoordinate(value, ref, negativeRef string) *float64 {
+	parts := strings.Fields(value)
+	if len(parts) == 0 || len(parts) > 3 {
+		return nil
+	}
+	var degrees float64
+	for i, part := range parts {
+		v, ok := parseRational(part)
+		if !ok {
+			return nil
+		}
+		degrees += v / []float64{1, 60, 3600}[i]
+	}
+	if strings.EqualFold(strings.TrimSpace(ref), negativeRef) {
+		degrees = -degrees
+	}
+	degrees = round(degrees, 6)
+	return &degrees
+}
+
+func round(v float64, places int) float64 {
+	scale := math.Pow10(places)
+	return math.Round(v*scale) / scale
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,101 @@
+package filemeta
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	body := []byte(`{
+		"format": "jpeg",
+		"width": 6000,
+		"height": 4000,
+		"orientation": 6,
+		"exif": {
+			"Make": "Canon",
+			"Model": "Canon EOS R5",
+			"LensModel": "RF24-70mm F2.8 L IS USM",
+			"DateTimeOriginal": "2024:05:01 12:34:56",
+			"OffsetTimeOriginal": "+02:00",
+			"DateTime": "2024:06:01 08:00:00",
+			"ISOSpeedRatings": "400",
+			"FNumber": "28/10",
+			"ExposureTime": "1/250",
+			"FocalLength": "50/1",
+			"GPSLatitude": "51/1 30/1 1234/100",
+			"GPSLatitudeRef": "N",
+			"GPSLongitude": "0/1 7/1 3960/100",
+			"GPSLongitudeRef": "W",
+			"GPSAltitude": "355/10",
+			"GPSAltitudeRef": "0"
+		}
+	}`)
+	m, err := Parse(body)
+	require.NoError(t, err)
+	assert.Equal(t, "jpeg", m.Format)
+	assert.Equal(t, 6000, m.Width)
+	assert.Equal(t, 6, m.Orientation)
+	assert.Equal(t, "2024-05-01T12:34:56+02:00", m.CaptureTime)
+	assert.Equal(t, "Canon", m.CameraMake)
+	assert.Equal(t, "Canon EOS R5", m.CameraModel)
+	assert.Equal(t, "RF24-70mm F2.8 L IS USM", m.LensModel)
+	assert.Equal(t, 400, m.ISO)
+	assert.Equal(t, 2.8, m.Aperture)
+	assert.Equal(t, "1/250", m.ExposureTime)
+	assert.Equal(t, 50.0, m.FocalLength)
+	require.NotNil(t, m.Latitude)
+	assert.Equal(t, 51.503428, *m.Latitude)
+	require.NotNil(t, m.Longitude)
+	assert.Equal(t, -0.127667, *m.Longitude)
+	require.NotNil(t, m.Altitude)
+	assert.Equal(t, 35.5, *m.Altitude)
+}
+
+func TestParse_Sparse(t *testing.T) {
+	m, err := Parse([]byte(`{"format":"png","width":10,"height":20,"exif":{"DateTime":"2023:01:02 03:04:05","Orientation":"3","GPSLatitude":"bogus"}}`))
+	require.NoError(t, err)
+	assert.Equal(t, "2023-01-02T03:04:05", m.CaptureTime)
+	assert.Equal(t, 3, m.Orientation)
+	assert.Nil(t, m.Latitude)
+	assert.Nil(t, m.Altitude)
+	assert.Zero(t, m.ISO)
+	assert.Zero(t, m.Aperture)
+
+	_, err = Parse([]byte(`not json`))
+	assert.Error(t, err)
+}
+
+func TestParseRational(t *testing.T) {
+	v, ok := parseRational("1/4")
+	assert.True(t, ok)
+	assert.Equal(t, 0.25, v)
+	v, ok = parseRational("2.5")
+	assert.True(t, ok)
+	assert.Equal(t, 2.5, v)
+	_, ok = parseRational("1/0")
+	assert.False(t, ok)
+	_, ok = parseRational("")
+	assert.False(t, ok)
+}
+
+func TestCaptureTimeValue(t *testing.T) {
+	v, ok := (&Metadata{CaptureTime: "2024-05-01T12:34:56+02:00"}).CaptureTimeValue()
+	require.True(t, ok)
+	assert.Equal(t, 10, v.UTC().Hour())
+	v, ok = (&Metadata{CaptureTime: "2024-05-01T12:34:56"}).CaptureTimeValue()
+	require.True(t, ok)
+	assert.Equal(t, 12, v.Hour())
+	_, ok = (*Metadata)(nil).CaptureTimeValue()
+	assert.False(t, ok)
+	assert.True(t, HasExif("IMG_0001.JPG"))
+	assert.False(t, HasExif("clip.mp4"))
+}
+
+func TestLocalCaptureTime(t *testing.T) {
+	assert.Equal(t, "2024-05-01T23:34:56", (&Metadata{CaptureTime: "2024-05-01T23:34:56-07:00"}).LocalCaptureTime())
+	assert.Equal(t, "2024-05-01T12:34:56", (&Metadata{CaptureTime: "2024-05-01T12:34:56"}).LocalCaptureTime())
+	assert.Equal(t, "", (&Metadata{CaptureTime: "sometime"}).LocalCaptureTime())
+	assert.Equal(t, "", (&Metadata{}).LocalCaptureTime())
+}
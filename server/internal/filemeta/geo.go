@@ -0,0 +1,132 @@
+package filemeta
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+const (
+	// MaxZoom is the deepest map zoom level clustered, as in web maps
+	MaxZoom = 22
+	// clusterCellPixels is the width of the square a cluster gathers photos
+	// from, in pixels of 256 pixel map tiles
+	clusterCellPixels = 64
+	// maxMercatorLatitude is where the Web Mercator projection is cut off
+	maxMercatorLatitude = 85.05112878
+)
+
+// Bounds is a map area in decimal degrees. West is greater than East when
+// the area crosses the antimeridian.
+type Bounds struct {
+	North float64
+	South float64
+	East  float64
+	West  float64
+}
+
+// Validate reports bounds outside of the valid coordinates
+func (b Bounds) Validate() error {
+	if b.South < -90 || b.North > 90 || b.South > b.North {
+		return errors.New("latitudes must be between -90 and 90 with south below north")
+	}
+	if b.West < -180 || b.West > 180 || b.East < -180 || b.East > 180 {
+		return errors.New("longitudes must be between -180 and 180")
+	}
+	return nil
+}
+
+// crossesAntimeridian reports whether the area wraps around longitude 180
+func (b Bounds) crossesAntimeridian() bool {
+	return b.West > b.East
+}
+
+// extend grows b to include a position. Longitudes are compared directly,
+// clusters never span the antimeridian as their cells do not.
+func (b *Bounds) extend(lat, lon float64) {
+	b.North = max(b.North, lat)
+	b.South = min(b.South, lat)
+	b.East = max(b.East, lon)
+	b.West = min(b.West, lon)
+}
+
+// Location is the GPS position of a file
+type Location struct {
+	FilePath  string
+	Latitude  float64
+	Longitude float64
+	// CapturedAt is the local capture time, empty when not recorded
+	CapturedAt string
+}
+
+// Cluster is a group of nearby files at a zoom level
+type Cluster struct {
+	// Latitude and Longitude are the center of the files
+	Latitude  float64
+	Longitude float64
+	Count     int
+	// Bounds fits the files, for zooming into the cluster
+	Bounds Bounds
+	// Representative is the most recently captured file
+	Representative string
+}
+
+// ClusterLocations groups locations falling in the same square of
+// clusterCellPixels on a Web Mercator map at zoom, largest cluster first
+func ClusterLocations(locations []Location, zoom int) []Cluster {
+	type cell struct{ x, y int64 }
+	cellsAcross := math.Exp2(float64(zoom)) * 256 / clusterCellPixels
+	type group struct {
+		cluster    Cluster
+		latSum     float64
+		lonSum     float64
+		capturedAt string
+	}
+	groups := make(map[cell]*group)
+	for _, l := range locations {
+		x, y := mercator(l.Latitude, l.Longitude)
+		key := cell{
+			x: min(int64(x*cellsAcross), int64(cellsAcross)-1),
+			y: min(int64(y*cellsAcross), int64(cellsAcross)-1),
+		}
+		g, ok := groups[key]
+		if !ok {
+			g = &group{cluster: Cluster{
+				Bounds:         Bounds{North: l.Latitude, South: l.Latitude, East: l.Longitude, West: l.Longitude},
+				Representative: l.FilePath,
+			}, capturedAt: l.CapturedAt}
+			groups[key] = g
+		}
+		g.cluster.Count++
+		g.latSum += l.Latitude
+		g.lonSum += l.Longitude
+		g.cluster.Bounds.extend(l.Latitude, l.Longitude)
+		if l.CapturedAt > g.capturedAt || (l.CapturedAt == g.capturedAt && l.FilePath < g.cluster.Representative) {
+			g.capturedAt = l.CapturedAt
+			g.cluster.Representative = l.FilePath
+		}
+	}
+	clusters := make([]Cluster, 0, len(groups))
+	for _, g := range groups {
+		g.cluster.Latitude = g.latSum / float64(g.cluster.Count)
+		g.cluster.Longitude = g.lonSum / float64(g.cluster.Count)
+		clusters = append(clusters, g.cluster)
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		if clusters[i].Count != clusters[j].Count {
+			return clusters[i].Count > clusters[j].Count
+		}
+		return clusters[i].Representative < clusters[j].Representative
+	})
+	return clusters
+}
+
+// mercator projects a position onto the Web Mercator square, x and y from 0
+// to 1 starting at the north west corner
+func mercator(lat, lon float64) (x, y float64) {
+	lat = max(-maxMercatorLatitude, min(maxMercatorLatitude, lat))
+	x = (lon + 180) / 360
+	sin := math.Sin(lat * math.Pi / 180)
+	y = 0.5 - math.Log((1+sin)/(1-sin))/(4*math.Pi)
+	return max(0, min(1, x)), max(0, min(1, y))
+}
@@ -0,0 +1,43 @@
+package filemeta
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBoundsValidate(t *testing.T) {
+	assert.NoError(t, Bounds{North: 10, South: -10, East: 20, West: -20}.Validate())
+	assert.NoError(t, Bounds{North: 10, South: -10, East: -170, West: 170}.Validate())
+	assert.Error(t, Bounds{North: -10, South: 10, East: 20, West: -20}.Validate())
+	assert.Error(t, Bounds{North: 91, South: 0, East: 20, West: -20}.Validate())
+	assert.Error(t, Bounds{North: 10, South: 0, East: 181, West: -20}.Validate())
+}
+
+func TestClusterLocations(t *testing.T) {
+	locations := []Location{
+		// Three photos around Hong Kong, one in London
+		{FilePath: "hk/a.jpg", Latitude: 22.28, Longitude: 114.15, CapturedAt: "2024-01-01T00:00:00"},
+		{FilePath: "hk/b.jpg", Latitude: 22.30, Longitude: 114.17, CapturedAt: "2024-03-01T00:00:00"},
+		{FilePath: "hk/c.jpg", Latitude: 22.31, Longitude: 114.18},
+		{FilePath: "london/d.jpg", Latitude: 51.50, Longitude: -0.12, CapturedAt: "2023-01-01T00:00:00"},
+	}
+
+	clusters := ClusterLocations(locations, 3)
+	require.Len(t, clusters, 2)
+	assert.Equal(t, 3, clusters[0].Count)
+	assert.Equal(t, "hk/b.jpg", clusters[0].Representative)
+	assert.InDelta(t, 22.2967, clusters[0].Latitude, 0.001)
+	assert.Equal(t, Bounds{North: 22.31, South: 22.28, East: 114.18, West: 114.15}, clusters[0].Bounds)
+	assert.Equal(t, 1, clusters[1].Count)
+	assert.Equal(t, "london/d.jpg", clusters[1].Representative)
+
+	// Zoomed in far enough, every photo stands alone
+	assert.Len(t, ClusterLocations(locations, 16), 4)
+	assert.Len(t, ClusterLocations(locations, 0), 2)
+	assert.Empty(t, ClusterLocations(nil, 5))
+
+	// Poles and the antimeridian stay within the map
+	assert.Len(t, ClusterLocations([]Location{{Latitude: 90, Longitude: 180}, {Latitude: -90, Longitude: -180}}, MaxZoom), 2)
+}
@@ -0,0 +1,59 @@
+package filemeta
+
+import (
+	"path"
+	"strings"
+)
+
+// livePhotoImages are the still images of Live Photos, in order of
+// preference when several share a name, as with HEIC exported as JPEG
+var livePhotoImages = []string{".heic", ".heif", ".jpg", ".jpeg"}
+
+// PairLivePhotos pairs Apple Live Photos among the files of a listing: a
+// still image and a MOV video of the same name in the same folder, ignoring
+// case, as iOS exports them (IMG_0001.HEIC and IMG_0001.MOV). The result
+// maps the path of each image to its video.
+func PairLivePhotos(paths []string) map[string]string {
+	videos := make(map[string]string)
+	for _, p := range paths {
+		if strings.EqualFold(path.Ext(p), ".mov") {
+			videos[livePhotoKey(p)] = p
+		}
+	}
+	if len(videos) == 0 {
+		return nil
+	}
+	images := make(map[string]string)
+	rank := func(p string) int {
+		ext := strings.ToLower(path.Ext(p))
+		for i, e := range livePhotoImages {
+			if e == ext {
+				return i
+			}
+		}
+		return -1
+	}
+	for _, p := range paths {
+		r := rank(p)
+		if r < 0 {
+			continue
+		}
+		key := livePhotoKey(p)
+		if _, ok := videos[key]; !ok {
+			continue
+		}
+		if current, ok := images[key]; !ok || r < rank(current) || (r == rank(current) && p < current) {
+			images[key] = p
+		}
+	}
+	pairs := make(map[string]string, len(images))
+	for key, image := range images {
+		pairs[image] = videos[key]
+	}
+	return pairs
+}
+
+// livePhotoKey is the folder and name without extension of p, lowercased
+func livePhotoKey(p string) string {
+	return strings.ToLower(strings.TrimSuffix(p, path.Ext(p)))
+}
@@ -0,0 +1,409 @@
+package filemeta
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+
+	"github.com/cshum/imagor-studio/server/internal/database"
+	"github.com/cshum/imagor-studio/server/internal/model"
+	"github.com/cshum/imagor-studio/server/pkg/uuid"
+	"github.com/uptrace/bun"
+	"go.uber.org/zap"
+)
+
+// Store caches extracted metadata per scope and file path
+type Store interface {
+	// Get returns the cached metadata of filePath, or nil when nothing is
+	// cached for this fingerprint
+	Get(ctx context.Context, scope, filePath, fingerprint string) (*Metadata, error)
+	// GetMulti returns the cached metadata of the given files, keyed by path.
+	// fingerprints maps each path to its current fingerprint, files cached
+	// for another fingerprint are left out.
+	GetMulti(ctx context.Context, scope string, fingerprints map[string]string) (map[string]*Metadata, error)
+	// Put replaces the cached metadata of filePath, keeping its content
+	// type when the fingerprint is unchanged
+	Put(ctx context.Context, scope, filePath, fingerprint string, metadata *Metadata) error
+	// PutContentType records the content type of filePath detected when it
+	// was uploaded, ahead of its metadata being read
+	PutContentType(ctx context.Context, scope, filePath, fingerprint, contentType string) error
+	// ContentType returns the recorded content type of filePath, or "" when
+	// none is recorded for this fingerprint
+	ContentType(ctx context.Context, scope, filePath, fingerprint string) (string, error)
+	// RemoveFilePath drops cached metadata of a file, or of every file below
+	// a folder
+	RemoveFilePath(ctx context.Context, scope, path string) error
+	// Timeline counts the files below folder with a capture time per period,
+	// newest first. An empty folder covers the whole scope.
+	Timeline(ctx context.Context, scope, folder string, granularity Granularity) ([]Bucket, error)
+	// TimelineEntries returns the files below folder with a capture time,
+	// newest first, skipping offset files
+	TimelineEntries(ctx context.Context, scope, folder string, offset, limit int) ([]Entry, error)
+	// Locations returns the files below folder with a GPS position within
+	// bounds. An empty folder covers the whole scope.
+	Locations(ctx context.Context, scope, folder string, bounds Bounds) ([]Location, error)
+	// LinkLivePhotos replaces the Live Photo links of the images directly in
+	// folder with pairs, mapping image paths to their videos
+	LinkLivePhotos(ctx context.Context, scope, folder string, pairs map[string]string) error
+	// LivePhotos returns the Live Photo links of which the image or the video
+	// is one of paths, mapping image paths to their videos
+	LivePhotos(ctx context.Context, scope string, paths []string) (map[string]string, error)
+}
+
+// Granularity is the period of timeline buckets
+type Granularity string
+
+const (
+	GranularityDay   Granularity = "day"
+	GranularityMonth Granularity = "month"
+	GranularityYear  Granularity = "year"
+)
+
+// keyLength is the length of the capture time prefix naming a bucket, e.g.
+// 2024-05-01 for a day
+func (g Granularity) keyLength() (int, error) {
+	switch g {
+	case GranularityDay:
+		return len("2006-01-02"), nil
+	case GranularityMonth:
+		return len("2006-01"), nil
+	case GranularityYear:
+		return len("2006"), nil
+	}
+	return 0, fmt.Errorf("invalid timeline granularity %q", g)
+}
+
+// Bucket is a period of the timeline
+type Bucket struct {
+	// Key is the period, e.g. 2024-05-01, 2024-05 or 2024
+	Key   string `bun:"bucket"`
+	Count int    `bun:"count"`
+}
+
+// Entry is a file on the timeline
+type Entry struct {
+	FilePath string
+	Metadata *Metadata
+}
+
+// getMultiChunkSize keeps IN lists below the SQLite parameter limit
+const getMultiChunkSize = 500
+
+type store struct {
+	db     *bun.DB
+	logger *zap.Logger
+}
+
+func NewStore(db *bun.DB, logger *zap.Logger) Store {
+	return &store{db: db, logger: logger}
+}
+
+func (s *store) Get(ctx context.Context, scope, filePath, fingerprint string) (*Metadata, error) {
+	var row model.FileMetadata
+	err := s.db.NewSelect().Model(&row).
+		Where("scope = ?", scope).
+		Where("file_path = ?", filePath).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting file metadata: %w", err)
+	}
+	if row.Fingerprint != fingerprint || row.Data == "" {
+		return nil, nil
+	}
+	var metadata Metadata
+	if err := json.Unmarshal([]byte(row.Data), &metadata); err != nil {
+		// Treated as a miss, the entry is replaced on the next Put
+		s.logger.Warn("Invalid cached file metadata", zap.String("path", filePath), zap.Error(err))
+		return nil, nil
+	}
+	return &metadata, nil
+}
+
+func (s *store) GetMulti(ctx context.Context, scope string, fingerprints map[string]string) (map[string]*Metadata, error) {
+	paths := make([]string, 0, len(fingerprints))
+	for p := range fingerprints {
+		paths = append(paths, p)
+	}
+	result := make(map[string]*Metadata)
+	for start := 0; start < len(paths); start += getMultiChunkSize {
+		end := min(start+getMultiChunkSize, len(paths))
+		var rows []model.FileMetadata
+		if err := s.db.NewSelect().Model(&rows).
+			Where("scope = ?", scope).
+			Where("file_path IN (?)", bun.In(paths[start:end])).
+			Scan(ctx); err != nil {
+			return nil, fmt.Errorf("error getting file metadata: %w", err)
+		}
+		for _, row := range rows {
+			if row.Fingerprint != fingerprints[row.FilePath] || row.Data == "" {
+				continue
+			}
+			var metadata Metadata
+			if err := json.Unmarshal([]byte(row.Data), &metadata); err != nil {
+				s.logger.Warn("Invalid cached file metadata", zap.String("path", row.FilePath), zap.Error(err))
+				continue
+			}
+			result[row.FilePath] = &metadata
+		}
+	}
+	return result, nil
+}
+
+func (s *store) Put(ctx context.Context, scope, filePath, fingerprint string, metadata *Metadata) error {
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("error encoding file metadata: %w", err)
+	}
+	row := &model.FileMetadata{
+		ID:          uuid.GenerateUUID(),
+		Scope:       scope,
+		FilePath:    filePath,
+		Fingerprint: fingerprint,
+		Data:        string(data),
+	}
+	if capturedAt := metadata.LocalCaptureTime(); capturedAt != "" {
+		row.CapturedAt = &capturedAt
+	}
+	if metadata.Latitude != nil && metadata.Longitude != nil {
+		row.Latitude, row.Longitude = metadata.Latitude, metadata.Longitude
+	}
+	return s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var existing model.FileMetadata
+		err := tx.NewSelect().Model(&existing).
+			Column("content_type").
+			Where("scope = ?", scope).
+			Where("file_path = ?", filePath).
+			Where("fingerprint = ?", fingerprint).
+			Scan(ctx)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("error replacing file metadata: %w", err)
+		}
+		row.ContentType = existing.ContentType
+		if _, err := upsertFileMetadata(tx, row).Exec(ctx); err != nil {
+			return fmt.Errorf("error saving file metadata: %w", err)
+		}
+		return nil
+	})
+}
+
+// upsertFileMetadata writes row over the metadata of its file, if any
+func upsertFileMetadata(tx bun.Tx, row *model.FileMetadata) *bun.InsertQuery {
+	return database.OnConflictUpdate(tx.NewInsert().Model(row), "scope, file_path",
+		"fingerprint", "data", "content_type", "captured_at", "latitude", "longitude")
+}
+
+func (s *store) PutContentType(ctx context.Context, scope, filePath, fingerprint, contentType string) error {
+	return s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		res, err := tx.NewUpdate().Model((*model.FileMetadata)(nil)).
+			Set("content_type = ?", contentType).
+			Where("scope = ?", scope).
+			Where("file_path = ?", filePath).
+			Where("fingerprint = ?", fingerprint).
+			Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("error saving file content type: %w", err)
+		}
+		if n, err := res.RowsAffected(); err == nil && n > 0 {
+			return nil
+		}
+		// Metadata of another version of the file is stale, the row is
+		// replaced by one with empty Data until the metadata is read
+		if _, err := upsertFileMetadata(tx, &model.FileMetadata{
+			ID:          uuid.GenerateUUID(),
+			Scope:       scope,
+			FilePath:    filePath,
+			Fingerprint: fingerprint,
+			ContentType: contentType,
+		}).Exec(ctx); err != nil {
+			return fmt.Errorf("error saving file content type: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *store) ContentType(ctx context.Context, scope, filePath, fingerprint string) (string, error) {
+	var row model.FileMetadata
+	err := s.db.NewSelect().Model(&row).
+		Column("content_type").
+		Where("scope = ?", scope).
+		Where("file_path = ?", filePath).
+		Where("fingerprint = ?", fingerprint).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("error getting file content type: %w", err)
+	}
+	return row.ContentType, nil
+}
+
+func (s *store) RemoveFilePath(ctx context.Context, scope, path string) error {
+	if _, err := s.db.NewDelete().Model((*model.FileMetadata)(nil)).
+		Where("scope = ?", scope).
+		Where("(file_path = ? OR substr(file_path, 1, ?) = ?)", path, len(path)+1, path+"/").
+		Exec(ctx); err != nil {
+		return fmt.Errorf("error removing file metadata: %w", err)
+	}
+	// Links go with either file of the pair
+	if _, err := s.db.NewDelete().Model((*model.LivePhoto)(nil)).
+		Where("scope = ?", scope).
+		WhereGroup(" AND ", func(q *bun.DeleteQuery) *bun.DeleteQuery {
+			return q.
+				WhereOr("image_path = ? OR substr(image_path, 1, ?) = ?", path, len(path)+1, path+"/").
+				WhereOr("video_path = ? OR substr(video_path, 1, ?) = ?", path, len(path)+1, path+"/")
+		}).
+		Exec(ctx); err != nil {
+		return fmt.Errorf("error removing live photo links: %w", err)
+	}
+	return nil
+}
+
+func (s *store) Timeline(ctx context.Context, scope, folder string, granularity Granularity) ([]Bucket, error) {
+	keyLength, err := granularity.keyLength()
+	if err != nil {
+		return nil, err
+	}
+	var buckets []Bucket
+	q := s.db.NewSelect().Model((*model.FileMetadata)(nil)).
+		ColumnExpr("substr(captured_at, 1, ?) AS bucket", keyLength).
+		ColumnExpr("COUNT(*) AS count").
+		Where("scope = ?", scope).
+		Where("captured_at IS NOT NULL")
+	if err := whereBelow(q, folder).
+		GroupExpr("bucket").
+		OrderExpr("bucket DESC").
+		Scan(ctx, &buckets); err != nil {
+		return nil, fmt.Errorf("error counting timeline: %w", err)
+	}
+	return buckets, nil
+}
+
+func (s *store) TimelineEntries(ctx context.Context, scope, folder string, offset, limit int) ([]Entry, error) {
+	var rows []model.FileMetadata
+	q := s.db.NewSelect().Model(&rows).
+		Where("scope = ?", scope).
+		Where("captured_at IS NOT NULL")
+	if err := whereBelow(q, folder).
+		OrderExpr("captured_at DESC, file_path ASC").
+		Offset(offset).
+		Limit(limit).
+		Scan(ctx); err != nil {
+		return nil, fmt.Errorf("error listing timeline: %w", err)
+	}
+	entries := make([]Entry, 0, len(rows))
+	for _, row := range rows {
+		var metadata Metadata
+		if err := json.Unmarshal([]byte(row.Data), &metadata); err != nil {
+			s.logger.Warn("Invalid cached file metadata", zap.String("path", row.FilePath), zap.Error(err))
+			metadata = Metadata{CaptureTime: *row.CapturedAt}
+		}
+		entries = append(entries, Entry{FilePath: row.FilePath, Metadata: &metadata})
+	}
+	return entries, nil
+}
+
+func (s *store) Locations(ctx context.Context, scope, folder string, bounds Bounds) ([]Location, error) {
+	var rows []model.FileMetadata
+	q := s.db.NewSelect().Model(&rows).
+		Column("file_path", "captured_at", "latitude", "longitude").
+		Where("scope = ?", scope).
+		Where("latitude BETWEEN ? AND ?", bounds.South, bounds.North).
+		Where("longitude IS NOT NULL")
+	if bounds.crossesAntimeridian() {
+		q = q.Where("(longitude >= ? OR longitude <= ?)", bounds.West, bounds.East)
+	} else {
+		q = q.Where("longitude BETWEEN ? AND ?", bounds.West, bounds.East)
+	}
+	if err := whereBelow(q, folder).Scan(ctx); err != nil {
+		return nil, fmt.Errorf("error listing file locations: %w", err)
+	}
+	locations := make([]Location, len(rows))
+	for i, row := range rows {
+		locations[i] = Location{FilePath: row.FilePath, Latitude: *row.Latitude, Longitude: *row.Longitude}
+		if row.CapturedAt != nil {
+			locations[i].CapturedAt = *row.CapturedAt
+		}
+	}
+	return locations, nil
+}
+
+func (s *store) LinkLivePhotos(ctx context.Context, scope, folder string, pairs map[string]string) error {
+	var rows []model.LivePhoto
+	q := s.db.NewSelect().Model(&rows).Where("scope = ?", scope)
+	if folder != "" {
+		q = q.Where("substr(image_path, 1, ?) = ?", len(folder)+1, folder+"/")
+	}
+	if err := q.Scan(ctx); err != nil {
+		return fmt.Errorf("error getting live photo links: %w", err)
+	}
+	var stale []string
+	linked := make(map[string]bool)
+	for _, row := range rows {
+		if dir := path.Dir(row.ImagePath); dir != folder && (folder != "" || dir != ".") {
+			continue
+		}
+		if pairs[row.ImagePath] == row.VideoPath {
+			linked[row.ImagePath] = true
+			continue
+		}
+		stale = append(stale, row.ID)
+	}
+	var added []model.LivePhoto
+	for image, video := range pairs {
+		if !linked[image] {
+			added = append(added, model.LivePhoto{ID: uuid.GenerateUUID(), Scope: scope, ImagePath: image, VideoPath: video})
+		}
+	}
+	if len(stale) == 0 && len(added) == 0 {
+		return nil
+	}
+	return s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if len(stale) > 0 {
+			if _, err := tx.NewDelete().Model((*model.LivePhoto)(nil)).
+				Where("id IN (?)", bun.In(stale)).
+				Exec(ctx); err != nil {
+				return fmt.Errorf("error removing live photo links: %w", err)
+			}
+		}
+		if len(added) > 0 {
+			if _, err := tx.NewInsert().Model(&added).Exec(ctx); err != nil {
+				return fmt.Errorf("error saving live photo links: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+func (s *store) LivePhotos(ctx context.Context, scope string, paths []string) (map[string]string, error) {
+	result := make(map[string]string)
+	for start := 0; start < len(paths); start += getMultiChunkSize {
+		end := min(start+getMultiChunkSize, len(paths))
+		chunk := bun.In(paths[start:end])
+		var rows []model.LivePhoto
+		if err := s.db.NewSelect().Model(&rows).
+			Where("scope = ?", scope).
+			Where("(image_path IN (?) OR video_path IN (?))", chunk, chunk).
+			Scan(ctx); err != nil {
+			return nil, fmt.Errorf("error getting live photo links: %w", err)
+		}
+		for _, row := range rows {
+			result[row.ImagePath] = row.VideoPath
+		}
+	}
+	return result, nil
+}
+
+// whereBelow limits q to files below folder, all files when empty
+func whereBelow(q *bun.SelectQuery, folder string) *bun.SelectQuery {
+	if folder == "" {
+		return q
+	}
+	return q.Where("substr(file_path, 1, ?) = ?", len(folder)+1, folder+"/")
+}
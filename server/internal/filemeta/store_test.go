@@ -0,0 +1,258 @@
+package filemeta
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const scope = "system:global"
+
+func setupTestStore(t *testing.T) Store {
+	t.Helper()
+	db := testutil.NewDB(t)
+
+	return NewStore(db, zap.NewNop())
+}
+
+func TestStore(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	m, err := s.Get(ctx, scope, "photos/a.jpg", "v1")
+	require.NoError(t, err)
+	assert.Nil(t, m)
+
+	require.NoError(t, s.Put(ctx, scope, "photos/a.jpg", "v1", &Metadata{CameraModel: "X100V", ISO: 160}))
+	m, err = s.Get(ctx, scope, "photos/a.jpg", "v1")
+	require.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "X100V", m.CameraModel)
+	assert.Equal(t, 160, m.ISO)
+
+	// A different fingerprint is a miss until the entry is replaced
+	m, err = s.Get(ctx, scope, "photos/a.jpg", "v2")
+	require.NoError(t, err)
+	assert.Nil(t, m)
+	require.NoError(t, s.Put(ctx, scope, "photos/a.jpg", "v2", &Metadata{CameraModel: "X-T5"}))
+	m, err = s.Get(ctx, scope, "photos/a.jpg", "v2")
+	require.NoError(t, err)
+	assert.Equal(t, "X-T5", m.CameraModel)
+
+	// Scopes are isolated
+	m, err = s.Get(ctx, "space:other", "photos/a.jpg", "v2")
+	require.NoError(t, err)
+	assert.Nil(t, m)
+}
+
+func TestStore_RemoveFilePath(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+	for _, p := range []string{"photos/a.jpg", "photos/sub/b.jpg", "photos-old/c.jpg"} {
+		require.NoError(t, s.Put(ctx, scope, p, "v1", &Metadata{}))
+	}
+
+	require.NoError(t, s.RemoveFilePath(ctx, scope, "photos"))
+	for p, cached := range map[string]bool{"photos/a.jpg": false, "photos/sub/b.jpg": false, "photos-old/c.jpg": true} {
+		m, err := s.Get(ctx, scope, p, "v1")
+		require.NoError(t, err)
+		assert.Equal(t, cached, m != nil, p)
+	}
+}
+
+func TestStore_GetMulti(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+	require.NoError(t, s.Put(ctx, scope, "a.jpg", "v1", &Metadata{CaptureTime: "2024-01-01T00:00:00"}))
+	require.NoError(t, s.Put(ctx, scope, "b.jpg", "v1", &Metadata{}))
+
+	result, err := s.GetMulti(ctx, scope, map[string]string{"a.jpg": "v1", "b.jpg": "v2", "c.jpg": "v1"})
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.Equal(t, "2024-01-01T00:00:00", result["a.jpg"].CaptureTime)
+
+	result, err = s.GetMulti(ctx, scope, nil)
+	require.NoError(t, err)
+	assert.Empty(t, result)
+}
+
+func TestStore_ContentType(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	contentType, err := s.ContentType(ctx, scope, "photos/a.jpg", "v1")
+	require.NoError(t, err)
+	assert.Empty(t, contentType)
+
+	// Recorded on upload, before the metadata is read
+	require.NoError(t, s.PutContentType(ctx, scope, "photos/a.jpg", "v1", "image/png"))
+	contentType, err = s.ContentType(ctx, scope, "photos/a.jpg", "v1")
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", contentType)
+	m, err := s.Get(ctx, scope, "photos/a.jpg", "v1")
+	require.NoError(t, err)
+	assert.Nil(t, m, "metadata is not read yet")
+	multi, err := s.GetMulti(ctx, scope, map[string]string{"photos/a.jpg": "v1"})
+	require.NoError(t, err)
+	assert.Empty(t, multi)
+
+	// Kept when the metadata of the same version is read
+	require.NoError(t, s.Put(ctx, scope, "photos/a.jpg", "v1", &Metadata{Format: "png"}))
+	contentType, err = s.ContentType(ctx, scope, "photos/a.jpg", "v1")
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", contentType)
+	m, err = s.Get(ctx, scope, "photos/a.jpg", "v1")
+	require.NoError(t, err)
+	assert.Equal(t, "png", m.Format)
+
+	// A new version replaces the stale metadata
+	require.NoError(t, s.PutContentType(ctx, scope, "photos/a.jpg", "v2", "image/jpeg"))
+	m, err = s.Get(ctx, scope, "photos/a.jpg", "v1")
+	require.NoError(t, err)
+	assert.Nil(t, m)
+	contentType, err = s.ContentType(ctx, scope, "photos/a.jpg", "v1")
+	require.NoError(t, err)
+	assert.Empty(t, contentType)
+	require.NoError(t, s.Put(ctx, scope, "photos/a.jpg", "v3", &Metadata{Format: "jpeg"}))
+	contentType, err = s.ContentType(ctx, scope, "photos/a.jpg", "v3")
+	require.NoError(t, err)
+	assert.Empty(t, contentType, "content types are not carried to other versions")
+}
+
+func TestStore_Timeline(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+	for p, captureTime := range map[string]string{
+		"2023/a.jpg":       "2023-12-31T23:00:00",
+		"2024/b.jpg":       "2024-05-01T08:00:00",
+		"2024/c.jpg":       "2024-05-01T20:00:00",
+		"2024/late.jpg":    "2024-05-01T23:30:00-07:00", // still May 1st where taken
+		"2024/d.jpg":       "2024-06-15T12:00:00",
+		"2024/undated.jpg": "",
+		"2024-old/e.jpg":   "2022-01-01T00:00:00",
+	} {
+		require.NoError(t, s.Put(ctx, scope, p, "v1", &Metadata{CaptureTime: captureTime}))
+	}
+	require.NoError(t, s.Put(ctx, "space:other", "f.jpg", "v1", &Metadata{CaptureTime: "2024-05-01T00:00:00"}))
+
+	buckets, err := s.Timeline(ctx, scope, "", GranularityYear)
+	require.NoError(t, err)
+	assert.Equal(t, []Bucket{{"2024", 4}, {"2023", 1}, {"2022", 1}}, buckets)
+
+	buckets, err = s.Timeline(ctx, scope, "2024", GranularityDay)
+	require.NoError(t, err)
+	assert.Equal(t, []Bucket{{"2024-06-15", 1}, {"2024-05-01", 3}}, buckets)
+
+	buckets, err = s.Timeline(ctx, scope, "", GranularityMonth)
+	require.NoError(t, err)
+	assert.Equal(t, []Bucket{{"2024-06", 1}, {"2024-05", 3}, {"2023-12", 1}, {"2022-01", 1}}, buckets)
+
+	_, err = s.Timeline(ctx, scope, "", Granularity("week"))
+	assert.Error(t, err)
+
+	entries, err := s.TimelineEntries(ctx, scope, "2024", 1, 2)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "2024/late.jpg", entries[0].FilePath)
+	assert.Equal(t, "2024-05-01T23:30:00-07:00", entries[0].Metadata.CaptureTime)
+	assert.Equal(t, "2024/c.jpg", entries[1].FilePath)
+
+	// Removed files leave the timeline
+	require.NoError(t, s.RemoveFilePath(ctx, scope, "2024"))
+	buckets, err = s.Timeline(ctx, scope, "2024", GranularityDay)
+	require.NoError(t, err)
+	assert.Empty(t, buckets)
+}
+
+func TestStore_Locations(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+	at := func(lat, lon float64) *Metadata {
+		return &Metadata{Latitude: &lat, Longitude: &lon, CaptureTime: "2024-01-01T00:00:00"}
+	}
+	require.NoError(t, s.Put(ctx, scope, "hk/a.jpg", "v1", at(22.3, 114.2)))
+	require.NoError(t, s.Put(ctx, scope, "fiji/b.jpg", "v1", at(-17.7, 178.1)))
+	require.NoError(t, s.Put(ctx, scope, "samoa/c.jpg", "v1", at(-13.8, -171.8)))
+	require.NoError(t, s.Put(ctx, scope, "undated/d.jpg", "v1", &Metadata{}))
+
+	locations, err := s.Locations(ctx, scope, "", Bounds{North: 90, South: -90, East: 180, West: -180})
+	require.NoError(t, err)
+	assert.Len(t, locations, 3)
+
+	locations, err = s.Locations(ctx, scope, "hk", Bounds{North: 90, South: -90, East: 180, West: -180})
+	require.NoError(t, err)
+	require.Len(t, locations, 1)
+	assert.Equal(t, Location{FilePath: "hk/a.jpg", Latitude: 22.3, Longitude: 114.2, CapturedAt: "2024-01-01T00:00:00"}, locations[0])
+
+	// Across the antimeridian, Fiji to Samoa
+	locations, err = s.Locations(ctx, scope, "", Bounds{North: 0, South: -30, East: -170, West: 170})
+	require.NoError(t, err)
+	assert.Len(t, locations, 2)
+
+	locations, err = s.Locations(ctx, scope, "", Bounds{North: 60, South: 40, East: 10, West: -10})
+	require.NoError(t, err)
+	assert.Empty(t, locations)
+}
+
+func TestStore_LivePhotos(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	require.NoError(t, s.LinkLivePhotos(ctx, scope, "", map[string]string{"a.heic": "a.mov"}))
+	require.NoError(t, s.LinkLivePhotos(ctx, scope, "trip", map[string]string{
+		"trip/b.heic": "trip/b.mov",
+		"trip/c.jpg":  "trip/c.mov",
+	}))
+	require.NoError(t, s.LinkLivePhotos(ctx, scope, "trip/day1", map[string]string{"trip/day1/d.heic": "trip/day1/d.mov"}))
+
+	all := []string{"a.heic", "trip/b.heic", "trip/c.jpg", "trip/day1/d.heic"}
+	videos, err := s.LivePhotos(ctx, scope, all)
+	require.NoError(t, err)
+	assert.Len(t, videos, 4)
+	assert.Equal(t, "trip/b.mov", videos["trip/b.heic"])
+	// Links are found by either path
+	videos, err = s.LivePhotos(ctx, scope, []string{"trip/c.mov"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"trip/c.jpg": "trip/c.mov"}, videos)
+
+	// Relinking a folder leaves its subfolders and the root alone
+	require.NoError(t, s.LinkLivePhotos(ctx, scope, "trip", map[string]string{"trip/b.heic": "trip/B.MOV"}))
+	videos, err = s.LivePhotos(ctx, scope, all)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"a.heic":           "a.mov",
+		"trip/b.heic":      "trip/B.MOV",
+		"trip/day1/d.heic": "trip/day1/d.mov",
+	}, videos)
+
+	// Removing either file of a pair drops the link
+	require.NoError(t, s.RemoveFilePath(ctx, scope, "trip/B.MOV"))
+	require.NoError(t, s.RemoveFilePath(ctx, scope, "trip/day1"))
+	videos, err = s.LivePhotos(ctx, scope, all)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"a.heic": "a.mov"}, videos)
+
+	videos, err = s.LivePhotos(ctx, "other", all)
+	require.NoError(t, err)
+	assert.Empty(t, videos)
+}
+
+func TestPairLivePhotos(t *testing.T) {
+	pairs := PairLivePhotos([]string{
+		"trip/IMG_0001.HEIC", "trip/IMG_0001.MOV",
+		"trip/IMG_0002.JPG", "trip/IMG_0002.heic", "trip/img_0002.mov",
+		"trip/IMG_0003.HEIC",
+		"trip/IMG_0004.MOV",
+		"trip/IMG_0005.PNG", "trip/IMG_0005.MOV",
+		"trip/IMG_0006.MP4", "trip/IMG_0006.JPG",
+	})
+	assert.Equal(t, map[string]string{
+		"trip/IMG_0001.HEIC": "trip/IMG_0001.MOV",
+		"trip/IMG_0002.heic": "trip/img_0002.mov",
+	}, pairs)
+	assert.Empty(t, PairLivePhotos([]string{"a.heic", "b.mov"}))
+}
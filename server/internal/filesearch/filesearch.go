@@ -0,0 +1,227 @@
+// Package filesearch finds files by name below a storage path.
+//
+// Backends implementing storage.Walker are enumerated with a single flat
+// listing, others are crawled folder by folder with a pool of workers.
+// There is no persistent index, every search reads the storage.
+package filesearch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+)
+
+const (
+	// DefaultLimit is the number of results returned when Limit is not set
+	DefaultLimit = 100
+	// MaxLimit is the largest number of results a search returns
+	MaxLimit = 1000
+	// DefaultWorkers is the number of concurrent folder listings
+	DefaultWorkers = 8
+	// DefaultMaxScanned bounds the files inspected by a single search
+	DefaultMaxScanned = 100000
+)
+
+var errStop = errors.New("search stopped")
+
+type Options struct {
+	// Query is split on whitespace, every term has to appear in the file
+	// path or its keywords, ignoring case
+	Query      string
+	Extensions []string
+	Limit      int
+	Workers    int
+	MaxScanned int
+	ShowHidden bool
+	// Keywords returns extra metadata matched against the query terms,
+	// such as the system tags of the file
+	Keywords func(storage.FileInfo) []string
+	// Filter, when set, excludes files it returns false for before the
+	// query terms are matched
+	Filter func(storage.FileInfo) bool
+}
+
+type Result struct {
+	Items []storage.FileInfo
+	// Scanned is the number of files inspected
+	Scanned int
+	// Truncated reports the search stopped before inspecting every file,
+	// either because Limit matches were found or MaxScanned was reached
+	Truncated bool
+}
+
+// Terms splits query into lowercase search terms
+func Terms(query string) []string {
+	return strings.Fields(strings.ToLower(query))
+}
+
+// Search returns the files below root matching opts, sorted by path
+func Search(ctx context.Context, stor storage.Storage, root string, opts Options) (Result, error) {
+	s := &searcher{opts: opts, terms: Terms(opts.Query)}
+	if s.opts.Limit <= 0 {
+		s.opts.Limit = DefaultLimit
+	}
+	s.opts.Limit = min(s.opts.Limit, MaxLimit)
+	if s.opts.Workers <= 0 {
+		s.opts.Workers = DefaultWorkers
+	}
+	if s.opts.MaxScanned <= 0 {
+		s.opts.MaxScanned = DefaultMaxScanned
+	}
+	root = strings.Trim(root, "/")
+
+	var err error
+	if walker, ok := stor.(storage.Walker); ok {
+		err = walker.Walk(ctx, root, s.visit)
+	} else {
+		err = s.crawl(ctx, stor, root)
+	}
+	if err != nil && !errors.Is(err, errStop) {
+		return Result{}, err
+	}
+
+	sort.Slice(s.items, func(i, j int) bool {
+		return s.items[i].Path < s.items[j].Path
+	})
+	return Result{Items: s.items, Scanned: s.scanned, Truncated: s.truncated}, nil
+}
+
+type searcher struct {
+	opts  Options
+	terms []string
+
+	mu        sync.Mutex
+	items     []storage.FileInfo
+	scanned   int
+	truncated bool
+}
+
+// visit matches a single file, returning errStop once the search is done
+func (s *searcher) visit(info storage.FileInfo) error {
+	if info.IsDir {
+		return nil
+	}
+	if !s.opts.ShowHidden && hasHiddenSegment(info.Path) {
+		return nil
+	}
+	matched := s.matches(info)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.truncated {
+		return errStop
+	}
+	if s.scanned >= s.opts.MaxScanned {
+		s.truncated = true
+		return errStop
+	}
+	s.scanned++
+	if !matched {
+		return nil
+	}
+	if len(s.items) >= s.opts.Limit {
+		s.truncated = true
+		return errStop
+	}
+	s.items = append(s.items, info)
+	return nil
+}
+
+func (s *searcher) matches(info storage.FileInfo) bool {
+	if !storage.MatchesExtensions(info.Name, s.opts.Extensions) {
+		return false
+	}
+	if s.opts.Filter != nil && !s.opts.Filter(info) {
+		return false
+	}
+	if len(s.terms) == 0 {
+		return true
+	}
+	haystack := strings.ToLower(info.Path)
+	if s.opts.Keywords != nil {
+		if keywords := s.opts.Keywords(info); len(keywords) > 0 {
+			haystack += "\n" + strings.ToLower(strings.Join(keywords, "\n"))
+		}
+	}
+	for _, term := range s.terms {
+		if !strings.Contains(haystack, term) {
+			return false
+		}
+	}
+	return true
+}
+
+// crawl lists root and its sub folders concurrently, at most Workers
+// listings at a time
+func (s *searcher) crawl(ctx context.Context, stor storage.Storage, root string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sem := make(chan struct{}, s.opts.Workers)
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	fail := func(err error) {
+		once.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	var listDir func(dir string)
+	listDir = func(dir string) {
+		defer wg.Done()
+		if err := ctx.Err(); err != nil {
+			fail(err)
+			return
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			fail(ctx.Err())
+			return
+		}
+		result, err := stor.List(ctx, dir, storage.ListOptions{ShowHidden: true})
+		<-sem
+		if err != nil {
+			if ctx.Err() != nil {
+				err = ctx.Err()
+			}
+			fail(fmt.Errorf("failed to list files: %w", err))
+			return
+		}
+		for _, item := range result.Items {
+			if item.IsDir {
+				if s.opts.ShowHidden || !storage.IsHiddenFile(item.Name) {
+					wg.Add(1)
+					go listDir(item.Path)
+				}
+				continue
+			}
+			if err := s.visit(item); err != nil {
+				fail(err)
+				return
+			}
+		}
+	}
+
+	wg.Add(1)
+	go listDir(root)
+	wg.Wait()
+	return firstErr
+}
+
+// hasHiddenSegment reports whether any element of p starts with "."
+func hasHiddenSegment(p string) bool {
+	for _, segment := range strings.Split(p, "/") {
+		if storage.IsHiddenFile(segment) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+package filesearch
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStorage(t *testing.T, paths ...string) storage.Storage {
+	t.Helper()
+	baseDir := t.TempDir()
+	for _, p := range paths {
+		fullPath := filepath.Join(baseDir, p)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0755))
+		require.NoError(t, os.WriteFile(fullPath, []byte("test"), 0644))
+	}
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+	return stor
+}
+
+func resultPaths(result Result) []string {
+	paths := make([]string, len(result.Items))
+	for i, item := range result.Items {
+		paths[i] = item.Path
+	}
+	return paths
+}
+
+func TestSearch(t *testing.T) {
+	stor := newTestStorage(t,
+		"holiday/Beach-Sunset.jpg",
+		"holiday/beach/waves.png",
+		"holiday/notes.txt",
+		"work/sunset-report.pdf",
+		".cache/beach.jpg",
+		"holiday/.hidden-beach.jpg",
+	)
+	ctx := context.Background()
+
+	result, err := Search(ctx, stor, "", Options{Query: "beach"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"holiday/Beach-Sunset.jpg", "holiday/beach/waves.png"}, resultPaths(result))
+	assert.Equal(t, 4, result.Scanned)
+	assert.False(t, result.Truncated)
+
+	// Every term has to match
+	result, err = Search(ctx, stor, "", Options{Query: "SUNSET beach"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"holiday/Beach-Sunset.jpg"}, resultPaths(result))
+
+	result, err = Search(ctx, stor, "/work/", Options{Query: "sunset"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"work/sunset-report.pdf"}, resultPaths(result))
+
+	result, err = Search(ctx, stor, "", Options{Query: "beach", Extensions: []string{".png"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"holiday/beach/waves.png"}, resultPaths(result))
+
+	result, err = Search(ctx, stor, "", Options{Query: "beach", ShowHidden: true})
+	require.NoError(t, err)
+	assert.Len(t, result.Items, 4)
+}
+
+func TestSearch_Keywords(t *testing.T) {
+	stor := newTestStorage(t, "a.png", "b.png")
+	result, err := Search(context.Background(), stor, "", Options{
+		Query: "screenshot",
+		Keywords: func(info storage.FileInfo) []string {
+			if info.Name == "b.png" {
+				return []string{"Screenshot"}
+			}
+			return nil
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b.png"}, resultPaths(result))
+}
+
+func TestSearch_Limits(t *testing.T) {
+	stor := newTestStorage(t, "a/1.jpg", "a/2.jpg", "b/3.jpg", "b/4.jpg", "c/5.jpg")
+	ctx := context.Background()
+
+	result, err := Search(ctx, stor, "", Options{Query: "jpg", Limit: 2, Workers: 2})
+	require.NoError(t, err)
+	assert.Len(t, result.Items, 2)
+	assert.True(t, result.Truncated)
+
+	// Exactly Limit matches is not truncated
+	result, err = Search(ctx, stor, "", Options{Query: "jpg", Limit: 5})
+	require.NoError(t, err)
+	assert.Len(t, result.Items, 5)
+	assert.False(t, result.Truncated)
+
+	result, err = Search(ctx, stor, "", Options{Query: "jpg", MaxScanned: 3})
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.Scanned)
+	assert.True(t, result.Truncated)
+}
+
+// walkerStorage exposes a flat listing over the wrapped storage
+type walkerStorage struct {
+	storage.Storage
+	walked bool
+}
+
+func (w *walkerStorage) Walk(ctx context.Context, key string, fn func(storage.FileInfo) error) error {
+	w.walked = true
+	var walk func(dir string) error
+	walk = func(dir string) error {
+		result, err := w.List(ctx, dir, storage.ListOptions{ShowHidden: true})
+		if err != nil {
+			return err
+		}
+		for _, item := range result.Items {
+			if item.IsDir {
+				if err := walk(item.Path); err != nil {
+					return err
+				}
+			} else if err := fn(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(key)
+}
+
+func TestSearch_Walker(t *testing.T) {
+	stor := &walkerStorage{Storage: newTestStorage(t, "x/cat.jpg", "x/y/cat2.jpg", "dog.jpg")}
+	result, err := Search(context.Background(), stor, "", Options{Query: "cat", Limit: 1})
+	require.NoError(t, err)
+	assert.True(t, stor.walked)
+	require.Len(t, result.Items, 1)
+	assert.True(t, strings.Contains(result.Items[0].Path, "cat"))
+	assert.True(t, result.Truncated)
+}
+
+func TestSearch_ListError(t *testing.T) {
+	_, err := Search(context.Background(), newTestStorage(t), "missing", Options{Query: "a"})
+	assert.Error(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = Search(ctx, newTestStorage(t, "a/b.jpg"), "", Options{Query: "b"})
+	assert.True(t, errors.Is(err, context.Canceled))
+}
@@ -0,0 +1,159 @@
+// Package foldercover keeps the image chosen to represent each folder in
+// folder grids.
+package foldercover
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/database"
+	"github.com/cshum/imagor-studio/server/internal/model"
+	"github.com/cshum/imagor-studio/server/pkg/uuid"
+	"github.com/uptrace/bun"
+	"go.uber.org/zap"
+)
+
+// getChunkSize keeps IN lists below the SQLite parameter limit
+const getChunkSize = 500
+
+// Store keeps one cover per scope and folder path. A cover is a file in the
+// folder or below it.
+type Store interface {
+	// Set chooses filePath as the cover of folderPath, an empty filePath
+	// clears it
+	Set(ctx context.Context, scope, folderPath, filePath, updatedBy string) error
+	// GetMulti returns the covers of folders, folders without one are left
+	// out
+	GetMulti(ctx context.Context, scope string, folders []string) (map[string]string, error)
+	// MoveFilePath keeps covers following a moved file or folder. Covers
+	// moved out of their folder are dropped.
+	MoveFilePath(ctx context.Context, scope, oldPath, newPath string) error
+	// RemoveFilePath drops the covers of a deleted folder and the folders
+	// below it, and covers of a deleted file or of files below a folder
+	RemoveFilePath(ctx context.Context, scope, path string) error
+}
+
+type store struct {
+	db     *bun.DB
+	logger *zap.Logger
+}
+
+func NewStore(db *bun.DB, logger *zap.Logger) Store {
+	return &store{db: db, logger: logger}
+}
+
+// IsBelow reports whether filePath is inside folderPath
+func IsBelow(folderPath, filePath string) bool {
+	return folderPath == "" || strings.HasPrefix(filePath, folderPath+"/")
+}
+
+func (s *store) Set(ctx context.Context, scope, folderPath, filePath, updatedBy string) error {
+	if filePath != "" && !IsBelow(folderPath, filePath) {
+		return fmt.Errorf("cover %s is not in folder %s", filePath, folderPath)
+	}
+	if filePath == "" {
+		if _, err := s.db.NewDelete().Model((*model.FolderCover)(nil)).
+			Where("scope = ?", scope).
+			Where("folder_path = ?", folderPath).
+			Exec(ctx); err != nil {
+			return fmt.Errorf("error clearing folder cover: %w", err)
+		}
+		return nil
+	}
+	if _, err := database.OnConflictUpdate(s.db.NewInsert().Model(&model.FolderCover{
+		ID:         uuid.GenerateUUID(),
+		Scope:      scope,
+		FolderPath: folderPath,
+		FilePath:   filePath,
+		UpdatedBy:  updatedBy,
+		UpdatedAt:  time.Now().UTC(),
+	}), "scope, folder_path", "file_path", "updated_by", "updated_at").Exec(ctx); err != nil {
+		return fmt.Errorf("error saving folder cover: %w", err)
+	}
+	return nil
+}
+
+func (s *store) GetMulti(ctx context.Context, scope string, folders []string) (map[string]string, error) {
+	result := make(map[string]string)
+	for start := 0; start < len(folders); start += getChunkSize {
+		end := min(start+getChunkSize, len(folders))
+		var rows []model.FolderCover
+		if err := s.db.NewSelect().Model(&rows).
+			Column("folder_path", "file_path").
+			Where("scope = ?", scope).
+			Where("folder_path IN (?)", bun.In(folders[start:end])).
+			Scan(ctx); err != nil {
+			return nil, fmt.Errorf("error getting folder covers: %w", err)
+		}
+		for _, row := range rows {
+			result[row.FolderPath] = row.FilePath
+		}
+	}
+	return result, nil
+}
+
+func (s *store) MoveFilePath(ctx context.Context, scope, oldPath, newPath string) error {
+	moved := func(p string) string {
+		if p == oldPath || strings.HasPrefix(p, oldPath+"/") {
+			return newPath + strings.TrimPrefix(p, oldPath)
+		}
+		return p
+	}
+	return s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var rows []model.FolderCover
+		if err := tx.NewSelect().Model(&rows).
+			Where("scope = ?", scope).
+			WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+				return q.
+					Where("folder_path = ? OR substr(folder_path, 1, ?) = ?", oldPath, len(oldPath)+1, oldPath+"/").
+					WhereOr("file_path = ? OR substr(file_path, 1, ?) = ?", oldPath, len(oldPath)+1, oldPath+"/")
+			}).
+			Scan(ctx); err != nil {
+			return fmt.Errorf("error moving folder covers: %w", err)
+		}
+		for _, row := range rows {
+			folderPath, filePath := moved(row.FolderPath), moved(row.FilePath)
+			if !IsBelow(folderPath, filePath) {
+				if _, err := tx.NewDelete().Model((*model.FolderCover)(nil)).
+					Where("id = ?", row.ID).
+					Exec(ctx); err != nil {
+					return fmt.Errorf("error moving folder covers: %w", err)
+				}
+				continue
+			}
+			// The cover of a replaced destination folder no longer applies
+			if folderPath != row.FolderPath {
+				if _, err := tx.NewDelete().Model((*model.FolderCover)(nil)).
+					Where("scope = ?", scope).
+					Where("folder_path = ?", folderPath).
+					Exec(ctx); err != nil {
+					return fmt.Errorf("error moving folder covers: %w", err)
+				}
+			}
+			if _, err := tx.NewUpdate().Model((*model.FolderCover)(nil)).
+				Set("folder_path = ?", folderPath).
+				Set("file_path = ?", filePath).
+				Where("id = ?", row.ID).
+				Exec(ctx); err != nil {
+				return fmt.Errorf("error moving folder covers: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+func (s *store) RemoveFilePath(ctx context.Context, scope, path string) error {
+	if _, err := s.db.NewDelete().Model((*model.FolderCover)(nil)).
+		Where("scope = ?", scope).
+		WhereGroup(" AND ", func(q *bun.DeleteQuery) *bun.DeleteQuery {
+			return q.
+				Where("folder_path = ? OR substr(folder_path, 1, ?) = ?", path, len(path)+1, path+"/").
+				WhereOr("file_path = ? OR substr(file_path, 1, ?) = ?", path, len(path)+1, path+"/")
+		}).
+		Exec(ctx); err != nil {
+		return fmt.Errorf("error removing folder covers: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package foldercover
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const scope = "system:global"
+
+func setupTestStore(t *testing.T) Store {
+	t.Helper()
+	db := testutil.NewDB(t)
+
+	return NewStore(db, zap.NewNop())
+}
+
+func TestStore(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	require.NoError(t, s.Set(ctx, scope, "photos", "photos/a.jpg", "user-1"))
+	require.NoError(t, s.Set(ctx, scope, "photos", "photos/trip/b.jpg", "user-1"))
+	require.NoError(t, s.Set(ctx, scope, "photos/trip", "photos/trip/b.jpg", "user-1"))
+	require.NoError(t, s.Set(ctx, "other", "photos", "photos/c.jpg", "user-1"))
+	assert.Error(t, s.Set(ctx, scope, "docs", "photos/a.jpg", "user-1"), "covers are in their folder")
+	assert.Error(t, s.Set(ctx, scope, "photos/tr", "photos/trip/b.jpg", "user-1"))
+
+	covers, err := s.GetMulti(ctx, scope, []string{"photos", "photos/trip", "docs"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"photos": "photos/trip/b.jpg", "photos/trip": "photos/trip/b.jpg"}, covers)
+
+	require.NoError(t, s.Set(ctx, scope, "photos/trip", "", "user-1"))
+	covers, err = s.GetMulti(ctx, scope, []string{"photos", "photos/trip"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"photos": "photos/trip/b.jpg"}, covers)
+
+	covers, err = s.GetMulti(ctx, scope, nil)
+	require.NoError(t, err)
+	assert.Empty(t, covers)
+}
+
+func TestStore_MoveFilePath(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	require.NoError(t, s.Set(ctx, scope, "photos", "photos/trip/b.jpg", "user-1"))
+	require.NoError(t, s.Set(ctx, scope, "photos/trip", "photos/trip/b.jpg", "user-1"))
+	require.NoError(t, s.Set(ctx, scope, "photos/trip/day1", "photos/trip/day1/c.jpg", "user-1"))
+	require.NoError(t, s.Set(ctx, scope, "archive/trip", "archive/trip/old.jpg", "user-1"))
+
+	// Renaming the cover within its folder keeps it
+	require.NoError(t, s.MoveFilePath(ctx, scope, "photos/trip/b.jpg", "photos/trip/best.jpg"))
+	covers, err := s.GetMulti(ctx, scope, []string{"photos", "photos/trip"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"photos": "photos/trip/best.jpg", "photos/trip": "photos/trip/best.jpg"}, covers)
+
+	// Moving a folder moves its covers and those below it, replaces the
+	// cover of the destination and drops covers moved out of their folder
+	require.NoError(t, s.MoveFilePath(ctx, scope, "photos/trip", "archive/trip"))
+	covers, err = s.GetMulti(ctx, scope, []string{"photos", "photos/trip", "archive/trip", "archive/trip/day1"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"archive/trip":      "archive/trip/best.jpg",
+		"archive/trip/day1": "archive/trip/day1/c.jpg",
+	}, covers)
+}
+
+func TestStore_RemoveFilePath(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	require.NoError(t, s.Set(ctx, scope, "photos", "photos/trip/b.jpg", "user-1"))
+	require.NoError(t, s.Set(ctx, scope, "photos/trip", "photos/trip/c.jpg", "user-1"))
+	require.NoError(t, s.Set(ctx, scope, "photos/trips", "photos/trips/d.jpg", "user-1"))
+	require.NoError(t, s.Set(ctx, scope, "docs", "docs/e.jpg", "user-1"))
+
+	require.NoError(t, s.RemoveFilePath(ctx, scope, "photos/trip"))
+	covers, err := s.GetMulti(ctx, scope, []string{"photos", "photos/trip", "photos/trips", "docs"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"photos/trips": "photos/trips/d.jpg", "docs": "docs/e.jpg"}, covers)
+
+	require.NoError(t, s.RemoveFilePath(ctx, scope, "docs/e.jpg"))
+	covers, err = s.GetMulti(ctx, scope, []string{"docs"})
+	require.NoError(t, err)
+	assert.Empty(t, covers)
+}
@@ -0,0 +1,300 @@
+// Package foldersettings keeps per folder settings: a description, the sort
+// listings of the folder default to, children pinned to the top and whether
+// the folder is hidden from the listing of its parent.
+package foldersettings
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cshum/imagor-studio/server/internal/database"
+	"github.com/cshum/imagor-studio/server/internal/model"
+	"github.com/cshum/imagor-studio/server/pkg/safepath"
+	"github.com/cshum/imagor-studio/server/pkg/uuid"
+	"github.com/uptrace/bun"
+	"go.uber.org/zap"
+)
+
+const (
+	// MaxDescriptionLength bounds descriptions, in characters
+	MaxDescriptionLength = 2000
+	// MaxPinned bounds the pinned children of a folder
+	MaxPinned = 100
+)
+
+var ErrInvalid = errors.New("invalid folder settings")
+
+// Settings of a folder. The zero value is a folder without settings.
+type Settings struct {
+	Description string
+	// SortBy and SortOrder are the listFiles sort options listings of the
+	// folder use when the request sets none, empty for the default
+	SortBy    string
+	SortOrder string
+	// Pinned are names of children listed first, in this order
+	Pinned []string
+	// Hidden leaves the folder out of the listing of its parent
+	Hidden bool
+
+	UpdatedBy string
+	UpdatedAt time.Time
+}
+
+// IsZero reports whether s holds no settings
+func (s Settings) IsZero() bool {
+	return s.Description == "" && s.SortBy == "" && s.SortOrder == "" && len(s.Pinned) == 0 && !s.Hidden
+}
+
+// Validate checks the settings of folderPath, the sort options are checked
+// by the caller
+func (s Settings) Validate(folderPath string) error {
+	if utf8.RuneCountInString(s.Description) > MaxDescriptionLength {
+		return fmt.Errorf("%w: description must be at most %d characters", ErrInvalid, MaxDescriptionLength)
+	}
+	if len(s.Pinned) > MaxPinned {
+		return fmt.Errorf("%w: at most %d children can be pinned", ErrInvalid, MaxPinned)
+	}
+	seen := make(map[string]bool, len(s.Pinned))
+	for _, name := range s.Pinned {
+		if name == "" || name == "." || strings.Contains(name, "/") || safepath.Validate(name) != nil {
+			return fmt.Errorf("%w: pinned must list names of children, got %q", ErrInvalid, name)
+		}
+		if seen[name] {
+			return fmt.Errorf("%w: %s is pinned twice", ErrInvalid, name)
+		}
+		seen[name] = true
+	}
+	if s.Hidden && folderPath == "" {
+		return fmt.Errorf("%w: the root folder cannot be hidden", ErrInvalid)
+	}
+	return nil
+}
+
+// Store keeps the settings of each folder per scope and folder path
+type Store interface {
+	// Get returns the settings of folderPath, the zero value when none are
+	// saved
+	Get(ctx context.Context, scope, folderPath string) (Settings, error)
+	// Save replaces the settings of folderPath, saving the zero value
+	// removes them
+	Save(ctx context.Context, scope, folderPath, updatedBy string, settings Settings) (Settings, error)
+	// HiddenChildren returns the paths of the hidden folders directly in
+	// parentPath
+	HiddenChildren(ctx context.Context, scope, parentPath string) (map[string]bool, error)
+	// MoveFilePath moves the settings of a folder and the folders below it,
+	// replacing settings saved at the destination. A child renamed in
+	// place stays pinned.
+	MoveFilePath(ctx context.Context, scope, oldPath, newPath string) error
+	// RemoveFilePath drops the settings of a folder and the folders below
+	// it, and unpins it from its parent
+	RemoveFilePath(ctx context.Context, scope, path string) error
+}
+
+type store struct {
+	db     *bun.DB
+	logger *zap.Logger
+}
+
+func NewStore(db *bun.DB, logger *zap.Logger) Store {
+	return &store{db: db, logger: logger}
+}
+
+// parentPath returns the folder containing folderPath, "" for the root and
+// folders directly in it
+func parentPath(folderPath string) string {
+	if parent := path.Dir(folderPath); parent != "." {
+		return parent
+	}
+	return ""
+}
+
+func (s *store) Get(ctx context.Context, scope, folderPath string) (Settings, error) {
+	var row model.FolderSetting
+	err := s.db.NewSelect().Model(&row).
+		Where("scope = ?", scope).
+		Where("folder_path = ?", folderPath).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Settings{}, nil
+	}
+	if err != nil {
+		return Settings{}, fmt.Errorf("error getting folder settings: %w", err)
+	}
+	return fromRow(row)
+}
+
+func fromRow(row model.FolderSetting) (Settings, error) {
+	settings := Settings{
+		Description: row.Description,
+		SortBy:      row.SortBy,
+		SortOrder:   row.SortOrder,
+		Hidden:      row.Hidden,
+		UpdatedBy:   row.UpdatedBy,
+		UpdatedAt:   row.UpdatedAt,
+	}
+	if row.Pinned != "" {
+		if err := json.Unmarshal([]byte(row.Pinned), &settings.Pinned); err != nil {
+			return Settings{}, fmt.Errorf("error decoding pinned children: %w", err)
+		}
+	}
+	return settings, nil
+}
+
+func (s *store) Save(ctx context.Context, scope, folderPath, updatedBy string, settings Settings) (Settings, error) {
+	if err := settings.Validate(folderPath); err != nil {
+		return Settings{}, err
+	}
+	settings.UpdatedBy = updatedBy
+	settings.UpdatedAt = time.Now().UTC()
+	if settings.IsZero() {
+		if _, err := s.db.NewDelete().Model((*model.FolderSetting)(nil)).
+			Where("scope = ?", scope).
+			Where("folder_path = ?", folderPath).
+			Exec(ctx); err != nil {
+			return Settings{}, fmt.Errorf("error replacing folder settings: %w", err)
+		}
+		return Settings{}, nil
+	}
+	if err := upsert(ctx, s.db, scope, folderPath, settings); err != nil {
+		return Settings{}, err
+	}
+	return settings, nil
+}
+
+// upsert writes the settings of a folder over its current ones, if any
+func upsert(ctx context.Context, db bun.IDB, scope, folderPath string, settings Settings) error {
+	pinned := ""
+	if len(settings.Pinned) > 0 {
+		data, err := json.Marshal(settings.Pinned)
+		if err != nil {
+			return fmt.Errorf("error encoding pinned children: %w", err)
+		}
+		pinned = string(data)
+	}
+	if _, err := database.OnConflictUpdate(db.NewInsert().Model(&model.FolderSetting{
+		ID:          uuid.GenerateUUID(),
+		Scope:       scope,
+		FolderPath:  folderPath,
+		ParentPath:  parentPath(folderPath),
+		Description: settings.Description,
+		SortBy:      settings.SortBy,
+		SortOrder:   settings.SortOrder,
+		Pinned:      pinned,
+		Hidden:      settings.Hidden,
+		UpdatedBy:   settings.UpdatedBy,
+		UpdatedAt:   settings.UpdatedAt,
+	}), "scope, folder_path", "parent_path", "description", "sort_by", "sort_order", "pinned", "hidden",
+		"updated_by", "updated_at").Exec(ctx); err != nil {
+		return fmt.Errorf("error saving folder settings: %w", err)
+	}
+	return nil
+}
+
+func (s *store) HiddenChildren(ctx context.Context, scope, parent string) (map[string]bool, error) {
+	var folders []string
+	if err := s.db.NewSelect().Model((*model.FolderSetting)(nil)).
+		Column("folder_path").
+		Where("scope = ?", scope).
+		Where("parent_path = ?", parent).
+		Where("hidden = ?", true).
+		Scan(ctx, &folders); err != nil {
+		return nil, fmt.Errorf("error getting hidden folders: %w", err)
+	}
+	result := make(map[string]bool, len(folders))
+	for _, folder := range folders {
+		result[folder] = true
+	}
+	return result, nil
+}
+
+func (s *store) MoveFilePath(ctx context.Context, scope, oldPath, newPath string) error {
+	return s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var rows []model.FolderSetting
+		if err := tx.NewSelect().Model(&rows).
+			Where("scope = ?", scope).
+			Where("(folder_path = ? OR substr(folder_path, 1, ?) = ?)", oldPath, len(oldPath)+1, oldPath+"/").
+			Scan(ctx); err != nil {
+			return fmt.Errorf("error moving folder settings: %w", err)
+		}
+		for _, row := range rows {
+			moved := newPath + strings.TrimPrefix(row.FolderPath, oldPath)
+			// Settings saved for the replaced destination no longer apply
+			if _, err := tx.NewDelete().Model((*model.FolderSetting)(nil)).
+				Where("scope = ?", scope).
+				Where("folder_path = ?", moved).
+				Exec(ctx); err != nil {
+				return fmt.Errorf("error moving folder settings: %w", err)
+			}
+			if _, err := tx.NewUpdate().Model((*model.FolderSetting)(nil)).
+				Set("folder_path = ?", moved).
+				Set("parent_path = ?", parentPath(moved)).
+				Where("id = ?", row.ID).
+				Exec(ctx); err != nil {
+				return fmt.Errorf("error moving folder settings: %w", err)
+			}
+		}
+		newName := ""
+		if parentPath(oldPath) == parentPath(newPath) {
+			newName = path.Base(newPath)
+		}
+		return replacePinned(ctx, tx, scope, parentPath(oldPath), path.Base(oldPath), newName)
+	})
+}
+
+func (s *store) RemoveFilePath(ctx context.Context, scope, folderPath string) error {
+	return s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewDelete().Model((*model.FolderSetting)(nil)).
+			Where("scope = ?", scope).
+			Where("(folder_path = ? OR substr(folder_path, 1, ?) = ?)", folderPath, len(folderPath)+1, folderPath+"/").
+			Exec(ctx); err != nil {
+			return fmt.Errorf("error removing folder settings: %w", err)
+		}
+		return replacePinned(ctx, tx, scope, parentPath(folderPath), path.Base(folderPath), "")
+	})
+}
+
+// replacePinned renames a pinned child of parent, or unpins it when newName
+// is empty
+func replacePinned(ctx context.Context, tx bun.Tx, scope, parent, oldName, newName string) error {
+	var row model.FolderSetting
+	err := tx.NewSelect().Model(&row).
+		Where("scope = ?", scope).
+		Where("folder_path = ?", parent).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error updating pinned children: %w", err)
+	}
+	settings, err := fromRow(row)
+	if err != nil {
+		return err
+	}
+	i := slices.Index(settings.Pinned, oldName)
+	if i < 0 {
+		return nil
+	}
+	if newName == "" || slices.Contains(settings.Pinned, newName) {
+		settings.Pinned = slices.Delete(settings.Pinned, i, i+1)
+	} else {
+		settings.Pinned[i] = newName
+	}
+	if settings.IsZero() {
+		if _, err := tx.NewDelete().Model((*model.FolderSetting)(nil)).
+			Where("id = ?", row.ID).
+			Exec(ctx); err != nil {
+			return fmt.Errorf("error updating pinned children: %w", err)
+		}
+		return nil
+	}
+	return upsert(ctx, tx, scope, parent, settings)
+}
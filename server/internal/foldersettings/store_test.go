@@ -0,0 +1,171 @@
+package foldersettings
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const scope = "system:global"
+
+func setupTestStore(t *testing.T) Store {
+	t.Helper()
+	db := testutil.NewDB(t)
+
+	return NewStore(db, zap.NewNop())
+}
+
+func TestStore(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	settings, err := s.Get(ctx, scope, "photos")
+	require.NoError(t, err)
+	assert.True(t, settings.IsZero())
+
+	saved, err := s.Save(ctx, scope, "photos", "user-1", Settings{
+		Description: "Holidays",
+		SortBy:      "MODIFIED_TIME",
+		SortOrder:   "DESC",
+		Pinned:      []string{"best", "cover.jpg"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", saved.UpdatedBy)
+	assert.False(t, saved.UpdatedAt.IsZero())
+
+	settings, err = s.Get(ctx, scope, "photos")
+	require.NoError(t, err)
+	assert.Equal(t, "Holidays", settings.Description)
+	assert.Equal(t, "MODIFIED_TIME", settings.SortBy)
+	assert.Equal(t, "DESC", settings.SortOrder)
+	assert.Equal(t, []string{"best", "cover.jpg"}, settings.Pinned)
+	assert.False(t, settings.Hidden)
+
+	settings, err = s.Get(ctx, "other", "photos")
+	require.NoError(t, err)
+	assert.True(t, settings.IsZero(), "settings are per scope")
+
+	// Saving no settings removes them
+	saved, err = s.Save(ctx, scope, "photos", "user-1", Settings{})
+	require.NoError(t, err)
+	assert.True(t, saved.IsZero())
+	settings, err = s.Get(ctx, scope, "photos")
+	require.NoError(t, err)
+	assert.True(t, settings.IsZero())
+}
+
+func TestStore_Validate(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	for name, settings := range map[string]Settings{
+		"long description": {Description: strings.Repeat("a", MaxDescriptionLength+1)},
+		"nested pinned":    {Pinned: []string{"a/b"}},
+		"empty pinned":     {Pinned: []string{""}},
+		"duplicate pinned": {Pinned: []string{"a", "a"}},
+		"too many pinned":  {Pinned: make([]string, MaxPinned+1)},
+	} {
+		_, err := s.Save(ctx, scope, "photos", "user-1", settings)
+		assert.ErrorIs(t, err, ErrInvalid, name)
+	}
+	_, err := s.Save(ctx, scope, "", "user-1", Settings{Hidden: true})
+	assert.ErrorIs(t, err, ErrInvalid, "the root cannot be hidden")
+}
+
+func TestStore_HiddenChildren(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	for _, folder := range []string{"private", "photos/private", "photos/trip/private"} {
+		_, err := s.Save(ctx, scope, folder, "user-1", Settings{Hidden: true})
+		require.NoError(t, err)
+	}
+	_, err := s.Save(ctx, scope, "photos/shown", "user-1", Settings{Description: "shown"})
+	require.NoError(t, err)
+
+	hidden, err := s.HiddenChildren(ctx, scope, "")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"private": true}, hidden)
+
+	hidden, err = s.HiddenChildren(ctx, scope, "photos")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"photos/private": true}, hidden)
+
+	hidden, err = s.HiddenChildren(ctx, scope, "docs")
+	require.NoError(t, err)
+	assert.Empty(t, hidden)
+}
+
+func TestStore_MoveFilePath(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	save := func(folder string, settings Settings) {
+		_, err := s.Save(ctx, scope, folder, "user-1", settings)
+		require.NoError(t, err)
+	}
+	save("photos", Settings{Pinned: []string{"trip", "best"}})
+	save("photos/trip", Settings{Description: "trip", Hidden: true})
+	save("photos/trip/day1", Settings{Description: "day1"})
+	save("photos/trips", Settings{Description: "not below trip"})
+	save("photos/journey", Settings{Description: "replaced"})
+
+	// Renamed in place, the folder stays pinned and hidden
+	require.NoError(t, s.MoveFilePath(ctx, scope, "photos/trip", "photos/journey"))
+	settings, err := s.Get(ctx, scope, "photos")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"journey", "best"}, settings.Pinned)
+	settings, err = s.Get(ctx, scope, "photos/journey")
+	require.NoError(t, err)
+	assert.Equal(t, "trip", settings.Description)
+	settings, err = s.Get(ctx, scope, "photos/journey/day1")
+	require.NoError(t, err)
+	assert.Equal(t, "day1", settings.Description)
+	settings, err = s.Get(ctx, scope, "photos/trips")
+	require.NoError(t, err)
+	assert.Equal(t, "not below trip", settings.Description)
+	hidden, err := s.HiddenChildren(ctx, scope, "photos")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"photos/journey": true}, hidden)
+
+	// Moved to another folder, it is unpinned from the old parent
+	require.NoError(t, s.MoveFilePath(ctx, scope, "photos/journey", "archive/journey"))
+	settings, err = s.Get(ctx, scope, "photos")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"best"}, settings.Pinned)
+	hidden, err = s.HiddenChildren(ctx, scope, "archive")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"archive/journey": true}, hidden)
+	settings, err = s.Get(ctx, scope, "archive/journey/day1")
+	require.NoError(t, err)
+	assert.Equal(t, "day1", settings.Description)
+}
+
+func TestStore_RemoveFilePath(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	save := func(folder string, settings Settings) {
+		_, err := s.Save(ctx, scope, folder, "user-1", settings)
+		require.NoError(t, err)
+	}
+	save("photos", Settings{Pinned: []string{"trip"}})
+	save("photos/trip", Settings{Description: "trip"})
+	save("photos/trip/day1", Settings{Description: "day1"})
+	save("photos/trips", Settings{Description: "trips"})
+
+	require.NoError(t, s.RemoveFilePath(ctx, scope, "photos/trip"))
+	for _, folder := range []string{"photos", "photos/trip", "photos/trip/day1"} {
+		settings, err := s.Get(ctx, scope, folder)
+		require.NoError(t, err)
+		assert.True(t, settings.IsZero(), folder)
+	}
+	settings, err := s.Get(ctx, scope, "photos/trips")
+	require.NoError(t, err)
+	assert.Equal(t, "trips", settings.Description)
+}
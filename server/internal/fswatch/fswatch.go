@@ -0,0 +1,278 @@
+// Package fswatch reports changes made to a directory tree from outside the
+// server, such as photos synced into the gallery by rsync or a camera app.
+//
+// Linux uses inotify. Other platforms, and trees with more directories than
+// the inotify watch limit allows, fall back to rescanning the tree. Names
+// starting with a dot are ignored, which skips the temporary files written by
+// rsync and most sync tools before renaming them into place.
+package fswatch
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Op is the kind of change
+type Op int
+
+const (
+	// Created is a file or folder that appeared
+	Created Op = iota + 1
+	// Updated is a file written in place
+	Updated
+	// Removed is a file or folder that disappeared
+	Removed
+)
+
+// Change is a change below the watched root
+type Change struct {
+	Op Op
+	// Path is slash separated and relative to the root
+	Path string
+}
+
+// Handler receives the changes of a batch, in the order they happened
+type Handler func([]Change)
+
+const (
+	// DefaultPollInterval is the rescan interval when inotify is unavailable
+	DefaultPollInterval = 10 * time.Second
+	// defaultBatchDelay groups the burst of changes made by a sync into few
+	// batches, and gives writers time to finish before the handler runs
+	defaultBatchDelay = time.Second
+)
+
+// errNativeUnavailable makes the watcher fall back to polling
+var errNativeUnavailable = errors.New("native file watching unavailable")
+
+// Watcher watches one root directory at a time
+type Watcher struct {
+	handler      Handler
+	logger       *zap.Logger
+	pollInterval time.Duration
+	batchDelay   time.Duration
+
+	mu     sync.Mutex
+	root   string
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Option configures a Watcher
+type Option func(*Watcher)
+
+// WithLogger sets the logger
+func WithLogger(logger *zap.Logger) Option {
+	return func(w *Watcher) {
+		w.logger = logger
+	}
+}
+
+// WithPollInterval sets the rescan interval used when inotify is unavailable
+func WithPollInterval(d time.Duration) Option {
+	return func(w *Watcher) {
+		if d > 0 {
+			w.pollInterval = d
+		}
+	}
+}
+
+// WithBatchDelay sets how long changes are collected before the handler runs
+func WithBatchDelay(d time.Duration) Option {
+	return func(w *Watcher) {
+		if d > 0 {
+			w.batchDelay = d
+		}
+	}
+}
+
+// New creates a watcher calling handler with the changes found. Nothing is
+// watched until Watch is called.
+func New(handler Handler, options ...Option) *Watcher {
+	w := &Watcher{
+		handler:      handler,
+		logger:       zap.NewNop(),
+		pollInterval: DefaultPollInterval,
+		batchDelay:   defaultBatchDelay,
+	}
+	for _, option := range options {
+		option(w)
+	}
+	return w
+}
+
+// Watch watches root until ctx is done, replacing the root watched so far.
+// It returns immediately, and does nothing when root is already watched. An
+// empty root stops watching.
+func (w *Watcher) Watch(ctx context.Context, root string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if root == w.root && w.done != nil {
+		select {
+		case <-w.done:
+			// the previous run ended with its context, start again
+		default:
+			return
+		}
+	}
+	w.stopLocked()
+	if root == "" {
+		return
+	}
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	w.root, w.cancel, w.done = root, cancel, done
+	go func() {
+		defer close(done)
+		w.run(runCtx, root)
+	}()
+}
+
+// Close stops watching and waits for pending changes to be handled
+func (w *Watcher) Close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stopLocked()
+}
+
+func (w *Watcher) stopLocked() {
+	if w.cancel != nil {
+		w.cancel()
+		<-w.done
+	}
+	w.root, w.cancel, w.done = "", nil, nil
+}
+
+func (w *Watcher) run(ctx context.Context, root string) {
+	b := newBatcher(w.handler, w.batchDelay)
+	batching := make(chan struct{})
+	go func() {
+		defer close(batching)
+		b.run(ctx)
+	}()
+	defer func() {
+		<-batching
+		b.flush()
+	}()
+
+	err := watchNative(ctx, root, w.logger, b.add)
+	if err == nil || ctx.Err() != nil {
+		return
+	}
+	if errors.Is(err, errNativeUnavailable) {
+		w.logger.Info("Watching file storage by polling",
+			zap.String("root", root), zap.Duration("interval", w.pollInterval), zap.Error(err))
+	} else {
+		w.logger.Warn("File storage watch failed, falling back to polling",
+			zap.String("root", root), zap.Error(err))
+	}
+	w.poll(ctx, root, b.add)
+}
+
+// ignored reports whether a base name is skipped: hidden files, and the
+// temporary files of sync tools
+func ignored(name string) bool {
+	return strings.HasPrefix(name, ".")
+}
+
+// relPath converts an absolute path below root to a Change path
+func relPath(root, p string) string {
+	rel, err := filepath.Rel(root, p)
+	if err != nil {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+// batcher collects changes and hands them to the handler batchDelay after
+// the first change of a batch, merging the changes made to the same path
+type batcher struct {
+	handler Handler
+	delay   time.Duration
+
+	mu      sync.Mutex
+	pending []Change
+	index   map[string]int
+	wake    chan struct{}
+}
+
+func newBatcher(handler Handler, delay time.Duration) *batcher {
+	return &batcher{
+		handler: handler,
+		delay:   delay,
+		index:   make(map[string]int),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+func (b *batcher) add(c Change) {
+	if c.Path == "" || c.Path == "." {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if i, ok := b.index[c.Path]; ok {
+		prev := b.pending[i].Op
+		switch {
+		case prev == Created && c.Op == Removed:
+			// appeared and disappeared within the batch
+			b.pending[i].Path = ""
+			delete(b.index, c.Path)
+			return
+		case prev == Created:
+			return
+		case prev == Removed && c.Op == Created:
+			b.pending[i].Op = Updated
+			return
+		default:
+			b.pending[i].Op = c.Op
+			return
+		}
+	}
+	b.index[c.Path] = len(b.pending)
+	b.pending = append(b.pending, c)
+	if len(b.pending) == 1 {
+		select {
+		case b.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (b *batcher) run(ctx context.Context) {
+	for {
+		select {
+		case <-b.wake:
+		case <-ctx.Done():
+			return
+		}
+		select {
+		case <-time.After(b.delay):
+			b.flush()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (b *batcher) flush() {
+	b.mu.Lock()
+	changes := make([]Change, 0, len(b.pending))
+	for _, c := range b.pending {
+		if c.Path != "" {
+			changes = append(changes, c)
+		}
+	}
+	b.pending = nil
+	b.index = make(map[string]int)
+	b.mu.Unlock()
+	if len(changes) > 0 {
+		b.handler(changes)
+	}
+}
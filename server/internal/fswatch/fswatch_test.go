@@ -0,0 +1,180 @@
+package fswatch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder collects the changes handled, merged by path
+type recorder struct {
+	mu      sync.Mutex
+	changes map[string]Op
+}
+
+func (r *recorder) handle(changes []Change) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range changes {
+		r.changes[c.Path] = c.Op
+	}
+}
+
+func (r *recorder) waitFor(t *testing.T, want map[string]Op) {
+	t.Helper()
+	assert.Eventually(t, func() bool {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		for p, op := range want {
+			if r.changes[p] != op {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, 20*time.Millisecond)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for p := range want {
+		delete(r.changes, p)
+	}
+}
+
+func (r *recorder) paths() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var paths []string
+	for p := range r.changes {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func writeFile(t *testing.T, root, path, content string) {
+	t.Helper()
+	full := filepath.Join(root, filepath.FromSlash(path))
+	require.NoError(t, os.MkdirAll(filepath.Dir(full), 0755))
+	require.NoError(t, os.WriteFile(full, []byte(content), 0644))
+}
+
+func testWatcher(t *testing.T, watch func(w *Watcher, ctx context.Context, root string)) {
+	root := t.TempDir()
+	writeFile(t, root, "photos/old.jpg", "old")
+	writeFile(t, root, "photos/edit.jpg", "v1")
+	rec := &recorder{changes: make(map[string]Op)}
+	w := New(rec.handle, WithBatchDelay(50*time.Millisecond), WithPollInterval(50*time.Millisecond))
+	defer w.Close()
+	watch(w, context.Background(), root)
+	// let the watcher take its initial view of the tree
+	time.Sleep(200 * time.Millisecond)
+
+	writeFile(t, root, "photos/new.jpg", "new")
+	writeFile(t, root, "photos/edit.jpg", "v2, longer")
+	require.NoError(t, os.Remove(filepath.Join(root, "photos/old.jpg")))
+	rec.waitFor(t, map[string]Op{
+		"photos/new.jpg":  Created,
+		"photos/edit.jpg": Updated,
+		"photos/old.jpg":  Removed,
+	})
+
+	// Folders synced in at once are reported with their content, temporary
+	// files of sync tools are not
+	staging := t.TempDir()
+	writeFile(t, staging, "day1/a.jpg", "a")
+	writeFile(t, staging, "day1/sub/b.jpg", "b")
+	writeFile(t, staging, "day1/.a.jpg.tmp", "partial")
+	require.NoError(t, os.Rename(filepath.Join(staging, "day1"), filepath.Join(root, "photos/day1")))
+	rec.waitFor(t, map[string]Op{
+		"photos/day1":           Created,
+		"photos/day1/a.jpg":     Created,
+		"photos/day1/sub":       Created,
+		"photos/day1/sub/b.jpg": Created,
+	})
+
+	require.NoError(t, os.RemoveAll(filepath.Join(root, "photos/day1")))
+	rec.waitFor(t, map[string]Op{"photos/day1": Removed})
+
+	w.Close()
+	time.Sleep(100 * time.Millisecond)
+	for _, p := range rec.paths() {
+		assert.Contains(t, []string{"photos/day1/a.jpg", "photos/day1/sub", "photos/day1/sub/b.jpg"}, p)
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	testWatcher(t, func(w *Watcher, ctx context.Context, root string) {
+		w.Watch(ctx, root)
+	})
+}
+
+func TestWatcher_Poll(t *testing.T) {
+	testWatcher(t, func(w *Watcher, ctx context.Context, root string) {
+		go w.poll(ctx, root, newBatcherFor(t, ctx, w).add)
+	})
+}
+
+// newBatcherFor runs a batcher for the lifetime of the test
+func newBatcherFor(t *testing.T, ctx context.Context, w *Watcher) *batcher {
+	ctx, cancel := context.WithCancel(ctx)
+	t.Cleanup(cancel)
+	b := newBatcher(w.handler, w.batchDelay)
+	go b.run(ctx)
+	return b
+}
+
+func TestWatcher_Watch(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	rec := &recorder{changes: make(map[string]Op)}
+	w := New(rec.handle, WithBatchDelay(20*time.Millisecond))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	w.Watch(ctx, first)
+	w.Watch(ctx, first)
+	time.Sleep(100 * time.Millisecond)
+	writeFile(t, first, "a.jpg", "a")
+	rec.waitFor(t, map[string]Op{"a.jpg": Created})
+
+	// Switching roots stops watching the previous one
+	w.Watch(ctx, second)
+	time.Sleep(100 * time.Millisecond)
+	writeFile(t, first, "ignored.jpg", "x")
+	writeFile(t, second, "b.jpg", "b")
+	rec.waitFor(t, map[string]Op{"b.jpg": Created})
+	assert.Empty(t, rec.paths())
+
+	w.Watch(ctx, "")
+	writeFile(t, second, "c.jpg", "c")
+	time.Sleep(100 * time.Millisecond)
+	assert.Empty(t, rec.paths())
+}
+
+func TestBatcher(t *testing.T) {
+	var got []Change
+	b := newBatcher(func(changes []Change) { got = append(got, changes...) }, time.Second)
+	b.add(Change{Op: Created, Path: "a.jpg"})
+	b.add(Change{Op: Updated, Path: "a.jpg"})
+	b.add(Change{Op: Created, Path: "tmp.jpg"})
+	b.add(Change{Op: Removed, Path: "tmp.jpg"})
+	b.add(Change{Op: Removed, Path: "b.jpg"})
+	b.add(Change{Op: Created, Path: "b.jpg"})
+	b.add(Change{Op: Updated, Path: "c.jpg"})
+	b.add(Change{Op: Removed, Path: "c.jpg"})
+	b.flush()
+	assert.Equal(t, []Change{
+		{Op: Created, Path: "a.jpg"},
+		{Op: Updated, Path: "b.jpg"},
+		{Op: Removed, Path: "c.jpg"},
+	}, got)
+
+	got = nil
+	b.flush()
+	assert.Empty(t, got)
+}
@@ -0,0 +1,197 @@
+//go:build linux
+
+package fswatch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"unsafe"
+
+	"go.uber.org/zap"
+	"golang.org/x/sys/unix"
+)
+
+const inotifyMask = unix.IN_CREATE | unix.IN_CLOSE_WRITE | unix.IN_DELETE |
+	unix.IN_MOVED_FROM | unix.IN_MOVED_TO |
+	unix.IN_ONLYDIR | unix.IN_DONT_FOLLOW | unix.IN_EXCL_UNLINK
+
+// inotifyPollTimeout is how often the event loop checks for cancellation, in
+// milliseconds
+const inotifyPollTimeout = 500
+
+// inotify keeps one watch per directory of the tree
+type inotify struct {
+	fd     int
+	root   string
+	logger *zap.Logger
+	emit   func(Change)
+	dirs   map[int]string // watch descriptor to absolute directory
+	// writing holds the files created but not closed yet, reported as
+	// created once written
+	writing map[string]bool
+}
+
+// watchNative reports the changes below root through inotify until ctx is
+// done. It returns errNativeUnavailable when inotify cannot watch the tree,
+// typically because the tree has more directories than fs.inotify.max_user_watches.
+func watchNative(ctx context.Context, root string, logger *zap.Logger, emit func(Change)) error {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errNativeUnavailable, err)
+	}
+	defer unix.Close(fd)
+	n := &inotify{
+		fd:      fd,
+		root:    root,
+		logger:  logger,
+		emit:    emit,
+		dirs:    make(map[int]string),
+		writing: make(map[string]bool),
+	}
+	if err := n.addTree(root, false); err != nil {
+		return err
+	}
+	if len(n.dirs) == 0 {
+		return fmt.Errorf("%w: %s cannot be watched", errNativeUnavailable, root)
+	}
+	logger.Info("Watching file storage", zap.String("root", root), zap.Int("directories", len(n.dirs)))
+
+	buf := make([]byte, 64*1024)
+	fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+	for ctx.Err() == nil {
+		count, err := unix.Poll(fds, inotifyPollTimeout)
+		if err != nil {
+			if errors.Is(err, unix.EINTR) {
+				continue
+			}
+			return fmt.Errorf("inotify poll: %w", err)
+		}
+		if count == 0 {
+			continue
+		}
+		size, err := unix.Read(fd, buf)
+		if err != nil {
+			if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EINTR) {
+				continue
+			}
+			return fmt.Errorf("inotify read: %w", err)
+		}
+		if err := n.handle(buf[:size]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handle processes the events read at once
+func (n *inotify) handle(buf []byte) error {
+	for offset := 0; offset+unix.SizeofInotifyEvent <= len(buf); {
+		raw := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+		nameStart := offset + unix.SizeofInotifyEvent
+		nameEnd := nameStart + int(raw.Len)
+		if nameEnd > len(buf) {
+			break
+		}
+		name := string(bytes.TrimRight(buf[nameStart:nameEnd], "\x00"))
+		offset = nameEnd
+
+		if raw.Mask&unix.IN_Q_OVERFLOW != 0 {
+			n.logger.Warn("File storage watch overflowed, some changes were missed", zap.String("root", n.root))
+			continue
+		}
+		if raw.Mask&unix.IN_IGNORED != 0 {
+			delete(n.dirs, int(raw.Wd))
+			continue
+		}
+		dir, ok := n.dirs[int(raw.Wd)]
+		if !ok || name == "" || ignored(name) {
+			continue
+		}
+		p := filepath.Join(dir, name)
+		isDir := raw.Mask&unix.IN_ISDIR != 0
+		switch {
+		case raw.Mask&(unix.IN_DELETE|unix.IN_MOVED_FROM) != 0:
+			delete(n.writing, p)
+			if isDir {
+				n.removeTree(p)
+			}
+			n.emit(Change{Op: Removed, Path: relPath(n.root, p)})
+		case raw.Mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0:
+			if isDir {
+				n.emit(Change{Op: Created, Path: relPath(n.root, p)})
+				// Report what the folder already holds, as it may have been
+				// moved in or filled before the watch was added
+				if err := n.addTree(p, true); err != nil {
+					return err
+				}
+			} else if raw.Mask&unix.IN_CREATE != 0 {
+				n.writing[p] = true
+			} else {
+				n.emit(Change{Op: Created, Path: relPath(n.root, p)})
+			}
+		case raw.Mask&unix.IN_CLOSE_WRITE != 0:
+			op := Updated
+			if n.writing[p] {
+				op = Created
+				delete(n.writing, p)
+			}
+			n.emit(Change{Op: op, Path: relPath(n.root, p)})
+		}
+	}
+	return nil
+}
+
+// addTree watches dir and the folders below it, reporting their content as
+// created when report is set
+func (n *inotify) addTree(dir string, report bool) error {
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// vanished or unreadable, a removal is reported by the parent
+			return nil
+		}
+		if p != dir && ignored(d.Name()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if p != dir && report {
+			n.emit(Change{Op: Created, Path: relPath(n.root, p)})
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		wd, err := unix.InotifyAddWatch(n.fd, p, inotifyMask)
+		if err != nil {
+			if errors.Is(err, unix.ENOSPC) {
+				return fmt.Errorf("%w: inotify watch limit reached", errNativeUnavailable)
+			}
+			if errors.Is(err, unix.ENOENT) || errors.Is(err, unix.ENOTDIR) || errors.Is(err, unix.EACCES) {
+				return filepath.SkipDir
+			}
+			return fmt.Errorf("inotify watch %s: %w", p, err)
+		}
+		n.dirs[wd] = p
+		return nil
+	})
+}
+
+// removeTree drops the watches of a folder moved away or deleted
+func (n *inotify) removeTree(dir string) {
+	for wd, p := range n.dirs {
+		if p == dir || strings.HasPrefix(p, dir+string(filepath.Separator)) {
+			_, _ = unix.InotifyRmWatch(n.fd, uint32(wd))
+			delete(n.dirs, wd)
+		}
+	}
+	for p := range n.writing {
+		if strings.HasPrefix(p, dir+string(filepath.Separator)) {
+			delete(n.writing, p)
+		}
+	}
+}
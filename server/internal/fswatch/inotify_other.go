@@ -0,0 +1,14 @@
+//go:build !linux
+
+package fswatch
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// watchNative is only implemented with inotify, other platforms poll
+func watchNative(ctx context.Context, root string, logger *zap.Logger, emit func(Change)) error {
+	return errNativeUnavailable
+}
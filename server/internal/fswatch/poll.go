@@ -0,0 +1,81 @@
+package fswatch
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"time"
+)
+
+// entryState is what a rescan compares to detect changes
+type entryState struct {
+	dir     bool
+	size    int64
+	modTime time.Time
+}
+
+// poll rescans root every poll interval until ctx is done
+func (w *Watcher) poll(ctx context.Context, root string, emit func(Change)) {
+	prev := scan(root)
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		cur := scan(root)
+		diff(prev, cur, emit)
+		prev = cur
+	}
+}
+
+// scan lists the tree below root. Entries that cannot be read are left out,
+// so they show as removed until they can be read again.
+func scan(root string) map[string]entryState {
+	entries := make(map[string]entryState)
+	_ = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == root {
+			return nil
+		}
+		if ignored(d.Name()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		state := entryState{dir: d.IsDir()}
+		if !state.dir {
+			state.size, state.modTime = info.Size(), info.ModTime()
+		}
+		entries[relPath(root, p)] = state
+		return nil
+	})
+	return entries
+}
+
+// diff emits the changes from prev to cur
+func diff(prev, cur map[string]entryState, emit func(Change)) {
+	for p, state := range cur {
+		old, ok := prev[p]
+		switch {
+		case !ok:
+			emit(Change{Op: Created, Path: p})
+		case old.dir != state.dir:
+			emit(Change{Op: Removed, Path: p})
+			emit(Change{Op: Created, Path: p})
+		case old != state:
+			emit(Change{Op: Updated, Path: p})
+		}
+	}
+	for p := range prev {
+		if _, ok := cur[p]; !ok {
+			emit(Change{Op: Removed, Path: p})
+		}
+	}
+}
@@ -7,23 +7,200 @@ import (
 	"fmt"
 	"io"
 	"strconv"
+
+	"github.com/99designs/gqlgen/graphql"
 )
 
+type Album struct {
+	ID                 string         `json:"id"`
+	Name               string         `json:"name"`
+	ItemCount          int            `json:"itemCount"`
+	CoverPath          *string        `json:"coverPath,omitempty"`
+	CoverThumbnailUrls *ThumbnailUrls `json:"coverThumbnailUrls,omitempty"`
+	CreatedAt          string         `json:"createdAt"`
+	UpdatedAt          string         `json:"updatedAt"`
+}
+
+type AlbumItem struct {
+	Path          string         `json:"path"`
+	AddedAt       string         `json:"addedAt"`
+	ThumbnailUrls *ThumbnailUrls `json:"thumbnailUrls,omitempty"`
+}
+
+type APIChange struct {
+	Version     int           `json:"version"`
+	Kind        APIChangeKind `json:"kind"`
+	Path        string        `json:"path"`
+	Description string        `json:"description"`
+	Replacement *string       `json:"replacement,omitempty"`
+}
+
+type APIDeprecation struct {
+	Path         string  `json:"path"`
+	Reason       string  `json:"reason"`
+	DeprecatedIn *int    `json:"deprecatedIn,omitempty"`
+	Replacement  *string `json:"replacement,omitempty"`
+}
+
+type APIToken struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Prefix     string   `json:"prefix"`
+	Scopes     []string `json:"scopes"`
+	ExpiresAt  *string  `json:"expiresAt,omitempty"`
+	LastUsedAt *string  `json:"lastUsedAt,omitempty"`
+	CreatedAt  string   `json:"createdAt"`
+}
+
+type APIVersionInfo struct {
+	Version      int               `json:"version"`
+	CompatMode   bool              `json:"compatMode"`
+	Deprecations []*APIDeprecation `json:"deprecations"`
+}
+
+type AuditLogEntry struct {
+	ID        string  `json:"id"`
+	CreatedAt string  `json:"createdAt"`
+	UserID    string  `json:"userID"`
+	Role      string  `json:"role"`
+	Operation string  `json:"operation"`
+	SpaceID   *string `json:"spaceID,omitempty"`
+	Path      *string `json:"path,omitempty"`
+	DestPath  *string `json:"destPath,omitempty"`
+	PathCount int     `json:"pathCount"`
+	Target    *string `json:"target,omitempty"`
+	ClientIP  string  `json:"clientIP"`
+	Error     *string `json:"error,omitempty"`
+}
+
+type AuditLogFilter struct {
+	UserID     *string `json:"userID,omitempty"`
+	Operation  *string `json:"operation,omitempty"`
+	Path       *string `json:"path,omitempty"`
+	Since      *string `json:"since,omitempty"`
+	Until      *string `json:"until,omitempty"`
+	FailedOnly *bool   `json:"failedOnly,omitempty"`
+}
+
+type AuditLogPage struct {
+	Items      []*AuditLogEntry `json:"items"`
+	TotalCount int              `json:"totalCount"`
+	EndCursor  *string          `json:"endCursor,omitempty"`
+}
+
 type AuthProvider struct {
 	Provider string  `json:"provider"`
 	Email    *string `json:"email,omitempty"`
 	LinkedAt string  `json:"linkedAt"`
 }
 
+type AutoUploadBatchResult struct {
+	Uploaded   int                 `json:"uploaded"`
+	Duplicates int                 `json:"duplicates"`
+	Failed     int                 `json:"failed"`
+	Results    []*AutoUploadResult `json:"results"`
+}
+
+type AutoUploadFile struct {
+	Filename   string          `json:"filename"`
+	CapturedAt *string         `json:"capturedAt,omitempty"`
+	Sha256     *string         `json:"sha256,omitempty"`
+	Content    *graphql.Upload `json:"content,omitempty"`
+}
+
+type AutoUploadResult struct {
+	Filename string           `json:"filename"`
+	Status   AutoUploadStatus `json:"status"`
+	Path     *string          `json:"path,omitempty"`
+	Sha256   *string          `json:"sha256,omitempty"`
+	Error    *string          `json:"error,omitempty"`
+	Code     *string          `json:"code,omitempty"`
+}
+
+type Backup struct {
+	FileName  string `json:"fileName"`
+	Content   string `json:"content"`
+	Migration string `json:"migration"`
+	CreatedAt string `json:"createdAt"`
+}
+
+type BatchFileResult struct {
+	Succeeded int                   `json:"succeeded"`
+	Failed    int                   `json:"failed"`
+	Results   []*FileTransferResult `json:"results"`
+}
+
 type BillingSession struct {
 	URL string `json:"url"`
 }
 
+type BulkDownload struct {
+	Token      string `json:"token"`
+	ExpiresAt  string `json:"expiresAt"`
+	FileCount  int    `json:"fileCount"`
+	TotalBytes int    `json:"totalBytes"`
+	ListURL    string `json:"listUrl"`
+	Aria2Url   string `json:"aria2Url"`
+}
+
+type CastLink struct {
+	URL         string   `json:"url"`
+	ContentType string   `json:"contentType"`
+	Mode        CastMode `json:"mode"`
+	ExpiresAt   *string  `json:"expiresAt,omitempty"`
+}
+
 type ChangePasswordInput struct {
 	CurrentPassword *string `json:"currentPassword,omitempty"`
 	NewPassword     string  `json:"newPassword"`
 }
 
+type ChunkedUpload struct {
+	ID             string `json:"id"`
+	Path           string `json:"path"`
+	SizeBytes      int    `json:"sizeBytes"`
+	ChunkSize      int    `json:"chunkSize"`
+	ChunkCount     int    `json:"chunkCount"`
+	ReceivedChunks []int  `json:"receivedChunks"`
+	ExpiresAt      string `json:"expiresAt"`
+}
+
+type Comment struct {
+	ID         string  `json:"id"`
+	Path       string  `json:"path"`
+	Text       string  `json:"text"`
+	AuthorID   string  `json:"authorID"`
+	AuthorName string  `json:"authorName"`
+	CreatedAt  string  `json:"createdAt"`
+	EditedAt   *string `json:"editedAt,omitempty"`
+}
+
+type ComparedImage struct {
+	Path   string `json:"path"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	URL    string `json:"url"`
+}
+
+type ComparisonAlignment struct {
+	SameDimensions   bool    `json:"sameDimensions"`
+	AspectRatioMatch bool    `json:"aspectRatioMatch"`
+	ScaleX           float64 `json:"scaleX"`
+	ScaleY           float64 `json:"scaleY"`
+	Width            int     `json:"width"`
+	Height           int     `json:"height"`
+}
+
+type ConfigSetting struct {
+	Key             string       `json:"key"`
+	Flag            string       `json:"flag"`
+	Value           string       `json:"value"`
+	Masked          bool         `json:"masked"`
+	Source          ConfigSource `json:"source"`
+	DefaultValue    string       `json:"defaultValue"`
+	RegistryIgnored bool         `json:"registryIgnored"`
+}
+
 type CreateUserInput struct {
 	DisplayName string `json:"displayName"`
 	Username    string `json:"username"`
@@ -31,28 +208,142 @@ type CreateUserInput struct {
 	Role        string `json:"role"`
 }
 
+type CreatedAPIToken struct {
+	APIToken *APIToken `json:"apiToken"`
+	Token    string    `json:"token"`
+}
+
+type CreatedS3AccessKey struct {
+	S3AccessKey     *S3AccessKey `json:"s3AccessKey"`
+	SecretAccessKey string       `json:"secretAccessKey"`
+}
+
+type DeleteFolderResult struct {
+	Deleted               bool       `json:"deleted"`
+	ItemCount             int        `json:"itemCount"`
+	MoreItems             bool       `json:"moreItems"`
+	RemovedCount          int        `json:"removedCount"`
+	ConfirmationToken     *string    `json:"confirmationToken,omitempty"`
+	ConfirmationExpiresAt *string    `json:"confirmationExpiresAt,omitempty"`
+	Operation             *Operation `json:"operation,omitempty"`
+}
+
 type DimensionsInput struct {
 	Width  int `json:"width"`
 	Height int `json:"height"`
 }
 
+type DirectUpload struct {
+	ID              string                 `json:"id"`
+	Path            string                 `json:"path"`
+	UploadURL       *string                `json:"uploadURL,omitempty"`
+	PartSize        int                    `json:"partSize"`
+	Parts           []*PresignedUploadPart `json:"parts"`
+	ExpiresAt       string                 `json:"expiresAt"`
+	RequiredHeaders []*UploadHeader        `json:"requiredHeaders"`
+}
+
+type DownloadLink struct {
+	URL       string `json:"url"`
+	ExpiresAt string `json:"expiresAt"`
+	Direct    bool   `json:"direct"`
+}
+
+type DuplicateGroup struct {
+	Hash  string   `json:"hash"`
+	Size  int      `json:"size"`
+	Paths []string `json:"paths"`
+}
+
 type EmailChangeRequestResult struct {
 	Email                string `json:"email"`
 	VerificationRequired bool   `json:"verificationRequired"`
 }
 
+type EncryptionKeyRotation struct {
+	Rotated   int    `json:"rotated"`
+	RotatedAt string `json:"rotatedAt"`
+}
+
+type ExtensionUsage struct {
+	Extension string `json:"extension"`
+	FileCount int    `json:"fileCount"`
+	TotalSize int    `json:"totalSize"`
+}
+
+type FaceBox struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+type Favorite struct {
+	Path      string `json:"path"`
+	CreatedAt string `json:"createdAt"`
+}
+
+type FileChange struct {
+	Kind    FileChangeKind `json:"kind"`
+	Path    string         `json:"path"`
+	OldPath *string        `json:"oldPath,omitempty"`
+}
+
+type FileChangeSet struct {
+	Changes        []*FileChange `json:"changes"`
+	NextToken      string        `json:"nextToken"`
+	HasMore        bool          `json:"hasMore"`
+	ResyncRequired bool          `json:"resyncRequired"`
+}
+
 type FileItem struct {
-	Name          string         `json:"name"`
-	Path          string         `json:"path"`
-	Size          int            `json:"size"`
-	IsDirectory   bool           `json:"isDirectory"`
-	ModifiedTime  string         `json:"modifiedTime"`
-	ThumbnailUrls *ThumbnailUrls `json:"thumbnailUrls,omitempty"`
+	Name               string         `json:"name"`
+	Path               string         `json:"path"`
+	Size               int            `json:"size"`
+	IsDirectory        bool           `json:"isDirectory"`
+	ModifiedTime       string         `json:"modifiedTime"`
+	ThumbnailUrls      *ThumbnailUrls `json:"thumbnailUrls,omitempty"`
+	SystemTags         []string       `json:"systemTags"`
+	CaptureTime        *string        `json:"captureTime,omitempty"`
+	PreviewSpriteURL   *string        `json:"previewSpriteUrl,omitempty"`
+	IsFavorite         bool           `json:"isFavorite"`
+	Rating             *int           `json:"rating,omitempty"`
+	IsRaw              bool           `json:"isRaw"`
+	LivePhotoVideoURL  *string        `json:"livePhotoVideoUrl,omitempty"`
+	CoverPath          *string        `json:"coverPath,omitempty"`
+	CoverThumbnailUrls *ThumbnailUrls `json:"coverThumbnailUrls,omitempty"`
 }
 
 type FileList struct {
 	Items      []*FileItem `json:"items"`
 	TotalCount int         `json:"totalCount"`
+	Etag       *string     `json:"etag,omitempty"`
+	EndCursor  *string     `json:"endCursor,omitempty"`
+}
+
+type FileMetadata struct {
+	Format       *string  `json:"format,omitempty"`
+	Width        *int     `json:"width,omitempty"`
+	Height       *int     `json:"height,omitempty"`
+	Orientation  *int     `json:"orientation,omitempty"`
+	CaptureTime  *string  `json:"captureTime,omitempty"`
+	CameraMake   *string  `json:"cameraMake,omitempty"`
+	CameraModel  *string  `json:"cameraModel,omitempty"`
+	LensModel    *string  `json:"lensModel,omitempty"`
+	Software     *string  `json:"software,omitempty"`
+	Iso          *int     `json:"iso,omitempty"`
+	Aperture     *float64 `json:"aperture,omitempty"`
+	ExposureTime *string  `json:"exposureTime,omitempty"`
+	FocalLength  *float64 `json:"focalLength,omitempty"`
+	Latitude     *float64 `json:"latitude,omitempty"`
+	Longitude    *float64 `json:"longitude,omitempty"`
+	Altitude     *float64 `json:"altitude,omitempty"`
+}
+
+type FileSearchResult struct {
+	Items        []*FileItem `json:"items"`
+	ScannedCount int         `json:"scannedCount"`
+	Truncated    bool        `json:"truncated"`
 }
 
 type FileStat struct {
@@ -63,6 +354,7 @@ type FileStat struct {
 	ModifiedTime  string         `json:"modifiedTime"`
 	Etag          *string        `json:"etag,omitempty"`
 	ThumbnailUrls *ThumbnailUrls `json:"thumbnailUrls,omitempty"`
+	SystemTags    []string       `json:"systemTags"`
 }
 
 type FileStorageConfig struct {
@@ -77,6 +369,138 @@ type FileStorageInput struct {
 	WritePermissions *string `json:"writePermissions,omitempty"`
 }
 
+type FileTransferInput struct {
+	SourcePath string `json:"sourcePath"`
+	DestPath   string `json:"destPath"`
+}
+
+type FileTransferResult struct {
+	SourcePath string  `json:"sourcePath"`
+	DestPath   string  `json:"destPath"`
+	Success    bool    `json:"success"`
+	Error      *string `json:"error,omitempty"`
+	Code       *string `json:"code,omitempty"`
+}
+
+type FolderCostEstimate struct {
+	Folder         string               `json:"folder"`
+	ObjectCount    int                  `json:"objectCount"`
+	Bytes          int                  `json:"bytes"`
+	StorageClasses []*StorageClassUsage `json:"storageClasses"`
+	StorageCost    float64              `json:"storageCost"`
+	EgressCost     float64              `json:"egressCost"`
+	TotalCost      float64              `json:"totalCost"`
+}
+
+type FolderSettings struct {
+	Path        string      `json:"path"`
+	Description string      `json:"description"`
+	SortBy      *SortOption `json:"sortBy,omitempty"`
+	SortOrder   *SortOrder  `json:"sortOrder,omitempty"`
+	Pinned      []string    `json:"pinned"`
+	Hidden      bool        `json:"hidden"`
+	UpdatedBy   *string     `json:"updatedBy,omitempty"`
+	UpdatedAt   *string     `json:"updatedAt,omitempty"`
+}
+
+type FolderSettingsInput struct {
+	Description *string     `json:"description,omitempty"`
+	SortBy      *SortOption `json:"sortBy,omitempty"`
+	SortOrder   *SortOrder  `json:"sortOrder,omitempty"`
+	Pinned      []string    `json:"pinned,omitempty"`
+	Hidden      *bool       `json:"hidden,omitempty"`
+}
+
+type FolderTreeNode struct {
+	Name        string            `json:"name"`
+	Path        string            `json:"path"`
+	FolderCount *int              `json:"folderCount,omitempty"`
+	FileCount   *int              `json:"fileCount,omitempty"`
+	Children    []*FolderTreeNode `json:"children,omitempty"`
+}
+
+type GeoBounds struct {
+	North float64 `json:"north"`
+	South float64 `json:"south"`
+	East  float64 `json:"east"`
+	West  float64 `json:"west"`
+}
+
+type GeoBoundsInput struct {
+	North float64 `json:"north"`
+	South float64 `json:"south"`
+	East  float64 `json:"east"`
+	West  float64 `json:"west"`
+}
+
+type GeoCluster struct {
+	Latitude           float64        `json:"latitude"`
+	Longitude          float64        `json:"longitude"`
+	Count              int            `json:"count"`
+	Bounds             *GeoBounds     `json:"bounds"`
+	RepresentativePath string         `json:"representativePath"`
+	ThumbnailUrls      *ThumbnailUrls `json:"thumbnailUrls,omitempty"`
+}
+
+type ImageComparison struct {
+	A             *ComparedImage       `json:"a"`
+	B             *ComparedImage       `json:"b"`
+	Alignment     *ComparisonAlignment `json:"alignment"`
+	SideBySideURL string               `json:"sideBySideUrl"`
+	OverlayURL    string               `json:"overlayUrl"`
+	DiffURL       string               `json:"diffUrl"`
+}
+
+type ImageEdit struct {
+	Path       string             `json:"path"`
+	Crop       *ImageEditCrop     `json:"crop,omitempty"`
+	Rotate     int                `json:"rotate"`
+	HFlip      bool               `json:"hFlip"`
+	VFlip      bool               `json:"vFlip"`
+	Brightness int                `json:"brightness"`
+	Contrast   int                `json:"contrast"`
+	Saturation int                `json:"saturation"`
+	Filters    []*ImageEditFilter `json:"filters"`
+	URL        string             `json:"url"`
+	UpdatedBy  string             `json:"updatedBy"`
+	UpdatedAt  string             `json:"updatedAt"`
+}
+
+type ImageEditCrop struct {
+	Left   int `json:"left"`
+	Top    int `json:"top"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+type ImageEditCropInput struct {
+	Left   int `json:"left"`
+	Top    int `json:"top"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+type ImageEditFilter struct {
+	Name string `json:"name"`
+	Args string `json:"args"`
+}
+
+type ImageEditFilterInput struct {
+	Name string  `json:"name"`
+	Args *string `json:"args,omitempty"`
+}
+
+type ImageEditInput struct {
+	Crop       *ImageEditCropInput     `json:"crop,omitempty"`
+	Rotate     *int                    `json:"rotate,omitempty"`
+	HFlip      *bool                   `json:"hFlip,omitempty"`
+	VFlip      *bool                   `json:"vFlip,omitempty"`
+	Brightness *int                    `json:"brightness,omitempty"`
+	Contrast   *int                    `json:"contrast,omitempty"`
+	Saturation *int                    `json:"saturation,omitempty"`
+	Filters    []*ImageEditFilterInput `json:"filters,omitempty"`
+}
+
 type ImagorConfig struct {
 	HasSecret      bool             `json:"hasSecret"`
 	SignerType     ImagorSignerType `json:"signerType"`
@@ -145,6 +569,40 @@ type LicenseStatus struct {
 type Mutation struct {
 }
 
+type NetworkACL struct {
+	Group      string   `json:"group"`
+	Restricted bool     `json:"restricted"`
+	Allow      []string `json:"allow"`
+	Deny       []string `json:"deny"`
+}
+
+type NotificationTestResult struct {
+	Channel string  `json:"channel"`
+	Success bool    `json:"success"`
+	Error   *string `json:"error,omitempty"`
+}
+
+type Operation struct {
+	ID         string          `json:"id"`
+	Kind       string          `json:"kind"`
+	Status     OperationStatus `json:"status"`
+	Completed  int             `json:"completed"`
+	Total      *int            `json:"total,omitempty"`
+	Message    *string         `json:"message,omitempty"`
+	Error      *string         `json:"error,omitempty"`
+	Results    []string        `json:"results"`
+	CreatedAt  string          `json:"createdAt"`
+	UpdatedAt  string          `json:"updatedAt"`
+	StartedAt  *string         `json:"startedAt,omitempty"`
+	FinishedAt *string         `json:"finishedAt,omitempty"`
+}
+
+type OperationAllowList struct {
+	Role       string   `json:"role"`
+	Restricted bool     `json:"restricted"`
+	Fields     []string `json:"fields"`
+}
+
 type OrgInvitation struct {
 	ID        string                  `json:"id"`
 	Email     string                  `json:"email"`
@@ -182,21 +640,124 @@ type Organization struct {
 	UpdatedAt          string        `json:"updatedAt"`
 }
 
+type OrganizeMove struct {
+	SourcePath string `json:"sourcePath"`
+	DestPath   string `json:"destPath"`
+}
+
+type OrganizeResult struct {
+	Moves     []*OrganizeMove `json:"moves"`
+	Operation *Operation      `json:"operation,omitempty"`
+}
+
+type PendingStorageConfig struct {
+	Type       string             `json:"type"`
+	UpdatedAt  *string            `json:"updatedAt,omitempty"`
+	S3Config   *S3StorageConfig   `json:"s3Config,omitempty"`
+	SftpConfig *SFTPStorageConfig `json:"sftpConfig,omitempty"`
+}
+
+type Person struct {
+	ID                 string         `json:"id"`
+	Name               *string        `json:"name,omitempty"`
+	FaceCount          int            `json:"faceCount"`
+	CoverPath          *string        `json:"coverPath,omitempty"`
+	CoverFace          *FaceBox       `json:"coverFace,omitempty"`
+	CoverThumbnailUrls *ThumbnailUrls `json:"coverThumbnailUrls,omitempty"`
+}
+
+type PersonList struct {
+	Items      []*Person `json:"items"`
+	TotalCount int       `json:"totalCount"`
+	EndCursor  *string   `json:"endCursor,omitempty"`
+}
+
+type PersonPhoto struct {
+	Path          string         `json:"path"`
+	ThumbnailUrls *ThumbnailUrls `json:"thumbnailUrls,omitempty"`
+}
+
+type PersonPhotoList struct {
+	Items      []*PersonPhoto `json:"items"`
+	TotalCount int            `json:"totalCount"`
+	EndCursor  *string        `json:"endCursor,omitempty"`
+}
+
+type Preferences struct {
+	Theme             *Theme      `json:"theme,omitempty"`
+	SortBy            *SortOption `json:"sortBy,omitempty"`
+	SortOrder         *SortOrder  `json:"sortOrder,omitempty"`
+	GridSize          *GridSize   `json:"gridSize,omitempty"`
+	SlideshowInterval *int        `json:"slideshowInterval,omitempty"`
+}
+
+type PreferencesInput struct {
+	Theme             *Theme      `json:"theme,omitempty"`
+	SortBy            *SortOption `json:"sortBy,omitempty"`
+	SortOrder         *SortOrder  `json:"sortOrder,omitempty"`
+	GridSize          *GridSize   `json:"gridSize,omitempty"`
+	SlideshowInterval *int        `json:"slideshowInterval,omitempty"`
+}
+
+type PreparedDownload struct {
+	Token      string `json:"token"`
+	ExpiresAt  string `json:"expiresAt"`
+	FileCount  int    `json:"fileCount"`
+	TotalBytes int    `json:"totalBytes"`
+	URL        string `json:"url"`
+}
+
 type PresignedUpload struct {
+	Path            string          `json:"path"`
 	UploadURL       string          `json:"uploadURL"`
 	ExpiresAt       string          `json:"expiresAt"`
 	RequiredHeaders []*UploadHeader `json:"requiredHeaders"`
 }
 
+type PresignedUploadPart struct {
+	PartNumber int    `json:"partNumber"`
+	UploadURL  string `json:"uploadURL"`
+}
+
+type ProcessingQueueClass struct {
+	Class         string  `json:"class"`
+	Waiting       int     `json:"waiting"`
+	Running       int     `json:"running"`
+	Started       int     `json:"started"`
+	Cancelled     int     `json:"cancelled"`
+	AverageWaitMs float64 `json:"averageWaitMs"`
+	MaxWaitMs     float64 `json:"maxWaitMs"`
+}
+
+type ProcessingQueueStatus struct {
+	Slots         int                     `json:"slots"`
+	ReservedSlots int                     `json:"reservedSlots"`
+	Classes       []*ProcessingQueueClass `json:"classes"`
+}
+
 type Query struct {
 }
 
+type QueryLimits struct {
+	MaxComplexity  int `json:"maxComplexity"`
+	MaxDepth       int `json:"maxDepth"`
+	TimeoutSeconds int `json:"timeoutSeconds"`
+}
+
 type RegistryEntryInput struct {
 	Key         string `json:"key"`
 	Value       string `json:"value"`
 	IsEncrypted bool   `json:"isEncrypted"`
 }
 
+type S3AccessKey struct {
+	AccessKeyID string   `json:"accessKeyId"`
+	Name        string   `json:"name"`
+	Scopes      []string `json:"scopes"`
+	LastUsedAt  *string  `json:"lastUsedAt,omitempty"`
+	CreatedAt   string   `json:"createdAt"`
+}
+
 type S3StorageConfig struct {
 	Bucket         string  `json:"bucket"`
 	Region         *string `json:"region,omitempty"`
@@ -216,6 +777,24 @@ type S3StorageInput struct {
 	BaseDir         *string `json:"baseDir,omitempty"`
 }
 
+type SFTPStorageConfig struct {
+	Host    string  `json:"host"`
+	Port    int     `json:"port"`
+	User    string  `json:"user"`
+	HostKey *string `json:"hostKey,omitempty"`
+	BaseDir *string `json:"baseDir,omitempty"`
+}
+
+type SFTPStorageInput struct {
+	Host                 string  `json:"host"`
+	Port                 *int    `json:"port,omitempty"`
+	User                 string  `json:"user"`
+	PrivateKey           string  `json:"privateKey"`
+	PrivateKeyPassphrase *string `json:"privateKeyPassphrase,omitempty"`
+	HostKey              *string `json:"hostKey,omitempty"`
+	BaseDir              *string `json:"baseDir,omitempty"`
+}
+
 type SaveTemplateInput struct {
 	Name            string        `json:"name"`
 	Description     *string       `json:"description,omitempty"`
@@ -226,6 +805,46 @@ type SaveTemplateInput struct {
 	Overwrite       *bool         `json:"overwrite,omitempty"`
 }
 
+type ScanStatus struct {
+	Schedule      *string    `json:"schedule,omitempty"`
+	ScheduleValid bool       `json:"scheduleValid"`
+	NextRunAt     *string    `json:"nextRunAt,omitempty"`
+	LastRun       *Operation `json:"lastRun,omitempty"`
+}
+
+type ServerInfo struct {
+	Version            string          `json:"version"`
+	UpdateCheckEnabled bool            `json:"updateCheckEnabled"`
+	Update             *UpdateAdvisory `json:"update,omitempty"`
+}
+
+type Session struct {
+	ID         string `json:"id"`
+	UserID     string `json:"userId"`
+	UserAgent  string `json:"userAgent"`
+	ClientIP   string `json:"clientIp"`
+	CreatedAt  string `json:"createdAt"`
+	LastUsedAt string `json:"lastUsedAt"`
+	ExpiresAt  string `json:"expiresAt"`
+	Current    bool   `json:"current"`
+}
+
+type ShareLink struct {
+	ID                string `json:"id"`
+	Token             string `json:"token"`
+	Path              string `json:"path"`
+	URL               string `json:"url"`
+	AllowDownload     bool   `json:"allowDownload"`
+	PasswordProtected bool   `json:"passwordProtected"`
+	ExpiresAt         string `json:"expiresAt"`
+	CreatedAt         string `json:"createdAt"`
+}
+
+type SimilarImage struct {
+	Path     string `json:"path"`
+	Distance int    `json:"distance"`
+}
+
 type Space struct {
 	ID                    string `json:"id"`
 	OrgID                 string `json:"orgId"`
@@ -310,10 +929,23 @@ type SpaceUsage struct {
 	ProcessingUsageCount *int   `json:"processingUsageCount,omitempty"`
 }
 
+type StorageClassPriceInput struct {
+	StorageClass string  `json:"storageClass"`
+	PerGbMonth   float64 `json:"perGbMonth"`
+}
+
+type StorageClassUsage struct {
+	StorageClass string  `json:"storageClass"`
+	ObjectCount  int     `json:"objectCount"`
+	Bytes        int     `json:"bytes"`
+	StorageCost  float64 `json:"storageCost"`
+}
+
 type StorageConfigInput struct {
 	Type       StorageType       `json:"type"`
 	FileConfig *FileStorageInput `json:"fileConfig,omitempty"`
 	S3Config   *S3StorageInput   `json:"s3Config,omitempty"`
+	SftpConfig *SFTPStorageInput `json:"sftpConfig,omitempty"`
 }
 
 type StorageConfigResult struct {
@@ -322,14 +954,65 @@ type StorageConfigResult struct {
 	Message   *string `json:"message,omitempty"`
 }
 
+type StorageConfigRollback struct {
+	Reason    string  `json:"reason"`
+	Timestamp *string `json:"timestamp,omitempty"`
+}
+
+type StorageCostReport struct {
+	Folders      []*FolderCostEstimate `json:"folders"`
+	TotalObjects int                   `json:"totalObjects"`
+	TotalBytes   int                   `json:"totalBytes"`
+	StorageCost  float64               `json:"storageCost"`
+	EgressCost   float64               `json:"egressCost"`
+	TotalCost    float64               `json:"totalCost"`
+	Truncated    bool                  `json:"truncated"`
+	GeneratedAt  string                `json:"generatedAt"`
+}
+
+type StorageMount struct {
+	Name       string             `json:"name"`
+	Type       string             `json:"type"`
+	FileConfig *FileStorageConfig `json:"fileConfig,omitempty"`
+	S3Config   *S3StorageConfig   `json:"s3Config,omitempty"`
+	SftpConfig *SFTPStorageConfig `json:"sftpConfig,omitempty"`
+}
+
+type StoragePricingInput struct {
+	StoragePerGbMonth  *float64                  `json:"storagePerGbMonth,omitempty"`
+	EgressPerGb        *float64                  `json:"egressPerGb,omitempty"`
+	MonthlyReadRatio   *float64                  `json:"monthlyReadRatio,omitempty"`
+	StorageClassPrices []*StorageClassPriceInput `json:"storageClassPrices,omitempty"`
+}
+
+type StorageQuota struct {
+	Kind       StorageQuotaKind `json:"kind"`
+	Target     string           `json:"target"`
+	LimitBytes int              `json:"limitBytes"`
+	UsedBytes  int              `json:"usedBytes"`
+	UpdatedBy  string           `json:"updatedBy"`
+	UpdatedAt  string           `json:"updatedAt"`
+}
+
+type StorageStats struct {
+	Path       string            `json:"path"`
+	FileCount  int               `json:"fileCount"`
+	TotalSize  int               `json:"totalSize"`
+	Extensions []*ExtensionUsage `json:"extensions"`
+	ScannedAt  string            `json:"scannedAt"`
+}
+
 type StorageStatus struct {
-	Configured              bool               `json:"configured"`
-	SupportsPresignedUpload bool               `json:"supportsPresignedUpload"`
-	Type                    *string            `json:"type,omitempty"`
-	LastUpdated             *string            `json:"lastUpdated,omitempty"`
-	IsOverriddenByConfig    bool               `json:"isOverriddenByConfig"`
-	FileConfig              *FileStorageConfig `json:"fileConfig,omitempty"`
-	S3Config                *S3StorageConfig   `json:"s3Config,omitempty"`
+	Configured              bool                   `json:"configured"`
+	SupportsPresignedUpload bool                   `json:"supportsPresignedUpload"`
+	Type                    *string                `json:"type,omitempty"`
+	LastUpdated             *string                `json:"lastUpdated,omitempty"`
+	IsOverriddenByConfig    bool                   `json:"isOverriddenByConfig"`
+	FileConfig              *FileStorageConfig     `json:"fileConfig,omitempty"`
+	S3Config                *S3StorageConfig       `json:"s3Config,omitempty"`
+	SftpConfig              *SFTPStorageConfig     `json:"sftpConfig,omitempty"`
+	PendingConfig           *PendingStorageConfig  `json:"pendingConfig,omitempty"`
+	LastRollback            *StorageConfigRollback `json:"lastRollback,omitempty"`
 }
 
 type StorageTestResult struct {
@@ -345,6 +1028,9 @@ type StorageUploadProbe struct {
 	ExpiresAt string `json:"expiresAt"`
 }
 
+type Subscription struct {
+}
+
 type SystemRegistry struct {
 	Key                  string `json:"key"`
 	Value                string `json:"value"`
@@ -352,6 +1038,25 @@ type SystemRegistry struct {
 	IsOverriddenByConfig bool   `json:"isOverriddenByConfig"`
 }
 
+type SystemRegistryImport struct {
+	Imported []string                    `json:"imported"`
+	Skipped  []*SystemRegistryImportSkip `json:"skipped"`
+}
+
+type SystemRegistryImportSkip struct {
+	Key    string `json:"key"`
+	Reason string `json:"reason"`
+}
+
+type Tag struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Path      string   `json:"path"`
+	ParentID  *string  `json:"parentID,omitempty"`
+	Aliases   []string `json:"aliases"`
+	FileCount int      `json:"fileCount"`
+}
+
 type TemplateResult struct {
 	Success      bool    `json:"success"`
 	TemplatePath string  `json:"templatePath"`
@@ -359,12 +1064,78 @@ type TemplateResult struct {
 	Message      *string `json:"message,omitempty"`
 }
 
+type Tenant struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	StorageRoot string `json:"storageRoot"`
+	UserCount   int    `json:"userCount"`
+	CreatedAt   string `json:"createdAt"`
+	UpdatedAt   string `json:"updatedAt"`
+}
+
+type ThumbnailPreset struct {
+	Name    string `json:"name"`
+	Width   int    `json:"width"`
+	Height  int    `json:"height"`
+	Fit     string `json:"fit"`
+	Format  string `json:"format"`
+	Quality int    `json:"quality"`
+}
+
+type ThumbnailPresetInput struct {
+	Name    string  `json:"name"`
+	Width   *int    `json:"width,omitempty"`
+	Height  *int    `json:"height,omitempty"`
+	Fit     *string `json:"fit,omitempty"`
+	Format  *string `json:"format,omitempty"`
+	Quality *int    `json:"quality,omitempty"`
+}
+
+type ThumbnailPresetURL struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
 type ThumbnailUrls struct {
-	Grid     *string `json:"grid,omitempty"`
-	Preview  *string `json:"preview,omitempty"`
-	Full     *string `json:"full,omitempty"`
-	Original *string `json:"original,omitempty"`
-	Meta     *string `json:"meta,omitempty"`
+	Grid     *string               `json:"grid,omitempty"`
+	Preview  *string               `json:"preview,omitempty"`
+	Full     *string               `json:"full,omitempty"`
+	Original *string               `json:"original,omitempty"`
+	Meta     *string               `json:"meta,omitempty"`
+	Presets  []*ThumbnailPresetURL `json:"presets"`
+}
+
+type Timeline struct {
+	Buckets    []*TimelineBucket `json:"buckets"`
+	TotalCount int               `json:"totalCount"`
+	Items      []*TimelineItem   `json:"items"`
+}
+
+type TimelineBucket struct {
+	Key    string `json:"key"`
+	Count  int    `json:"count"`
+	Offset int    `json:"offset"`
+}
+
+type TimelineItem struct {
+	Name          string         `json:"name"`
+	Path          string         `json:"path"`
+	CaptureTime   string         `json:"captureTime"`
+	Width         *int           `json:"width,omitempty"`
+	Height        *int           `json:"height,omitempty"`
+	Orientation   *int           `json:"orientation,omitempty"`
+	ThumbnailUrls *ThumbnailUrls `json:"thumbnailUrls,omitempty"`
+}
+
+type UpdateAdvisory struct {
+	CurrentVersion  string         `json:"currentVersion"`
+	LatestVersion   string         `json:"latestVersion"`
+	UpdateAvailable bool           `json:"updateAvailable"`
+	Severity        UpdateSeverity `json:"severity"`
+	ReleaseURL      *string        `json:"releaseURL,omitempty"`
+	PublishedAt     *string        `json:"publishedAt,omitempty"`
+	Notes           []string       `json:"notes"`
+	CheckedAt       string         `json:"checkedAt"`
 }
 
 type UpdateProfileInput struct {
@@ -377,6 +1148,16 @@ type UploadHeader struct {
 	Value string `json:"value"`
 }
 
+type UploadPartInput struct {
+	PartNumber int    `json:"partNumber"`
+	Etag       string `json:"etag"`
+}
+
+type UploadResult struct {
+	Path         string `json:"path"`
+	Deduplicated bool   `json:"deduplicated"`
+}
+
 type UsageSummary struct {
 	UsedSpaces             int           `json:"usedSpaces"`
 	MaxSpaces              *int          `json:"maxSpaces,omitempty"`
@@ -402,12 +1183,15 @@ type User struct {
 	EmailVerified bool            `json:"emailVerified"`
 	HasPassword   bool            `json:"hasPassword"`
 	AvatarURL     *string         `json:"avatarUrl,omitempty"`
+	HomePath      *string         `json:"homePath,omitempty"`
+	TenantID      *string         `json:"tenantId,omitempty"`
 	AuthProviders []*AuthProvider `json:"authProviders"`
 }
 
 type UserList struct {
 	Items      []*User `json:"items"`
 	TotalCount int     `json:"totalCount"`
+	EndCursor  *string `json:"endCursor,omitempty"`
 }
 
 type UserRegistry struct {
@@ -416,48 +1200,102 @@ type UserRegistry struct {
 	IsEncrypted bool   `json:"isEncrypted"`
 }
 
-type DimensionMode string
+type VideoPlayback struct {
+	Mode        VideoPlaybackMode `json:"mode"`
+	SourceCodec string            `json:"sourceCodec"`
+	PlaylistURL *string           `json:"playlistUrl,omitempty"`
+	ExpiresAt   *string           `json:"expiresAt,omitempty"`
+}
+
+type VideoStream struct {
+	URL       string `json:"url"`
+	Cached    bool   `json:"cached"`
+	ExpiresAt string `json:"expiresAt"`
+}
+
+type Webhook struct {
+	ID        string   `json:"id"`
+	URL       string   `json:"url"`
+	Events    []string `json:"events"`
+	Active    bool     `json:"active"`
+	HasSecret bool     `json:"hasSecret"`
+	CreatedBy string   `json:"createdBy"`
+	CreatedAt string   `json:"createdAt"`
+	UpdatedAt string   `json:"updatedAt"`
+}
+
+type WebhookDelivery struct {
+	ID             string                `json:"id"`
+	WebhookID      string                `json:"webhookId"`
+	Event          string                `json:"event"`
+	Payload        string                `json:"payload"`
+	Status         WebhookDeliveryStatus `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	ResponseStatus *int                  `json:"responseStatus,omitempty"`
+	Error          *string               `json:"error,omitempty"`
+	CreatedAt      string                `json:"createdAt"`
+	UpdatedAt      string                `json:"updatedAt"`
+}
+
+type WebhookDeliveryPage struct {
+	Items      []*WebhookDelivery `json:"items"`
+	TotalCount int                `json:"totalCount"`
+	EndCursor  *string            `json:"endCursor,omitempty"`
+}
+
+type WebhookInput struct {
+	URL    string   `json:"url"`
+	Secret *string  `json:"secret,omitempty"`
+	Events []string `json:"events"`
+	Active *bool    `json:"active,omitempty"`
+}
+
+type APIChangeKind string
 
 const (
-	DimensionModeAdaptive   DimensionMode = "ADAPTIVE"
-	DimensionModePredefined DimensionMode = "PREDEFINED"
+	APIChangeKindAdded      APIChangeKind = "ADDED"
+	APIChangeKindChanged    APIChangeKind = "CHANGED"
+	APIChangeKindDeprecated APIChangeKind = "DEPRECATED"
+	APIChangeKindRemoved    APIChangeKind = "REMOVED"
 )
 
-var AllDimensionMode = []DimensionMode{
-	DimensionModeAdaptive,
-	DimensionModePredefined,
+var AllAPIChangeKind = []APIChangeKind{
+	APIChangeKindAdded,
+	APIChangeKindChanged,
+	APIChangeKindDeprecated,
+	APIChangeKindRemoved,
 }
 
-func (e DimensionMode) IsValid() bool {
+func (e APIChangeKind) IsValid() bool {
 	switch e {
-	case DimensionModeAdaptive, DimensionModePredefined:
+	case APIChangeKindAdded, APIChangeKindChanged, APIChangeKindDeprecated, APIChangeKindRemoved:
 		return true
 	}
 	return false
 }
 
-func (e DimensionMode) String() string {
+func (e APIChangeKind) String() string {
 	return string(e)
 }
 
-func (e *DimensionMode) UnmarshalGQL(v any) error {
+func (e *APIChangeKind) UnmarshalGQL(v any) error {
 	str, ok := v.(string)
 	if !ok {
 		return fmt.Errorf("enums must be strings")
 	}
 
-	*e = DimensionMode(str)
+	*e = APIChangeKind(str)
 	if !e.IsValid() {
-		return fmt.Errorf("%s is not a valid DimensionMode", str)
+		return fmt.Errorf("%s is not a valid ApiChangeKind", str)
 	}
 	return nil
 }
 
-func (e DimensionMode) MarshalGQL(w io.Writer) {
+func (e APIChangeKind) MarshalGQL(w io.Writer) {
 	fmt.Fprint(w, strconv.Quote(e.String()))
 }
 
-func (e *DimensionMode) UnmarshalJSON(b []byte) error {
+func (e *APIChangeKind) UnmarshalJSON(b []byte) error {
 	s, err := strconv.Unquote(string(b))
 	if err != nil {
 		return err
@@ -465,56 +1303,58 @@ func (e *DimensionMode) UnmarshalJSON(b []byte) error {
 	return e.UnmarshalGQL(s)
 }
 
-func (e DimensionMode) MarshalJSON() ([]byte, error) {
+func (e APIChangeKind) MarshalJSON() ([]byte, error) {
 	var buf bytes.Buffer
 	e.MarshalGQL(&buf)
 	return buf.Bytes(), nil
 }
 
-type ImagorSignerType string
+type AutoUploadStatus string
 
 const (
-	ImagorSignerTypeSha1   ImagorSignerType = "SHA1"
-	ImagorSignerTypeSha256 ImagorSignerType = "SHA256"
-	ImagorSignerTypeSha512 ImagorSignerType = "SHA512"
+	AutoUploadStatusUploaded     AutoUploadStatus = "UPLOADED"
+	AutoUploadStatusDuplicate    AutoUploadStatus = "DUPLICATE"
+	AutoUploadStatusNeedsContent AutoUploadStatus = "NEEDS_CONTENT"
+	AutoUploadStatusFailed       AutoUploadStatus = "FAILED"
 )
 
-var AllImagorSignerType = []ImagorSignerType{
-	ImagorSignerTypeSha1,
-	ImagorSignerTypeSha256,
-	ImagorSignerTypeSha512,
+var AllAutoUploadStatus = []AutoUploadStatus{
+	AutoUploadStatusUploaded,
+	AutoUploadStatusDuplicate,
+	AutoUploadStatusNeedsContent,
+	AutoUploadStatusFailed,
 }
 
-func (e ImagorSignerType) IsValid() bool {
+func (e AutoUploadStatus) IsValid() bool {
 	switch e {
-	case ImagorSignerTypeSha1, ImagorSignerTypeSha256, ImagorSignerTypeSha512:
+	case AutoUploadStatusUploaded, AutoUploadStatusDuplicate, AutoUploadStatusNeedsContent, AutoUploadStatusFailed:
 		return true
 	}
 	return false
 }
 
-func (e ImagorSignerType) String() string {
+func (e AutoUploadStatus) String() string {
 	return string(e)
 }
 
-func (e *ImagorSignerType) UnmarshalGQL(v any) error {
+func (e *AutoUploadStatus) UnmarshalGQL(v any) error {
 	str, ok := v.(string)
 	if !ok {
 		return fmt.Errorf("enums must be strings")
 	}
 
-	*e = ImagorSignerType(str)
+	*e = AutoUploadStatus(str)
 	if !e.IsValid() {
-		return fmt.Errorf("%s is not a valid ImagorSignerType", str)
+		return fmt.Errorf("%s is not a valid AutoUploadStatus", str)
 	}
 	return nil
 }
 
-func (e ImagorSignerType) MarshalGQL(w io.Writer) {
+func (e AutoUploadStatus) MarshalGQL(w io.Writer) {
 	fmt.Fprint(w, strconv.Quote(e.String()))
 }
 
-func (e *ImagorSignerType) UnmarshalJSON(b []byte) error {
+func (e *AutoUploadStatus) UnmarshalJSON(b []byte) error {
 	s, err := strconv.Unquote(string(b))
 	if err != nil {
 		return err
@@ -522,54 +1362,56 @@ func (e *ImagorSignerType) UnmarshalJSON(b []byte) error {
 	return e.UnmarshalGQL(s)
 }
 
-func (e ImagorSignerType) MarshalJSON() ([]byte, error) {
+func (e AutoUploadStatus) MarshalJSON() ([]byte, error) {
 	var buf bytes.Buffer
 	e.MarshalGQL(&buf)
 	return buf.Bytes(), nil
 }
 
-type OrgMemberAssignableRole string
+type CastMode string
 
 const (
-	OrgMemberAssignableRoleAdmin  OrgMemberAssignableRole = "admin"
-	OrgMemberAssignableRoleMember OrgMemberAssignableRole = "member"
+	CastModeOriginal    CastMode = "ORIGINAL"
+	CastModeImage       CastMode = "IMAGE"
+	CastModeVideoStream CastMode = "VIDEO_STREAM"
 )
 
-var AllOrgMemberAssignableRole = []OrgMemberAssignableRole{
-	OrgMemberAssignableRoleAdmin,
-	OrgMemberAssignableRoleMember,
+var AllCastMode = []CastMode{
+	CastModeOriginal,
+	CastModeImage,
+	CastModeVideoStream,
 }
 
-func (e OrgMemberAssignableRole) IsValid() bool {
+func (e CastMode) IsValid() bool {
 	switch e {
-	case OrgMemberAssignableRoleAdmin, OrgMemberAssignableRoleMember:
+	case CastModeOriginal, CastModeImage, CastModeVideoStream:
 		return true
 	}
 	return false
 }
 
-func (e OrgMemberAssignableRole) String() string {
+func (e CastMode) String() string {
 	return string(e)
 }
 
-func (e *OrgMemberAssignableRole) UnmarshalGQL(v any) error {
+func (e *CastMode) UnmarshalGQL(v any) error {
 	str, ok := v.(string)
 	if !ok {
 		return fmt.Errorf("enums must be strings")
 	}
 
-	*e = OrgMemberAssignableRole(str)
+	*e = CastMode(str)
 	if !e.IsValid() {
-		return fmt.Errorf("%s is not a valid OrgMemberAssignableRole", str)
+		return fmt.Errorf("%s is not a valid CastMode", str)
 	}
 	return nil
 }
 
-func (e OrgMemberAssignableRole) MarshalGQL(w io.Writer) {
+func (e CastMode) MarshalGQL(w io.Writer) {
 	fmt.Fprint(w, strconv.Quote(e.String()))
 }
 
-func (e *OrgMemberAssignableRole) UnmarshalJSON(b []byte) error {
+func (e *CastMode) UnmarshalJSON(b []byte) error {
 	s, err := strconv.Unquote(string(b))
 	if err != nil {
 		return err
@@ -577,13 +1419,418 @@ func (e *OrgMemberAssignableRole) UnmarshalJSON(b []byte) error {
 	return e.UnmarshalGQL(s)
 }
 
-func (e OrgMemberAssignableRole) MarshalJSON() ([]byte, error) {
+func (e CastMode) MarshalJSON() ([]byte, error) {
 	var buf bytes.Buffer
 	e.MarshalGQL(&buf)
 	return buf.Bytes(), nil
 }
 
-type OrgMemberRole string
+type ConfigSource string
+
+const (
+	ConfigSourceCli      ConfigSource = "CLI"
+	ConfigSourceEnv      ConfigSource = "ENV"
+	ConfigSourceFile     ConfigSource = "FILE"
+	ConfigSourceRegistry ConfigSource = "REGISTRY"
+	ConfigSourceDefault  ConfigSource = "DEFAULT"
+)
+
+var AllConfigSource = []ConfigSource{
+	ConfigSourceCli,
+	ConfigSourceEnv,
+	ConfigSourceFile,
+	ConfigSourceRegistry,
+	ConfigSourceDefault,
+}
+
+func (e ConfigSource) IsValid() bool {
+	switch e {
+	case ConfigSourceCli, ConfigSourceEnv, ConfigSourceFile, ConfigSourceRegistry, ConfigSourceDefault:
+		return true
+	}
+	return false
+}
+
+func (e ConfigSource) String() string {
+	return string(e)
+}
+
+func (e *ConfigSource) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ConfigSource(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ConfigSource", str)
+	}
+	return nil
+}
+
+func (e ConfigSource) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *ConfigSource) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e ConfigSource) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type DimensionMode string
+
+const (
+	DimensionModeAdaptive   DimensionMode = "ADAPTIVE"
+	DimensionModePredefined DimensionMode = "PREDEFINED"
+)
+
+var AllDimensionMode = []DimensionMode{
+	DimensionModeAdaptive,
+	DimensionModePredefined,
+}
+
+func (e DimensionMode) IsValid() bool {
+	switch e {
+	case DimensionModeAdaptive, DimensionModePredefined:
+		return true
+	}
+	return false
+}
+
+func (e DimensionMode) String() string {
+	return string(e)
+}
+
+func (e *DimensionMode) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = DimensionMode(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid DimensionMode", str)
+	}
+	return nil
+}
+
+func (e DimensionMode) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *DimensionMode) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e DimensionMode) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type FileChangeKind string
+
+const (
+	FileChangeKindCreated FileChangeKind = "CREATED"
+	FileChangeKindUpdated FileChangeKind = "UPDATED"
+	FileChangeKindDeleted FileChangeKind = "DELETED"
+	FileChangeKindMoved   FileChangeKind = "MOVED"
+)
+
+var AllFileChangeKind = []FileChangeKind{
+	FileChangeKindCreated,
+	FileChangeKindUpdated,
+	FileChangeKindDeleted,
+	FileChangeKindMoved,
+}
+
+func (e FileChangeKind) IsValid() bool {
+	switch e {
+	case FileChangeKindCreated, FileChangeKindUpdated, FileChangeKindDeleted, FileChangeKindMoved:
+		return true
+	}
+	return false
+}
+
+func (e FileChangeKind) String() string {
+	return string(e)
+}
+
+func (e *FileChangeKind) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = FileChangeKind(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid FileChangeKind", str)
+	}
+	return nil
+}
+
+func (e FileChangeKind) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *FileChangeKind) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e FileChangeKind) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type GridSize string
+
+const (
+	GridSizeSmall  GridSize = "SMALL"
+	GridSizeMedium GridSize = "MEDIUM"
+	GridSizeLarge  GridSize = "LARGE"
+)
+
+var AllGridSize = []GridSize{
+	GridSizeSmall,
+	GridSizeMedium,
+	GridSizeLarge,
+}
+
+func (e GridSize) IsValid() bool {
+	switch e {
+	case GridSizeSmall, GridSizeMedium, GridSizeLarge:
+		return true
+	}
+	return false
+}
+
+func (e GridSize) String() string {
+	return string(e)
+}
+
+func (e *GridSize) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = GridSize(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid GridSize", str)
+	}
+	return nil
+}
+
+func (e GridSize) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *GridSize) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e GridSize) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type ImagorSignerType string
+
+const (
+	ImagorSignerTypeSha1   ImagorSignerType = "SHA1"
+	ImagorSignerTypeSha256 ImagorSignerType = "SHA256"
+	ImagorSignerTypeSha512 ImagorSignerType = "SHA512"
+)
+
+var AllImagorSignerType = []ImagorSignerType{
+	ImagorSignerTypeSha1,
+	ImagorSignerTypeSha256,
+	ImagorSignerTypeSha512,
+}
+
+func (e ImagorSignerType) IsValid() bool {
+	switch e {
+	case ImagorSignerTypeSha1, ImagorSignerTypeSha256, ImagorSignerTypeSha512:
+		return true
+	}
+	return false
+}
+
+func (e ImagorSignerType) String() string {
+	return string(e)
+}
+
+func (e *ImagorSignerType) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ImagorSignerType(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ImagorSignerType", str)
+	}
+	return nil
+}
+
+func (e ImagorSignerType) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *ImagorSignerType) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e ImagorSignerType) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type OperationStatus string
+
+const (
+	OperationStatusQueued    OperationStatus = "QUEUED"
+	OperationStatusRunning   OperationStatus = "RUNNING"
+	OperationStatusSucceeded OperationStatus = "SUCCEEDED"
+	OperationStatusFailed    OperationStatus = "FAILED"
+	OperationStatusCancelled OperationStatus = "CANCELLED"
+)
+
+var AllOperationStatus = []OperationStatus{
+	OperationStatusQueued,
+	OperationStatusRunning,
+	OperationStatusSucceeded,
+	OperationStatusFailed,
+	OperationStatusCancelled,
+}
+
+func (e OperationStatus) IsValid() bool {
+	switch e {
+	case OperationStatusQueued, OperationStatusRunning, OperationStatusSucceeded, OperationStatusFailed, OperationStatusCancelled:
+		return true
+	}
+	return false
+}
+
+func (e OperationStatus) String() string {
+	return string(e)
+}
+
+func (e *OperationStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = OperationStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid OperationStatus", str)
+	}
+	return nil
+}
+
+func (e OperationStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *OperationStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e OperationStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type OrgMemberAssignableRole string
+
+const (
+	OrgMemberAssignableRoleAdmin  OrgMemberAssignableRole = "admin"
+	OrgMemberAssignableRoleMember OrgMemberAssignableRole = "member"
+)
+
+var AllOrgMemberAssignableRole = []OrgMemberAssignableRole{
+	OrgMemberAssignableRoleAdmin,
+	OrgMemberAssignableRoleMember,
+}
+
+func (e OrgMemberAssignableRole) IsValid() bool {
+	switch e {
+	case OrgMemberAssignableRoleAdmin, OrgMemberAssignableRoleMember:
+		return true
+	}
+	return false
+}
+
+func (e OrgMemberAssignableRole) String() string {
+	return string(e)
+}
+
+func (e *OrgMemberAssignableRole) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = OrgMemberAssignableRole(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid OrgMemberAssignableRole", str)
+	}
+	return nil
+}
+
+func (e OrgMemberAssignableRole) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *OrgMemberAssignableRole) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e OrgMemberAssignableRole) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type OrgMemberRole string
 
 const (
 	OrgMemberRoleOwner  OrgMemberRole = "owner"
@@ -591,42 +1838,325 @@ const (
 	OrgMemberRoleMember OrgMemberRole = "member"
 )
 
-var AllOrgMemberRole = []OrgMemberRole{
-	OrgMemberRoleOwner,
-	OrgMemberRoleAdmin,
-	OrgMemberRoleMember,
+var AllOrgMemberRole = []OrgMemberRole{
+	OrgMemberRoleOwner,
+	OrgMemberRoleAdmin,
+	OrgMemberRoleMember,
+}
+
+func (e OrgMemberRole) IsValid() bool {
+	switch e {
+	case OrgMemberRoleOwner, OrgMemberRoleAdmin, OrgMemberRoleMember:
+		return true
+	}
+	return false
+}
+
+func (e OrgMemberRole) String() string {
+	return string(e)
+}
+
+func (e *OrgMemberRole) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = OrgMemberRole(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid OrgMemberRole", str)
+	}
+	return nil
+}
+
+func (e OrgMemberRole) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *OrgMemberRole) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e OrgMemberRole) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type SortOption string
+
+const (
+	SortOptionName         SortOption = "NAME"
+	SortOptionSize         SortOption = "SIZE"
+	SortOptionModifiedTime SortOption = "MODIFIED_TIME"
+	SortOptionCaptureDate  SortOption = "CAPTURE_DATE"
+	SortOptionRating       SortOption = "RATING"
+)
+
+var AllSortOption = []SortOption{
+	SortOptionName,
+	SortOptionSize,
+	SortOptionModifiedTime,
+	SortOptionCaptureDate,
+	SortOptionRating,
+}
+
+func (e SortOption) IsValid() bool {
+	switch e {
+	case SortOptionName, SortOptionSize, SortOptionModifiedTime, SortOptionCaptureDate, SortOptionRating:
+		return true
+	}
+	return false
+}
+
+func (e SortOption) String() string {
+	return string(e)
+}
+
+func (e *SortOption) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = SortOption(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid SortOption", str)
+	}
+	return nil
+}
+
+func (e SortOption) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *SortOption) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e SortOption) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type SortOrder string
+
+const (
+	SortOrderAsc  SortOrder = "ASC"
+	SortOrderDesc SortOrder = "DESC"
+)
+
+var AllSortOrder = []SortOrder{
+	SortOrderAsc,
+	SortOrderDesc,
+}
+
+func (e SortOrder) IsValid() bool {
+	switch e {
+	case SortOrderAsc, SortOrderDesc:
+		return true
+	}
+	return false
+}
+
+func (e SortOrder) String() string {
+	return string(e)
+}
+
+func (e *SortOrder) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = SortOrder(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid SortOrder", str)
+	}
+	return nil
+}
+
+func (e SortOrder) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *SortOrder) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e SortOrder) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type SpaceMemberAssignableRole string
+
+const (
+	SpaceMemberAssignableRoleAdmin  SpaceMemberAssignableRole = "admin"
+	SpaceMemberAssignableRoleMember SpaceMemberAssignableRole = "member"
+)
+
+var AllSpaceMemberAssignableRole = []SpaceMemberAssignableRole{
+	SpaceMemberAssignableRoleAdmin,
+	SpaceMemberAssignableRoleMember,
+}
+
+func (e SpaceMemberAssignableRole) IsValid() bool {
+	switch e {
+	case SpaceMemberAssignableRoleAdmin, SpaceMemberAssignableRoleMember:
+		return true
+	}
+	return false
+}
+
+func (e SpaceMemberAssignableRole) String() string {
+	return string(e)
+}
+
+func (e *SpaceMemberAssignableRole) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = SpaceMemberAssignableRole(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid SpaceMemberAssignableRole", str)
+	}
+	return nil
+}
+
+func (e SpaceMemberAssignableRole) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *SpaceMemberAssignableRole) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e SpaceMemberAssignableRole) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type SpaceMemberRole string
+
+const (
+	SpaceMemberRoleOwner  SpaceMemberRole = "owner"
+	SpaceMemberRoleAdmin  SpaceMemberRole = "admin"
+	SpaceMemberRoleMember SpaceMemberRole = "member"
+)
+
+var AllSpaceMemberRole = []SpaceMemberRole{
+	SpaceMemberRoleOwner,
+	SpaceMemberRoleAdmin,
+	SpaceMemberRoleMember,
+}
+
+func (e SpaceMemberRole) IsValid() bool {
+	switch e {
+	case SpaceMemberRoleOwner, SpaceMemberRoleAdmin, SpaceMemberRoleMember:
+		return true
+	}
+	return false
+}
+
+func (e SpaceMemberRole) String() string {
+	return string(e)
+}
+
+func (e *SpaceMemberRole) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = SpaceMemberRole(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid SpaceMemberRole", str)
+	}
+	return nil
+}
+
+func (e SpaceMemberRole) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *SpaceMemberRole) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e SpaceMemberRole) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type SpaceMemberRoleSource string
+
+const (
+	SpaceMemberRoleSourceOrganization SpaceMemberRoleSource = "organization"
+	SpaceMemberRoleSourceSpace        SpaceMemberRoleSource = "space"
+)
+
+var AllSpaceMemberRoleSource = []SpaceMemberRoleSource{
+	SpaceMemberRoleSourceOrganization,
+	SpaceMemberRoleSourceSpace,
 }
 
-func (e OrgMemberRole) IsValid() bool {
+func (e SpaceMemberRoleSource) IsValid() bool {
 	switch e {
-	case OrgMemberRoleOwner, OrgMemberRoleAdmin, OrgMemberRoleMember:
+	case SpaceMemberRoleSourceOrganization, SpaceMemberRoleSourceSpace:
 		return true
 	}
 	return false
 }
 
-func (e OrgMemberRole) String() string {
+func (e SpaceMemberRoleSource) String() string {
 	return string(e)
 }
 
-func (e *OrgMemberRole) UnmarshalGQL(v any) error {
+func (e *SpaceMemberRoleSource) UnmarshalGQL(v any) error {
 	str, ok := v.(string)
 	if !ok {
 		return fmt.Errorf("enums must be strings")
 	}
 
-	*e = OrgMemberRole(str)
+	*e = SpaceMemberRoleSource(str)
 	if !e.IsValid() {
-		return fmt.Errorf("%s is not a valid OrgMemberRole", str)
+		return fmt.Errorf("%s is not a valid SpaceMemberRoleSource", str)
 	}
 	return nil
 }
 
-func (e OrgMemberRole) MarshalGQL(w io.Writer) {
+func (e SpaceMemberRoleSource) MarshalGQL(w io.Writer) {
 	fmt.Fprint(w, strconv.Quote(e.String()))
 }
 
-func (e *OrgMemberRole) UnmarshalJSON(b []byte) error {
+func (e *SpaceMemberRoleSource) UnmarshalJSON(b []byte) error {
 	s, err := strconv.Unquote(string(b))
 	if err != nil {
 		return err
@@ -634,56 +2164,54 @@ func (e *OrgMemberRole) UnmarshalJSON(b []byte) error {
 	return e.UnmarshalGQL(s)
 }
 
-func (e OrgMemberRole) MarshalJSON() ([]byte, error) {
+func (e SpaceMemberRoleSource) MarshalJSON() ([]byte, error) {
 	var buf bytes.Buffer
 	e.MarshalGQL(&buf)
 	return buf.Bytes(), nil
 }
 
-type SortOption string
+type StorageQuotaKind string
 
 const (
-	SortOptionName         SortOption = "NAME"
-	SortOptionSize         SortOption = "SIZE"
-	SortOptionModifiedTime SortOption = "MODIFIED_TIME"
+	StorageQuotaKindUser StorageQuotaKind = "USER"
+	StorageQuotaKindPath StorageQuotaKind = "PATH"
 )
 
-var AllSortOption = []SortOption{
-	SortOptionName,
-	SortOptionSize,
-	SortOptionModifiedTime,
+var AllStorageQuotaKind = []StorageQuotaKind{
+	StorageQuotaKindUser,
+	StorageQuotaKindPath,
 }
 
-func (e SortOption) IsValid() bool {
+func (e StorageQuotaKind) IsValid() bool {
 	switch e {
-	case SortOptionName, SortOptionSize, SortOptionModifiedTime:
+	case StorageQuotaKindUser, StorageQuotaKindPath:
 		return true
 	}
 	return false
 }
 
-func (e SortOption) String() string {
+func (e StorageQuotaKind) String() string {
 	return string(e)
 }
 
-func (e *SortOption) UnmarshalGQL(v any) error {
+func (e *StorageQuotaKind) UnmarshalGQL(v any) error {
 	str, ok := v.(string)
 	if !ok {
 		return fmt.Errorf("enums must be strings")
 	}
 
-	*e = SortOption(str)
+	*e = StorageQuotaKind(str)
 	if !e.IsValid() {
-		return fmt.Errorf("%s is not a valid SortOption", str)
+		return fmt.Errorf("%s is not a valid StorageQuotaKind", str)
 	}
 	return nil
 }
 
-func (e SortOption) MarshalGQL(w io.Writer) {
+func (e StorageQuotaKind) MarshalGQL(w io.Writer) {
 	fmt.Fprint(w, strconv.Quote(e.String()))
 }
 
-func (e *SortOption) UnmarshalJSON(b []byte) error {
+func (e *StorageQuotaKind) UnmarshalJSON(b []byte) error {
 	s, err := strconv.Unquote(string(b))
 	if err != nil {
 		return err
@@ -691,54 +2219,56 @@ func (e *SortOption) UnmarshalJSON(b []byte) error {
 	return e.UnmarshalGQL(s)
 }
 
-func (e SortOption) MarshalJSON() ([]byte, error) {
+func (e StorageQuotaKind) MarshalJSON() ([]byte, error) {
 	var buf bytes.Buffer
 	e.MarshalGQL(&buf)
 	return buf.Bytes(), nil
 }
 
-type SortOrder string
+type StorageType string
 
 const (
-	SortOrderAsc  SortOrder = "ASC"
-	SortOrderDesc SortOrder = "DESC"
+	StorageTypeFile StorageType = "FILE"
+	StorageTypeS3   StorageType = "S3"
+	StorageTypeSftp StorageType = "SFTP"
 )
 
-var AllSortOrder = []SortOrder{
-	SortOrderAsc,
-	SortOrderDesc,
+var AllStorageType = []StorageType{
+	StorageTypeFile,
+	StorageTypeS3,
+	StorageTypeSftp,
 }
 
-func (e SortOrder) IsValid() bool {
+func (e StorageType) IsValid() bool {
 	switch e {
-	case SortOrderAsc, SortOrderDesc:
+	case StorageTypeFile, StorageTypeS3, StorageTypeSftp:
 		return true
 	}
 	return false
 }
 
-func (e SortOrder) String() string {
+func (e StorageType) String() string {
 	return string(e)
 }
 
-func (e *SortOrder) UnmarshalGQL(v any) error {
+func (e *StorageType) UnmarshalGQL(v any) error {
 	str, ok := v.(string)
 	if !ok {
 		return fmt.Errorf("enums must be strings")
 	}
 
-	*e = SortOrder(str)
+	*e = StorageType(str)
 	if !e.IsValid() {
-		return fmt.Errorf("%s is not a valid SortOrder", str)
+		return fmt.Errorf("%s is not a valid StorageType", str)
 	}
 	return nil
 }
 
-func (e SortOrder) MarshalGQL(w io.Writer) {
+func (e StorageType) MarshalGQL(w io.Writer) {
 	fmt.Fprint(w, strconv.Quote(e.String()))
 }
 
-func (e *SortOrder) UnmarshalJSON(b []byte) error {
+func (e *StorageType) UnmarshalJSON(b []byte) error {
 	s, err := strconv.Unquote(string(b))
 	if err != nil {
 		return err
@@ -746,54 +2276,56 @@ func (e *SortOrder) UnmarshalJSON(b []byte) error {
 	return e.UnmarshalGQL(s)
 }
 
-func (e SortOrder) MarshalJSON() ([]byte, error) {
+func (e StorageType) MarshalJSON() ([]byte, error) {
 	var buf bytes.Buffer
 	e.MarshalGQL(&buf)
 	return buf.Bytes(), nil
 }
 
-type SpaceMemberAssignableRole string
+type Theme string
 
 const (
-	SpaceMemberAssignableRoleAdmin  SpaceMemberAssignableRole = "admin"
-	SpaceMemberAssignableRoleMember SpaceMemberAssignableRole = "member"
+	ThemeLight  Theme = "LIGHT"
+	ThemeDark   Theme = "DARK"
+	ThemeSystem Theme = "SYSTEM"
 )
 
-var AllSpaceMemberAssignableRole = []SpaceMemberAssignableRole{
-	SpaceMemberAssignableRoleAdmin,
-	SpaceMemberAssignableRoleMember,
+var AllTheme = []Theme{
+	ThemeLight,
+	ThemeDark,
+	ThemeSystem,
 }
 
-func (e SpaceMemberAssignableRole) IsValid() bool {
+func (e Theme) IsValid() bool {
 	switch e {
-	case SpaceMemberAssignableRoleAdmin, SpaceMemberAssignableRoleMember:
+	case ThemeLight, ThemeDark, ThemeSystem:
 		return true
 	}
 	return false
 }
 
-func (e SpaceMemberAssignableRole) String() string {
+func (e Theme) String() string {
 	return string(e)
 }
 
-func (e *SpaceMemberAssignableRole) UnmarshalGQL(v any) error {
+func (e *Theme) UnmarshalGQL(v any) error {
 	str, ok := v.(string)
 	if !ok {
 		return fmt.Errorf("enums must be strings")
 	}
 
-	*e = SpaceMemberAssignableRole(str)
+	*e = Theme(str)
 	if !e.IsValid() {
-		return fmt.Errorf("%s is not a valid SpaceMemberAssignableRole", str)
+		return fmt.Errorf("%s is not a valid Theme", str)
 	}
 	return nil
 }
 
-func (e SpaceMemberAssignableRole) MarshalGQL(w io.Writer) {
+func (e Theme) MarshalGQL(w io.Writer) {
 	fmt.Fprint(w, strconv.Quote(e.String()))
 }
 
-func (e *SpaceMemberAssignableRole) UnmarshalJSON(b []byte) error {
+func (e *Theme) UnmarshalJSON(b []byte) error {
 	s, err := strconv.Unquote(string(b))
 	if err != nil {
 		return err
@@ -801,56 +2333,56 @@ func (e *SpaceMemberAssignableRole) UnmarshalJSON(b []byte) error {
 	return e.UnmarshalGQL(s)
 }
 
-func (e SpaceMemberAssignableRole) MarshalJSON() ([]byte, error) {
+func (e Theme) MarshalJSON() ([]byte, error) {
 	var buf bytes.Buffer
 	e.MarshalGQL(&buf)
 	return buf.Bytes(), nil
 }
 
-type SpaceMemberRole string
+type TimelineGranularity string
 
 const (
-	SpaceMemberRoleOwner  SpaceMemberRole = "owner"
-	SpaceMemberRoleAdmin  SpaceMemberRole = "admin"
-	SpaceMemberRoleMember SpaceMemberRole = "member"
+	TimelineGranularityDay   TimelineGranularity = "DAY"
+	TimelineGranularityMonth TimelineGranularity = "MONTH"
+	TimelineGranularityYear  TimelineGranularity = "YEAR"
 )
 
-var AllSpaceMemberRole = []SpaceMemberRole{
-	SpaceMemberRoleOwner,
-	SpaceMemberRoleAdmin,
-	SpaceMemberRoleMember,
+var AllTimelineGranularity = []TimelineGranularity{
+	TimelineGranularityDay,
+	TimelineGranularityMonth,
+	TimelineGranularityYear,
 }
 
-func (e SpaceMemberRole) IsValid() bool {
+func (e TimelineGranularity) IsValid() bool {
 	switch e {
-	case SpaceMemberRoleOwner, SpaceMemberRoleAdmin, SpaceMemberRoleMember:
+	case TimelineGranularityDay, TimelineGranularityMonth, TimelineGranularityYear:
 		return true
 	}
 	return false
 }
 
-func (e SpaceMemberRole) String() string {
+func (e TimelineGranularity) String() string {
 	return string(e)
 }
 
-func (e *SpaceMemberRole) UnmarshalGQL(v any) error {
+func (e *TimelineGranularity) UnmarshalGQL(v any) error {
 	str, ok := v.(string)
 	if !ok {
 		return fmt.Errorf("enums must be strings")
 	}
 
-	*e = SpaceMemberRole(str)
+	*e = TimelineGranularity(str)
 	if !e.IsValid() {
-		return fmt.Errorf("%s is not a valid SpaceMemberRole", str)
+		return fmt.Errorf("%s is not a valid TimelineGranularity", str)
 	}
 	return nil
 }
 
-func (e SpaceMemberRole) MarshalGQL(w io.Writer) {
+func (e TimelineGranularity) MarshalGQL(w io.Writer) {
 	fmt.Fprint(w, strconv.Quote(e.String()))
 }
 
-func (e *SpaceMemberRole) UnmarshalJSON(b []byte) error {
+func (e *TimelineGranularity) UnmarshalJSON(b []byte) error {
 	s, err := strconv.Unquote(string(b))
 	if err != nil {
 		return err
@@ -858,54 +2390,56 @@ func (e *SpaceMemberRole) UnmarshalJSON(b []byte) error {
 	return e.UnmarshalGQL(s)
 }
 
-func (e SpaceMemberRole) MarshalJSON() ([]byte, error) {
+func (e TimelineGranularity) MarshalJSON() ([]byte, error) {
 	var buf bytes.Buffer
 	e.MarshalGQL(&buf)
 	return buf.Bytes(), nil
 }
 
-type SpaceMemberRoleSource string
+type UpdateSeverity string
 
 const (
-	SpaceMemberRoleSourceOrganization SpaceMemberRoleSource = "organization"
-	SpaceMemberRoleSourceSpace        SpaceMemberRoleSource = "space"
+	UpdateSeverityNone     UpdateSeverity = "NONE"
+	UpdateSeverityFeature  UpdateSeverity = "FEATURE"
+	UpdateSeveritySecurity UpdateSeverity = "SECURITY"
 )
 
-var AllSpaceMemberRoleSource = []SpaceMemberRoleSource{
-	SpaceMemberRoleSourceOrganization,
-	SpaceMemberRoleSourceSpace,
+var AllUpdateSeverity = []UpdateSeverity{
+	UpdateSeverityNone,
+	UpdateSeverityFeature,
+	UpdateSeveritySecurity,
 }
 
-func (e SpaceMemberRoleSource) IsValid() bool {
+func (e UpdateSeverity) IsValid() bool {
 	switch e {
-	case SpaceMemberRoleSourceOrganization, SpaceMemberRoleSourceSpace:
+	case UpdateSeverityNone, UpdateSeverityFeature, UpdateSeveritySecurity:
 		return true
 	}
 	return false
 }
 
-func (e SpaceMemberRoleSource) String() string {
+func (e UpdateSeverity) String() string {
 	return string(e)
 }
 
-func (e *SpaceMemberRoleSource) UnmarshalGQL(v any) error {
+func (e *UpdateSeverity) UnmarshalGQL(v any) error {
 	str, ok := v.(string)
 	if !ok {
 		return fmt.Errorf("enums must be strings")
 	}
 
-	*e = SpaceMemberRoleSource(str)
+	*e = UpdateSeverity(str)
 	if !e.IsValid() {
-		return fmt.Errorf("%s is not a valid SpaceMemberRoleSource", str)
+		return fmt.Errorf("%s is not a valid UpdateSeverity", str)
 	}
 	return nil
 }
 
-func (e SpaceMemberRoleSource) MarshalGQL(w io.Writer) {
+func (e UpdateSeverity) MarshalGQL(w io.Writer) {
 	fmt.Fprint(w, strconv.Quote(e.String()))
 }
 
-func (e *SpaceMemberRoleSource) UnmarshalJSON(b []byte) error {
+func (e *UpdateSeverity) UnmarshalJSON(b []byte) error {
 	s, err := strconv.Unquote(string(b))
 	if err != nil {
 		return err
@@ -913,54 +2447,56 @@ func (e *SpaceMemberRoleSource) UnmarshalJSON(b []byte) error {
 	return e.UnmarshalGQL(s)
 }
 
-func (e SpaceMemberRoleSource) MarshalJSON() ([]byte, error) {
+func (e UpdateSeverity) MarshalJSON() ([]byte, error) {
 	var buf bytes.Buffer
 	e.MarshalGQL(&buf)
 	return buf.Bytes(), nil
 }
 
-type StorageType string
+type UploadCollisionPolicy string
 
 const (
-	StorageTypeFile StorageType = "FILE"
-	StorageTypeS3   StorageType = "S3"
+	UploadCollisionPolicyOverwrite UploadCollisionPolicy = "OVERWRITE"
+	UploadCollisionPolicyRename    UploadCollisionPolicy = "RENAME"
+	UploadCollisionPolicyReject    UploadCollisionPolicy = "REJECT"
 )
 
-var AllStorageType = []StorageType{
-	StorageTypeFile,
-	StorageTypeS3,
+var AllUploadCollisionPolicy = []UploadCollisionPolicy{
+	UploadCollisionPolicyOverwrite,
+	UploadCollisionPolicyRename,
+	UploadCollisionPolicyReject,
 }
 
-func (e StorageType) IsValid() bool {
+func (e UploadCollisionPolicy) IsValid() bool {
 	switch e {
-	case StorageTypeFile, StorageTypeS3:
+	case UploadCollisionPolicyOverwrite, UploadCollisionPolicyRename, UploadCollisionPolicyReject:
 		return true
 	}
 	return false
 }
 
-func (e StorageType) String() string {
+func (e UploadCollisionPolicy) String() string {
 	return string(e)
 }
 
-func (e *StorageType) UnmarshalGQL(v any) error {
+func (e *UploadCollisionPolicy) UnmarshalGQL(v any) error {
 	str, ok := v.(string)
 	if !ok {
 		return fmt.Errorf("enums must be strings")
 	}
 
-	*e = StorageType(str)
+	*e = UploadCollisionPolicy(str)
 	if !e.IsValid() {
-		return fmt.Errorf("%s is not a valid StorageType", str)
+		return fmt.Errorf("%s is not a valid UploadCollisionPolicy", str)
 	}
 	return nil
 }
 
-func (e StorageType) MarshalGQL(w io.Writer) {
+func (e UploadCollisionPolicy) MarshalGQL(w io.Writer) {
 	fmt.Fprint(w, strconv.Quote(e.String()))
 }
 
-func (e *StorageType) UnmarshalJSON(b []byte) error {
+func (e *UploadCollisionPolicy) UnmarshalJSON(b []byte) error {
 	s, err := strconv.Unquote(string(b))
 	if err != nil {
 		return err
@@ -968,7 +2504,119 @@ func (e *StorageType) UnmarshalJSON(b []byte) error {
 	return e.UnmarshalGQL(s)
 }
 
-func (e StorageType) MarshalJSON() ([]byte, error) {
+func (e UploadCollisionPolicy) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type VideoPlaybackMode string
+
+const (
+	VideoPlaybackModeDirect VideoPlaybackMode = "DIRECT"
+	VideoPlaybackModeHls    VideoPlaybackMode = "HLS"
+)
+
+var AllVideoPlaybackMode = []VideoPlaybackMode{
+	VideoPlaybackModeDirect,
+	VideoPlaybackModeHls,
+}
+
+func (e VideoPlaybackMode) IsValid() bool {
+	switch e {
+	case VideoPlaybackModeDirect, VideoPlaybackModeHls:
+		return true
+	}
+	return false
+}
+
+func (e VideoPlaybackMode) String() string {
+	return string(e)
+}
+
+func (e *VideoPlaybackMode) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = VideoPlaybackMode(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid VideoPlaybackMode", str)
+	}
+	return nil
+}
+
+func (e VideoPlaybackMode) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *VideoPlaybackMode) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e VideoPlaybackMode) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryStatusPending   WebhookDeliveryStatus = "PENDING"
+	WebhookDeliveryStatusSucceeded WebhookDeliveryStatus = "SUCCEEDED"
+	WebhookDeliveryStatusFailed    WebhookDeliveryStatus = "FAILED"
+)
+
+var AllWebhookDeliveryStatus = []WebhookDeliveryStatus{
+	WebhookDeliveryStatusPending,
+	WebhookDeliveryStatusSucceeded,
+	WebhookDeliveryStatusFailed,
+}
+
+func (e WebhookDeliveryStatus) IsValid() bool {
+	switch e {
+	case WebhookDeliveryStatusPending, WebhookDeliveryStatusSucceeded, WebhookDeliveryStatusFailed:
+		return true
+	}
+	return false
+}
+
+func (e WebhookDeliveryStatus) String() string {
+	return string(e)
+}
+
+func (e *WebhookDeliveryStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = WebhookDeliveryStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid WebhookDeliveryStatus", str)
+	}
+	return nil
+}
+
+func (e WebhookDeliveryStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *WebhookDeliveryStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e WebhookDeliveryStatus) MarshalJSON() ([]byte, error) {
 	var buf bytes.Buffer
 	e.MarshalGQL(&buf)
 	return buf.Bytes(), nil
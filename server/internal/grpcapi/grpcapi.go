@@ -0,0 +1,393 @@
+// Package grpcapi serves the imagorstudio.v1.StorageService gRPC service,
+// defined in proto/imagorstudio/v1/storage.proto, for programs moving many
+// files at once, such as an importer syncing from a NAS.
+//
+// Calls are answered by the GraphQL resolvers, so they go through the same
+// permission checks, home path, upload routing and quotas as the GraphQL
+// API. Each method also stands for the GraphQL root fields it answers with,
+// so the operation allow-list of the session role applies to it and uploads
+// are recorded in the audit log, see WithAllowList and WithAuditLog. The
+// fields and arguments involved are not deprecated, so strict API mode has
+// nothing to refuse. The handler speaks the gRPC wire protocol over HTTP/2
+// itself; the server serving it authenticates the calls.
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/cshum/imagor-studio/server/internal/allowlist"
+	"github.com/cshum/imagor-studio/server/internal/auditlog"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/generated/pb"
+	"go.uber.org/zap"
+)
+
+const (
+	// servicePath prefixes the paths of the service methods
+	servicePath = "/imagorstudio.v1.StorageService/"
+	// listPageSize is the number of entries listed per listFiles call
+	listPageSize = 1000
+	// maxBatchPaths bounds the paths of a BatchGetFiles call
+	maxBatchPaths = 1000
+	// batchWorkers is the number of paths of a BatchGetFiles call looked up
+	// concurrently
+	batchWorkers = 8
+)
+
+// Root fields the methods stand for
+const (
+	listFilesField    = "Query.listFiles"
+	statFileField     = "Query.statFile"
+	fileMetadataField = "Query.fileMetadata"
+	uploadField       = "Mutation.uploadFileWithResult"
+)
+
+// Queries is the part of the GraphQL query resolver the service answers with
+type Queries interface {
+	ListFiles(ctx context.Context, path string, spaceID *string, offset *int, limit *int, onlyFiles *bool, onlyFolders *bool, extensions *string, showHidden *bool, sortBy *gql.SortOption, sortOrder *gql.SortOrder, systemTags []string, excludeSystemTags []string, tags []string, minRating *int, after *string, skipMetadata *bool) (*gql.FileList, error)
+	StatFile(ctx context.Context, path string, spaceID *string) (*gql.FileStat, error)
+	FileMetadata(ctx context.Context, path string, spaceID *string) (*gql.FileMetadata, error)
+}
+
+// Mutations is the part of the GraphQL mutation resolver the service
+// answers with
+type Mutations interface {
+	UploadFileWithResult(ctx context.Context, path string, spaceID *string, content graphql.Upload, onCollision *gql.UploadCollisionPolicy) (*gql.UploadResult, error)
+}
+
+// Handler serves the gRPC calls of the storage service
+type Handler struct {
+	queries   Queries
+	mutations Mutations
+	allowList *allowlist.Store
+	auditLog  auditlog.Store
+	logger    *zap.Logger
+}
+
+// Option configures a Handler
+type Option func(*Handler)
+
+// WithAllowList refuses the calls of methods standing for root fields
+// outside the allow-list of the session role
+func WithAllowList(store *allowlist.Store) Option {
+	return func(h *Handler) {
+		h.allowList = store
+	}
+}
+
+// WithAuditLog records uploads in store as uploadFileWithResult mutations
+func WithAuditLog(store auditlog.Store) Option {
+	return func(h *Handler) {
+		h.auditLog = store
+	}
+}
+
+// NewHandler returns a handler answering calls with queries and mutations
+func NewHandler(queries Queries, mutations Mutations, logger *zap.Logger, options ...Option) *Handler {
+	h := &Handler{queries: queries, mutations: mutations, logger: logger}
+	for _, option := range options {
+		option(h)
+	}
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "gRPC calls are POST requests", http.StatusMethodNotAllowed)
+		return
+	}
+	contentType := r.Header.Get("Content-Type")
+	if contentType != "application/grpc" && contentType != "application/grpc+proto" {
+		http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+		return
+	}
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	w.WriteHeader(http.StatusOK)
+
+	ctx := r.Context()
+	if value := r.Header.Get("Grpc-Timeout"); value != "" {
+		if timeout, ok := parseTimeout(value); ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+	}
+	s, err := newStream(w, r)
+	if err == nil {
+		err = h.call(ctx, strings.TrimPrefix(r.URL.Path, servicePath), s)
+	}
+	status := statusOf(err)
+	if status.Code == Unknown || status.Code == Internal {
+		h.logger.Debug("gRPC call failed", zap.String("method", r.URL.Path), zap.Error(err))
+	}
+	s.finish(status)
+}
+
+// call answers the call of method
+func (h *Handler) call(ctx context.Context, method string, s *stream) error {
+	switch method {
+	case "ListFiles":
+		return h.listFiles(ctx, s)
+	case "BatchGetFiles":
+		return h.batchGetFiles(ctx, s)
+	case "Upload":
+		return h.upload(ctx, s)
+	}
+	return statusf(Unimplemented, "unknown method %s", method)
+}
+
+func (h *Handler) listFiles(ctx context.Context, s *stream) error {
+	if err := h.allowList.Check(ctx, listFilesField); err != nil {
+		return err
+	}
+	var req pb.ListFilesRequest
+	if err := s.recvOne(&req); err != nil {
+		return err
+	}
+	limit := listPageSize
+	sortBy, sortOrder := gql.SortOptionName, gql.SortOrderAsc
+	folders := []string{req.Path}
+	for len(folders) > 0 {
+		folder := folders[0]
+		folders = folders[1:]
+		after := ""
+		for {
+			list, err := h.queries.ListFiles(ctx, folder, optional(req.SpaceId), nil, &limit, nil, nil,
+				optional(req.Extensions), &req.ShowHidden, &sortBy, &sortOrder, nil, nil, nil, nil, &after, nil)
+			if err != nil {
+				return err
+			}
+			for _, item := range list.Items {
+				if err := s.send(&pb.FileInfo{
+					Name:         item.Name,
+					Path:         item.Path,
+					Size:         int64(item.Size),
+					IsDirectory:  item.IsDirectory,
+					ModifiedTime: item.ModifiedTime,
+					SystemTags:   item.SystemTags,
+				}); err != nil {
+					return err
+				}
+				if req.Recursive && item.IsDirectory {
+					folders = append(folders, item.Path)
+				}
+			}
+			if list.EndCursor == nil {
+				break
+			}
+			after = *list.EndCursor
+		}
+	}
+	return nil
+}
+
+func (h *Handler) batchGetFiles(ctx context.Context, s *stream) error {
+	var req pb.BatchGetFilesRequest
+	if err := s.recvOne(&req); err != nil {
+		return err
+	}
+	if err := h.allowList.Check(ctx, statFileField); err != nil {
+		return err
+	}
+	if req.IncludeMetadata {
+		if err := h.allowList.Check(ctx, fileMetadataField); err != nil {
+			return err
+		}
+	}
+	if len(req.Paths) > maxBatchPaths {
+		return statusf(InvalidArgument, "at most %d paths can be looked up at once", maxBatchPaths)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	paths := make(chan string)
+	results := make(chan *pb.FileResult)
+	go func() {
+		defer close(paths)
+		for _, p := range req.Paths {
+			select {
+			case paths <- p:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	var wg sync.WaitGroup
+	for range min(batchWorkers, len(req.Paths)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range paths {
+				select {
+				case results <- h.getFile(ctx, p, optional(req.SpaceId), req.IncludeMetadata):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	for result := range results {
+		if err := s.send(result); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+// getFile looks up a path of a BatchGetFiles call, failures included in
+// the result
+func (h *Handler) getFile(ctx context.Context, p string, spaceID *string, withMetadata bool) *pb.FileResult {
+	result := &pb.FileResult{Path: p}
+	stat, err := h.queries.StatFile(ctx, p, spaceID)
+	if err != nil {
+		result.Error = statusOf(err).Message
+		return result
+	}
+	result.File = &pb.FileInfo{
+		Name:         stat.Name,
+		Path:         stat.Path,
+		Size:         int64(stat.Size),
+		IsDirectory:  stat.IsDirectory,
+		ModifiedTime: stat.ModifiedTime,
+		SystemTags:   stat.SystemTags,
+	}
+	if stat.Etag != nil {
+		result.File.Etag = *stat.Etag
+	}
+	if !withMetadata || stat.IsDirectory {
+		return result
+	}
+	metadata, err := h.queries.FileMetadata(ctx, p, spaceID)
+	if err != nil {
+		result.Error = statusOf(err).Message
+		return result
+	}
+	result.Metadata = &pb.ImageMetadata{
+		Format:       metadata.Format,
+		Width:        int32Of(metadata.Width),
+		Height:       int32Of(metadata.Height),
+		Orientation:  int32Of(metadata.Orientation),
+		CaptureTime:  metadata.CaptureTime,
+		CameraMake:   metadata.CameraMake,
+		CameraModel:  metadata.CameraModel,
+		LensModel:    metadata.LensModel,
+		Software:     metadata.Software,
+		Iso:          int32Of(metadata.Iso),
+		Aperture:     metadata.Aperture,
+		ExposureTime: metadata.ExposureTime,
+		FocalLength:  metadata.FocalLength,
+		Latitude:     metadata.Latitude,
+		Longitude:    metadata.Longitude,
+		Altitude:     metadata.Altitude,
+	}
+	return result
+}
+
+// upload uploads the content of an Upload call as uploadFileWithResult,
+// recorded in the audit log as the mutation is
+func (h *Handler) upload(ctx context.Context, s *stream) error {
+	if err := h.allowList.Check(ctx, uploadField); err != nil {
+		return err
+	}
+	var req pb.UploadRequest
+	if err := s.recv(&req); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	header := req.GetHeader()
+	if header == nil || header.Path == "" {
+		return statusf(InvalidArgument, "the first message must carry the upload header with a path")
+	}
+	result, err := h.store(ctx, s, header)
+	if h.auditLog != nil {
+		auditlog.RecordOperation(ctx, h.auditLog, h.logger, &auditlog.Entry{
+			Operation: "uploadFileWithResult",
+			SpaceID:   header.SpaceId,
+			Path:      header.Path,
+			PathCount: 1,
+		}, err)
+	}
+	if err != nil {
+		return err
+	}
+	return s.send(&pb.UploadResponse{Path: result.Path, Deduplicated: result.Deduplicated})
+}
+
+// store spools the content following header to a temporary file, as the
+// storage needs its size up front, then uploads it
+func (h *Handler) store(ctx context.Context, s *stream, header *pb.UploadHeader) (*gql.UploadResult, error) {
+	tmp, err := os.CreateTemp("", "imagor-studio-grpc-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	var (
+		req  pb.UploadRequest
+		size int64
+	)
+	for {
+		req.Reset()
+		err := s.recv(&req)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if req.GetHeader() != nil {
+			return nil, statusf(InvalidArgument, "the upload header can only be sent once")
+		}
+		n, err := tmp.Write(req.GetChunk())
+		if err != nil {
+			return nil, err
+		}
+		size += int64(n)
+	}
+	if header.Size > 0 && header.Size != size {
+		return nil, statusf(InvalidArgument, "received %d bytes of the %d in the upload header", size, header.Size)
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	contentType := header.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(header.Path))
+	}
+	return h.mutations.UploadFileWithResult(ctx, header.Path, optional(header.SpaceId), graphql.Upload{
+		File:        tmp,
+		Filename:    path.Base(header.Path),
+		Size:        size,
+		ContentType: contentType,
+	}, nil)
+}
+
+// optional returns nil for an empty string, as unset fields of proto3
+// messages are
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func int32Of(i *int) *int32 {
+	if i == nil {
+		return nil
+	}
+	v := int32(*i)
+	return &v
+}
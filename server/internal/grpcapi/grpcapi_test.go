@@ -0,0 +1,425 @@
+package grpcapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/cshum/imagor-studio/server/internal/allowlist"
+	"github.com/cshum/imagor-studio/server/internal/auditlog"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/generated/pb"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/cshum/imagor-studio/server/pkg/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+)
+
+// testPageSize is the page size of testResolver listings, smaller than the
+// one asked for to exercise paging
+const testPageSize = 2
+
+// testResolver answers with a storage tree of folders and files
+type testResolver struct {
+	// folders lists the children of each folder
+	folders map[string][]*gql.FileItem
+
+	mu       sync.Mutex
+	uploaded map[string]string
+}
+
+func newTestResolver() *testResolver {
+	file := func(p string, size int) *gql.FileItem {
+		return &gql.FileItem{Name: p[strings.LastIndex(p, "/")+1:], Path: p, Size: size, ModifiedTime: "2026-10-15T09:30:00Z", SystemTags: []string{"photo"}}
+	}
+	folder := func(p string) *gql.FileItem {
+		return &gql.FileItem{Name: p[strings.LastIndex(p, "/")+1:], Path: p, IsDirectory: true}
+	}
+	return &testResolver{
+		folders: map[string][]*gql.FileItem{
+			"":            {folder("album"), file("a.jpg", 1), file("b.jpg", 2)},
+			"album":       {folder("album/2024"), file("album/c.jpg", 3)},
+			"album/2024":  {file("album/2024/d.jpg", 4), file("album/2024/e.jpg", 5), file("album/2024/f.jpg", 6)},
+			"album/empty": {},
+		},
+		uploaded: map[string]string{},
+	}
+}
+
+func (r *testResolver) ListFiles(_ context.Context, path string, _ *string, _ *int, _ *int, _ *bool, _ *bool, _ *string, _ *bool, sortBy *gql.SortOption, _ *gql.SortOrder, _ []string, _ []string, _ []string, _ *int, after *string, _ *bool) (*gql.FileList, error) {
+	if sortBy == nil || *sortBy != gql.SortOptionName {
+		return nil, errors.New("expected listing sorted by name")
+	}
+	items, ok := r.folders[path]
+	if !ok {
+		return nil, &gqlerror.Error{Message: path + " not found", Extensions: map[string]interface{}{"code": "NOT_FOUND"}}
+	}
+	start := 0
+	if after != nil && *after != "" {
+		start, _ = strconv.Atoi(*after)
+	}
+	end := min(start+testPageSize, len(items))
+	list := &gql.FileList{Items: items[start:end], TotalCount: len(items)}
+	if end < len(items) {
+		cursor := strconv.Itoa(end)
+		list.EndCursor = &cursor
+	}
+	return list, nil
+}
+
+func (r *testResolver) StatFile(_ context.Context, path string, _ *string) (*gql.FileStat, error) {
+	if _, ok := r.folders[path]; ok {
+		return &gql.FileStat{Name: path, Path: path, IsDirectory: true}, nil
+	}
+	for _, items := range r.folders {
+		for _, item := range items {
+			if item.Path == path {
+				etag := "etag-" + path
+				return &gql.FileStat{Name: item.Name, Path: item.Path, Size: item.Size, ModifiedTime: item.ModifiedTime, Etag: &etag, SystemTags: item.SystemTags}, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("failed to get file stats: %s not found", path)
+}
+
+func (r *testResolver) FileMetadata(_ context.Context, path string, _ *string) (*gql.FileMetadata, error) {
+	if path == "b.jpg" {
+		return nil, &gqlerror.Error{Message: "image processing is not available", Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"}}
+	}
+	width, cameraMake := 640, "Fujifilm"
+	return &gql.FileMetadata{Width: &width, CameraMake: &cameraMake}, nil
+}
+
+func (r *testResolver) UploadFileWithResult(_ context.Context, path string, _ *string, content graphql.Upload, _ *gql.UploadCollisionPolicy) (*gql.UploadResult, error) {
+	if strings.HasPrefix(path, "locked/") {
+		return nil, errors.New("insufficient permission: write access required")
+	}
+	data, err := io.ReadAll(content.File)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) != content.Size {
+		return nil, fmt.Errorf("size %d, read %d", content.Size, len(data))
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.uploaded["uploads/"+path] = content.ContentType + ":" + string(data)
+	return &gql.UploadResult{Path: "uploads/" + path}, nil
+}
+
+// testClient makes gRPC calls over cleartext HTTP/2
+type testClient struct {
+	url    string
+	client *http.Client
+}
+
+// newTestClient serves resolver to calls of a user session from 192.0.2.1
+func newTestClient(t *testing.T, resolver *testResolver, options ...Option) *testClient {
+	t.Helper()
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+	handler := NewHandler(resolver, resolver, zap.NewNop(), options...)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := auth.SetClaimsInContext(r.Context(), &auth.Claims{UserID: "user-1", Role: "user"})
+		handler.ServeHTTP(w, r.WithContext(auditlog.WithClientIP(ctx, "192.0.2.1")))
+	}))
+	srv.Config.Protocols = protocols
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return &testClient{url: srv.URL, client: &http.Client{Transport: &http.Transport{Protocols: protocols}}}
+}
+
+// call sends reqs to method, returning the raw response messages and the
+// status code and message
+func (c *testClient) call(t *testing.T, method string, reqs ...proto.Message) ([][]byte, Code, string) {
+	t.Helper()
+	var body bytes.Buffer
+	for _, req := range reqs {
+		data, err := proto.Marshal(req)
+		require.NoError(t, err)
+		var prefix [5]byte
+		binary.BigEndian.PutUint32(prefix[1:], uint32(len(data)))
+		body.Write(prefix[:])
+		body.Write(data)
+	}
+	httpReq, err := http.NewRequest(http.MethodPost, c.url+servicePath+method, &body)
+	require.NoError(t, err)
+	httpReq.Header.Set("Content-Type", "application/grpc")
+	httpReq.Header.Set("TE", "trailers")
+	resp, err := c.client.Do(httpReq)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var msgs [][]byte
+	for {
+		var prefix [5]byte
+		if _, err := io.ReadFull(resp.Body, prefix[:]); err == io.EOF {
+			break
+		} else {
+			require.NoError(t, err)
+		}
+		data := make([]byte, binary.BigEndian.Uint32(prefix[1:]))
+		_, err := io.ReadFull(resp.Body, data)
+		require.NoError(t, err)
+		msgs = append(msgs, data)
+	}
+	code, err := strconv.Atoi(resp.Trailer.Get("Grpc-Status"))
+	require.NoError(t, err)
+	return msgs, Code(code), resp.Trailer.Get("Grpc-Message")
+}
+
+func TestListFiles(t *testing.T) {
+	c := newTestClient(t, newTestResolver())
+
+	paths := func(msgs [][]byte) []string {
+		var paths []string
+		for _, data := range msgs {
+			var info pb.FileInfo
+			require.NoError(t, proto.Unmarshal(data, &info))
+			paths = append(paths, info.Path)
+		}
+		return paths
+	}
+
+	t.Run("folder", func(t *testing.T) {
+		msgs, code, _ := c.call(t, "ListFiles", &pb.ListFilesRequest{})
+		assert.Equal(t, OK, code)
+		assert.Equal(t, []string{"album", "a.jpg", "b.jpg"}, paths(msgs))
+
+		var info pb.FileInfo
+		require.NoError(t, proto.Unmarshal(msgs[2], &info))
+		assert.Equal(t, int64(2), info.Size)
+		assert.Equal(t, "2026-10-15T09:30:00Z", info.ModifiedTime)
+		assert.Equal(t, []string{"photo"}, info.SystemTags)
+	})
+
+	t.Run("recursive", func(t *testing.T) {
+		msgs, code, _ := c.call(t, "ListFiles", &pb.ListFilesRequest{Recursive: true})
+		assert.Equal(t, OK, code)
+		assert.Equal(t, []string{
+			"album", "a.jpg", "b.jpg",
+			"album/2024", "album/c.jpg",
+			"album/2024/d.jpg", "album/2024/e.jpg", "album/2024/f.jpg",
+		}, paths(msgs))
+	})
+
+	t.Run("error", func(t *testing.T) {
+		msgs, code, message := c.call(t, "ListFiles", &pb.ListFilesRequest{Path: "missing"})
+		assert.Empty(t, msgs)
+		assert.Equal(t, NotFound, code)
+		assert.Equal(t, "missing not found", message)
+	})
+}
+
+func TestBatchGetFiles(t *testing.T) {
+	c := newTestClient(t, newTestResolver())
+
+	t.Run("paths", func(t *testing.T) {
+		msgs, code, _ := c.call(t, "BatchGetFiles", &pb.BatchGetFilesRequest{
+			Paths:           []string{"a.jpg", "b.jpg", "album", "missing.jpg"},
+			IncludeMetadata: true,
+		})
+		assert.Equal(t, OK, code)
+		results := map[string]*pb.FileResult{}
+		for _, data := range msgs {
+			var result pb.FileResult
+			require.NoError(t, proto.Unmarshal(data, &result))
+			results[result.Path] = &result
+		}
+		require.Len(t, results, 4)
+
+		a := results["a.jpg"]
+		assert.Empty(t, a.Error)
+		assert.Equal(t, "etag-a.jpg", a.File.Etag)
+		assert.Equal(t, int64(1), a.File.Size)
+		require.NotNil(t, a.Metadata)
+		assert.Equal(t, int32(640), a.Metadata.GetWidth())
+		assert.Equal(t, "Fujifilm", a.Metadata.GetCameraMake())
+		assert.Nil(t, a.Metadata.Height)
+
+		b := results["b.jpg"]
+		assert.NotNil(t, b.File)
+		assert.Nil(t, b.Metadata)
+		assert.Equal(t, "image processing is not available", b.Error)
+
+		album := results["album"]
+		assert.True(t, album.File.IsDirectory)
+		assert.Nil(t, album.Metadata)
+		assert.Empty(t, album.Error)
+
+		missing := results["missing.jpg"]
+		assert.Nil(t, missing.File)
+		assert.Contains(t, missing.Error, "not found")
+	})
+
+	t.Run("without metadata", func(t *testing.T) {
+		msgs, code, _ := c.call(t, "BatchGetFiles", &pb.BatchGetFilesRequest{Paths: []string{"a.jpg"}})
+		assert.Equal(t, OK, code)
+		require.Len(t, msgs, 1)
+		var result pb.FileResult
+		require.NoError(t, proto.Unmarshal(msgs[0], &result))
+		assert.Nil(t, result.Metadata)
+	})
+
+	t.Run("too many paths", func(t *testing.T) {
+		paths := make([]string, maxBatchPaths+1)
+		for i := range paths {
+			paths[i] = fmt.Sprintf("%d.jpg", i)
+		}
+		msgs, code, _ := c.call(t, "BatchGetFiles", &pb.BatchGetFilesRequest{Paths: paths})
+		assert.Empty(t, msgs)
+		assert.Equal(t, InvalidArgument, code)
+	})
+}
+
+func TestUpload(t *testing.T) {
+	resolver := newTestResolver()
+	c := newTestClient(t, resolver)
+
+	header := func(h *pb.UploadHeader) *pb.UploadRequest {
+		return &pb.UploadRequest{Data: &pb.UploadRequest_Header{Header: h}}
+	}
+	chunk := func(s string) *pb.UploadRequest {
+		return &pb.UploadRequest{Data: &pb.UploadRequest_Chunk{Chunk: []byte(s)}}
+	}
+
+	t.Run("chunks", func(t *testing.T) {
+		msgs, code, message := c.call(t, "Upload", header(&pb.UploadHeader{Path: "new.jpg", Size: 11}), chunk("hello "), chunk("world"))
+		require.Equal(t, OK, code, message)
+		require.Len(t, msgs, 1)
+		var resp pb.UploadResponse
+		require.NoError(t, proto.Unmarshal(msgs[0], &resp))
+		assert.Equal(t, "uploads/new.jpg", resp.Path)
+		assert.False(t, resp.Deduplicated)
+		assert.Equal(t, "image/jpeg:hello world", resolver.uploaded["uploads/new.jpg"])
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		_, code, message := c.call(t, "Upload", header(&pb.UploadHeader{Path: "empty.txt", ContentType: "text/plain"}))
+		require.Equal(t, OK, code, message)
+		assert.Equal(t, "text/plain:", resolver.uploaded["uploads/empty.txt"])
+	})
+
+	t.Run("size mismatch", func(t *testing.T) {
+		_, code, message := c.call(t, "Upload", header(&pb.UploadHeader{Path: "short.jpg", Size: 100}), chunk("hello"))
+		assert.Equal(t, InvalidArgument, code)
+		assert.Equal(t, "received 5 bytes of the 100 in the upload header", message)
+	})
+
+	t.Run("missing header", func(t *testing.T) {
+		_, code, _ := c.call(t, "Upload", chunk("hello"))
+		assert.Equal(t, InvalidArgument, code)
+		_, code, _ = c.call(t, "Upload")
+		assert.Equal(t, InvalidArgument, code)
+	})
+
+	t.Run("header sent twice", func(t *testing.T) {
+		_, code, _ := c.call(t, "Upload", header(&pb.UploadHeader{Path: "a.jpg"}), header(&pb.UploadHeader{Path: "b.jpg"}))
+		assert.Equal(t, InvalidArgument, code)
+	})
+
+	t.Run("denied", func(t *testing.T) {
+		_, code, message := c.call(t, "Upload", header(&pb.UploadHeader{Path: "locked/a.jpg"}), chunk("x"))
+		assert.Equal(t, Unknown, code)
+		assert.Equal(t, "insufficient permission: write access required", message)
+	})
+
+	uploaded := make([]string, 0, len(resolver.uploaded))
+	for p := range resolver.uploaded {
+		uploaded = append(uploaded, p)
+	}
+	sort.Strings(uploaded)
+	assert.Equal(t, []string{"uploads/empty.txt", "uploads/new.jpg"}, uploaded)
+}
+
+func TestAllowListAndAuditLog(t *testing.T) {
+	ctx := context.Background()
+	db := testutil.NewDB(t)
+	allowList := allowlist.New(registrystore.New(db, zap.NewNop(), nil), zap.NewNop())
+	_, err := allowList.Set(ctx, "user", []string{"Query.statFile", "Mutation.uploadFileWithResult"})
+	require.NoError(t, err)
+	auditLog := auditlog.New(db, zap.NewNop())
+	resolver := newTestResolver()
+	c := newTestClient(t, resolver, WithAllowList(allowList), WithAuditLog(auditLog))
+
+	header := &pb.UploadRequest{Data: &pb.UploadRequest_Header{Header: &pb.UploadHeader{Path: "new.jpg"}}}
+	_, code, message := c.call(t, "Upload", header)
+	require.Equal(t, OK, code, message)
+	_, code, _ = c.call(t, "Upload", &pb.UploadRequest{Data: &pb.UploadRequest_Header{Header: &pb.UploadHeader{Path: "locked/a.jpg"}}})
+	assert.Equal(t, Unknown, code)
+
+	// Methods standing for fields outside the allow-list are refused
+	msgs, code, message := c.call(t, "ListFiles", &pb.ListFilesRequest{})
+	assert.Empty(t, msgs)
+	assert.Equal(t, PermissionDenied, code)
+	assert.Equal(t, "operation Query.listFiles is not allowed for role user", message)
+	msgs, code, _ = c.call(t, "BatchGetFiles", &pb.BatchGetFilesRequest{Paths: []string{"a.jpg"}})
+	assert.Equal(t, OK, code)
+	assert.Len(t, msgs, 1)
+	msgs, code, _ = c.call(t, "BatchGetFiles", &pb.BatchGetFilesRequest{Paths: []string{"a.jpg"}, IncludeMetadata: true})
+	assert.Empty(t, msgs)
+	assert.Equal(t, PermissionDenied, code)
+
+	_, err = allowList.Set(ctx, "user", []string{"Query.statFile"})
+	require.NoError(t, err)
+	_, code, _ = c.call(t, "Upload", &pb.UploadRequest{Data: &pb.UploadRequest_Header{Header: &pb.UploadHeader{Path: "refused.jpg"}}})
+	assert.Equal(t, PermissionDenied, code)
+	assert.NotContains(t, resolver.uploaded, "uploads/refused.jpg")
+
+	// Uploads are recorded as the mutation they run, failures included
+	entries, total, err := auditLog.List(ctx, auditlog.Filter{}, 0, 10)
+	require.NoError(t, err)
+	require.Equal(t, 2, total)
+	byPath := map[string]*auditlog.Entry{}
+	for _, entry := range entries {
+		byPath[entry.Path] = entry
+	}
+	require.Contains(t, byPath, "new.jpg")
+	assert.Equal(t, "uploadFileWithResult", byPath["new.jpg"].Operation)
+	assert.Equal(t, "user-1", byPath["new.jpg"].UserID)
+	assert.Equal(t, "192.0.2.1", byPath["new.jpg"].ClientIP)
+	assert.Empty(t, byPath["new.jpg"].Error)
+	require.Contains(t, byPath, "locked/a.jpg")
+	assert.Equal(t, "insufficient permission: write access required", byPath["locked/a.jpg"].Error)
+}
+
+func TestUnknownMethod(t *testing.T) {
+	c := newTestClient(t, newTestResolver())
+	_, code, message := c.call(t, "DeleteFiles", &pb.ListFilesRequest{})
+	assert.Equal(t, Unimplemented, code)
+	assert.Equal(t, "unknown method DeleteFiles", message)
+}
+
+func TestEncodeMessage(t *testing.T) {
+	assert.Equal(t, "caf%C3%A9 100%25 done", encodeMessage("café 100% done"))
+	assert.Equal(t, "line%0Abreak", encodeMessage("line\nbreak"))
+}
+
+func TestParseTimeout(t *testing.T) {
+	timeout, ok := parseTimeout("100m")
+	assert.True(t, ok)
+	assert.Equal(t, "100ms", timeout.String())
+	timeout, ok = parseTimeout("2H")
+	assert.True(t, ok)
+	assert.Equal(t, "2h0m0s", timeout.String())
+	for _, value := range []string{"", "m", "10x", "-1S", "123456789S"} {
+		_, ok := parseTimeout(value)
+		assert.False(t, ok, value)
+	}
+}
@@ -0,0 +1,222 @@
+package grpcapi
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxMessageSize bounds the messages received, as the 4 MiB default of
+// gRPC clients and servers
+const maxMessageSize = 4 << 20
+
+// Code is a gRPC status code
+type Code uint32
+
+const (
+	OK                 Code = 0
+	Canceled           Code = 1
+	Unknown            Code = 2
+	InvalidArgument    Code = 3
+	DeadlineExceeded   Code = 4
+	NotFound           Code = 5
+	PermissionDenied   Code = 7
+	ResourceExhausted  Code = 8
+	FailedPrecondition Code = 9
+	Unimplemented      Code = 12
+	Internal           Code = 13
+)
+
+// Status is an error answered with a gRPC status
+type Status struct {
+	Code    Code
+	Message string
+}
+
+func (s *Status) Error() string {
+	return fmt.Sprintf("grpc status %d: %s", s.Code, s.Message)
+}
+
+func statusf(code Code, format string, args ...any) *Status {
+	return &Status{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// statusOf returns the status answering err, with the codes of GraphQL
+// errors mapped to their gRPC counterparts
+func statusOf(err error) *Status {
+	if err == nil {
+		return &Status{Code: OK}
+	}
+	var s *Status
+	if errors.As(err, &s) {
+		return s
+	}
+	if errors.Is(err, context.Canceled) {
+		return &Status{Code: Canceled, Message: err.Error()}
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &Status{Code: DeadlineExceeded, Message: err.Error()}
+	}
+	var gqlErr *gqlerror.Error
+	if errors.As(err, &gqlErr) {
+		code, _ := gqlErr.Extensions["code"].(string)
+		switch code {
+		case "BAD_USER_INPUT":
+			return &Status{Code: InvalidArgument, Message: gqlErr.Message}
+		case "NOT_FOUND":
+			return &Status{Code: NotFound, Message: gqlErr.Message}
+		case "NOT_AVAILABLE":
+			return &Status{Code: FailedPrecondition, Message: gqlErr.Message}
+		case "TOO_MANY_REQUESTS":
+			return &Status{Code: ResourceExhausted, Message: gqlErr.Message}
+		case "FORBIDDEN":
+			return &Status{Code: PermissionDenied, Message: gqlErr.Message}
+		}
+		return &Status{Code: Unknown, Message: gqlErr.Message}
+	}
+	return &Status{Code: Unknown, Message: err.Error()}
+}
+
+// stream reads the length-prefixed messages of a gRPC request and writes
+// those of its response, ending it with the status in the trailers
+type stream struct {
+	w       http.ResponseWriter
+	body    io.Reader
+	gzipped bool
+}
+
+// newStream returns the stream of r, with an error when its messages
+// cannot be read
+func newStream(w http.ResponseWriter, r *http.Request) (*stream, error) {
+	s := &stream{w: w, body: r.Body}
+	switch encoding := r.Header.Get("Grpc-Encoding"); encoding {
+	case "", "identity":
+	case "gzip":
+		s.gzipped = true
+	default:
+		return s, statusf(Unimplemented, "unsupported grpc-encoding %q", encoding)
+	}
+	return s, nil
+}
+
+// recv reads the next message into msg, io.EOF once the client has sent
+// every message
+func (s *stream) recv(msg proto.Message) error {
+	var prefix [5]byte
+	if _, err := io.ReadFull(s.body, prefix[:]); err != nil {
+		if errors.Is(err, io.EOF) {
+			return io.EOF
+		}
+		return statusf(Canceled, "reading request: %v", err)
+	}
+	size := binary.BigEndian.Uint32(prefix[1:])
+	if size > maxMessageSize {
+		return statusf(ResourceExhausted, "message of %d bytes is larger than %d", size, maxMessageSize)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(s.body, data); err != nil {
+		return statusf(Canceled, "reading request: %v", err)
+	}
+	if prefix[0] == 1 {
+		if !s.gzipped {
+			return statusf(Internal, "compressed message without grpc-encoding")
+		}
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return statusf(Internal, "decompressing message: %v", err)
+		}
+		if data, err = io.ReadAll(io.LimitReader(zr, maxMessageSize+1)); err != nil {
+			return statusf(Internal, "decompressing message: %v", err)
+		}
+		if len(data) > maxMessageSize {
+			return statusf(ResourceExhausted, "message is larger than %d bytes", maxMessageSize)
+		}
+	}
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return statusf(Internal, "decoding message: %v", err)
+	}
+	return nil
+}
+
+// recvOne reads the request message of a call the client sends one
+// message to
+func (s *stream) recvOne(msg proto.Message) error {
+	err := s.recv(msg)
+	if errors.Is(err, io.EOF) {
+		return statusf(Internal, "missing request message")
+	}
+	return err
+}
+
+// send writes msg to the client right away
+func (s *stream) send(msg proto.Message) error {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return statusf(Internal, "encoding message: %v", err)
+	}
+	frame := make([]byte, 5, 5+len(data))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(data)))
+	if _, err := s.w.Write(append(frame, data...)); err != nil {
+		return err
+	}
+	return http.NewResponseController(s.w).Flush()
+}
+
+// finish ends the response with status
+func (s *stream) finish(status *Status) {
+	s.w.Header().Set("Grpc-Status", strconv.Itoa(int(status.Code)))
+	if status.Message != "" {
+		s.w.Header().Set("Grpc-Message", encodeMessage(status.Message))
+	}
+}
+
+// encodeMessage percent-encodes a status message for the grpc-message
+// trailer
+func encodeMessage(msg string) string {
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c < ' ' || c > '~' || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// parseTimeout parses a grpc-timeout header, such as 100m for 100
+// milliseconds
+func parseTimeout(value string) (time.Duration, bool) {
+	if len(value) < 2 || len(value) > 9 {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(value[:len(value)-1], 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	units := map[byte]time.Duration{
+		'H': time.Hour,
+		'M': time.Minute,
+		'S': time.Second,
+		'm': time.Millisecond,
+		'u': time.Microsecond,
+		'n': time.Nanosecond,
+	}
+	unit, ok := units[value[len(value)-1]]
+	if !ok {
+		return 0, false
+	}
+	return time.Duration(n) * unit, true
+}
@@ -0,0 +1,126 @@
+package hls
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FFmpeg transcodes with the ffmpeg and ffprobe command line tools
+type FFmpeg struct {
+	FFmpegPath  string
+	FFprobePath string
+}
+
+// NewFFmpeg looks up ffmpeg and ffprobe, ffmpegPath may be a name on PATH or
+// an absolute path; ffprobe is expected next to it
+func NewFFmpeg(ffmpegPath string) (*FFmpeg, error) {
+	resolved, err := exec.LookPath(ffmpegPath)
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg not found: %w", err)
+	}
+	probe := filepath.Join(filepath.Dir(resolved), "ffprobe")
+	if _, err := exec.LookPath(probe); err != nil {
+		if probe, err = exec.LookPath("ffprobe"); err != nil {
+			return nil, fmt.Errorf("ffprobe not found: %w", err)
+		}
+	}
+	return &FFmpeg{FFmpegPath: resolved, FFprobePath: probe}, nil
+}
+
+// networkProtocols are the protocols reading sources over HTTP needs
+const networkProtocols = "http,https,tcp,tls"
+
+// InputArgs returns the ffmpeg and ffprobe arguments reading input with only
+// the protocols it needs: HTTP for the presigned and loopback URLs sources
+// are read at, plain files for local paths. Local paths are given with the
+// file: prefix so no part of them is taken for an option or another
+// protocol. URLs of other schemes are refused.
+func InputArgs(input string) ([]string, error) {
+	if strings.HasPrefix(input, "http://") || strings.HasPrefix(input, "https://") {
+		return []string{"-protocol_whitelist", networkProtocols, "-i", input}, nil
+	}
+	if strings.Contains(input, "://") {
+		return nil, fmt.Errorf("unsupported video source %q", input)
+	}
+	return []string{"-protocol_whitelist", "file", "-i", "file:" + input}, nil
+}
+
+// Probe implements Transcoder
+func (f *FFmpeg) Probe(ctx context.Context, input string) (string, error) {
+	inputArgs, err := InputArgs(input)
+	if err != nil {
+		return "", err
+	}
+	args := append([]string{
+		"-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "stream=codec_name",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+	}, inputArgs...)
+	cmd := exec.CommandContext(ctx, f.FFprobePath, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("ffprobe failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	codec := strings.TrimSpace(string(out))
+	if codec == "" {
+		return "", fmt.Errorf("no video stream found")
+	}
+	return codec, nil
+}
+
+// Transcode implements Transcoder
+func (f *FFmpeg) Transcode(ctx context.Context, input, outDir string, segmentDuration time.Duration) error {
+	args, err := ffmpegArgs(input, outDir, segmentDuration)
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, f.FFmpegPath, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ffmpeg failed: %w: %s", err, lastLines(stderr.String(), 5))
+	}
+	return nil
+}
+
+func ffmpegArgs(input, outDir string, segmentDuration time.Duration) ([]string, error) {
+	inputArgs, err := InputArgs(input)
+	if err != nil {
+		return nil, err
+	}
+	seconds := int(segmentDuration.Round(time.Second) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	args := append([]string{"-hide_banner", "-nostdin", "-y"}, inputArgs...)
+	return append(args,
+		"-map", "0:v:0", "-map", "0:a:0?",
+		"-c:v", "libx264", "-preset", "veryfast", "-profile:v", "high", "-pix_fmt", "yuv420p",
+		// Keyframes on segment boundaries so every segment starts cleanly
+		"-force_key_frames", "expr:gte(t,n_forced*"+strconv.Itoa(seconds)+")",
+		"-c:a", "aac", "-b:a", "128k", "-ac", "2",
+		"-f", "hls",
+		"-hls_time", strconv.Itoa(seconds),
+		"-hls_playlist_type", "event",
+		"-hls_flags", "temp_file+independent_segments",
+		"-hls_segment_filename", filepath.Join(outDir, "seg_%05d.ts"),
+		filepath.Join(outDir, PlaylistName),
+	), nil
+}
+
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, " | ")
+}
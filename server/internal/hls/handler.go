@@ -0,0 +1,112 @@
+package hls
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// segmentPattern matches segment names written by the ffmpeg transcoder
+var segmentPattern = regexp.MustCompile(`^seg_\d{5}\.ts$`)
+
+const (
+	// fileWaitTimeout bounds how long a request waits for a segment that
+	// is still being transcoded
+	fileWaitTimeout = 30 * time.Second
+	filePollPeriod  = 200 * time.Millisecond
+)
+
+// ServeHTTP serves /<sessionID>/index.m3u8 and its segments, from local disk
+// while transcoding and from the result storage once kept there. Session IDs
+// are unguessable and short-lived, so the playlist can be used by native
+// players that cannot attach an Authorization header.
+func (m *Manager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	sessionID, name, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if !ok || (name != PlaylistName && !segmentPattern.MatchString(name)) {
+		http.NotFound(w, r)
+		return
+	}
+	key, running, err := m.lookup(sessionID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	filePath := filepath.Join(m.dir(key), name)
+	if err := m.waitForFile(r, filePath, running); err != nil && !errors.Is(err, os.ErrNotExist) {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	if name == PlaylistName {
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		// The playlist grows while transcoding
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Content-Type", "video/mp2t")
+		w.Header().Set("Cache-Control", "private, max-age=86400, immutable")
+	}
+	if file, err := os.Open(filePath); err == nil {
+		defer file.Close()
+		if info, err := file.Stat(); err == nil {
+			http.ServeContent(w, r, "", info.ModTime(), file)
+			return
+		}
+	}
+	m.serveResult(w, r, key, name)
+}
+
+// serveResult serves name of a finished transcode from the result storage
+func (m *Manager) serveResult(w http.ResponseWriter, r *http.Request, key, name string) {
+	if m.results == nil {
+		http.NotFound(w, r)
+		return
+	}
+	blob, err := m.results.Get(r, ResultKey(key, name))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	content, _, err := blob.NewReadSeeker()
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer content.Close()
+	http.ServeContent(w, r, "", time.Time{}, content)
+}
+
+// waitForFile blocks until filePath exists, the transcode finishes or the
+// wait times out
+func (m *Manager) waitForFile(r *http.Request, filePath string, running *transcode) error {
+	if _, err := os.Stat(filePath); err == nil || running == nil {
+		return err
+	}
+	timeout := time.NewTimer(fileWaitTimeout)
+	defer timeout.Stop()
+	ticker := time.NewTicker(filePollPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := os.Stat(filePath); err == nil {
+				return nil
+			}
+		case <-running.done:
+			_, err := os.Stat(filePath)
+			return err
+		case <-timeout.C:
+			return errors.New("transcode in progress, retry later")
+		case <-r.Context().Done():
+			return r.Context().Err()
+		}
+	}
+}
@@ -0,0 +1,577 @@
+// Package hls transcodes videos browsers cannot play into HLS on demand.
+//
+// A client asks for playback with a hint of the codecs it can decode. When the
+// source codec is among them the client plays the original file directly;
+// otherwise a playback session is created and ffmpeg transcodes the video to
+// H.264/AAC HLS segments in the background. ffmpeg reads the source by URL
+// with ranged reads, so the codec is probed without copying the video and
+// nothing is downloaded on the request path. Segments are written to local
+// disk while transcoding and kept in the result storage when done, keyed by
+// the source path and version, so repeat plays skip transcoding. The number
+// of concurrent transcodes is limited globally and per user.
+package hls
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/cshum/imagor-studio/server/pkg/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultMaxTranscodes        = 2
+	DefaultMaxTranscodesPerUser = 1
+	DefaultSegmentDuration      = 4 * time.Second
+	DefaultSessionTTL           = 2 * time.Hour
+	DefaultCacheTTL             = 7 * 24 * time.Hour
+
+	// TargetCodec is the codec every transcode produces
+	TargetCodec = "h264"
+
+	PlaylistName = "index.m3u8"
+
+	// ResultFolder is the folder of the result storage keeping finished
+	// transcodes, not hidden as file result storages refuse dot files
+	ResultFolder = "imagor-studio/hls"
+
+	codecName = "codec"
+	doneName  = ".done"
+
+	// probeTimeout bounds probing the codec of a source
+	probeTimeout = 30 * time.Second
+)
+
+var (
+	// ErrTooManyTranscodes is returned when starting a transcode would
+	// exceed the global or per-user limit
+	ErrTooManyTranscodes = errors.New("too many concurrent transcodes")
+	// ErrSessionNotFound is returned for unknown or expired sessions
+	ErrSessionNotFound = errors.New("playback session not found")
+)
+
+// Mode tells the client how to play a video
+type Mode string
+
+const (
+	// ModeDirect means the client can decode the original file
+	ModeDirect Mode = "direct"
+	// ModeHLS means the client should play the session playlist
+	ModeHLS Mode = "hls"
+)
+
+// directContainers are containers browsers play natively given a supported codec
+var directContainers = map[string]bool{
+	".mp4":  true,
+	".m4v":  true,
+	".mov":  true,
+	".webm": true,
+}
+
+// codecAliases normalises codec names used by clients and ffprobe
+var codecAliases = map[string]string{
+	"avc":  "h264",
+	"avc1": "h264",
+	"h264": "h264",
+	"h265": "hevc",
+	"hev1": "hevc",
+	"hvc1": "hevc",
+	"hevc": "hevc",
+	"av01": "av1",
+	"av1":  "av1",
+	"vp09": "vp9",
+	"vp9":  "vp9",
+	"vp8":  "vp8",
+}
+
+// NormalizeCodec maps a client or ffprobe codec name to its canonical form
+func NormalizeCodec(codec string) string {
+	codec = strings.ToLower(strings.TrimSpace(codec))
+	// Strip RFC 6381 profile suffixes such as avc1.64001F
+	if i := strings.IndexByte(codec, '.'); i > 0 {
+		codec = codec[:i]
+	}
+	if alias, ok := codecAliases[codec]; ok {
+		return alias
+	}
+	return codec
+}
+
+// Transcoder probes and transcodes videos read from a local path or URL
+type Transcoder interface {
+	// Probe returns the codec of the first video stream
+	Probe(ctx context.Context, input string) (string, error)
+	// Transcode writes an HLS playlist named PlaylistName and its segments
+	// into outDir, appending segments to the playlist as they complete
+	Transcode(ctx context.Context, input, outDir string, segmentDuration time.Duration) error
+}
+
+// Source identifies the video to play
+type Source struct {
+	Storage storage.Storage
+	// Namespace separates storages, e.g. a space ID, empty when self-hosted
+	Namespace string
+	Path      string
+}
+
+// Playback is the result of negotiating playback for a client
+type Playback struct {
+	Mode        Mode
+	SourceCodec string
+	// SessionID is set in HLS mode, the playlist is served at
+	// <SessionID>/index.m3u8 relative to the handler
+	SessionID string
+	ExpiresAt time.Time
+}
+
+type session struct {
+	key       string
+	userID    string
+	expiresAt time.Time
+}
+
+type transcode struct {
+	userID string
+	done   chan struct{}
+	err    error
+}
+
+// probe is the codec probe of a source shared by concurrent requests
+type probe struct {
+	done  chan struct{}
+	codec string
+	err   error
+}
+
+// Manager negotiates playback, runs transcodes and serves HLS sessions
+type Manager struct {
+	logger     *zap.Logger
+	transcoder Transcoder
+	cacheDir   string
+	// results keeps finished transcodes, nil keeps them in cacheDir
+	results imagor.Storage
+	sources *sourceServer
+
+	maxTranscodes        int
+	maxTranscodesPerUser int
+	segmentDuration      time.Duration
+	sessionTTL           time.Duration
+	cacheTTL             time.Duration
+	now                  func() time.Time
+
+	// ctx is cancelled by Close to stop running transcodes
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu         sync.Mutex
+	sessions   map[string]*session
+	transcodes map[string]*transcode
+	// probes are the running probes per cache key
+	probes map[string]*probe
+}
+
+// Option configures a Manager
+type Option func(*Manager)
+
+// WithMaxTranscodes limits concurrent transcodes across all users
+func WithMaxTranscodes(n int) Option {
+	return func(m *Manager) {
+		if n > 0 {
+			m.maxTranscodes = n
+		}
+	}
+}
+
+// WithMaxTranscodesPerUser limits concurrent transcodes started by one user
+func WithMaxTranscodesPerUser(n int) Option {
+	return func(m *Manager) {
+		if n > 0 {
+			m.maxTranscodesPerUser = n
+		}
+	}
+}
+
+// WithSegmentDuration sets the target HLS segment length
+func WithSegmentDuration(d time.Duration) Option {
+	return func(m *Manager) {
+		if d > 0 {
+			m.segmentDuration = d
+		}
+	}
+}
+
+// WithSessionTTL sets how long a playback session stays valid
+func WithSessionTTL(d time.Duration) Option {
+	return func(m *Manager) {
+		if d > 0 {
+			m.sessionTTL = d
+		}
+	}
+}
+
+// WithResultStorage keeps finished transcodes in results rather than on
+// local disk, so they survive restarts and are shared by replicas
+func WithResultStorage(results imagor.Storage) Option {
+	return func(m *Manager) {
+		m.results = results
+	}
+}
+
+// WithCacheTTL sets how long unused transcodes are kept on disk
+func WithCacheTTL(d time.Duration) Option {
+	return func(m *Manager) {
+		if d > 0 {
+			m.cacheTTL = d
+		}
+	}
+}
+
+// NewManager creates a manager caching transcodes below cacheDir
+func NewManager(logger *zap.Logger, transcoder Transcoder, cacheDir string, opts ...Option) (*Manager, error) {
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create HLS cache directory: %w", err)
+	}
+	sources, err := newSourceServer()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &Manager{
+		ctx:                  ctx,
+		cancel:               cancel,
+		logger:               logger,
+		transcoder:           transcoder,
+		cacheDir:             cacheDir,
+		sources:              sources,
+		maxTranscodes:        DefaultMaxTranscodes,
+		maxTranscodesPerUser: DefaultMaxTranscodesPerUser,
+		segmentDuration:      DefaultSegmentDuration,
+		sessionTTL:           DefaultSessionTTL,
+		cacheTTL:             DefaultCacheTTL,
+		now:                  time.Now,
+		sessions:             make(map[string]*session),
+		transcodes:           make(map[string]*transcode),
+		probes:               make(map[string]*probe),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m, nil
+}
+
+// Close stops running transcodes
+func (m *Manager) Close() {
+	m.cancel()
+	m.sources.close()
+}
+
+// Negotiate decides how userID should play src given the codecs the client
+// reports it can decode, starting a transcode when needed
+func (m *Manager) Negotiate(ctx context.Context, userID string, src Source, clientCodecs []string) (*Playback, error) {
+	info, err := src.Storage.Stat(ctx, src.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat video: %w", err)
+	}
+	if info.IsDir {
+		return nil, fmt.Errorf("%s is a folder", src.Path)
+	}
+	key := cacheKey(src, info)
+
+	codec, err := m.probeCodec(ctx, key, src, info)
+	if err != nil {
+		return nil, err
+	}
+
+	if directContainers[strings.ToLower(path.Ext(src.Path))] {
+		for _, clientCodec := range clientCodecs {
+			if NormalizeCodec(clientCodec) == codec {
+				return &Playback{Mode: ModeDirect, SourceCodec: codec}, nil
+			}
+		}
+	}
+
+	if err := m.ensureTranscode(ctx, key, userID, src, info); err != nil {
+		return nil, err
+	}
+	sessionID, expiresAt := m.createSession(key, userID)
+	return &Playback{
+		Mode:        ModeHLS,
+		SourceCodec: codec,
+		SessionID:   sessionID,
+		ExpiresAt:   expiresAt,
+	}, nil
+}
+
+// ResultKey is where name of the transcode of a cache key is kept in the
+// result storage
+func ResultKey(key, name string) string {
+	return path.Join(ResultFolder, key, name)
+}
+
+// probeCodec returns the codec of the source, probed once per version and
+// shared by concurrent requests. ffprobe only reads the ranges of the source
+// it needs.
+func (m *Manager) probeCodec(ctx context.Context, key string, src Source, info storage.FileInfo) (string, error) {
+	codecPath := filepath.Join(m.dir(key), codecName)
+	if data, err := os.ReadFile(codecPath); err == nil {
+		m.touch(m.dir(key))
+		return string(data), nil
+	}
+	if m.results != nil {
+		if blob, err := m.results.Get(new(http.Request).WithContext(ctx), ResultKey(key, codecName)); err == nil {
+			if data, err := blob.ReadAll(); err == nil && len(data) > 0 {
+				return string(data), nil
+			}
+		}
+	}
+
+	m.mu.Lock()
+	p, running := m.probes[key]
+	if !running {
+		p = &probe{done: make(chan struct{})}
+		m.probes[key] = p
+	}
+	m.mu.Unlock()
+	if !running {
+		// Not cancelled with the request, other requests may wait for it
+		probeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), probeTimeout)
+		p.codec, p.err = m.runProbe(probeCtx, key, src, info)
+		cancel()
+		m.mu.Lock()
+		delete(m.probes, key)
+		m.mu.Unlock()
+		close(p.done)
+	}
+	select {
+	case <-p.done:
+		return p.codec, p.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func (m *Manager) runProbe(ctx context.Context, key string, src Source, info storage.FileInfo) (string, error) {
+	input, release, err := m.sources.open(ctx, src, info)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	codec, err := m.transcoder.Probe(ctx, input)
+	if err != nil {
+		return "", fmt.Errorf("failed to probe video: %w", err)
+	}
+	codec = NormalizeCodec(codec)
+	dir := m.dir(key)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create HLS cache entry: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, codecName), []byte(codec), 0644); err != nil {
+		return "", fmt.Errorf("failed to write HLS cache entry: %w", err)
+	}
+	if m.results != nil {
+		if err := m.results.Put(ctx, ResultKey(key, codecName), imagor.NewBlobFromBytes([]byte(codec))); err != nil {
+			m.logger.Warn("Failed to keep HLS codec in result storage", zap.String("key", key), zap.Error(err))
+		}
+	}
+	return codec, nil
+}
+
+// transcoded reports whether the transcode of key is finished, on local
+// disk or in the result storage
+func (m *Manager) transcoded(ctx context.Context, key string) bool {
+	if _, err := os.Stat(filepath.Join(m.dir(key), doneName)); err == nil {
+		return true
+	}
+	if m.results == nil {
+		return false
+	}
+	_, err := m.results.Stat(ctx, ResultKey(key, PlaylistName))
+	return err == nil
+}
+
+// ensureTranscode starts transcoding key unless it is transcoded or running
+func (m *Manager) ensureTranscode(ctx context.Context, key, userID string, src Source, info storage.FileInfo) error {
+	if m.transcoded(ctx, key) {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, running := m.transcodes[key]; running {
+		// Shared with whoever started it, no extra slot needed
+		return nil
+	}
+	perUser := 0
+	for _, t := range m.transcodes {
+		if t.userID == userID {
+			perUser++
+		}
+	}
+	if len(m.transcodes) >= m.maxTranscodes || perUser >= m.maxTranscodesPerUser {
+		return ErrTooManyTranscodes
+	}
+
+	t := &transcode{userID: userID, done: make(chan struct{})}
+	m.transcodes[key] = t
+	go m.runTranscode(key, t, src, info)
+	return nil
+}
+
+func (m *Manager) runTranscode(key string, t *transcode, src Source, info storage.FileInfo) {
+	defer close(t.done)
+	dir := m.dir(key)
+
+	m.pruneCache()
+	err := m.transcode(dir, src, info)
+	if err == nil {
+		err = os.WriteFile(filepath.Join(dir, doneName), nil, 0644)
+	}
+	stored := false
+	if err != nil {
+		m.logger.Warn("HLS transcode failed", zap.String("key", key), zap.Error(err))
+		// Drop partial output so the next request starts over
+		m.removeOutput(dir)
+	} else {
+		m.logger.Info("HLS transcode completed", zap.String("key", key))
+		if m.results != nil {
+			if storeErr := m.storeResult(dir, key); storeErr != nil {
+				// Still served from local disk
+				m.logger.Warn("Failed to keep HLS transcode in result storage", zap.String("key", key), zap.Error(storeErr))
+			} else {
+				stored = true
+			}
+		}
+	}
+
+	m.mu.Lock()
+	t.err = err
+	delete(m.transcodes, key)
+	m.mu.Unlock()
+	if stored {
+		_ = os.RemoveAll(dir)
+	}
+}
+
+// transcode transcodes the source read by URL into dir
+func (m *Manager) transcode(dir string, src Source, info storage.FileInfo) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create HLS cache entry: %w", err)
+	}
+	input, release, err := m.sources.open(m.ctx, src, info)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return m.transcoder.Transcode(m.ctx, input, dir, m.segmentDuration)
+}
+
+// storeResult copies the segments and then the playlist of a finished
+// transcode to the result storage, the playlist marking it complete
+func (m *Manager) storeResult(dir, key string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if name := entry.Name(); segmentPattern.MatchString(name) {
+			if err := m.results.Put(m.ctx, ResultKey(key, name), imagor.NewBlobFromFile(filepath.Join(dir, name))); err != nil {
+				return err
+			}
+		}
+	}
+	return m.results.Put(m.ctx, ResultKey(key, PlaylistName), imagor.NewBlobFromFile(filepath.Join(dir, PlaylistName)))
+}
+
+func (m *Manager) removeOutput(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if name := entry.Name(); name != codecName {
+			_ = os.Remove(filepath.Join(dir, name))
+		}
+	}
+}
+
+func (m *Manager) createSession(key, userID string) (string, time.Time) {
+	now := m.now()
+	expiresAt := now.Add(m.sessionTTL)
+	id := uuid.GenerateUUID()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for sid, s := range m.sessions {
+		if now.After(s.expiresAt) {
+			delete(m.sessions, sid)
+		}
+	}
+	m.sessions[id] = &session{key: key, userID: userID, expiresAt: expiresAt}
+	return id, expiresAt
+}
+
+// lookup returns the cache key and running transcode, if any, for a session
+func (m *Manager) lookup(sessionID string) (string, *transcode, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[sessionID]
+	if !ok || m.now().After(s.expiresAt) {
+		return "", nil, ErrSessionNotFound
+	}
+	return s.key, m.transcodes[s.key], nil
+}
+
+// pruneCache removes cache entries unused for longer than the cache TTL
+func (m *Manager) pruneCache() {
+	entries, err := os.ReadDir(m.cacheDir)
+	if err != nil {
+		return
+	}
+	cutoff := m.now().Add(-m.cacheTTL)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		m.mu.Lock()
+		_, running := m.transcodes[entry.Name()]
+		m.mu.Unlock()
+		if running {
+			continue
+		}
+		info, err := entry.Info()
+		if err == nil && info.ModTime().Before(cutoff) {
+			_ = os.RemoveAll(filepath.Join(m.cacheDir, entry.Name()))
+		}
+	}
+}
+
+func (m *Manager) touch(dir string) {
+	now := m.now()
+	_ = os.Chtimes(dir, now, now)
+}
+
+func (m *Manager) dir(key string) string {
+	return filepath.Join(m.cacheDir, key)
+}
+
+// cacheKey changes whenever the source file changes
+func cacheKey(src Source, info storage.FileInfo) string {
+	version := info.ETag
+	if version == "" {
+		version = fmt.Sprintf("%d-%d", info.Size, info.ModifiedTime.UnixNano())
+	}
+	sum := sha256.Sum256([]byte(src.Namespace + "\x00" + src.Path + "\x00" + version + "\x00" + TargetCodec))
+	return hex.EncodeToString(sum[:16])
+}
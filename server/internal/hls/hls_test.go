@@ -0,0 +1,336 @@
+package hls
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	imagorfilestorage "github.com/cshum/imagor/storage/filestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type fakeTranscoder struct {
+	codec string
+
+	mu         sync.Mutex
+	probes     int
+	transcodes int
+	// probed and transcoded are the source content each read
+	probed, transcoded string
+	// release, when set, blocks Transcode after the first segment
+	release chan struct{}
+}
+
+// readInput reads the source at input, the first bytes only when n > 0, as
+// ffprobe does with ranged reads
+func readInput(ctx context.Context, input string, n int) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, input, nil)
+	if err != nil {
+		return "", err
+	}
+	if n > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", n-1))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return "", fmt.Errorf("reading source: %s", resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	return string(data), err
+}
+
+func (f *fakeTranscoder) Probe(ctx context.Context, input string) (string, error) {
+	probed, err := readInput(ctx, input, 2)
+	if err != nil {
+		return "", err
+	}
+	f.mu.Lock()
+	f.probes++
+	f.probed = probed
+	f.mu.Unlock()
+	return f.codec, nil
+}
+
+func (f *fakeTranscoder) Transcode(ctx context.Context, input, outDir string, segmentDuration time.Duration) error {
+	transcoded, err := readInput(ctx, input, 0)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	f.transcodes++
+	f.transcoded = transcoded
+	f.mu.Unlock()
+	if err := os.WriteFile(filepath.Join(outDir, "seg_00000.ts"), []byte("segment0"), 0644); err != nil {
+		return err
+	}
+	playlist := "#EXTM3U\n#EXT-X-PLAYLIST-TYPE:EVENT\n#EXTINF:4.0,\nseg_00000.ts\n"
+	if err := os.WriteFile(filepath.Join(outDir, PlaylistName), []byte(playlist), 0644); err != nil {
+		return err
+	}
+	if f.release != nil {
+		select {
+		case <-f.release:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return os.WriteFile(filepath.Join(outDir, PlaylistName), []byte(playlist+"#EXT-X-ENDLIST\n"), 0644)
+}
+
+func newTestManager(t *testing.T, transcoder Transcoder, opts ...Option) (*Manager, Source, string) {
+	t.Helper()
+	baseDir := t.TempDir()
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+	m, err := NewManager(zap.NewNop(), transcoder, filepath.Join(t.TempDir(), "hls"), opts...)
+	require.NoError(t, err)
+	t.Cleanup(m.Close)
+	return m, Source{Storage: stor, Path: "clips/video.mp4"}, baseDir
+}
+
+func writeVideo(t *testing.T, baseDir, path, content string) {
+	t.Helper()
+	fullPath := filepath.Join(baseDir, path)
+	require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0755))
+	require.NoError(t, os.WriteFile(fullPath, []byte(content), 0644))
+}
+
+func waitTranscodes(t *testing.T, m *Manager) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		return len(m.transcodes) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func cacheKeyOf(t *testing.T, src Source) string {
+	t.Helper()
+	info, err := src.Storage.Stat(context.Background(), src.Path)
+	require.NoError(t, err)
+	return cacheKey(src, info)
+}
+
+func get(m *Manager, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec
+}
+
+func TestNormalizeCodec(t *testing.T) {
+	assert.Equal(t, "h264", NormalizeCodec("avc1.64001F"))
+	assert.Equal(t, "hevc", NormalizeCodec("H265"))
+	assert.Equal(t, "hevc", NormalizeCodec("hvc1"))
+	assert.Equal(t, "av1", NormalizeCodec("av01.0.05M.08"))
+	assert.Equal(t, "prores", NormalizeCodec("prores"))
+}
+
+func TestNegotiate_DirectWhenClientSupportsCodec(t *testing.T) {
+	transcoder := &fakeTranscoder{codec: "hevc"}
+	m, src, baseDir := newTestManager(t, transcoder)
+	writeVideo(t, baseDir, src.Path, "video")
+
+	playback, err := m.Negotiate(context.Background(), "user-1", src, []string{"h264", "hvc1"})
+	require.NoError(t, err)
+	assert.Equal(t, ModeDirect, playback.Mode)
+	assert.Equal(t, "hevc", playback.SourceCodec)
+	assert.Empty(t, playback.SessionID)
+	assert.Equal(t, 0, transcoder.transcodes)
+}
+
+func TestNegotiate_TranscodesAndServesHLS(t *testing.T) {
+	transcoder := &fakeTranscoder{codec: "hevc"}
+	m, src, baseDir := newTestManager(t, transcoder)
+	writeVideo(t, baseDir, src.Path, "video")
+
+	playback, err := m.Negotiate(context.Background(), "user-1", src, []string{"h264"})
+	require.NoError(t, err)
+	assert.Equal(t, ModeHLS, playback.Mode)
+	assert.Equal(t, "hevc", playback.SourceCodec)
+	require.NotEmpty(t, playback.SessionID)
+
+	rec := get(m, "/"+playback.SessionID+"/"+PlaylistName)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/vnd.apple.mpegurl", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "seg_00000.ts")
+
+	rec = get(m, "/"+playback.SessionID+"/seg_00000.ts")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "segment0", rec.Body.String())
+
+	waitTranscodes(t, m)
+
+	// Cached: a new session reuses the finished transcode
+	again, err := m.Negotiate(context.Background(), "user-2", src, nil)
+	require.NoError(t, err)
+	assert.NotEqual(t, playback.SessionID, again.SessionID)
+	rec = get(m, "/"+again.SessionID+"/"+PlaylistName)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "#EXT-X-ENDLIST")
+	assert.Equal(t, 1, transcoder.transcodes)
+	assert.Equal(t, 1, transcoder.probes)
+
+	// Changing the source invalidates the cache
+	writeVideo(t, baseDir, src.Path, "re-encoded video")
+	_, err = m.Negotiate(context.Background(), "user-1", src, nil)
+	require.NoError(t, err)
+	waitTranscodes(t, m)
+	assert.Equal(t, 2, transcoder.transcodes)
+}
+
+func TestNegotiate_ProbesWithRangedReadsOnce(t *testing.T) {
+	transcoder := &fakeTranscoder{codec: "hevc"}
+	m, src, baseDir := newTestManager(t, transcoder)
+	writeVideo(t, baseDir, src.Path, "video")
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			playback, err := m.Negotiate(context.Background(), "user-1", src, []string{"hevc"})
+			assert.NoError(t, err)
+			assert.Equal(t, ModeDirect, playback.Mode)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, transcoder.probes)
+	assert.Equal(t, "vi", transcoder.probed)
+
+	// Only the codec is cached, the source is not copied
+	entries, err := os.ReadDir(m.dir(cacheKeyOf(t, src)))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, codecName, entries[0].Name())
+	assert.Empty(t, m.probes)
+}
+
+func TestNegotiate_KeepsTranscodesInResultStorage(t *testing.T) {
+	results := imagorfilestorage.New(t.TempDir())
+	transcoder := &fakeTranscoder{codec: "hevc"}
+	m, src, baseDir := newTestManager(t, transcoder, WithResultStorage(results))
+	writeVideo(t, baseDir, src.Path, "video")
+
+	playback, err := m.Negotiate(context.Background(), "user-1", src, nil)
+	require.NoError(t, err)
+	waitTranscodes(t, m)
+	assert.Equal(t, "video", transcoder.transcoded)
+
+	// Served from the result storage, local output removed
+	key := cacheKeyOf(t, src)
+	_, err = os.Stat(m.dir(key))
+	assert.True(t, os.IsNotExist(err))
+	rec := get(m, "/"+playback.SessionID+"/"+PlaylistName)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "#EXT-X-ENDLIST")
+	rec = get(m, "/"+playback.SessionID+"/seg_00000.ts")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "segment0", rec.Body.String())
+	assert.Equal(t, http.StatusNotFound, get(m, "/"+playback.SessionID+"/seg_00009.ts").Code)
+
+	// Another manager sharing the result storage neither probes nor transcodes
+	other, err := NewManager(zap.NewNop(), transcoder, filepath.Join(t.TempDir(), "hls"), WithResultStorage(results))
+	require.NoError(t, err)
+	t.Cleanup(other.Close)
+	again, err := other.Negotiate(context.Background(), "user-2", src, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "hevc", again.SourceCodec)
+	rec = get(other, "/"+again.SessionID+"/seg_00000.ts")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, 1, transcoder.probes)
+	assert.Equal(t, 1, transcoder.transcodes)
+}
+
+func TestNegotiate_ConcurrencyLimits(t *testing.T) {
+	transcoder := &fakeTranscoder{codec: "av1", release: make(chan struct{})}
+	m, src, baseDir := newTestManager(t, transcoder, WithMaxTranscodes(2), WithMaxTranscodesPerUser(1))
+	writeVideo(t, baseDir, "a.mkv", "a")
+	writeVideo(t, baseDir, "b.mkv", "b")
+	writeVideo(t, baseDir, "c.mkv", "c")
+	srcA, srcB, srcC := src, src, src
+	srcA.Path, srcB.Path, srcC.Path = "a.mkv", "b.mkv", "c.mkv"
+
+	_, err := m.Negotiate(context.Background(), "user-1", srcA, nil)
+	require.NoError(t, err)
+
+	// Same user, second video
+	_, err = m.Negotiate(context.Background(), "user-1", srcB, nil)
+	assert.ErrorIs(t, err, ErrTooManyTranscodes)
+
+	// Another user joining the running transcode needs no extra slot
+	_, err = m.Negotiate(context.Background(), "user-2", srcA, nil)
+	require.NoError(t, err)
+
+	_, err = m.Negotiate(context.Background(), "user-2", srcB, nil)
+	require.NoError(t, err)
+
+	// Global limit reached
+	_, err = m.Negotiate(context.Background(), "user-3", srcC, nil)
+	assert.ErrorIs(t, err, ErrTooManyTranscodes)
+
+	close(transcoder.release)
+	waitTranscodes(t, m)
+	_, err = m.Negotiate(context.Background(), "user-3", srcC, nil)
+	assert.NoError(t, err)
+}
+
+func TestServeHTTP_RejectsUnknownSessionsAndFiles(t *testing.T) {
+	m, src, baseDir := newTestManager(t, &fakeTranscoder{codec: "hevc"})
+	writeVideo(t, baseDir, src.Path, "video")
+
+	assert.Equal(t, http.StatusNotFound, get(m, "/unknown/"+PlaylistName).Code)
+
+	playback, err := m.Negotiate(context.Background(), "user-1", src, nil)
+	require.NoError(t, err)
+	waitTranscodes(t, m)
+	assert.Equal(t, http.StatusNotFound, get(m, "/"+playback.SessionID+"/source").Code)
+	assert.Equal(t, http.StatusNotFound, get(m, "/"+playback.SessionID+"/../codec").Code)
+	assert.Equal(t, http.StatusNotFound, get(m, "/"+playback.SessionID+"/seg_00009.ts").Code)
+
+	// Expired sessions are rejected
+	m.now = func() time.Time { return time.Now().Add(DefaultSessionTTL + time.Minute) }
+	assert.Equal(t, http.StatusNotFound, get(m, "/"+playback.SessionID+"/"+PlaylistName).Code)
+}
+
+func TestFFmpegArgs(t *testing.T) {
+	args, err := ffmpegArgs("http://127.0.0.1:8080/source", "/cache/key", 4*time.Second)
+	require.NoError(t, err)
+	assert.Contains(t, args, "/cache/key/seg_%05d.ts")
+	assert.Equal(t, "/cache/key/"+PlaylistName, args[len(args)-1])
+	assert.Contains(t, args, "libx264")
+	assert.Contains(t, strings.Join(args, " "), "-protocol_whitelist http,https,tcp,tls -i http://127.0.0.1:8080/source")
+}
+
+func TestInputArgs(t *testing.T) {
+	args, err := InputArgs("https://bucket.s3.amazonaws.com/video.mp4?X-Amz-Signature=abc")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-protocol_whitelist", "http,https,tcp,tls", "-i", "https://bucket.s3.amazonaws.com/video.mp4?X-Amz-Signature=abc"}, args)
+
+	// Local paths cannot pass for options or other protocols
+	args, err = InputArgs("-f lavfi.mp4")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-protocol_whitelist", "file", "-i", "file:-f lavfi.mp4"}, args)
+	args, err = InputArgs("/cache/concat:a.mp4|b.mp4")
+	require.NoError(t, err)
+	assert.Equal(t, "file:/cache/concat:a.mp4|b.mp4", args[len(args)-1])
+
+	for _, input := range []string{"ftp://host/video.mp4", "rtmp://host/live", "file:///etc/passwd"} {
+		_, err = InputArgs(input)
+		assert.Error(t, err, input)
+	}
+}
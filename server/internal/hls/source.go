@@ -0,0 +1,88 @@
+package hls
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/cshum/imagor-studio/server/pkg/uuid"
+)
+
+// sourceURLTTL bounds how long a presigned source URL stays valid, long
+// enough for a transcode to finish reading it
+const sourceURLTTL = 6 * time.Hour
+
+// sourceServer serves sources to ffmpeg over loopback HTTP with byte-range
+// support, so probing reads the few ranges ffprobe asks for rather than a
+// full copy of the video. Sources are served at unguessable paths only
+// while they are open.
+type sourceServer struct {
+	listener net.Listener
+	server   *http.Server
+
+	mu      sync.Mutex
+	sources map[string]openSource
+}
+
+type openSource struct {
+	src  Source
+	info storage.FileInfo
+}
+
+func newSourceServer() (*sourceServer, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for HLS sources: %w", err)
+	}
+	s := &sourceServer{listener: listener, sources: make(map[string]openSource)}
+	s.server = &http.Server{Handler: s, ReadHeaderTimeout: 10 * time.Second}
+	go func() { _ = s.server.Serve(listener) }()
+	return s, nil
+}
+
+// open returns the URL ffmpeg reads src at and releases it when done.
+// Storages presigning downloads are read from directly.
+func (s *sourceServer) open(ctx context.Context, src Source, info storage.FileInfo) (string, func(), error) {
+	if presigner, ok := src.Storage.(storage.DownloadPresignableStorage); ok {
+		url, err := presigner.PresignedGetURL(ctx, src.Path, path.Base(src.Path), sourceURLTTL)
+		if err == nil {
+			return url, func() {}, nil
+		}
+	}
+	token := uuid.GenerateUUID()
+	s.mu.Lock()
+	s.sources[token] = openSource{src: src, info: info}
+	s.mu.Unlock()
+	release := func() {
+		s.mu.Lock()
+		delete(s.sources, token)
+		s.mu.Unlock()
+	}
+	return "http://" + s.listener.Addr().String() + "/" + token, release, nil
+}
+
+func (s *sourceServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	open, ok := s.sources[strings.TrimPrefix(r.URL.Path, "/")]
+	s.mu.Unlock()
+	if !ok || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		http.NotFound(w, r)
+		return
+	}
+	content := storage.NewLazyReadSeeker(r.Context(), func(ctx context.Context) (io.ReadCloser, error) {
+		return open.src.Storage.Get(ctx, open.src.Path)
+	}, open.info.Size)
+	defer content.Close()
+	http.ServeContent(w, r, "", open.info.ModifiedTime, content)
+}
+
+func (s *sourceServer) close() {
+	_ = s.server.Close()
+}
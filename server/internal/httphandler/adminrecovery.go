@@ -0,0 +1,130 @@
+package httphandler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/cshum/imagor-studio/server/internal/adminrecovery"
+	"github.com/cshum/imagor-studio/server/internal/userstore"
+	"github.com/cshum/imagor-studio/server/pkg/apperror"
+	"github.com/cshum/imagor-studio/server/pkg/auth"
+	"github.com/cshum/imagor-studio/server/pkg/validation"
+	"go.uber.org/zap"
+)
+
+type AdminRecoveryRequest struct {
+	Token       string `json:"token"`
+	Username    string `json:"username"`
+	NewPassword string `json:"newPassword"`
+}
+
+type AdminRecoveryResponse struct {
+	UserID   string `json:"userId"`
+	Username string `json:"username"`
+}
+
+// AdminRecoveryHandler resets an admin password with a recovery token
+// printed to the server log
+type AdminRecoveryHandler struct {
+	recovery  *adminrecovery.Manager
+	userStore userstore.Store
+	logger    *zap.Logger
+}
+
+func NewAdminRecoveryHandler(recovery *adminrecovery.Manager, userStore userstore.Store, logger *zap.Logger) *AdminRecoveryHandler {
+	return &AdminRecoveryHandler{
+		recovery:  recovery,
+		userStore: userStore,
+		logger:    logger,
+	}
+}
+
+// Recover sets the password of the named account, reactivating it and
+// granting the admin role (no authentication required, the token is the
+// credential)
+func (h *AdminRecoveryHandler) Recover() http.HandlerFunc {
+	return Handle(http.MethodPost, func(w http.ResponseWriter, r *http.Request) error {
+		var req AdminRecoveryRequest
+		if err := DecodeJSON(r, &req); err != nil {
+			return err
+		}
+		if strings.TrimSpace(req.Token) == "" {
+			return apperror.BadRequest("Recovery token is required", nil, "token")
+		}
+		username := validation.NormalizeUsername(req.Username)
+		if username == "" {
+			return apperror.BadRequest("Username is required", nil, "username")
+		}
+		if err := validation.ValidatePassword(req.NewPassword); err != nil {
+			return apperror.BadRequest(err.Error(), nil, "newPassword")
+		}
+
+		var user *userstore.User
+		err := h.recovery.Redeem(strings.TrimSpace(req.Token), r.RemoteAddr, func() error {
+			var err error
+			user, err = h.reset(r.Context(), username, req.NewPassword)
+			return err
+		})
+		switch {
+		case errors.Is(err, adminrecovery.ErrNoToken), errors.Is(err, adminrecovery.ErrInvalidToken):
+			return apperror.Unauthorized(err.Error(), "token")
+		case err != nil:
+			return err
+		}
+		return WriteSuccess(w, AdminRecoveryResponse{UserID: user.ID, Username: user.Username})
+	})
+}
+
+// reset updates the password, active flag and role of username
+func (h *AdminRecoveryHandler) reset(ctx context.Context, username, password string) (*userstore.User, error) {
+	user, err := h.findUser(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	hashedPassword, err := auth.HashPassword(password)
+	if err != nil {
+		h.logger.Error("Failed to hash password", zap.Error(err))
+		return nil, apperror.InternalServerError("Failed to reset password")
+	}
+	if err := h.userStore.UpdatePassword(ctx, user.ID, hashedPassword); err != nil {
+		h.logger.Error("Failed to reset admin password", zap.String("userID", user.ID), zap.Error(err))
+		return nil, apperror.InternalServerError("Failed to reset password")
+	}
+	if !user.IsActive {
+		if err := h.userStore.SetActive(ctx, user.ID, true); err != nil {
+			h.logger.Error("Failed to reactivate admin", zap.String("userID", user.ID), zap.Error(err))
+			return nil, apperror.InternalServerError("Failed to reactivate account")
+		}
+	}
+	if user.Role != "admin" {
+		if err := h.userStore.UpdateRole(ctx, user.ID, "admin"); err != nil {
+			h.logger.Error("Failed to grant admin role", zap.String("userID", user.ID), zap.Error(err))
+			return nil, apperror.InternalServerError("Failed to grant admin role")
+		}
+	}
+	h.logger.Named("audit").Warn("Admin account recovered",
+		zap.String("component", "admin_recovery"),
+		zap.String("userID", user.ID),
+		zap.String("username", user.Username),
+		zap.String("previousRole", user.Role),
+		zap.Bool("reactivated", !user.IsActive))
+	return user, nil
+}
+
+// findUser looks up username including deactivated accounts, which
+// GetByUsername skips
+func (h *AdminRecoveryHandler) findUser(ctx context.Context, username string) (*userstore.User, error) {
+	users, _, err := h.userStore.List(ctx, 0, 0, username)
+	if err != nil {
+		h.logger.Error("Failed to look up user for admin recovery", zap.Error(err))
+		return nil, apperror.InternalServerError("Failed to look up user")
+	}
+	for _, user := range users {
+		if strings.EqualFold(user.Username, username) {
+			return user, nil
+		}
+	}
+	return nil, apperror.NotFound("User not found", "username")
+}
@@ -0,0 +1,74 @@
+package httphandler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/adminrecovery"
+	"github.com/cshum/imagor-studio/server/internal/userstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func postAdminRecovery(handler http.HandlerFunc, body AdminRecoveryRequest) *httptest.ResponseRecorder {
+	data, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/admin-recovery", bytes.NewReader(data))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestAdminRecoveryHandler_Recover(t *testing.T) {
+	logger := zap.NewNop()
+	recovery := adminrecovery.New(logger)
+	mockUserStore := new(MockUserStore)
+	handler := NewAdminRecoveryHandler(recovery, mockUserStore, logger).Recover()
+
+	// No token issued yet
+	rr := postAdminRecovery(handler, AdminRecoveryRequest{Token: "abc", Username: "admin", NewPassword: "newpassword123"})
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	token, err := recovery.Issue("test")
+	require.NoError(t, err)
+
+	rr = postAdminRecovery(handler, AdminRecoveryRequest{Token: token, Username: "admin", NewPassword: "short"})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	// A deactivated account demoted to user is restored as an active admin
+	locked := &userstore.User{ID: "user-1", Username: "admin", Role: "user", IsActive: false}
+	mockUserStore.On("List", mock.Anything, 0, 0, "admin").
+		Return([]*userstore.User{{ID: "user-2", Username: "admin2"}, locked}, 2, nil)
+	mockUserStore.On("UpdatePassword", mock.Anything, "user-1", mock.AnythingOfType("string")).Return(nil)
+	mockUserStore.On("SetActive", mock.Anything, "user-1", true).Return(nil)
+	mockUserStore.On("UpdateRole", mock.Anything, "user-1", "admin").Return(nil)
+
+	rr = postAdminRecovery(handler, AdminRecoveryRequest{Token: token, Username: "Admin", NewPassword: "newpassword123"})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var response AdminRecoveryResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Equal(t, "user-1", response.UserID)
+	mockUserStore.AssertExpectations(t)
+
+	// Tokens are single use
+	rr = postAdminRecovery(handler, AdminRecoveryRequest{Token: token, Username: "admin", NewPassword: "newpassword123"})
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestAdminRecoveryHandler_UnknownUserKeepsToken(t *testing.T) {
+	logger := zap.NewNop()
+	recovery := adminrecovery.New(logger)
+	mockUserStore := new(MockUserStore)
+	handler := NewAdminRecoveryHandler(recovery, mockUserStore, logger).Recover()
+	token, err := recovery.Issue("test")
+	require.NoError(t, err)
+
+	mockUserStore.On("List", mock.Anything, 0, 0, "nobody").Return([]*userstore.User{}, 0, nil)
+	rr := postAdminRecovery(handler, AdminRecoveryRequest{Token: token, Username: "nobody", NewPassword: "newpassword123"})
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.True(t, recovery.Active())
+}
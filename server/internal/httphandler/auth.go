@@ -6,18 +6,29 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
+	"net"
 	"net/http"
+	"path"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/cshum/imagor-studio/server/internal/attemptlimit"
 	"github.com/cshum/imagor-studio/server/internal/model"
+	"github.com/cshum/imagor-studio/server/internal/notify"
 	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/internal/sessionstore"
+	"github.com/cshum/imagor-studio/server/internal/sharestore"
 	"github.com/cshum/imagor-studio/server/internal/userstore"
+	"github.com/cshum/imagor-studio/server/internal/webhook"
 	"github.com/cshum/imagor-studio/server/pkg/apperror"
 	"github.com/cshum/imagor-studio/server/pkg/auth"
 	"github.com/cshum/imagor-studio/server/pkg/management"
 	"github.com/cshum/imagor-studio/server/pkg/org"
 	"github.com/cshum/imagor-studio/server/pkg/processing"
+	"github.com/cshum/imagor-studio/server/pkg/safepath"
 	"github.com/cshum/imagor-studio/server/pkg/signup"
 	"github.com/cshum/imagor-studio/server/pkg/space"
 	"github.com/cshum/imagor-studio/server/pkg/uuid"
@@ -41,6 +52,15 @@ type AuthHandler struct {
 	signupRuntime            signup.Runtime
 	previewTTL               time.Duration
 	processingOriginResolver space.ProcessingOriginResolver
+	shareStore               sharestore.Store
+	sessionStore             sessionstore.Store
+	tenantsEnabled           bool
+	webhooks                 *webhook.Dispatcher
+	notifier                 *notify.Notifier
+	// shareLinkAttempts and shareIPAttempts count the wrong passwords sent
+	// for each shared link and from each client address
+	shareLinkAttempts *attemptlimit.Limiter
+	shareIPAttempts   *attemptlimit.Limiter
 }
 
 type AuthHandlerConfig struct {
@@ -53,6 +73,17 @@ type AuthHandlerConfig struct {
 	SignupRuntime            signup.Runtime
 	PreviewTTL               time.Duration
 	ProcessingOriginResolver space.ProcessingOriginResolver
+	ShareStore               sharestore.Store
+	// SessionStore issues refresh tokens on login, nil keeps logins to a
+	// single access token refreshed by itself
+	SessionStore sessionstore.Store
+	// TenantsEnabled disables guest mode, whose sessions would not be
+	// confined to a tenant
+	TenantsEnabled bool
+	// Webhooks is notified of shared links opened, nil when unavailable
+	Webhooks *webhook.Dispatcher
+	// Notifier notifies admins of shared links opened, nil when unavailable
+	Notifier *notify.Notifier
 }
 
 type PreviewSessionRequest struct {
@@ -84,6 +115,20 @@ type GuestLoginRequest struct {
 	SpaceKey string `json:"spaceKey"`
 }
 
+// Wrong shared link passwords lock the link out for everyone after
+// shareLinkMaxAttempts, and the client address for every link after
+// shareIPMaxAttempts, until shareAttemptWindow after the first one
+const (
+	shareLinkMaxAttempts = 20
+	shareIPMaxAttempts   = 10
+	shareAttemptWindow   = 15 * time.Minute
+)
+
+type ShareLinkLoginRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password,omitempty"`
+}
+
 func NewAuthHandler(
 	tokenManager *auth.TokenManager,
 	userStore userstore.Store,
@@ -107,6 +152,13 @@ func NewAuthHandler(
 		signupRuntime:            cfg.SignupRuntime,
 		previewTTL:               cfg.PreviewTTL,
 		processingOriginResolver: cfg.ProcessingOriginResolver,
+		shareStore:               cfg.ShareStore,
+		sessionStore:             cfg.SessionStore,
+		tenantsEnabled:           cfg.TenantsEnabled,
+		webhooks:                 cfg.Webhooks,
+		notifier:                 cfg.Notifier,
+		shareLinkAttempts:        attemptlimit.New(shareLinkMaxAttempts, shareAttemptWindow),
+		shareIPAttempts:          attemptlimit.New(shareIPMaxAttempts, shareAttemptWindow),
 	}
 }
 
@@ -136,12 +188,18 @@ type LoginRequest struct {
 }
 
 type LoginResponse struct {
-	Token        string       `json:"token"`
-	ExpiresIn    int64        `json:"expiresIn"`
+	Token     string `json:"token"`
+	ExpiresIn int64  `json:"expiresIn"`
+	// RefreshToken is exchanged for the next token pair, it can be used once
+	RefreshToken string       `json:"refreshToken,omitempty"`
+	SessionID    string       `json:"sessionId,omitempty"`
 	User         UserResponse `json:"user"`
 	Mode         string       `json:"mode,omitempty"`
 	RedirectPath string       `json:"redirectPath,omitempty"`
 	PathPrefix   string       `json:"pathPrefix,omitempty"`
+	// UploadPath is the folder a guest may upload to, when guest uploads
+	// are enabled
+	UploadPath string `json:"uploadPath,omitempty"`
 }
 
 type UserResponse struct {
@@ -157,8 +215,11 @@ type FirstRunResponse struct {
 	MultiTenant bool  `json:"multiTenant"`
 }
 
+// RefreshTokenRequest carries the refresh token of a session. Token alone
+// refreshes an access token that has not expired yet.
 type RefreshTokenRequest struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken,omitempty"`
 }
 
 type StartPublicSignupRequest struct {
@@ -182,20 +243,28 @@ type ResendPublicSignupVerificationRequest struct {
 
 func (h *AuthHandler) CheckFirstRun() http.HandlerFunc {
 	return Handle(http.MethodGet, func(w http.ResponseWriter, r *http.Request) error {
-		_, totalCount, err := h.userStore.List(r.Context(), 0, 1, "")
+		isFirstRun, err := h.isFirstRun(r.Context())
 		if err != nil {
-			h.logger.Error("Failed to check existing users", zap.Error(err))
-			return apperror.InternalServerError("Failed to check system status")
+			return err
 		}
 
 		return WriteSuccess(w, FirstRunResponse{
-			IsFirstRun:  totalCount == 0,
+			IsFirstRun:  isFirstRun,
 			Timestamp:   time.Now().UnixMilli(),
 			MultiTenant: h.multiTenant,
 		})
 	})
 }
 
+func (h *AuthHandler) isFirstRun(ctx context.Context) (bool, error) {
+	_, totalCount, err := h.userStore.List(ctx, 0, 1, "")
+	if err != nil {
+		h.logger.Error("Failed to check existing users", zap.Error(err))
+		return false, apperror.InternalServerError("Failed to check system status")
+	}
+	return totalCount == 0, nil
+}
+
 func (h *AuthHandler) RegisterAdmin() http.HandlerFunc {
 	return Handle(http.MethodPost, func(w http.ResponseWriter, r *http.Request) error {
 		var req RegisterAdminRequest
@@ -221,7 +290,7 @@ func (h *AuthHandler) RegisterAdmin() http.HandlerFunc {
 			Password:    req.Password,
 		}
 
-		response, err := h.createUser(r.Context(), userReq, "admin")
+		response, err := h.createUser(sessionContext(r), userReq, "admin")
 		if err != nil {
 			return err
 		}
@@ -265,7 +334,7 @@ func (h *AuthHandler) Register() http.HandlerFunc {
 			return err
 		}
 
-		response, err := h.createUser(r.Context(), req, "user")
+		response, err := h.createUser(sessionContext(r), req, "user")
 		if err != nil {
 			return err
 		}
@@ -380,7 +449,7 @@ func (h *AuthHandler) VerifyPublicSignup() http.HandlerFunc {
 			orgID = h.resolvePrimaryOrgID(r.Context(), result.UserID)
 		}
 
-		response, err := h.generateAuthResponse(user.ID, user.DisplayName, user.Username, user.Role, orgID)
+		response, err := h.generateAuthResponse(sessionContext(r), user.ID, user.DisplayName, user.Username, user.Role, orgID, "")
 		if err != nil {
 			return err
 		}
@@ -529,7 +598,7 @@ func (h *AuthHandler) Login() http.HandlerFunc {
 			return err
 		}
 
-		response, err := h.generateAuthResponse(user.ID, user.DisplayName, user.Username, user.Role, orgID)
+		response, err := h.generateAuthResponse(sessionContext(r), user.ID, user.DisplayName, user.Username, user.Role, orgID, tenantIDOf(user.TenantID))
 		if err != nil {
 			return err
 		}
@@ -585,7 +654,18 @@ func (h *AuthHandler) GuestLogin() http.HandlerFunc {
 			}
 			response.Mode = auth.ExperienceModePublicPreview
 		} else {
-			token, err = h.tokenManager.GenerateToken(guestID, "guest", []string{"read"}, "")
+			claims := auth.Claims{
+				UserID: guestID,
+				Role:   "guest",
+				Scopes: []string{"read"},
+			}
+			if spaceKey == "" {
+				if folder := h.guestUploadFolder(r.Context()); folder != "" {
+					claims.WritePrefix = folder + "/guest-" + guestID
+					response.UploadPath = claims.WritePrefix
+				}
+			}
+			token, err = h.tokenManager.GenerateTokenWithClaims(claims, 0)
 			if err != nil {
 				h.logger.Error("Failed to generate guest token", zap.Error(err))
 				return apperror.InternalServerError("Failed to generate token")
@@ -599,7 +679,123 @@ func (h *AuthHandler) GuestLogin() http.HandlerFunc {
 	})
 }
 
+// ShareLinkLogin redeems a shared link token for a read-only guest session
+// rooted at the shared path. The session never outlives the link.
+// Password protected links also require the link password.
+func (h *AuthHandler) ShareLinkLogin() http.HandlerFunc {
+	return Handle(http.MethodPost, func(w http.ResponseWriter, r *http.Request) error {
+		if h.shareStore == nil {
+			return apperror.Forbidden("Shared links are not available in this deployment.")
+		}
+		var req ShareLinkLoginRequest
+		if err := DecodeJSON(r, &req); err != nil {
+			return apperror.BadRequest("Invalid request body", map[string]interface{}{"error": err.Error()})
+		}
+		token := strings.TrimSpace(req.Token)
+		if token == "" {
+			return apperror.BadRequest("Share token is required", nil, "token")
+		}
+
+		link, err := h.shareStore.GetByToken(r.Context(), token)
+		if err != nil {
+			h.logger.Error("Failed to get share link", zap.Error(err))
+			return apperror.InternalServerError("Failed to open shared link")
+		}
+		if link == nil {
+			return apperror.NotFound("Shared link not found or expired")
+		}
+		if link.PasswordProtected() {
+			if req.Password == "" {
+				return apperror.Unauthorized("PASSWORD_REQUIRED", "password")
+			}
+			ip := r.RemoteAddr
+			if host, _, err := net.SplitHostPort(ip); err == nil {
+				ip = host
+			}
+			retryAfter := max(h.shareLinkAttempts.Check(link.ID), h.shareIPAttempts.Check(ip))
+			if retryAfter > 0 {
+				seconds := int(math.Ceil(retryAfter.Seconds()))
+				w.Header().Set("Retry-After", strconv.Itoa(seconds))
+				return apperror.TooManyRequests(
+					fmt.Sprintf("Too many wrong passwords, retry in %d seconds", seconds),
+					map[string]interface{}{"reason": "share_password_attempts", "retryAfterSeconds": seconds},
+					"password",
+				)
+			}
+			if err := auth.CheckPassword(link.PasswordHash, req.Password); err != nil {
+				h.shareLinkAttempts.Fail(link.ID)
+				h.shareIPAttempts.Fail(ip)
+				h.logger.Warn("Wrong shared link password", zap.String("shareID", link.ID), zap.String("clientIP", ip))
+				return apperror.InvalidCredentials("INVALID_PASSWORD", "password")
+			}
+			h.shareIPAttempts.Reset(ip)
+		}
+
+		ttl := h.tokenManager.TokenDuration()
+		if remaining := time.Until(link.ExpiresAt); remaining < ttl {
+			ttl = remaining
+		}
+		scopes := []string{"read"}
+		if link.AllowDownload {
+			scopes = append(scopes, "download")
+		}
+		guestID := uuid.GenerateUUID()
+		pathPrefix := "/" + link.Path
+		sessionToken, err := h.tokenManager.GenerateTokenWithClaims(auth.Claims{
+			UserID:     guestID,
+			Role:       "guest",
+			Scopes:     scopes,
+			PathPrefix: pathPrefix,
+			Kind:       auth.ShareLinkTokenKind,
+			ShareID:    link.ID,
+		}, ttl)
+		if err != nil {
+			h.logger.Error("Failed to generate share link token", zap.Error(err), zap.String("shareID", link.ID))
+			return apperror.InternalServerError("Failed to generate token")
+		}
+
+		h.logger.Debug("Share link opened", zap.String("shareID", link.ID), zap.String("path", link.Path))
+		if h.webhooks != nil {
+			h.webhooks.Notify(webhook.EventShareAccessed, webhook.ShareData{ShareID: link.ID, Path: link.Path})
+		}
+		h.notifier.Notify(notify.Message{
+			Event: notify.EventShareAccessed,
+			Title: "Shared link opened",
+			Body:  fmt.Sprintf("The shared link %s to /%s was opened.", link.ID, link.Path),
+		})
+		return WriteSuccess(w, LoginResponse{
+			Token:     sessionToken,
+			ExpiresIn: int64(ttl.Seconds()),
+			User: UserResponse{
+				ID:          guestID,
+				DisplayName: "guest",
+				Username:    "guest",
+				Role:        "guest",
+			},
+			PathPrefix: pathPrefix,
+		})
+	})
+}
+
+// guestUploadFolder returns the folder holding the upload folders of guests,
+// empty when guests cannot upload. Lookup failures disable guest uploads.
+func (h *AuthHandler) guestUploadFolder(ctx context.Context) string {
+	entry, err := h.registryStore.Get(ctx, registrystore.SystemOwnerID, "config.guest_upload_folder")
+	if err != nil {
+		h.logger.Warn("Failed to check guest upload folder setting", zap.Error(err))
+		return ""
+	}
+	if entry == nil {
+		return ""
+	}
+	// Cleaning below the root keeps the folder inside the storage
+	return strings.Trim(path.Clean("/"+strings.TrimSpace(entry.Value)), "/")
+}
+
 func (h *AuthHandler) isGuestLoginAllowed(ctx context.Context, spaceKey string) (bool, error) {
+	if h.tenantsEnabled {
+		return false, nil
+	}
 	guestModeMetadata, err := h.registryStore.Get(ctx, registrystore.SystemOwnerID, "config.allow_guest_mode")
 	if err != nil {
 		h.logger.Error("Failed to check guest mode setting", zap.Error(err))
@@ -650,6 +846,9 @@ func (h *AuthHandler) RefreshToken() http.HandlerFunc {
 		if err := DecodeJSON(r, &req); err != nil {
 			return err
 		}
+		if req.RefreshToken != "" {
+			return h.rotateSession(w, r, req.RefreshToken)
+		}
 
 		// Validate existing token
 		claims, err := h.tokenManager.ValidateToken(req.Token)
@@ -657,6 +856,21 @@ func (h *AuthHandler) RefreshToken() http.HandlerFunc {
 			return apperror.Unauthorized("Invalid token")
 		}
 
+		// Tokens of revoked sessions cannot be refreshed
+		if claims.SessionID != "" {
+			if h.sessionStore == nil {
+				return apperror.Unauthorized("Invalid token")
+			}
+			active, err := h.sessionStore.Active(r.Context(), claims.SessionID)
+			if err != nil {
+				h.logger.Error("Failed to check session for token refresh", zap.Error(err))
+				return apperror.InternalServerError("Failed to refresh token")
+			}
+			if !active {
+				return apperror.Unauthorized("Session has been revoked")
+			}
+		}
+
 		// Verify user still exists and is active
 		user, err := h.userStore.GetByID(r.Context(), claims.UserID)
 		if err != nil {
@@ -669,7 +883,7 @@ func (h *AuthHandler) RefreshToken() http.HandlerFunc {
 		}
 
 		currentOrgID := h.resolvePrimaryOrgID(r.Context(), claims.UserID)
-		response, err := h.generateAuthResponse(user.ID, user.DisplayName, user.Username, user.Role, currentOrgID)
+		response, err := h.buildAuthResponse(user.ID, user.DisplayName, user.Username, user.Role, currentOrgID, tenantIDOf(user.TenantID), claims.SessionID)
 		if err != nil {
 			h.logger.Error("Failed to refresh token", zap.Error(err))
 			return apperror.InternalServerError("Failed to refresh token")
@@ -679,6 +893,43 @@ func (h *AuthHandler) RefreshToken() http.HandlerFunc {
 	})
 }
 
+// rotateSession exchanges a refresh token for a new token pair of its session
+func (h *AuthHandler) rotateSession(w http.ResponseWriter, r *http.Request, refreshToken string) error {
+	if h.sessionStore == nil {
+		return apperror.Unauthorized("Refresh tokens are not available")
+	}
+	session, nextRefreshToken, err := h.sessionStore.Rotate(r.Context(), refreshToken, sessionstore.ClientFromRequest(r))
+	if errors.Is(err, sessionstore.ErrUnauthorized) {
+		return apperror.Unauthorized("Invalid or expired refresh token")
+	}
+	if err != nil {
+		h.logger.Error("Failed to rotate refresh token", zap.Error(err))
+		return apperror.InternalServerError("Failed to refresh token")
+	}
+
+	user, err := h.userStore.GetByID(r.Context(), session.UserID)
+	if err != nil {
+		h.logger.Error("Failed to get user for token refresh", zap.Error(err))
+		return apperror.InternalServerError("Failed to refresh token")
+	}
+	if user == nil {
+		if err := h.sessionStore.Revoke(r.Context(), session.ID); err != nil && !errors.Is(err, sessionstore.ErrNotFound) {
+			h.logger.Warn("Failed to revoke session of inactive user", zap.String("sessionID", session.ID), zap.Error(err))
+		}
+		return apperror.Unauthorized("User not found or inactive")
+	}
+
+	currentOrgID := h.resolvePrimaryOrgID(r.Context(), session.UserID)
+	response, err := h.buildAuthResponse(user.ID, user.DisplayName, user.Username, user.Role, currentOrgID, tenantIDOf(user.TenantID), session.ID)
+	if err != nil {
+		h.logger.Error("Failed to refresh token", zap.Error(err))
+		return apperror.InternalServerError("Failed to refresh token")
+	}
+	response.RefreshToken = nextRefreshToken
+
+	return WriteSuccess(w, response)
+}
+
 func (h *AuthHandler) EmbeddedGuestLogin() http.HandlerFunc {
 	return Handle(http.MethodPost, func(w http.ResponseWriter, r *http.Request) error {
 		// Check if embedded mode is enabled
@@ -699,29 +950,37 @@ func (h *AuthHandler) EmbeddedGuestLogin() http.HandlerFunc {
 			return apperror.Unauthorized("Invalid or expired JWT token")
 		}
 
-		pathPrefix := claims.PathPrefix
-
-		// Validate path prefix format if provided
-		if pathPrefix != "" {
-			// Normalize path prefix - ensure it starts with / and doesn't end with / (unless it's root)
-			if !strings.HasPrefix(pathPrefix, "/") {
-				pathPrefix = "/" + pathPrefix
-			}
-			if len(pathPrefix) > 1 && strings.HasSuffix(pathPrefix, "/") {
-				pathPrefix = strings.TrimSuffix(pathPrefix, "/")
-			}
-
-			// Basic security check - prevent path traversal
-			if strings.Contains(pathPrefix, "..") {
-				return apperror.BadRequest("Invalid path prefix: path traversal not allowed", nil)
+		pathPrefix, err := normalizeEmbeddedPathPrefix(claims.PathPrefix)
+		if err != nil {
+			return apperror.BadRequest(err.Error(), nil)
+		}
+		if claims.Watermark != nil {
+			if err := claims.Watermark.Validate(); err != nil {
+				return apperror.BadRequest(err.Error(), nil)
 			}
 		}
 
-		// Generate embedded guest user ID
+		// Viewers of tokens minted by /api/embed/token keep the ID and scopes
+		// the host gave them
 		embeddedGuestID := uuid.GenerateUUID()
+		scopes := []string{"read", "edit"}
+		if claims.Kind == auth.EmbedTokenKind {
+			if claims.UserID != "" {
+				embeddedGuestID = claims.UserID
+			}
+			scopes = embeddedGuestScopes(claims.Scopes)
+		}
 
-		// Generate session token for embedded guest with editor permissions and path prefix
-		sessionToken, err := h.tokenManager.GenerateTokenWithOptions(embeddedGuestID, "guest", []string{"read", "edit"}, true, pathPrefix)
+		// Generate session token for embedded guest with editor permissions,
+		// path prefix and watermark
+		sessionToken, err := h.tokenManager.GenerateTokenWithClaims(auth.Claims{
+			UserID:     embeddedGuestID,
+			Role:       "guest",
+			Scopes:     scopes,
+			IsEmbedded: true,
+			PathPrefix: pathPrefix,
+			Watermark:  claims.Watermark,
+		}, 0)
 		if err != nil {
 			h.logger.Error("Failed to generate embedded guest token", zap.Error(err))
 			return apperror.InternalServerError("Failed to generate session token")
@@ -748,6 +1007,39 @@ func (h *AuthHandler) EmbeddedGuestLogin() http.HandlerFunc {
 	})
 }
 
+// embeddedGuestScopes returns the scopes of embedded guests: read and edit,
+// or those of them requested
+func embeddedGuestScopes(requested []string) []string {
+	if len(requested) == 0 {
+		return []string{"read", "edit"}
+	}
+	var scopes []string
+	for _, scope := range []string{"read", "edit"} {
+		if slices.Contains(requested, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// normalizeEmbeddedPathPrefix returns the path prefix of an embedded guest
+// starting with / and without trailing /, empty when unrestricted
+func normalizeEmbeddedPathPrefix(pathPrefix string) (string, error) {
+	if pathPrefix == "" {
+		return "", nil
+	}
+	if !strings.HasPrefix(pathPrefix, "/") {
+		pathPrefix = "/" + pathPrefix
+	}
+	if len(pathPrefix) > 1 && strings.HasSuffix(pathPrefix, "/") {
+		pathPrefix = strings.TrimSuffix(pathPrefix, "/")
+	}
+	if err := safepath.Validate(pathPrefix); err != nil {
+		return "", fmt.Errorf("Invalid path prefix: %w", err)
+	}
+	return pathPrefix, nil
+}
+
 func (h *AuthHandler) PublicPreviewSession() http.HandlerFunc {
 	return Handle(http.MethodPost, func(w http.ResponseWriter, r *http.Request) error {
 		if !h.publicPreviewEnabled {
@@ -1145,7 +1437,7 @@ func (h *AuthHandler) provisionWorkspaceOwner(ctx context.Context, user *usersto
 		orgID = org.ID
 	}
 
-	return h.generateAuthResponse(user.ID, user.DisplayName, user.Username, user.Role, orgID)
+	return h.generateAuthResponse(ctx, user.ID, user.DisplayName, user.Username, user.Role, orgID, "")
 }
 
 func (h *AuthHandler) provisionInvitedSignup(ctx context.Context, user *userstore.User, email string, invitation *space.Invitation) (*LoginResponse, error) {
@@ -1198,7 +1490,7 @@ func (h *AuthHandler) provisionInvitedSignup(ctx context.Context, user *userstor
 		return nil, apperror.InternalServerError("Failed to complete sign-up")
 	}
 
-	response, err := h.generateAuthResponse(user.ID, user.DisplayName, user.Username, user.Role, orgID)
+	response, err := h.generateAuthResponse(ctx, user.ID, user.DisplayName, user.Username, user.Role, orgID, "")
 	if err != nil {
 		return nil, err
 	}
@@ -1207,12 +1499,12 @@ func (h *AuthHandler) provisionInvitedSignup(ctx context.Context, user *userstor
 	return response, nil
 }
 
-func (h *AuthHandler) provisionWorkspaceMember(_ context.Context, user *userstore.User, orgID string) (*LoginResponse, error) {
+func (h *AuthHandler) provisionWorkspaceMember(ctx context.Context, user *userstore.User, orgID string) (*LoginResponse, error) {
 	if user == nil || strings.TrimSpace(orgID) == "" {
 		return nil, apperror.InternalServerError("Failed to initialize organization")
 	}
 
-	return h.generateAuthResponse(user.ID, user.DisplayName, user.Username, user.Role, orgID)
+	return h.generateAuthResponse(ctx, user.ID, user.DisplayName, user.Username, user.Role, orgID, "")
 }
 
 func (h *AuthHandler) resolveLoginOrgID(ctx context.Context, user *model.User, inviteToken string) (string, string, error) {
@@ -1349,7 +1641,40 @@ func (h *AuthHandler) resolvePrimaryOrgID(ctx context.Context, userID string) st
 	return org.ID
 }
 
-func (h *AuthHandler) generateAuthResponse(userID, displayName, username, role, orgID string) (*LoginResponse, error) {
+// tenantIDOf returns the tenant ID of a user record, empty without a tenant
+func tenantIDOf(tenantID *string) string {
+	if tenantID == nil {
+		return ""
+	}
+	return *tenantID
+}
+
+// sessionContext returns the context of r carrying the client of sessions
+// started by the request
+func sessionContext(r *http.Request) context.Context {
+	return sessionstore.WithClient(r.Context(), sessionstore.ClientFromRequest(r))
+}
+
+// generateAuthResponse signs the user in, starting a session with a refresh
+// token when sessions are stored
+func (h *AuthHandler) generateAuthResponse(ctx context.Context, userID, displayName, username, role, orgID, tenantID string) (*LoginResponse, error) {
+	if h.sessionStore == nil {
+		return h.buildAuthResponse(userID, displayName, username, role, orgID, tenantID, "")
+	}
+	session, refreshToken, err := h.sessionStore.Create(ctx, userID, orgID, sessionstore.ClientFromContext(ctx))
+	if err != nil {
+		h.logger.Error("Failed to start session", zap.Error(err))
+		return nil, apperror.InternalServerError("Failed to start session")
+	}
+	response, err := h.buildAuthResponse(userID, displayName, username, role, orgID, tenantID, session.ID)
+	if err != nil {
+		return nil, err
+	}
+	response.RefreshToken = refreshToken
+	return response, nil
+}
+
+func (h *AuthHandler) buildAuthResponse(userID, displayName, username, role, orgID, tenantID, sessionID string) (*LoginResponse, error) {
 	// Determine scopes based on role
 	scopes := []string{"read", "write"}
 	if role == "admin" {
@@ -1359,9 +1684,19 @@ func (h *AuthHandler) generateAuthResponse(userID, displayName, username, role,
 	// Use org-aware token when an org is known (multi-tenant mode).
 	var token string
 	var err error
-	if orgID != "" {
+	switch {
+	case sessionID != "" || tenantID != "":
+		token, err = h.tokenManager.GenerateTokenWithClaims(auth.Claims{
+			UserID:    userID,
+			OrgID:     orgID,
+			TenantID:  tenantID,
+			Role:      role,
+			Scopes:    scopes,
+			SessionID: sessionID,
+		}, 0)
+	case orgID != "":
 		token, err = h.tokenManager.GenerateTokenForUser(userID, role, scopes, orgID)
-	} else {
+	default:
 		token, err = h.tokenManager.GenerateToken(userID, role, scopes, "")
 	}
 	if err != nil {
@@ -1372,6 +1707,7 @@ func (h *AuthHandler) generateAuthResponse(userID, displayName, username, role,
 	return &LoginResponse{
 		Token:     token,
 		ExpiresIn: h.tokenManager.TokenDuration().Milliseconds() / 1000,
+		SessionID: sessionID,
 		User: UserResponse{
 			ID:          userID,
 			DisplayName: displayName,
@@ -3,6 +3,7 @@ package httphandler
 import (
 	"bytes"
 	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -12,7 +13,10 @@ import (
 	"time"
 
 	"github.com/cshum/imagor-studio/server/internal/model"
+	"github.com/cshum/imagor-studio/server/internal/pagination"
 	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/internal/sessionstore"
+	"github.com/cshum/imagor-studio/server/internal/sharestore"
 	"github.com/cshum/imagor-studio/server/internal/userstore"
 	"github.com/cshum/imagor-studio/server/pkg/apperror"
 	"github.com/cshum/imagor-studio/server/pkg/auth"
@@ -24,6 +28,9 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	"github.com/uptrace/bun/driver/sqliteshim"
 	"github.com/vektah/gqlparser/v2/gqlerror"
 	"go.uber.org/zap"
 )
@@ -434,6 +441,40 @@ func (m *MockUserStore) UpdateRole(ctx context.Context, id string, role string)
 	return args.Error(0)
 }
 
+func (m *MockUserStore) UpdateHomePath(ctx context.Context, id string, homePath *string) error {
+	args := m.Called(ctx, id, homePath)
+	return args.Error(0)
+}
+
+func (m *MockUserStore) CreateInTenant(ctx context.Context, tenantID, displayName, username, hashedPassword, role string) (*userstore.User, error) {
+	args := m.Called(ctx, tenantID, displayName, username, hashedPassword, role)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*userstore.User), args.Error(1)
+}
+
+func (m *MockUserStore) ListByTenant(ctx context.Context, tenantID string, offset, limit int, search string) ([]*userstore.User, int, error) {
+	args := m.Called(ctx, tenantID, offset, limit, search)
+	if args.Get(0) == nil {
+		return nil, args.Get(1).(int), args.Error(2)
+	}
+	return args.Get(0).([]*userstore.User), args.Get(1).(int), args.Error(2)
+}
+
+func (m *MockUserStore) ListAfter(ctx context.Context, tenantID string, after *pagination.Key, limit int, search string) ([]*userstore.User, int, bool, error) {
+	args := m.Called(ctx, tenantID, after, limit, search)
+	if args.Get(0) == nil {
+		return nil, args.Get(1).(int), args.Bool(2), args.Error(3)
+	}
+	return args.Get(0).([]*userstore.User), args.Get(1).(int), args.Bool(2), args.Error(3)
+}
+
+func (m *MockUserStore) UpdateTenant(ctx context.Context, id string, tenantID *string) error {
+	args := m.Called(ctx, id, tenantID)
+	return args.Error(0)
+}
+
 type MockRegistryStore struct {
 	mock.Mock
 }
@@ -1416,6 +1457,100 @@ func TestRefreshToken_RecomputesCurrentOrgID(t *testing.T) {
 	mockUserStore.AssertExpectations(t)
 }
 
+func newTestSessionStore(t *testing.T) sessionstore.Store {
+	t.Helper()
+	sqldb, err := sql.Open(sqliteshim.ShimName, ":memory:")
+	require.NoError(t, err)
+	sqldb.SetMaxOpenConns(1)
+	db := bun.NewDB(sqldb, sqlitedialect.New())
+	t.Cleanup(func() { _ = db.Close() })
+	_, err = db.NewCreateTable().Model((*model.Session)(nil)).Exec(context.Background())
+	require.NoError(t, err)
+	return sessionstore.New(db, zap.NewNop(), time.Hour)
+}
+
+func TestGuestLogin_UploadFolder(t *testing.T) {
+	tokenManager := auth.NewTokenManager("test-secret", time.Hour)
+	mockRegistryStore := new(MockRegistryStore)
+	mockRegistryStore.On("Get", mock.Anything, registrystore.SystemOwnerID, "config.allow_guest_mode").Return(&registrystore.Registry{
+		Key: "config.allow_guest_mode", Value: "true",
+	}, nil)
+	mockRegistryStore.On("Get", mock.Anything, registrystore.SystemOwnerID, "config.guest_upload_folder").Return(&registrystore.Registry{
+		Key: "config.guest_upload_folder", Value: "/inbox/../party/",
+	}, nil)
+	handler := NewAuthHandler(tokenManager, new(MockUserStore), nil, mockRegistryStore, zap.NewNop(), AuthHandlerConfig{})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/guest", bytes.NewBuffer(nil))
+	rr := httptest.NewRecorder()
+	handler.GuestLogin()(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var loginResp LoginResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &loginResp))
+	assert.Equal(t, "party/guest-"+loginResp.User.ID, loginResp.UploadPath)
+	claims, err := tokenManager.ValidateToken(loginResp.Token)
+	require.NoError(t, err)
+	assert.Equal(t, loginResp.UploadPath, claims.WritePrefix)
+	assert.NotContains(t, claims.Scopes, "write")
+}
+
+func TestRefreshToken_RotatesSession(t *testing.T) {
+	tokenManager := auth.NewTokenManager("test-secret", time.Hour)
+	mockUserStore := new(MockUserStore)
+	sessions := newTestSessionStore(t)
+	handler := NewAuthHandler(tokenManager, mockUserStore, nil, nil, zap.NewNop(), AuthHandlerConfig{SessionStore: sessions})
+
+	hashedPassword, err := auth.HashPassword("password123")
+	require.NoError(t, err)
+	mockUserStore.On("GetByUsername", mock.Anything, "testuser").Return(&model.User{
+		ID: "user-123", DisplayName: "testuser", Username: "testuser", HashedPassword: hashedPassword, Role: "user", IsActive: true,
+	}, nil)
+	mockUserStore.On("UpdateLastLogin", mock.Anything, "user-123").Return(nil)
+	mockUserStore.On("GetByID", mock.Anything, "user-123").Return(&userstore.User{
+		ID: "user-123", DisplayName: "testuser", Username: "testuser", Role: "user", IsActive: true,
+	}, nil)
+
+	post := func(handle http.HandlerFunc, path string, body interface{}) (int, LoginResponse) {
+		data, err := json.Marshal(body)
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(data))
+		req.Header.Set("User-Agent", "Firefox")
+		rr := httptest.NewRecorder()
+		handle(rr, req)
+		var resp LoginResponse
+		if rr.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+		}
+		return rr.Code, resp
+	}
+
+	code, login := post(handler.Login(), "/api/auth/login", LoginRequest{Username: "testuser", Password: "password123"})
+	require.Equal(t, http.StatusOK, code)
+	require.NotEmpty(t, login.RefreshToken)
+	require.NotEmpty(t, login.SessionID)
+	claims, err := tokenManager.ValidateToken(login.Token)
+	require.NoError(t, err)
+	assert.Equal(t, login.SessionID, claims.SessionID)
+	listed, err := sessions.List(context.Background(), "user-123")
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	assert.Equal(t, "Firefox", listed[0].UserAgent)
+
+	code, refreshed := post(handler.RefreshToken(), "/api/auth/refresh", RefreshTokenRequest{RefreshToken: login.RefreshToken})
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, login.SessionID, refreshed.SessionID)
+	assert.NotEqual(t, login.RefreshToken, refreshed.RefreshToken)
+
+	// A rotated refresh token is spent, replaying it revokes the session
+	code, _ = post(handler.RefreshToken(), "/api/auth/refresh", RefreshTokenRequest{RefreshToken: login.RefreshToken})
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _ = post(handler.RefreshToken(), "/api/auth/refresh", RefreshTokenRequest{RefreshToken: refreshed.RefreshToken})
+	assert.Equal(t, http.StatusUnauthorized, code)
+	// Access tokens of the revoked session cannot be refreshed either
+	code, _ = post(handler.RefreshToken(), "/api/auth/refresh", RefreshTokenRequest{Token: refreshed.Token})
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
+
 func TestGuestLogin(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
 	tokenManager := auth.NewTokenManager("test-secret", time.Hour)
@@ -1439,6 +1574,7 @@ func TestGuestLogin(t *testing.T) {
 					Key:   "config.allow_guest_mode",
 					Value: "true",
 				}, nil)
+				mockRegistryStore.On("Get", mock.Anything, registrystore.SystemOwnerID, "config.guest_upload_folder").Return(nil, nil)
 			},
 			expectedStatus: http.StatusOK,
 			expectError:    false,
@@ -2793,6 +2929,162 @@ func TestRegisterAdmin(t *testing.T) {
 	}
 }
 
+type stubShareStore map[string]*sharestore.ShareLink
+
+func (s stubShareStore) Create(ctx context.Context, path, createdBy string, allowDownload bool, expiresAt time.Time, passwordHash string) (*sharestore.ShareLink, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (s stubShareStore) GetByToken(ctx context.Context, token string) (*sharestore.ShareLink, error) {
+	return s[token], nil
+}
+
+func TestShareLinkLogin(t *testing.T) {
+	logger := zap.NewNop()
+	tokenManager := auth.NewTokenManager("test-secret", time.Hour)
+	shares := stubShareStore{
+		"album-token": {ID: "share-1", Token: "album-token", Path: "albums/summer", ExpiresAt: time.Now().Add(10 * time.Minute)},
+		"file-token":  {ID: "share-2", Token: "file-token", Path: "albums/summer/a.jpg", AllowDownload: true, ExpiresAt: time.Now().Add(48 * time.Hour)},
+	}
+	handler := NewAuthHandler(tokenManager, new(MockUserStore), nil, new(MockRegistryStore), logger, AuthHandlerConfig{ShareStore: shares})
+
+	redeem := func(h *AuthHandler, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/share", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		h.ShareLinkLogin()(rr, req)
+		return rr
+	}
+
+	rr := redeem(handler, `{"token":"album-token"}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var loginResp LoginResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &loginResp))
+	assert.Equal(t, "guest", loginResp.User.Role)
+	assert.Equal(t, "/albums/summer", loginResp.PathPrefix)
+	// The session ends with the link
+	assert.LessOrEqual(t, loginResp.ExpiresIn, int64(600))
+	claims, err := tokenManager.ValidateToken(loginResp.Token)
+	require.NoError(t, err)
+	assert.Equal(t, auth.ShareLinkTokenKind, claims.Kind)
+	assert.Equal(t, "share-1", claims.ShareID)
+	assert.Equal(t, "/albums/summer", claims.PathPrefix)
+	assert.Equal(t, []string{"read"}, claims.Scopes)
+
+	rr = redeem(handler, `{"token":"file-token"}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &loginResp))
+	assert.Equal(t, int64(3600), loginResp.ExpiresIn)
+	claims, err = tokenManager.ValidateToken(loginResp.Token)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"read", "download"}, claims.Scopes)
+
+	rr = redeem(handler, `{"token":"unknown"}`)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	rr = redeem(handler, `{}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	disabled := NewAuthHandler(tokenManager, new(MockUserStore), nil, new(MockRegistryStore), logger, AuthHandlerConfig{})
+	rr = redeem(disabled, `{"token":"album-token"}`)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+}
+
+func TestShareLinkLogin_Password(t *testing.T) {
+	tokenManager := auth.NewTokenManager("test-secret", time.Hour)
+	passwordHash, err := auth.HashPassword("summer-2024")
+	require.NoError(t, err)
+	shares := stubShareStore{
+		"private-token": {ID: "share-1", Token: "private-token", Path: "albums/private", PasswordHash: passwordHash, ExpiresAt: time.Now().Add(time.Hour)},
+	}
+	handler := NewAuthHandler(tokenManager, new(MockUserStore), nil, new(MockRegistryStore), zap.NewNop(), AuthHandlerConfig{ShareStore: shares})
+
+	tests := []struct {
+		name           string
+		requestBody    string
+		expectedStatus int
+		errorCode      string
+	}{
+		{
+			name:           "Password missing",
+			requestBody:    `{"token":"private-token"}`,
+			expectedStatus: http.StatusUnauthorized,
+			errorCode:      "UNAUTHORIZED",
+		},
+		{
+			name:           "Password wrong",
+			requestBody:    `{"token":"private-token","password":"winter-2024"}`,
+			expectedStatus: http.StatusUnauthorized,
+			errorCode:      "INVALID_CREDENTIALS",
+		},
+		{
+			name:           "Password correct",
+			requestBody:    `{"token":"private-token","password":"summer-2024"}`,
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/auth/share", bytes.NewBufferString(tt.requestBody))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+			handler.ShareLinkLogin()(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.errorCode != "" {
+				var errResp apperror.ErrorResponse
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &errResp))
+				assert.Equal(t, tt.errorCode, errResp.Code)
+				return
+			}
+			var loginResp LoginResponse
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &loginResp))
+			claims, err := tokenManager.ValidateToken(loginResp.Token)
+			require.NoError(t, err)
+			assert.Equal(t, auth.ShareLinkTokenKind, claims.Kind)
+			assert.Equal(t, "/albums/private", claims.PathPrefix)
+			assert.Equal(t, []string{"read"}, claims.Scopes)
+		})
+	}
+}
+
+func TestShareLinkLogin_PasswordAttempts(t *testing.T) {
+	tokenManager := auth.NewTokenManager("test-secret", time.Hour)
+	passwordHash, err := auth.HashPassword("summer-2024")
+	require.NoError(t, err)
+	shares := stubShareStore{
+		"private-token": {ID: "share-1", Token: "private-token", Path: "albums/private", PasswordHash: passwordHash, ExpiresAt: time.Now().Add(time.Hour)},
+	}
+	handler := NewAuthHandler(tokenManager, new(MockUserStore), nil, new(MockRegistryStore), zap.NewNop(), AuthHandlerConfig{ShareStore: shares})
+
+	redeem := func(remoteAddr, password string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/share", bytes.NewBufferString(`{"token":"private-token","password":"`+password+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = remoteAddr
+		rr := httptest.NewRecorder()
+		handler.ShareLinkLogin()(rr, req)
+		return rr
+	}
+
+	// A client is locked out after too many wrong passwords, even the right one
+	for i := 0; i < shareIPMaxAttempts; i++ {
+		require.Equal(t, http.StatusUnauthorized, redeem("192.0.2.1:1234", "wrong").Code)
+	}
+	rr := redeem("192.0.2.1:5678", "summer-2024")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.NotEmpty(t, rr.Header().Get("Retry-After"))
+	var errResp apperror.ErrorResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &errResp))
+	assert.Equal(t, "TOO_MANY_REQUESTS", errResp.Code)
+	assert.Equal(t, http.StatusOK, redeem("192.0.2.2:1234", "summer-2024").Code)
+
+	// Guessing from many addresses locks the link out
+	for i := shareIPMaxAttempts; i < shareLinkMaxAttempts; i++ {
+		require.Equal(t, http.StatusUnauthorized, redeem(fmt.Sprintf("198.51.100.%d:1234", i), "wrong").Code)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, redeem("192.0.2.3:1234", "summer-2024").Code)
+}
+
 func TestEmbeddedGuestLogin(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
 	tokenManager := auth.NewTokenManager("test-secret", time.Hour)
@@ -0,0 +1,142 @@
+package httphandler
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// BootstrapConfig holds the static part of the bootstrap response, fixed
+// for the lifetime of the server
+type BootstrapConfig struct {
+	// AppURL is the public frontend URL; its path is reported as the base path
+	AppURL        string
+	AppHomeTitle  string
+	AuthProviders []string
+	ServerVersion string
+	APIVersion    int
+	APICompatMode bool
+	// Capabilities lists optional features enabled on this server,
+	// e.g. "hls" or "bulk_download"
+	Capabilities []string
+}
+
+// BootstrapResponse is everything the SPA needs before the login screen renders
+type BootstrapResponse struct {
+	BasePath     string             `json:"basePath"`
+	Branding     BootstrapBranding  `json:"branding"`
+	Auth         BootstrapAuthModes `json:"auth"`
+	API          BootstrapAPI       `json:"api"`
+	Capabilities []string           `json:"capabilities"`
+}
+
+type BootstrapBranding struct {
+	IsLicensed     bool    `json:"isLicensed"`
+	AppTitle       *string `json:"appTitle,omitempty"`
+	AppURL         *string `json:"appUrl,omitempty"`
+	HomeTitle      *string `json:"homeTitle,omitempty"`
+	SupportMessage *string `json:"supportMessage,omitempty"`
+}
+
+type BootstrapAuthModes struct {
+	IsFirstRun        bool     `json:"isFirstRun"`
+	MultiTenant       bool     `json:"multiTenant"`
+	EmbeddedMode      bool     `json:"embeddedMode"`
+	GuestMode         bool     `json:"guestMode"`
+	Registration      bool     `json:"registration"`
+	EmailVerification bool     `json:"emailVerification"`
+	PublicPreview     bool     `json:"publicPreview"`
+	Providers         []string `json:"providers"`
+}
+
+type BootstrapAPI struct {
+	ServerVersion string `json:"serverVersion"`
+	Version       int    `json:"version"`
+	CompatMode    bool   `json:"compatMode"`
+}
+
+// BootstrapHandler serves /api/bootstrap, replacing the first-run, license
+// status and auth provider requests the SPA otherwise makes on load
+type BootstrapHandler struct {
+	auth    *AuthHandler
+	license *LicenseHandler
+	cfg     BootstrapConfig
+}
+
+func NewBootstrapHandler(authHandler *AuthHandler, licenseHandler *LicenseHandler, cfg BootstrapConfig) *BootstrapHandler {
+	return &BootstrapHandler{
+		auth:    authHandler,
+		license: licenseHandler,
+		cfg:     cfg,
+	}
+}
+
+// Get returns the bootstrap payload (no authentication required)
+func (h *BootstrapHandler) Get() http.HandlerFunc {
+	return Handle(http.MethodGet, func(w http.ResponseWriter, r *http.Request) error {
+		ctx := r.Context()
+		isFirstRun, err := h.auth.isFirstRun(ctx)
+		if err != nil {
+			return err
+		}
+
+		guestMode := false
+		if h.auth.registryStore != nil {
+			// Failures are logged by the check; report guest mode as off
+			guestMode, _ = h.auth.isGuestLoginAllowed(ctx, "")
+		}
+
+		response := BootstrapResponse{
+			BasePath: basePathFromAppURL(h.cfg.AppURL),
+			Auth: BootstrapAuthModes{
+				IsFirstRun:        isFirstRun,
+				MultiTenant:       h.auth.multiTenant,
+				EmbeddedMode:      h.auth.embeddedMode,
+				GuestMode:         guestMode,
+				Registration:      h.auth.cloudEnabled(),
+				EmailVerification: h.auth.cloudEnabled() && h.auth.signupRuntime != nil,
+				PublicPreview:     h.auth.publicPreviewEnabled,
+				Providers:         nonNilStrings(h.cfg.AuthProviders),
+			},
+			API: BootstrapAPI{
+				ServerVersion: h.cfg.ServerVersion,
+				Version:       h.cfg.APIVersion,
+				CompatMode:    h.cfg.APICompatMode,
+			},
+			Capabilities: nonNilStrings(h.cfg.Capabilities),
+		}
+
+		if h.license != nil {
+			status := h.license.publicStatus(ctx)
+			response.Branding = BootstrapBranding{
+				IsLicensed:     status.IsLicensed,
+				AppTitle:       status.AppTitle,
+				AppURL:         status.AppURL,
+				SupportMessage: status.SupportMessage,
+			}
+		}
+		if title := strings.TrimSpace(h.cfg.AppHomeTitle); title != "" {
+			response.Branding.HomeTitle = stringPtr(title)
+		}
+
+		// Reflects first-run and registry state, which change at runtime
+		w.Header().Set("Cache-Control", "no-store")
+		return WriteSuccess(w, response)
+	})
+}
+
+// basePathFromAppURL returns the path the SPA is served under, "/" by default
+func basePathFromAppURL(appURL string) string {
+	u, err := url.Parse(strings.TrimSpace(appURL))
+	if err != nil || strings.Trim(u.Path, "/") == "" {
+		return "/"
+	}
+	return "/" + strings.Trim(u.Path, "/") + "/"
+}
+
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
@@ -0,0 +1,78 @@
+package httphandler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/license"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/internal/userstore"
+	"github.com/cshum/imagor-studio/server/pkg/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestBootstrapHandler_Get(t *testing.T) {
+	logger := zap.NewNop()
+	tokenManager := auth.NewTokenManager("test-secret", time.Hour)
+	mockUserStore := new(MockUserStore)
+	mockRegistryStore := new(MockRegistryStore)
+	mockLicense := new(MockLicenseService)
+
+	mockUserStore.On("List", mock.Anything, 0, 1, "").Return([]*userstore.User{}, 0, nil)
+	mockRegistryStore.On("Get", mock.Anything, registrystore.SystemOwnerID, "config.allow_guest_mode").
+		Return(&registrystore.Registry{Key: "config.allow_guest_mode", Value: "true"}, nil)
+	mockLicense.On("GetLicenseStatus", mock.Anything, false).Return(&license.LicenseStatus{IsLicensed: true}, nil)
+	mockRegistryStore.On("GetMulti", mock.Anything, registrystore.SystemOwnerID, []string{"config.app_title", "config.app_url"}).
+		Return([]*registrystore.Registry{{Key: "config.app_title", Value: "Acme Studio"}}, nil)
+
+	authHandler := NewAuthHandler(tokenManager, mockUserStore, nil, mockRegistryStore, logger, AuthHandlerConfig{PublicPreviewEnabled: true})
+	licenseHandler := NewLicenseHandler(mockLicense, mockRegistryStore, logger)
+	handler := NewBootstrapHandler(authHandler, licenseHandler, BootstrapConfig{
+		AppURL:        "https://example.com/studio/",
+		AppHomeTitle:  "Gallery",
+		ServerVersion: "v1.2.3",
+		APIVersion:    2,
+		APICompatMode: true,
+		Capabilities:  []string{"bulk_download", "hls"},
+	})
+
+	rr := httptest.NewRecorder()
+	handler.Get()(rr, httptest.NewRequest(http.MethodGet, "/api/bootstrap", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+
+	var response BootstrapResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Equal(t, "/studio/", response.BasePath)
+	assert.True(t, response.Auth.IsFirstRun)
+	assert.True(t, response.Auth.GuestMode)
+	assert.True(t, response.Auth.PublicPreview)
+	assert.False(t, response.Auth.Registration)
+	assert.Equal(t, []string{}, response.Auth.Providers)
+	assert.True(t, response.Branding.IsLicensed)
+	require.NotNil(t, response.Branding.AppTitle)
+	assert.Equal(t, "Acme Studio", *response.Branding.AppTitle)
+	require.NotNil(t, response.Branding.HomeTitle)
+	assert.Equal(t, "Gallery", *response.Branding.HomeTitle)
+	assert.Equal(t, BootstrapAPI{ServerVersion: "v1.2.3", Version: 2, CompatMode: true}, response.API)
+	assert.Equal(t, []string{"bulk_download", "hls"}, response.Capabilities)
+}
+
+func TestBootstrapHandler_MethodNotAllowed(t *testing.T) {
+	handler := NewBootstrapHandler(nil, nil, BootstrapConfig{})
+	rr := httptest.NewRecorder()
+	handler.Get()(rr, httptest.NewRequest(http.MethodPost, "/api/bootstrap", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+}
+
+func TestBasePathFromAppURL(t *testing.T) {
+	assert.Equal(t, "/", basePathFromAppURL(""))
+	assert.Equal(t, "/", basePathFromAppURL("https://example.com"))
+	assert.Equal(t, "/studio/", basePathFromAppURL("https://example.com/studio"))
+}
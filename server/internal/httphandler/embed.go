@@ -0,0 +1,199 @@
+package httphandler
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/pkg/apperror"
+	"github.com/cshum/imagor-studio/server/pkg/auth"
+	"github.com/cshum/imagor-studio/server/pkg/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	// EmbedTimestampHeader carries the Unix time an embed token request was
+	// signed at
+	EmbedTimestampHeader = "X-Imagor-Studio-Timestamp"
+	// EmbedSignatureHeader carries the signature of an embed token request,
+	// see SignEmbedTokenRequest
+	EmbedSignatureHeader = "X-Imagor-Studio-Signature"
+
+	// embedTokenMaxSkew bounds how far the request timestamp may be from the
+	// server time, so captured requests cannot be replayed later
+	embedTokenMaxSkew = 5 * time.Minute
+	// maxEmbedTokenRequestBytes bounds the request body
+	maxEmbedTokenRequestBytes = 64 << 10
+
+	defaultEmbedTokenTTL = time.Hour
+	maxEmbedTokenTTL     = 24 * time.Hour
+)
+
+type EmbedTokenRequest struct {
+	// ViewerID identifies the viewer in the logs of the server, a random ID
+	// when empty
+	ViewerID string `json:"viewerId"`
+	// PathPrefix restricts the viewer to a folder
+	PathPrefix string `json:"pathPrefix"`
+	// Scopes are some of read and edit, both when empty
+	Scopes []string `json:"scopes"`
+	// ExpiresIn is the lifetime of the token in seconds, an hour when 0 and
+	// at most a day
+	ExpiresIn int64 `json:"expiresIn"`
+	// Watermark is overlaid on the images rendered for the viewer
+	Watermark *EmbedWatermark `json:"watermark"`
+}
+
+// EmbedWatermark are the arguments of the imagor watermark filter, see
+// auth.Watermark
+type EmbedWatermark struct {
+	Image  string `json:"image"`
+	X      string `json:"x"`
+	Y      string `json:"y"`
+	Alpha  int    `json:"alpha"`
+	WRatio int    `json:"wRatio"`
+	HRatio int    `json:"hRatio"`
+}
+
+type EmbedTokenResponse struct {
+	Token      string `json:"token"`
+	ExpiresIn  int64  `json:"expiresIn"`
+	ViewerID   string `json:"viewerId"`
+	PathPrefix string `json:"pathPrefix,omitempty"`
+}
+
+// EmbedTokenHandler mints the tokens the embedded editor is opened with, on
+// behalf of host servers holding the embed token secret, so they need not
+// share the JWT secret
+type EmbedTokenHandler struct {
+	tokenManager *auth.TokenManager
+	secret       string
+	logger       *zap.Logger
+}
+
+func NewEmbedTokenHandler(tokenManager *auth.TokenManager, secret string, logger *zap.Logger) *EmbedTokenHandler {
+	return &EmbedTokenHandler{
+		tokenManager: tokenManager,
+		secret:       secret,
+		logger:       logger,
+	}
+}
+
+// SignEmbedTokenRequest returns the EmbedSignatureHeader value of a request
+// body signed at timestamp, the HMAC-SHA256 of "<timestamp>.<body>" as
+// "sha256=<hex>"
+func SignEmbedTokenRequest(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Token mints a token scoped to a viewer (no authentication required, the
+// request signature is the credential)
+func (h *EmbedTokenHandler) Token() http.HandlerFunc {
+	return Handle(http.MethodPost, func(w http.ResponseWriter, r *http.Request) error {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxEmbedTokenRequestBytes+1))
+		if err != nil {
+			return apperror.BadRequest("Invalid request body", nil)
+		}
+		if len(body) > maxEmbedTokenRequestBytes {
+			return apperror.BadRequest("Request body too large", nil)
+		}
+		if err := h.verify(r, body); err != nil {
+			return err
+		}
+
+		var req EmbedTokenRequest
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&req); err != nil {
+			return apperror.BadRequest("Invalid request body", map[string]interface{}{"error": err.Error()})
+		}
+
+		pathPrefix, err := normalizeEmbeddedPathPrefix(strings.TrimSpace(req.PathPrefix))
+		if err != nil {
+			return apperror.BadRequest(err.Error(), nil, "pathPrefix")
+		}
+		for _, scope := range req.Scopes {
+			if scope != "read" && scope != "edit" {
+				return apperror.BadRequest("Scopes must be read or edit", nil, "scopes")
+			}
+		}
+		ttl := defaultEmbedTokenTTL
+		if req.ExpiresIn != 0 {
+			ttl = time.Duration(req.ExpiresIn) * time.Second
+			if ttl <= 0 || ttl > maxEmbedTokenTTL {
+				return apperror.BadRequest("expiresIn must be between 1 and 86400 seconds", nil, "expiresIn")
+			}
+		}
+		var watermark *auth.Watermark
+		if req.Watermark != nil {
+			watermark = &auth.Watermark{
+				Image:  strings.Trim(req.Watermark.Image, "/"),
+				X:      req.Watermark.X,
+				Y:      req.Watermark.Y,
+				Alpha:  req.Watermark.Alpha,
+				WRatio: req.Watermark.WRatio,
+				HRatio: req.Watermark.HRatio,
+			}
+			if err := watermark.Validate(); err != nil {
+				return apperror.BadRequest(err.Error(), nil, "watermark")
+			}
+		}
+		viewerID := strings.TrimSpace(req.ViewerID)
+		if viewerID == "" {
+			viewerID = uuid.GenerateUUID()
+		}
+
+		token, err := h.tokenManager.GenerateTokenWithClaims(auth.Claims{
+			UserID:     viewerID,
+			Role:       "guest",
+			Scopes:     embeddedGuestScopes(req.Scopes),
+			PathPrefix: pathPrefix,
+			IsEmbedded: true,
+			Kind:       auth.EmbedTokenKind,
+			Watermark:  watermark,
+		}, ttl)
+		if err != nil {
+			h.logger.Error("Failed to generate embed token", zap.Error(err))
+			return apperror.InternalServerError("Failed to generate token")
+		}
+
+		h.logger.Debug("Embed token minted",
+			zap.String("viewerID", viewerID),
+			zap.String("pathPrefix", pathPrefix),
+			zap.Bool("watermark", watermark != nil))
+
+		return WriteSuccess(w, EmbedTokenResponse{
+			Token:      token,
+			ExpiresIn:  int64(ttl.Seconds()),
+			ViewerID:   viewerID,
+			PathPrefix: pathPrefix,
+		})
+	})
+}
+
+// verify checks the request timestamp and signature
+func (h *EmbedTokenHandler) verify(r *http.Request, body []byte) error {
+	timestamp := r.Header.Get(EmbedTimestampHeader)
+	signedAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return apperror.Unauthorized("Request timestamp is missing or invalid")
+	}
+	if skew := time.Since(time.Unix(signedAt, 0)); skew > embedTokenMaxSkew || skew < -embedTokenMaxSkew {
+		return apperror.Unauthorized("Request timestamp is too far from the server time")
+	}
+	expected := SignEmbedTokenRequest(h.secret, timestamp, body)
+	if !hmac.Equal([]byte(r.Header.Get(EmbedSignatureHeader)), []byte(expected)) {
+		return apperror.Unauthorized("Invalid request signature")
+	}
+	return nil
+}
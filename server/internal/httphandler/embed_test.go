@@ -0,0 +1,118 @@
+package httphandler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/pkg/apperror"
+	"github.com/cshum/imagor-studio/server/pkg/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newEmbedTokenRequest(secret, body string, signedAt time.Time) *http.Request {
+	timestamp := strconv.FormatInt(signedAt.Unix(), 10)
+	req := httptest.NewRequest(http.MethodPost, "/api/embed/token", strings.NewReader(body))
+	req.Header.Set(EmbedTimestampHeader, timestamp)
+	req.Header.Set(EmbedSignatureHeader, SignEmbedTokenRequest(secret, timestamp, []byte(body)))
+	return req
+}
+
+func TestEmbedToken(t *testing.T) {
+	tokenManager := auth.NewTokenManager("test-secret", time.Hour)
+	handler := NewEmbedTokenHandler(tokenManager, "embed-secret", zap.NewNop())
+
+	body := `{"viewerId":"viewer-1","pathPrefix":"users/123/","scopes":["read"],"expiresIn":600,
+		"watermark":{"image":"/brand/logo.png","x":"-10","y":"bottom","alpha":40,"wRatio":20}}`
+	rr := httptest.NewRecorder()
+	handler.Token()(rr, newEmbedTokenRequest("embed-secret", body, time.Now()))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	var resp EmbedTokenResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	assert.Equal(t, int64(600), resp.ExpiresIn)
+	assert.Equal(t, "viewer-1", resp.ViewerID)
+	assert.Equal(t, "/users/123", resp.PathPrefix)
+	claims, err := tokenManager.ValidateToken(resp.Token)
+	require.NoError(t, err)
+	assert.Equal(t, auth.EmbedTokenKind, claims.Kind)
+	assert.Equal(t, []string{"read"}, claims.Scopes)
+	assert.Equal(t, &auth.Watermark{Image: "brand/logo.png", X: "-10", Y: "bottom", Alpha: 40, WRatio: 20}, claims.Watermark)
+
+	// The embedded editor opens a session with the minted token
+	authHandler := NewAuthHandler(tokenManager, new(MockUserStore), nil, new(MockRegistryStore), zap.NewNop(), AuthHandlerConfig{EmbeddedMode: true})
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/embedded-guest", nil)
+	req.Header.Set("Authorization", "Bearer "+resp.Token)
+	rr = httptest.NewRecorder()
+	authHandler.EmbeddedGuestLogin()(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var loginResp LoginResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &loginResp))
+	assert.Equal(t, "viewer-1", loginResp.User.ID)
+	sessionClaims, err := tokenManager.ValidateToken(loginResp.Token)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"read"}, sessionClaims.Scopes)
+	assert.Equal(t, "/users/123", sessionClaims.PathPrefix)
+	assert.Equal(t, claims.Watermark, sessionClaims.Watermark)
+
+	// Refreshed sessions keep their watermark
+	refreshed, err := tokenManager.RefreshToken(sessionClaims)
+	require.NoError(t, err)
+	refreshedClaims, err := tokenManager.ValidateToken(refreshed)
+	require.NoError(t, err)
+	assert.Equal(t, claims.Watermark, refreshedClaims.Watermark)
+}
+
+func TestEmbedToken_Defaults(t *testing.T) {
+	tokenManager := auth.NewTokenManager("test-secret", time.Hour)
+	handler := NewEmbedTokenHandler(tokenManager, "embed-secret", zap.NewNop())
+
+	rr := httptest.NewRecorder()
+	handler.Token()(rr, newEmbedTokenRequest("embed-secret", `{}`, time.Now()))
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	var resp EmbedTokenResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	assert.Equal(t, int64(3600), resp.ExpiresIn)
+	assert.NotEmpty(t, resp.ViewerID)
+	claims, err := tokenManager.ValidateToken(resp.Token)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"read", "edit"}, claims.Scopes)
+	assert.Empty(t, claims.PathPrefix)
+	assert.Nil(t, claims.Watermark)
+}
+
+func TestEmbedToken_Rejected(t *testing.T) {
+	handler := NewEmbedTokenHandler(auth.NewTokenManager("test-secret", time.Hour), "embed-secret", zap.NewNop())
+
+	unsigned := httptest.NewRequest(http.MethodPost, "/api/embed/token", strings.NewReader(`{}`))
+	tests := []struct {
+		name           string
+		req            *http.Request
+		expectedStatus int
+	}{
+		{"unsigned", unsigned, http.StatusUnauthorized},
+		{"wrong secret", newEmbedTokenRequest("other-secret", `{}`, time.Now()), http.StatusUnauthorized},
+		{"stale timestamp", newEmbedTokenRequest("embed-secret", `{}`, time.Now().Add(-10*time.Minute)), http.StatusUnauthorized},
+		{"path traversal", newEmbedTokenRequest("embed-secret", `{"pathPrefix":"users/../admin"}`, time.Now()), http.StatusBadRequest},
+		{"admin scope", newEmbedTokenRequest("embed-secret", `{"scopes":["admin"]}`, time.Now()), http.StatusBadRequest},
+		{"expires in over a day", newEmbedTokenRequest("embed-secret", `{"expiresIn":90000}`, time.Now()), http.StatusBadRequest},
+		{"watermark without image", newEmbedTokenRequest("embed-secret", `{"watermark":{"alpha":20}}`, time.Now()), http.StatusBadRequest},
+		{"watermark position", newEmbedTokenRequest("embed-secret", `{"watermark":{"image":"logo.png","x":"1,2"}}`, time.Now()), http.StatusBadRequest},
+		{"unknown field", newEmbedTokenRequest("embed-secret", `{"role":"admin"}`, time.Now()), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handler.Token()(rr, tt.req)
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			var errResp apperror.ErrorResponse
+			assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &errResp))
+		})
+	}
+}
@@ -35,41 +35,44 @@ func NewLicenseHandler(licenseService LicenseServicer, registryStore registrysto
 // When licensed, also includes appTitle and appUrl for brand display on the login screen.
 func (h *LicenseHandler) GetPublicStatus() http.HandlerFunc {
 	return Handle(http.MethodGet, func(w http.ResponseWriter, r *http.Request) error {
-		// Use the unified method with includeDetails=false for public access
-		status, err := h.licenseService.GetLicenseStatus(r.Context(), false)
-		if err != nil {
-			h.logger.Error("Failed to get public license status", zap.Error(err))
-			// Return a safe default status instead of exposing the error
-			status = &license.LicenseStatus{
-				IsLicensed:     false,
-				Message:        "Support ongoing development",
-				SupportMessage: stringPtr("From the creator of imagor & vipsgen"),
-			}
+		return WriteSuccess(w, h.publicStatus(r.Context()))
+	})
+}
+
+func (h *LicenseHandler) publicStatus(ctx context.Context) *license.LicenseStatus {
+	// Use the unified method with includeDetails=false for public access
+	status, err := h.licenseService.GetLicenseStatus(ctx, false)
+	if err != nil {
+		h.logger.Error("Failed to get public license status", zap.Error(err))
+		// Return a safe default status instead of exposing the error
+		status = &license.LicenseStatus{
+			IsLicensed:     false,
+			Message:        "Support ongoing development",
+			SupportMessage: stringPtr("From the creator of imagor & vipsgen"),
 		}
+	}
 
-		// When licensed, attach brand values so the login screen can show them
-		// before authentication. Only set non-empty values.
-		if status.IsLicensed && h.registryStore != nil {
-			entries, regErr := h.registryStore.GetMulti(r.Context(), registrystore.SystemOwnerID,
-				[]string{"config.app_title", "config.app_url"})
-			if regErr == nil {
-				for _, entry := range entries {
-					v := strings.TrimSpace(entry.Value)
-					if v == "" {
-						continue
-					}
-					switch entry.Key {
-					case "config.app_title":
-						status.AppTitle = stringPtr(v)
-					case "config.app_url":
-						status.AppURL = stringPtr(v)
-					}
+	// When licensed, attach brand values so the login screen can show them
+	// before authentication. Only set non-empty values.
+	if status.IsLicensed && h.registryStore != nil {
+		entries, regErr := h.registryStore.GetMulti(ctx, registrystore.SystemOwnerID,
+			[]string{"config.app_title", "config.app_url"})
+		if regErr == nil {
+			for _, entry := range entries {
+				v := strings.TrimSpace(entry.Value)
+				if v == "" {
+					continue
+				}
+				switch entry.Key {
+				case "config.app_title":
+					status.AppTitle = stringPtr(v)
+				case "config.app_url":
+					status.AppURL = stringPtr(v)
 				}
 			}
 		}
-
-		return WriteSuccess(w, status)
-	})
+	}
+	return status
 }
 
 // ActivateLicense activates a license with the provided key (no authentication required)
@@ -2,22 +2,38 @@ package httphandler
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"io"
 	"io/fs"
+	"mime"
 	"net/http"
+	"path"
 	"regexp"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/cshum/imagor-studio/server/internal/compression"
+	"github.com/cshum/imagor-studio/server/internal/middleware"
 	"go.uber.org/zap"
 )
 
 const (
-	spaDocumentCacheControl = "no-cache, no-store, must-revalidate"
-	staticAssetCacheControl = "public, max-age=31536000, immutable"
-	htmlBootstrapTarget     = "</head>"
+	spaDocumentCacheControl  = "no-cache"
+	staticAssetCacheControl  = "public, max-age=31536000, immutable"
+	mutableAssetCacheControl = "public, no-cache"
+	htmlBootstrapTarget      = "</head>"
 )
 
+// precompressed lists the encodings the build precompresses assets to, by
+// preference, and the suffixes of their files
+var precompressed = []struct{ encoding, suffix string }{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
 type AppBootstrap struct {
 	AuthProviders []string `json:"authProviders,omitempty"`
 }
@@ -80,16 +96,83 @@ func isValidBase64URLHash(hash string) bool {
 	return true
 }
 
+// setStaticCacheHeaders caches the fingerprinted files of assets/ for good,
+// HTML documents and the other files keep their names across releases and
+// are revalidated by their ETag
 func setStaticCacheHeaders(w http.ResponseWriter, path string) {
-	if path == "index.html" || strings.HasSuffix(path, ".html") {
+	switch {
+	case path == "index.html" || strings.HasSuffix(path, ".html"):
 		w.Header().Set("Cache-Control", spaDocumentCacheControl)
-		return
+	case strings.HasPrefix(path, "assets/"):
+		w.Header().Set("Cache-Control", staticAssetCacheControl)
+	default:
+		w.Header().Set("Cache-Control", mutableAssetCacheControl)
 	}
+}
+
+func contentETag(content []byte) string {
+	sum := sha256.Sum256(content)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
 
-	w.Header().Set("Cache-Control", staticAssetCacheControl)
+// staticETags caches the ETags of the embedded files, which never change
+type staticETags struct {
+	staticFS fs.FS
+	etags    sync.Map
 }
 
-func serveHTMLDocument(w http.ResponseWriter, staticFS fs.FS, path string, bootstrap AppBootstrap, logger *zap.Logger) bool {
+func (e *staticETags) get(name string, file io.ReadSeeker) (string, error) {
+	if etag, ok := e.etags.Load(name); ok {
+		return etag.(string), nil
+	}
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	etag := contentETag(content)
+	e.etags.Store(name, etag)
+	return etag, nil
+}
+
+// serve serves the file name, or its precompressed variant of encoding
+// when set. It returns false when the file is missing or not seekable.
+// Vary is left to compression.Middleware, which the static files go
+// through.
+func (e *staticETags) serve(w http.ResponseWriter, r *http.Request, name, encoding, suffix string) bool {
+	f, err := e.staticFS.Open(name + suffix)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	file, ok := f.(io.ReadSeeker)
+	if !ok {
+		return false
+	}
+	if info, err := f.Stat(); err != nil || info.IsDir() {
+		return false
+	}
+	etag, err := e.get(name+suffix, file)
+	if err != nil {
+		return false
+	}
+	setStaticCacheHeaders(w, name)
+	w.Header().Set("ETag", etag)
+	if encoding != "" {
+		contentType := mime.TypeByExtension(path.Ext(name))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Encoding", encoding)
+	}
+	http.ServeContent(w, r, name, time.Time{}, file)
+	return true
+}
+
+func serveHTMLDocument(w http.ResponseWriter, r *http.Request, staticFS fs.FS, path string, bootstrap AppBootstrap, logger *zap.Logger) bool {
 	htmlFile, err := staticFS.Open(path)
 	if err != nil {
 		return false
@@ -123,6 +206,12 @@ func serveHTMLDocument(w http.ResponseWriter, staticFS fs.FS, path string, boots
 	}
 
 	setStaticCacheHeaders(w, path)
+	etag := contentETag(body)
+	w.Header().Set("ETag", etag)
+	if middleware.ETagMatch(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	_, _ = w.Write(body)
 	return true
@@ -133,6 +222,7 @@ func serveHTMLDocument(w http.ResponseWriter, staticFS fs.FS, path string, boots
 // pass nil when no imagor instance is available.
 func SPAHandler(staticFS fs.FS, imagorHandler http.Handler, logger *zap.Logger, bootstrap AppBootstrap) http.Handler {
 	fileServer := http.FileServer(http.FS(staticFS))
+	etags := &staticETags{staticFS: staticFS}
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		path := r.URL.Path
 
@@ -166,7 +256,7 @@ func SPAHandler(staticFS fs.FS, imagorHandler http.Handler, logger *zap.Logger,
 
 		if _, err := staticFS.Open(trimmedPath); err != nil {
 			// File doesn't exist, serve index.html for SPA routes
-			if !serveHTMLDocument(w, staticFS, "index.html", bootstrap, logger) {
+			if !serveHTMLDocument(w, r, staticFS, "index.html", bootstrap, logger) {
 				if logger != nil {
 					logger.Error("Failed to open index.html for SPA route",
 						zap.String("path", path),
@@ -178,12 +268,21 @@ func SPAHandler(staticFS fs.FS, imagorHandler http.Handler, logger *zap.Logger,
 		}
 
 		if trimmedPath == "index.html" || strings.HasSuffix(trimmedPath, ".html") {
-			if !serveHTMLDocument(w, staticFS, trimmedPath, bootstrap, logger) {
+			if !serveHTMLDocument(w, r, staticFS, trimmedPath, bootstrap, logger) {
 				http.NotFound(w, r)
 			}
 			return
 		}
 
+		acceptEncoding := r.Header.Get("Accept-Encoding")
+		for _, p := range precompressed {
+			if compression.Accepts(acceptEncoding, p.encoding) && etags.serve(w, r, trimmedPath, p.encoding, p.suffix) {
+				return
+			}
+		}
+		if etags.serve(w, r, trimmedPath, "", "") {
+			return
+		}
 		setStaticCacheHeaders(w, trimmedPath)
 		fileServer.ServeHTTP(w, r)
 	})
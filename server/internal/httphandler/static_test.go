@@ -294,3 +294,92 @@ func TestSPAHandlerRedirectsFaviconToIconPNG(t *testing.T) {
 		t.Fatalf("Expected redirect to /icon.png, got %q", location)
 	}
 }
+
+func TestSPAHandlerServesPrecompressedAssets(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	staticFS := fstest.MapFS{
+		"index.html":              {Data: []byte("<html><body>Mock HTML</body></html>")},
+		"assets/app-abc123.js":    {Data: []byte("console.log('ok')")},
+		"assets/app-abc123.js.br": {Data: []byte("brotli")},
+		"assets/app-abc123.js.gz": {Data: []byte("gzip")},
+		"icon.png":                {Data: []byte("png")},
+	}
+	handler := SPAHandler(staticFS, nil, logger, AppBootstrap{})
+
+	tests := []struct {
+		acceptEncoding   string
+		expectedEncoding string
+		expectedBody     string
+	}{
+		{"gzip, deflate, br", "br", "brotli"},
+		{"gzip", "gzip", "gzip"},
+		{"br;q=0, gzip", "gzip", "gzip"},
+		{"", "", "console.log('ok')"},
+	}
+	etags := map[string]bool{}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/assets/app-abc123.js", nil)
+		req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Accept-Encoding %q: expected status 200, got %d", tt.acceptEncoding, w.Code)
+		}
+		if encoding := w.Header().Get("Content-Encoding"); encoding != tt.expectedEncoding {
+			t.Errorf("Accept-Encoding %q: expected Content-Encoding %q, got %q", tt.acceptEncoding, tt.expectedEncoding, encoding)
+		}
+		if body := w.Body.String(); body != tt.expectedBody {
+			t.Errorf("Accept-Encoding %q: expected body %q, got %q", tt.acceptEncoding, tt.expectedBody, body)
+		}
+		if contentType := w.Header().Get("Content-Type"); !strings.Contains(contentType, "javascript") {
+			t.Errorf("Accept-Encoding %q: expected JavaScript content type, got %q", tt.acceptEncoding, contentType)
+		}
+		etags[tt.expectedEncoding+" "+w.Header().Get("ETag")] = true
+	}
+	if len(etags) != 3 {
+		t.Errorf("Expected an ETag per encoding, got %v", etags)
+	}
+
+	// Files outside assets/ are not fingerprinted and are revalidated
+	req := httptest.NewRequest("GET", "/icon.png", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if cacheControl := w.Header().Get("Cache-Control"); cacheControl != mutableAssetCacheControl {
+		t.Errorf("Expected Cache-Control %q, got %q", mutableAssetCacheControl, cacheControl)
+	}
+}
+
+func TestSPAHandlerRevalidatesByETag(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	staticFS := fstest.MapFS{
+		"index.html": {Data: []byte("<html><head></head><body>Mock HTML</body></html>")},
+		"logo.png":   {Data: []byte("png")},
+	}
+	handler := SPAHandler(staticFS, nil, logger, AppBootstrap{AuthProviders: []string{"google"}})
+
+	for _, path := range []string{"/", "/some/spa/route", "/logo.png"} {
+		req := httptest.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		etag := w.Header().Get("ETag")
+		if w.Code != http.StatusOK || etag == "" {
+			t.Fatalf("%s: expected status 200 with an ETag, got %d %q", path, w.Code, etag)
+		}
+
+		req = httptest.NewRequest("GET", path, nil)
+		req.Header.Set("If-None-Match", etag)
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNotModified {
+			t.Errorf("%s: expected status 304, got %d", path, w.Code)
+		}
+		if w.Body.Len() != 0 {
+			t.Errorf("%s: expected empty body, got %q", path, w.Body.String())
+		}
+	}
+}
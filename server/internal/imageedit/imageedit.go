@@ -0,0 +1,171 @@
+// Package imageedit stores non-destructive image edits (crop, rotation,
+// flips, tone adjustments and filters) as a descriptor per file, and maps
+// the descriptor onto imagor params so the edited image is rendered on
+// demand while the original stays untouched.
+package imageedit
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cshum/imagor/imagorpath"
+)
+
+const (
+	// MaxFilters bounds the extra filters of an edit
+	MaxFilters = 20
+	// adjustmentRange is the range of brightness, contrast and saturation
+	adjustmentRange = 100
+)
+
+// Filters are the extra imagor filters an edit may apply. Filters loading
+// other images, such as watermark, are left out as they read other paths.
+var Filters = map[string]bool{
+	"blur":             true,
+	"sharpen":          true,
+	"grayscale":        true,
+	"hue":              true,
+	"rgb":              true,
+	"modulate":         true,
+	"round_corner":     true,
+	"background_color": true,
+	"pixelate":         true,
+	"strip_exif":       true,
+	"strip_icc":        true,
+}
+
+// Formats are the export formats, keyed by format name with their file
+// extension
+var Formats = map[string]string{
+	"jpeg": "jpg",
+	"png":  "png",
+	"webp": "webp",
+	"avif": "avif",
+	"gif":  "gif",
+	"tiff": "tiff",
+	"jxl":  "jxl",
+}
+
+// filterArgs restricts filter arguments to numbers, colors and keywords
+var filterArgs = regexp.MustCompile(`^[0-9A-Za-z.,#-]*$`)
+
+var (
+	ErrInvalidCrop       = errors.New("crop width and height must be greater than 0 and offsets not negative")
+	ErrInvalidRotate     = errors.New("rotate must be 0, 90, 180 or 270")
+	ErrInvalidAdjust     = fmt.Errorf("brightness, contrast and saturation must be between -%d and %d", adjustmentRange, adjustmentRange)
+	ErrTooManyFilters    = fmt.Errorf("an edit applies at most %d filters", MaxFilters)
+	ErrUnknownFilter     = errors.New("unsupported filter")
+	ErrInvalidArgs       = errors.New("invalid filter arguments")
+	ErrUnsupportedFormat = errors.New("unsupported export format")
+)
+
+// Crop is a region of the original image, in pixels
+type Crop struct {
+	Left   int `json:"left"`
+	Top    int `json:"top"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// Filter is an extra imagor filter with its comma separated arguments
+type Filter struct {
+	Name string `json:"name"`
+	Args string `json:"args,omitempty"`
+}
+
+// Edit describes the edits applied to an image. The crop region is in the
+// coordinates of the original image, it applies before rotation.
+type Edit struct {
+	Crop       *Crop    `json:"crop,omitempty"`
+	Rotate     int      `json:"rotate,omitempty"`
+	HFlip      bool     `json:"hFlip,omitempty"`
+	VFlip      bool     `json:"vFlip,omitempty"`
+	Brightness int      `json:"brightness,omitempty"`
+	Contrast   int      `json:"contrast,omitempty"`
+	Saturation int      `json:"saturation,omitempty"`
+	Filters    []Filter `json:"filters,omitempty"`
+}
+
+// Validate checks the edit renders with the supported imagor operations
+func (e *Edit) Validate() error {
+	if c := e.Crop; c != nil && (c.Width <= 0 || c.Height <= 0 || c.Left < 0 || c.Top < 0) {
+		return ErrInvalidCrop
+	}
+	switch e.Rotate {
+	case 0, 90, 180, 270:
+	default:
+		return ErrInvalidRotate
+	}
+	for _, v := range []int{e.Brightness, e.Contrast, e.Saturation} {
+		if v < -adjustmentRange || v > adjustmentRange {
+			return ErrInvalidAdjust
+		}
+	}
+	if len(e.Filters) > MaxFilters {
+		return ErrTooManyFilters
+	}
+	for _, f := range e.Filters {
+		if !Filters[f.Name] {
+			return fmt.Errorf("%w: %s", ErrUnknownFilter, f.Name)
+		}
+		if !filterArgs.MatchString(f.Args) {
+			return fmt.Errorf("%w for %s", ErrInvalidArgs, f.Name)
+		}
+	}
+	return nil
+}
+
+// Params returns the imagor params rendering an image with the edit
+func (e *Edit) Params() imagorpath.Params {
+	params := imagorpath.Params{
+		HFlip: e.HFlip,
+		VFlip: e.VFlip,
+	}
+	if c := e.Crop; c != nil {
+		params.CropLeft = float64(c.Left)
+		params.CropTop = float64(c.Top)
+		params.CropRight = float64(c.Left + c.Width)
+		params.CropBottom = float64(c.Top + c.Height)
+	}
+	for _, f := range []struct {
+		name  string
+		value int
+	}{
+		{"rotate", e.Rotate},
+		{"brightness", e.Brightness},
+		{"contrast", e.Contrast},
+		{"saturation", e.Saturation},
+	} {
+		if f.value != 0 {
+			params.Filters = append(params.Filters, imagorpath.Filter{Name: f.name, Args: strconv.Itoa(f.value)})
+		}
+	}
+	for _, f := range e.Filters {
+		params.Filters = append(params.Filters, imagorpath.Filter{Name: f.Name, Args: f.Args})
+	}
+	return params
+}
+
+// ExportParams returns the imagor params rendering an image with the edit
+// in the given export format
+func (e *Edit) ExportParams(format string) (imagorpath.Params, error) {
+	format = NormalizeFormat(format)
+	if _, ok := Formats[format]; !ok {
+		return imagorpath.Params{}, fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+	params := e.Params()
+	params.Filters = append(params.Filters, imagorpath.Filter{Name: "format", Args: format})
+	return params, nil
+}
+
+// NormalizeFormat lower cases a format name, accepting jpg for jpeg
+func NormalizeFormat(format string) string {
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format == "jpg" {
+		return "jpeg"
+	}
+	return format
+}
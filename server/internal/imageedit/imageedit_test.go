@@ -0,0 +1,72 @@
+package imageedit
+
+import (
+	"testing"
+
+	"github.com/cshum/imagor/imagorpath"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEdit_Params(t *testing.T) {
+	edit := &Edit{
+		Crop:       &Crop{Left: 10, Top: 20, Width: 300, Height: 200},
+		Rotate:     90,
+		HFlip:      true,
+		Brightness: 15,
+		Saturation: -30,
+		Filters:    []Filter{{Name: "grayscale"}, {Name: "round_corner", Args: "20,20,#ffffff"}},
+	}
+	require.NoError(t, edit.Validate())
+
+	params := edit.Params()
+	assert.Equal(t, 10.0, params.CropLeft)
+	assert.Equal(t, 20.0, params.CropTop)
+	assert.Equal(t, 310.0, params.CropRight)
+	assert.Equal(t, 220.0, params.CropBottom)
+	assert.True(t, params.HFlip)
+	assert.False(t, params.VFlip)
+	assert.Equal(t, imagorpath.Filters{
+		{Name: "rotate", Args: "90"},
+		{Name: "brightness", Args: "15"},
+		{Name: "saturation", Args: "-30"},
+		{Name: "grayscale"},
+		{Name: "round_corner", Args: "20,20,#ffffff"},
+	}, params.Filters)
+
+	assert.Empty(t, (&Edit{}).Params().Filters)
+	assert.False(t, imagorpath.HasCrop((&Edit{}).Params()))
+}
+
+func TestEdit_ExportParams(t *testing.T) {
+	edit := &Edit{Contrast: 10}
+	params, err := edit.ExportParams("JPG")
+	require.NoError(t, err)
+	assert.Equal(t, imagorpath.Filters{
+		{Name: "contrast", Args: "10"},
+		{Name: "format", Args: "jpeg"},
+	}, params.Filters)
+
+	_, err = edit.ExportParams("bmp")
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+}
+
+func TestEdit_Validate(t *testing.T) {
+	for name, tc := range map[string]struct {
+		edit Edit
+		err  error
+	}{
+		"empty crop":       {Edit{Crop: &Crop{Width: 0, Height: 10}}, ErrInvalidCrop},
+		"negative offset":  {Edit{Crop: &Crop{Left: -1, Width: 10, Height: 10}}, ErrInvalidCrop},
+		"rotate":           {Edit{Rotate: 45}, ErrInvalidRotate},
+		"brightness":       {Edit{Brightness: 101}, ErrInvalidAdjust},
+		"contrast":         {Edit{Contrast: -101}, ErrInvalidAdjust},
+		"watermark filter": {Edit{Filters: []Filter{{Name: "watermark", Args: "logo.png"}}}, ErrUnknownFilter},
+		"nested filter":    {Edit{Filters: []Filter{{Name: "blur", Args: "5):watermark(x.png"}}}, ErrInvalidArgs},
+		"too many filters": {Edit{Filters: make([]Filter, MaxFilters+1)}, ErrTooManyFilters},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.ErrorIs(t, tc.edit.Validate(), tc.err)
+		})
+	}
+}
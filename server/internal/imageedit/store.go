@@ -0,0 +1,126 @@
+package imageedit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/database"
+	"github.com/cshum/imagor-studio/server/internal/model"
+	"github.com/cshum/imagor-studio/server/pkg/uuid"
+	"github.com/uptrace/bun"
+	"go.uber.org/zap"
+)
+
+// Record is the edit saved for a file
+type Record struct {
+	FilePath  string
+	Edit      *Edit
+	UpdatedBy string
+	UpdatedAt time.Time
+}
+
+// Store keeps the edit of each file per scope and file path
+type Store interface {
+	// Get returns the edit saved for filePath, or nil when there is none
+	Get(ctx context.Context, scope, filePath string) (*Record, error)
+	// Save replaces the edit saved for filePath
+	Save(ctx context.Context, scope, filePath, updatedBy string, edit *Edit) (*Record, error)
+	// MoveFilePath moves the edits of a file, or of every file below a
+	// folder, replacing edits saved at the destination
+	MoveFilePath(ctx context.Context, scope, oldPath, newPath string) error
+	// RemoveFilePath drops the edit of a file, or of every file below a
+	// folder
+	RemoveFilePath(ctx context.Context, scope, path string) error
+}
+
+type store struct {
+	db     *bun.DB
+	logger *zap.Logger
+}
+
+func NewStore(db *bun.DB, logger *zap.Logger) Store {
+	return &store{db: db, logger: logger}
+}
+
+func (s *store) Get(ctx context.Context, scope, filePath string) (*Record, error) {
+	var row model.ImageEdit
+	err := s.db.NewSelect().Model(&row).
+		Where("scope = ?", scope).
+		Where("file_path = ?", filePath).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting image edit: %w", err)
+	}
+	var edit Edit
+	if err := json.Unmarshal([]byte(row.Data), &edit); err != nil {
+		return nil, fmt.Errorf("error decoding image edit: %w", err)
+	}
+	return &Record{FilePath: row.FilePath, Edit: &edit, UpdatedBy: row.UpdatedBy, UpdatedAt: row.UpdatedAt}, nil
+}
+
+func (s *store) Save(ctx context.Context, scope, filePath, updatedBy string, edit *Edit) (*Record, error) {
+	data, err := json.Marshal(edit)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding image edit: %w", err)
+	}
+	row := &model.ImageEdit{
+		ID:        uuid.GenerateUUID(),
+		Scope:     scope,
+		FilePath:  filePath,
+		Data:      string(data),
+		UpdatedBy: updatedBy,
+		UpdatedAt: time.Now().UTC(),
+	}
+	if _, err := database.OnConflictUpdate(s.db.NewInsert().Model(row), "scope, file_path",
+		"data", "updated_by", "updated_at").Exec(ctx); err != nil {
+		return nil, fmt.Errorf("error saving image edit: %w", err)
+	}
+	return &Record{FilePath: filePath, Edit: edit, UpdatedBy: updatedBy, UpdatedAt: row.UpdatedAt}, nil
+}
+
+func (s *store) MoveFilePath(ctx context.Context, scope, oldPath, newPath string) error {
+	return s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var rows []model.ImageEdit
+		if err := tx.NewSelect().Model(&rows).
+			Where("scope = ?", scope).
+			Where("(file_path = ? OR substr(file_path, 1, ?) = ?)", oldPath, len(oldPath)+1, oldPath+"/").
+			Scan(ctx); err != nil {
+			return fmt.Errorf("error moving image edits: %w", err)
+		}
+		for _, row := range rows {
+			moved := newPath + strings.TrimPrefix(row.FilePath, oldPath)
+			// An edit saved for the replaced destination no longer applies
+			if _, err := tx.NewDelete().Model((*model.ImageEdit)(nil)).
+				Where("scope = ?", scope).
+				Where("file_path = ?", moved).
+				Exec(ctx); err != nil {
+				return fmt.Errorf("error moving image edits: %w", err)
+			}
+			if _, err := tx.NewUpdate().Model((*model.ImageEdit)(nil)).
+				Set("file_path = ?", moved).
+				Where("id = ?", row.ID).
+				Exec(ctx); err != nil {
+				return fmt.Errorf("error moving image edits: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+func (s *store) RemoveFilePath(ctx context.Context, scope, path string) error {
+	if _, err := s.db.NewDelete().Model((*model.ImageEdit)(nil)).
+		Where("scope = ?", scope).
+		Where("(file_path = ? OR substr(file_path, 1, ?) = ?)", path, len(path)+1, path+"/").
+		Exec(ctx); err != nil {
+		return fmt.Errorf("error removing image edits: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package imageedit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const scope = "system:global"
+
+func setupTestStore(t *testing.T) Store {
+	t.Helper()
+	db := testutil.NewDB(t)
+
+	return NewStore(db, zap.NewNop())
+}
+
+func TestStore(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	record, err := s.Get(ctx, scope, "photos/a.jpg")
+	require.NoError(t, err)
+	assert.Nil(t, record)
+
+	_, err = s.Save(ctx, scope, "photos/a.jpg", "user-1", &Edit{Rotate: 90})
+	require.NoError(t, err)
+	saved, err := s.Save(ctx, scope, "photos/a.jpg", "user-2", &Edit{Rotate: 180, Filters: []Filter{{Name: "grayscale"}}})
+	require.NoError(t, err)
+	assert.Equal(t, "user-2", saved.UpdatedBy)
+
+	record, err = s.Get(ctx, scope, "photos/a.jpg")
+	require.NoError(t, err)
+	require.NotNil(t, record)
+	assert.Equal(t, 180, record.Edit.Rotate)
+	assert.Equal(t, []Filter{{Name: "grayscale"}}, record.Edit.Filters)
+	assert.Equal(t, "user-2", record.UpdatedBy)
+
+	// Scopes are kept apart
+	record, err = s.Get(ctx, "space:other", "photos/a.jpg")
+	require.NoError(t, err)
+	assert.Nil(t, record)
+}
+
+func TestStore_MoveAndRemove(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	for _, p := range []string{"photos/a.jpg", "photos/b.jpg", "photos-old/c.jpg", "archive/a.jpg"} {
+		_, err := s.Save(ctx, scope, p, "user-1", &Edit{Brightness: len(p)})
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, s.MoveFilePath(ctx, scope, "photos", "archive"))
+	record, err := s.Get(ctx, scope, "archive/a.jpg")
+	require.NoError(t, err)
+	require.NotNil(t, record)
+	assert.Equal(t, len("photos/a.jpg"), record.Edit.Brightness)
+	record, err = s.Get(ctx, scope, "photos/a.jpg")
+	require.NoError(t, err)
+	assert.Nil(t, record)
+	record, err = s.Get(ctx, scope, "photos-old/c.jpg")
+	require.NoError(t, err)
+	assert.NotNil(t, record)
+
+	require.NoError(t, s.RemoveFilePath(ctx, scope, "archive"))
+	for _, p := range []string{"archive/a.jpg", "archive/b.jpg"} {
+		record, err := s.Get(ctx, scope, p)
+		require.NoError(t, err)
+		assert.Nil(t, record, p)
+	}
+	record, err = s.Get(ctx, scope, "photos-old/c.jpg")
+	require.NoError(t, err)
+	assert.NotNil(t, record)
+}
@@ -16,9 +16,12 @@ import (
 
 	"github.com/cshum/imagor"
 	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/rawpreview"
 	"github.com/cshum/imagor-studio/server/internal/registrystore"
 	"github.com/cshum/imagor-studio/server/internal/storageprovider"
+	"github.com/cshum/imagor-studio/server/internal/tracing"
 	"github.com/cshum/imagor-studio/server/pkg/processing"
+	"github.com/cshum/imagor-studio/server/pkg/safepath"
 	"github.com/cshum/imagor-studio/server/pkg/storage"
 	"github.com/cshum/imagor/imagorpath"
 	"go.uber.org/zap"
@@ -39,9 +42,17 @@ type StorageLoader struct {
 }
 
 // Get implements imagor.Loader by delegating to the current storage.
+// Preview keys of RAW files resolve to the JPEG the file embeds, or to the
+// RAW file itself when it has none.
 func (l *StorageLoader) Get(r *http.Request, key string) (*imagor.Blob, error) {
+	if err := safepath.Validate(key); err != nil {
+		return nil, imagor.ErrInvalid
+	}
 	ctx := r.Context()
 	source := l.source
+	if rawPath, ok := rawpreview.SourcePath(key); ok {
+		return loadRawPreview(ctx, source.GetStorage(), rawPath)
+	}
 	blob := imagor.NewBlob(func() (io.ReadCloser, int64, error) {
 		rc, err := source.GetStorage().Get(ctx, key)
 		if err != nil {
@@ -52,6 +63,25 @@ func (l *StorageLoader) Get(r *http.Request, key string) (*imagor.Blob, error) {
 	return blob, blob.Err()
 }
 
+// loadRawPreview returns the embedded preview of the RAW file at rawPath
+func loadRawPreview(ctx context.Context, stor storage.Storage, rawPath string) (*imagor.Blob, error) {
+	rc, err := stor.Get(ctx, rawPath)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+	preview, err := rawpreview.Extract(data)
+	if err != nil {
+		// libvips may still decode the RAW file itself
+		return imagor.NewBlobFromBytes(data), nil
+	}
+	return imagor.NewBlobFromBytes(preview), nil
+}
+
 // NewStorageLoader wraps a storageprovider.Provider as an imagor.Loader.
 // Use this for self-hosted deployments; on processing nodes pass
 // spaceloader.New(…) instead.
@@ -91,6 +121,10 @@ type Provider struct {
 	// app is the running *imagor.Imagor instance. Set during Initialize().
 	app *imagor.Imagor
 
+	// resultStorage persists generated thumbnails, nil when disabled. Set
+	// during Initialize().
+	resultStorage imagor.Storage
+
 	// dynSigner is passed to imagor at startup; its inner signer is replaced by
 	// Sync() so imagor verifies requests with the current secret without restart.
 	// Nil in processing-node mode.
@@ -171,6 +205,12 @@ func (p *Provider) Imagor() *imagor.Imagor {
 	return p.app
 }
 
+// ResultStorage returns the storage persisting generated results, nil when
+// result storage is disabled or before Initialize().
+func (p *Provider) ResultStorage() imagor.Storage {
+	return p.resultStorage
+}
+
 // Initialize starts the imagor instance using registry/config values.
 func (p *Provider) Initialize() error {
 	cfg, err := buildConfigFromRegistry(p.registryStore, p.config)
@@ -191,7 +231,11 @@ func (p *Provider) Initialize() error {
 // and stores it in p.app.
 func (p *Provider) createApp(cfg *ImagorConfig) error {
 	// Processor options — compiled in only when the vips build tag is set.
-	options := buildProcessors(p.logger, p.config, p.processorDecorator, p.extraProcessors)
+	decorator := p.processorDecorator
+	if tracing.Enabled(p.config) {
+		decorator = tracedDecorator{next: decorator}
+	}
+	options := buildProcessors(p.logger, p.config, decorator, p.extraProcessors)
 
 	if p.spaceConfigStore != nil {
 		// ── Processing-node mode ─────────────────────────────────────────────
@@ -294,6 +338,20 @@ func (p *Provider) createApp(cfg *ImagorConfig) error {
 		ds.update(signerFromConfig(cfg))
 		p.dynSigner = ds
 		options = append(options, imagor.WithSigner(p.dynSigner))
+		// Key results by source path so those of a file stay together
+		options = append(options, imagor.WithResultStoragePathStyle(imagorpath.SuffixResultStorageHasher))
+	}
+
+	resultStorage, err := newResultStorage(p.config)
+	if err != nil {
+		return err
+	}
+	p.resultStorage = resultStorage
+	if resultStorage != nil {
+		// Stored results older than their source are regenerated
+		options = append(options,
+			imagor.WithResultStorages(resultStorage),
+			imagor.WithModifiedTimeCheck(true))
 	}
 
 	// Wire the single loader chosen by bootstrap:
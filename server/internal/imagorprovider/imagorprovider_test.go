@@ -534,6 +534,19 @@ func TestStorageLoader_Get_NotFound(t *testing.T) {
 	assert.Error(t, blob.Err())
 }
 
+func TestStorageLoader_Get_RejectsTraversal(t *testing.T) {
+	stor := newMockReadStorage()
+	stor.data["../secret.jpg"] = []byte("secret")
+
+	loader := &StorageLoader{source: &mockStorageSource{stor: stor}}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	for _, key := range []string{"../secret.jpg", `images\..\..\secret.jpg`, "images/a\x00.jpg"} {
+		_, err := loader.Get(req, key)
+		assert.ErrorIs(t, err, imagor.ErrInvalid, key)
+	}
+}
+
 func TestStorageLoader_Get_DelegatesCurrentStorage(t *testing.T) {
 	// Start with empty storage
 	stor := newMockReadStorage()
@@ -575,6 +588,23 @@ func TestStorageLoader_Get_StorageError(t *testing.T) {
 	assert.Error(t, blob.Err())
 }
 
+func TestStorageLoader_Get_RawPreviewFallback(t *testing.T) {
+	stor := newMockReadStorage()
+	stor.data["photos/a.nef"] = []byte("raw-without-preview")
+	loader := &StorageLoader{source: &mockStorageSource{stor: stor}}
+	req := httptest.NewRequest("GET", "/", nil)
+
+	// Without an embedded preview the RAW file itself is served
+	blob, err := loader.Get(req, "photos/a.nef.imagor.rawpreview")
+	require.NoError(t, err)
+	data, err := blob.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, []byte("raw-without-preview"), data)
+
+	_, err = loader.Get(req, "photos/missing.nef.imagor.rawpreview")
+	assert.Error(t, err)
+}
+
 // Compile-time check: mockStorageSource satisfies the storageSource interface.
 var _ storageSource = (*mockStorageSource)(nil)
 
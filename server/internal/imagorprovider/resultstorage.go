@@ -0,0 +1,85 @@
+package imagorprovider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/rawpreview"
+	pkgs3storage "github.com/cshum/imagor-studio/server/pkg/storage/s3storage"
+	"github.com/cshum/imagor/storage/filestorage"
+	"github.com/cshum/imagor/storage/s3storage"
+)
+
+// newResultStorage returns the storage persisting generated thumbnails, so
+// they survive restarts and are shared by replicas. Returns nil when result
+// storage is disabled.
+func newResultStorage(cfg *config.Config) (imagor.Storage, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	switch cfg.ImagorResultStorage {
+	case "":
+		return nil, nil
+	case "file":
+		options := []filestorage.Option{filestorage.WithExpiration(cfg.ImagorResultStorageExpiration)}
+		if cfg.FileStorageMkdirPermissions != 0 {
+			options = append(options, filestorage.WithMkdirPermission(fmt.Sprintf("%#o", cfg.FileStorageMkdirPermissions)))
+		}
+		if cfg.FileStorageWritePermissions != 0 {
+			options = append(options, filestorage.WithWritePermission(fmt.Sprintf("%#o", cfg.FileStorageWritePermissions)))
+		}
+		return filestorage.New(cfg.ImagorResultStorageBaseDir, options...), nil
+	case "s3":
+		bucket := cfg.ImagorResultStorageBucket
+		if bucket == "" {
+			bucket = cfg.S3StorageBucket
+		}
+		awsCfg, err := loadAWSConfig(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load AWS configuration for result storage: %w", err)
+		}
+		options := []s3storage.Option{
+			s3storage.WithBaseDir(cfg.ImagorResultStorageBaseDir),
+			s3storage.WithExpiration(cfg.ImagorResultStorageExpiration),
+			s3storage.WithForcePathStyle(cfg.S3ForcePathStyle),
+		}
+		if cfg.S3Endpoint != "" {
+			options = append(options, s3storage.WithEndpoint(cfg.S3Endpoint))
+		}
+		return s3storage.New(awsCfg, bucket, options...), nil
+	default:
+		return nil, fmt.Errorf("unsupported imagor result storage: %s", cfg.ImagorResultStorage)
+	}
+}
+
+// loadAWSConfig builds the AWS configuration from the s3 storage flags,
+// falling back to the default credential chain like the s3 storage does
+func loadAWSConfig(cfg *config.Config) (aws.Config, error) {
+	options := []func(*awsconfig.LoadOptions) error{
+		awsconfig.WithRegion(cfg.AWSRegion),
+		awsconfig.WithHTTPClient(pkgs3storage.SharedHTTPClient(cfg.S3HTTPMaxIdleConnsPerHost)),
+	}
+	if cfg.AWSAccessKeyID != "" && cfg.AWSSecretAccessKey != "" {
+		options = append(options, awsconfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
+			cfg.AWSAccessKeyID, cfg.AWSSecretAccessKey, cfg.AWSSessionToken)))
+	}
+	return awsconfig.LoadDefaultConfig(context.Background(), options...)
+}
+
+// Stat implements imagor.Stater, letting imagor regenerate stored results
+// of files modified since
+func (l *StorageLoader) Stat(ctx context.Context, key string) (*imagor.Stat, error) {
+	if rawPath, ok := rawpreview.SourcePath(key); ok {
+		key = rawPath
+	}
+	info, err := l.source.GetStorage().Stat(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return &imagor.Stat{ModifiedTime: info.ModifiedTime, ETag: info.ETag, Size: info.Size}, nil
+}
@@ -0,0 +1,74 @@
+package imagorprovider
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/cshum/imagor/imagorpath"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestNewResultStorage(t *testing.T) {
+	rs, err := newResultStorage(&config.Config{})
+	require.NoError(t, err)
+	assert.Nil(t, rs)
+
+	rs, err = newResultStorage(&config.Config{ImagorResultStorage: "file", ImagorResultStorageBaseDir: t.TempDir()})
+	require.NoError(t, err)
+	assert.NotNil(t, rs)
+
+	_, err = newResultStorage(&config.Config{ImagorResultStorage: "ftp"})
+	assert.Error(t, err)
+}
+
+func TestResultStorage_File(t *testing.T) {
+	sourceDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(sourceDir, "photos"), 0755))
+	sourcePath := filepath.Join(sourceDir, "photos", "a.jpg")
+	require.NoError(t, os.WriteFile(sourcePath, []byte("source-v1"), 0644))
+	stor, err := filestorage.New(sourceDir)
+	require.NoError(t, err)
+
+	resultDir := t.TempDir()
+	cfg := &config.Config{JWTSecret: "test-jwt-secret", ImagorResultStorage: "file", ImagorResultStorageBaseDir: resultDir}
+	provider := New(zap.NewNop(), newMockRegistryStore(), cfg, &StorageLoader{source: &mockStorageSource{stor: stor}})
+	require.NoError(t, provider.Initialize())
+
+	params := imagorpath.Params{Image: "photos/a.jpg", Width: 100, Filters: imagorpath.Filters{{Name: "format", Args: "webp"}}}
+	render := func() string {
+		blob, err := provider.Imagor().Do(httptest.NewRequest("GET", "/", nil), params)
+		require.NoError(t, err)
+		data, err := blob.ReadAll()
+		require.NoError(t, err)
+		return string(data)
+	}
+	assert.Equal(t, "source-v1", render())
+
+	// The result is stored next to the other results of the file
+	var stored []string
+	require.Eventually(t, func() bool {
+		stored, _ = filepath.Glob(filepath.Join(resultDir, "photos", "a.*.webp"))
+		return len(stored) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	// Stored results are served instead of rendering again. The result is
+	// replaced by rename, imagor may still be writing the file, and requests
+	// share the render until it is saved.
+	replacement := filepath.Join(resultDir, "stored")
+	require.NoError(t, os.WriteFile(replacement, []byte("stored"), 0644))
+	require.NoError(t, os.Rename(replacement, stored[0]))
+	require.Eventually(t, func() bool { return render() == "stored" }, time.Second, 10*time.Millisecond)
+
+	// Results older than their source are regenerated
+	require.NoError(t, os.WriteFile(sourcePath, []byte("source-v2"), 0644))
+	future := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(sourcePath, future, future))
+	assert.Equal(t, "source-v2", render())
+}
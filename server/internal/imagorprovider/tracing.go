@@ -0,0 +1,51 @@
+package imagorprovider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor-studio/server/internal/tracing"
+	"github.com/cshum/imagor-studio/server/pkg/processing"
+	"github.com/cshum/imagor/imagorpath"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// tracedDecorator traces the processors decorated by next, with the time
+// spent waiting for a processing slot included in the span
+type tracedDecorator struct {
+	next processing.ProcessorDecorator
+}
+
+func (d tracedDecorator) WrapProcessor(processor imagor.Processor) imagor.Processor {
+	name := fmt.Sprintf("%T", processor)
+	if d.next != nil {
+		processor = d.next.WrapProcessor(processor)
+	}
+	return &tracedProcessor{Processor: processor, name: name}
+}
+
+type tracedProcessor struct {
+	imagor.Processor
+	name string
+}
+
+func (p *tracedProcessor) Process(ctx context.Context, blob *imagor.Blob, params imagorpath.Params, load imagor.LoadFunc) (*imagor.Blob, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "imagor.process")
+	span.SetAttributes(
+		attribute.String("imagor.processor", p.name),
+		attribute.String("imagor.image", params.Image),
+		attribute.Int("imagor.width", params.Width),
+		attribute.Int("imagor.height", params.Height),
+		attribute.Int("imagor.filters", len(params.Filters)),
+	)
+	result, err := p.Processor.Process(ctx, blob, params, load)
+	// Forwarding passes the image on to the next processor, not a failure
+	if _, ok := err.(imagor.ErrForward); ok {
+		span.SetAttributes(attribute.Bool("imagor.forwarded", true))
+		span.End()
+		return result, err
+	}
+	tracing.End(span, err)
+	return result, err
+}
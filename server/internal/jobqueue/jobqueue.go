@@ -0,0 +1,312 @@
+// Package jobqueue schedules image processing by priority class so that
+// interactive thumbnail requests are not stuck behind preview regeneration
+// or bulk backfills.
+//
+// A Scheduler hands out a fixed number of processing slots. Waiting jobs are
+// admitted highest class first, and a number of slots is reserved for
+// interactive jobs: lower classes never occupy them, so an interactive request
+// always finds a free slot as soon as one interactive job completes instead of
+// waiting for a backlog of background work. Running jobs are not interrupted.
+// Lower class jobs waiting longer than the starvation limit are admitted
+// ahead of higher classes so a steady interactive load cannot stall them.
+package jobqueue
+
+import (
+	"context"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Class is the priority class of a job, lower values are served first
+type Class int
+
+const (
+	// Interactive is a request a user is waiting on, the default class
+	Interactive Class = iota
+	// RecentUpload is work triggered by a recent change, e.g. template previews
+	RecentUpload
+	// Background is bulk pre-generation and backfill
+	Background
+
+	numClasses = int(Background) + 1
+)
+
+// Classes lists all classes in priority order
+var Classes = []Class{Interactive, RecentUpload, Background}
+
+func (c Class) String() string {
+	switch c {
+	case Interactive:
+		return "interactive"
+	case RecentUpload:
+		return "recent_upload"
+	case Background:
+		return "background"
+	}
+	return "unknown"
+}
+
+// ParseClass parses a class name, reporting whether it is known
+func ParseClass(name string) (Class, bool) {
+	for _, c := range Classes {
+		if strings.EqualFold(strings.TrimSpace(name), c.String()) {
+			return c, true
+		}
+	}
+	return Interactive, false
+}
+
+// PriorityHeader lets callers of the imagor endpoint lower the priority of
+// their requests, e.g. X-Processing-Priority: background for a warm-up script.
+// Requests cannot be raised above Interactive, which is the default.
+const PriorityHeader = "X-Processing-Priority"
+
+// DefaultStarvationLimit is how long a lower class job may wait before it is
+// admitted ahead of higher classes
+const DefaultStarvationLimit = 10 * time.Second
+
+type classKey struct{}
+
+// WithClass returns a context whose processing runs in class c
+func WithClass(ctx context.Context, c Class) context.Context {
+	return context.WithValue(ctx, classKey{}, c)
+}
+
+// ClassFromContext returns the class of ctx, Interactive if unset
+func ClassFromContext(ctx context.Context) Class {
+	if c, ok := ctx.Value(classKey{}).(Class); ok {
+		return c
+	}
+	return Interactive
+}
+
+// ClassifyRequests sets the class of requests carrying PriorityHeader.
+// A class already set on the request context takes precedence.
+func ClassifyRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value(classKey{}).(Class); !ok {
+			if c, ok := ParseClass(r.Header.Get(PriorityHeader)); ok && c != Interactive {
+				r = r.WithContext(WithClass(r.Context(), c))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ClassStats are the counters of one class
+type ClassStats struct {
+	Class   Class
+	Waiting int
+	Running int
+	// Started counts jobs admitted since startup
+	Started int64
+	// Cancelled counts jobs whose context ended while waiting
+	Cancelled int64
+	TotalWait time.Duration
+	MaxWait   time.Duration
+}
+
+// AverageWait returns the mean queue wait of started jobs
+func (s ClassStats) AverageWait() time.Duration {
+	if s.Started == 0 {
+		return 0
+	}
+	return s.TotalWait / time.Duration(s.Started)
+}
+
+// Stats is a snapshot of the scheduler
+type Stats struct {
+	Slots         int
+	ReservedSlots int
+	Classes       []ClassStats
+}
+
+type waiter struct {
+	class    Class
+	enqueued time.Time
+	ready    chan struct{}
+	granted  bool
+}
+
+// Scheduler admits jobs into a fixed number of slots by priority class
+type Scheduler struct {
+	slots           int
+	reserved        int
+	starvationLimit time.Duration
+	now             func() time.Time
+
+	mu      sync.Mutex
+	queues  [numClasses][]*waiter
+	running [numClasses]int
+	stats   [numClasses]ClassStats
+}
+
+// Option configures a Scheduler
+type Option func(*Scheduler)
+
+// WithReservedSlots sets the number of slots only interactive jobs may use
+func WithReservedSlots(n int) Option {
+	return func(s *Scheduler) {
+		if n >= 0 {
+			s.reserved = n
+		}
+	}
+}
+
+// WithStarvationLimit sets how long lower class jobs wait at most before
+// being admitted ahead of higher classes
+func WithStarvationLimit(d time.Duration) Option {
+	return func(s *Scheduler) {
+		if d > 0 {
+			s.starvationLimit = d
+		}
+	}
+}
+
+// New creates a scheduler with the given number of slots, the number of
+// CPUs when slots is not positive. A quarter of the slots, at least one,
+// is reserved for interactive jobs unless configured otherwise.
+func New(slots int, opts ...Option) *Scheduler {
+	if slots <= 0 {
+		slots = runtime.NumCPU()
+	}
+	s := &Scheduler{
+		slots:           slots,
+		reserved:        max(1, slots/4),
+		starvationLimit: DefaultStarvationLimit,
+		now:             time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	// Lower classes always keep at least one slot
+	s.reserved = min(s.reserved, slots-1)
+	return s
+}
+
+// Acquire blocks until a slot is available for class c and returns the
+// function releasing it. It fails with the context error if ctx ends first.
+func (s *Scheduler) Acquire(ctx context.Context, c Class) (func(), error) {
+	if c < Interactive || int(c) >= numClasses {
+		c = Background
+	}
+	s.mu.Lock()
+	now := s.now()
+	w := &waiter{class: c, enqueued: now, ready: make(chan struct{})}
+	s.queues[c] = append(s.queues[c], w)
+	s.stats[c].Waiting++
+	s.dispatchLocked(now)
+	s.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return s.releaseFunc(c), nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if w.granted {
+			// Granted concurrently with the cancellation, hand the slot on
+			s.releaseLocked(c)
+		} else {
+			s.removeLocked(w)
+			s.stats[c].Cancelled++
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// Stats returns a snapshot of the counters of every class
+func (s *Scheduler) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := Stats{Slots: s.slots, ReservedSlots: s.reserved}
+	for _, c := range Classes {
+		cs := s.stats[c]
+		cs.Class = c
+		cs.Running = s.running[c]
+		stats.Classes = append(stats.Classes, cs)
+	}
+	return stats
+}
+
+func (s *Scheduler) releaseFunc(c Class) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.releaseLocked(c)
+		})
+	}
+}
+
+func (s *Scheduler) releaseLocked(c Class) {
+	s.running[c]--
+	s.dispatchLocked(s.now())
+}
+
+func (s *Scheduler) removeLocked(w *waiter) {
+	queue := s.queues[w.class]
+	for i, queued := range queue {
+		if queued == w {
+			s.queues[w.class] = append(queue[:i], queue[i+1:]...)
+			s.stats[w.class].Waiting--
+			return
+		}
+	}
+}
+
+// dispatchLocked admits waiting jobs while slots are free
+func (s *Scheduler) dispatchLocked(now time.Time) {
+	for {
+		c, ok := s.nextLocked(now)
+		if !ok {
+			return
+		}
+		w := s.queues[c][0]
+		s.queues[c] = s.queues[c][1:]
+		s.running[c]++
+		wait := now.Sub(w.enqueued)
+		stats := &s.stats[c]
+		stats.Waiting--
+		stats.Started++
+		stats.TotalWait += wait
+		stats.MaxWait = max(stats.MaxWait, wait)
+		w.granted = true
+		close(w.ready)
+	}
+}
+
+// nextLocked picks the class of the next job to admit
+func (s *Scheduler) nextLocked(now time.Time) (Class, bool) {
+	total := 0
+	for _, n := range s.running {
+		total += n
+	}
+	if total >= s.slots {
+		return 0, false
+	}
+	// Starved lower class jobs go first
+	for _, c := range Classes[1:] {
+		if len(s.queues[c]) > 0 && now.Sub(s.queues[c][0].enqueued) >= s.starvationLimit && total < s.limit(c) {
+			return c, true
+		}
+	}
+	for _, c := range Classes {
+		if len(s.queues[c]) > 0 && total < s.limit(c) {
+			return c, true
+		}
+	}
+	return 0, false
+}
+
+// limit is the number of occupied slots below which class c is admitted
+func (s *Scheduler) limit(c Class) int {
+	if c == Interactive {
+		return s.slots
+	}
+	return s.slots - s.reserved
+}
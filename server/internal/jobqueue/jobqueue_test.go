@@ -0,0 +1,152 @@
+package jobqueue
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func acquireAsync(s *Scheduler, ctx context.Context, c Class) <-chan func() {
+	ch := make(chan func(), 1)
+	go func() {
+		release, err := s.Acquire(ctx, c)
+		if err == nil {
+			ch <- release
+		}
+		close(ch)
+	}()
+	return ch
+}
+
+func waitForWaiting(t *testing.T, s *Scheduler, c Class, n int) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		return s.Stats().Classes[c].Waiting == n
+	}, time.Second, time.Millisecond)
+}
+
+func TestScheduler_InteractiveFirst(t *testing.T) {
+	s := New(1)
+	release, err := s.Acquire(context.Background(), Background)
+	require.NoError(t, err)
+
+	background := acquireAsync(s, context.Background(), Background)
+	waitForWaiting(t, s, Background, 1)
+	interactive := acquireAsync(s, context.Background(), Interactive)
+	waitForWaiting(t, s, Interactive, 1)
+
+	release()
+	releaseInteractive := <-interactive
+	require.NotNil(t, releaseInteractive)
+	select {
+	case <-background:
+		t.Fatal("background job admitted before the interactive one finished")
+	default:
+	}
+	releaseInteractive()
+	releaseBackground := <-background
+	require.NotNil(t, releaseBackground)
+	releaseBackground()
+
+	stats := s.Stats()
+	assert.Equal(t, int64(1), stats.Classes[Interactive].Started)
+	assert.Equal(t, int64(2), stats.Classes[Background].Started)
+	assert.Positive(t, stats.Classes[Background].MaxWait)
+}
+
+func TestScheduler_ReservedSlots(t *testing.T) {
+	s := New(2, WithReservedSlots(1))
+	release, err := s.Acquire(context.Background(), Background)
+	require.NoError(t, err)
+	defer release()
+
+	// The second slot is kept for interactive jobs
+	background := acquireAsync(s, context.Background(), RecentUpload)
+	waitForWaiting(t, s, RecentUpload, 1)
+	releaseInteractive, err := s.Acquire(context.Background(), Interactive)
+	require.NoError(t, err)
+	releaseInteractive()
+
+	select {
+	case <-background:
+		t.Fatal("lower class job used the reserved slot")
+	default:
+	}
+	release()
+	releaseBackground := <-background
+	require.NotNil(t, releaseBackground)
+	releaseBackground()
+}
+
+func TestScheduler_StarvationLimit(t *testing.T) {
+	s := New(1, WithStarvationLimit(time.Minute))
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	release, err := s.Acquire(context.Background(), Interactive)
+	require.NoError(t, err)
+	background := acquireAsync(s, context.Background(), Background)
+	waitForWaiting(t, s, Background, 1)
+	interactive := acquireAsync(s, context.Background(), Interactive)
+	waitForWaiting(t, s, Interactive, 1)
+
+	s.mu.Lock()
+	now = now.Add(2 * time.Minute)
+	s.mu.Unlock()
+	release()
+	releaseBackground := <-background
+	require.NotNil(t, releaseBackground)
+	releaseBackground()
+	releaseInteractive := <-interactive
+	require.NotNil(t, releaseInteractive)
+	releaseInteractive()
+}
+
+func TestScheduler_CancelWhileWaiting(t *testing.T) {
+	s := New(1)
+	release, err := s.Acquire(context.Background(), Interactive)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = s.Acquire(ctx, Background)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	release()
+	release() // releasing twice is a no-op
+	stats := s.Stats()
+	assert.Equal(t, int64(1), stats.Classes[Background].Cancelled)
+	assert.Zero(t, stats.Classes[Background].Waiting)
+	assert.Zero(t, stats.Classes[Interactive].Running)
+}
+
+func TestNew_Defaults(t *testing.T) {
+	s := New(8)
+	assert.Equal(t, 2, s.Stats().ReservedSlots)
+	assert.Equal(t, 0, New(1).Stats().ReservedSlots)
+	assert.Positive(t, New(0).Stats().Slots)
+}
+
+func TestClassifyRequests(t *testing.T) {
+	var got Class
+	handler := ClassifyRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = ClassFromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, Interactive, got)
+
+	req.Header.Set(PriorityHeader, "Background")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, Background, got)
+
+	req = req.WithContext(WithClass(req.Context(), RecentUpload))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, RecentUpload, got)
+}
@@ -0,0 +1,44 @@
+package jobqueue
+
+import (
+	"context"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/imagorpath"
+)
+
+// WrapProcessor implements processing.ProcessorDecorator, running every
+// Process call of next in a slot of the class of its context.
+func (s *Scheduler) WrapProcessor(next imagor.Processor) imagor.Processor {
+	return &scheduledProcessor{Processor: next, scheduler: s}
+}
+
+type scheduledProcessor struct {
+	imagor.Processor
+	scheduler *Scheduler
+}
+
+func (p *scheduledProcessor) Process(ctx context.Context, blob *imagor.Blob, params imagorpath.Params, load imagor.LoadFunc) (*imagor.Blob, error) {
+	class := ClassFromContext(ctx)
+	release, err := p.scheduler.Acquire(ctx, class)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { release() }()
+
+	// Nested images of the image() filter are processed through imagor again
+	// and need a slot of their own; give ours up while loading so a full
+	// scheduler cannot deadlock on them.
+	scheduledLoad := func(image string) (*imagor.Blob, error) {
+		release()
+		loaded, loadErr := load(image)
+		next, err := p.scheduler.Acquire(ctx, class)
+		if err != nil {
+			release = func() {}
+			return nil, err
+		}
+		release = next
+		return loaded, loadErr
+	}
+	return p.Processor.Process(ctx, blob, params, scheduledLoad)
+}
@@ -0,0 +1,90 @@
+package jobqueue
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor/imagorpath"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type nestedProcessor struct {
+	imagor.Processor
+	scheduler *Scheduler
+	running   []int
+}
+
+func (p *nestedProcessor) Process(ctx context.Context, blob *imagor.Blob, params imagorpath.Params, load imagor.LoadFunc) (*imagor.Blob, error) {
+	p.running = append(p.running, p.scheduler.Stats().Classes[ClassFromContext(ctx)].Running)
+	if params.Image == "outer" {
+		return load("inner")
+	}
+	return imagor.NewBlobFromBytes([]byte(params.Image)), nil
+}
+
+func TestWrapProcessor_ReleasesSlotForNestedLoads(t *testing.T) {
+	s := New(1)
+	inner := &nestedProcessor{scheduler: s}
+	wrapped := s.WrapProcessor(inner)
+
+	// A nested load goes through the wrapped processor again, which would
+	// deadlock on a single slot if the outer call kept holding it
+	var load imagor.LoadFunc
+	load = func(image string) (*imagor.Blob, error) {
+		return wrapped.Process(context.Background(), nil, imagorpath.Params{Image: image}, load)
+	}
+	ctx := WithClass(context.Background(), RecentUpload)
+	blob, err := wrapped.Process(ctx, nil, imagorpath.Params{Image: "outer"}, load)
+	require.NoError(t, err)
+	buf, err := blob.ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, "inner", string(buf))
+	assert.Equal(t, []int{1, 1}, inner.running)
+
+	stats := s.Stats()
+	// Admitted once initially and again after the nested load
+	assert.Equal(t, int64(2), stats.Classes[RecentUpload].Started)
+	assert.Equal(t, int64(1), stats.Classes[Interactive].Started)
+	assert.Zero(t, stats.Classes[RecentUpload].Running)
+}
+
+type blockingProcessor struct {
+	imagor.Processor
+	started chan Class
+	done    chan struct{}
+}
+
+func (p *blockingProcessor) Process(ctx context.Context, blob *imagor.Blob, params imagorpath.Params, load imagor.LoadFunc) (*imagor.Blob, error) {
+	p.started <- ClassFromContext(ctx)
+	<-p.done
+	return imagor.NewBlobFromBytes([]byte(params.Image)), nil
+}
+
+func TestWrapProcessor_BackgroundKeepsReservedSlots(t *testing.T) {
+	s := New(2, WithReservedSlots(1))
+	inner := &blockingProcessor{started: make(chan Class, 3), done: make(chan struct{})}
+	wrapped := s.WrapProcessor(inner)
+	process := func(ctx context.Context) {
+		_, _ = wrapped.Process(ctx, nil, imagorpath.Params{Image: "image"}, nil)
+	}
+
+	// Bulk renders run in the background class: one takes the unreserved
+	// slot, the next waits behind it
+	bulk := WithClass(context.Background(), Background)
+	go process(bulk)
+	assert.Equal(t, Background, <-inner.started)
+	go process(bulk)
+	waitForWaiting(t, s, Background, 1)
+
+	// An interactive request still finds the reserved slot free
+	go process(context.Background())
+	assert.Equal(t, Interactive, <-inner.started)
+	stats := s.Stats()
+	assert.Equal(t, 1, stats.Classes[Background].Running)
+	assert.Equal(t, 1, stats.Classes[Interactive].Running)
+
+	close(inner.done)
+	assert.Equal(t, Background, <-inner.started)
+}
@@ -0,0 +1,272 @@
+// Package labelhook hands new images to an external classification service,
+// such as an ML tagger running on the user's own hardware.
+//
+// For every image created or rewritten in the default storage, the hook
+// POSTs a JSON Request with a signed imagor URL of the image to the
+// configured webhook. The service downloads the image from that URL and
+// sends its labels back with the ingestLabels mutation, authenticated by an
+// API token, where they are stored as tags below TagParent.
+//
+// Delivery is best effort: requests are queued in memory, retried a few
+// times, and dropped when the queue is full or the server stops. A library
+// scan does not resend images, the service can list files itself to catch up.
+package labelhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/events"
+	"go.uber.org/zap"
+)
+
+const (
+	// TagParent is the tag labels are stored below, e.g. Labels/Dog
+	TagParent = "Labels"
+	// SignatureHeader carries the HMAC-SHA256 of the body when a secret is
+	// set, as "sha256=<hex>"
+	SignatureHeader = "X-Imagor-Studio-Signature"
+	// MaxLabels bounds the labels ingested for one image
+	MaxLabels = 50
+
+	defaultQueueSize  = 1000
+	defaultWorkers    = 2
+	defaultRetryDelay = 5 * time.Second
+	maxAttempts       = 3
+)
+
+// Event names of a Request
+const (
+	EventImageCreated = "image.created"
+	EventImageUpdated = "image.updated"
+)
+
+// imageExtensions are the images sent for classification, the formats
+// imagor decodes
+var imageExtensions = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".webp": true, ".avif": true,
+	".gif": true, ".tif": true, ".tiff": true, ".heic": true, ".heif": true,
+	".bmp": true,
+}
+
+// IsImage reports whether p is an image sent for classification
+func IsImage(p string) bool {
+	return imageExtensions[strings.ToLower(path.Ext(p))]
+}
+
+// Request is the JSON body posted to the webhook
+type Request struct {
+	Event string `json:"event"`
+	// Path of the image in the storage, to pass back to ingestLabels
+	Path string `json:"path"`
+	// ImageURL is a signed imagor URL of the image, at most 1024 pixels wide
+	// or high
+	ImageURL string `json:"imageUrl"`
+	// SentAt is the RFC3339 time of the first attempt
+	SentAt string `json:"sentAt"`
+}
+
+// ImageURLFunc returns the absolute URL the service downloads an image from
+type ImageURLFunc func(imagePath string) (string, error)
+
+// Hook posts images to the classification webhook
+type Hook struct {
+	url        string
+	secret     string
+	imageURL   ImageURLFunc
+	client     *http.Client
+	logger     *zap.Logger
+	queue      chan Request
+	workers    int
+	retryDelay time.Duration
+}
+
+// Option configures a Hook
+type Option func(*Hook)
+
+// WithSecret signs requests with HMAC-SHA256, see SignatureHeader
+func WithSecret(secret string) Option {
+	return func(h *Hook) {
+		h.secret = secret
+	}
+}
+
+// WithLogger sets the logger
+func WithLogger(logger *zap.Logger) Option {
+	return func(h *Hook) {
+		h.logger = logger
+	}
+}
+
+// WithClient sets the HTTP client posting requests
+func WithClient(client *http.Client) Option {
+	return func(h *Hook) {
+		h.client = client
+	}
+}
+
+// WithRetryDelay sets the wait before the first retry, doubled on each
+// following one
+func WithRetryDelay(delay time.Duration) Option {
+	return func(h *Hook) {
+		h.retryDelay = delay
+	}
+}
+
+// WithQueueSize bounds the requests waiting to be sent
+func WithQueueSize(size int) Option {
+	return func(h *Hook) {
+		if size > 0 {
+			h.queue = make(chan Request, size)
+		}
+	}
+}
+
+// New returns a hook posting to url
+func New(url string, imageURL ImageURLFunc, options ...Option) *Hook {
+	h := &Hook{
+		url:        url,
+		imageURL:   imageURL,
+		client:     &http.Client{Timeout: 30 * time.Second},
+		logger:     zap.NewNop(),
+		queue:      make(chan Request, defaultQueueSize),
+		workers:    defaultWorkers,
+		retryDelay: defaultRetryDelay,
+	}
+	for _, option := range options {
+		option(h)
+	}
+	return h
+}
+
+// Sign returns the SignatureHeader value of body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Run sends the images created or updated in scope until ctx is done
+func (h *Hook) Run(ctx context.Context, broker *events.Broker, scope string) {
+	changes := broker.Subscribe(ctx, func(e events.Event) bool {
+		return e.Scope == scope && (e.Kind == events.FileCreated || e.Kind == events.FileUpdated) && IsImage(e.Path)
+	})
+	var wg sync.WaitGroup
+	for range h.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case req := <-h.queue:
+					if err := h.send(ctx, req); err != nil {
+						h.logger.Warn("Failed to send image for labelling", zap.String("path", req.Path), zap.Error(err))
+					}
+				}
+			}
+		}()
+	}
+	for e := range changes {
+		event := EventImageCreated
+		if e.Kind == events.FileUpdated {
+			event = EventImageUpdated
+		}
+		h.Enqueue(event, e.Path)
+	}
+	wg.Wait()
+}
+
+// Enqueue queues an image to be sent, reporting false when the queue is full
+func (h *Hook) Enqueue(event, imagePath string) bool {
+	select {
+	case h.queue <- Request{Event: event, Path: imagePath, SentAt: time.Now().UTC().Format(time.RFC3339)}:
+		return true
+	default:
+		h.logger.Warn("Label hook queue full, dropping image", zap.String("path", imagePath))
+		return false
+	}
+}
+
+// send posts req, retrying failed attempts
+func (h *Hook) send(ctx context.Context, req Request) error {
+	imageURL, err := h.imageURL(req.Path)
+	if err != nil {
+		return fmt.Errorf("failed to generate image URL: %w", err)
+	}
+	req.ImageURL = imageURL
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	delay := h.retryDelay
+	for attempt := 1; ; attempt++ {
+		err = h.post(ctx, body)
+		if err == nil || attempt == maxAttempts {
+			return err
+		}
+		h.logger.Debug("Retrying label hook", zap.String("path", req.Path), zap.Int("attempt", attempt), zap.Error(err))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+func (h *Hook) post(ctx context.Context, body []byte) error {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if h.secret != "" {
+		httpReq.Header.Set(SignatureHeader, Sign(h.secret, body))
+	}
+	resp, err := h.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
+
+// TagNames returns the tags storing labels, below TagParent. Blank and
+// repeated labels are skipped, a label containing / becomes nested tags.
+func TagNames(labels []string) ([]string, error) {
+	if len(labels) > MaxLabels {
+		return nil, fmt.Errorf("at most %d labels are accepted per image", MaxLabels)
+	}
+	seen := make(map[string]bool, len(labels))
+	names := make([]string, 0, len(labels))
+	for _, label := range labels {
+		label = strings.Trim(strings.TrimSpace(label), "/")
+		key := strings.ToLower(label)
+		if label == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		names = append(names, TagParent+"/"+label)
+	}
+	return names, nil
+}
+
+// IsLabelTag reports whether a tag path stores a label
+func IsLabelTag(tagPath string) bool {
+	return len(tagPath) > len(TagParent) && strings.EqualFold(tagPath[:len(TagParent)+1], TagParent+"/")
+}
@@ -0,0 +1,109 @@
+package labelhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHook(t *testing.T) {
+	var attempts atomic.Int32
+	received := make(chan Request, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, Sign("s3cret", body), r.Header.Get(SignatureHeader))
+		// The first attempt fails, the retry goes through
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var req Request
+		assert.NoError(t, json.Unmarshal(body, &req))
+		received <- req
+	}))
+	defer srv.Close()
+
+	hook := New(srv.URL, func(imagePath string) (string, error) {
+		return "https://studio.example.com/imagor/signed/" + imagePath, nil
+	}, WithSecret("s3cret"), WithRetryDelay(time.Millisecond))
+	broker := events.NewBroker()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		hook.Run(ctx, broker, "system:global")
+		close(done)
+	}()
+	// Let Run subscribe
+	require.Eventually(t, func() bool {
+		broker.Publish(events.Event{Kind: events.FileCreated, Scope: "system:global", Path: "photos/a.jpg"})
+		select {
+		case req := <-received:
+			assert.Equal(t, EventImageCreated, req.Event)
+			assert.Equal(t, "photos/a.jpg", req.Path)
+			assert.Equal(t, "https://studio.example.com/imagor/signed/photos/a.jpg", req.ImageURL)
+			assert.NotEmpty(t, req.SentAt)
+			return true
+		case <-time.After(50 * time.Millisecond):
+			return false
+		}
+	}, 5*time.Second, time.Millisecond)
+
+	// Other storages, files and kinds are not sent
+	broker.Publish(events.Event{Kind: events.FileCreated, Scope: "space:1", Path: "b.jpg"})
+	broker.Publish(events.Event{Kind: events.FileCreated, Scope: "system:global", Path: "notes.txt"})
+	broker.Publish(events.Event{Kind: events.FileDeleted, Scope: "system:global", Path: "c.jpg"})
+	broker.Publish(events.Event{Kind: events.FileUpdated, Scope: "system:global", Path: "d.png"})
+	for {
+		req := <-received
+		if req.Path == "photos/a.jpg" {
+			continue // duplicates of the subscription probe
+		}
+		assert.Equal(t, EventImageUpdated, req.Event)
+		assert.Equal(t, "d.png", req.Path)
+		break
+	}
+	cancel()
+	<-done
+}
+
+func TestHook_GivesUp(t *testing.T) {
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	hook := New(srv.URL, func(imagePath string) (string, error) { return imagePath, nil }, WithRetryDelay(time.Millisecond))
+	err := hook.send(context.Background(), Request{Event: EventImageCreated, Path: "a.jpg"})
+	assert.ErrorContains(t, err, "500")
+	assert.Equal(t, int32(maxAttempts), attempts.Load())
+}
+
+func TestHook_QueueFull(t *testing.T) {
+	hook := New("http://localhost", nil, WithQueueSize(1))
+	assert.True(t, hook.Enqueue(EventImageCreated, "a.jpg"))
+	assert.False(t, hook.Enqueue(EventImageCreated, "b.jpg"))
+}
+
+func TestTagNames(t *testing.T) {
+	names, err := TagNames([]string{"Dog", " dog ", "", "Animals/Cat/", "Beach"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Labels/Dog", "Labels/Animals/Cat", "Labels/Beach"}, names)
+	_, err = TagNames(make([]string, MaxLabels+1))
+	assert.Error(t, err)
+
+	assert.True(t, IsLabelTag("Labels/Dog"))
+	assert.True(t, IsLabelTag("labels/dog"))
+	assert.False(t, IsLabelTag("Labels"))
+	assert.False(t, IsLabelTag("LabelsX/Dog"))
+	assert.False(t, IsLabelTag("Trips/Dog"))
+}
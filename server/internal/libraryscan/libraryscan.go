@@ -0,0 +1,360 @@
+// Package libraryscan walks the default storage to refresh what the server
+// keeps about it: cached folder listings, storage usage, the journal of file
+// changes, photo metadata and content hashes.
+//
+// Files copied into the storage behind the server's back, by rsync or a
+// sync client, otherwise only show once the cached listing of their folder
+// expires, and their metadata and hashes are only read when first asked
+// for. A scan brings all of them up to date, so new files list immediately
+// with their capture dates, and sorting or finding duplicates does not wait
+// on them. Scans run on a cron schedule from the registry and on demand.
+package libraryscan
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/dedupe"
+	"github.com/cshum/imagor-studio/server/internal/filejournal"
+	"github.com/cshum/imagor-studio/server/internal/filemeta"
+	"github.com/cshum/imagor-studio/server/internal/listcache"
+	"github.com/cshum/imagor-studio/server/internal/notify"
+	"github.com/cshum/imagor-studio/server/internal/operation"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/internal/storagestats"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"go.uber.org/zap"
+)
+
+const (
+	// OperationKind identifies library scans in the operations API
+	OperationKind = "library_scan"
+	// ScheduleKey holds the cron expression of scheduled scans, the registry
+	// key of the library-scan-schedule flag
+	ScheduleKey = "config.library_scan_schedule"
+)
+
+// metadataWorkers bounds the images read for metadata at once
+const metadataWorkers = 4
+
+// MetadataReader returns the imagor meta response of an image
+type MetadataReader func(ctx context.Context, imagePath string) ([]byte, error)
+
+// Scanner refreshes the indexes of a storage. Each index is optional and
+// skipped when not configured.
+type Scanner struct {
+	listCache  *listcache.Cache
+	stats      storagestats.Store
+	journal    filejournal.Store
+	fileMeta   filemeta.Store
+	readMeta   MetadataReader
+	duplicates *dedupe.Scanner
+	perceptual dedupe.PerceptualHasher
+	notifier   *notify.Notifier
+	logger     *zap.Logger
+}
+
+// Option configures a Scanner
+type Option func(*Scanner)
+
+// WithListCache refreshes the cached listings of folders that changed
+func WithListCache(cache *listcache.Cache) Option {
+	return func(s *Scanner) {
+		s.listCache = cache
+	}
+}
+
+// WithStats records the storage usage of every folder
+func WithStats(store storagestats.Store) Option {
+	return func(s *Scanner) {
+		s.stats = store
+	}
+}
+
+// WithJournal records the files created, updated or deleted since the
+// previous scan that the journal does not account for
+func WithJournal(store filejournal.Store) Option {
+	return func(s *Scanner) {
+		s.journal = store
+	}
+}
+
+// WithMetadata caches the metadata of images that have none cached for
+// their current version, read with readMeta
+func WithMetadata(store filemeta.Store, readMeta MetadataReader) Option {
+	return func(s *Scanner) {
+		s.fileMeta = store
+		s.readMeta = readMeta
+	}
+}
+
+// WithHashes records content hashes, and perceptual hashes of images when
+// perceptual is not nil, as scanDuplicates does
+func WithHashes(scanner *dedupe.Scanner, perceptual dedupe.PerceptualHasher) Option {
+	return func(s *Scanner) {
+		s.duplicates = scanner
+		s.perceptual = perceptual
+	}
+}
+
+// WithNotifier notifies admins of scans failed or completed with images
+// that could not be read
+func WithNotifier(notifier *notify.Notifier) Option {
+	return func(s *Scanner) {
+		s.notifier = notifier
+	}
+}
+
+// WithLogger sets the logger
+func WithLogger(logger *zap.Logger) Option {
+	return func(s *Scanner) {
+		s.logger = logger
+	}
+}
+
+// NewScanner returns a scanner refreshing the indexes enabled by options
+func NewScanner(options ...Option) *Scanner {
+	s := &Scanner{logger: zap.NewNop()}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+// Scan refreshes the indexes of stor, the default storage, reporting through
+// progress. Images imagor fails to read are skipped, they are retried on the
+// next scan.
+func (s *Scanner) Scan(ctx context.Context, stor storage.Storage, progress *operation.Progress) error {
+	failed, err := s.scan(ctx, stor, progress)
+	switch {
+	case err != nil && ctx.Err() == nil:
+		s.notifier.Notify(notify.Message{
+			Event: notify.EventScanErrors,
+			Title: "Library scan failed",
+			Body:  err.Error(),
+		})
+	case err == nil && failed > 0:
+		s.notifier.Notify(notify.Message{
+			Event: notify.EventScanErrors,
+			Title: "Library scan completed with errors",
+			Body:  fmt.Sprintf("The metadata of %d images could not be read. They are retried on the next scan.", failed),
+		})
+	}
+	return err
+}
+
+// scan runs Scan, returning the number of images whose metadata could not
+// be read
+func (s *Scanner) scan(ctx context.Context, stor storage.Storage, progress *operation.Progress) (failed int, err error) {
+	scope := registrystore.SystemOwnerID
+	progress.SetMessage("Listing folders")
+	scannedAt := time.Now()
+	files, folders, refreshed, err := s.walk(ctx, stor, scope)
+	if err != nil {
+		return 0, err
+	}
+	progress.Advance(0, fmt.Sprintf("listings: %d folders, %d refreshed", len(folders), refreshed))
+	s.logger.Debug("Library scan listed storage", zap.Int("files", len(files)), zap.Int("folders", len(folders)), zap.Int("refreshed", refreshed))
+
+	if s.stats != nil {
+		if err := s.stats.Replace(ctx, scope, storagestats.Compute(files, folders, scannedAt)); err != nil {
+			return 0, fmt.Errorf("storage stats: %w", err)
+		}
+		progress.Advance(0, fmt.Sprintf("usage: %d folders", len(folders)))
+	}
+
+	if s.journal != nil {
+		recorded, err := s.journal.Reconcile(ctx, files, scannedAt)
+		if err != nil {
+			return 0, fmt.Errorf("file journal: %w", err)
+		}
+		progress.Advance(0, fmt.Sprintf("journal: %d changes", recorded))
+	}
+
+	if s.fileMeta != nil && s.readMeta != nil {
+		var read int
+		read, failed, err = s.refreshMetadata(ctx, scope, files, progress)
+		if err != nil {
+			return failed, err
+		}
+		progress.Advance(0, fmt.Sprintf("metadata: %d images read, %d failed", read, failed))
+	}
+
+	if s.duplicates != nil {
+		if err := s.duplicates.Scan(ctx, stor, scope, "", s.perceptual, progress); err != nil {
+			return failed, fmt.Errorf("hashes: %w", err)
+		}
+	}
+	progress.SetMessage(fmt.Sprintf("Scanned %d files in %d folders", len(files), len(folders)))
+	return failed, nil
+}
+
+// walk lists every folder not hidden, refreshing the cached listings that
+// differ from storage, and returns the files and folders found
+func (s *Scanner) walk(ctx context.Context, stor storage.Storage, scope string) (files []storage.FileInfo, folders []string, refreshed int, err error) {
+	var visit func(folder string) error
+	visit = func(folder string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		result, err := stor.List(ctx, folder, storage.ListOptions{ShowHidden: true})
+		if err != nil {
+			return fmt.Errorf("failed to list %q: %w", folder, err)
+		}
+		folders = append(folders, folder)
+		if s.refreshListing(ctx, scope, folder, result.Items) {
+			refreshed++
+		}
+		for _, item := range result.Items {
+			if storage.IsHiddenFile(item.Name) {
+				continue
+			}
+			if item.IsDir {
+				if err := visit(item.Path); err != nil {
+					return err
+				}
+				continue
+			}
+			files = append(files, item)
+		}
+		return nil
+	}
+	err = visit("")
+	return files, folders, refreshed, err
+}
+
+// refreshListing replaces the cached listing of folder when it differs from
+// items, reporting whether it did. Folders not cached are left for the
+// listing cache to load when browsed.
+func (s *Scanner) refreshListing(ctx context.Context, scope, folder string, items []storage.FileInfo) bool {
+	if s.listCache == nil {
+		return false
+	}
+	cached, ok := s.listCache.Get(ctx, scope, folder)
+	if !ok || cached.ETag == listcache.ETag(items) {
+		return false
+	}
+	s.listCache.Invalidate(ctx, scope, folder)
+	_, _ = s.listCache.Load(ctx, scope, folder, func() ([]storage.FileInfo, error) {
+		return items, nil
+	})
+	return true
+}
+
+// refreshMetadata reads the metadata of the images lacking it for their
+// current version. Progress counts are left to the hash scan, which sets its
+// own total.
+func (s *Scanner) refreshMetadata(ctx context.Context, scope string, files []storage.FileInfo, progress *operation.Progress) (read, failed int, err error) {
+	fingerprints := make(map[string]string)
+	for _, info := range files {
+		if filemeta.HasExif(info.Name) {
+			fingerprints[info.Path] = filemeta.Fingerprint(info)
+		}
+	}
+	if len(fingerprints) == 0 {
+		return 0, 0, nil
+	}
+	cached, err := s.fileMeta.GetMulti(ctx, scope, fingerprints)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read cached metadata: %w", err)
+	}
+	var missing []string
+	for p := range fingerprints {
+		if _, ok := cached[p]; !ok {
+			missing = append(missing, p)
+		}
+	}
+	sort.Strings(missing)
+	progress.SetMessage(fmt.Sprintf("Reading metadata of %d images", len(missing)))
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	paths := make(chan string)
+	for range min(metadataWorkers, len(missing)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range paths {
+				err := s.extractMetadata(ctx, scope, p, fingerprints[p])
+				if err != nil && ctx.Err() == nil {
+					s.logger.Debug("Failed to read file metadata", zap.String("path", p), zap.Error(err))
+				}
+				mu.Lock()
+				if err != nil {
+					failed++
+				} else {
+					read++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, p := range missing {
+		if ctx.Err() != nil {
+			break
+		}
+		paths <- p
+	}
+	close(paths)
+	wg.Wait()
+	return read, failed, ctx.Err()
+}
+
+func (s *Scanner) extractMetadata(ctx context.Context, scope, path, fingerprint string) error {
+	body, err := s.readMeta(ctx, path)
+	if err != nil {
+		return err
+	}
+	metadata, err := filemeta.Parse(body)
+	if err != nil {
+		return err
+	}
+	return s.fileMeta.Put(ctx, scope, path, fingerprint, metadata)
+}
+
+// Job scans the default storage through the operation manager
+type Job struct {
+	scanner    *Scanner
+	storage    func() storage.Storage
+	operations *operation.Manager
+	logger     *zap.Logger
+}
+
+// NewJob returns a job scanning the storage returned by stor
+func NewJob(scanner *Scanner, stor func() storage.Storage, operations *operation.Manager, logger *zap.Logger) *Job {
+	return &Job{scanner: scanner, storage: stor, operations: operations, logger: logger}
+}
+
+// Start begins a scan of the default storage on behalf of ownerID. If a scan
+// is already in progress it is returned instead of starting another.
+func (j *Job) Start(ctx context.Context, ownerID string) operation.Operation {
+	op, started := j.operations.StartExclusive(ctx, OperationKind, ownerID, func(ctx context.Context, progress *operation.Progress) error {
+		stor := j.storage()
+		if stor == nil {
+			return fmt.Errorf("storage is not configured")
+		}
+		return j.scanner.Scan(ctx, stor, progress)
+	})
+	if started {
+		j.logger.Info("Library scan started", zap.String("id", op.ID), zap.String("owner", ownerID))
+	}
+	return op
+}
+
+// Sync starts a scheduled scan, for use with the scheduler
+func (j *Job) Sync() error {
+	j.Start(context.Background(), registrystore.SystemOwnerID)
+	return nil
+}
+
+// LastRun returns the most recent scan, false when there was none
+func (j *Job) LastRun() (operation.Operation, bool) {
+	ops := j.operations.List(OperationKind)
+	if len(ops) == 0 {
+		return operation.Operation{}, false
+	}
+	return ops[0], true
+}
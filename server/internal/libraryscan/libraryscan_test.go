@@ -0,0 +1,245 @@
+package libraryscan
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/dedupe"
+	"github.com/cshum/imagor-studio/server/internal/events"
+	"github.com/cshum/imagor-studio/server/internal/filejournal"
+	"github.com/cshum/imagor-studio/server/internal/filemeta"
+	"github.com/cshum/imagor-studio/server/internal/listcache"
+	"github.com/cshum/imagor-studio/server/internal/notify"
+	"github.com/cshum/imagor-studio/server/internal/operation"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/internal/storagestats"
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const scope = registrystore.SystemOwnerID
+
+func writeFile(t *testing.T, baseDir, path, content string) {
+	t.Helper()
+	fullPath := filepath.Join(baseDir, path)
+	require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0755))
+	require.NoError(t, os.WriteFile(fullPath, []byte(content), 0644))
+}
+
+func scan(t *testing.T, job *Job) operation.Operation {
+	t.Helper()
+	started := job.Start(context.Background(), "admin")
+	op, err := job.operations.Wait(context.Background(), started.ID)
+	require.NoError(t, err)
+	return op
+}
+
+// metaReader serves imagor meta responses, failing for broken images
+type metaReader struct {
+	mu   sync.Mutex
+	read []string
+}
+
+func (r *metaReader) Read(_ context.Context, imagePath string) ([]byte, error) {
+	r.mu.Lock()
+	r.read = append(r.read, imagePath)
+	r.mu.Unlock()
+	if filepath.Base(imagePath) == "broken.jpg" {
+		return nil, fmt.Errorf("imagor returned status 415")
+	}
+	return []byte(`{"format":"jpeg","width":40,"height":30,"exif":{"Model":"X100V"}}`), nil
+}
+
+func TestJob_Scan(t *testing.T) {
+	baseDir := t.TempDir()
+	writeFile(t, baseDir, "photos/a.jpg", "one")
+	writeFile(t, baseDir, "photos/broken.jpg", "two")
+	writeFile(t, baseDir, "photos/notes.txt", "three")
+	writeFile(t, baseDir, ".hidden/c.jpg", "four")
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+
+	db := testutil.NewDB(t)
+	ctx := context.Background()
+	cache := listcache.New(listcache.WithTTL(time.Hour))
+	fileMeta := filemeta.NewStore(db, zap.NewNop())
+	hashes := dedupe.NewStore(db, zap.NewNop())
+	reader := &metaReader{}
+	scanner := NewScanner(
+		WithListCache(cache),
+		WithMetadata(fileMeta, reader.Read),
+		WithHashes(dedupe.NewScanner(hashes, zap.NewNop()), nil),
+	)
+	operations := operation.NewManager(zap.NewNop())
+	job := NewJob(scanner, func() storage.Storage { return stor }, operations, zap.NewNop())
+
+	// A listing cached before a file is copied in behind the server's back
+	list := func(folder string) func() ([]storage.FileInfo, error) {
+		return func() ([]storage.FileInfo, error) {
+			result, err := stor.List(ctx, folder, storage.ListOptions{ShowHidden: true})
+			return result.Items, err
+		}
+	}
+	stale, err := cache.Load(ctx, scope, "photos", list("photos"))
+	require.NoError(t, err)
+	require.Len(t, stale.Items, 3)
+	writeFile(t, baseDir, "photos/b.jpg", "five")
+
+	_, ok := job.LastRun()
+	assert.False(t, ok)
+	op := scan(t, job)
+	assert.Equal(t, operation.StatusSucceeded, op.Status, op.Error)
+	assert.Equal(t, OperationKind, op.Kind)
+	assert.Equal(t, "Scanned 4 files in 2 folders", op.Message)
+	assert.Contains(t, op.Results, "listings: 2 folders, 1 refreshed")
+	assert.Contains(t, op.Results, "metadata: 2 images read, 1 failed")
+	lastRun, ok := job.LastRun()
+	require.True(t, ok)
+	assert.Equal(t, op.ID, lastRun.ID)
+
+	listing, ok := cache.Get(ctx, scope, "photos")
+	require.True(t, ok)
+	assert.Len(t, listing.Items, 4)
+
+	info, err := stor.Stat(ctx, "photos/b.jpg")
+	require.NoError(t, err)
+	metadata, err := fileMeta.Get(ctx, scope, "photos/b.jpg", filemeta.Fingerprint(info))
+	require.NoError(t, err)
+	require.NotNil(t, metadata)
+	assert.Equal(t, "X100V", metadata.CameraModel)
+
+	entries, err := hashes.List(ctx, scope, "")
+	require.NoError(t, err)
+	assert.Len(t, entries, 4)
+
+	// Cached metadata is not read again, the broken image is retried
+	reader.read = nil
+	op = scan(t, job)
+	assert.Equal(t, operation.StatusSucceeded, op.Status, op.Error)
+	assert.Contains(t, op.Results, "listings: 2 folders, 0 refreshed")
+	assert.Equal(t, []string{"photos/broken.jpg"}, reader.read)
+}
+
+// ntfyConfig points notifications to an ntfy topic
+type ntfyConfig string
+
+func (c ntfyConfig) GetByRegistryKey(key string) (string, bool) {
+	if key == notify.NtfyURLKey {
+		return string(c), true
+	}
+	return "", false
+}
+
+func (c ntfyConfig) IsEmbeddedMode() bool { return false }
+
+func TestJob_ScanNotifiesErrors(t *testing.T) {
+	titles := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		titles <- r.Header.Get("Title")
+	}))
+	defer srv.Close()
+
+	baseDir := t.TempDir()
+	writeFile(t, baseDir, "photos/broken.jpg", "one")
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+	reader := &metaReader{}
+	scanner := NewScanner(
+		WithMetadata(filemeta.NewStore(testutil.NewDB(t), zap.NewNop()), reader.Read),
+		WithNotifier(notify.New(zap.NewNop(), nil, ntfyConfig(srv.URL+"/alerts"))),
+	)
+	job := NewJob(scanner, func() storage.Storage { return stor }, operation.NewManager(zap.NewNop()), zap.NewNop())
+
+	op := scan(t, job)
+	assert.Equal(t, operation.StatusSucceeded, op.Status, op.Error)
+	select {
+	case title := <-titles:
+		assert.Equal(t, "Library scan completed with errors", title)
+	case <-time.After(5 * time.Second):
+		t.Fatal("admins not notified")
+	}
+}
+
+func TestJob_ScanStats(t *testing.T) {
+	baseDir := t.TempDir()
+	writeFile(t, baseDir, "a.jpg", "one")
+	writeFile(t, baseDir, "photos/b.jpg", "two")
+	writeFile(t, baseDir, "photos/trip/c.mp4", "three")
+	writeFile(t, baseDir, ".hidden/d.jpg", "four")
+	require.NoError(t, os.MkdirAll(filepath.Join(baseDir, "empty"), 0755))
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+
+	stats := storagestats.NewStore(testutil.NewDB(t), zap.NewNop())
+	job := NewJob(NewScanner(WithStats(stats)), func() storage.Storage { return stor }, operation.NewManager(zap.NewNop()), zap.NewNop())
+	op := scan(t, job)
+	assert.Equal(t, operation.StatusSucceeded, op.Status, op.Error)
+	assert.Contains(t, op.Results, "usage: 4 folders")
+
+	ctx := context.Background()
+	root, err := stats.Get(ctx, scope, "")
+	require.NoError(t, err)
+	require.NotNil(t, root)
+	assert.Equal(t, int64(3), root.FileCount, "hidden files are not counted")
+	assert.Equal(t, int64(11), root.TotalSize)
+	photos, err := stats.Get(ctx, scope, "photos")
+	require.NoError(t, err)
+	assert.Equal(t, int64(8), photos.TotalSize)
+	assert.Equal(t, []storagestats.ExtensionUsage{
+		{Extension: "mp4", FileCount: 1, TotalSize: 5},
+		{Extension: "jpg", FileCount: 1, TotalSize: 3},
+	}, photos.Extensions)
+	empty, err := stats.Get(ctx, scope, "empty")
+	require.NoError(t, err)
+	require.NotNil(t, empty)
+	assert.Zero(t, empty.FileCount)
+}
+
+func TestJob_ScanJournal(t *testing.T) {
+	baseDir := t.TempDir()
+	writeFile(t, baseDir, "photos/a.jpg", "one")
+	writeFile(t, baseDir, "photos/b.jpg", "two")
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+
+	journal := filejournal.New(testutil.NewDB(t), zap.NewNop())
+	job := NewJob(NewScanner(WithJournal(journal)), func() storage.Storage { return stor }, operation.NewManager(zap.NewNop()), zap.NewNop())
+	op := scan(t, job)
+	assert.Equal(t, operation.StatusSucceeded, op.Status, op.Error)
+	assert.Contains(t, op.Results, "journal: 0 changes")
+
+	writeFile(t, baseDir, "photos/c.jpg", "three")
+	require.NoError(t, os.Remove(filepath.Join(baseDir, "photos/a.jpg")))
+	op = scan(t, job)
+	assert.Equal(t, operation.StatusSucceeded, op.Status, op.Error)
+	assert.Contains(t, op.Results, "journal: 2 changes")
+
+	ctx := context.Background()
+	latest, err := journal.Latest(ctx)
+	require.NoError(t, err)
+	entries, _, err := journal.List(ctx, 0, latest, "photos", 0)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, events.FileCreated, entries[0].Kind)
+	assert.Equal(t, "photos/c.jpg", entries[0].Path)
+	assert.Equal(t, events.FileDeleted, entries[1].Kind)
+	assert.Equal(t, "photos/a.jpg", entries[1].Path)
+}
+
+func TestJob_StorageNotConfigured(t *testing.T) {
+	job := NewJob(NewScanner(), func() storage.Storage { return nil }, operation.NewManager(zap.NewNop()), zap.NewNop())
+	op := scan(t, job)
+	assert.Equal(t, operation.StatusFailed, op.Status)
+	assert.Equal(t, "storage is not configured", op.Error)
+}
@@ -0,0 +1,317 @@
+// Package listcache caches full folder listings, so paging through a large
+// folder of a slow backend such as S3 lists it once. Filtering, sorting and
+// paging are applied to the cached listing.
+//
+// Listings are held in memory, least recently used first out, and optionally
+// persisted in the database to survive restarts. Entries expire after a TTL
+// and are invalidated by the changes made through the server.
+package listcache
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultTTL is how long a listing is served from the cache
+	DefaultTTL = time.Minute
+	// DefaultMaxItems bounds the file entries held in memory across listings
+	DefaultMaxItems = 200000
+)
+
+// Listing is the full listing of a folder
+type Listing struct {
+	Items []storage.FileInfo
+	// ETag changes whenever an entry of the folder is added, removed or
+	// modified
+	ETag     string
+	CachedAt time.Time
+}
+
+// Store persists listings
+type Store interface {
+	// Get returns the listing of folder, nil when not cached
+	Get(ctx context.Context, scope, folder string) (*Listing, error)
+	Put(ctx context.Context, scope, folder string, listing *Listing) error
+	// Remove drops the listing of folder, and of every folder below it when
+	// tree is set
+	Remove(ctx context.Context, scope, folder string, tree bool) error
+}
+
+type key struct {
+	scope  string
+	folder string
+}
+
+type entry struct {
+	key     key
+	listing *Listing
+}
+
+// Cache caches folder listings by scope and folder path
+type Cache struct {
+	ttl      time.Duration
+	maxItems int
+	store    Store
+	logger   *zap.Logger
+
+	mu      sync.Mutex
+	lru     *list.List // most recently used first
+	entries map[key]*list.Element
+	items   int
+	// invalidations counts Invalidate and Purge calls, so a listing fetched
+	// while one happened is not cached
+	invalidations uint64
+	// storeMu orders store writes after the invalidation checks
+	storeMu sync.Mutex
+}
+
+// Option configures a Cache
+type Option func(*Cache)
+
+// WithTTL sets how long a listing is served from the cache
+func WithTTL(ttl time.Duration) Option {
+	return func(c *Cache) {
+		if ttl > 0 {
+			c.ttl = ttl
+		}
+	}
+}
+
+// WithMaxItems bounds the file entries held in memory across listings
+func WithMaxItems(n int) Option {
+	return func(c *Cache) {
+		if n > 0 {
+			c.maxItems = n
+		}
+	}
+}
+
+// WithStore persists listings in store
+func WithStore(store Store) Option {
+	return func(c *Cache) {
+		c.store = store
+	}
+}
+
+// WithLogger sets the logger
+func WithLogger(logger *zap.Logger) Option {
+	return func(c *Cache) {
+		c.logger = logger
+	}
+}
+
+// New creates a cache
+func New(options ...Option) *Cache {
+	c := &Cache{
+		ttl:      DefaultTTL,
+		maxItems: DefaultMaxItems,
+		logger:   zap.NewNop(),
+		lru:      list.New(),
+		entries:  make(map[key]*list.Element),
+	}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// Load returns the cached listing of folder, or lists it with fetch and
+// caches the result
+func (c *Cache) Load(ctx context.Context, scope, folder string, fetch func() ([]storage.FileInfo, error)) (*Listing, error) {
+	if listing, ok := c.Get(ctx, scope, folder); ok {
+		return listing, nil
+	}
+	c.mu.Lock()
+	invalidations := c.invalidations
+	c.mu.Unlock()
+	items, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	listing := &Listing{Items: items, ETag: ETag(items), CachedAt: time.Now()}
+	c.put(ctx, key{scope, normalize(folder)}, listing, invalidations)
+	return listing, nil
+}
+
+// Get returns the cached listing of folder, or false when missing or expired
+func (c *Cache) Get(ctx context.Context, scope, folder string) (*Listing, bool) {
+	k := key{scope, normalize(folder)}
+	c.mu.Lock()
+	if el, ok := c.entries[k]; ok {
+		listing := el.Value.(*entry).listing
+		if c.fresh(listing) {
+			c.lru.MoveToFront(el)
+			c.mu.Unlock()
+			return listing, true
+		}
+		c.removeLocked(el)
+	}
+	c.mu.Unlock()
+
+	if c.store == nil {
+		return nil, false
+	}
+	listing, err := c.store.Get(ctx, k.scope, k.folder)
+	if err != nil {
+		c.logger.Warn("Failed to load cached listing", zap.String("folder", k.folder), zap.Error(err))
+		return nil, false
+	}
+	if listing == nil || !c.fresh(listing) {
+		return nil, false
+	}
+	c.mu.Lock()
+	c.addLocked(k, listing)
+	c.mu.Unlock()
+	return listing, true
+}
+
+// put caches a listing unless the cache was invalidated since the listing
+// was fetched, as it may predate the change
+func (c *Cache) put(ctx context.Context, k key, listing *Listing, invalidations uint64) {
+	c.mu.Lock()
+	if c.invalidations != invalidations {
+		c.mu.Unlock()
+		return
+	}
+	if el, ok := c.entries[k]; ok {
+		c.removeLocked(el)
+	}
+	c.addLocked(k, listing)
+	c.mu.Unlock()
+	if c.store == nil {
+		return
+	}
+	c.storeMu.Lock()
+	defer c.storeMu.Unlock()
+	c.mu.Lock()
+	stale := c.invalidations != invalidations
+	c.mu.Unlock()
+	if stale {
+		return
+	}
+	if err := c.store.Put(ctx, k.scope, k.folder, listing); err != nil {
+		c.logger.Warn("Failed to persist listing", zap.String("folder", k.folder), zap.Error(err))
+	}
+}
+
+// Invalidate drops the listings a change to p affects: its parent folder,
+// and p itself with every folder below it
+func (c *Cache) Invalidate(ctx context.Context, scope, p string) {
+	p = normalize(p)
+	if p == "" {
+		c.Purge(ctx, scope)
+		return
+	}
+	parent := normalize(path.Dir(p))
+	if parent == "." {
+		parent = ""
+	}
+	c.mu.Lock()
+	c.invalidations++
+	for k, el := range c.entries {
+		if k.scope == scope && (k.folder == parent || within(k.folder, p)) {
+			c.removeLocked(el)
+		}
+	}
+	c.mu.Unlock()
+	if c.store == nil {
+		return
+	}
+	c.storeMu.Lock()
+	defer c.storeMu.Unlock()
+	if err := c.store.Remove(ctx, scope, parent, false); err != nil {
+		c.logger.Warn("Failed to drop cached listing", zap.String("folder", parent), zap.Error(err))
+	}
+	if err := c.store.Remove(ctx, scope, p, true); err != nil {
+		c.logger.Warn("Failed to drop cached listing", zap.String("folder", p), zap.Error(err))
+	}
+}
+
+// Purge drops every listing of scope, such as after the storage behind it
+// is reconfigured
+func (c *Cache) Purge(ctx context.Context, scope string) {
+	c.mu.Lock()
+	c.invalidations++
+	for k, el := range c.entries {
+		if k.scope == scope {
+			c.removeLocked(el)
+		}
+	}
+	c.mu.Unlock()
+	if c.store == nil {
+		return
+	}
+	c.storeMu.Lock()
+	defer c.storeMu.Unlock()
+	if err := c.store.Remove(ctx, scope, "", true); err != nil {
+		c.logger.Warn("Failed to drop cached listings", zap.String("scope", scope), zap.Error(err))
+	}
+}
+
+func (c *Cache) fresh(listing *Listing) bool {
+	return time.Since(listing.CachedAt) < c.ttl
+}
+
+// addLocked adds a listing, evicting the least recently used ones beyond
+// maxItems. A listing larger than maxItems on its own is not held in memory.
+func (c *Cache) addLocked(k key, listing *Listing) {
+	if len(listing.Items) > c.maxItems {
+		return
+	}
+	c.entries[k] = c.lru.PushFront(&entry{key: k, listing: listing})
+	c.items += len(listing.Items)
+	for c.items > c.maxItems {
+		c.removeLocked(c.lru.Back())
+	}
+}
+
+func (c *Cache) removeLocked(el *list.Element) {
+	e := c.lru.Remove(el).(*entry)
+	delete(c.entries, e.key)
+	c.items -= len(e.listing.Items)
+}
+
+// Apply filters, sorts and pages a full listing as storage backends do
+func Apply(items []storage.FileInfo, options storage.ListOptions) storage.ListResult {
+	filtered := make([]storage.FileInfo, 0, len(items))
+	for _, item := range items {
+		if storage.ShouldIncludeFile(item.Name, item.IsDir, options) {
+			filtered = append(filtered, item)
+		}
+	}
+	storage.SortFileInfos(filtered, options.SortBy, options.SortOrder)
+	total := len(filtered)
+	start := min(max(options.Offset, 0), total)
+	end := total
+	if options.Limit > 0 {
+		end = min(start+options.Limit, total)
+	}
+	return storage.ListResult{Items: filtered[start:end], TotalCount: total}
+}
+
+// ETag fingerprints a listing
+func ETag(items []storage.FileInfo) string {
+	b, _ := json.Marshal(items)
+	sum := sha256.Sum256(b)
+	return fmt.Sprintf("%x", sum[:8])
+}
+
+func normalize(folder string) string {
+	return strings.Trim(folder, "/")
+}
+
+// within reports whether folder is p or below it
+func within(folder, p string) bool {
+	return folder == p || strings.HasPrefix(folder, p+"/")
+}
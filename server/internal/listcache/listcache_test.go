@@ -0,0 +1,185 @@
+package listcache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const scope = "system:global"
+
+// countingFetch lists n files and counts the calls
+func countingFetch(calls *int, n int) func() ([]storage.FileInfo, error) {
+	return func() ([]storage.FileInfo, error) {
+		*calls++
+		items := make([]storage.FileInfo, n)
+		for i := range items {
+			items[i] = storage.FileInfo{Name: fmt.Sprintf("%d.jpg", i), Size: int64(i)}
+		}
+		return items, nil
+	}
+}
+
+func TestCache_Load(t *testing.T) {
+	c := New()
+	ctx := context.Background()
+	var calls int
+
+	first, err := c.Load(ctx, scope, "/photos/", countingFetch(&calls, 3))
+	require.NoError(t, err)
+	second, err := c.Load(ctx, scope, "photos", countingFetch(&calls, 3))
+	require.NoError(t, err)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, first, second)
+	assert.Len(t, second.Items, 3)
+	assert.NotEmpty(t, second.ETag)
+
+	// Scopes are isolated
+	_, err = c.Load(ctx, "space:1", "photos", countingFetch(&calls, 3))
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls)
+
+	// Errors are not cached
+	_, err = c.Load(ctx, scope, "broken", func() ([]storage.FileInfo, error) { return nil, assert.AnError })
+	assert.ErrorIs(t, err, assert.AnError)
+	_, ok := c.Get(ctx, scope, "broken")
+	assert.False(t, ok)
+}
+
+func TestCache_TTL(t *testing.T) {
+	c := New(WithTTL(50 * time.Millisecond))
+	ctx := context.Background()
+	var calls int
+	_, err := c.Load(ctx, scope, "photos", countingFetch(&calls, 1))
+	require.NoError(t, err)
+	time.Sleep(60 * time.Millisecond)
+	_, ok := c.Get(ctx, scope, "photos")
+	assert.False(t, ok)
+	_, err = c.Load(ctx, scope, "photos", countingFetch(&calls, 1))
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls)
+}
+
+func TestCache_Invalidate(t *testing.T) {
+	c := New()
+	ctx := context.Background()
+	var calls int
+	for _, folder := range []string{"", "photos", "photos/2024", "photos/2024/june", "photos-old", "docs"} {
+		_, err := c.Load(ctx, scope, folder, countingFetch(&calls, 1))
+		require.NoError(t, err)
+	}
+
+	c.Invalidate(ctx, scope, "photos/2024")
+	for folder, cached := range map[string]bool{
+		"":                 true,
+		"photos":           false,
+		"photos/2024":      false,
+		"photos/2024/june": false,
+		"photos-old":       true,
+		"docs":             true,
+	} {
+		_, ok := c.Get(ctx, scope, folder)
+		assert.Equal(t, cached, ok, folder)
+	}
+
+	c.Invalidate(ctx, scope, "docs")
+	_, ok := c.Get(ctx, scope, "")
+	assert.False(t, ok, "top level entries invalidate the root listing")
+
+	c.Purge(ctx, scope)
+	_, ok = c.Get(ctx, scope, "photos-old")
+	assert.False(t, ok)
+}
+
+func TestCache_InvalidateDuringFetch(t *testing.T) {
+	c := New()
+	ctx := context.Background()
+	listing, err := c.Load(ctx, scope, "photos", func() ([]storage.FileInfo, error) {
+		// an upload completes while the folder is being listed
+		c.Invalidate(ctx, scope, "photos/new.jpg")
+		return []storage.FileInfo{{Name: "old.jpg"}}, nil
+	})
+	require.NoError(t, err)
+	assert.Len(t, listing.Items, 1)
+	_, ok := c.Get(ctx, scope, "photos")
+	assert.False(t, ok, "a listing that may predate a change is not cached")
+}
+
+func TestCache_MaxItems(t *testing.T) {
+	c := New(WithMaxItems(10))
+	ctx := context.Background()
+	var calls int
+	for _, folder := range []string{"a", "b", "c"} {
+		_, err := c.Load(ctx, scope, folder, countingFetch(&calls, 4))
+		require.NoError(t, err)
+	}
+	_, ok := c.Get(ctx, scope, "a")
+	assert.False(t, ok, "least recently used listing is evicted")
+	_, ok = c.Get(ctx, scope, "b")
+	assert.True(t, ok)
+
+	// Used recently, b outlives c
+	_, err := c.Load(ctx, scope, "d", countingFetch(&calls, 4))
+	require.NoError(t, err)
+	_, ok = c.Get(ctx, scope, "b")
+	assert.True(t, ok)
+	_, ok = c.Get(ctx, scope, "c")
+	assert.False(t, ok)
+
+	// Listings larger than the cache are served but not held
+	_, err = c.Load(ctx, scope, "huge", countingFetch(&calls, 11))
+	require.NoError(t, err)
+	_, ok = c.Get(ctx, scope, "huge")
+	assert.False(t, ok)
+}
+
+func TestApply(t *testing.T) {
+	items := []storage.FileInfo{
+		{Name: "b.jpg", Size: 1},
+		{Name: "sub", IsDir: true},
+		{Name: ".hidden.jpg", Size: 3},
+		{Name: "a.png", Size: 2},
+		{Name: "c.jpg", Size: 5},
+	}
+
+	result := Apply(items, storage.ListOptions{})
+	assert.Equal(t, 4, result.TotalCount)
+	assert.Equal(t, []string{"b.jpg", "sub", "a.png", "c.jpg"}, names(result.Items))
+
+	result = Apply(items, storage.ListOptions{
+		OnlyFiles:  true,
+		Extensions: []string{".jpg"},
+		SortBy:     storage.SortBySize,
+		SortOrder:  storage.SortOrderDesc,
+		Offset:     1,
+		Limit:      5,
+	})
+	assert.Equal(t, 2, result.TotalCount)
+	assert.Equal(t, []string{"b.jpg"}, names(result.Items))
+
+	result = Apply(items, storage.ListOptions{Offset: 10})
+	assert.Equal(t, 4, result.TotalCount)
+	assert.Empty(t, result.Items)
+
+	// The cached listing is left as it was
+	assert.Equal(t, "b.jpg", items[0].Name)
+}
+
+func TestETag(t *testing.T) {
+	items := []storage.FileInfo{{Name: "a.jpg", Size: 1}}
+	assert.Equal(t, ETag(items), ETag([]storage.FileInfo{{Name: "a.jpg", Size: 1}}))
+	assert.NotEqual(t, ETag(items), ETag([]storage.FileInfo{{Name: "a.jpg", Size: 2}}))
+}
+
+func names(items []storage.FileInfo) []string {
+	var names []string
+	for _, item := range items {
+		names = append(names, item.Name)
+	}
+	return names
+}
@@ -0,0 +1,82 @@
+package listcache
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/cshum/imagor-studio/server/internal/database"
+	"github.com/cshum/imagor-studio/server/internal/model"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/cshum/imagor-studio/server/pkg/uuid"
+	"github.com/uptrace/bun"
+	"go.uber.org/zap"
+)
+
+type store struct {
+	db     *bun.DB
+	logger *zap.Logger
+}
+
+// NewStore persists listings in the folder_listings table
+func NewStore(db *bun.DB, logger *zap.Logger) Store {
+	return &store{db: db, logger: logger}
+}
+
+func (s *store) Get(ctx context.Context, scope, folder string) (*Listing, error) {
+	var row model.FolderListing
+	err := s.db.NewSelect().Model(&row).
+		Where("scope = ?", scope).
+		Where("folder_path = ?", folder).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting folder listing: %w", err)
+	}
+	var items []storage.FileInfo
+	if err := json.Unmarshal([]byte(row.Items), &items); err != nil {
+		// Treated as a miss, the entry is replaced on the next Put
+		s.logger.Warn("Invalid cached folder listing", zap.String("folder", folder), zap.Error(err))
+		return nil, nil
+	}
+	return &Listing{Items: items, ETag: row.ETag, CachedAt: row.CachedAt}, nil
+}
+
+func (s *store) Put(ctx context.Context, scope, folder string, listing *Listing) error {
+	items, err := json.Marshal(listing.Items)
+	if err != nil {
+		return fmt.Errorf("error encoding folder listing: %w", err)
+	}
+	row := &model.FolderListing{
+		ID:         uuid.GenerateUUID(),
+		Scope:      scope,
+		FolderPath: folder,
+		ETag:       listing.ETag,
+		Items:      string(items),
+		CachedAt:   listing.CachedAt,
+	}
+	if _, err := database.OnConflictUpdate(s.db.NewInsert().Model(row), "scope, folder_path",
+		"etag", "items", "cached_at").Exec(ctx); err != nil {
+		return fmt.Errorf("error saving folder listing: %w", err)
+	}
+	return nil
+}
+
+func (s *store) Remove(ctx context.Context, scope, folder string, tree bool) error {
+	q := s.db.NewDelete().Model((*model.FolderListing)(nil)).Where("scope = ?", scope)
+	switch {
+	case tree && folder == "":
+	case tree:
+		q = q.Where("(folder_path = ? OR substr(folder_path, 1, ?) = ?)", folder, len(folder)+1, folder+"/")
+	default:
+		q = q.Where("folder_path = ?", folder)
+	}
+	if _, err := q.Exec(ctx); err != nil {
+		return fmt.Errorf("error removing folder listing: %w", err)
+	}
+	return nil
+}
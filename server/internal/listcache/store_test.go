@@ -0,0 +1,87 @@
+package listcache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func setupTestStore(t *testing.T) Store {
+	t.Helper()
+	db := testutil.NewDB(t)
+
+	return NewStore(db, zap.NewNop())
+}
+
+func TestStore(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	listing, err := s.Get(ctx, scope, "photos")
+	require.NoError(t, err)
+	assert.Nil(t, listing)
+
+	cachedAt := time.Now().UTC().Truncate(time.Second)
+	items := []storage.FileInfo{{Name: "a.jpg", Path: "photos/a.jpg", Size: 3, ModifiedTime: cachedAt}}
+	for _, folder := range []string{"photos", "photos/2024", "photos-old", ""} {
+		require.NoError(t, s.Put(ctx, scope, folder, &Listing{Items: items, ETag: ETag(items), CachedAt: cachedAt}))
+	}
+	listing, err = s.Get(ctx, scope, "photos")
+	require.NoError(t, err)
+	require.NotNil(t, listing)
+	assert.Equal(t, ETag(items), listing.ETag)
+	assert.Equal(t, ETag(items), ETag(listing.Items))
+	assert.True(t, cachedAt.Equal(listing.CachedAt))
+
+	// Replaced in place
+	require.NoError(t, s.Put(ctx, scope, "photos", &Listing{ETag: "empty", CachedAt: cachedAt}))
+	listing, err = s.Get(ctx, scope, "photos")
+	require.NoError(t, err)
+	assert.Equal(t, "empty", listing.ETag)
+	assert.Empty(t, listing.Items)
+
+	require.NoError(t, s.Remove(ctx, scope, "photos", true))
+	for folder, cached := range map[string]bool{"photos": false, "photos/2024": false, "photos-old": true, "": true} {
+		listing, err := s.Get(ctx, scope, folder)
+		require.NoError(t, err)
+		assert.Equal(t, cached, listing != nil, folder)
+	}
+	require.NoError(t, s.Remove(ctx, scope, "", false))
+	listing, err = s.Get(ctx, scope, "photos-old")
+	require.NoError(t, err)
+	assert.NotNil(t, listing)
+	require.NoError(t, s.Remove(ctx, scope, "", true))
+	listing, err = s.Get(ctx, scope, "photos-old")
+	require.NoError(t, err)
+	assert.Nil(t, listing)
+}
+
+func TestCache_WithStore(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+	var calls int
+	_, err := New(WithStore(s)).Load(ctx, scope, "photos", countingFetch(&calls, 2))
+	require.NoError(t, err)
+
+	// A new cache, as after a restart, loads the persisted listing
+	restarted := New(WithStore(s))
+	listing, err := restarted.Load(ctx, scope, "photos", countingFetch(&calls, 2))
+	require.NoError(t, err)
+	assert.Equal(t, 1, calls)
+	assert.Len(t, listing.Items, 2)
+
+	restarted.Invalidate(ctx, scope, "photos/0.jpg")
+	_, ok := New(WithStore(s)).Get(ctx, scope, "photos")
+	assert.False(t, ok)
+
+	// Expired listings are not loaded
+	require.NoError(t, s.Put(ctx, scope, "old", &Listing{CachedAt: time.Now().Add(-time.Hour)}))
+	_, ok = New(WithStore(s)).Get(ctx, scope, "old")
+	assert.False(t, ok)
+}
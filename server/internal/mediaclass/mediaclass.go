@@ -0,0 +1,250 @@
+// Package mediaclass assigns lightweight system tags (screenshot, scan, meme,
+// document) to media files using filename patterns, image dimensions and the
+// EXIF Software tag. Rules are plain JSON so they can be stored in the registry
+// and tuned per instance without a redeploy.
+package mediaclass
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// RegistryKey is the system registry key holding the JSON encoded rule list.
+// When the key is unset or empty, DefaultRules are used.
+const RegistryKey = "config.app_media_classification_rules"
+
+// Built-in system tags
+const (
+	TagScreenshot = "screenshot"
+	TagScan       = "scan"
+	TagMeme       = "meme"
+	TagDocument   = "document"
+)
+
+// Rule describes when a tag applies to a file.
+//
+// Every non-empty criterion must match for the rule to apply; within a single
+// criterion any listed value may match. Dimension and software criteria only
+// match when the corresponding metadata is known: listings read it from the
+// metadata index, so they apply to files indexed by a library scan or opened
+// before, and never to upload routing, which runs before the content is read.
+type Rule struct {
+	Tag          string   `json:"tag"`
+	NamePatterns []string `json:"namePatterns,omitempty"`
+	Extensions   []string `json:"extensions,omitempty"`
+	Software     []string `json:"software,omitempty"`
+	MinWidth     int      `json:"minWidth,omitempty"`
+	MinHeight    int      `json:"minHeight,omitempty"`
+	MaxWidth     int      `json:"maxWidth,omitempty"`
+	MaxHeight    int      `json:"maxHeight,omitempty"`
+}
+
+// Subject is the information available about a file when classifying it.
+// Width, Height and Software are zero values when unknown.
+type Subject struct {
+	Name     string
+	Width    int
+	Height   int
+	Software string
+}
+
+// DefaultRules are applied when no rules are configured in the registry.
+var DefaultRules = []Rule{
+	{Tag: TagScreenshot, NamePatterns: []string{"screenshot*", "screen shot*", "screen_shot*", "scr_*"}},
+	{Tag: TagScreenshot, Software: []string{"screenshot", "snipping tool", "greenshot", "flameshot", "shottr"}},
+	{Tag: TagScan, NamePatterns: []string{"scan*", "*_scan*", "*-scan*", "img_scan*"}},
+	{Tag: TagScan, Software: []string{"scan", "camscanner", "genius scan", "adobe scan", "naps2"}},
+	{Tag: TagMeme, NamePatterns: []string{"*meme*"}},
+	{Tag: TagDocument, Extensions: []string{".pdf", ".doc", ".docx", ".txt", ".md", ".rtf", ".odt"}},
+	{Tag: TagDocument, NamePatterns: []string{"*receipt*", "*invoice*", "*document*", "doc_*"}},
+}
+
+// Classifier evaluates a set of rules against files.
+type Classifier struct {
+	rules []Rule
+}
+
+// New creates a classifier for the given rules.
+// Patterns, extensions and software names are matched case-insensitively.
+func New(rules []Rule) *Classifier {
+	normalized := make([]Rule, 0, len(rules))
+	for _, rule := range rules {
+		rule.Tag = strings.ToLower(strings.TrimSpace(rule.Tag))
+		if rule.Tag == "" {
+			continue
+		}
+		rule.NamePatterns = lowerAll(rule.NamePatterns)
+		rule.Software = lowerAll(rule.Software)
+		rule.Extensions = lowerAll(rule.Extensions)
+		for i, ext := range rule.Extensions {
+			if !strings.HasPrefix(ext, ".") {
+				rule.Extensions[i] = "." + ext
+			}
+		}
+		normalized = append(normalized, rule)
+	}
+	return &Classifier{rules: normalized}
+}
+
+// ParseRules decodes a JSON rule list and validates it.
+func ParseRules(data string) ([]Rule, error) {
+	var rules []Rule
+	if err := json.Unmarshal([]byte(data), &rules); err != nil {
+		return nil, fmt.Errorf("invalid classification rules: %w", err)
+	}
+	for i, rule := range rules {
+		if strings.TrimSpace(rule.Tag) == "" {
+			return nil, fmt.Errorf("invalid classification rule %d: tag is required", i)
+		}
+		if !rule.hasCriteria() {
+			return nil, fmt.Errorf("invalid classification rule %d: at least one criterion is required", i)
+		}
+		for _, pattern := range rule.NamePatterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid classification rule %d: bad name pattern %q: %w", i, pattern, err)
+			}
+		}
+	}
+	return rules, nil
+}
+
+// FromRegistryValue builds a classifier from the registry value,
+// falling back to DefaultRules when the value is empty or invalid.
+func FromRegistryValue(value string) (*Classifier, error) {
+	if strings.TrimSpace(value) == "" {
+		return New(DefaultRules), nil
+	}
+	rules, err := ParseRules(value)
+	if err != nil {
+		return New(DefaultRules), err
+	}
+	return New(rules), nil
+}
+
+// UsesMetadata reports whether any rule has dimension or software criteria,
+// which only match subjects carrying the metadata of the file
+func (c *Classifier) UsesMetadata() bool {
+	if c == nil {
+		return false
+	}
+	for _, rule := range c.rules {
+		if len(rule.Software) > 0 || rule.MinWidth > 0 || rule.MinHeight > 0 || rule.MaxWidth > 0 || rule.MaxHeight > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Classify returns the sorted, de-duplicated tags matching the subject.
+func (c *Classifier) Classify(subject Subject) []string {
+	if c == nil {
+		return nil
+	}
+	name := strings.ToLower(path.Base(subject.Name))
+	ext := path.Ext(name)
+	software := strings.ToLower(subject.Software)
+
+	seen := make(map[string]bool)
+	var tags []string
+	for _, rule := range c.rules {
+		if seen[rule.Tag] || !rule.matches(name, ext, software, subject.Width, subject.Height) {
+			continue
+		}
+		seen[rule.Tag] = true
+		tags = append(tags, rule.Tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// Filter reports whether a file with the given tags passes the include and
+// exclude lists. An empty include list accepts everything.
+func Filter(tags, include, exclude []string) bool {
+	for _, tag := range exclude {
+		if containsFold(tags, tag) {
+			return false
+		}
+	}
+	if len(include) == 0 {
+		return true
+	}
+	for _, tag := range include {
+		if containsFold(tags, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+func (rule Rule) hasCriteria() bool {
+	return len(rule.NamePatterns) > 0 || len(rule.Extensions) > 0 || len(rule.Software) > 0 ||
+		rule.MinWidth > 0 || rule.MinHeight > 0 || rule.MaxWidth > 0 || rule.MaxHeight > 0
+}
+
+func (rule Rule) matches(name, ext, software string, width, height int) bool {
+	if !rule.hasCriteria() {
+		return false
+	}
+	if len(rule.NamePatterns) > 0 && !matchAnyPattern(rule.NamePatterns, name) {
+		return false
+	}
+	if len(rule.Extensions) > 0 && !containsFold(rule.Extensions, ext) {
+		return false
+	}
+	if len(rule.Software) > 0 {
+		if software == "" || !containsAnySubstring(software, rule.Software) {
+			return false
+		}
+	}
+	if rule.MinWidth > 0 || rule.MinHeight > 0 || rule.MaxWidth > 0 || rule.MaxHeight > 0 {
+		if width <= 0 || height <= 0 {
+			return false
+		}
+		if (rule.MinWidth > 0 && width < rule.MinWidth) || (rule.MaxWidth > 0 && width > rule.MaxWidth) ||
+			(rule.MinHeight > 0 && height < rule.MinHeight) || (rule.MaxHeight > 0 && height > rule.MaxHeight) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchAnyPattern(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAnySubstring(s string, subs []string) bool {
+	for _, sub := range subs {
+		if sub != "" && strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(values []string, target string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func lowerAll(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = strings.ToLower(strings.TrimSpace(v))
+	}
+	return out
+}
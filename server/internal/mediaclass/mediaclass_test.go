@@ -0,0 +1,96 @@
+package mediaclass
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassify_DefaultRules(t *testing.T) {
+	c := New(DefaultRules)
+
+	tests := []struct {
+		name    string
+		subject Subject
+		want    []string
+	}{
+		{"macOS screenshot", Subject{Name: "Screen Shot 2024-01-02 at 10.00.00.png"}, []string{TagScreenshot}},
+		{"android screenshot in folder", Subject{Name: "photos/Screenshot_20240101.png"}, []string{TagScreenshot}},
+		{"screenshot by software", Subject{Name: "IMG_0001.png", Software: "Greenshot 1.2"}, []string{TagScreenshot}},
+		{"scanned receipt", Subject{Name: "scan_receipt.jpg"}, []string{TagDocument, TagScan}},
+		{"scan by software", Subject{Name: "page1.jpg", Software: "EPSON Scan"}, []string{TagScan}},
+		{"meme", Subject{Name: "funny-meme.gif"}, []string{TagMeme}},
+		{"pdf document", Subject{Name: "report.PDF"}, []string{TagDocument}},
+		{"regular photo", Subject{Name: "IMG_1234.jpg", Software: "iOS 17.1"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, c.Classify(tt.subject))
+		})
+	}
+}
+
+func TestClassify_DimensionRules(t *testing.T) {
+	c := New([]Rule{{Tag: "wallpaper", MinWidth: 3840, MinHeight: 2160}})
+
+	assert.Equal(t, []string{"wallpaper"}, c.Classify(Subject{Name: "a.jpg", Width: 3840, Height: 2160}))
+	assert.Nil(t, c.Classify(Subject{Name: "a.jpg", Width: 1920, Height: 1080}))
+	// Unknown dimensions never satisfy dimension rules
+	assert.Nil(t, c.Classify(Subject{Name: "a.jpg"}))
+}
+
+func TestClassify_NilClassifier(t *testing.T) {
+	var c *Classifier
+	assert.Nil(t, c.Classify(Subject{Name: "Screenshot.png"}))
+}
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules(`[{"tag":"Screenshot","namePatterns":["shot*"]},{"tag":"doc","extensions":["pdf"]}]`)
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+
+	c := New(rules)
+	assert.Equal(t, []string{"screenshot"}, c.Classify(Subject{Name: "SHOT-1.png"}))
+	assert.Equal(t, []string{"doc"}, c.Classify(Subject{Name: "a.pdf"}))
+
+	_, err = ParseRules(`not json`)
+	assert.Error(t, err)
+
+	_, err = ParseRules(`[{"tag":"","namePatterns":["a*"]}]`)
+	assert.ErrorContains(t, err, "tag is required")
+
+	_, err = ParseRules(`[{"tag":"x"}]`)
+	assert.ErrorContains(t, err, "at least one criterion")
+
+	_, err = ParseRules(`[{"tag":"x","namePatterns":["[a"]}]`)
+	assert.ErrorContains(t, err, "bad name pattern")
+}
+
+func TestFromRegistryValue(t *testing.T) {
+	c, err := FromRegistryValue("")
+	require.NoError(t, err)
+	assert.Equal(t, []string{TagScreenshot}, c.Classify(Subject{Name: "Screenshot.png"}))
+
+	// Invalid rules fall back to defaults but still report the error
+	c, err = FromRegistryValue("{")
+	assert.Error(t, err)
+	assert.Equal(t, []string{TagScreenshot}, c.Classify(Subject{Name: "Screenshot.png"}))
+}
+
+func TestFilter(t *testing.T) {
+	assert.True(t, Filter(nil, nil, nil))
+	assert.True(t, Filter([]string{TagScan}, nil, []string{TagScreenshot}))
+	assert.False(t, Filter([]string{TagScreenshot}, nil, []string{"SCREENSHOT"}))
+	assert.True(t, Filter([]string{TagScreenshot}, []string{TagScreenshot}, nil))
+	assert.False(t, Filter(nil, []string{TagScreenshot}, nil))
+	assert.False(t, Filter([]string{TagScreenshot, TagDocument}, []string{TagDocument}, []string{TagScreenshot}))
+}
+
+func TestClassifier_UsesMetadata(t *testing.T) {
+	assert.True(t, New(DefaultRules).UsesMetadata())
+	assert.True(t, New([]Rule{{Tag: "wallpaper", MinWidth: 3840}}).UsesMetadata())
+	assert.False(t, New([]Rule{{Tag: "whiteboard", NamePatterns: []string{"wb_*"}}}).UsesMetadata())
+	var c *Classifier
+	assert.False(t, c.UsesMetadata())
+}
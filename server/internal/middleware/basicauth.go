@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/cshum/imagor-studio/server/internal/apitoken"
+	"github.com/cshum/imagor-studio/server/internal/resolver"
+	"github.com/cshum/imagor-studio/server/pkg/apperror"
+	"github.com/cshum/imagor-studio/server/pkg/auth"
+)
+
+// APITokenBasicAuthMiddleware authenticates clients that only speak HTTP
+// Basic auth, such as WebDAV clients, with an API token as password. The
+// user name is ignored. Bearer tokens are accepted as by JWTMiddleware.
+// Requests without credentials are challenged for them.
+func APITokenBasicAuthMiddleware(realm string, tokenManager *auth.TokenManager, apiTokens APITokenAuthenticator, sessions SessionVerifier) func(http.Handler) http.Handler {
+	challenge := fmt.Sprintf("Basic realm=%q, charset=\"UTF-8\"", realm)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authHeader := r.Header.Get("Authorization")
+			if _, password, ok := r.BasicAuth(); ok {
+				if !apitoken.IsToken(password) {
+					w.Header().Set("WWW-Authenticate", challenge)
+					apperror.WriteHTTPErrorResponse(w, apperror.Unauthorized("Sign in with an API token as password"))
+					return
+				}
+				authHeader = "Bearer " + password
+			}
+
+			claims, err := authenticate(r.Context(), tokenManager, apiTokens, sessions, authHeader)
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", challenge)
+				apperror.WriteHTTPErrorResponse(w, err)
+				return
+			}
+			ctx := auth.SetClaimsInContext(r.Context(), claims)
+			ctx = resolver.WithUserID(ctx, claims.UserID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/resolver"
+	"github.com/cshum/imagor-studio/server/pkg/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPITokenBasicAuthMiddleware(t *testing.T) {
+	tokenManager := auth.NewTokenManager("test-secret", time.Hour)
+	jwtToken, err := tokenManager.GenerateToken("jwt-user", "user", []string{"read"}, "")
+	require.NoError(t, err)
+	apiTokens := fakeAPITokens{
+		"ist_valid": {UserID: "dav-user", Role: "user", Scopes: []string{"read", "write"}, Kind: auth.APITokenKind},
+	}
+	handler := APITokenBasicAuthMiddleware("Imagor Studio", tokenManager, apiTokens, nil)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, _ := resolver.GetUserIDFromContext(r.Context())
+			_, _ = w.Write([]byte(userID))
+		}))
+	serve := func(setup func(r *http.Request)) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PROPFIND", "/dav/", nil)
+		setup(req)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := serve(func(r *http.Request) { r.SetBasicAuth("anyone", "ist_valid") })
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "dav-user", rr.Body.String())
+
+	rr = serve(func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+jwtToken) })
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "jwt-user", rr.Body.String())
+
+	for _, setup := range []func(r *http.Request){
+		func(r *http.Request) {},
+		func(r *http.Request) { r.SetBasicAuth("anyone", "ist_revoked") },
+		// Account passwords are not accepted
+		func(r *http.Request) { r.SetBasicAuth("admin", "password") },
+	} {
+		rr = serve(setup)
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		assert.Equal(t, `Basic realm="Imagor Studio", charset="UTF-8"`, rr.Header().Get("WWW-Authenticate"))
+	}
+}
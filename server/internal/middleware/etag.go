@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ETagMiddleware sets an ETag hashed from the body on successful GET
+// responses lacking one, and answers 304 Not Modified when it matches the
+// If-None-Match of the request. Bodies are held back up to maxSize bytes,
+// larger ones are streamed without an ETag.
+func ETagMiddleware(maxSize int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet || r.Header.Get("Range") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ew := &etagResponseWriter{ResponseWriter: w, ifNoneMatch: r.Header.Get("If-None-Match"), maxSize: maxSize}
+			defer ew.finish()
+			next.ServeHTTP(ew, r)
+		})
+	}
+}
+
+// ETagMatch reports whether an If-None-Match header matches etag, by the
+// weak comparison of RFC 9110
+func ETagMatch(ifNoneMatch, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+type etagResponseWriter struct {
+	http.ResponseWriter
+	ifNoneMatch string
+	maxSize     int
+	status      int
+	buf         []byte
+	passthrough bool
+}
+
+func (w *etagResponseWriter) WriteHeader(statusCode int) {
+	if w.passthrough || w.status != 0 {
+		return
+	}
+	if statusCode < http.StatusOK {
+		w.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	w.status = statusCode
+	if statusCode != http.StatusOK || w.Header().Get("ETag") != "" {
+		w.startPassthrough()
+	}
+}
+
+func (w *etagResponseWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(data)
+	}
+	w.buf = append(w.buf, data...)
+	if len(w.buf) > w.maxSize {
+		if err := w.startPassthrough(); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+// startPassthrough sends the headers and the held back bytes as they are
+func (w *etagResponseWriter) startPassthrough() error {
+	w.passthrough = true
+	w.ResponseWriter.WriteHeader(w.status)
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+func (w *etagResponseWriter) finish() {
+	if w.passthrough || w.status == 0 {
+		return
+	}
+	sum := sha256.Sum256(w.buf)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	if w.ifNoneMatch != "" && ETagMatch(w.ifNoneMatch, etag) {
+		w.Header().Del("Content-Type")
+		w.Header().Del("Content-Length")
+		w.ResponseWriter.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.ResponseWriter.WriteHeader(http.StatusOK)
+	_, _ = w.ResponseWriter.Write(w.buf)
+}
+
+// Flush gives up on the ETag to let streamed responses through
+func (w *etagResponseWriter) Flush() {
+	if !w.passthrough {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		_ = w.startPassthrough()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack lets WebSocket upgrades through the wrapper
+func (w *etagResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	return h.Hijack()
+}
+
+// Unwrap exposes the wrapped writer to http.ResponseController
+func (w *etagResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
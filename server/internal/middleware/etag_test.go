@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestETagMatch(t *testing.T) {
+	assert.True(t, ETagMatch(`"abc"`, `"abc"`))
+	assert.True(t, ETagMatch(`"x", W/"abc"`, `"abc"`))
+	assert.True(t, ETagMatch(`"abc"`, `W/"abc"`))
+	assert.True(t, ETagMatch(`*`, `"abc"`))
+	assert.False(t, ETagMatch(`"abd"`, `"abc"`))
+	assert.False(t, ETagMatch(``, `"abc"`))
+}
+
+func TestETagMiddleware(t *testing.T) {
+	thumbnail := strings.Repeat("jpeg", 64)
+	handler := ETagMiddleware(1024)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing.jpg":
+			http.NotFound(w, r)
+		case "/stored.jpg":
+			w.Header().Set("ETag", `"stored"`)
+			_, _ = io.WriteString(w, thumbnail)
+		case "/large.jpg":
+			_, _ = io.WriteString(w, strings.Repeat(thumbnail, 8))
+		default:
+			w.Header().Set("Content-Type", "image/jpeg")
+			w.Header().Set("Cache-Control", "public, max-age=604800")
+			_, _ = io.WriteString(w, thumbnail)
+		}
+	}))
+	serve := func(method, path, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := serve(http.MethodGet, "/photo.jpg", "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, thumbnail, rr.Body.String())
+	etag := rr.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+	assert.Equal(t, etag, serve(http.MethodGet, "/photo.jpg", "").Header().Get("ETag"), "ETags are stable")
+
+	rr = serve(http.MethodGet, "/photo.jpg", "W/"+etag)
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+	assert.Empty(t, rr.Body.String())
+	assert.Empty(t, rr.Header().Get("Content-Type"))
+	assert.Equal(t, "public, max-age=604800", rr.Header().Get("Cache-Control"))
+	assert.Equal(t, etag, rr.Header().Get("ETag"))
+
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet, "/photo.jpg", `"other"`).Code)
+
+	// Existing ETags and failures are left alone
+	rr = serve(http.MethodGet, "/stored.jpg", etag)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, `"stored"`, rr.Header().Get("ETag"))
+	rr = serve(http.MethodGet, "/missing.jpg", "")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Empty(t, rr.Header().Get("ETag"))
+
+	// Large bodies are streamed
+	rr = serve(http.MethodGet, "/large.jpg", "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Len(t, rr.Body.String(), len(thumbnail)*8)
+	assert.Empty(t, rr.Header().Get("ETag"))
+
+	rr = serve(http.MethodHead, "/photo.jpg", "")
+	assert.Empty(t, rr.Header().Get("ETag"))
+}
@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/cshum/imagor-studio/server/internal/resolver"
+	"github.com/cshum/imagor-studio/server/internal/tenantstore"
+	"github.com/cshum/imagor-studio/server/internal/userstore"
+	"github.com/cshum/imagor-studio/server/pkg/apperror"
+	"github.com/cshum/imagor-studio/server/pkg/auth"
+)
+
+// UserLookup loads user records regardless of their active status
+type UserLookup interface {
+	GetByIDAdmin(ctx context.Context, id string) (*userstore.User, error)
+}
+
+// TenantLookup loads the tenants users are members of
+type TenantLookup interface {
+	Get(ctx context.Context, id string) (*tenantstore.Tenant, error)
+}
+
+// errTenantChanged rejects tokens issued for another tenant than the one the
+// user is a member of now
+var errTenantChanged = errors.New("tenant changed")
+
+// sessionScope is the part of the storage and the tenant a session is
+// confined to, empty when it is not
+type sessionScope struct {
+	homePath string
+	tenantID string
+}
+
+// HomePathMiddleware scopes the storage access of users to the home path set
+// on their user record. It runs after JWTMiddleware and reads the record on
+// every request, so home path changes apply without a new token. Shared
+// link sessions are rooted at the shared path. Admins, other guests and
+// embedded sessions are not scoped.
+//
+// With tenants, members of a tenant, its admins included, are confined to
+// the tenant and rooted at its storage root, below which their home path
+// lies. tenants is nil when tenants are not enabled.
+func HomePathMiddleware(users UserLookup, tenants TenantLookup) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, err := auth.GetClaimsFromContext(r.Context())
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			scope, err := scopeFor(r.Context(), users, tenants, claims)
+			if errors.Is(err, errTenantChanged) {
+				apperror.WriteHTTPErrorResponse(w, apperror.Unauthorized("Session is no longer valid"))
+				return
+			}
+			if err != nil {
+				apperror.WriteHTTPErrorResponse(w, apperror.InternalServerError("Failed to load user"))
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(scope.apply(r.Context())))
+		})
+	}
+}
+
+// apply adds the scope to ctx
+func (s sessionScope) apply(ctx context.Context) context.Context {
+	if s.homePath != "" {
+		ctx = resolver.WithHomePath(ctx, s.homePath)
+	}
+	if s.tenantID != "" {
+		ctx = resolver.WithTenantID(ctx, s.tenantID)
+	}
+	return ctx
+}
+
+// scopeFor returns the scope of the session of claims
+func scopeFor(ctx context.Context, users UserLookup, tenants TenantLookup, claims *auth.Claims) (sessionScope, error) {
+	if claims.Kind == auth.ShareLinkTokenKind {
+		// Shared link sessions are rooted at the shared path
+		return sessionScope{homePath: strings.Trim(claims.PathPrefix, "/")}, nil
+	}
+	if claims.IsEmbedded || claims.Role == "guest" {
+		return sessionScope{}, nil
+	}
+	isAdmin := hasScope(claims, "admin")
+	if isAdmin && tenants == nil {
+		return sessionScope{}, nil
+	}
+	user, err := users.GetByIDAdmin(ctx, claims.UserID)
+	if err != nil {
+		return sessionScope{}, err
+	}
+	if user == nil {
+		return sessionScope{}, nil
+	}
+	if tenants == nil || user.TenantID == nil {
+		if claims.TenantID != "" && tenants != nil {
+			return sessionScope{}, errTenantChanged
+		}
+		if isAdmin || user.HomePath == nil {
+			return sessionScope{}, nil
+		}
+		return sessionScope{homePath: *user.HomePath}, nil
+	}
+
+	if claims.TenantID != "" && claims.TenantID != *user.TenantID {
+		return sessionScope{}, errTenantChanged
+	}
+	tenant, err := tenants.Get(ctx, *user.TenantID)
+	if err != nil {
+		return sessionScope{}, err
+	}
+	scope := sessionScope{homePath: tenant.StorageRoot, tenantID: tenant.ID}
+	if !isAdmin && user.HomePath != nil {
+		scope.homePath = path.Join(tenant.StorageRoot, *user.HomePath)
+	}
+	return scope, nil
+}
+
+func hasScope(claims *auth.Claims, scope string) bool {
+	for _, s := range claims.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
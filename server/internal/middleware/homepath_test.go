@@ -0,0 +1,183 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/resolver"
+	"github.com/cshum/imagor-studio/server/internal/tenantstore"
+	"github.com/cshum/imagor-studio/server/internal/userstore"
+	"github.com/cshum/imagor-studio/server/pkg/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeUserLookup map[string]*userstore.User
+
+func (f fakeUserLookup) GetByIDAdmin(ctx context.Context, id string) (*userstore.User, error) {
+	if id == "broken" {
+		return nil, errors.New("database unavailable")
+	}
+	return f[id], nil
+}
+
+type fakeTenantLookup map[string]*tenantstore.Tenant
+
+func (f fakeTenantLookup) Get(ctx context.Context, id string) (*tenantstore.Tenant, error) {
+	if tenant, ok := f[id]; ok {
+		return tenant, nil
+	}
+	return nil, tenantstore.ErrNotFound
+}
+
+func TestHomePathMiddleware(t *testing.T) {
+	homePath := "teams/alice"
+	users := fakeUserLookup{
+		"alice": {ID: "alice", Role: "user", HomePath: &homePath},
+		"bob":   {ID: "bob", Role: "user"},
+		"admin": {ID: "admin", Role: "admin", HomePath: &homePath},
+	}
+
+	tests := []struct {
+		name             string
+		claims           *auth.Claims
+		expectedStatus   int
+		expectedHomePath string
+	}{
+		{
+			name:             "User with home path is scoped",
+			claims:           &auth.Claims{UserID: "alice", Role: "user", Scopes: []string{"read", "write"}},
+			expectedStatus:   http.StatusOK,
+			expectedHomePath: "teams/alice",
+		},
+		{
+			name:           "User without home path is not scoped",
+			claims:         &auth.Claims{UserID: "bob", Role: "user", Scopes: []string{"read", "write"}},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Admin is not scoped",
+			claims:         &auth.Claims{UserID: "admin", Role: "admin", Scopes: []string{"read", "write", "admin"}},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:             "Share link session is rooted at the shared path",
+			claims:           &auth.Claims{UserID: "broken", Role: "guest", Scopes: []string{"read"}, Kind: auth.ShareLinkTokenKind, PathPrefix: "/albums/summer"},
+			expectedStatus:   http.StatusOK,
+			expectedHomePath: "albums/summer",
+		},
+		{
+			name:           "Guest is not looked up",
+			claims:         &auth.Claims{UserID: "broken", Role: "guest", Scopes: []string{"read"}},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Lookup failure is rejected",
+			claims:         &auth.Claims{UserID: "broken", Role: "user", Scopes: []string{"read"}},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var homePathInHandler string
+			handler := HomePathMiddleware(users, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				homePathInHandler = resolver.GetHomePathFromContext(r.Context())
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest("POST", "/api/query", nil)
+			req = req.WithContext(auth.SetClaimsInContext(req.Context(), tt.claims))
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.Equal(t, tt.expectedHomePath, homePathInHandler)
+		})
+	}
+}
+
+func TestHomePathMiddleware_Tenants(t *testing.T) {
+	homePath := "alice"
+	acme, other := "acme", "other"
+	users := fakeUserLookup{
+		"alice":  {ID: "alice", Role: "user", HomePath: &homePath, TenantID: &acme},
+		"bob":    {ID: "bob", Role: "user", TenantID: &acme},
+		"admin":  {ID: "admin", Role: "admin", HomePath: &homePath, TenantID: &acme},
+		"root":   {ID: "root", Role: "admin"},
+		"orphan": {ID: "orphan", Role: "user", TenantID: &other},
+	}
+	tenants := fakeTenantLookup{"acme": {ID: "acme", StorageRoot: "tenants/acme"}}
+
+	tests := []struct {
+		name             string
+		claims           *auth.Claims
+		expectedStatus   int
+		expectedHomePath string
+		expectedTenantID string
+	}{
+		{
+			name:             "Member home path is below the tenant root",
+			claims:           &auth.Claims{UserID: "alice", Role: "user", Scopes: []string{"read", "write"}, TenantID: "acme"},
+			expectedStatus:   http.StatusOK,
+			expectedHomePath: "tenants/acme/alice",
+			expectedTenantID: "acme",
+		},
+		{
+			name:             "Member without home path is rooted at the tenant root",
+			claims:           &auth.Claims{UserID: "bob", Role: "user", Scopes: []string{"read", "write"}},
+			expectedStatus:   http.StatusOK,
+			expectedHomePath: "tenants/acme",
+			expectedTenantID: "acme",
+		},
+		{
+			name:             "Tenant admin is rooted at the tenant root",
+			claims:           &auth.Claims{UserID: "admin", Role: "admin", Scopes: []string{"read", "write", "admin"}, TenantID: "acme"},
+			expectedStatus:   http.StatusOK,
+			expectedHomePath: "tenants/acme",
+			expectedTenantID: "acme",
+		},
+		{
+			name:           "Server admin is not scoped",
+			claims:         &auth.Claims{UserID: "root", Role: "admin", Scopes: []string{"read", "write", "admin"}},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Token of another tenant is rejected",
+			claims:         &auth.Claims{UserID: "bob", Role: "user", Scopes: []string{"read"}, TenantID: "other"},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Token of a tenant the user left is rejected",
+			claims:         &auth.Claims{UserID: "root", Role: "admin", Scopes: []string{"read", "admin"}, TenantID: "acme"},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Missing tenant is rejected",
+			claims:         &auth.Claims{UserID: "orphan", Role: "user", Scopes: []string{"read"}},
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var homePathInHandler, tenantIDInHandler string
+			handler := HomePathMiddleware(users, tenants)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				homePathInHandler = resolver.GetHomePathFromContext(r.Context())
+				tenantIDInHandler = resolver.GetTenantIDFromContext(r.Context())
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest("POST", "/api/query", nil)
+			req = req.WithContext(auth.SetClaimsInContext(req.Context(), tt.claims))
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.Equal(t, tt.expectedHomePath, homePathInHandler)
+			assert.Equal(t, tt.expectedTenantID, tenantIDInHandler)
+		})
+	}
+}
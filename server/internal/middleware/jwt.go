@@ -1,40 +1,46 @@
 package middleware
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"strings"
 
+	"github.com/cshum/imagor-studio/server/internal/apitoken"
 	"github.com/cshum/imagor-studio/server/internal/resolver"
 	"github.com/cshum/imagor-studio/server/pkg/apperror"
 	"github.com/cshum/imagor-studio/server/pkg/auth"
 )
 
-// JWTMiddleware creates a JWT authentication middleware
-func JWTMiddleware(tokenManager *auth.TokenManager) func(http.Handler) http.Handler {
+// APITokenAuthenticator resolves personal access tokens to session claims
+type APITokenAuthenticator interface {
+	Authenticate(ctx context.Context, token string) (*auth.Claims, error)
+}
+
+// SessionVerifier reports whether the login session of a JWT is still active
+type SessionVerifier interface {
+	Active(ctx context.Context, sessionID string) (bool, error)
+}
+
+// JWTMiddleware creates a JWT authentication middleware. WebSocket upgrades
+// without an Authorization header are passed through unauthenticated, as
+// browsers cannot set it; WebsocketInit authenticates them instead. Bearer
+// tokens with the API token prefix are resolved by apiTokens instead, they
+// are rejected when apiTokens is nil. JWTs issued for a login session are
+// rejected once sessions reports it revoked.
+func JWTMiddleware(tokenManager *auth.TokenManager, apiTokens APITokenAuthenticator, sessions SessionVerifier) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Extract token from Authorization header
 			authHeader := r.Header.Get("Authorization")
-
-			token, err := auth.ExtractTokenFromHeader(authHeader)
-			if err != nil {
-				unauthorizedErr := apperror.Unauthorized("Authorization header is missing or invalid")
-				apperror.WriteHTTPErrorResponse(w, unauthorizedErr)
+			if authHeader == "" && isWebsocketUpgrade(r) {
+				next.ServeHTTP(w, r)
 				return
 			}
 
-			// Validate token
-			claims, err := tokenManager.ValidateToken(token)
+			claims, err := authenticate(r.Context(), tokenManager, apiTokens, sessions, authHeader)
 			if err != nil {
-				// Check if it's a token expired error
-				if strings.Contains(err.Error(), "token is expired") {
-					expiredErr := apperror.Unauthorized("Token has expired")
-					apperror.WriteHTTPErrorResponse(w, expiredErr)
-					return
-				}
-
-				invalidErr := apperror.Unauthorized("Invalid or expired token")
-				apperror.WriteHTTPErrorResponse(w, invalidErr)
+				apperror.WriteHTTPErrorResponse(w, err)
 				return
 			}
 
@@ -50,6 +56,57 @@ func JWTMiddleware(tokenManager *auth.TokenManager) func(http.Handler) http.Hand
 	}
 }
 
+// authenticate validates the bearer token of an Authorization header value.
+// apiTokens is nil when API tokens are not accepted, sessions is nil when
+// login sessions are not stored.
+func authenticate(ctx context.Context, tokenManager *auth.TokenManager, apiTokens APITokenAuthenticator, sessions SessionVerifier, authHeader string) (*auth.Claims, error) {
+	token, err := auth.ExtractTokenFromHeader(authHeader)
+	if err != nil {
+		return nil, apperror.Unauthorized("Authorization header is missing or invalid")
+	}
+
+	if apitoken.IsToken(token) {
+		if apiTokens == nil {
+			return nil, apperror.Unauthorized("API tokens are not accepted")
+		}
+		claims, err := apiTokens.Authenticate(ctx, token)
+		if errors.Is(err, apitoken.ErrUnauthorized) {
+			return nil, apperror.Unauthorized("Invalid or expired API token")
+		}
+		if err != nil {
+			return nil, apperror.InternalServerError("Failed to authenticate API token")
+		}
+		return claims, nil
+	}
+
+	// Validate token
+	claims, err := tokenManager.ValidateToken(token)
+	if err != nil {
+		// Check if it's a token expired error
+		if strings.Contains(err.Error(), "token is expired") {
+			return nil, apperror.Unauthorized("Token has expired")
+		}
+		return nil, apperror.Unauthorized("Invalid or expired token")
+	}
+	if claims.SessionID != "" {
+		if sessions == nil {
+			return nil, apperror.Unauthorized("Invalid or expired token")
+		}
+		active, err := sessions.Active(ctx, claims.SessionID)
+		if err != nil {
+			return nil, apperror.InternalServerError("Failed to check session")
+		}
+		if !active {
+			return nil, apperror.Unauthorized("Session has been revoked")
+		}
+	}
+	return claims, nil
+}
+
+func isWebsocketUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
 // AuthorizationMiddleware checks if the user has the required scope
 func AuthorizationMiddleware(requiredScope string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
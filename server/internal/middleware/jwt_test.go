@@ -1,12 +1,14 @@
 package middleware
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/cshum/imagor-studio/server/internal/apitoken"
 	"github.com/cshum/imagor-studio/server/internal/resolver"
 	"github.com/cshum/imagor-studio/server/pkg/apperror"
 	"github.com/cshum/imagor-studio/server/pkg/auth"
@@ -80,7 +82,7 @@ func TestJWTMiddleware(t *testing.T) {
 				w.WriteHeader(http.StatusOK)
 			})
 
-			middleware := JWTMiddleware(tokenManager)
+			middleware := JWTMiddleware(tokenManager, nil, nil)
 			wrappedHandler := middleware(handler)
 
 			req := httptest.NewRequest(http.MethodGet, "/test", nil)
@@ -106,6 +108,84 @@ func TestJWTMiddleware(t *testing.T) {
 	}
 }
 
+type fakeAPITokens map[string]*auth.Claims
+
+func (f fakeAPITokens) Authenticate(ctx context.Context, token string) (*auth.Claims, error) {
+	if claims, ok := f[token]; ok {
+		return claims, nil
+	}
+	return nil, apitoken.ErrUnauthorized
+}
+
+func TestJWTMiddleware_APITokens(t *testing.T) {
+	tokenManager := auth.NewTokenManager("test-secret", time.Hour)
+	jwtToken, err := tokenManager.GenerateToken("jwt-user", "user", []string{"read"}, "")
+	require.NoError(t, err)
+	apiTokens := fakeAPITokens{
+		"ist_valid": {UserID: "script-user", Role: "user", Scopes: []string{"write"}, Kind: auth.APITokenKind},
+	}
+
+	serve := func(middleware func(http.Handler) http.Handler, token string) (int, *auth.Claims) {
+		var claims *auth.Claims
+		handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, _ = auth.GetClaimsFromContext(r.Context())
+			w.WriteHeader(http.StatusOK)
+		}))
+		req := httptest.NewRequest(http.MethodPost, "/api/query", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code, claims
+	}
+
+	code, claims := serve(JWTMiddleware(tokenManager, apiTokens, nil), "ist_valid")
+	assert.Equal(t, http.StatusOK, code)
+	require.NotNil(t, claims)
+	assert.Equal(t, "script-user", claims.UserID)
+	assert.Equal(t, auth.APITokenKind, claims.Kind)
+
+	code, _ = serve(JWTMiddleware(tokenManager, apiTokens, nil), "ist_revoked")
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	// JWT sessions keep working alongside API tokens
+	code, claims = serve(JWTMiddleware(tokenManager, apiTokens, nil), jwtToken)
+	assert.Equal(t, http.StatusOK, code)
+	require.NotNil(t, claims)
+	assert.Equal(t, "jwt-user", claims.UserID)
+
+	code, _ = serve(JWTMiddleware(tokenManager, nil, nil), "ist_valid")
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
+
+type fakeSessions map[string]bool
+
+func (f fakeSessions) Active(ctx context.Context, sessionID string) (bool, error) {
+	return f[sessionID], nil
+}
+
+func TestJWTMiddleware_Sessions(t *testing.T) {
+	tokenManager := auth.NewTokenManager("test-secret", time.Hour)
+	sessions := fakeSessions{"session-1": true}
+	serve := func(middleware func(http.Handler) http.Handler, sessionID string) int {
+		token, err := tokenManager.GenerateTokenWithClaims(auth.Claims{UserID: "user-1", Role: "user", Scopes: []string{"read"}, SessionID: sessionID}, 0)
+		require.NoError(t, err)
+		handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		req := httptest.NewRequest(http.MethodPost, "/api/query", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve(JWTMiddleware(tokenManager, nil, sessions), "session-1"))
+	assert.Equal(t, http.StatusUnauthorized, serve(JWTMiddleware(tokenManager, nil, sessions), "revoked"))
+	// Tokens issued without a session are not affected
+	assert.Equal(t, http.StatusOK, serve(JWTMiddleware(tokenManager, nil, sessions), ""))
+	assert.Equal(t, http.StatusUnauthorized, serve(JWTMiddleware(tokenManager, nil, nil), "session-1"))
+}
+
 func TestAuthorizationMiddleware(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/cshum/imagor-studio/server/internal/resolver"
+	"github.com/cshum/imagor-studio/server/internal/s3gateway"
+	"github.com/cshum/imagor-studio/server/internal/s3key"
+	"github.com/cshum/imagor-studio/server/pkg/auth"
+)
+
+// S3KeyAuthenticator resolves S3 access keys to their secret and session
+// claims
+type S3KeyAuthenticator interface {
+	Authenticate(ctx context.Context, id string) (string, *auth.Claims, error)
+}
+
+// S3SignatureMiddleware authenticates requests to the S3 gateway by their
+// AWS Signature Version 4, answering unsigned and badly signed requests
+// with S3 errors
+func S3SignatureMiddleware(keys S3KeyAuthenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var claims *auth.Claims
+			err := s3gateway.Verify(r, func(accessKeyID string) (string, error) {
+				secret, keyClaims, err := keys.Authenticate(r.Context(), accessKeyID)
+				if errors.Is(err, s3key.ErrUnauthorized) {
+					return "", s3gateway.ErrInvalidAccessKeyID
+				}
+				claims = keyClaims
+				return secret, err
+			})
+			if err != nil {
+				s3gateway.WriteError(w, r, err)
+				return
+			}
+			ctx := auth.SetClaimsInContext(r.Context(), claims)
+			ctx = resolver.WithUserID(ctx, claims.UserID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
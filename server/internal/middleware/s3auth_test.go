@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/cshum/imagor-studio/server/internal/resolver"
+	"github.com/cshum/imagor-studio/server/internal/s3key"
+	"github.com/cshum/imagor-studio/server/pkg/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeS3Keys map[string]*auth.Claims
+
+func (f fakeS3Keys) Authenticate(ctx context.Context, id string) (string, *auth.Claims, error) {
+	if claims, ok := f[id]; ok {
+		return "secret-of-" + id, claims, nil
+	}
+	return "", nil, s3key.ErrUnauthorized
+}
+
+func TestS3SignatureMiddleware(t *testing.T) {
+	keys := fakeS3Keys{
+		"ISAKVALIDVALIDVALID1": {UserID: "s3-user", Role: "user", Scopes: []string{"read"}, Kind: auth.S3AccessKeyKind},
+	}
+	handler := S3SignatureMiddleware(keys)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := resolver.GetUserIDFromContext(r.Context())
+		_, _ = w.Write([]byte(userID))
+	}))
+	serve := func(accessKeyID, secret string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/s3/gallery?list-type=2", nil)
+		req.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+		err := v4.NewSigner().SignHTTP(context.Background(), aws.Credentials{AccessKeyID: accessKeyID, SecretAccessKey: secret},
+			req, "UNSIGNED-PAYLOAD", "s3", "us-east-1", time.Now())
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := serve("ISAKVALIDVALIDVALID1", "secret-of-ISAKVALIDVALIDVALID1")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "s3-user", rr.Body.String())
+
+	rr = serve("ISAKVALIDVALIDVALID1", "wrong")
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.Contains(t, rr.Body.String(), "<Code>SignatureDoesNotMatch</Code>")
+
+	rr = serve("ISAKREVOKEDREVOKED12", "secret-of-ISAKREVOKEDREVOKED12")
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.Contains(t, rr.Body.String(), "<Code>InvalidAccessKeyId</Code>")
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/cshum/imagor-studio/server/internal/resolver"
+	"github.com/cshum/imagor-studio/server/pkg/auth"
+)
+
+// WebsocketInit authenticates GraphQL WebSocket connections from the
+// Authorization field of the connection_init payload, as browsers cannot set
+// headers on the upgrade request. Connections already authenticated by
+// JWTMiddleware are accepted as they are. The home path and tenant are
+// resolved once for the lifetime of the connection as in HomePathMiddleware;
+// users is nil when sessions are not scoped. sessions rejects tokens of
+// revoked login sessions as in JWTMiddleware.
+func WebsocketInit(tokenManager *auth.TokenManager, sessions SessionVerifier, users UserLookup, tenants TenantLookup) transport.WebsocketInitFunc {
+	return func(ctx context.Context, payload transport.InitPayload) (context.Context, *transport.InitPayload, error) {
+		if payload.Authorization() == "" {
+			if _, err := auth.GetClaimsFromContext(ctx); err == nil {
+				return ctx, nil, nil
+			}
+		}
+		claims, err := authenticate(ctx, tokenManager, nil, sessions, payload.Authorization())
+		if err != nil {
+			return ctx, nil, err
+		}
+		ctx = auth.SetClaimsInContext(ctx, claims)
+		ctx = resolver.WithUserID(ctx, claims.UserID)
+		if users == nil {
+			return ctx, nil, nil
+		}
+		scope, err := scopeFor(ctx, users, tenants, claims)
+		if errors.Is(err, errTenantChanged) {
+			return ctx, nil, errors.New("session is no longer valid")
+		}
+		if err != nil {
+			return ctx, nil, errors.New("failed to load user")
+		}
+		return scope.apply(ctx), nil, nil
+	}
+}
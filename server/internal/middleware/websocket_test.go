@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/cshum/imagor-studio/server/internal/resolver"
+	"github.com/cshum/imagor-studio/server/pkg/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebsocketInit(t *testing.T) {
+	tokenManager := auth.NewTokenManager("test-secret", time.Hour)
+	homePath := "teams/alice"
+	users := fakeUserLookup{"alice": {ID: "alice", Role: "user", HomePath: &homePath}}
+	init := WebsocketInit(tokenManager, nil, users, nil)
+
+	token, err := tokenManager.GenerateToken("alice", "user", []string{"read"}, "")
+	require.NoError(t, err)
+	ctx, _, err := init(context.Background(), transport.InitPayload{"Authorization": "Bearer " + token})
+	require.NoError(t, err)
+	claims, err := auth.GetClaimsFromContext(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", claims.UserID)
+	userID, err := resolver.GetUserIDFromContext(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", userID)
+	assert.Equal(t, "teams/alice", resolver.GetHomePathFromContext(ctx))
+
+	_, _, err = init(context.Background(), transport.InitPayload{})
+	assert.Error(t, err)
+	_, _, err = init(context.Background(), transport.InitPayload{"Authorization": "Bearer invalid.token.here"})
+	assert.Error(t, err)
+
+	// Connections authenticated by the upgrade request are kept as they are
+	authenticated := auth.SetClaimsInContext(context.Background(), &auth.Claims{UserID: "bob"})
+	ctx, _, err = init(authenticated, transport.InitPayload{})
+	require.NoError(t, err)
+	assert.Equal(t, authenticated, ctx)
+
+	// Lookup failures do not leak to the client
+	brokenToken, err := tokenManager.GenerateToken("broken", "user", []string{"read"}, "")
+	require.NoError(t, err)
+	_, _, err = WebsocketInit(tokenManager, nil, fakeUserLookup{}, nil)(context.Background(), transport.InitPayload{"authorization": "Bearer " + brokenToken})
+	assert.EqualError(t, err, "failed to load user")
+
+	// Without a user lookup sessions are not scoped
+	ctx, _, err = WebsocketInit(tokenManager, nil, nil, nil)(context.Background(), transport.InitPayload{"Authorization": "Bearer " + token})
+	require.NoError(t, err)
+	assert.Empty(t, resolver.GetHomePathFromContext(ctx))
+
+	// Members of a tenant are confined to it
+	acme := "acme"
+	tenantInit := WebsocketInit(tokenManager, nil,
+		fakeUserLookup{"alice": {ID: "alice", Role: "user", TenantID: &acme}},
+		fakeTenantLookup{"acme": {ID: "acme", StorageRoot: "tenants/acme"}})
+	ctx, _, err = tenantInit(context.Background(), transport.InitPayload{"Authorization": "Bearer " + token})
+	require.NoError(t, err)
+	assert.Equal(t, "tenants/acme", resolver.GetHomePathFromContext(ctx))
+	assert.Equal(t, "acme", resolver.GetTenantIDFromContext(ctx))
+}
+
+func TestJWTMiddleware_WebsocketUpgrade(t *testing.T) {
+	tokenManager := auth.NewTokenManager("test-secret", time.Hour)
+	var reached bool
+	handler := JWTMiddleware(tokenManager, nil, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+		_, err := auth.GetClaimsFromContext(r.Context())
+		assert.Error(t, err)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/query", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.True(t, reached, "upgrade without a token is authenticated by connection_init")
+
+	// Plain requests still need the header
+	reached = false
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/query", nil))
+	assert.False(t, reached)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
@@ -0,0 +1,91 @@
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		for _, model := range []interface{}{(*Tag)(nil), (*TagAlias)(nil), (*FileTag)(nil)} {
+			if _, err := db.NewCreateTable().Model(model).IfNotExists().Exec(ctx); err != nil {
+				return err
+			}
+		}
+		for _, idx := range tagIndexes {
+			query := db.NewCreateIndex().
+				Model(idx.model).
+				Index(idx.name).
+				Column(idx.columns...)
+			if idx.unique {
+				query = query.Unique()
+			}
+			if _, err := query.Exec(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		for _, idx := range tagIndexes {
+			if _, err := db.NewDropIndex().Model(idx.model).Index(idx.name).IfExists().Exec(ctx); err != nil {
+				return err
+			}
+		}
+		for _, model := range []interface{}{(*FileTag)(nil), (*TagAlias)(nil), (*Tag)(nil)} {
+			if _, err := db.NewDropTable().Model(model).IfExists().Exec(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+var tagIndexes = []struct {
+	model   interface{}
+	name    string
+	columns []string
+	unique  bool
+}{
+	{(*Tag)(nil), "idx_tags_scope_path_key", []string{"scope", "path_key"}, true},
+	{(*Tag)(nil), "idx_tags_parent_id", []string{"parent_id"}, false},
+	{(*TagAlias)(nil), "idx_tag_aliases_scope_alias_key", []string{"scope", "alias_key"}, true},
+	{(*TagAlias)(nil), "idx_tag_aliases_tag_id", []string{"tag_id"}, false},
+	{(*FileTag)(nil), "idx_file_tags_scope_file_tag", []string{"scope", "file_path", "tag_id"}, true},
+	{(*FileTag)(nil), "idx_file_tags_tag_id", []string{"tag_id"}, false},
+}
+
+type Tag struct {
+	bun.BaseModel `bun:"table:tags,alias:t"`
+
+	ID        string    `bun:"id,pk,type:text"`
+	Scope     string    `bun:"scope,notnull"`
+	ParentID  *string   `bun:"parent_id,type:text"`
+	Name      string    `bun:"name,notnull"`
+	Path      string    `bun:"path,notnull"`
+	PathKey   string    `bun:"path_key,notnull"`
+	CreatedAt time.Time `bun:"created_at,notnull,default:current_timestamp"`
+	UpdatedAt time.Time `bun:"updated_at,notnull,default:current_timestamp"`
+}
+
+type TagAlias struct {
+	bun.BaseModel `bun:"table:tag_aliases,alias:ta"`
+
+	ID        string    `bun:"id,pk,type:text"`
+	Scope     string    `bun:"scope,notnull"`
+	TagID     string    `bun:"tag_id,notnull,type:text"`
+	Alias     string    `bun:"alias,notnull"`
+	AliasKey  string    `bun:"alias_key,notnull"`
+	CreatedAt time.Time `bun:"created_at,notnull,default:current_timestamp"`
+}
+
+type FileTag struct {
+	bun.BaseModel `bun:"table:file_tags,alias:ft"`
+
+	ID        string    `bun:"id,pk,type:text"`
+	Scope     string    `bun:"scope,notnull"`
+	FilePath  string    `bun:"file_path,notnull"`
+	TagID     string    `bun:"tag_id,notnull,type:text"`
+	CreatedAt time.Time `bun:"created_at,notnull,default:current_timestamp"`
+}
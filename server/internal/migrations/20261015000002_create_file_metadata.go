@@ -0,0 +1,40 @@
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewCreateTable().Model((*FileMetadata)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.NewCreateIndex().
+			Model((*FileMetadata)(nil)).
+			Index("idx_file_metadata_scope_file_path").
+			Unique().
+			Column("scope", "file_path").
+			Exec(ctx)
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewDropIndex().Model((*FileMetadata)(nil)).Index("idx_file_metadata_scope_file_path").IfExists().Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.NewDropTable().Model((*FileMetadata)(nil)).IfExists().Exec(ctx)
+		return err
+	})
+}
+
+type FileMetadata struct {
+	bun.BaseModel `bun:"table:file_metadata,alias:fm"`
+
+	ID          string    `bun:"id,pk,type:text"`
+	Scope       string    `bun:"scope,notnull"`
+	FilePath    string    `bun:"file_path,notnull"`
+	Fingerprint string    `bun:"fingerprint,notnull"`
+	Data        string    `bun:"data,notnull"`
+	CreatedAt   time.Time `bun:"created_at,notnull,default:current_timestamp"`
+}
@@ -0,0 +1,28 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		// Add home_path column (nullable — users without a home path see the whole storage)
+		_, err := db.ExecContext(ctx,
+			`ALTER TABLE users ADD COLUMN home_path TEXT`,
+		)
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		// SQLite does not support DROP COLUMN — skip on SQLite (tests use fresh DB)
+		if db.Dialect().Name() != dialect.SQLite {
+			if _, err := db.ExecContext(ctx,
+				`ALTER TABLE users DROP COLUMN home_path`,
+			); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
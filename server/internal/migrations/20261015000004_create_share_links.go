@@ -0,0 +1,41 @@
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewCreateTable().Model((*ShareLink)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.NewCreateIndex().
+			Model((*ShareLink)(nil)).
+			Index("idx_share_links_token").
+			Unique().
+			Column("token").
+			Exec(ctx)
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewDropIndex().Model((*ShareLink)(nil)).Index("idx_share_links_token").IfExists().Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.NewDropTable().Model((*ShareLink)(nil)).IfExists().Exec(ctx)
+		return err
+	})
+}
+
+type ShareLink struct {
+	bun.BaseModel `bun:"table:share_links,alias:sl"`
+
+	ID            string    `bun:"id,pk,type:text"`
+	Token         string    `bun:"token,notnull"`
+	Path          string    `bun:"path,notnull"`
+	CreatedBy     string    `bun:"created_by,notnull"`
+	AllowDownload bool      `bun:"allow_download,notnull,default:false"`
+	ExpiresAt     time.Time `bun:"expires_at,notnull"`
+	CreatedAt     time.Time `bun:"created_at,notnull,default:current_timestamp"`
+}
@@ -0,0 +1,28 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		// Add password_hash column (nullable — links without a password open directly)
+		_, err := db.ExecContext(ctx,
+			`ALTER TABLE share_links ADD COLUMN password_hash TEXT`,
+		)
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		// SQLite does not support DROP COLUMN — skip on SQLite (tests use fresh DB)
+		if db.Dialect().Name() != dialect.SQLite {
+			if _, err := db.ExecContext(ctx,
+				`ALTER TABLE share_links DROP COLUMN password_hash`,
+			); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,40 @@
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewCreateTable().Model((*ImageEdit)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.NewCreateIndex().
+			Model((*ImageEdit)(nil)).
+			Index("idx_image_edits_scope_file_path").
+			Unique().
+			Column("scope", "file_path").
+			Exec(ctx)
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewDropIndex().Model((*ImageEdit)(nil)).Index("idx_image_edits_scope_file_path").IfExists().Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.NewDropTable().Model((*ImageEdit)(nil)).IfExists().Exec(ctx)
+		return err
+	})
+}
+
+type ImageEdit struct {
+	bun.BaseModel `bun:"table:image_edits,alias:ie"`
+
+	ID        string    `bun:"id,pk,type:text"`
+	Scope     string    `bun:"scope,notnull"`
+	FilePath  string    `bun:"file_path,notnull"`
+	Data      string    `bun:"data,notnull"`
+	UpdatedBy string    `bun:"updated_by,notnull"`
+	UpdatedAt time.Time `bun:"updated_at,notnull,default:current_timestamp"`
+}
@@ -0,0 +1,47 @@
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewCreateTable().Model((*Job)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.NewCreateIndex().
+			Model((*Job)(nil)).
+			Index("idx_jobs_created_at").
+			Column("created_at").
+			Exec(ctx)
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewDropIndex().Model((*Job)(nil)).Index("idx_jobs_created_at").IfExists().Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.NewDropTable().Model((*Job)(nil)).IfExists().Exec(ctx)
+		return err
+	})
+}
+
+type Job struct {
+	bun.BaseModel `bun:"table:jobs,alias:j"`
+
+	ID          string     `bun:"id,pk,type:text"`
+	Kind        string     `bun:"kind,notnull"`
+	OwnerID     string     `bun:"owner_id,notnull"`
+	Status      string     `bun:"status,notnull"`
+	Completed   int        `bun:"completed,notnull,default:0"`
+	Total       int        `bun:"total,notnull,default:0"`
+	Message     string     `bun:"message,notnull,default:''"`
+	Error       string     `bun:"error,notnull,default:''"`
+	Results     string     `bun:"results,notnull,default:'[]'"`
+	CreatedAt   time.Time  `bun:"created_at,notnull"`
+	UpdatedAt   time.Time  `bun:"updated_at,notnull"`
+	StartedAt   *time.Time `bun:"started_at"`
+	FinishedAt  *time.Time `bun:"finished_at"`
+	HeartbeatAt time.Time  `bun:"heartbeat_at,notnull"`
+}
@@ -0,0 +1,40 @@
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewCreateTable().Model((*FolderListing)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.NewCreateIndex().
+			Model((*FolderListing)(nil)).
+			Index("idx_folder_listings_scope_folder_path").
+			Unique().
+			Column("scope", "folder_path").
+			Exec(ctx)
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewDropIndex().Model((*FolderListing)(nil)).Index("idx_folder_listings_scope_folder_path").IfExists().Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.NewDropTable().Model((*FolderListing)(nil)).IfExists().Exec(ctx)
+		return err
+	})
+}
+
+type FolderListing struct {
+	bun.BaseModel `bun:"table:folder_listings,alias:fl"`
+
+	ID         string    `bun:"id,pk,type:text"`
+	Scope      string    `bun:"scope,notnull"`
+	FolderPath string    `bun:"folder_path,notnull"`
+	ETag       string    `bun:"etag,notnull"`
+	Items      string    `bun:"items,notnull"`
+	CachedAt   time.Time `bun:"cached_at,notnull"`
+}
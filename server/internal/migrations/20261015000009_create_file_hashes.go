@@ -0,0 +1,50 @@
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewCreateTable().Model((*FileHash)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := db.NewCreateIndex().
+			Model((*FileHash)(nil)).
+			Index("idx_file_hashes_scope_file_path").
+			Unique().
+			Column("scope", "file_path").
+			Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.NewCreateIndex().
+			Model((*FileHash)(nil)).
+			Index("idx_file_hashes_scope_hash").
+			Column("scope", "hash").
+			Exec(ctx)
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		for _, index := range []string{"idx_file_hashes_scope_hash", "idx_file_hashes_scope_file_path"} {
+			if _, err := db.NewDropIndex().Model((*FileHash)(nil)).Index(index).IfExists().Exec(ctx); err != nil {
+				return err
+			}
+		}
+		_, err := db.NewDropTable().Model((*FileHash)(nil)).IfExists().Exec(ctx)
+		return err
+	})
+}
+
+type FileHash struct {
+	bun.BaseModel `bun:"table:file_hashes,alias:fh"`
+
+	ID          string    `bun:"id,pk,type:text"`
+	Scope       string    `bun:"scope,notnull"`
+	FilePath    string    `bun:"file_path,notnull"`
+	Size        int64     `bun:"size,notnull"`
+	Fingerprint string    `bun:"fingerprint,notnull"`
+	Hash        string    `bun:"hash,notnull"`
+	HashedAt    time.Time `bun:"hashed_at,notnull"`
+}
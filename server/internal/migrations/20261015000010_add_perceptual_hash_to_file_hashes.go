@@ -0,0 +1,27 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		// Nullable, only images get a perceptual hash
+		_, err := db.ExecContext(ctx,
+			`ALTER TABLE file_hashes ADD COLUMN perceptual_hash TEXT`,
+		)
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		// SQLite does not support DROP COLUMN — skip on SQLite (tests use fresh DB)
+		if db.Dialect().Name() != dialect.SQLite {
+			_, err := db.ExecContext(ctx,
+				`ALTER TABLE file_hashes DROP COLUMN perceptual_hash`,
+			)
+			return err
+		}
+		return nil
+	})
+}
@@ -0,0 +1,48 @@
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewCreateTable().Model((*Favorite)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := db.NewCreateIndex().
+			Model((*Favorite)(nil)).
+			Index("idx_favorites_user_scope_file_path").
+			Unique().
+			Column("user_id", "scope", "file_path").
+			Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.NewCreateIndex().
+			Model((*Favorite)(nil)).
+			Index("idx_favorites_scope_file_path").
+			Column("scope", "file_path").
+			Exec(ctx)
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		for _, index := range []string{"idx_favorites_scope_file_path", "idx_favorites_user_scope_file_path"} {
+			if _, err := db.NewDropIndex().Model((*Favorite)(nil)).Index(index).IfExists().Exec(ctx); err != nil {
+				return err
+			}
+		}
+		_, err := db.NewDropTable().Model((*Favorite)(nil)).IfExists().Exec(ctx)
+		return err
+	})
+}
+
+type Favorite struct {
+	bun.BaseModel `bun:"table:favorites,alias:fav"`
+
+	ID        string    `bun:"id,pk,type:text"`
+	UserID    string    `bun:"user_id,notnull,type:text"`
+	Scope     string    `bun:"scope,notnull"`
+	FilePath  string    `bun:"file_path,notnull"`
+	CreatedAt time.Time `bun:"created_at,notnull,default:current_timestamp"`
+}
@@ -0,0 +1,76 @@
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewCreateTable().Model((*Album)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := db.NewCreateIndex().
+			Model((*Album)(nil)).
+			Index("idx_albums_scope").
+			Column("scope").
+			Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := db.NewCreateTable().Model((*AlbumItem)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := db.NewCreateIndex().
+			Model((*AlbumItem)(nil)).
+			Index("idx_album_items_album_file_path").
+			Unique().
+			Column("album_id", "file_path").
+			Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.NewCreateIndex().
+			Model((*AlbumItem)(nil)).
+			Index("idx_album_items_scope_file_path").
+			Column("scope", "file_path").
+			Exec(ctx)
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		for _, index := range []string{"idx_album_items_scope_file_path", "idx_album_items_album_file_path"} {
+			if _, err := db.NewDropIndex().Model((*AlbumItem)(nil)).Index(index).IfExists().Exec(ctx); err != nil {
+				return err
+			}
+		}
+		if _, err := db.NewDropTable().Model((*AlbumItem)(nil)).IfExists().Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := db.NewDropIndex().Model((*Album)(nil)).Index("idx_albums_scope").IfExists().Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.NewDropTable().Model((*Album)(nil)).IfExists().Exec(ctx)
+		return err
+	})
+}
+
+type Album struct {
+	bun.BaseModel `bun:"table:albums,alias:alb"`
+
+	ID        string    `bun:"id,pk,type:text"`
+	Scope     string    `bun:"scope,notnull"`
+	Name      string    `bun:"name,notnull"`
+	CreatedBy string    `bun:"created_by,type:text"`
+	CreatedAt time.Time `bun:"created_at,notnull,default:current_timestamp"`
+	UpdatedAt time.Time `bun:"updated_at,notnull,default:current_timestamp"`
+}
+
+type AlbumItem struct {
+	bun.BaseModel `bun:"table:album_items,alias:albi"`
+
+	ID       string    `bun:"id,pk,type:text"`
+	AlbumID  string    `bun:"album_id,notnull,type:text"`
+	Scope    string    `bun:"scope,notnull"`
+	FilePath string    `bun:"file_path,notnull"`
+	Position int       `bun:"position,notnull"`
+	AddedAt  time.Time `bun:"added_at,notnull,default:current_timestamp"`
+}
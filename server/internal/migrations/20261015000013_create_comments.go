@@ -0,0 +1,41 @@
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewCreateTable().Model((*Comment)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.NewCreateIndex().
+			Model((*Comment)(nil)).
+			Index("idx_comments_scope_file_path").
+			Column("scope", "file_path", "created_at").
+			Exec(ctx)
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewDropIndex().Model((*Comment)(nil)).Index("idx_comments_scope_file_path").IfExists().Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.NewDropTable().Model((*Comment)(nil)).IfExists().Exec(ctx)
+		return err
+	})
+}
+
+type Comment struct {
+	bun.BaseModel `bun:"table:comments,alias:cmt"`
+
+	ID         string     `bun:"id,pk,type:text"`
+	Scope      string     `bun:"scope,notnull"`
+	FilePath   string     `bun:"file_path,notnull"`
+	AuthorID   string     `bun:"author_id,notnull,type:text"`
+	AuthorName string     `bun:"author_name,notnull"`
+	Text       string     `bun:"text,notnull"`
+	CreatedAt  time.Time  `bun:"created_at,notnull,default:current_timestamp"`
+	EditedAt   *time.Time `bun:"edited_at"`
+}
@@ -0,0 +1,49 @@
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewCreateTable().Model((*Rating)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := db.NewCreateIndex().
+			Model((*Rating)(nil)).
+			Index("idx_ratings_user_scope_file_path").
+			Unique().
+			Column("user_id", "scope", "file_path").
+			Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.NewCreateIndex().
+			Model((*Rating)(nil)).
+			Index("idx_ratings_scope_file_path").
+			Column("scope", "file_path").
+			Exec(ctx)
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		for _, index := range []string{"idx_ratings_scope_file_path", "idx_ratings_user_scope_file_path"} {
+			if _, err := db.NewDropIndex().Model((*Rating)(nil)).Index(index).IfExists().Exec(ctx); err != nil {
+				return err
+			}
+		}
+		_, err := db.NewDropTable().Model((*Rating)(nil)).IfExists().Exec(ctx)
+		return err
+	})
+}
+
+type Rating struct {
+	bun.BaseModel `bun:"table:ratings,alias:rat"`
+
+	ID       string    `bun:"id,pk,type:text"`
+	UserID   string    `bun:"user_id,notnull,type:text"`
+	Scope    string    `bun:"scope,notnull"`
+	FilePath string    `bun:"file_path,notnull"`
+	Rating   int       `bun:"rating,notnull"`
+	RatedAt  time.Time `bun:"rated_at,notnull,default:current_timestamp"`
+}
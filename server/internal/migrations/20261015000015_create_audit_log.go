@@ -0,0 +1,54 @@
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewCreateTable().Model((*AuditEntry)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := db.NewCreateIndex().
+			Model((*AuditEntry)(nil)).
+			Index("idx_audit_log_created_at").
+			Column("created_at").
+			Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.NewCreateIndex().
+			Model((*AuditEntry)(nil)).
+			Index("idx_audit_log_user_id_created_at").
+			Column("user_id", "created_at").
+			Exec(ctx)
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		for _, index := range []string{"idx_audit_log_user_id_created_at", "idx_audit_log_created_at"} {
+			if _, err := db.NewDropIndex().Model((*AuditEntry)(nil)).Index(index).IfExists().Exec(ctx); err != nil {
+				return err
+			}
+		}
+		_, err := db.NewDropTable().Model((*AuditEntry)(nil)).IfExists().Exec(ctx)
+		return err
+	})
+}
+
+type AuditEntry struct {
+	bun.BaseModel `bun:"table:audit_log,alias:aud"`
+
+	ID        string    `bun:"id,pk,type:text"`
+	CreatedAt time.Time `bun:"created_at,notnull,default:current_timestamp"`
+	UserID    string    `bun:"user_id,notnull,type:text"`
+	Role      string    `bun:"role,notnull"`
+	Operation string    `bun:"operation,notnull"`
+	SpaceID   string    `bun:"space_id,notnull"`
+	Path      string    `bun:"path,notnull"`
+	DestPath  string    `bun:"dest_path,notnull"`
+	PathCount int       `bun:"path_count,notnull"`
+	Target    string    `bun:"target,notnull"`
+	ClientIP  string    `bun:"client_ip,notnull"`
+	Error     string    `bun:"error,notnull"`
+}
@@ -0,0 +1,53 @@
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewCreateTable().Model((*APIToken)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := db.NewCreateIndex().
+			Model((*APIToken)(nil)).
+			Index("idx_api_tokens_token_hash").
+			Unique().
+			Column("token_hash").
+			Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.NewCreateIndex().
+			Model((*APIToken)(nil)).
+			Index("idx_api_tokens_user_id").
+			Column("user_id").
+			Exec(ctx)
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		for _, index := range []string{"idx_api_tokens_user_id", "idx_api_tokens_token_hash"} {
+			if _, err := db.NewDropIndex().Model((*APIToken)(nil)).Index(index).IfExists().Exec(ctx); err != nil {
+				return err
+			}
+		}
+		_, err := db.NewDropTable().Model((*APIToken)(nil)).IfExists().Exec(ctx)
+		return err
+	})
+}
+
+type APIToken struct {
+	bun.BaseModel `bun:"table:api_tokens,alias:apt"`
+
+	ID         string     `bun:"id,pk,type:text"`
+	UserID     string     `bun:"user_id,notnull,type:text"`
+	OrgID      string     `bun:"org_id,notnull,type:text"`
+	Name       string     `bun:"name,notnull"`
+	TokenHash  string     `bun:"token_hash,notnull"`
+	Prefix     string     `bun:"prefix,notnull"`
+	Scopes     string     `bun:"scopes,notnull"`
+	ExpiresAt  *time.Time `bun:"expires_at"`
+	LastUsedAt *time.Time `bun:"last_used_at"`
+	CreatedAt  time.Time  `bun:"created_at,notnull,default:current_timestamp"`
+}
@@ -0,0 +1,60 @@
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewCreateTable().Model((*Session)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := db.NewCreateIndex().
+			Model((*Session)(nil)).
+			Index("idx_sessions_token_hash").
+			Unique().
+			Column("token_hash").
+			Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := db.NewCreateIndex().
+			Model((*Session)(nil)).
+			Index("idx_sessions_previous_hash").
+			Column("previous_hash").
+			Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.NewCreateIndex().
+			Model((*Session)(nil)).
+			Index("idx_sessions_user_id").
+			Column("user_id").
+			Exec(ctx)
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		for _, index := range []string{"idx_sessions_user_id", "idx_sessions_previous_hash", "idx_sessions_token_hash"} {
+			if _, err := db.NewDropIndex().Model((*Session)(nil)).Index(index).IfExists().Exec(ctx); err != nil {
+				return err
+			}
+		}
+		_, err := db.NewDropTable().Model((*Session)(nil)).IfExists().Exec(ctx)
+		return err
+	})
+}
+
+type Session struct {
+	bun.BaseModel `bun:"table:sessions,alias:ses"`
+
+	ID           string    `bun:"id,pk,type:text"`
+	UserID       string    `bun:"user_id,notnull,type:text"`
+	OrgID        string    `bun:"org_id,notnull,type:text"`
+	TokenHash    string    `bun:"token_hash,notnull"`
+	PreviousHash string    `bun:"previous_hash,notnull"`
+	UserAgent    string    `bun:"user_agent,notnull"`
+	ClientIP     string    `bun:"client_ip,notnull"`
+	CreatedAt    time.Time `bun:"created_at,notnull,default:current_timestamp"`
+	LastUsedAt   time.Time `bun:"last_used_at,notnull,default:current_timestamp"`
+	ExpiresAt    time.Time `bun:"expires_at,notnull"`
+}
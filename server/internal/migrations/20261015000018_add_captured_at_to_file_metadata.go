@@ -0,0 +1,70 @@
+package migrations
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		// Local capture time, e.g. 2024-05-01T12:34:56, nullable as not every
+		// image records one
+		if _, err := db.ExecContext(ctx, `ALTER TABLE file_metadata ADD COLUMN captured_at TEXT`); err != nil {
+			return err
+		}
+		if _, err := db.NewCreateIndex().
+			Model((*FileMetadata)(nil)).
+			Index("idx_file_metadata_scope_captured_at").
+			Column("scope", "captured_at").
+			Exec(ctx); err != nil {
+			return err
+		}
+		return backfillCapturedAt(ctx, db)
+	}, func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewDropIndex().Model((*FileMetadata)(nil)).Index("idx_file_metadata_scope_captured_at").IfExists().Exec(ctx); err != nil {
+			return err
+		}
+		// SQLite does not support DROP COLUMN — skip on SQLite (tests use fresh DB)
+		if db.Dialect().Name() != dialect.SQLite {
+			_, err := db.ExecContext(ctx, `ALTER TABLE file_metadata DROP COLUMN captured_at`)
+			return err
+		}
+		return nil
+	})
+}
+
+// backfillCapturedAt indexes the capture times of metadata cached before
+// the column existed
+func backfillCapturedAt(ctx context.Context, db *bun.DB) error {
+	var rows []struct {
+		ID   string `bun:"id"`
+		Data string `bun:"data"`
+	}
+	if err := db.NewSelect().Table("file_metadata").Column("id", "data").Scan(ctx, &rows); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		var data struct {
+			CaptureTime string `json:"captureTime"`
+		}
+		if json.Unmarshal([]byte(row.Data), &data) != nil || data.CaptureTime == "" {
+			continue
+		}
+		// Zoned times keep the wall time of their offset
+		capturedAt := data.CaptureTime
+		if t, err := time.Parse(time.RFC3339, capturedAt); err == nil {
+			capturedAt = t.Format("2006-01-02T15:04:05")
+		}
+		if _, err := db.NewUpdate().Table("file_metadata").
+			Set("captured_at = ?", capturedAt).
+			Where("id = ?", row.ID).
+			Exec(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
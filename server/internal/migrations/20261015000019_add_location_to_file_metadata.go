@@ -0,0 +1,70 @@
+package migrations
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		// GPS position in decimal degrees, nullable as most images have none
+		for _, column := range []string{"latitude", "longitude"} {
+			if _, err := db.ExecContext(ctx, `ALTER TABLE file_metadata ADD COLUMN `+column+` DOUBLE PRECISION`); err != nil {
+				return err
+			}
+		}
+		if _, err := db.NewCreateIndex().
+			Model((*FileMetadata)(nil)).
+			Index("idx_file_metadata_scope_latitude").
+			Column("scope", "latitude").
+			Exec(ctx); err != nil {
+			return err
+		}
+		return backfillLocation(ctx, db)
+	}, func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewDropIndex().Model((*FileMetadata)(nil)).Index("idx_file_metadata_scope_latitude").IfExists().Exec(ctx); err != nil {
+			return err
+		}
+		// SQLite does not support DROP COLUMN — skip on SQLite (tests use fresh DB)
+		if db.Dialect().Name() != dialect.SQLite {
+			for _, column := range []string{"latitude", "longitude"} {
+				if _, err := db.ExecContext(ctx, `ALTER TABLE file_metadata DROP COLUMN `+column); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// backfillLocation indexes the GPS positions of metadata cached before the
+// columns existed
+func backfillLocation(ctx context.Context, db *bun.DB) error {
+	var rows []struct {
+		ID   string `bun:"id"`
+		Data string `bun:"data"`
+	}
+	if err := db.NewSelect().Table("file_metadata").Column("id", "data").Scan(ctx, &rows); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		var data struct {
+			Latitude  *float64 `json:"latitude"`
+			Longitude *float64 `json:"longitude"`
+		}
+		if json.Unmarshal([]byte(row.Data), &data) != nil || data.Latitude == nil || data.Longitude == nil {
+			continue
+		}
+		if _, err := db.NewUpdate().Table("file_metadata").
+			Set("latitude = ?", *data.Latitude).
+			Set("longitude = ?", *data.Longitude).
+			Where("id = ?", row.ID).
+			Exec(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
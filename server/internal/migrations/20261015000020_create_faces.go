@@ -0,0 +1,108 @@
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewCreateTable().Model((*Person)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := db.NewCreateIndex().
+			Model((*Person)(nil)).
+			Index("idx_people_scope").
+			Column("scope").
+			Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := db.NewCreateTable().Model((*Face)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := db.NewCreateIndex().
+			Model((*Face)(nil)).
+			Index("idx_faces_scope_file_path").
+			Column("scope", "file_path").
+			Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := db.NewCreateIndex().
+			Model((*Face)(nil)).
+			Index("idx_faces_person_id").
+			Column("person_id").
+			Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := db.NewCreateTable().Model((*FaceScan)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.NewCreateIndex().
+			Model((*FaceScan)(nil)).
+			Index("idx_face_scans_scope_file_path").
+			Unique().
+			Column("scope", "file_path").
+			Exec(ctx)
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewDropIndex().Model((*FaceScan)(nil)).Index("idx_face_scans_scope_file_path").IfExists().Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := db.NewDropTable().Model((*FaceScan)(nil)).IfExists().Exec(ctx); err != nil {
+			return err
+		}
+		for _, index := range []string{"idx_faces_person_id", "idx_faces_scope_file_path"} {
+			if _, err := db.NewDropIndex().Model((*Face)(nil)).Index(index).IfExists().Exec(ctx); err != nil {
+				return err
+			}
+		}
+		if _, err := db.NewDropTable().Model((*Face)(nil)).IfExists().Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := db.NewDropIndex().Model((*Person)(nil)).Index("idx_people_scope").IfExists().Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.NewDropTable().Model((*Person)(nil)).IfExists().Exec(ctx)
+		return err
+	})
+}
+
+type Person struct {
+	bun.BaseModel `bun:"table:people,alias:ppl"`
+
+	ID        string    `bun:"id,pk,type:text"`
+	Scope     string    `bun:"scope,notnull"`
+	Name      string    `bun:"name,nullzero"`
+	Centroid  string    `bun:"centroid,notnull"`
+	FaceCount int       `bun:"face_count,notnull"`
+	CreatedAt time.Time `bun:"created_at,notnull,default:current_timestamp"`
+	UpdatedAt time.Time `bun:"updated_at,notnull,default:current_timestamp"`
+}
+
+type Face struct {
+	bun.BaseModel `bun:"table:faces,alias:fc"`
+
+	ID         string    `bun:"id,pk,type:text"`
+	Scope      string    `bun:"scope,notnull"`
+	FilePath   string    `bun:"file_path,notnull"`
+	PersonID   string    `bun:"person_id,notnull,type:text"`
+	BoxX       float64   `bun:"box_x,notnull"`
+	BoxY       float64   `bun:"box_y,notnull"`
+	BoxWidth   float64   `bun:"box_width,notnull"`
+	BoxHeight  float64   `bun:"box_height,notnull"`
+	Confidence float64   `bun:"confidence,notnull"`
+	Embedding  string    `bun:"embedding,notnull"`
+	DetectedAt time.Time `bun:"detected_at,notnull"`
+}
+
+type FaceScan struct {
+	bun.BaseModel `bun:"table:face_scans,alias:fcs"`
+
+	ID          string    `bun:"id,pk,type:text"`
+	Scope       string    `bun:"scope,notnull"`
+	FilePath    string    `bun:"file_path,notnull"`
+	Fingerprint string    `bun:"fingerprint,notnull"`
+	ScannedAt   time.Time `bun:"scanned_at,notnull"`
+}
@@ -0,0 +1,48 @@
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewCreateTable().Model((*LivePhoto)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := db.NewCreateIndex().
+			Model((*LivePhoto)(nil)).
+			Index("idx_live_photos_scope_image_path").
+			Unique().
+			Column("scope", "image_path").
+			Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.NewCreateIndex().
+			Model((*LivePhoto)(nil)).
+			Index("idx_live_photos_scope_video_path").
+			Column("scope", "video_path").
+			Exec(ctx)
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		for _, index := range []string{"idx_live_photos_scope_video_path", "idx_live_photos_scope_image_path"} {
+			if _, err := db.NewDropIndex().Model((*LivePhoto)(nil)).Index(index).IfExists().Exec(ctx); err != nil {
+				return err
+			}
+		}
+		_, err := db.NewDropTable().Model((*LivePhoto)(nil)).IfExists().Exec(ctx)
+		return err
+	})
+}
+
+type LivePhoto struct {
+	bun.BaseModel `bun:"table:live_photos,alias:lp"`
+
+	ID        string    `bun:"id,pk,type:text"`
+	Scope     string    `bun:"scope,notnull"`
+	ImagePath string    `bun:"image_path,notnull"`
+	VideoPath string    `bun:"video_path,notnull"`
+	CreatedAt time.Time `bun:"created_at,notnull,default:current_timestamp"`
+}
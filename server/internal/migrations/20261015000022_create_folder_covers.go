@@ -0,0 +1,49 @@
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewCreateTable().Model((*FolderCover)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := db.NewCreateIndex().
+			Model((*FolderCover)(nil)).
+			Index("idx_folder_covers_scope_folder_path").
+			Unique().
+			Column("scope", "folder_path").
+			Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.NewCreateIndex().
+			Model((*FolderCover)(nil)).
+			Index("idx_folder_covers_scope_file_path").
+			Column("scope", "file_path").
+			Exec(ctx)
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		for _, index := range []string{"idx_folder_covers_scope_file_path", "idx_folder_covers_scope_folder_path"} {
+			if _, err := db.NewDropIndex().Model((*FolderCover)(nil)).Index(index).IfExists().Exec(ctx); err != nil {
+				return err
+			}
+		}
+		_, err := db.NewDropTable().Model((*FolderCover)(nil)).IfExists().Exec(ctx)
+		return err
+	})
+}
+
+type FolderCover struct {
+	bun.BaseModel `bun:"table:folder_covers,alias:fc"`
+
+	ID         string    `bun:"id,pk,type:text"`
+	Scope      string    `bun:"scope,notnull"`
+	FolderPath string    `bun:"folder_path,notnull"`
+	FilePath   string    `bun:"file_path,notnull"`
+	UpdatedBy  string    `bun:"updated_by,notnull"`
+	UpdatedAt  time.Time `bun:"updated_at,notnull,default:current_timestamp"`
+}
@@ -0,0 +1,54 @@
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewCreateTable().Model((*FolderSetting)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := db.NewCreateIndex().
+			Model((*FolderSetting)(nil)).
+			Index("idx_folder_settings_scope_folder_path").
+			Unique().
+			Column("scope", "folder_path").
+			Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.NewCreateIndex().
+			Model((*FolderSetting)(nil)).
+			Index("idx_folder_settings_scope_parent_path").
+			Column("scope", "parent_path").
+			Exec(ctx)
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		for _, index := range []string{"idx_folder_settings_scope_parent_path", "idx_folder_settings_scope_folder_path"} {
+			if _, err := db.NewDropIndex().Model((*FolderSetting)(nil)).Index(index).IfExists().Exec(ctx); err != nil {
+				return err
+			}
+		}
+		_, err := db.NewDropTable().Model((*FolderSetting)(nil)).IfExists().Exec(ctx)
+		return err
+	})
+}
+
+type FolderSetting struct {
+	bun.BaseModel `bun:"table:folder_settings,alias:fs"`
+
+	ID          string    `bun:"id,pk,type:text"`
+	Scope       string    `bun:"scope,notnull"`
+	FolderPath  string    `bun:"folder_path,notnull"`
+	ParentPath  string    `bun:"parent_path,notnull"`
+	Description string    `bun:"description,notnull"`
+	SortBy      string    `bun:"sort_by,notnull"`
+	SortOrder   string    `bun:"sort_order,notnull"`
+	Pinned      string    `bun:"pinned,notnull"`
+	Hidden      bool      `bun:"hidden,notnull"`
+	UpdatedBy   string    `bun:"updated_by,notnull"`
+	UpdatedAt   time.Time `bun:"updated_at,notnull,default:current_timestamp"`
+}
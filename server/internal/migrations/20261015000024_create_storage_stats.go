@@ -0,0 +1,103 @@
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		for _, model := range []interface{}{(*StorageStat)(nil), (*StorageUpload)(nil), (*StorageQuota)(nil)} {
+			if _, err := db.NewCreateTable().Model(model).IfNotExists().Exec(ctx); err != nil {
+				return err
+			}
+		}
+		if _, err := db.NewCreateIndex().
+			Model((*StorageStat)(nil)).
+			Index("idx_storage_stats_scope_folder_path").
+			Unique().
+			Column("scope", "folder_path").
+			Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := db.NewCreateIndex().
+			Model((*StorageUpload)(nil)).
+			Index("idx_storage_uploads_scope_file_path").
+			Unique().
+			Column("scope", "file_path").
+			Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := db.NewCreateIndex().
+			Model((*StorageUpload)(nil)).
+			Index("idx_storage_uploads_scope_user_id").
+			Column("scope", "user_id").
+			Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.NewCreateIndex().
+			Model((*StorageQuota)(nil)).
+			Index("idx_storage_quotas_scope_kind_target").
+			Unique().
+			Column("scope", "kind", "target").
+			Exec(ctx)
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		for _, index := range []struct {
+			model interface{}
+			name  string
+		}{
+			{(*StorageQuota)(nil), "idx_storage_quotas_scope_kind_target"},
+			{(*StorageUpload)(nil), "idx_storage_uploads_scope_user_id"},
+			{(*StorageUpload)(nil), "idx_storage_uploads_scope_file_path"},
+			{(*StorageStat)(nil), "idx_storage_stats_scope_folder_path"},
+		} {
+			if _, err := db.NewDropIndex().Model(index.model).Index(index.name).IfExists().Exec(ctx); err != nil {
+				return err
+			}
+		}
+		for _, model := range []interface{}{(*StorageQuota)(nil), (*StorageUpload)(nil), (*StorageStat)(nil)} {
+			if _, err := db.NewDropTable().Model(model).IfExists().Exec(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+type StorageStat struct {
+	bun.BaseModel `bun:"table:storage_stats,alias:sst"`
+
+	ID         string    `bun:"id,pk,type:text"`
+	Scope      string    `bun:"scope,notnull"`
+	FolderPath string    `bun:"folder_path,notnull"`
+	FileCount  int64     `bun:"file_count,notnull"`
+	TotalSize  int64     `bun:"total_size,notnull"`
+	Extensions string    `bun:"extensions,notnull"`
+	ScannedAt  time.Time `bun:"scanned_at,notnull"`
+}
+
+type StorageUpload struct {
+	bun.BaseModel `bun:"table:storage_uploads,alias:sup"`
+
+	ID         string    `bun:"id,pk,type:text"`
+	Scope      string    `bun:"scope,notnull"`
+	FilePath   string    `bun:"file_path,notnull"`
+	UserID     string    `bun:"user_id,notnull"`
+	Size       int64     `bun:"size,notnull"`
+	UploadedAt time.Time `bun:"uploaded_at,notnull"`
+}
+
+type StorageQuota struct {
+	bun.BaseModel `bun:"table:storage_quotas,alias:sqt"`
+
+	ID         string    `bun:"id,pk,type:text"`
+	Scope      string    `bun:"scope,notnull"`
+	Kind       string    `bun:"kind,notnull"`
+	Target     string    `bun:"target,notnull"`
+	LimitBytes int64     `bun:"limit_bytes,notnull"`
+	UpdatedBy  string    `bun:"updated_by,notnull"`
+	UpdatedAt  time.Time `bun:"updated_at,notnull,default:current_timestamp"`
+}
@@ -0,0 +1,45 @@
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewCreateTable().Model((*Tenant)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+		// Add tenant_id column (nullable — users without a tenant are not isolated)
+		if _, err := db.ExecContext(ctx, `ALTER TABLE users ADD COLUMN tenant_id TEXT`); err != nil {
+			return err
+		}
+		_, err := db.ExecContext(ctx, `CREATE INDEX idx_users_tenant_id ON users(tenant_id)`)
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.ExecContext(ctx, `DROP INDEX IF EXISTS idx_users_tenant_id`); err != nil {
+			return err
+		}
+		// SQLite does not support DROP COLUMN — skip on SQLite (tests use fresh DB)
+		if db.Dialect().Name() != dialect.SQLite {
+			if _, err := db.ExecContext(ctx, `ALTER TABLE users DROP COLUMN tenant_id`); err != nil {
+				return err
+			}
+		}
+		_, err := db.NewDropTable().Model((*Tenant)(nil)).IfExists().Exec(ctx)
+		return err
+	})
+}
+
+type Tenant struct {
+	bun.BaseModel `bun:"table:tenants,alias:tnt"`
+
+	ID          string    `bun:"id,pk,type:text"`
+	Name        string    `bun:"name,notnull,unique"`
+	StorageRoot string    `bun:"storage_root,notnull,unique"`
+	CreatedAt   time.Time `bun:"created_at,notnull,default:current_timestamp"`
+	UpdatedAt   time.Time `bun:"updated_at,notnull,default:current_timestamp"`
+}
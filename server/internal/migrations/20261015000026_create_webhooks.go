@@ -0,0 +1,62 @@
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewCreateTable().Model((*Webhook)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := db.NewCreateTable().Model((*WebhookDelivery)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.NewCreateIndex().
+			Model((*WebhookDelivery)(nil)).
+			Index("idx_webhook_deliveries_webhook_id_created_at").
+			Column("webhook_id", "created_at").
+			Exec(ctx)
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewDropIndex().Model((*WebhookDelivery)(nil)).Index("idx_webhook_deliveries_webhook_id_created_at").IfExists().Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := db.NewDropTable().Model((*WebhookDelivery)(nil)).IfExists().Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.NewDropTable().Model((*Webhook)(nil)).IfExists().Exec(ctx)
+		return err
+	})
+}
+
+type Webhook struct {
+	bun.BaseModel `bun:"table:webhooks,alias:wh"`
+
+	ID        string    `bun:"id,pk,type:text"`
+	URL       string    `bun:"url,notnull"`
+	Secret    string    `bun:"secret,notnull"`
+	Events    string    `bun:"events,notnull"`
+	Active    bool      `bun:"active,notnull,default:true"`
+	CreatedBy string    `bun:"created_by,notnull,type:text"`
+	CreatedAt time.Time `bun:"created_at,notnull,default:current_timestamp"`
+	UpdatedAt time.Time `bun:"updated_at,notnull,default:current_timestamp"`
+}
+
+type WebhookDelivery struct {
+	bun.BaseModel `bun:"table:webhook_deliveries,alias:whd"`
+
+	ID             string    `bun:"id,pk,type:text"`
+	WebhookID      string    `bun:"webhook_id,notnull,type:text"`
+	Event          string    `bun:"event,notnull"`
+	Payload        string    `bun:"payload,notnull"`
+	Status         string    `bun:"status,notnull"`
+	Attempts       int       `bun:"attempts,notnull"`
+	ResponseStatus int       `bun:"response_status,notnull"`
+	Error          string    `bun:"error,notnull"`
+	CreatedAt      time.Time `bun:"created_at,notnull,default:current_timestamp"`
+	UpdatedAt      time.Time `bun:"updated_at,notnull,default:current_timestamp"`
+}
@@ -0,0 +1,41 @@
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewCreateTable().Model((*S3AccessKey)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.NewCreateIndex().
+			Model((*S3AccessKey)(nil)).
+			Index("idx_s3_access_keys_user_id").
+			Column("user_id").
+			Exec(ctx)
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewDropIndex().Model((*S3AccessKey)(nil)).Index("idx_s3_access_keys_user_id").IfExists().Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.NewDropTable().Model((*S3AccessKey)(nil)).IfExists().Exec(ctx)
+		return err
+	})
+}
+
+type S3AccessKey struct {
+	bun.BaseModel `bun:"table:s3_access_keys,alias:s3k"`
+
+	ID         string     `bun:"id,pk,type:text"`
+	UserID     string     `bun:"user_id,notnull,type:text"`
+	OrgID      string     `bun:"org_id,notnull,type:text"`
+	Name       string     `bun:"name,notnull"`
+	Secret     string     `bun:"secret,notnull"`
+	Scopes     string     `bun:"scopes,notnull"`
+	LastUsedAt *time.Time `bun:"last_used_at"`
+	CreatedAt  time.Time  `bun:"created_at,notnull,default:current_timestamp"`
+}
@@ -0,0 +1,52 @@
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewCreateTable().Model((*FileJournalEntry)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := db.NewCreateIndex().
+			Model((*FileJournalEntry)(nil)).
+			Index("idx_file_journal_changed_at").
+			Column("changed_at").
+			Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.NewCreateTable().Model((*FileJournalSnapshot)(nil)).IfNotExists().Exec(ctx)
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewDropTable().Model((*FileJournalSnapshot)(nil)).IfExists().Exec(ctx); err != nil {
+			return err
+		}
+		if _, err := db.NewDropIndex().Model((*FileJournalEntry)(nil)).Index("idx_file_journal_changed_at").IfExists().Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.NewDropTable().Model((*FileJournalEntry)(nil)).IfExists().Exec(ctx)
+		return err
+	})
+}
+
+type FileJournalEntry struct {
+	bun.BaseModel `bun:"table:file_journal,alias:fj"`
+
+	Seq       int64     `bun:"seq,pk,autoincrement"`
+	Kind      string    `bun:"kind,notnull"`
+	Path      string    `bun:"path,notnull"`
+	OldPath   string    `bun:"old_path,notnull"`
+	ChangedAt time.Time `bun:"changed_at,notnull,default:current_timestamp"`
+}
+
+type FileJournalSnapshot struct {
+	bun.BaseModel `bun:"table:file_journal_snapshot,alias:fjs"`
+
+	Path        string    `bun:"path,pk,type:text"`
+	Fingerprint string    `bun:"fingerprint,notnull"`
+	ScannedAt   time.Time `bun:"scanned_at,notnull"`
+}
@@ -0,0 +1,43 @@
+package migrations
+
+import (
+	"context"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewCreateTable().Model((*AutoUpload)(nil)).IfNotExists().Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.NewCreateIndex().
+			Model((*AutoUpload)(nil)).
+			Index("idx_auto_uploads_user_id_scope_hash").
+			Unique().
+			Column("user_id", "scope", "hash").
+			Exec(ctx)
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewDropIndex().Model((*AutoUpload)(nil)).Index("idx_auto_uploads_user_id_scope_hash").IfExists().Exec(ctx); err != nil {
+			return err
+		}
+		_, err := db.NewDropTable().Model((*AutoUpload)(nil)).IfExists().Exec(ctx)
+		return err
+	})
+}
+
+type AutoUpload struct {
+	bun.BaseModel `bun:"table:auto_uploads,alias:au"`
+
+	ID         string    `bun:"id,pk,type:text"`
+	UserID     string    `bun:"user_id,notnull,type:text"`
+	Scope      string    `bun:"scope,notnull"`
+	Hash       string    `bun:"hash,notnull"`
+	Path       string    `bun:"path,notnull"`
+	DeviceID   string    `bun:"device_id,notnull"`
+	Filename   string    `bun:"filename,notnull"`
+	CapturedAt time.Time `bun:"captured_at,notnull"`
+	CreatedAt  time.Time `bun:"created_at,notnull,default:current_timestamp"`
+}
@@ -0,0 +1,24 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		// Content type detected from the upload, empty for files not
+		// uploaded through the server since
+		_, err := db.ExecContext(ctx, `ALTER TABLE file_metadata ADD COLUMN content_type TEXT NOT NULL DEFAULT ''`)
+		return err
+	}, func(ctx context.Context, db *bun.DB) error {
+		// SQLite does not support DROP COLUMN — skip on SQLite (tests use fresh DB)
+		if db.Dialect().Name() != dialect.SQLite {
+			_, err := db.ExecContext(ctx, `ALTER TABLE file_metadata DROP COLUMN content_type`)
+			return err
+		}
+		return nil
+	})
+}
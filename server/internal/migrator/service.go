@@ -3,10 +3,14 @@ package migrator
 import (
 	"context"
 	"fmt"
+	"os"
 
+	"github.com/cshum/imagor-studio/server/internal/backup"
 	"github.com/cshum/imagor-studio/server/internal/config"
 	"github.com/cshum/imagor-studio/server/internal/database"
 	"github.com/cshum/imagor-studio/server/internal/migrations"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/pkg/encryption"
 	"github.com/uptrace/bun"
 	"github.com/uptrace/bun/migrate"
 	"go.uber.org/zap"
@@ -69,9 +73,39 @@ func (s *Service) ExecuteAutoMigrationFor(databaseURL string, forceAutoMigrate b
 
 // Execute runs the specified migration command
 func (s *Service) Execute(cfg *config.Config) error {
+	if cfg.MigrateCommand == "rotate-encryption-key" {
+		_, err := s.RotateEncryptionKey(cfg.DatabaseURL, cfg.JWTSecret)
+		return err
+	}
 	return s.ExecuteCommand(cfg.DatabaseURL, cfg.MigrateCommand)
 }
 
+// RotateEncryptionKey re-encrypts the encrypted registry values with a new
+// key. jwtSecret is the JWT secret of the server, read from the registry when
+// empty. It returns the number of re-encrypted values.
+func (s *Service) RotateEncryptionKey(databaseURL, jwtSecret string) (int, error) {
+	ctx := context.Background()
+	enc := encryption.NewService(databaseURL)
+	if jwtSecret == "" {
+		entry, err := registrystore.New(s.db, s.logger, enc).Get(ctx, registrystore.SystemOwnerID, "config.jwt_secret")
+		if err != nil {
+			return 0, fmt.Errorf("failed to read JWT secret: %w", err)
+		}
+		if entry == nil || entry.Value == "" {
+			return 0, fmt.Errorf("JWT secret not found, start the server once or pass --jwt-secret")
+		}
+		jwtSecret = entry.Value
+	}
+	enc.SetJWTKey(jwtSecret)
+
+	rotated, err := registrystore.RotateEncryptionKey(ctx, s.db, enc)
+	if err != nil {
+		return 0, fmt.Errorf("failed to rotate encryption key: %w", err)
+	}
+	s.logger.Info("Encryption key rotated", zap.Int("values", rotated))
+	return rotated, nil
+}
+
 // ExecuteCommand runs the specified migration command for the provided database configuration.
 func (s *Service) ExecuteCommand(databaseURL, command string) error {
 	// Validate command first
@@ -209,6 +243,49 @@ func (s *Service) migrateReset(migrator *migrate.Migrator) error {
 	return nil
 }
 
+// Backup writes a backup archive of the database to a new file at path
+func (s *Service) Backup(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create backup file: %w", err)
+	}
+	manifest, err := backup.Write(context.Background(), s.db, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+	s.logger.Info("Backup written",
+		zap.String("file", path),
+		zap.String("migration", manifest.Migration),
+		zap.Any("tables", manifest.Tables))
+	return nil
+}
+
+// Restore applies pending migrations and loads the backup archive at path
+// into the database, which must not have users yet
+func (s *Service) Restore(databaseURL, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open backup file: %w", err)
+	}
+	defer f.Close()
+	if err := s.ExecuteCommand(databaseURL, "up"); err != nil {
+		return err
+	}
+	manifest, err := backup.Restore(context.Background(), s.db, f)
+	if err != nil {
+		return fmt.Errorf("failed to restore backup: %w", err)
+	}
+	s.logger.Info("Backup restored",
+		zap.String("file", path),
+		zap.Time("createdAt", manifest.CreatedAt),
+		zap.Any("tables", manifest.Tables))
+	return nil
+}
+
 // ValidateConnection tests the database connection
 func (s *Service) ValidateConnection() error {
 	return s.db.Ping()
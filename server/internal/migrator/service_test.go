@@ -112,6 +112,23 @@ func TestService_Execute(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "rotate encryption key",
+			config: &config.Config{
+				DatabaseURL:    "sqlite::memory:",
+				MigrateCommand: "rotate-encryption-key",
+				JWTSecret:      "test-secret",
+			},
+			wantErr: false,
+		},
+		{
+			name: "rotate encryption key without JWT secret",
+			config: &config.Config{
+				DatabaseURL:    "sqlite::memory:",
+				MigrateCommand: "rotate-encryption-key",
+			},
+			wantErr: true,
+		},
 		{
 			name: "invalid command",
 			config: &config.Config{
@@ -0,0 +1,100 @@
+package mimetype
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/http"
+	"strings"
+)
+
+// Content types of executables
+const (
+	typePE     = "application/vnd.microsoft.portable-executable"
+	typeELF    = "application/x-executable"
+	typeMachO  = "application/x-mach-binary"
+	typeScript = "text/x-shellscript"
+)
+
+// machOMagics are the leading bytes of Mach-O executables and universal
+// binaries
+var machOMagics = [][]byte{
+	{0xfe, 0xed, 0xfa, 0xce}, {0xfe, 0xed, 0xfa, 0xcf},
+	{0xce, 0xfa, 0xed, 0xfe}, {0xcf, 0xfa, 0xed, 0xfe},
+	{0xca, 0xfe, 0xba, 0xbe},
+}
+
+// ftypBrands maps ISO base media brands to content types. Brands not
+// listed are taken for MP4 video.
+var ftypBrands = map[string]string{
+	"avif": "image/avif", "avis": "image/avif",
+	"heic": "image/heic", "heix": "image/heic", "heim": "image/heic", "heis": "image/heic",
+	"hevc": "image/heic", "hevx": "image/heic",
+	"mif1": "image/heif", "msf1": "image/heif",
+	"crx ": "image/x-canon-cr3",
+	"qt  ": "video/quicktime",
+	"3gp4": "video/3gpp", "3gp5": "video/3gpp", "3gp6": "video/3gpp",
+	"M4A ": "audio/mp4",
+}
+
+// Detect returns the content type of head, the first SniffLen bytes of a
+// file, from its magic bytes, or "" when not recognized. On top of the
+// types of http.DetectContentType, it recognizes executables, scripts,
+// TIFF, SVG and the HEIF, AVIF and CR3 formats built on MP4.
+func Detect(head []byte) string {
+	if len(head) > SniffLen {
+		head = head[:SniffLen]
+	}
+	if len(head) == 0 {
+		return ""
+	}
+	switch {
+	case bytes.HasPrefix(head, []byte("MZ")):
+		return typePE
+	case bytes.HasPrefix(head, []byte("\x7fELF")):
+		return typeELF
+	case bytes.HasPrefix(head, []byte("#!")):
+		return typeScript
+	case bytes.HasPrefix(head, []byte("II*\x00")), bytes.HasPrefix(head, []byte("MM\x00*")):
+		return "image/tiff"
+	}
+	for _, magic := range machOMagics {
+		if bytes.HasPrefix(head, magic) {
+			return typeMachO
+		}
+	}
+	if contentType := detectFtyp(head); contentType != "" {
+		return contentType
+	}
+	detected := http.DetectContentType(head)
+	switch {
+	case detected == "application/octet-stream":
+		return ""
+	case strings.HasPrefix(detected, "text/plain"), strings.HasPrefix(detected, "text/xml"):
+		if bytes.Contains(bytes.ToLower(head), []byte("<svg")) {
+			return "image/svg+xml"
+		}
+	}
+	return detected
+}
+
+// detectFtyp recognizes the ISO base media formats by the brands of their
+// leading ftyp box, the major brand first
+func detectFtyp(head []byte) string {
+	if len(head) < 12 || string(head[4:8]) != "ftyp" {
+		return ""
+	}
+	size := int(binary.BigEndian.Uint32(head[:4]))
+	if size < 12 || size > len(head) {
+		size = len(head)
+	}
+	if contentType, ok := ftypBrands[string(head[8:12])]; ok {
+		return contentType
+	}
+	// Compatible brands follow the minor version
+	for i := 16; i+4 <= size; i += 4 {
+		if contentType, ok := ftypBrands[string(head[i:i+4])]; ok && strings.HasPrefix(contentType, "image/") {
+			return contentType
+		}
+	}
+	return "video/mp4"
+}
@@ -0,0 +1,242 @@
+// Package mimetype detects the content type of uploads from their leading
+// bytes and verifies it against their extension, so files cannot pass for
+// a type they are not, such as an executable named like a photo. Known
+// extensions and the content types they may hold are registered by
+// extension, more can be built in with RegisterExtension.
+package mimetype
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SniffLen is the number of leading bytes Detect and Verify look at
+const SniffLen = 512
+
+var (
+	// ErrExecutable is returned for executables and scripts named with an
+	// extension of another type
+	ErrExecutable = errors.New("executable content")
+	// ErrMismatch is returned for content of another type than its
+	// extension registers
+	ErrMismatch = errors.New("content does not match extension")
+)
+
+// executableExtensions name executables, which may hold executable content
+var executableExtensions = map[string]bool{
+	"": true, ".exe": true, ".dll": true, ".com": true, ".scr": true, ".msi": true,
+	".bin": true, ".run": true, ".so": true, ".dylib": true, ".app": true,
+	".sh": true, ".bash": true, ".zsh": true, ".command": true, ".py": true,
+	".pl": true, ".rb": true, ".js": true, ".mjs": true, ".php": true,
+}
+
+var (
+	extensionsMu sync.RWMutex
+	// extensions maps lower case extensions to the content types their
+	// files hold, canonical type first. Further types are the ones content
+	// sniffs as, such as image/tiff for raw formats built on TIFF.
+	extensions = map[string][]string{
+		".jpg":  {"image/jpeg"},
+		".jpeg": {"image/jpeg"},
+		".png":  {"image/png"},
+		".gif":  {"image/gif"},
+		".webp": {"image/webp"},
+		".bmp":  {"image/bmp"},
+		".ico":  {"image/x-icon"},
+		".tif":  {"image/tiff"},
+		".tiff": {"image/tiff"},
+		".svg":  {"image/svg+xml", "text/xml", "text/plain"},
+		".heic": {"image/heic", "image/heif"},
+		".heif": {"image/heif", "image/heic"},
+		".avif": {"image/avif"},
+		".jxl":  {"image/jxl"},
+		".dng":  {"image/x-adobe-dng", "image/tiff"},
+		".cr2":  {"image/x-canon-cr2", "image/tiff"},
+		".cr3":  {"image/x-canon-cr3"},
+		".nef":  {"image/x-nikon-nef", "image/tiff"},
+		".arw":  {"image/x-sony-arw", "image/tiff"},
+		".raf":  {"image/x-fuji-raf"},
+		".orf":  {"image/x-olympus-orf"},
+		".rw2":  {"image/x-panasonic-rw2"},
+		".pef":  {"image/x-pentax-pef", "image/tiff"},
+		".mp4":  {"video/mp4"},
+		".m4v":  {"video/x-m4v", "video/mp4"},
+		".mov":  {"video/quicktime", "video/mp4"},
+		".3gp":  {"video/3gpp", "video/mp4"},
+		".webm": {"video/webm"},
+		".mkv":  {"video/x-matroska", "video/webm"},
+		".avi":  {"video/x-msvideo", "video/avi"},
+		".mpg":  {"video/mpeg"},
+		".mpeg": {"video/mpeg"},
+		".mts":  {"video/mp2t"},
+		".m2ts": {"video/mp2t"},
+		".mp3":  {"audio/mpeg"},
+		".m4a":  {"audio/mp4", "video/mp4"},
+		".wav":  {"audio/wav", "audio/wave"},
+		".flac": {"audio/flac"},
+		".ogg":  {"audio/ogg", "application/ogg"},
+		".pdf":  {"application/pdf"},
+		".zip":  {"application/zip"},
+		".txt":  {"text/plain"},
+		".md":   {"text/markdown", "text/plain"},
+		".csv":  {"text/csv", "text/plain"},
+		".json": {"application/json", "text/plain"},
+		".xmp":  {"application/rdf+xml", "text/xml", "text/plain"},
+	}
+)
+
+// RegisterExtension makes an extension known with the content types its
+// files hold, canonical type first. It panics if the extension is taken.
+func RegisterExtension(ext string, mimeTypes ...string) {
+	ext = normalizeExtension(ext)
+	if len(mimeTypes) == 0 {
+		panic("mimetype: no content types for extension: " + ext)
+	}
+	extensionsMu.Lock()
+	defer extensionsMu.Unlock()
+	if _, ok := extensions[ext]; ok {
+		panic("mimetype: extension already registered: " + ext)
+	}
+	extensions[ext] = mimeTypes
+}
+
+// Extensions returns the registered extensions
+func Extensions() []string {
+	extensionsMu.RLock()
+	defer extensionsMu.RUnlock()
+	names := make([]string, 0, len(extensions))
+	for ext := range extensions {
+		names = append(names, ext)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ByExtension returns the canonical content type of a file name by its
+// extension, "" when unknown
+func ByExtension(name string) string {
+	if types := registered(Extension(name)); len(types) > 0 {
+		return types[0]
+	}
+	mediaType, _, err := mime.ParseMediaType(mime.TypeByExtension(Extension(name)))
+	if err != nil {
+		return ""
+	}
+	return mediaType
+}
+
+// Extension returns the lower case extension of a file name, "" for none
+func Extension(name string) string {
+	return strings.ToLower(path.Ext(name))
+}
+
+// ParseExtensions parses a comma-separated list of extensions, with or
+// without the leading dot, into lower case extensions with the dot
+func ParseExtensions(list string) []string {
+	var exts []string
+	for _, ext := range strings.Split(list, ",") {
+		if ext = strings.TrimSpace(ext); ext != "" {
+			exts = append(exts, normalizeExtension(ext))
+		}
+	}
+	return exts
+}
+
+// Verify detects the content type of head, the first SniffLen bytes of a
+// file, and checks it against the extension of name. It returns the
+// canonical content type to serve the file with: the registered type of the
+// extension, or the detected type for media of another format than the
+// extension says, such as a PNG named .jpg. It fails with ErrExecutable
+// for executables named like other files, and with ErrMismatch for content
+// of another kind than the extension registers, such as HTML named .jpg.
+// Content not recognized, or of an extension not registered, is taken for
+// what its extension says, as is plain text.
+func Verify(name string, head []byte) (string, error) {
+	ext := Extension(name)
+	detected := Detect(head)
+	detectedType, _, _ := mime.ParseMediaType(detected)
+	if IsExecutable(detectedType) && !executableExtensions[ext] {
+		return "", fmt.Errorf("%w: %s named %s", ErrExecutable, detectedType, ext)
+	}
+	types := registered(ext)
+	if len(types) == 0 {
+		// Formats built on others, such as Office documents on ZIP, are
+		// served as their extension says
+		if byExt := ByExtension(name); byExt != "" {
+			return byExt, nil
+		}
+		if detected != "" {
+			return detected, nil
+		}
+		return "application/octet-stream", nil
+	}
+	switch {
+	case detected == "":
+		return types[0], nil
+	case detectedType == types[0]:
+		// Keeps parameters such as the charset of text
+		return detected, nil
+	case contains(types, detectedType):
+		return types[0], nil
+	case detectedType == "text/plain":
+		// Any content without binary bytes sniffs as text, which tells
+		// nothing of what it is
+		return types[0], nil
+	case isMedia(detectedType) && family(detectedType) == family(types[0]):
+		return detectedType, nil
+	}
+	return "", fmt.Errorf("%w: %s named %s", ErrMismatch, detectedType, ext)
+}
+
+// IsExecutable reports whether a content type detected by Detect is an
+// executable or a script
+func IsExecutable(mediaType string) bool {
+	switch mediaType {
+	case typePE, typeELF, typeMachO, typeScript:
+		return true
+	}
+	return false
+}
+
+func registered(ext string) []string {
+	extensionsMu.RLock()
+	defer extensionsMu.RUnlock()
+	return extensions[ext]
+}
+
+func normalizeExtension(ext string) string {
+	ext = strings.ToLower(strings.TrimSpace(ext))
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return ext
+}
+
+// isMedia reports whether a content type is an image, video or audio,
+// whose formats a file may be converted between keeping its extension
+func isMedia(mediaType string) bool {
+	switch family(mediaType) {
+	case "image", "video", "audio":
+		return true
+	}
+	return false
+}
+
+func family(mediaType string) string {
+	top, _, _ := strings.Cut(mediaType, "/")
+	return top
+}
+
+func contains(values []string, target string) bool {
+	for _, v := range values {
+		if v == target {
+			return true
+		}
+	}
+	return false
+}
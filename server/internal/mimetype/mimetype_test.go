@@ -0,0 +1,132 @@
+package mimetype
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	jpeg = []byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00")
+	png  = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	heic = []byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic")
+	pe   = []byte("MZ\x90\x00\x03\x00\x00\x00\x04\x00")
+	elf  = []byte("\x7fELF\x02\x01\x01\x00")
+)
+
+func TestDetect(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		head []byte
+		want string
+	}{
+		{"jpeg", jpeg, "image/jpeg"},
+		{"png", png, "image/png"},
+		{"heic", heic, "image/heic"},
+		{"heif compatible brand", []byte("\x00\x00\x00\x18ftypmp42\x00\x00\x00\x00mp42mif1"), "image/heif"},
+		{"avif", []byte("\x00\x00\x00\x1cftypavif\x00\x00\x00\x00avifmif1miaf"), "image/avif"},
+		{"cr3", []byte("\x00\x00\x00\x18ftypcrx \x00\x00\x00\x01crx isom"), "image/x-canon-cr3"},
+		{"quicktime", []byte("\x00\x00\x00\x14ftypqt  \x00\x00\x00\x00qt  "), "video/quicktime"},
+		{"mp4", []byte("\x00\x00\x00\x18ftypisom\x00\x00\x02\x00isomiso2"), "video/mp4"},
+		{"tiff little endian", []byte("II*\x00\x08\x00\x00\x00"), "image/tiff"},
+		{"tiff big endian", []byte("MM\x00*\x00\x00\x00\x08"), "image/tiff"},
+		{"svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`), "image/svg+xml"},
+		{"svg with xml declaration", []byte(`<?xml version="1.0"?><svg></svg>`), "image/svg+xml"},
+		{"pe", pe, typePE},
+		{"elf", elf, typeELF},
+		{"mach-o", []byte("\xcf\xfa\xed\xfe\x07\x00\x00\x01"), typeMachO},
+		{"script", []byte("#!/bin/sh\nrm -rf /\n"), typeScript},
+		{"html", []byte("<html><script>alert(1)</script></html>"), "text/html; charset=utf-8"},
+		{"text", []byte("hello world"), "text/plain; charset=utf-8"},
+		{"unknown binary", []byte("\x00\x01\x02\x03\x04"), ""},
+		{"empty", nil, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, Detect(tc.head))
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		fileName string
+		head     []byte
+		want     string
+	}{
+		{"matching", "photo.JPG", jpeg, "image/jpeg"},
+		{"other image format", "photo.jpg", png, "image/png"},
+		{"heic", "IMG_0001.HEIC", heic, "image/heic"},
+		{"raw built on tiff", "IMG_0001.CR2", []byte("II*\x00\x10\x00\x00\x00CR"), "image/x-canon-cr2"},
+		{"not recognized", "IMG_0001.flac", []byte("fLaC\x00\x00\x00\x22"), "audio/flac"},
+		{"empty", "photo.jpg", nil, "image/jpeg"},
+		{"plain text", "photo.jpg", []byte("hello"), "image/jpeg"},
+		{"text keeps charset", "notes.txt", []byte("hello"), "text/plain; charset=utf-8"},
+		{"sidecar", "photo.xmp", []byte(`<?xml version="1.0"?><x:xmpmeta/>`), "application/rdf+xml"},
+		{"svg", "logo.svg", []byte(`<svg></svg>`), "image/svg+xml"},
+		{"unregistered extension", "styles.css", []byte("body { color: red }"), "text/css"},
+		{"unregistered and unknown", "data.imagortest", []byte("\x00\x01\x02"), "application/octet-stream"},
+		// Served by the system MIME types, which vary
+		{"executable named as one", "setup.exe", pe, ""},
+		{"executable without extension", "tool", elf, ""},
+		{"script", "install.sh", []byte("#!/bin/sh\n"), ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Verify(tc.fileName, tc.head)
+			require.NoError(t, err)
+			if tc.want != "" {
+				assert.Equal(t, tc.want, got)
+			}
+		})
+	}
+}
+
+func TestVerify_Rejects(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		fileName string
+		head     []byte
+		want     error
+	}{
+		{"windows executable named jpg", "photo.jpg", pe, ErrExecutable},
+		{"linux executable named pdf", "invoice.pdf", elf, ErrExecutable},
+		{"script named txt", "notes.txt", []byte("#!/bin/bash\ncurl x | sh"), ErrExecutable},
+		{"executable with unregistered extension", "movie.avi.scr2", pe, ErrExecutable},
+		{"html named jpg", "photo.jpg", []byte("<html><script>alert(1)</script>"), ErrMismatch},
+		{"html named txt", "notes.txt", []byte("<html><script>alert(1)</script>"), ErrMismatch},
+		{"zip named png", "photo.png", []byte("PK\x03\x04\x14\x00"), ErrMismatch},
+		{"image named pdf", "invoice.pdf", png, ErrMismatch},
+		{"image named video", "clip.mp4", jpeg, ErrMismatch},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Verify(tc.fileName, tc.head)
+			assert.ErrorIs(t, err, tc.want)
+		})
+	}
+}
+
+func TestByExtension(t *testing.T) {
+	assert.Equal(t, "image/x-canon-cr3", ByExtension("a/IMG_0001.CR3"))
+	assert.Equal(t, "text/html", ByExtension("index.html"))
+	assert.Empty(t, ByExtension("data.imagortest"))
+	assert.Empty(t, ByExtension("README"))
+}
+
+func TestParseExtensions(t *testing.T) {
+	assert.Equal(t, []string{".jpg", ".png", ".mp4"}, ParseExtensions(" .JPG, png ,, .mp4"))
+	assert.Empty(t, ParseExtensions(""))
+}
+
+func TestRegisterExtension(t *testing.T) {
+	RegisterExtension("IMAGORTEST", "application/x-test")
+	t.Cleanup(func() {
+		extensionsMu.Lock()
+		delete(extensions, ".imagortest")
+		extensionsMu.Unlock()
+	})
+	assert.Contains(t, Extensions(), ".imagortest")
+	assert.Equal(t, "application/x-test", ByExtension("data.imagortest"))
+	assert.Panics(t, func() { RegisterExtension(".imagortest", "application/x-test") })
+	assert.Panics(t, func() { RegisterExtension(".abc") })
+}
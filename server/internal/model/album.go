@@ -0,0 +1,31 @@
+package model
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Album is a virtual collection of files, independent of folders
+type Album struct {
+	bun.BaseModel `bun:"table:albums,alias:alb"`
+
+	ID        string    `bun:"id,pk,type:text"`
+	Scope     string    `bun:"scope,notnull"`
+	Name      string    `bun:"name,notnull"`
+	CreatedBy string    `bun:"created_by,type:text"`
+	CreatedAt time.Time `bun:"created_at,notnull,default:current_timestamp"`
+	UpdatedAt time.Time `bun:"updated_at,notnull,default:current_timestamp"`
+}
+
+// AlbumItem is a file in an album, ordered by position
+type AlbumItem struct {
+	bun.BaseModel `bun:"table:album_items,alias:albi"`
+
+	ID       string    `bun:"id,pk,type:text"`
+	AlbumID  string    `bun:"album_id,notnull,type:text"`
+	Scope    string    `bun:"scope,notnull"`
+	FilePath string    `bun:"file_path,notnull"`
+	Position int       `bun:"position,notnull"`
+	AddedAt  time.Time `bun:"added_at,notnull,default:current_timestamp"`
+}
@@ -0,0 +1,26 @@
+package model
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// APIToken is a long-lived personal access token of a user. Only the hash
+// of the token is stored.
+type APIToken struct {
+	bun.BaseModel `bun:"table:api_tokens,alias:apt"`
+
+	ID        string `bun:"id,pk,type:text"`
+	UserID    string `bun:"user_id,notnull,type:text"`
+	OrgID     string `bun:"org_id,notnull,type:text"`
+	Name      string `bun:"name,notnull"`
+	TokenHash string `bun:"token_hash,notnull"`
+	// Prefix is the start of the token, shown to tell tokens apart
+	Prefix string `bun:"prefix,notnull"`
+	// Scopes is the comma separated list of granted scopes
+	Scopes     string     `bun:"scopes,notnull"`
+	ExpiresAt  *time.Time `bun:"expires_at"`
+	LastUsedAt *time.Time `bun:"last_used_at"`
+	CreatedAt  time.Time  `bun:"created_at,notnull,default:current_timestamp"`
+}
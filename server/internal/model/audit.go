@@ -0,0 +1,31 @@
+package model
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// AuditEntry records one executed GraphQL mutation
+type AuditEntry struct {
+	bun.BaseModel `bun:"table:audit_log,alias:aud"`
+
+	ID        string    `bun:"id,pk,type:text"`
+	CreatedAt time.Time `bun:"created_at,notnull,default:current_timestamp"`
+	UserID    string    `bun:"user_id,notnull,type:text"`
+	Role      string    `bun:"role,notnull"`
+	Operation string    `bun:"operation,notnull"`
+	SpaceID   string    `bun:"space_id,notnull"`
+	// Path is the file or folder operated on, the first one when several
+	Path string `bun:"path,notnull"`
+	// DestPath is the destination of moves, copies and exports
+	DestPath string `bun:"dest_path,notnull"`
+	// PathCount is the number of paths operated on
+	PathCount int `bun:"path_count,notnull"`
+	// Target identifies what other mutations operated on, such as a user,
+	// tag or album ID
+	Target   string `bun:"target,notnull"`
+	ClientIP string `bun:"client_ip,notnull"`
+	// Error is empty for mutations that succeeded
+	Error string `bun:"error,notnull"`
+}
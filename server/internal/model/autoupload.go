@@ -0,0 +1,26 @@
+package model
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// AutoUpload records a file a user backed up from a device, keyed by its
+// content hash so the same content is not backed up twice
+type AutoUpload struct {
+	bun.BaseModel `bun:"table:auto_uploads,alias:au"`
+
+	ID     string `bun:"id,pk,type:text"`
+	UserID string `bun:"user_id,notnull,type:text"`
+	// Scope is the file metadata scope of the storage backed up to
+	Scope string `bun:"scope,notnull"`
+	// Hash is the SHA-256 of the content, hex encoded
+	Hash     string `bun:"hash,notnull"`
+	Path     string `bun:"path,notnull"`
+	DeviceID string `bun:"device_id,notnull"`
+	// Filename is the name of the file on the device
+	Filename   string    `bun:"filename,notnull"`
+	CapturedAt time.Time `bun:"captured_at,notnull"`
+	CreatedAt  time.Time `bun:"created_at,notnull,default:current_timestamp"`
+}
@@ -0,0 +1,22 @@
+package model
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Comment is a note left on a file
+type Comment struct {
+	bun.BaseModel `bun:"table:comments,alias:cmt"`
+
+	ID       string `bun:"id,pk,type:text"`
+	Scope    string `bun:"scope,notnull"`
+	FilePath string `bun:"file_path,notnull"`
+	AuthorID string `bun:"author_id,notnull,type:text"`
+	// AuthorName is the display name of the author when commenting
+	AuthorName string     `bun:"author_name,notnull"`
+	Text       string     `bun:"text,notnull"`
+	CreatedAt  time.Time  `bun:"created_at,notnull,default:current_timestamp"`
+	EditedAt   *time.Time `bun:"edited_at"`
+}
@@ -0,0 +1,55 @@
+package model
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Face is a face detected in an image. The box is relative to the image
+// size, 0 to 1 from the top left corner. Embedding is the base64 encoded
+// little endian float32 vector the detector returned, normalized to unit
+// length, compared to match faces of the same person.
+type Face struct {
+	bun.BaseModel `bun:"table:faces,alias:fc"`
+
+	ID         string    `bun:"id,pk,type:text"`
+	Scope      string    `bun:"scope,notnull"`
+	FilePath   string    `bun:"file_path,notnull"`
+	PersonID   string    `bun:"person_id,notnull,type:text"`
+	BoxX       float64   `bun:"box_x,notnull"`
+	BoxY       float64   `bun:"box_y,notnull"`
+	BoxWidth   float64   `bun:"box_width,notnull"`
+	BoxHeight  float64   `bun:"box_height,notnull"`
+	Confidence float64   `bun:"confidence,notnull"`
+	Embedding  string    `bun:"embedding,notnull"`
+	DetectedAt time.Time `bun:"detected_at,notnull"`
+}
+
+// Person is a cluster of faces of one person. Centroid is the mean of the
+// face embeddings, encoded as in Face, new faces join the person with the
+// nearest centroid. Name is empty until a user names the person.
+type Person struct {
+	bun.BaseModel `bun:"table:people,alias:ppl"`
+
+	ID        string    `bun:"id,pk,type:text"`
+	Scope     string    `bun:"scope,notnull"`
+	Name      string    `bun:"name,nullzero"`
+	Centroid  string    `bun:"centroid,notnull"`
+	FaceCount int       `bun:"face_count,notnull"`
+	CreatedAt time.Time `bun:"created_at,notnull,default:current_timestamp"`
+	UpdatedAt time.Time `bun:"updated_at,notnull,default:current_timestamp"`
+}
+
+// FaceScan records an image scanned for faces, including images without
+// any, so unchanged images are not scanned again. Fingerprint identifies
+// the file version that was scanned.
+type FaceScan struct {
+	bun.BaseModel `bun:"table:face_scans,alias:fcs"`
+
+	ID          string    `bun:"id,pk,type:text"`
+	Scope       string    `bun:"scope,notnull"`
+	FilePath    string    `bun:"file_path,notnull"`
+	Fingerprint string    `bun:"fingerprint,notnull"`
+	ScannedAt   time.Time `bun:"scanned_at,notnull"`
+}
@@ -0,0 +1,18 @@
+package model
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Favorite is a file or folder starred by a user
+type Favorite struct {
+	bun.BaseModel `bun:"table:favorites,alias:fav"`
+
+	ID        string    `bun:"id,pk,type:text"`
+	UserID    string    `bun:"user_id,notnull,type:text"`
+	Scope     string    `bun:"scope,notnull"`
+	FilePath  string    `bun:"file_path,notnull"`
+	CreatedAt time.Time `bun:"created_at,notnull,default:current_timestamp"`
+}
@@ -0,0 +1,25 @@
+package model
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// FileHash records the SHA-256 content hash of a file for duplicate
+// detection, and for images the perceptual hash for similar image search.
+// Fingerprint identifies the file version that was hashed, so a modified
+// file is hashed again.
+type FileHash struct {
+	bun.BaseModel `bun:"table:file_hashes,alias:fh"`
+
+	ID          string `bun:"id,pk,type:text"`
+	Scope       string `bun:"scope,notnull"`
+	FilePath    string `bun:"file_path,notnull"`
+	Size        int64  `bun:"size,notnull"`
+	Fingerprint string `bun:"fingerprint,notnull"`
+	Hash        string `bun:"hash,notnull"`
+	// PerceptualHash is the hex encoded dHash, empty for other files
+	PerceptualHash string    `bun:"perceptual_hash,nullzero"`
+	HashedAt       time.Time `bun:"hashed_at,notnull"`
+}
@@ -0,0 +1,32 @@
+package model
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// FileJournalEntry records a change of the default storage, numbered in the
+// order changes are recorded so sync clients can ask for what follows one
+type FileJournalEntry struct {
+	bun.BaseModel `bun:"table:file_journal,alias:fj"`
+
+	Seq int64 `bun:"seq,pk,autoincrement"`
+	// Kind is the events.Kind of the change
+	Kind string `bun:"kind,notnull"`
+	Path string `bun:"path,notnull"`
+	// OldPath is where a moved file or folder was before
+	OldPath   string    `bun:"old_path,notnull"`
+	ChangedAt time.Time `bun:"changed_at,notnull,default:current_timestamp"`
+}
+
+// FileJournalSnapshot is a file of the default storage as last seen by a
+// library scan, which records the differences the next scan finds. The row
+// of the empty path marks when the snapshot was taken.
+type FileJournalSnapshot struct {
+	bun.BaseModel `bun:"table:file_journal_snapshot,alias:fjs"`
+
+	Path        string    `bun:"path,pk,type:text"`
+	Fingerprint string    `bun:"fingerprint,notnull"`
+	ScannedAt   time.Time `bun:"scanned_at,notnull"`
+}
@@ -0,0 +1,32 @@
+package model
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// FileMetadata caches the extracted metadata of a file. Fingerprint
+// identifies the file version it was read from, so a modified file is
+// extracted again.
+type FileMetadata struct {
+	bun.BaseModel `bun:"table:file_metadata,alias:fm"`
+
+	ID          string `bun:"id,pk,type:text"`
+	Scope       string `bun:"scope,notnull"`
+	FilePath    string `bun:"file_path,notnull"`
+	Fingerprint string `bun:"fingerprint,notnull"`
+	Data        string `bun:"data,notnull"`
+	// CapturedAt is the local capture time, e.g. 2024-05-01T12:34:56, nil
+	// when the file records none. Indexed for the timeline.
+	CapturedAt *string `bun:"captured_at"`
+	// Latitude and Longitude are the GPS position in decimal degrees, nil
+	// when the file records none. Indexed for the map.
+	Latitude  *float64 `bun:"latitude"`
+	Longitude *float64 `bun:"longitude"`
+	// ContentType is the content type detected when the file was uploaded,
+	// empty when it was not. Rows with only a content type have empty Data,
+	// their metadata not read yet.
+	ContentType string    `bun:"content_type,notnull"`
+	CreatedAt   time.Time `bun:"created_at,notnull,default:current_timestamp"`
+}
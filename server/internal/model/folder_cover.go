@@ -0,0 +1,19 @@
+package model
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// FolderCover is the image chosen to represent a folder in folder grids
+type FolderCover struct {
+	bun.BaseModel `bun:"table:folder_covers,alias:fc"`
+
+	ID         string    `bun:"id,pk,type:text"`
+	Scope      string    `bun:"scope,notnull"`
+	FolderPath string    `bun:"folder_path,notnull"`
+	FilePath   string    `bun:"file_path,notnull"`
+	UpdatedBy  string    `bun:"updated_by,notnull"`
+	UpdatedAt  time.Time `bun:"updated_at,notnull,default:current_timestamp"`
+}
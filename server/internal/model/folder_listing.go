@@ -0,0 +1,20 @@
+package model
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// FolderListing caches the full listing of a storage folder. Items is the
+// JSON encoded listing and ETag its fingerprint.
+type FolderListing struct {
+	bun.BaseModel `bun:"table:folder_listings,alias:fl"`
+
+	ID         string    `bun:"id,pk,type:text"`
+	Scope      string    `bun:"scope,notnull"`
+	FolderPath string    `bun:"folder_path,notnull"`
+	ETag       string    `bun:"etag,notnull"`
+	Items      string    `bun:"items,notnull"`
+	CachedAt   time.Time `bun:"cached_at,notnull"`
+}
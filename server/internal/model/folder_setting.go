@@ -0,0 +1,26 @@
+package model
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// FolderSetting holds the settings of a folder. ParentPath is the folder
+// containing it, for looking up the hidden folders of a listing; Pinned is
+// the JSON encoded list of child names listed first.
+type FolderSetting struct {
+	bun.BaseModel `bun:"table:folder_settings,alias:fs"`
+
+	ID          string    `bun:"id,pk,type:text"`
+	Scope       string    `bun:"scope,notnull"`
+	FolderPath  string    `bun:"folder_path,notnull"`
+	ParentPath  string    `bun:"parent_path,notnull"`
+	Description string    `bun:"description,notnull"`
+	SortBy      string    `bun:"sort_by,notnull"`
+	SortOrder   string    `bun:"sort_order,notnull"`
+	Pinned      string    `bun:"pinned,notnull"`
+	Hidden      bool      `bun:"hidden,notnull"`
+	UpdatedBy   string    `bun:"updated_by,notnull"`
+	UpdatedAt   time.Time `bun:"updated_at,notnull,default:current_timestamp"`
+}
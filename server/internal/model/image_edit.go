@@ -0,0 +1,20 @@
+package model
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// ImageEdit is the edit descriptor saved for an image. Data is the JSON
+// encoded imageedit.Edit, the original file is left untouched.
+type ImageEdit struct {
+	bun.BaseModel `bun:"table:image_edits,alias:ie"`
+
+	ID        string    `bun:"id,pk,type:text"`
+	Scope     string    `bun:"scope,notnull"`
+	FilePath  string    `bun:"file_path,notnull"`
+	Data      string    `bun:"data,notnull"`
+	UpdatedBy string    `bun:"updated_by,notnull"`
+	UpdatedAt time.Time `bun:"updated_at,notnull,default:current_timestamp"`
+}
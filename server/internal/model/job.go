@@ -0,0 +1,28 @@
+package model
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Job is the persisted snapshot of a long-running operation. HeartbeatAt is
+// refreshed by the replica running it, so abandoned jobs can be failed.
+type Job struct {
+	bun.BaseModel `bun:"table:jobs,alias:j"`
+
+	ID          string     `bun:"id,pk,type:text"`
+	Kind        string     `bun:"kind,notnull"`
+	OwnerID     string     `bun:"owner_id,notnull"`
+	Status      string     `bun:"status,notnull"`
+	Completed   int        `bun:"completed,notnull,default:0"`
+	Total       int        `bun:"total,notnull,default:0"`
+	Message     string     `bun:"message,notnull,default:''"`
+	Error       string     `bun:"error,notnull,default:''"`
+	Results     string     `bun:"results,notnull,default:'[]'"`
+	CreatedAt   time.Time  `bun:"created_at,notnull"`
+	UpdatedAt   time.Time  `bun:"updated_at,notnull"`
+	StartedAt   *time.Time `bun:"started_at"`
+	FinishedAt  *time.Time `bun:"finished_at"`
+	HeartbeatAt time.Time  `bun:"heartbeat_at,notnull"`
+}
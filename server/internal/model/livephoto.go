@@ -0,0 +1,20 @@
+package model
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// LivePhoto links the still image of a Live Photo to its motion video, both
+// in the same folder. Recorded when a listing pairs them, so listings that
+// only see one page of the folder still show the pair as one item.
+type LivePhoto struct {
+	bun.BaseModel `bun:"table:live_photos,alias:lp"`
+
+	ID        string    `bun:"id,pk,type:text"`
+	Scope     string    `bun:"scope,notnull"`
+	ImagePath string    `bun:"image_path,notnull"`
+	VideoPath string    `bun:"video_path,notnull"`
+	CreatedAt time.Time `bun:"created_at,notnull,default:current_timestamp"`
+}
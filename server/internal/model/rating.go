@@ -0,0 +1,19 @@
+package model
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Rating is a user's 1 to 5 star rating of a file
+type Rating struct {
+	bun.BaseModel `bun:"table:ratings,alias:rat"`
+
+	ID       string    `bun:"id,pk,type:text"`
+	UserID   string    `bun:"user_id,notnull,type:text"`
+	Scope    string    `bun:"scope,notnull"`
+	FilePath string    `bun:"file_path,notnull"`
+	Rating   int       `bun:"rating,notnull"`
+	RatedAt  time.Time `bun:"rated_at,notnull,default:current_timestamp"`
+}
@@ -0,0 +1,24 @@
+package model
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// S3AccessKey is an access key of a user signing requests to the S3
+// gateway. The secret is stored as is, as verifying a signature needs it.
+type S3AccessKey struct {
+	bun.BaseModel `bun:"table:s3_access_keys,alias:s3k"`
+
+	// ID is the access key ID, sent in the clear by clients
+	ID     string `bun:"id,pk,type:text"`
+	UserID string `bun:"user_id,notnull,type:text"`
+	OrgID  string `bun:"org_id,notnull,type:text"`
+	Name   string `bun:"name,notnull"`
+	Secret string `bun:"secret,notnull"`
+	// Scopes is the comma separated list of granted scopes
+	Scopes     string     `bun:"scopes,notnull"`
+	LastUsedAt *time.Time `bun:"last_used_at"`
+	CreatedAt  time.Time  `bun:"created_at,notnull,default:current_timestamp"`
+}
@@ -0,0 +1,26 @@
+package model
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Session is a login session of a user, kept alive by refresh tokens. Only
+// the hash of the current and the previous refresh token is stored.
+type Session struct {
+	bun.BaseModel `bun:"table:sessions,alias:ses"`
+
+	ID        string `bun:"id,pk,type:text"`
+	UserID    string `bun:"user_id,notnull,type:text"`
+	OrgID     string `bun:"org_id,notnull,type:text"`
+	TokenHash string `bun:"token_hash,notnull"`
+	// PreviousHash is the hash of the refresh token rotated last, presenting
+	// it again revokes the session
+	PreviousHash string    `bun:"previous_hash,notnull"`
+	UserAgent    string    `bun:"user_agent,notnull"`
+	ClientIP     string    `bun:"client_ip,notnull"`
+	CreatedAt    time.Time `bun:"created_at,notnull,default:current_timestamp"`
+	LastUsedAt   time.Time `bun:"last_used_at,notnull,default:current_timestamp"`
+	ExpiresAt    time.Time `bun:"expires_at,notnull"`
+}
@@ -0,0 +1,22 @@
+package model
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// ShareLink grants unauthenticated read-only access to a file or folder
+// until it expires
+type ShareLink struct {
+	bun.BaseModel `bun:"table:share_links,alias:sl"`
+
+	ID            string    `bun:"id,pk,type:text"`
+	Token         string    `bun:"token,notnull"`
+	Path          string    `bun:"path,notnull"`
+	CreatedBy     string    `bun:"created_by,notnull"`
+	AllowDownload bool      `bun:"allow_download,notnull,default:false"`
+	PasswordHash  *string   `bun:"password_hash,type:text"`
+	ExpiresAt     time.Time `bun:"expires_at,notnull"`
+	CreatedAt     time.Time `bun:"created_at,notnull,default:current_timestamp"`
+}
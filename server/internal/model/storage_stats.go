@@ -0,0 +1,50 @@
+package model
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// StorageStat is the usage of a folder and everything below it as of the
+// latest library scan. Extensions is the JSON encoded usage per lower case
+// file extension.
+type StorageStat struct {
+	bun.BaseModel `bun:"table:storage_stats,alias:sst"`
+
+	ID         string    `bun:"id,pk,type:text"`
+	Scope      string    `bun:"scope,notnull"`
+	FolderPath string    `bun:"folder_path,notnull"`
+	FileCount  int64     `bun:"file_count,notnull"`
+	TotalSize  int64     `bun:"total_size,notnull"`
+	Extensions string    `bun:"extensions,notnull"`
+	ScannedAt  time.Time `bun:"scanned_at,notnull"`
+}
+
+// StorageUpload is a file uploaded through the server. It counts toward
+// the quota of its uploader, and toward the quotas of its folders until the
+// next library scan counts it.
+type StorageUpload struct {
+	bun.BaseModel `bun:"table:storage_uploads,alias:sup"`
+
+	ID         string    `bun:"id,pk,type:text"`
+	Scope      string    `bun:"scope,notnull"`
+	FilePath   string    `bun:"file_path,notnull"`
+	UserID     string    `bun:"user_id,notnull"`
+	Size       int64     `bun:"size,notnull"`
+	UploadedAt time.Time `bun:"uploaded_at,notnull"`
+}
+
+// StorageQuota bounds the bytes uploaded by a user, or stored below a
+// folder. Kind is user or path, Target the user ID or folder path.
+type StorageQuota struct {
+	bun.BaseModel `bun:"table:storage_quotas,alias:sqt"`
+
+	ID         string    `bun:"id,pk,type:text"`
+	Scope      string    `bun:"scope,notnull"`
+	Kind       string    `bun:"kind,notnull"`
+	Target     string    `bun:"target,notnull"`
+	LimitBytes int64     `bun:"limit_bytes,notnull"`
+	UpdatedBy  string    `bun:"updated_by,notnull"`
+	UpdatedAt  time.Time `bun:"updated_at,notnull,default:current_timestamp"`
+}
@@ -0,0 +1,46 @@
+package model
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Tag is a node in a scope's tag hierarchy. Path is the full slash separated
+// name, e.g. "Animals/Dogs/Beagle"; PathKey is its case-folded form used for
+// lookups and descendant matching.
+type Tag struct {
+	bun.BaseModel `bun:"table:tags,alias:t"`
+
+	ID        string    `bun:"id,pk,type:text"`
+	Scope     string    `bun:"scope,notnull"`
+	ParentID  *string   `bun:"parent_id,type:text"`
+	Name      string    `bun:"name,notnull"`
+	Path      string    `bun:"path,notnull"`
+	PathKey   string    `bun:"path_key,notnull"`
+	CreatedAt time.Time `bun:"created_at,notnull,default:current_timestamp"`
+	UpdatedAt time.Time `bun:"updated_at,notnull,default:current_timestamp"`
+}
+
+// TagAlias is an alternative name resolving to a tag
+type TagAlias struct {
+	bun.BaseModel `bun:"table:tag_aliases,alias:ta"`
+
+	ID        string    `bun:"id,pk,type:text"`
+	Scope     string    `bun:"scope,notnull"`
+	TagID     string    `bun:"tag_id,notnull,type:text"`
+	Alias     string    `bun:"alias,notnull"`
+	AliasKey  string    `bun:"alias_key,notnull"`
+	CreatedAt time.Time `bun:"created_at,notnull,default:current_timestamp"`
+}
+
+// FileTag associates a file path with a tag
+type FileTag struct {
+	bun.BaseModel `bun:"table:file_tags,alias:ft"`
+
+	ID        string    `bun:"id,pk,type:text"`
+	Scope     string    `bun:"scope,notnull"`
+	FilePath  string    `bun:"file_path,notnull"`
+	TagID     string    `bun:"tag_id,notnull,type:text"`
+	CreatedAt time.Time `bun:"created_at,notnull,default:current_timestamp"`
+}
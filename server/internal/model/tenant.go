@@ -0,0 +1,19 @@
+package model
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Tenant is an isolated library of a multi-tenant deployment, with its own
+// storage root, users and settings
+type Tenant struct {
+	bun.BaseModel `bun:"table:tenants,alias:tnt"`
+
+	ID          string    `bun:"id,pk,type:text"`
+	Name        string    `bun:"name,notnull,unique"`
+	StorageRoot string    `bun:"storage_root,notnull,unique"`
+	CreatedAt   time.Time `bun:"created_at,notnull,default:current_timestamp"`
+	UpdatedAt   time.Time `bun:"updated_at,notnull,default:current_timestamp"`
+}
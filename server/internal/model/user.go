@@ -19,6 +19,8 @@ type User struct {
 	PendingEmail   *string   `bun:"pending_email,type:text"`
 	EmailVerified  bool      `bun:"email_verified,notnull,default:false"`
 	AvatarUrl      *string   `bun:"avatar_url,type:text"`
+	HomePath       *string   `bun:"home_path,type:text"`
+	TenantID       *string   `bun:"tenant_id,type:text"`
 	CreatedAt      time.Time `bun:"created_at,notnull,default:current_timestamp"`
 	UpdatedAt      time.Time `bun:"updated_at,notnull,default:current_timestamp"`
 }
@@ -0,0 +1,38 @@
+package model
+
+import (
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// Webhook is a URL receiving signed event notifications
+type Webhook struct {
+	bun.BaseModel `bun:"table:webhooks,alias:wh"`
+
+	ID     string `bun:"id,pk,type:text"`
+	URL    string `bun:"url,notnull"`
+	Secret string `bun:"secret,notnull"`
+	// Events is the comma separated event names delivered to the webhook
+	Events    string    `bun:"events,notnull"`
+	Active    bool      `bun:"active,notnull,default:true"`
+	CreatedBy string    `bun:"created_by,notnull,type:text"`
+	CreatedAt time.Time `bun:"created_at,notnull,default:current_timestamp"`
+	UpdatedAt time.Time `bun:"updated_at,notnull,default:current_timestamp"`
+}
+
+// WebhookDelivery is one event posted, or being posted, to a webhook
+type WebhookDelivery struct {
+	bun.BaseModel `bun:"table:webhook_deliveries,alias:whd"`
+
+	ID             string    `bun:"id,pk,type:text"`
+	WebhookID      string    `bun:"webhook_id,notnull,type:text"`
+	Event          string    `bun:"event,notnull"`
+	Payload        string    `bun:"payload,notnull"`
+	Status         string    `bun:"status,notnull"`
+	Attempts       int       `bun:"attempts,notnull"`
+	ResponseStatus int       `bun:"response_status,notnull"`
+	Error          string    `bun:"error,notnull"`
+	CreatedAt      time.Time `bun:"created_at,notnull,default:current_timestamp"`
+	UpdatedAt      time.Time `bun:"updated_at,notnull,default:current_timestamp"`
+}
@@ -0,0 +1,85 @@
+package netacl
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/auditlog"
+	"github.com/cshum/imagor-studio/server/pkg/apperror"
+	"go.uber.org/zap"
+)
+
+// AuditOperation is the operation of audit entries recording refused clients
+const AuditOperation = "networkAccessDenied"
+
+// auditInterval is how often the refusals of one client on one route group
+// are recorded, so a client retrying in a loop cannot flood the audit log
+const auditInterval = time.Minute
+
+// Middleware refuses clients outside the ACL of the route group of each
+// request with 403 Forbidden. Refusals are recorded in auditLog when set.
+func (s *Store) Middleware(auditLog auditlog.Store) func(http.Handler) http.Handler {
+	var (
+		mu      sync.Mutex
+		audited = make(map[string]time.Time)
+	)
+	record := func(r *http.Request, group, ip string) {
+		s.logger.Debug("Network ACL refused client",
+			zap.String("group", group),
+			zap.String("clientIP", ip),
+			zap.String("path", r.URL.Path))
+		if auditLog == nil {
+			return
+		}
+		now := time.Now()
+		mu.Lock()
+		for key, at := range audited {
+			if now.Sub(at) >= auditInterval {
+				delete(audited, key)
+			}
+		}
+		key := group + "|" + ip
+		_, seen := audited[key]
+		if !seen {
+			audited[key] = now
+		}
+		mu.Unlock()
+		if seen {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), 5*time.Second)
+		defer cancel()
+		if err := auditLog.Record(ctx, &auditlog.Entry{
+			Operation: AuditOperation,
+			Path:      r.URL.Path,
+			Target:    group,
+			ClientIP:  ip,
+			Error:     "client address refused by the network ACL of " + group,
+		}); err != nil {
+			s.logger.Warn("Failed to record audit entry", zap.String("operation", AuditOperation), zap.Error(err))
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			group := GroupOf(r.URL.Path)
+			if group == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ip := r.RemoteAddr
+			if host, _, err := net.SplitHostPort(ip); err == nil {
+				ip = host
+			}
+			if !s.Allowed(group, ip) {
+				record(r, group, ip)
+				apperror.WriteHTTPErrorResponse(w, apperror.Forbidden("Access from this network is not allowed"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,259 @@
+// Package netacl restricts the client networks that may reach each group of
+// routes of the server.
+//
+// An ACL is a list of allowed and a list of denied networks, stored per
+// route group in the system registry so every replica enforces the same
+// lists. A client is refused when its address is in a denied network, or
+// when the allowed networks are set and none contains it. A group without
+// an ACL is not restricted. Client addresses are the remote address of the
+// request, resolved from forwarded headers for trusted proxies only.
+package netacl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"go.uber.org/zap"
+)
+
+// KeyPrefix prefixes the registry key of each route group's ACL
+const KeyPrefix = "config.network_acl_"
+
+// Route groups
+const (
+	// GroupAuth is login, registration and token refresh under /api/auth/
+	GroupAuth = "auth"
+	// GroupGraphQL is the GraphQL API at /api/query
+	GroupGraphQL = "graphql"
+	// GroupImagor is image processing under /imagor/
+	GroupImagor = "imagor"
+	// GroupShares is the redemption of shared links at /api/auth/share
+	GroupShares = "shares"
+)
+
+// Groups lists the route groups that can be restricted
+var Groups = []string{GroupAuth, GroupGraphQL, GroupImagor, GroupShares}
+
+var (
+	// ErrUnknownGroup is returned for groups that cannot be restricted
+	ErrUnknownGroup = errors.New("network ACLs can only be set for the auth, graphql, imagor and shares route groups")
+	// ErrInvalidNetwork is returned for entries that are neither an IP
+	// address nor a CIDR network
+	ErrInvalidNetwork = errors.New("invalid network")
+)
+
+// Key returns the registry key holding the ACL of group
+func Key(group string) string {
+	return KeyPrefix + group
+}
+
+// IsGroup reports whether group can have an ACL
+func IsGroup(group string) bool {
+	return slices.Contains(Groups, group)
+}
+
+// GroupOf returns the route group of a request path, empty for routes that
+// cannot be restricted
+func GroupOf(path string) string {
+	switch {
+	case path == "/api/auth/share":
+		return GroupShares
+	case strings.HasPrefix(path, "/api/auth/"), strings.HasPrefix(path, "/api/embed/"):
+		return GroupAuth
+	case path == "/api/query":
+		return GroupGraphQL
+	case strings.HasPrefix(path, "/imagor/"):
+		return GroupImagor
+	}
+	return ""
+}
+
+// ACL is the allowed and denied networks of a route group
+type ACL struct {
+	Allow []netip.Prefix
+	Deny  []netip.Prefix
+}
+
+// Allowed reports whether the ACL lets addr through
+func (a *ACL) Allowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if slices.ContainsFunc(a.Deny, func(p netip.Prefix) bool { return p.Contains(addr) }) {
+		return false
+	}
+	return len(a.Allow) == 0 || slices.ContainsFunc(a.Allow, func(p netip.Prefix) bool { return p.Contains(addr) })
+}
+
+// storedACL is the registry value of an ACL
+type storedACL struct {
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
+}
+
+// ParseNetworks parses IP addresses and CIDR networks, an address is a
+// network of itself
+func ParseNetworks(networks []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(networks))
+	for _, network := range networks {
+		network = strings.TrimSpace(network)
+		if network == "" {
+			continue
+		}
+		var prefix netip.Prefix
+		if strings.Contains(network, "/") {
+			p, err := netip.ParsePrefix(network)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %q", ErrInvalidNetwork, network)
+			}
+			prefix = p.Masked()
+		} else {
+			addr, err := netip.ParseAddr(network)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %q", ErrInvalidNetwork, network)
+			}
+			addr = addr.Unmap()
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		if !slices.Contains(prefixes, prefix) {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	return prefixes, nil
+}
+
+// Store caches the ACLs of the system registry
+type Store struct {
+	registryStore registrystore.Store
+	logger        *zap.Logger
+
+	mu   sync.RWMutex
+	acls map[string]*ACL
+}
+
+// New creates an ACL store, call Sync to load the stored ACLs
+func New(registryStore registrystore.Store, logger *zap.Logger) *Store {
+	return &Store{
+		registryStore: registryStore,
+		logger:        logger,
+		acls:          make(map[string]*ACL),
+	}
+}
+
+// Sync reloads the ACLs from the registry, so changes made on other
+// replicas or directly in the registry take effect
+func (s *Store) Sync() error {
+	return s.Load(context.Background())
+}
+
+// Load reads the ACLs of all route groups from the registry
+func (s *Store) Load(ctx context.Context) error {
+	keys := make([]string, 0, len(Groups))
+	for _, group := range Groups {
+		keys = append(keys, Key(group))
+	}
+	entries, err := s.registryStore.GetMulti(ctx, registrystore.SystemOwnerID, keys)
+	if err != nil {
+		return fmt.Errorf("failed to load network ACLs: %w", err)
+	}
+	acls := make(map[string]*ACL)
+	for _, entry := range entries {
+		if entry == nil || !strings.HasPrefix(entry.Key, KeyPrefix) {
+			continue
+		}
+		acl, err := parseStoredACL(entry.Value)
+		if err != nil {
+			s.logger.Warn("Ignoring malformed network ACL", zap.String("key", entry.Key), zap.Error(err))
+			continue
+		}
+		acls[strings.TrimPrefix(entry.Key, KeyPrefix)] = acl
+	}
+	s.mu.Lock()
+	s.acls = acls
+	s.mu.Unlock()
+	return nil
+}
+
+func parseStoredACL(value string) (*ACL, error) {
+	var stored storedACL
+	if err := json.Unmarshal([]byte(value), &stored); err != nil {
+		return nil, err
+	}
+	allow, err := ParseNetworks(stored.Allow)
+	if err != nil {
+		return nil, err
+	}
+	deny, err := ParseNetworks(stored.Deny)
+	if err != nil {
+		return nil, err
+	}
+	return &ACL{Allow: allow, Deny: deny}, nil
+}
+
+// Get returns the ACL of group, reporting whether group is restricted
+func (s *Store) Get(group string) (*ACL, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	acl, ok := s.acls[group]
+	return acl, ok
+}
+
+// Set restricts group to the allowed networks, less the denied ones. An
+// ACL without networks lets every client through.
+func (s *Store) Set(ctx context.Context, group string, acl *ACL) error {
+	if !IsGroup(group) {
+		return ErrUnknownGroup
+	}
+	stored := storedACL{Allow: make([]string, 0, len(acl.Allow)), Deny: make([]string, 0, len(acl.Deny))}
+	for _, p := range acl.Allow {
+		stored.Allow = append(stored.Allow, p.String())
+	}
+	for _, p := range acl.Deny {
+		stored.Deny = append(stored.Deny, p.String())
+	}
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+	if _, err := s.registryStore.Set(ctx, registrystore.SystemOwnerID, Key(group), string(data), false); err != nil {
+		return fmt.Errorf("failed to save network ACL: %w", err)
+	}
+	s.mu.Lock()
+	s.acls[group] = acl
+	s.mu.Unlock()
+	return nil
+}
+
+// Clear lifts the restriction of group
+func (s *Store) Clear(ctx context.Context, group string) error {
+	if !IsGroup(group) {
+		return ErrUnknownGroup
+	}
+	// DeleteMulti is idempotent, clearing an unrestricted group is not an error
+	if err := s.registryStore.DeleteMulti(ctx, registrystore.SystemOwnerID, []string{Key(group)}); err != nil {
+		return fmt.Errorf("failed to clear network ACL: %w", err)
+	}
+	s.mu.Lock()
+	delete(s.acls, group)
+	s.mu.Unlock()
+	return nil
+}
+
+// Allowed reports whether a client at addr may reach the routes of group.
+// Unparsable addresses are only refused by restricted groups.
+func (s *Store) Allowed(group, addr string) bool {
+	acl, ok := s.Get(group)
+	if !ok {
+		return true
+	}
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return false
+	}
+	return acl.Allowed(ip)
+}
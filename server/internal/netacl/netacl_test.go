@@ -0,0 +1,205 @@
+package netacl
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/auditlog"
+	"github.com/cshum/imagor-studio/server/internal/pagination"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// mockRegistryStore is an in-memory registry store for a single owner
+type mockRegistryStore struct {
+	data map[string]string
+}
+
+func newMockRegistryStore() *mockRegistryStore {
+	return &mockRegistryStore{data: make(map[string]string)}
+}
+
+func (m *mockRegistryStore) List(ctx context.Context, ownerID string, prefix *string) ([]*registrystore.Registry, error) {
+	return nil, nil
+}
+
+func (m *mockRegistryStore) Get(ctx context.Context, ownerID, key string) (*registrystore.Registry, error) {
+	if value, ok := m.data[key]; ok {
+		return &registrystore.Registry{Key: key, Value: value}, nil
+	}
+	return nil, nil
+}
+
+func (m *mockRegistryStore) GetMulti(ctx context.Context, ownerID string, keys []string) ([]*registrystore.Registry, error) {
+	var result []*registrystore.Registry
+	for _, key := range keys {
+		if value, ok := m.data[key]; ok {
+			result = append(result, &registrystore.Registry{Key: key, Value: value})
+		}
+	}
+	return result, nil
+}
+
+func (m *mockRegistryStore) Set(ctx context.Context, ownerID, key, value string, isEncrypted bool) (*registrystore.Registry, error) {
+	m.data[key] = value
+	return &registrystore.Registry{Key: key, Value: value}, nil
+}
+
+func (m *mockRegistryStore) SetMulti(ctx context.Context, ownerID string, entries []*registrystore.Registry) ([]*registrystore.Registry, error) {
+	for _, entry := range entries {
+		m.data[entry.Key] = entry.Value
+	}
+	return entries, nil
+}
+
+func (m *mockRegistryStore) Delete(ctx context.Context, ownerID, key string) error {
+	delete(m.data, key)
+	return nil
+}
+
+func (m *mockRegistryStore) DeleteMulti(ctx context.Context, ownerID string, keys []string) error {
+	for _, key := range keys {
+		delete(m.data, key)
+	}
+	return nil
+}
+
+// recordingAuditLog keeps recorded entries in memory
+type recordingAuditLog struct {
+	entries []*auditlog.Entry
+}
+
+func (l *recordingAuditLog) Record(ctx context.Context, entry *auditlog.Entry) error {
+	l.entries = append(l.entries, entry)
+	return nil
+}
+
+func (l *recordingAuditLog) List(ctx context.Context, filter auditlog.Filter, offset, limit int) ([]*auditlog.Entry, int, error) {
+	return l.entries, len(l.entries), nil
+}
+
+func (l *recordingAuditLog) ListAfter(ctx context.Context, filter auditlog.Filter, after *pagination.Key, limit int) ([]*auditlog.Entry, int, bool, error) {
+	return l.entries, len(l.entries), false, nil
+}
+
+func (l *recordingAuditLog) Prune(ctx context.Context, cutoff time.Time) (int, error) {
+	return 0, nil
+}
+
+func TestParseNetworks(t *testing.T) {
+	prefixes, err := ParseNetworks([]string{" 10.1.2.3/8", "192.168.1.5", "", "::ffff:192.168.1.5", "2001:db8::/32", "10.0.0.0/8"})
+	require.NoError(t, err)
+	var networks []string
+	for _, p := range prefixes {
+		networks = append(networks, p.String())
+	}
+	assert.Equal(t, []string{"10.0.0.0/8", "192.168.1.5/32", "2001:db8::/32"}, networks)
+
+	_, err = ParseNetworks([]string{"10.0.0.0/33"})
+	assert.ErrorIs(t, err, ErrInvalidNetwork)
+	_, err = ParseNetworks([]string{"example.com"})
+	assert.ErrorIs(t, err, ErrInvalidNetwork)
+}
+
+func TestGroupOf(t *testing.T) {
+	assert.Equal(t, GroupShares, GroupOf("/api/auth/share"))
+	assert.Equal(t, GroupAuth, GroupOf("/api/auth/login"))
+	assert.Equal(t, GroupAuth, GroupOf("/api/embed/token"))
+	assert.Equal(t, GroupGraphQL, GroupOf("/api/query"))
+	assert.Equal(t, GroupImagor, GroupOf("/imagor/unsafe/photo.jpg"))
+	assert.Empty(t, GroupOf("/health"))
+	assert.Empty(t, GroupOf("/"))
+}
+
+func TestStore_SetAndAllowed(t *testing.T) {
+	ctx := context.Background()
+	registry := newMockRegistryStore()
+	s := New(registry, zap.NewNop())
+
+	assert.True(t, s.Allowed(GroupGraphQL, "203.0.113.7"), "groups without an ACL are unrestricted")
+
+	allow, err := ParseNetworks([]string{"10.0.0.0/8", "192.168.0.0/16"})
+	require.NoError(t, err)
+	deny, err := ParseNetworks([]string{"10.6.6.0/24"})
+	require.NoError(t, err)
+	require.NoError(t, s.Set(ctx, GroupGraphQL, &ACL{Allow: allow, Deny: deny}))
+
+	assert.True(t, s.Allowed(GroupGraphQL, "10.1.2.3"))
+	assert.True(t, s.Allowed(GroupGraphQL, "::ffff:192.168.1.1"))
+	assert.False(t, s.Allowed(GroupGraphQL, "10.6.6.6"), "denied networks win over allowed ones")
+	assert.False(t, s.Allowed(GroupGraphQL, "203.0.113.7"))
+	assert.False(t, s.Allowed(GroupGraphQL, "not-an-ip"))
+	assert.True(t, s.Allowed(GroupImagor, "203.0.113.7"))
+
+	// Deny only
+	require.NoError(t, s.Set(ctx, GroupShares, &ACL{Deny: deny}))
+	assert.True(t, s.Allowed(GroupShares, "203.0.113.7"))
+	assert.False(t, s.Allowed(GroupShares, "10.6.6.1"))
+
+	// Another replica loads the same ACLs
+	other := New(registry, zap.NewNop())
+	require.NoError(t, other.Sync())
+	assert.False(t, other.Allowed(GroupGraphQL, "203.0.113.7"))
+	acl, ok := other.Get(GroupGraphQL)
+	require.True(t, ok)
+	assert.Equal(t, allow, acl.Allow)
+
+	require.NoError(t, s.Clear(ctx, GroupGraphQL))
+	assert.True(t, s.Allowed(GroupGraphQL, "203.0.113.7"))
+	require.NoError(t, other.Sync())
+	assert.True(t, other.Allowed(GroupGraphQL, "203.0.113.7"))
+
+	assert.ErrorIs(t, s.Set(ctx, "health", &ACL{}), ErrUnknownGroup)
+	assert.ErrorIs(t, s.Clear(ctx, "health"), ErrUnknownGroup)
+}
+
+func TestStore_LoadIgnoresMalformed(t *testing.T) {
+	registry := newMockRegistryStore()
+	registry.data[Key(GroupAuth)] = `{"allow":["nope"]}`
+	registry.data[Key(GroupImagor)] = `{"deny":["198.51.100.0/24"]}`
+	s := New(registry, zap.NewNop())
+	require.NoError(t, s.Load(context.Background()))
+
+	_, ok := s.Get(GroupAuth)
+	assert.False(t, ok)
+	assert.False(t, s.Allowed(GroupImagor, "198.51.100.9"))
+}
+
+func TestMiddleware(t *testing.T) {
+	s := New(newMockRegistryStore(), zap.NewNop())
+	deny, err := ParseNetworks([]string{"198.51.100.0/24"})
+	require.NoError(t, err)
+	require.NoError(t, s.Set(context.Background(), GroupAuth, &ACL{Deny: deny}))
+
+	audit := &recordingAuditLog{}
+	handler := s.Middleware(audit)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	serve := func(path, remoteAddr string) int {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.RemoteAddr = remoteAddr
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	assert.Equal(t, http.StatusForbidden, serve("/api/auth/login", "198.51.100.7:4242"))
+	assert.Equal(t, http.StatusForbidden, serve("/api/auth/login", "198.51.100.7:4243"))
+	assert.Equal(t, http.StatusNoContent, serve("/api/auth/login", "203.0.113.7:4242"))
+	assert.Equal(t, http.StatusNoContent, serve("/api/query", "198.51.100.7:4242"))
+	assert.Equal(t, http.StatusNoContent, serve("/health", "198.51.100.7:4242"))
+
+	// Repeated refusals of a client are recorded once
+	require.Len(t, audit.entries, 1)
+	entry := audit.entries[0]
+	assert.Equal(t, AuditOperation, entry.Operation)
+	assert.Equal(t, GroupAuth, entry.Target)
+	assert.Equal(t, "/api/auth/login", entry.Path)
+	assert.Equal(t, "198.51.100.7", entry.ClientIP)
+	assert.NotEmpty(t, entry.Error)
+}
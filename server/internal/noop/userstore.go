@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/cshum/imagor-studio/server/internal/model"
+	"github.com/cshum/imagor-studio/server/internal/pagination"
 	"github.com/cshum/imagor-studio/server/internal/userstore"
 )
 
@@ -101,3 +102,23 @@ func (n *UserStore) UpsertOAuth(ctx context.Context, provider, providerID, email
 func (n *UserStore) UpdateRole(ctx context.Context, id string, role string) error {
 	return ErrEmbeddedMode
 }
+
+func (n *UserStore) UpdateHomePath(ctx context.Context, id string, homePath *string) error {
+	return ErrEmbeddedMode
+}
+
+func (n *UserStore) CreateInTenant(ctx context.Context, tenantID, displayName, username, hashedPassword, role string) (*userstore.User, error) {
+	return nil, ErrEmbeddedMode
+}
+
+func (n *UserStore) ListByTenant(ctx context.Context, tenantID string, offset, limit int, search string) ([]*userstore.User, int, error) {
+	return nil, 0, ErrEmbeddedMode
+}
+
+func (n *UserStore) ListAfter(ctx context.Context, tenantID string, after *pagination.Key, limit int, search string) ([]*userstore.User, int, bool, error) {
+	return nil, 0, false, ErrEmbeddedMode
+}
+
+func (n *UserStore) UpdateTenant(ctx context.Context, id string, tenantID *string) error {
+	return ErrEmbeddedMode
+}
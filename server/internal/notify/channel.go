@@ -0,0 +1,201 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxErrorLength bounds the response body kept in send errors
+const maxErrorLength = 512
+
+// Channel delivers messages to admins
+type Channel interface {
+	// Name is the channel name, see ChannelEmail, ChannelTelegram and
+	// ChannelNtfy
+	Name() string
+	Send(ctx context.Context, msg Message) error
+}
+
+// Email sends messages over SMTP. Port 465 connects with implicit TLS,
+// other ports upgrade with STARTTLS when the server supports it.
+type Email struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+func (e *Email) Name() string {
+	return ChannelEmail
+}
+
+func (e *Email) Send(ctx context.Context, msg Message) error {
+	addr := net.JoinHostPort(e.Host, strconv.Itoa(e.Port))
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	var conn net.Conn
+	var err error
+	if e.Port == 465 {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: e.Host}}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("email: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	client, err := smtp.NewClient(conn, e.Host)
+	if err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("email: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: e.Host}); err != nil {
+			return fmt.Errorf("email: %w", err)
+		}
+	}
+	if e.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", e.Username, e.Password, e.Host)); err != nil {
+			return fmt.Errorf("email: %w", err)
+		}
+	}
+	if err := client.Mail(e.From); err != nil {
+		return fmt.Errorf("email: %w", err)
+	}
+	for _, to := range e.To {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("email: %w", err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("email: %w", err)
+	}
+	if _, err := w.Write(e.message(msg)); err != nil {
+		return fmt.Errorf("email: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("email: %w", err)
+	}
+	return client.Quit()
+}
+
+// headerReplacer keeps values on a single header line
+var headerReplacer = strings.NewReplacer("\r", " ", "\n", " ")
+
+func (e *Email) message(msg Message) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", e.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(e.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", headerReplacer.Replace(msg.Title))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
+
+// Telegram sends messages to a chat through a Telegram bot
+type Telegram struct {
+	// BaseURL is the Bot API endpoint, DefaultTelegramURL when empty
+	BaseURL  string
+	BotToken string
+	ChatID   string
+	Client   *http.Client
+}
+
+func (t *Telegram) Name() string {
+	return ChannelTelegram
+}
+
+func (t *Telegram) Send(ctx context.Context, msg Message) error {
+	baseURL := t.BaseURL
+	if baseURL == "" {
+		baseURL = DefaultTelegramURL
+	}
+	body, err := json.Marshal(map[string]string{
+		"chat_id": t.ChatID,
+		"text":    msg.Title + "\n\n" + msg.Body,
+	})
+	if err != nil {
+		return fmt.Errorf("telegram: %w", err)
+	}
+	endpoint := strings.TrimSuffix(baseURL, "/") + "/bot" + t.BotToken + "/sendMessage"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return errors.New("telegram: invalid bot API URL")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return do(t.Client, req, ChannelTelegram)
+}
+
+// Ntfy publishes messages to an ntfy topic
+type Ntfy struct {
+	// URL is the topic URL, such as https://ntfy.sh/my-topic
+	URL string
+	// Token authenticates to servers with access control, optional
+	Token  string
+	Client *http.Client
+}
+
+func (n *Ntfy) Name() string {
+	return ChannelNtfy
+}
+
+func (n *Ntfy) Send(ctx context.Context, msg Message) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, strings.NewReader(msg.Body))
+	if err != nil {
+		return fmt.Errorf("ntfy: %w", err)
+	}
+	req.Header.Set("Title", msg.Title)
+	if msg.Event != "" {
+		req.Header.Set("Tags", msg.Event)
+	}
+	if n.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.Token)
+	}
+	return do(n.Client, req, ChannelNtfy)
+}
+
+// do sends req, failing on responses other than 2xx. Client errors are
+// unwrapped from the request URL, which may carry credentials.
+func do(client *http.Client, req *http.Request, channel string) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("%s: %w", channel, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorLength))
+		if len(detail) > 0 {
+			return fmt.Errorf("%s responded %s: %s", channel, resp.Status, bytes.TrimSpace(detail))
+		}
+		return fmt.Errorf("%s responded %s", channel, resp.Status)
+	}
+	return nil
+}
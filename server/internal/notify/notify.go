@@ -0,0 +1,255 @@
+// Package notify tells admins of server events, such as shared links opened,
+// library scans completed with errors and malware found in uploads, through
+// email, Telegram and ntfy.
+//
+// Channels are configured by system registry settings, their credentials
+// stored encrypted. Settings are read on every notification, so changes
+// take effect right away on every replica. A channel is enabled once its
+// required settings are set, and sends the events of config.notify_events,
+// every event when empty.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/internal/registryutil"
+	"go.uber.org/zap"
+)
+
+// Event names
+const (
+	EventShareAccessed  = "share.accessed"
+	EventScanErrors     = "scan.errors"
+	EventUploadInfected = "upload.infected"
+)
+
+// Events are the event names admins can be notified of
+var Events = []string{EventScanErrors, EventShareAccessed, EventUploadInfected}
+
+// Channel names
+const (
+	ChannelEmail    = "email"
+	ChannelTelegram = "telegram"
+	ChannelNtfy     = "ntfy"
+)
+
+// Channels are the channel names
+var Channels = []string{ChannelEmail, ChannelTelegram, ChannelNtfy}
+
+// Registry keys
+const (
+	// EventsKey is the comma separated events notified, every event when
+	// empty
+	EventsKey = "config.notify_events"
+
+	// EmailHostKey, EmailFromKey and EmailToKey enable email, EmailToKey
+	// being comma separated addresses. EmailPortKey defaults to 587.
+	EmailHostKey     = "config.notify_email_host"
+	EmailPortKey     = "config.notify_email_port"
+	EmailUsernameKey = "config.notify_email_username"
+	EmailPasswordKey = "config.notify_email_password"
+	EmailFromKey     = "config.notify_email_from"
+	EmailToKey       = "config.notify_email_to"
+
+	// TelegramBotTokenKey and TelegramChatIDKey enable Telegram
+	TelegramBotTokenKey = "config.notify_telegram_bot_token"
+	TelegramChatIDKey   = "config.notify_telegram_chat_id"
+
+	// NtfyURLKey, the topic URL, enables ntfy
+	NtfyURLKey   = "config.notify_ntfy_url"
+	NtfyTokenKey = "config.notify_ntfy_token"
+)
+
+// CredentialKeys are the settings always stored encrypted
+var CredentialKeys = []string{EmailPasswordKey, TelegramBotTokenKey, NtfyTokenKey}
+
+var registryKeys = []string{
+	EventsKey,
+	EmailHostKey, EmailPortKey, EmailUsernameKey, EmailPasswordKey, EmailFromKey, EmailToKey,
+	TelegramBotTokenKey, TelegramChatIDKey,
+	NtfyURLKey, NtfyTokenKey,
+}
+
+const (
+	// DefaultTelegramURL is the Telegram Bot API endpoint
+	DefaultTelegramURL = "https://api.telegram.org"
+
+	defaultEmailPort = 587
+	// sendTimeout bounds the sending of a notification to every channel
+	sendTimeout = time.Minute
+)
+
+var (
+	// ErrNotConfigured is returned when testing channels none of which is
+	// configured
+	ErrNotConfigured = errors.New("notification channel not configured")
+	ErrInvalid       = errors.New("invalid notification channel")
+)
+
+// Message is a notification
+type Message struct {
+	Event string
+	Title string
+	Body  string
+}
+
+// Result is the outcome of sending a message to a channel
+type Result struct {
+	Channel string
+	Err     error
+}
+
+// Notifier sends notifications to the channels configured in the registry
+type Notifier struct {
+	logger        *zap.Logger
+	registryStore registrystore.Store
+	config        registryutil.ConfigProvider
+	client        *http.Client
+	telegramURL   string
+}
+
+// Option configures a Notifier
+type Option func(*Notifier)
+
+// WithHTTPClient sets the HTTP client of the Telegram and ntfy channels
+func WithHTTPClient(client *http.Client) Option {
+	return func(n *Notifier) {
+		n.client = client
+	}
+}
+
+// WithTelegramURL overrides the Telegram Bot API endpoint
+func WithTelegramURL(url string) Option {
+	return func(n *Notifier) {
+		n.telegramURL = url
+	}
+}
+
+// New returns a notifier reading its settings from registryStore
+func New(logger *zap.Logger, registryStore registrystore.Store, cfg registryutil.ConfigProvider, opts ...Option) *Notifier {
+	n := &Notifier{
+		logger:        logger,
+		registryStore: registryStore,
+		config:        cfg,
+		client:        &http.Client{Timeout: 30 * time.Second},
+		telegramURL:   DefaultTelegramURL,
+	}
+	for _, opt := range opts {
+		opt(n)
+	}
+	return n
+}
+
+// Notify sends msg to the configured channels in the background, unless
+// admins opted out of its event. Failures are logged.
+func (n *Notifier) Notify(msg Message) {
+	if n == nil {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		defer cancel()
+		settings := n.settings(ctx)
+		if events := splitList(settings[EventsKey]); len(events) > 0 && !slices.Contains(events, msg.Event) {
+			return
+		}
+		for _, result := range n.send(ctx, n.channels(settings), msg) {
+			if result.Err != nil {
+				n.logger.Warn("Failed to send notification",
+					zap.String("channel", result.Channel),
+					zap.String("event", msg.Event),
+					zap.Error(result.Err))
+			}
+		}
+	}()
+}
+
+// Test sends a test message to channel, or to every configured channel when
+// empty, whatever the events notified
+func (n *Notifier) Test(ctx context.Context, channel string) ([]Result, error) {
+	if channel != "" && !slices.Contains(Channels, channel) {
+		return nil, fmt.Errorf("%w: unknown channel %q, expected one of %s", ErrInvalid, channel, strings.Join(Channels, ", "))
+	}
+	var channels []Channel
+	for _, c := range n.channels(n.settings(ctx)) {
+		if channel == "" || c.Name() == channel {
+			channels = append(channels, c)
+		}
+	}
+	if len(channels) == 0 {
+		return nil, ErrNotConfigured
+	}
+	return n.send(ctx, channels, Message{
+		Title: "Imagor Studio test notification",
+		Body:  "Notifications are set up. Admins will be notified of events on this server here.",
+	}), nil
+}
+
+func (n *Notifier) send(ctx context.Context, channels []Channel, msg Message) []Result {
+	results := make([]Result, 0, len(channels))
+	for _, c := range channels {
+		results = append(results, Result{Channel: c.Name(), Err: c.Send(ctx, msg)})
+	}
+	return results
+}
+
+func (n *Notifier) settings(ctx context.Context) map[string]string {
+	settings := make(map[string]string, len(registryKeys))
+	for _, result := range registryutil.GetEffectiveValues(ctx, n.registryStore, n.config, registryKeys...) {
+		settings[result.Key] = strings.TrimSpace(result.Value)
+	}
+	return settings
+}
+
+// channels returns the channels whose required settings are set
+func (n *Notifier) channels(settings map[string]string) []Channel {
+	var channels []Channel
+	if to := splitList(settings[EmailToKey]); settings[EmailHostKey] != "" && settings[EmailFromKey] != "" && len(to) > 0 {
+		port, err := strconv.Atoi(settings[EmailPortKey])
+		if err != nil || port <= 0 {
+			port = defaultEmailPort
+		}
+		channels = append(channels, &Email{
+			Host:     settings[EmailHostKey],
+			Port:     port,
+			Username: settings[EmailUsernameKey],
+			Password: settings[EmailPasswordKey],
+			From:     settings[EmailFromKey],
+			To:       to,
+		})
+	}
+	if settings[TelegramBotTokenKey] != "" && settings[TelegramChatIDKey] != "" {
+		channels = append(channels, &Telegram{
+			BaseURL:  n.telegramURL,
+			BotToken: settings[TelegramBotTokenKey],
+			ChatID:   settings[TelegramChatIDKey],
+			Client:   n.client,
+		})
+	}
+	if settings[NtfyURLKey] != "" {
+		channels = append(channels, &Ntfy{
+			URL:    settings[NtfyURLKey],
+			Token:  settings[NtfyTokenKey],
+			Client: n.client,
+		})
+	}
+	return channels
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
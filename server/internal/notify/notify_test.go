@@ -0,0 +1,116 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type staticConfig map[string]string
+
+func (c staticConfig) GetByRegistryKey(key string) (string, bool) {
+	v, ok := c[key]
+	return v, ok
+}
+
+func (c staticConfig) IsEmbeddedMode() bool { return false }
+
+func TestTest(t *testing.T) {
+	telegram := make(chan map[string]string, 1)
+	telegramSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/botbot-token/sendMessage", r.URL.Path)
+		var body map[string]string
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		telegram <- body
+	}))
+	defer telegramSrv.Close()
+	ntfySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer ntfy-token", r.Header.Get("Authorization"))
+		http.Error(w, `{"error":"forbidden"}`, http.StatusForbidden)
+	}))
+	defer ntfySrv.Close()
+
+	n := New(zap.NewNop(), nil, staticConfig{
+		TelegramBotTokenKey: "bot-token",
+		TelegramChatIDKey:   "42",
+		NtfyURLKey:          ntfySrv.URL + "/alerts",
+		NtfyTokenKey:        "ntfy-token",
+		// Incomplete, email is not configured
+		EmailHostKey: "smtp.example.com",
+	}, WithTelegramURL(telegramSrv.URL))
+
+	results, err := n.Test(context.Background(), "")
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, ChannelTelegram, results[0].Channel)
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, "42", (<-telegram)["chat_id"])
+	assert.Equal(t, ChannelNtfy, results[1].Channel)
+	assert.ErrorContains(t, results[1].Err, "ntfy responded 403 Forbidden")
+
+	_, err = n.Test(context.Background(), ChannelEmail)
+	assert.ErrorIs(t, err, ErrNotConfigured)
+	_, err = n.Test(context.Background(), "sms")
+	assert.ErrorIs(t, err, ErrInvalid)
+}
+
+func TestNotify(t *testing.T) {
+	received := make(chan *http.Request, 2)
+	bodies := make(chan string, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- string(body)
+	}))
+	defer srv.Close()
+
+	n := New(zap.NewNop(), nil, staticConfig{
+		NtfyURLKey: srv.URL + "/alerts",
+		EventsKey:  EventScanErrors,
+	})
+	n.Notify(Message{Event: EventShareAccessed, Title: "Shared link opened", Body: "photos"})
+	n.Notify(Message{Event: EventScanErrors, Title: "Library scan failed", Body: "storage unreachable"})
+
+	select {
+	case r := <-received:
+		assert.Equal(t, "Library scan failed", r.Header.Get("Title"))
+		assert.Equal(t, EventScanErrors, r.Header.Get("Tags"))
+		assert.Empty(t, r.Header.Get("Authorization"))
+		assert.Equal(t, "storage unreachable", <-bodies)
+	case <-time.After(5 * time.Second):
+		t.Fatal("notification not sent")
+	}
+	select {
+	case r := <-received:
+		t.Fatalf("unexpected notification %q", r.Header.Get("Title"))
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// A nil notifier is a no-op
+	var nilNotifier *Notifier
+	nilNotifier.Notify(Message{Event: EventScanErrors})
+}
+
+func TestEmailMessage(t *testing.T) {
+	e := &Email{From: "studio@example.com", To: []string{"a@example.com", "b@example.com"}}
+	msg := string(e.message(Message{Title: "Library scan\r\nBcc: x@example.com", Body: "line 1\nline 2"}))
+	assert.Contains(t, msg, "To: a@example.com, b@example.com\r\n")
+	assert.Contains(t, msg, "Subject: Library scan  Bcc: x@example.com\r\n")
+	assert.True(t, strings.HasSuffix(msg, "\r\n\r\nline 1\r\nline 2\r\n"))
+}
+
+func TestTelegram_HidesToken(t *testing.T) {
+	tg := &Telegram{BaseURL: "http://127.0.0.1:0", BotToken: "secret-token", ChatID: "1"}
+	err := tg.Send(context.Background(), Message{Title: "t"})
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "secret-token")
+}
@@ -0,0 +1,629 @@
+// Package operation tracks long-running work started by API mutations.
+//
+// A mutation starts an operation and returns its ID immediately; clients then
+// poll or watch the operation for progress, partial results and the final
+// outcome, and may request cancellation. Operations run on the replica that started them
+// and are pruned once finished for longer than the retention. A worker limit
+// queues operations beyond it until a worker is free.
+//
+// With a Store, snapshots are also persisted so operations stay queryable
+// from every replica and across restarts. Persisted progress is refreshed by
+// Sweep; operations left unfinished by a replica that stopped are failed once
+// their heartbeat is older than the stale limit.
+package operation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/pkg/uuid"
+	"go.uber.org/zap"
+)
+
+// Status is the lifecycle state of an operation
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+	StatusCancelled Status = "cancelled"
+)
+
+// DefaultRetention is how long finished operations remain queryable
+const DefaultRetention = time.Hour
+
+// DefaultMaxResults caps the partial results kept per operation
+const DefaultMaxResults = 1000
+
+// DefaultWorkers is the suggested number of operations running at once
+const DefaultWorkers = 4
+
+// DefaultStaleAfter is how long a persisted operation may go without a
+// heartbeat before it is considered abandoned. Sweep must run more often.
+const DefaultStaleAfter = 5 * time.Minute
+
+// watchPollInterval is how often Watch polls operations of other replicas
+const watchPollInterval = 5 * time.Second
+
+// listLimit caps the persisted operations returned by List
+const listLimit = 500
+
+var (
+	// ErrNotFound is returned for unknown or pruned operation IDs
+	ErrNotFound = errors.New("operation not found")
+	// ErrRemote is returned when cancelling an operation running on another replica
+	ErrRemote = errors.New("operation is running on another server")
+)
+
+// Operation is a point-in-time snapshot of a tracked operation
+type Operation struct {
+	ID        string
+	Kind      string
+	OwnerID   string
+	Status    Status
+	Completed int
+	// Total is 0 while the amount of work is unknown
+	Total      int
+	Message    string
+	Error      string
+	Results    []string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	StartedAt  *time.Time
+	FinishedAt *time.Time
+}
+
+// Finished reports whether the operation reached a terminal status
+func (o Operation) Finished() bool {
+	return o.Status != StatusQueued && o.Status != StatusRunning
+}
+
+// Store persists operation snapshots
+type Store interface {
+	// Save replaces the snapshot of an operation with a fresh heartbeat
+	Save(ctx context.Context, op Operation, heartbeatAt time.Time) error
+	// Get returns a snapshot, or nil when there is none
+	Get(ctx context.Context, id string) (*Operation, error)
+	// List returns up to limit snapshots, newest first. An empty kind
+	// matches every operation.
+	List(ctx context.Context, kind string, limit int) ([]Operation, error)
+	// FailStale fails unfinished operations without a heartbeat since before
+	FailStale(ctx context.Context, before time.Time, message string) (int, error)
+	// DeleteFinishedBefore drops operations finished before the given time
+	DeleteFinishedBefore(ctx context.Context, before time.Time) error
+}
+
+// Func performs the work of an operation, reporting through progress.
+// It should return promptly with ctx.Err() once ctx is cancelled.
+type Func func(ctx context.Context, progress *Progress) error
+
+type entry struct {
+	op     Operation
+	cancel context.CancelFunc
+	done   chan struct{}
+	// changed is closed and replaced on every change, guarded by the manager
+	changed chan struct{}
+	// persistMu orders saves so an older snapshot never overwrites a newer one
+	persistMu sync.Mutex
+}
+
+// Manager runs and tracks operations
+type Manager struct {
+	logger     *zap.Logger
+	retention  time.Duration
+	maxResults int
+	staleAfter time.Duration
+	store      Store
+	// slots holds a token per running operation, nil when unlimited
+	slots chan struct{}
+	now   func() time.Time
+
+	mu  sync.RWMutex
+	ops map[string]*entry
+}
+
+// Option configures a Manager
+type Option func(*Manager)
+
+// WithRetention sets how long finished operations remain queryable
+func WithRetention(retention time.Duration) Option {
+	return func(m *Manager) {
+		if retention > 0 {
+			m.retention = retention
+		}
+	}
+}
+
+// WithMaxResults sets how many partial results are kept per operation
+func WithMaxResults(maxResults int) Option {
+	return func(m *Manager) {
+		if maxResults > 0 {
+			m.maxResults = maxResults
+		}
+	}
+}
+
+// WithWorkers limits the operations running at once, later ones are queued
+func WithWorkers(n int) Option {
+	return func(m *Manager) {
+		if n > 0 {
+			m.slots = make(chan struct{}, n)
+		}
+	}
+}
+
+// WithStore persists operation snapshots to store
+func WithStore(store Store) Option {
+	return func(m *Manager) {
+		m.store = store
+	}
+}
+
+// WithStaleAfter sets how long a persisted operation may go without a
+// heartbeat before Sweep fails it
+func WithStaleAfter(d time.Duration) Option {
+	return func(m *Manager) {
+		if d > 0 {
+			m.staleAfter = d
+		}
+	}
+}
+
+// NewManager creates an operation manager
+func NewManager(logger *zap.Logger, opts ...Option) *Manager {
+	m := &Manager{
+		logger:     logger,
+		retention:  DefaultRetention,
+		maxResults: DefaultMaxResults,
+		staleAfter: DefaultStaleAfter,
+		now:        time.Now,
+		ops:        make(map[string]*entry),
+	}
+	if m.logger == nil {
+		m.logger = zap.NewNop()
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Start runs fn in the background and returns the initial snapshot.
+// The operation is detached from ctx cancellation so it outlives the request
+// that started it, but keeps its values.
+func (m *Manager) Start(ctx context.Context, kind, ownerID string, fn Func) Operation {
+	op, _ := m.start(ctx, kind, ownerID, fn, false)
+	return op
+}
+
+// StartExclusive is like Start but returns the running operation of the same
+// kind instead when there is one. started reports whether fn was started.
+func (m *Manager) StartExclusive(ctx context.Context, kind, ownerID string, fn Func) (op Operation, started bool) {
+	return m.start(ctx, kind, ownerID, fn, true)
+}
+
+func (m *Manager) start(ctx context.Context, kind, ownerID string, fn Func, exclusive bool) (Operation, bool) {
+	m.prune()
+
+	now := m.now()
+	opCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	e := &entry{
+		op: Operation{
+			ID:        uuid.GenerateUUID(),
+			Kind:      kind,
+			OwnerID:   ownerID,
+			Status:    StatusQueued,
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		changed: make(chan struct{}),
+	}
+	// Started right away when a worker is free
+	acquired := m.tryAcquire()
+	if acquired {
+		e.op.Status = StatusRunning
+		e.op.StartedAt = &now
+	}
+
+	m.mu.Lock()
+	if exclusive {
+		for _, existing := range m.ops {
+			if existing.op.Kind == kind && !existing.op.Finished() {
+				snapshot := existing.snapshot()
+				m.mu.Unlock()
+				cancel()
+				if acquired {
+					m.release()
+				}
+				return snapshot, false
+			}
+		}
+	}
+	m.ops[e.op.ID] = e
+	snapshot := e.op
+	m.mu.Unlock()
+	m.persist(e)
+
+	go m.run(opCtx, e, fn, acquired)
+
+	return snapshot, true
+}
+
+func (m *Manager) run(ctx context.Context, e *entry, fn Func, acquired bool) {
+	defer close(e.done)
+	defer e.cancel()
+
+	if !acquired {
+		if !m.acquire(ctx) {
+			// Cancelled while queued
+			m.finish(ctx, e, ctx.Err())
+			return
+		}
+		m.mu.Lock()
+		now := m.now()
+		e.op.Status = StatusRunning
+		e.op.StartedAt = &now
+		e.op.UpdatedAt = now
+		e.notify()
+		m.mu.Unlock()
+		m.persist(e)
+	}
+	defer m.release()
+
+	var err error
+	func() {
+		defer func() {
+			if rec := recover(); rec != nil {
+				err = fmt.Errorf("operation panicked: %v", rec)
+			}
+		}()
+		err = fn(ctx, &Progress{manager: m, entry: e})
+	}()
+	m.finish(ctx, e, err)
+}
+
+// finish records the outcome of an operation
+func (m *Manager) finish(ctx context.Context, e *entry, err error) {
+	m.mu.Lock()
+	now := m.now()
+	e.op.UpdatedAt = now
+	e.op.FinishedAt = &now
+	switch {
+	case err == nil:
+		e.op.Status = StatusSucceeded
+	case ctx.Err() != nil:
+		// Any error after cancellation is treated as the result of cancelling
+		e.op.Status = StatusCancelled
+	default:
+		e.op.Status = StatusFailed
+		e.op.Error = err.Error()
+		if m.logger != nil {
+			m.logger.Warn("Operation failed",
+				zap.String("id", e.op.ID),
+				zap.String("kind", e.op.Kind),
+				zap.Error(err))
+		}
+	}
+	e.notify()
+	m.mu.Unlock()
+	m.persist(e)
+}
+
+func (m *Manager) tryAcquire() bool {
+	if m.slots == nil {
+		return true
+	}
+	select {
+	case m.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (m *Manager) acquire(ctx context.Context) bool {
+	select {
+	case m.slots <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (m *Manager) release() {
+	if m.slots != nil {
+		<-m.slots
+	}
+}
+
+// Get returns a snapshot of the operation, looking up the store for
+// operations not running on this replica
+func (m *Manager) Get(id string) (Operation, error) {
+	m.mu.RLock()
+	e, ok := m.ops[id]
+	if ok {
+		defer m.mu.RUnlock()
+		return e.snapshot(), nil
+	}
+	m.mu.RUnlock()
+	return m.getPersisted(id)
+}
+
+func (m *Manager) getPersisted(id string) (Operation, error) {
+	if m.store == nil {
+		return Operation{}, ErrNotFound
+	}
+	op, err := m.store.Get(context.Background(), id)
+	if err != nil {
+		return Operation{}, err
+	}
+	if op == nil {
+		return Operation{}, ErrNotFound
+	}
+	return *op, nil
+}
+
+// List returns snapshots of tracked operations, newest first. An empty kind
+// matches every operation.
+func (m *Manager) List(kind string) []Operation {
+	m.mu.RLock()
+	ops := make([]Operation, 0, len(m.ops))
+	local := make(map[string]bool, len(m.ops))
+	for id, e := range m.ops {
+		local[id] = true
+		if kind == "" || e.op.Kind == kind {
+			ops = append(ops, e.snapshot())
+		}
+	}
+	m.mu.RUnlock()
+	if m.store != nil {
+		persisted, err := m.store.List(context.Background(), kind, listLimit)
+		if err != nil {
+			m.logger.Warn("Failed to list persisted operations", zap.Error(err))
+		}
+		for _, op := range persisted {
+			if !local[op.ID] {
+				ops = append(ops, op)
+			}
+		}
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		if ops[i].CreatedAt.Equal(ops[j].CreatedAt) {
+			return ops[i].ID > ops[j].ID
+		}
+		return ops[i].CreatedAt.After(ops[j].CreatedAt)
+	})
+	return ops
+}
+
+// Cancel requests cancellation of a running operation and returns its
+// current snapshot. Cancelling a finished operation is a no-op.
+func (m *Manager) Cancel(id string) (Operation, error) {
+	m.mu.RLock()
+	e, ok := m.ops[id]
+	m.mu.RUnlock()
+	if !ok {
+		op, err := m.getPersisted(id)
+		if err == nil && !op.Finished() {
+			return op, ErrRemote
+		}
+		return op, err
+	}
+	e.cancel()
+	return m.Get(id)
+}
+
+// Wait blocks until the operation finishes or ctx is done
+func (m *Manager) Wait(ctx context.Context, id string) (Operation, error) {
+	m.mu.RLock()
+	e, ok := m.ops[id]
+	m.mu.RUnlock()
+	if !ok {
+		return Operation{}, ErrNotFound
+	}
+	select {
+	case <-e.done:
+		return m.Get(id)
+	case <-ctx.Done():
+		return Operation{}, ctx.Err()
+	}
+}
+
+// Watch sends a snapshot of the operation now and after every change until
+// it finishes or ctx is done, then closes the channel. Updates made while the
+// receiver is busy are coalesced into the latest snapshot. Operations running
+// on another replica are polled from the store instead.
+func (m *Manager) Watch(ctx context.Context, id string) (<-chan Operation, error) {
+	m.mu.RLock()
+	e, ok := m.ops[id]
+	m.mu.RUnlock()
+	if !ok {
+		op, err := m.getPersisted(id)
+		if err != nil {
+			return nil, err
+		}
+		ch := make(chan Operation, 1)
+		go m.pollPersisted(ctx, op, ch)
+		return ch, nil
+	}
+	ch := make(chan Operation, 1)
+	go func() {
+		defer close(ch)
+		for {
+			m.mu.RLock()
+			op := e.snapshot()
+			changed := e.changed
+			m.mu.RUnlock()
+			select {
+			case ch <- op:
+			case <-ctx.Done():
+				return
+			}
+			if op.Finished() {
+				return
+			}
+			select {
+			case <-changed:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// pollPersisted sends persisted snapshots of an operation running elsewhere
+// whenever its update time moves
+func (m *Manager) pollPersisted(ctx context.Context, op Operation, ch chan<- Operation) {
+	defer close(ch)
+	ticker := time.NewTicker(watchPollInterval)
+	defer ticker.Stop()
+	var sent time.Time
+	for {
+		if !op.UpdatedAt.Equal(sent) {
+			select {
+			case ch <- op:
+			case <-ctx.Done():
+				return
+			}
+			sent = op.UpdatedAt
+		}
+		if op.Finished() {
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		next, err := m.getPersisted(op.ID)
+		if err != nil {
+			m.logger.Warn("Failed to poll operation", zap.String("id", op.ID), zap.Error(err))
+			continue
+		}
+		op = next
+	}
+}
+
+// prune drops operations finished longer than the retention ago
+func (m *Manager) prune() {
+	cutoff := m.now().Add(-m.retention)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, e := range m.ops {
+		if e.op.FinishedAt != nil && e.op.FinishedAt.Before(cutoff) {
+			delete(m.ops, id)
+		}
+	}
+}
+
+// Sweep refreshes the persisted progress and heartbeat of the operations
+// running here, fails persisted operations abandoned by a stopped replica
+// and drops those past the retention. Called by the server sync loop.
+func (m *Manager) Sweep() error {
+	m.prune()
+	if m.store == nil {
+		return nil
+	}
+	m.mu.RLock()
+	var unfinished []*entry
+	for _, e := range m.ops {
+		if !e.op.Finished() {
+			unfinished = append(unfinished, e)
+		}
+	}
+	m.mu.RUnlock()
+	for _, e := range unfinished {
+		m.persist(e)
+	}
+
+	ctx := context.Background()
+	now := m.now()
+	failed, err := m.store.FailStale(ctx, now.Add(-m.staleAfter), "interrupted: the server running it stopped")
+	if err != nil {
+		return fmt.Errorf("failed to fail stale operations: %w", err)
+	}
+	if failed > 0 {
+		m.logger.Warn("Failed abandoned operations", zap.Int("count", failed))
+	}
+	if err := m.store.DeleteFinishedBefore(ctx, now.Add(-m.retention)); err != nil {
+		return fmt.Errorf("failed to prune operations: %w", err)
+	}
+	return nil
+}
+
+// persist saves the current snapshot of an operation to the store,
+// failures are logged as the operation itself carries on
+func (m *Manager) persist(e *entry) {
+	if m.store == nil {
+		return
+	}
+	e.persistMu.Lock()
+	defer e.persistMu.Unlock()
+	m.mu.RLock()
+	op := e.snapshot()
+	m.mu.RUnlock()
+	if err := m.store.Save(context.Background(), op, m.now()); err != nil {
+		m.logger.Warn("Failed to persist operation", zap.String("id", op.ID), zap.Error(err))
+	}
+}
+
+// notify wakes up watchers, the manager lock must be held
+func (e *entry) notify() {
+	close(e.changed)
+	e.changed = make(chan struct{})
+}
+
+func (e *entry) snapshot() Operation {
+	op := e.op
+	op.Results = append([]string(nil), e.op.Results...)
+	return op
+}
+
+// Progress reports progress for a running operation
+type Progress struct {
+	manager *Manager
+	entry   *entry
+}
+
+// SetTotal sets the total amount of work, if known
+func (p *Progress) SetTotal(total int) {
+	p.update(func(op *Operation) {
+		op.Total = total
+	})
+}
+
+// SetMessage sets a human readable status message
+func (p *Progress) SetMessage(message string) {
+	p.update(func(op *Operation) {
+		op.Message = message
+	})
+}
+
+// Advance marks n more units of work as completed and records any partial
+// results. Only the most recent results are kept once the cap is reached.
+func (p *Progress) Advance(n int, results ...string) {
+	p.update(func(op *Operation) {
+		op.Completed += n
+		op.Results = append(op.Results, results...)
+		if overflow := len(op.Results) - p.manager.maxResults; overflow > 0 {
+			op.Results = append([]string(nil), op.Results[overflow:]...)
+		}
+	})
+}
+
+func (p *Progress) update(fn func(op *Operation)) {
+	p.manager.mu.Lock()
+	defer p.manager.mu.Unlock()
+	fn(&p.entry.op)
+	p.entry.op.UpdatedAt = p.manager.now()
+	p.entry.notify()
+}
@@ -0,0 +1,263 @@
+package operation
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func waitFor(t *testing.T, m *Manager, id string) Operation {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	op, err := m.Wait(ctx, id)
+	require.NoError(t, err)
+	return op
+}
+
+func TestManager_Succeeded(t *testing.T) {
+	m := NewManager(zap.NewNop())
+
+	started := m.Start(context.Background(), "test", "user-1", func(ctx context.Context, p *Progress) error {
+		p.SetTotal(2)
+		p.Advance(1, "a")
+		p.SetMessage("halfway")
+		p.Advance(1, "b")
+		return nil
+	})
+	assert.NotEmpty(t, started.ID)
+	assert.Equal(t, StatusRunning, started.Status)
+	assert.Equal(t, "user-1", started.OwnerID)
+
+	op := waitFor(t, m, started.ID)
+	assert.Equal(t, StatusSucceeded, op.Status)
+	assert.True(t, op.Finished())
+	assert.Equal(t, 2, op.Completed)
+	assert.Equal(t, 2, op.Total)
+	assert.Equal(t, "halfway", op.Message)
+	assert.Equal(t, []string{"a", "b"}, op.Results)
+	assert.NotNil(t, op.FinishedAt)
+}
+
+func TestManager_Failed(t *testing.T) {
+	m := NewManager(zap.NewNop())
+
+	started := m.Start(context.Background(), "test", "user-1", func(ctx context.Context, p *Progress) error {
+		return errors.New("boom")
+	})
+
+	op := waitFor(t, m, started.ID)
+	assert.Equal(t, StatusFailed, op.Status)
+	assert.Equal(t, "boom", op.Error)
+}
+
+func TestManager_Panic(t *testing.T) {
+	m := NewManager(zap.NewNop())
+
+	started := m.Start(context.Background(), "test", "user-1", func(ctx context.Context, p *Progress) error {
+		panic("unexpected")
+	})
+
+	op := waitFor(t, m, started.ID)
+	assert.Equal(t, StatusFailed, op.Status)
+	assert.Contains(t, op.Error, "unexpected")
+}
+
+func TestManager_Cancel(t *testing.T) {
+	m := NewManager(zap.NewNop())
+	running := make(chan struct{})
+
+	started := m.Start(context.Background(), "test", "user-1", func(ctx context.Context, p *Progress) error {
+		close(running)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	<-running
+
+	_, err := m.Cancel(started.ID)
+	require.NoError(t, err)
+
+	op := waitFor(t, m, started.ID)
+	assert.Equal(t, StatusCancelled, op.Status)
+	assert.Empty(t, op.Error)
+}
+
+func TestManager_DetachedFromRequestContext(t *testing.T) {
+	m := NewManager(zap.NewNop())
+	reqCtx, cancelReq := context.WithCancel(context.Background())
+	release := make(chan struct{})
+
+	started := m.Start(reqCtx, "test", "user-1", func(ctx context.Context, p *Progress) error {
+		<-release
+		return ctx.Err()
+	})
+	cancelReq()
+	close(release)
+
+	op := waitFor(t, m, started.ID)
+	assert.Equal(t, StatusSucceeded, op.Status)
+}
+
+func TestManager_NotFound(t *testing.T) {
+	m := NewManager(zap.NewNop())
+
+	_, err := m.Get("missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = m.Cancel("missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = m.Wait(context.Background(), "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestManager_MaxResults(t *testing.T) {
+	m := NewManager(zap.NewNop(), WithMaxResults(2))
+
+	started := m.Start(context.Background(), "test", "user-1", func(ctx context.Context, p *Progress) error {
+		p.Advance(3, "a", "b", "c")
+		return nil
+	})
+
+	op := waitFor(t, m, started.ID)
+	assert.Equal(t, 3, op.Completed)
+	assert.Equal(t, []string{"b", "c"}, op.Results)
+}
+
+func TestManager_PrunesFinishedOperations(t *testing.T) {
+	m := NewManager(zap.NewNop(), WithRetention(time.Minute))
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	first := m.Start(context.Background(), "test", "user-1", func(ctx context.Context, p *Progress) error { return nil })
+	waitFor(t, m, first.ID)
+
+	now = now.Add(2 * time.Minute)
+	m.Start(context.Background(), "test", "user-1", func(ctx context.Context, p *Progress) error { return nil })
+
+	_, err := m.Get(first.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestManager_StartExclusive(t *testing.T) {
+	m := NewManager(zap.NewNop())
+	release := make(chan struct{})
+
+	first, started := m.StartExclusive(context.Background(), "maintenance", "system", func(ctx context.Context, p *Progress) error {
+		<-release
+		return nil
+	})
+	require.True(t, started)
+
+	second, started := m.StartExclusive(context.Background(), "maintenance", "admin-1", func(ctx context.Context, p *Progress) error {
+		t.Error("second operation must not run")
+		return nil
+	})
+	assert.False(t, started)
+	assert.Equal(t, first.ID, second.ID)
+
+	close(release)
+	waitFor(t, m, first.ID)
+
+	third, started := m.StartExclusive(context.Background(), "maintenance", "system", func(ctx context.Context, p *Progress) error {
+		return nil
+	})
+	assert.True(t, started)
+	assert.NotEqual(t, first.ID, third.ID)
+	waitFor(t, m, third.ID)
+}
+
+func TestManager_List(t *testing.T) {
+	m := NewManager(zap.NewNop())
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	a := m.Start(context.Background(), "a", "user-1", func(ctx context.Context, p *Progress) error { return nil })
+	waitFor(t, m, a.ID)
+	now = now.Add(time.Second)
+	b := m.Start(context.Background(), "b", "user-1", func(ctx context.Context, p *Progress) error { return nil })
+	waitFor(t, m, b.ID)
+
+	all := m.List("")
+	require.Len(t, all, 2)
+	assert.Equal(t, b.ID, all[0].ID)
+	assert.Equal(t, a.ID, all[1].ID)
+
+	onlyA := m.List("a")
+	require.Len(t, onlyA, 1)
+	assert.Equal(t, a.ID, onlyA[0].ID)
+	assert.Empty(t, m.List("c"))
+}
+
+func TestManager_Workers(t *testing.T) {
+	m := NewManager(zap.NewNop(), WithWorkers(1))
+	release := make(chan struct{})
+
+	first := m.Start(context.Background(), "test", "user-1", func(ctx context.Context, p *Progress) error {
+		<-release
+		return nil
+	})
+	assert.Equal(t, StatusRunning, first.Status)
+	assert.NotNil(t, first.StartedAt)
+
+	second := m.Start(context.Background(), "test", "user-1", func(ctx context.Context, p *Progress) error { return nil })
+	assert.Equal(t, StatusQueued, second.Status)
+	assert.Nil(t, second.StartedAt)
+	assert.False(t, second.Finished())
+
+	// Cancelling a queued operation never runs it
+	third := m.Start(context.Background(), "test", "user-1", func(ctx context.Context, p *Progress) error {
+		t.Error("cancelled operation must not run")
+		return nil
+	})
+	_, err := m.Cancel(third.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusCancelled, waitFor(t, m, third.ID).Status)
+
+	close(release)
+	assert.Equal(t, StatusSucceeded, waitFor(t, m, first.ID).Status)
+	op := waitFor(t, m, second.ID)
+	assert.Equal(t, StatusSucceeded, op.Status)
+	assert.NotNil(t, op.StartedAt)
+}
+
+func TestManager_Watch(t *testing.T) {
+	m := NewManager(zap.NewNop())
+	step := make(chan struct{})
+	op := m.Start(context.Background(), "test", "user-1", func(ctx context.Context, progress *Progress) error {
+		progress.SetTotal(2)
+		<-step
+		progress.Advance(1, "a")
+		<-step
+		progress.Advance(1, "b")
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	updates, err := m.Watch(ctx, op.ID)
+	require.NoError(t, err)
+
+	// The operation waits for a step, so the first snapshot is unfinished
+	last := <-updates
+	assert.False(t, last.Finished())
+	go func() {
+		step <- struct{}{}
+		step <- struct{}{}
+	}()
+
+	// Snapshots may be coalesced but never go backwards
+	for update := range updates {
+		assert.GreaterOrEqual(t, update.Completed, last.Completed)
+		last = update
+	}
+	require.NoError(t, ctx.Err())
+	assert.Equal(t, StatusSucceeded, last.Status)
+	assert.Equal(t, []string{"a", "b"}, last.Results)
+
+	_, err = m.Watch(ctx, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
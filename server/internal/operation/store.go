@@ -0,0 +1,134 @@
+package operation
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/database"
+	"github.com/cshum/imagor-studio/server/internal/model"
+	"github.com/uptrace/bun"
+	"go.uber.org/zap"
+)
+
+type store struct {
+	db     *bun.DB
+	logger *zap.Logger
+}
+
+// NewStore creates a Store persisting operations to the jobs table
+func NewStore(db *bun.DB, logger *zap.Logger) Store {
+	return &store{db: db, logger: logger}
+}
+
+func (s *store) Save(ctx context.Context, op Operation, heartbeatAt time.Time) error {
+	results, err := json.Marshal(op.Results)
+	if err != nil {
+		return fmt.Errorf("error encoding operation results: %w", err)
+	}
+	if op.Results == nil {
+		results = []byte("[]")
+	}
+	row := &model.Job{
+		ID:          op.ID,
+		Kind:        op.Kind,
+		OwnerID:     op.OwnerID,
+		Status:      string(op.Status),
+		Completed:   op.Completed,
+		Total:       op.Total,
+		Message:     op.Message,
+		Error:       op.Error,
+		Results:     string(results),
+		CreatedAt:   op.CreatedAt,
+		UpdatedAt:   op.UpdatedAt,
+		StartedAt:   op.StartedAt,
+		FinishedAt:  op.FinishedAt,
+		HeartbeatAt: heartbeatAt,
+	}
+	if _, err := database.OnConflictUpdate(s.db.NewInsert().Model(row), "id",
+		"kind", "owner_id", "status", "completed", "total", "message", "error", "results",
+		"created_at", "updated_at", "started_at", "finished_at", "heartbeat_at").Exec(ctx); err != nil {
+		return fmt.Errorf("error saving operation: %w", err)
+	}
+	return nil
+}
+
+func (s *store) Get(ctx context.Context, id string) (*Operation, error) {
+	var row model.Job
+	err := s.db.NewSelect().Model(&row).Where("id = ?", id).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting operation: %w", err)
+	}
+	op := s.toOperation(row)
+	return &op, nil
+}
+
+func (s *store) List(ctx context.Context, kind string, limit int) ([]Operation, error) {
+	var rows []model.Job
+	q := s.db.NewSelect().Model(&rows).Order("created_at DESC").Limit(limit)
+	if kind != "" {
+		q = q.Where("kind = ?", kind)
+	}
+	if err := q.Scan(ctx); err != nil {
+		return nil, fmt.Errorf("error listing operations: %w", err)
+	}
+	ops := make([]Operation, len(rows))
+	for i, row := range rows {
+		ops[i] = s.toOperation(row)
+	}
+	return ops, nil
+}
+
+func (s *store) FailStale(ctx context.Context, before time.Time, message string) (int, error) {
+	now := time.Now()
+	res, err := s.db.NewUpdate().Model((*model.Job)(nil)).
+		Set("status = ?", string(StatusFailed)).
+		Set("error = ?", message).
+		Set("updated_at = ?", now).
+		Set("finished_at = ?", now).
+		Where("status IN (?)", bun.In([]string{string(StatusQueued), string(StatusRunning)})).
+		Where("heartbeat_at < ?", before).
+		Exec(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error failing stale operations: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+func (s *store) DeleteFinishedBefore(ctx context.Context, before time.Time) error {
+	if _, err := s.db.NewDelete().Model((*model.Job)(nil)).
+		Where("finished_at IS NOT NULL").
+		Where("finished_at < ?", before).
+		Exec(ctx); err != nil {
+		return fmt.Errorf("error pruning operations: %w", err)
+	}
+	return nil
+}
+
+func (s *store) toOperation(row model.Job) Operation {
+	op := Operation{
+		ID:         row.ID,
+		Kind:       row.Kind,
+		OwnerID:    row.OwnerID,
+		Status:     Status(row.Status),
+		Completed:  row.Completed,
+		Total:      row.Total,
+		Message:    row.Message,
+		Error:      row.Error,
+		CreatedAt:  row.CreatedAt,
+		UpdatedAt:  row.UpdatedAt,
+		StartedAt:  row.StartedAt,
+		FinishedAt: row.FinishedAt,
+	}
+	if err := json.Unmarshal([]byte(row.Results), &op.Results); err != nil {
+		s.logger.Warn("Invalid persisted operation results", zap.String("id", row.ID), zap.Error(err))
+	}
+	return op
+}
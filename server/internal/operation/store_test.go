@@ -0,0 +1,99 @@
+package operation
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func setupTestStore(t *testing.T) Store {
+	t.Helper()
+	db := testutil.NewDB(t)
+
+	return NewStore(db, zap.NewNop())
+}
+
+func TestManager_PersistsOperations(t *testing.T) {
+	s := setupTestStore(t)
+	m := NewManager(zap.NewNop(), WithStore(s))
+
+	started := m.Start(context.Background(), "test", "user-1", func(ctx context.Context, p *Progress) error {
+		p.SetTotal(2)
+		p.Advance(2, "a", "b")
+		return errors.New("disk full")
+	})
+	waitFor(t, m, started.ID)
+
+	// Another replica sharing the store sees the outcome
+	other := NewManager(zap.NewNop(), WithStore(s))
+	op, err := other.Get(started.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusFailed, op.Status)
+	assert.Equal(t, "disk full", op.Error)
+	assert.Equal(t, 2, op.Completed)
+	assert.Equal(t, []string{"a", "b"}, op.Results)
+	assert.NotNil(t, op.StartedAt)
+	assert.NotNil(t, op.FinishedAt)
+
+	ops := other.List("test")
+	require.Len(t, ops, 1)
+	assert.Equal(t, started.ID, ops[0].ID)
+	assert.Empty(t, other.List("other"))
+
+	_, err = other.Get("missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestManager_SweepFailsAbandonedOperations(t *testing.T) {
+	s := setupTestStore(t)
+	now := time.Now()
+	ctx := context.Background()
+
+	// Left running by a replica that stopped
+	require.NoError(t, s.Save(ctx, Operation{ID: "abandoned", Kind: "test", Status: StatusRunning, CreatedAt: now, UpdatedAt: now}, now))
+	m := NewManager(zap.NewNop(), WithStore(s), WithStaleAfter(time.Minute))
+	_, err := m.Cancel("abandoned")
+	assert.ErrorIs(t, err, ErrRemote)
+
+	release := make(chan struct{})
+	defer close(release)
+	running := m.Start(ctx, "test", "user-1", func(ctx context.Context, p *Progress) error {
+		<-release
+		return nil
+	})
+
+	m.now = func() time.Time { return now.Add(2 * time.Minute) }
+	require.NoError(t, m.Sweep())
+
+	op, err := m.Get("abandoned")
+	require.NoError(t, err)
+	assert.Equal(t, StatusFailed, op.Status)
+	assert.Contains(t, op.Error, "interrupted")
+
+	// Operations running here keep their heartbeat
+	persisted, err := s.Get(ctx, running.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusRunning, persisted.Status)
+}
+
+func TestStore_DeleteFinishedBefore(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+	now := time.Now()
+	old := now.Add(-2 * time.Hour)
+
+	require.NoError(t, s.Save(ctx, Operation{ID: "old", Kind: "test", Status: StatusSucceeded, CreatedAt: old, UpdatedAt: old, FinishedAt: &old}, old))
+	require.NoError(t, s.Save(ctx, Operation{ID: "recent", Kind: "test", Status: StatusSucceeded, CreatedAt: now, UpdatedAt: now, FinishedAt: &now}, now))
+	require.NoError(t, s.DeleteFinishedBefore(ctx, now.Add(-time.Hour)))
+
+	ops, err := s.List(ctx, "", 10)
+	require.NoError(t, err)
+	require.Len(t, ops, 1)
+	assert.Equal(t, "recent", ops[0].ID)
+}
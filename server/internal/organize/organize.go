@@ -0,0 +1,151 @@
+// Package organize plans moving photos into folders named after their
+// metadata, such as 2024/05/Canon EOS R5 for the template
+// {year}/{month}/{camera}. Plans are listed for review before any file is
+// moved.
+package organize
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/autoupload"
+)
+
+const (
+	// UnknownCamera names the {camera}, {make} and {model} folders of
+	// photos without camera metadata
+	UnknownCamera = "Unknown Camera"
+	// maxNames bounds the names tried for a file whose name is taken
+	maxNames = 100
+)
+
+// ErrInvalidTemplate is returned for templates that do not name a folder
+var ErrInvalidTemplate = errors.New("invalid organize template")
+
+// placeholders lists the placeholders of templates
+var placeholders = []string{"{year}", "{month}", "{day}", "{camera}", "{make}", "{model}"}
+
+// Template names the folder of a file from its metadata
+type Template string
+
+// ParseTemplate validates a template. Placeholders are {year}, {month} and
+// {day} of the capture date, {camera} for the make and model, {make} and
+// {model}.
+func ParseTemplate(s string) (Template, error) {
+	s = strings.Trim(strings.TrimSpace(s), "/")
+	if s == "" {
+		return "", fmt.Errorf("%w: template is empty", ErrInvalidTemplate)
+	}
+	rest := s
+	for _, p := range placeholders {
+		rest = strings.ReplaceAll(rest, p, "")
+	}
+	if strings.ContainsAny(rest, "{}") {
+		return "", fmt.Errorf("%w: unknown placeholder in %q", ErrInvalidTemplate, s)
+	}
+	for _, segment := range strings.Split(s, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return "", fmt.Errorf("%w: %q", ErrInvalidTemplate, s)
+		}
+	}
+	return Template(s), nil
+}
+
+// File is a file to organize with the metadata the template reads
+type File struct {
+	Path string
+	// CapturedAt is the capture time in the zone it was taken, or the
+	// modified time of files without one
+	CapturedAt time.Time
+	Make       string
+	Model      string
+	// Video is the video of a Live Photo, moved along under the same name
+	Video string
+}
+
+// Folder returns the folder of file, relative to the organized folder
+func (t Template) Folder(file File) string {
+	return strings.NewReplacer(
+		"{year}", file.CapturedAt.Format("2006"),
+		"{month}", file.CapturedAt.Format("01"),
+		"{day}", file.CapturedAt.Format("02"),
+		"{camera}", Camera(file.Make, file.Model),
+		"{make}", folderName(file.Make),
+		"{model}", folderName(file.Model),
+	).Replace(string(t))
+}
+
+// Camera returns the folder name of a camera, the model alone when it
+// already starts with the make as in Canon EOS R5
+func Camera(cameraMake, model string) string {
+	cameraMake, model = strings.TrimSpace(cameraMake), strings.TrimSpace(model)
+	if cameraMake != "" && model != "" && !strings.HasPrefix(strings.ToLower(model), strings.ToLower(cameraMake)) {
+		model = cameraMake + " " + model
+	} else if model == "" {
+		model = cameraMake
+	}
+	return folderName(model)
+}
+
+// folderName makes a metadata value safe as a folder name
+func folderName(value string) string {
+	value = strings.Map(func(r rune) rune {
+		if r < ' ' || r == '/' || r == '\\' || r == 0x7f {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(value))
+	if strings.Trim(value, ". ") == "" {
+		return UnknownCamera
+	}
+	return value
+}
+
+// Move is a planned move of a file
+type Move struct {
+	Source string
+	Dest   string
+}
+
+// Plan returns the moves placing files in the folders template names below
+// dest, in source path order. Files already in place are left out. Names
+// taken, by files that exist or by earlier moves, get a number as in
+// IMG_0001 (2).JPG; files for which no name is free are left out as well.
+func Plan(files []File, template Template, dest string, exists func(path string) bool) []Move {
+	files = append([]File(nil), files...)
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	taken := make(map[string]bool)
+	free := func(p string) bool {
+		return !taken[p] && !exists(p)
+	}
+	var moves []Move
+	for _, file := range files {
+		folder := path.Join(dest, template.Folder(file))
+		name := path.Base(file.Path)
+		if path.Join(folder, name) == file.Path {
+			continue
+		}
+		for n := 1; n <= maxNames; n++ {
+			candidate := path.Join(folder, autoupload.Candidate(name, n))
+			var video string
+			if file.Video != "" {
+				video = strings.TrimSuffix(candidate, path.Ext(candidate)) + path.Ext(file.Video)
+			}
+			if !free(candidate) || (video != "" && !free(video)) {
+				continue
+			}
+			taken[candidate] = true
+			moves = append(moves, Move{Source: file.Path, Dest: candidate})
+			if video != "" {
+				taken[video] = true
+				moves = append(moves, Move{Source: file.Video, Dest: video})
+			}
+			break
+		}
+	}
+	return moves
+}
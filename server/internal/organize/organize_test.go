@@ -0,0 +1,61 @@
+package organize
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTemplate(t *testing.T) {
+	tmpl, err := ParseTemplate(" /{year}/{month}/{camera}/ ")
+	require.NoError(t, err)
+	assert.Equal(t, Template("{year}/{month}/{camera}"), tmpl)
+
+	for _, invalid := range []string{"", "/", "{year}/{device}", "{year", "../{year}", "{year}//{month}"} {
+		_, err := ParseTemplate(invalid)
+		assert.ErrorIs(t, err, ErrInvalidTemplate, invalid)
+	}
+}
+
+func TestFolder(t *testing.T) {
+	capturedAt := time.Date(2024, 5, 1, 23, 30, 0, 0, time.FixedZone("", -7*3600))
+	tmpl := Template("{year}/{month}/{day}/{camera}")
+	assert.Equal(t, "2024/05/01/Canon EOS R5", tmpl.Folder(File{CapturedAt: capturedAt, Make: "Canon", Model: "Canon EOS R5"}))
+	assert.Equal(t, "2024/05/01/Apple iPhone 15 Pro", tmpl.Folder(File{CapturedAt: capturedAt, Make: "Apple", Model: "iPhone 15 Pro"}))
+	assert.Equal(t, "2024/05/01/Unknown Camera", tmpl.Folder(File{CapturedAt: capturedAt}))
+	assert.Equal(t, "FUJIFILM/X-T5_II", Template("{make}/{model}").Folder(File{Make: " FUJIFILM ", Model: "X-T5/II"}))
+}
+
+func TestPlan(t *testing.T) {
+	day := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	files := []File{
+		{Path: "import/b/IMG_0001.JPG", CapturedAt: day, Model: "Canon EOS R5"},
+		{Path: "import/a/IMG_0001.JPG", CapturedAt: day, Model: "Canon EOS R5"},
+		{Path: "import/IMG_0002.JPG", CapturedAt: day, Model: "Canon EOS R5"},
+		{Path: "import/2024/Canon EOS R5/IMG_0003.JPG", CapturedAt: day, Model: "Canon EOS R5"},
+		{Path: "import/IMG_0004.JPG", CapturedAt: day.AddDate(1, 0, 0)},
+	}
+	existing := map[string]bool{"import/2024/Canon EOS R5/IMG_0002.JPG": true}
+	moves := Plan(files, "{year}/{camera}", "import", func(p string) bool { return existing[p] })
+	assert.Equal(t, []Move{
+		{Source: "import/IMG_0002.JPG", Dest: "import/2024/Canon EOS R5/IMG_0002 (2).JPG"},
+		{Source: "import/IMG_0004.JPG", Dest: "import/2025/Unknown Camera/IMG_0004.JPG"},
+		{Source: "import/a/IMG_0001.JPG", Dest: "import/2024/Canon EOS R5/IMG_0001.JPG"},
+		{Source: "import/b/IMG_0001.JPG", Dest: "import/2024/Canon EOS R5/IMG_0001 (2).JPG"},
+	}, moves)
+
+	// Videos of Live Photos move along under the same name
+	moves = Plan([]File{
+		{Path: "import/IMG_0005.HEIC", CapturedAt: day, Video: "import/IMG_0005.MOV"},
+	}, "{year}", "", func(p string) bool { return existing[p] || p == "2024/IMG_0005.MOV" })
+	assert.Equal(t, []Move{
+		{Source: "import/IMG_0005.HEIC", Dest: "2024/IMG_0005 (2).HEIC"},
+		{Source: "import/IMG_0005.MOV", Dest: "2024/IMG_0005 (2).MOV"},
+	}, moves)
+
+	// Organized into another folder
+	moves = Plan(files[:1], "{year}", "", func(string) bool { return false })
+	assert.Equal(t, []Move{{Source: "import/b/IMG_0001.JPG", Dest: "2024/IMG_0001.JPG"}}, moves)
+}
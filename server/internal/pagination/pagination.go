@@ -0,0 +1,81 @@
+// Package pagination encodes the opaque cursors of paginated lists.
+//
+// A cursor is the position of the last entry of a page rather than a count
+// of the entries before it, so entries added or removed meanwhile neither
+// repeat nor skip entries on the next page, and databases seek to the
+// position with an index instead of reading and discarding the skipped
+// rows. Cursors are base64url encoded JSON, clients must pass them back
+// unchanged.
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// ErrInvalidCursor is returned for cursors that were not encoded by Encode
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Encode returns the cursor of a position
+func Encode(position any) string {
+	data, _ := json.Marshal(position)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Decode decodes a cursor returned by Encode into position
+func Decode(cursor string, position any) error {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, position); err != nil {
+		return ErrInvalidCursor
+	}
+	return nil
+}
+
+// Key is the position of an entry in a list ordered newest first, by
+// creation time then by ID
+type Key struct {
+	CreatedAt time.Time `json:"t"`
+	ID        string    `json:"i"`
+}
+
+// Cursor returns the cursor continuing a list after the entry
+func (k Key) Cursor() string {
+	return Encode(k)
+}
+
+// ParseKey decodes the cursor of a list ordered newest first, nil for an
+// empty cursor starting from the beginning
+func ParseKey(cursor string) (*Key, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	var k Key
+	if err := Decode(cursor, &k); err != nil {
+		return nil, err
+	}
+	if k.ID == "" || k.CreatedAt.IsZero() {
+		return nil, ErrInvalidCursor
+	}
+	return &k, nil
+}
+
+// After narrows q to the entries after k, for lists ordered by
+// created_at DESC, id DESC. A nil k leaves q as is.
+func (k *Key) After(q *bun.SelectQuery) *bun.SelectQuery {
+	if k == nil {
+		return q
+	}
+	createdAt := k.CreatedAt.UTC()
+	return q.WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.
+			Where("created_at < ?", createdAt).
+			WhereOr("created_at = ? AND id < ?", createdAt, k.ID)
+	})
+}
@@ -0,0 +1,39 @@
+package pagination
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	type position struct {
+		Path string `json:"p"`
+	}
+	cursor := Encode(position{Path: "photos/a.jpg"})
+	var p position
+	require.NoError(t, Decode(cursor, &p))
+	assert.Equal(t, "photos/a.jpg", p.Path)
+
+	assert.ErrorIs(t, Decode("not a cursor", &p), ErrInvalidCursor)
+	assert.ErrorIs(t, Decode(Encode("a string"), &p), ErrInvalidCursor)
+}
+
+func TestParseKey(t *testing.T) {
+	key, err := ParseKey("")
+	require.NoError(t, err)
+	assert.Nil(t, key)
+
+	createdAt := time.Date(2026, 3, 1, 12, 30, 0, 123456000, time.UTC)
+	key, err = ParseKey(Key{CreatedAt: createdAt, ID: "entry-1"}.Cursor())
+	require.NoError(t, err)
+	assert.Equal(t, "entry-1", key.ID)
+	assert.True(t, createdAt.Equal(key.CreatedAt))
+
+	_, err = ParseKey(Encode(Key{ID: "entry-1"}))
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+	_, err = ParseKey("eyJ0Ijo")
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
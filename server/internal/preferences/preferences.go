@@ -0,0 +1,138 @@
+// Package preferences keeps the UI preferences of each user: theme, default
+// sort, grid size and slideshow interval.
+//
+// Preferences are stored as a single JSON entry of the user registry and
+// validated against a fixed schema, so clients on different devices read
+// back values they all understand. Unknown fields and invalid values saved
+// by older clients read as the default instead of failing.
+package preferences
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+)
+
+// RegistryKey is the user registry key the preferences are stored under,
+// written through Save only
+const RegistryKey = "preferences"
+
+// ErrInvalid is wrapped by the errors of Validate
+var ErrInvalid = errors.New("invalid preferences")
+
+// Themes, sorts and grid sizes accepted by Validate
+var (
+	Themes     = []string{"LIGHT", "DARK", "SYSTEM"}
+	SortBys    = []string{"NAME", "SIZE", "MODIFIED_TIME", "CAPTURE_DATE", "RATING"}
+	SortOrders = []string{"ASC", "DESC"}
+	GridSizes  = []string{"SMALL", "MEDIUM", "LARGE"}
+)
+
+// Bounds of SlideshowInterval, in seconds
+const (
+	MinSlideshowInterval = 1
+	MaxSlideshowInterval = 600
+)
+
+// Preferences of a user. Empty fields are unset, clients use their default.
+type Preferences struct {
+	Theme     string `json:"theme,omitempty"`
+	SortBy    string `json:"sortBy,omitempty"`
+	SortOrder string `json:"sortOrder,omitempty"`
+	GridSize  string `json:"gridSize,omitempty"`
+	// SlideshowInterval is the seconds each slide is shown
+	SlideshowInterval int `json:"slideshowInterval,omitempty"`
+}
+
+// Validate checks every set field against the schema
+func (p Preferences) Validate() error {
+	if p.Theme != "" && !slices.Contains(Themes, p.Theme) {
+		return fmt.Errorf("%w: theme must be one of %v, got %q", ErrInvalid, Themes, p.Theme)
+	}
+	if p.SortBy != "" && !slices.Contains(SortBys, p.SortBy) {
+		return fmt.Errorf("%w: sortBy must be one of %v, got %q", ErrInvalid, SortBys, p.SortBy)
+	}
+	if p.SortOrder != "" && !slices.Contains(SortOrders, p.SortOrder) {
+		return fmt.Errorf("%w: sortOrder must be one of %v, got %q", ErrInvalid, SortOrders, p.SortOrder)
+	}
+	if p.GridSize != "" && !slices.Contains(GridSizes, p.GridSize) {
+		return fmt.Errorf("%w: gridSize must be one of %v, got %q", ErrInvalid, GridSizes, p.GridSize)
+	}
+	if p.SlideshowInterval != 0 {
+		return ValidateSlideshowInterval(p.SlideshowInterval)
+	}
+	return nil
+}
+
+// ValidateSlideshowInterval checks a slideshow interval in seconds
+func ValidateSlideshowInterval(seconds int) error {
+	if seconds < MinSlideshowInterval || seconds > MaxSlideshowInterval {
+		return fmt.Errorf("%w: slideshowInterval must be between %d and %d seconds, got %d",
+			ErrInvalid, MinSlideshowInterval, MaxSlideshowInterval, seconds)
+	}
+	return nil
+}
+
+// sanitize unsets the fields that do not validate
+func (p Preferences) sanitize() Preferences {
+	if (Preferences{Theme: p.Theme}).Validate() != nil {
+		p.Theme = ""
+	}
+	if (Preferences{SortBy: p.SortBy}).Validate() != nil {
+		p.SortBy = ""
+	}
+	if (Preferences{SortOrder: p.SortOrder}).Validate() != nil {
+		p.SortOrder = ""
+	}
+	if (Preferences{GridSize: p.GridSize}).Validate() != nil {
+		p.GridSize = ""
+	}
+	if (Preferences{SlideshowInterval: p.SlideshowInterval}).Validate() != nil {
+		p.SlideshowInterval = 0
+	}
+	return p
+}
+
+// Load returns the preferences of a user, unset when none are saved
+func Load(ctx context.Context, store registrystore.Store, userID string) (Preferences, error) {
+	entry, err := store.Get(ctx, registrystore.UserOwnerID(userID), RegistryKey)
+	if err != nil {
+		return Preferences{}, fmt.Errorf("failed to get preferences: %w", err)
+	}
+	if entry == nil {
+		return Preferences{}, nil
+	}
+	var p Preferences
+	if err := json.Unmarshal([]byte(entry.Value), &p); err != nil {
+		// Unreadable preferences are reset rather than locking the user out
+		return Preferences{}, nil
+	}
+	return p.sanitize(), nil
+}
+
+// Save validates and replaces the preferences of a user, saving unset
+// preferences removes them
+func Save(ctx context.Context, store registrystore.Store, userID string, p Preferences) (Preferences, error) {
+	if err := p.Validate(); err != nil {
+		return Preferences{}, err
+	}
+	ownerID := registrystore.UserOwnerID(userID)
+	if p == (Preferences{}) {
+		if err := store.Delete(ctx, ownerID, RegistryKey); err != nil {
+			return Preferences{}, fmt.Errorf("failed to delete preferences: %w", err)
+		}
+		return p, nil
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		return Preferences{}, err
+	}
+	if _, err := store.Set(ctx, ownerID, RegistryKey, string(data), false); err != nil {
+		return Preferences{}, fmt.Errorf("failed to save preferences: %w", err)
+	}
+	return p, nil
+}
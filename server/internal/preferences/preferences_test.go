@@ -0,0 +1,95 @@
+package preferences
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryRegistryStore keeps the registry in memory
+type memoryRegistryStore struct {
+	registrystore.Store
+	data map[string]string
+}
+
+func (m *memoryRegistryStore) Get(_ context.Context, ownerID, key string) (*registrystore.Registry, error) {
+	if value, ok := m.data[ownerID+"/"+key]; ok {
+		return &registrystore.Registry{Key: key, Value: value}, nil
+	}
+	return nil, nil
+}
+
+func (m *memoryRegistryStore) Set(_ context.Context, ownerID, key, value string, _ bool) (*registrystore.Registry, error) {
+	m.data[ownerID+"/"+key] = value
+	return &registrystore.Registry{Key: key, Value: value}, nil
+}
+
+func (m *memoryRegistryStore) Delete(_ context.Context, ownerID, key string) error {
+	delete(m.data, ownerID+"/"+key)
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Preferences{}.Validate())
+	assert.NoError(t, Preferences{Theme: "DARK", SortBy: "RATING", SortOrder: "DESC", GridSize: "LARGE", SlideshowInterval: 5}.Validate())
+
+	for _, invalid := range []Preferences{
+		{Theme: "dark"},
+		{SortBy: "COLOR"},
+		{SortOrder: "UP"},
+		{GridSize: "HUGE"},
+		{SlideshowInterval: -1},
+		{SlideshowInterval: MaxSlideshowInterval + 1},
+	} {
+		err := invalid.Validate()
+		assert.ErrorIs(t, err, ErrInvalid, "%+v", invalid)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryRegistryStore{data: map[string]string{}}
+
+	p, err := Load(ctx, store, "u1")
+	require.NoError(t, err)
+	assert.Equal(t, Preferences{}, p)
+
+	saved := Preferences{Theme: "LIGHT", GridSize: "SMALL", SlideshowInterval: 10}
+	_, err = Save(ctx, store, "u1", saved)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"theme":"LIGHT","gridSize":"SMALL","slideshowInterval":10}`, store.data["user:u1/"+RegistryKey])
+
+	p, err = Load(ctx, store, "u1")
+	require.NoError(t, err)
+	assert.Equal(t, saved, p)
+
+	p, err = Load(ctx, store, "u2")
+	require.NoError(t, err)
+	assert.Equal(t, Preferences{}, p)
+
+	_, err = Save(ctx, store, "u1", Preferences{Theme: "PINK"})
+	assert.ErrorIs(t, err, ErrInvalid)
+
+	_, err = Save(ctx, store, "u1", Preferences{})
+	require.NoError(t, err)
+	assert.NotContains(t, store.data, "user:u1/"+RegistryKey)
+}
+
+func TestLoadSanitizes(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryRegistryStore{data: map[string]string{
+		"user:u1/" + RegistryKey: `{"theme":"DARK","sortBy":"COLOR","gridSize":"MEDIUM","slideshowInterval":9999,"extra":true}`,
+		"user:u2/" + RegistryKey: `not json`,
+	}}
+
+	p, err := Load(ctx, store, "u1")
+	require.NoError(t, err)
+	assert.Equal(t, Preferences{Theme: "DARK", GridSize: "MEDIUM"}, p)
+
+	p, err = Load(ctx, store, "u2")
+	require.NoError(t, err)
+	assert.Equal(t, Preferences{}, p)
+}
@@ -0,0 +1,242 @@
+// Package querylimit bounds the cost of GraphQL operations.
+//
+// Operations whose complexity or selection depth exceed the limits are
+// rejected before they execute, and queries and mutations running longer
+// than the timeout have their context canceled, which stops the storage
+// listings they wait on. The limits are stored in the system registry so
+// every replica enforces the same ones, zero disables a limit. Admins are
+// not limited, so an administrator can always lift limits set too low.
+package querylimit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/99designs/gqlgen/complexity"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/pkg/auth"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+// Key is the registry key holding the limits
+const Key = "config.graphql_query_limits"
+
+// Error codes of refused and timed out operations
+const (
+	CodeComplexityLimit = "COMPLEXITY_LIMIT_EXCEEDED"
+	CodeDepthLimit      = "DEPTH_LIMIT_EXCEEDED"
+	CodeTimeout         = "OPERATION_TIMEOUT"
+)
+
+// ErrNegativeLimit is returned for negative limits
+var ErrNegativeLimit = errors.New("query limits must not be negative")
+
+// Limits of a GraphQL operation, zero for no limit
+type Limits struct {
+	// MaxComplexity bounds the complexity computed by gqlgen, one per
+	// selected field unless the schema says otherwise
+	MaxComplexity int `json:"maxComplexity,omitempty"`
+	// MaxDepth bounds the nesting of selections, introspection excluded
+	MaxDepth int `json:"maxDepth,omitempty"`
+	// TimeoutSeconds bounds the run time of queries and mutations
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+}
+
+// Timeout returns the run time limit of queries and mutations
+func (l Limits) Timeout() time.Duration {
+	return time.Duration(l.TimeoutSeconds) * time.Second
+}
+
+// Validate checks that no limit is negative
+func (l Limits) Validate() error {
+	if l.MaxComplexity < 0 || l.MaxDepth < 0 || l.TimeoutSeconds < 0 {
+		return ErrNegativeLimit
+	}
+	return nil
+}
+
+// Store caches the limits of the system registry
+type Store struct {
+	registryStore registrystore.Store
+	logger        *zap.Logger
+
+	mu     sync.RWMutex
+	limits Limits
+}
+
+// New creates a limit store, call Sync to load the stored limits
+func New(registryStore registrystore.Store, logger *zap.Logger) *Store {
+	return &Store{registryStore: registryStore, logger: logger}
+}
+
+// Sync reloads the limits from the registry, so changes made on other
+// replicas take effect
+func (s *Store) Sync() error {
+	return s.Load(context.Background())
+}
+
+// Load reads the limits from the registry
+func (s *Store) Load(ctx context.Context) error {
+	entry, err := s.registryStore.Get(ctx, registrystore.SystemOwnerID, Key)
+	if err != nil {
+		return fmt.Errorf("failed to load GraphQL query limits: %w", err)
+	}
+	var limits Limits
+	if entry != nil {
+		if err := json.Unmarshal([]byte(entry.Value), &limits); err != nil || limits.Validate() != nil {
+			s.logger.Warn("Ignoring malformed GraphQL query limits", zap.String("key", Key), zap.Error(err))
+			limits = Limits{}
+		}
+	}
+	s.mu.Lock()
+	s.limits = limits
+	s.mu.Unlock()
+	return nil
+}
+
+// Get returns the current limits
+func (s *Store) Get() Limits {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.limits
+}
+
+// Set replaces the limits
+func (s *Store) Set(ctx context.Context, limits Limits) error {
+	if err := limits.Validate(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(limits)
+	if err != nil {
+		return err
+	}
+	if _, err := s.registryStore.Set(ctx, registrystore.SystemOwnerID, Key, string(data), false); err != nil {
+		return fmt.Errorf("failed to save GraphQL query limits: %w", err)
+	}
+	s.mu.Lock()
+	s.limits = limits
+	s.mu.Unlock()
+	return nil
+}
+
+// Extension returns the gqlgen handler extension refusing operations over
+// the complexity and depth limits
+func (s *Store) Extension() graphql.HandlerExtension {
+	return &extension{store: s}
+}
+
+// ResponseMiddleware cancels queries and mutations running longer than
+// the timeout. Subscriptions stay open.
+func (s *Store) ResponseMiddleware() graphql.ResponseMiddleware {
+	return func(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+		timeout := s.Get().Timeout()
+		if timeout <= 0 || exempt(ctx) {
+			return next(ctx)
+		}
+		if opCtx := graphql.GetOperationContext(ctx); opCtx == nil || opCtx.Operation == nil ||
+			opCtx.Operation.Operation == ast.Subscription {
+			return next(ctx)
+		}
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		resp := next(ctx)
+		if resp != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err := gqlerror.Errorf("operation exceeded the timeout of %s", timeout)
+			errcode.Set(err, CodeTimeout)
+			resp.Errors = append(resp.Errors, err)
+		}
+		return resp
+	}
+}
+
+type extension struct {
+	store *Store
+	es    graphql.ExecutableSchema
+}
+
+var _ interface {
+	graphql.OperationContextMutator
+	graphql.HandlerExtension
+} = &extension{}
+
+func (e *extension) ExtensionName() string {
+	return "QueryLimit"
+}
+
+func (e *extension) Validate(schema graphql.ExecutableSchema) error {
+	e.es = schema
+	return nil
+}
+
+func (e *extension) MutateOperationContext(ctx context.Context, opCtx *graphql.OperationContext) *gqlerror.Error {
+	limits := e.store.Get()
+	if (limits.MaxComplexity <= 0 && limits.MaxDepth <= 0) || exempt(ctx) {
+		return nil
+	}
+	op := opCtx.Doc.Operations.ForName(opCtx.OperationName)
+	if op == nil {
+		return nil
+	}
+	if limits.MaxDepth > 0 {
+		if depth := Depth(op.SelectionSet); depth > limits.MaxDepth {
+			err := gqlerror.Errorf("operation has depth %d, which exceeds the limit of %d", depth, limits.MaxDepth)
+			errcode.Set(err, CodeDepthLimit)
+			return err
+		}
+	}
+	if limits.MaxComplexity > 0 {
+		if c := complexity.Calculate(ctx, e.es, op, opCtx.Variables); c > limits.MaxComplexity {
+			err := gqlerror.Errorf("operation has complexity %d, which exceeds the limit of %d", c, limits.MaxComplexity)
+			errcode.Set(err, CodeComplexityLimit)
+			return err
+		}
+	}
+	return nil
+}
+
+// Depth returns the deepest nesting of fields in a selection set, fragments
+// expanded. Introspection fields do not count, the introspection query of
+// GraphQL tools nests deeply.
+func Depth(selectionSet ast.SelectionSet) int {
+	return depth(selectionSet, nil)
+}
+
+func depth(selectionSet ast.SelectionSet, visited []string) int {
+	deepest := 0
+	for _, selection := range selectionSet {
+		var d int
+		switch s := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(s.Name, "__") {
+				continue
+			}
+			d = 1 + depth(s.SelectionSet, visited)
+		case *ast.InlineFragment:
+			d = depth(s.SelectionSet, visited)
+		case *ast.FragmentSpread:
+			// Validation rejects fragment cycles, guard anyway
+			if s.Definition == nil || slices.Contains(visited, s.Name) {
+				continue
+			}
+			d = depth(s.Definition.SelectionSet, append(visited[:len(visited):len(visited)], s.Name))
+		}
+		deepest = max(deepest, d)
+	}
+	return deepest
+}
+
+// exempt reports whether the caller is an admin
+func exempt(ctx context.Context) bool {
+	claims, err := auth.GetClaimsFromContext(ctx)
+	return err == nil && slices.Contains(claims.Scopes, "admin")
+}
@@ -0,0 +1,197 @@
+package querylimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/pkg/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+// mockRegistryStore is an in-memory registry store for a single owner
+type mockRegistryStore struct {
+	data map[string]string
+}
+
+func newMockRegistryStore() *mockRegistryStore {
+	return &mockRegistryStore{data: make(map[string]string)}
+}
+
+func (m *mockRegistryStore) List(ctx context.Context, ownerID string, prefix *string) ([]*registrystore.Registry, error) {
+	return nil, nil
+}
+
+func (m *mockRegistryStore) Get(ctx context.Context, ownerID, key string) (*registrystore.Registry, error) {
+	if value, ok := m.data[key]; ok {
+		return &registrystore.Registry{Key: key, Value: value}, nil
+	}
+	return nil, nil
+}
+
+func (m *mockRegistryStore) GetMulti(ctx context.Context, ownerID string, keys []string) ([]*registrystore.Registry, error) {
+	var result []*registrystore.Registry
+	for _, key := range keys {
+		if value, ok := m.data[key]; ok {
+			result = append(result, &registrystore.Registry{Key: key, Value: value})
+		}
+	}
+	return result, nil
+}
+
+func (m *mockRegistryStore) Set(ctx context.Context, ownerID, key, value string, isEncrypted bool) (*registrystore.Registry, error) {
+	m.data[key] = value
+	return &registrystore.Registry{Key: key, Value: value}, nil
+}
+
+func (m *mockRegistryStore) SetMulti(ctx context.Context, ownerID string, entries []*registrystore.Registry) ([]*registrystore.Registry, error) {
+	for _, entry := range entries {
+		m.data[entry.Key] = entry.Value
+	}
+	return entries, nil
+}
+
+func (m *mockRegistryStore) Delete(ctx context.Context, ownerID, key string) error {
+	delete(m.data, key)
+	return nil
+}
+
+func (m *mockRegistryStore) DeleteMulti(ctx context.Context, ownerID string, keys []string) error {
+	for _, key := range keys {
+		delete(m.data, key)
+	}
+	return nil
+}
+
+const listingQuery = `
+query Listing {
+  listFiles(path: "") {
+    totalCount
+    items { ...File }
+  }
+}
+fragment File on FileItem {
+  name
+  thumbnailUrls { grid preview }
+}`
+
+func parseOperation(t *testing.T, es graphql.ExecutableSchema, query string) *graphql.OperationContext {
+	t.Helper()
+	doc, errs := gqlparser.LoadQuery(es.Schema(), query)
+	require.Nil(t, errs)
+	return &graphql.OperationContext{Doc: doc, Operation: doc.Operations[0], OperationName: doc.Operations[0].Name}
+}
+
+func userContext(scopes ...string) context.Context {
+	return auth.SetClaimsInContext(context.Background(), &auth.Claims{UserID: "user-1", Role: "user", Scopes: scopes})
+}
+
+func TestStore_SetAndLoad(t *testing.T) {
+	ctx := context.Background()
+	registry := newMockRegistryStore()
+	s := New(registry, zap.NewNop())
+	assert.Equal(t, Limits{}, s.Get())
+
+	limits := Limits{MaxComplexity: 500, MaxDepth: 8, TimeoutSeconds: 30}
+	require.NoError(t, s.Set(ctx, limits))
+	assert.Equal(t, limits, s.Get())
+	assert.Equal(t, 30*time.Second, s.Get().Timeout())
+	assert.ErrorIs(t, s.Set(ctx, Limits{MaxDepth: -1}), ErrNegativeLimit)
+	assert.Equal(t, limits, s.Get())
+
+	// Another replica loads the same limits
+	other := New(registry, zap.NewNop())
+	require.NoError(t, other.Sync())
+	assert.Equal(t, limits, other.Get())
+
+	registry.data[Key] = `{"maxDepth":-3}`
+	require.NoError(t, other.Sync())
+	assert.Equal(t, Limits{}, other.Get(), "malformed limits are ignored")
+}
+
+func TestDepth(t *testing.T) {
+	es := gql.NewExecutableSchema(gql.Config{})
+	opCtx := parseOperation(t, es, listingQuery)
+	assert.Equal(t, 4, Depth(opCtx.Operation.SelectionSet))
+
+	opCtx = parseOperation(t, es, `{ __schema { types { fields { type { ofType { name } } } } } }`)
+	assert.Equal(t, 0, Depth(opCtx.Operation.SelectionSet), "introspection does not count")
+}
+
+func TestExtension(t *testing.T) {
+	es := gql.NewExecutableSchema(gql.Config{})
+	s := New(newMockRegistryStore(), zap.NewNop())
+	ext := s.Extension().(*extension)
+	require.NoError(t, ext.Validate(es))
+
+	check := func(ctx context.Context) string {
+		if err := ext.MutateOperationContext(ctx, parseOperation(t, es, listingQuery)); err != nil {
+			return err.Extensions["code"].(string)
+		}
+		return ""
+	}
+
+	assert.Empty(t, check(userContext()), "no limits by default")
+
+	require.NoError(t, s.Set(context.Background(), Limits{MaxDepth: 3}))
+	assert.Equal(t, CodeDepthLimit, check(userContext()))
+	assert.Empty(t, check(userContext("admin")), "admins are not limited")
+
+	require.NoError(t, s.Set(context.Background(), Limits{MaxDepth: 4, MaxComplexity: 5}))
+	assert.Equal(t, CodeComplexityLimit, check(userContext()))
+
+	require.NoError(t, s.Set(context.Background(), Limits{MaxDepth: 4, MaxComplexity: 100}))
+	assert.Empty(t, check(userContext()))
+}
+
+func TestResponseMiddleware(t *testing.T) {
+	s := New(newMockRegistryStore(), zap.NewNop())
+	require.NoError(t, s.Set(context.Background(), Limits{TimeoutSeconds: 1}))
+	middleware := s.ResponseMiddleware()
+
+	slow := func(ctx context.Context) *graphql.Response {
+		select {
+		case <-ctx.Done():
+			return &graphql.Response{Errors: gqlerror.List{{Message: ctx.Err().Error()}}}
+		case <-time.After(5 * time.Second):
+			return &graphql.Response{}
+		}
+	}
+	withOperation := func(ctx context.Context, operation ast.Operation) context.Context {
+		return graphql.WithOperationContext(ctx, &graphql.OperationContext{
+			Operation: &ast.OperationDefinition{Operation: operation},
+		})
+	}
+
+	start := time.Now()
+	resp := middleware(withOperation(userContext(), ast.Query), slow)
+	assert.Less(t, time.Since(start), 3*time.Second)
+	require.Len(t, resp.Errors, 2)
+	assert.Equal(t, CodeTimeout, resp.Errors[1].Extensions["code"])
+
+	// Admins and subscriptions are not canceled
+	_, hasDeadline := deadlineOf(t, middleware, withOperation(userContext("admin"), ast.Query))
+	assert.False(t, hasDeadline)
+	_, hasDeadline = deadlineOf(t, middleware, withOperation(userContext(), ast.Subscription))
+	assert.False(t, hasDeadline)
+	deadline, hasDeadline := deadlineOf(t, middleware, withOperation(userContext(), ast.Mutation))
+	require.True(t, hasDeadline)
+	assert.WithinDuration(t, time.Now().Add(time.Second), deadline, time.Second)
+}
+
+func deadlineOf(t *testing.T, middleware graphql.ResponseMiddleware, ctx context.Context) (deadline time.Time, ok bool) {
+	t.Helper()
+	middleware(ctx, func(ctx context.Context) *graphql.Response {
+		deadline, ok = ctx.Deadline()
+		return &graphql.Response{}
+	})
+	return deadline, ok
+}
@@ -0,0 +1,133 @@
+package ratelimit
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cshum/imagor-studio/server/pkg/apperror"
+	"github.com/cshum/imagor-studio/server/pkg/auth"
+)
+
+// KeyParam is the query parameter of the image URLs issued to a session
+// carrying its signed key, so images are counted against the session that
+// listed them rather than the address fetching them
+const KeyParam = "rk"
+
+// SessionKey returns the key the requests of a session are counted under:
+// the shared link of share link sessions and the user of other sessions.
+// Admin sessions are not limited, their key is empty.
+func SessionKey(claims *auth.Claims) string {
+	if slices.Contains(claims.Scopes, "admin") {
+		return ""
+	}
+	if claims.ShareID != "" {
+		return "share:" + claims.ShareID
+	}
+	return "user:" + claims.UserID
+}
+
+// SignKey returns the KeyParam value of key signed with secret
+func SignKey(key, secret string) string {
+	return key + "." + keySignature(key, secret)
+}
+
+func keySignature(key, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("ratelimit:" + key))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// Key returns the key the requests of r are counted under: the SessionKey
+// of the session of r, else the key signed in the KeyParam of image URLs,
+// else the client address. Behind trusted proxies the remote address is
+// already the client's, see clientip.
+func (l *Limiter) Key(r *http.Request) string {
+	if claims, err := auth.GetClaimsFromContext(r.Context()); err == nil {
+		return SessionKey(claims)
+	}
+	if l.secret != "" {
+		if value := r.URL.Query().Get(KeyParam); value != "" {
+			if i := strings.LastIndexByte(value, '.'); i > 0 {
+				key := value[:i]
+				if hmac.Equal([]byte(value[i+1:]), []byte(keySignature(key, l.secret))) {
+					return key
+				}
+			}
+		}
+	}
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	return "ip:" + ip
+}
+
+// Middleware refuses requests over the limits of their Key with 429 Too
+// Many Requests and counts the bytes of the responses. A nil limiter
+// limits nothing.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := l.Key(r)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if ok, retryAfter := l.Allow(key); !ok {
+			seconds := int(math.Ceil(retryAfter.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			apperror.WriteHTTPErrorResponse(w, apperror.TooManyRequests(
+				fmt.Sprintf("Rate limit exceeded, retry in %d seconds", seconds),
+				map[string]interface{}{"retryAfterSeconds": seconds},
+			))
+			return
+		}
+		cw := &countingResponseWriter{ResponseWriter: w}
+		defer func() { l.AddBytes(key, cw.written) }()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+type countingResponseWriter struct {
+	http.ResponseWriter
+	written int64
+}
+
+func (w *countingResponseWriter) Write(data []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(data)
+	w.written += int64(n)
+	return n, err
+}
+
+// Hijack lets WebSocket upgrades through the wrapper, their traffic is not
+// counted
+func (w *countingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	return h.Hijack()
+}
+
+// Flush lets streamed responses through the wrapper
+func (w *countingResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the wrapped writer to http.ResponseController
+func (w *countingResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
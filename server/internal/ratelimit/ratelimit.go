@@ -0,0 +1,116 @@
+// Package ratelimit softly limits the requests and bandwidth of each user,
+// shared link and anonymous client, so a scraped guest link cannot saturate
+// the uplink of the server. Counters are kept in memory over fixed windows:
+// every server instance limits on its own, and a restart resets them.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	requestWindow   = time.Minute
+	bandwidthWindow = time.Hour
+)
+
+// Limits are the allowance of a key, 0 for no limit
+type Limits struct {
+	RequestsPerMinute int
+	BytesPerHour      int64
+}
+
+// Enabled reports whether any limit is set
+func (l Limits) Enabled() bool {
+	return l.RequestsPerMinute > 0 || l.BytesPerHour > 0
+}
+
+type counter struct {
+	requests      int
+	requestsReset time.Time
+	bytes         int64
+	bytesReset    time.Time
+}
+
+// expired reports whether both windows of c are over, so it can be dropped
+func (c *counter) expired(now time.Time) bool {
+	return !now.Before(c.requestsReset) && !now.Before(c.bytesReset)
+}
+
+// Limiter counts the requests and response bytes of keys
+type Limiter struct {
+	limits Limits
+	secret string
+	now    func() time.Time
+
+	mu        sync.Mutex
+	counters  map[string]*counter
+	nextSweep time.Time
+}
+
+// New creates a limiter enforcing limits. secret verifies the keys signed
+// in image URLs by SignKey, empty ignores them.
+func New(limits Limits, secret string) *Limiter {
+	return &Limiter{
+		limits:   limits,
+		secret:   secret,
+		now:      time.Now,
+		counters: make(map[string]*counter),
+	}
+}
+
+// Allow counts a request of key. It returns false with the time until the
+// key may retry when the key is over its request or bandwidth limit, in
+// which case the request is not counted.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	c := l.counter(key, now)
+	if l.limits.BytesPerHour > 0 && c.bytes >= l.limits.BytesPerHour {
+		return false, c.bytesReset.Sub(now)
+	}
+	if l.limits.RequestsPerMinute > 0 && c.requests >= l.limits.RequestsPerMinute {
+		return false, c.requestsReset.Sub(now)
+	}
+	c.requests++
+	return true, 0
+}
+
+// AddBytes counts n bytes sent to key. The bandwidth limit is soft: a
+// response is never cut short, the requests following it are refused.
+func (l *Limiter) AddBytes(key string, n int64) {
+	if n <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.counter(key, l.now()).bytes += n
+}
+
+// counter returns the counter of key with its windows reset when over,
+// called with mu held
+func (l *Limiter) counter(key string, now time.Time) *counter {
+	if !now.Before(l.nextSweep) {
+		for k, c := range l.counters {
+			if c.expired(now) {
+				delete(l.counters, k)
+			}
+		}
+		l.nextSweep = now.Add(requestWindow)
+	}
+	c, ok := l.counters[key]
+	if !ok {
+		c = &counter{}
+		l.counters[key] = c
+	}
+	if !now.Before(c.requestsReset) {
+		c.requests = 0
+		c.requestsReset = now.Add(requestWindow)
+	}
+	if !now.Before(c.bytesReset) {
+		c.bytes = 0
+		c.bytesReset = now.Add(bandwidthWindow)
+	}
+	return c
+}
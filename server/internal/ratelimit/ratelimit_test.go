@@ -0,0 +1,135 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/pkg/apperror"
+	"github.com/cshum/imagor-studio/server/pkg/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLimiter(limits Limits) (*Limiter, *time.Time) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := New(limits, "secret")
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func TestLimiter_Requests(t *testing.T) {
+	l, now := newTestLimiter(Limits{RequestsPerMinute: 2})
+
+	for i := 0; i < 2; i++ {
+		ok, _ := l.Allow("user:a")
+		assert.True(t, ok)
+	}
+	ok, retryAfter := l.Allow("user:a")
+	assert.False(t, ok)
+	assert.Equal(t, time.Minute, retryAfter)
+
+	// Keys are counted apart
+	ok, _ = l.Allow("user:b")
+	assert.True(t, ok)
+
+	*now = now.Add(time.Minute)
+	ok, _ = l.Allow("user:a")
+	assert.True(t, ok)
+}
+
+func TestLimiter_Bandwidth(t *testing.T) {
+	l, now := newTestLimiter(Limits{BytesPerHour: 100})
+
+	ok, _ := l.Allow("share:x")
+	require.True(t, ok)
+	l.AddBytes("share:x", 60)
+	ok, _ = l.Allow("share:x")
+	require.True(t, ok)
+	l.AddBytes("share:x", 60)
+
+	*now = now.Add(20 * time.Minute)
+	ok, retryAfter := l.Allow("share:x")
+	assert.False(t, ok)
+	assert.Equal(t, 40*time.Minute, retryAfter)
+
+	*now = now.Add(40 * time.Minute)
+	ok, _ = l.Allow("share:x")
+	assert.True(t, ok)
+}
+
+func TestLimiter_Sweep(t *testing.T) {
+	l, now := newTestLimiter(Limits{RequestsPerMinute: 10})
+	l.Allow("ip:1.2.3.4")
+	*now = now.Add(2 * time.Hour)
+	l.Allow("ip:5.6.7.8")
+	assert.Len(t, l.counters, 1)
+}
+
+func TestKey(t *testing.T) {
+	l, _ := newTestLimiter(Limits{RequestsPerMinute: 10})
+	req := httptest.NewRequest(http.MethodGet, "/imagor/unsafe/a.jpg", nil)
+	req.RemoteAddr = "203.0.113.7:51234"
+	assert.Equal(t, "ip:203.0.113.7", l.Key(req))
+
+	withClaims := func(claims *auth.Claims) *http.Request {
+		return req.WithContext(auth.SetClaimsInContext(req.Context(), claims))
+	}
+	assert.Equal(t, "user:u1", l.Key(withClaims(&auth.Claims{UserID: "u1", Scopes: []string{"read", "write"}})))
+	assert.Equal(t, "share:s1", l.Key(withClaims(&auth.Claims{UserID: "guest-1", Scopes: []string{"read"}, Kind: auth.ShareLinkTokenKind, ShareID: "s1"})))
+	assert.Empty(t, l.Key(withClaims(&auth.Claims{UserID: "admin-1", Scopes: []string{"read", "write", "admin"}})))
+}
+
+func TestKey_SignedParam(t *testing.T) {
+	l, _ := newTestLimiter(Limits{RequestsPerMinute: 10})
+	withParam := func(value string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/imagor/unsafe/a.jpg?"+KeyParam+"="+url.QueryEscape(value), nil)
+		req.RemoteAddr = "203.0.113.7:51234"
+		return req
+	}
+	assert.Equal(t, "share:s1", l.Key(withParam(SignKey("share:s1", "secret"))))
+	assert.Equal(t, "user:u.1", l.Key(withParam(SignKey("user:u.1", "secret"))))
+
+	// Forged or foreign keys fall back to the client address
+	assert.Equal(t, "ip:203.0.113.7", l.Key(withParam(SignKey("share:s1", "other"))))
+	assert.Equal(t, "ip:203.0.113.7", l.Key(withParam("share:s1")))
+	assert.Equal(t, "ip:203.0.113.7", l.Key(withParam(strings.Replace(SignKey("share:s1", "secret"), "s1", "s2", 1))))
+
+	// Without a secret signed keys are ignored
+	unsigned := New(Limits{RequestsPerMinute: 10}, "")
+	assert.Equal(t, "ip:203.0.113.7", unsigned.Key(withParam(SignKey("share:s1", ""))))
+}
+
+func TestMiddleware(t *testing.T) {
+	l, _ := newTestLimiter(Limits{RequestsPerMinute: 10, BytesPerHour: 10})
+	handler := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", 8)))
+	}))
+	serve := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/imagor/unsafe/a.jpg", nil)
+		req.RemoteAddr = remoteAddr
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	assert.Equal(t, http.StatusOK, serve("203.0.113.7:1").Code)
+	assert.Equal(t, http.StatusOK, serve("203.0.113.7:2").Code)
+	assert.Equal(t, http.StatusOK, serve("198.51.100.1:1").Code)
+
+	rr := serve("203.0.113.7:3")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "3600", rr.Header().Get("Retry-After"))
+	var errResp apperror.ErrorResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &errResp))
+}
+
+func TestMiddleware_NilLimiter(t *testing.T) {
+	var l *Limiter
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	assert.NotNil(t, l.Middleware(next))
+}
@@ -0,0 +1,139 @@
+// Package ratingstore persists the 1 to 5 star ratings each user gives
+// files, for culling shoots by rating.
+package ratingstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/model"
+	"github.com/cshum/imagor-studio/server/pkg/uuid"
+	"github.com/uptrace/bun"
+	"go.uber.org/zap"
+)
+
+const (
+	// MinRating and MaxRating bound a rating, 0 means unrated
+	MinRating = 1
+	MaxRating = 5
+
+	// getChunkSize keeps IN lists below the SQLite parameter limit
+	getChunkSize = 500
+)
+
+var ErrInvalid = errors.New("invalid rating")
+
+type Store interface {
+	// Set rates a file for a user, a rating of 0 clears it
+	Set(ctx context.Context, userID, scope, filePath string, rating int) error
+	// GetMulti returns the ratings the user gave paths, unrated paths are
+	// left out
+	GetMulti(ctx context.Context, userID, scope string, paths []string) (map[string]int, error)
+	// MoveFilePath rewrites the ratings of every user for a file, or for
+	// every file below a folder, after it has been moved
+	MoveFilePath(ctx context.Context, scope, oldPath, newPath string) error
+	// RemoveFilePath drops the ratings of every user for a file, or for
+	// every file below a folder, after it has been deleted
+	RemoveFilePath(ctx context.Context, scope, path string) error
+}
+
+type store struct {
+	db     *bun.DB
+	logger *zap.Logger
+}
+
+func New(db *bun.DB, logger *zap.Logger) Store {
+	return &store{db: db, logger: logger}
+}
+
+func (s *store) Set(ctx context.Context, userID, scope, filePath string, rating int) error {
+	if rating != 0 && (rating < MinRating || rating > MaxRating) {
+		return fmt.Errorf("%w: rating must be between %d and %d, or 0 to clear", ErrInvalid, MinRating, MaxRating)
+	}
+	return s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewDelete().Model((*model.Rating)(nil)).
+			Where("user_id = ?", userID).
+			Where("scope = ?", scope).
+			Where("file_path = ?", filePath).
+			Exec(ctx); err != nil {
+			return fmt.Errorf("error clearing rating: %w", err)
+		}
+		if rating == 0 {
+			return nil
+		}
+		if _, err := tx.NewInsert().Model(&model.Rating{
+			ID:       uuid.GenerateUUID(),
+			UserID:   userID,
+			Scope:    scope,
+			FilePath: filePath,
+			Rating:   rating,
+			RatedAt:  time.Now().UTC(),
+		}).Exec(ctx); err != nil {
+			return fmt.Errorf("error saving rating: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *store) GetMulti(ctx context.Context, userID, scope string, paths []string) (map[string]int, error) {
+	result := make(map[string]int)
+	for start := 0; start < len(paths); start += getChunkSize {
+		end := min(start+getChunkSize, len(paths))
+		var rows []model.Rating
+		if err := s.db.NewSelect().Model(&rows).
+			Column("file_path", "rating").
+			Where("user_id = ?", userID).
+			Where("scope = ?", scope).
+			Where("file_path IN (?)", bun.In(paths[start:end])).
+			Scan(ctx); err != nil {
+			return nil, fmt.Errorf("error getting ratings: %w", err)
+		}
+		for _, row := range rows {
+			result[row.FilePath] = row.Rating
+		}
+	}
+	return result, nil
+}
+
+func (s *store) MoveFilePath(ctx context.Context, scope, oldPath, newPath string) error {
+	return s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var rows []model.Rating
+		if err := tx.NewSelect().Model(&rows).
+			Where("scope = ?", scope).
+			Where("(file_path = ? OR substr(file_path, 1, ?) = ?)", oldPath, len(oldPath)+1, oldPath+"/").
+			Scan(ctx); err != nil {
+			return fmt.Errorf("error moving ratings: %w", err)
+		}
+		for _, row := range rows {
+			moved := newPath + strings.TrimPrefix(row.FilePath, oldPath)
+			// A rating the user already gave the destination is replaced
+			if _, err := tx.NewDelete().Model((*model.Rating)(nil)).
+				Where("user_id = ?", row.UserID).
+				Where("scope = ?", scope).
+				Where("file_path = ?", moved).
+				Exec(ctx); err != nil {
+				return fmt.Errorf("error moving ratings: %w", err)
+			}
+			if _, err := tx.NewUpdate().Model((*model.Rating)(nil)).
+				Set("file_path = ?", moved).
+				Where("id = ?", row.ID).
+				Exec(ctx); err != nil {
+				return fmt.Errorf("error moving ratings: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+func (s *store) RemoveFilePath(ctx context.Context, scope, path string) error {
+	if _, err := s.db.NewDelete().Model((*model.Rating)(nil)).
+		Where("scope = ?", scope).
+		Where("(file_path = ? OR substr(file_path, 1, ?) = ?)", path, len(path)+1, path+"/").
+		Exec(ctx); err != nil {
+		return fmt.Errorf("error removing ratings: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package ratingstore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const scope = "system:global"
+
+func setupTestStore(t *testing.T) Store {
+	t.Helper()
+	db := testutil.NewDB(t)
+
+	return New(db, zap.NewNop())
+}
+
+func TestStore_SetAndGet(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	assert.ErrorIs(t, s.Set(ctx, "alice", scope, "a.jpg", 6), ErrInvalid)
+	assert.ErrorIs(t, s.Set(ctx, "alice", scope, "a.jpg", -1), ErrInvalid)
+
+	require.NoError(t, s.Set(ctx, "alice", scope, "a.jpg", 3))
+	require.NoError(t, s.Set(ctx, "alice", scope, "a.jpg", 5))
+	require.NoError(t, s.Set(ctx, "alice", scope, "b.jpg", 1))
+	require.NoError(t, s.Set(ctx, "bob", scope, "a.jpg", 2))
+
+	ratings, err := s.GetMulti(ctx, "alice", scope, []string{"a.jpg", "b.jpg", "c.jpg"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"a.jpg": 5, "b.jpg": 1}, ratings)
+
+	require.NoError(t, s.Set(ctx, "alice", scope, "b.jpg", 0))
+	ratings, err = s.GetMulti(ctx, "alice", scope, []string{"a.jpg", "b.jpg"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"a.jpg": 5}, ratings)
+	ratings, err = s.GetMulti(ctx, "bob", scope, []string{"a.jpg"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"a.jpg": 2}, ratings)
+}
+
+func TestStore_MoveAndRemove(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	require.NoError(t, s.Set(ctx, "alice", scope, "shoot/a.jpg", 4))
+	require.NoError(t, s.Set(ctx, "alice", scope, "shoot/raw/b.jpg", 2))
+	require.NoError(t, s.Set(ctx, "alice", scope, "picks/a.jpg", 1))
+	require.NoError(t, s.Set(ctx, "alice", scope, "shoot-2/c.jpg", 3))
+
+	require.NoError(t, s.MoveFilePath(ctx, scope, "shoot", "picks"))
+	ratings, err := s.GetMulti(ctx, "alice", scope, []string{"shoot/a.jpg", "picks/a.jpg", "picks/raw/b.jpg", "shoot-2/c.jpg"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"picks/a.jpg": 4, "picks/raw/b.jpg": 2, "shoot-2/c.jpg": 3}, ratings)
+
+	require.NoError(t, s.RemoveFilePath(ctx, scope, "picks"))
+	ratings, err = s.GetMulti(ctx, "alice", scope, []string{"picks/a.jpg", "picks/raw/b.jpg", "shoot-2/c.jpg"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"shoot-2/c.jpg": 3}, ratings)
+}
@@ -0,0 +1,297 @@
+// Package rawpreview extracts the JPEG previews camera RAW files embed, so
+// RAW photos get thumbnails without decoding the sensor data.
+//
+// TIFF based formats (CR2, NEF, ARW, DNG, ORF, PEF and most others) are
+// searched through their IFDs and SubIFDs for JPEG images, Fujifilm RAF
+// through its header. The largest preview wins, usually a full or near full
+// size JPEG. Previews without EXIF of their own get the orientation of the
+// RAW file, as the camera stores it on the RAW rather than the preview.
+//
+// Thumbnails of a RAW file are rendered from the virtual key of Key, which
+// the imagor loader resolves with Extract.
+package rawpreview
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"path"
+	"strings"
+)
+
+// Suffix turns the path of a RAW file into the key of its preview
+const Suffix = ".imagor.rawpreview"
+
+// ErrNoPreview is returned for files without a usable embedded JPEG
+var ErrNoPreview = errors.New("no embedded preview")
+
+// rawExtensions are the camera RAW formats, matching the RAW extensions of
+// the default image extension list
+var rawExtensions = map[string]bool{
+	".cr2": true, ".cr3": true, ".crw": true, ".nef": true, ".nrw": true,
+	".arw": true, ".sr2": true, ".srf": true, ".dng": true, ".raf": true,
+	".orf": true, ".rw2": true, ".rwl": true, ".pef": true, ".srw": true,
+	".x3f": true, ".raw": true, ".erf": true, ".mrw": true, ".dcr": true,
+	".kdc": true, ".3fr": true, ".mef": true, ".iiq": true,
+}
+
+// IsRaw reports whether p is a camera RAW file
+func IsRaw(p string) bool {
+	return rawExtensions[strings.ToLower(path.Ext(p))]
+}
+
+// Key returns the virtual key of the preview of the RAW file at p
+func Key(p string) string {
+	return p + Suffix
+}
+
+// SourcePath returns the RAW file of a preview key, false for other keys
+func SourcePath(key string) (string, bool) {
+	if !strings.HasSuffix(key, Suffix) {
+		return "", false
+	}
+	p := strings.TrimSuffix(key, Suffix)
+	return p, IsRaw(p)
+}
+
+// TIFF tags read while searching for previews
+const (
+	tagNewSubfileType  = 0x00FE
+	tagCompression     = 0x0103
+	tagStripOffsets    = 0x0111
+	tagOrientation     = 0x0112
+	tagStripByteCounts = 0x0117
+	tagSubIFDs         = 0x014A
+	tagJPEGOffset      = 0x0201
+	tagJPEGLength      = 0x0202
+	tagExifIFD         = 0x8769
+)
+
+const (
+	// maxIFDs bounds the IFDs visited, against loops in corrupt files
+	maxIFDs = 32
+	// rafMagic starts Fujifilm RAF files, whose header points at the JPEG
+	rafMagic = "FUJIFILMCCD-RAW"
+)
+
+// span is a candidate preview within the file
+type span struct {
+	offset, length uint32
+}
+
+// Extract returns the largest embedded JPEG preview of a RAW file, with the
+// orientation of the RAW file when the preview carries none
+func Extract(data []byte) ([]byte, error) {
+	if bytes.HasPrefix(data, []byte(rafMagic)) {
+		if len(data) < 92 {
+			return nil, ErrNoPreview
+		}
+		s := span{binary.BigEndian.Uint32(data[84:]), binary.BigEndian.Uint32(data[88:])}
+		if jpeg, ok := decodableJPEG(data, s); ok {
+			return jpeg, nil
+		}
+		return nil, ErrNoPreview
+	}
+	t, ok := newTIFF(data)
+	if !ok {
+		return nil, ErrNoPreview
+	}
+	spans, orientation := t.previews()
+	var best []byte
+	for _, s := range spans {
+		if jpeg, ok := decodableJPEG(data, s); ok && len(jpeg) > len(best) {
+			best = jpeg
+		}
+	}
+	if best == nil {
+		return nil, ErrNoPreview
+	}
+	if orientation > 1 && orientation <= 8 && !hasExif(best) {
+		best = withOrientation(best, orientation)
+	}
+	return best, nil
+}
+
+type tiff struct {
+	data  []byte
+	order binary.ByteOrder
+	first uint32
+}
+
+// newTIFF reads a TIFF header, accepting the magic numbers Olympus and
+// Panasonic use in place of 42
+func newTIFF(data []byte) (*tiff, bool) {
+	if len(data) < 8 {
+		return nil, false
+	}
+	var order binary.ByteOrder
+	switch string(data[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, false
+	}
+	switch order.Uint16(data[2:]) {
+	case 42, 0x4F52, 0x5352, 0x0055:
+	default:
+		return nil, false
+	}
+	return &tiff{data: data, order: order, first: order.Uint32(data[4:])}, true
+}
+
+// entry is an IFD entry with its values read as integers
+type entry struct {
+	tag    uint16
+	values []uint32
+}
+
+// ifd returns the entries of the IFD at offset and the offset of the next
+func (t *tiff) ifd(offset uint32) ([]entry, uint32, bool) {
+	if offset < 8 || uint64(offset)+2 > uint64(len(t.data)) {
+		return nil, 0, false
+	}
+	count := int(t.order.Uint16(t.data[offset:]))
+	end := uint64(offset) + 2 + uint64(count)*12
+	if end+4 > uint64(len(t.data)) {
+		return nil, 0, false
+	}
+	entries := make([]entry, 0, count)
+	for i := range count {
+		e := t.data[int(offset)+2+i*12:]
+		tag, typ, n := t.order.Uint16(e), t.order.Uint16(e[2:]), t.order.Uint32(e[4:])
+		var size uint32
+		switch typ {
+		case 3: // SHORT
+			size = 2
+		case 4, 13: // LONG, IFD
+			size = 4
+		default:
+			continue
+		}
+		if n == 0 || n > 64 {
+			continue
+		}
+		raw := e[8:12]
+		if size*n > 4 {
+			at := t.order.Uint32(e[8:])
+			if uint64(at)+uint64(size*n) > uint64(len(t.data)) {
+				continue
+			}
+			raw = t.data[at : at+size*n]
+		}
+		values := make([]uint32, n)
+		for j := range values {
+			if size == 2 {
+				values[j] = uint32(t.order.Uint16(raw[j*2:]))
+			} else {
+				values[j] = t.order.Uint32(raw[j*4:])
+			}
+		}
+		entries = append(entries, entry{tag: tag, values: values})
+	}
+	return entries, t.order.Uint32(t.data[end:]), true
+}
+
+// previews returns the JPEG candidates of every IFD, and the orientation
+// of the first
+func (t *tiff) previews() ([]span, uint32) {
+	var spans []span
+	var orientation uint32
+	visited := make(map[uint32]bool)
+	queue := []uint32{t.first}
+	for len(queue) > 0 && len(visited) < maxIFDs {
+		offset := queue[0]
+		queue = queue[1:]
+		if offset == 0 || visited[offset] {
+			continue
+		}
+		visited[offset] = true
+		entries, next, ok := t.ifd(offset)
+		if !ok {
+			continue
+		}
+		queue = append(queue, next)
+		tags := make(map[uint16][]uint32, len(entries))
+		for _, e := range entries {
+			tags[e.tag] = e.values
+		}
+		if offset == t.first && len(tags[tagOrientation]) > 0 {
+			orientation = tags[tagOrientation][0]
+		}
+		queue = append(queue, tags[tagSubIFDs]...)
+		queue = append(queue, tags[tagExifIFD]...)
+		if o, l := tags[tagJPEGOffset], tags[tagJPEGLength]; len(o) > 0 && len(l) > 0 {
+			spans = append(spans, span{o[0], l[0]})
+		}
+		// Strips of a single JPEG, as in CR2 and DNG previews
+		if c := tags[tagCompression]; len(c) > 0 && (c[0] == 6 || c[0] == 7) {
+			if o, l := tags[tagStripOffsets], tags[tagStripByteCounts]; len(o) == 1 && len(l) == 1 {
+				spans = append(spans, span{o[0], l[0]})
+			}
+		}
+	}
+	return spans, orientation
+}
+
+// decodableJPEG returns the JPEG at s when it is one common decoders read.
+// The lossless JPEG of RAW sensor data is not.
+func decodableJPEG(data []byte, s span) ([]byte, bool) {
+	if s.length < 4 || uint64(s.offset)+uint64(s.length) > uint64(len(data)) {
+		return nil, false
+	}
+	jpeg := data[s.offset : s.offset+s.length]
+	if jpeg[0] != 0xFF || jpeg[1] != 0xD8 {
+		return nil, false
+	}
+	for i := 2; i+4 <= len(jpeg); {
+		if jpeg[i] != 0xFF {
+			return nil, false
+		}
+		marker := jpeg[i+1]
+		switch {
+		case marker == 0xC0 || marker == 0xC1 || marker == 0xC2:
+			return jpeg, true
+		case marker >= 0xC3 && marker <= 0xCF && marker != 0xC4 && marker != 0xC8 && marker != 0xCC:
+			// Lossless, hierarchical or arithmetic coded
+			return nil, false
+		case marker == 0xD9 || marker == 0xDA:
+			return nil, false
+		}
+		i += 2 + int(binary.BigEndian.Uint16(jpeg[i+2:]))
+	}
+	return nil, false
+}
+
+// hasExif reports whether a JPEG starts with an EXIF segment
+func hasExif(jpeg []byte) bool {
+	for i := 2; i+10 <= len(jpeg) && jpeg[i] == 0xFF; {
+		marker := jpeg[i+1]
+		if marker == 0xE1 && string(jpeg[i+4:i+10]) == "Exif\x00\x00" {
+			return true
+		}
+		if marker < 0xE0 || marker > 0xEF {
+			return false
+		}
+		i += 2 + int(binary.BigEndian.Uint16(jpeg[i+2:]))
+	}
+	return false
+}
+
+// withOrientation inserts an EXIF segment holding only the orientation
+// after the start of image marker
+func withOrientation(jpeg []byte, orientation uint32) []byte {
+	exif := []byte{
+		0xFF, 0xE1, 0x00, 0x22, // APP1, length 34
+		'E', 'x', 'i', 'f', 0, 0,
+		'I', 'I', 42, 0, 8, 0, 0, 0, // little endian TIFF, IFD at 8
+		1, 0, // one entry
+		0x12, 0x01, 3, 0, 1, 0, 0, 0, byte(orientation), 0, 0, 0, // Orientation SHORT
+		0, 0, 0, 0, // no next IFD
+	}
+	result := make([]byte, 0, len(jpeg)+len(exif))
+	result = append(result, jpeg[:2]...)
+	result = append(result, exif...)
+	return append(result, jpeg[2:]...)
+}
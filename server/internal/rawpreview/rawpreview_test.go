@@ -0,0 +1,170 @@
+package rawpreview
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testJPEG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height)), nil))
+	return buf.Bytes()
+}
+
+// ifdEntry is a SHORT or LONG entry of a test TIFF
+type ifdEntry struct {
+	tag   uint16
+	typ   uint16
+	value uint32
+}
+
+// testTIFF lays out a little endian TIFF: IFD0 with entries0, an optional
+// SubIFD with entries1, followed by the payloads. Entries with value
+// 0xFFFF0000+i point at payload i, length entries use 0xFFFE0000+i.
+func testTIFF(entries0, entries1 []ifdEntry, payloads ...[]byte) []byte {
+	const header = 8
+	ifdSize := func(n int) int { return 2 + n*12 + 4 }
+	subOffset := header + ifdSize(len(entries0))
+	if entries1 != nil {
+		entries0 = append(entries0, ifdEntry{tagSubIFDs, 4, uint32(subOffset)})
+		subOffset = header + ifdSize(len(entries0))
+	}
+	dataOffset := subOffset
+	if entries1 != nil {
+		dataOffset += ifdSize(len(entries1))
+	}
+	offsets := make([]uint32, len(payloads))
+	at := uint32(dataOffset)
+	for i, p := range payloads {
+		offsets[i] = at
+		at += uint32(len(p))
+	}
+	resolve := func(v uint32) uint32 {
+		switch v & 0xFFFF0000 {
+		case 0xFFFF0000:
+			return offsets[v&0xFFFF]
+		case 0xFFFE0000:
+			return uint32(len(payloads[v&0xFFFF]))
+		}
+		return v
+	}
+	var buf bytes.Buffer
+	buf.WriteString("II")
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(42))
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(header))
+	writeIFD := func(entries []ifdEntry) {
+		_ = binary.Write(&buf, binary.LittleEndian, uint16(len(entries)))
+		for _, e := range entries {
+			if e.tag == tagSubIFDs {
+				e.value = uint32(subOffset)
+			}
+			_ = binary.Write(&buf, binary.LittleEndian, e.tag)
+			_ = binary.Write(&buf, binary.LittleEndian, e.typ)
+			_ = binary.Write(&buf, binary.LittleEndian, uint32(1))
+			_ = binary.Write(&buf, binary.LittleEndian, resolve(e.value))
+		}
+		_ = binary.Write(&buf, binary.LittleEndian, uint32(0))
+	}
+	writeIFD(entries0)
+	if entries1 != nil {
+		writeIFD(entries1)
+	}
+	for _, p := range payloads {
+		buf.Write(p)
+	}
+	return buf.Bytes()
+}
+
+func TestIsRaw(t *testing.T) {
+	assert.True(t, IsRaw("a/IMG_0001.CR2"))
+	assert.True(t, IsRaw("b.nef"))
+	assert.False(t, IsRaw("c.jpg"))
+
+	assert.Equal(t, "a/b.arw.imagor.rawpreview", Key("a/b.arw"))
+	p, ok := SourcePath("a/b.arw.imagor.rawpreview")
+	assert.True(t, ok)
+	assert.Equal(t, "a/b.arw", p)
+	_, ok = SourcePath("a/b.jpg.imagor.rawpreview")
+	assert.False(t, ok)
+	_, ok = SourcePath("a/b.arw")
+	assert.False(t, ok)
+}
+
+func TestExtract_LargestPreview(t *testing.T) {
+	thumbnail := testJPEG(t, 16, 12)
+	large := testJPEG(t, 160, 120)
+	data := testTIFF(
+		[]ifdEntry{
+			{tagOrientation, 3, 6},
+			{tagJPEGOffset, 4, 0xFFFF0000},
+			{tagJPEGLength, 4, 0xFFFE0000},
+		},
+		[]ifdEntry{
+			{tagNewSubfileType, 4, 1},
+			{tagCompression, 3, 7},
+			{tagStripOffsets, 4, 0xFFFF0001},
+			{tagStripByteCounts, 4, 0xFFFE0001},
+		},
+		thumbnail, large,
+	)
+
+	preview, err := Extract(data)
+	require.NoError(t, err)
+	// The orientation of the RAW file is carried over
+	assert.True(t, hasExif(preview))
+	assert.Equal(t, large[2:], preview[2+36:])
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(preview))
+	require.NoError(t, err)
+	assert.Equal(t, 160, cfg.Width)
+}
+
+func TestExtract_SkipsLosslessJPEG(t *testing.T) {
+	preview := testJPEG(t, 16, 12)
+	// Sensor data as lossless JPEG, SOF3
+	lossless := []byte{0xFF, 0xD8, 0xFF, 0xC3, 0x00, 0x0B, 8, 0, 16, 0, 16, 1, 1, 0x11, 0, 0xFF, 0xD9}
+	lossless = append(lossless, make([]byte, 4096)...)
+	data := testTIFF(
+		[]ifdEntry{
+			{tagJPEGOffset, 4, 0xFFFF0000},
+			{tagJPEGLength, 4, 0xFFFE0000},
+			{tagCompression, 3, 7},
+			{tagStripOffsets, 4, 0xFFFF0001},
+			{tagStripByteCounts, 4, 0xFFFE0001},
+		},
+		nil,
+		preview, lossless,
+	)
+	got, err := Extract(data)
+	require.NoError(t, err)
+	assert.Equal(t, preview, got)
+}
+
+func TestExtract_RAF(t *testing.T) {
+	preview := testJPEG(t, 16, 12)
+	data := make([]byte, 100)
+	copy(data, rafMagic)
+	binary.BigEndian.PutUint32(data[84:], 100)
+	binary.BigEndian.PutUint32(data[88:], uint32(len(preview)))
+	data = append(data, preview...)
+
+	got, err := Extract(data)
+	require.NoError(t, err)
+	assert.Equal(t, preview, got)
+}
+
+func TestExtract_NoPreview(t *testing.T) {
+	_, err := Extract([]byte("not a raw file"))
+	assert.ErrorIs(t, err, ErrNoPreview)
+	_, err = Extract(testTIFF([]ifdEntry{{tagOrientation, 3, 1}}, nil))
+	assert.ErrorIs(t, err, ErrNoPreview)
+	// Offsets beyond the file are ignored
+	_, err = Extract(testTIFF([]ifdEntry{{tagJPEGOffset, 4, 1 << 20}, {tagJPEGLength, 4, 100}}, nil))
+	assert.ErrorIs(t, err, ErrNoPreview)
+}
@@ -0,0 +1,166 @@
+package registrystore
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cshum/imagor-studio/server/internal/dataloader"
+)
+
+// entryKey identifies an entry of an owner
+type entryKey struct {
+	ownerID string
+	key     string
+}
+
+type requestCacheKey struct{}
+
+// requestCache holds the registry loaders of a request, one per store
+type requestCache struct {
+	mu      sync.Mutex
+	loaders map[Store]*dataloader.Loader[entryKey, *Registry]
+}
+
+// WithRequestCache returns ctx carrying a cache of the registry reads made
+// with it through stores wrapped by NewRequestCached. Settings looked up for
+// every item of a listing are then read once per request, and lookups of
+// different keys made meanwhile are read together.
+func WithRequestCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, requestCacheKey{}, &requestCache{
+		loaders: make(map[Store]*dataloader.Loader[entryKey, *Registry]),
+	})
+}
+
+// NewRequestCached wraps store to batch and cache its reads within requests
+// whose context carries WithRequestCache. Writes through the wrapper drop
+// the cached entries they change, reads of other contexts pass through.
+func NewRequestCached(store Store) Store {
+	return &requestCachedStore{Store: store}
+}
+
+type requestCachedStore struct {
+	Store
+}
+
+func (s *requestCachedStore) loader(ctx context.Context) *dataloader.Loader[entryKey, *Registry] {
+	cache, ok := ctx.Value(requestCacheKey{}).(*requestCache)
+	if !ok {
+		return nil
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	l, ok := cache.loaders[s.Store]
+	if !ok {
+		l = dataloader.New(s.fetch)
+		cache.loaders[s.Store] = l
+	}
+	return l
+}
+
+// fetch reads a batch of entries with one GetMulti per owner, missing
+// entries load as nil
+func (s *requestCachedStore) fetch(ctx context.Context, keys []entryKey) (map[entryKey]*Registry, error) {
+	byOwner := make(map[string][]string)
+	for _, k := range keys {
+		byOwner[k.ownerID] = append(byOwner[k.ownerID], k.key)
+	}
+	result := make(map[entryKey]*Registry, len(keys))
+	for ownerID, ownerKeys := range byOwner {
+		entries, err := s.Store.GetMulti(ctx, ownerID, ownerKeys)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			result[entryKey{ownerID, entry.Key}] = entry
+		}
+	}
+	return result, nil
+}
+
+func (s *requestCachedStore) List(ctx context.Context, ownerID string, prefix *string) ([]*Registry, error) {
+	entries, err := s.Store.List(ctx, ownerID, prefix)
+	if l := s.loader(ctx); l != nil && err == nil {
+		for _, entry := range entries {
+			l.Prime(entryKey{ownerID, entry.Key}, copyRegistry(entry))
+		}
+	}
+	return entries, err
+}
+
+func (s *requestCachedStore) Get(ctx context.Context, ownerID, key string) (*Registry, error) {
+	l := s.loader(ctx)
+	if l == nil {
+		return s.Store.Get(ctx, ownerID, key)
+	}
+	entry, err := l.Load(ctx, entryKey{ownerID, key})
+	if err != nil {
+		return nil, err
+	}
+	return copyRegistry(entry), nil
+}
+
+func (s *requestCachedStore) GetMulti(ctx context.Context, ownerID string, keys []string) ([]*Registry, error) {
+	l := s.loader(ctx)
+	if l == nil {
+		return s.Store.GetMulti(ctx, ownerID, keys)
+	}
+	entryKeys := make([]entryKey, len(keys))
+	for i, key := range keys {
+		entryKeys[i] = entryKey{ownerID, key}
+	}
+	loaded, err := l.LoadMany(ctx, entryKeys)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*Registry, 0, len(keys))
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if entry := loaded[entryKey{ownerID, key}]; entry != nil && !seen[key] {
+			seen[key] = true
+			entries = append(entries, copyRegistry(entry))
+		}
+	}
+	return entries, nil
+}
+
+func (s *requestCachedStore) Set(ctx context.Context, ownerID, key, value string, isEncrypted bool) (*Registry, error) {
+	defer s.clear(ctx, ownerID, key)
+	return s.Store.Set(ctx, ownerID, key, value, isEncrypted)
+}
+
+func (s *requestCachedStore) SetMulti(ctx context.Context, ownerID string, entries []*Registry) ([]*Registry, error) {
+	keys := make([]string, len(entries))
+	for i, entry := range entries {
+		keys[i] = entry.Key
+	}
+	defer s.clear(ctx, ownerID, keys...)
+	return s.Store.SetMulti(ctx, ownerID, entries)
+}
+
+func (s *requestCachedStore) Delete(ctx context.Context, ownerID, key string) error {
+	defer s.clear(ctx, ownerID, key)
+	return s.Store.Delete(ctx, ownerID, key)
+}
+
+func (s *requestCachedStore) DeleteMulti(ctx context.Context, ownerID string, keys []string) error {
+	defer s.clear(ctx, ownerID, keys...)
+	return s.Store.DeleteMulti(ctx, ownerID, keys)
+}
+
+func (s *requestCachedStore) clear(ctx context.Context, ownerID string, keys ...string) {
+	if l := s.loader(ctx); l != nil {
+		for _, key := range keys {
+			l.Clear(entryKey{ownerID, key})
+		}
+	}
+}
+
+// copyRegistry copies an entry, so callers changing it do not change the
+// cached one
+func copyRegistry(entry *Registry) *Registry {
+	if entry == nil {
+		return nil
+	}
+	c := *entry
+	return &c
+}
@@ -0,0 +1,117 @@
+package registrystore
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// countingStore counts the reads reaching the wrapped store
+type countingStore struct {
+	Store
+	reads atomic.Int32
+}
+
+func (s *countingStore) Get(ctx context.Context, ownerID, key string) (*Registry, error) {
+	s.reads.Add(1)
+	return s.Store.Get(ctx, ownerID, key)
+}
+
+func (s *countingStore) GetMulti(ctx context.Context, ownerID string, keys []string) ([]*Registry, error) {
+	s.reads.Add(1)
+	return s.Store.GetMulti(ctx, ownerID, keys)
+}
+
+func setupRequestCachedStore(t *testing.T) (*countingStore, Store) {
+	db, cleanup := setupTestDB(t)
+	t.Cleanup(cleanup)
+	counting := &countingStore{Store: New(db, zap.NewNop(), nil)}
+	_, err := counting.SetMulti(context.Background(), "owner1", []*Registry{
+		{Key: "key1", Value: "value1"},
+		{Key: "key2", Value: "value2"},
+	})
+	require.NoError(t, err)
+	return counting, NewRequestCached(counting)
+}
+
+func TestRequestCached_ReadsOncePerRequest(t *testing.T) {
+	counting, store := setupRequestCachedStore(t)
+	ctx := WithRequestCache(context.Background())
+
+	// A thousand items reading the same settings concurrently
+	var wg sync.WaitGroup
+	for range 1000 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			entries, err := store.GetMulti(ctx, "owner1", []string{"key1", "key2", "missing"})
+			assert.NoError(t, err)
+			assert.Len(t, entries, 2)
+		}()
+	}
+	wg.Wait()
+
+	entry, err := store.Get(ctx, "owner1", "key1")
+	require.NoError(t, err)
+	assert.Equal(t, "value1", entry.Value)
+	missing, err := store.Get(ctx, "owner1", "missing")
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+
+	assert.LessOrEqual(t, counting.reads.Load(), int32(2))
+}
+
+func TestRequestCached_WithoutRequestCache(t *testing.T) {
+	counting, store := setupRequestCachedStore(t)
+	ctx := context.Background()
+
+	for range 3 {
+		entry, err := store.Get(ctx, "owner1", "key1")
+		require.NoError(t, err)
+		assert.Equal(t, "value1", entry.Value)
+	}
+	assert.Equal(t, int32(3), counting.reads.Load())
+}
+
+func TestRequestCached_WritesClearEntries(t *testing.T) {
+	_, store := setupRequestCachedStore(t)
+	ctx := WithRequestCache(context.Background())
+
+	entry, err := store.Get(ctx, "owner1", "key1")
+	require.NoError(t, err)
+	assert.Equal(t, "value1", entry.Value)
+
+	_, err = store.Set(ctx, "owner1", "key1", "updated", false)
+	require.NoError(t, err)
+	entry, err = store.Get(ctx, "owner1", "key1")
+	require.NoError(t, err)
+	assert.Equal(t, "updated", entry.Value)
+
+	require.NoError(t, store.Delete(ctx, "owner1", "key1"))
+	entry, err = store.Get(ctx, "owner1", "key1")
+	require.NoError(t, err)
+	assert.Nil(t, entry)
+}
+
+func TestRequestCached_ListPrimesEntries(t *testing.T) {
+	counting, store := setupRequestCachedStore(t)
+	ctx := WithRequestCache(context.Background())
+
+	_, err := store.List(ctx, "owner1", nil)
+	require.NoError(t, err)
+	entries, err := store.GetMulti(ctx, "owner1", []string{"key1", "key2"})
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Zero(t, counting.reads.Load())
+
+	// Changing a returned entry leaves the cached one alone
+	entries[0].Value = "changed"
+	entry, err := store.Get(ctx, "owner1", entries[0].Key)
+	require.NoError(t, err)
+	assert.NotEqual(t, "changed", entry.Value)
+}
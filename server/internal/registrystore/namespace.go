@@ -12,6 +12,9 @@ const (
 	UserNamespace = "user"
 	// SpaceNamespace represents space-specific settings
 	SpaceNamespace = "space"
+	// TenantNamespace represents tenant-specific settings, overriding system
+	// settings for the members of a tenant
+	TenantNamespace = "tenant"
 	// SystemOwnerID the owner ID for system-wide settings
 	SystemOwnerID = "system:global"
 )
@@ -33,10 +36,10 @@ func ParseOwnerID(ownerID string) (namespace, id string, err error) {
 
 	// Validate namespace
 	switch namespace {
-	case SystemNamespace, UserNamespace, SpaceNamespace:
+	case SystemNamespace, UserNamespace, SpaceNamespace, TenantNamespace:
 		// Valid namespaces
 	default:
-		return "", "", fmt.Errorf("invalid namespace '%s': must be one of [%s, %s, %s]", namespace, SystemNamespace, UserNamespace, SpaceNamespace)
+		return "", "", fmt.Errorf("invalid namespace '%s': must be one of [%s, %s, %s, %s]", namespace, SystemNamespace, UserNamespace, SpaceNamespace, TenantNamespace)
 	}
 
 	// Validate ID is not empty
@@ -102,3 +105,14 @@ func GetSpaceIDFromOwnerID(ownerID string) (string, error) {
 
 	return id, nil
 }
+
+// TenantOwnerID creates a namespaced owner ID for a tenant
+func TenantOwnerID(tenantID string) string {
+	return fmt.Sprintf("tenant:%s", tenantID)
+}
+
+// IsTenantOwnerID checks if the owner ID represents tenant settings
+func IsTenantOwnerID(ownerID string) bool {
+	namespace, _, err := ParseOwnerID(ownerID)
+	return err == nil && namespace == TenantNamespace
+}
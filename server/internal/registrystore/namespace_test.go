@@ -22,6 +22,21 @@ func TestSpaceOwnerID(t *testing.T) {
 	}
 }
 
+func TestTenantOwnerID(t *testing.T) {
+	tenantID := "123e4567-e89b-12d3-a456-426614174222"
+	expected := "tenant:123e4567-e89b-12d3-a456-426614174222"
+	actual := TenantOwnerID(tenantID)
+	if actual != expected {
+		t.Errorf("TenantOwnerID(%q) = %q, want %q", tenantID, actual, expected)
+	}
+	if !IsTenantOwnerID(actual) {
+		t.Errorf("IsTenantOwnerID(%q) = false, want true", actual)
+	}
+	if IsTenantOwnerID(SystemOwnerID) {
+		t.Errorf("IsTenantOwnerID(%q) = true, want false", SystemOwnerID)
+	}
+}
+
 func TestParseOwnerID(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -51,6 +66,13 @@ func TestParseOwnerID(t *testing.T) {
 			wantID:  "123e4567-e89b-12d3-a456-426614174111",
 			wantErr: false,
 		},
+		{
+			name:    "tenant owner ID",
+			ownerID: "tenant:123e4567-e89b-12d3-a456-426614174222",
+			wantNS:  "tenant",
+			wantID:  "123e4567-e89b-12d3-a456-426614174222",
+			wantErr: false,
+		},
 		{
 			name:    "invalid format - no colon",
 			ownerID: "invalid",
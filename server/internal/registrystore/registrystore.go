@@ -45,16 +45,21 @@ func New(db *bun.DB, logger *zap.Logger, encryptionService *encryption.Service)
 }
 
 // processRegistryEntry handles the decryption logic for a single registry entry
-func (s *store) processRegistryEntry(entry model.Registry) (*Registry, error) {
+func (s *store) processRegistryEntry(ctx context.Context, entry model.Registry) (*Registry, error) {
 	value := entry.Value
 
 	// Decrypt if encrypted
 	if entry.IsEncrypted && s.encryption != nil {
 		var decryptErr error
-		if encryption.IsJWTSecret(entry.Key) {
+		if encryption.UsesMasterKey(entry.Key) {
 			value, decryptErr = s.encryption.DecryptWithMaster(entry.Value)
 		} else {
 			value, decryptErr = s.encryption.DecryptWithJWT(entry.Value)
+			// The key may have been rotated since it was loaded, by another
+			// server or the migrate command
+			if decryptErr != nil && s.reloadDataKey(ctx) {
+				value, decryptErr = s.encryption.DecryptWithJWT(entry.Value)
+			}
 		}
 		if decryptErr != nil {
 			return nil, fmt.Errorf("failed to decrypt registry value for key %s: %w", entry.Key, decryptErr)
@@ -85,7 +90,7 @@ func (s *store) List(ctx context.Context, ownerID string, prefix *string) ([]*Re
 
 	var result []*Registry
 	for _, entry := range entries {
-		processedEntry, err := s.processRegistryEntry(entry)
+		processedEntry, err := s.processRegistryEntry(ctx, entry)
 		if err != nil {
 			// For List, we log the error and continue with encrypted value rather than failing
 			s.logger.Error("Failed to decrypt registry value",
@@ -118,7 +123,7 @@ func (s *store) Get(ctx context.Context, ownerID, key string) (*Registry, error)
 		return nil, fmt.Errorf("error getting registry: %w", err)
 	}
 
-	return s.processRegistryEntry(entry)
+	return s.processRegistryEntry(ctx, entry)
 }
 
 func (s *store) GetMulti(ctx context.Context, ownerID string, keys []string) ([]*Registry, error) {
@@ -138,7 +143,7 @@ func (s *store) GetMulti(ctx context.Context, ownerID string, keys []string) ([]
 
 	var result []*Registry
 	for _, entry := range entries {
-		processedEntry, err := s.processRegistryEntry(entry)
+		processedEntry, err := s.processRegistryEntry(ctx, entry)
 		if err != nil {
 			return nil, err
 		}
@@ -157,13 +162,16 @@ func (s *store) setWithinTx(ctx context.Context, db bun.IDB, ownerID string, ent
 		if encryption.IsJWTSecret(entry.Key) && !entry.IsEncrypted {
 			return nil, fmt.Errorf("JWT secret must always be encrypted")
 		}
+		if entry.Key == encryption.DataKeyRegistryKey {
+			return nil, fmt.Errorf("%s is managed by encryption key rotation", entry.Key)
+		}
 
 		finalValue := entry.Value
 
 		// Encrypt if needed
 		if entry.IsEncrypted && s.encryption != nil {
 			var encryptErr error
-			if encryption.IsJWTSecret(entry.Key) {
+			if encryption.UsesMasterKey(entry.Key) {
 				finalValue, encryptErr = s.encryption.EncryptWithMaster(entry.Value)
 			} else {
 				finalValue, encryptErr = s.encryption.EncryptWithJWT(entry.Value)
@@ -230,6 +238,9 @@ func (s *store) SetMulti(ctx context.Context, ownerID string, entries []*Registr
 }
 
 func (s *store) Delete(ctx context.Context, ownerID, key string) error {
+	if key == encryption.DataKeyRegistryKey {
+		return fmt.Errorf("%s is managed by encryption key rotation", key)
+	}
 	result, err := s.db.NewDelete().
 		Model((*model.Registry)(nil)).
 		Where("owner_id = ? AND key = ?", ownerID, key).
@@ -258,6 +269,9 @@ func (s *store) DeleteMulti(ctx context.Context, ownerID string, keys []string)
 	// Remove duplicates and count unique keys
 	uniqueKeys := make(map[string]bool)
 	for _, key := range keys {
+		if key == encryption.DataKeyRegistryKey {
+			return fmt.Errorf("%s is managed by encryption key rotation", key)
+		}
 		uniqueKeys[key] = true
 	}
 
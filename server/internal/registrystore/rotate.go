@@ -0,0 +1,111 @@
+package registrystore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/model"
+	"github.com/cshum/imagor-studio/server/pkg/encryption"
+	"github.com/cshum/imagor-studio/server/pkg/uuid"
+	"github.com/uptrace/bun"
+	"go.uber.org/zap"
+)
+
+// RotateEncryptionKey generates a new data key and re-encrypts every
+// encrypted registry value of all owners with it, except those encrypted with
+// the master key. The values and the new key, encrypted with the master key,
+// are written in one transaction, so a failure leaves the registry as it was.
+// enc uses the new key once the transaction is committed. Other servers
+// sharing the database load it on their next failure to decrypt a value.
+//
+// It returns the number of re-encrypted values.
+func RotateEncryptionKey(ctx context.Context, db *bun.DB, enc *encryption.Service) (int, error) {
+	dataKey, err := encryption.GenerateDataKey()
+	if err != nil {
+		return 0, err
+	}
+	next := enc.WithDataKey(dataKey)
+	s := &store{db: db, logger: zap.NewNop(), encryption: enc}
+	// Values are encrypted with the current key, which may be unknown yet
+	s.reloadDataKey(ctx)
+
+	var rotated int
+	err = db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var entries []model.Registry
+		if err := tx.NewSelect().
+			Model(&entries).
+			Where("is_encrypted = ?", true).
+			Where("key NOT IN (?)", bun.In([]string{"config.jwt_secret", encryption.DataKeyRegistryKey})).
+			Scan(ctx); err != nil {
+			return fmt.Errorf("error listing encrypted registry: %w", err)
+		}
+
+		now := time.Now()
+		for _, entry := range entries {
+			value, err := enc.DecryptWithJWT(entry.Value)
+			if err != nil {
+				return fmt.Errorf("failed to decrypt registry value for key %s of %s: %w", entry.Key, entry.OwnerID, err)
+			}
+			if value, err = next.EncryptWithJWT(value); err != nil {
+				return fmt.Errorf("failed to encrypt registry value for key %s of %s: %w", entry.Key, entry.OwnerID, err)
+			}
+			if _, err := tx.NewUpdate().
+				Model((*model.Registry)(nil)).
+				Set("value = ?", value).
+				Set("updated_at = ?", now).
+				Where("id = ?", entry.ID).
+				Exec(ctx); err != nil {
+				return fmt.Errorf("error updating registry for key %s of %s: %w", entry.Key, entry.OwnerID, err)
+			}
+			rotated++
+		}
+
+		encryptedKey, err := enc.EncryptWithMaster(dataKey)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt data key: %w", err)
+		}
+		return s.upsertRegistry(ctx, tx, &model.Registry{
+			ID:          uuid.GenerateUUID(),
+			OwnerID:     SystemOwnerID,
+			Key:         encryption.DataKeyRegistryKey,
+			Value:       encryptedKey,
+			IsEncrypted: true,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	enc.SetDataKey(dataKey)
+	return rotated, nil
+}
+
+// reloadDataKey loads the data key from the registry into the encryption
+// service, reporting whether it changed
+func (s *store) reloadDataKey(ctx context.Context) bool {
+	var entry model.Registry
+	err := s.db.NewSelect().
+		Model(&entry).
+		Where("owner_id = ? AND key = ?", SystemOwnerID, encryption.DataKeyRegistryKey).
+		Scan(ctx)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			s.logger.Warn("Failed to load encryption key", zap.Error(err))
+		}
+		return false
+	}
+	dataKey, err := s.encryption.DecryptWithMaster(entry.Value)
+	if err != nil {
+		s.logger.Warn("Failed to decrypt encryption key", zap.Error(err))
+		return false
+	}
+	if s.encryption.HasDataKey(dataKey) {
+		return false
+	}
+	s.encryption.SetDataKey(dataKey)
+	return true
+}
@@ -0,0 +1,91 @@
+package registrystore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/model"
+	"github.com/cshum/imagor-studio/server/pkg/encryption"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRotateEncryptionKey(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	enc := encryption.NewServiceWithJwtLey(":memory:", "jwt-secret")
+	s := New(db, zap.NewNop(), enc)
+	_, err := s.Set(ctx, SystemOwnerID, "config.jwt_secret", "jwt-secret", true)
+	require.NoError(t, err)
+	_, err = s.Set(ctx, SystemOwnerID, "config.imagor_secret", "imagor-secret", true)
+	require.NoError(t, err)
+	_, err = s.Set(ctx, "user-1", "token", "user-token", true)
+	require.NoError(t, err)
+	_, err = s.Set(ctx, SystemOwnerID, "config.app_title", "Photos", false)
+	require.NoError(t, err)
+
+	rawValue := func(ownerID, key string) string {
+		var entry model.Registry
+		require.NoError(t, db.NewSelect().Model(&entry).Where("owner_id = ? AND key = ?", ownerID, key).Scan(ctx))
+		return entry.Value
+	}
+	before := rawValue(SystemOwnerID, "config.imagor_secret")
+	jwtBefore := rawValue(SystemOwnerID, "config.jwt_secret")
+
+	rotated, err := RotateEncryptionKey(ctx, db, enc)
+	require.NoError(t, err)
+	assert.Equal(t, 2, rotated)
+	assert.NotEqual(t, before, rawValue(SystemOwnerID, "config.imagor_secret"))
+	assert.Equal(t, jwtBefore, rawValue(SystemOwnerID, "config.jwt_secret"), "the JWT secret uses the master key")
+	assert.NotEmpty(t, rawValue(SystemOwnerID, encryption.DataKeyRegistryKey))
+
+	entry, err := s.Get(ctx, "user-1", "token")
+	require.NoError(t, err)
+	assert.Equal(t, "user-token", entry.Value)
+
+	// Another server with the old key loads the new one
+	other := New(db, zap.NewNop(), encryption.NewServiceWithJwtLey(":memory:", "jwt-secret"))
+	entry, err = other.Get(ctx, SystemOwnerID, "config.imagor_secret")
+	require.NoError(t, err)
+	assert.Equal(t, "imagor-secret", entry.Value)
+
+	// Rotating again starts from the stored key
+	rotated, err = RotateEncryptionKey(ctx, db, encryption.NewServiceWithJwtLey(":memory:", "jwt-secret"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, rotated)
+	entries, err := other.GetMulti(ctx, SystemOwnerID, []string{"config.imagor_secret", "config.app_title"})
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	_, err = s.Set(ctx, SystemOwnerID, encryption.DataKeyRegistryKey, "x", true)
+	assert.Error(t, err)
+	assert.Error(t, s.Delete(ctx, SystemOwnerID, encryption.DataKeyRegistryKey))
+}
+
+func TestRotateEncryptionKeyFailure(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := New(db, zap.NewNop(), encryption.NewServiceWithJwtLey(":memory:", "other-secret")).
+		Set(ctx, SystemOwnerID, "config.imagor_secret", "imagor-secret", true)
+	require.NoError(t, err)
+	_, err = New(db, zap.NewNop(), encryption.NewServiceWithJwtLey(":memory:", "jwt-secret")).
+		Set(ctx, SystemOwnerID, "config.s3_secret", "s3-secret", true)
+	require.NoError(t, err)
+
+	// A value that does not decrypt rolls back the whole rotation
+	enc := encryption.NewServiceWithJwtLey(":memory:", "jwt-secret")
+	_, err = RotateEncryptionKey(ctx, db, enc)
+	assert.Error(t, err)
+	exists, err := db.NewSelect().Model((*model.Registry)(nil)).Where("key = ?", encryption.DataKeyRegistryKey).Exists(ctx)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	entry, err := New(db, zap.NewNop(), enc).Get(ctx, SystemOwnerID, "config.s3_secret")
+	require.NoError(t, err)
+	assert.Equal(t, "s3-secret", entry.Value)
+}
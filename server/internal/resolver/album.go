@@ -0,0 +1,327 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/albumstore"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/webhook"
+	"github.com/cshum/imagor-studio/server/pkg/space"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+// albumScope returns the album namespace and config of a space
+func (r *Resolver) albumScope(ctx context.Context, spaceID *string) (string, *space.Space, error) {
+	if r.albumStore == nil {
+		return "", nil, &gqlerror.Error{
+			Message:    "albums are not available",
+			Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+		}
+	}
+	spaceConfig, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err != nil {
+		return "", nil, err
+	}
+	return tenantScope(ctx, spaceConfig), spaceConfig, nil
+}
+
+func albumError(err error) error {
+	switch {
+	case errors.Is(err, albumstore.ErrNotFound):
+		return &gqlerror.Error{
+			Message:    err.Error(),
+			Extensions: map[string]interface{}{"code": "NOT_FOUND"},
+		}
+	case errors.Is(err, albumstore.ErrInvalid):
+		return &gqlerror.Error{
+			Message:    err.Error(),
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	return fmt.Errorf("album operation failed: %w", err)
+}
+
+// toGQLAlbum converts an album, hiding a cover outside the access root of
+// the request
+func (r *Resolver) toGQLAlbum(ctx context.Context, album *albumstore.Album, spaceConfig *space.Space) *gql.Album {
+	result := &gql.Album{
+		ID:        album.ID,
+		Name:      album.Name,
+		ItemCount: album.ItemCount,
+		CreatedAt: album.CreatedAt.Format(time.RFC3339),
+		UpdatedAt: album.UpdatedAt.Format(time.RFC3339),
+	}
+	root := accessRoot(ctx)
+	if album.CoverPath != "" && (root == "" || isWithinHomePath(root, album.CoverPath)) {
+		coverPath := album.CoverPath
+		result.CoverPath = &coverPath
+		result.CoverThumbnailUrls = r.generateThumbnailUrlsForResolvedSpace(ctx, coverPath,
+			r.getEffectiveVideoThumbnailPosition(ctx, spaceConfig), thumbnailSpaceKey(spaceConfig), spaceConfig)
+	}
+	return result
+}
+
+// thumbnailSpaceKey returns the key thumbnail URLs of a space are signed for
+func thumbnailSpaceKey(spaceConfig *space.Space) *string {
+	if spaceConfig == nil {
+		return nil
+	}
+	return &spaceConfig.Key
+}
+
+// albumPaths normalizes the paths of an album mutation, requiring write
+// access to each
+func albumPaths(ctx context.Context, paths []string) ([]string, error) {
+	if len(paths) > albumstore.MaxItems {
+		return nil, &gqlerror.Error{
+			Message:    fmt.Sprintf("at most %d paths can be given", albumstore.MaxItems),
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	result := make([]string, 0, len(paths))
+	for _, p := range paths {
+		scoped, err := ScopePath(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		scoped = strings.Trim(scoped, "/")
+		if err := RequireWritePermission(ctx, scoped); err != nil {
+			return nil, err
+		}
+		result = append(result, scoped)
+	}
+	return result, nil
+}
+
+// Albums is the resolver for the albums field.
+func (r *queryResolver) Albums(ctx context.Context, spaceID *string) ([]*gql.Album, error) {
+	if err := RequirePermission(ctx, "read"); err != nil {
+		return nil, err
+	}
+	scope, spaceConfig, err := r.albumScope(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	albums, err := r.albumStore.List(ctx, scope)
+	if err != nil {
+		return nil, albumError(err)
+	}
+	result := make([]*gql.Album, 0, len(albums))
+	for _, album := range albums {
+		result = append(result, r.toGQLAlbum(ctx, album, spaceConfig))
+	}
+	return result, nil
+}
+
+// Album is the resolver for the album field.
+func (r *queryResolver) Album(ctx context.Context, id string, spaceID *string) (*gql.Album, error) {
+	if err := RequirePermission(ctx, "read"); err != nil {
+		return nil, err
+	}
+	scope, spaceConfig, err := r.albumScope(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	album, err := r.albumStore.Get(ctx, scope, id)
+	if err != nil {
+		return nil, albumError(err)
+	}
+	return r.toGQLAlbum(ctx, album, spaceConfig), nil
+}
+
+// AlbumItems is the resolver for the albumItems field.
+func (r *queryResolver) AlbumItems(ctx context.Context, id string, spaceID *string) ([]*gql.AlbumItem, error) {
+	if err := RequirePermission(ctx, "read"); err != nil {
+		return nil, err
+	}
+	scope, spaceConfig, err := r.albumScope(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	items, err := r.albumStore.Items(ctx, scope, id)
+	if err != nil {
+		return nil, albumError(err)
+	}
+	// Items are listed only below the home path or shared folder
+	root := accessRoot(ctx)
+	videoThumbnailPos := r.getEffectiveVideoThumbnailPosition(ctx, spaceConfig)
+	spaceKey := thumbnailSpaceKey(spaceConfig)
+	result := make([]*gql.AlbumItem, 0, len(items))
+	for _, item := range items {
+		if root != "" && !isWithinHomePath(root, item.Path) {
+			continue
+		}
+		result = append(result, &gql.AlbumItem{
+			Path:          item.Path,
+			AddedAt:       item.AddedAt.Format(time.RFC3339),
+			ThumbnailUrls: r.generateThumbnailUrlsForResolvedSpace(ctx, item.Path, videoThumbnailPos, spaceKey, spaceConfig),
+		})
+	}
+	return result, nil
+}
+
+// CreateAlbum is the resolver for the createAlbum field.
+func (r *mutationResolver) CreateAlbum(ctx context.Context, name string, spaceID *string) (*gql.Album, error) {
+	if err := RequirePermission(ctx, "write"); err != nil {
+		return nil, err
+	}
+	scope, spaceConfig, err := r.albumScope(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	userID, _ := GetUserIDFromContext(ctx)
+	album, err := r.albumStore.Create(ctx, scope, name, userID)
+	if err != nil {
+		return nil, albumError(err)
+	}
+	// Like file events, webhooks only report albums of the default storage
+	if spaceConfig == nil {
+		r.notifyWebhooks(webhook.EventAlbumCreated, webhook.AlbumData{ID: album.ID, Name: album.Name, CreatedBy: album.CreatedBy})
+	}
+	return r.toGQLAlbum(ctx, album, spaceConfig), nil
+}
+
+// RenameAlbum is the resolver for the renameAlbum field.
+func (r *mutationResolver) RenameAlbum(ctx context.Context, id string, name string, spaceID *string) (*gql.Album, error) {
+	if err := RequirePermission(ctx, "write"); err != nil {
+		return nil, err
+	}
+	scope, spaceConfig, err := r.albumScope(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	album, err := r.albumStore.Rename(ctx, scope, id, name)
+	if err != nil {
+		return nil, albumError(err)
+	}
+	return r.toGQLAlbum(ctx, album, spaceConfig), nil
+}
+
+// DeleteAlbum is the resolver for the deleteAlbum field.
+func (r *mutationResolver) DeleteAlbum(ctx context.Context, id string, spaceID *string) (bool, error) {
+	if err := RequirePermission(ctx, "write"); err != nil {
+		return false, err
+	}
+	scope, _, err := r.albumScope(ctx, spaceID)
+	if err != nil {
+		return false, err
+	}
+	if err := r.albumStore.Delete(ctx, scope, id); err != nil {
+		return false, albumError(err)
+	}
+	return true, nil
+}
+
+// AddAlbumItems is the resolver for the addAlbumItems field.
+func (r *mutationResolver) AddAlbumItems(ctx context.Context, id string, paths []string, spaceID *string) (*gql.Album, error) {
+	if err := RequirePermission(ctx, "write"); err != nil {
+		return nil, err
+	}
+	paths, err := albumPaths(ctx, paths)
+	if err != nil {
+		return nil, err
+	}
+	scope, spaceConfig, err := r.albumScope(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	stor, err := r.getSpaceStorageByID(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	// Albums hold files, checked up front so nothing is added on failure
+	for _, p := range paths {
+		info, err := stor.Stat(ctx, p)
+		if err != nil {
+			return nil, &gqlerror.Error{
+				Message:    fmt.Sprintf("file not found: %s", p),
+				Extensions: map[string]interface{}{"code": "NOT_FOUND"},
+			}
+		}
+		if info.IsDir {
+			return nil, &gqlerror.Error{
+				Message:    fmt.Sprintf("%s is a folder, albums hold files", p),
+				Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+			}
+		}
+	}
+	album, err := r.albumStore.AddItems(ctx, scope, id, paths)
+	if err != nil {
+		return nil, albumError(err)
+	}
+	return r.toGQLAlbum(ctx, album, spaceConfig), nil
+}
+
+// RemoveAlbumItems is the resolver for the removeAlbumItems field.
+func (r *mutationResolver) RemoveAlbumItems(ctx context.Context, id string, paths []string, spaceID *string) (*gql.Album, error) {
+	if err := RequirePermission(ctx, "write"); err != nil {
+		return nil, err
+	}
+	paths, err := albumPaths(ctx, paths)
+	if err != nil {
+		return nil, err
+	}
+	scope, spaceConfig, err := r.albumScope(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	album, err := r.albumStore.RemoveItems(ctx, scope, id, paths)
+	if err != nil {
+		return nil, albumError(err)
+	}
+	return r.toGQLAlbum(ctx, album, spaceConfig), nil
+}
+
+// ReorderAlbumItems is the resolver for the reorderAlbumItems field.
+func (r *mutationResolver) ReorderAlbumItems(ctx context.Context, id string, paths []string, spaceID *string) (*gql.Album, error) {
+	if err := RequirePermission(ctx, "write"); err != nil {
+		return nil, err
+	}
+	paths, err := albumPaths(ctx, paths)
+	if err != nil {
+		return nil, err
+	}
+	scope, spaceConfig, err := r.albumScope(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	album, err := r.albumStore.Reorder(ctx, scope, id, paths)
+	if err != nil {
+		return nil, albumError(err)
+	}
+	return r.toGQLAlbum(ctx, album, spaceConfig), nil
+}
+
+// moveAlbumItems keeps album items following a moved file or folder.
+// Failures are logged rather than failing the move that already happened.
+func (r *Resolver) moveAlbumItems(ctx context.Context, spaceID *string, sourcePath, destPath string) {
+	if r.albumStore == nil {
+		return
+	}
+	scope, _, err := r.albumScope(ctx, spaceID)
+	if err == nil {
+		err = r.albumStore.MoveFilePath(ctx, scope, strings.Trim(sourcePath, "/"), strings.Trim(destPath, "/"))
+	}
+	if err != nil {
+		r.logger.Warn("Failed to move album items", zap.String("source", sourcePath), zap.String("dest", destPath), zap.Error(err))
+	}
+}
+
+// removeAlbumItems drops album items of a deleted file or folder
+func (r *Resolver) removeAlbumItems(ctx context.Context, spaceID *string, path string) {
+	if r.albumStore == nil {
+		return
+	}
+	scope, _, err := r.albumScope(ctx, spaceID)
+	if err == nil {
+		err = r.albumStore.RemoveFilePath(ctx, scope, strings.Trim(path, "/"))
+	}
+	if err != nil {
+		r.logger.Warn("Failed to remove album items", zap.String("path", path), zap.Error(err))
+	}
+}
@@ -0,0 +1,163 @@
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor-studio/server/internal/albumstore"
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/cshum/imagor-studio/server/pkg/auth"
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func newAlbumTestResolver(t *testing.T) (*Resolver, string) {
+	t.Helper()
+	db := testutil.NewDB(t)
+
+	baseDir := t.TempDir()
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+	mockImagorProvider := new(MockImagorProvider)
+	mockImagorProvider.On("Imagor").Return((*imagor.Imagor)(nil))
+	mockImagorProvider.On("GenerateURL", mock.Anything, mock.Anything).Return("/imagor/thumbnail.webp", nil)
+	mockRegistryStore := new(MockRegistryStore)
+	mockRegistryStore.On("GetMulti", mock.Anything, "system:global", mock.Anything).Return([]*registrystore.Registry{}, nil)
+	logger := zap.NewNop()
+	return newTestResolver(NewMockStorageProvider(stor), mockRegistryStore, new(MockUserStore), mockImagorProvider, &config.Config{}, nil, logger,
+		WithAlbumStore(albumstore.New(db, logger))), baseDir
+}
+
+func TestAlbums_CreateAddAndReorder(t *testing.T) {
+	resolver, baseDir := newAlbumTestResolver(t)
+	writeTestFile(t, baseDir, "2024/a.jpg")
+	writeTestFile(t, baseDir, "2025/b.jpg")
+	ctx := createReadWriteContext("user-1")
+
+	album, err := resolver.Mutation().CreateAlbum(ctx, "Best of", nil)
+	require.NoError(t, err)
+	assert.Nil(t, album.CoverPath)
+
+	album, err = resolver.Mutation().AddAlbumItems(ctx, album.ID, []string{"/2024/a.jpg", "2025/b.jpg"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, album.ItemCount)
+	require.NotNil(t, album.CoverPath)
+	assert.Equal(t, "2024/a.jpg", *album.CoverPath)
+	require.NotNil(t, album.CoverThumbnailUrls)
+
+	_, err = resolver.Mutation().AddAlbumItems(ctx, album.ID, []string{"2025/missing.jpg"}, nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_FOUND", gqlErr.Extensions["code"])
+	_, err = resolver.Mutation().AddAlbumItems(ctx, album.ID, []string{"2025"}, nil)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+
+	album, err = resolver.Mutation().ReorderAlbumItems(ctx, album.ID, []string{"2025/b.jpg", "2024/a.jpg"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "2025/b.jpg", *album.CoverPath)
+	items, err := resolver.Query().AlbumItems(ctx, album.ID, nil)
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+	assert.Equal(t, "2025/b.jpg", items[0].Path)
+	assert.Equal(t, "2024/a.jpg", items[1].Path)
+	assert.NotNil(t, items[0].ThumbnailUrls)
+
+	_, err = resolver.Mutation().ReorderAlbumItems(ctx, album.ID, []string{"2025/b.jpg"}, nil)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+
+	renamed, err := resolver.Mutation().RenameAlbum(ctx, album.ID, "Highlights", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Highlights", renamed.Name)
+	albums, err := resolver.Query().Albums(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, albums, 1)
+	assert.Equal(t, 2, albums[0].ItemCount)
+
+	album, err = resolver.Mutation().RemoveAlbumItems(ctx, album.ID, []string{"2025/b.jpg"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, album.ItemCount)
+
+	deleted, err := resolver.Mutation().DeleteAlbum(ctx, album.ID, nil)
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	_, err = resolver.Query().Album(ctx, album.ID, nil)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_FOUND", gqlErr.Extensions["code"])
+	assert.FileExists(t, baseDir+"/2025/b.jpg", "files outlive their albums")
+}
+
+func TestAlbums_FollowMovedAndDeletedFiles(t *testing.T) {
+	resolver, baseDir := newAlbumTestResolver(t)
+	writeTestFile(t, baseDir, "album/a.jpg")
+	writeTestFile(t, baseDir, "album/b.jpg")
+	ctx := createReadWriteContext("user-1")
+
+	album, err := resolver.Mutation().CreateAlbum(ctx, "Trip", nil)
+	require.NoError(t, err)
+	_, err = resolver.Mutation().AddAlbumItems(ctx, album.ID, []string{"album/a.jpg", "album/b.jpg"}, nil)
+	require.NoError(t, err)
+
+	_, err = resolver.Mutation().MoveFile(ctx, "album", "trip", nil)
+	require.NoError(t, err)
+	_, err = resolver.Mutation().DeleteFile(ctx, "trip/b.jpg", nil)
+	require.NoError(t, err)
+
+	items, err := resolver.Query().AlbumItems(ctx, album.ID, nil)
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, "trip/a.jpg", items[0].Path)
+}
+
+func TestAlbums_ScopedToAccessRoot(t *testing.T) {
+	resolver, baseDir := newAlbumTestResolver(t)
+	writeTestFile(t, baseDir, "shared/a.jpg")
+	writeTestFile(t, baseDir, "private/b.jpg")
+	ctx := createReadWriteContext("user-1")
+
+	album, err := resolver.Mutation().CreateAlbum(ctx, "Mixed", nil)
+	require.NoError(t, err)
+	_, err = resolver.Mutation().AddAlbumItems(ctx, album.ID, []string{"private/b.jpg", "shared/a.jpg"}, nil)
+	require.NoError(t, err)
+
+	// Shared links only see the items and cover below their folder
+	shared := auth.SetClaimsInContext(context.Background(), &auth.Claims{
+		UserID: "guest", Role: "guest", Scopes: []string{"read"}, PathPrefix: "shared",
+	})
+	items, err := resolver.Query().AlbumItems(shared, album.ID, nil)
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, "shared/a.jpg", items[0].Path)
+	got, err := resolver.Query().Album(shared, album.ID, nil)
+	require.NoError(t, err)
+	assert.Nil(t, got.CoverPath)
+	assert.Nil(t, got.CoverThumbnailUrls)
+
+	home := WithHomePath(createReadOnlyContext("user-2"), "private")
+	items, err = resolver.Query().AlbumItems(home, album.ID, nil)
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, "private/b.jpg", items[0].Path)
+	got, err = resolver.Query().Album(home, album.ID, nil)
+	require.NoError(t, err)
+	require.NotNil(t, got.CoverPath)
+	assert.Equal(t, "private/b.jpg", *got.CoverPath)
+}
+
+func TestAlbums_MutationsRequireWrite(t *testing.T) {
+	resolver, _ := newAlbumTestResolver(t)
+
+	_, err := resolver.Mutation().CreateAlbum(createReadOnlyContext("user-1"), "Trip", nil)
+	assert.Error(t, err)
+	albums, err := resolver.Query().Albums(createReadOnlyContext("user-1"), nil)
+	require.NoError(t, err)
+	assert.Empty(t, albums)
+}
@@ -0,0 +1,84 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cshum/imagor-studio/server/internal/allowlist"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+// OperationAllowLists is the resolver for the operationAllowLists field.
+func (r *queryResolver) OperationAllowLists(ctx context.Context) ([]*gql.OperationAllowList, error) {
+	if err := r.requireOperationAllowList(ctx); err != nil {
+		return nil, err
+	}
+	lists := make([]*gql.OperationAllowList, 0, len(allowlist.Roles))
+	for _, role := range allowlist.Roles {
+		lists = append(lists, r.toGQLOperationAllowList(role))
+	}
+	return lists, nil
+}
+
+// SetOperationAllowList is the resolver for the setOperationAllowList field.
+func (r *mutationResolver) SetOperationAllowList(ctx context.Context, role string, fields []string) (*gql.OperationAllowList, error) {
+	if err := r.requireOperationAllowList(ctx); err != nil {
+		return nil, err
+	}
+	if !allowlist.IsRestrictable(role) {
+		return nil, allowListInputError(allowlist.ErrUnknownRole)
+	}
+	if err := allowlist.Validate(gql.NewExecutableSchema(gql.Config{}).Schema(), fields); err != nil {
+		return nil, allowListInputError(err)
+	}
+	if _, err := r.operationAllowList.Set(ctx, role, fields); err != nil {
+		r.logger.Error("Failed to set operation allow-list", zap.String("role", role), zap.Error(err))
+		return nil, err
+	}
+	return r.toGQLOperationAllowList(role), nil
+}
+
+// ClearOperationAllowList is the resolver for the clearOperationAllowList field.
+func (r *mutationResolver) ClearOperationAllowList(ctx context.Context, role string) (*gql.OperationAllowList, error) {
+	if err := r.requireOperationAllowList(ctx); err != nil {
+		return nil, err
+	}
+	if err := r.operationAllowList.Clear(ctx, role); err != nil {
+		if errors.Is(err, allowlist.ErrUnknownRole) {
+			return nil, allowListInputError(err)
+		}
+		r.logger.Error("Failed to clear operation allow-list", zap.String("role", role), zap.Error(err))
+		return nil, err
+	}
+	return r.toGQLOperationAllowList(role), nil
+}
+
+func (r *Resolver) requireOperationAllowList(ctx context.Context) error {
+	if err := RequireAdminPermission(ctx); err != nil {
+		return err
+	}
+	if r.operationAllowList == nil {
+		return &gqlerror.Error{
+			Message:    "operation allow-lists are not available on this server",
+			Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+		}
+	}
+	return nil
+}
+
+func (r *Resolver) toGQLOperationAllowList(role string) *gql.OperationAllowList {
+	fields, restricted := r.operationAllowList.Get(role)
+	if fields == nil {
+		fields = []string{}
+	}
+	return &gql.OperationAllowList{Role: role, Restricted: restricted, Fields: fields}
+}
+
+func allowListInputError(err error) error {
+	return &gqlerror.Error{
+		Message:    err.Error(),
+		Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+	}
+}
@@ -0,0 +1,64 @@
+package resolver
+
+import (
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/allowlist"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func TestOperationAllowLists(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	mockRegistryStore := new(MockRegistryStore)
+	store := allowlist.New(mockRegistryStore, logger)
+	resolver := newTestResolver(nil, mockRegistryStore, nil, nil, nil, nil, logger, WithOperationAllowList(store))
+	ctx := createAdminContext("admin-1")
+
+	_, err := resolver.Query().OperationAllowLists(createReadWriteContext("user-1"))
+	assert.Error(t, err)
+
+	mockRegistryStore.On("Set", ctx, registrystore.SystemOwnerID, allowlist.Key("guest"), `["Query.listFiles","Query.statFile"]`, false).
+		Return(&registrystore.Registry{}, nil)
+	list, err := resolver.Mutation().SetOperationAllowList(ctx, "guest", []string{"Query.statFile", "Query.listFiles"})
+	require.NoError(t, err)
+	assert.True(t, list.Restricted)
+	assert.Equal(t, []string{"Query.listFiles", "Query.statFile"}, list.Fields)
+
+	lists, err := resolver.Query().OperationAllowLists(ctx)
+	require.NoError(t, err)
+	require.Len(t, lists, 2)
+	assert.Equal(t, "guest", lists[0].Role)
+	assert.True(t, lists[0].Restricted)
+	assert.Equal(t, "user", lists[1].Role)
+	assert.False(t, lists[1].Restricted)
+	assert.Empty(t, lists[1].Fields)
+
+	var gqlErr *gqlerror.Error
+	_, err = resolver.Mutation().SetOperationAllowList(ctx, "guest", []string{"Query.noSuchField"})
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+	_, err = resolver.Mutation().SetOperationAllowList(ctx, "admin", []string{"Query.listFiles"})
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+
+	mockRegistryStore.On("DeleteMulti", ctx, registrystore.SystemOwnerID, []string{allowlist.Key("guest")}).Return(nil)
+	list, err = resolver.Mutation().ClearOperationAllowList(ctx, "guest")
+	require.NoError(t, err)
+	assert.False(t, list.Restricted)
+	mockRegistryStore.AssertExpectations(t)
+	mockRegistryStore.AssertNotCalled(t, "Set", mock.Anything, mock.Anything, allowlist.Key("admin"), mock.Anything, mock.Anything)
+}
+
+func TestOperationAllowLists_NotAvailable(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	resolver := newTestResolver(nil, nil, nil, nil, nil, nil, logger)
+	_, err := resolver.Query().OperationAllowLists(createAdminContext("admin-1"))
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_AVAILABLE", gqlErr.Extensions["code"])
+}
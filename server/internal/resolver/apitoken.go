@@ -0,0 +1,131 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/apitoken"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/pkg/auth"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+func apiTokenError(err error) error {
+	switch {
+	case errors.Is(err, apitoken.ErrNotFound):
+		return &gqlerror.Error{
+			Message:    err.Error(),
+			Extensions: map[string]interface{}{"code": "NOT_FOUND"},
+		}
+	case errors.Is(err, apitoken.ErrInvalid):
+		return &gqlerror.Error{
+			Message:    err.Error(),
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	return fmt.Errorf("API token operation failed: %w", err)
+}
+
+// requireAPITokenUser returns the claims of a user session that may manage
+// API tokens. Guests, shared link, preview and embedded sessions have no
+// tokens.
+func (r *Resolver) requireAPITokenUser(ctx context.Context) (*auth.Claims, error) {
+	if r.apiTokenStore == nil {
+		return nil, &gqlerror.Error{
+			Message:    "API tokens are not available on this server",
+			Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+		}
+	}
+	claims, err := auth.GetClaimsFromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unauthorized")
+	}
+	if IsGuestUser(ctx) || IsShareLinkSession(ctx) || IsPublicPreviewMode(ctx) || claims.IsEmbedded {
+		return nil, fmt.Errorf("API tokens require a user account")
+	}
+	return claims, nil
+}
+
+func toGQLAPIToken(token *apitoken.Token) *gql.APIToken {
+	result := &gql.APIToken{
+		ID:        token.ID,
+		Name:      token.Name,
+		Prefix:    token.Prefix,
+		Scopes:    token.Scopes,
+		CreatedAt: token.CreatedAt.Format(time.RFC3339),
+	}
+	if token.ExpiresAt != nil {
+		expiresAt := token.ExpiresAt.Format(time.RFC3339)
+		result.ExpiresAt = &expiresAt
+	}
+	if token.LastUsedAt != nil {
+		lastUsedAt := token.LastUsedAt.Format(time.RFC3339)
+		result.LastUsedAt = &lastUsedAt
+	}
+	return result
+}
+
+// APITokens is the resolver for the apiTokens field.
+func (r *queryResolver) APITokens(ctx context.Context) ([]*gql.APIToken, error) {
+	claims, err := r.requireAPITokenUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := r.apiTokenStore.List(ctx, claims.UserID)
+	if err != nil {
+		return nil, apiTokenError(err)
+	}
+	result := make([]*gql.APIToken, 0, len(tokens))
+	for _, token := range tokens {
+		result = append(result, toGQLAPIToken(token))
+	}
+	return result, nil
+}
+
+// CreateAPIToken is the resolver for the createApiToken field.
+func (r *mutationResolver) CreateAPIToken(ctx context.Context, name string, scopes []string, expiresAt *string) (*gql.CreatedAPIToken, error) {
+	claims, err := r.requireAPITokenUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// A leaked token must not be able to outlive its own revocation
+	if claims.Kind == auth.APITokenKind {
+		return nil, fmt.Errorf("API tokens cannot create API tokens")
+	}
+	held := make(map[string]bool, len(claims.Scopes))
+	for _, scope := range claims.Scopes {
+		held[scope] = true
+	}
+	for _, scope := range scopes {
+		if !held[scope] {
+			return nil, apiTokenError(fmt.Errorf("%w: the session does not hold scope %q", apitoken.ErrInvalid, scope))
+		}
+	}
+	var expires *time.Time
+	if expiresAt != nil {
+		t, err := time.Parse(time.RFC3339, *expiresAt)
+		if err != nil {
+			return nil, apiTokenError(fmt.Errorf("%w: expiresAt must be an RFC 3339 time", apitoken.ErrInvalid))
+		}
+		expires = &t
+	}
+	token, secret, err := r.apiTokenStore.Create(ctx, claims.UserID, claims.OrgID, name, scopes, expires)
+	if err != nil {
+		return nil, apiTokenError(err)
+	}
+	return &gql.CreatedAPIToken{APIToken: toGQLAPIToken(token), Token: secret}, nil
+}
+
+// RevokeAPIToken is the resolver for the revokeApiToken field.
+func (r *mutationResolver) RevokeAPIToken(ctx context.Context, id string) (bool, error) {
+	claims, err := r.requireAPITokenUser(ctx)
+	if err != nil {
+		return false, err
+	}
+	if err := r.apiTokenStore.Revoke(ctx, claims.UserID, id); err != nil {
+		return false, apiTokenError(err)
+	}
+	return true, nil
+}
@@ -0,0 +1,78 @@
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/apitoken"
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/cshum/imagor-studio/server/pkg/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func newAPITokenTestResolver(t *testing.T) *Resolver {
+	t.Helper()
+	db := testutil.NewDB(t)
+
+	logger := zap.NewNop()
+	return newTestResolver(nil, new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, logger,
+		WithAPITokenStore(apitoken.New(db, logger)))
+}
+
+func TestAPITokens_CreateListRevoke(t *testing.T) {
+	resolver := newAPITokenTestResolver(t)
+	ctx := createReadWriteContext("user-1")
+
+	created, err := resolver.Mutation().CreateAPIToken(ctx, "camera import", []string{"read", "write"}, stringPtr("2099-01-01T00:00:00Z"))
+	require.NoError(t, err)
+	assert.True(t, apitoken.IsToken(created.Token))
+	assert.Equal(t, "camera import", created.APIToken.Name)
+	require.NotNil(t, created.APIToken.ExpiresAt)
+	assert.Equal(t, "2099-01-01T00:00:00Z", *created.APIToken.ExpiresAt)
+
+	tokens, err := resolver.Query().APITokens(ctx)
+	require.NoError(t, err)
+	require.Len(t, tokens, 1)
+	tokens, err = resolver.Query().APITokens(createReadWriteContext("user-2"))
+	require.NoError(t, err)
+	assert.Empty(t, tokens)
+
+	_, err = resolver.Mutation().RevokeAPIToken(createReadWriteContext("user-2"), created.APIToken.ID)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_FOUND", gqlErr.Extensions["code"])
+	revoked, err := resolver.Mutation().RevokeAPIToken(ctx, created.APIToken.ID)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+}
+
+func TestAPITokens_Restrictions(t *testing.T) {
+	resolver := newAPITokenTestResolver(t)
+	var gqlErr *gqlerror.Error
+
+	// Scopes cannot exceed those of the session
+	_, err := resolver.Mutation().CreateAPIToken(createReadOnlyContext("user-1"), "cron", []string{"write"}, nil)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+	_, err = resolver.Mutation().CreateAPIToken(createReadWriteContext("user-1"), "cron", []string{"read"}, stringPtr("tomorrow"))
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+
+	_, err = resolver.Mutation().CreateAPIToken(createGuestContext("guest-1"), "cron", []string{"read"}, nil)
+	assert.Error(t, err)
+	_, err = resolver.Mutation().CreateAPIToken(createEmbeddedUserContext("user-1", "user", []string{"read"}, ""), "cron", []string{"read"}, nil)
+	assert.Error(t, err)
+
+	tokenSession := auth.SetClaimsInContext(context.Background(), &auth.Claims{
+		UserID: "user-1", Role: "user", Scopes: []string{"read", "write"}, Kind: auth.APITokenKind,
+	})
+	tokenSession = context.WithValue(tokenSession, UserIDContextKey, "user-1")
+	_, err = resolver.Mutation().CreateAPIToken(tokenSession, "cron", []string{"read"}, nil)
+	assert.Error(t, err)
+	_, err = resolver.Query().APITokens(tokenSession)
+	assert.NoError(t, err)
+}
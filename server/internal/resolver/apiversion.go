@@ -0,0 +1,59 @@
+package resolver
+
+import (
+	"context"
+	"strings"
+
+	"github.com/cshum/imagor-studio/server/internal/apiversion"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+)
+
+// APIVersion is the resolver for the apiVersion field.
+func (r *queryResolver) APIVersion(ctx context.Context) (*gql.APIVersionInfo, error) {
+	deprecations := apiversion.Deprecations(gql.NewExecutableSchema(gql.Config{}).Schema())
+	result := &gql.APIVersionInfo{
+		Version:      apiversion.Current,
+		CompatMode:   r.apiCompatMode,
+		Deprecations: make([]*gql.APIDeprecation, 0, len(deprecations)),
+	}
+	for _, d := range deprecations {
+		item := &gql.APIDeprecation{
+			Path:   d.Path,
+			Reason: d.Reason,
+		}
+		if d.DeprecatedIn > 0 {
+			deprecatedIn := d.DeprecatedIn
+			item.DeprecatedIn = &deprecatedIn
+		}
+		if d.Replacement != "" {
+			replacement := d.Replacement
+			item.Replacement = &replacement
+		}
+		result.Deprecations = append(result.Deprecations, item)
+	}
+	return result, nil
+}
+
+// APIChangelog is the resolver for the apiChangelog field.
+func (r *queryResolver) APIChangelog(ctx context.Context, sinceVersion *int) ([]*gql.APIChange, error) {
+	since := 0
+	if sinceVersion != nil {
+		since = *sinceVersion
+	}
+	changes := apiversion.ChangesSince(since)
+	result := make([]*gql.APIChange, 0, len(changes))
+	for _, c := range changes {
+		item := &gql.APIChange{
+			Version:     c.Version,
+			Kind:        gql.APIChangeKind(strings.ToUpper(string(c.Kind))),
+			Path:        c.Path,
+			Description: c.Description,
+		}
+		if c.Replacement != "" {
+			replacement := c.Replacement
+			item.Replacement = &replacement
+		}
+		result = append(result, item)
+	}
+	return result, nil
+}
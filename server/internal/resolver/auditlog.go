@@ -0,0 +1,110 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/auditlog"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// AuditLog is the resolver for the auditLog field.
+func (r *queryResolver) AuditLog(ctx context.Context, filter *gql.AuditLogFilter, offset *int, limit *int, after *string) (*gql.AuditLogPage, error) {
+	if err := RequireAdminPermission(ctx); err != nil {
+		return nil, err
+	}
+	if r.auditLog == nil {
+		return nil, &gqlerror.Error{
+			Message:    "the audit log is not available on this server",
+			Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+		}
+	}
+	var f auditlog.Filter
+	if filter != nil {
+		if filter.UserID != nil {
+			f.UserID = *filter.UserID
+		}
+		if filter.Operation != nil {
+			f.Operation = *filter.Operation
+		}
+		if filter.Path != nil {
+			f.Path = *filter.Path
+		}
+		f.FailedOnly = filter.FailedOnly != nil && *filter.FailedOnly
+		var err error
+		if f.Since, err = parseAuditLogTime("since", filter.Since); err != nil {
+			return nil, err
+		}
+		if f.Until, err = parseAuditLogTime("until", filter.Until); err != nil {
+			return nil, err
+		}
+	}
+	if err := requireAfterWithoutOffset(after, offset); err != nil {
+		return nil, err
+	}
+	offsetValue, limitValue := 0, 0
+	if offset != nil {
+		offsetValue = *offset
+	}
+	if limit != nil {
+		limitValue = *limit
+	}
+	var entries []*auditlog.Entry
+	var total int
+	var endCursor *string
+	if after != nil {
+		key, err := decodeAfterKey(*after)
+		if err != nil {
+			return nil, err
+		}
+		var more bool
+		entries, total, more, err = r.auditLog.ListAfter(ctx, f, key, limitValue)
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) > 0 {
+			last := entries[len(entries)-1]
+			endCursor = keyEndCursor(more, last.CreatedAt, last.ID)
+		}
+	} else {
+		var err error
+		entries, total, err = r.auditLog.List(ctx, f, offsetValue, limitValue)
+		if err != nil {
+			return nil, err
+		}
+	}
+	items := make([]*gql.AuditLogEntry, 0, len(entries))
+	for _, e := range entries {
+		items = append(items, &gql.AuditLogEntry{
+			ID:        e.ID,
+			CreatedAt: e.CreatedAt.Format(time.RFC3339),
+			UserID:    e.UserID,
+			Role:      e.Role,
+			Operation: e.Operation,
+			SpaceID:   optionalString(e.SpaceID),
+			Path:      optionalString(e.Path),
+			DestPath:  optionalString(e.DestPath),
+			PathCount: e.PathCount,
+			Target:    optionalString(e.Target),
+			ClientIP:  e.ClientIP,
+			Error:     optionalString(e.Error),
+		})
+	}
+	return &gql.AuditLogPage{Items: items, TotalCount: total, EndCursor: endCursor}, nil
+}
+
+func parseAuditLogTime(name string, value *string) (time.Time, error) {
+	if value == nil || *value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, *value)
+	if err != nil {
+		return time.Time{}, &gqlerror.Error{
+			Message:    fmt.Sprintf("%s must be an RFC 3339 time", name),
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	return t, nil
+}
@@ -0,0 +1,105 @@
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/auditlog"
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func newAuditLogTestResolver(t *testing.T) (*Resolver, auditlog.Store) {
+	t.Helper()
+	db := testutil.NewDB(t)
+
+	logger := zap.NewNop()
+	store := auditlog.New(db, logger)
+	return newTestResolver(nil, new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, logger,
+		WithAuditLog(store)), store
+}
+
+func TestAuditLog_AdminOnly(t *testing.T) {
+	resolver, store := newAuditLogTestResolver(t)
+	ctx := context.Background()
+	require.NoError(t, store.Record(ctx, &auditlog.Entry{UserID: "user-1", Role: "user", Operation: "deleteFile", Path: "a.jpg", PathCount: 1, ClientIP: "192.0.2.1"}))
+	require.NoError(t, store.Record(ctx, &auditlog.Entry{UserID: "admin-1", Role: "admin", Operation: "createUser", Target: "user-2", Error: "username taken"}))
+
+	_, err := resolver.Query().AuditLog(createReadWriteContext("user-1"), nil, nil, nil, nil)
+	assert.Error(t, err)
+
+	admin := createAdminContext("admin-1")
+	page, err := resolver.Query().AuditLog(admin, nil, nil, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, page.TotalCount)
+
+	failedOnly := true
+	page, err = resolver.Query().AuditLog(admin, &gql.AuditLogFilter{FailedOnly: &failedOnly}, nil, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	entry := page.Items[0]
+	assert.Equal(t, "createUser", entry.Operation)
+	assert.Nil(t, entry.Path)
+	require.NotNil(t, entry.Target)
+	assert.Equal(t, "user-2", *entry.Target)
+	require.NotNil(t, entry.Error)
+	assert.Equal(t, "username taken", *entry.Error)
+
+	since := "yesterday"
+	_, err = resolver.Query().AuditLog(admin, &gql.AuditLogFilter{Since: &since}, nil, nil, nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+}
+
+func TestAuditLog_NotAvailableWithoutStore(t *testing.T) {
+	resolver := newTestResolver(nil, new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop())
+	_, err := resolver.Query().AuditLog(createAdminContext("admin-1"), nil, nil, nil, nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_AVAILABLE", gqlErr.Extensions["code"])
+}
+
+func TestAuditLog_Cursor(t *testing.T) {
+	resolver, store := newAuditLogTestResolver(t)
+	ctx := context.Background()
+	for _, operation := range []string{"uploadFile", "moveFile", "deleteFile"} {
+		require.NoError(t, store.Record(ctx, &auditlog.Entry{UserID: "user-1", Role: "user", Operation: operation}))
+	}
+	admin := createAdminContext("admin-1")
+
+	// Offsets without after have no endCursor
+	page, err := resolver.Query().AuditLog(admin, nil, nil, intPtr(2), nil)
+	require.NoError(t, err)
+	assert.Nil(t, page.EndCursor)
+
+	var operations []string
+	after := ""
+	for {
+		page, err := resolver.Query().AuditLog(admin, nil, nil, intPtr(2), &after)
+		require.NoError(t, err)
+		assert.Equal(t, 3, page.TotalCount)
+		for _, entry := range page.Items {
+			operations = append(operations, entry.Operation)
+		}
+		if page.EndCursor == nil {
+			break
+		}
+		after = *page.EndCursor
+	}
+	assert.ElementsMatch(t, []string{"uploadFile", "moveFile", "deleteFile"}, operations)
+	assert.Len(t, operations, 3)
+
+	var gqlErr *gqlerror.Error
+	_, err = resolver.Query().AuditLog(admin, nil, intPtr(1), nil, stringPtr(""))
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+	_, err = resolver.Query().AuditLog(admin, nil, nil, nil, stringPtr("not-a-cursor"))
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+}
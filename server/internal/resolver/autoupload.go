@@ -0,0 +1,274 @@
+package resolver
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/autoupload"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/internal/registryutil"
+	"github.com/cshum/imagor-studio/server/pkg/space"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxAutoUploadFiles bounds the files of a single autoUpload call
+	maxAutoUploadFiles = 100
+	// maxAutoUploadNames bounds the names tried for a file whose name is
+	// taken, IMG_0001 (2).JPG up to IMG_0001 (100).JPG
+	maxAutoUploadNames = 100
+)
+
+// AutoUpload is the resolver for the autoUpload field.
+func (r *mutationResolver) AutoUpload(ctx context.Context, deviceID string, files []*gql.AutoUploadFile, spaceID *string) (*gql.AutoUploadBatchResult, error) {
+	// Checked without a path, so guests limited to an upload folder cannot
+	// back up
+	if err := RequireWritePermission(ctx); err != nil {
+		return nil, err
+	}
+	userID, err := GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if r.autoUploadStore == nil {
+		return nil, &gqlerror.Error{
+			Message:    "auto upload is not available on this server",
+			Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+		}
+	}
+	if len(files) > maxAutoUploadFiles {
+		return nil, autoUploadInputError(fmt.Sprintf("too many files, at most %d are allowed per request", maxAutoUploadFiles))
+	}
+	device, err := autoupload.NormalizeDeviceID(deviceID)
+	if err != nil {
+		return nil, autoUploadInputError(err.Error())
+	}
+	stor, sp, err := r.resolveUploadStorageTarget(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureSpaceUploadAllowed(sp); err != nil {
+		return nil, err
+	}
+	template := r.getAutoUploadFolderTemplate(ctx)
+	now := time.Now().UTC()
+
+	result := &gql.AutoUploadBatchResult{Results: make([]*gql.AutoUploadResult, 0, len(files))}
+	for _, file := range files {
+		if file == nil {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		fileResult := &gql.AutoUploadResult{Filename: file.Filename}
+		if err := r.autoUploadFile(ctx, stor, sp, userID, device, template, now, file, fileResult); err != nil {
+			message := err.Error()
+			fileResult.Status = gql.AutoUploadStatusFailed
+			fileResult.Error = &message
+			fileResult.Code = batchErrorCode(err)
+		}
+		switch fileResult.Status {
+		case gql.AutoUploadStatusUploaded:
+			result.Uploaded++
+		case gql.AutoUploadStatusDuplicate:
+			result.Duplicates++
+		case gql.AutoUploadStatusFailed:
+			result.Failed++
+		}
+		result.Results = append(result.Results, fileResult)
+	}
+	return result, nil
+}
+
+// autoUploadFile backs up a single file, filling in the status, path and
+// hash of result
+func (r *Resolver) autoUploadFile(ctx context.Context, stor storage.Storage, sp *space.Space, userID, device string, template autoupload.Template, now time.Time, file *gql.AutoUploadFile, result *gql.AutoUploadResult) error {
+	name, err := autoupload.NormalizeFilename(file.Filename)
+	if err != nil {
+		return autoUploadInputError(err.Error())
+	}
+	capturedAt := now
+	if file.CapturedAt != nil && *file.CapturedAt != "" {
+		// The offset sent is kept, so files are filed by the local date of
+		// the device
+		capturedAt, err = time.Parse(time.RFC3339, *file.CapturedAt)
+		if err != nil {
+			return autoUploadInputError("capturedAt must be an RFC 3339 time")
+		}
+	}
+	hash, err := autoUploadHash(file)
+	if err != nil {
+		return err
+	}
+	result.Sha256 = &hash
+
+	existing, err := r.findAutoUploadDuplicate(ctx, stor, sp, userID, hash)
+	if err != nil {
+		return err
+	}
+	if existing != "" {
+		result.Status = gql.AutoUploadStatusDuplicate
+		result.Path = &existing
+		return nil
+	}
+	if file.Content == nil {
+		result.Status = gql.AutoUploadStatusNeedsContent
+		return nil
+	}
+
+	folder, err := ScopePath(ctx, template.Folder(capturedAt, device))
+	if err != nil {
+		return err
+	}
+	if err := RequireWritePermission(ctx, folder); err != nil {
+		return err
+	}
+	filePath, err := availableUploadPath(ctx, stor, folder, name)
+	if err != nil {
+		return err
+	}
+	if err := r.enforceHostedStorageQuota(ctx, sp, file.Content.Size); err != nil {
+		return err
+	}
+	if err := r.enforceStorageQuotas(ctx, sp, filePath, file.Content.Size); err != nil {
+		return err
+	}
+	r.logger.Debug("Auto uploading file", zap.String("path", filePath), zap.String("device", device))
+	filePath, err = r.storeUpload(ctx, stor, sp, filePath, uploadCollisionRename, file.Content.File, file.Content.Size)
+	if err != nil {
+		return err
+	}
+	if r.duplicates != nil {
+		r.recordUploadHash(ctx, stor, sp, filePath, hash)
+	}
+	// The file is stored either way; without the record, sending it again
+	// stores another copy
+	if err := r.autoUploadStore.Record(ctx, &autoupload.Record{
+		UserID:     userID,
+		Scope:      fileMetadataScope(sp),
+		Hash:       hash,
+		Path:       filePath,
+		DeviceID:   device,
+		Filename:   name,
+		CapturedAt: capturedAt,
+	}); err != nil {
+		r.logger.Warn("Failed to record auto upload", zap.String("path", filePath), zap.Error(err))
+	}
+	result.Status = gql.AutoUploadStatusUploaded
+	result.Path = &filePath
+	return nil
+}
+
+// autoUploadHash returns the hash of the content of file, checking it
+// against the hash sent, or the hash sent when there is no content
+func autoUploadHash(file *gql.AutoUploadFile) (string, error) {
+	var declared string
+	if file.Sha256 != nil {
+		declared = strings.ToLower(strings.TrimSpace(*file.Sha256))
+	}
+	if declared != "" {
+		if b, err := hex.DecodeString(declared); err != nil || len(b) != 32 {
+			return "", autoUploadInputError("sha256 must be a hex encoded SHA-256 hash")
+		}
+	}
+	if file.Content == nil {
+		if declared == "" {
+			return "", autoUploadInputError("sha256 is required without content")
+		}
+		return declared, nil
+	}
+	hash, err := hashUpload(*file.Content)
+	if err != nil {
+		return "", err
+	}
+	if declared != "" && declared != hash {
+		return "", autoUploadInputError("content does not match sha256")
+	}
+	return hash, nil
+}
+
+// findAutoUploadDuplicate returns where content with hash is already
+// stored for the user: a file they backed up before, or a file they can
+// read hashed for duplicate detection. Empty when there is none.
+func (r *Resolver) findAutoUploadDuplicate(ctx context.Context, stor storage.Storage, sp *space.Space, userID, hash string) (string, error) {
+	record, err := r.autoUploadStore.Find(ctx, userID, fileMetadataScope(sp), hash)
+	if err != nil {
+		return "", err
+	}
+	// Backups deleted or moved since, or now outside the user's home
+	// path, are stored again
+	if record != nil && ValidatePathAccess(ctx, record.Path) == nil {
+		if _, err := stor.Stat(ctx, record.Path); err == nil {
+			return record.Path, nil
+		}
+	}
+	if r.duplicates == nil {
+		return "", nil
+	}
+	return r.findHashedFile(ctx, stor, sp, hash, "")
+}
+
+// availableUploadPath returns the first path of name in folder not taken,
+// trying IMG_0001.JPG, IMG_0001 (2).JPG and so on
+func availableUploadPath(ctx context.Context, stor storage.Storage, folder, name string) (string, error) {
+	for n := 1; n <= maxAutoUploadNames; n++ {
+		candidate := path.Join(folder, autoupload.Candidate(name, n))
+		taken, err := pathTaken(ctx, stor, candidate)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return candidate, nil
+		}
+	}
+	return "", fileAlreadyExistsError("auto upload")
+}
+
+// getAutoUploadFolderTemplate resolves the auto upload folder template,
+// preferring the user registry and falling back to the system registry set
+// by admins. Invalid templates are logged and the default is used.
+func (r *Resolver) getAutoUploadFolderTemplate(ctx context.Context) autoupload.Template {
+	var value string
+	if userID, err := GetUserIDFromContext(ctx); err == nil && r.registryStore != nil {
+		entries, err := r.registryStore.GetMulti(ctx, registrystore.UserOwnerID(userID), []string{autoupload.FolderTemplateKey})
+		if err != nil {
+			r.logger.Warn("Failed to read auto upload folder template", zap.String("userID", userID), zap.Error(err))
+		}
+		for _, entry := range entries {
+			if entry != nil && strings.TrimSpace(entry.Value) != "" {
+				value = entry.Value
+			}
+		}
+	}
+	if value == "" {
+		for _, result := range registryutil.GetEffectiveValues(ctx, r.registryStore, r.config, autoupload.FolderTemplateKey) {
+			if result.Exists {
+				value = result.Value
+			}
+		}
+	}
+	if strings.TrimSpace(value) == "" {
+		return autoupload.DefaultFolderTemplate
+	}
+	template, err := autoupload.ParseTemplate(value)
+	if err != nil {
+		r.logger.Warn("Invalid auto upload folder template, using the default", zap.Error(err))
+		return autoupload.DefaultFolderTemplate
+	}
+	return template
+}
+
+func autoUploadInputError(message string) error {
+	return &gqlerror.Error{
+		Message:    message,
+		Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+	}
+}
@@ -0,0 +1,225 @@
+package resolver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/cshum/imagor-studio/server/internal/autoupload"
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/dedupe"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func newAutoUploadTestResolver(t *testing.T, registry *MockRegistryStore, withDuplicates bool) (*Resolver, string) {
+	t.Helper()
+	db := testutil.NewDB(t)
+
+	baseDir := t.TempDir()
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+	logger := zap.NewNop()
+	if registry == nil {
+		registry = new(MockRegistryStore)
+		registry.On("GetMulti", mock.Anything, mock.Anything, mock.Anything).Return([]*registrystore.Registry{}, nil)
+	}
+	opts := []ResolverOption{WithAutoUploadStore(autoupload.New(db, logger))}
+	if withDuplicates {
+		opts = append(opts, WithDuplicateScanner(dedupe.NewScanner(dedupe.NewStore(db, logger), logger)))
+	}
+	return newTestResolver(NewMockStorageProvider(stor), registry, new(MockUserStore), nil, &config.Config{}, nil, logger, opts...), baseDir
+}
+
+func sha256Hex(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func autoUploadFile(filename, capturedAt, content string) *gql.AutoUploadFile {
+	file := &gql.AutoUploadFile{Filename: filename, CapturedAt: &capturedAt}
+	if content != "" {
+		u := upload(content)
+		file.Content = &u
+	}
+	return file
+}
+
+func TestAutoUpload(t *testing.T) {
+	resolver, baseDir := newAutoUploadTestResolver(t, nil, false)
+	ctx := createReadWriteContext("user-1")
+
+	result, err := resolver.Mutation().AutoUpload(ctx, "pixel", []*gql.AutoUploadFile{
+		autoUploadFile("DCIM/Camera/IMG_0001.JPG", "2026-03-07T12:00:00Z", "first"),
+		// Filed by the local date of the device
+		autoUploadFile("IMG_0002.JPG", "2026-04-01T00:30:00+02:00", "second"),
+		// Reusing a name taken by other content
+		autoUploadFile("IMG_0001.JPG", "2026-03-20T08:00:00Z", "third"),
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.Uploaded)
+	assert.Zero(t, result.Failed)
+	require.Len(t, result.Results, 3)
+	assert.Equal(t, "2026/03/IMG_0001.JPG", *result.Results[0].Path)
+	assert.Equal(t, sha256Hex("first"), *result.Results[0].Sha256)
+	assert.Equal(t, "2026/04/IMG_0002.JPG", *result.Results[1].Path)
+	assert.Equal(t, "2026/03/IMG_0001 (2).JPG", *result.Results[2].Path)
+	content, err := os.ReadFile(filepath.Join(baseDir, "2026/03/IMG_0001 (2).JPG"))
+	require.NoError(t, err)
+	assert.Equal(t, "third", string(content))
+
+	// Content backed up before is not written again, whatever the name
+	result, err = resolver.Mutation().AutoUpload(ctx, "pixel", []*gql.AutoUploadFile{
+		autoUploadFile("IMG_0001-edited.JPG", "2026-05-01T00:00:00Z", "first"),
+		autoUploadFile("IMG_0003.JPG", "2026-05-01T00:00:00Z", "fourth"),
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Duplicates)
+	assert.Equal(t, 1, result.Uploaded)
+	assert.Equal(t, gql.AutoUploadStatusDuplicate, result.Results[0].Status)
+	assert.Equal(t, "2026/03/IMG_0001.JPG", *result.Results[0].Path)
+	assert.NoFileExists(t, filepath.Join(baseDir, "2026/05/IMG_0001-edited.JPG"))
+
+	// Backups deleted since are stored again
+	require.NoError(t, os.Remove(filepath.Join(baseDir, "2026/03/IMG_0001.JPG")))
+	result, err = resolver.Mutation().AutoUpload(ctx, "pixel", []*gql.AutoUploadFile{
+		autoUploadFile("IMG_0001.JPG", "2026-03-07T12:00:00Z", "first"),
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, gql.AutoUploadStatusUploaded, result.Results[0].Status)
+	assert.Equal(t, "2026/03/IMG_0001.JPG", *result.Results[0].Path)
+}
+
+func TestAutoUpload_HashOnly(t *testing.T) {
+	resolver, _ := newAutoUploadTestResolver(t, nil, false)
+	ctx := createReadWriteContext("user-1")
+	_, err := resolver.Mutation().AutoUpload(ctx, "pixel", []*gql.AutoUploadFile{
+		autoUploadFile("IMG_0001.JPG", "2026-03-07T12:00:00Z", "first"),
+	}, nil)
+	require.NoError(t, err)
+
+	known, unknown, wrong := sha256Hex("first"), sha256Hex("second"), sha256Hex("other")
+	mismatched := autoUploadFile("IMG_0003.JPG", "", "third")
+	mismatched.Sha256 = &wrong
+	result, err := resolver.Mutation().AutoUpload(ctx, "pixel", []*gql.AutoUploadFile{
+		{Filename: "IMG_0001.JPG", Sha256: &known},
+		{Filename: "IMG_0002.JPG", Sha256: &unknown},
+		{Filename: "IMG_0004.JPG"},
+		mismatched,
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Duplicates)
+	assert.Equal(t, 2, result.Failed)
+	assert.Equal(t, gql.AutoUploadStatusDuplicate, result.Results[0].Status)
+	assert.Equal(t, "2026/03/IMG_0001.JPG", *result.Results[0].Path)
+	assert.Equal(t, gql.AutoUploadStatusNeedsContent, result.Results[1].Status)
+	assert.Nil(t, result.Results[1].Path)
+	for _, failed := range result.Results[2:] {
+		assert.Equal(t, gql.AutoUploadStatusFailed, failed.Status)
+		assert.Equal(t, "BAD_USER_INPUT", *failed.Code)
+	}
+}
+
+func TestAutoUpload_LibraryDuplicates(t *testing.T) {
+	resolver, baseDir := newAutoUploadTestResolver(t, nil, true)
+	ctx := createReadWriteContext("user-1")
+
+	// Files uploaded otherwise and hashed for duplicate detection count
+	recordLibraryHash(t, resolver, baseDir, "albums/trip.jpg", "first")
+	result, err := resolver.Mutation().AutoUpload(ctx, "pixel", []*gql.AutoUploadFile{
+		autoUploadFile("IMG_0001.JPG", "2026-03-07T12:00:00Z", "first"),
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, gql.AutoUploadStatusDuplicate, result.Results[0].Status)
+	assert.Equal(t, "albums/trip.jpg", *result.Results[0].Path)
+
+	// Files outside the user's home path do not count
+	home := WithHomePath(createReadWriteContext("user-2"), "users/bob")
+	result, err = resolver.Mutation().AutoUpload(home, "iphone", []*gql.AutoUploadFile{
+		autoUploadFile("IMG_0001.JPG", "2026-03-07T12:00:00Z", "first"),
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, gql.AutoUploadStatusUploaded, result.Results[0].Status)
+	assert.Equal(t, "users/bob/2026/03/IMG_0001.JPG", *result.Results[0].Path)
+}
+
+// recordLibraryHash writes content to path and records its hash as a
+// duplicate scan would
+func recordLibraryHash(t *testing.T, resolver *Resolver, baseDir, path, content string) {
+	t.Helper()
+	writeTestFile(t, baseDir, path)
+	require.NoError(t, os.WriteFile(filepath.Join(baseDir, path), []byte(content), 0644))
+	stor, _, err := resolver.resolveUploadStorageTarget(context.Background(), nil)
+	require.NoError(t, err)
+	resolver.recordUploadHash(context.Background(), stor, nil, path, sha256Hex(content))
+}
+
+func TestAutoUpload_FolderTemplate(t *testing.T) {
+	registry := new(MockRegistryStore)
+	registry.On("GetMulti", mock.Anything, "user:user-1", []string{autoupload.FolderTemplateKey}).Return([]*registrystore.Registry{
+		{Key: autoupload.FolderTemplateKey, Value: "Phones/{device}/{year}"},
+	}, nil)
+	registry.On("GetMulti", mock.Anything, "user:user-2", []string{autoupload.FolderTemplateKey}).Return([]*registrystore.Registry{
+		{Key: autoupload.FolderTemplateKey, Value: "{year}/{hour}"},
+	}, nil)
+	registry.On("GetMulti", mock.Anything, registrystore.SystemOwnerID, mock.Anything).Return([]*registrystore.Registry{}, nil)
+	resolver, _ := newAutoUploadTestResolver(t, registry, false)
+
+	result, err := resolver.Mutation().AutoUpload(createReadWriteContext("user-1"), "Alice/Pixel", []*gql.AutoUploadFile{
+		autoUploadFile("IMG_0001.JPG", "2026-03-07T12:00:00Z", "first"),
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Phones/Alice_Pixel/2026/IMG_0001.JPG", *result.Results[0].Path)
+
+	// Invalid templates fall back to the default
+	result, err = resolver.Mutation().AutoUpload(createReadWriteContext("user-2"), "pixel", []*gql.AutoUploadFile{
+		autoUploadFile("IMG_0001.JPG", "2026-03-07T12:00:00Z", "second"),
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "2026/03/IMG_0001.JPG", *result.Results[0].Path)
+}
+
+func TestAutoUpload_Validation(t *testing.T) {
+	resolver, _ := newAutoUploadTestResolver(t, nil, false)
+	ctx := createReadWriteContext("user-1")
+
+	_, err := resolver.Mutation().AutoUpload(createReadOnlyContext("user-1"), "pixel", nil, nil)
+	assert.Error(t, err)
+
+	var gqlErr *gqlerror.Error
+	_, err = resolver.Mutation().AutoUpload(ctx, " ", nil, nil)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+
+	files := make([]*gql.AutoUploadFile, maxAutoUploadFiles+1)
+	_, err = resolver.Mutation().AutoUpload(ctx, "pixel", files, nil)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+
+	result, err := resolver.Mutation().AutoUpload(ctx, "pixel", []*gql.AutoUploadFile{
+		autoUploadFile("..", "2026-03-07T12:00:00Z", "first"),
+		autoUploadFile("IMG_0001.JPG", "yesterday", "first"),
+		{Filename: "IMG_0002.JPG", Content: &graphql.Upload{}, Sha256: stringPtr("not a hash")},
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.Failed)
+	for _, failed := range result.Results {
+		assert.Equal(t, "BAD_USER_INPUT", *failed.Code)
+	}
+
+	withoutStore := newTestResolver(nil, new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop())
+	_, err = withoutStore.Mutation().AutoUpload(ctx, "pixel", nil, nil)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_AVAILABLE", gqlErr.Extensions["code"])
+}
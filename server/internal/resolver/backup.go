@@ -0,0 +1,37 @@
+package resolver
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/backup"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// CreateBackup is the resolver for the createBackup field.
+func (r *mutationResolver) CreateBackup(ctx context.Context) (*gql.Backup, error) {
+	if err := RequireAdminPermission(ctx); err != nil {
+		return nil, err
+	}
+	if r.backupDB == nil {
+		return nil, &gqlerror.Error{
+			Message:    "backups are not available on this server",
+			Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+		}
+	}
+	var buf bytes.Buffer
+	manifest, err := backup.Write(ctx, r.backupDB, &buf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backup: %w", err)
+	}
+	return &gql.Backup{
+		FileName:  "imagor-studio-backup-" + manifest.CreatedAt.Format("20060102-150405") + ".tar.gz",
+		Content:   base64.StdEncoding.EncodeToString(buf.Bytes()),
+		Migration: manifest.Migration,
+		CreatedAt: manifest.CreatedAt.Format(time.RFC3339),
+	}, nil
+}
@@ -0,0 +1,44 @@
+package resolver
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/backup"
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func TestCreateBackup(t *testing.T) {
+	db := testutil.NewDB(t)
+
+	resolver := newTestResolver(nil, new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop(), WithBackups(db))
+	_, err := resolver.Mutation().CreateBackup(createReadWriteContext("user-1"))
+	assert.Error(t, err)
+
+	result, err := resolver.Mutation().CreateBackup(createAdminContext("admin-1"))
+	require.NoError(t, err)
+	assert.Contains(t, result.FileName, ".tar.gz")
+	assert.NotEmpty(t, result.Migration)
+	content, err := base64.StdEncoding.DecodeString(result.Content)
+	require.NoError(t, err)
+
+	// The archive restores into another database
+	targetDB := testutil.NewDB(t)
+	_, err = backup.Restore(context.Background(), targetDB, bytes.NewReader(content))
+	require.NoError(t, err)
+}
+
+func TestCreateBackup_NotAvailable(t *testing.T) {
+	resolver := newTestResolver(nil, new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop())
+	_, err := resolver.Mutation().CreateBackup(createAdminContext("admin-1"))
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_AVAILABLE", gqlErr.Extensions["code"])
+}
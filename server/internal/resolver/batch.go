@@ -0,0 +1,76 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// maxBatchFileItems bounds the items of a single copyFiles or moveFiles call
+const maxBatchFileItems = 1000
+
+// CopyFiles is the resolver for the copyFiles field.
+func (r *mutationResolver) CopyFiles(ctx context.Context, items []*gql.FileTransferInput, spaceID *string) (*gql.BatchFileResult, error) {
+	return r.batchTransfer(ctx, items, func(sourcePath, destPath string) (bool, error) {
+		return r.CopyFile(ctx, sourcePath, destPath, spaceID)
+	})
+}
+
+// MoveFiles is the resolver for the moveFiles field.
+func (r *mutationResolver) MoveFiles(ctx context.Context, items []*gql.FileTransferInput, spaceID *string) (*gql.BatchFileResult, error) {
+	return r.batchTransfer(ctx, items, func(sourcePath, destPath string) (bool, error) {
+		return r.MoveFile(ctx, sourcePath, destPath, spaceID)
+	})
+}
+
+// batchTransfer applies transfer to every item in order. Failures are
+// reported per item; only a request without write scope or an oversized
+// batch fails as a whole.
+func (r *mutationResolver) batchTransfer(ctx context.Context, items []*gql.FileTransferInput, transfer func(sourcePath, destPath string) (bool, error)) (*gql.BatchFileResult, error) {
+	if err := RequirePermission(ctx, "write"); err != nil {
+		return nil, err
+	}
+	if len(items) > maxBatchFileItems {
+		return nil, &gqlerror.Error{
+			Message:    fmt.Sprintf("too many items, at most %d are allowed per request", maxBatchFileItems),
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+
+	result := &gql.BatchFileResult{Results: make([]*gql.FileTransferResult, 0, len(items))}
+	for _, item := range items {
+		if item == nil {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		itemResult := &gql.FileTransferResult{SourcePath: item.SourcePath, DestPath: item.DestPath}
+		if _, err := transfer(item.SourcePath, item.DestPath); err != nil {
+			message := err.Error()
+			itemResult.Error = &message
+			itemResult.Code = batchErrorCode(err)
+			result.Failed++
+		} else {
+			itemResult.Success = true
+			result.Succeeded++
+		}
+		result.Results = append(result.Results, itemResult)
+	}
+	return result, nil
+}
+
+// batchErrorCode returns the GraphQL error code of err, if any
+func batchErrorCode(err error) *string {
+	var gqlErr *gqlerror.Error
+	if !errors.As(err, &gqlErr) {
+		return nil
+	}
+	if code, ok := gqlErr.Extensions["code"].(string); ok {
+		return &code
+	}
+	return nil
+}
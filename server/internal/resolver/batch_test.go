@@ -0,0 +1,82 @@
+package resolver
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/pkg/apperror"
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newBatchTestResolver(t *testing.T) (*Resolver, string) {
+	t.Helper()
+	baseDir := t.TempDir()
+	writeTestFile(t, baseDir, "inbox/a.jpg")
+	writeTestFile(t, baseDir, "inbox/b.jpg")
+	writeTestFile(t, baseDir, "album/b.jpg")
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+	return newTestResolver(NewMockStorageProvider(stor), new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop()), baseDir
+}
+
+func TestMoveFiles(t *testing.T) {
+	resolver, baseDir := newBatchTestResolver(t)
+	ctx := createReadWriteContext("user-1")
+
+	result, err := resolver.Mutation().MoveFiles(ctx, []*gql.FileTransferInput{
+		{SourcePath: "inbox/a.jpg", DestPath: "album/a.jpg"},
+		{SourcePath: "inbox/b.jpg", DestPath: "album/b.jpg"},
+		{SourcePath: "inbox/missing.jpg", DestPath: "album/missing.jpg"},
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Succeeded)
+	assert.Equal(t, 2, result.Failed)
+	require.Len(t, result.Results, 3)
+	assert.True(t, result.Results[0].Success)
+	assert.False(t, result.Results[1].Success)
+	require.NotNil(t, result.Results[1].Code)
+	assert.Equal(t, apperror.ErrCodeFileAlreadyExists, *result.Results[1].Code)
+	assert.NotNil(t, result.Results[2].Error)
+
+	assert.FileExists(t, filepath.Join(baseDir, "album/a.jpg"))
+	_, err = os.Stat(filepath.Join(baseDir, "inbox/a.jpg"))
+	assert.True(t, os.IsNotExist(err))
+	assert.FileExists(t, filepath.Join(baseDir, "inbox/b.jpg"))
+}
+
+func TestCopyFiles(t *testing.T) {
+	resolver, baseDir := newBatchTestResolver(t)
+	ctx := createReadWriteContext("user-1")
+
+	result, err := resolver.Mutation().CopyFiles(ctx, []*gql.FileTransferInput{
+		{SourcePath: "inbox/a.jpg", DestPath: "backup/a.jpg"},
+		{SourcePath: "inbox/b.jpg", DestPath: "backup/b.jpg"},
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Succeeded)
+	assert.Zero(t, result.Failed)
+	assert.FileExists(t, filepath.Join(baseDir, "backup/b.jpg"))
+	assert.FileExists(t, filepath.Join(baseDir, "inbox/b.jpg"))
+}
+
+func TestBatchTransfer_Validation(t *testing.T) {
+	resolver, _ := newBatchTestResolver(t)
+
+	_, err := resolver.Mutation().MoveFiles(createReadOnlyContext("user-1"), []*gql.FileTransferInput{
+		{SourcePath: "inbox/a.jpg", DestPath: "album/a.jpg"},
+	}, nil)
+	assert.Error(t, err)
+
+	items := make([]*gql.FileTransferInput, maxBatchFileItems+1)
+	for i := range items {
+		items[i] = &gql.FileTransferInput{SourcePath: "inbox/a.jpg", DestPath: "album/a.jpg"}
+	}
+	_, err = resolver.Mutation().CopyFiles(createReadWriteContext("user-1"), items, nil)
+	assert.Error(t, err)
+}
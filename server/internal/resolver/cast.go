@@ -0,0 +1,158 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/bulkdownload"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/rawpreview"
+	"github.com/cshum/imagor/imagorpath"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+const (
+	// defaultCastURLTTL is how long castUrl URLs of originals are valid by
+	// default, long enough for a slideshow or a film
+	defaultCastURLTTL = 4 * time.Hour
+	// castImageWidth and castImageHeight bound image renditions to 4K
+	castImageWidth  = 3840
+	castImageHeight = 2160
+)
+
+// castExtensions are the files Chromecast and DLNA renderers play without
+// a rendition
+var castExtensions = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true,
+	".bmp": true, ".mp4": true, ".m4v": true, ".webm": true,
+}
+
+// CastURL is the resolver for the castUrl field.
+func (r *queryResolver) CastURL(ctx context.Context, filePath string, expiresIn *int, spaceID *string) (*gql.CastLink, error) {
+	ttl := defaultCastURLTTL
+	if expiresIn != nil {
+		if *expiresIn < 1 || time.Duration(*expiresIn)*time.Second > maxDownloadURLTTL {
+			return nil, &gqlerror.Error{
+				Message:    fmt.Sprintf("expiresIn must be between 1 and %d seconds", int(maxDownloadURLTTL.Seconds())),
+				Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+			}
+		}
+		ttl = time.Duration(*expiresIn) * time.Second
+	}
+	filePath, err := ScopePath(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+	if err := RequireReadPermission(ctx, filePath); err != nil {
+		return nil, err
+	}
+	filePath = strings.Trim(filePath, "/")
+	ext := strings.ToLower(path.Ext(filePath))
+
+	switch {
+	case castExtensions[ext] && CanDownloadOriginals(ctx):
+		return r.castOriginal(ctx, filePath, spaceID, ttl)
+	case videoExtensions[ext]:
+		if !CanDownloadOriginals(ctx) {
+			return nil, fmt.Errorf("insufficient permission: downloads are not allowed for this shared link")
+		}
+		stream, err := r.VideoStream(ctx, filePath, spaceID)
+		if err != nil {
+			return nil, err
+		}
+		return &gql.CastLink{
+			URL:         stream.URL,
+			ContentType: "video/mp4",
+			Mode:        gql.CastModeVideoStream,
+			ExpiresAt:   &stream.ExpiresAt,
+		}, nil
+	case castExtensions[ext] || convertibleExtensions[ext] || rawpreview.IsRaw(filePath):
+		return r.castImage(ctx, filePath, spaceID)
+	}
+	return nil, &gqlerror.Error{
+		Message:    fmt.Sprintf("cannot cast %s: not an image or video", filePath),
+		Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+	}
+}
+
+// castOriginal issues a download token serving the original file inline
+func (r *queryResolver) castOriginal(ctx context.Context, filePath string, spaceID *string, ttl time.Duration) (*gql.CastLink, error) {
+	if r.bulkDownloads == nil {
+		return nil, &gqlerror.Error{
+			Message:    "casting is not available",
+			Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+		}
+	}
+	stor, spaceConfig, err := r.downloadStorage(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	info, err := stor.Stat(ctx, filePath)
+	if err != nil || info.IsDir {
+		return nil, &gqlerror.Error{
+			Message:    fmt.Sprintf("file not found: %s", filePath),
+			Extensions: map[string]interface{}{"code": "NOT_FOUND"},
+		}
+	}
+	ownerID, _ := GetUserIDFromContext(ctx)
+	file := bulkdownload.File{Path: filePath, Size: info.Size, ContentType: r.storedContentType(ctx, spaceConfig, filePath, info)}
+	token, err := r.bulkDownloads.Manager().CreateWithTTL(stor, ownerID, []bulkdownload.File{file}, ttl)
+	if errors.Is(err, bulkdownload.ErrTooManyTokens) {
+		return nil, &gqlerror.Error{
+			Message:    "too many active downloads, try again later",
+			Extensions: map[string]interface{}{"code": "TOO_MANY_REQUESTS"},
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cast URL: %w", err)
+	}
+	expiresAt := token.ExpiresAt.UTC().Format(time.RFC3339)
+	return &gql.CastLink{
+		URL:         r.bulkDownloads.MediaPath(token.ID, filePath),
+		ContentType: bulkdownload.MediaType(filePath),
+		Mode:        gql.CastModeOriginal,
+		ExpiresAt:   &expiresAt,
+	}, nil
+}
+
+// castImage returns the signed imagor URL of a 4K JPEG rendition of an
+// image, watermarked like the thumbnails of the session
+func (r *queryResolver) castImage(ctx context.Context, filePath string, spaceID *string) (*gql.CastLink, error) {
+	if r.imagorProvider == nil {
+		return nil, &gqlerror.Error{
+			Message:    "image processing is not available",
+			Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+		}
+	}
+	spaceConfig, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	imagePath := filePath
+	if rawpreview.IsRaw(filePath) {
+		imagePath = rawpreview.Key(filePath)
+	}
+	params := withSessionWatermark(ctx, imagorpath.Params{
+		FitIn:  true,
+		Width:  castImageWidth,
+		Height: castImageHeight,
+		Filters: imagorpath.Filters{
+			{Name: "quality", Args: "90"},
+			{Name: "format", Args: "jpeg"},
+		},
+	})
+	imageURL, err := r.signedImagorURL(ctx, imagePath, params, spaceConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate cast URL: %w", err)
+	}
+	imageURL = r.clientImagorURL(ctx, absolutizeURL(r.processingOriginForResolvedSpace(ctx, spaceConfig), imageURL), imagePath, params)
+	return &gql.CastLink{
+		URL:         imageURL,
+		ContentType: "image/jpeg",
+		Mode:        gql.CastModeImage,
+	}, nil
+}
@@ -0,0 +1,102 @@
+package resolver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/bulkdownload"
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/pkg/auth"
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/cshum/imagor/imagorpath"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func newCastTestResolver(t *testing.T, imagorProvider ImagorProvider) (*Resolver, *bulkdownload.Handler) {
+	t.Helper()
+	baseDir := t.TempDir()
+	writeTestFile(t, baseDir, "album/a.jpg")
+	writeTestFile(t, baseDir, "album/b.heic")
+	writeTestFile(t, baseDir, "clip.mp4")
+	writeTestFile(t, baseDir, "clip.mkv")
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+	handler := bulkdownload.NewHandler(bulkdownload.NewManager(), "/api/downloads")
+	return newTestResolver(NewMockStorageProvider(stor), new(MockRegistryStore), new(MockUserStore), imagorProvider, &config.Config{}, nil, zap.NewNop(),
+		WithBulkDownloads(handler)), handler
+}
+
+func TestCastURL_Original(t *testing.T) {
+	resolver, handler := newCastTestResolver(t, nil)
+	ctx := createReadOnlyContext("user-1")
+
+	link, err := resolver.Query().CastURL(ctx, "/clip.mp4", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, gql.CastModeOriginal, link.Mode)
+	assert.Equal(t, "video/mp4", link.ContentType)
+	assert.True(t, strings.HasSuffix(link.URL, "/media/clip.mp4"))
+	require.NotNil(t, link.ExpiresAt)
+
+	req := httptest.NewRequest(http.MethodGet, strings.TrimPrefix(link.URL, "/api/downloads"), nil)
+	req.Header.Set("Range", "bytes=0-1")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusPartialContent, rec.Code)
+	assert.Equal(t, "video/mp4", rec.Header().Get("Content-Type"))
+
+	var gqlErr *gqlerror.Error
+	_, err = resolver.Query().CastURL(ctx, "missing.jpg", nil, nil)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_FOUND", gqlErr.Extensions["code"])
+	_, err = resolver.Query().CastURL(ctx, "notes.txt", nil, nil)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+	_, err = resolver.Query().CastURL(ctx, "clip.mp4", intPtr(0), nil)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+}
+
+func TestCastURL_Image(t *testing.T) {
+	mockImagorProvider := new(MockImagorProvider)
+	mockImagorProvider.On("GenerateURL", "album/b.heic", mock.Anything).Return("/imagor/signed/b.jpg", nil)
+	mockImagorProvider.On("GenerateURL", "album/a.jpg", mock.Anything).Return("/imagor/signed/a.jpg", nil)
+	resolver, _ := newCastTestResolver(t, mockImagorProvider)
+
+	link, err := resolver.Query().CastURL(createReadOnlyContext("user-1"), "album/b.heic", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, gql.CastModeImage, link.Mode)
+	assert.Equal(t, "image/jpeg", link.ContentType)
+	assert.Equal(t, "/imagor/signed/b.jpg", link.URL)
+	assert.Nil(t, link.ExpiresAt)
+	params := mockImagorProvider.Calls[0].Arguments.Get(1).(imagorpath.Params)
+	assert.True(t, params.FitIn)
+	assert.Equal(t, castImageWidth, params.Width)
+	assert.Contains(t, params.Filters, imagorpath.Filter{Name: "format", Args: "jpeg"})
+
+	// Shared links without downloads cast renditions instead of originals
+	share := auth.SetClaimsInContext(context.Background(), &auth.Claims{
+		UserID: "guest-id", Role: "guest", Scopes: []string{"read"}, Kind: auth.ShareLinkTokenKind,
+	})
+	link, err = resolver.Query().CastURL(share, "album/a.jpg", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, gql.CastModeImage, link.Mode)
+	_, err = resolver.Query().CastURL(share, "clip.mp4", nil, nil)
+	assert.Error(t, err)
+}
+
+func TestCastURL_VideoStreamNotEnabled(t *testing.T) {
+	resolver, _ := newCastTestResolver(t, nil)
+
+	_, err := resolver.Query().CastURL(createReadOnlyContext("user-1"), "clip.mkv", nil, nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_AVAILABLE", gqlErr.Extensions["code"])
+}
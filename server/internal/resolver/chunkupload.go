@@ -0,0 +1,196 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/cshum/imagor-studio/server/internal/chunkupload"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+// ChunkedUpload is the resolver for the chunkedUpload field.
+func (r *queryResolver) ChunkedUpload(ctx context.Context, id string) (*gql.ChunkedUpload, error) {
+	upload, err := r.getOwnChunkedUpload(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return r.toGQLChunkedUpload(upload), nil
+}
+
+// StartChunkedUpload is the resolver for the startChunkedUpload field.
+func (r *mutationResolver) StartChunkedUpload(ctx context.Context, path string, spaceID *string, contentType string, sizeBytes int) (*gql.ChunkedUpload, error) {
+	if r.chunkUploads == nil {
+		return nil, chunkUploadsNotAvailableError()
+	}
+	path, err := normalizeNewPath(path)
+	if err != nil {
+		return nil, err
+	}
+	path, err = r.routeUploadPath(ctx, path, contentType)
+	if err != nil {
+		return nil, err
+	}
+	if err := RequireWritePermission(ctx, path); err != nil {
+		return nil, err
+	}
+	if sizeBytes <= 0 {
+		return nil, &gqlerror.Error{
+			Message:    "invalid sizeBytes: must be greater than 0",
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	// Fails before the chunks are sent, the content is verified on completion
+	if err := r.checkUploadExtension(path); err != nil {
+		return nil, err
+	}
+	_, sp, err := r.resolveUploadStorageTarget(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureSpaceUploadAllowed(sp); err != nil {
+		return nil, err
+	}
+	if err := r.enforceHostedStorageQuota(ctx, sp, int64(sizeBytes)); err != nil {
+		return nil, err
+	}
+	if err := r.enforceStorageQuotas(ctx, sp, path, int64(sizeBytes)); err != nil {
+		return nil, err
+	}
+
+	ownerID, _ := GetUserIDFromContext(ctx)
+	uploadSpaceID := ""
+	if spaceID != nil {
+		uploadSpaceID = *spaceID
+	}
+	upload, err := r.chunkUploads.Start(ownerID, path, uploadSpaceID, contentType, int64(sizeBytes))
+	if err != nil {
+		return nil, chunkUploadError(err)
+	}
+	r.logger.Debug("Chunked upload started",
+		zap.String("uploadID", upload.ID),
+		zap.String("path", upload.Path),
+		zap.Int64("sizeBytes", upload.Size))
+	return r.toGQLChunkedUpload(upload), nil
+}
+
+// UploadChunk is the resolver for the uploadChunk field.
+func (r *mutationResolver) UploadChunk(ctx context.Context, id string, index int, content graphql.Upload) (*gql.ChunkedUpload, error) {
+	if _, err := r.getOwnChunkedUpload(ctx, id); err != nil {
+		return nil, err
+	}
+	upload, err := r.chunkUploads.WriteChunk(id, index, content.File)
+	if err != nil {
+		return nil, chunkUploadError(err)
+	}
+	return r.toGQLChunkedUpload(upload), nil
+}
+
+// CompleteChunkedUpload is the resolver for the completeChunkedUpload field.
+func (r *mutationResolver) CompleteChunkedUpload(ctx context.Context, id string) (bool, error) {
+	upload, err := r.getOwnChunkedUpload(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	if err := RequireWritePermission(ctx, upload.Path); err != nil {
+		return false, err
+	}
+	var spaceID *string
+	if upload.SpaceID != "" {
+		spaceID = &upload.SpaceID
+	}
+	stor, sp, err := r.resolveUploadStorageTarget(ctx, spaceID)
+	if err != nil {
+		return false, err
+	}
+	if err := ensureSpaceUploadAllowed(sp); err != nil {
+		return false, err
+	}
+
+	upload, content, err := r.chunkUploads.Open(id)
+	if err != nil {
+		return false, chunkUploadError(err)
+	}
+	_, err = r.storeUpload(ctx, stor, sp, upload.Path, r.fixedPathCollisionMode(), content, upload.Size)
+	_ = content.Close()
+	if err != nil {
+		// Keep the chunks so the client can retry completing
+		r.chunkUploads.Reopen(id)
+		return false, err
+	}
+	r.chunkUploads.Remove(id)
+	r.logger.Debug("Chunked upload completed", zap.String("uploadID", id), zap.String("path", upload.Path))
+	return true, nil
+}
+
+// AbortChunkedUpload is the resolver for the abortChunkedUpload field.
+func (r *mutationResolver) AbortChunkedUpload(ctx context.Context, id string) (bool, error) {
+	if _, err := r.getOwnChunkedUpload(ctx, id); err != nil {
+		return false, err
+	}
+	r.chunkUploads.Remove(id)
+	return true, nil
+}
+
+// getOwnChunkedUpload returns an upload started by the current user.
+// Uploads of other users are reported as not found.
+func (r *Resolver) getOwnChunkedUpload(ctx context.Context, id string) (*chunkupload.Upload, error) {
+	if r.chunkUploads == nil {
+		return nil, chunkUploadsNotAvailableError()
+	}
+	if err := RequirePermission(ctx, "write"); err != nil {
+		return nil, err
+	}
+	upload, err := r.chunkUploads.Get(id)
+	if err != nil {
+		return nil, chunkUploadError(err)
+	}
+	if userID, _ := GetUserIDFromContext(ctx); userID != upload.OwnerID {
+		return nil, chunkUploadError(chunkupload.ErrUploadNotFound)
+	}
+	return upload, nil
+}
+
+func (r *Resolver) toGQLChunkedUpload(upload *chunkupload.Upload) *gql.ChunkedUpload {
+	return &gql.ChunkedUpload{
+		ID:             upload.ID,
+		Path:           upload.Path,
+		SizeBytes:      int(upload.Size),
+		ChunkSize:      int(upload.ChunkSize),
+		ChunkCount:     upload.ChunkCount(),
+		ReceivedChunks: upload.Received(),
+		ExpiresAt:      upload.UpdatedAt.Add(r.chunkUploads.TTL()).UTC().Format(time.RFC3339),
+	}
+}
+
+func chunkUploadsNotAvailableError() error {
+	return &gqlerror.Error{
+		Message:    "chunked uploads are not available",
+		Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+	}
+}
+
+// chunkUploadError maps chunk upload errors to client errors
+func chunkUploadError(err error) error {
+	code := ""
+	switch {
+	case errors.Is(err, chunkupload.ErrUploadNotFound):
+		code = "NOT_FOUND"
+	case errors.Is(err, chunkupload.ErrTooManyUploads):
+		code = "TOO_MANY_REQUESTS"
+	case errors.Is(err, chunkupload.ErrInvalidSize), errors.Is(err, chunkupload.ErrInvalidChunk),
+		errors.Is(err, chunkupload.ErrChunkSize), errors.Is(err, chunkupload.ErrIncomplete),
+		errors.Is(err, chunkupload.ErrCompleting):
+		code = "BAD_USER_INPUT"
+	default:
+		return fmt.Errorf("chunked upload failed: %w", err)
+	}
+	return &gqlerror.Error{
+		Message:    err.Error(),
+		Extensions: map[string]interface{}{"code": code},
+	}
+}
@@ -0,0 +1,109 @@
+package resolver
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/cshum/imagor-studio/server/internal/chunkupload"
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func newChunkUploadTestResolver(t *testing.T) (*Resolver, string) {
+	t.Helper()
+	baseDir := t.TempDir()
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+	manager, err := chunkupload.NewManager(t.TempDir(), chunkupload.WithChunkSize(4))
+	require.NoError(t, err)
+	logger, _ := zap.NewDevelopment()
+	return newTestResolver(NewMockStorageProvider(stor), new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, logger,
+		WithChunkUploads(manager)), baseDir
+}
+
+func chunk(content string) graphql.Upload {
+	return graphql.Upload{File: strings.NewReader(content), Size: int64(len(content))}
+}
+
+func TestChunkedUpload(t *testing.T) {
+	resolver, baseDir := newChunkUploadTestResolver(t)
+	ctx := createReadWriteContext("user-1")
+
+	started, err := resolver.Mutation().StartChunkedUpload(ctx, "videos/clip.mp4", nil, "video/mp4", 10)
+	require.NoError(t, err)
+	assert.Equal(t, "videos/clip.mp4", started.Path)
+	assert.Equal(t, 4, started.ChunkSize)
+	assert.Equal(t, 3, started.ChunkCount)
+	assert.Empty(t, started.ReceivedChunks)
+
+	_, err = resolver.Mutation().UploadChunk(ctx, started.ID, 2, chunk("89"))
+	require.NoError(t, err)
+	_, err = resolver.Mutation().UploadChunk(ctx, started.ID, 0, chunk("0123"))
+	require.NoError(t, err)
+
+	// Resuming picks up the chunks received so far
+	progress, err := resolver.Query().ChunkedUpload(ctx, started.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 2}, progress.ReceivedChunks)
+
+	var gqlErr *gqlerror.Error
+	_, err = resolver.Mutation().CompleteChunkedUpload(ctx, started.ID)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+
+	_, err = resolver.Mutation().UploadChunk(ctx, started.ID, 1, chunk("4567"))
+	require.NoError(t, err)
+	ok, err := resolver.Mutation().CompleteChunkedUpload(ctx, started.ID)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	content, err := os.ReadFile(filepath.Join(baseDir, "videos", "clip.mp4"))
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789", string(content))
+
+	_, err = resolver.Query().ChunkedUpload(ctx, started.ID)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_FOUND", gqlErr.Extensions["code"])
+}
+
+func TestChunkedUpload_Access(t *testing.T) {
+	resolver, _ := newChunkUploadTestResolver(t)
+	ctx := createReadWriteContext("user-1")
+
+	_, err := resolver.Mutation().StartChunkedUpload(createReadOnlyContext("user-1"), "videos/clip.mp4", nil, "video/mp4", 10)
+	assert.Error(t, err)
+	_, err = resolver.Mutation().StartChunkedUpload(ctx, "videos/clip.mp4", nil, "video/mp4", 0)
+	assert.Error(t, err)
+
+	started, err := resolver.Mutation().StartChunkedUpload(ctx, "videos/clip.mp4", nil, "video/mp4", 10)
+	require.NoError(t, err)
+
+	// Uploads of other users are hidden
+	var gqlErr *gqlerror.Error
+	_, err = resolver.Mutation().UploadChunk(createReadWriteContext("user-2"), started.ID, 0, chunk("0123"))
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_FOUND", gqlErr.Extensions["code"])
+	_, err = resolver.Mutation().AbortChunkedUpload(createReadWriteContext("user-2"), started.ID)
+	assert.Error(t, err)
+
+	_, err = resolver.Mutation().UploadChunk(ctx, started.ID, 0, chunk("012"))
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+
+	ok, err := resolver.Mutation().AbortChunkedUpload(ctx, started.ID)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	_, err = resolver.Query().ChunkedUpload(ctx, started.ID)
+	assert.Error(t, err)
+
+	disabled := newTestResolver(NewMockStorageProvider(new(MockStorage)), new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop())
+	_, err = disabled.Mutation().StartChunkedUpload(ctx, "videos/clip.mp4", nil, "video/mp4", 10)
+	assert.ErrorContains(t, err, "not available")
+}
@@ -0,0 +1,236 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/commentstore"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+// guestAuthorName is shown for comments of guests without a user record
+const guestAuthorName = "Guest"
+
+// commentScope returns the comment namespace of a space
+func (r *Resolver) commentScope(ctx context.Context, spaceID *string) (string, error) {
+	if r.commentStore == nil {
+		return "", &gqlerror.Error{
+			Message:    "comments are not available",
+			Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+		}
+	}
+	spaceConfig, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err != nil {
+		return "", err
+	}
+	return fileMetadataScope(spaceConfig), nil
+}
+
+func commentError(err error) error {
+	switch {
+	case errors.Is(err, commentstore.ErrNotFound):
+		return &gqlerror.Error{
+			Message:    err.Error(),
+			Extensions: map[string]interface{}{"code": "NOT_FOUND"},
+		}
+	case errors.Is(err, commentstore.ErrInvalid):
+		return &gqlerror.Error{
+			Message:    err.Error(),
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	return fmt.Errorf("comment operation failed: %w", err)
+}
+
+func toGQLComment(comment *commentstore.Comment) *gql.Comment {
+	result := &gql.Comment{
+		ID:         comment.ID,
+		Path:       comment.Path,
+		Text:       comment.Text,
+		AuthorID:   comment.AuthorID,
+		AuthorName: comment.AuthorName,
+		CreatedAt:  comment.CreatedAt.Format(time.RFC3339),
+	}
+	if comment.EditedAt != nil {
+		editedAt := comment.EditedAt.Format(time.RFC3339)
+		result.EditedAt = &editedAt
+	}
+	return result
+}
+
+// requireCommentAccess rejects sessions that may read files but not leave
+// comments, returning the commenting user
+func requireCommentAccess(ctx context.Context) (string, error) {
+	if IsPublicPreviewMode(ctx) || IsShareLinkSession(ctx) {
+		return "", fmt.Errorf("comments cannot be changed from this session")
+	}
+	return GetUserIDFromContext(ctx)
+}
+
+// getComment loads a comment, checking the user may still reach its file
+func (r *Resolver) getComment(ctx context.Context, scope, id string) (*commentstore.Comment, error) {
+	comment, err := r.commentStore.Get(ctx, scope, id)
+	if err != nil {
+		return nil, commentError(err)
+	}
+	if err := RequireReadPermission(ctx, comment.Path); err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
+
+// Comments is the resolver for the comments field.
+func (r *queryResolver) Comments(ctx context.Context, path string, spaceID *string) ([]*gql.Comment, error) {
+	path, err := ScopePath(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	path = strings.Trim(path, "/")
+	if err := RequireReadPermission(ctx, path); err != nil {
+		return nil, err
+	}
+	scope, err := r.commentScope(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	comments, err := r.commentStore.List(ctx, scope, path)
+	if err != nil {
+		return nil, commentError(err)
+	}
+	result := make([]*gql.Comment, 0, len(comments))
+	for _, comment := range comments {
+		result = append(result, toGQLComment(comment))
+	}
+	return result, nil
+}
+
+// AddComment is the resolver for the addComment field.
+func (r *mutationResolver) AddComment(ctx context.Context, path string, text string, spaceID *string) (*gql.Comment, error) {
+	path, err := ScopePath(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	path = strings.Trim(path, "/")
+	if err := RequireReadPermission(ctx, path); err != nil {
+		return nil, err
+	}
+	userID, err := requireCommentAccess(ctx)
+	if err != nil {
+		return nil, err
+	}
+	scope, err := r.commentScope(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	stor, err := r.getSpaceStorageByID(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stor.Stat(ctx, path); err != nil {
+		return nil, &gqlerror.Error{
+			Message:    fmt.Sprintf("file not found: %s", path),
+			Extensions: map[string]interface{}{"code": "NOT_FOUND"},
+		}
+	}
+	comment, err := r.commentStore.Add(ctx, scope, path, userID, r.commentAuthorName(ctx, userID), text)
+	if err != nil {
+		return nil, commentError(err)
+	}
+	return toGQLComment(comment), nil
+}
+
+// EditComment is the resolver for the editComment field.
+func (r *mutationResolver) EditComment(ctx context.Context, id string, text string, spaceID *string) (*gql.Comment, error) {
+	userID, err := requireCommentAccess(ctx)
+	if err != nil {
+		return nil, err
+	}
+	scope, err := r.commentScope(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	comment, err := r.getComment(ctx, scope, id)
+	if err != nil {
+		return nil, err
+	}
+	if comment.AuthorID != userID {
+		return nil, fmt.Errorf("only the author can edit a comment")
+	}
+	comment, err = r.commentStore.Update(ctx, scope, id, text)
+	if err != nil {
+		return nil, commentError(err)
+	}
+	return toGQLComment(comment), nil
+}
+
+// DeleteComment is the resolver for the deleteComment field.
+func (r *mutationResolver) DeleteComment(ctx context.Context, id string, spaceID *string) (bool, error) {
+	userID, err := requireCommentAccess(ctx)
+	if err != nil {
+		return false, err
+	}
+	scope, err := r.commentScope(ctx, spaceID)
+	if err != nil {
+		return false, err
+	}
+	comment, err := r.getComment(ctx, scope, id)
+	if err != nil {
+		return false, err
+	}
+	if comment.AuthorID != userID && RequireAdminPermission(ctx) != nil {
+		return false, fmt.Errorf("only the author or an admin can delete a comment")
+	}
+	if err := r.commentStore.Delete(ctx, scope, id); err != nil {
+		return false, commentError(err)
+	}
+	return true, nil
+}
+
+// commentAuthorName returns the display name recorded with a comment
+func (r *Resolver) commentAuthorName(ctx context.Context, userID string) string {
+	if IsGuestUser(ctx) || r.userStore == nil {
+		return guestAuthorName
+	}
+	user, err := r.userStore.GetByID(ctx, userID)
+	if err != nil || user == nil {
+		return guestAuthorName
+	}
+	if user.DisplayName != "" {
+		return user.DisplayName
+	}
+	return user.Username
+}
+
+// moveComments keeps comments following a moved file or folder. Failures
+// are logged rather than failing the move that already happened.
+func (r *Resolver) moveComments(ctx context.Context, spaceID *string, sourcePath, destPath string) {
+	if r.commentStore == nil {
+		return
+	}
+	scope, err := r.commentScope(ctx, spaceID)
+	if err == nil {
+		err = r.commentStore.MoveFilePath(ctx, scope, strings.Trim(sourcePath, "/"), strings.Trim(destPath, "/"))
+	}
+	if err != nil {
+		r.logger.Warn("Failed to move comments", zap.String("source", sourcePath), zap.String("dest", destPath), zap.Error(err))
+	}
+}
+
+// removeComments drops comments of a deleted file or folder
+func (r *Resolver) removeComments(ctx context.Context, spaceID *string, path string) {
+	if r.commentStore == nil {
+		return
+	}
+	scope, err := r.commentScope(ctx, spaceID)
+	if err == nil {
+		err = r.commentStore.RemoveFilePath(ctx, scope, strings.Trim(path, "/"))
+	}
+	if err != nil {
+		r.logger.Warn("Failed to remove comments", zap.String("path", path), zap.Error(err))
+	}
+}
@@ -0,0 +1,113 @@
+package resolver
+
+import (
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/commentstore"
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/cshum/imagor-studio/server/pkg/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func newCommentTestResolver(t *testing.T) (*Resolver, string) {
+	t.Helper()
+	db := testutil.NewDB(t)
+
+	baseDir := t.TempDir()
+	writeTestFile(t, baseDir, "family/a.jpg")
+	writeTestFile(t, baseDir, "private/b.jpg")
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+	mockUserStore := new(MockUserStore)
+	mockUserStore.On("GetByID", mock.Anything, "user-1").Return(&user.User{ID: "user-1", DisplayName: "Alice"}, nil)
+	mockUserStore.On("GetByID", mock.Anything, mock.Anything).Return((*user.User)(nil), nil)
+	logger := zap.NewNop()
+	return newTestResolver(NewMockStorageProvider(stor), new(MockRegistryStore), mockUserStore, nil, &config.Config{}, nil, logger,
+		WithCommentStore(commentstore.New(db, logger))), baseDir
+}
+
+func TestComments_AddEditDelete(t *testing.T) {
+	resolver, _ := newCommentTestResolver(t)
+	alice := createReadOnlyContext("user-1")
+	bob := createReadOnlyContext("user-2")
+
+	comment, err := resolver.Mutation().AddComment(alice, "/family/a.jpg", "Grandma's birthday", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "family/a.jpg", comment.Path)
+	assert.Equal(t, "Alice", comment.AuthorName)
+	assert.Nil(t, comment.EditedAt)
+
+	_, err = resolver.Mutation().AddComment(alice, "family/missing.jpg", "Hello", nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_FOUND", gqlErr.Extensions["code"])
+	_, err = resolver.Mutation().AddComment(alice, "family/a.jpg", "  ", nil)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+
+	_, err = resolver.Mutation().EditComment(bob, comment.ID, "Not mine", nil)
+	assert.Error(t, err)
+	edited, err := resolver.Mutation().EditComment(alice, comment.ID, "Grandma's 80th birthday", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Grandma's 80th birthday", edited.Text)
+	assert.NotNil(t, edited.EditedAt)
+
+	comments, err := resolver.Query().Comments(bob, "family/a.jpg", nil)
+	require.NoError(t, err)
+	require.Len(t, comments, 1)
+	assert.Equal(t, "Grandma's 80th birthday", comments[0].Text)
+
+	_, err = resolver.Mutation().DeleteComment(bob, comment.ID, nil)
+	assert.Error(t, err)
+	deleted, err := resolver.Mutation().DeleteComment(createAdminContext("admin-1"), comment.ID, nil)
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	comments, err = resolver.Query().Comments(alice, "family/a.jpg", nil)
+	require.NoError(t, err)
+	assert.Empty(t, comments)
+}
+
+func TestComments_EmbeddedGuestPathPrefix(t *testing.T) {
+	resolver, _ := newCommentTestResolver(t)
+	guest := createEmbeddedUserContext("guest-1", "guest", []string{"read"}, "/family")
+
+	comment, err := resolver.Mutation().AddComment(guest, "family/a.jpg", "Lovely", nil)
+	require.NoError(t, err)
+	assert.Equal(t, guestAuthorName, comment.AuthorName)
+
+	_, err = resolver.Mutation().AddComment(guest, "private/b.jpg", "Peek", nil)
+	assert.Error(t, err)
+	_, err = resolver.Query().Comments(guest, "private/b.jpg", nil)
+	assert.Error(t, err)
+
+	// Comments on files outside the prefix cannot be touched by id either
+	private, err := resolver.Mutation().AddComment(createReadOnlyContext("user-1"), "private/b.jpg", "Secret", nil)
+	require.NoError(t, err)
+	_, err = resolver.Mutation().DeleteComment(createEmbeddedUserContext("user-1", "user", []string{"read"}, "/family"), private.ID, nil)
+	assert.Error(t, err)
+}
+
+func TestComments_FollowMovedAndDeletedFiles(t *testing.T) {
+	resolver, _ := newCommentTestResolver(t)
+	ctx := createReadWriteContext("user-1")
+
+	_, err := resolver.Mutation().AddComment(ctx, "family/a.jpg", "Note", nil)
+	require.NoError(t, err)
+	_, err = resolver.Mutation().MoveFile(ctx, "family", "archive", nil)
+	require.NoError(t, err)
+	comments, err := resolver.Query().Comments(ctx, "archive/a.jpg", nil)
+	require.NoError(t, err)
+	assert.Len(t, comments, 1)
+
+	_, err = resolver.Mutation().DeleteFile(ctx, "archive/a.jpg", nil)
+	require.NoError(t, err)
+	comments, err = resolver.Query().Comments(ctx, "archive/a.jpg", nil)
+	require.NoError(t, err)
+	assert.Empty(t, comments)
+}
@@ -0,0 +1,256 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/imagortemplate"
+	"github.com/cshum/imagor-studio/server/pkg/space"
+	"github.com/cshum/imagor/imagorpath"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultCompareWidth  = 1200
+	defaultCompareHeight = 900
+	maxCompareDimension  = 4096
+	// compareGap separates the two panels of the side-by-side rendering
+	compareGap = 16
+	// compareOverlayAlpha is the transparency of B in the overlay rendering
+	compareOverlayAlpha = 50
+	// aspectRatioTolerance is the relative difference below which two
+	// aspect ratios are considered equal
+	aspectRatioTolerance = 0.01
+)
+
+// CompareImages is the resolver for the compareImages field.
+func (r *queryResolver) CompareImages(ctx context.Context, pathA string, pathB string, spaceID *string, maxWidth *int, maxHeight *int) (*gql.ImageComparison, error) {
+	pathA, err := ScopePath(ctx, pathA)
+	if err != nil {
+		return nil, err
+	}
+	pathB, err = ScopePath(ctx, pathB)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range []string{pathA, pathB} {
+		if err := RequireReadPermission(ctx, p); err != nil {
+			return nil, err
+		}
+	}
+	if r.imagorProvider == nil {
+		return nil, &gqlerror.Error{
+			Message:    "image processing is not available",
+			Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+		}
+	}
+	boxWidth, err := compareBoxDimension("maxWidth", maxWidth, defaultCompareWidth)
+	if err != nil {
+		return nil, err
+	}
+	boxHeight, err := compareBoxDimension("maxHeight", maxHeight, defaultCompareHeight)
+	if err != nil {
+		return nil, err
+	}
+	spaceConfig, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	dimsA, err := r.fetchImageDimensions(ctx, pathA, spaceConfig)
+	if err != nil {
+		r.logger.Warn("Failed to read image dimensions for comparison", zap.String("path", pathA), zap.Error(err))
+		return nil, fmt.Errorf("failed to read dimensions of %q: %w", pathA, err)
+	}
+	dimsB, err := r.fetchImageDimensions(ctx, pathB, spaceConfig)
+	if err != nil {
+		r.logger.Warn("Failed to read image dimensions for comparison", zap.String("path", pathB), zap.Error(err))
+		return nil, fmt.Errorf("failed to read dimensions of %q: %w", pathB, err)
+	}
+
+	plan := newComparePlan(pathA, pathB, dimsA, dimsB, boxWidth, boxHeight)
+	sign := func(imagePath string, params imagorpath.Params) (string, error) {
+		return r.compareURL(ctx, imagePath, params, spaceConfig)
+	}
+	urlA, err := sign(pathA, plan.panelA())
+	if err != nil {
+		return nil, err
+	}
+	urlB, err := sign(pathB, plan.panelB())
+	if err != nil {
+		return nil, err
+	}
+	sideBySideURL, err := sign(compareCanvasImage, plan.sideBySide())
+	if err != nil {
+		return nil, err
+	}
+	overlayURL, err := sign(pathA, plan.overlay())
+	if err != nil {
+		return nil, err
+	}
+	diffURL, err := sign(pathA, plan.diff())
+	if err != nil {
+		return nil, err
+	}
+
+	return &gql.ImageComparison{
+		A:             &gql.ComparedImage{Path: pathA, Width: dimsA.Width, Height: dimsA.Height, URL: urlA},
+		B:             &gql.ComparedImage{Path: pathB, Width: dimsB.Width, Height: dimsB.Height, URL: urlB},
+		Alignment:     compareAlignment(dimsA, dimsB, plan.panel),
+		SideBySideURL: sideBySideURL,
+		OverlayURL:    overlayURL,
+		DiffURL:       diffURL,
+	}, nil
+}
+
+// compareURL signs a comparison rendering the same way as thumbnails, so the
+// compare view is tagged as internal traffic
+func (r *Resolver) compareURL(ctx context.Context, imagePath string, params imagorpath.Params, spaceConfig *space.Space) (string, error) {
+	params = withSessionWatermark(ctx, params)
+	url, err := r.generateImagorURLForSpaceConfig(imagePath, params, spaceConfig)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate imagor URL: %w", err)
+	}
+	url = absolutizeURL(r.processingOriginForResolvedSpace(ctx, spaceConfig), url)
+	return r.clientImagorURL(ctx, url, imagePath, params), nil
+}
+
+func compareBoxDimension(name string, value *int, fallback int) (int, error) {
+	if value == nil {
+		return fallback, nil
+	}
+	if *value <= 0 || *value > maxCompareDimension {
+		return 0, &gqlerror.Error{
+			Message:    fmt.Sprintf("%s must be between 1 and %d", name, maxCompareDimension),
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	return *value, nil
+}
+
+// compareAlignment describes how B lines up with A
+func compareAlignment(a, b, panel imagortemplate.Dimensions) *gql.ComparisonAlignment {
+	ratioA := float64(a.Width) / float64(a.Height)
+	ratioB := float64(b.Width) / float64(b.Height)
+	return &gql.ComparisonAlignment{
+		SameDimensions:   a == b,
+		AspectRatioMatch: math.Abs(ratioA-ratioB)/ratioA <= aspectRatioTolerance,
+		ScaleX:           roundScale(float64(b.Width) / float64(a.Width)),
+		ScaleY:           roundScale(float64(b.Height) / float64(a.Height)),
+		Width:            panel.Width,
+		Height:           panel.Height,
+	}
+}
+
+func roundScale(v float64) float64 {
+	return math.Round(v*10000) / 10000
+}
+
+// fitWithin scales d down to fit a width x height box, keeping its aspect
+// ratio. Images smaller than the box are not upscaled.
+func fitWithin(d imagortemplate.Dimensions, width, height int) imagortemplate.Dimensions {
+	scale := math.Min(1, math.Min(float64(width)/float64(d.Width), float64(height)/float64(d.Height)))
+	return imagortemplate.Dimensions{
+		Width:  max(1, int(math.Round(float64(d.Width)*scale))),
+		Height: max(1, int(math.Round(float64(d.Height)*scale))),
+	}
+}
+
+// compareCanvasImage is the blank imagor source the side-by-side panels are
+// drawn on
+const compareCanvasImage = "color:none"
+
+// comparePlan lays out the comparison renderings. The panel is A fitted into
+// the requested box; B is stretched to the panel for the overlay and diff so
+// that exports at a different resolution line up pixel for pixel.
+type comparePlan struct {
+	pathA, pathB string
+	dimsB        imagortemplate.Dimensions
+	panel        imagortemplate.Dimensions
+}
+
+func newComparePlan(pathA, pathB string, dimsA, dimsB imagortemplate.Dimensions, boxWidth, boxHeight int) comparePlan {
+	return comparePlan{
+		pathA: pathA,
+		pathB: pathB,
+		dimsB: dimsB,
+		panel: fitWithin(dimsA, boxWidth, boxHeight),
+	}
+}
+
+func (p comparePlan) panelA() imagorpath.Params {
+	return imagorpath.Params{
+		Width:   p.panel.Width,
+		Height:  p.panel.Height,
+		Filters: compareOutputFilters(),
+	}
+}
+
+func (p comparePlan) panelB() imagorpath.Params {
+	return imagorpath.Params{
+		Width:   p.panel.Width,
+		Height:  p.panel.Height,
+		Stretch: true,
+		Filters: compareOutputFilters(),
+	}
+}
+
+func (p comparePlan) sideBySide() imagorpath.Params {
+	// B keeps its own aspect ratio here and is centred in the right half
+	fittedB := fitWithin(p.dimsB, p.panel.Width, p.panel.Height)
+	left := inlineImagorPath(p.pathA, imagorpath.Params{Width: p.panel.Width, Height: p.panel.Height})
+	right := inlineImagorPath(p.pathB, imagorpath.Params{Width: fittedB.Width, Height: fittedB.Height})
+	rightX := p.panel.Width + compareGap + (p.panel.Width-fittedB.Width)/2
+	rightY := (p.panel.Height - fittedB.Height) / 2
+	filters := imagorpath.Filters{
+		{Name: "image", Args: fmt.Sprintf("%s,0,0", left)},
+		{Name: "image", Args: fmt.Sprintf("%s,%d,%d", right, rightX, rightY)},
+	}
+	return imagorpath.Params{
+		Width:   2*p.panel.Width + compareGap,
+		Height:  p.panel.Height,
+		Filters: append(filters, compareOutputFilters()...),
+	}
+}
+
+func (p comparePlan) overlay() imagorpath.Params {
+	return p.blend(fmt.Sprintf("%d", compareOverlayAlpha))
+}
+
+func (p comparePlan) diff() imagorpath.Params {
+	return p.blend("0,difference")
+}
+
+// blend draws the stretched B over A with the given alpha and blend mode args
+func (p comparePlan) blend(args string) imagorpath.Params {
+	stretchedB := inlineImagorPath(p.pathB, imagorpath.Params{Width: p.panel.Width, Height: p.panel.Height, Stretch: true})
+	filters := imagorpath.Filters{
+		{Name: "image", Args: fmt.Sprintf("%s,0,0,%s", stretchedB, args)},
+	}
+	return imagorpath.Params{
+		Width:   p.panel.Width,
+		Height:  p.panel.Height,
+		Filters: append(filters, compareOutputFilters()...),
+	}
+}
+
+func compareOutputFilters() imagorpath.Filters {
+	return imagorpath.Filters{
+		{Name: "quality", Args: "90"},
+		{Name: "format", Args: "webp"},
+	}
+}
+
+// inlineImagorPath builds an unsigned path for use inside the image filter.
+// Paths that would break filter argument parsing are base64 encoded.
+func inlineImagorPath(imagePath string, params imagorpath.Params) string {
+	params.Image = imagePath
+	if strings.ContainsAny(imagePath, " ?#&(),") {
+		params.Base64Image = true
+	}
+	return "/" + strings.TrimPrefix(imagorpath.GenerateUnsafe(params), "unsafe/")
+}
@@ -0,0 +1,85 @@
+package resolver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/imagortemplate"
+	"github.com/cshum/imagor/imagorpath"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestCompareImages(t *testing.T) {
+	metaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("image") == "b" {
+			_, _ = w.Write([]byte(`{"width":2000,"height":1500}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"width":4000,"height":3000}`))
+	}))
+	defer metaServer.Close()
+
+	mockImagorProvider := new(MockImagorProvider)
+	mockImagorProvider.On("Imagor").Return((*imagor.Imagor)(nil))
+	mockImagorProvider.On("GenerateURL", "shots/a.jpg", imagorpath.Params{Meta: true}).Return(metaServer.URL+"?image=a", nil)
+	mockImagorProvider.On("GenerateURL", "exports/b.jpg", imagorpath.Params{Meta: true}).Return(metaServer.URL+"?image=b", nil)
+
+	var generated []imagorpath.Params
+	mockImagorProvider.On("GenerateURL", mock.Anything, mock.MatchedBy(func(p imagorpath.Params) bool { return !p.Meta })).
+		Run(func(args mock.Arguments) { generated = append(generated, args.Get(1).(imagorpath.Params)) }).
+		Return("/imagor/rendered.webp", nil)
+
+	resolver := newTestResolver(NewMockStorageProvider(new(MockStorage)), new(MockRegistryStore), new(MockUserStore),
+		mockImagorProvider, &config.Config{}, nil, zap.NewNop())
+
+	result, err := resolver.Query().CompareImages(createReadOnlyContext("test-user"), "shots/a.jpg", "exports/b.jpg", nil, nil, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, 4000, result.A.Width)
+	assert.Equal(t, 1500, result.B.Height)
+	assert.False(t, result.Alignment.SameDimensions)
+	assert.True(t, result.Alignment.AspectRatioMatch)
+	assert.Equal(t, 0.5, result.Alignment.ScaleX)
+	assert.Equal(t, 1200, result.Alignment.Width)
+	assert.Equal(t, 900, result.Alignment.Height)
+	assert.Equal(t, "/imagor/rendered.webp", result.DiffURL)
+
+	require.Len(t, generated, 5)
+	sideBySide, overlay, diff := generated[2], generated[3], generated[4]
+	assert.True(t, generated[1].Stretch)
+	assert.Equal(t, 2*1200+compareGap, sideBySide.Width)
+	assert.Equal(t, "image", sideBySide.Filters[1].Name)
+	assert.Equal(t, "/1200x900/exports/b.jpg,1216,0", sideBySide.Filters[1].Args)
+	assert.Equal(t, "/stretch/1200x900/exports/b.jpg,0,0,50", overlay.Filters[0].Args)
+	assert.Equal(t, "/stretch/1200x900/exports/b.jpg,0,0,0,difference", diff.Filters[0].Args)
+	mockImagorProvider.AssertExpectations(t)
+}
+
+func TestCompareImages_InvalidBox(t *testing.T) {
+	resolver := newTestResolver(NewMockStorageProvider(new(MockStorage)), new(MockRegistryStore), new(MockUserStore),
+		new(MockImagorProvider), &config.Config{}, nil, zap.NewNop())
+
+	_, err := resolver.Query().CompareImages(createReadOnlyContext("test-user"), "a.jpg", "b.jpg", nil, intPtr(0), nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "maxWidth")
+}
+
+func TestFitWithin(t *testing.T) {
+	assert.Equal(t, imagortemplate.Dimensions{Width: 1200, Height: 675},
+		fitWithin(imagortemplate.Dimensions{Width: 1920, Height: 1080}, 1200, 900))
+	assert.Equal(t, imagortemplate.Dimensions{Width: 640, Height: 480},
+		fitWithin(imagortemplate.Dimensions{Width: 640, Height: 480}, 1200, 900))
+}
+
+func TestInlineImagorPath_EncodesSpecialCharacters(t *testing.T) {
+	path := inlineImagorPath("a,b.jpg", imagorpath.Params{Width: 10, Height: 10})
+	assert.NotContains(t, path, ",")
+	assert.Contains(t, path, "b64:")
+}
@@ -0,0 +1,87 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// configValuesProvider is implemented by *config.Config
+type configValuesProvider interface {
+	Values() []config.Value
+}
+
+var configSources = map[string]gql.ConfigSource{
+	config.ValueSourceCLI:      gql.ConfigSourceCli,
+	config.ValueSourceEnv:      gql.ConfigSourceEnv,
+	config.ValueSourceFile:     gql.ConfigSourceFile,
+	config.ValueSourceRegistry: gql.ConfigSourceRegistry,
+	config.ValueSourceDefault:  gql.ConfigSourceDefault,
+}
+
+// EffectiveConfig is the resolver for the effectiveConfig field.
+func (r *queryResolver) EffectiveConfig(ctx context.Context) ([]*gql.ConfigSetting, error) {
+	if err := RequireAdminPermission(ctx); err != nil {
+		return nil, err
+	}
+	provider, ok := r.config.(configValuesProvider)
+	if !ok {
+		return nil, &gqlerror.Error{
+			Message:    "the effective configuration is not available on this server",
+			Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+		}
+	}
+
+	// The system registry changes at runtime, so its current values are used
+	// rather than those applied at startup
+	prefix := "config."
+	entries, err := r.registryStore.List(ctx, registrystore.SystemOwnerID, &prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list system registry: %w", err)
+	}
+	registry := make(map[string]*registrystore.Registry, len(entries))
+	for _, entry := range entries {
+		if entry.Value != "" {
+			registry[strings.ToLower(entry.Key)] = entry
+		}
+	}
+
+	values := provider.Values()
+	settings := make([]*gql.ConfigSetting, 0, len(values))
+	for _, value := range values {
+		setting := &gql.ConfigSetting{
+			Key:          config.GetRegistryKeyForFlag(value.Name),
+			Flag:         value.Name,
+			Value:        value.Value,
+			Masked:       value.Masked,
+			Source:       configSources[value.Source],
+			DefaultValue: value.Default,
+		}
+		entry := registry[setting.Key]
+		switch value.Source {
+		case config.ValueSourceCLI, config.ValueSourceEnv, config.ValueSourceFile:
+			setting.RegistryIgnored = entry != nil
+		default:
+			if entry != nil {
+				setting.Value = config.MaskValue(value.Name, entry.Value)
+				if entry.IsEncrypted {
+					setting.Value = "********"
+				}
+				setting.Masked = setting.Value != entry.Value
+				setting.Source = gql.ConfigSourceRegistry
+			} else if value.Source == config.ValueSourceRegistry {
+				// Deleted from the registry since startup
+				setting.Value = value.Default
+				setting.Masked = false
+				setting.Source = gql.ConfigSourceDefault
+			}
+		}
+		settings = append(settings, setting)
+	}
+	return settings, nil
+}
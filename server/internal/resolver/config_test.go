@@ -0,0 +1,60 @@
+package resolver
+
+import (
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func TestEffectiveConfig(t *testing.T) {
+	t.Setenv("APP_HOME_TITLE", "Home")
+	cfg, err := config.Load([]string{"--app-title", "Photos", "--jwt-secret", "test-secret"}, nil)
+	require.NoError(t, err)
+
+	mockRegistryStore := new(MockRegistryStore)
+	mockRegistryStore.On("List", mock.Anything, registrystore.SystemOwnerID, mock.Anything).Return([]*registrystore.Registry{
+		{Key: "config.app_title", Value: "Ignored"},
+		{Key: "config.allow_guest_mode", Value: "true"},
+		{Key: "config.imagor_secret", Value: "s3cret", IsEncrypted: true},
+	}, nil)
+	resolver := newTestResolver(nil, mockRegistryStore, new(MockUserStore), nil, cfg, nil, zap.NewNop())
+
+	_, err = resolver.Query().EffectiveConfig(createReadWriteContext("user-1"))
+	assert.Error(t, err, "admin only")
+
+	settings, err := resolver.Query().EffectiveConfig(createAdminContext("admin-1"))
+	require.NoError(t, err)
+	byFlag := make(map[string]*gql.ConfigSetting)
+	for _, setting := range settings {
+		byFlag[setting.Flag] = setting
+	}
+
+	assert.Equal(t, &gql.ConfigSetting{
+		Key: "config.app_title", Flag: "app-title", Value: "Photos", Source: gql.ConfigSourceCli, RegistryIgnored: true,
+	}, byFlag["app-title"])
+	assert.Equal(t, gql.ConfigSourceEnv, byFlag["app-home-title"].Source)
+	assert.False(t, byFlag["app-home-title"].RegistryIgnored)
+	assert.Equal(t, &gql.ConfigSetting{
+		Key: "config.allow_guest_mode", Flag: "allow-guest-mode", Value: "true", Source: gql.ConfigSourceRegistry, DefaultValue: "false",
+	}, byFlag["allow-guest-mode"])
+	assert.Equal(t, "********", byFlag["imagor-secret"].Value)
+	assert.True(t, byFlag["imagor-secret"].Masked)
+	assert.Equal(t, "********", byFlag["jwt-secret"].Value)
+	assert.Equal(t, gql.ConfigSourceDefault, byFlag["app-default-sort-by"].Source)
+}
+
+func TestEffectiveConfig_NotAvailable(t *testing.T) {
+	resolver := newTestResolver(nil, new(MockRegistryStore), new(MockUserStore), nil, &MockConfig{}, nil, zap.NewNop())
+
+	_, err := resolver.Query().EffectiveConfig(createAdminContext("admin-1"))
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_AVAILABLE", gqlErr.Extensions["code"])
+}
@@ -7,12 +7,15 @@ import (
 	"strings"
 
 	"github.com/cshum/imagor-studio/server/pkg/auth"
+	"github.com/cshum/imagor-studio/server/pkg/safepath"
 )
 
 type contextKey string
 
 const (
-	UserIDContextKey contextKey = "userID"
+	UserIDContextKey   contextKey = "userID"
+	HomePathContextKey contextKey = "homePath"
+	TenantContextKey   contextKey = "tenantID"
 )
 
 // WithUserID adds owner ID to context
@@ -29,6 +32,67 @@ func GetUserIDFromContext(ctx context.Context) (string, error) {
 	return ownerID, nil
 }
 
+// WithHomePath scopes the storage access of the request to a user's home path
+func WithHomePath(ctx context.Context, homePath string) context.Context {
+	return context.WithValue(ctx, HomePathContextKey, homePath)
+}
+
+// GetHomePathFromContext returns the home path the request is scoped to,
+// empty when the whole storage is accessible
+func GetHomePathFromContext(ctx context.Context) string {
+	homePath, _ := ctx.Value(HomePathContextKey).(string)
+	return homePath
+}
+
+// WithTenantID confines the request to a tenant. The storage access is
+// scoped separately by the home path, which is the tenant's storage root or
+// below it.
+func WithTenantID(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, TenantContextKey, tenantID)
+}
+
+// GetTenantIDFromContext returns the tenant the request is confined to,
+// empty outside of tenants
+func GetTenantIDFromContext(ctx context.Context) string {
+	tenantID, _ := ctx.Value(TenantContextKey).(string)
+	return tenantID
+}
+
+// NormalizeHomePath cleans a home path to the relative form stored on the
+// user record, an empty path or "/" means no home path
+func NormalizeHomePath(homePath string) (string, error) {
+	if err := safepath.Validate(homePath); err != nil {
+		return "", fmt.Errorf("invalid home path: %w", err)
+	}
+	return strings.Trim(filepath.Clean("/"+strings.TrimSpace(homePath)), "/"), nil
+}
+
+// isWithinHomePath reports whether the cleaned relative path is the home
+// path or below it
+func isWithinHomePath(homePath, cleanedPath string) bool {
+	return cleanedPath == homePath || strings.HasPrefix(cleanedPath, homePath+"/")
+}
+
+// ScopePath re-roots a storage path to the home path of the request. The
+// storage root resolves to the home path and paths outside of it are taken
+// relative to it, so clients of users with a home path can keep using root
+// relative paths. Paths are returned unchanged without a home path. Paths
+// safepath.Validate rejects are denied.
+func ScopePath(ctx context.Context, requestedPath string) (string, error) {
+	if err := validatePath(requestedPath); err != nil {
+		return "", err
+	}
+	homePath := GetHomePathFromContext(ctx)
+	if homePath == "" {
+		return requestedPath, nil
+	}
+	cleaned := strings.Trim(filepath.Clean("/"+requestedPath), "/")
+	if isWithinHomePath(homePath, cleaned) {
+		return cleaned, nil
+	}
+	return filepath.Join(homePath, cleaned), nil
+}
+
 func RequirePermission(ctx context.Context, requiredScopes ...string) error {
 	claims, err := auth.GetClaimsFromContext(ctx)
 	if err != nil {
@@ -56,6 +120,21 @@ func RequireWritePermission(ctx context.Context, path ...string) error {
 		return fmt.Errorf("public preview sessions cannot persist changes")
 	}
 
+	// Guests allowed to upload hold no write scope, so checks without a
+	// path keep rejecting them
+	if prefix := guestWritePrefix(ctx); prefix != "" {
+		if len(path) == 0 || path[0] == "" {
+			return fmt.Errorf("insufficient permission: guests can only write to %s", prefix)
+		}
+		if err := validatePath(path[0]); err != nil {
+			return err
+		}
+		if !isWithinHomePath(prefix, strings.Trim(filepath.Clean("/"+path[0]), "/")) {
+			return fmt.Errorf("path access denied: guests can only write to %s", prefix)
+		}
+		return ValidatePathAccess(ctx, path[0])
+	}
+
 	if err := RequirePermission(ctx, "write"); err != nil {
 		return err
 	}
@@ -96,8 +175,23 @@ func RequireReadPermission(ctx context.Context, path ...string) error {
 	return nil
 }
 
-// RequireAdminPermission to check admin permissions
+// RequireAdminPermission to check admin permissions. Admins of a tenant
+// only administer their tenant, so server-wide admin features are denied to
+// them.
 func RequireAdminPermission(ctx context.Context) error {
+	if err := RequirePermission(ctx, "admin"); err != nil {
+		return err
+	}
+	if GetTenantIDFromContext(ctx) != "" {
+		return fmt.Errorf("insufficient permission: server admin access required")
+	}
+	return nil
+}
+
+// RequireTenantAdminPermission checks for admin permissions over the users
+// and settings of the request's tenant, or of the whole server outside of
+// tenants
+func RequireTenantAdminPermission(ctx context.Context) error {
 	return RequirePermission(ctx, "admin")
 }
 
@@ -110,6 +204,48 @@ func IsPublicPreviewMode(ctx context.Context) bool {
 	return claims.Mode == auth.ExperienceModePublicPreview
 }
 
+// IsShareLinkSession checks whether the current session was opened from a shared link
+func IsShareLinkSession(ctx context.Context) bool {
+	claims, err := auth.GetClaimsFromContext(ctx)
+	if err != nil {
+		return false
+	}
+	return claims.Kind == auth.ShareLinkTokenKind
+}
+
+// CanDownloadOriginals checks whether original files may be downloaded.
+// Shared link sessions carry the download scope only when the link allows it,
+// and sessions with a watermark never may.
+func CanDownloadOriginals(ctx context.Context) bool {
+	if sessionWatermark(ctx) != nil {
+		return false
+	}
+	if !IsShareLinkSession(ctx) {
+		return true
+	}
+	return RequirePermission(ctx, "download") == nil
+}
+
+// sessionWatermark returns the watermark overlaid on the images rendered for
+// the session, nil without
+func sessionWatermark(ctx context.Context) *auth.Watermark {
+	claims, err := auth.GetClaimsFromContext(ctx)
+	if err != nil || claims.Watermark == nil || claims.Watermark.Image == "" {
+		return nil
+	}
+	return claims.Watermark
+}
+
+// guestWritePrefix returns the folder a guest session may write below,
+// empty for other sessions and guests without uploads
+func guestWritePrefix(ctx context.Context) string {
+	claims, err := auth.GetClaimsFromContext(ctx)
+	if err != nil || claims.Role != "guest" {
+		return ""
+	}
+	return strings.Trim(claims.WritePrefix, "/")
+}
+
 // IsGuestUser to check if user is a guest
 func IsGuestUser(ctx context.Context) bool {
 	claims, err := auth.GetClaimsFromContext(ctx)
@@ -147,9 +283,10 @@ func GetEffectiveTargetUserID(ctx context.Context, providedUserID *string) (stri
 
 	targetUserID := *providedUserID
 
-	// Users can only access their own metadata, admins can access any user's metadata
+	// Users can only access their own metadata, admins can access any user's
+	// metadata, tenant admins that of their tenant's users
 	if targetUserID != currentUserID {
-		if err := RequireAdminPermission(ctx); err != nil {
+		if err := RequireTenantAdminPermission(ctx); err != nil {
 			return "", fmt.Errorf("admin permission required to access other user's metadata: %w", err)
 		}
 	}
@@ -163,6 +300,16 @@ func ValidatePathAccess(ctx context.Context, requestedPath string) error {
 	if err != nil {
 		return fmt.Errorf("unauthorized")
 	}
+	if err := validatePath(requestedPath); err != nil {
+		return err
+	}
+
+	// Users with a home path only reach paths below it
+	if homePath := GetHomePathFromContext(ctx); homePath != "" {
+		if !isWithinHomePath(homePath, strings.Trim(filepath.Clean("/"+requestedPath), "/")) {
+			return fmt.Errorf("path access denied: %s not within home path %s", requestedPath, homePath)
+		}
+	}
 
 	// If no path prefix is set, allow all paths (backward compatibility)
 	if claims.PathPrefix == "" {
@@ -189,11 +336,15 @@ func ValidatePathAccess(ctx context.Context, requestedPath string) error {
 		return fmt.Errorf("path access denied: %s not within allowed prefix %s", requestedPath, claims.PathPrefix)
 	}
 
-	// Additional check: ensure the path doesn't escape the prefix using path traversal
-	if strings.Contains(requestedPath, "..") {
-		return fmt.Errorf("path access denied: path traversal not allowed")
-	}
+	return nil
+}
 
+// validatePath denies paths safepath.Validate rejects, such as paths with
+// ".." segments or control characters
+func validatePath(requestedPath string) error {
+	if err := safepath.Validate(requestedPath); err != nil {
+		return fmt.Errorf("path access denied: %w", err)
+	}
 	return nil
 }
 
@@ -207,3 +358,17 @@ func RequirePathPermission(ctx context.Context, requestedPath string, requiredSc
 	// Then check path access permissions
 	return ValidatePathAccess(ctx, requestedPath)
 }
+
+// accessRoot returns the folder every path the request may reach is below:
+// the home path of the user, or the folder of a shared link. Empty when the
+// request reaches the whole storage.
+func accessRoot(ctx context.Context) string {
+	if homePath := GetHomePathFromContext(ctx); homePath != "" {
+		return homePath
+	}
+	claims, err := auth.GetClaimsFromContext(ctx)
+	if err != nil {
+		return ""
+	}
+	return strings.Trim(filepath.Clean("/"+claims.PathPrefix), "/")
+}
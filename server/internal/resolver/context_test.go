@@ -284,6 +284,31 @@ func TestRequireWritePermissionWithPath(t *testing.T) {
 	}
 }
 
+func TestRequireWritePermission_GuestUploadFolder(t *testing.T) {
+	ctx := auth.SetClaimsInContext(context.Background(), &auth.Claims{
+		UserID:      "guest-1",
+		Role:        "guest",
+		Scopes:      []string{"read"},
+		WritePrefix: "inbox/guest-1",
+	})
+
+	assert.NoError(t, RequireWritePermission(ctx, "inbox/guest-1/photo.jpg"))
+	assert.NoError(t, RequireWritePermission(ctx, "/inbox/guest-1"))
+	assert.Error(t, RequireWritePermission(ctx, "inbox/guest-2/photo.jpg"))
+	assert.Error(t, RequireWritePermission(ctx, "inbox/guest-1-other/photo.jpg"))
+	assert.Error(t, RequireWritePermission(ctx, "inbox/guest-1/../guest-2/photo.jpg"))
+	assert.Error(t, RequireWritePermission(ctx, "photo.jpg"))
+	// Checks without a path and direct scope checks keep rejecting guests
+	assert.Error(t, RequireWritePermission(ctx))
+	assert.Error(t, RequirePermission(ctx, "write"))
+
+	// The prefix is only honored for guests
+	userCtx := auth.SetClaimsInContext(context.Background(), &auth.Claims{
+		UserID: "user-1", Role: "user", Scopes: []string{"read"}, WritePrefix: "inbox/guest-1",
+	})
+	assert.Error(t, RequireWritePermission(userCtx, "inbox/guest-1/photo.jpg"))
+}
+
 func TestIsPublicPreviewMode(t *testing.T) {
 	t.Run("returns true when mode is public preview", func(t *testing.T) {
 		ctx := auth.SetClaimsInContext(context.Background(), &auth.Claims{Mode: auth.ExperienceModePublicPreview})
@@ -395,3 +420,77 @@ func TestEmbeddedModeWithPathValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestNormalizeHomePath(t *testing.T) {
+	tests := []struct {
+		homePath    string
+		expected    string
+		expectError bool
+	}{
+		{homePath: "teams/alice", expected: "teams/alice"},
+		{homePath: " /teams//alice/ ", expected: "teams/alice"},
+		{homePath: "/", expected: ""},
+		{homePath: "", expected: ""},
+		{homePath: "teams/../alice", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.homePath, func(t *testing.T) {
+			result, err := NormalizeHomePath(tt.homePath)
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestScopePath(t *testing.T) {
+	ctx := WithHomePath(createReadWriteContext("test-user-id"), "teams/alice")
+
+	tests := []struct {
+		requestedPath string
+		expected      string
+		expectError   bool
+	}{
+		{requestedPath: "", expected: "teams/alice"},
+		{requestedPath: "/", expected: "teams/alice"},
+		{requestedPath: "photos/a.jpg", expected: "teams/alice/photos/a.jpg"},
+		{requestedPath: "teams/alice/photos", expected: "teams/alice/photos"},
+		{requestedPath: "/teams/alice", expected: "teams/alice"},
+		{requestedPath: "teams/alicex", expected: "teams/alice/teams/alicex"},
+		{requestedPath: "../bob", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.requestedPath, func(t *testing.T) {
+			result, err := ScopePath(ctx, tt.requestedPath)
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+
+	// Without a home path paths are left as they are
+	result, err := ScopePath(createReadWriteContext("test-user-id"), "/photos")
+	assert.NoError(t, err)
+	assert.Equal(t, "/photos", result)
+}
+
+func TestValidatePathAccess_HomePath(t *testing.T) {
+	ctx := WithHomePath(createReadWriteContext("test-user-id"), "teams/alice")
+
+	assert.NoError(t, RequireReadPermission(ctx, "teams/alice"))
+	assert.NoError(t, RequireWritePermission(ctx, "/teams/alice/photos/a.jpg"))
+
+	err := RequireWritePermission(ctx, "teams/bob/a.jpg")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "not within home path")
+	assert.Error(t, RequireReadPermission(ctx, "teams/alicex"))
+	assert.Error(t, RequireReadPermission(ctx, "teams/alice/../bob"))
+}
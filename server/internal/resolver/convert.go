@@ -0,0 +1,214 @@
+package resolver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/cshum/imagor-studio/server/internal/bulkdownload"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/imageedit"
+	"github.com/cshum/imagor-studio/server/internal/jobqueue"
+	"github.com/cshum/imagor-studio/server/internal/operation"
+	"github.com/cshum/imagor-studio/server/pkg/space"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/cshum/imagor/imagorpath"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxConvertFiles bounds the images converted by one operation
+	maxConvertFiles = 5000
+	// maxConvertDimension matches the imagor processor size limit
+	maxConvertDimension = 9999
+)
+
+// convertibleExtensions are the image formats picked up below a selected
+// folder; files selected directly are converted whatever their extension
+var convertibleExtensions = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".webp": true, ".avif": true,
+	".gif": true, ".tif": true, ".tiff": true, ".heic": true, ".heif": true,
+	".jxl": true, ".bmp": true, ".dng": true,
+}
+
+// ConvertImages is the resolver for the convertImages field.
+func (r *mutationResolver) ConvertImages(ctx context.Context, paths []string, format string, quality *int, maxDimension *int, destFolder string, spaceID *string) (*gql.Operation, error) {
+	if len(paths) == 0 {
+		return nil, &gqlerror.Error{
+			Message:    "no files selected",
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	scoped := make([]string, len(paths))
+	for i, p := range paths {
+		p, err := ScopePath(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		if err := RequireReadPermission(ctx, p); err != nil {
+			return nil, err
+		}
+		scoped[i] = strings.Trim(p, "/")
+	}
+	destFolder, err := ScopePath(ctx, destFolder)
+	if err != nil {
+		return nil, err
+	}
+	destFolder = strings.Trim(destFolder, "/")
+	if err := RequireWritePermission(ctx, destFolder); err != nil {
+		return nil, err
+	}
+	if r.imagorProvider == nil {
+		return nil, &gqlerror.Error{
+			Message:    "image processing is not available",
+			Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+		}
+	}
+	params, ext, err := convertParams(format, quality, maxDimension)
+	if err != nil {
+		return nil, err
+	}
+
+	stor, sp, err := r.resolveUploadStorageTarget(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureSpaceUploadAllowed(sp); err != nil {
+		return nil, err
+	}
+	files, err := collectDownloadFiles(ctx, stor, scoped, maxConvertFiles)
+	if errors.Is(err, errSelectionLimit) {
+		return nil, &gqlerror.Error{
+			Message:    fmt.Sprintf("too many files selected: max %d", maxConvertFiles),
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	files = convertibleFiles(files, scoped)
+
+	ownerID, _ := GetUserIDFromContext(ctx)
+	op := r.operations.Start(ctx, operationKindConvertImages, ownerID, func(ctx context.Context, progress *operation.Progress) error {
+		// Bulk conversions keep the slots reserved for thumbnails a user is
+		// waiting on free
+		ctx = jobqueue.WithClass(ctx, jobqueue.Background)
+		return r.convertImagesWithProgress(ctx, stor, sp, files, params, destFolder, ext, progress)
+	})
+	return toGQLOperation(op), nil
+}
+
+// convertImagesWithProgress converts files one at a time, honouring
+// cancellation between files. A file failing to convert does not stop the
+// others; the operation fails at the end when any did.
+func (r *Resolver) convertImagesWithProgress(ctx context.Context, stor storage.Storage, sp *space.Space, files []bulkdownload.File, params imagorpath.Params, destFolder, ext string, progress *operation.Progress) error {
+	progress.SetTotal(len(files))
+	progress.SetMessage("Converting images")
+	var failed int
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		dest, err := r.convertImage(ctx, stor, sp, f.Path, params, destFolder, ext)
+		if err != nil {
+			failed++
+			r.logger.Warn("Failed to convert image", zap.String("path", f.Path), zap.Error(err))
+			progress.Advance(1)
+			continue
+		}
+		progress.Advance(1, dest)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d images failed to convert", failed, len(files))
+	}
+	progress.SetMessage(fmt.Sprintf("Converted %d images", len(files)))
+	return nil
+}
+
+func (r *Resolver) convertImage(ctx context.Context, stor storage.Storage, sp *space.Space, imagePath string, params imagorpath.Params, destFolder, ext string) (string, error) {
+	name := path.Base(imagePath)
+	dest := strings.TrimSuffix(name, path.Ext(name)) + "." + ext
+	if destFolder != "" {
+		dest = destFolder + "/" + dest
+	}
+	if dest == imagePath {
+		return "", errors.New("the converted file would replace the original")
+	}
+	if taken, err := pathTaken(ctx, stor, dest); err != nil {
+		return "", err
+	} else if taken {
+		return "", fmt.Errorf("%s already exists", dest)
+	}
+	convertURL, err := r.generateImagorURLForSpaceConfig(imagePath, params, sp)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate imagor URL: %w", err)
+	}
+	body, err := r.fetchImagorURL(ctx, convertURL)
+	if err != nil {
+		return "", err
+	}
+	if err := r.enforceHostedStorageQuota(ctx, sp, int64(len(body))); err != nil {
+		return "", err
+	}
+	if _, err := r.storeUpload(ctx, stor, sp, dest, uploadCollisionReject, bytes.NewReader(body), int64(len(body))); err != nil {
+		return "", err
+	}
+	return dest, nil
+}
+
+// convertParams validates the conversion settings and returns the imagor
+// params applying them, with the extension of the converted files
+func convertParams(format string, quality, maxDimension *int) (imagorpath.Params, string, error) {
+	format = imageedit.NormalizeFormat(format)
+	ext, ok := imageedit.Formats[format]
+	if !ok {
+		return imagorpath.Params{}, "", &gqlerror.Error{
+			Message:    fmt.Sprintf("unsupported format: %s", format),
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	var params imagorpath.Params
+	if maxDimension != nil {
+		if *maxDimension <= 0 || *maxDimension > maxConvertDimension {
+			return imagorpath.Params{}, "", &gqlerror.Error{
+				Message:    fmt.Sprintf("maxDimension must be between 1 and %d", maxConvertDimension),
+				Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+			}
+		}
+		params.FitIn = true
+		params.Width = *maxDimension
+		params.Height = *maxDimension
+	}
+	params.Filters = imagorpath.Filters{{Name: "format", Args: format}}
+	if quality != nil {
+		if *quality < 1 || *quality > 100 {
+			return imagorpath.Params{}, "", &gqlerror.Error{
+				Message:    "quality must be between 1 and 100",
+				Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+			}
+		}
+		params.Filters = append(params.Filters, imagorpath.Filter{Name: "quality", Args: strconv.Itoa(*quality)})
+	}
+	return params, ext, nil
+}
+
+// convertibleFiles drops the files found below selected folders that are
+// not images
+func convertibleFiles(files []bulkdownload.File, selected []string) []bulkdownload.File {
+	direct := make(map[string]bool, len(selected))
+	for _, p := range selected {
+		direct[p] = true
+	}
+	result := files[:0]
+	for _, f := range files {
+		if direct[f.Path] || convertibleExtensions[strings.ToLower(path.Ext(f.Path))] {
+			result = append(result, f)
+		}
+	}
+	return result
+}
@@ -0,0 +1,110 @@
+package resolver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/operation"
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/cshum/imagor/imagorpath"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func TestConvertImages(t *testing.T) {
+	renderServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("image") == "broken" {
+			http.Error(w, "unsupported", http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte("jpeg data"))
+	}))
+	defer renderServer.Close()
+
+	baseDir := t.TempDir()
+	for _, p := range []string{"imports/a.heic", "imports/b.HEIC", "imports/live.mov", "imports/broken.heic", "exported/a.jpg"} {
+		writeTestFile(t, baseDir, p)
+	}
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+
+	params := imagorpath.Params{FitIn: true, Width: 2048, Height: 2048,
+		Filters: imagorpath.Filters{{Name: "format", Args: "jpeg"}, {Name: "quality", Args: "85"}}}
+	mockImagorProvider := new(MockImagorProvider)
+	mockImagorProvider.On("Imagor").Return((*imagor.Imagor)(nil))
+	mockImagorProvider.On("GenerateURL", "imports/broken.heic", params).Return(renderServer.URL+"?image=broken", nil)
+	mockImagorProvider.On("GenerateURL", mock.Anything, params).Return(renderServer.URL, nil)
+	manager := operation.NewManager(zap.NewNop())
+	resolver := newTestResolver(NewMockStorageProvider(stor), new(MockRegistryStore), new(MockUserStore), mockImagorProvider, &config.Config{}, nil, zap.NewNop(),
+		WithOperationManager(manager))
+	ctx := createReadWriteContext("user-1")
+
+	started, err := resolver.Mutation().ConvertImages(ctx, []string{"imports"}, "jpg", intPtr(85), intPtr(2048), "/exported/", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "convert_images", started.Kind)
+
+	waitCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = manager.Wait(waitCtx, started.ID)
+	require.NoError(t, err)
+
+	// a.jpg exists already and broken.heic fails to render, the movie is skipped
+	op, err := resolver.Query().Operation(ctx, started.ID)
+	require.NoError(t, err)
+	assert.Equal(t, gql.OperationStatusFailed, op.Status)
+	assert.Equal(t, "2 of 3 images failed to convert", *op.Error)
+	assert.Equal(t, 3, op.Completed)
+	assert.Equal(t, []string{"exported/b.jpg"}, op.Results)
+
+	content, err := os.ReadFile(filepath.Join(baseDir, "exported", "b.jpg"))
+	require.NoError(t, err)
+	assert.Equal(t, "jpeg data", string(content))
+	content, err = os.ReadFile(filepath.Join(baseDir, "exported", "a.jpg"))
+	require.NoError(t, err)
+	assert.Equal(t, "test", string(content))
+}
+
+func TestConvertImages_Validation(t *testing.T) {
+	resolver, _, baseDir := newOperationTestResolver(t)
+	writeTestFile(t, baseDir, "imports/a.heic")
+	ctx := createReadWriteContext("user-1")
+
+	// Without imagor there is nothing to convert with
+	_, err := resolver.Mutation().ConvertImages(ctx, []string{"imports/a.heic"}, "jpeg", nil, nil, "exported", nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_AVAILABLE", gqlErr.Extensions["code"])
+
+	resolver.imagorProvider = new(MockImagorProvider)
+	for _, tc := range []struct {
+		paths        []string
+		format       string
+		quality      *int
+		maxDimension *int
+	}{
+		{nil, "jpeg", nil, nil},
+		{[]string{"imports/a.heic"}, "bmp", nil, nil},
+		{[]string{"imports/a.heic"}, "jpeg", intPtr(0), nil},
+		{[]string{"imports/a.heic"}, "jpeg", nil, intPtr(20000)},
+	} {
+		_, err := resolver.Mutation().ConvertImages(ctx, tc.paths, tc.format, tc.quality, tc.maxDimension, "exported", nil)
+		require.ErrorAs(t, err, &gqlErr)
+		assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+	}
+
+	_, err = resolver.Mutation().ConvertImages(ctx, []string{"imports/missing.heic"}, "jpeg", nil, nil, "exported", nil)
+	assert.ErrorContains(t, err, "file not found")
+	_, err = resolver.Mutation().ConvertImages(createReadOnlyContext("user-1"), []string{"imports/a.heic"}, "jpeg", nil, nil, "exported", nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,447 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/cshum/imagor-studio/server/internal/dedupe"
+	"github.com/cshum/imagor-studio/server/internal/events"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/operation"
+	"github.com/cshum/imagor-studio/server/pkg/space"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+// maxDuplicateGroups caps the groups returned by duplicateGroups
+const maxDuplicateGroups = 200
+
+// Modes of upload-dedupe besides allow
+const (
+	uploadDedupeReject = "reject"
+	uploadDedupeLink   = "link"
+)
+
+const (
+	// defaultSimilarThreshold is the perceptual hash distance similarImages
+	// matches by default, about one in six bits
+	defaultSimilarThreshold = 10
+	// maxSimilarImages caps the images returned by similarImages
+	maxSimilarImages = 100
+)
+
+func duplicatesNotAvailableError() error {
+	return &gqlerror.Error{
+		Message:    "duplicate detection is not enabled",
+		Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+	}
+}
+
+// DuplicateGroups is the resolver for the duplicateGroups field.
+func (r *queryResolver) DuplicateGroups(ctx context.Context, path *string, spaceID *string) ([]*gql.DuplicateGroup, error) {
+	root := ""
+	if path != nil {
+		root = strings.Trim(*path, "/")
+	}
+	root, err := ScopePath(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+	if err := RequireReadPermission(ctx, root); err != nil {
+		return nil, err
+	}
+	if r.duplicates == nil {
+		return nil, duplicatesNotAvailableError()
+	}
+	sp, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := r.duplicates.Store().DuplicateGroups(ctx, fileMetadataScope(sp), root, maxDuplicateGroups)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*gql.DuplicateGroup, 0, len(groups))
+	for _, g := range groups {
+		result = append(result, &gql.DuplicateGroup{Hash: g.Hash, Size: int(g.Size), Paths: g.Paths})
+	}
+	return result, nil
+}
+
+// SimilarImages is the resolver for the similarImages field.
+func (r *queryResolver) SimilarImages(ctx context.Context, path string, threshold *int, spaceID *string) ([]*gql.SimilarImage, error) {
+	path, err := ScopePath(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	path = strings.Trim(path, "/")
+	if err := RequireReadPermission(ctx, path); err != nil {
+		return nil, err
+	}
+	maxDistance := defaultSimilarThreshold
+	if threshold != nil {
+		if *threshold < 0 || *threshold > dedupe.MaxDistance {
+			return nil, &gqlerror.Error{
+				Message:    fmt.Sprintf("threshold must be between 0 and %d", dedupe.MaxDistance),
+				Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+			}
+		}
+		maxDistance = *threshold
+	}
+	if r.duplicates == nil {
+		return nil, duplicatesNotAvailableError()
+	}
+	sp, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	scope := fileMetadataScope(sp)
+	store := r.duplicates.Store()
+
+	entries, err := store.Get(ctx, scope, []string{path})
+	if err != nil {
+		return nil, err
+	}
+	target, err := dedupe.ParsePerceptual(entries[path].Perceptual)
+	if err != nil {
+		return nil, &gqlerror.Error{
+			Message:    fmt.Sprintf("no perceptual hash for %s, scan for duplicates first", path),
+			Extensions: map[string]interface{}{"code": "NOT_FOUND"},
+		}
+	}
+	// Candidates are limited to the home path of the request
+	root, err := ScopePath(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	candidates, err := store.ListPerceptual(ctx, scope, strings.Trim(root, "/"))
+	if err != nil {
+		return nil, err
+	}
+	var result []*gql.SimilarImage
+	for _, c := range candidates {
+		if c.Path == path {
+			continue
+		}
+		hash, err := dedupe.ParsePerceptual(c.Perceptual)
+		if err != nil {
+			continue
+		}
+		if distance := dedupe.Distance(target, hash); distance <= maxDistance {
+			result = append(result, &gql.SimilarImage{Path: c.Path, Distance: distance})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Distance != result[j].Distance {
+			return result[i].Distance < result[j].Distance
+		}
+		return result[i].Path < result[j].Path
+	})
+	if len(result) > maxSimilarImages {
+		result = result[:maxSimilarImages]
+	}
+	if result == nil {
+		result = []*gql.SimilarImage{}
+	}
+	return result, nil
+}
+
+// ScanDuplicates is the resolver for the scanDuplicates field.
+func (r *mutationResolver) ScanDuplicates(ctx context.Context, path *string, spaceID *string) (*gql.Operation, error) {
+	root := ""
+	if path != nil {
+		root = strings.Trim(*path, "/")
+	}
+	root, err := ScopePath(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+	if err := RequireWritePermission(ctx, root); err != nil {
+		return nil, err
+	}
+	if r.duplicates == nil {
+		return nil, duplicatesNotAvailableError()
+	}
+	stor, sp, err := r.resolveUploadStorageTarget(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	scope := fileMetadataScope(sp)
+	ownerID, _ := GetUserIDFromContext(ctx)
+	op := r.operations.Start(ctx, dedupe.OperationKind, ownerID, func(ctx context.Context, progress *operation.Progress) error {
+		return r.duplicates.Scan(ctx, stor, scope, root, r.perceptualHasher(sp), progress)
+	})
+	return toGQLOperation(op), nil
+}
+
+// ResolveDuplicates is the resolver for the resolveDuplicates field.
+func (r *mutationResolver) ResolveDuplicates(ctx context.Context, paths []string, spaceID *string) ([]string, error) {
+	if len(paths) == 0 {
+		return nil, &gqlerror.Error{
+			Message:    "no files selected",
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	deleting := make(map[string]bool, len(paths))
+	scoped := make([]string, 0, len(paths))
+	for _, p := range paths {
+		p, err := ScopePath(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		p = strings.Trim(p, "/")
+		if err := RequireWritePermission(ctx, p); err != nil {
+			return nil, err
+		}
+		if !deleting[p] {
+			deleting[p] = true
+			scoped = append(scoped, p)
+		}
+	}
+	if r.duplicates == nil {
+		return nil, duplicatesNotAvailableError()
+	}
+	stor, sp, err := r.resolveUploadStorageTarget(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	scope := fileMetadataScope(sp)
+	store := r.duplicates.Store()
+
+	// Everything is checked before the first delete, so a rejected request
+	// leaves storage untouched
+	entries, err := store.Get(ctx, scope, scoped)
+	if err != nil {
+		return nil, err
+	}
+	unchanged := func(entry dedupe.Entry) bool {
+		info, err := stor.Stat(ctx, entry.Path)
+		return err == nil && !info.IsDir && dedupe.Fingerprint(info) == entry.Fingerprint
+	}
+	checked := make(map[string]bool)
+	for _, p := range scoped {
+		entry, ok := entries[p]
+		if !ok {
+			return nil, &gqlerror.Error{
+				Message:    fmt.Sprintf("not a scanned duplicate: %s", p),
+				Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+			}
+		}
+		if !unchanged(entry) {
+			return nil, &gqlerror.Error{
+				Message:    fmt.Sprintf("file changed since the duplicate scan, scan again: %s", p),
+				Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+			}
+		}
+		if checked[entry.Hash] {
+			continue
+		}
+		checked[entry.Hash] = true
+		copies, err := store.GetByHash(ctx, scope, entry.Hash)
+		if err != nil {
+			return nil, err
+		}
+		kept := false
+		for _, c := range copies {
+			if !deleting[c.Path] && unchanged(c) {
+				kept = true
+				break
+			}
+		}
+		if !kept {
+			return nil, &gqlerror.Error{
+				Message:    fmt.Sprintf("no copy of %s would be kept", p),
+				Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+			}
+		}
+	}
+
+	deleted := make([]string, 0, len(scoped))
+	for _, p := range scoped {
+		if err := r.deleteStoragePath(ctx, stor, sp, p); err != nil {
+			if len(deleted) == 0 {
+				return nil, err
+			}
+			r.logger.Warn("Failed to delete duplicate file", zap.String("path", p), zap.Error(err))
+			continue
+		}
+		r.removeFileTags(ctx, spaceID, p)
+		r.removeFavorites(ctx, spaceID, p)
+		r.removeAlbumItems(ctx, spaceID, p)
+		r.removeComments(ctx, spaceID, p)
+		r.removeRatings(ctx, spaceID, p)
+		r.removeFileMetadata(ctx, spaceID, p)
+		r.removeFileHashes(ctx, spaceID, p)
+		r.removeFaces(ctx, spaceID, p)
+		r.removeImageEdits(ctx, spaceID, p)
+		r.removeFolderCovers(ctx, spaceID, p)
+		r.removeFolderSettings(ctx, spaceID, p)
+		r.removeStorageUsage(ctx, spaceID, p)
+		r.publishSpaceFileChange(sp, events.FileDeleted, p, "")
+		deleted = append(deleted, p)
+	}
+	return deleted, nil
+}
+
+// perceptualHasher hashes images of a space from the 9x8 grayscale
+// thumbnails imagor renders, nil without imagor
+func (r *Resolver) perceptualHasher(sp *space.Space) dedupe.PerceptualHasher {
+	if r.imagorProvider == nil {
+		return nil
+	}
+	return func(ctx context.Context, imagePath string) (uint64, error) {
+		thumbnailURL, err := r.generateImagorURLForSpaceConfig(imagePath, dedupe.PerceptualParams(), sp)
+		if err != nil {
+			return 0, err
+		}
+		data, err := r.fetchImagorURL(ctx, thumbnailURL)
+		if err != nil {
+			return 0, err
+		}
+		return dedupe.DHash(data)
+	}
+}
+
+// removeFileHashes drops recorded hashes of a deleted or moved file or
+// folder. Failures are logged, the next scan drops them as well.
+func (r *Resolver) removeFileHashes(ctx context.Context, spaceID *string, path string) {
+	if r.duplicates == nil {
+		return
+	}
+	spaceConfig, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err == nil {
+		err = r.duplicates.Store().RemoveFilePath(ctx, fileMetadataScope(spaceConfig), strings.Trim(path, "/"))
+	}
+	if err != nil {
+		r.logger.Warn("Failed to remove file hashes", zap.String("path", path), zap.Error(err))
+	}
+}
+
+// uploadDuplicate is what findDuplicateUpload found about an upload
+type uploadDuplicate struct {
+	// hash of the upload content, empty when not hashed
+	hash string
+	// existing file to answer with instead of writing the upload
+	existing string
+}
+
+// findDuplicateUpload hashes an upload when upload-dedupe is reject or
+// link, rewinding its content, and looks for a file of the same content
+// hashed for duplicate detection. Files changed since they were hashed do
+// not count. Reject fails the upload, link answers with the existing file.
+func (r *Resolver) findDuplicateUpload(ctx context.Context, stor storage.Storage, sp *space.Space, path string, content graphql.Upload) (uploadDuplicate, error) {
+	if !r.dedupesUploads() || content.Size == 0 {
+		return uploadDuplicate{}, nil
+	}
+	hash, err := hashUpload(content)
+	if err != nil {
+		return uploadDuplicate{}, err
+	}
+	return r.findDuplicateHash(ctx, stor, sp, path, hash)
+}
+
+// hashUpload hashes the content of an upload, rewinding it
+func hashUpload(content graphql.Upload) (string, error) {
+	hash, err := dedupe.HashContent(content.File)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash upload: %w", err)
+	}
+	if _, err := content.File.Seek(0, io.SeekStart); err != nil {
+		return "", fmt.Errorf("failed to rewind upload: %w", err)
+	}
+	return hash, nil
+}
+
+// findDuplicateStored is findDuplicateUpload for content already written
+// to path by the client, as direct uploads are
+func (r *Resolver) findDuplicateStored(ctx context.Context, stor storage.Storage, sp *space.Space, path string, size int64) (uploadDuplicate, error) {
+	if !r.dedupesUploads() || size == 0 {
+		return uploadDuplicate{}, nil
+	}
+	reader, err := stor.Get(ctx, path)
+	if err != nil {
+		return uploadDuplicate{}, fmt.Errorf("failed to read upload: %w", err)
+	}
+	hash, err := dedupe.HashContent(reader)
+	_ = reader.Close()
+	if err != nil {
+		return uploadDuplicate{}, fmt.Errorf("failed to hash upload: %w", err)
+	}
+	return r.findDuplicateHash(ctx, stor, sp, path, hash)
+}
+
+// dedupesUploads reports whether uploads are hashed to find duplicates
+func (r *Resolver) dedupesUploads() bool {
+	return r.duplicates != nil && (r.uploadDedupe == uploadDedupeReject || r.uploadDedupe == uploadDedupeLink)
+}
+
+func (r *Resolver) findDuplicateHash(ctx context.Context, stor storage.Storage, sp *space.Space, path, hash string) (uploadDuplicate, error) {
+	path = strings.Trim(path, "/")
+	existing, err := r.findHashedFile(ctx, stor, sp, hash, path)
+	if err != nil {
+		return uploadDuplicate{}, err
+	}
+	if existing == "" {
+		return uploadDuplicate{hash: hash}, nil
+	}
+	if r.uploadDedupe == uploadDedupeReject {
+		return uploadDuplicate{}, &gqlerror.Error{
+			Message: fmt.Sprintf("identical file already exists: %s", existing),
+			Extensions: map[string]interface{}{
+				"code":         "BAD_USER_INPUT",
+				"reason":       "duplicate_upload",
+				"existingPath": existing,
+			},
+		}
+	}
+	r.logger.Debug("Skipped duplicate upload", zap.String("path", path), zap.String("existing", existing))
+	return uploadDuplicate{hash: hash, existing: existing}, nil
+}
+
+// findHashedFile returns a file other than skip hashed for duplicate
+// detection with content hash and unchanged since, empty when there is none.
+// Only files the request may read are returned, others must not be revealed.
+func (r *Resolver) findHashedFile(ctx context.Context, stor storage.Storage, sp *space.Space, hash, skip string) (string, error) {
+	entries, err := r.duplicates.Store().GetByHash(ctx, fileMetadataScope(sp), hash)
+	if err != nil {
+		return "", err
+	}
+	for _, entry := range entries {
+		// Uploading identical content over itself is not a duplicate
+		if entry.Path == skip {
+			continue
+		}
+		if ValidatePathAccess(ctx, entry.Path) != nil {
+			continue
+		}
+		info, err := stor.Stat(ctx, entry.Path)
+		if err != nil || dedupe.Fingerprint(info) != entry.Fingerprint {
+			continue
+		}
+		return entry.Path, nil
+	}
+	return "", nil
+}
+
+// recordUploadHash records the hash of a stored upload, so later uploads of
+// the same content are found before the next duplicate scan. Failures are
+// logged, the scan hashes the file then.
+func (r *Resolver) recordUploadHash(ctx context.Context, stor storage.Storage, sp *space.Space, path, hash string) {
+	info, err := stor.Stat(ctx, path)
+	if err == nil {
+		err = r.duplicates.Store().Put(ctx, fileMetadataScope(sp), dedupe.Entry{
+			Path:        strings.Trim(path, "/"),
+			Size:        info.Size,
+			Fingerprint: dedupe.Fingerprint(info),
+			Hash:        hash,
+		})
+	}
+	if err != nil {
+		r.logger.Warn("Failed to record upload hash", zap.String("path", path), zap.Error(err))
+	}
+}
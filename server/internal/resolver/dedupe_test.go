@@ -0,0 +1,272 @@
+package resolver
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/dedupe"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/operation"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func newDedupeTestResolver(t *testing.T, imagorProvider ImagorProvider, opts ...ResolverOption) (*Resolver, *operation.Manager, string) {
+	t.Helper()
+	db := testutil.NewDB(t)
+
+	baseDir := t.TempDir()
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+	logger := zap.NewNop()
+	manager := operation.NewManager(logger)
+	mockRegistryStore := new(MockRegistryStore)
+	mockRegistryStore.On("GetMulti", mock.Anything, mock.Anything, mock.Anything).Return([]*registrystore.Registry{}, nil)
+	mockRegistryStore.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
+	opts = append([]ResolverOption{
+		WithOperationManager(manager),
+		WithDuplicateScanner(dedupe.NewScanner(dedupe.NewStore(db, logger), logger)),
+	}, opts...)
+	return newTestResolver(NewMockStorageProvider(stor), mockRegistryStore, new(MockUserStore), imagorProvider, &config.Config{}, nil, logger, opts...), manager, baseDir
+}
+
+func TestDuplicates_ScanAndResolve(t *testing.T) {
+	resolver, manager, baseDir := newDedupeTestResolver(t, nil)
+	writeTestFile(t, baseDir, "photos/a.jpg")
+	writeTestFile(t, baseDir, "photos/copy/a.jpg")
+	writeTestFile(t, baseDir, "backup/a.jpg")
+	ctx := createReadWriteContext("user-1")
+
+	started, err := resolver.Mutation().ScanDuplicates(ctx, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, dedupe.OperationKind, started.Kind)
+	waitCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	op, err := manager.Wait(waitCtx, started.ID)
+	require.NoError(t, err)
+	assert.Equal(t, operation.StatusSucceeded, op.Status)
+
+	groups, err := resolver.Query().DuplicateGroups(ctx, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, groups, 1)
+	assert.Equal(t, 4, groups[0].Size)
+	assert.Equal(t, []string{"backup/a.jpg", "photos/a.jpg", "photos/copy/a.jpg"}, groups[0].Paths)
+
+	photos := "photos"
+	groups, err = resolver.Query().DuplicateGroups(ctx, &photos, nil)
+	require.NoError(t, err)
+	require.Len(t, groups, 1)
+	assert.Equal(t, []string{"photos/a.jpg", "photos/copy/a.jpg"}, groups[0].Paths)
+
+	// Deleting every copy is refused, leaving storage untouched
+	_, err = resolver.Mutation().ResolveDuplicates(ctx, []string{"backup/a.jpg", "photos/a.jpg", "photos/copy/a.jpg"}, nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+	assert.FileExists(t, filepath.Join(baseDir, "backup/a.jpg"))
+
+	deleted, err := resolver.Mutation().ResolveDuplicates(ctx, []string{"photos/copy/a.jpg", "backup/a.jpg"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"photos/copy/a.jpg", "backup/a.jpg"}, deleted)
+	assert.NoFileExists(t, filepath.Join(baseDir, "photos/copy/a.jpg"))
+	assert.NoFileExists(t, filepath.Join(baseDir, "backup/a.jpg"))
+	assert.FileExists(t, filepath.Join(baseDir, "photos/a.jpg"))
+
+	groups, err = resolver.Query().DuplicateGroups(ctx, nil, nil)
+	require.NoError(t, err)
+	assert.Empty(t, groups)
+}
+
+func TestResolveDuplicates_ChangedSinceScan(t *testing.T) {
+	resolver, manager, baseDir := newDedupeTestResolver(t, nil)
+	writeTestFile(t, baseDir, "a.jpg")
+	writeTestFile(t, baseDir, "b.jpg")
+	ctx := createReadWriteContext("user-1")
+
+	started, err := resolver.Mutation().ScanDuplicates(ctx, nil, nil)
+	require.NoError(t, err)
+	waitCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = manager.Wait(waitCtx, started.ID)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(baseDir, "b.jpg"), []byte("edited"), 0644))
+	for _, paths := range [][]string{{"b.jpg"}, {"a.jpg"}, {"unknown.jpg"}} {
+		_, err = resolver.Mutation().ResolveDuplicates(ctx, paths, nil)
+		var gqlErr *gqlerror.Error
+		require.ErrorAs(t, err, &gqlErr, paths)
+		assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+	}
+	assert.FileExists(t, filepath.Join(baseDir, "a.jpg"))
+}
+
+func TestDuplicates_NotEnabled(t *testing.T) {
+	resolver := newTestResolver(nil, nil, nil, nil, nil, nil, zap.NewNop())
+
+	_, err := resolver.Query().DuplicateGroups(createReadOnlyContext("user-1"), nil, nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_AVAILABLE", gqlErr.Extensions["code"])
+	_, err = resolver.Mutation().ResolveDuplicates(createReadWriteContext("user-1"), []string{"a.jpg"}, nil)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_AVAILABLE", gqlErr.Extensions["code"])
+}
+
+// perceptualPNG renders a 90x80 grayscale wave, mirrored when flip is set
+func perceptualPNG(t *testing.T, brightness int, flip bool) []byte {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, 90, 80))
+	for y := range 80 {
+		for x := range 90 {
+			u := float64(x) / 90
+			if flip {
+				u = 1 - u
+			}
+			shade := 100 + 90*math.Sin(u*5)*math.Cos(float64(y)/80*3+1)
+			img.SetGray(x, y, color.Gray{Y: uint8(int(shade) + brightness)})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func TestSimilarImages(t *testing.T) {
+	thumbnails := map[string][]byte{
+		"photos/burst1.jpg": perceptualPNG(t, 0, false),
+		"photos/burst2.jpg": perceptualPNG(t, 10, false),
+		"export/burst.jpg":  perceptualPNG(t, 30, false),
+		"photos/other.jpg":  perceptualPNG(t, 0, true),
+	}
+	thumbnailServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(thumbnails[r.URL.Query().Get("image")])
+	}))
+	defer thumbnailServer.Close()
+	mockImagorProvider := new(MockImagorProvider)
+	mockImagorProvider.On("Imagor").Return((*imagor.Imagor)(nil))
+	for p := range thumbnails {
+		mockImagorProvider.On("GenerateURL", p, dedupe.PerceptualParams()).Return(thumbnailServer.URL+"?image="+p, nil)
+	}
+	resolver, manager, baseDir := newDedupeTestResolver(t, mockImagorProvider)
+	for p := range thumbnails {
+		writeTestFile(t, baseDir, p)
+	}
+	writeTestFile(t, baseDir, "photos/notes.txt")
+	ctx := createReadWriteContext("user-1")
+
+	_, err := resolver.Query().SimilarImages(ctx, "photos/burst1.jpg", nil, nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_FOUND", gqlErr.Extensions["code"])
+
+	started, err := resolver.Mutation().ScanDuplicates(ctx, nil, nil)
+	require.NoError(t, err)
+	waitCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	op, err := manager.Wait(waitCtx, started.ID)
+	require.NoError(t, err)
+	assert.Equal(t, operation.StatusSucceeded, op.Status)
+
+	similar, err := resolver.Query().SimilarImages(ctx, "photos/burst1.jpg", nil, nil)
+	require.NoError(t, err)
+	paths := make([]string, 0, len(similar))
+	for _, s := range similar {
+		paths = append(paths, s.Path)
+		assert.LessOrEqual(t, s.Distance, defaultSimilarThreshold)
+	}
+	assert.ElementsMatch(t, []string{"photos/burst2.jpg", "export/burst.jpg"}, paths)
+
+	zero := 0
+	similar, err = resolver.Query().SimilarImages(ctx, "photos/burst1.jpg", &zero, nil)
+	require.NoError(t, err)
+	for _, s := range similar {
+		assert.Equal(t, 0, s.Distance)
+	}
+
+	tooLarge := 65
+	_, err = resolver.Query().SimilarImages(ctx, "photos/burst1.jpg", &tooLarge, nil)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+}
+
+func TestUploadDedupe_Link(t *testing.T) {
+	mockImagorProvider := new(MockImagorProvider)
+	mockImagorProvider.On("Imagor").Return((*imagor.Imagor)(nil))
+	resolver, _, baseDir := newDedupeTestResolver(t, mockImagorProvider, WithUploadDedupe(uploadDedupeLink))
+	ctx := createReadWriteContext("user-1")
+
+	result, err := resolver.Mutation().UploadFileWithResult(ctx, "photos/a.txt", nil, upload("hello"), nil)
+	require.NoError(t, err)
+	assert.Equal(t, &gql.UploadResult{Path: "photos/a.txt"}, result)
+
+	// Identical content answers with the existing file without writing
+	result, err = resolver.Mutation().UploadFileWithResult(ctx, "backup/a.txt", nil, upload("hello"), nil)
+	require.NoError(t, err)
+	assert.Equal(t, &gql.UploadResult{Path: "photos/a.txt", Deduplicated: true}, result)
+	assert.NoFileExists(t, filepath.Join(baseDir, "backup/a.txt"))
+
+	// Uploading over the same file is not a duplicate
+	result, err = resolver.Mutation().UploadFileWithResult(ctx, "photos/a.txt", nil, upload("hello"), nil)
+	require.NoError(t, err)
+	assert.False(t, result.Deduplicated)
+
+	// Files changed since they were hashed do not count
+	require.NoError(t, os.WriteFile(filepath.Join(baseDir, "photos/a.txt"), []byte("changed"), 0644))
+	ok, err := resolver.Mutation().UploadFile(ctx, "backup/a.txt", nil, upload("hello"), nil)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.FileExists(t, filepath.Join(baseDir, "backup/a.txt"))
+
+	// Nor are files outside the home path of the uploader linked to
+	home := WithHomePath(createReadWriteContext("user-2"), "users/bob")
+	result, err = resolver.Mutation().UploadFileWithResult(home, "a.txt", nil, upload("hello"), nil)
+	require.NoError(t, err)
+	assert.Equal(t, &gql.UploadResult{Path: "users/bob/a.txt"}, result)
+	assert.FileExists(t, filepath.Join(baseDir, "users/bob/a.txt"))
+}
+
+func TestUploadDedupe_Reject(t *testing.T) {
+	mockImagorProvider := new(MockImagorProvider)
+	mockImagorProvider.On("Imagor").Return((*imagor.Imagor)(nil))
+	resolver, _, baseDir := newDedupeTestResolver(t, mockImagorProvider, WithUploadDedupe(uploadDedupeReject))
+	ctx := createReadWriteContext("user-1")
+
+	_, err := resolver.Mutation().UploadFile(ctx, "photos/a.txt", nil, upload("hello"), nil)
+	require.NoError(t, err)
+
+	_, err = resolver.Mutation().UploadFile(ctx, "backup/a.txt", nil, upload("hello"), nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "duplicate_upload", gqlErr.Extensions["reason"])
+	assert.Equal(t, "photos/a.txt", gqlErr.Extensions["existingPath"])
+	assert.NoFileExists(t, filepath.Join(baseDir, "backup/a.txt"))
+
+	// Different content is written
+	_, err = resolver.Mutation().UploadFile(ctx, "backup/b.txt", nil, upload("world"), nil)
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(baseDir, "backup/b.txt"))
+
+	// Files outside the home path of the uploader are not revealed
+	home := WithHomePath(createReadWriteContext("user-2"), "users/bob")
+	_, err = resolver.Mutation().UploadFile(home, "a.txt", nil, upload("hello"), nil)
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(baseDir, "users/bob/a.txt"))
+}
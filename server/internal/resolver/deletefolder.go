@@ -0,0 +1,266 @@
+package resolver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/operation"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+const (
+	// deleteConfirmationTTL is how long a recursive delete confirmation is valid
+	deleteConfirmationTTL = 5 * time.Minute
+	// maxFolderCountItems bounds the files counted before a folder delete
+	maxFolderCountItems = 10000
+	// maxSyncFolderDeleteItems is the largest folder deleteFolder removes
+	// before returning, larger folders are deleted in the background
+	maxSyncFolderDeleteItems = 1000
+)
+
+var errFolderCountLimit = errors.New("folder count limit reached")
+
+type deleteConfirmation struct {
+	userID    string
+	spaceID   string
+	path      string
+	expiresAt time.Time
+}
+
+// deleteConfirmations holds the single use tokens confirming recursive
+// folder deletes. Like operations they live on the replica that issued them.
+type deleteConfirmations struct {
+	now func() time.Time
+
+	mu     sync.Mutex
+	tokens map[string]deleteConfirmation
+}
+
+func newDeleteConfirmations() *deleteConfirmations {
+	return &deleteConfirmations{now: time.Now, tokens: make(map[string]deleteConfirmation)}
+}
+
+// issue returns a token confirming the delete of path by userID
+func (c *deleteConfirmations) issue(userID, spaceID, path string) (string, time.Time, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", time.Time{}, err
+	}
+	token := hex.EncodeToString(buf)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	for key, confirmation := range c.tokens {
+		if !now.Before(confirmation.expiresAt) {
+			delete(c.tokens, key)
+		}
+	}
+	expiresAt := now.Add(deleteConfirmationTTL)
+	c.tokens[token] = deleteConfirmation{userID: userID, spaceID: spaceID, path: path, expiresAt: expiresAt}
+	return token, expiresAt, nil
+}
+
+// consume reports whether token confirms the delete of path by userID.
+// A matching token can only be used once.
+func (c *deleteConfirmations) consume(token, userID, spaceID, path string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	confirmation, ok := c.tokens[token]
+	if !ok {
+		return false
+	}
+	if confirmation.userID != userID || confirmation.spaceID != spaceID || confirmation.path != path {
+		return false
+	}
+	delete(c.tokens, token)
+	return c.now().Before(confirmation.expiresAt)
+}
+
+// DeleteFolder is the resolver for the deleteFolder field.
+func (r *mutationResolver) DeleteFolder(ctx context.Context, path string, recursive *bool, confirmationToken *string, spaceID *string) (*gql.DeleteFolderResult, error) {
+	if strings.Trim(path, "/") == "" {
+		return nil, &gqlerror.Error{
+			Message:    "cannot delete the root folder",
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	path, err := ScopePath(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if err := RequireWritePermission(ctx, path); err != nil {
+		return nil, err
+	}
+	path = strings.Trim(path, "/")
+	stor, sp, err := r.resolveUploadStorageTarget(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	info, err := stor.Stat(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file stats: %w", err)
+	}
+	if !info.IsDir {
+		return nil, &gqlerror.Error{
+			Message:    fmt.Sprintf("%s is not a folder", path),
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+
+	count, moreItems, err := countFolderFiles(ctx, stor, path, maxFolderCountItems)
+	if err != nil {
+		return nil, err
+	}
+	result := &gql.DeleteFolderResult{ItemCount: count, MoreItems: moreItems}
+	if count > 0 && (recursive == nil || !*recursive) {
+		return nil, &gqlerror.Error{
+			Message:    fmt.Sprintf("folder %s is not empty, delete it recursively", path),
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+
+	userID, _ := GetUserIDFromContext(ctx)
+	spaceKey := ""
+	if spaceID != nil {
+		spaceKey = *spaceID
+	}
+	if count > 0 {
+		if confirmationToken == nil || *confirmationToken == "" {
+			token, expiresAt, err := r.deleteConfirmations.issue(userID, spaceKey, path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to issue confirmation token: %w", err)
+			}
+			expires := expiresAt.UTC().Format(time.RFC3339)
+			result.ConfirmationToken = &token
+			result.ConfirmationExpiresAt = &expires
+			return result, nil
+		}
+		if !r.deleteConfirmations.consume(*confirmationToken, userID, spaceKey, path) {
+			return nil, &gqlerror.Error{
+				Message:    "invalid or expired confirmation token, request a new one",
+				Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+			}
+		}
+	}
+
+	// Resolved up front as the operation outlives the request
+	var tagScope string
+	if r.tagStore != nil {
+		if tagScope, err = r.tagScope(ctx, spaceID); err != nil {
+			return nil, err
+		}
+	}
+	var favoriteScope, albumScope, commentScope, ratingScope, coverScope, settingsScope, usageScope string
+	if r.favoriteStore != nil {
+		favoriteScope = fileMetadataScope(sp)
+	}
+	if r.albumStore != nil {
+		albumScope = tenantScope(ctx, sp)
+	}
+	if r.commentStore != nil {
+		commentScope = fileMetadataScope(sp)
+	}
+	if r.ratingStore != nil {
+		ratingScope = fileMetadataScope(sp)
+	}
+	if r.folderCoverStore != nil {
+		coverScope = fileMetadataScope(sp)
+	}
+	if r.folderSettingsStore != nil {
+		settingsScope = fileMetadataScope(sp)
+	}
+	if r.storageStatsStore != nil {
+		usageScope = fileMetadataScope(sp)
+	}
+	r.logger.Info("Deleting folder", zap.String("path", path), zap.Int("itemCount", count), zap.Bool("moreItems", moreItems))
+	op := r.operations.Start(ctx, operationKindDeleteFolder, userID, func(ctx context.Context, progress *operation.Progress) error {
+		if err := r.deleteFolderWithProgress(ctx, stor, sp, path, progress); err != nil {
+			return err
+		}
+		if tagScope != "" {
+			if err := r.tagStore.RemoveFilePath(ctx, tagScope, path); err != nil {
+				r.logger.Warn("Failed to remove file tags", zap.String("path", path), zap.Error(err))
+			}
+		}
+		if favoriteScope != "" {
+			if err := r.favoriteStore.RemoveFilePath(ctx, favoriteScope, path); err != nil {
+				r.logger.Warn("Failed to remove favorites", zap.String("path", path), zap.Error(err))
+			}
+		}
+		if albumScope != "" {
+			if err := r.albumStore.RemoveFilePath(ctx, albumScope, path); err != nil {
+				r.logger.Warn("Failed to remove album items", zap.String("path", path), zap.Error(err))
+			}
+		}
+		if commentScope != "" {
+			if err := r.commentStore.RemoveFilePath(ctx, commentScope, path); err != nil {
+				r.logger.Warn("Failed to remove comments", zap.String("path", path), zap.Error(err))
+			}
+		}
+		if ratingScope != "" {
+			if err := r.ratingStore.RemoveFilePath(ctx, ratingScope, path); err != nil {
+				r.logger.Warn("Failed to remove ratings", zap.String("path", path), zap.Error(err))
+			}
+		}
+		if coverScope != "" {
+			if err := r.folderCoverStore.RemoveFilePath(ctx, coverScope, path); err != nil {
+				r.logger.Warn("Failed to remove folder covers", zap.String("path", path), zap.Error(err))
+			}
+		}
+		if settingsScope != "" {
+			if err := r.folderSettingsStore.RemoveFilePath(ctx, settingsScope, path); err != nil {
+				r.logger.Warn("Failed to remove folder settings", zap.String("path", path), zap.Error(err))
+			}
+		}
+		if usageScope != "" {
+			if err := r.storageStatsStore.RemoveFilePath(ctx, usageScope, path); err != nil {
+				r.logger.Warn("Failed to remove storage usage", zap.String("path", path), zap.Error(err))
+			}
+		}
+		return nil
+	})
+	if !moreItems && count <= maxSyncFolderDeleteItems {
+		// Small folders are deleted before returning, the operation keeps
+		// running if the request goes away
+		if finished, err := r.operations.Wait(ctx, op.ID); err == nil {
+			op = finished
+			if op.Status == operation.StatusFailed {
+				return nil, fmt.Errorf("failed to delete folder: %s", op.Error)
+			}
+		}
+	}
+	result.Deleted = op.Status == operation.StatusSucceeded
+	result.RemovedCount = op.Completed
+	result.Operation = toGQLOperation(op)
+	return result, nil
+}
+
+// countFolderFiles counts the files below path, stopping after limit. Storage
+// able to count on its own, such as S3 listing folders concurrently, does so.
+func countFolderFiles(ctx context.Context, stor storage.Storage, path string, limit int) (int, bool, error) {
+	if counter, ok := stor.(storage.Counter); ok {
+		return counter.CountFiles(ctx, path, limit)
+	}
+	count := 0
+	err := walkStorageFiles(ctx, stor, path, func(storage.FileInfo) error {
+		if count >= limit {
+			return errFolderCountLimit
+		}
+		count++
+		return nil
+	})
+	if errors.Is(err, errFolderCountLimit) {
+		return count, true, nil
+	}
+	return count, false, err
+}
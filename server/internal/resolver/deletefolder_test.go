@@ -0,0 +1,91 @@
+package resolver
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+func TestDeleteFolder_RequiresConfirmation(t *testing.T) {
+	resolver, _, baseDir := newOperationTestResolver(t)
+	writeTestFile(t, baseDir, "album/a.jpg")
+	writeTestFile(t, baseDir, "album/nested/b.jpg")
+	writeTestFile(t, baseDir, "other/keep.jpg")
+	ctx := createReadWriteContext("user-1")
+
+	// Non-recursive deletes refuse non-empty folders
+	_, err := resolver.Mutation().DeleteFolder(ctx, "album", nil, nil, nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+
+	pending, err := resolver.Mutation().DeleteFolder(ctx, "album", boolPtr(true), nil, nil)
+	require.NoError(t, err)
+	assert.False(t, pending.Deleted)
+	assert.Equal(t, 2, pending.ItemCount)
+	assert.False(t, pending.MoreItems)
+	require.NotNil(t, pending.ConfirmationToken)
+	assert.NotNil(t, pending.ConfirmationExpiresAt)
+	assert.Nil(t, pending.Operation)
+	assert.FileExists(t, filepath.Join(baseDir, "album/a.jpg"))
+
+	// Tokens are bound to the caller and the path
+	_, err = resolver.Mutation().DeleteFolder(createReadWriteContext("user-2"), "album", boolPtr(true), pending.ConfirmationToken, nil)
+	assert.Error(t, err)
+	_, err = resolver.Mutation().DeleteFolder(ctx, "other", boolPtr(true), pending.ConfirmationToken, nil)
+	assert.Error(t, err)
+
+	// The mismatched attempts do not consume the token
+	result, err := resolver.Mutation().DeleteFolder(ctx, "/album/", boolPtr(true), pending.ConfirmationToken, nil)
+	require.NoError(t, err)
+	assert.True(t, result.Deleted)
+	assert.Equal(t, 2, result.RemovedCount)
+	require.NotNil(t, result.Operation)
+	assert.Equal(t, gql.OperationStatusSucceeded, result.Operation.Status)
+	_, err = os.Stat(filepath.Join(baseDir, "album"))
+	assert.True(t, os.IsNotExist(err))
+	assert.FileExists(t, filepath.Join(baseDir, "other/keep.jpg"))
+
+	// Tokens are single use
+	writeTestFile(t, baseDir, "album/c.jpg")
+	_, err = resolver.Mutation().DeleteFolder(ctx, "album", boolPtr(true), pending.ConfirmationToken, nil)
+	assert.Error(t, err)
+}
+
+func TestDeleteFolder_EmptyFolder(t *testing.T) {
+	resolver, _, baseDir := newOperationTestResolver(t)
+	require.NoError(t, os.MkdirAll(filepath.Join(baseDir, "empty"), 0755))
+	writeTestFile(t, baseDir, "file.jpg")
+	ctx := createReadWriteContext("user-1")
+
+	result, err := resolver.Mutation().DeleteFolder(ctx, "empty", nil, nil, nil)
+	require.NoError(t, err)
+	assert.True(t, result.Deleted)
+	assert.Zero(t, result.ItemCount)
+	_, err = os.Stat(filepath.Join(baseDir, "empty"))
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = resolver.Mutation().DeleteFolder(ctx, "file.jpg", boolPtr(true), nil, nil)
+	assert.Error(t, err)
+	_, err = resolver.Mutation().DeleteFolder(ctx, "/", boolPtr(true), nil, nil)
+	assert.Error(t, err)
+	_, err = resolver.Mutation().DeleteFolder(createReadOnlyContext("user-1"), "empty", nil, nil, nil)
+	assert.Error(t, err)
+}
+
+func TestDeleteConfirmations_Expiry(t *testing.T) {
+	c := newDeleteConfirmations()
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	token, _, err := c.issue("user-1", "", "album")
+	require.NoError(t, err)
+	now = now.Add(deleteConfirmationTTL + time.Second)
+	assert.False(t, c.consume(token, "user-1", "", "album"))
+}
@@ -0,0 +1,336 @@
+package resolver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+const (
+	// directUploadTTL is how long multipart upload URLs are valid. Uploads
+	// can still be finalized for as long after the URLs expire, for parts
+	// sent just before.
+	directUploadTTL = time.Hour
+	// maxDirectUploadParts is the part limit of S3 multipart uploads
+	maxDirectUploadParts = 10000
+)
+
+// directUploadPartSize is the part size of multipart direct uploads, files
+// up to it are sent with a single PUT. A variable so tests can lower it.
+var directUploadPartSize int64 = 64 * 1024 * 1024
+
+// directUpload is a direct upload awaiting finalizeUpload
+type directUpload struct {
+	userID  string
+	spaceID string
+	path    string
+	size    int64
+	// multipartID is the backend upload id of multipart uploads
+	multipartID string
+	partCount   int
+	// multipart aborts expired multipart uploads
+	multipart storage.MultipartPresignableStorage
+	expiresAt time.Time
+}
+
+// directUploads holds the direct uploads started and not yet finalized.
+// Like operations they live on the replica that started them.
+type directUploads struct {
+	now    func() time.Time
+	logger *zap.Logger
+
+	mu      sync.Mutex
+	uploads map[string]*directUpload
+}
+
+func newDirectUploads(logger *zap.Logger) *directUploads {
+	return &directUploads{now: time.Now, logger: logger, uploads: make(map[string]*directUpload)}
+}
+
+// add registers upload, returning its id. Expired uploads are dropped,
+// aborting their multipart uploads.
+func (u *directUploads) add(upload *directUpload) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(buf)
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	now := u.now()
+	for key, expired := range u.uploads {
+		if now.Before(expired.expiresAt.Add(directUploadTTL)) {
+			continue
+		}
+		delete(u.uploads, key)
+		if expired.multipartID != "" {
+			go u.abort(expired)
+		}
+	}
+	u.uploads[id] = upload
+	return id, nil
+}
+
+// get returns the upload id started by userID, nil when unknown
+func (u *directUploads) get(id, userID string) *directUpload {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	upload, ok := u.uploads[id]
+	if !ok || upload.userID != userID || !u.now().Before(upload.expiresAt.Add(directUploadTTL)) {
+		return nil
+	}
+	return upload
+}
+
+func (u *directUploads) remove(id string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	delete(u.uploads, id)
+}
+
+func (u *directUploads) abort(upload *directUpload) {
+	if err := upload.multipart.AbortMultipartUpload(context.Background(), upload.path, upload.multipartID); err != nil {
+		u.logger.Warn("Failed to abort multipart upload", zap.String("path", upload.path), zap.Error(err))
+	}
+}
+
+// CreatePresignedUpload is the resolver for the createPresignedUpload field.
+func (r *mutationResolver) CreatePresignedUpload(ctx context.Context, path string, contentType string, size int, spaceID *string) (*gql.DirectUpload, error) {
+	path, err := normalizeNewPath(path)
+	if err != nil {
+		return nil, err
+	}
+	path, err = r.routeUploadPath(ctx, path, contentType)
+	if err != nil {
+		return nil, err
+	}
+	if err := RequireWritePermission(ctx, path); err != nil {
+		return nil, err
+	}
+	if size <= 0 {
+		return nil, &gqlerror.Error{
+			Message:    "invalid size: must be greater than 0",
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	partCount := int((int64(size) + directUploadPartSize - 1) / directUploadPartSize)
+	if partCount > maxDirectUploadParts {
+		return nil, &gqlerror.Error{
+			Message:    fmt.Sprintf("file too large for direct upload: max %d bytes", directUploadPartSize*maxDirectUploadParts),
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	stor, sp, err := r.resolveUploadStorageTarget(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureSpaceUploadAllowed(sp); err != nil {
+		return nil, err
+	}
+	path, err = r.resolveUploadCollision(ctx, stor, path, r.uploadCollisionMode(nil))
+	if err != nil {
+		return nil, err
+	}
+	if err := r.enforceHostedStorageQuota(ctx, sp, int64(size)); err != nil {
+		return nil, err
+	}
+	if err := r.enforceStorageQuotas(ctx, sp, path, int64(size)); err != nil {
+		return nil, err
+	}
+
+	userID, _ := GetUserIDFromContext(ctx)
+	upload := &directUpload{userID: userID, path: path, size: int64(size), partCount: partCount}
+	if spaceID != nil {
+		upload.spaceID = *spaceID
+	}
+	result := &gql.DirectUpload{
+		Path:            path,
+		PartSize:        int(directUploadPartSize),
+		Parts:           []*gql.PresignedUploadPart{},
+		RequiredHeaders: []*gql.UploadHeader{},
+	}
+	if partCount == 1 {
+		uploadURL, requiredHeaders, expiresAt, err := r.presignPut(ctx, stor, sp, path, contentType, int64(size))
+		if err != nil {
+			return nil, err
+		}
+		upload.expiresAt = expiresAt
+		result.UploadURL = &uploadURL
+		result.RequiredHeaders = requiredHeaders
+	} else {
+		if r.uploadScanner != nil {
+			return nil, scannedDirectUploadError()
+		}
+		if err := r.checkUploadExtension(path); err != nil {
+			return nil, err
+		}
+		multipart, ok := stor.(storage.MultipartPresignableStorage)
+		if !ok || r.tracksHostedStorage(sp) {
+			return nil, &gqlerror.Error{
+				Message:    "current storage backend does not support multipart direct uploads, use chunked uploads",
+				Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+			}
+		}
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		upload.multipart = multipart
+		upload.multipartID, err = multipart.CreateMultipartUpload(ctx, path, contentType)
+		if err != nil {
+			r.logger.Error("Failed to create multipart upload", zap.Error(err), zap.String("path", path))
+			return nil, fmt.Errorf("failed to create multipart upload: %w", err)
+		}
+		upload.expiresAt = time.Now().UTC().Add(directUploadTTL)
+		for number := 1; number <= partCount; number++ {
+			partURL, err := multipart.PresignedUploadPartURL(ctx, path, upload.multipartID, number, directUploadTTL)
+			if err != nil {
+				r.directUploads.abort(upload)
+				r.logger.Error("Failed to generate presigned part URL", zap.Error(err), zap.String("path", path))
+				return nil, fmt.Errorf("failed to generate upload URL: %w", err)
+			}
+			result.Parts = append(result.Parts, &gql.PresignedUploadPart{PartNumber: number, UploadURL: partURL})
+		}
+	}
+	if result.ID, err = r.directUploads.add(upload); err != nil {
+		return nil, err
+	}
+	result.ExpiresAt = upload.expiresAt.Format(time.RFC3339)
+	r.logger.Debug("Direct upload started",
+		zap.String("path", path),
+		zap.Int64("sizeBytes", upload.size),
+		zap.Int("parts", partCount))
+	return result, nil
+}
+
+// FinalizeUpload is the resolver for the finalizeUpload field.
+func (r *mutationResolver) FinalizeUpload(ctx context.Context, id string, parts []*gql.UploadPartInput) (*gql.UploadResult, error) {
+	upload, err := r.getOwnDirectUpload(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := RequireWritePermission(ctx, upload.path); err != nil {
+		return nil, err
+	}
+	var spaceID *string
+	if upload.spaceID != "" {
+		spaceID = &upload.spaceID
+	}
+	stor, sp, err := r.resolveUploadStorageTarget(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureSpaceUploadAllowed(sp); err != nil {
+		return nil, err
+	}
+
+	if upload.multipartID != "" {
+		if len(parts) != upload.partCount {
+			return nil, &gqlerror.Error{
+				Message:    fmt.Sprintf("expected the ETags of %d parts, got %d", upload.partCount, len(parts)),
+				Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+			}
+		}
+		completed := make([]storage.UploadPart, len(parts))
+		for i, part := range parts {
+			completed[i] = storage.UploadPart{Number: part.PartNumber, ETag: part.Etag}
+		}
+		sort.Slice(completed, func(i, j int) bool { return completed[i].Number < completed[j].Number })
+		// Failures keep the upload so the client can resend parts and retry
+		if err := upload.multipart.CompleteMultipartUpload(ctx, upload.path, upload.multipartID, completed); err != nil {
+			r.logger.Warn("Failed to complete multipart upload", zap.Error(err), zap.String("path", upload.path))
+			return nil, &gqlerror.Error{
+				Message:    fmt.Sprintf("failed to complete multipart upload: %v", err),
+				Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+			}
+		}
+	}
+
+	info, err := stor.Stat(ctx, upload.path)
+	if err != nil {
+		return nil, &gqlerror.Error{
+			Message:    "uploaded file not found, send its content before finalizing",
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	r.directUploads.remove(id)
+	if info.IsDir || info.Size != upload.size {
+		r.discardDirectUpload(ctx, stor, upload.path)
+		return nil, &gqlerror.Error{
+			Message:    fmt.Sprintf("uploaded file has %d bytes, %d announced", info.Size, upload.size),
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	contentType, err := r.verifyStoredUploadType(ctx, stor, upload.path)
+	if err != nil {
+		r.discardDirectUpload(ctx, stor, upload.path)
+		return nil, err
+	}
+
+	duplicate, err := r.findDuplicateStored(ctx, stor, sp, upload.path, info.Size)
+	if err != nil || duplicate.existing != "" {
+		r.discardDirectUpload(ctx, stor, upload.path)
+		if err != nil {
+			return nil, err
+		}
+		return &gql.UploadResult{Path: duplicate.existing, Deduplicated: true}, nil
+	}
+	if r.tracksHostedStorage(sp) {
+		if _, err := r.hostedStorageStore.FinalizePendingUpload(ctx, sp.ID, upload.path, info.Size); err != nil {
+			r.cleanupHostedUploadFailure(ctx, stor, sp, upload.path, err)
+			r.logger.Error("Failed to finalize hosted upload", zap.Error(err), zap.String("spaceID", sp.ID), zap.String("path", upload.path), zap.Int64("sizeBytes", info.Size))
+			return nil, fmt.Errorf("failed to finalize upload: %w", err)
+		}
+	}
+	r.recordUpload(ctx, stor, sp, upload.path, info.Size)
+	r.recordUploadContentType(ctx, stor, sp, upload.path, contentType)
+	r.publishSpaceUpload(sp, upload.path)
+	if duplicate.hash != "" {
+		r.recordUploadHash(ctx, stor, sp, upload.path, duplicate.hash)
+	}
+	r.logger.Debug("Direct upload finalized", zap.String("path", upload.path), zap.Int64("sizeBytes", info.Size))
+	return &gql.UploadResult{Path: upload.path}, nil
+}
+
+// AbortPresignedUpload is the resolver for the abortPresignedUpload field.
+func (r *mutationResolver) AbortPresignedUpload(ctx context.Context, id string) (bool, error) {
+	upload, err := r.getOwnDirectUpload(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	r.directUploads.remove(id)
+	if upload.multipartID != "" {
+		r.directUploads.abort(upload)
+	}
+	return true, nil
+}
+
+// getOwnDirectUpload returns a direct upload started by the current user.
+// Uploads of other users are reported as not found.
+func (r *Resolver) getOwnDirectUpload(ctx context.Context, id string) (*directUpload, error) {
+	userID, _ := GetUserIDFromContext(ctx)
+	upload := r.directUploads.get(id, userID)
+	if upload == nil {
+		return nil, &gqlerror.Error{
+			Message:    "direct upload not found",
+			Extensions: map[string]interface{}{"code": "NOT_FOUND"},
+		}
+	}
+	return upload, nil
+}
+
+// discardDirectUpload deletes a direct upload rejected when finalizing
+func (r *Resolver) discardDirectUpload(ctx context.Context, stor storage.Storage, path string) {
+	if err := stor.Delete(ctx, path); err != nil {
+		r.logger.Warn("Failed to delete rejected direct upload", zap.String("path", path), zap.Error(err))
+	}
+}
@@ -0,0 +1,173 @@
+package resolver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/cshum/imagor-studio/server/pkg/storage/s3storage"
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func newDirectUploadTestResolver(t *testing.T, stor storage.Storage) *Resolver {
+	t.Helper()
+	mockImagorProvider := new(MockImagorProvider)
+	mockImagorProvider.On("Imagor").Return((*imagor.Imagor)(nil))
+	mockRegistryStore := new(MockRegistryStore)
+	mockRegistryStore.On("GetMulti", mock.Anything, mock.Anything, mock.Anything).Return([]*registrystore.Registry{}, nil)
+	mockRegistryStore.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
+	return newTestResolver(NewMockStorageProvider(stor), mockRegistryStore, new(MockUserStore), mockImagorProvider, &config.Config{}, nil, zap.NewNop())
+}
+
+func newFakeS3Storage(t *testing.T) *s3storage.S3Storage {
+	t.Helper()
+	backend := s3mem.New()
+	require.NoError(t, backend.CreateBucket("test-bucket"))
+	ts := httptest.NewServer(gofakes3.New(backend).Server())
+	t.Cleanup(ts.Close)
+	stor, err := s3storage.New("test-bucket",
+		s3storage.WithRegion("us-east-1"),
+		s3storage.WithEndpoint(ts.URL),
+		s3storage.WithCredentials("test-key", "test-secret", ""),
+		s3storage.WithForcePathStyle(true))
+	require.NoError(t, err)
+	return stor
+}
+
+// putPresigned sends content to a presigned URL as clients do, returning
+// the ETag response header
+func putPresigned(t *testing.T, uploadURL, content string) string {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPut, uploadURL, strings.NewReader(content))
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	return resp.Header.Get("ETag")
+}
+
+func TestDirectUpload_SinglePut(t *testing.T) {
+	stor := newFakeS3Storage(t)
+	resolver := newDirectUploadTestResolver(t, stor)
+	ctx := createReadWriteContext("user-1")
+
+	upload, err := resolver.Mutation().CreatePresignedUpload(ctx, "photos/a.txt", "text/plain", 5, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "photos/a.txt", upload.Path)
+	require.NotNil(t, upload.UploadURL)
+	assert.Empty(t, upload.Parts)
+
+	// Finalizing before the content is sent keeps the upload
+	_, err = resolver.Mutation().FinalizeUpload(ctx, upload.ID, nil)
+	assert.Error(t, err)
+
+	putPresigned(t, *upload.UploadURL, "hello")
+	_, err = resolver.Mutation().FinalizeUpload(createReadWriteContext("user-2"), upload.ID, nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_FOUND", gqlErr.Extensions["code"], "uploads of other users are not found")
+
+	result, err := resolver.Mutation().FinalizeUpload(ctx, upload.ID, nil)
+	require.NoError(t, err)
+	assert.Equal(t, &gql.UploadResult{Path: "photos/a.txt"}, result)
+	info, err := stor.Stat(context.Background(), "photos/a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), info.Size)
+
+	_, err = resolver.Mutation().FinalizeUpload(ctx, upload.ID, nil)
+	assert.Error(t, err, "uploads are finalized once")
+}
+
+func TestDirectUpload_SizeMismatch(t *testing.T) {
+	stor := newFakeS3Storage(t)
+	resolver := newDirectUploadTestResolver(t, stor)
+	ctx := createReadWriteContext("user-1")
+
+	upload, err := resolver.Mutation().CreatePresignedUpload(ctx, "photos/a.txt", "text/plain", 10, nil)
+	require.NoError(t, err)
+	require.NoError(t, stor.Put(context.Background(), "photos/a.txt", strings.NewReader("short")))
+
+	_, err = resolver.Mutation().FinalizeUpload(ctx, upload.ID, nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+	_, err = stor.Stat(context.Background(), "photos/a.txt")
+	assert.Error(t, err, "mismatching uploads are deleted")
+}
+
+func TestDirectUpload_Multipart(t *testing.T) {
+	partSize := directUploadPartSize
+	directUploadPartSize = 5 * 1024 * 1024
+	t.Cleanup(func() { directUploadPartSize = partSize })
+	stor := newFakeS3Storage(t)
+	resolver := newDirectUploadTestResolver(t, stor)
+	ctx := createReadWriteContext("user-1")
+
+	content := strings.Repeat("a", int(directUploadPartSize)) + "end"
+	upload, err := resolver.Mutation().CreatePresignedUpload(ctx, "videos/big.mp4", "video/mp4", len(content), nil)
+	require.NoError(t, err)
+	assert.Nil(t, upload.UploadURL)
+	assert.Equal(t, int(directUploadPartSize), upload.PartSize)
+	require.Len(t, upload.Parts, 2)
+
+	var parts []*gql.UploadPartInput
+	for _, part := range upload.Parts {
+		start := int64(part.PartNumber-1) * directUploadPartSize
+		end := min(start+directUploadPartSize, int64(len(content)))
+		etag := putPresigned(t, part.UploadURL, content[start:end])
+		parts = append(parts, &gql.UploadPartInput{PartNumber: part.PartNumber, Etag: etag})
+	}
+
+	_, err = resolver.Mutation().FinalizeUpload(ctx, upload.ID, parts[:1])
+	assert.Error(t, err, "every part is required")
+
+	// Parts may be listed in any order
+	result, err := resolver.Mutation().FinalizeUpload(ctx, upload.ID, []*gql.UploadPartInput{parts[1], parts[0]})
+	require.NoError(t, err)
+	assert.Equal(t, "videos/big.mp4", result.Path)
+	info, err := stor.Stat(context.Background(), "videos/big.mp4")
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(content)), info.Size)
+}
+
+func TestDirectUpload_Abort(t *testing.T) {
+	partSize := directUploadPartSize
+	directUploadPartSize = 5 * 1024 * 1024
+	t.Cleanup(func() { directUploadPartSize = partSize })
+	resolver := newDirectUploadTestResolver(t, newFakeS3Storage(t))
+	ctx := createReadWriteContext("user-1")
+
+	upload, err := resolver.Mutation().CreatePresignedUpload(ctx, "videos/big.mp4", "video/mp4", int(directUploadPartSize)+1, nil)
+	require.NoError(t, err)
+	ok, err := resolver.Mutation().AbortPresignedUpload(ctx, upload.ID)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	_, err = resolver.Mutation().FinalizeUpload(ctx, upload.ID, nil)
+	assert.Error(t, err)
+}
+
+func TestDirectUpload_NotAvailable(t *testing.T) {
+	stor, err := filestorage.New(t.TempDir())
+	require.NoError(t, err)
+	resolver := newDirectUploadTestResolver(t, stor)
+
+	_, err = resolver.Mutation().CreatePresignedUpload(createReadWriteContext("user-1"), "photos/a.txt", "text/plain", 5, nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_AVAILABLE", gqlErr.Extensions["code"])
+}
@@ -0,0 +1,307 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/bulkdownload"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/pkg/space"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+const (
+	// defaultDownloadURLTTL is how long downloadUrl URLs are valid by default
+	defaultDownloadURLTTL = 15 * time.Minute
+	// maxDownloadURLTTL bounds the expiresIn of downloadUrl
+	maxDownloadURLTTL = 24 * time.Hour
+)
+
+// errSelectionLimit stops walking folders once the selection is too large
+var errSelectionLimit = errors.New("selection limit reached")
+
+// CreateBulkDownload is the resolver for the createBulkDownload field.
+func (r *mutationResolver) CreateBulkDownload(ctx context.Context, paths []string, spaceID *string) (*gql.BulkDownload, error) {
+	token, _, err := r.issueDownloadToken(ctx, paths, spaceID, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &gql.BulkDownload{
+		Token:      token.ID,
+		ExpiresAt:  token.ExpiresAt.UTC().Format(time.RFC3339),
+		FileCount:  len(token.Files),
+		TotalBytes: int(token.TotalBytes()),
+		ListURL:    r.bulkDownloads.ListPath(token.ID, bulkdownload.FormatURLs),
+		Aria2Url:   r.bulkDownloads.ListPath(token.ID, bulkdownload.FormatAria2),
+	}, nil
+}
+
+// PrepareDownload is the resolver for the prepareDownload field.
+func (r *mutationResolver) PrepareDownload(ctx context.Context, paths []string, spaceID *string) (*gql.PreparedDownload, error) {
+	token, scoped, err := r.issueDownloadToken(ctx, paths, spaceID, bulkdownload.ArchiveTTL)
+	if err != nil {
+		return nil, err
+	}
+	return &gql.PreparedDownload{
+		Token:      token.ID,
+		ExpiresAt:  token.ExpiresAt.UTC().Format(time.RFC3339),
+		FileCount:  len(token.Files),
+		TotalBytes: int(token.TotalBytes()),
+		URL:        r.bulkDownloads.ArchivePath(token.ID, archiveName(scoped)),
+	}, nil
+}
+
+// issueDownloadToken checks access to the selected paths and issues a
+// download token for the files they contain, valid for ttl or the
+// configured bulk download TTL when zero. The paths are returned scoped to
+// the home path of the request.
+func (r *mutationResolver) issueDownloadToken(ctx context.Context, paths []string, spaceID *string, ttl time.Duration) (*bulkdownload.Token, []string, error) {
+	if r.bulkDownloads == nil {
+		return nil, nil, &gqlerror.Error{
+			Message:    "bulk downloads are not available",
+			Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+		}
+	}
+	if len(paths) == 0 {
+		return nil, nil, &gqlerror.Error{
+			Message:    "at least one path is required",
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	if !CanDownloadOriginals(ctx) {
+		return nil, nil, fmt.Errorf("insufficient permission: downloads are not allowed for this shared link")
+	}
+	paths, err := scopeDownloadPaths(ctx, paths)
+	if err != nil {
+		return nil, nil, err
+	}
+	stor, _, err := r.downloadStorage(ctx, spaceID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	manager := r.bulkDownloads.Manager()
+	files, err := collectDownloadFiles(ctx, stor, paths, manager.MaxFiles())
+	if errors.Is(err, errSelectionLimit) {
+		return nil, nil, &gqlerror.Error{
+			Message:    fmt.Sprintf("too many files selected: max %d", manager.MaxFiles()),
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ownerID, _ := GetUserIDFromContext(ctx)
+	var token *bulkdownload.Token
+	if ttl > 0 {
+		token, err = manager.CreateWithTTL(stor, ownerID, files, ttl)
+	} else {
+		token, err = manager.Create(stor, ownerID, files)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, bulkdownload.ErrEmptySelection):
+			return nil, nil, &gqlerror.Error{
+				Message:    "the selection contains no files",
+				Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+			}
+		case errors.Is(err, bulkdownload.ErrTooManyTokens):
+			return nil, nil, &gqlerror.Error{
+				Message:    "too many active bulk downloads, try again later",
+				Extensions: map[string]interface{}{"code": "TOO_MANY_REQUESTS"},
+			}
+		}
+		return nil, nil, fmt.Errorf("failed to create bulk download: %w", err)
+	}
+	return token, paths, nil
+}
+
+// scopeDownloadPaths scopes the selected paths to the home path of the
+// request and checks read access to them. The storage root can only be
+// selected by requests reaching the whole storage, as reading it walks every
+// file.
+func scopeDownloadPaths(ctx context.Context, paths []string) ([]string, error) {
+	scoped := make([]string, len(paths))
+	for i, p := range paths {
+		p, err := ScopePath(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		p = strings.Trim(p, "/")
+		if root := accessRoot(ctx); p == "" && root != "" {
+			return nil, fmt.Errorf("path access denied: the storage root is not within %s", root)
+		}
+		if err := RequireReadPermission(ctx, p); err != nil {
+			return nil, err
+		}
+		scoped[i] = p
+	}
+	return scoped, nil
+}
+
+// downloadStorage returns the storage of the space downloads read from
+func (r *Resolver) downloadStorage(ctx context.Context, spaceID *string) (storage.Storage, *space.Space, error) {
+	spaceConfig, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err != nil {
+		return nil, nil, err
+	}
+	var stor storage.Storage
+	if spaceConfig != nil {
+		stor, err = r.storageFromSpaceConfig(spaceConfig)
+	} else {
+		stor, err = r.getSpaceStorageByID(ctx, spaceID)
+	}
+	return stor, spaceConfig, err
+}
+
+// DownloadURL is the resolver for the downloadUrl field.
+func (r *queryResolver) DownloadURL(ctx context.Context, filePath string, expiresIn *int, spaceID *string) (*gql.DownloadLink, error) {
+	ttl := defaultDownloadURLTTL
+	if expiresIn != nil {
+		if *expiresIn < 1 || time.Duration(*expiresIn)*time.Second > maxDownloadURLTTL {
+			return nil, &gqlerror.Error{
+				Message:    fmt.Sprintf("expiresIn must be between 1 and %d seconds", int(maxDownloadURLTTL.Seconds())),
+				Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+			}
+		}
+		ttl = time.Duration(*expiresIn) * time.Second
+	}
+	filePath, err := ScopePath(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+	if !CanDownloadOriginals(ctx) {
+		return nil, fmt.Errorf("insufficient permission: downloads are not allowed for this shared link")
+	}
+	if err := RequireReadPermission(ctx, filePath); err != nil {
+		return nil, err
+	}
+	stor, spaceConfig, err := r.downloadStorage(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	filePath = strings.Trim(filePath, "/")
+	info, err := stor.Stat(ctx, filePath)
+	if err != nil || info.IsDir {
+		return nil, &gqlerror.Error{
+			Message:    fmt.Sprintf("file not found: %s", filePath),
+			Extensions: map[string]interface{}{"code": "NOT_FOUND"},
+		}
+	}
+
+	expiresAt := time.Now().Add(ttl).UTC().Format(time.RFC3339)
+	if presignable, ok := stor.(storage.DownloadPresignableStorage); ok {
+		url, err := presignable.PresignedGetURL(ctx, filePath, path.Base(filePath), ttl)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate download URL: %w", err)
+		}
+		return &gql.DownloadLink{URL: url, ExpiresAt: expiresAt, Direct: true}, nil
+	}
+	if r.bulkDownloads == nil {
+		return nil, &gqlerror.Error{
+			Message:    "downloads are not available",
+			Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+		}
+	}
+	ownerID, _ := GetUserIDFromContext(ctx)
+	file := bulkdownload.File{Path: filePath, Size: info.Size, ContentType: r.storedContentType(ctx, spaceConfig, filePath, info)}
+	token, err := r.bulkDownloads.Manager().CreateWithTTL(stor, ownerID, []bulkdownload.File{file}, ttl)
+	if errors.Is(err, bulkdownload.ErrTooManyTokens) {
+		return nil, &gqlerror.Error{
+			Message:    "too many active downloads, try again later",
+			Extensions: map[string]interface{}{"code": "TOO_MANY_REQUESTS"},
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create download: %w", err)
+	}
+	return &gql.DownloadLink{
+		URL:       r.bulkDownloads.FilePath(token.ID, filePath),
+		ExpiresAt: token.ExpiresAt.UTC().Format(time.RFC3339),
+	}, nil
+}
+
+// archiveName names the zip archive after a single selected file or
+// folder
+func archiveName(paths []string) string {
+	if len(paths) != 1 {
+		return "download"
+	}
+	name := path.Base(strings.Trim(paths[0], "/"))
+	if name == "." || name == "/" {
+		return "download"
+	}
+	return strings.TrimSuffix(name, path.Ext(name))
+}
+
+// RevokeBulkDownload is the resolver for the revokeBulkDownload field.
+func (r *mutationResolver) RevokeBulkDownload(ctx context.Context, token string) (bool, error) {
+	if err := RequirePermission(ctx, "read"); err != nil {
+		return false, err
+	}
+	if r.bulkDownloads == nil {
+		return false, nil
+	}
+	manager := r.bulkDownloads.Manager()
+	issued, err := manager.Get(token)
+	if err != nil {
+		return false, nil
+	}
+	if RequireAdminPermission(ctx) != nil {
+		if userID, _ := GetUserIDFromContext(ctx); userID != issued.OwnerID {
+			return false, nil
+		}
+	}
+	manager.Revoke(token)
+	return true, nil
+}
+
+// collectDownloadFiles resolves the selected paths to files, expanding
+// folders and skipping hidden files inside them
+func collectDownloadFiles(ctx context.Context, stor storage.Storage, paths []string, limit int) ([]bulkdownload.File, error) {
+	seen := make(map[string]bool)
+	var files []bulkdownload.File
+	add := func(item storage.FileInfo) error {
+		if seen[item.Path] {
+			return nil
+		}
+		if len(files) >= limit {
+			return errSelectionLimit
+		}
+		seen[item.Path] = true
+		files = append(files, bulkdownload.File{Path: item.Path, Size: item.Size})
+		return nil
+	}
+	for _, p := range paths {
+		p = strings.Trim(p, "/")
+		info, err := stor.Stat(ctx, p)
+		if err != nil {
+			return nil, &gqlerror.Error{
+				Message:    fmt.Sprintf("file not found: %s", p),
+				Extensions: map[string]interface{}{"code": "NOT_FOUND"},
+			}
+		}
+		if !info.IsDir {
+			info.Path = p
+			if err := add(info); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err := walkStorageFiles(ctx, stor, p, func(item storage.FileInfo) error {
+			if rel := "/" + strings.TrimPrefix(item.Path, p+"/"); strings.Contains(rel, "/.") {
+				return nil
+			}
+			return add(item)
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,216 @@
+package resolver
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/bulkdownload"
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/pkg/auth"
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func newDownloadTestResolver(t *testing.T, opts ...bulkdownload.Option) (*Resolver, *bulkdownload.Handler) {
+	t.Helper()
+	baseDir := t.TempDir()
+	writeTestFile(t, baseDir, "album/a.jpg")
+	writeTestFile(t, baseDir, "album/nested/b.jpg")
+	writeTestFile(t, baseDir, "album/.hidden/c.jpg")
+	writeTestFile(t, baseDir, "album/.DS_Store")
+	writeTestFile(t, baseDir, "single.mp4")
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+	handler := bulkdownload.NewHandler(bulkdownload.NewManager(opts...), "/api/downloads")
+	logger, _ := zap.NewDevelopment()
+	return newTestResolver(NewMockStorageProvider(stor), new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, logger,
+		WithBulkDownloads(handler)), handler
+}
+
+func TestCreateBulkDownload(t *testing.T) {
+	resolver, handler := newDownloadTestResolver(t)
+	ctx := createReadOnlyContext("user-1")
+
+	result, err := resolver.Mutation().CreateBulkDownload(ctx, []string{"album", "single.mp4", "album/a.jpg"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.FileCount)
+	assert.Positive(t, result.TotalBytes)
+	assert.Equal(t, "/api/downloads/"+result.Token, result.ListURL)
+	assert.Equal(t, "/api/downloads/"+result.Token+"?format=aria2", result.Aria2Url)
+	assert.NotEmpty(t, result.ExpiresAt)
+
+	token, err := handler.Manager().Get(result.Token)
+	require.NoError(t, err)
+	var paths []string
+	for _, f := range token.Files {
+		paths = append(paths, f.Path)
+	}
+	assert.ElementsMatch(t, []string{"album/a.jpg", "album/nested/b.jpg", "single.mp4"}, paths)
+}
+
+func TestPrepareDownload(t *testing.T) {
+	resolver, handler := newDownloadTestResolver(t)
+	ctx := createReadOnlyContext("user-1")
+
+	result, err := resolver.Mutation().PrepareDownload(ctx, []string{"/album/"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.FileCount)
+	assert.Equal(t, "/api/downloads/"+result.Token+"/archive/album.zip", result.URL)
+
+	token, err := handler.Manager().Get(result.Token)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(bulkdownload.ArchiveTTL), token.ExpiresAt, time.Minute)
+
+	result, err = resolver.Mutation().PrepareDownload(ctx, []string{"album/a.jpg", "single.mp4"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "/api/downloads/"+result.Token+"/archive/download.zip", result.URL)
+
+	result, err = resolver.Mutation().PrepareDownload(ctx, []string{"single.mp4"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "/api/downloads/"+result.Token+"/archive/single.zip", result.URL)
+}
+
+func TestCreateBulkDownload_Errors(t *testing.T) {
+	resolver, _ := newDownloadTestResolver(t, bulkdownload.WithMaxFiles(1))
+	ctx := createReadOnlyContext("user-1")
+	var gqlErr *gqlerror.Error
+
+	_, err := resolver.Mutation().CreateBulkDownload(ctx, []string{"album"}, nil)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+
+	_, err = resolver.Mutation().CreateBulkDownload(ctx, []string{"missing.jpg"}, nil)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_FOUND", gqlErr.Extensions["code"])
+
+	_, err = resolver.Mutation().CreateBulkDownload(ctx, nil, nil)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+
+	logger, _ := zap.NewDevelopment()
+	disabled := newTestResolver(nil, new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, logger)
+	_, err = disabled.Mutation().CreateBulkDownload(ctx, []string{"album"}, nil)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_AVAILABLE", gqlErr.Extensions["code"])
+}
+
+func TestCreateBulkDownload_ScopedToAccessRoot(t *testing.T) {
+	resolver, handler := newDownloadTestResolver(t)
+	tokenPaths := func(token string) []string {
+		issued, err := handler.Manager().Get(token)
+		require.NoError(t, err)
+		var paths []string
+		for _, f := range issued.Files {
+			paths = append(paths, f.Path)
+		}
+		return paths
+	}
+
+	// The storage root and paths outside resolve below the home path
+	home := WithHomePath(createReadOnlyContext("user-2"), "album")
+	result, err := resolver.Mutation().CreateBulkDownload(home, []string{""}, nil)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"album/a.jpg", "album/nested/b.jpg"}, tokenPaths(result.Token))
+	prepared, err := resolver.Mutation().PrepareDownload(home, []string{"/"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "/api/downloads/"+prepared.Token+"/archive/album.zip", prepared.URL)
+	_, err = resolver.Mutation().CreateBulkDownload(home, []string{"../other"}, nil)
+	assert.ErrorContains(t, err, "path access denied")
+	_, err = resolver.Mutation().PrepareDownload(home, []string{"single.mp4"}, nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_FOUND", gqlErr.Extensions["code"])
+
+	// Shared links cannot select the storage root
+	shared := auth.SetClaimsInContext(context.Background(), &auth.Claims{
+		UserID: "guest", Role: "guest", Scopes: []string{"read"}, PathPrefix: "album",
+	})
+	_, err = resolver.Mutation().CreateBulkDownload(shared, []string{""}, nil)
+	assert.ErrorContains(t, err, "path access denied")
+	_, err = resolver.Mutation().PrepareDownload(shared, []string{"/"}, nil)
+	assert.ErrorContains(t, err, "path access denied")
+	_, err = resolver.Mutation().CreateBulkDownload(shared, []string{"single.mp4"}, nil)
+	assert.ErrorContains(t, err, "path access denied")
+	result, err = resolver.Mutation().CreateBulkDownload(shared, []string{"album/nested"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"album/nested/b.jpg"}, tokenPaths(result.Token))
+}
+
+func TestRevokeBulkDownload_OwnerOrAdmin(t *testing.T) {
+	resolver, handler := newDownloadTestResolver(t)
+
+	result, err := resolver.Mutation().CreateBulkDownload(createReadOnlyContext("user-1"), []string{"single.mp4"}, nil)
+	require.NoError(t, err)
+
+	ok, err := resolver.Mutation().RevokeBulkDownload(createReadOnlyContext("user-2"), result.Token)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	_, err = handler.Manager().Get(result.Token)
+	require.NoError(t, err)
+
+	ok, err = resolver.Mutation().RevokeBulkDownload(createReadOnlyContext("user-1"), result.Token)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	_, err = handler.Manager().Get(result.Token)
+	assert.ErrorIs(t, err, bulkdownload.ErrTokenNotFound)
+
+	result, err = resolver.Mutation().CreateBulkDownload(createReadOnlyContext("user-1"), []string{"single.mp4"}, nil)
+	require.NoError(t, err)
+	ok, err = resolver.Mutation().RevokeBulkDownload(createAdminContext("admin"), result.Token)
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestDownloadURL(t *testing.T) {
+	resolver, handler := newDownloadTestResolver(t)
+	ctx := createReadOnlyContext("user-1")
+
+	link, err := resolver.Query().DownloadURL(ctx, "/album/a.jpg", intPtr(60), nil)
+	require.NoError(t, err)
+	assert.False(t, link.Direct)
+	assert.True(t, strings.HasPrefix(link.URL, "/api/downloads/"))
+	assert.True(t, strings.HasSuffix(link.URL, "/files/album/a.jpg"))
+	expiresAt, err := time.Parse(time.RFC3339, link.ExpiresAt)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), expiresAt, 5*time.Second)
+
+	// The server streams the file with Range support
+	req := httptest.NewRequest("GET", strings.TrimPrefix(link.URL, "/api/downloads"), nil)
+	req.Header.Set("Range", "bytes=1-2")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusPartialContent, rec.Code)
+	assert.Equal(t, "es", rec.Body.String())
+
+	var gqlErr *gqlerror.Error
+	_, err = resolver.Query().DownloadURL(ctx, "album", nil, nil)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_FOUND", gqlErr.Extensions["code"], "folders are not downloaded")
+	_, err = resolver.Query().DownloadURL(ctx, "album/a.jpg", intPtr(2*24*60*60), nil)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+}
+
+func TestDownloadURL_Presigned(t *testing.T) {
+	stor := newFakeS3Storage(t)
+	require.NoError(t, stor.Put(context.Background(), "album/a.jpg", strings.NewReader("test")))
+	resolver := newTestResolver(NewMockStorageProvider(stor), new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop())
+
+	link, err := resolver.Query().DownloadURL(createReadOnlyContext("user-1"), "album/a.jpg", nil, nil)
+	require.NoError(t, err)
+	assert.True(t, link.Direct)
+	resp, err := http.Get(link.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "test", string(body))
+}
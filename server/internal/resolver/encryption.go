@@ -0,0 +1,32 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// RotateEncryptionKey is the resolver for the rotateEncryptionKey field.
+func (r *mutationResolver) RotateEncryptionKey(ctx context.Context) (*gql.EncryptionKeyRotation, error) {
+	if err := RequireAdminPermission(ctx); err != nil {
+		return nil, err
+	}
+	if r.keyRotationDB == nil || r.encryption == nil {
+		return nil, &gqlerror.Error{
+			Message:    "encryption key rotation is not available on this server",
+			Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+		}
+	}
+	rotated, err := registrystore.RotateEncryptionKey(ctx, r.keyRotationDB, r.encryption)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rotate encryption key: %w", err)
+	}
+	return &gql.EncryptionKeyRotation{
+		Rotated:   rotated,
+		RotatedAt: time.Now().Format(time.RFC3339),
+	}, nil
+}
@@ -0,0 +1,46 @@
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/cshum/imagor-studio/server/pkg/encryption"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func TestRotateEncryptionKey(t *testing.T) {
+	db := testutil.NewDB(t)
+
+	enc := encryption.NewServiceWithJwtLey(":memory:", "jwt-secret")
+	registryStore := registrystore.New(db, zap.NewNop(), enc)
+	_, err := registryStore.Set(context.Background(), registrystore.SystemOwnerID, "config.imagor_secret", "s3cret", true)
+	require.NoError(t, err)
+
+	resolver := newTestResolver(nil, registryStore, new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop(), WithEncryptionKeyRotation(db, enc))
+	_, err = resolver.Mutation().RotateEncryptionKey(createReadWriteContext("user-1"))
+	assert.Error(t, err)
+
+	result, err := resolver.Mutation().RotateEncryptionKey(createAdminContext("admin-1"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Rotated)
+	assert.NotEmpty(t, result.RotatedAt)
+
+	entry, err := registryStore.Get(context.Background(), registrystore.SystemOwnerID, "config.imagor_secret")
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", entry.Value)
+}
+
+func TestRotateEncryptionKey_NotAvailable(t *testing.T) {
+	resolver := newTestResolver(nil, new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop())
+
+	_, err := resolver.Mutation().RotateEncryptionKey(createAdminContext("admin-1"))
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_AVAILABLE", gqlErr.Extensions["code"])
+}
@@ -0,0 +1,388 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cshum/imagor-studio/server/internal/faces"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/operation"
+	"github.com/cshum/imagor-studio/server/internal/pagination"
+	"github.com/cshum/imagor-studio/server/pkg/space"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultPeopleLimit and maxPeopleLimit bound the page of people
+	defaultPeopleLimit = 50
+	maxPeopleLimit     = 200
+	// defaultPersonPhotosLimit and maxPersonPhotosLimit bound the page of
+	// files showing a person
+	defaultPersonPhotosLimit = 100
+	maxPersonPhotosLimit     = 1000
+	// maxPersonNameLength bounds person names, in characters
+	maxPersonNameLength = 200
+)
+
+func facesNotAvailableError() error {
+	return &gqlerror.Error{
+		Message:    "face detection is not enabled",
+		Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+	}
+}
+
+func faceError(err error) error {
+	if errors.Is(err, faces.ErrPersonNotFound) {
+		return &gqlerror.Error{
+			Message:    err.Error(),
+			Extensions: map[string]interface{}{"code": "NOT_FOUND"},
+		}
+	}
+	return fmt.Errorf("face operation failed: %w", err)
+}
+
+// pageBounds validates the offset and limit of a page, limit defaulting to
+// defaultLimit and at most maxLimit
+func pageBounds(offset, limit *int, defaultLimit, maxLimit int) (int, int, error) {
+	offsetValue, limitValue := 0, defaultLimit
+	if offset != nil {
+		if *offset < 0 {
+			return 0, 0, &gqlerror.Error{
+				Message:    "offset must not be negative",
+				Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+			}
+		}
+		offsetValue = *offset
+	}
+	if limit != nil {
+		if *limit < 1 || *limit > maxLimit {
+			return 0, 0, &gqlerror.Error{
+				Message:    fmt.Sprintf("limit must be between 1 and %d", maxLimit),
+				Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+			}
+		}
+		limitValue = *limit
+	}
+	return offsetValue, limitValue, nil
+}
+
+// personCursor is the position of a person in the people listing, encoded
+// as the after and endCursor of people
+type personCursor struct {
+	ID        string `json:"i"`
+	Name      string `json:"n,omitempty"`
+	FaceCount int    `json:"c"`
+}
+
+// personPhotoCursor is the position of a file in the personPhotos listing
+type personPhotoCursor struct {
+	Path string `json:"p"`
+}
+
+// faceRoot returns the folder people queries are limited to, path within
+// the home path of the request, after checking read access
+func faceRoot(ctx context.Context, path *string) (string, error) {
+	root := ""
+	if path != nil {
+		root = strings.Trim(*path, "/")
+	}
+	root, err := ScopePath(ctx, root)
+	if err != nil {
+		return "", err
+	}
+	root = strings.Trim(root, "/")
+	if err := RequireReadPermission(ctx, root); err != nil {
+		return "", err
+	}
+	return root, nil
+}
+
+// toGQLPerson converts a person, with the cover thumbnail of its space
+func (r *Resolver) toGQLPerson(ctx context.Context, p *faces.Person, spaceConfig *space.Space) *gql.Person {
+	result := &gql.Person{ID: p.ID, FaceCount: p.FaceCount}
+	if p.Name != "" {
+		name := p.Name
+		result.Name = &name
+	}
+	if p.Cover != nil {
+		coverPath := p.Cover.FilePath
+		result.CoverPath = &coverPath
+		result.CoverFace = &gql.FaceBox{X: p.Cover.Box.X, Y: p.Cover.Box.Y, Width: p.Cover.Box.Width, Height: p.Cover.Box.Height}
+		result.CoverThumbnailUrls = r.generateThumbnailUrlsForResolvedSpace(ctx, coverPath,
+			r.getEffectiveVideoThumbnailPosition(ctx, spaceConfig), thumbnailSpaceKey(spaceConfig), spaceConfig)
+	}
+	return result
+}
+
+// People is the resolver for the people field.
+func (r *queryResolver) People(ctx context.Context, path *string, offset *int, limit *int, spaceID *string, after *string) (*gql.PersonList, error) {
+	root, err := faceRoot(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if r.faces == nil {
+		return nil, facesNotAvailableError()
+	}
+	offsetValue, limitValue, err := pageBounds(offset, limit, defaultPeopleLimit, maxPeopleLimit)
+	if err != nil {
+		return nil, err
+	}
+	spaceConfig, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireAfterWithoutOffset(after, offset); err != nil {
+		return nil, err
+	}
+	var people []faces.Person
+	var total int
+	var endCursor *string
+	if after != nil {
+		var from *faces.Person
+		if *after != "" {
+			var c personCursor
+			if err := pagination.Decode(*after, &c); err != nil || c.ID == "" {
+				return nil, invalidListCursorError()
+			}
+			from = &faces.Person{ID: c.ID, Name: c.Name, FaceCount: c.FaceCount}
+		}
+		var more bool
+		people, total, more, err = r.faces.Store().PeopleAfter(ctx, fileMetadataScope(spaceConfig), root, from, limitValue)
+		if err != nil {
+			return nil, faceError(err)
+		}
+		if more && len(people) > 0 {
+			last := people[len(people)-1]
+			cursor := pagination.Encode(personCursor{ID: last.ID, Name: last.Name, FaceCount: last.FaceCount})
+			endCursor = &cursor
+		}
+	} else {
+		people, total, err = r.faces.Store().People(ctx, fileMetadataScope(spaceConfig), root, offsetValue, limitValue)
+		if err != nil {
+			return nil, faceError(err)
+		}
+	}
+	result := &gql.PersonList{Items: make([]*gql.Person, len(people)), TotalCount: total, EndCursor: endCursor}
+	for i := range people {
+		result.Items[i] = r.toGQLPerson(ctx, &people[i], spaceConfig)
+	}
+	return result, nil
+}
+
+// Person is the resolver for the person field.
+func (r *queryResolver) Person(ctx context.Context, id string, path *string, spaceID *string) (*gql.Person, error) {
+	root, err := faceRoot(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if r.faces == nil {
+		return nil, facesNotAvailableError()
+	}
+	spaceConfig, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	person, err := r.faces.Store().Person(ctx, fileMetadataScope(spaceConfig), id, root)
+	if errors.Is(err, faces.ErrPersonNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, faceError(err)
+	}
+	return r.toGQLPerson(ctx, person, spaceConfig), nil
+}
+
+// PersonPhotos is the resolver for the personPhotos field.
+func (r *queryResolver) PersonPhotos(ctx context.Context, id string, path *string, offset *int, limit *int, spaceID *string, after *string) (*gql.PersonPhotoList, error) {
+	root, err := faceRoot(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if r.faces == nil {
+		return nil, facesNotAvailableError()
+	}
+	offsetValue, limitValue, err := pageBounds(offset, limit, defaultPersonPhotosLimit, maxPersonPhotosLimit)
+	if err != nil {
+		return nil, err
+	}
+	spaceConfig, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireAfterWithoutOffset(after, offset); err != nil {
+		return nil, err
+	}
+	var paths []string
+	var total int
+	var endCursor *string
+	if after != nil {
+		var c personPhotoCursor
+		if *after != "" {
+			if err := pagination.Decode(*after, &c); err != nil || c.Path == "" {
+				return nil, invalidListCursorError()
+			}
+		}
+		var more bool
+		paths, total, more, err = r.faces.Store().PersonFilesAfter(ctx, fileMetadataScope(spaceConfig), id, root, c.Path, limitValue)
+		if err != nil {
+			return nil, faceError(err)
+		}
+		if more && len(paths) > 0 {
+			cursor := pagination.Encode(personPhotoCursor{Path: paths[len(paths)-1]})
+			endCursor = &cursor
+		}
+	} else {
+		paths, total, err = r.faces.Store().PersonFiles(ctx, fileMetadataScope(spaceConfig), id, root, offsetValue, limitValue)
+		if err != nil {
+			return nil, faceError(err)
+		}
+	}
+	videoThumbnailPos := r.getEffectiveVideoThumbnailPosition(ctx, spaceConfig)
+	result := &gql.PersonPhotoList{Items: make([]*gql.PersonPhoto, len(paths)), TotalCount: total, EndCursor: endCursor}
+	for i, p := range paths {
+		result.Items[i] = &gql.PersonPhoto{
+			Path:          p,
+			ThumbnailUrls: r.generateThumbnailUrlsForResolvedSpace(ctx, p, videoThumbnailPos, thumbnailSpaceKey(spaceConfig), spaceConfig),
+		}
+	}
+	return result, nil
+}
+
+// ScanFaces is the resolver for the scanFaces field.
+func (r *mutationResolver) ScanFaces(ctx context.Context, path *string, spaceID *string) (*gql.Operation, error) {
+	root := ""
+	if path != nil {
+		root = strings.Trim(*path, "/")
+	}
+	root, err := ScopePath(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+	if err := RequireWritePermission(ctx, root); err != nil {
+		return nil, err
+	}
+	if r.faces == nil || r.imagorProvider == nil {
+		return nil, facesNotAvailableError()
+	}
+	stor, sp, err := r.resolveUploadStorageTarget(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	scope := fileMetadataScope(sp)
+	ownerID, _ := GetUserIDFromContext(ctx)
+	op := r.operations.Start(ctx, faces.OperationKind, ownerID, func(ctx context.Context, progress *operation.Progress) error {
+		return r.faces.Scan(ctx, stor, scope, root, r.faceRenderer(sp), progress)
+	})
+	return toGQLOperation(op), nil
+}
+
+// NamePerson is the resolver for the namePerson field.
+func (r *mutationResolver) NamePerson(ctx context.Context, id string, name string, spaceID *string) (*gql.Person, error) {
+	root, err := ScopePath(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	root = strings.Trim(root, "/")
+	if err := RequireWritePermission(ctx, root); err != nil {
+		return nil, err
+	}
+	if r.faces == nil {
+		return nil, facesNotAvailableError()
+	}
+	name = strings.TrimSpace(name)
+	if utf8.RuneCountInString(name) > maxPersonNameLength {
+		return nil, &gqlerror.Error{
+			Message:    fmt.Sprintf("name must be at most %d characters", maxPersonNameLength),
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	spaceConfig, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	scope := fileMetadataScope(spaceConfig)
+	store := r.faces.Store()
+	// Only people with faces the request can see can be named
+	if _, err := store.Person(ctx, scope, id, root); err != nil {
+		return nil, faceError(err)
+	}
+	if err := store.NamePerson(ctx, scope, id, name); err != nil {
+		return nil, faceError(err)
+	}
+	person, err := store.Person(ctx, scope, id, root)
+	if err != nil {
+		return nil, faceError(err)
+	}
+	return r.toGQLPerson(ctx, person, spaceConfig), nil
+}
+
+// MergePeople is the resolver for the mergePeople field.
+func (r *mutationResolver) MergePeople(ctx context.Context, sourceID string, targetID string, spaceID *string) (*gql.Person, error) {
+	root, err := ScopePath(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	root = strings.Trim(root, "/")
+	if err := RequireWritePermission(ctx, root); err != nil {
+		return nil, err
+	}
+	if r.faces == nil {
+		return nil, facesNotAvailableError()
+	}
+	if sourceID == targetID {
+		return nil, &gqlerror.Error{
+			Message:    "cannot merge a person into itself",
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	spaceConfig, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	scope := fileMetadataScope(spaceConfig)
+	store := r.faces.Store()
+	for _, id := range []string{sourceID, targetID} {
+		if _, err := store.Person(ctx, scope, id, root); err != nil {
+			return nil, faceError(err)
+		}
+	}
+	if err := store.MergePeople(ctx, scope, sourceID, targetID); err != nil {
+		return nil, faceError(err)
+	}
+	person, err := store.Person(ctx, scope, targetID, root)
+	if err != nil {
+		return nil, faceError(err)
+	}
+	return r.toGQLPerson(ctx, person, spaceConfig), nil
+}
+
+// faceRenderer renders images of a space for face detection through
+// imagor
+func (r *Resolver) faceRenderer(sp *space.Space) faces.Renderer {
+	return func(ctx context.Context, imagePath string) ([]byte, error) {
+		imageURL, err := r.generateImagorURLForSpaceConfig(imagePath, faces.RenderParams(), sp)
+		if err != nil {
+			return nil, err
+		}
+		return r.fetchImagorURL(ctx, imageURL)
+	}
+}
+
+// removeFaces drops recorded faces of a deleted or moved file or folder.
+// Failures are logged, the next scan drops them as well.
+func (r *Resolver) removeFaces(ctx context.Context, spaceID *string, path string) {
+	if r.faces == nil {
+		return
+	}
+	spaceConfig, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err == nil {
+		err = r.faces.Store().RemoveFilePath(ctx, fileMetadataScope(spaceConfig), strings.Trim(path, "/"))
+	}
+	if err != nil {
+		r.logger.Warn("Failed to remove faces", zap.String("path", path), zap.Error(err))
+	}
+}
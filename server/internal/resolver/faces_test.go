@@ -0,0 +1,139 @@
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/faces"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func newFacesTestResolver(t *testing.T) (*Resolver, faces.Store) {
+	t.Helper()
+	db := testutil.NewDB(t)
+
+	mockImagorProvider := new(MockImagorProvider)
+	mockImagorProvider.On("Imagor").Return((*imagor.Imagor)(nil))
+	mockImagorProvider.On("GenerateURL", mock.Anything, mock.Anything).Return("/imagor/thumbnail.webp", nil)
+	mockRegistryStore := new(MockRegistryStore)
+	mockRegistryStore.On("GetMulti", mock.Anything, "system:global", mock.Anything).Return([]*registrystore.Registry{}, nil)
+	store := faces.NewStore(db, zap.NewNop())
+	return newTestResolver(nil, mockRegistryStore, new(MockUserStore), mockImagorProvider, &config.Config{}, nil, zap.NewNop(),
+		WithFaceScanner(faces.NewScanner(store, nil, 0, zap.NewNop()))), store
+}
+
+// testFace returns a face whose embedding points at person
+func testFace(person int) faces.Face {
+	embedding := make([]float32, 3)
+	embedding[person] = 1
+	return faces.Face{Box: faces.Box{X: 0.1, Y: 0.2, Width: 0.3, Height: 0.3}, Confidence: 0.9, Embedding: embedding}
+}
+
+func TestPeople(t *testing.T) {
+	resolver, store := newFacesTestResolver(t)
+	ctx := context.Background()
+	for p, found := range map[string][]faces.Face{
+		"family/a.jpg": {testFace(0), testFace(1)},
+		"family/b.jpg": {testFace(0)},
+		"work/c.jpg":   {testFace(2)},
+	} {
+		require.NoError(t, store.ReplaceFaces(ctx, registrystore.SystemOwnerID, p, "v1", found, faces.DefaultMatchThreshold))
+	}
+	readCtx := createReadOnlyContext("user-1")
+	writeCtx := createReadWriteContext("user-1")
+
+	people, err := resolver.Query().People(readCtx, nil, nil, nil, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 3, people.TotalCount)
+	require.Len(t, people.Items, 3)
+	first := people.Items[0]
+	assert.Equal(t, 2, first.FaceCount)
+	assert.Nil(t, first.Name)
+	require.NotNil(t, first.CoverPath)
+	assert.Equal(t, 0.1, first.CoverFace.X)
+	assert.NotNil(t, first.CoverThumbnailUrls)
+
+	people, err = resolver.Query().People(readCtx, stringPtr("/work/"), nil, intPtr(10), nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, people.TotalCount)
+	assert.Equal(t, "work/c.jpg", *people.Items[0].CoverPath)
+	work := people.Items[0].ID
+
+	photos, err := resolver.Query().PersonPhotos(readCtx, first.ID, nil, nil, intPtr(1), nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, photos.TotalCount)
+	require.Len(t, photos.Items, 1)
+	assert.Equal(t, "family/a.jpg", photos.Items[0].Path)
+	assert.NotNil(t, photos.Items[0].ThumbnailUrls)
+	assert.Nil(t, photos.EndCursor)
+
+	// Cursor listings continue after the last file and person of a page
+	photos, err = resolver.Query().PersonPhotos(readCtx, first.ID, nil, nil, intPtr(1), nil, stringPtr(""))
+	require.NoError(t, err)
+	require.NotNil(t, photos.EndCursor)
+	photos, err = resolver.Query().PersonPhotos(readCtx, first.ID, nil, nil, intPtr(1), nil, photos.EndCursor)
+	require.NoError(t, err)
+	require.Len(t, photos.Items, 1)
+	assert.NotEqual(t, "family/a.jpg", photos.Items[0].Path)
+	assert.Nil(t, photos.EndCursor)
+	people, err = resolver.Query().People(readCtx, nil, nil, intPtr(2), nil, stringPtr(""))
+	require.NoError(t, err)
+	require.Len(t, people.Items, 2)
+	assert.Equal(t, first.ID, people.Items[0].ID)
+	require.NotNil(t, people.EndCursor)
+	people, err = resolver.Query().People(readCtx, nil, nil, intPtr(2), nil, people.EndCursor)
+	require.NoError(t, err)
+	require.Len(t, people.Items, 1)
+	assert.Nil(t, people.EndCursor)
+
+	// Naming requires write access
+	_, err = resolver.Mutation().NamePerson(readCtx, first.ID, "Alice", nil)
+	assert.Error(t, err)
+	named, err := resolver.Mutation().NamePerson(writeCtx, first.ID, "  Alice ", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", *named.Name)
+	person, err := resolver.Query().Person(readCtx, first.ID, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", *person.Name)
+
+	// A person without faces below the path is not found
+	person, err = resolver.Query().Person(readCtx, work, stringPtr("family"), nil)
+	require.NoError(t, err)
+	assert.Nil(t, person)
+
+	merged, err := resolver.Mutation().MergePeople(writeCtx, work, first.ID, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 3, merged.FaceCount)
+	assert.Equal(t, "Alice", *merged.Name)
+
+	_, err = resolver.Mutation().MergePeople(writeCtx, first.ID, first.ID, nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+	_, err = resolver.Mutation().NamePerson(writeCtx, work, "Bob", nil)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_FOUND", gqlErr.Extensions["code"])
+	_, err = resolver.Query().People(readCtx, nil, nil, intPtr(0), nil, nil)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+}
+
+func TestPeople_NotAvailable(t *testing.T) {
+	resolver := newTestResolver(nil, new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop())
+
+	_, err := resolver.Query().People(createReadOnlyContext("user-1"), nil, nil, nil, nil, nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_AVAILABLE", gqlErr.Extensions["code"])
+	_, err = resolver.Mutation().ScanFaces(createReadWriteContext("user-1"), nil, nil)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_AVAILABLE", gqlErr.Extensions["code"])
+}
@@ -0,0 +1,142 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/pkg/space"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func favoritesNotAvailableError() error {
+	return &gqlerror.Error{
+		Message:    "favorites are not available",
+		Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+	}
+}
+
+// ListFavorites is the resolver for the listFavorites field.
+func (r *queryResolver) ListFavorites(ctx context.Context, spaceID *string) ([]*gql.Favorite, error) {
+	if err := RequireReadPermission(ctx); err != nil {
+		return nil, err
+	}
+	if r.favoriteStore == nil {
+		return nil, favoritesNotAvailableError()
+	}
+	userID, err := GetUserIDFromContext(ctx)
+	if err != nil || IsGuestUser(ctx) {
+		return []*gql.Favorite{}, nil
+	}
+	sp, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	favorites, err := r.favoriteStore.List(ctx, userID, fileMetadataScope(sp))
+	if err != nil {
+		return nil, err
+	}
+	// Favorites outside a home path set after starring are not reachable
+	homePath := GetHomePathFromContext(ctx)
+	result := make([]*gql.Favorite, 0, len(favorites))
+	for _, f := range favorites {
+		if homePath != "" && !isWithinHomePath(homePath, f.Path) {
+			continue
+		}
+		result = append(result, &gql.Favorite{Path: f.Path, CreatedAt: f.CreatedAt.Format(time.RFC3339)})
+	}
+	return result, nil
+}
+
+// SetFavorite is the resolver for the setFavorite field.
+func (r *mutationResolver) SetFavorite(ctx context.Context, path string, favorite bool, spaceID *string) (bool, error) {
+	path, err := ScopePath(ctx, path)
+	if err != nil {
+		return false, err
+	}
+	path = strings.Trim(path, "/")
+	if err := RequireReadPermission(ctx, path); err != nil {
+		return false, err
+	}
+	if r.favoriteStore == nil {
+		return false, favoritesNotAvailableError()
+	}
+	if IsGuestUser(ctx) {
+		return false, fmt.Errorf("guest users cannot star files")
+	}
+	userID, err := GetUserIDFromContext(ctx)
+	if err != nil {
+		return false, err
+	}
+	sp, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err != nil {
+		return false, err
+	}
+	// Unstarring works for paths deleted meanwhile
+	if favorite {
+		stor, err := r.getSpaceStorageByID(ctx, spaceID)
+		if err != nil {
+			return false, err
+		}
+		if _, err := stor.Stat(ctx, path); err != nil {
+			return false, &gqlerror.Error{
+				Message:    fmt.Sprintf("file not found: %s", path),
+				Extensions: map[string]interface{}{"code": "NOT_FOUND"},
+			}
+		}
+	}
+	if err := r.favoriteStore.Set(ctx, userID, fileMetadataScope(sp), path, favorite); err != nil {
+		return false, err
+	}
+	return favorite, nil
+}
+
+// favoritePaths returns which of paths the current user starred. Guests and
+// failures yield none, favorites never fail a listing.
+func (r *Resolver) favoritePaths(ctx context.Context, spaceConfig *space.Space, paths []string) map[string]bool {
+	if r.favoriteStore == nil || len(paths) == 0 || IsGuestUser(ctx) {
+		return nil
+	}
+	userID, err := GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil
+	}
+	starred, err := r.favoriteStore.Contains(ctx, userID, fileMetadataScope(spaceConfig), paths)
+	if err != nil {
+		r.logger.Warn("Failed to get favorites", zap.Error(err))
+		return nil
+	}
+	return starred
+}
+
+// moveFavorites keeps favorites following a moved file or folder. Failures
+// are logged rather than failing the move that already happened.
+func (r *Resolver) moveFavorites(ctx context.Context, spaceID *string, sourcePath, destPath string) {
+	if r.favoriteStore == nil {
+		return
+	}
+	spaceConfig, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err == nil {
+		err = r.favoriteStore.MoveFilePath(ctx, fileMetadataScope(spaceConfig), strings.Trim(sourcePath, "/"), strings.Trim(destPath, "/"))
+	}
+	if err != nil {
+		r.logger.Warn("Failed to move favorites", zap.String("source", sourcePath), zap.String("dest", destPath), zap.Error(err))
+	}
+}
+
+// removeFavorites drops favorites of a deleted file or folder
+func (r *Resolver) removeFavorites(ctx context.Context, spaceID *string, path string) {
+	if r.favoriteStore == nil {
+		return
+	}
+	spaceConfig, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err == nil {
+		err = r.favoriteStore.RemoveFilePath(ctx, fileMetadataScope(spaceConfig), strings.Trim(path, "/"))
+	}
+	if err != nil {
+		r.logger.Warn("Failed to remove favorites", zap.String("path", path), zap.Error(err))
+	}
+}
@@ -0,0 +1,125 @@
+package resolver
+
+import (
+	"testing"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/favoritestore"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func newFavoriteTestResolver(t *testing.T) (*Resolver, string) {
+	t.Helper()
+	db := testutil.NewDB(t)
+
+	baseDir := t.TempDir()
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+	mockImagorProvider := new(MockImagorProvider)
+	mockImagorProvider.On("Imagor").Return((*imagor.Imagor)(nil))
+	mockImagorProvider.On("GenerateURL", mock.Anything, mock.Anything).Return("/imagor/thumbnail.webp", nil)
+	mockRegistryStore := new(MockRegistryStore)
+	mockRegistryStore.On("GetMulti", mock.Anything, "system:global", mock.Anything).Return([]*registrystore.Registry{}, nil)
+	logger := zap.NewNop()
+	return newTestResolver(NewMockStorageProvider(stor), mockRegistryStore, new(MockUserStore), mockImagorProvider, &config.Config{}, nil, logger,
+		WithFavoriteStore(favoritestore.New(db, logger))), baseDir
+}
+
+func TestFavorites_SetAndList(t *testing.T) {
+	resolver, baseDir := newFavoriteTestResolver(t)
+	writeTestFile(t, baseDir, "album/a.jpg")
+	writeTestFile(t, baseDir, "album/b.jpg")
+	ctx := createReadOnlyContext("user-1")
+
+	starred, err := resolver.Mutation().SetFavorite(ctx, "/album/a.jpg", true, nil)
+	require.NoError(t, err)
+	assert.True(t, starred)
+	_, err = resolver.Mutation().SetFavorite(ctx, "album", true, nil)
+	require.NoError(t, err)
+
+	_, err = resolver.Mutation().SetFavorite(ctx, "album/missing.jpg", true, nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_FOUND", gqlErr.Extensions["code"])
+
+	favorites, err := resolver.Query().ListFavorites(ctx, nil)
+	require.NoError(t, err)
+	paths := make([]string, len(favorites))
+	for i, f := range favorites {
+		paths[i] = f.Path
+		assert.NotEmpty(t, f.CreatedAt)
+	}
+	assert.ElementsMatch(t, []string{"album", "album/a.jpg"}, paths)
+
+	// Favorites are per user
+	favorites, err = resolver.Query().ListFavorites(createReadOnlyContext("user-2"), nil)
+	require.NoError(t, err)
+	assert.Empty(t, favorites)
+
+	result, err := resolver.Query().ListFiles(ctx, "album", nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, result.Items, 2)
+	for _, item := range result.Items {
+		assert.Equal(t, item.Path == "album/a.jpg", item.IsFavorite, item.Path)
+	}
+
+	starred, err = resolver.Mutation().SetFavorite(ctx, "album/a.jpg", false, nil)
+	require.NoError(t, err)
+	assert.False(t, starred)
+	favorites, err = resolver.Query().ListFavorites(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, favorites, 1)
+	assert.Equal(t, "album", favorites[0].Path)
+}
+
+func TestFavorites_FollowMoveAndDelete(t *testing.T) {
+	resolver, baseDir := newFavoriteTestResolver(t)
+	writeTestFile(t, baseDir, "album/a.jpg")
+	ctx := createReadWriteContext("user-1")
+
+	_, err := resolver.Mutation().SetFavorite(ctx, "album/a.jpg", true, nil)
+	require.NoError(t, err)
+
+	_, err = resolver.Mutation().MoveFile(ctx, "album", "archive", nil)
+	require.NoError(t, err)
+	favorites, err := resolver.Query().ListFavorites(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, favorites, 1)
+	assert.Equal(t, "archive/a.jpg", favorites[0].Path)
+
+	_, err = resolver.Mutation().DeleteFile(ctx, "archive/a.jpg", nil)
+	require.NoError(t, err)
+	favorites, err = resolver.Query().ListFavorites(ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, favorites)
+}
+
+func TestFavorites_Guest(t *testing.T) {
+	resolver, baseDir := newFavoriteTestResolver(t)
+	writeTestFile(t, baseDir, "album/a.jpg")
+	ctx := createGuestContext("guest-1")
+
+	_, err := resolver.Mutation().SetFavorite(ctx, "album/a.jpg", true, nil)
+	assert.Error(t, err)
+	favorites, err := resolver.Query().ListFavorites(ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, favorites)
+}
+
+func TestFavorites_NotAvailable(t *testing.T) {
+	resolver := newTestResolver(NewMockStorageProvider(new(MockStorage)), new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop())
+	ctx := createReadOnlyContext("user-1")
+
+	_, err := resolver.Query().ListFavorites(ctx, nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_AVAILABLE", gqlErr.Extensions["code"])
+}
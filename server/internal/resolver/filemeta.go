@@ -0,0 +1,277 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/filemeta"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/pkg/space"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxCaptureDateExtractions bounds the uncached files read from imagor
+	// by a single listing sorted by capture date
+	maxCaptureDateExtractions = 100
+	// captureDateExtractionWorkers is the number of concurrent imagor reads
+	captureDateExtractionWorkers = 4
+)
+
+// FileMetadata is the resolver for the fileMetadata field.
+func (r *queryResolver) FileMetadata(ctx context.Context, path string, spaceID *string) (*gql.FileMetadata, error) {
+	path, err := ScopePath(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if err := RequireReadPermission(ctx, path); err != nil {
+		return nil, err
+	}
+	if r.imagorProvider == nil {
+		return nil, &gqlerror.Error{
+			Message:    "image processing is not available",
+			Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+		}
+	}
+	path = strings.Trim(path, "/")
+	spaceConfig, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	var stor storage.Storage
+	if spaceConfig != nil {
+		stor, err = r.storageFromSpaceConfig(spaceConfig)
+	} else {
+		stor, err = r.getSpaceStorageByID(ctx, spaceID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	info, err := stor.Stat(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file stats: %w", err)
+	}
+	if info.IsDir {
+		return nil, &gqlerror.Error{
+			Message:    fmt.Sprintf("%s is a folder", path),
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+
+	scope := fileMetadataScope(spaceConfig)
+	fingerprint := filemeta.Fingerprint(info)
+	if r.fileMetaStore != nil {
+		cached, err := r.fileMetaStore.Get(ctx, scope, path, fingerprint)
+		if err != nil {
+			r.logger.Warn("Failed to read cached file metadata", zap.String("path", path), zap.Error(err))
+		} else if cached != nil {
+			return toGQLFileMetadata(cached), nil
+		}
+	}
+
+	metadata, err := r.extractFileMetadata(ctx, spaceConfig, scope, path, fingerprint)
+	if err != nil {
+		r.logger.Warn("Failed to read file metadata", zap.String("path", path), zap.Error(err))
+		return nil, fmt.Errorf("failed to read metadata of %q: %w", path, err)
+	}
+	return toGQLFileMetadata(metadata), nil
+}
+
+// extractFileMetadata reads the metadata of a file from imagor and caches it
+func (r *Resolver) extractFileMetadata(ctx context.Context, spaceConfig *space.Space, scope, path, fingerprint string) (*filemeta.Metadata, error) {
+	body, err := r.fetchImageMeta(ctx, path, spaceConfig)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := filemeta.Parse(body)
+	if err != nil {
+		return nil, err
+	}
+	if r.fileMetaStore != nil {
+		if err := r.fileMetaStore.Put(ctx, scope, path, fingerprint, metadata); err != nil {
+			r.logger.Warn("Failed to cache file metadata", zap.String("path", path), zap.Error(err))
+		}
+	}
+	return metadata, nil
+}
+
+// sortByCaptureDate orders items, and their tags alongside, by EXIF capture
+// date falling back to the modified time. Folders come first, by name.
+// Returns the capture dates of the files that have one.
+func (r *Resolver) sortByCaptureDate(ctx context.Context, spaceConfig *space.Space, items []storage.FileInfo, tags [][]string, order storage.SortOrder) map[string]string {
+	metadata := r.captureDateMetadata(ctx, spaceConfig, items)
+	type entry struct {
+		item storage.FileInfo
+		tags []string
+		key  time.Time
+	}
+	captureTimes := make(map[string]string)
+	entries := make([]entry, len(items))
+	for i, item := range items {
+		entries[i] = entry{item: item, tags: tags[i], key: item.ModifiedTime}
+		if t, ok := metadata[item.Path].CaptureTimeValue(); ok {
+			entries[i].key = t
+			captureTimes[item.Path] = metadata[item.Path].CaptureTime
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.item.IsDir != b.item.IsDir {
+			return a.item.IsDir
+		}
+		if !a.item.IsDir && !a.key.Equal(b.key) {
+			if order == storage.SortOrderDesc {
+				return a.key.After(b.key)
+			}
+			return a.key.Before(b.key)
+		}
+		return a.item.Name < b.item.Name
+	})
+	for i, e := range entries {
+		items[i] = e.item
+		tags[i] = e.tags
+	}
+	return captureTimes
+}
+
+// captureDateMetadata returns the metadata of the files in items that may
+// carry EXIF: cached entries first, then up to maxCaptureDateExtractions
+// uncached files read from imagor
+func (r *Resolver) captureDateMetadata(ctx context.Context, spaceConfig *space.Space, items []storage.FileInfo) map[string]*filemeta.Metadata {
+	return r.exifMetadata(ctx, spaceConfig, items, maxCaptureDateExtractions)
+}
+
+// exifMetadata returns the metadata of the files in items that may carry
+// EXIF, reading up to maxExtractions uncached files from imagor
+func (r *Resolver) exifMetadata(ctx context.Context, spaceConfig *space.Space, items []storage.FileInfo, maxExtractions int) map[string]*filemeta.Metadata {
+	fingerprints := make(map[string]string)
+	for _, item := range items {
+		if !item.IsDir && filemeta.HasExif(item.Name) {
+			fingerprints[item.Path] = filemeta.Fingerprint(item)
+		}
+	}
+	if len(fingerprints) == 0 {
+		return nil
+	}
+	scope := fileMetadataScope(spaceConfig)
+	metadata := make(map[string]*filemeta.Metadata)
+	if r.fileMetaStore != nil {
+		cached, err := r.fileMetaStore.GetMulti(ctx, scope, fingerprints)
+		if err != nil {
+			r.logger.Warn("Failed to read cached file metadata", zap.Error(err))
+		} else {
+			metadata = cached
+		}
+	}
+	if r.imagorProvider == nil {
+		return metadata
+	}
+
+	var missing []string
+	for p := range fingerprints {
+		if _, ok := metadata[p]; !ok {
+			missing = append(missing, p)
+		}
+	}
+	sort.Strings(missing)
+	if len(missing) > maxExtractions {
+		missing = missing[:maxExtractions]
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	paths := make(chan string)
+	for range min(captureDateExtractionWorkers, len(missing)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range paths {
+				m, err := r.extractFileMetadata(ctx, spaceConfig, scope, p, fingerprints[p])
+				if err != nil {
+					r.logger.Debug("Failed to read file metadata", zap.String("path", p), zap.Error(err))
+					continue
+				}
+				mu.Lock()
+				metadata[p] = m
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, p := range missing {
+		paths <- p
+	}
+	close(paths)
+	wg.Wait()
+	return metadata
+}
+
+// fileMetadataScope namespaces cached metadata per space, falling back to
+// the global scope for the default storage
+func fileMetadataScope(spaceConfig *space.Space) string {
+	if spaceConfig != nil {
+		return "space:" + spaceConfig.ID
+	}
+	return registrystore.SystemOwnerID
+}
+
+// removeFileMetadata drops cached metadata of a deleted or moved file or
+// folder. Failures are logged, stale entries are also caught by fingerprint.
+func (r *Resolver) removeFileMetadata(ctx context.Context, spaceID *string, path string) {
+	if r.fileMetaStore == nil {
+		return
+	}
+	spaceConfig, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err == nil {
+		err = r.fileMetaStore.RemoveFilePath(ctx, fileMetadataScope(spaceConfig), strings.Trim(path, "/"))
+	}
+	if err != nil {
+		r.logger.Warn("Failed to remove cached file metadata", zap.String("path", path), zap.Error(err))
+	}
+}
+
+func toGQLFileMetadata(m *filemeta.Metadata) *gql.FileMetadata {
+	result := &gql.FileMetadata{
+		Format:       optionalString(m.Format),
+		Width:        optionalInt(m.Width),
+		Height:       optionalInt(m.Height),
+		Orientation:  optionalInt(m.Orientation),
+		CaptureTime:  optionalString(m.CaptureTime),
+		CameraMake:   optionalString(m.CameraMake),
+		CameraModel:  optionalString(m.CameraModel),
+		LensModel:    optionalString(m.LensModel),
+		Software:     optionalString(m.Software),
+		Iso:          optionalInt(m.ISO),
+		ExposureTime: optionalString(m.ExposureTime),
+		Latitude:     m.Latitude,
+		Longitude:    m.Longitude,
+		Altitude:     m.Altitude,
+	}
+	if m.Aperture > 0 {
+		result.Aperture = &m.Aperture
+	}
+	if m.FocalLength > 0 {
+		result.FocalLength = &m.FocalLength
+	}
+	return result
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func optionalInt(v int) *int {
+	if v == 0 {
+		return nil
+	}
+	return &v
+}
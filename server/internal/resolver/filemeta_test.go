@@ -0,0 +1,286 @@
+package resolver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/filemeta"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/cshum/imagor/imagorpath"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+// memoryFileMetaStore is an in-memory filemeta.Store
+type memoryFileMetaStore struct {
+	entries map[string]memoryFileMetaEntry
+	// livePhotos maps scope|image paths to their videos
+	livePhotos map[string]string
+}
+
+type memoryFileMetaEntry struct {
+	fingerprint string
+	metadata    *filemeta.Metadata
+	contentType string
+}
+
+func (s *memoryFileMetaStore) Get(_ context.Context, scope, filePath, fingerprint string) (*filemeta.Metadata, error) {
+	entry, ok := s.entries[scope+"|"+filePath]
+	if !ok || entry.fingerprint != fingerprint {
+		return nil, nil
+	}
+	return entry.metadata, nil
+}
+
+func (s *memoryFileMetaStore) PutContentType(_ context.Context, scope, filePath, fingerprint, contentType string) error {
+	entry := s.entries[scope+"|"+filePath]
+	if entry.fingerprint != fingerprint {
+		entry = memoryFileMetaEntry{fingerprint: fingerprint}
+	}
+	entry.contentType = contentType
+	s.entries[scope+"|"+filePath] = entry
+	return nil
+}
+
+func (s *memoryFileMetaStore) ContentType(_ context.Context, scope, filePath, fingerprint string) (string, error) {
+	entry, ok := s.entries[scope+"|"+filePath]
+	if !ok || entry.fingerprint != fingerprint {
+		return "", nil
+	}
+	return entry.contentType, nil
+}
+
+func (s *memoryFileMetaStore) GetMulti(ctx context.Context, scope string, fingerprints map[string]string) (map[string]*filemeta.Metadata, error) {
+	result := make(map[string]*filemeta.Metadata)
+	for p, fingerprint := range fingerprints {
+		if m, _ := s.Get(ctx, scope, p, fingerprint); m != nil {
+			result[p] = m
+		}
+	}
+	return result, nil
+}
+
+func (s *memoryFileMetaStore) Put(_ context.Context, scope, filePath, fingerprint string, metadata *filemeta.Metadata) error {
+	entry := memoryFileMetaEntry{fingerprint: fingerprint, metadata: metadata}
+	if existing := s.entries[scope+"|"+filePath]; existing.fingerprint == fingerprint {
+		entry.contentType = existing.contentType
+	}
+	s.entries[scope+"|"+filePath] = entry
+	return nil
+}
+
+func (s *memoryFileMetaStore) RemoveFilePath(_ context.Context, scope, path string) error {
+	delete(s.entries, scope+"|"+path)
+	return nil
+}
+
+func (s *memoryFileMetaStore) Timeline(context.Context, string, string, filemeta.Granularity) ([]filemeta.Bucket, error) {
+	return nil, nil
+}
+
+func (s *memoryFileMetaStore) TimelineEntries(context.Context, string, string, int, int) ([]filemeta.Entry, error) {
+	return nil, nil
+}
+
+func (s *memoryFileMetaStore) Locations(context.Context, string, string, filemeta.Bounds) ([]filemeta.Location, error) {
+	return nil, nil
+}
+
+func (s *memoryFileMetaStore) LinkLivePhotos(_ context.Context, scope, folder string, pairs map[string]string) error {
+	if s.livePhotos == nil {
+		s.livePhotos = map[string]string{}
+	}
+	for key := range s.livePhotos {
+		if image := strings.TrimPrefix(key, scope+"|"); image != key && path.Dir(image) == folder {
+			delete(s.livePhotos, key)
+		}
+	}
+	for image, video := range pairs {
+		s.livePhotos[scope+"|"+image] = video
+	}
+	return nil
+}
+
+func (s *memoryFileMetaStore) LivePhotos(_ context.Context, scope string, paths []string) (map[string]string, error) {
+	wanted := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		wanted[p] = true
+	}
+	result := make(map[string]string)
+	for key, video := range s.livePhotos {
+		if image := strings.TrimPrefix(key, scope+"|"); image != key && (wanted[image] || wanted[video]) {
+			result[image] = video
+		}
+	}
+	return result, nil
+}
+
+func TestFileMetadata(t *testing.T) {
+	var requests atomic.Int32
+	metaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"format":"jpeg","width":4000,"height":3000,"orientation":1,
+			"exif":{"Model":"X100V","DateTimeOriginal":"2024:05:01 12:34:56","ISOSpeedRatings":"160","FNumber":"2/1","ExposureTime":"1/500",
+			"GPSLatitude":"10/1 0/1 0/1","GPSLatitudeRef":"S"}}`))
+	}))
+	defer metaServer.Close()
+
+	baseDir := t.TempDir()
+	writeTestFile(t, baseDir, "photos/a.jpg")
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+
+	mockImagorProvider := new(MockImagorProvider)
+	mockImagorProvider.On("Imagor").Return((*imagor.Imagor)(nil))
+	mockImagorProvider.On("GenerateURL", "photos/a.jpg", imagorpath.Params{Meta: true}).Return(metaServer.URL, nil)
+	store := &memoryFileMetaStore{entries: map[string]memoryFileMetaEntry{}}
+	resolver := newTestResolver(NewMockStorageProvider(stor), new(MockRegistryStore), new(MockUserStore),
+		mockImagorProvider, &config.Config{}, nil, zap.NewNop(), WithFileMetaStore(store))
+	ctx := createReadOnlyContext("user-1")
+
+	result, err := resolver.Query().FileMetadata(ctx, "/photos/a.jpg", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "X100V", *result.CameraModel)
+	assert.Equal(t, "2024-05-01T12:34:56", *result.CaptureTime)
+	assert.Equal(t, 160, *result.Iso)
+	assert.Equal(t, 2.0, *result.Aperture)
+	assert.Equal(t, "1/500", *result.ExposureTime)
+	assert.Equal(t, -10.0, *result.Latitude)
+	assert.Nil(t, result.Longitude)
+	assert.Nil(t, result.FocalLength)
+	assert.Nil(t, result.LensModel)
+
+	// Served from the cache
+	result, err = resolver.Query().FileMetadata(ctx, "photos/a.jpg", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "X100V", *result.CameraModel)
+	assert.Equal(t, int32(1), requests.Load())
+
+	// Deleting the file drops the cached entry
+	_, err = resolver.Mutation().DeleteFile(createReadWriteContext("user-1"), "photos/a.jpg", nil)
+	require.NoError(t, err)
+	assert.Empty(t, store.entries)
+}
+
+func TestFileMetadata_InvalidInput(t *testing.T) {
+	baseDir := t.TempDir()
+	writeTestFile(t, baseDir, "photos/a.jpg")
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+	ctx := createReadOnlyContext("user-1")
+
+	// Without imagor there is nothing to read metadata with
+	resolver := newTestResolver(NewMockStorageProvider(stor), new(MockRegistryStore), new(MockUserStore),
+		nil, &config.Config{}, nil, zap.NewNop())
+	_, err = resolver.Query().FileMetadata(ctx, "photos/a.jpg", nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_AVAILABLE", gqlErr.Extensions["code"])
+
+	mockImagorProvider := new(MockImagorProvider)
+	mockImagorProvider.On("Imagor").Return((*imagor.Imagor)(nil))
+	resolver = newTestResolver(NewMockStorageProvider(stor), new(MockRegistryStore), new(MockUserStore),
+		mockImagorProvider, &config.Config{}, nil, zap.NewNop())
+	_, err = resolver.Query().FileMetadata(ctx, "photos", nil)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+	_, err = resolver.Query().FileMetadata(ctx, "photos/missing.jpg", nil)
+	assert.Error(t, err)
+}
+
+func TestListFiles_SortByCaptureDate(t *testing.T) {
+	captureDates := map[string]string{
+		"album/new-name.jpg": "2021:01:01 10:00:00",
+		"album/old-name.jpg": "2023:06:01 10:00:00",
+	}
+	metaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"format":"jpeg","exif":{"DateTimeOriginal":"` + captureDates[r.URL.Query().Get("image")] + `"}}`))
+	}))
+	defer metaServer.Close()
+
+	baseDir := t.TempDir()
+	writeTestFile(t, baseDir, "album/new-name.jpg")
+	writeTestFile(t, baseDir, "album/old-name.jpg")
+	writeTestFile(t, baseDir, "album/notes.txt")
+	writeTestFile(t, baseDir, "album/sub/c.jpg")
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+
+	mockImagorProvider := new(MockImagorProvider)
+	mockImagorProvider.On("Imagor").Return((*imagor.Imagor)(nil))
+	for imagePath := range captureDates {
+		mockImagorProvider.On("GenerateURL", imagePath, imagorpath.Params{Meta: true}).Return(metaServer.URL+"?image="+imagePath, nil)
+	}
+	mockImagorProvider.On("GenerateURL", mock.Anything, mock.Anything).Return("/imagor/thumbnail.webp", nil)
+	mockRegistryStore := new(MockRegistryStore)
+	mockRegistryStore.On("GetMulti", mock.Anything, "system:global", mock.Anything).Return([]*registrystore.Registry{}, nil)
+	store := &memoryFileMetaStore{entries: map[string]memoryFileMetaEntry{}}
+	resolver := newTestResolver(NewMockStorageProvider(stor), mockRegistryStore, new(MockUserStore),
+		mockImagorProvider, &config.Config{}, nil, zap.NewNop(), WithFileMetaStore(store))
+	ctx := createReadOnlyContext("user-1")
+
+	sortBy := gql.SortOptionCaptureDate
+	sortOrder := gql.SortOrderDesc
+	result, err := resolver.Query().ListFiles(ctx, "album", nil, nil, nil, nil, nil, nil, nil, &sortBy, &sortOrder, nil, nil, nil, nil, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, result.Items, 4)
+	assert.Equal(t, "sub", result.Items[0].Name, "folders come first")
+	assert.Equal(t, "notes.txt", result.Items[1].Name, "modified today, newer than both capture dates")
+	assert.Equal(t, "old-name.jpg", result.Items[2].Name)
+	assert.Equal(t, "2023-06-01T10:00:00", *result.Items[2].CaptureTime)
+	assert.Equal(t, "new-name.jpg", result.Items[3].Name)
+	assert.Nil(t, result.Items[1].CaptureTime)
+	assert.Len(t, store.entries, 2)
+
+	// Pagination applies after sorting
+	result, err = resolver.Query().ListFiles(ctx, "album", nil, intPtr(2), intPtr(1), nil, nil, nil, nil, &sortBy, &sortOrder, nil, nil, nil, nil, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 4, result.TotalCount)
+	require.Len(t, result.Items, 1)
+	assert.Equal(t, "old-name.jpg", result.Items[0].Name)
+}
+
+func TestListFiles_SystemTagsFromIndexedMetadata(t *testing.T) {
+	baseDir := t.TempDir()
+	writeTestFile(t, baseDir, "shots/capture.png")
+	writeTestFile(t, baseDir, "shots/other.png")
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+	info, err := stor.Stat(context.Background(), "shots/capture.png")
+	require.NoError(t, err)
+
+	mockImagorProvider := new(MockImagorProvider)
+	mockImagorProvider.On("GenerateURL", mock.Anything, mock.Anything).Return("/imagor/thumbnail.webp", nil)
+	mockRegistryStore := new(MockRegistryStore)
+	mockRegistryStore.On("GetMulti", mock.Anything, "system:global", mock.Anything).Return([]*registrystore.Registry{}, nil)
+	store := &memoryFileMetaStore{entries: map[string]memoryFileMetaEntry{}}
+	require.NoError(t, store.Put(context.Background(), fileMetadataScope(nil), "shots/capture.png",
+		filemeta.Fingerprint(info), &filemeta.Metadata{Software: "Snipping Tool"}))
+	resolver := newTestResolver(NewMockStorageProvider(stor), mockRegistryStore, new(MockUserStore),
+		mockImagorProvider, &config.Config{}, nil, zap.NewNop(), WithFileMetaStore(store))
+	ctx := createReadOnlyContext("user-1")
+
+	result, err := resolver.Query().ListFiles(ctx, "shots", nil, nil, nil, nil, nil, nil, nil, nil, nil, []string{"screenshot"}, nil, nil, nil, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, result.Items, 1)
+	assert.Equal(t, "capture.png", result.Items[0].Name)
+	assert.Equal(t, []string{"screenshot"}, result.Items[0].SystemTags)
+
+	stat, err := resolver.Query().StatFile(ctx, "shots/capture.png", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"screenshot"}, stat.SystemTags)
+}
@@ -0,0 +1,133 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cshum/imagor-studio/server/internal/foldercover"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/pkg/space"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+// SetFolderCover is the resolver for the setFolderCover field.
+func (r *mutationResolver) SetFolderCover(ctx context.Context, folderPath string, filePath *string, spaceID *string) (*gql.ThumbnailUrls, error) {
+	folderPath, err := ScopePath(ctx, folderPath)
+	if err != nil {
+		return nil, err
+	}
+	folderPath = strings.Trim(folderPath, "/")
+	if err := RequireWritePermission(ctx, folderPath); err != nil {
+		return nil, err
+	}
+	if r.folderCoverStore == nil {
+		return nil, &gqlerror.Error{
+			Message:    "folder covers are not available",
+			Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+		}
+	}
+	if folderPath == "" {
+		return nil, &gqlerror.Error{
+			Message:    "the root folder has no cover",
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	stor, spaceConfig, err := r.resolveUploadStorageTarget(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	userID, err := GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	scope := fileMetadataScope(spaceConfig)
+	// Clearing works for folders deleted meanwhile
+	if filePath == nil || strings.Trim(*filePath, "/") == "" {
+		if err := r.folderCoverStore.Set(ctx, scope, folderPath, "", userID); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+	coverPath, err := ScopePath(ctx, *filePath)
+	if err != nil {
+		return nil, err
+	}
+	coverPath = strings.Trim(coverPath, "/")
+	if !foldercover.IsBelow(folderPath, coverPath) {
+		return nil, &gqlerror.Error{
+			Message:    fmt.Sprintf("%s is not in folder %s", coverPath, folderPath),
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	folderInfo, err := stor.Stat(ctx, folderPath)
+	if err != nil || !folderInfo.IsDir {
+		return nil, &gqlerror.Error{
+			Message:    fmt.Sprintf("folder not found: %s", folderPath),
+			Extensions: map[string]interface{}{"code": "NOT_FOUND"},
+		}
+	}
+	info, err := stor.Stat(ctx, coverPath)
+	if err != nil {
+		return nil, &gqlerror.Error{
+			Message:    fmt.Sprintf("file not found: %s", coverPath),
+			Extensions: map[string]interface{}{"code": "NOT_FOUND"},
+		}
+	}
+	if info.IsDir {
+		return nil, &gqlerror.Error{
+			Message:    fmt.Sprintf("%s is a folder", coverPath),
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	if err := r.folderCoverStore.Set(ctx, scope, folderPath, coverPath, userID); err != nil {
+		return nil, err
+	}
+	return r.generateThumbnailUrlsForResolvedSpace(ctx, coverPath,
+		r.getEffectiveVideoThumbnailPosition(ctx, spaceConfig), thumbnailSpaceKey(spaceConfig), spaceConfig), nil
+}
+
+// folderCovers returns the covers of the folders among paths. Covers never
+// fail a listing, failures are logged.
+func (r *Resolver) folderCovers(ctx context.Context, spaceConfig *space.Space, folders []string) map[string]string {
+	if r.folderCoverStore == nil || len(folders) == 0 {
+		return nil
+	}
+	covers, err := r.folderCoverStore.GetMulti(ctx, fileMetadataScope(spaceConfig), folders)
+	if err != nil {
+		r.logger.Warn("Failed to get folder covers", zap.Error(err))
+		return nil
+	}
+	return covers
+}
+
+// moveFolderCovers keeps folder covers following a moved file or folder.
+// Failures are logged rather than failing the move that already happened.
+func (r *Resolver) moveFolderCovers(ctx context.Context, spaceID *string, sourcePath, destPath string) {
+	if r.folderCoverStore == nil {
+		return
+	}
+	spaceConfig, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err == nil {
+		err = r.folderCoverStore.MoveFilePath(ctx, fileMetadataScope(spaceConfig), strings.Trim(sourcePath, "/"), strings.Trim(destPath, "/"))
+	}
+	if err != nil {
+		r.logger.Warn("Failed to move folder covers", zap.String("source", sourcePath), zap.String("dest", destPath), zap.Error(err))
+	}
+}
+
+// removeFolderCovers drops covers of a deleted folder and covers showing a
+// deleted file
+func (r *Resolver) removeFolderCovers(ctx context.Context, spaceID *string, path string) {
+	if r.folderCoverStore == nil {
+		return
+	}
+	spaceConfig, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err == nil {
+		err = r.folderCoverStore.RemoveFilePath(ctx, fileMetadataScope(spaceConfig), strings.Trim(path, "/"))
+	}
+	if err != nil {
+		r.logger.Warn("Failed to remove folder covers", zap.String("path", path), zap.Error(err))
+	}
+}
@@ -0,0 +1,120 @@
+package resolver
+
+import (
+	"testing"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/foldercover"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func newFolderCoverTestResolver(t *testing.T) *Resolver {
+	t.Helper()
+	db := testutil.NewDB(t)
+
+	baseDir := t.TempDir()
+	writeTestFile(t, baseDir, "photos/a.jpg")
+	writeTestFile(t, baseDir, "photos/trip/b.jpg")
+	writeTestFile(t, baseDir, "docs/c.jpg")
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+
+	mockImagorProvider := new(MockImagorProvider)
+	mockImagorProvider.On("Imagor").Return((*imagor.Imagor)(nil))
+	mockImagorProvider.On("GenerateURL", mock.Anything, mock.Anything).Return("/imagor/thumbnail.webp", nil)
+	mockRegistryStore := new(MockRegistryStore)
+	mockRegistryStore.On("GetMulti", mock.Anything, "system:global", mock.Anything).Return([]*registrystore.Registry{}, nil)
+	logger := zap.NewNop()
+	return newTestResolver(NewMockStorageProvider(stor), mockRegistryStore, new(MockUserStore), mockImagorProvider, &config.Config{}, nil, logger,
+		WithFolderCoverStore(foldercover.NewStore(db, logger)))
+}
+
+func TestSetFolderCover(t *testing.T) {
+	resolver := newFolderCoverTestResolver(t)
+	ctx := createReadWriteContext("user-1")
+
+	urls, err := resolver.Mutation().SetFolderCover(ctx, "/photos/", stringPtr("photos/trip/b.jpg"), nil)
+	require.NoError(t, err)
+	require.NotNil(t, urls)
+	assert.NotNil(t, urls.Grid)
+
+	listCover := func(name string) *string {
+		result, err := resolver.Query().ListFiles(ctx, "", nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+		require.NoError(t, err)
+		for _, item := range result.Items {
+			if item.Name == name {
+				if item.CoverPath != nil {
+					assert.NotNil(t, item.CoverThumbnailUrls)
+				}
+				return item.CoverPath
+			}
+		}
+		t.Fatalf("%s not listed", name)
+		return nil
+	}
+	assert.Equal(t, "photos/trip/b.jpg", *listCover("photos"))
+	assert.Nil(t, listCover("docs"))
+
+	// Covers follow renamed files
+	_, err = resolver.Mutation().MoveFile(ctx, "photos/trip/b.jpg", "photos/trip/best.jpg", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "photos/trip/best.jpg", *listCover("photos"))
+
+	// and are dropped with deleted files
+	_, err = resolver.Mutation().DeleteFile(ctx, "photos/trip/best.jpg", nil)
+	require.NoError(t, err)
+	assert.Nil(t, listCover("photos"))
+
+	// A null file path clears the cover
+	_, err = resolver.Mutation().SetFolderCover(ctx, "docs", stringPtr("docs/c.jpg"), nil)
+	require.NoError(t, err)
+	assert.NotNil(t, listCover("docs"))
+	urls, err = resolver.Mutation().SetFolderCover(ctx, "docs", nil, nil)
+	require.NoError(t, err)
+	assert.Nil(t, urls)
+	assert.Nil(t, listCover("docs"))
+}
+
+func TestSetFolderCover_InvalidInput(t *testing.T) {
+	resolver := newFolderCoverTestResolver(t)
+	ctx := createReadWriteContext("user-1")
+
+	tests := []struct {
+		name       string
+		folderPath string
+		filePath   string
+		code       string
+	}{
+		{"root folder", "", "photos/a.jpg", "BAD_USER_INPUT"},
+		{"file outside the folder", "docs", "photos/a.jpg", "BAD_USER_INPUT"},
+		{"folder as cover", "photos", "photos/trip", "BAD_USER_INPUT"},
+		{"missing file", "photos", "photos/missing.jpg", "NOT_FOUND"},
+		{"missing folder", "missing", "missing/a.jpg", "NOT_FOUND"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := resolver.Mutation().SetFolderCover(ctx, tt.folderPath, stringPtr(tt.filePath), nil)
+			var gqlErr *gqlerror.Error
+			require.ErrorAs(t, err, &gqlErr)
+			assert.Equal(t, tt.code, gqlErr.Extensions["code"])
+		})
+	}
+
+	// Read-only users cannot choose covers
+	_, err := resolver.Mutation().SetFolderCover(createReadOnlyContext("user-1"), "photos", stringPtr("photos/a.jpg"), nil)
+	assert.Error(t, err)
+
+	resolver = newTestResolver(nil, new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop())
+	_, err = resolver.Mutation().SetFolderCover(ctx, "photos", stringPtr("photos/a.jpg"), nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_AVAILABLE", gqlErr.Extensions["code"])
+}
@@ -0,0 +1,222 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/foldersettings"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/pkg/space"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+// FolderSettings is the resolver for the folderSettings field.
+func (r *queryResolver) FolderSettings(ctx context.Context, path string, spaceID *string) (*gql.FolderSettings, error) {
+	path, err := ScopePath(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if err := RequireReadPermission(ctx, path); err != nil {
+		return nil, err
+	}
+	path = strings.Trim(path, "/")
+	spaceConfig, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	if r.folderSettingsStore == nil {
+		return toGQLFolderSettings(path, foldersettings.Settings{}), nil
+	}
+	settings, err := r.folderSettingsStore.Get(ctx, fileMetadataScope(spaceConfig), path)
+	if err != nil {
+		return nil, err
+	}
+	return toGQLFolderSettings(path, settings), nil
+}
+
+// SetFolderSettings is the resolver for the setFolderSettings field.
+func (r *mutationResolver) SetFolderSettings(ctx context.Context, path string, input gql.FolderSettingsInput, spaceID *string) (*gql.FolderSettings, error) {
+	path, err := ScopePath(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if err := RequireWritePermission(ctx, path); err != nil {
+		return nil, err
+	}
+	if r.folderSettingsStore == nil {
+		return nil, &gqlerror.Error{
+			Message:    "folder settings are not available",
+			Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+		}
+	}
+	path = strings.Trim(path, "/")
+	settings := fromGQLFolderSettingsInput(input)
+	if err := settings.Validate(path); err != nil {
+		return nil, folderSettingsError(err)
+	}
+	stor, spaceConfig, err := r.resolveUploadStorageTarget(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	if path != "" {
+		info, err := stor.Stat(ctx, path)
+		if err != nil || !info.IsDir {
+			return nil, &gqlerror.Error{
+				Message:    fmt.Sprintf("folder not found: %s", path),
+				Extensions: map[string]interface{}{"code": "NOT_FOUND"},
+			}
+		}
+	}
+	userID, err := GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	settings, err = r.folderSettingsStore.Save(ctx, fileMetadataScope(spaceConfig), path, userID, settings)
+	if err != nil {
+		return nil, folderSettingsError(err)
+	}
+	return toGQLFolderSettings(path, settings), nil
+}
+
+func folderSettingsError(err error) error {
+	if errors.Is(err, foldersettings.ErrInvalid) {
+		return &gqlerror.Error{
+			Message:    err.Error(),
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	return err
+}
+
+func fromGQLFolderSettingsInput(input gql.FolderSettingsInput) foldersettings.Settings {
+	settings := foldersettings.Settings{Pinned: input.Pinned}
+	if input.Description != nil {
+		settings.Description = strings.TrimSpace(*input.Description)
+	}
+	if input.SortBy != nil {
+		settings.SortBy = input.SortBy.String()
+	}
+	if input.SortOrder != nil {
+		settings.SortOrder = input.SortOrder.String()
+	}
+	if input.Hidden != nil {
+		settings.Hidden = *input.Hidden
+	}
+	return settings
+}
+
+func toGQLFolderSettings(path string, settings foldersettings.Settings) *gql.FolderSettings {
+	result := &gql.FolderSettings{
+		Path:        path,
+		Description: settings.Description,
+		Pinned:      settings.Pinned,
+		Hidden:      settings.Hidden,
+	}
+	if result.Pinned == nil {
+		result.Pinned = []string{}
+	}
+	if sortBy := gql.SortOption(settings.SortBy); sortBy.IsValid() {
+		result.SortBy = &sortBy
+	}
+	if sortOrder := gql.SortOrder(settings.SortOrder); sortOrder.IsValid() {
+		result.SortOrder = &sortOrder
+	}
+	if !settings.UpdatedAt.IsZero() {
+		updatedBy := settings.UpdatedBy
+		updatedAt := settings.UpdatedAt.Format(time.RFC3339)
+		result.UpdatedBy = &updatedBy
+		result.UpdatedAt = &updatedAt
+	}
+	return result
+}
+
+// pinFirst moves the pinned children to the front in pinned order, keeping
+// the order of the others
+func pinFirst(items []storage.FileInfo, tags [][]string, pinned []string) {
+	rank := make(map[string]int, len(pinned))
+	for i, name := range pinned {
+		rank[name] = i
+	}
+	type entry struct {
+		item storage.FileInfo
+		tags []string
+	}
+	entries := make([]entry, len(items))
+	for i, item := range items {
+		entries[i] = entry{item: item, tags: tags[i]}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		ri, iPinned := rank[entries[i].item.Name]
+		rj, jPinned := rank[entries[j].item.Name]
+		if iPinned && jPinned {
+			return ri < rj
+		}
+		return iPinned && !jPinned
+	})
+	for i, e := range entries {
+		items[i] = e.item
+		tags[i] = e.tags
+	}
+}
+
+// folderSettings returns the settings of a listed folder and its hidden
+// children. Settings never fail a listing, failures are logged.
+func (r *Resolver) folderSettings(ctx context.Context, spaceConfig *space.Space, path string) (foldersettings.Settings, map[string]bool) {
+	if r.folderSettingsStore == nil {
+		return foldersettings.Settings{}, nil
+	}
+	settings, err := r.folderSettingsStore.Get(ctx, fileMetadataScope(spaceConfig), path)
+	if err != nil {
+		r.logger.Warn("Failed to get folder settings", zap.String("path", path), zap.Error(err))
+	}
+	return settings, r.hiddenFolders(ctx, spaceConfig, path)
+}
+
+// hiddenFolders returns the folders in path hidden by their settings,
+// failures are logged
+func (r *Resolver) hiddenFolders(ctx context.Context, spaceConfig *space.Space, path string) map[string]bool {
+	if r.folderSettingsStore == nil {
+		return nil
+	}
+	hidden, err := r.folderSettingsStore.HiddenChildren(ctx, fileMetadataScope(spaceConfig), path)
+	if err != nil {
+		r.logger.Warn("Failed to get hidden folders", zap.String("path", path), zap.Error(err))
+	}
+	return hidden
+}
+
+// moveFolderSettings keeps folder settings following a moved folder.
+// Failures are logged rather than failing the move that already happened.
+func (r *Resolver) moveFolderSettings(ctx context.Context, spaceID *string, sourcePath, destPath string) {
+	if r.folderSettingsStore == nil {
+		return
+	}
+	spaceConfig, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err == nil {
+		err = r.folderSettingsStore.MoveFilePath(ctx, fileMetadataScope(spaceConfig), strings.Trim(sourcePath, "/"), strings.Trim(destPath, "/"))
+	}
+	if err != nil {
+		r.logger.Warn("Failed to move folder settings", zap.String("source", sourcePath), zap.String("dest", destPath), zap.Error(err))
+	}
+}
+
+// removeFolderSettings drops the settings of a deleted folder and unpins
+// a deleted file or folder
+func (r *Resolver) removeFolderSettings(ctx context.Context, spaceID *string, path string) {
+	if r.folderSettingsStore == nil {
+		return
+	}
+	spaceConfig, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err == nil {
+		err = r.folderSettingsStore.RemoveFilePath(ctx, fileMetadataScope(spaceConfig), strings.Trim(path, "/"))
+	}
+	if err != nil {
+		r.logger.Warn("Failed to remove folder settings", zap.String("path", path), zap.Error(err))
+	}
+}
@@ -0,0 +1,145 @@
+package resolver
+
+import (
+	"testing"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/foldersettings"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func newFolderSettingsTestResolver(t *testing.T) *Resolver {
+	t.Helper()
+	db := testutil.NewDB(t)
+
+	baseDir := t.TempDir()
+	writeTestFile(t, baseDir, "photos/a.jpg")
+	writeTestFile(t, baseDir, "photos/b.jpg")
+	writeTestFile(t, baseDir, "photos/c.jpg")
+	writeTestFile(t, baseDir, "photos/trip/d.jpg")
+	writeTestFile(t, baseDir, "photos/private/e.jpg")
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+
+	mockImagorProvider := new(MockImagorProvider)
+	mockImagorProvider.On("Imagor").Return((*imagor.Imagor)(nil))
+	mockImagorProvider.On("GenerateURL", mock.Anything, mock.Anything).Return("/imagor/thumbnail.webp", nil)
+	mockRegistryStore := new(MockRegistryStore)
+	mockRegistryStore.On("GetMulti", mock.Anything, "system:global", mock.Anything).Return([]*registrystore.Registry{}, nil)
+	logger := zap.NewNop()
+	return newTestResolver(NewMockStorageProvider(stor), mockRegistryStore, new(MockUserStore), mockImagorProvider, &config.Config{}, nil, logger,
+		WithFolderSettingsStore(foldersettings.NewStore(db, logger)))
+}
+
+func TestFolderSettings(t *testing.T) {
+	resolver := newFolderSettingsTestResolver(t)
+	ctx := createReadWriteContext("user-1")
+
+	settings, err := resolver.Query().FolderSettings(ctx, "photos", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "photos", settings.Path)
+	assert.Empty(t, settings.Pinned)
+	assert.Nil(t, settings.SortBy)
+	assert.Nil(t, settings.UpdatedAt)
+
+	sortBy, sortOrder := gql.SortOptionName, gql.SortOrderDesc
+	settings, err = resolver.Mutation().SetFolderSettings(ctx, "/photos/", gql.FolderSettingsInput{
+		Description: stringPtr(" Holidays "),
+		SortBy:      &sortBy,
+		SortOrder:   &sortOrder,
+		Pinned:      []string{"b.jpg"},
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Holidays", settings.Description)
+	assert.Equal(t, []string{"b.jpg"}, settings.Pinned)
+	require.NotNil(t, settings.UpdatedBy)
+	assert.Equal(t, "user-1", *settings.UpdatedBy)
+
+	settings, err = resolver.Query().FolderSettings(createReadOnlyContext("user-2"), "photos", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Holidays", settings.Description)
+	require.NotNil(t, settings.SortBy)
+	assert.Equal(t, gql.SortOptionName, *settings.SortBy)
+
+	hidden := true
+	_, err = resolver.Mutation().SetFolderSettings(ctx, "photos/private", gql.FolderSettingsInput{Hidden: &hidden}, nil)
+	require.NoError(t, err)
+
+	listNames := func(onlyFiles, onlyFolders, showHidden bool, sortBy *gql.SortOption) []string {
+		result, err := resolver.Query().ListFiles(ctx, "photos", nil, nil, nil, &onlyFiles, &onlyFolders, nil, &showHidden, sortBy, nil, nil, nil, nil, nil, nil, nil)
+		require.NoError(t, err)
+		var names []string
+		for _, item := range result.Items {
+			names = append(names, item.Name)
+		}
+		return names
+	}
+	// The folder sort applies when the listing sets none, pinned children
+	// come first
+	assert.Equal(t, []string{"b.jpg", "c.jpg", "a.jpg"}, listNames(true, false, false, nil))
+	nameSort := gql.SortOptionName
+	assert.Equal(t, []string{"b.jpg", "c.jpg", "a.jpg"}, listNames(true, false, false, &nameSort), "the folder sort order still applies")
+
+	// Hidden folders are listed with showHidden only
+	assert.Equal(t, []string{"trip"}, listNames(false, true, false, nil))
+	assert.ElementsMatch(t, []string{"trip", "private"}, listNames(false, true, true, nil))
+
+	// Pinned children follow renames
+	_, err = resolver.Mutation().MoveFile(ctx, "photos/b.jpg", "photos/best.jpg", nil)
+	require.NoError(t, err)
+	settings, err = resolver.Query().FolderSettings(ctx, "photos", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"best.jpg"}, settings.Pinned)
+
+	// Saving the defaults removes the settings
+	settings, err = resolver.Mutation().SetFolderSettings(ctx, "photos", gql.FolderSettingsInput{}, nil)
+	require.NoError(t, err)
+	assert.Nil(t, settings.UpdatedAt)
+	assert.Equal(t, []string{"a.jpg", "best.jpg", "c.jpg"}, listNames(true, false, false, nil))
+}
+
+func TestSetFolderSettings_InvalidInput(t *testing.T) {
+	resolver := newFolderSettingsTestResolver(t)
+	ctx := createReadWriteContext("user-1")
+	hidden := true
+
+	tests := []struct {
+		name  string
+		path  string
+		input gql.FolderSettingsInput
+		code  string
+	}{
+		{"hidden root", "", gql.FolderSettingsInput{Hidden: &hidden}, "BAD_USER_INPUT"},
+		{"nested pinned", "photos", gql.FolderSettingsInput{Pinned: []string{"trip/d.jpg"}}, "BAD_USER_INPUT"},
+		{"duplicate pinned", "photos", gql.FolderSettingsInput{Pinned: []string{"a.jpg", "a.jpg"}}, "BAD_USER_INPUT"},
+		{"missing folder", "missing", gql.FolderSettingsInput{Description: stringPtr("missing")}, "NOT_FOUND"},
+		{"file", "photos/a.jpg", gql.FolderSettingsInput{Description: stringPtr("file")}, "NOT_FOUND"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := resolver.Mutation().SetFolderSettings(ctx, tt.path, tt.input, nil)
+			var gqlErr *gqlerror.Error
+			require.ErrorAs(t, err, &gqlErr)
+			assert.Equal(t, tt.code, gqlErr.Extensions["code"])
+		})
+	}
+
+	// Read-only users cannot change settings
+	_, err := resolver.Mutation().SetFolderSettings(createReadOnlyContext("user-1"), "photos", gql.FolderSettingsInput{Description: stringPtr("a")}, nil)
+	assert.Error(t, err)
+
+	resolver = newTestResolver(nil, new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop())
+	_, err = resolver.Mutation().SetFolderSettings(ctx, "photos", gql.FolderSettingsInput{}, nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_AVAILABLE", gqlErr.Extensions["code"])
+}
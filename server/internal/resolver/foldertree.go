@@ -0,0 +1,171 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/pkg/space"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultFolderTreeDepth = 1
+	maxFolderTreeDepth     = 5
+	// maxFolderTreeListings bounds the folders listed for one tree
+	maxFolderTreeListings = 500
+	// folderTreeWorkers bounds the folders listed at a time
+	folderTreeWorkers = 8
+)
+
+// FolderTree is the resolver for the folderTree field.
+func (r *queryResolver) FolderTree(ctx context.Context, path string, depth *int, showHidden *bool, spaceID *string) (*gql.FolderTreeNode, error) {
+	path, err := ScopePath(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if err := RequireReadPermission(ctx, path); err != nil {
+		return nil, err
+	}
+	depthValue := defaultFolderTreeDepth
+	if depth != nil {
+		depthValue = *depth
+	}
+	if depthValue < 0 || depthValue > maxFolderTreeDepth {
+		return nil, &gqlerror.Error{
+			Message:    fmt.Sprintf("depth must be between 0 and %d", maxFolderTreeDepth),
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	spaceConfig, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	var stor storage.Storage
+	if spaceConfig != nil {
+		stor, err = r.storageFromSpaceConfig(spaceConfig)
+	} else {
+		stor, err = r.getSpaceStorageByID(ctx, spaceID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	path = strings.Trim(path, "/")
+
+	r.logger.Debug("Listing folder tree", zap.String("path", path), zap.Int("depth", depthValue))
+
+	root := &gql.FolderTreeNode{Path: path}
+	if path != "" {
+		root.Name = path[strings.LastIndex(path, "/")+1:]
+	}
+	tree := &folderTree{
+		resolver:    r.Resolver,
+		stor:        stor,
+		spaceConfig: spaceConfig,
+		showHidden:  showHidden != nil && *showHidden,
+	}
+	if err := tree.expand(ctx, root, depthValue); err != nil {
+		r.logger.Error("Failed to list folder tree", zap.Error(err))
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+	return root, nil
+}
+
+// folderTree lists a tree level by level, so the listing budget is spent
+// on the folders nearest to the root
+type folderTree struct {
+	resolver    *Resolver
+	stor        storage.Storage
+	spaceConfig *space.Space
+	showHidden  bool
+}
+
+func (t *folderTree) expand(ctx context.Context, root *gql.FolderTreeNode, depth int) error {
+	level := []*gql.FolderTreeNode{root}
+	budget := maxFolderTreeListings
+	for d := 0; d <= depth && len(level) > 0 && budget > 0; d++ {
+		if len(level) > budget {
+			level = level[:budget]
+		}
+		budget -= len(level)
+		if err := t.listLevel(ctx, level, d < depth); err != nil {
+			return err
+		}
+		var next []*gql.FolderTreeNode
+		for _, node := range level {
+			next = append(next, node.Children...)
+		}
+		level = next
+	}
+	return nil
+}
+
+// listLevel lists the folders of a level concurrently, filling in their
+// counts and, with withChildren, their children
+func (t *folderTree) listLevel(ctx context.Context, level []*gql.FolderTreeNode, withChildren bool) error {
+	sem := make(chan struct{}, folderTreeWorkers)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+	for _, node := range level {
+		wg.Add(1)
+		go func(node *gql.FolderTreeNode) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if err := t.list(ctx, node, withChildren); err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+		}(node)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+func (t *folderTree) list(ctx context.Context, node *gql.FolderTreeNode, withChildren bool) error {
+	listing, err := t.resolver.listFolder(ctx, t.stor, t.spaceConfig, node.Path)
+	if err != nil {
+		return err
+	}
+	var hidden map[string]bool
+	if !t.showHidden {
+		hidden = t.resolver.hiddenFolders(ctx, t.spaceConfig, node.Path)
+	}
+	folderCount, fileCount := 0, 0
+	var children []*gql.FolderTreeNode
+	for _, item := range listing.Items {
+		if !t.showHidden && storage.IsHiddenFile(item.Name) {
+			continue
+		}
+		if !item.IsDir {
+			fileCount++
+			continue
+		}
+		if hidden[item.Path] {
+			continue
+		}
+		folderCount++
+		if withChildren {
+			children = append(children, &gql.FolderTreeNode{Name: item.Name, Path: item.Path})
+		}
+	}
+	node.FolderCount = &folderCount
+	node.FileCount = &fileCount
+	if withChildren {
+		sort.Slice(children, func(i, j int) bool { return children[i].Name < children[j].Name })
+		if children == nil {
+			children = []*gql.FolderTreeNode{}
+		}
+		node.Children = children
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package resolver
+
+import (
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func TestFolderTree(t *testing.T) {
+	baseDir := t.TempDir()
+	writeTestFile(t, baseDir, "a.jpg")
+	writeTestFile(t, baseDir, "photos/b.jpg")
+	writeTestFile(t, baseDir, "photos/c.jpg")
+	writeTestFile(t, baseDir, "photos/trip/day1/d.jpg")
+	writeTestFile(t, baseDir, "docs/e.jpg")
+	writeTestFile(t, baseDir, ".hidden/f.jpg")
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+	resolver := newTestResolver(NewMockStorageProvider(stor), new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop())
+	ctx := createReadOnlyContext("user-1")
+
+	names := func(nodes []*gql.FolderTreeNode) []string {
+		result := []string{}
+		for _, node := range nodes {
+			result = append(result, node.Name)
+		}
+		return result
+	}
+
+	root, err := resolver.Query().FolderTree(ctx, "", nil, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "", root.Name)
+	assert.Equal(t, 2, *root.FolderCount)
+	assert.Equal(t, 1, *root.FileCount)
+	require.Equal(t, []string{"docs", "photos"}, names(root.Children))
+	photos := root.Children[1]
+	assert.Equal(t, "photos", photos.Path)
+	assert.Equal(t, 1, *photos.FolderCount)
+	assert.Equal(t, 2, *photos.FileCount)
+	assert.Nil(t, photos.Children, "children below the depth are not listed")
+
+	root, err = resolver.Query().FolderTree(ctx, "/photos/", intPtr(2), nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "photos", root.Name)
+	require.Equal(t, []string{"trip"}, names(root.Children))
+	trip := root.Children[0]
+	assert.Equal(t, "photos/trip", trip.Path)
+	require.Equal(t, []string{"day1"}, names(trip.Children))
+	assert.Equal(t, 1, *trip.Children[0].FileCount)
+	assert.Nil(t, trip.Children[0].Children)
+
+	showHidden := true
+	root, err = resolver.Query().FolderTree(ctx, "", intPtr(0), &showHidden, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 3, *root.FolderCount)
+	assert.Nil(t, root.Children)
+
+	_, err = resolver.Query().FolderTree(ctx, "", intPtr(maxFolderTreeDepth+1), nil, nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+}
+
+func TestFolderTree_HiddenFolders(t *testing.T) {
+	resolver := newFolderSettingsTestResolver(t)
+	ctx := createReadWriteContext("user-1")
+	hidden := true
+	_, err := resolver.Mutation().SetFolderSettings(ctx, "photos/private", gql.FolderSettingsInput{Hidden: &hidden}, nil)
+	require.NoError(t, err)
+
+	root, err := resolver.Query().FolderTree(ctx, "photos", nil, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, *root.FolderCount)
+	require.Len(t, root.Children, 1)
+	assert.Equal(t, "trip", root.Children[0].Name)
+
+	showHidden := true
+	root, err = resolver.Query().FolderTree(ctx, "photos", nil, &showHidden, nil)
+	require.NoError(t, err)
+	assert.Len(t, root.Children, 2)
+}
@@ -0,0 +1,79 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cshum/imagor-studio/server/internal/filemeta"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+// GeoClusters is the resolver for the geoClusters field.
+func (r *queryResolver) GeoClusters(ctx context.Context, bounds gql.GeoBoundsInput, zoom int, path *string, spaceID *string) ([]*gql.GeoCluster, error) {
+	root := ""
+	if path != nil {
+		root = strings.Trim(*path, "/")
+	}
+	root, err := ScopePath(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+	if err := RequireReadPermission(ctx, root); err != nil {
+		return nil, err
+	}
+	if r.fileMetaStore == nil {
+		return nil, &gqlerror.Error{
+			Message:    "the map is not available",
+			Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+		}
+	}
+	area := filemeta.Bounds{North: bounds.North, South: bounds.South, East: bounds.East, West: bounds.West}
+	if err := area.Validate(); err != nil {
+		return nil, &gqlerror.Error{
+			Message:    err.Error(),
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	if zoom < 0 || zoom > filemeta.MaxZoom {
+		return nil, &gqlerror.Error{
+			Message:    fmt.Sprintf("zoom must be between 0 and %d", filemeta.MaxZoom),
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+
+	spaceConfig, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	locations, err := r.fileMetaStore.Locations(ctx, fileMetadataScope(spaceConfig), root, area)
+	if err != nil {
+		r.logger.Error("Failed to get file locations", zap.String("path", root), zap.Error(err))
+		return nil, fmt.Errorf("failed to get file locations: %w", err)
+	}
+	videoThumbnailPos := r.getEffectiveVideoThumbnailPosition(ctx, spaceConfig)
+	var resolvedSpaceKey *string
+	if spaceConfig != nil {
+		resolvedSpaceKey = &spaceConfig.Key
+	}
+	clusters := filemeta.ClusterLocations(locations, zoom)
+	result := make([]*gql.GeoCluster, len(clusters))
+	for i, c := range clusters {
+		result[i] = &gql.GeoCluster{
+			Latitude:  c.Latitude,
+			Longitude: c.Longitude,
+			Count:     c.Count,
+			Bounds: &gql.GeoBounds{
+				North: c.Bounds.North,
+				South: c.Bounds.South,
+				East:  c.Bounds.East,
+				West:  c.Bounds.West,
+			},
+			RepresentativePath: c.Representative,
+			ThumbnailUrls:      r.generateThumbnailUrlsForResolvedSpace(ctx, c.Representative, videoThumbnailPos, resolvedSpaceKey, spaceConfig),
+		}
+	}
+	return result, nil
+}
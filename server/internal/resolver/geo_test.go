@@ -0,0 +1,60 @@
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/filemeta"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+var worldBounds = gql.GeoBoundsInput{North: 90, South: -90, East: 180, West: -180}
+
+func TestGeoClusters(t *testing.T) {
+	resolver, store := newTimelineTestResolver(t)
+	ctx := context.Background()
+	at := func(lat, lon float64) *filemeta.Metadata {
+		return &filemeta.Metadata{Latitude: &lat, Longitude: &lon}
+	}
+	require.NoError(t, store.Put(ctx, registrystore.SystemOwnerID, "hk/a.jpg", "v1", at(22.28, 114.15)))
+	require.NoError(t, store.Put(ctx, registrystore.SystemOwnerID, "hk/b.jpg", "v1", at(22.30, 114.17)))
+	require.NoError(t, store.Put(ctx, registrystore.SystemOwnerID, "london/c.jpg", "v1", at(51.50, -0.12)))
+	readCtx := createReadOnlyContext("user-1")
+
+	clusters, err := resolver.Query().GeoClusters(readCtx, worldBounds, 2, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, clusters, 2)
+	assert.Equal(t, 2, clusters[0].Count)
+	assert.Equal(t, "hk/a.jpg", clusters[0].RepresentativePath)
+	assert.Equal(t, 22.30, clusters[0].Bounds.North)
+	assert.NotNil(t, clusters[0].ThumbnailUrls)
+
+	clusters, err = resolver.Query().GeoClusters(readCtx, worldBounds, 2, stringPtr("london"), nil)
+	require.NoError(t, err)
+	require.Len(t, clusters, 1)
+	assert.Equal(t, "london/c.jpg", clusters[0].RepresentativePath)
+
+	var gqlErr *gqlerror.Error
+	_, err = resolver.Query().GeoClusters(readCtx, gql.GeoBoundsInput{North: -10, South: 10, East: 0, West: 0}, 2, nil, nil)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+	_, err = resolver.Query().GeoClusters(readCtx, worldBounds, 23, nil, nil)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+	_, err = resolver.Query().GeoClusters(context.Background(), worldBounds, 2, nil, nil)
+	assert.Error(t, err)
+}
+
+func TestGeoClusters_NotAvailable(t *testing.T) {
+	resolver := newTestResolver(nil, new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop())
+	_, err := resolver.Query().GeoClusters(createReadOnlyContext("user-1"), worldBounds, 2, nil, nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_AVAILABLE", gqlErr.Extensions["code"])
+}
@@ -0,0 +1,318 @@
+package resolver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/imageedit"
+	"github.com/cshum/imagor-studio/server/pkg/space"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+// ImageEdit is the resolver for the imageEdit field.
+func (r *queryResolver) ImageEdit(ctx context.Context, path string, spaceID *string) (*gql.ImageEdit, error) {
+	path, err := ScopePath(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if err := RequireReadPermission(ctx, path); err != nil {
+		return nil, err
+	}
+	if err := r.requireImageEdits(); err != nil {
+		return nil, err
+	}
+	path = strings.Trim(path, "/")
+	spaceConfig, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	record, err := r.imageEditStore.Get(ctx, fileMetadataScope(spaceConfig), path)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, nil
+	}
+	return r.toGQLImageEdit(ctx, record, spaceConfig)
+}
+
+// SaveImageEdit is the resolver for the saveImageEdit field.
+func (r *mutationResolver) SaveImageEdit(ctx context.Context, path string, spaceID *string, edit gql.ImageEditInput) (*gql.ImageEdit, error) {
+	path, err := ScopePath(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if err := RequireWritePermission(ctx, path); err != nil {
+		return nil, err
+	}
+	if err := r.requireImageEdits(); err != nil {
+		return nil, err
+	}
+	path = strings.Trim(path, "/")
+	descriptor := fromGQLImageEditInput(edit)
+	if err := descriptor.Validate(); err != nil {
+		return nil, imageEditError(err)
+	}
+	stor, spaceConfig, err := r.resolveUploadStorageTarget(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	info, err := stor.Stat(ctx, path)
+	if err != nil {
+		return nil, &gqlerror.Error{
+			Message:    fmt.Sprintf("image %q not found", path),
+			Extensions: map[string]interface{}{"code": "NOT_FOUND"},
+		}
+	}
+	if info.IsDir {
+		return nil, &gqlerror.Error{
+			Message:    fmt.Sprintf("%s is a folder", path),
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	userID, err := GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	record, err := r.imageEditStore.Save(ctx, fileMetadataScope(spaceConfig), path, userID, descriptor)
+	if err != nil {
+		return nil, err
+	}
+	return r.toGQLImageEdit(ctx, record, spaceConfig)
+}
+
+// ResetImageEdit is the resolver for the resetImageEdit field.
+func (r *mutationResolver) ResetImageEdit(ctx context.Context, path string, spaceID *string) (bool, error) {
+	path, err := ScopePath(ctx, path)
+	if err != nil {
+		return false, err
+	}
+	if err := RequireWritePermission(ctx, path); err != nil {
+		return false, err
+	}
+	if err := r.requireImageEdits(); err != nil {
+		return false, err
+	}
+	spaceConfig, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err != nil {
+		return false, err
+	}
+	if err := r.imageEditStore.RemoveFilePath(ctx, fileMetadataScope(spaceConfig), strings.Trim(path, "/")); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ExportEdit is the resolver for the exportEdit field.
+func (r *mutationResolver) ExportEdit(ctx context.Context, path string, format string, destPath *string, spaceID *string) (string, error) {
+	path, err := ScopePath(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	if err := RequireReadPermission(ctx, path); err != nil {
+		return "", err
+	}
+	if err := r.requireImageEdits(); err != nil {
+		return "", err
+	}
+	path = strings.Trim(path, "/")
+	format = imageedit.NormalizeFormat(format)
+	ext, ok := imageedit.Formats[format]
+	if !ok {
+		return "", imageEditError(fmt.Errorf("%w: %s", imageedit.ErrUnsupportedFormat, format))
+	}
+	dest := editedFilePath(path, ext)
+	if destPath != nil && strings.TrimSpace(*destPath) != "" {
+		if dest, err = ScopePath(ctx, *destPath); err != nil {
+			return "", err
+		}
+		dest = strings.Trim(dest, "/")
+	}
+	if err := RequireWritePermission(ctx, dest); err != nil {
+		return "", err
+	}
+	if dest == path {
+		return "", &gqlerror.Error{
+			Message:    "an export cannot replace the original image",
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+
+	stor, spaceConfig, err := r.resolveUploadStorageTarget(ctx, spaceID)
+	if err != nil {
+		return "", err
+	}
+	if err := ensureSpaceUploadAllowed(spaceConfig); err != nil {
+		return "", err
+	}
+	record, err := r.imageEditStore.Get(ctx, fileMetadataScope(spaceConfig), path)
+	if err != nil {
+		return "", err
+	}
+	if record == nil {
+		return "", &gqlerror.Error{
+			Message:    fmt.Sprintf("no edit saved for %q", path),
+			Extensions: map[string]interface{}{"code": "NOT_FOUND"},
+		}
+	}
+	if taken, err := pathTaken(ctx, stor, dest); err != nil {
+		return "", err
+	} else if taken {
+		return "", fileAlreadyExistsError("export edit")
+	}
+
+	params, err := record.Edit.ExportParams(format)
+	if err != nil {
+		return "", imageEditError(err)
+	}
+	exportURL, err := r.generateImagorURLForSpaceConfig(path, params, spaceConfig)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate imagor URL: %w", err)
+	}
+	body, err := r.fetchImagorURL(ctx, exportURL)
+	if err != nil {
+		r.logger.Warn("Failed to render image edit", zap.String("path", path), zap.Error(err))
+		return "", fmt.Errorf("failed to render %q: %w", path, err)
+	}
+	if err := r.enforceHostedStorageQuota(ctx, spaceConfig, int64(len(body))); err != nil {
+		return "", err
+	}
+	if _, err := r.storeUpload(ctx, stor, spaceConfig, dest, uploadCollisionReject, bytes.NewReader(body), int64(len(body))); err != nil {
+		return "", err
+	}
+	r.logger.Debug("Exported image edit", zap.String("path", path), zap.String("dest", dest))
+	return dest, nil
+}
+
+// requireImageEdits fails unless edits can be saved and rendered
+func (r *Resolver) requireImageEdits() error {
+	if r.imageEditStore == nil || r.imagorProvider == nil {
+		return &gqlerror.Error{
+			Message:    "image editing is not available",
+			Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+		}
+	}
+	return nil
+}
+
+// moveImageEdits keeps saved edits following a moved file or folder.
+// Failures are logged rather than failing the move that already happened.
+func (r *Resolver) moveImageEdits(ctx context.Context, spaceID *string, sourcePath, destPath string) {
+	if r.imageEditStore == nil {
+		return
+	}
+	spaceConfig, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err == nil {
+		err = r.imageEditStore.MoveFilePath(ctx, fileMetadataScope(spaceConfig), strings.Trim(sourcePath, "/"), strings.Trim(destPath, "/"))
+	}
+	if err != nil {
+		r.logger.Warn("Failed to move image edits", zap.String("source", sourcePath), zap.String("dest", destPath), zap.Error(err))
+	}
+}
+
+// removeImageEdits drops saved edits of a deleted file or folder
+func (r *Resolver) removeImageEdits(ctx context.Context, spaceID *string, path string) {
+	if r.imageEditStore == nil {
+		return
+	}
+	spaceConfig, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err == nil {
+		err = r.imageEditStore.RemoveFilePath(ctx, fileMetadataScope(spaceConfig), strings.Trim(path, "/"))
+	}
+	if err != nil {
+		r.logger.Warn("Failed to remove image edits", zap.String("path", path), zap.Error(err))
+	}
+}
+
+// editedFilePath names an export next to the original, e.g.
+// photos/a-edited.webp for photos/a.jpg
+func editedFilePath(imagePath, ext string) string {
+	dir, name := path.Split(imagePath)
+	return dir + strings.TrimSuffix(name, path.Ext(name)) + "-edited." + ext
+}
+
+// imageEditError maps edit validation errors to client errors
+func imageEditError(err error) error {
+	for _, target := range []error{
+		imageedit.ErrInvalidCrop, imageedit.ErrInvalidRotate, imageedit.ErrInvalidAdjust, imageedit.ErrTooManyFilters,
+		imageedit.ErrUnknownFilter, imageedit.ErrInvalidArgs, imageedit.ErrUnsupportedFormat,
+	} {
+		if errors.Is(err, target) {
+			return &gqlerror.Error{
+				Message:    err.Error(),
+				Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+			}
+		}
+	}
+	return err
+}
+
+func fromGQLImageEditInput(input gql.ImageEditInput) *imageedit.Edit {
+	edit := &imageedit.Edit{}
+	if c := input.Crop; c != nil {
+		edit.Crop = &imageedit.Crop{Left: c.Left, Top: c.Top, Width: c.Width, Height: c.Height}
+	}
+	if input.Rotate != nil {
+		edit.Rotate = *input.Rotate
+	}
+	if input.HFlip != nil {
+		edit.HFlip = *input.HFlip
+	}
+	if input.VFlip != nil {
+		edit.VFlip = *input.VFlip
+	}
+	if input.Brightness != nil {
+		edit.Brightness = *input.Brightness
+	}
+	if input.Contrast != nil {
+		edit.Contrast = *input.Contrast
+	}
+	if input.Saturation != nil {
+		edit.Saturation = *input.Saturation
+	}
+	for _, f := range input.Filters {
+		filter := imageedit.Filter{Name: f.Name}
+		if f.Args != nil {
+			filter.Args = *f.Args
+		}
+		edit.Filters = append(edit.Filters, filter)
+	}
+	return edit
+}
+
+func (r *Resolver) toGQLImageEdit(ctx context.Context, record *imageedit.Record, spaceConfig *space.Space) (*gql.ImageEdit, error) {
+	edit := record.Edit
+	params := withSessionWatermark(ctx, edit.Params())
+	url, err := r.generateImagorURLForSpaceConfig(record.FilePath, params, spaceConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate imagor URL: %w", err)
+	}
+	url = absolutizeURL(r.processingOriginForResolvedSpace(ctx, spaceConfig), url)
+	result := &gql.ImageEdit{
+		Path:       record.FilePath,
+		Rotate:     edit.Rotate,
+		HFlip:      edit.HFlip,
+		VFlip:      edit.VFlip,
+		Brightness: edit.Brightness,
+		Contrast:   edit.Contrast,
+		Saturation: edit.Saturation,
+		Filters:    make([]*gql.ImageEditFilter, 0, len(edit.Filters)),
+		URL:        r.clientImagorURL(ctx, url, record.FilePath, params),
+		UpdatedBy:  record.UpdatedBy,
+		UpdatedAt:  record.UpdatedAt.Format(time.RFC3339),
+	}
+	if c := edit.Crop; c != nil {
+		result.Crop = &gql.ImageEditCrop{Left: c.Left, Top: c.Top, Width: c.Width, Height: c.Height}
+	}
+	for _, f := range edit.Filters {
+		result.Filters = append(result.Filters, &gql.ImageEditFilter{Name: f.Name, Args: f.Args})
+	}
+	return result, nil
+}
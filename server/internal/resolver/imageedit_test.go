@@ -0,0 +1,179 @@
+package resolver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/imageedit"
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/cshum/imagor/imagorpath"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func newImageEditTestResolver(t *testing.T, imagorProvider ImagorProvider) (*Resolver, string) {
+	t.Helper()
+	db := testutil.NewDB(t)
+
+	baseDir := t.TempDir()
+	writeTestFile(t, baseDir, "photos/a.jpg")
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+	logger := zap.NewNop()
+	return newTestResolver(NewMockStorageProvider(stor), new(MockRegistryStore), new(MockUserStore), imagorProvider, &config.Config{}, nil, logger,
+		WithImageEditStore(imageedit.NewStore(db, logger))), baseDir
+}
+
+func TestImageEdit_SaveAndReset(t *testing.T) {
+	mockImagorProvider := new(MockImagorProvider)
+	mockImagorProvider.On("GenerateURL", "photos/a.jpg", mock.Anything).Return("/imagor/edited.jpg", nil)
+	resolver, _ := newImageEditTestResolver(t, mockImagorProvider)
+	ctx := createReadWriteContext("user-1")
+
+	edit, err := resolver.Query().ImageEdit(ctx, "photos/a.jpg", nil)
+	require.NoError(t, err)
+	assert.Nil(t, edit)
+
+	edit, err = resolver.Mutation().SaveImageEdit(ctx, "/photos/a.jpg", nil, gql.ImageEditInput{
+		Crop:       &gql.ImageEditCropInput{Left: 10, Top: 10, Width: 100, Height: 50},
+		Rotate:     intPtr(90),
+		Brightness: intPtr(20),
+		Filters:    []*gql.ImageEditFilterInput{{Name: "grayscale"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "photos/a.jpg", edit.Path)
+	assert.Equal(t, 90, edit.Rotate)
+	assert.Equal(t, 100, edit.Crop.Width)
+	assert.Equal(t, "/imagor/edited.jpg", edit.URL)
+	assert.Equal(t, "user-1", edit.UpdatedBy)
+	mockImagorProvider.AssertCalled(t, "GenerateURL", "photos/a.jpg", imagorpath.Params{
+		CropLeft: 10, CropTop: 10, CropRight: 110, CropBottom: 60,
+		Filters: imagorpath.Filters{{Name: "rotate", Args: "90"}, {Name: "brightness", Args: "20"}, {Name: "grayscale"}},
+	})
+
+	edit, err = resolver.Query().ImageEdit(createReadOnlyContext("user-2"), "photos/a.jpg", nil)
+	require.NoError(t, err)
+	require.NotNil(t, edit)
+	assert.Equal(t, 20, edit.Brightness)
+	require.Len(t, edit.Filters, 1)
+	assert.Equal(t, "grayscale", edit.Filters[0].Name)
+
+	_, err = resolver.Mutation().ResetImageEdit(createReadOnlyContext("user-2"), "photos/a.jpg", nil)
+	assert.Error(t, err)
+	ok, err := resolver.Mutation().ResetImageEdit(ctx, "photos/a.jpg", nil)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	edit, err = resolver.Query().ImageEdit(ctx, "photos/a.jpg", nil)
+	require.NoError(t, err)
+	assert.Nil(t, edit)
+}
+
+func TestImageEdit_InvalidInput(t *testing.T) {
+	resolver, _ := newImageEditTestResolver(t, new(MockImagorProvider))
+	ctx := createReadWriteContext("user-1")
+
+	for _, input := range []gql.ImageEditInput{
+		{Rotate: intPtr(45)},
+		{Contrast: intPtr(150)},
+		{Crop: &gql.ImageEditCropInput{Width: 0, Height: 10}},
+		{Filters: []*gql.ImageEditFilterInput{{Name: "watermark", Args: stringPtr("logo.png")}}},
+	} {
+		_, err := resolver.Mutation().SaveImageEdit(ctx, "photos/a.jpg", nil, input)
+		var gqlErr *gqlerror.Error
+		require.ErrorAs(t, err, &gqlErr)
+		assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+	}
+
+	_, err := resolver.Mutation().SaveImageEdit(ctx, "photos/missing.jpg", nil, gql.ImageEditInput{})
+	assert.ErrorContains(t, err, "not found")
+	_, err = resolver.Mutation().SaveImageEdit(ctx, "photos", nil, gql.ImageEditInput{})
+	assert.ErrorContains(t, err, "is a folder")
+	_, err = resolver.Mutation().SaveImageEdit(createReadOnlyContext("user-1"), "photos/a.jpg", nil, gql.ImageEditInput{})
+	assert.Error(t, err)
+
+	// Without a store there is nothing to save edits to
+	disabled := newTestResolver(NewMockStorageProvider(new(MockStorage)), new(MockRegistryStore), new(MockUserStore),
+		new(MockImagorProvider), &config.Config{}, nil, zap.NewNop())
+	_, err = disabled.Query().ImageEdit(ctx, "photos/a.jpg", nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_AVAILABLE", gqlErr.Extensions["code"])
+}
+
+func TestExportEdit(t *testing.T) {
+	renderServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("rendered webp"))
+	}))
+	defer renderServer.Close()
+
+	mockImagorProvider := new(MockImagorProvider)
+	mockImagorProvider.On("Imagor").Return((*imagor.Imagor)(nil))
+	mockImagorProvider.On("GenerateURL", "photos/a.jpg", imagorpath.Params{
+		VFlip:   true,
+		Filters: imagorpath.Filters{{Name: "format", Args: "webp"}},
+	}).Return(renderServer.URL, nil)
+	mockImagorProvider.On("GenerateURL", "photos/a.jpg", mock.Anything).Return("/imagor/edited.jpg", nil)
+	resolver, baseDir := newImageEditTestResolver(t, mockImagorProvider)
+	ctx := createReadWriteContext("user-1")
+
+	_, err := resolver.Mutation().ExportEdit(ctx, "photos/a.jpg", "webp", nil, nil)
+	assert.ErrorContains(t, err, "no edit saved")
+
+	_, err = resolver.Mutation().SaveImageEdit(ctx, "photos/a.jpg", nil, gql.ImageEditInput{VFlip: boolPtr(true)})
+	require.NoError(t, err)
+
+	dest, err := resolver.Mutation().ExportEdit(ctx, "photos/a.jpg", "WEBP", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "photos/a-edited.webp", dest)
+	content, err := os.ReadFile(filepath.Join(baseDir, "photos", "a-edited.webp"))
+	require.NoError(t, err)
+	assert.Equal(t, "rendered webp", string(content))
+
+	// An existing file is not overwritten
+	_, err = resolver.Mutation().ExportEdit(ctx, "photos/a.jpg", "webp", nil, nil)
+	assert.ErrorContains(t, err, "file already exists")
+
+	dest, err = resolver.Mutation().ExportEdit(ctx, "photos/a.jpg", "webp", stringPtr("/exports/a.webp"), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "exports/a.webp", dest)
+
+	_, err = resolver.Mutation().ExportEdit(ctx, "photos/a.jpg", "bmp", nil, nil)
+	assert.ErrorContains(t, err, "unsupported export format")
+	_, err = resolver.Mutation().ExportEdit(ctx, "photos/a.jpg", "jpeg", stringPtr("photos/a.jpg"), nil)
+	assert.ErrorContains(t, err, "cannot replace the original")
+	_, err = resolver.Mutation().ExportEdit(createReadOnlyContext("user-1"), "photos/a.jpg", "webp", stringPtr("exports/b.webp"), nil)
+	assert.Error(t, err)
+}
+
+func TestImageEdit_FollowsMoveAndDelete(t *testing.T) {
+	mockImagorProvider := new(MockImagorProvider)
+	mockImagorProvider.On("GenerateURL", mock.Anything, mock.Anything).Return("/imagor/edited.jpg", nil)
+	resolver, _ := newImageEditTestResolver(t, mockImagorProvider)
+	ctx := createReadWriteContext("user-1")
+
+	_, err := resolver.Mutation().SaveImageEdit(ctx, "photos/a.jpg", nil, gql.ImageEditInput{Rotate: intPtr(180)})
+	require.NoError(t, err)
+
+	_, err = resolver.Mutation().MoveFile(ctx, "photos", "trips", nil)
+	require.NoError(t, err)
+	edit, err := resolver.Query().ImageEdit(ctx, "trips/a.jpg", nil)
+	require.NoError(t, err)
+	require.NotNil(t, edit)
+	assert.Equal(t, 180, edit.Rotate)
+
+	_, err = resolver.Mutation().DeleteFile(ctx, "trips/a.jpg", nil)
+	require.NoError(t, err)
+	edit, err = resolver.Query().ImageEdit(ctx, "trips/a.jpg", nil)
+	require.NoError(t, err)
+	assert.Nil(t, edit)
+}
@@ -1,19 +1,28 @@
 package resolver
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/cshum/imagor-studio/server/internal/generated/gql"
 	"github.com/cshum/imagor-studio/server/internal/imagorprovider"
 	"github.com/cshum/imagor-studio/server/internal/imagortemplate"
+	"github.com/cshum/imagor-studio/server/internal/ratelimit"
+	"github.com/cshum/imagor-studio/server/internal/rawpreview"
 	"github.com/cshum/imagor-studio/server/internal/registryutil"
+	"github.com/cshum/imagor-studio/server/internal/thumbnailpreset"
+	"github.com/cshum/imagor-studio/server/pkg/auth"
 	sharedprocessing "github.com/cshum/imagor-studio/server/pkg/processing"
 	"github.com/cshum/imagor-studio/server/pkg/space"
 	"github.com/cshum/imagor/imagorpath"
@@ -22,7 +31,11 @@ import (
 
 // GenerateImagorURL is the resolver for the generateImagorUrl field.
 func (r *mutationResolver) GenerateImagorURL(ctx context.Context, imagePath string, spaceID *string, params gql.ImagorParamsInput) (string, error) {
-	if err := RequireEditPermission(ctx); err != nil {
+	imagePath, err := ScopePath(ctx, imagePath)
+	if err != nil {
+		return "", err
+	}
+	if err := RequireEditPermission(ctx, imagePath); err != nil {
 		return "", err
 	}
 
@@ -30,7 +43,7 @@ func (r *mutationResolver) GenerateImagorURL(ctx context.Context, imagePath stri
 		zap.String("imagePath", imagePath))
 
 	// Convert GraphQL input to imagorpath.Params
-	imagorParams := convertToImagorParams(params)
+	imagorParams := withSessionWatermark(ctx, convertToImagorParams(params))
 	spaceConfig, err := r.getAccessibleSpaceByID(ctx, spaceID)
 	if err != nil {
 		return "", err
@@ -84,11 +97,18 @@ func (r *mutationResolver) GenerateImagorURLFromTemplate(
 	// fetch the target image's actual dimensions via the imagor meta URL, then apply
 	// crop-validation and dimension-mode rules before proceeding.
 	if imagePath != nil && *imagePath != "" {
-		targetDims, err := r.fetchImageDimensions(ctx, *imagePath, spaceConfig)
+		scoped, err := ScopePath(ctx, *imagePath)
+		if err != nil {
+			return "", err
+		}
+		if err := RequireEditPermission(ctx, scoped); err != nil {
+			return "", err
+		}
+		targetDims, err := r.fetchImageDimensions(ctx, scoped, spaceConfig)
 		if err != nil {
-			return "", fmt.Errorf("failed to fetch dimensions for image %q: %w", *imagePath, err)
+			return "", fmt.Errorf("failed to fetch dimensions for image %q: %w", scoped, err)
 		}
-		tmpl = imagortemplate.ApplyTemplateToImage(tmpl, *imagePath, targetDims)
+		tmpl = imagortemplate.ApplyTemplateToImage(tmpl, scoped, targetDims)
 	}
 
 	base := tmpl.Transformations
@@ -103,6 +123,15 @@ func (r *mutationResolver) GenerateImagorURLFromTemplate(
 	origDims := *base.OriginalDimensions
 
 	res := imagortemplate.ResolveContext(base, origDims, baseImagePath, contextPath)
+	// The image of a saved template may be any path, it is scoped as
+	// requested ones are
+	res.ImagePath, err = ScopePath(ctx, res.ImagePath)
+	if err != nil {
+		return "", err
+	}
+	if err := RequireEditPermission(ctx, res.ImagePath); err != nil {
+		return "", err
+	}
 
 	var previewMaxDims *imagortemplate.Dimensions
 	if previewMaxDimensions != nil {
@@ -122,13 +151,14 @@ func (r *mutationResolver) GenerateImagorURLFromTemplate(
 			params.Filters = append(params.Filters, imagorpath.Filter{Name: f.Name, Args: f.Args})
 		}
 	}
+	params = withSessionWatermark(ctx, params)
 
 	url, err := r.generateImagorURLForSpaceConfig(res.ImagePath, params, spaceConfig)
 	if err != nil {
 		return "", fmt.Errorf("failed to generate imagor URL: %w", err)
 	}
 	if preview {
-		url = r.appendInternalTrafficSignature(url, res.ImagePath, params)
+		url = r.clientImagorURL(ctx, url, res.ImagePath, params)
 	}
 	return absolutizeURL(r.processingOriginForResolvedSpace(ctx, spaceConfig), url), nil
 }
@@ -136,54 +166,64 @@ func (r *mutationResolver) GenerateImagorURLFromTemplate(
 // fetchImageDimensions fetches the width and height of an image via the imagor meta URL.
 // It mirrors the frontend's fetchImageDimensions logic: call the imagor meta endpoint
 // and parse the JSON response for width/height.
+func (r *Resolver) fetchImageDimensions(ctx context.Context, imagePath string, spaceConfig *space.Space) (imagortemplate.Dimensions, error) {
+	body, err := r.fetchImageMeta(ctx, imagePath, spaceConfig)
+	if err != nil {
+		return imagortemplate.Dimensions{}, err
+	}
+	var meta struct {
+		Width  int `json:"width"`
+		Height int `json:"height"`
+	}
+	if err := json.Unmarshal(body, &meta); err != nil {
+		return imagortemplate.Dimensions{}, fmt.Errorf("failed to parse meta response: %w", err)
+	}
+	if meta.Width <= 0 || meta.Height <= 0 {
+		return imagortemplate.Dimensions{}, fmt.Errorf("invalid dimensions from meta: %dx%d", meta.Width, meta.Height)
+	}
+	return imagortemplate.Dimensions{Width: meta.Width, Height: meta.Height}, nil
+}
+
+// fetchImageMeta returns the imagor meta JSON of an image.
 // Uses embedded mode (in-process ServeHTTP) when available, otherwise falls back to HTTP GET.
-func (r *mutationResolver) fetchImageDimensions(ctx context.Context, imagePath string, spaceConfig *space.Space) (imagortemplate.Dimensions, error) {
+func (r *Resolver) fetchImageMeta(ctx context.Context, imagePath string, spaceConfig *space.Space) ([]byte, error) {
 	metaURL, err := r.generateImagorURLForSpaceConfig(imagePath, imagorpath.Params{Meta: true}, spaceConfig)
 	if err != nil {
-		return imagortemplate.Dimensions{}, fmt.Errorf("failed to generate meta URL: %w", err)
+		return nil, fmt.Errorf("failed to generate meta URL: %w", err)
 	}
+	return r.fetchImagorURL(ctx, metaURL)
+}
 
-	var body []byte
-
+// fetchImagorURL returns the response body of an imagor URL
+func (r *Resolver) fetchImagorURL(ctx context.Context, imagorURL string) ([]byte, error) {
 	if imagorInstance := r.imagorProvider.Imagor(); imagorInstance != nil {
 		// Embedded: call ServeHTTP in-process (no network overhead).
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, metaURL, nil)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, imagorURL, nil)
 		if err != nil {
-			return imagortemplate.Dimensions{}, fmt.Errorf("failed to create meta request: %w", err)
+			return nil, fmt.Errorf("failed to create imagor request: %w", err)
 		}
 		rec := httptest.NewRecorder()
 		imagorInstance.ServeHTTP(rec, req)
 		if rec.Code != http.StatusOK {
-			return imagortemplate.Dimensions{}, fmt.Errorf("imagor meta returned status %d", rec.Code)
-		}
-		body = rec.Body.Bytes()
-	} else {
-		// Fallback: plain HTTP GET (used in testing or if instance is not yet initialized).
-		resp, err := http.Get(metaURL) //nolint:noctx
-		if err != nil {
-			return imagortemplate.Dimensions{}, fmt.Errorf("failed to fetch meta URL: %w", err)
-		}
-		defer resp.Body.Close()
-		if resp.StatusCode != http.StatusOK {
-			return imagortemplate.Dimensions{}, fmt.Errorf("imagor meta returned status %d", resp.StatusCode)
-		}
-		body, err = io.ReadAll(resp.Body)
-		if err != nil {
-			return imagortemplate.Dimensions{}, fmt.Errorf("failed to read meta response: %w", err)
+			return nil, fmt.Errorf("imagor returned status %d", rec.Code)
 		}
+		return rec.Body.Bytes(), nil
 	}
 
-	var meta struct {
-		Width  int `json:"width"`
-		Height int `json:"height"`
+	// Fallback: plain HTTP GET (used in testing or if instance is not yet initialized).
+	resp, err := http.Get(imagorURL) //nolint:noctx
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch imagor URL: %w", err)
 	}
-	if err := json.Unmarshal(body, &meta); err != nil {
-		return imagortemplate.Dimensions{}, fmt.Errorf("failed to parse meta response: %w", err)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("imagor returned status %d", resp.StatusCode)
 	}
-	if meta.Width <= 0 || meta.Height <= 0 {
-		return imagortemplate.Dimensions{}, fmt.Errorf("invalid dimensions from meta: %dx%d", meta.Width, meta.Height)
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read imagor response: %w", err)
 	}
-	return imagortemplate.Dimensions{Width: meta.Width, Height: meta.Height}, nil
+	return body, nil
 }
 
 // buildImagePath constructs the full image path from gallery and image keys
@@ -209,6 +249,32 @@ func (r *Resolver) appendInternalTrafficSignature(rawURL, imagePath string, para
 	return sharedprocessing.AppendInternalTrafficSignature(rawURL, canonicalPath, r.cloudConfig.InternalAPISecret)
 }
 
+// clientImagorURL returns rawURL as handed to the client of ctx: tagged as
+// internal traffic, and carrying the signed rate limit key of the session so
+// the image is counted against it rather than the address fetching it
+func (r *Resolver) clientImagorURL(ctx context.Context, rawURL, imagePath string, params imagorpath.Params) string {
+	rawURL = r.appendInternalTrafficSignature(rawURL, imagePath, params)
+	if r.rateLimitSecret == "" || rawURL == "" {
+		return rawURL
+	}
+	claims, err := auth.GetClaimsFromContext(ctx)
+	if err != nil {
+		return rawURL
+	}
+	key := ratelimit.SessionKey(claims)
+	if key == "" {
+		return rawURL
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	query := parsed.Query()
+	query.Set(ratelimit.KeyParam, ratelimit.SignKey(key, r.rateLimitSecret))
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
 func canonicalImagorPath(imagePath string, params imagorpath.Params) string {
 	params.Image = imagePath
 	if strings.Contains(imagePath, " ") || strings.ContainsAny(imagePath, "?#&()") {
@@ -222,7 +288,7 @@ func (r *Resolver) processingOriginForSpace(ctx context.Context, spaceKey *strin
 		return ""
 	}
 
-	return r.processingOriginResolver.ResolveProcessingOrigin(ctx, *spaceKey)
+	return r.resolveProcessingOrigin(ctx, *spaceKey)
 }
 
 func (r *Resolver) processingOriginForResolvedSpace(ctx context.Context, spaceConfig *space.Space) string {
@@ -230,7 +296,7 @@ func (r *Resolver) processingOriginForResolvedSpace(ctx context.Context, spaceCo
 		return ""
 	}
 
-	return r.processingOriginResolver.ResolveProcessingOrigin(ctx, spaceConfig.Key)
+	return r.resolveProcessingOrigin(ctx, spaceConfig.Key)
 }
 
 // convertToImagorParams converts GraphQL input to imagorpath.Params
@@ -352,8 +418,8 @@ func (r *Resolver) generateThumbnailUrlsForResolvedSpace(ctx context.Context, im
 		previewUrls := r.generateThumbnailUrlsForResolvedSpace(ctx, previewPath, videoThumbnailPos, spaceKey, spaceConfig)
 
 		// Override 'original' to point to the actual JSON file
-		if previewUrls != nil {
-			jsonURL, _ := r.generateImagorURLForSpaceConfig(imagePath, imagorpath.Params{
+		if previewUrls != nil && CanDownloadOriginals(ctx) {
+			jsonURL, _ := r.signedImagorURL(ctx, imagePath, imagorpath.Params{
 				Filters: imagorpath.Filters{{Name: "raw"}},
 			}, spaceConfig)
 			jsonURL = absolutizeURL(processingOrigin, jsonURL)
@@ -363,85 +429,155 @@ func (r *Resolver) generateThumbnailUrlsForResolvedSpace(ctx context.Context, im
 		return previewUrls
 	}
 
+	// RAW files render from their embedded JPEG preview, keeping the
+	// original and metadata of the RAW file
+	if rawpreview.IsRaw(imagePath) {
+		previewUrls := r.generateThumbnailUrlsForResolvedSpace(ctx, rawpreview.Key(imagePath), videoThumbnailPos, spaceKey, spaceConfig)
+		if previewUrls == nil {
+			return nil
+		}
+		metaParams := imagorpath.Params{Meta: true}
+		metaURL, _ := r.signedImagorURL(ctx, imagePath, metaParams, spaceConfig)
+		metaURL = r.clientImagorURL(ctx, absolutizeURL(processingOrigin, metaURL), imagePath, metaParams)
+		previewUrls.Meta = &metaURL
+		if previewUrls.Original != nil {
+			originalParams := imagorpath.Params{Filters: imagorpath.Filters{{Name: "raw"}}}
+			originalURL, _ := r.signedImagorURL(ctx, imagePath, originalParams, spaceConfig)
+			originalURL = r.clientImagorURL(ctx, absolutizeURL(processingOrigin, originalURL), imagePath, originalParams)
+			previewUrls.Original = &originalURL
+		}
+		return previewUrls
+	}
+
 	// Check if the image is SVG or PDF (case-insensitive)
 	lowerPath := strings.ToLower(imagePath)
 	isSvgOrPdf := strings.HasSuffix(lowerPath, ".svg") || strings.HasSuffix(lowerPath, ".pdf")
 
-	// Helper to build filters with specific quality
-	buildFilters := func(quality string) imagorpath.Filters {
-		filters := imagorpath.Filters{
-			{Name: "quality", Args: quality},
-			{Name: "format", Args: "webp"},
+	// Filters added to every preset
+	var extraFilters imagorpath.Filters
+
+	// Add DPI filter for SVG and PDF files for higher quality rendering
+	if isSvgOrPdf {
+		extraFilters = append(extraFilters, imagorpath.Filter{Name: "dpi", Args: "144"})
+	}
+
+	// Add video thumbnail filter based on position
+	switch videoThumbnailPos {
+	case "seek_1s":
+		extraFilters = append(extraFilters, imagorpath.Filter{Name: "seek", Args: "1s"})
+	case "seek_3s":
+		extraFilters = append(extraFilters, imagorpath.Filter{Name: "seek", Args: "3s"})
+	case "seek_5s":
+		extraFilters = append(extraFilters, imagorpath.Filter{Name: "seek", Args: "5s"})
+	case "seek_10pct":
+		extraFilters = append(extraFilters, imagorpath.Filter{Name: "seek", Args: "0.1"})
+	case "seek_25pct":
+		extraFilters = append(extraFilters, imagorpath.Filter{Name: "seek", Args: "0.25"})
+	}
+
+	if watermark := sessionWatermark(ctx); watermark != nil {
+		extraFilters = append(extraFilters, watermarkFilter(watermark))
+	}
+
+	// generateURL returns the signed URL of imagePath rendered with params
+	generateURL := func(params imagorpath.Params) string {
+		imageURL, _ := r.signedImagorURL(ctx, imagePath, params, spaceConfig)
+		return r.clientImagorURL(ctx, absolutizeURL(processingOrigin, imageURL), imagePath, params)
+	}
+
+	// Thumbnails of each preset, grid, preview and full among them
+	urls := &gql.ThumbnailUrls{}
+	presets := r.thumbnailPresets.Presets()
+	urls.Presets = make([]*gql.ThumbnailPresetURL, len(presets))
+	for i, preset := range presets {
+		presetURL := generateURL(preset.Params(extraFilters...))
+		urls.Presets[i] = &gql.ThumbnailPresetURL{Name: preset.Name, URL: presetURL}
+		switch preset.Name {
+		case thumbnailpreset.Grid:
+			urls.Grid = &presetURL
+		case thumbnailpreset.Preview:
+			urls.Preview = &presetURL
+		case thumbnailpreset.Full:
+			urls.Full = &presetURL
 		}
+	}
+	originalURL := generateURL(imagorpath.Params{Filters: imagorpath.Filters{{Name: "raw"}}})
+	metaURL := generateURL(imagorpath.Params{Meta: true})
+	urls.Original = &originalURL
+	urls.Meta = &metaURL
 
-		// Add DPI filter for SVG and PDF files for higher quality rendering
-		if isSvgOrPdf {
-			filters = append(filters, imagorpath.Filter{Name: "dpi", Args: "144"})
-		}
+	// Shared links without downloads do not expose the original file
+	if !CanDownloadOriginals(ctx) {
+		urls.Original = nil
+	}
+	return urls
+}
 
-		// Add video thumbnail filter based on position
-		switch videoThumbnailPos {
-		case "seek_1s":
-			filters = append(filters, imagorpath.Filter{Name: "seek", Args: "1s"})
-		case "seek_3s":
-			filters = append(filters, imagorpath.Filter{Name: "seek", Args: "3s"})
-		case "seek_5s":
-			filters = append(filters, imagorpath.Filter{Name: "seek", Args: "5s"})
-		case "seek_10pct":
-			filters = append(filters, imagorpath.Filter{Name: "seek", Args: "0.1"})
-		case "seek_25pct":
-			filters = append(filters, imagorpath.Filter{Name: "seek", Args: "0.25"})
-		}
+const (
+	previewSpriteFrames      = 10
+	previewSpriteFrameWidth  = 160
+	previewSpriteFrameHeight = 90
+)
+
+// videoExtensions are the video files the web app plays, matching its
+// VIDEO_EXTENSIONS
+var videoExtensions = map[string]bool{
+	".mp4": true, ".webm": true, ".avi": true, ".mov": true, ".mkv": true, ".m4v": true,
+	".3gp": true, ".flv": true, ".wmv": true, ".mpg": true, ".mpeg": true,
+}
+
+// generatePreviewSpriteURL returns the hover scrubbing sprite of a video,
+// frames seeked through by imagorvideo and tiled on a blank canvas. nil for
+// other files.
+func (r *Resolver) generatePreviewSpriteURL(ctx context.Context, imagePath string, spaceKey *string, spaceConfig *space.Space) *string {
+	if r.imagorProvider == nil || !videoExtensions[strings.ToLower(path.Ext(imagePath))] {
+		return nil
+	}
+	filters := make(imagorpath.Filters, 0, previewSpriteFrames+2)
+	for i := range previewSpriteFrames {
+		frame := inlineImagorPath(imagePath, imagorpath.Params{
+			Width:  previewSpriteFrameWidth,
+			Height: previewSpriteFrameHeight,
+			Filters: imagorpath.Filters{
+				{Name: "seek", Args: strconv.FormatFloat((float64(i)+0.5)/previewSpriteFrames, 'f', 2, 64)},
+			},
+		})
+		filters = append(filters, imagorpath.Filter{
+			Name: "image",
+			Args: fmt.Sprintf("%s,%d,0", frame, i*previewSpriteFrameWidth),
+		})
+	}
+	params := imagorpath.Params{
+		Width:   previewSpriteFrames * previewSpriteFrameWidth,
+		Height:  previewSpriteFrameHeight,
+		Filters: append(filters, imagorpath.Filter{Name: "quality", Args: "80"}, imagorpath.Filter{Name: "format", Args: "webp"}),
+	}
+	url, err := r.generateImagorURLForSpaceConfig(compareCanvasImage, params, spaceConfig)
+	if err != nil {
+		return nil
+	}
+	url = absolutizeURL(r.processingOriginForSpace(ctx, spaceKey), url)
+	url = r.clientImagorURL(ctx, url, compareCanvasImage, params)
+	return &url
+}
 
-		return filters
-	}
-
-	// Generate different sized URLs using the imagor provider
-	gridParams := imagorpath.Params{
-		Width:   300,
-		Height:  225,
-		Filters: buildFilters("80"),
-	}
-	previewParams := imagorpath.Params{
-		Width:   1200,
-		Height:  900,
-		FitIn:   true,
-		Filters: buildFilters("90"),
-	}
-	fullParams := imagorpath.Params{
-		Width:   2400,
-		Height:  1800,
-		FitIn:   true,
-		Filters: buildFilters("95"),
-	}
-	originalParams := imagorpath.Params{Filters: imagorpath.Filters{{Name: "raw"}}}
-	metaParams := imagorpath.Params{Meta: true}
-
-	gridURL, _ := r.generateImagorURLForSpaceConfig(imagePath, gridParams, spaceConfig)
-	previewURL, _ := r.generateImagorURLForSpaceConfig(imagePath, previewParams, spaceConfig)
-	fullURL, _ := r.generateImagorURLForSpaceConfig(imagePath, fullParams, spaceConfig)
-	originalURL, _ := r.generateImagorURLForSpaceConfig(imagePath, originalParams, spaceConfig)
-	metaURL, _ := r.generateImagorURLForSpaceConfig(imagePath, metaParams, spaceConfig)
-
-	gridURL = absolutizeURL(processingOrigin, gridURL)
-	previewURL = absolutizeURL(processingOrigin, previewURL)
-	fullURL = absolutizeURL(processingOrigin, fullURL)
-	originalURL = absolutizeURL(processingOrigin, originalURL)
-	metaURL = absolutizeURL(processingOrigin, metaURL)
-
-	gridURL = r.appendInternalTrafficSignature(gridURL, imagePath, gridParams)
-	previewURL = r.appendInternalTrafficSignature(previewURL, imagePath, previewParams)
-	fullURL = r.appendInternalTrafficSignature(fullURL, imagePath, fullParams)
-	originalURL = r.appendInternalTrafficSignature(originalURL, imagePath, originalParams)
-	metaURL = r.appendInternalTrafficSignature(metaURL, imagePath, metaParams)
-
-	return &gql.ThumbnailUrls{
-		Grid:     &gridURL,
-		Preview:  &previewURL,
-		Full:     &fullURL,
-		Original: &originalURL,
-		Meta:     &metaURL,
+// watermarkFilter returns the imagor filter overlaying watermark
+func watermarkFilter(watermark *auth.Watermark) imagorpath.Filter {
+	x, y := cmp.Or(watermark.X, "center"), cmp.Or(watermark.Y, "center")
+	args := fmt.Sprintf("%s,%s,%s,%d", inlineImagorPath(watermark.Image, imagorpath.Params{}), x, y, watermark.Alpha)
+	if watermark.WRatio > 0 || watermark.HRatio > 0 {
+		args += fmt.Sprintf(",%d,%d", watermark.WRatio, watermark.HRatio)
 	}
+	return imagorpath.Filter{Name: "watermark", Args: args}
+}
+
+// withSessionWatermark appends the watermark of the session to the filters
+// of params
+func withSessionWatermark(ctx context.Context, params imagorpath.Params) imagorpath.Params {
+	if watermark := sessionWatermark(ctx); watermark != nil {
+		params.Filters = append(slices.Clone(params.Filters), watermarkFilter(watermark))
+	}
+	return params
 }
 
 func (r *Resolver) generateImagorURLForSpaceConfig(imagePath string, params imagorpath.Params, spaceConfig *space.Space) (string, error) {
@@ -14,6 +14,8 @@ import (
 	"github.com/cshum/imagor-studio/server/internal/config"
 	"github.com/cshum/imagor-studio/server/internal/generated/gql"
 	"github.com/cshum/imagor-studio/server/internal/imagorprovider"
+	"github.com/cshum/imagor-studio/server/internal/ratelimit"
+	"github.com/cshum/imagor-studio/server/pkg/auth"
 	"github.com/cshum/imagor-studio/server/pkg/management"
 	sharedprocessing "github.com/cshum/imagor-studio/server/pkg/processing"
 	"github.com/cshum/imagor-studio/server/pkg/space"
@@ -93,6 +95,52 @@ func TestGenerateImagorURL(t *testing.T) {
 
 		mockImagorProvider.AssertExpectations(t)
 	})
+
+	t.Run("SessionWatermark", func(t *testing.T) {
+		mockImagorProvider.ExpectedCalls = nil
+		viewerCtx := auth.SetClaimsInContext(context.Background(), &auth.Claims{
+			UserID: "viewer-1", Role: "guest", Scopes: []string{"read", "edit"}, IsEmbedded: true,
+			Watermark: &auth.Watermark{Image: "brand/logo (1).png", X: "-10", Alpha: 40, WRatio: 20},
+		})
+
+		expectedURL := "/imagor/400x0/filters:watermark(...)/root-image.jpg"
+		mockImagorProvider.On("GenerateURL", "root-image.jpg", imagorpath.Params{
+			Width: 400,
+			Filters: imagorpath.Filters{{
+				Name: "watermark",
+				Args: inlineImagorPath("brand/logo (1).png", imagorpath.Params{}) + ",-10,center,40,20,0",
+			}},
+		}).Return(expectedURL, nil)
+
+		url, err := resolver.Mutation().GenerateImagorURL(viewerCtx, "root-image.jpg", nil, gql.ImagorParamsInput{Width: intPtr(400)})
+		require.NoError(t, err)
+		assert.Equal(t, expectedURL, url)
+
+		mockImagorProvider.AssertExpectations(t)
+	})
+
+	t.Run("OutsideHomePath", func(t *testing.T) {
+		mockImagorProvider.ExpectedCalls = nil
+		homeCtx := WithHomePath(createReadWriteContext("test-user"), "users/bob")
+
+		// Paths outside the home path are taken relative to it
+		expectedURL := "/imagor/400x0/users/bob/other/secret.jpg"
+		mockImagorProvider.On("GenerateURL", "users/bob/other/secret.jpg", imagorpath.Params{Width: 400}).Return(expectedURL, nil)
+		url, err := resolver.Mutation().GenerateImagorURL(homeCtx, "other/secret.jpg", nil, gql.ImagorParamsInput{Width: intPtr(400)})
+		require.NoError(t, err)
+		assert.Equal(t, expectedURL, url)
+
+		_, err = resolver.Mutation().GenerateImagorURL(homeCtx, "../other/secret.jpg", nil, gql.ImagorParamsInput{})
+		assert.ErrorContains(t, err, "path access denied")
+
+		sharedCtx := auth.SetClaimsInContext(context.Background(), &auth.Claims{
+			UserID: "guest", Role: "guest", Scopes: []string{"read", "edit"}, PathPrefix: "album",
+		})
+		_, err = resolver.Mutation().GenerateImagorURL(sharedCtx, "other/secret.jpg", nil, gql.ImagorParamsInput{})
+		assert.ErrorContains(t, err, "path access denied")
+
+		mockImagorProvider.AssertExpectations(t)
+	})
 }
 
 func TestBuildImagePath(t *testing.T) {
@@ -770,6 +818,51 @@ func TestGenerateThumbnailUrls_TemplateFile(t *testing.T) {
 	mockImagorProvider.AssertExpectations(t)
 }
 
+func TestGenerateThumbnailUrls_RawFile(t *testing.T) {
+	mockImagorProvider := new(MockImagorProvider)
+	resolver := newTestResolver(NewMockStorageProvider(new(MockStorage)), new(MockRegistryStore), new(MockUserStore),
+		mockImagorProvider, &config.Config{}, nil, zap.NewNop())
+
+	mockImagorProvider.On("GenerateURL", "photos/IMG_1.CR2.imagor.rawpreview", mock.Anything).Return("/imagor/preview/photos/IMG_1.CR2.imagor.rawpreview", nil)
+	mockImagorProvider.On("GenerateURL", "photos/IMG_1.CR2", imagorpath.Params{
+		Filters: imagorpath.Filters{{Name: "raw"}},
+	}).Return("/imagor/filters:raw()/photos/IMG_1.CR2", nil)
+	mockImagorProvider.On("GenerateURL", "photos/IMG_1.CR2", imagorpath.Params{
+		Meta: true,
+	}).Return("/imagor/meta/photos/IMG_1.CR2", nil)
+
+	result := resolver.generateThumbnailUrls("photos/IMG_1.CR2", "first_frame")
+
+	require.NotNil(t, result)
+	assert.Equal(t, "/imagor/preview/photos/IMG_1.CR2.imagor.rawpreview", *result.Grid)
+	assert.Equal(t, "/imagor/preview/photos/IMG_1.CR2.imagor.rawpreview", *result.Full)
+	// The original download and metadata stay on the RAW file
+	assert.Equal(t, "/imagor/filters:raw()/photos/IMG_1.CR2", *result.Original)
+	assert.Equal(t, "/imagor/meta/photos/IMG_1.CR2", *result.Meta)
+}
+
+func TestGenerateThumbnailUrls_RateLimitKey(t *testing.T) {
+	mockImagorProvider := new(MockImagorProvider)
+	mockImagorProvider.On("GenerateURL", "photos/a.jpg", mock.Anything).Return("/imagor/unsafe/photos/a.jpg", nil)
+	resolver := newTestResolver(NewMockStorageProvider(new(MockStorage)), new(MockRegistryStore), new(MockUserStore),
+		mockImagorProvider, &config.Config{}, nil, zap.NewNop(), WithRateLimitKeys("secret"))
+
+	keyOf := func(ctx context.Context) string {
+		result := resolver.generateThumbnailUrlsForResolvedSpace(ctx, "photos/a.jpg", "first_frame", nil, nil)
+		require.NotNil(t, result)
+		parsed, err := url.Parse(*result.Grid)
+		require.NoError(t, err)
+		original, err := url.Parse(*result.Original)
+		require.NoError(t, err)
+		assert.Equal(t, parsed.Query().Get(ratelimit.KeyParam), original.Query().Get(ratelimit.KeyParam))
+		return parsed.Query().Get(ratelimit.KeyParam)
+	}
+	assert.Equal(t, ratelimit.SignKey("user:user-1", "secret"), keyOf(createReadOnlyContext("user-1")))
+	// Admins are not limited by session, their URLs carry no key
+	assert.Empty(t, keyOf(createAdminContext("admin-1")))
+	assert.Empty(t, keyOf(context.Background()))
+}
+
 func TestGenerateThumbnailUrlsForSpace_UsesVerifiedCustomDomain(t *testing.T) {
 	mockImagorProvider := new(MockImagorProvider)
 	mockStorage := new(MockStorage)
@@ -1262,6 +1355,38 @@ func boolPtr(b bool) *bool {
 // TestGenerateImagorURLFromTemplate_ImagePathOverride tests the imagePath override feature.
 // It uses an external-mode mock (GetInstance returns nil) so fetchImageDimensions
 // falls through to the HTTP GET path, which we intercept with a test HTTP server.
+func TestGenerateImagorURLFromTemplate_OutsideAccessRoot(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	mockImagorProvider := new(MockImagorProvider)
+	resolver := newTestResolver(NewMockStorageProvider(new(MockStorage)), new(MockRegistryStore), new(MockUserStore), mockImagorProvider, &config.Config{}, nil, logger)
+	templateJSON := `{
+		"version": "1.0",
+		"dimensionMode": "adaptive",
+		"transformations": {
+			"imagePath": "other/secret.jpg",
+			"originalDimensions": {"width": 800, "height": 600},
+			"width": 800,
+			"height": 600
+		}
+	}`
+
+	sharedCtx := auth.SetClaimsInContext(context.Background(), &auth.Claims{
+		UserID: "guest", Role: "guest", Scopes: []string{"read", "edit"}, PathPrefix: "album",
+	})
+	_, err := resolver.Mutation().GenerateImagorURLFromTemplate(sharedCtx, templateJSON, nil, nil, nil, nil, nil, nil, nil)
+	assert.ErrorContains(t, err, "path access denied")
+	_, err = resolver.Mutation().GenerateImagorURLFromTemplate(sharedCtx, templateJSON, nil, stringPtr("other/b.jpg"), nil, nil, nil, nil, nil)
+	assert.ErrorContains(t, err, "path access denied")
+
+	// The template image is taken relative to the home path
+	mockImagorProvider.On("GenerateURL", "users/bob/other/secret.jpg", mock.Anything).Return("/imagor/users/bob/other/secret.jpg", nil)
+	homeCtx := WithHomePath(createReadWriteContext("test-user"), "users/bob")
+	url, err := resolver.Mutation().GenerateImagorURLFromTemplate(homeCtx, templateJSON, nil, nil, nil, nil, nil, nil, nil)
+	require.NoError(t, err)
+	assert.Contains(t, url, "users/bob/other/secret.jpg")
+	mockImagorProvider.AssertExpectations(t)
+}
+
 func TestGenerateImagorURLFromTemplate_ImagePathOverride(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
 	cfg := &config.Config{}
@@ -1429,3 +1554,29 @@ func TestGenerateImagorURLFromTemplate_NoImagePath(t *testing.T) {
 	assert.Equal(t, "/imagor/original/photo.jpg", result)
 	mockImagorProvider.AssertExpectations(t)
 }
+
+func TestGeneratePreviewSpriteURL(t *testing.T) {
+	mockImagorProvider := new(MockImagorProvider)
+	resolver := newTestResolver(NewMockStorageProvider(new(MockStorage)), new(MockRegistryStore), new(MockUserStore), mockImagorProvider, &config.Config{}, nil, zap.NewNop())
+
+	var sprite imagorpath.Params
+	mockImagorProvider.On("GenerateURL", "color:none", mock.Anything).Run(func(args mock.Arguments) {
+		sprite = args.Get(1).(imagorpath.Params)
+	}).Return("/imagor/sprite", nil)
+
+	url := resolver.generatePreviewSpriteURL(context.Background(), "clips/My Video.MKV", nil, nil)
+	require.NotNil(t, url)
+	assert.Equal(t, "/imagor/sprite", *url)
+	assert.Equal(t, 1600, sprite.Width)
+	assert.Equal(t, 90, sprite.Height)
+	require.Len(t, sprite.Filters, 12)
+	assert.Equal(t, "image", sprite.Filters[0].Name)
+	first := imagorpath.Parse(strings.SplitN(sprite.Filters[0].Args, ",", 2)[0])
+	assert.Equal(t, "clips/My Video.MKV", first.Image)
+	assert.Equal(t, 160, first.Width)
+	assert.Equal(t, imagorpath.Filters{{Name: "seek", Args: "0.05"}}, first.Filters)
+	assert.True(t, strings.HasSuffix(sprite.Filters[9].Args, ",1440,0"))
+	assert.Contains(t, sprite.Filters[9].Args, "seek(0.95)")
+
+	assert.Nil(t, resolver.generatePreviewSpriteURL(context.Background(), "photos/image.jpg", nil, nil))
+}
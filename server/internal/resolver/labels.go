@@ -0,0 +1,72 @@
+package resolver
+
+import (
+	"context"
+	"strings"
+
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/labelhook"
+	"github.com/cshum/imagor-studio/server/pkg/auth"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// IngestLabels is the resolver for the ingestLabels field.
+func (r *mutationResolver) IngestLabels(ctx context.Context, path string, labels []string, spaceID *string) ([]*gql.Tag, error) {
+	claims, err := auth.GetClaimsFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Classification services run unattended, so they get their own
+	// revocable credential rather than a user's login
+	if claims.Kind != auth.APITokenKind {
+		return nil, &gqlerror.Error{
+			Message:    "ingestLabels requires an API token",
+			Extensions: map[string]interface{}{"code": "FORBIDDEN"},
+		}
+	}
+	path, err = ScopePath(ctx, strings.Trim(path, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if err := RequireWritePermission(ctx, path); err != nil {
+		return nil, err
+	}
+	names, err := labelhook.TagNames(labels)
+	if err != nil {
+		return nil, &gqlerror.Error{
+			Message:    err.Error(),
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	scope, err := r.tagScope(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := r.tagStore.FileTags(ctx, scope, path)
+	if err != nil {
+		return nil, tagError(err)
+	}
+	keep := make(map[string]bool, len(names))
+	for _, name := range names {
+		keep[strings.ToLower(name)] = true
+	}
+	var stale []string
+	for _, tag := range current {
+		if labelhook.IsLabelTag(tag.Path) && !keep[strings.ToLower(tag.Path)] {
+			stale = append(stale, tag.Path)
+		}
+	}
+	result := current
+	if len(stale) > 0 {
+		if result, err = r.tagStore.UntagFile(ctx, scope, path, stale); err != nil {
+			return nil, tagError(err)
+		}
+	}
+	if len(names) > 0 {
+		if result, err = r.tagStore.TagFile(ctx, scope, path, names); err != nil {
+			return nil, tagError(err)
+		}
+	}
+	return toGQLTags(result), nil
+}
@@ -0,0 +1,60 @@
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/pkg/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+func createAPITokenContext(userID string, scopes []string) context.Context {
+	ctx := auth.SetClaimsInContext(context.Background(), &auth.Claims{
+		UserID: userID,
+		Role:   "user",
+		Scopes: scopes,
+		Kind:   auth.APITokenKind,
+	})
+	return context.WithValue(ctx, UserIDContextKey, userID)
+}
+
+func TestIngestLabels(t *testing.T) {
+	resolver, _ := newTagTestResolver(t)
+	ctx := createAPITokenContext("tagger", []string{"read", "write"})
+	_, err := resolver.Mutation().TagFile(createReadWriteContext("user-1"), "album/a.jpg", []string{"Trips/Japan"}, nil)
+	require.NoError(t, err)
+
+	tags, err := resolver.Mutation().IngestLabels(ctx, "album/a.jpg", []string{"Dog", "beach", "dog", " "}, nil)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"Trips/Japan", "Labels/Dog", "Labels/beach"}, tagPaths(tags))
+
+	// A new classification replaces the previous labels only
+	tags, err = resolver.Mutation().IngestLabels(ctx, "album/a.jpg", []string{"DOG", "Sunset"}, nil)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"Trips/Japan", "Labels/Dog", "Labels/Sunset"}, tagPaths(tags))
+
+	tags, err = resolver.Mutation().IngestLabels(ctx, "album/a.jpg", []string{}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Trips/Japan"}, tagPaths(tags))
+
+	var gqlErr *gqlerror.Error
+	_, err = resolver.Mutation().IngestLabels(createReadWriteContext("user-1"), "album/a.jpg", []string{"Dog"}, nil)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "FORBIDDEN", gqlErr.Extensions["code"])
+	_, err = resolver.Mutation().IngestLabels(createAPITokenContext("tagger", []string{"read"}), "album/a.jpg", []string{"Dog"}, nil)
+	assert.Error(t, err)
+	_, err = resolver.Mutation().IngestLabels(ctx, "album/a.jpg", make([]string, 51), nil)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+}
+
+func tagPaths(tags []*gql.Tag) []string {
+	paths := make([]string, len(tags))
+	for i, tag := range tags {
+		paths[i] = tag.Path
+	}
+	return paths
+}
@@ -0,0 +1,56 @@
+package resolver
+
+import (
+	"context"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/libraryscan"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// ScanStatus is the resolver for the scanStatus field.
+func (r *queryResolver) ScanStatus(ctx context.Context) (*gql.ScanStatus, error) {
+	if err := RequireAdminPermission(ctx); err != nil {
+		return nil, err
+	}
+	if r.libraryScan == nil {
+		return nil, libraryScanNotAvailableError()
+	}
+	result := &gql.ScanStatus{ScheduleValid: true}
+	if r.scanSchedule != nil {
+		if status, ok := r.scanSchedule.Status(libraryscan.OperationKind); ok {
+			result.ScheduleValid = status.Valid
+			if status.Expression != "" {
+				result.Schedule = &status.Expression
+			}
+			if !status.NextRun.IsZero() {
+				next := status.NextRun.Format(time.RFC3339)
+				result.NextRunAt = &next
+			}
+		}
+	}
+	if op, ok := r.libraryScan.LastRun(); ok {
+		result.LastRun = toGQLOperation(op)
+	}
+	return result, nil
+}
+
+// TriggerScan is the resolver for the triggerScan field.
+func (r *mutationResolver) TriggerScan(ctx context.Context) (*gql.Operation, error) {
+	if err := RequireAdminPermission(ctx); err != nil {
+		return nil, err
+	}
+	if r.libraryScan == nil {
+		return nil, libraryScanNotAvailableError()
+	}
+	userID, _ := GetUserIDFromContext(ctx)
+	return toGQLOperation(r.libraryScan.Start(ctx, userID)), nil
+}
+
+func libraryScanNotAvailableError() error {
+	return &gqlerror.Error{
+		Message:    "library scans are not available",
+		Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+	}
+}
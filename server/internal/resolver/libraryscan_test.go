@@ -0,0 +1,74 @@
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/libraryscan"
+	"github.com/cshum/imagor-studio/server/internal/operation"
+	"github.com/cshum/imagor-studio/server/internal/scheduler"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+// scheduleConfig sets the library scan schedule as the flag would
+type scheduleConfig string
+
+func (c scheduleConfig) GetByRegistryKey(key string) (string, bool) {
+	return string(c), key == libraryscan.ScheduleKey
+}
+
+func (c scheduleConfig) IsEmbeddedMode() bool {
+	return false
+}
+
+func TestLibraryScan(t *testing.T) {
+	stor, err := filestorage.New(t.TempDir())
+	require.NoError(t, err)
+	operations := operation.NewManager(zap.NewNop())
+	job := libraryscan.NewJob(libraryscan.NewScanner(), func() storage.Storage { return stor }, operations, zap.NewNop())
+	schedule := scheduler.New(nil, scheduleConfig("0 3 * * *"), zap.NewNop())
+	schedule.Add(libraryscan.OperationKind, libraryscan.ScheduleKey, job.Sync)
+	require.NoError(t, schedule.Sync())
+	resolver := newTestResolver(nil, new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop(),
+		WithOperationManager(operations), WithLibraryScan(job, schedule))
+
+	_, err = resolver.Query().ScanStatus(createReadWriteContext("user-1"))
+	assert.Error(t, err)
+	_, err = resolver.Mutation().TriggerScan(createReadWriteContext("user-1"))
+	assert.Error(t, err)
+
+	status, err := resolver.Query().ScanStatus(createAdminContext("admin-1"))
+	require.NoError(t, err)
+	require.NotNil(t, status.Schedule)
+	assert.Equal(t, "0 3 * * *", *status.Schedule)
+	assert.True(t, status.ScheduleValid)
+	assert.NotNil(t, status.NextRunAt)
+	assert.Nil(t, status.LastRun)
+
+	op, err := resolver.Mutation().TriggerScan(createAdminContext("admin-1"))
+	require.NoError(t, err)
+	assert.Equal(t, libraryscan.OperationKind, op.Kind)
+	_, err = operations.Wait(context.Background(), op.ID)
+	require.NoError(t, err)
+
+	status, err = resolver.Query().ScanStatus(createAdminContext("admin-1"))
+	require.NoError(t, err)
+	require.NotNil(t, status.LastRun)
+	assert.Equal(t, op.ID, status.LastRun.ID)
+	assert.Equal(t, gql.OperationStatusSucceeded, status.LastRun.Status)
+}
+
+func TestLibraryScan_NotAvailable(t *testing.T) {
+	resolver := newTestResolver(nil, new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop())
+	_, err := resolver.Mutation().TriggerScan(createAdminContext("admin-1"))
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_AVAILABLE", gqlErr.Extensions["code"])
+}
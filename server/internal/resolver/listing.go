@@ -0,0 +1,138 @@
+package resolver
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cshum/imagor-studio/server/internal/listcache"
+	"github.com/cshum/imagor-studio/server/pkg/space"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+// RefreshFolder is the resolver for the refreshFolder field.
+func (r *mutationResolver) RefreshFolder(ctx context.Context, path string, spaceID *string) (string, error) {
+	path, err := ScopePath(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	if err := RequireReadPermission(ctx, path); err != nil {
+		return "", err
+	}
+	spaceConfig, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err != nil {
+		return "", err
+	}
+	var stor storage.Storage
+	if spaceConfig != nil {
+		stor, err = r.storageFromSpaceConfig(spaceConfig)
+	} else {
+		stor, err = r.getSpaceStorageByID(ctx, spaceID)
+	}
+	if err != nil {
+		return "", err
+	}
+	path = strings.Trim(path, "/")
+
+	r.logger.Debug("Refreshing folder", zap.String("path", path))
+
+	if r.listCache != nil {
+		r.listCache.Invalidate(ctx, fileMetadataScope(spaceConfig), path)
+	}
+	listing, err := r.listFolder(ctx, stor, spaceConfig, path)
+	if err != nil {
+		r.logger.Error("Failed to refresh folder", zap.Error(err))
+		return "", fmt.Errorf("failed to list files: %w", err)
+	}
+	return listing.ETag, nil
+}
+
+// listFolder returns the full listing of a folder, from the listing cache
+// when enabled
+func (r *Resolver) listFolder(ctx context.Context, stor storage.Storage, spaceConfig *space.Space, path string) (*listcache.Listing, error) {
+	fetch := func() ([]storage.FileInfo, error) {
+		result, err := stor.List(ctx, path, storage.ListOptions{ShowHidden: true})
+		return result.Items, err
+	}
+	if r.listCache == nil {
+		items, err := fetch()
+		if err != nil {
+			return nil, err
+		}
+		return &listcache.Listing{Items: items, ETag: listcache.ETag(items)}, nil
+	}
+	return r.listCache.Load(ctx, fileMetadataScope(spaceConfig), path, fetch)
+}
+
+// invalidateListing drops the cached listings a change to path affects
+func (r *Resolver) invalidateListing(spaceConfig *space.Space, path string) {
+	if r.listCache != nil && path != "" {
+		r.listCache.Invalidate(context.Background(), fileMetadataScope(spaceConfig), path)
+	}
+}
+
+// listCursor is the position of a cursor listing, encoded as the after and
+// endCursor of listFiles
+type listCursor struct {
+	Path string `json:"p"`
+	// Cursor continues a listing paged by the storage
+	Cursor string `json:"c,omitempty"`
+	// Offset counts the items listed before the position
+	Offset int `json:"o,omitempty"`
+}
+
+func (c listCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeListCursor decodes the after argument of a listing of path, an empty
+// after starts from the beginning
+func decodeListCursor(after, path string) (listCursor, error) {
+	if after == "" {
+		return listCursor{Path: path}, nil
+	}
+	var c listCursor
+	data, err := base64.RawURLEncoding.DecodeString(after)
+	if err == nil {
+		err = json.Unmarshal(data, &c)
+	}
+	if err != nil || c.Path != path || c.Offset < 0 {
+		return listCursor{}, invalidListCursorError()
+	}
+	return c, nil
+}
+
+func invalidListCursorError() error {
+	return &gqlerror.Error{
+		Message:    "invalid after cursor, list again from the beginning",
+		Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+	}
+}
+
+// listPage lists the page after cursor with the storage's own paging, so
+// the items before it are not listed again. ok is false when the storage
+// cannot page the listing and it has to be listed with an offset.
+func listPage(ctx context.Context, stor storage.Storage, path string, options storage.ListOptions, cursor listCursor) (storage.ListResult, listCursor, bool, error) {
+	pager, ok := stor.(storage.Pager)
+	if !ok || (cursor.Cursor == "" && cursor.Offset > 0) {
+		return storage.ListResult{}, listCursor{}, false, nil
+	}
+	page, err := pager.ListPage(ctx, path, options, cursor.Cursor)
+	if errors.Is(err, storage.ErrPagingUnsupported) {
+		return storage.ListResult{}, listCursor{}, false, nil
+	}
+	if errors.Is(err, storage.ErrInvalidCursor) {
+		return storage.ListResult{}, listCursor{}, false, invalidListCursorError()
+	}
+	if err != nil {
+		return storage.ListResult{}, listCursor{}, false, err
+	}
+	next := listCursor{Path: cursor.Path, Cursor: page.Cursor, Offset: cursor.Offset + len(page.Items)}
+	return storage.ListResult{Items: page.Items, TotalCount: next.Offset}, next, true, nil
+}
@@ -0,0 +1,259 @@
+package resolver
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/listcache"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func TestListFiles_ListCache(t *testing.T) {
+	baseDir := t.TempDir()
+	writeTestFile(t, baseDir, "album/a.jpg")
+	writeTestFile(t, baseDir, "album/b.jpg")
+	writeTestFile(t, baseDir, "album/notes.txt")
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+
+	mockImagorProvider := new(MockImagorProvider)
+	mockImagorProvider.On("GenerateURL", mock.Anything, mock.Anything).Return("/imagor/thumbnail.webp", nil)
+	mockRegistryStore := new(MockRegistryStore)
+	mockRegistryStore.On("GetMulti", mock.Anything, "system:global", mock.Anything).Return([]*registrystore.Registry{}, nil)
+	resolver := newTestResolver(NewMockStorageProvider(stor), mockRegistryStore, new(MockUserStore),
+		mockImagorProvider, &config.Config{}, nil, zap.NewNop(), WithListCache(listcache.New()))
+	ctx := createReadWriteContext("user-1")
+
+	list := func(offset, limit int) *gql.FileList {
+		t.Helper()
+		sortBy := gql.SortOptionName
+		extensions := ".jpg,.png"
+		result, err := resolver.Query().ListFiles(ctx, "album", nil, &offset, &limit, nil, nil, &extensions, nil, &sortBy, nil, nil, nil, nil, nil, nil, nil)
+		require.NoError(t, err)
+		return result
+	}
+	names := func(result *gql.FileList) []string {
+		var names []string
+		for _, item := range result.Items {
+			names = append(names, item.Name)
+		}
+		return names
+	}
+
+	first := list(0, 1)
+	assert.Equal(t, []string{"a.jpg"}, names(first))
+	assert.Equal(t, 2, first.TotalCount)
+	require.NotNil(t, first.Etag)
+	second := list(1, 1)
+	assert.Equal(t, []string{"b.jpg"}, names(second))
+	assert.Equal(t, *first.Etag, *second.Etag)
+
+	// Changes made directly in the storage are seen after a refresh
+	writeTestFile(t, baseDir, "album/c.jpg")
+	assert.Equal(t, 2, list(0, 10).TotalCount)
+	etag, err := resolver.Mutation().RefreshFolder(ctx, "/album/", nil)
+	require.NoError(t, err)
+	assert.NotEqual(t, *first.Etag, etag)
+	refreshed := list(0, 10)
+	assert.Equal(t, []string{"a.jpg", "b.jpg", "c.jpg"}, names(refreshed))
+	assert.Equal(t, etag, *refreshed.Etag)
+
+	// Changes made through the server are seen right away
+	_, err = resolver.Mutation().DeleteFile(ctx, "album/a.jpg", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b.jpg", "c.jpg"}, names(list(0, 10)))
+	_, err = resolver.Mutation().MoveFile(ctx, "album/b.jpg", "b.jpg", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c.jpg"}, names(list(0, 10)))
+}
+
+func TestRefreshFolder_WithoutCache(t *testing.T) {
+	baseDir := t.TempDir()
+	writeTestFile(t, baseDir, "album/a.jpg")
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+	resolver := newTestResolver(NewMockStorageProvider(stor), new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop())
+	ctx := createReadOnlyContext("user-1")
+
+	etag, err := resolver.Mutation().RefreshFolder(ctx, "album", nil)
+	require.NoError(t, err)
+	assert.NotEmpty(t, etag)
+	again, err := resolver.Mutation().RefreshFolder(ctx, "album", nil)
+	require.NoError(t, err)
+	assert.Equal(t, etag, again)
+
+	_, err = resolver.Mutation().RefreshFolder(ctx, "missing", nil)
+	assert.Error(t, err)
+}
+
+// pagedStorage pages the listing of the wrapped storage with index cursors
+type pagedStorage struct {
+	storage.Storage
+	calls int
+}
+
+func (s *pagedStorage) ListPage(ctx context.Context, key string, options storage.ListOptions, cursor string) (storage.ListPage, error) {
+	s.calls++
+	start := 0
+	if cursor != "" {
+		var err error
+		if start, err = strconv.Atoi(cursor); err != nil {
+			return storage.ListPage{}, storage.ErrInvalidCursor
+		}
+	}
+	options.Offset = start
+	result, err := s.List(ctx, key, options)
+	if err != nil {
+		return storage.ListPage{}, err
+	}
+	page := storage.ListPage{Items: result.Items}
+	if end := start + len(result.Items); end < result.TotalCount {
+		page.Cursor = strconv.Itoa(end)
+	}
+	return page, nil
+}
+
+func TestListFiles_After(t *testing.T) {
+	baseDir := t.TempDir()
+	for _, name := range []string{"a.jpg", "b.jpg", "c.jpg", "d.jpg", "e.jpg"} {
+		writeTestFile(t, baseDir, "album/"+name)
+	}
+	fileStorage, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+
+	mockImagorProvider := new(MockImagorProvider)
+	mockImagorProvider.On("GenerateURL", mock.Anything, mock.Anything).Return("/imagor/thumbnail.webp", nil)
+	mockRegistryStore := new(MockRegistryStore)
+	mockRegistryStore.On("GetMulti", mock.Anything, "system:global", mock.Anything).Return([]*registrystore.Registry{}, nil)
+	ctx := createReadWriteContext("user-1")
+
+	listAll := func(stor storage.Storage, sortBy *gql.SortOption) ([]string, []int) {
+		t.Helper()
+		resolver := newTestResolver(NewMockStorageProvider(stor), mockRegistryStore, new(MockUserStore),
+			mockImagorProvider, &config.Config{}, nil, zap.NewNop())
+		var names []string
+		var totals []int
+		limit, after := 2, ""
+		for {
+			result, err := resolver.Query().ListFiles(ctx, "album", nil, nil, &limit, nil, nil, nil, nil, sortBy, nil, nil, nil, nil, nil, &after, nil)
+			require.NoError(t, err)
+			for _, item := range result.Items {
+				names = append(names, item.Name)
+			}
+			totals = append(totals, result.TotalCount)
+			if result.EndCursor == nil {
+				return names, totals
+			}
+			after = *result.EndCursor
+		}
+	}
+	all := []string{"a.jpg", "b.jpg", "c.jpg", "d.jpg", "e.jpg"}
+
+	// Paged by the storage, totalCount counts the items listed so far
+	paged := &pagedStorage{Storage: fileStorage}
+	names, totals := listAll(paged, nil)
+	assert.Equal(t, all, names)
+	assert.Equal(t, []int{2, 4, 5}, totals)
+	assert.Equal(t, 3, paged.calls)
+
+	// Sorted listings are paged with an offset
+	paged.calls = 0
+	sortBy := gql.SortOptionName
+	names, totals = listAll(paged, &sortBy)
+	assert.Equal(t, all, names)
+	assert.Equal(t, []int{5, 5, 5}, totals)
+	assert.Equal(t, 0, paged.calls)
+
+	names, totals = listAll(fileStorage, nil)
+	assert.Equal(t, all, names)
+	assert.Equal(t, []int{5, 5, 5}, totals)
+
+	resolver := newTestResolver(NewMockStorageProvider(fileStorage), mockRegistryStore, new(MockUserStore),
+		mockImagorProvider, &config.Config{}, nil, zap.NewNop())
+	limit, offset := 2, 1
+	first, err := resolver.Query().ListFiles(ctx, "album", nil, nil, &limit, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, new(string), nil)
+	require.NoError(t, err)
+	require.NotNil(t, first.EndCursor)
+	for _, tc := range []struct {
+		name   string
+		path   string
+		offset *int
+		after  string
+	}{
+		{name: "malformed", path: "album", after: "not a cursor"},
+		{name: "other folder", path: "other", after: *first.EndCursor},
+		{name: "with offset", path: "album", offset: &offset, after: *first.EndCursor},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := resolver.Query().ListFiles(ctx, tc.path, nil, tc.offset, &limit, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, &tc.after, nil)
+			var gqlErr *gqlerror.Error
+			require.ErrorAs(t, err, &gqlErr)
+			assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+		})
+	}
+
+	// Without after, listings keep their offset paging
+	result, err := resolver.Query().ListFiles(ctx, "album", nil, nil, &limit, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	require.NoError(t, err)
+	assert.Nil(t, result.EndCursor)
+	assert.Equal(t, 5, result.TotalCount)
+}
+
+func TestListFiles_LivePhotos(t *testing.T) {
+	baseDir := t.TempDir()
+	writeTestFile(t, baseDir, "album/IMG_0001.HEIC")
+	writeTestFile(t, baseDir, "album/IMG_0001.MOV")
+	writeTestFile(t, baseDir, "album/IMG_0002.HEIC")
+	writeTestFile(t, baseDir, "album/clip.mov")
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+
+	mockImagorProvider := new(MockImagorProvider)
+	mockImagorProvider.On("GenerateURL", "album/IMG_0001.MOV", mock.Anything).Return("/imagor/IMG_0001.MOV", nil)
+	mockImagorProvider.On("GenerateURL", mock.Anything, mock.Anything).Return("/imagor/thumbnail.webp", nil)
+	mockRegistryStore := new(MockRegistryStore)
+	mockRegistryStore.On("GetMulti", mock.Anything, "system:global", mock.Anything).Return([]*registrystore.Registry{}, nil)
+	store := &memoryFileMetaStore{entries: map[string]memoryFileMetaEntry{}}
+	cached := newTestResolver(NewMockStorageProvider(stor), mockRegistryStore, new(MockUserStore),
+		mockImagorProvider, &config.Config{}, nil, zap.NewNop(), WithListCache(listcache.New()), WithFileMetaStore(store))
+	ctx := createReadOnlyContext("user-1")
+	sortBy := gql.SortOptionName
+
+	result, err := cached.Query().ListFiles(ctx, "album", nil, nil, nil, nil, nil, nil, nil, &sortBy, nil, nil, nil, nil, nil, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.TotalCount)
+	require.Len(t, result.Items, 3)
+	assert.Equal(t, "IMG_0001.HEIC", result.Items[0].Name)
+	require.NotNil(t, result.Items[0].LivePhotoVideoURL)
+	assert.Equal(t, "/imagor/IMG_0001.MOV", *result.Items[0].LivePhotoVideoURL)
+	assert.Nil(t, result.Items[1].LivePhotoVideoURL)
+	assert.Equal(t, "clip.mov", result.Items[2].Name)
+
+	// Listing videos alone still shows the video of the pair
+	extensions := ".mov"
+	result, err = cached.Query().ListFiles(ctx, "album", nil, nil, nil, nil, nil, &extensions, nil, &sortBy, nil, nil, nil, nil, nil, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.TotalCount)
+
+	// Storage paged listings know the pair split across pages from the
+	// recorded link
+	paged := newTestResolver(NewMockStorageProvider(stor), mockRegistryStore, new(MockUserStore),
+		mockImagorProvider, &config.Config{}, nil, zap.NewNop(), WithFileMetaStore(store))
+	first, err := paged.Query().ListFiles(ctx, "album", nil, intPtr(0), intPtr(1), nil, nil, nil, nil, &sortBy, nil, nil, nil, nil, nil, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, first.Items, 1)
+	assert.NotNil(t, first.Items[0].LivePhotoVideoURL)
+	second, err := paged.Query().ListFiles(ctx, "album", nil, intPtr(1), intPtr(1), nil, nil, nil, nil, &sortBy, nil, nil, nil, nil, nil, nil, nil)
+	require.NoError(t, err)
+	assert.Empty(t, second.Items, "IMG_0001.MOV is shown with its image")
+}
@@ -0,0 +1,103 @@
+package resolver
+
+import (
+	"context"
+
+	"github.com/cshum/imagor-studio/server/internal/filemeta"
+	"github.com/cshum/imagor-studio/server/pkg/space"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/cshum/imagor/imagorpath"
+	"go.uber.org/zap"
+)
+
+// linkLivePhotos records the Live Photos of a complete folder listing, for
+// later listings that only see a page of it. Failures are logged, the pairs
+// of the listing itself are unaffected.
+func (r *Resolver) linkLivePhotos(ctx context.Context, spaceConfig *space.Space, folder string, items []storage.FileInfo) {
+	if r.fileMetaStore == nil {
+		return
+	}
+	pairs := filemeta.PairLivePhotos(filePaths(items))
+	if err := r.fileMetaStore.LinkLivePhotos(ctx, fileMetadataScope(spaceConfig), folder, pairs); err != nil {
+		r.logger.Warn("Failed to link live photos", zap.String("folder", folder), zap.Error(err))
+	}
+}
+
+// livePhotos pairs the Live Photos of listed items, mapping image paths to
+// their videos. A complete listing is paired by itself, a page also by the
+// links recorded for pairs split across pages.
+func (r *Resolver) livePhotos(ctx context.Context, spaceConfig *space.Space, items []storage.FileInfo, complete bool) map[string]string {
+	paths := filePaths(items)
+	pairs := filemeta.PairLivePhotos(paths)
+	if complete || r.fileMetaStore == nil || len(paths) == 0 {
+		return pairs
+	}
+	links, err := r.fileMetaStore.LivePhotos(ctx, fileMetadataScope(spaceConfig), paths)
+	if err != nil {
+		r.logger.Warn("Failed to get live photo links", zap.Error(err))
+		return pairs
+	}
+	for image, video := range pairs {
+		links[image] = video
+	}
+	return links
+}
+
+// hideLivePhotoVideos drops the videos of Live Photos from a listing, along
+// with their system tags, as each is shown with its image. Without
+// showImageless, a video is only dropped when its image is listed too.
+func hideLivePhotoVideos(items []storage.FileInfo, itemTags [][]string, pairs map[string]string, showImageless bool) ([]storage.FileInfo, [][]string) {
+	if len(pairs) == 0 {
+		return items, itemTags
+	}
+	listed := make(map[string]bool, len(items))
+	for _, item := range items {
+		listed[item.Path] = true
+	}
+	hidden := make(map[string]bool, len(pairs))
+	for image, video := range pairs {
+		if listed[image] || !showImageless {
+			hidden[video] = true
+		}
+	}
+	filtered := make([]storage.FileInfo, 0, len(items))
+	filteredTags := make([][]string, 0, len(items))
+	for i, item := range items {
+		if !item.IsDir && hidden[item.Path] {
+			continue
+		}
+		filtered = append(filtered, item)
+		filteredTags = append(filteredTags, itemTags[i])
+	}
+	return filtered, filteredTags
+}
+
+// generateLivePhotoVideoURL returns the URL playing the video of a Live
+// Photo, nil where originals cannot be downloaded
+func (r *Resolver) generateLivePhotoVideoURL(ctx context.Context, videoPath string, spaceConfig *space.Space) *string {
+	if r.imagorProvider == nil || !CanDownloadOriginals(ctx) {
+		return nil
+	}
+	var spaceKey *string
+	if spaceConfig != nil {
+		spaceKey = &spaceConfig.Key
+	}
+	params := imagorpath.Params{Filters: imagorpath.Filters{{Name: "raw"}}}
+	videoURL, err := r.generateImagorURLForSpaceConfig(videoPath, params, spaceConfig)
+	if err != nil {
+		return nil
+	}
+	videoURL = r.clientImagorURL(ctx, absolutizeURL(r.processingOriginForSpace(ctx, spaceKey), videoURL), videoPath, params)
+	return &videoURL
+}
+
+// filePaths returns the paths of the files among items
+func filePaths(items []storage.FileInfo) []string {
+	paths := make([]string, 0, len(items))
+	for _, item := range items {
+		if !item.IsDir {
+			paths = append(paths, item.Path)
+		}
+	}
+	return paths
+}
@@ -0,0 +1,119 @@
+package resolver
+
+import (
+	"context"
+	"sync"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/cshum/imagor-studio/server/internal/dataloader"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/pkg/space"
+	"github.com/cshum/imagor-studio/server/pkg/uuid"
+	"github.com/cshum/imagor/imagorpath"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+type requestLoadersKey struct{}
+
+// requestLoaders cache the lookups made for every item of a listing, such as
+// the processing origin and signed URLs of thumbnails, for one operation
+type requestLoaders struct {
+	once              sync.Once
+	processingOrigins *dataloader.Loader[string, string]
+
+	mu   sync.Mutex
+	urls map[imagorURLKey]string
+}
+
+// imagorURLKey identifies a signed imagor URL: the unsigned path and the
+// space whose secret signs it
+type imagorURLKey struct {
+	spaceID string
+	path    string
+}
+
+// LoaderMiddleware attaches request loaders to queries and mutations, so
+// listings look up registry settings and processing origins once instead of
+// once per item. Subscriptions live too long to cache settings and are left
+// alone.
+func LoaderMiddleware() graphql.OperationMiddleware {
+	return func(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+		if opCtx := graphql.GetOperationContext(ctx); opCtx == nil || opCtx.Operation == nil ||
+			opCtx.Operation.Operation == ast.Subscription {
+			return next(ctx)
+		}
+		return next(WithRequestLoaders(ctx))
+	}
+}
+
+// WithRequestLoaders returns ctx carrying request loaders and a registry
+// request cache
+func WithRequestLoaders(ctx context.Context) context.Context {
+	ctx = registrystore.WithRequestCache(ctx)
+	return context.WithValue(ctx, requestLoadersKey{}, &requestLoaders{urls: make(map[imagorURLKey]string)})
+}
+
+// loaders returns the request loaders of ctx, nil outside of operations
+// attached by LoaderMiddleware
+func (r *Resolver) loaders(ctx context.Context) *requestLoaders {
+	l, ok := ctx.Value(requestLoadersKey{}).(*requestLoaders)
+	if !ok {
+		return nil
+	}
+	l.once.Do(func() {
+		resolver := r.processingOriginResolver
+		l.processingOrigins = dataloader.New(func(ctx context.Context, spaceKeys []string) (map[string]string, error) {
+			origins := make(map[string]string, len(spaceKeys))
+			for _, spaceKey := range spaceKeys {
+				origins[spaceKey] = resolver.ResolveProcessingOrigin(ctx, spaceKey)
+			}
+			return origins, nil
+		})
+	})
+	return l
+}
+
+// resolveProcessingOrigin returns the processing origin of a space, once per
+// request
+func (r *Resolver) resolveProcessingOrigin(ctx context.Context, spaceKey string) string {
+	l := r.loaders(ctx)
+	if l == nil {
+		return r.processingOriginResolver.ResolveProcessingOrigin(ctx, spaceKey)
+	}
+	origin, err := l.processingOrigins.Load(ctx, spaceKey)
+	if err != nil {
+		return ""
+	}
+	return origin
+}
+
+// signedImagorURL returns the imagor URL of imagePath rendered with params,
+// signed once per request for URLs repeated within it
+func (r *Resolver) signedImagorURL(ctx context.Context, imagePath string, params imagorpath.Params, spaceConfig *space.Space) (string, error) {
+	l := r.loaders(ctx)
+	if l == nil {
+		return r.generateImagorURLForSpaceConfig(imagePath, params, spaceConfig)
+	}
+	key := imagorURLKey{path: canonicalImagorPath(imagePath, params)}
+	if spaceConfig != nil {
+		key.spaceID = spaceConfig.ID
+		if key.spaceID == "" {
+			// Unsaved spaces are never shared between lookups
+			key.spaceID = uuid.GenerateUUID()
+		}
+	}
+	l.mu.Lock()
+	url, ok := l.urls[key]
+	l.mu.Unlock()
+	if ok {
+		return url, nil
+	}
+	url, err := r.generateImagorURLForSpaceConfig(imagePath, params, spaceConfig)
+	if err != nil {
+		return "", err
+	}
+	l.mu.Lock()
+	l.urls[key] = url
+	l.mu.Unlock()
+	return url, nil
+}
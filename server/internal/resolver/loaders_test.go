@@ -0,0 +1,68 @@
+package resolver
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/pkg/management"
+	"github.com/cshum/imagor-studio/server/pkg/space"
+	"github.com/cshum/imagor/imagorpath"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRequestLoaders_ResolveProcessingOriginOncePerRequest(t *testing.T) {
+	var lookups atomic.Int32
+	spaceKey := "acme"
+	spaceConfig := &space.Space{
+		ID:              "space-1",
+		Key:             spaceKey,
+		ImagorSecret:    "space-secret",
+		SignerAlgorithm: "sha256",
+		SignerTruncate:  32,
+	}
+	resolver := newTestResolver(nil, new(MockRegistryStore), new(MockUserStore), new(MockImagorProvider), &config.Config{}, nil, zap.NewNop(),
+		WithCloudConfig(management.CloudConfig{InternalAPISecret: "internal-secret"}),
+		WithProcessingOriginResolver(space.ProcessingOriginResolverFunc(func(ctx context.Context, key string) string {
+			lookups.Add(1)
+			return "https://acme.imagor.app"
+		})),
+	)
+
+	uncached := resolver.generateThumbnailUrlsForResolvedSpace(context.Background(), "photos/a.jpg", "first_frame", &spaceKey, spaceConfig)
+	require.NotNil(t, uncached)
+	assert.Equal(t, int32(1), lookups.Load())
+
+	lookups.Store(0)
+	ctx := WithRequestLoaders(context.Background())
+	for range 1000 {
+		urls := resolver.generateThumbnailUrlsForResolvedSpace(ctx, "photos/a.jpg", "first_frame", &spaceKey, spaceConfig)
+		require.NotNil(t, urls)
+		assert.Equal(t, *uncached.Grid, *urls.Grid)
+		assert.Equal(t, *uncached.Full, *urls.Full)
+	}
+	assert.Equal(t, int32(1), lookups.Load())
+
+	// Each request resolves again
+	resolver.generateThumbnailUrlsForResolvedSpace(WithRequestLoaders(context.Background()), "photos/a.jpg", "first_frame", &spaceKey, spaceConfig)
+	assert.Equal(t, int32(2), lookups.Load())
+}
+
+func TestRequestLoaders_SignedURLsKeepSpacesApart(t *testing.T) {
+	resolver := newTestResolver(nil, new(MockRegistryStore), new(MockUserStore), new(MockImagorProvider), &config.Config{}, nil, zap.NewNop())
+	ctx := WithRequestLoaders(context.Background())
+
+	params := imagorpath.Params{Width: 300, Height: 225}
+	first, err := resolver.signedImagorURL(ctx, "photos/a.jpg", params, &space.Space{ID: "space-1", ImagorSecret: "secret-1"})
+	require.NoError(t, err)
+	second, err := resolver.signedImagorURL(ctx, "photos/a.jpg", params, &space.Space{ID: "space-2", ImagorSecret: "secret-2"})
+	require.NoError(t, err)
+	assert.NotEqual(t, first, second)
+
+	again, err := resolver.signedImagorURL(ctx, "photos/a.jpg", params, &space.Space{ID: "space-1", ImagorSecret: "secret-1"})
+	require.NoError(t, err)
+	assert.Equal(t, first, again)
+}
@@ -0,0 +1,23 @@
+package resolver
+
+import (
+	"context"
+
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// RunDatabaseMaintenance is the resolver for the runDatabaseMaintenance field.
+func (r *mutationResolver) RunDatabaseMaintenance(ctx context.Context) (*gql.Operation, error) {
+	if err := RequireAdminPermission(ctx); err != nil {
+		return nil, err
+	}
+	if r.databaseMaintenance == nil {
+		return nil, &gqlerror.Error{
+			Message:    "database maintenance is only available for SQLite databases",
+			Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+		}
+	}
+	userID, _ := GetUserIDFromContext(ctx)
+	return toGQLOperation(r.databaseMaintenance.Start(ctx, userID)), nil
+}
@@ -0,0 +1,58 @@
+package resolver
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/database"
+	"github.com/cshum/imagor-studio/server/internal/dbmaintenance"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/operation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func TestRunDatabaseMaintenance(t *testing.T) {
+	db, err := database.Connect("sqlite:" + filepath.Join(t.TempDir(), "studio.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	logger, _ := zap.NewDevelopment()
+	manager := operation.NewManager(logger)
+	resolver := newTestResolver(nil, nil, nil, nil, nil, nil, logger,
+		WithOperationManager(manager),
+		WithDatabaseMaintenance(dbmaintenance.New(db, manager, logger)))
+
+	_, err = resolver.Mutation().RunDatabaseMaintenance(createReadWriteContext("user-1"))
+	assert.Error(t, err)
+
+	ctx := createAdminContext("admin-1")
+	started, err := resolver.Mutation().RunDatabaseMaintenance(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, dbmaintenance.OperationKind, started.Kind)
+
+	waitCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = manager.Wait(waitCtx, started.ID)
+	require.NoError(t, err)
+
+	op, err := resolver.Query().Operation(ctx, started.ID)
+	require.NoError(t, err)
+	require.NotNil(t, op)
+	assert.Equal(t, gql.OperationStatusSucceeded, op.Status)
+	assert.Contains(t, op.Results, "integrity_check: ok")
+}
+
+func TestRunDatabaseMaintenance_NotAvailable(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	resolver := newTestResolver(nil, nil, nil, nil, nil, nil, logger)
+
+	_, err := resolver.Mutation().RunDatabaseMaintenance(createAdminContext("admin-1"))
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_AVAILABLE", gqlErr.Extensions["code"])
+}
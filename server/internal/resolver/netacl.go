@@ -0,0 +1,106 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+
+	"github.com/cshum/imagor-studio/server/internal/auditlog"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/netacl"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+// NetworkAcls is the resolver for the networkAcls field.
+func (r *queryResolver) NetworkAcls(ctx context.Context) ([]*gql.NetworkACL, error) {
+	if err := r.requireNetworkACL(ctx); err != nil {
+		return nil, err
+	}
+	acls := make([]*gql.NetworkACL, 0, len(netacl.Groups))
+	for _, group := range netacl.Groups {
+		acls = append(acls, r.toGQLNetworkACL(group))
+	}
+	return acls, nil
+}
+
+// SetNetworkACL is the resolver for the setNetworkAcl field.
+func (r *mutationResolver) SetNetworkACL(ctx context.Context, group string, allow []string, deny []string) (*gql.NetworkACL, error) {
+	if err := r.requireNetworkACL(ctx); err != nil {
+		return nil, err
+	}
+	if !netacl.IsGroup(group) {
+		return nil, networkACLInputError(netacl.ErrUnknownGroup)
+	}
+	acl := &netacl.ACL{}
+	var err error
+	if acl.Allow, err = netacl.ParseNetworks(allow); err != nil {
+		return nil, networkACLInputError(err)
+	}
+	if acl.Deny, err = netacl.ParseNetworks(deny); err != nil {
+		return nil, networkACLInputError(err)
+	}
+	// The admin reaches the API through these groups, refuse an ACL that
+	// would lock them out
+	if group == netacl.GroupGraphQL || group == netacl.GroupAuth {
+		if addr, err := netip.ParseAddr(auditlog.ClientIPFromContext(ctx)); err == nil && !acl.Allowed(addr) {
+			return nil, networkACLInputError(errors.New("the network ACL would refuse your own address " + addr.String()))
+		}
+	}
+	if err := r.networkACL.Set(ctx, group, acl); err != nil {
+		r.logger.Error("Failed to set network ACL", zap.String("group", group), zap.Error(err))
+		return nil, err
+	}
+	return r.toGQLNetworkACL(group), nil
+}
+
+// ClearNetworkACL is the resolver for the clearNetworkAcl field.
+func (r *mutationResolver) ClearNetworkACL(ctx context.Context, group string) (*gql.NetworkACL, error) {
+	if err := r.requireNetworkACL(ctx); err != nil {
+		return nil, err
+	}
+	if err := r.networkACL.Clear(ctx, group); err != nil {
+		if errors.Is(err, netacl.ErrUnknownGroup) {
+			return nil, networkACLInputError(err)
+		}
+		r.logger.Error("Failed to clear network ACL", zap.String("group", group), zap.Error(err))
+		return nil, err
+	}
+	return r.toGQLNetworkACL(group), nil
+}
+
+func (r *Resolver) requireNetworkACL(ctx context.Context) error {
+	if err := RequireAdminPermission(ctx); err != nil {
+		return err
+	}
+	if r.networkACL == nil {
+		return &gqlerror.Error{
+			Message:    "network ACLs are not available on this server",
+			Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+		}
+	}
+	return nil
+}
+
+func (r *Resolver) toGQLNetworkACL(group string) *gql.NetworkACL {
+	result := &gql.NetworkACL{Group: group, Allow: []string{}, Deny: []string{}}
+	acl, restricted := r.networkACL.Get(group)
+	if !restricted {
+		return result
+	}
+	result.Restricted = true
+	for _, p := range acl.Allow {
+		result.Allow = append(result.Allow, p.String())
+	}
+	for _, p := range acl.Deny {
+		result.Deny = append(result.Deny, p.String())
+	}
+	return result
+}
+
+func networkACLInputError(err error) error {
+	return &gqlerror.Error{
+		Message:    err.Error(),
+		Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+	}
+}
@@ -0,0 +1,69 @@
+package resolver
+
+import (
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/auditlog"
+	"github.com/cshum/imagor-studio/server/internal/netacl"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func TestNetworkAcls(t *testing.T) {
+	mockRegistryStore := new(MockRegistryStore)
+	store := netacl.New(mockRegistryStore, zap.NewNop())
+	resolver := newTestResolver(nil, mockRegistryStore, nil, nil, nil, nil, zap.NewNop(), WithNetworkACL(store))
+	ctx := auditlog.WithClientIP(createAdminContext("admin-1"), "10.1.2.3")
+
+	_, err := resolver.Query().NetworkAcls(createReadWriteContext("user-1"))
+	assert.Error(t, err)
+
+	mockRegistryStore.On("Set", ctx, registrystore.SystemOwnerID, netacl.Key(netacl.GroupGraphQL), `{"allow":["10.0.0.0/8"],"deny":["10.6.6.6/32"]}`, false).
+		Return(&registrystore.Registry{}, nil)
+	acl, err := resolver.Mutation().SetNetworkACL(ctx, netacl.GroupGraphQL, []string{"10.0.0.0/8"}, []string{"10.6.6.6"})
+	require.NoError(t, err)
+	assert.True(t, acl.Restricted)
+	assert.Equal(t, []string{"10.0.0.0/8"}, acl.Allow)
+	assert.Equal(t, []string{"10.6.6.6/32"}, acl.Deny)
+
+	acls, err := resolver.Query().NetworkAcls(ctx)
+	require.NoError(t, err)
+	require.Len(t, acls, len(netacl.Groups))
+	for _, acl := range acls {
+		assert.Equal(t, acl.Group == netacl.GroupGraphQL, acl.Restricted, acl.Group)
+	}
+
+	var gqlErr *gqlerror.Error
+	// The admin cannot lock themselves out
+	_, err = resolver.Mutation().SetNetworkACL(ctx, netacl.GroupAuth, []string{"192.168.0.0/16"}, nil)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+	_, err = resolver.Mutation().SetNetworkACL(ctx, netacl.GroupGraphQL, nil, []string{"10.1.2.3"})
+	require.ErrorAs(t, err, &gqlErr)
+	_, err = resolver.Mutation().SetNetworkACL(ctx, netacl.GroupImagor, []string{"not-a-network"}, nil)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+	_, err = resolver.Mutation().SetNetworkACL(ctx, "health", nil, nil)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+
+	mockRegistryStore.On("DeleteMulti", ctx, registrystore.SystemOwnerID, []string{netacl.Key(netacl.GroupGraphQL)}).Return(nil)
+	acl, err = resolver.Mutation().ClearNetworkACL(ctx, netacl.GroupGraphQL)
+	require.NoError(t, err)
+	assert.False(t, acl.Restricted)
+	assert.Empty(t, acl.Allow)
+	mockRegistryStore.AssertExpectations(t)
+	mockRegistryStore.AssertNotCalled(t, "Set", mock.Anything, mock.Anything, netacl.Key(netacl.GroupAuth), mock.Anything, mock.Anything)
+}
+
+func TestNetworkAcls_NotAvailable(t *testing.T) {
+	resolver := newTestResolver(nil, nil, nil, nil, nil, nil, zap.NewNop())
+	_, err := resolver.Query().NetworkAcls(createAdminContext("admin-1"))
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_AVAILABLE", gqlErr.Extensions["code"])
+}
@@ -0,0 +1,53 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/notify"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+// TestNotification is the resolver for the testNotification field.
+func (r *mutationResolver) TestNotification(ctx context.Context, channel *string) ([]*gql.NotificationTestResult, error) {
+	if err := RequireAdminPermission(ctx); err != nil {
+		return nil, err
+	}
+	if r.notifier == nil {
+		return nil, &gqlerror.Error{
+			Message:    "notifications are not available on this server",
+			Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+		}
+	}
+	var name string
+	if channel != nil {
+		name = *channel
+	}
+	results, err := r.notifier.Test(ctx, name)
+	if errors.Is(err, notify.ErrInvalid) || errors.Is(err, notify.ErrNotConfigured) {
+		return nil, &gqlerror.Error{
+			Message:    err.Error(),
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	currentUserID, _ := GetUserIDFromContext(ctx)
+	gqlResults := make([]*gql.NotificationTestResult, 0, len(results))
+	for _, result := range results {
+		gqlResult := &gql.NotificationTestResult{Channel: result.Channel, Success: result.Err == nil}
+		if result.Err != nil {
+			message := result.Err.Error()
+			gqlResult.Error = &message
+		}
+		r.logger.Info("Test notification sent",
+			zap.String("channel", result.Channel),
+			zap.Bool("success", result.Err == nil),
+			zap.String("sentByUserID", currentUserID))
+		gqlResults = append(gqlResults, gqlResult)
+	}
+	return gqlResults, nil
+}
@@ -0,0 +1,94 @@
+package resolver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/notify"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func TestTestNotification(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+
+	mockRegistryStore := new(MockRegistryStore)
+	cfg := &MockConfig{}
+	notifier := notify.New(zap.NewNop(), mockRegistryStore, cfg)
+	resolver := newTestResolver(nil, mockRegistryStore, new(MockUserStore), nil, cfg, nil, zap.NewNop(), WithNotifier(notifier))
+	ctx := createAdminContext("admin-1")
+
+	mockRegistryStore.On("GetMulti", ctx, registrystore.SystemOwnerID, mock.Anything).Return([]*registrystore.Registry{
+		{Key: notify.NtfyURLKey, Value: srv.URL + "/alerts"},
+		{Key: notify.NtfyTokenKey, Value: "wrong", IsEncrypted: true},
+	}, nil).Once()
+	results, err := resolver.Mutation().TestNotification(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, notify.ChannelNtfy, results[0].Channel)
+	assert.False(t, results[0].Success)
+	require.NotNil(t, results[0].Error)
+	assert.Contains(t, *results[0].Error, "401 Unauthorized")
+
+	mockRegistryStore.On("GetMulti", ctx, registrystore.SystemOwnerID, mock.Anything).Return([]*registrystore.Registry{
+		{Key: notify.NtfyURLKey, Value: srv.URL + "/alerts"},
+		{Key: notify.NtfyTokenKey, Value: "s3cret", IsEncrypted: true},
+	}, nil)
+	channel := notify.ChannelNtfy
+	results, err = resolver.Mutation().TestNotification(ctx, &channel)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.True(t, results[0].Success)
+	assert.Nil(t, results[0].Error)
+
+	var gqlErr *gqlerror.Error
+	channel = notify.ChannelTelegram
+	_, err = resolver.Mutation().TestNotification(ctx, &channel)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+
+	_, err = resolver.Mutation().TestNotification(createReadWriteContext("user-1"), nil)
+	assert.Error(t, err)
+}
+
+func TestTestNotification_NotAvailable(t *testing.T) {
+	resolver := newTestResolver(nil, new(MockRegistryStore), new(MockUserStore), nil, &MockConfig{}, nil, zap.NewNop())
+
+	_, err := resolver.Mutation().TestNotification(createAdminContext("admin-1"), nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_AVAILABLE", gqlErr.Extensions["code"])
+}
+
+func TestSetSystemRegistry_CredentialsEncrypted(t *testing.T) {
+	mockRegistryStore := new(MockRegistryStore)
+	resolver := newTestResolver(nil, mockRegistryStore, new(MockUserStore), nil, &MockConfig{}, nil, zap.NewNop())
+	ctx := createAdminContext("admin-1")
+
+	entries := []*registrystore.Registry{
+		{Key: notify.TelegramBotTokenKey, Value: "123:abc", IsEncrypted: true},
+		{Key: notify.TelegramChatIDKey, Value: "42"},
+	}
+	mockRegistryStore.On("SetMulti", ctx, registrystore.SystemOwnerID, entries).Return(entries, nil)
+
+	result, err := resolver.Mutation().SetSystemRegistry(ctx, nil, []*gql.RegistryEntryInput{
+		{Key: notify.TelegramBotTokenKey, Value: "123:abc"},
+		{Key: notify.TelegramChatIDKey, Value: "42"},
+	})
+	require.NoError(t, err)
+	require.Len(t, result, 2)
+	assert.True(t, result[0].IsEncrypted)
+	assert.Empty(t, result[0].Value)
+	mockRegistryStore.AssertExpectations(t)
+}
@@ -0,0 +1,301 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/events"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/operation"
+	"github.com/cshum/imagor-studio/server/pkg/space"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+// Operation kinds
+const (
+	operationKindDeleteFolder  = "delete_folder"
+	operationKindConvertImages = "convert_images"
+	operationKindOrganizeFiles = "organize_files"
+)
+
+// Operation is the resolver for the operation field.
+func (r *queryResolver) Operation(ctx context.Context, id string) (*gql.Operation, error) {
+	if err := RequirePermission(ctx, "read"); err != nil {
+		return nil, err
+	}
+	op, err := r.getOwnedOperation(ctx, id)
+	if err != nil {
+		if errors.Is(err, operation.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return toGQLOperation(op), nil
+}
+
+// Operations is the resolver for the operations field.
+func (r *queryResolver) Operations(ctx context.Context, kind *string) ([]*gql.Operation, error) {
+	if err := RequirePermission(ctx, "read"); err != nil {
+		return nil, err
+	}
+	filter := ""
+	if kind != nil {
+		filter = *kind
+	}
+	isAdmin := RequireAdminPermission(ctx) == nil
+	userID, _ := GetUserIDFromContext(ctx)
+
+	result := []*gql.Operation{}
+	for _, op := range r.operations.List(filter) {
+		if !isAdmin && (userID == "" || op.OwnerID != userID) {
+			continue
+		}
+		result = append(result, toGQLOperation(op))
+	}
+	return result, nil
+}
+
+// CancelOperation is the resolver for the cancelOperation field.
+func (r *mutationResolver) CancelOperation(ctx context.Context, id string) (*gql.Operation, error) {
+	if err := RequirePermission(ctx, "read"); err != nil {
+		return nil, err
+	}
+	if _, err := r.getOwnedOperation(ctx, id); err != nil {
+		if errors.Is(err, operation.ErrNotFound) {
+			return nil, operationNotFoundError(id)
+		}
+		return nil, err
+	}
+	op, err := r.operations.Cancel(id)
+	if errors.Is(err, operation.ErrRemote) {
+		return nil, &gqlerror.Error{
+			Message:    err.Error(),
+			Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+		}
+	}
+	if err != nil {
+		return nil, operationNotFoundError(id)
+	}
+	r.logger.Info("Operation cancellation requested", zap.String("id", id), zap.String("kind", op.Kind))
+	return toGQLOperation(op), nil
+}
+
+// DeleteFolderAsync is the resolver for the deleteFolderAsync field.
+func (r *mutationResolver) DeleteFolderAsync(ctx context.Context, path string, spaceID *string) (*gql.Operation, error) {
+	if path == "" || path == "/" {
+		return nil, &gqlerror.Error{
+			Message:    "cannot delete the root folder",
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	path, err := ScopePath(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if err := RequireWritePermission(ctx, path); err != nil {
+		return nil, err
+	}
+	stor, sp, err := r.resolveUploadStorageTarget(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	info, err := stor.Stat(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file stats: %w", err)
+	}
+	if !info.IsDir {
+		return nil, &gqlerror.Error{
+			Message:    fmt.Sprintf("%s is not a folder", path),
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+
+	// Resolved up front as the operation outlives the request
+	var tagScope string
+	if r.tagStore != nil {
+		if tagScope, err = r.tagScope(ctx, spaceID); err != nil {
+			return nil, err
+		}
+	}
+
+	ownerID, _ := GetUserIDFromContext(ctx)
+	op := r.operations.Start(ctx, operationKindDeleteFolder, ownerID, func(ctx context.Context, progress *operation.Progress) error {
+		if err := r.deleteFolderWithProgress(ctx, stor, sp, path, progress); err != nil {
+			return err
+		}
+		if tagScope != "" {
+			if err := r.tagStore.RemoveFilePath(ctx, tagScope, path); err != nil {
+				r.logger.Warn("Failed to remove file tags", zap.String("path", path), zap.Error(err))
+			}
+		}
+		return nil
+	})
+	return toGQLOperation(op), nil
+}
+
+// deleteBatchSize is the number of files per batch delete request
+const deleteBatchSize = 1000
+
+// deleteFolderWithProgress deletes every file under path so that progress
+// can be reported and cancellation honoured between files, then removes the
+// remaining empty folder tree. Backends supporting batch deletes remove
+// files a batch at a time, unless hosted storage rows must be kept in sync
+// file by file.
+func (r *Resolver) deleteFolderWithProgress(ctx context.Context, stor storage.Storage, sp *space.Space, path string, progress *operation.Progress) error {
+	progress.SetMessage("Scanning folder")
+	var files []storage.FileInfo
+	if err := walkStorageFiles(ctx, stor, path, func(item storage.FileInfo) error {
+		files = append(files, item)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	progress.SetTotal(len(files))
+	progress.SetMessage("Deleting files")
+	if batch, ok := stor.(storage.BatchDeleter); ok && !r.tracksHostedStorage(sp) {
+		for start := 0; start < len(files); start += deleteBatchSize {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			chunk := files[start:min(start+deleteBatchSize, len(files))]
+			keys := make([]string, len(chunk))
+			for i, item := range chunk {
+				keys[i] = item.Path
+			}
+			if err := batch.DeleteBatch(ctx, keys); err != nil {
+				return fmt.Errorf("failed to delete files: %w", err)
+			}
+			progress.Advance(len(keys), keys...)
+		}
+		return r.removeEmptyFolder(ctx, stor, sp, path, progress)
+	}
+	removed := make(map[string]bool)
+	for _, item := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// Template previews are removed together with their template
+		if removed[item.Path] {
+			progress.Advance(1, item.Path)
+			continue
+		}
+		if err := r.deleteStoragePath(ctx, stor, sp, item.Path); err != nil {
+			return err
+		}
+		if previewPath := getPreviewPath(item.Path); previewPath != "" {
+			removed[previewPath] = true
+		}
+		progress.Advance(1, item.Path)
+	}
+	return r.removeEmptyFolder(ctx, stor, sp, path, progress)
+}
+
+// removeEmptyFolder deletes the folder tree left after its files are gone
+func (r *Resolver) removeEmptyFolder(ctx context.Context, stor storage.Storage, sp *space.Space, path string, progress *operation.Progress) error {
+	progress.SetMessage("Removing folders")
+	if err := stor.Delete(ctx, path); err != nil {
+		return fmt.Errorf("failed to delete folder: %w", err)
+	}
+	r.publishSpaceFileChange(sp, events.FileDeleted, path, "")
+	progress.SetMessage("")
+	return nil
+}
+
+// walkStorageFiles calls fn for every file below dir, depth first
+func walkStorageFiles(ctx context.Context, stor storage.Storage, dir string, fn func(storage.FileInfo) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	result, err := stor.List(ctx, dir, storage.ListOptions{ShowHidden: true})
+	if err != nil {
+		return fmt.Errorf("failed to list files: %w", err)
+	}
+	for _, item := range result.Items {
+		if item.IsDir {
+			if err := walkStorageFiles(ctx, stor, item.Path, fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getOwnedOperation returns the operation if the caller started it or is an admin
+func (r *Resolver) getOwnedOperation(ctx context.Context, id string) (operation.Operation, error) {
+	op, err := r.operations.Get(id)
+	if err != nil {
+		return operation.Operation{}, err
+	}
+	if RequireAdminPermission(ctx) == nil {
+		return op, nil
+	}
+	userID, err := GetUserIDFromContext(ctx)
+	if err != nil || userID != op.OwnerID {
+		// Do not reveal operations owned by other users
+		return operation.Operation{}, operation.ErrNotFound
+	}
+	return op, nil
+}
+
+func operationNotFoundError(id string) error {
+	return &gqlerror.Error{
+		Message:    fmt.Sprintf("operation not found: %s", id),
+		Extensions: map[string]interface{}{"code": "NOT_FOUND"},
+	}
+}
+
+func toGQLOperation(op operation.Operation) *gql.Operation {
+	result := &gql.Operation{
+		ID:        op.ID,
+		Kind:      op.Kind,
+		Completed: op.Completed,
+		Results:   op.Results,
+		CreatedAt: op.CreatedAt.Format(time.RFC3339),
+		UpdatedAt: op.UpdatedAt.Format(time.RFC3339),
+	}
+	if result.Results == nil {
+		result.Results = []string{}
+	}
+	switch op.Status {
+	case operation.StatusQueued:
+		result.Status = gql.OperationStatusQueued
+	case operation.StatusSucceeded:
+		result.Status = gql.OperationStatusSucceeded
+	case operation.StatusFailed:
+		result.Status = gql.OperationStatusFailed
+	case operation.StatusCancelled:
+		result.Status = gql.OperationStatusCancelled
+	default:
+		result.Status = gql.OperationStatusRunning
+	}
+	if op.Total > 0 {
+		total := op.Total
+		result.Total = &total
+	}
+	if op.Message != "" {
+		message := op.Message
+		result.Message = &message
+	}
+	if op.Error != "" {
+		errMsg := op.Error
+		result.Error = &errMsg
+	}
+	if op.StartedAt != nil {
+		startedAt := op.StartedAt.Format(time.RFC3339)
+		result.StartedAt = &startedAt
+	}
+	if op.FinishedAt != nil {
+		finishedAt := op.FinishedAt.Format(time.RFC3339)
+		result.FinishedAt = &finishedAt
+	}
+	return result
+}
@@ -0,0 +1,193 @@
+package resolver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/operation"
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func newOperationTestResolver(t *testing.T) (*Resolver, *operation.Manager, string) {
+	t.Helper()
+	baseDir := t.TempDir()
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+	logger, _ := zap.NewDevelopment()
+	manager := operation.NewManager(logger)
+	resolver := newTestResolver(NewMockStorageProvider(stor), new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, logger,
+		WithOperationManager(manager))
+	return resolver, manager, baseDir
+}
+
+func writeTestFile(t *testing.T, baseDir, path string) {
+	t.Helper()
+	fullPath := filepath.Join(baseDir, path)
+	require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0755))
+	require.NoError(t, os.WriteFile(fullPath, []byte("test"), 0644))
+}
+
+func TestDeleteFolderAsync_ReportsProgress(t *testing.T) {
+	resolver, manager, baseDir := newOperationTestResolver(t)
+	writeTestFile(t, baseDir, "album/a.jpg")
+	writeTestFile(t, baseDir, "album/nested/b.jpg")
+	writeTestFile(t, baseDir, "album/design.imagor.json")
+	writeTestFile(t, baseDir, "album/design.imagor.preview")
+	writeTestFile(t, baseDir, "keep.jpg")
+
+	ctx := createReadWriteContext("user-1")
+	started, err := resolver.Mutation().DeleteFolderAsync(ctx, "album", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "delete_folder", started.Kind)
+
+	waitCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = manager.Wait(waitCtx, started.ID)
+	require.NoError(t, err)
+
+	op, err := resolver.Query().Operation(ctx, started.ID)
+	require.NoError(t, err)
+	assert.Equal(t, gql.OperationStatusSucceeded, op.Status)
+	assert.Equal(t, 4, op.Completed)
+	require.NotNil(t, op.Total)
+	assert.Equal(t, 4, *op.Total)
+	assert.Len(t, op.Results, 4)
+	assert.NotNil(t, op.FinishedAt)
+
+	_, err = os.Stat(filepath.Join(baseDir, "album"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(baseDir, "keep.jpg"))
+	assert.NoError(t, err)
+}
+
+func TestDeleteFolderAsync_Validation(t *testing.T) {
+	resolver, _, baseDir := newOperationTestResolver(t)
+	writeTestFile(t, baseDir, "file.jpg")
+
+	_, err := resolver.Mutation().DeleteFolderAsync(createReadOnlyContext("user-1"), "album", nil)
+	assert.Error(t, err)
+
+	_, err = resolver.Mutation().DeleteFolderAsync(createReadWriteContext("user-1"), "", nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+
+	_, err = resolver.Mutation().DeleteFolderAsync(createReadWriteContext("user-1"), "file.jpg", nil)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+}
+
+func TestOperation_OwnershipAndCancel(t *testing.T) {
+	resolver, manager, _ := newOperationTestResolver(t)
+	release := make(chan struct{})
+	defer close(release)
+
+	started := manager.Start(context.Background(), "test", "user-1", func(ctx context.Context, progress *operation.Progress) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-release:
+			return nil
+		}
+	})
+
+	// Other users cannot see the operation
+	op, err := resolver.Query().Operation(createReadOnlyContext("user-2"), started.ID)
+	assert.NoError(t, err)
+	assert.Nil(t, op)
+	_, err = resolver.Mutation().CancelOperation(createReadOnlyContext("user-2"), started.ID)
+	assert.Error(t, err)
+
+	// Unknown operations resolve to null
+	op, err = resolver.Query().Operation(createReadOnlyContext("user-1"), "missing")
+	assert.NoError(t, err)
+	assert.Nil(t, op)
+
+	// Admins can see and cancel any operation
+	op, err = resolver.Query().Operation(createAdminContext("admin-1"), started.ID)
+	require.NoError(t, err)
+	assert.Equal(t, gql.OperationStatusRunning, op.Status)
+
+	_, err = resolver.Mutation().CancelOperation(createReadOnlyContext("user-1"), started.ID)
+	require.NoError(t, err)
+
+	waitCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	finished, err := manager.Wait(waitCtx, started.ID)
+	require.NoError(t, err)
+	assert.Equal(t, operation.StatusCancelled, finished.Status)
+}
+
+func TestOperations_ListsOwnOperationsUnlessAdmin(t *testing.T) {
+	resolver, manager, _ := newOperationTestResolver(t)
+	noop := func(ctx context.Context, p *operation.Progress) error { return nil }
+
+	mine := manager.Start(context.Background(), "delete_folder", "user-1", noop)
+	other := manager.Start(context.Background(), "delete_folder", "user-2", noop)
+	system := manager.Start(context.Background(), "database_maintenance", "system", noop)
+	for _, id := range []string{mine.ID, other.ID, system.ID} {
+		waitCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err := manager.Wait(waitCtx, id)
+		cancel()
+		require.NoError(t, err)
+	}
+
+	ops, err := resolver.Query().Operations(createReadOnlyContext("user-1"), nil)
+	require.NoError(t, err)
+	require.Len(t, ops, 1)
+	assert.Equal(t, mine.ID, ops[0].ID)
+
+	ops, err = resolver.Query().Operations(createAdminContext("admin-1"), nil)
+	require.NoError(t, err)
+	assert.Len(t, ops, 3)
+
+	kind := "database_maintenance"
+	ops, err = resolver.Query().Operations(createAdminContext("admin-1"), &kind)
+	require.NoError(t, err)
+	require.Len(t, ops, 1)
+	assert.Equal(t, system.ID, ops[0].ID)
+}
+
+func TestOperation_Queued(t *testing.T) {
+	manager := operation.NewManager(zap.NewNop(), operation.WithWorkers(1))
+	resolver := newTestResolver(nil, new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop(),
+		WithOperationManager(manager))
+	release := make(chan struct{})
+	block := func(ctx context.Context, progress *operation.Progress) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-release:
+			return nil
+		}
+	}
+	running := manager.Start(context.Background(), "test", "user-1", block)
+	queued := manager.Start(context.Background(), "test", "user-1", block)
+
+	ctx := createReadOnlyContext("user-1")
+	op, err := resolver.Query().Operation(ctx, running.ID)
+	require.NoError(t, err)
+	assert.Equal(t, gql.OperationStatusRunning, op.Status)
+	assert.NotNil(t, op.StartedAt)
+	op, err = resolver.Query().Operation(ctx, queued.ID)
+	require.NoError(t, err)
+	assert.Equal(t, gql.OperationStatusQueued, op.Status)
+	assert.Nil(t, op.StartedAt)
+
+	close(release)
+	waitCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	finished, err := manager.Wait(waitCtx, queued.ID)
+	require.NoError(t, err)
+	assert.Equal(t, operation.StatusSucceeded, finished.Status)
+	assert.NotNil(t, finished.StartedAt)
+}
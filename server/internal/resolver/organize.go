@@ -0,0 +1,171 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cshum/imagor-studio/server/internal/filemeta"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/operation"
+	"github.com/cshum/imagor-studio/server/internal/organize"
+	"github.com/cshum/imagor-studio/server/pkg/space"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+// maxOrganizeFiles bounds the photos organized by one call
+const maxOrganizeFiles = 1000
+
+// OrganizeFiles is the resolver for the organizeFiles field.
+func (r *mutationResolver) OrganizeFiles(ctx context.Context, sourcePath string, template string, destFolder *string, dryRun *bool, spaceID *string) (*gql.OrganizeResult, error) {
+	sourcePath, err := ScopePath(ctx, sourcePath)
+	if err != nil {
+		return nil, err
+	}
+	sourcePath = strings.Trim(sourcePath, "/")
+	if err := RequireWritePermission(ctx, sourcePath); err != nil {
+		return nil, err
+	}
+	dest := sourcePath
+	if destFolder != nil {
+		dest, err = ScopePath(ctx, *destFolder)
+		if err != nil {
+			return nil, err
+		}
+		dest = strings.Trim(dest, "/")
+		if err := RequireWritePermission(ctx, dest); err != nil {
+			return nil, err
+		}
+	}
+	tmpl, err := organize.ParseTemplate(template)
+	if err != nil {
+		return nil, &gqlerror.Error{
+			Message:    err.Error(),
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	if r.imagorProvider == nil {
+		return nil, &gqlerror.Error{
+			Message:    "image processing is not available",
+			Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+		}
+	}
+	stor, sp, err := r.resolveUploadStorageTarget(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ensureSpaceUploadAllowed(sp); err != nil {
+		return nil, err
+	}
+	if sourcePath != "" {
+		info, err := stor.Stat(ctx, sourcePath)
+		if err != nil {
+			return nil, &gqlerror.Error{
+				Message:    fmt.Sprintf("folder not found: %s", sourcePath),
+				Extensions: map[string]interface{}{"code": "NOT_FOUND"},
+			}
+		}
+		if !info.IsDir {
+			return nil, &gqlerror.Error{
+				Message:    fmt.Sprintf("%s is not a folder", sourcePath),
+				Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+			}
+		}
+	}
+
+	moves, err := r.planOrganize(ctx, stor, sp, sourcePath, tmpl, dest)
+	if errors.Is(err, errSelectionLimit) {
+		return nil, &gqlerror.Error{
+			Message:    fmt.Sprintf("too many photos below %s: max %d, organize its folders one at a time", sourcePath, maxOrganizeFiles),
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	result := &gql.OrganizeResult{Moves: make([]*gql.OrganizeMove, len(moves))}
+	for i, move := range moves {
+		result.Moves[i] = &gql.OrganizeMove{SourcePath: move.Source, DestPath: move.Dest}
+	}
+	if dryRun == nil || *dryRun {
+		return result, nil
+	}
+
+	ownerID, _ := GetUserIDFromContext(ctx)
+	op := r.operations.Start(ctx, operationKindOrganizeFiles, ownerID, func(ctx context.Context, progress *operation.Progress) error {
+		return r.organizeFilesWithProgress(ctx, moves, spaceID, progress)
+	})
+	result.Operation = toGQLOperation(op)
+	return result, nil
+}
+
+// planOrganize plans moving the photos below source into the folders tmpl
+// names below dest, reading their metadata from imagor when not cached
+func (r *Resolver) planOrganize(ctx context.Context, stor storage.Storage, sp *space.Space, source string, tmpl organize.Template, dest string) ([]organize.Move, error) {
+	var photos []storage.FileInfo
+	var paths []string
+	if err := walkStorageFiles(ctx, stor, source, func(item storage.FileInfo) error {
+		if rel := "/" + strings.TrimPrefix(item.Path, source+"/"); strings.Contains(rel, "/.") {
+			return nil
+		}
+		paths = append(paths, item.Path)
+		if !filemeta.HasExif(item.Name) {
+			return nil
+		}
+		if len(photos) >= maxOrganizeFiles {
+			return errSelectionLimit
+		}
+		photos = append(photos, item)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	metadata := r.exifMetadata(ctx, sp, photos, len(photos))
+	videos := filemeta.PairLivePhotos(paths)
+	files := make([]organize.File, len(photos))
+	for i, photo := range photos {
+		files[i] = organize.File{Path: photo.Path, CapturedAt: photo.ModifiedTime, Video: videos[photo.Path]}
+		if m := metadata[photo.Path]; m != nil {
+			if t, ok := m.CaptureTimeValue(); ok {
+				files[i].CapturedAt = t
+			}
+			files[i].Make = m.CameraMake
+			files[i].Model = m.CameraModel
+		}
+	}
+	return organize.Plan(files, tmpl, dest, func(p string) bool {
+		_, err := stor.Stat(ctx, p)
+		return err == nil
+	}), nil
+}
+
+// organizeFilesWithProgress moves files one at a time like moveFile,
+// honouring cancellation between files. A file failing to move does not
+// stop the others; the operation fails at the end when any did.
+func (r *Resolver) organizeFilesWithProgress(ctx context.Context, moves []organize.Move, spaceID *string, progress *operation.Progress) error {
+	progress.SetTotal(len(moves))
+	progress.SetMessage("Organizing files")
+	mutation := &mutationResolver{r}
+	var failed int
+	for _, move := range moves {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if _, err := mutation.MoveFile(ctx, move.Source, move.Dest, spaceID); err != nil {
+			failed++
+			r.logger.Warn("Failed to organize file", zap.String("sourcePath", move.Source), zap.String("destPath", move.Dest), zap.Error(err))
+			progress.Advance(1)
+			continue
+		}
+		progress.Advance(1, move.Dest)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files failed to move", failed, len(moves))
+	}
+	progress.SetMessage(fmt.Sprintf("Moved %d files", len(moves)))
+	return nil
+}
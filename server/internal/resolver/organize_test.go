@@ -0,0 +1,137 @@
+package resolver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/operation"
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/cshum/imagor/imagorpath"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+// newOrganizeTestResolver serves the EXIF of the images at paths, keyed
+// by name so it follows files moved
+func newOrganizeTestResolver(t *testing.T, exif map[string]string, paths ...string) (*Resolver, *operation.Manager, string) {
+	t.Helper()
+	metaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"format":"jpeg","exif":` + exif[path.Base(r.URL.Query().Get("image"))] + `}`))
+	}))
+	t.Cleanup(metaServer.Close)
+
+	baseDir := t.TempDir()
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+	mockImagorProvider := new(MockImagorProvider)
+	mockImagorProvider.On("Imagor").Return((*imagor.Imagor)(nil))
+	for _, imagePath := range paths {
+		mockImagorProvider.On("GenerateURL", imagePath, imagorpath.Params{Meta: true}).Return(metaServer.URL+"?image="+url.QueryEscape(imagePath), nil)
+	}
+	manager := operation.NewManager(zap.NewNop())
+	resolver := newTestResolver(NewMockStorageProvider(stor), new(MockRegistryStore), new(MockUserStore),
+		mockImagorProvider, &config.Config{}, nil, zap.NewNop(),
+		WithOperationManager(manager),
+		WithFileMetaStore(&memoryFileMetaStore{entries: map[string]memoryFileMetaEntry{}}))
+	return resolver, manager, baseDir
+}
+
+func TestOrganizeFiles(t *testing.T) {
+	resolver, manager, baseDir := newOrganizeTestResolver(t, map[string]string{
+		"a.jpg":      `{"Make":"Canon","Model":"Canon EOS R5","DateTimeOriginal":"2024:05:01 12:00:00"}`,
+		"b.jpg":      `{"Make":"Apple","Model":"iPhone 15 Pro","DateTimeOriginal":"2023:12:31 23:30:00"}`,
+		"IMG_1.HEIC": `{"Make":"Apple","Model":"iPhone 15 Pro","DateTimeOriginal":"2024:05:02 08:00:00"}`,
+		"c.jpg":      `{"Make":"Canon","Model":"Canon EOS R5","DateTimeOriginal":"2024:05:03 12:00:00"}`,
+	},
+		"import/a.jpg", "import/sub/b.jpg", "import/IMG_1.HEIC", "import/2024/05/Canon EOS R5/c.jpg",
+		"import/2024/05/Canon EOS R5/a.jpg", "import/2023/12/Apple iPhone 15 Pro/b.jpg", "import/2024/05/Apple iPhone 15 Pro/IMG_1.HEIC",
+	)
+	for _, p := range []string{"import/a.jpg", "import/sub/b.jpg", "import/IMG_1.HEIC", "import/IMG_1.MOV", "import/notes.txt", "import/2024/05/Canon EOS R5/c.jpg"} {
+		writeTestFile(t, baseDir, p)
+	}
+	ctx := createReadWriteContext("user-1")
+
+	// Dry runs list the plan without moving anything
+	result, err := resolver.Mutation().OrganizeFiles(ctx, "import", "{year}/{month}/{camera}", nil, nil, nil)
+	require.NoError(t, err)
+	assert.Nil(t, result.Operation)
+	assert.Equal(t, []*gql.OrganizeMove{
+		{SourcePath: "import/IMG_1.HEIC", DestPath: "import/2024/05/Apple iPhone 15 Pro/IMG_1.HEIC"},
+		{SourcePath: "import/IMG_1.MOV", DestPath: "import/2024/05/Apple iPhone 15 Pro/IMG_1.MOV"},
+		{SourcePath: "import/a.jpg", DestPath: "import/2024/05/Canon EOS R5/a.jpg"},
+		{SourcePath: "import/sub/b.jpg", DestPath: "import/2023/12/Apple iPhone 15 Pro/b.jpg"},
+	}, result.Moves)
+	assert.FileExists(t, filepath.Join(baseDir, "import/a.jpg"))
+
+	result, err = resolver.Mutation().OrganizeFiles(ctx, "import", "{year}/{month}/{camera}", nil, boolPtr(false), nil)
+	require.NoError(t, err)
+	require.NotNil(t, result.Operation)
+	assert.Equal(t, operationKindOrganizeFiles, result.Operation.Kind)
+	waitCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	op, err := manager.Wait(waitCtx, result.Operation.ID)
+	require.NoError(t, err)
+	assert.Equal(t, operation.StatusSucceeded, op.Status)
+	assert.Equal(t, 4, op.Completed)
+	for _, move := range result.Moves {
+		assert.NoFileExists(t, filepath.Join(baseDir, move.SourcePath))
+		assert.FileExists(t, filepath.Join(baseDir, move.DestPath))
+	}
+	assert.FileExists(t, filepath.Join(baseDir, "import/notes.txt"))
+
+	// Organized photos stay in place
+	result, err = resolver.Mutation().OrganizeFiles(ctx, "import", "{year}/{month}/{camera}", nil, nil, nil)
+	require.NoError(t, err)
+	assert.Empty(t, result.Moves)
+}
+
+func TestOrganizeFiles_DestFolder(t *testing.T) {
+	resolver, _, baseDir := newOrganizeTestResolver(t, map[string]string{
+		"a.jpg": `{"DateTimeOriginal":"2024:05:01 12:00:00"}`,
+	}, "import/a.jpg")
+	writeTestFile(t, baseDir, "import/a.jpg")
+	writeTestFile(t, baseDir, "library/2024/a.jpg")
+
+	result, err := resolver.Mutation().OrganizeFiles(createReadWriteContext("user-1"), "import", "{year}", stringPtr("library"), nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []*gql.OrganizeMove{
+		{SourcePath: "import/a.jpg", DestPath: "library/2024/a (2).jpg"},
+	}, result.Moves)
+}
+
+func TestOrganizeFiles_InvalidInput(t *testing.T) {
+	resolver, _, baseDir := newOrganizeTestResolver(t, nil)
+	writeTestFile(t, baseDir, "import/a.jpg")
+	ctx := createReadWriteContext("user-1")
+
+	_, err := resolver.Mutation().OrganizeFiles(createReadOnlyContext("user-1"), "import", "{year}", nil, nil, nil)
+	assert.Error(t, err)
+
+	var gqlErr *gqlerror.Error
+	_, err = resolver.Mutation().OrganizeFiles(ctx, "import", "{year}/{lens}", nil, nil, nil)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+	_, err = resolver.Mutation().OrganizeFiles(ctx, "import/a.jpg", "{year}", nil, nil, nil)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+	_, err = resolver.Mutation().OrganizeFiles(ctx, "missing", "{year}", nil, nil, nil)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_FOUND", gqlErr.Extensions["code"])
+
+	withoutImagor := newTestResolver(nil, new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop())
+	_, err = withoutImagor.Mutation().OrganizeFiles(ctx, "import", "{year}", nil, nil, nil)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_AVAILABLE", gqlErr.Extensions["code"])
+}
@@ -0,0 +1,75 @@
+package resolver
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/pagination"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// requireAfterWithoutOffset rejects cursor listings combined with an offset,
+// offsets stay supported on their own until they are removed
+func requireAfterWithoutOffset(after *string, offset *int) error {
+	if after != nil && offset != nil && *offset > 0 {
+		return &gqlerror.Error{
+			Message:    "after cannot be combined with offset",
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	return nil
+}
+
+// decodeAfterKey decodes the after argument of a list ordered newest first,
+// nil for an empty after starting from the newest
+func decodeAfterKey(after string) (*pagination.Key, error) {
+	key, err := pagination.ParseKey(after)
+	if err != nil {
+		return nil, invalidListCursorError()
+	}
+	return key, nil
+}
+
+// keyEndCursor returns the endCursor of a page of a list ordered newest
+// first ending with the given entry, nil on the last page
+func keyEndCursor(more bool, createdAt time.Time, id string) *string {
+	if !more {
+		return nil
+	}
+	cursor := pagination.Key{CreatedAt: createdAt, ID: id}.Cursor()
+	return &cursor
+}
+
+// pageByKey returns the page of items with keys after the given one, in key
+// order, at most limit when set. Registry entries are paged by their keys,
+// which are unique and stable.
+func pageByKey[T any](items []T, key func(T) string, after *string, limit *int) ([]T, error) {
+	if limit != nil && *limit < 0 {
+		return nil, &gqlerror.Error{
+			Message:    "limit must not be negative",
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	if after == nil && limit == nil {
+		return items, nil
+	}
+	sorted := slices.Clone(items)
+	slices.SortStableFunc(sorted, func(a, b T) int {
+		return strings.Compare(key(a), key(b))
+	})
+	start := 0
+	if after != nil {
+		start, _ = slices.BinarySearchFunc(sorted, *after, func(item T, after string) int {
+			if key(item) <= after {
+				return -1
+			}
+			return 1
+		})
+	}
+	end := len(sorted)
+	if limit != nil {
+		end = min(start+*limit, end)
+	}
+	return sorted[start:end], nil
+}
@@ -0,0 +1,91 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/preferences"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// GetPreferences is the resolver for the getPreferences field.
+func (r *queryResolver) GetPreferences(ctx context.Context) (*gql.Preferences, error) {
+	userID, err := r.effectiveTargetUserID(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	p, err := preferences.Load(ctx, r.registryStore, userID)
+	if err != nil {
+		return nil, err
+	}
+	return toGQLPreferences(p), nil
+}
+
+// SetPreferences is the resolver for the setPreferences field.
+func (r *mutationResolver) SetPreferences(ctx context.Context, input gql.PreferencesInput, reset *bool) (*gql.Preferences, error) {
+	userID, err := r.effectiveTargetUserID(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	var p preferences.Preferences
+	if reset == nil || !*reset {
+		if p, err = preferences.Load(ctx, r.registryStore, userID); err != nil {
+			return nil, err
+		}
+	}
+	if input.Theme != nil {
+		p.Theme = input.Theme.String()
+	}
+	if input.SortBy != nil {
+		p.SortBy = input.SortBy.String()
+	}
+	if input.SortOrder != nil {
+		p.SortOrder = input.SortOrder.String()
+	}
+	if input.GridSize != nil {
+		p.GridSize = input.GridSize.String()
+	}
+	if input.SlideshowInterval != nil {
+		if err := preferences.ValidateSlideshowInterval(*input.SlideshowInterval); err != nil {
+			return nil, preferencesError(err)
+		}
+		p.SlideshowInterval = *input.SlideshowInterval
+	}
+	p, err = preferences.Save(ctx, r.registryStore, userID, p)
+	if err != nil {
+		return nil, preferencesError(err)
+	}
+	return toGQLPreferences(p), nil
+}
+
+func preferencesError(err error) error {
+	if errors.Is(err, preferences.ErrInvalid) {
+		return &gqlerror.Error{
+			Message:    err.Error(),
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	return err
+}
+
+func toGQLPreferences(p preferences.Preferences) *gql.Preferences {
+	result := &gql.Preferences{}
+	if theme := gql.Theme(p.Theme); theme.IsValid() {
+		result.Theme = &theme
+	}
+	if sortBy := gql.SortOption(p.SortBy); sortBy.IsValid() {
+		result.SortBy = &sortBy
+	}
+	if sortOrder := gql.SortOrder(p.SortOrder); sortOrder.IsValid() {
+		result.SortOrder = &sortOrder
+	}
+	if gridSize := gql.GridSize(p.GridSize); gridSize.IsValid() {
+		result.GridSize = &gridSize
+	}
+	if p.SlideshowInterval != 0 {
+		interval := p.SlideshowInterval
+		result.SlideshowInterval = &interval
+	}
+	return result
+}
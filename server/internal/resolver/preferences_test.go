@@ -0,0 +1,107 @@
+package resolver
+
+import (
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/preferences"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func TestGetPreferences(t *testing.T) {
+	mockRegistryStore := new(MockRegistryStore)
+	resolver := newTestResolver(nil, mockRegistryStore, new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop())
+	ctx := createReadOnlyContext("user-1")
+
+	mockRegistryStore.On("Get", ctx, "user:user-1", preferences.RegistryKey).Return(&registrystore.Registry{
+		Key:   preferences.RegistryKey,
+		Value: `{"theme":"DARK","gridSize":"HUGE","slideshowInterval":5}`,
+	}, nil)
+
+	result, err := resolver.Query().GetPreferences(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, result.Theme)
+	assert.Equal(t, gql.ThemeDark, *result.Theme)
+	assert.Nil(t, result.GridSize)
+	assert.Nil(t, result.SortBy)
+	require.NotNil(t, result.SlideshowInterval)
+	assert.Equal(t, 5, *result.SlideshowInterval)
+}
+
+func TestSetPreferences_Merge(t *testing.T) {
+	mockRegistryStore := new(MockRegistryStore)
+	resolver := newTestResolver(nil, mockRegistryStore, new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop())
+	ctx := createReadOnlyContext("user-1")
+
+	mockRegistryStore.On("Get", ctx, "user:user-1", preferences.RegistryKey).Return(&registrystore.Registry{
+		Key:   preferences.RegistryKey,
+		Value: `{"theme":"DARK","slideshowInterval":5}`,
+	}, nil)
+	mockRegistryStore.On("Set", ctx, "user:user-1", preferences.RegistryKey,
+		`{"theme":"DARK","sortBy":"RATING","sortOrder":"DESC","slideshowInterval":5}`, false).
+		Return(&registrystore.Registry{}, nil)
+
+	sortBy, sortOrder := gql.SortOptionRating, gql.SortOrderDesc
+	result, err := resolver.Mutation().SetPreferences(ctx, gql.PreferencesInput{SortBy: &sortBy, SortOrder: &sortOrder}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, gql.ThemeDark, *result.Theme)
+	assert.Equal(t, gql.SortOptionRating, *result.SortBy)
+	mockRegistryStore.AssertExpectations(t)
+}
+
+func TestSetPreferences_Reset(t *testing.T) {
+	mockRegistryStore := new(MockRegistryStore)
+	resolver := newTestResolver(nil, mockRegistryStore, new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop())
+	ctx := createReadOnlyContext("user-1")
+	reset := true
+
+	mockRegistryStore.On("Set", ctx, "user:user-1", preferences.RegistryKey, `{"gridSize":"LARGE"}`, false).
+		Return(&registrystore.Registry{}, nil)
+	gridSize := gql.GridSizeLarge
+	result, err := resolver.Mutation().SetPreferences(ctx, gql.PreferencesInput{GridSize: &gridSize}, &reset)
+	require.NoError(t, err)
+	assert.Nil(t, result.Theme)
+	assert.Equal(t, gql.GridSizeLarge, *result.GridSize)
+
+	mockRegistryStore.On("Delete", ctx, "user:user-1", preferences.RegistryKey).Return(nil)
+	result, err = resolver.Mutation().SetPreferences(ctx, gql.PreferencesInput{}, &reset)
+	require.NoError(t, err)
+	assert.Equal(t, &gql.Preferences{}, result)
+	mockRegistryStore.AssertExpectations(t)
+	mockRegistryStore.AssertNotCalled(t, "Get", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestSetPreferences_InvalidSlideshowInterval(t *testing.T) {
+	mockRegistryStore := new(MockRegistryStore)
+	resolver := newTestResolver(nil, mockRegistryStore, new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop())
+	ctx := createReadOnlyContext("user-1")
+
+	mockRegistryStore.On("Get", ctx, "user:user-1", preferences.RegistryKey).Return(nil, nil)
+
+	for _, interval := range []int{0, preferences.MaxSlideshowInterval + 1} {
+		_, err := resolver.Mutation().SetPreferences(ctx, gql.PreferencesInput{SlideshowInterval: &interval}, nil)
+		var gqlErr *gqlerror.Error
+		require.ErrorAs(t, err, &gqlErr)
+		assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+	}
+	mockRegistryStore.AssertNotCalled(t, "Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestSetUserRegistry_PreferencesKeyReserved(t *testing.T) {
+	mockRegistryStore := new(MockRegistryStore)
+	resolver := newTestResolver(nil, mockRegistryStore, new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop())
+	ctx := createReadWriteContext("user-1")
+
+	entries := []*gql.RegistryEntryInput{{Key: preferences.RegistryKey, Value: `{"theme":"PINK"}`}}
+	_, err := resolver.Mutation().SetUserRegistry(ctx, nil, entries, nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+	mockRegistryStore.AssertNotCalled(t, "SetMulti", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,48 @@
+package resolver
+
+import (
+	"context"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/jobqueue"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// ProcessingQueue is the resolver for the processingQueue field.
+func (r *queryResolver) ProcessingQueue(ctx context.Context) (*gql.ProcessingQueueStatus, error) {
+	if err := RequireAdminPermission(ctx); err != nil {
+		return nil, err
+	}
+	if r.processingScheduler == nil {
+		return nil, &gqlerror.Error{
+			Message:    "processing queue is not available on this server",
+			Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+		}
+	}
+	return toGQLProcessingQueueStatus(r.processingScheduler.Stats()), nil
+}
+
+func toGQLProcessingQueueStatus(stats jobqueue.Stats) *gql.ProcessingQueueStatus {
+	classes := make([]*gql.ProcessingQueueClass, 0, len(stats.Classes))
+	for _, cs := range stats.Classes {
+		classes = append(classes, &gql.ProcessingQueueClass{
+			Class:         cs.Class.String(),
+			Waiting:       cs.Waiting,
+			Running:       cs.Running,
+			Started:       int(cs.Started),
+			Cancelled:     int(cs.Cancelled),
+			AverageWaitMs: durationMillis(cs.AverageWait()),
+			MaxWaitMs:     durationMillis(cs.MaxWait),
+		})
+	}
+	return &gql.ProcessingQueueStatus{
+		Slots:         stats.Slots,
+		ReservedSlots: stats.ReservedSlots,
+		Classes:       classes,
+	}
+}
+
+func durationMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
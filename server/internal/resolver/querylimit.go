@@ -0,0 +1,59 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/querylimit"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+// QueryLimits is the resolver for the queryLimits field.
+func (r *queryResolver) QueryLimits(ctx context.Context) (*gql.QueryLimits, error) {
+	if err := r.requireQueryLimits(ctx); err != nil {
+		return nil, err
+	}
+	return toGQLQueryLimits(r.queryLimits.Get()), nil
+}
+
+// SetQueryLimits is the resolver for the setQueryLimits field.
+func (r *mutationResolver) SetQueryLimits(ctx context.Context, maxComplexity int, maxDepth int, timeoutSeconds int) (*gql.QueryLimits, error) {
+	if err := r.requireQueryLimits(ctx); err != nil {
+		return nil, err
+	}
+	limits := querylimit.Limits{MaxComplexity: maxComplexity, MaxDepth: maxDepth, TimeoutSeconds: timeoutSeconds}
+	if err := r.queryLimits.Set(ctx, limits); err != nil {
+		if errors.Is(err, querylimit.ErrNegativeLimit) {
+			return nil, &gqlerror.Error{
+				Message:    err.Error(),
+				Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+			}
+		}
+		r.logger.Error("Failed to set GraphQL query limits", zap.Error(err))
+		return nil, err
+	}
+	return toGQLQueryLimits(limits), nil
+}
+
+func (r *Resolver) requireQueryLimits(ctx context.Context) error {
+	if err := RequireAdminPermission(ctx); err != nil {
+		return err
+	}
+	if r.queryLimits == nil {
+		return &gqlerror.Error{
+			Message:    "query limits are not available on this server",
+			Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+		}
+	}
+	return nil
+}
+
+func toGQLQueryLimits(limits querylimit.Limits) *gql.QueryLimits {
+	return &gql.QueryLimits{
+		MaxComplexity:  limits.MaxComplexity,
+		MaxDepth:       limits.MaxDepth,
+		TimeoutSeconds: limits.TimeoutSeconds,
+	}
+}
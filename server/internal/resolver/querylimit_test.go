@@ -0,0 +1,49 @@
+package resolver
+
+import (
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/querylimit"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func TestQueryLimits(t *testing.T) {
+	mockRegistryStore := new(MockRegistryStore)
+	store := querylimit.New(mockRegistryStore, zap.NewNop())
+	resolver := newTestResolver(nil, mockRegistryStore, nil, nil, nil, nil, zap.NewNop(), WithQueryLimits(store))
+	ctx := createAdminContext("admin-1")
+
+	_, err := resolver.Query().QueryLimits(createReadWriteContext("user-1"))
+	assert.Error(t, err)
+
+	limits, err := resolver.Query().QueryLimits(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, limits.MaxComplexity)
+
+	mockRegistryStore.On("Set", ctx, registrystore.SystemOwnerID, querylimit.Key, `{"maxComplexity":500,"maxDepth":10,"timeoutSeconds":30}`, false).
+		Return(&registrystore.Registry{}, nil)
+	limits, err = resolver.Mutation().SetQueryLimits(ctx, 500, 10, 30)
+	require.NoError(t, err)
+	assert.Equal(t, 500, limits.MaxComplexity)
+	assert.Equal(t, 10, limits.MaxDepth)
+	assert.Equal(t, 30, limits.TimeoutSeconds)
+	assert.Equal(t, querylimit.Limits{MaxComplexity: 500, MaxDepth: 10, TimeoutSeconds: 30}, store.Get())
+
+	var gqlErr *gqlerror.Error
+	_, err = resolver.Mutation().SetQueryLimits(ctx, 500, -1, 30)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+	mockRegistryStore.AssertExpectations(t)
+}
+
+func TestQueryLimits_NotAvailable(t *testing.T) {
+	resolver := newTestResolver(nil, nil, nil, nil, nil, nil, zap.NewNop())
+	_, err := resolver.Query().QueryLimits(createAdminContext("admin-1"))
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_AVAILABLE", gqlErr.Extensions["code"])
+}
@@ -0,0 +1,150 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cshum/imagor-studio/server/internal/ratingstore"
+	"github.com/cshum/imagor-studio/server/pkg/space"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func ratingsNotAvailableError() error {
+	return &gqlerror.Error{
+		Message:    "ratings are not available",
+		Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+	}
+}
+
+// RateFile is the resolver for the rateFile field.
+func (r *mutationResolver) RateFile(ctx context.Context, path string, rating int, spaceID *string) (int, error) {
+	path, err := ScopePath(ctx, path)
+	if err != nil {
+		return 0, err
+	}
+	path = strings.Trim(path, "/")
+	if err := RequireReadPermission(ctx, path); err != nil {
+		return 0, err
+	}
+	if r.ratingStore == nil {
+		return 0, ratingsNotAvailableError()
+	}
+	if IsGuestUser(ctx) {
+		return 0, fmt.Errorf("guest users cannot rate files")
+	}
+	userID, err := GetUserIDFromContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	sp, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err != nil {
+		return 0, err
+	}
+	// Clearing works for paths deleted meanwhile
+	if rating != 0 {
+		stor, err := r.getSpaceStorageByID(ctx, spaceID)
+		if err != nil {
+			return 0, err
+		}
+		info, err := stor.Stat(ctx, path)
+		if err != nil {
+			return 0, &gqlerror.Error{
+				Message:    fmt.Sprintf("file not found: %s", path),
+				Extensions: map[string]interface{}{"code": "NOT_FOUND"},
+			}
+		}
+		if info.IsDir {
+			return 0, &gqlerror.Error{
+				Message:    "folders cannot be rated",
+				Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+			}
+		}
+	}
+	if err := r.ratingStore.Set(ctx, userID, fileMetadataScope(sp), path, rating); err != nil {
+		if errors.Is(err, ratingstore.ErrInvalid) {
+			return 0, &gqlerror.Error{
+				Message:    err.Error(),
+				Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+			}
+		}
+		return 0, err
+	}
+	return rating, nil
+}
+
+// fileRatings returns the ratings the current user gave paths. Guests have
+// none.
+func (r *Resolver) fileRatings(ctx context.Context, spaceConfig *space.Space, paths []string) (map[string]int, error) {
+	if r.ratingStore == nil || len(paths) == 0 || IsGuestUser(ctx) {
+		return nil, nil
+	}
+	userID, err := GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, nil
+	}
+	return r.ratingStore.GetMulti(ctx, userID, fileMetadataScope(spaceConfig), paths)
+}
+
+// sortByRating orders listed items in place by rating, folders first and
+// ties by name
+func sortByRating(items []storage.FileInfo, tags [][]string, ratings map[string]int, order storage.SortOrder) {
+	type entry struct {
+		item storage.FileInfo
+		tags []string
+	}
+	entries := make([]entry, len(items))
+	for i, item := range items {
+		entries[i] = entry{item: item, tags: tags[i]}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.item.IsDir != b.item.IsDir {
+			return a.item.IsDir
+		}
+		if ra, rb := ratings[a.item.Path], ratings[b.item.Path]; !a.item.IsDir && ra != rb {
+			if order == storage.SortOrderDesc {
+				return ra > rb
+			}
+			return ra < rb
+		}
+		return a.item.Name < b.item.Name
+	})
+	for i, e := range entries {
+		items[i] = e.item
+		tags[i] = e.tags
+	}
+}
+
+// moveRatings keeps ratings following a moved file or folder. Failures are
+// logged rather than failing the move that already happened.
+func (r *Resolver) moveRatings(ctx context.Context, spaceID *string, sourcePath, destPath string) {
+	if r.ratingStore == nil {
+		return
+	}
+	spaceConfig, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err == nil {
+		err = r.ratingStore.MoveFilePath(ctx, fileMetadataScope(spaceConfig), strings.Trim(sourcePath, "/"), strings.Trim(destPath, "/"))
+	}
+	if err != nil {
+		r.logger.Warn("Failed to move ratings", zap.String("source", sourcePath), zap.String("dest", destPath), zap.Error(err))
+	}
+}
+
+// removeRatings drops ratings of a deleted file or folder
+func (r *Resolver) removeRatings(ctx context.Context, spaceID *string, path string) {
+	if r.ratingStore == nil {
+		return
+	}
+	spaceConfig, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err == nil {
+		err = r.ratingStore.RemoveFilePath(ctx, fileMetadataScope(spaceConfig), strings.Trim(path, "/"))
+	}
+	if err != nil {
+		r.logger.Warn("Failed to remove ratings", zap.String("path", path), zap.Error(err))
+	}
+}
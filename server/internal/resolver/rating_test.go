@@ -0,0 +1,141 @@
+package resolver
+
+import (
+	"testing"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/ratingstore"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func newRatingTestResolver(t *testing.T) *Resolver {
+	t.Helper()
+	db := testutil.NewDB(t)
+
+	baseDir := t.TempDir()
+	writeTestFile(t, baseDir, "shoot/a.jpg")
+	writeTestFile(t, baseDir, "shoot/b.jpg")
+	writeTestFile(t, baseDir, "shoot/c.jpg")
+	writeTestFile(t, baseDir, "shoot/raw/d.jpg")
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+	mockImagorProvider := new(MockImagorProvider)
+	mockImagorProvider.On("Imagor").Return((*imagor.Imagor)(nil))
+	mockImagorProvider.On("GenerateURL", mock.Anything, mock.Anything).Return("/imagor/thumbnail.webp", nil)
+	mockRegistryStore := new(MockRegistryStore)
+	mockRegistryStore.On("GetMulti", mock.Anything, "system:global", mock.Anything).Return([]*registrystore.Registry{}, nil)
+	logger := zap.NewNop()
+	return newTestResolver(NewMockStorageProvider(stor), mockRegistryStore, new(MockUserStore), mockImagorProvider, &config.Config{}, nil, logger,
+		WithRatingStore(ratingstore.New(db, logger)))
+}
+
+func listedNames(list *gql.FileList) []string {
+	names := make([]string, len(list.Items))
+	for i, item := range list.Items {
+		names[i] = item.Name
+	}
+	return names
+}
+
+func TestRateFile(t *testing.T) {
+	resolver := newRatingTestResolver(t)
+	ctx := createReadOnlyContext("user-1")
+	var gqlErr *gqlerror.Error
+
+	rating, err := resolver.Mutation().RateFile(ctx, "/shoot/a.jpg", 4, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 4, rating)
+
+	_, err = resolver.Mutation().RateFile(ctx, "shoot/a.jpg", 6, nil)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+	_, err = resolver.Mutation().RateFile(ctx, "shoot/raw", 3, nil)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+	_, err = resolver.Mutation().RateFile(ctx, "shoot/missing.jpg", 3, nil)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_FOUND", gqlErr.Extensions["code"])
+	_, err = resolver.Mutation().RateFile(createGuestContext("guest-1"), "shoot/a.jpg", 3, nil)
+	assert.Error(t, err)
+
+	// Ratings are per user
+	_, err = resolver.Mutation().RateFile(createReadOnlyContext("user-2"), "shoot/b.jpg", 5, nil)
+	require.NoError(t, err)
+	list, err := resolver.Query().ListFiles(ctx, "shoot", nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	require.NoError(t, err)
+	for _, item := range list.Items {
+		if item.Name == "a.jpg" {
+			require.NotNil(t, item.Rating)
+			assert.Equal(t, 4, *item.Rating)
+		} else {
+			assert.Nil(t, item.Rating, item.Name)
+		}
+	}
+
+	cleared, err := resolver.Mutation().RateFile(ctx, "shoot/a.jpg", 0, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 0, cleared)
+}
+
+func TestListFiles_MinRatingAndRatingSort(t *testing.T) {
+	resolver := newRatingTestResolver(t)
+	ctx := createReadWriteContext("user-1")
+	for path, rating := range map[string]int{"shoot/a.jpg": 2, "shoot/b.jpg": 5, "shoot/c.jpg": 4} {
+		_, err := resolver.Mutation().RateFile(ctx, path, rating, nil)
+		require.NoError(t, err)
+	}
+
+	minRating := 4
+	list, err := resolver.Query().ListFiles(ctx, "shoot", nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, &minRating, nil, nil)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"raw", "b.jpg", "c.jpg"}, listedNames(list))
+	assert.Equal(t, 3, list.TotalCount)
+
+	sortBy, sortOrder := gql.SortOptionRating, gql.SortOrderDesc
+	list, err = resolver.Query().ListFiles(ctx, "shoot", nil, nil, nil, nil, nil, nil, nil, &sortBy, &sortOrder, nil, nil, nil, nil, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"raw", "b.jpg", "c.jpg", "a.jpg"}, listedNames(list))
+
+	limit, onlyFiles := 1, true
+	list, err = resolver.Query().ListFiles(ctx, "shoot", nil, nil, &limit, &onlyFiles, nil, nil, nil, &sortBy, &sortOrder, nil, nil, nil, &minRating, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b.jpg"}, listedNames(list))
+	assert.Equal(t, 2, list.TotalCount)
+
+	invalid := 0
+	_, err = resolver.Query().ListFiles(ctx, "shoot", nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, &invalid, nil, nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+}
+
+func TestRatings_FollowMovedAndDeletedFiles(t *testing.T) {
+	resolver := newRatingTestResolver(t)
+	ctx := createReadWriteContext("user-1")
+
+	_, err := resolver.Mutation().RateFile(ctx, "shoot/a.jpg", 5, nil)
+	require.NoError(t, err)
+	_, err = resolver.Mutation().MoveFile(ctx, "shoot", "picks", nil)
+	require.NoError(t, err)
+
+	minRating := 5
+	onlyFiles := true
+	list, err := resolver.Query().ListFiles(ctx, "picks", nil, nil, nil, &onlyFiles, nil, nil, nil, nil, nil, nil, nil, nil, &minRating, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.jpg"}, listedNames(list))
+
+	_, err = resolver.Mutation().DeleteFile(ctx, "picks/a.jpg", nil)
+	require.NoError(t, err)
+	list, err = resolver.Query().ListFiles(ctx, "picks", nil, nil, nil, &onlyFiles, nil, nil, nil, nil, nil, nil, nil, nil, &minRating, nil, nil)
+	require.NoError(t, err)
+	assert.Empty(t, list.Items)
+}
@@ -3,9 +3,13 @@ package resolver
 import (
 	"context"
 	"fmt"
+	"slices"
 
 	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/notify"
+	"github.com/cshum/imagor-studio/server/internal/preferences"
 	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/vektah/gqlparser/v2/gqlerror"
 )
 
 // licenseRequiredRegistryKeys contains registry keys that require a valid license.
@@ -16,6 +20,12 @@ var licenseRequiredRegistryKeys = map[string]bool{
 	"config.app_url":   true,
 }
 
+// isCredentialRegistryKey reports whether key holds a credential, stored
+// encrypted whatever the isEncrypted input says
+func isCredentialRegistryKey(key string) bool {
+	return slices.Contains(notify.CredentialKeys, key)
+}
+
 var publicSpaceRegistryKeys = map[string]bool{
 	"config.allow_guest_mode":       true,
 	"config.app_default_language":   true,
@@ -56,7 +66,7 @@ func (r *mutationResolver) SetUserRegistry(ctx context.Context, entry *gql.Regis
 		return nil, fmt.Errorf("exactly one of 'entry' or 'entries' must be provided")
 	}
 
-	effectiveUserID, err := GetEffectiveTargetUserID(ctx, ownerID)
+	effectiveUserID, err := r.effectiveTargetUserID(ctx, ownerID)
 	if err != nil {
 		return nil, err
 	}
@@ -85,6 +95,16 @@ func (r *mutationResolver) SetUserRegistry(ctx context.Context, entry *gql.Regis
 		}
 	}
 
+	// Preferences are only written through setPreferences, which validates them
+	for _, e := range registryEntries {
+		if e.Key == preferences.RegistryKey {
+			return nil, &gqlerror.Error{
+				Message:    fmt.Sprintf("cannot set registry key '%s': use setPreferences", e.Key),
+				Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+			}
+		}
+	}
+
 	// Use SetMulti for better performance
 	registries, err := r.registryStore.SetMulti(ctx, effectiveOwnerID, registryEntries)
 	if err != nil {
@@ -155,7 +175,7 @@ func (r *mutationResolver) DeleteUserRegistry(ctx context.Context, key *string,
 		return false, fmt.Errorf("exactly one of 'key' or 'keys' must be provided")
 	}
 
-	effectiveUserID, err := GetEffectiveTargetUserID(ctx, ownerID)
+	effectiveUserID, err := r.effectiveTargetUserID(ctx, ownerID)
 	if err != nil {
 		return false, err
 	}
@@ -181,8 +201,8 @@ func (r *mutationResolver) DeleteUserRegistry(ctx context.Context, key *string,
 }
 
 // ListUserRegistry lists user-specific registry
-func (r *queryResolver) ListUserRegistry(ctx context.Context, prefix *string, ownerID *string) ([]*gql.UserRegistry, error) {
-	effectiveUserID, err := GetEffectiveTargetUserID(ctx, ownerID)
+func (r *queryResolver) ListUserRegistry(ctx context.Context, prefix *string, ownerID *string, after *string, limit *int) ([]*gql.UserRegistry, error) {
+	effectiveUserID, err := r.effectiveTargetUserID(ctx, ownerID)
 	if err != nil {
 		return nil, err
 	}
@@ -209,7 +229,7 @@ func (r *queryResolver) ListUserRegistry(ctx context.Context, prefix *string, ow
 			IsEncrypted: registry.IsEncrypted,
 		}
 	}
-	return result, nil
+	return pageByKey(result, func(entry *gql.UserRegistry) string { return entry.Key }, after, limit)
 }
 
 // GetUserRegistry gets specific user registry (unified flexible API)
@@ -219,7 +239,7 @@ func (r *queryResolver) GetUserRegistry(ctx context.Context, key *string, keys [
 		return nil, fmt.Errorf("exactly one of 'key' or 'keys' must be provided")
 	}
 
-	effectiveUserID, err := GetEffectiveTargetUserID(ctx, ownerID)
+	effectiveUserID, err := r.effectiveTargetUserID(ctx, ownerID)
 	if err != nil {
 		return nil, err
 	}
@@ -272,8 +292,9 @@ func (r *mutationResolver) SetSystemRegistry(ctx context.Context, entry *gql.Reg
 		return nil, fmt.Errorf("exactly one of 'entry' or 'entries' must be provided")
 	}
 
-	// Only admins can write system registry
-	if err := RequireAdminPermission(ctx); err != nil {
+	// Only admins can write system registry, tenant admins write the
+	// settings of their tenant instead
+	if err := RequireTenantAdminPermission(ctx); err != nil {
 		return nil, fmt.Errorf("admin permission required for system registry write: %w", err)
 	}
 
@@ -285,6 +306,16 @@ func (r *mutationResolver) SetSystemRegistry(ctx context.Context, entry *gql.Reg
 		allEntries = entries
 	}
 
+	ownerID := registrystore.SystemOwnerID
+	if tenantID := GetTenantIDFromContext(ctx); tenantID != "" {
+		for _, e := range allEntries {
+			if !tenantRegistryKeys[e.Key] {
+				return nil, fmt.Errorf("cannot set registry key '%s': only server admins can change this setting", e.Key)
+			}
+		}
+		ownerID = registrystore.TenantOwnerID(tenantID)
+	}
+
 	// Reject writes for license-required keys when the instance is unlicensed
 	for _, e := range allEntries {
 		if licenseRequiredRegistryKeys[e.Key] {
@@ -309,12 +340,12 @@ func (r *mutationResolver) SetSystemRegistry(ctx context.Context, entry *gql.Reg
 		registryEntries = append(registryEntries, &registrystore.Registry{
 			Key:         e.Key,
 			Value:       e.Value,
-			IsEncrypted: e.IsEncrypted,
+			IsEncrypted: e.IsEncrypted || isCredentialRegistryKey(e.Key),
 		})
 	}
 
 	// Use SetMulti for better performance
-	registries, err := r.registryStore.SetMulti(ctx, registrystore.SystemOwnerID, registryEntries)
+	registries, err := r.registryStore.SetMulti(ctx, ownerID, registryEntries)
 	if err != nil {
 		return nil, fmt.Errorf("failed to set system registry: %w", err)
 	}
@@ -354,20 +385,26 @@ func (r *mutationResolver) DeleteSystemRegistry(ctx context.Context, key *string
 		return false, fmt.Errorf("exactly one of 'key' or 'keys' must be provided")
 	}
 
-	// Only admins can delete system registry
-	if err := RequireAdminPermission(ctx); err != nil {
+	// Only admins can delete system registry, tenant admins delete the
+	// settings of their tenant instead
+	if err := RequireTenantAdminPermission(ctx); err != nil {
 		return false, fmt.Errorf("admin permission required for system registry delete: %w", err)
 	}
 
+	ownerID := registrystore.SystemOwnerID
+	if tenantID := GetTenantIDFromContext(ctx); tenantID != "" {
+		ownerID = registrystore.TenantOwnerID(tenantID)
+	}
+
 	if key != nil {
 		// Single key operation
-		err := r.registryStore.Delete(ctx, registrystore.SystemOwnerID, *key)
+		err := r.registryStore.Delete(ctx, ownerID, *key)
 		if err != nil {
 			return false, fmt.Errorf("failed to delete system registry: %w", err)
 		}
 	} else {
 		// Multi key operation
-		err := r.registryStore.DeleteMulti(ctx, registrystore.SystemOwnerID, keys)
+		err := r.registryStore.DeleteMulti(ctx, ownerID, keys)
 		if err != nil {
 			return false, fmt.Errorf("failed to delete system registries: %w", err)
 		}
@@ -377,7 +414,7 @@ func (r *mutationResolver) DeleteSystemRegistry(ctx context.Context, key *string
 }
 
 // ListSystemRegistry lists system-wide registry (open read access)
-func (r *queryResolver) ListSystemRegistry(ctx context.Context, prefix *string) ([]*gql.SystemRegistry, error) {
+func (r *queryResolver) ListSystemRegistry(ctx context.Context, prefix *string, after *string, limit *int) ([]*gql.SystemRegistry, error) {
 	// All authenticated users can read system registry
 	// No additional permission check needed
 
@@ -390,6 +427,13 @@ func (r *queryResolver) ListSystemRegistry(ctx context.Context, prefix *string)
 		if err != nil {
 			return nil, fmt.Errorf("failed to list system registry: %w", err)
 		}
+		if tenantID := GetTenantIDFromContext(ctx); tenantID != "" {
+			tenantList, err := r.registryStore.List(ctx, registrystore.TenantOwnerID(tenantID), prefix)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list tenant registry: %w", err)
+			}
+			registryList = overlayTenantRegistry(registryList, tenantList)
+		}
 	}
 	// If embedded mode, registryList stays empty []
 
@@ -441,7 +485,7 @@ func (r *queryResolver) ListSystemRegistry(ctx context.Context, prefix *string)
 	// we'll just return the empty result. Config values will be available via GetSystemRegistry
 	// when specifically requested.
 
-	return result, nil
+	return pageByKey(result, func(entry *gql.SystemRegistry) string { return entry.Key }, after, limit)
 }
 
 // SpaceRegistry gets space-scoped registry entries, falling back to system:global for unset keys (space manager only)
@@ -669,6 +713,17 @@ func (r *queryResolver) GetSystemRegistry(ctx context.Context, key *string, keys
 				return nil, fmt.Errorf("failed to get system registries: %w", err)
 			}
 		}
+		if tenantID := GetTenantIDFromContext(ctx); tenantID != "" {
+			tenantKeys := keys
+			if key != nil {
+				tenantKeys = []string{*key}
+			}
+			tenantRegistries, err := r.registryStore.GetMulti(ctx, registrystore.TenantOwnerID(tenantID), tenantKeys)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get tenant registries: %w", err)
+			}
+			registries = overlayTenantRegistry(registries, tenantRegistries)
+		}
 	}
 	// If embedded mode, registries stays empty []
 
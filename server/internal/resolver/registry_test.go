@@ -10,6 +10,7 @@ import (
 	"github.com/cshum/imagor-studio/server/internal/registrystore"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 )
 
@@ -240,7 +241,7 @@ func TestListUserRegistry_SelfOperation(t *testing.T) {
 
 	mockRegistryStore.On("List", ctx, "user:test-user-id", &prefix).Return(mockRegistry, nil)
 
-	result, err := resolver.Query().ListUserRegistry(ctx, &prefix, nil)
+	result, err := resolver.Query().ListUserRegistry(ctx, &prefix, nil, nil, nil)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, result)
@@ -426,7 +427,7 @@ func TestListSystemRegistry_OpenRead(t *testing.T) {
 
 	mockRegistryStore.On("List", ctx, "system:global", &prefix).Return(mockRegistry, nil)
 
-	result, err := resolver.Query().ListSystemRegistry(ctx, &prefix)
+	result, err := resolver.Query().ListSystemRegistry(ctx, &prefix, nil, nil)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, result)
@@ -437,6 +438,40 @@ func TestListSystemRegistry_OpenRead(t *testing.T) {
 	mockRegistryStore.AssertExpectations(t)
 }
 
+func TestListSystemRegistry_Paging(t *testing.T) {
+	mockRegistryStore := new(MockRegistryStore)
+	resolver := newTestResolver(nil, mockRegistryStore, new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop())
+	ctx := createReadWriteContext("user-id")
+
+	mockRegistryStore.On("List", ctx, "system:global", (*string)(nil)).Return([]*registrystore.Registry{
+		{Key: "config:a", Value: "1"},
+		{Key: "config:b", Value: "2"},
+		{Key: "config:c", Value: "3"},
+	}, nil)
+
+	keys := func(result []*gql.SystemRegistry) []string {
+		keys := make([]string, len(result))
+		for i, entry := range result {
+			keys[i] = entry.Key
+		}
+		return keys
+	}
+	result, err := resolver.Query().ListSystemRegistry(ctx, nil, nil, intPtr(2))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"config:a", "config:b"}, keys(result))
+
+	// after is the key of the last entry, which need not exist anymore
+	result, err = resolver.Query().ListSystemRegistry(ctx, nil, stringPtr("config:b"), intPtr(2))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"config:c"}, keys(result))
+	result, err = resolver.Query().ListSystemRegistry(ctx, nil, stringPtr("config:aa"), nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"config:b", "config:c"}, keys(result))
+
+	_, err = resolver.Query().ListSystemRegistry(ctx, nil, nil, intPtr(-1))
+	assert.Error(t, err)
+}
+
 func TestDeleteSystemRegistry_AdminOnly(t *testing.T) {
 	mockStorage := new(MockStorage)
 	mockRegistryStore := new(MockRegistryStore)
@@ -548,7 +583,7 @@ func TestUserRegistry_PermissionEdgeCases(t *testing.T) {
 				mockRegistryStore.On("List", ctx, "user:"+expectedOwnerID, (*string)(nil)).Return([]*registrystore.Registry{}, nil)
 			}
 
-			result, err := resolver.Query().ListUserRegistry(ctx, nil, tt.ownerID)
+			result, err := resolver.Query().ListUserRegistry(ctx, nil, tt.ownerID, nil, nil)
 
 			if tt.expectError {
 				assert.Error(t, err)
@@ -653,7 +688,7 @@ func TestListUserRegistry_EncryptedValueHidden(t *testing.T) {
 
 	mockRegistryStore.On("List", ctx, "user:test-user-id", (*string)(nil)).Return(mockRegistry, nil)
 
-	result, err := resolver.Query().ListUserRegistry(ctx, nil, nil)
+	result, err := resolver.Query().ListUserRegistry(ctx, nil, nil, nil, nil)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, result)
@@ -926,7 +961,7 @@ func TestListSystemRegistry_OverrideDetection(t *testing.T) {
 
 	mockRegistryStore.On("List", ctx, "system:global", (*string)(nil)).Return(mockRegistries, nil)
 
-	result, err := resolver.Query().ListSystemRegistry(ctx, nil)
+	result, err := resolver.Query().ListSystemRegistry(ctx, nil, nil, nil)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, result)
@@ -1265,7 +1300,7 @@ func TestListSystemRegistry_LicenseRequired_OmittedWhenUnlicensed(t *testing.T)
 	}
 	mockRegistryStore.On("List", ctx, "system:global", (*string)(nil)).Return(dbEntries, nil)
 
-	result, err := resolver.Query().ListSystemRegistry(ctx, nil)
+	result, err := resolver.Query().ListSystemRegistry(ctx, nil, nil, nil)
 
 	assert.NoError(t, err)
 	assert.Len(t, result, 1) // app_title omitted entirely; only app_home_title returned
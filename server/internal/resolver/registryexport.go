@@ -0,0 +1,180 @@
+package resolver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/internal/storageprovider"
+	"github.com/cshum/imagor-studio/server/internal/updatecheck"
+	"github.com/cshum/imagor-studio/server/pkg/encryption"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// registryExportVersion is the version of the exportSystemRegistry document
+const registryExportVersion = 1
+
+// serverStateRegistryKeys are system registry keys holding state of the
+// server rather than settings, never exported nor imported
+var serverStateRegistryKeys = map[string]bool{
+	"config.jwt_secret":                       true,
+	encryption.DataKeyRegistryKey:             true,
+	storageprovider.PendingConfigKey:          true,
+	storageprovider.PendingConfigUpdatedAtKey: true,
+	storageprovider.RollbackReasonKey:         true,
+	storageprovider.RollbackAtKey:             true,
+	updatecheck.LatestKey:                     true,
+}
+
+// registryExport is the document of exportSystemRegistry
+type registryExport struct {
+	Version    int                   `json:"version"`
+	ExportedAt time.Time             `json:"exportedAt"`
+	Entries    []registryExportEntry `json:"entries"`
+}
+
+// registryExportEntry is a setting of a registryExport, the value of
+// encrypted settings being left out
+type registryExportEntry struct {
+	Key         string  `json:"key"`
+	Value       *string `json:"value,omitempty"`
+	IsEncrypted bool    `json:"isEncrypted,omitempty"`
+}
+
+// ExportSystemRegistry is the resolver for the exportSystemRegistry field.
+func (r *mutationResolver) ExportSystemRegistry(ctx context.Context) (string, error) {
+	if err := RequireAdminPermission(ctx); err != nil {
+		return "", err
+	}
+	if r.config.IsEmbeddedMode() {
+		return "", registryExportNotAvailableError()
+	}
+	registryList, err := r.registryStore.List(ctx, registrystore.SystemOwnerID, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to list system registry: %w", err)
+	}
+	doc := registryExport{
+		Version:    registryExportVersion,
+		ExportedAt: time.Now().UTC(),
+		Entries:    make([]registryExportEntry, 0, len(registryList)),
+	}
+	for _, registry := range registryList {
+		if serverStateRegistryKeys[registry.Key] {
+			continue
+		}
+		entry := registryExportEntry{Key: registry.Key, IsEncrypted: registry.IsEncrypted}
+		if !registry.IsEncrypted {
+			value := registry.Value
+			entry.Value = &value
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to export system registry: %w", err)
+	}
+	return string(data), nil
+}
+
+// ImportSystemRegistry is the resolver for the importSystemRegistry field.
+func (r *mutationResolver) ImportSystemRegistry(ctx context.Context, document string, overwrite *bool) (*gql.SystemRegistryImport, error) {
+	if err := RequireAdminPermission(ctx); err != nil {
+		return nil, err
+	}
+	if r.config.IsEmbeddedMode() {
+		return nil, registryExportNotAvailableError()
+	}
+	var doc registryExport
+	if err := json.Unmarshal([]byte(document), &doc); err != nil {
+		return nil, invalidRegistryImportError(fmt.Sprintf("invalid JSON: %v", err))
+	}
+	if doc.Version != registryExportVersion {
+		return nil, invalidRegistryImportError(fmt.Sprintf("unsupported version %d, expected %d", doc.Version, registryExportVersion))
+	}
+	keys := make([]string, 0, len(doc.Entries))
+	seen := make(map[string]bool, len(doc.Entries))
+	for _, entry := range doc.Entries {
+		if entry.Key == "" {
+			return nil, invalidRegistryImportError("entry without a key")
+		}
+		if seen[entry.Key] {
+			return nil, invalidRegistryImportError(fmt.Sprintf("duplicate key '%s'", entry.Key))
+		}
+		seen[entry.Key] = true
+		keys = append(keys, entry.Key)
+	}
+
+	existing := make(map[string]bool)
+	if overwrite == nil || !*overwrite {
+		registries, err := r.registryStore.GetMulti(ctx, registrystore.SystemOwnerID, keys)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get system registries: %w", err)
+		}
+		for _, registry := range registries {
+			existing[registry.Key] = true
+		}
+	}
+
+	// Lazily evaluate license status, only when a license-required key is imported
+	var licenseChecked, licensed bool
+	getLicensed := func() bool {
+		if !licenseChecked {
+			licenseChecked = true
+			licensed = r.checkLicensed(ctx)
+		}
+		return licensed
+	}
+
+	result := &gql.SystemRegistryImport{Imported: []string{}, Skipped: []*gql.SystemRegistryImportSkip{}}
+	skip := func(key, reason string) {
+		result.Skipped = append(result.Skipped, &gql.SystemRegistryImportSkip{Key: key, Reason: reason})
+	}
+	var registryEntries []*registrystore.Registry
+	for _, entry := range doc.Entries {
+		_, configExists := r.config.GetByRegistryKey(entry.Key)
+		switch {
+		case serverStateRegistryKeys[entry.Key]:
+			skip(entry.Key, "server state")
+		case configExists:
+			skip(entry.Key, "managed by external config")
+		case licenseRequiredRegistryKeys[entry.Key] && !getLicensed():
+			skip(entry.Key, "requires a license")
+		case entry.Value == nil && entry.IsEncrypted:
+			skip(entry.Key, "encrypted value not exported")
+		case entry.Value == nil:
+			skip(entry.Key, "no value")
+		case existing[entry.Key]:
+			skip(entry.Key, "already set")
+		default:
+			registryEntries = append(registryEntries, &registrystore.Registry{
+				Key:         entry.Key,
+				Value:       *entry.Value,
+				IsEncrypted: entry.IsEncrypted || isCredentialRegistryKey(entry.Key),
+			})
+			result.Imported = append(result.Imported, entry.Key)
+		}
+	}
+	if len(registryEntries) > 0 {
+		if _, err := r.registryStore.SetMulti(ctx, registrystore.SystemOwnerID, registryEntries); err != nil {
+			return nil, fmt.Errorf("failed to set system registry: %w", err)
+		}
+	}
+	return result, nil
+}
+
+func invalidRegistryImportError(reason string) *gqlerror.Error {
+	return &gqlerror.Error{
+		Message:    "invalid system registry document: " + reason,
+		Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+	}
+}
+
+func registryExportNotAvailableError() *gqlerror.Error {
+	return &gqlerror.Error{
+		Message:    "the system registry is not stored on this server in embedded mode",
+		Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+	}
+}
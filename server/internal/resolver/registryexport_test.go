@@ -0,0 +1,136 @@
+package resolver
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func TestExportSystemRegistry(t *testing.T) {
+	mockRegistryStore := new(MockRegistryStore)
+	resolver := newTestResolver(nil, mockRegistryStore, new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop())
+	ctx := createAdminContext("admin-user-id")
+
+	mockRegistryStore.On("List", ctx, registrystore.SystemOwnerID, (*string)(nil)).Return([]*registrystore.Registry{
+		{Key: "config.app_title", Value: "Studio"},
+		{Key: "config.notify_email_password", Value: "encrypted", IsEncrypted: true},
+		{Key: "config.jwt_secret", Value: "secret", IsEncrypted: true},
+		{Key: "config.storage_pending_config", Value: "{}"},
+	}, nil)
+
+	document, err := resolver.Mutation().ExportSystemRegistry(ctx)
+	require.NoError(t, err)
+
+	var doc registryExport
+	require.NoError(t, json.Unmarshal([]byte(document), &doc))
+	assert.Equal(t, registryExportVersion, doc.Version)
+	require.Len(t, doc.Entries, 2)
+	assert.Equal(t, "config.app_title", doc.Entries[0].Key)
+	require.NotNil(t, doc.Entries[0].Value)
+	assert.Equal(t, "Studio", *doc.Entries[0].Value)
+	assert.Equal(t, "config.notify_email_password", doc.Entries[1].Key)
+	assert.True(t, doc.Entries[1].IsEncrypted)
+	assert.Nil(t, doc.Entries[1].Value)
+	assert.NotContains(t, document, "encrypted\"")
+}
+
+func TestExportSystemRegistry_RequiresServerAdmin(t *testing.T) {
+	resolver := newTestResolver(nil, new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop())
+
+	_, err := resolver.Mutation().ExportSystemRegistry(createReadWriteContext("user-id"))
+	assert.Error(t, err)
+	_, err = resolver.Mutation().ImportSystemRegistry(createReadWriteContext("user-id"), `{"version":1}`, nil)
+	assert.Error(t, err)
+}
+
+func TestImportSystemRegistry(t *testing.T) {
+	mockRegistryStore := new(MockRegistryStore)
+	resolver := newTestResolver(nil, mockRegistryStore, new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop())
+	ctx := createAdminContext("admin-user-id")
+
+	document := `{
+		"version": 1,
+		"entries": [
+			{"key": "config.app_title", "value": "Studio"},
+			{"key": "config.allow_guest_mode", "value": "true"},
+			{"key": "config.notify_email_password", "isEncrypted": true},
+			{"key": "config.notify_ntfy_token", "value": "typed-in", "isEncrypted": true},
+			{"key": "config.encryption_key", "value": "key"}
+		]
+	}`
+	mockRegistryStore.On("GetMulti", ctx, registrystore.SystemOwnerID, mock.Anything).Return([]*registrystore.Registry{
+		{Key: "config.allow_guest_mode", Value: "false"},
+	}, nil)
+	mockRegistryStore.On("SetMulti", ctx, registrystore.SystemOwnerID, []*registrystore.Registry{
+		{Key: "config.app_title", Value: "Studio"},
+		{Key: "config.notify_ntfy_token", Value: "typed-in", IsEncrypted: true},
+	}).Return([]*registrystore.Registry{}, nil)
+
+	result, err := resolver.Mutation().ImportSystemRegistry(ctx, document, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"config.app_title", "config.notify_ntfy_token"}, result.Imported)
+	skipped := make(map[string]string)
+	for _, skip := range result.Skipped {
+		skipped[skip.Key] = skip.Reason
+	}
+	assert.Equal(t, map[string]string{
+		"config.allow_guest_mode":      "already set",
+		"config.notify_email_password": "encrypted value not exported",
+		"config.encryption_key":        "server state",
+	}, skipped)
+	mockRegistryStore.AssertExpectations(t)
+}
+
+func TestImportSystemRegistry_Overwrite(t *testing.T) {
+	mockRegistryStore := new(MockRegistryStore)
+	resolver := newTestResolver(nil, mockRegistryStore, new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop())
+	ctx := createAdminContext("admin-user-id")
+
+	mockRegistryStore.On("SetMulti", ctx, registrystore.SystemOwnerID, []*registrystore.Registry{
+		{Key: "config.allow_guest_mode", Value: "true"},
+	}).Return([]*registrystore.Registry{}, nil)
+
+	overwrite := true
+	result, err := resolver.Mutation().ImportSystemRegistry(ctx, `{"version":1,"entries":[{"key":"config.allow_guest_mode","value":"true"}]}`, &overwrite)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"config.allow_guest_mode"}, result.Imported)
+	mockRegistryStore.AssertNotCalled(t, "GetMulti", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestImportSystemRegistry_SkipsExternalConfig(t *testing.T) {
+	mockRegistryStore := new(MockRegistryStore)
+	resolver := newTestResolver(nil, mockRegistryStore, new(MockUserStore), nil, &MockConfig{configExists: true}, nil, zap.NewNop())
+	ctx := createAdminContext("admin-user-id")
+
+	overwrite := true
+	result, err := resolver.Mutation().ImportSystemRegistry(ctx, `{"version":1,"entries":[{"key":"config.storage_type","value":"s3"}]}`, &overwrite)
+	require.NoError(t, err)
+	assert.Empty(t, result.Imported)
+	require.Len(t, result.Skipped, 1)
+	assert.Equal(t, "managed by external config", result.Skipped[0].Reason)
+	mockRegistryStore.AssertNotCalled(t, "SetMulti", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestImportSystemRegistry_InvalidDocument(t *testing.T) {
+	resolver := newTestResolver(nil, new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop())
+	ctx := createAdminContext("admin-user-id")
+
+	for _, document := range []string{
+		`not json`,
+		`{"version":2,"entries":[]}`,
+		`{"version":1,"entries":[{"value":"x"}]}`,
+		`{"version":1,"entries":[{"key":"a","value":"1"},{"key":"a","value":"2"}]}`,
+	} {
+		_, err := resolver.Mutation().ImportSystemRegistry(ctx, document, nil)
+		var gqlErr *gqlerror.Error
+		require.ErrorAs(t, err, &gqlErr, document)
+		assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"], document)
+	}
+}
@@ -2,14 +2,57 @@ package resolver
 
 import (
 	"context"
+	"strings"
+	"time"
 
 	"github.com/cshum/imagor"
+	"github.com/cshum/imagor-studio/server/internal/albumstore"
+	"github.com/cshum/imagor-studio/server/internal/allowlist"
+	"github.com/cshum/imagor-studio/server/internal/apitoken"
+	"github.com/cshum/imagor-studio/server/internal/auditlog"
+	"github.com/cshum/imagor-studio/server/internal/autoupload"
+	"github.com/cshum/imagor-studio/server/internal/bulkdownload"
+	"github.com/cshum/imagor-studio/server/internal/chunkupload"
+	"github.com/cshum/imagor-studio/server/internal/commentstore"
+	"github.com/cshum/imagor-studio/server/internal/dbmaintenance"
+	"github.com/cshum/imagor-studio/server/internal/dedupe"
+	"github.com/cshum/imagor-studio/server/internal/events"
+	"github.com/cshum/imagor-studio/server/internal/faces"
+	"github.com/cshum/imagor-studio/server/internal/favoritestore"
+	"github.com/cshum/imagor-studio/server/internal/filejournal"
+	"github.com/cshum/imagor-studio/server/internal/filemeta"
+	"github.com/cshum/imagor-studio/server/internal/foldercover"
+	"github.com/cshum/imagor-studio/server/internal/foldersettings"
 	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/hls"
+	"github.com/cshum/imagor-studio/server/internal/imageedit"
 	"github.com/cshum/imagor-studio/server/internal/imagorprovider"
+	"github.com/cshum/imagor-studio/server/internal/jobqueue"
+	"github.com/cshum/imagor-studio/server/internal/libraryscan"
 	"github.com/cshum/imagor-studio/server/internal/license"
+	"github.com/cshum/imagor-studio/server/internal/listcache"
+	"github.com/cshum/imagor-studio/server/internal/netacl"
+	"github.com/cshum/imagor-studio/server/internal/notify"
+	"github.com/cshum/imagor-studio/server/internal/operation"
+	"github.com/cshum/imagor-studio/server/internal/querylimit"
+	"github.com/cshum/imagor-studio/server/internal/ratingstore"
 	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/internal/s3key"
+	"github.com/cshum/imagor-studio/server/internal/scheduler"
+	"github.com/cshum/imagor-studio/server/internal/sessionstore"
+	"github.com/cshum/imagor-studio/server/internal/sharestore"
+	"github.com/cshum/imagor-studio/server/internal/storagestats"
+	"github.com/cshum/imagor-studio/server/internal/tagstore"
+	"github.com/cshum/imagor-studio/server/internal/tenantstore"
+	"github.com/cshum/imagor-studio/server/internal/thumbnailpreset"
+	"github.com/cshum/imagor-studio/server/internal/updatecheck"
+	"github.com/cshum/imagor-studio/server/internal/urlimport"
 	"github.com/cshum/imagor-studio/server/internal/userstore"
+	"github.com/cshum/imagor-studio/server/internal/videostream"
+	"github.com/cshum/imagor-studio/server/internal/virusscan"
+	"github.com/cshum/imagor-studio/server/internal/webhook"
 	"github.com/cshum/imagor-studio/server/pkg/billing"
+	"github.com/cshum/imagor-studio/server/pkg/encryption"
 	"github.com/cshum/imagor-studio/server/pkg/management"
 	"github.com/cshum/imagor-studio/server/pkg/org"
 	"github.com/cshum/imagor-studio/server/pkg/processing"
@@ -17,6 +60,7 @@ import (
 	"github.com/cshum/imagor-studio/server/pkg/space"
 	"github.com/cshum/imagor-studio/server/pkg/storage"
 	"github.com/cshum/imagor/imagorpath"
+	"github.com/uptrace/bun"
 	"go.uber.org/zap"
 )
 
@@ -71,6 +115,62 @@ type Resolver struct {
 	inviteSender     space.InviteSender
 	signupRuntime    signup.Runtime
 
+	operations    *operation.Manager
+	updateChecker *updatecheck.Checker
+	apiCompatMode bool
+
+	databaseMaintenance *dbmaintenance.Job
+	libraryScan         *libraryscan.Job
+	scanSchedule        *scheduler.Scheduler
+	hlsManager          *hls.Manager
+	videoStreams        *videostream.Manager
+	tagStore            tagstore.Store
+	favoriteStore       favoritestore.Store
+	albumStore          albumstore.Store
+	commentStore        commentstore.Store
+	ratingStore         ratingstore.Store
+	auditLog            auditlog.Store
+	fileJournal         filejournal.Store
+	journalRetention    time.Duration
+	autoUploadStore     autoupload.Store
+	webhookStore        webhook.Store
+	webhooks            *webhook.Dispatcher
+	notifier            *notify.Notifier
+	apiTokenStore       apitoken.Store
+	s3KeyStore          s3key.Store
+	sessionStore        sessionstore.Store
+	backupDB            *bun.DB
+	keyRotationDB       *bun.DB
+	encryption          *encryption.Service
+	tenantStore         tenantstore.Store
+	shareStore          sharestore.Store
+	shareBaseURL        string
+	fileMetaStore       filemeta.Store
+	duplicates          *dedupe.Scanner
+	uploadDedupe        string
+	uploadCollision     string
+	uploadScanner       virusscan.Scanner
+	uploadQuarantine    *virusscan.Quarantine
+	uploadExtensions    []string
+	faces               *faces.Scanner
+	listCache           *listcache.Cache
+	imageEditStore      imageedit.Store
+	folderCoverStore    foldercover.Store
+	folderSettingsStore foldersettings.Store
+	storageStatsStore   storagestats.Store
+	events              *events.Broker
+	bulkDownloads       *bulkdownload.Handler
+	chunkUploads        *chunkupload.Manager
+	urlImporter         *urlimport.Importer
+	processingScheduler *jobqueue.Scheduler
+	operationAllowList  *allowlist.Store
+	networkACL          *netacl.Store
+	queryLimits         *querylimit.Store
+	thumbnailPresets    *thumbnailpreset.Store
+	rateLimitSecret     string
+	deleteConfirmations *deleteConfirmations
+	directUploads       *directUploads
+
 	storageConfigValidator StorageConfigValidator
 	spaceStorageFactory    func(*space.Space) (storage.Storage, error)
 	publicPreviewEnabled   bool
@@ -134,6 +234,362 @@ func WithSpaceStorageFactory(factory func(*space.Space) (storage.Storage, error)
 	}
 }
 
+// WithOperationManager overrides the in-memory manager tracking async operations
+func WithOperationManager(manager *operation.Manager) ResolverOption {
+	return func(r *Resolver) {
+		r.operations = manager
+	}
+}
+
+// WithUpdateChecker enables the update advisory in serverInfo
+func WithUpdateChecker(checker *updatecheck.Checker) ResolverOption {
+	return func(r *Resolver) {
+		r.updateChecker = checker
+	}
+}
+
+// WithDatabaseMaintenance enables the runDatabaseMaintenance mutation.
+// The job should share the resolver's operation manager.
+func WithDatabaseMaintenance(job *dbmaintenance.Job) ResolverOption {
+	return func(r *Resolver) {
+		r.databaseMaintenance = job
+	}
+}
+
+// WithLibraryScan enables scanStatus and triggerScan. The job should share
+// the resolver's operation manager; schedule reports its scheduled runs.
+func WithLibraryScan(job *libraryscan.Job, schedule *scheduler.Scheduler) ResolverOption {
+	return func(r *Resolver) {
+		r.libraryScan = job
+		r.scanSchedule = schedule
+	}
+}
+
+// WithHLSManager enables on-demand HLS transcoding for videoPlayback
+func WithHLSManager(manager *hls.Manager) ResolverOption {
+	return func(r *Resolver) {
+		r.hlsManager = manager
+	}
+}
+
+// WithVideoStreamManager enables MP4 video streams for videoStream
+func WithVideoStreamManager(manager *videostream.Manager) ResolverOption {
+	return func(r *Resolver) {
+		r.videoStreams = manager
+	}
+}
+
+// WithAlbumStore enables albums; album queries fail when nil
+func WithAlbumStore(store albumstore.Store) ResolverOption {
+	return func(r *Resolver) {
+		r.albumStore = store
+	}
+}
+
+// WithCommentStore enables file comments; comment queries fail when nil
+func WithCommentStore(store commentstore.Store) ResolverOption {
+	return func(r *Resolver) {
+		r.commentStore = store
+	}
+}
+
+// WithAPITokenStore enables personal access tokens; token queries fail when nil
+func WithAPITokenStore(store apitoken.Store) ResolverOption {
+	return func(r *Resolver) {
+		r.apiTokenStore = store
+	}
+}
+
+// WithS3KeyStore enables S3 access keys of the S3 gateway; key queries fail
+// when nil
+func WithS3KeyStore(store s3key.Store) ResolverOption {
+	return func(r *Resolver) {
+		r.s3KeyStore = store
+	}
+}
+
+// WithSessionStore enables the sessions query; it fails when nil
+func WithSessionStore(store sessionstore.Store) ResolverOption {
+	return func(r *Resolver) {
+		r.sessionStore = store
+	}
+}
+
+// WithBackups enables the createBackup mutation, backing up db
+func WithBackups(db *bun.DB) ResolverOption {
+	return func(r *Resolver) {
+		r.backupDB = db
+	}
+}
+
+// WithEncryptionKeyRotation enables the rotateEncryptionKey mutation,
+// re-encrypting the registry of db with enc
+func WithEncryptionKeyRotation(db *bun.DB, enc *encryption.Service) ResolverOption {
+	return func(r *Resolver) {
+		r.keyRotationDB = db
+		r.encryption = enc
+	}
+}
+
+// WithTenantStore enables tenant management; tenant queries fail when nil
+func WithTenantStore(store tenantstore.Store) ResolverOption {
+	return func(r *Resolver) {
+		r.tenantStore = store
+	}
+}
+
+// WithAuditLog enables the auditLog query, it fails when nil, and records
+// the changes made over WebDAV and the S3 gateway
+func WithAuditLog(store auditlog.Store) ResolverOption {
+	return func(r *Resolver) {
+		r.auditLog = store
+	}
+}
+
+// WithFileJournal enables the changesSince query, answered from store which
+// keeps changes for retention, forever when 0; it fails when store is nil
+func WithFileJournal(store filejournal.Store, retention time.Duration) ResolverOption {
+	return func(r *Resolver) {
+		r.fileJournal = store
+		r.journalRetention = retention
+	}
+}
+
+// WithAutoUploadStore enables the autoUpload mutation; it fails when store
+// is nil
+func WithAutoUploadStore(store autoupload.Store) ResolverOption {
+	return func(r *Resolver) {
+		r.autoUploadStore = store
+	}
+}
+
+// WithWebhooks enables webhook management and notifies dispatcher of
+// albums created; webhook queries fail when store is nil
+func WithWebhooks(store webhook.Store, dispatcher *webhook.Dispatcher) ResolverOption {
+	return func(r *Resolver) {
+		r.webhookStore = store
+		r.webhooks = dispatcher
+	}
+}
+
+// WithNotifier enables the testNotification mutation
+func WithNotifier(notifier *notify.Notifier) ResolverOption {
+	return func(r *Resolver) {
+		r.notifier = notifier
+	}
+}
+
+// WithRatingStore enables star ratings; rateFile fails when nil
+func WithRatingStore(store ratingstore.Store) ResolverOption {
+	return func(r *Resolver) {
+		r.ratingStore = store
+	}
+}
+
+// WithFavoriteStore enables favorites; favorite queries fail when nil
+func WithFavoriteStore(store favoritestore.Store) ResolverOption {
+	return func(r *Resolver) {
+		r.favoriteStore = store
+	}
+}
+
+// WithTagStore enables tagging; tag queries fail when nil
+func WithTagStore(store tagstore.Store) ResolverOption {
+	return func(r *Resolver) {
+		r.tagStore = store
+	}
+}
+
+// WithShareStore enables shared links; createShareLink fails when nil.
+// Link URLs are built on appURL, or relative to the app origin when empty.
+func WithShareStore(store sharestore.Store, appURL string) ResolverOption {
+	return func(r *Resolver) {
+		r.shareStore = store
+		r.shareBaseURL = strings.TrimRight(appURL, "/")
+	}
+}
+
+// WithFileMetaStore caches fileMetadata results; metadata is read from
+// imagor on every request when nil
+func WithFileMetaStore(store filemeta.Store) ResolverOption {
+	return func(r *Resolver) {
+		r.fileMetaStore = store
+	}
+}
+
+// WithDuplicateScanner enables duplicateGroups, scanDuplicates and
+// resolveDuplicates
+func WithDuplicateScanner(scanner *dedupe.Scanner) ResolverOption {
+	return func(r *Resolver) {
+		r.duplicates = scanner
+	}
+}
+
+// WithUploadDedupe sets how uploads identical to a hashed file are handled:
+// allow writes them, reject fails them and link answers with the existing
+// file. Requires WithDuplicateScanner.
+func WithUploadDedupe(mode string) ResolverOption {
+	return func(r *Resolver) {
+		r.uploadDedupe = mode
+	}
+}
+
+// WithUploadCollision sets what uploads to a path already taken do when the
+// request does not say: overwrite replaces the file, rename stores them under
+// a numbered name and reject fails them. Defaults to overwrite.
+func WithUploadCollision(policy string) ResolverOption {
+	return func(r *Resolver) {
+		r.uploadCollision = policy
+	}
+}
+
+// WithUploadScanner scans uploads for malware before they are stored,
+// rejecting infected ones. Infected uploads are kept in quarantine when not
+// nil. Direct uploads, which bypass the server, are turned off.
+func WithUploadScanner(scanner virusscan.Scanner, quarantine *virusscan.Quarantine) ResolverOption {
+	return func(r *Resolver) {
+		r.uploadScanner = scanner
+		r.uploadQuarantine = quarantine
+	}
+}
+
+// WithUploadExtensions restricts uploads to files with these extensions,
+// lower case with the leading dot. Uploads of any extension are allowed
+// when empty.
+func WithUploadExtensions(extensions []string) ResolverOption {
+	return func(r *Resolver) {
+		r.uploadExtensions = extensions
+	}
+}
+
+// WithFaceScanner enables people, personPhotos, scanFaces and the people
+// naming mutations
+func WithFaceScanner(scanner *faces.Scanner) ResolverOption {
+	return func(r *Resolver) {
+		r.faces = scanner
+	}
+}
+
+// WithListCache serves folder listings from cache, every listFiles call
+// lists the storage when nil
+func WithListCache(cache *listcache.Cache) ResolverOption {
+	return func(r *Resolver) {
+		r.listCache = cache
+	}
+}
+
+// WithImageEditStore enables saving image edits and exporting them;
+// the image edit fields fail when nil
+func WithImageEditStore(store imageedit.Store) ResolverOption {
+	return func(r *Resolver) {
+		r.imageEditStore = store
+	}
+}
+
+// WithFolderCoverStore enables folder covers; setFolderCover fails when nil
+func WithFolderCoverStore(store foldercover.Store) ResolverOption {
+	return func(r *Resolver) {
+		r.folderCoverStore = store
+	}
+}
+
+// WithFolderSettingsStore enables folder settings; setFolderSettings fails
+// when nil
+func WithFolderSettingsStore(store foldersettings.Store) ResolverOption {
+	return func(r *Resolver) {
+		r.folderSettingsStore = store
+	}
+}
+
+// WithStorageStatsStore enables storage stats and quotas; storageStats
+// fails when nil
+func WithStorageStatsStore(store storagestats.Store) ResolverOption {
+	return func(r *Resolver) {
+		r.storageStatsStore = store
+	}
+}
+
+// WithEvents publishes file and storage changes to subscriptions through b;
+// fileChanged fails when nil
+func WithEvents(b *events.Broker) ResolverOption {
+	return func(r *Resolver) {
+		r.events = b
+	}
+}
+
+// WithBulkDownloads enables createBulkDownload, issuing tokens served by h
+func WithBulkDownloads(h *bulkdownload.Handler) ResolverOption {
+	return func(r *Resolver) {
+		r.bulkDownloads = h
+	}
+}
+
+// WithChunkUploads enables chunked uploads spooled by m
+func WithChunkUploads(m *chunkupload.Manager) ResolverOption {
+	return func(r *Resolver) {
+		r.chunkUploads = m
+	}
+}
+
+// WithURLImporter enables importFromUrl
+func WithURLImporter(importer *urlimport.Importer) ResolverOption {
+	return func(r *Resolver) {
+		r.urlImporter = importer
+	}
+}
+
+// WithProcessingScheduler exposes the processing queue stats and schedules
+// template previews below interactive requests
+func WithProcessingScheduler(s *jobqueue.Scheduler) ResolverOption {
+	return func(r *Resolver) {
+		r.processingScheduler = s
+	}
+}
+
+// WithOperationAllowList enables managing the per-role operation allow-lists
+func WithOperationAllowList(store *allowlist.Store) ResolverOption {
+	return func(r *Resolver) {
+		r.operationAllowList = store
+	}
+}
+
+// WithNetworkACL enables managing the network ACLs of the route groups
+func WithNetworkACL(store *netacl.Store) ResolverOption {
+	return func(r *Resolver) {
+		r.networkACL = store
+	}
+}
+
+// WithQueryLimits enables managing the limits of GraphQL operations
+func WithQueryLimits(store *querylimit.Store) ResolverOption {
+	return func(r *Resolver) {
+		r.queryLimits = store
+	}
+}
+
+// WithThumbnailPresets serves the thumbnail presets of the registry, the
+// defaults are used without
+func WithThumbnailPresets(store *thumbnailpreset.Store) ResolverOption {
+	return func(r *Resolver) {
+		r.thumbnailPresets = store
+	}
+}
+
+// WithRateLimitKeys signs the rate limit key of the session into the image
+// URLs it is issued, see ratelimit.KeyParam. An empty secret signs nothing.
+func WithRateLimitKeys(secret string) ResolverOption {
+	return func(r *Resolver) {
+		r.rateLimitSecret = secret
+	}
+}
+
+// WithAPICompatMode reports whether deprecated fields are still served
+func WithAPICompatMode(enabled bool) ResolverOption {
+	return func(r *Resolver) {
+		r.apiCompatMode = enabled
+	}
+}
+
 func WithSignupRuntime(runtime signup.Runtime) ResolverOption {
 	return func(r *Resolver) {
 		r.signupRuntime = runtime
@@ -179,6 +635,10 @@ func NewResolver(
 		processingOriginResolver: space.NewCustomDomainProcessingOriginResolver(spaceStore),
 		spaceInviteStore:         spaceInviteStore,
 		inviteSender:             inviteSender,
+		operations:               operation.NewManager(logger),
+		deleteConfirmations:      newDeleteConfirmations(),
+		directUploads:            newDirectUploads(logger),
+		apiCompatMode:            true,
 	}
 
 	for _, opt := range opts {
@@ -209,5 +669,9 @@ func (r *Resolver) Mutation() gql.MutationResolver { return &mutationResolver{r}
 // Query returns QueryResolver implementation.
 func (r *Resolver) Query() gql.QueryResolver { return &queryResolver{r} }
 
+// Subscription returns SubscriptionResolver implementation.
+func (r *Resolver) Subscription() gql.SubscriptionResolver { return &subscriptionResolver{r} }
+
 type mutationResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
+type subscriptionResolver struct{ *Resolver }
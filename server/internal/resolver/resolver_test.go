@@ -10,6 +10,7 @@ import (
 	"github.com/cshum/imagor-studio/server/internal/imagorprovider"
 	"github.com/cshum/imagor-studio/server/internal/license"
 	"github.com/cshum/imagor-studio/server/internal/model"
+	"github.com/cshum/imagor-studio/server/internal/pagination"
 	"github.com/cshum/imagor-studio/server/internal/registrystore"
 	"github.com/cshum/imagor-studio/server/internal/userstore"
 	"github.com/cshum/imagor-studio/server/pkg/auth"
@@ -219,6 +220,40 @@ func (m *MockUserStore) UpdateRole(ctx context.Context, id string, role string)
 	return args.Error(0)
 }
 
+func (m *MockUserStore) UpdateHomePath(ctx context.Context, id string, homePath *string) error {
+	args := m.Called(ctx, id, homePath)
+	return args.Error(0)
+}
+
+func (m *MockUserStore) CreateInTenant(ctx context.Context, tenantID, displayName, username, hashedPassword, role string) (*userstore.User, error) {
+	args := m.Called(ctx, tenantID, displayName, username, hashedPassword, role)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*userstore.User), args.Error(1)
+}
+
+func (m *MockUserStore) ListByTenant(ctx context.Context, tenantID string, offset, limit int, search string) ([]*userstore.User, int, error) {
+	args := m.Called(ctx, tenantID, offset, limit, search)
+	if args.Get(0) == nil {
+		return nil, args.Get(1).(int), args.Error(2)
+	}
+	return args.Get(0).([]*userstore.User), args.Get(1).(int), args.Error(2)
+}
+
+func (m *MockUserStore) ListAfter(ctx context.Context, tenantID string, after *pagination.Key, limit int, search string) ([]*userstore.User, int, bool, error) {
+	args := m.Called(ctx, tenantID, after, limit, search)
+	if args.Get(0) == nil {
+		return nil, args.Get(1).(int), args.Bool(2), args.Error(3)
+	}
+	return args.Get(0).([]*userstore.User), args.Get(1).(int), args.Bool(2), args.Error(3)
+}
+
+func (m *MockUserStore) UpdateTenant(ctx context.Context, id string, tenantID *string) error {
+	args := m.Called(ctx, id, tenantID)
+	return args.Error(0)
+}
+
 type MockStorage struct {
 	mock.Mock
 }
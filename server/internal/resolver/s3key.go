@@ -0,0 +1,117 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/s3key"
+	"github.com/cshum/imagor-studio/server/pkg/auth"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+func s3KeyError(err error) error {
+	switch {
+	case errors.Is(err, s3key.ErrNotFound):
+		return &gqlerror.Error{
+			Message:    err.Error(),
+			Extensions: map[string]interface{}{"code": "NOT_FOUND"},
+		}
+	case errors.Is(err, s3key.ErrInvalid):
+		return &gqlerror.Error{
+			Message:    err.Error(),
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	return fmt.Errorf("S3 access key operation failed: %w", err)
+}
+
+// requireS3KeyUser returns the claims of a user session that may manage S3
+// access keys, the same sessions as may manage API tokens
+func (r *Resolver) requireS3KeyUser(ctx context.Context) (*auth.Claims, error) {
+	if r.s3KeyStore == nil {
+		return nil, &gqlerror.Error{
+			Message:    "the S3 gateway is not enabled on this server",
+			Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+		}
+	}
+	claims, err := auth.GetClaimsFromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unauthorized")
+	}
+	if IsGuestUser(ctx) || IsShareLinkSession(ctx) || IsPublicPreviewMode(ctx) || claims.IsEmbedded {
+		return nil, fmt.Errorf("S3 access keys require a user account")
+	}
+	return claims, nil
+}
+
+func toGQLS3AccessKey(key *s3key.AccessKey) *gql.S3AccessKey {
+	result := &gql.S3AccessKey{
+		AccessKeyID: key.ID,
+		Name:        key.Name,
+		Scopes:      key.Scopes,
+		CreatedAt:   key.CreatedAt.Format(time.RFC3339),
+	}
+	if key.LastUsedAt != nil {
+		lastUsedAt := key.LastUsedAt.Format(time.RFC3339)
+		result.LastUsedAt = &lastUsedAt
+	}
+	return result
+}
+
+// S3AccessKeys is the resolver for the s3AccessKeys field.
+func (r *queryResolver) S3AccessKeys(ctx context.Context) ([]*gql.S3AccessKey, error) {
+	claims, err := r.requireS3KeyUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := r.s3KeyStore.List(ctx, claims.UserID)
+	if err != nil {
+		return nil, s3KeyError(err)
+	}
+	result := make([]*gql.S3AccessKey, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, toGQLS3AccessKey(key))
+	}
+	return result, nil
+}
+
+// CreateS3AccessKey is the resolver for the createS3AccessKey field.
+func (r *mutationResolver) CreateS3AccessKey(ctx context.Context, name string, scopes []string) (*gql.CreatedS3AccessKey, error) {
+	claims, err := r.requireS3KeyUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// A leaked API token must not be able to outlive its own revocation
+	if claims.Kind == auth.APITokenKind {
+		return nil, fmt.Errorf("API tokens cannot create S3 access keys")
+	}
+	held := make(map[string]bool, len(claims.Scopes))
+	for _, scope := range claims.Scopes {
+		held[scope] = true
+	}
+	for _, scope := range scopes {
+		if !held[scope] {
+			return nil, s3KeyError(fmt.Errorf("%w: the session does not hold scope %q", s3key.ErrInvalid, scope))
+		}
+	}
+	key, secret, err := r.s3KeyStore.Create(ctx, claims.UserID, claims.OrgID, name, scopes)
+	if err != nil {
+		return nil, s3KeyError(err)
+	}
+	return &gql.CreatedS3AccessKey{S3AccessKey: toGQLS3AccessKey(key), SecretAccessKey: secret}, nil
+}
+
+// RevokeS3AccessKey is the resolver for the revokeS3AccessKey field.
+func (r *mutationResolver) RevokeS3AccessKey(ctx context.Context, accessKeyID string) (bool, error) {
+	claims, err := r.requireS3KeyUser(ctx)
+	if err != nil {
+		return false, err
+	}
+	if err := r.s3KeyStore.Revoke(ctx, claims.UserID, accessKeyID); err != nil {
+		return false, s3KeyError(err)
+	}
+	return true, nil
+}
@@ -0,0 +1,73 @@
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/s3key"
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/cshum/imagor-studio/server/pkg/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func newS3KeyTestResolver(t *testing.T) *Resolver {
+	t.Helper()
+	db := testutil.NewDB(t)
+
+	logger := zap.NewNop()
+	return newTestResolver(nil, new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, logger,
+		WithS3KeyStore(s3key.New(db, logger)))
+}
+
+func TestS3AccessKeys_CreateListRevoke(t *testing.T) {
+	resolver := newS3KeyTestResolver(t)
+	ctx := createReadWriteContext("user-1")
+
+	created, err := resolver.Mutation().CreateS3AccessKey(ctx, "photo indexer", []string{"read"})
+	require.NoError(t, err)
+	assert.Equal(t, "photo indexer", created.S3AccessKey.Name)
+	assert.Equal(t, []string{"read"}, created.S3AccessKey.Scopes)
+	assert.NotEmpty(t, created.SecretAccessKey)
+
+	keys, err := resolver.Query().S3AccessKeys(ctx)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Equal(t, created.S3AccessKey.AccessKeyID, keys[0].AccessKeyID)
+	keys, err = resolver.Query().S3AccessKeys(createReadWriteContext("user-2"))
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	_, err = resolver.Mutation().RevokeS3AccessKey(createReadWriteContext("user-2"), created.S3AccessKey.AccessKeyID)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_FOUND", gqlErr.Extensions["code"])
+	revoked, err := resolver.Mutation().RevokeS3AccessKey(ctx, created.S3AccessKey.AccessKeyID)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+}
+
+func TestS3AccessKeys_Restrictions(t *testing.T) {
+	resolver := newS3KeyTestResolver(t)
+	var gqlErr *gqlerror.Error
+
+	// Scopes cannot exceed those of the session
+	_, err := resolver.Mutation().CreateS3AccessKey(createReadOnlyContext("user-1"), "indexer", []string{"write"})
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+
+	_, err = resolver.Mutation().CreateS3AccessKey(createGuestContext("guest-1"), "indexer", []string{"read"})
+	assert.Error(t, err)
+	apiTokenCtx := auth.SetClaimsInContext(context.Background(), &auth.Claims{UserID: "user-1", Role: "user", Scopes: []string{"read", "write"}, Kind: auth.APITokenKind})
+	_, err = resolver.Mutation().CreateS3AccessKey(WithUserID(apiTokenCtx, "user-1"), "indexer", []string{"read"})
+	assert.ErrorContains(t, err, "API tokens cannot create S3 access keys")
+
+	// Keys are not available while the gateway is disabled
+	disabled := newTestResolver(nil, new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop())
+	_, err = disabled.Query().S3AccessKeys(createReadWriteContext("user-1"))
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_AVAILABLE", gqlErr.Extensions["code"])
+}
@@ -0,0 +1,134 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/filesearch"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/rawpreview"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+// SearchFiles is the resolver for the searchFiles field.
+func (r *queryResolver) SearchFiles(ctx context.Context, query string, path *string, extensions *string, limit *int, spaceID *string, tags []string) (*gql.FileSearchResult, error) {
+	root := ""
+	if path != nil {
+		root = strings.Trim(*path, "/")
+	}
+	root, err := ScopePath(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+	if err := RequireReadPermission(ctx, root); err != nil {
+		return nil, err
+	}
+	if len(filesearch.Terms(query)) == 0 && len(tags) == 0 {
+		return nil, &gqlerror.Error{
+			Message:    "query must not be empty",
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	limitValue := filesearch.DefaultLimit
+	if limit != nil {
+		if *limit <= 0 || *limit > filesearch.MaxLimit {
+			return nil, &gqlerror.Error{
+				Message:    fmt.Sprintf("limit must be between 1 and %d", filesearch.MaxLimit),
+				Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+			}
+		}
+		limitValue = *limit
+	}
+
+	spaceConfig, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	var stor storage.Storage
+	if spaceConfig != nil {
+		stor, err = r.storageFromSpaceConfig(spaceConfig)
+	} else {
+		stor, err = r.getSpaceStorageByID(ctx, spaceID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	options := filesearch.Options{
+		Query:      query,
+		Extensions: parseExtensions(extensions),
+		Limit:      limitValue,
+	}
+	if len(tags) > 0 {
+		tagged, err := r.filesWithTags(ctx, spaceConfig, tags)
+		if err != nil {
+			return nil, err
+		}
+		options.Filter = func(item storage.FileInfo) bool {
+			return tagged[item.Path]
+		}
+	}
+	classifier := r.getMediaClassifier(ctx)
+	options.Keywords = func(item storage.FileInfo) []string {
+		// Only filename rules match while scanning, reading the metadata
+		// index for every scanned file would be too costly
+		return classifyFileInfo(classifier, item, nil)
+	}
+	start := time.Now()
+	result, err := filesearch.Search(ctx, stor, root, options)
+	if err != nil {
+		r.logger.Error("Failed to search files", zap.String("path", root), zap.Error(err))
+		return nil, fmt.Errorf("failed to search files: %w", err)
+	}
+	r.logger.Debug("Searched files",
+		zap.String("path", root),
+		zap.String("query", query),
+		zap.Int("matched", len(result.Items)),
+		zap.Int("scanned", result.Scanned),
+		zap.Bool("truncated", result.Truncated),
+		zap.Duration("duration", time.Since(start)),
+	)
+
+	videoThumbnailPos := r.getEffectiveVideoThumbnailPosition(ctx, spaceConfig)
+	var resolvedSpaceKey *string
+	if spaceConfig != nil {
+		resolvedSpaceKey = &spaceConfig.Key
+	}
+	itemPaths := make([]string, len(result.Items))
+	for i, item := range result.Items {
+		itemPaths[i] = item.Path
+	}
+	favorites := r.favoritePaths(ctx, spaceConfig, itemPaths)
+	ratings, err := r.fileRatings(ctx, spaceConfig, itemPaths)
+	if err != nil {
+		r.logger.Warn("Failed to get ratings", zap.Error(err))
+	}
+	classified := r.classificationMetadata(ctx, spaceConfig, classifier, result.Items)
+	files := make([]*gql.FileItem, len(result.Items))
+	for i, item := range result.Items {
+		files[i] = &gql.FileItem{
+			Name:             item.Name,
+			Path:             item.Path,
+			Size:             int(item.Size),
+			IsDirectory:      false,
+			ModifiedTime:     item.ModifiedTime.Format(time.RFC3339),
+			SystemTags:       classifyFileInfo(classifier, item, classified[item.Path]),
+			ThumbnailUrls:    r.generateThumbnailUrlsForResolvedSpace(ctx, item.Path, videoThumbnailPos, resolvedSpaceKey, spaceConfig),
+			PreviewSpriteURL: r.generatePreviewSpriteURL(ctx, item.Path, resolvedSpaceKey, spaceConfig),
+			IsFavorite:       favorites[item.Path],
+			IsRaw:            rawpreview.IsRaw(item.Path),
+		}
+		if rating, ok := ratings[item.Path]; ok {
+			files[i].Rating = &rating
+		}
+	}
+	return &gql.FileSearchResult{
+		Items:        files,
+		ScannedCount: result.Scanned,
+		Truncated:    result.Truncated,
+	}, nil
+}
@@ -0,0 +1,68 @@
+package resolver
+
+import (
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func newSearchTestResolver(t *testing.T) (*Resolver, string) {
+	t.Helper()
+	baseDir := t.TempDir()
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+	mockRegistryStore := new(MockRegistryStore)
+	mockRegistryStore.On("GetMulti", mock.Anything, "system:global", mock.Anything).
+		Return([]*registrystore.Registry{}, nil)
+	resolver := newTestResolver(NewMockStorageProvider(stor), mockRegistryStore, new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop())
+	return resolver, baseDir
+}
+
+func TestSearchFiles(t *testing.T) {
+	resolver, baseDir := newSearchTestResolver(t)
+	writeTestFile(t, baseDir, "holiday/beach.jpg")
+	writeTestFile(t, baseDir, "holiday/2024/Beach-Party.png")
+	writeTestFile(t, baseDir, "work/beach-budget.pdf")
+	writeTestFile(t, baseDir, "work/report.pdf")
+	ctx := createReadOnlyContext("user-1")
+
+	result, err := resolver.Query().SearchFiles(ctx, "beach", nil, nil, nil, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, result.Items, 3)
+	assert.Equal(t, "holiday/2024/Beach-Party.png", result.Items[0].Path)
+	assert.Equal(t, "Beach-Party.png", result.Items[0].Name)
+	assert.Equal(t, 4, result.ScannedCount)
+	assert.False(t, result.Truncated)
+
+	path := "/holiday"
+	extensions := ".jpg,.png"
+	result, err = resolver.Query().SearchFiles(ctx, "beach", &path, &extensions, nil, nil, nil)
+	require.NoError(t, err)
+	assert.Len(t, result.Items, 2)
+
+	result, err = resolver.Query().SearchFiles(ctx, "beach", nil, nil, intPtr(1), nil, nil)
+	require.NoError(t, err)
+	assert.Len(t, result.Items, 1)
+	assert.True(t, result.Truncated)
+}
+
+func TestSearchFiles_InvalidInput(t *testing.T) {
+	resolver, _ := newSearchTestResolver(t)
+	ctx := createReadOnlyContext("user-1")
+
+	for _, limit := range []*int{intPtr(0), intPtr(1001)} {
+		_, err := resolver.Query().SearchFiles(ctx, "beach", nil, nil, limit, nil, nil)
+		var gqlErr *gqlerror.Error
+		require.ErrorAs(t, err, &gqlErr)
+		assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+	}
+	_, err := resolver.Query().SearchFiles(ctx, "   ", nil, nil, nil, nil, nil)
+	assert.Error(t, err)
+}
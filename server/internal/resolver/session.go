@@ -0,0 +1,99 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/sessionstore"
+	"github.com/cshum/imagor-studio/server/pkg/auth"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// requireSessionUser returns the claims of a user account session. Guests,
+// shared link, preview and embedded sessions have no login sessions.
+func (r *Resolver) requireSessionUser(ctx context.Context) (*auth.Claims, error) {
+	if r.sessionStore == nil {
+		return nil, &gqlerror.Error{
+			Message:    "login sessions are not available on this server",
+			Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+		}
+	}
+	claims, err := auth.GetClaimsFromContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unauthorized")
+	}
+	if IsGuestUser(ctx) || IsShareLinkSession(ctx) || IsPublicPreviewMode(ctx) || claims.IsEmbedded {
+		return nil, fmt.Errorf("login sessions require a user account")
+	}
+	return claims, nil
+}
+
+func toGQLSession(session *sessionstore.Session, currentID string) *gql.Session {
+	return &gql.Session{
+		ID:         session.ID,
+		UserID:     session.UserID,
+		UserAgent:  session.UserAgent,
+		ClientIP:   session.ClientIP,
+		CreatedAt:  session.CreatedAt.Format(time.RFC3339),
+		LastUsedAt: session.LastUsedAt.Format(time.RFC3339),
+		ExpiresAt:  session.ExpiresAt.Format(time.RFC3339),
+		Current:    session.ID == currentID,
+	}
+}
+
+// Sessions is the resolver for the sessions field.
+func (r *queryResolver) Sessions(ctx context.Context, userID *string) ([]*gql.Session, error) {
+	claims, err := r.requireSessionUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	targetID := claims.UserID
+	if userID != nil && *userID != claims.UserID {
+		if err := RequireAdminPermission(ctx); err != nil {
+			return nil, err
+		}
+		targetID = *userID
+	}
+	sessions, err := r.sessionStore.List(ctx, targetID)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*gql.Session, 0, len(sessions))
+	for _, session := range sessions {
+		result = append(result, toGQLSession(session, claims.SessionID))
+	}
+	return result, nil
+}
+
+// RevokeSession is the resolver for the revokeSession field.
+func (r *mutationResolver) RevokeSession(ctx context.Context, sessionID string) (bool, error) {
+	claims, err := r.requireSessionUser(ctx)
+	if err != nil {
+		return false, err
+	}
+	notFound := &gqlerror.Error{
+		Message:    sessionstore.ErrNotFound.Error(),
+		Extensions: map[string]interface{}{"code": "NOT_FOUND"},
+	}
+	session, err := r.sessionStore.Get(ctx, sessionID)
+	if errors.Is(err, sessionstore.ErrNotFound) {
+		return false, notFound
+	}
+	if err != nil {
+		return false, err
+	}
+	// Sessions of other users are reported missing to non-admins
+	if session.UserID != claims.UserID && RequireAdminPermission(ctx) != nil {
+		return false, notFound
+	}
+	if err := r.sessionStore.Revoke(ctx, sessionID); err != nil {
+		if errors.Is(err, sessionstore.ErrNotFound) {
+			return false, notFound
+		}
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,90 @@
+package resolver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/sessionstore"
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/cshum/imagor-studio/server/pkg/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func newSessionTestResolver(t *testing.T) (*Resolver, sessionstore.Store) {
+	t.Helper()
+	db := testutil.NewDB(t)
+
+	logger := zap.NewNop()
+	store := sessionstore.New(db, logger, time.Hour)
+	return newTestResolver(nil, new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, logger,
+		WithSessionStore(store)), store
+}
+
+// sessionContext returns a read-write context of userID signed in with
+// sessionID
+func sessionContext(userID, sessionID string) context.Context {
+	ctx := auth.SetClaimsInContext(context.Background(), &auth.Claims{
+		UserID: userID, Role: "user", Scopes: []string{"read", "write"}, SessionID: sessionID,
+	})
+	return context.WithValue(ctx, UserIDContextKey, userID)
+}
+
+func TestSessions_ListRevoke(t *testing.T) {
+	resolver, store := newSessionTestResolver(t)
+	bg := context.Background()
+	current, _, err := store.Create(bg, "user-1", "", sessionstore.Client{UserAgent: "Firefox", IP: "192.0.2.1"})
+	require.NoError(t, err)
+	other, _, err := store.Create(bg, "user-1", "", sessionstore.Client{UserAgent: "Safari"})
+	require.NoError(t, err)
+	foreign, _, err := store.Create(bg, "user-2", "", sessionstore.Client{})
+	require.NoError(t, err)
+	ctx := sessionContext("user-1", current.ID)
+
+	sessions, err := resolver.Query().Sessions(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	for _, session := range sessions {
+		assert.Equal(t, session.ID == current.ID, session.Current)
+	}
+
+	// Sessions of other users are hidden from non-admins
+	_, err = resolver.Query().Sessions(ctx, stringPtr("user-2"))
+	assert.Error(t, err)
+	_, err = resolver.Mutation().RevokeSession(ctx, foreign.ID)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_FOUND", gqlErr.Extensions["code"])
+
+	revoked, err := resolver.Mutation().RevokeSession(ctx, other.ID)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+	_, err = resolver.Mutation().RevokeSession(ctx, other.ID)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_FOUND", gqlErr.Extensions["code"])
+
+	// Admins manage the sessions of any user
+	admin := createAdminContext("admin-1")
+	sessions, err = resolver.Query().Sessions(admin, stringPtr("user-2"))
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	revoked, err = resolver.Mutation().RevokeSession(admin, foreign.ID)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+}
+
+func TestSessions_Restrictions(t *testing.T) {
+	resolver, _ := newSessionTestResolver(t)
+	_, err := resolver.Query().Sessions(createGuestContext("guest-1"), nil)
+	assert.Error(t, err)
+
+	resolver = newTestResolver(nil, new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop())
+	_, err = resolver.Query().Sessions(createReadWriteContext("user-1"), nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_AVAILABLE", gqlErr.Extensions["code"])
+}
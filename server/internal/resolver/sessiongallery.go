@@ -0,0 +1,177 @@
+package resolver
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/cshum/imagor-studio/server/internal/auditlog"
+	"github.com/cshum/imagor-studio/server/internal/davserver"
+	"github.com/cshum/imagor-studio/server/internal/s3gateway"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+)
+
+// sessionGallery serves the default storage over WebDAV and the S3 gateway
+// with the permissions and home path of the request session, changing files
+// the way the GraphQL mutations do. Uploads are stored at the path written,
+// without upload routing or duplicate detection.
+//
+// Each operation stands for a GraphQL root field, see the gallery* fields:
+// the operation allow-list of the session role is checked against it and
+// changes are recorded in the audit log under its name, as for GraphQL.
+// None of the fields or arguments involved is deprecated, so strict API
+// mode has nothing to refuse.
+type sessionGallery struct {
+	r *Resolver
+}
+
+// Root fields the gallery operations stand for
+const (
+	galleryReadField   = "Query.listFiles"
+	galleryUploadField = "Mutation.uploadFile"
+	galleryMkdirField  = "Mutation.createFolder"
+	galleryDeleteField = "Mutation.deleteFile"
+	galleryMoveField   = "Mutation.moveFile"
+)
+
+// change runs the change of files run stands for field, once the
+// allow-list lets it through, and records it in the audit log at the
+// storage keys of the change, see galleryKey
+func (g sessionGallery) change(ctx context.Context, field, path, destPath string, run func() error) error {
+	if err := g.r.operationAllowList.Check(ctx, field); err != nil {
+		return err
+	}
+	err := run()
+	if g.r.auditLog != nil {
+		auditlog.RecordOperation(ctx, g.r.auditLog, g.r.logger, &auditlog.Entry{
+			Operation: strings.TrimPrefix(field, "Mutation."),
+			Path:      path,
+			DestPath:  destPath,
+		}, err)
+	}
+	return err
+}
+
+// DAVGallery returns the gallery served by the WebDAV endpoint
+func (r *Resolver) DAVGallery() davserver.Gallery {
+	return sessionGallery{r: r}
+}
+
+// S3Gallery returns the gallery served by the S3 gateway
+func (r *Resolver) S3Gallery() s3gateway.Gallery {
+	return sessionGallery{r: r}
+}
+
+func (g sessionGallery) Storage(ctx context.Context, name string) (storage.Storage, string, error) {
+	if err := g.r.operationAllowList.Check(ctx, galleryReadField); err != nil {
+		return nil, "", err
+	}
+	key, err := ScopePath(ctx, name)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := RequireReadPermission(ctx, key); err != nil {
+		return nil, "", err
+	}
+	stor, err := g.r.getSpaceStorageByID(ctx, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	return stor, key, nil
+}
+
+func (g sessionGallery) Writable(ctx context.Context, name string) error {
+	if err := g.r.operationAllowList.Check(ctx, galleryUploadField); err != nil {
+		return err
+	}
+	key, err := ScopePath(ctx, name)
+	if err != nil {
+		return err
+	}
+	return RequireWritePermission(ctx, key)
+}
+
+// galleryKey returns the storage key name is changed at, scoped as the
+// GraphQL mutations do and normalized first for names created. Failing,
+// name itself is returned for the audit log.
+func galleryKey(ctx context.Context, name string, create bool) (string, error) {
+	key := name
+	var err error
+	if create {
+		if key, err = normalizeNewPath(key); err != nil {
+			return name, err
+		}
+	}
+	if key, err = ScopePath(ctx, key); err != nil {
+		return name, err
+	}
+	return key, nil
+}
+
+func (g sessionGallery) Put(ctx context.Context, name string, content io.Reader, size int64) error {
+	key, err := galleryKey(ctx, name, true)
+	return g.change(ctx, galleryUploadField, key, "", func() error {
+		if err != nil {
+			return err
+		}
+		return g.put(ctx, key, content, size)
+	})
+}
+
+func (g sessionGallery) put(ctx context.Context, key string, content io.Reader, size int64) error {
+	if err := RequireWritePermission(ctx, key); err != nil {
+		return err
+	}
+	stor, sp, err := g.r.resolveUploadStorageTarget(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := ensureSpaceUploadAllowed(sp); err != nil {
+		return err
+	}
+	if err := g.r.enforceHostedStorageQuota(ctx, sp, size); err != nil {
+		return err
+	}
+	if err := g.r.enforceStorageQuotas(ctx, sp, key, size); err != nil {
+		return err
+	}
+	_, err = g.r.storeUpload(ctx, stor, sp, key, g.r.fixedPathCollisionMode(), content, size)
+	return err
+}
+
+func (g sessionGallery) Mkdir(ctx context.Context, name string) error {
+	key, err := galleryKey(ctx, name, true)
+	return g.change(ctx, galleryMkdirField, key, "", func() error {
+		if err != nil {
+			return err
+		}
+		_, err := g.r.Mutation().CreateFolder(ctx, key, nil)
+		return err
+	})
+}
+
+func (g sessionGallery) Remove(ctx context.Context, name string) error {
+	key, err := galleryKey(ctx, name, false)
+	return g.change(ctx, galleryDeleteField, key, "", func() error {
+		if err != nil {
+			return err
+		}
+		_, err := g.r.Mutation().DeleteFile(ctx, key, nil)
+		return err
+	})
+}
+
+func (g sessionGallery) Move(ctx context.Context, oldName, newName string) error {
+	oldKey, oldErr := galleryKey(ctx, oldName, false)
+	newKey, newErr := galleryKey(ctx, newName, true)
+	return g.change(ctx, galleryMoveField, oldKey, newKey, func() error {
+		if oldErr != nil {
+			return oldErr
+		}
+		if newErr != nil {
+			return newErr
+		}
+		_, err := g.r.Mutation().MoveFile(ctx, oldKey, newKey, nil)
+		return err
+	})
+}
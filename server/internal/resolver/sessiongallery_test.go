@@ -0,0 +1,164 @@
+package resolver
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/allowlist"
+	"github.com/cshum/imagor-studio/server/internal/auditlog"
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/davserver"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func TestSessionGallery_AllowListAndAuditLog(t *testing.T) {
+	baseDir := t.TempDir()
+	writeTestFile(t, baseDir, "photos/a.jpg")
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+
+	mockRegistryStore := new(MockRegistryStore)
+	mockRegistryStore.On("GetMulti", mock.Anything, mock.Anything, mock.Anything).Return([]*registrystore.Registry{}, nil)
+	mockRegistryStore.On("Set", mock.Anything, registrystore.SystemOwnerID, allowlist.Key("user"), mock.Anything, false).
+		Return(&registrystore.Registry{}, nil)
+	allowList := allowlist.New(mockRegistryStore, zap.NewNop())
+	_, err = allowList.Set(context.Background(), "user", []string{"Query.listFiles", "Mutation.uploadFile", "Mutation.moveFile"})
+	require.NoError(t, err)
+	auditLog := auditlog.New(testutil.NewDB(t), zap.NewNop())
+
+	resolver := newTestResolver(NewMockStorageProvider(stor), mockRegistryStore, new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop(),
+		WithOperationAllowList(allowList), WithAuditLog(auditLog))
+	gallery := resolver.DAVGallery()
+	ctx := auditlog.WithClientIP(createReadWriteContext("user-1"), "192.0.2.1")
+
+	_, key, err := gallery.Storage(ctx, "photos/a.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, "photos/a.jpg", key)
+	require.NoError(t, gallery.Put(ctx, "photos/b.jpg", strings.NewReader("new"), 3))
+	assert.Error(t, gallery.Move(ctx, "photos/missing.jpg", "photos/c.jpg"))
+
+	// Operations outside the allow-list are refused before they run
+	err = gallery.Remove(ctx, "photos/a.jpg")
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "FORBIDDEN", gqlErr.Extensions["code"])
+	_, err = os.Stat(filepath.Join(baseDir, "photos/a.jpg"))
+	assert.NoError(t, err)
+	assert.Error(t, gallery.Mkdir(ctx, "photos/new"))
+
+	entries, total, err := auditLog.List(context.Background(), auditlog.Filter{}, 0, 10)
+	require.NoError(t, err)
+	require.Equal(t, 2, total)
+	byOperation := map[string]*auditlog.Entry{}
+	for _, entry := range entries {
+		byOperation[entry.Operation] = entry
+	}
+	require.Contains(t, byOperation, "uploadFile")
+	assert.Equal(t, "photos/b.jpg", byOperation["uploadFile"].Path)
+	assert.Equal(t, "user-1", byOperation["uploadFile"].UserID)
+	assert.Equal(t, "192.0.2.1", byOperation["uploadFile"].ClientIP)
+	assert.Empty(t, byOperation["uploadFile"].Error)
+	require.Contains(t, byOperation, "moveFile")
+	assert.Equal(t, "photos/c.jpg", byOperation["moveFile"].DestPath)
+	assert.NotEmpty(t, byOperation["moveFile"].Error)
+
+	// Reads are checked against listFiles
+	_, err = allowList.Set(context.Background(), "user", []string{"Mutation.uploadFile"})
+	require.NoError(t, err)
+	_, _, err = gallery.Storage(ctx, "photos/a.jpg")
+	assert.ErrorContains(t, err, "Query.listFiles is not allowed")
+}
+
+func TestSessionGallery_AuditsScopedPaths(t *testing.T) {
+	baseDir := t.TempDir()
+	writeTestFile(t, baseDir, "users/bob/photos/a.jpg")
+	writeTestFile(t, baseDir, "photos/a.jpg")
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+	auditLog := auditlog.New(testutil.NewDB(t), zap.NewNop())
+	resolver := newTestResolver(NewMockStorageProvider(stor), new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop(),
+		WithAuditLog(auditLog))
+	gallery := resolver.DAVGallery()
+	ctx := WithHomePath(createReadWriteContext("user-1"), "users/bob")
+
+	// Removals resolve below the home path like every other change
+	require.NoError(t, gallery.Mkdir(ctx, "photos/new"))
+	require.NoError(t, gallery.Move(ctx, "photos/new", "photos/renamed"))
+	require.NoError(t, gallery.Remove(ctx, "photos/a.jpg"))
+	assert.NoFileExists(t, filepath.Join(baseDir, "users/bob/photos/a.jpg"))
+	assert.FileExists(t, filepath.Join(baseDir, "photos/a.jpg"))
+	assert.Error(t, gallery.Remove(ctx, "../photos/a.jpg"))
+
+	entries, total, err := auditLog.List(context.Background(), auditlog.Filter{}, 0, 10)
+	require.NoError(t, err)
+	require.Equal(t, 4, total)
+	var paths []string
+	for _, entry := range entries {
+		paths = append(paths, entry.Operation+" "+entry.Path+" "+entry.DestPath)
+	}
+	assert.ElementsMatch(t, []string{
+		"createFolder users/bob/photos/new ",
+		"moveFile users/bob/photos/new users/bob/photos/renamed",
+		"deleteFile users/bob/photos/a.jpg ",
+		"deleteFile ../photos/a.jpg ",
+	}, paths)
+}
+
+func TestSessionGallery_WebDAVNormalizesNewNames(t *testing.T) {
+	baseDir := t.TempDir()
+	writeTestFile(t, baseDir, "photos/a.jpg")
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+	resolver := newTestResolver(NewMockStorageProvider(stor), new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop())
+	handler := davserver.NewHandler(resolver.DAVGallery(), "/dav/", zap.NewNop())
+	ctx := createReadWriteContext("user-1")
+	serve := func(method, target string, headers map[string]string) int {
+		var body io.Reader
+		if method == http.MethodPut {
+			body = strings.NewReader("new")
+		}
+		req := httptest.NewRequest(method, target, body).WithContext(ctx)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Names written in NFD, as macOS clients do, are stored in NFC
+	nfd := url.PathEscape("Cafe\u0301")
+	assert.Equal(t, http.StatusCreated, serve("MKCOL", "/dav/"+nfd, nil))
+	assert.DirExists(t, filepath.Join(baseDir, "Caf\u00e9"))
+	assert.Equal(t, http.StatusCreated, serve(http.MethodPut, "/dav/photos/"+nfd+".jpg", nil))
+	assert.FileExists(t, filepath.Join(baseDir, "photos", "Caf\u00e9.jpg"))
+	assert.Equal(t, http.StatusCreated, serve("MOVE", "/dav/photos/a.jpg", map[string]string{
+		"Destination": "/dav/photos/" + nfd + "-moved.jpg",
+	}))
+	assert.FileExists(t, filepath.Join(baseDir, "photos", "Caf\u00e9-moved.jpg"))
+
+	// Backslashes are refused rather than stored in names
+	assert.GreaterOrEqual(t, serve(http.MethodPut, "/dav/photos/a%5Cb.jpg", nil), 400)
+	assert.GreaterOrEqual(t, serve("MKCOL", "/dav/a%5Cb", nil), 400)
+	entries, err := os.ReadDir(filepath.Join(baseDir, "photos"))
+	require.NoError(t, err)
+	for _, entry := range entries {
+		assert.NotContains(t, entry.Name(), "\\")
+	}
+	_, err = os.Stat(filepath.Join(baseDir, "a\\b"))
+	assert.True(t, os.IsNotExist(err))
+}
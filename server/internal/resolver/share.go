@@ -0,0 +1,107 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/sharestore"
+	"github.com/cshum/imagor-studio/server/pkg/auth"
+	"github.com/cshum/imagor-studio/server/pkg/validation"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+// CreateShareLink is the resolver for the createShareLink field.
+func (r *mutationResolver) CreateShareLink(ctx context.Context, sharePath string, expiresAt string, allowDownload *bool, password *string) (*gql.ShareLink, error) {
+	if r.shareStore == nil {
+		return nil, &gqlerror.Error{
+			Message:    "shared links are not available",
+			Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+		}
+	}
+	sharePath, err := ScopePath(ctx, sharePath)
+	if err != nil {
+		return nil, err
+	}
+	sharePath = strings.Trim(path.Clean("/"+sharePath), "/")
+	if sharePath == "" {
+		return nil, &gqlerror.Error{
+			Message:    "the storage root cannot be shared",
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	if err := RequireWritePermission(ctx, sharePath); err != nil {
+		return nil, err
+	}
+
+	expiry, err := time.Parse(time.RFC3339, expiresAt)
+	if err != nil {
+		return nil, &gqlerror.Error{
+			Message:    "expiresAt must be an RFC 3339 timestamp",
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	if !expiry.After(time.Now()) {
+		return nil, &gqlerror.Error{
+			Message:    "expiresAt must be in the future",
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+
+	passwordHash := ""
+	if password != nil && *password != "" {
+		if err := validation.ValidatePassword(*password); err != nil {
+			return nil, &gqlerror.Error{
+				Message:    err.Error(),
+				Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+			}
+		}
+		passwordHash, err = auth.HashPassword(*password)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash share link password: %w", err)
+		}
+	}
+
+	if _, err := r.getStorage().Stat(ctx, sharePath); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, &gqlerror.Error{
+				Message:    fmt.Sprintf("%s not found", sharePath),
+				Extensions: map[string]interface{}{"code": "NOT_FOUND"},
+			}
+		}
+		return nil, fmt.Errorf("failed to stat shared path: %w", err)
+	}
+
+	userID, _ := GetUserIDFromContext(ctx)
+	link, err := r.shareStore.Create(ctx, sharePath, userID, allowDownload != nil && *allowDownload, expiry, passwordHash)
+	if err != nil {
+		r.logger.Error("Failed to create share link", zap.String("path", sharePath), zap.Error(err))
+		return nil, fmt.Errorf("failed to create share link: %w", err)
+	}
+	r.logger.Info("Share link created",
+		zap.String("shareLinkID", link.ID),
+		zap.String("path", link.Path),
+		zap.String("createdByUserID", userID),
+		zap.Bool("passwordProtected", link.PasswordProtected()),
+		zap.Time("expiresAt", link.ExpiresAt))
+	return r.toGQLShareLink(link), nil
+}
+
+func (r *Resolver) toGQLShareLink(link *sharestore.ShareLink) *gql.ShareLink {
+	return &gql.ShareLink{
+		ID:                link.ID,
+		Token:             link.Token,
+		Path:              link.Path,
+		URL:               r.shareBaseURL + "/share/" + link.Token,
+		AllowDownload:     link.AllowDownload,
+		PasswordProtected: link.PasswordProtected(),
+		ExpiresAt:         link.ExpiresAt.UTC().Format(time.RFC3339),
+		CreatedAt:         link.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
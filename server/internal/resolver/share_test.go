@@ -0,0 +1,143 @@
+package resolver
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/sharestore"
+	"github.com/cshum/imagor-studio/server/pkg/auth"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type MockShareStore struct {
+	mock.Mock
+}
+
+func (m *MockShareStore) Create(ctx context.Context, path, createdBy string, allowDownload bool, expiresAt time.Time, passwordHash string) (*sharestore.ShareLink, error) {
+	args := m.Called(ctx, path, createdBy, allowDownload, expiresAt, passwordHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*sharestore.ShareLink), args.Error(1)
+}
+
+func (m *MockShareStore) GetByToken(ctx context.Context, token string) (*sharestore.ShareLink, error) {
+	args := m.Called(ctx, token)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*sharestore.ShareLink), args.Error(1)
+}
+
+func TestCreateShareLink(t *testing.T) {
+	mockStorage := new(MockStorage)
+	mockShareStore := new(MockShareStore)
+	logger, _ := zap.NewDevelopment()
+	resolver := newTestResolver(NewMockStorageProvider(mockStorage), new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, logger,
+		WithShareStore(mockShareStore, "https://studio.example.com/"))
+	ctx := createReadWriteContext("test-user-id")
+	expiresAt := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+
+	mockStorage.On("Stat", ctx, "albums/summer").Return(storage.FileInfo{Name: "summer", Path: "albums/summer", IsDir: true}, nil)
+	mockShareStore.On("Create", ctx, "albums/summer", "test-user-id", true, mock.Anything, "").Return(&sharestore.ShareLink{
+		ID:            "share-1",
+		Token:         "abc123",
+		Path:          "albums/summer",
+		AllowDownload: true,
+		ExpiresAt:     expiresAt,
+		CreatedAt:     time.Now(),
+	}, nil)
+
+	result, err := resolver.Mutation().CreateShareLink(ctx, "/albums/summer/", expiresAt.Format(time.RFC3339), boolPtr(true), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "abc123", result.Token)
+	assert.Equal(t, "albums/summer", result.Path)
+	assert.Equal(t, "https://studio.example.com/share/abc123", result.URL)
+	assert.True(t, result.AllowDownload)
+	assert.False(t, result.PasswordProtected)
+	assert.Equal(t, expiresAt.Format(time.RFC3339), result.ExpiresAt)
+	mockShareStore.AssertExpectations(t)
+}
+
+func TestCreateShareLink_Password(t *testing.T) {
+	mockStorage := new(MockStorage)
+	mockShareStore := new(MockShareStore)
+	logger, _ := zap.NewDevelopment()
+	resolver := newTestResolver(NewMockStorageProvider(mockStorage), new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, logger,
+		WithShareStore(mockShareStore, ""))
+	ctx := createReadWriteContext("test-user-id")
+
+	mockStorage.On("Stat", ctx, "albums/private").Return(storage.FileInfo{Name: "private", Path: "albums/private", IsDir: true}, nil)
+	var passwordHash string
+	mockShareStore.On("Create", ctx, "albums/private", "test-user-id", false, mock.Anything, mock.MatchedBy(func(hash string) bool {
+		passwordHash = hash
+		return auth.CheckPassword(hash, "summer-2024") == nil
+	})).Return(&sharestore.ShareLink{ID: "share-1", Token: "abc123", Path: "albums/private", PasswordHash: "hashed", ExpiresAt: time.Now().Add(time.Hour)}, nil)
+
+	result, err := resolver.Mutation().CreateShareLink(ctx, "albums/private", time.Now().Add(time.Hour).Format(time.RFC3339), nil, stringPtr("summer-2024"))
+	require.NoError(t, err)
+	assert.True(t, result.PasswordProtected)
+	assert.NotEqual(t, "summer-2024", passwordHash)
+	assert.Equal(t, "/share/abc123", result.URL)
+}
+
+func TestCreateShareLink_Rejected(t *testing.T) {
+	mockStorage := new(MockStorage)
+	mockShareStore := new(MockShareStore)
+	logger, _ := zap.NewDevelopment()
+	resolver := newTestResolver(NewMockStorageProvider(mockStorage), new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, logger,
+		WithShareStore(mockShareStore, ""))
+	ctx := createReadWriteContext("test-user-id")
+	future := time.Now().Add(time.Hour).Format(time.RFC3339)
+
+	mockStorage.On("Stat", ctx, "missing.jpg").Return(storage.FileInfo{}, os.ErrNotExist)
+
+	_, err := resolver.Mutation().CreateShareLink(ctx, "/", future, nil, nil)
+	assert.ErrorContains(t, err, "storage root cannot be shared")
+	_, err = resolver.Mutation().CreateShareLink(ctx, "photo.jpg", time.Now().Add(-time.Hour).Format(time.RFC3339), nil, nil)
+	assert.ErrorContains(t, err, "must be in the future")
+	_, err = resolver.Mutation().CreateShareLink(ctx, "photo.jpg", "tomorrow", nil, nil)
+	assert.ErrorContains(t, err, "RFC 3339")
+	_, err = resolver.Mutation().CreateShareLink(ctx, "missing.jpg", future, nil, nil)
+	assert.ErrorContains(t, err, "not found")
+	_, err = resolver.Mutation().CreateShareLink(createReadOnlyContext("test-user-id"), "photo.jpg", future, nil, nil)
+	assert.Error(t, err)
+
+	disabled := newTestResolver(NewMockStorageProvider(mockStorage), new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, logger)
+	_, err = disabled.Mutation().CreateShareLink(ctx, "photo.jpg", future, nil, nil)
+	assert.ErrorContains(t, err, "not available")
+
+	_, err = resolver.Mutation().CreateShareLink(ctx, "photo.jpg", future, nil, stringPtr("short"))
+	assert.ErrorContains(t, err, "at least 8 characters")
+
+	mockShareStore.AssertNotCalled(t, "Create", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCanDownloadOriginals(t *testing.T) {
+	assert.True(t, CanDownloadOriginals(context.Background()))
+	assert.True(t, CanDownloadOriginals(createReadOnlyContext("test-user-id")))
+
+	share := auth.SetClaimsInContext(context.Background(), &auth.Claims{
+		UserID: "guest-id", Role: "guest", Scopes: []string{"read"}, Kind: auth.ShareLinkTokenKind,
+	})
+	assert.True(t, IsShareLinkSession(share))
+	assert.False(t, CanDownloadOriginals(share))
+
+	shareWithDownload := auth.SetClaimsInContext(context.Background(), &auth.Claims{
+		UserID: "guest-id", Role: "guest", Scopes: []string{"read", "download"}, Kind: auth.ShareLinkTokenKind,
+	})
+	assert.True(t, CanDownloadOriginals(shareWithDownload))
+
+	watermarked := auth.SetClaimsInContext(context.Background(), &auth.Claims{
+		UserID: "viewer-id", Role: "guest", Scopes: []string{"read", "edit"}, IsEmbedded: true,
+		Watermark: &auth.Watermark{Image: "logo.png"},
+	})
+	assert.False(t, CanDownloadOriginals(watermarked))
+}
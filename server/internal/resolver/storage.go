@@ -2,21 +2,30 @@ package resolver
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/99designs/gqlgen/graphql"
+	"github.com/cshum/imagor-studio/server/internal/events"
+	"github.com/cshum/imagor-studio/server/internal/filemeta"
 	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/listcache"
+	"github.com/cshum/imagor-studio/server/internal/mediaclass"
+	"github.com/cshum/imagor-studio/server/internal/ratingstore"
+	"github.com/cshum/imagor-studio/server/internal/rawpreview"
 	"github.com/cshum/imagor-studio/server/internal/registrystore"
 	"github.com/cshum/imagor-studio/server/internal/registryutil"
 	"github.com/cshum/imagor-studio/server/internal/storageprovider"
 	"github.com/cshum/imagor-studio/server/pkg/apperror"
 	"github.com/cshum/imagor-studio/server/pkg/billing"
 	"github.com/cshum/imagor-studio/server/pkg/management"
+	"github.com/cshum/imagor-studio/server/pkg/safepath"
 	"github.com/cshum/imagor-studio/server/pkg/space"
 	"github.com/cshum/imagor-studio/server/pkg/storage"
 	"github.com/vektah/gqlparser/v2/gqlerror"
@@ -29,6 +38,13 @@ const hostedUploadIntentTTL = 5 * time.Minute
 
 const uploadHeaderIfNoneMatch = "If-None-Match"
 
+// Policies of upload-collision
+const (
+	uploadCollisionOverwrite = "overwrite"
+	uploadCollisionRename    = "rename"
+	uploadCollisionReject    = "reject"
+)
+
 func supportsPresignedUpload(stor storage.Storage) bool {
 	_, ok := stor.(storage.PresignableStorage)
 	return ok
@@ -252,7 +268,7 @@ func (r *Resolver) enforceHostedStorageQuota(ctx context.Context, sp *space.Spac
 	return apperror.BadRequest("hosted storage quota exceeded", map[string]interface{}{"reason": "hosted_storage_quota_exceeded"})
 }
 
-func (r *queryResolver) getEffectiveVideoThumbnailPosition(ctx context.Context, spaceConfig *space.Space) string {
+func (r *Resolver) getEffectiveVideoThumbnailPosition(ctx context.Context, spaceConfig *space.Space) string {
 	defaultValue := "first_frame"
 	if r.config != nil {
 		configValue, isOverridden := r.config.GetByRegistryKey("config.app_video_thumbnail_position")
@@ -283,6 +299,77 @@ func (r *queryResolver) getEffectiveVideoThumbnailPosition(ctx context.Context,
 	return defaultValue
 }
 
+// getMediaClassifier returns the classifier built from the registry rules,
+// falling back to the built-in defaults when the rules are unset or invalid.
+func (r *Resolver) getMediaClassifier(ctx context.Context) *mediaclass.Classifier {
+	result := registryutil.GetEffectiveValue(ctx, r.registryStore, r.config, mediaclass.RegistryKey)
+	classifier, err := mediaclass.FromRegistryValue(result.Value)
+	if err != nil {
+		r.logger.Warn("Invalid media classification rules, using defaults", zap.Error(err))
+	}
+	return classifier
+}
+
+// classifyFileInfo returns the system tags for a listed file. metadata is
+// the indexed metadata of the file, nil when unknown, in which case only
+// filename based rules can match.
+func classifyFileInfo(classifier *mediaclass.Classifier, item storage.FileInfo, metadata *filemeta.Metadata) []string {
+	if item.IsDir {
+		return []string{}
+	}
+	subject := mediaclass.Subject{Name: item.Name}
+	if metadata != nil {
+		subject.Width = metadata.Width
+		subject.Height = metadata.Height
+		subject.Software = metadata.Software
+	}
+	tags := classifier.Classify(subject)
+	if tags == nil {
+		return []string{}
+	}
+	return tags
+}
+
+// classificationMetadata returns the indexed metadata of the files in items,
+// keyed by path, when classifier has rules matching on it. Files are not
+// read, those missing from the index or changed since are left out.
+func (r *Resolver) classificationMetadata(ctx context.Context, spaceConfig *space.Space, classifier *mediaclass.Classifier, items []storage.FileInfo) map[string]*filemeta.Metadata {
+	if r.fileMetaStore == nil || !classifier.UsesMetadata() {
+		return nil
+	}
+	fingerprints := make(map[string]string)
+	for _, item := range items {
+		if !item.IsDir {
+			fingerprints[item.Path] = filemeta.Fingerprint(item)
+		}
+	}
+	if len(fingerprints) == 0 {
+		return nil
+	}
+	metadata, err := r.fileMetaStore.GetMulti(ctx, fileMetadataScope(spaceConfig), fingerprints)
+	if err != nil {
+		r.logger.Warn("Failed to read file metadata for classification", zap.Error(err))
+		return nil
+	}
+	return metadata
+}
+
+// paginateBounds returns the slice bounds for offset/limit pagination.
+// A limit of 0 means unlimited.
+func paginateBounds(total, offset, limit int) (int, int) {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > total {
+		offset = total
+	}
+	end := total
+	if limit > 0 && offset+limit < total {
+		end = offset + limit
+	}
+	return offset, end
+}
+
 // getSpaceStorageByID returns the storage instance for the given optional spaceID.
 //
 // When spaceID is nil/empty or cloud space mode is disabled,
@@ -322,40 +409,204 @@ func getPreviewPath(templatePath string) string {
 }
 
 // UploadFile is the resolver for the uploadFile field.
-func (r *mutationResolver) UploadFile(ctx context.Context, path string, spaceID *string, content graphql.Upload) (bool, error) {
+func (r *mutationResolver) UploadFile(ctx context.Context, path string, spaceID *string, content graphql.Upload, onCollision *gql.UploadCollisionPolicy) (bool, error) {
+	if _, err := r.uploadFile(ctx, path, spaceID, content, onCollision); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// UploadFileWithResult is the resolver for the uploadFileWithResult field.
+func (r *mutationResolver) UploadFileWithResult(ctx context.Context, path string, spaceID *string, content graphql.Upload, onCollision *gql.UploadCollisionPolicy) (*gql.UploadResult, error) {
+	return r.uploadFile(ctx, path, spaceID, content, onCollision)
+}
+
+func (r *Resolver) uploadFile(ctx context.Context, path string, spaceID *string, content graphql.Upload, onCollision *gql.UploadCollisionPolicy) (*gql.UploadResult, error) {
+	path, err := normalizeNewPath(path)
+	if err != nil {
+		return nil, err
+	}
+	path, err = r.routeUploadPath(ctx, path, content.ContentType)
+	if err != nil {
+		return nil, err
+	}
 	// Check write permissions and path access
 	if err := RequireWritePermission(ctx, path); err != nil {
-		return false, err
+		return nil, err
 	}
 	stor, sp, err := r.resolveUploadStorageTarget(ctx, spaceID)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 	if err := ensureSpaceUploadAllowed(sp); err != nil {
-		return false, err
+		return nil, err
+	}
+	duplicate, err := r.findDuplicateUpload(ctx, stor, sp, path, content)
+	if err != nil {
+		return nil, err
+	}
+	if duplicate.existing != "" {
+		return &gql.UploadResult{Path: duplicate.existing, Deduplicated: true}, nil
 	}
 	if err := r.enforceHostedStorageQuota(ctx, sp, content.Size); err != nil {
-		return false, err
+		return nil, err
+	}
+	if err := r.enforceStorageQuotas(ctx, sp, path, content.Size); err != nil {
+		return nil, err
+	}
+	r.logger.Debug("Uploading file", zap.String("path", path), zap.String("filename", content.Filename))
+	path, err = r.storeUpload(ctx, stor, sp, path, r.uploadCollisionMode(onCollision), content.File, content.Size)
+	if err != nil {
+		return nil, err
+	}
+	if duplicate.hash != "" {
+		r.recordUploadHash(ctx, stor, sp, path, duplicate.hash)
+	}
+	return &gql.UploadResult{Path: path}, nil
+}
+
+// normalizeNewPath normalizes the path of a file or folder to create with
+// safepath.Normalize, so new names are stored in one Unicode form and names
+// the storage backends cannot store are rejected
+func normalizeNewPath(requestedPath string) (string, error) {
+	if err := validatePath(requestedPath); err != nil {
+		return "", err
 	}
+	normalized, err := safepath.Normalize(requestedPath)
+	if err != nil {
+		return "", &gqlerror.Error{
+			Message:    err.Error(),
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	return normalized, nil
+}
+
+// uploadCollisionMode returns the collision policy of an upload, policy when
+// given, else the upload-collision setting
+func (r *Resolver) uploadCollisionMode(policy *gql.UploadCollisionPolicy) string {
+	if policy != nil {
+		return strings.ToLower(string(*policy))
+	}
+	if r.uploadCollision == "" {
+		return uploadCollisionOverwrite
+	}
+	return r.uploadCollision
+}
+
+// fixedPathCollisionMode returns the collision policy of uploads to a path
+// the client keeps, such as chunked uploads and writes of the WebDAV and S3
+// mounts. Their clients cannot learn of another name, so renaming rejects
+// them instead.
+func (r *Resolver) fixedPathCollisionMode() string {
+	if mode := r.uploadCollisionMode(nil); mode != uploadCollisionRename {
+		return mode
+	}
+	return uploadCollisionReject
+}
+
+// resolveUploadCollision returns the path an upload to path is stored at
+// under mode: path itself when free or overwritten, the first free numbered
+// name when renaming. Rejected uploads fail with uploadCollisionError. Only
+// paths reported as storage.ErrNotFound are free.
+func (r *Resolver) resolveUploadCollision(ctx context.Context, stor storage.Storage, path, mode string) (string, error) {
+	if mode == uploadCollisionOverwrite {
+		return path, nil
+	}
+	taken, err := pathTaken(ctx, stor, path)
+	if err != nil || !taken {
+		return path, err
+	}
+	if mode == uploadCollisionRename {
+		folder, name := "", path
+		if i := strings.LastIndex(path, "/"); i >= 0 {
+			folder, name = path[:i], path[i+1:]
+		}
+		renamed, err := availableUploadPath(ctx, stor, folder, name)
+		if err == nil {
+			return renamed, nil
+		}
+		var gqlErr *gqlerror.Error
+		if !errors.As(err, &gqlErr) {
+			return "", err
+		}
+	}
+	return "", uploadCollisionError(path)
+}
+
+// pathTaken reports whether a file or folder exists at path. Errors other
+// than storage.ErrNotFound are returned, as the path may well exist.
+func pathTaken(ctx context.Context, stor storage.Storage, path string) (bool, error) {
+	_, err := stor.Stat(ctx, path)
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, storage.ErrNotFound) {
+		return false, nil
+	}
+	return false, fmt.Errorf("failed to check %s: %w", path, err)
+}
+
+// uploadCollisionError rejects an upload to a path already taken, naming
+// the file in the way
+func uploadCollisionError(path string) error {
+	return &gqlerror.Error{
+		Message: fmt.Sprintf("failed to upload file: %s already exists", path),
+		Extensions: map[string]interface{}{
+			"code":         apperror.ErrCodeFileAlreadyExists,
+			"reason":       "upload_collision",
+			"existingPath": path,
+		},
+	}
+}
+
+// storeUpload writes an uploaded file to stor once its content is verified
+// against its extension and scanned for malware, recording it in the hosted
+// storage ledger of platform spaces. Uploads to a path already taken are
+// handled by the collision mode, see resolveUploadCollision, and the path the
+// file is stored at is returned. Unless overwriting, the path taken by a
+// concurrent upload in the meantime rejects the upload. size may be 0 when
+// unknown.
+func (r *Resolver) storeUpload(ctx context.Context, stor storage.Storage, sp *space.Space, path, mode string, content io.Reader, size int64) (string, error) {
 	if r.tracksHostedStorage(sp) {
 		if _, err := stor.Stat(ctx, path); err == nil {
-			return false, fileAlreadyExistsError("upload file")
+			return "", fileAlreadyExistsError("upload file")
 		}
 	}
-	r.logger.Debug("Uploading file", zap.String("path", path), zap.String("filename", content.Filename))
+	path, err := r.resolveUploadCollision(ctx, stor, path, mode)
+	if err != nil {
+		return "", err
+	}
 
-	if err := stor.Put(ctx, path, content.File); err != nil {
+	content, contentType, err := r.verifyUploadType(path, content)
+	if err != nil {
+		return "", err
+	}
+	content, release, err := r.scanUpload(ctx, path, content)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	exclusive := mode != uploadCollisionOverwrite || r.tracksHostedStorage(sp)
+	if err := putUpload(ctx, stor, path, content, exclusive); err != nil {
+		if errors.Is(err, storage.ErrExists) {
+			if r.tracksHostedStorage(sp) {
+				return "", fileAlreadyExistsError("upload file")
+			}
+			return "", uploadCollisionError(path)
+		}
 		r.logger.Error("Failed to upload file", zap.Error(err))
-		return false, fmt.Errorf("failed to upload file: %w", err)
+		return "", fmt.Errorf("failed to upload file: %w", err)
 	}
 	if r.tracksHostedStorage(sp) {
-		sizeBytes := content.Size
+		sizeBytes := size
 		if sizeBytes <= 0 {
 			info, err := stor.Stat(ctx, path)
 			if err != nil {
 				r.cleanupHostedUploadFailure(ctx, stor, sp, path, err)
 				r.logger.Error("Failed to stat uploaded hosted file", zap.Error(err), zap.String("spaceID", sp.ID), zap.String("path", path))
-				return false, fmt.Errorf("failed to stat uploaded file: %w", err)
+				return "", fmt.Errorf("failed to stat uploaded file: %w", err)
 			}
 			sizeBytes = info.Size
 		}
@@ -363,20 +614,46 @@ func (r *mutationResolver) UploadFile(ctx context.Context, path string, spaceID
 		if err := r.hostedStorageStore.BeginPendingUpload(ctx, sp.OrgID, sp.ID, path, expiresAt); err != nil {
 			r.cleanupHostedUploadFailure(ctx, stor, sp, path, err)
 			r.logger.Error("Failed to record pending hosted upload", zap.Error(err), zap.String("spaceID", sp.ID), zap.String("path", path))
-			return false, fmt.Errorf("failed to record upload intent: %w", err)
+			return "", fmt.Errorf("failed to record upload intent: %w", err)
 		}
 		if _, err := r.hostedStorageStore.FinalizePendingUpload(ctx, sp.ID, path, sizeBytes); err != nil {
 			r.cleanupHostedUploadFailure(ctx, stor, sp, path, err)
 			r.logger.Error("Failed to finalize hosted upload", zap.Error(err), zap.String("spaceID", sp.ID), zap.String("path", path), zap.Int64("sizeBytes", sizeBytes))
-			return false, fmt.Errorf("failed to finalize upload: %w", err)
+			return "", fmt.Errorf("failed to finalize upload: %w", err)
 		}
 	}
+	r.recordUpload(ctx, stor, sp, path, size)
+	r.recordUploadContentType(ctx, stor, sp, path, contentType)
+	r.publishSpaceUpload(sp, path)
 
-	return true, nil
+	return path, nil
+}
+
+// putUpload writes content to path, only if still free when exclusive and
+// stor can tell, so uploads racing past the collision check do not overwrite
+// one another. The loser fails with an error matching storage.ErrExists.
+func putUpload(ctx context.Context, stor storage.Storage, path string, content io.Reader, exclusive bool) error {
+	if exclusive {
+		if exclusiveStor, ok := stor.(storage.ExclusiveStorage); ok {
+			err := exclusiveStor.PutIfAbsent(ctx, path, content)
+			if !errors.Is(err, errors.ErrUnsupported) {
+				return err
+			}
+		}
+	}
+	return stor.Put(ctx, path, content)
 }
 
 // RequestUpload is the resolver for the requestUpload field.
 func (r *mutationResolver) RequestUpload(ctx context.Context, path string, spaceID *string, contentType string, sizeBytes int) (*gql.PresignedUpload, error) {
+	path, err := normalizeNewPath(path)
+	if err != nil {
+		return nil, err
+	}
+	path, err = r.routeUploadPath(ctx, path, contentType)
+	if err != nil {
+		return nil, err
+	}
 	if err := RequireWritePermission(ctx, path); err != nil {
 		return nil, err
 	}
@@ -402,13 +679,44 @@ func (r *mutationResolver) RequestUpload(ctx context.Context, path string, space
 	if err := ensureSpaceUploadAllowed(sp); err != nil {
 		return nil, err
 	}
+	path, err = r.resolveUploadCollision(ctx, stor, path, r.uploadCollisionMode(nil))
+	if err != nil {
+		return nil, err
+	}
 	if err := r.enforceHostedStorageQuota(ctx, sp, int64(sizeBytes)); err != nil {
 		return nil, err
 	}
+	if err := r.enforceStorageQuotas(ctx, sp, path, int64(sizeBytes)); err != nil {
+		return nil, err
+	}
 
+	uploadURL, requiredHeaders, expiresAt, err := r.presignPut(ctx, stor, sp, path, contentType, int64(sizeBytes))
+	if err != nil {
+		return nil, err
+	}
+	return &gql.PresignedUpload{
+		Path:            path,
+		UploadURL:       uploadURL,
+		ExpiresAt:       expiresAt.Format(time.RFC3339),
+		RequiredHeaders: requiredHeaders,
+	}, nil
+}
+
+// presignPut returns a presigned PUT URL uploading size bytes to path, and
+// the headers the client must send with it. Uploads to hosted storage may
+// not overwrite and are recorded as pending until completed. Elsewhere they
+// may not overwrite either unless the upload-collision setting allows it,
+// where the backend supports it.
+func (r *Resolver) presignPut(ctx context.Context, stor storage.Storage, sp *space.Space, path, contentType string, size int64) (string, []*gql.UploadHeader, time.Time, error) {
+	if r.uploadScanner != nil {
+		return "", nil, time.Time{}, scannedDirectUploadError()
+	}
+	if err := r.checkUploadExtension(path); err != nil {
+		return "", nil, time.Time{}, err
+	}
 	presignable, ok := stor.(storage.PresignableStorage)
 	if !ok {
-		return nil, &gqlerror.Error{
+		return "", nil, time.Time{}, &gqlerror.Error{
 			Message:    "current storage backend does not support presigned uploads",
 			Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
 		}
@@ -420,43 +728,46 @@ func (r *mutationResolver) RequestUpload(ctx context.Context, path string, space
 	}
 
 	ttl := hostedUploadIntentTTL
-	uploadURL := ""
+	var uploadURL string
+	var err error
 	requiredHeaders := []*gql.UploadHeader{}
 	if r.tracksHostedStorage(sp) {
 		conditionalPresignable, ok := stor.(storage.ConditionalPresignableStorage)
 		if !ok {
-			return nil, &gqlerror.Error{
+			return "", nil, time.Time{}, &gqlerror.Error{
 				Message:    "current hosted storage backend does not support no-overwrite presigned uploads",
 				Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
 			}
 		}
-		uploadURL, err = conditionalPresignable.PresignedPutURLNoOverwrite(ctx, path, trimmedContentType, int64(sizeBytes), ttl)
+		uploadURL, err = conditionalPresignable.PresignedPutURLNoOverwrite(ctx, path, trimmedContentType, size, ttl)
+		requiredHeaders = []*gql.UploadHeader{{Name: uploadHeaderIfNoneMatch, Value: "*"}}
+	} else if conditionalPresignable, ok := stor.(storage.ConditionalPresignableStorage); ok && r.uploadCollisionMode(nil) != uploadCollisionOverwrite {
+		uploadURL, err = conditionalPresignable.PresignedPutURLNoOverwrite(ctx, path, trimmedContentType, size, ttl)
 		requiredHeaders = []*gql.UploadHeader{{Name: uploadHeaderIfNoneMatch, Value: "*"}}
 	} else {
-		uploadURL, err = presignable.PresignedPutURL(ctx, path, trimmedContentType, int64(sizeBytes), ttl)
+		uploadURL, err = presignable.PresignedPutURL(ctx, path, trimmedContentType, size, ttl)
 	}
 	if err != nil {
 		r.logger.Error("Failed to generate presigned upload URL", zap.Error(err), zap.String("path", path))
-		return nil, fmt.Errorf("failed to generate upload URL: %w", err)
+		return "", nil, time.Time{}, fmt.Errorf("failed to generate upload URL: %w", err)
 	}
 
+	expiresAt := time.Now().UTC().Add(ttl)
 	if r.tracksHostedStorage(sp) {
-		expiresAt := time.Now().UTC().Add(ttl)
 		if err := r.hostedStorageStore.BeginPendingUpload(ctx, sp.OrgID, sp.ID, path, expiresAt); err != nil {
 			r.logger.Error("Failed to record pending hosted upload", zap.Error(err), zap.String("spaceID", sp.ID), zap.String("path", path))
-			return nil, fmt.Errorf("failed to record upload intent: %w", err)
+			return "", nil, time.Time{}, fmt.Errorf("failed to record upload intent: %w", err)
 		}
 	}
-
-	return &gql.PresignedUpload{
-		UploadURL:       uploadURL,
-		ExpiresAt:       time.Now().UTC().Add(ttl).Format(time.RFC3339),
-		RequiredHeaders: requiredHeaders,
-	}, nil
+	return uploadURL, requiredHeaders, expiresAt, nil
 }
 
 // CompleteUpload is the resolver for the completeUpload field.
 func (r *mutationResolver) CompleteUpload(ctx context.Context, path string, spaceID *string) (bool, error) {
+	path, err := ScopePath(ctx, path)
+	if err != nil {
+		return false, err
+	}
 	if err := RequireWritePermission(ctx, path); err != nil {
 		return false, err
 	}
@@ -491,12 +802,17 @@ func (r *mutationResolver) CompleteUpload(ctx context.Context, path string, spac
 		r.logger.Error("Failed to finalize hosted upload", zap.Error(err), zap.String("spaceID", sp.ID), zap.String("path", path), zap.Int64("sizeBytes", info.Size))
 		return false, fmt.Errorf("failed to finalize upload: %w", err)
 	}
+	r.publishSpaceUpload(sp, path)
 
 	return true, nil
 }
 
 // DeleteFile is the resolver for the deleteFile field.
 func (r *mutationResolver) DeleteFile(ctx context.Context, path string, spaceID *string) (bool, error) {
+	path, err := ScopePath(ctx, path)
+	if err != nil {
+		return false, err
+	}
 	// Check write permissions and path access
 	if err := RequireWritePermission(ctx, path); err != nil {
 		return false, err
@@ -505,16 +821,38 @@ func (r *mutationResolver) DeleteFile(ctx context.Context, path string, spaceID
 	if err != nil {
 		return false, err
 	}
+	if err := r.deleteStoragePath(ctx, stor, sp, path); err != nil {
+		return false, err
+	}
+	r.removeFileTags(ctx, spaceID, path)
+	r.removeFavorites(ctx, spaceID, path)
+	r.removeAlbumItems(ctx, spaceID, path)
+	r.removeComments(ctx, spaceID, path)
+	r.removeRatings(ctx, spaceID, path)
+	r.removeFileMetadata(ctx, spaceID, path)
+	r.removeFileHashes(ctx, spaceID, path)
+	r.removeFaces(ctx, spaceID, path)
+	r.removeImageEdits(ctx, spaceID, path)
+	r.removeFolderCovers(ctx, spaceID, path)
+	r.removeFolderSettings(ctx, spaceID, path)
+	r.removeStorageUsage(ctx, spaceID, path)
+	r.publishSpaceFileChange(sp, events.FileDeleted, path, "")
+	return true, nil
+}
+
+// deleteStoragePath deletes a file or folder along with its template preview
+// and hosted storage tracking rows.
+func (r *Resolver) deleteStoragePath(ctx context.Context, stor storage.Storage, sp *space.Space, path string) error {
 	hostedObject, err := r.getTrackedHostedObject(ctx, sp, path)
 	if err != nil {
-		return false, err
+		return err
 	}
 	var hostedPreviewObject *management.HostedStorageObject
 	previewPath := getPreviewPath(path)
 	if previewPath != "" {
 		hostedPreviewObject, err = r.getTrackedHostedObject(ctx, sp, previewPath)
 		if err != nil {
-			return false, err
+			return err
 		}
 	}
 
@@ -523,12 +861,12 @@ func (r *mutationResolver) DeleteFile(ctx context.Context, path string, spaceID
 	// Delete the main file
 	if err := stor.Delete(ctx, path); err != nil {
 		r.logger.Error("Failed to delete file", zap.Error(err))
-		return false, fmt.Errorf("failed to delete file: %w", err)
+		return fmt.Errorf("failed to delete file: %w", err)
 	}
 	if hostedObject != nil {
 		if _, err := r.hostedStorageStore.DeleteReadyObject(ctx, sp.ID, path); err != nil {
 			r.logger.Error("Failed to delete hosted storage row", zap.Error(err), zap.String("spaceID", sp.ID), zap.String("path", path))
-			return false, fmt.Errorf("failed to delete hosted storage object: %w", err)
+			return fmt.Errorf("failed to delete hosted storage object: %w", err)
 		}
 	}
 
@@ -548,11 +886,19 @@ func (r *mutationResolver) DeleteFile(ctx context.Context, path string, spaceID
 		}
 	}
 
-	return true, nil
+	return nil
 }
 
 // CreateFolder is the resolver for the createFolder field.
 func (r *mutationResolver) CreateFolder(ctx context.Context, path string, spaceID *string) (bool, error) {
+	path, err := normalizeNewPath(path)
+	if err != nil {
+		return false, err
+	}
+	path, err = ScopePath(ctx, path)
+	if err != nil {
+		return false, err
+	}
 	// Check write permissions and path access
 	if err := RequireWritePermission(ctx, path); err != nil {
 		return false, err
@@ -568,12 +914,25 @@ func (r *mutationResolver) CreateFolder(ctx context.Context, path string, spaceI
 		r.logger.Error("Failed to create folder", zap.Error(err))
 		return false, fmt.Errorf("failed to create folder: %w", err)
 	}
+	r.publishFileChange(ctx, spaceID, events.FileCreated, path, "")
 
 	return true, nil
 }
 
 // CopyFile is the resolver for the copyFile field.
 func (r *mutationResolver) CopyFile(ctx context.Context, sourcePath string, destPath string, spaceID *string) (bool, error) {
+	sourcePath, err := ScopePath(ctx, sourcePath)
+	if err != nil {
+		return false, err
+	}
+	destPath, err = normalizeNewPath(destPath)
+	if err != nil {
+		return false, err
+	}
+	destPath, err = ScopePath(ctx, destPath)
+	if err != nil {
+		return false, err
+	}
 	// Check write permissions for both source and destination paths
 	if err := RequireWritePermission(ctx, sourcePath); err != nil {
 		return false, err
@@ -613,12 +972,25 @@ func (r *mutationResolver) CopyFile(ctx context.Context, sourcePath string, dest
 			return false, fmt.Errorf("failed to copy hosted storage object: %w", err)
 		}
 	}
+	r.publishSpaceFileChange(sp, events.FileCreated, destPath, "")
 
 	return true, nil
 }
 
 // MoveFile is the resolver for the moveFile field.
 func (r *mutationResolver) MoveFile(ctx context.Context, sourcePath string, destPath string, spaceID *string) (bool, error) {
+	sourcePath, err := ScopePath(ctx, sourcePath)
+	if err != nil {
+		return false, err
+	}
+	destPath, err = normalizeNewPath(destPath)
+	if err != nil {
+		return false, err
+	}
+	destPath, err = ScopePath(ctx, destPath)
+	if err != nil {
+		return false, err
+	}
 	// Check write permissions for both source and destination paths
 	if err := RequireWritePermission(ctx, sourcePath); err != nil {
 		return false, err
@@ -690,11 +1062,57 @@ func (r *mutationResolver) MoveFile(ctx context.Context, sourcePath string, dest
 		}
 	}
 
+	r.moveFileTags(ctx, spaceID, sourcePath, destPath)
+	r.moveFavorites(ctx, spaceID, sourcePath, destPath)
+	r.moveAlbumItems(ctx, spaceID, sourcePath, destPath)
+	r.moveComments(ctx, spaceID, sourcePath, destPath)
+	r.moveRatings(ctx, spaceID, sourcePath, destPath)
+	r.removeFileMetadata(ctx, spaceID, sourcePath)
+	r.removeFileHashes(ctx, spaceID, sourcePath)
+	r.removeFaces(ctx, spaceID, sourcePath)
+	r.moveImageEdits(ctx, spaceID, sourcePath, destPath)
+	r.moveFolderCovers(ctx, spaceID, sourcePath, destPath)
+	r.moveFolderSettings(ctx, spaceID, sourcePath, destPath)
+	r.moveStorageUsage(ctx, spaceID, sourcePath, destPath)
+	r.publishSpaceFileChange(sp, events.FileMoved, destPath, sourcePath)
+
 	return true, nil
 }
 
+// RenameFile is the resolver for the renameFile field.
+func (r *mutationResolver) RenameFile(ctx context.Context, path string, newName string, spaceID *string) (bool, error) {
+	path = strings.TrimSuffix(path, "/")
+	newName = strings.TrimSpace(newName)
+	if strings.Trim(path, "/") == "" {
+		return false, &gqlerror.Error{
+			Message:    "cannot rename the root folder",
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	newName, err := safepath.NormalizeName(newName)
+	if err != nil {
+		return false, &gqlerror.Error{
+			Message:    fmt.Sprintf("invalid name: %v", err),
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	dir, name := "", path
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		dir, name = path[:i+1], path[i+1:]
+	}
+	if newName == name {
+		return true, nil
+	}
+	// Storage backends move folders recursively, S3 as copy and delete
+	return r.MoveFile(ctx, path, dir+newName, spaceID)
+}
+
 // ListFiles is the resolver for the listFiles field.
-func (r *queryResolver) ListFiles(ctx context.Context, path string, spaceID *string, offset *int, limit *int, onlyFiles *bool, onlyFolders *bool, extensions *string, showHidden *bool, sortBy *gql.SortOption, sortOrder *gql.SortOrder) (*gql.FileList, error) {
+func (r *queryResolver) ListFiles(ctx context.Context, path string, spaceID *string, offset *int, limit *int, onlyFiles *bool, onlyFolders *bool, extensions *string, showHidden *bool, sortBy *gql.SortOption, sortOrder *gql.SortOrder, systemTags []string, excludeSystemTags []string, tags []string, minRating *int, after *string, skipMetadata *bool) (*gql.FileList, error) {
+	path, err := ScopePath(ctx, path)
+	if err != nil {
+		return nil, err
+	}
 	// Check read permissions and path access
 	if err := RequireReadPermission(ctx, path); err != nil {
 		return nil, err
@@ -726,6 +1144,23 @@ func (r *queryResolver) ListFiles(ctx context.Context, path string, spaceID *str
 		limitValue = *limit
 	}
 
+	// A cursor listing continues where the previous page ended
+	var cursor *listCursor
+	if after != nil {
+		if offsetValue > 0 {
+			return nil, &gqlerror.Error{
+				Message:    "after cannot be combined with offset",
+				Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+			}
+		}
+		c, err := decodeListCursor(*after, strings.Trim(path, "/"))
+		if err != nil {
+			return nil, err
+		}
+		cursor = &c
+		offsetValue = c.Offset
+	}
+
 	r.logger.Debug("Listing files",
 		zap.String("path", path),
 		zap.Int("offset", offsetValue),
@@ -734,13 +1169,57 @@ func (r *queryResolver) ListFiles(ctx context.Context, path string, spaceID *str
 		zap.Any("sortOrder", sortOrder),
 	)
 
+	if minRating != nil && (*minRating < ratingstore.MinRating || *minRating > ratingstore.MaxRating) {
+		return nil, &gqlerror.Error{
+			Message:    fmt.Sprintf("minRating must be between %d and %d", ratingstore.MinRating, ratingstore.MaxRating),
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+
+	// The folder settings sort listings that set no sort, list pinned
+	// children first and leave hidden child folders out
+	settings, hiddenFolders := r.folderSettings(ctx, spaceConfig, strings.Trim(path, "/"))
+	if sortBy == nil && settings.SortBy != "" {
+		folderSortBy := gql.SortOption(settings.SortBy)
+		sortBy = &folderSortBy
+	}
+	if sortOrder == nil && settings.SortOrder != "" {
+		folderSortOrder := gql.SortOrder(settings.SortOrder)
+		sortOrder = &folderSortOrder
+	}
+	if showHidden != nil && *showHidden {
+		hiddenFolders = nil
+	}
+
+	// Tag and rating filters, capture date and rating sorting, pinned and
+	// hidden folders are applied after listing, so pagination has to be
+	// applied here instead of in the storage backend
+	filterBySystemTags := len(systemTags) > 0 || len(excludeSystemTags) > 0
+	sortByCaptureDate := sortBy != nil && *sortBy == gql.SortOptionCaptureDate
+	sortByRatings := sortBy != nil && *sortBy == gql.SortOptionRating
+	// The cached listing is paged here as well, once Live Photo videos are
+	// hidden
+	paginateAfterList := filterBySystemTags || len(tags) > 0 || minRating != nil || sortByCaptureDate || sortByRatings ||
+		len(settings.Pinned) > 0 || len(hiddenFolders) > 0 || r.listCache != nil
+	if (minRating != nil || sortByRatings) && r.ratingStore == nil {
+		return nil, ratingsNotAvailableError()
+	}
+
+	var tagged map[string]bool
+	if len(tags) > 0 {
+		if tagged, err = r.filesWithTags(ctx, spaceConfig, tags); err != nil {
+			return nil, err
+		}
+	}
+
 	options := storage.ListOptions{
-		Offset:      offsetValue,
-		Limit:       limitValue,
-		OnlyFiles:   onlyFiles != nil && *onlyFiles,
-		OnlyFolders: onlyFolders != nil && *onlyFolders,
-		Extensions:  parseExtensions(extensions),
-		ShowHidden:  showHidden != nil && *showHidden,
+		Offset:       offsetValue,
+		Limit:        limitValue,
+		OnlyFiles:    onlyFiles != nil && *onlyFiles,
+		OnlyFolders:  onlyFolders != nil && *onlyFolders,
+		Extensions:   parseExtensions(extensions),
+		ShowHidden:   showHidden != nil && *showHidden,
+		SkipMetadata: skipMetadata != nil && *skipMetadata,
 	}
 
 	if sortBy != nil {
@@ -751,6 +1230,10 @@ func (r *queryResolver) ListFiles(ctx context.Context, path string, spaceID *str
 			options.SortBy = storage.SortBySize
 		case gql.SortOptionModifiedTime:
 			options.SortBy = storage.SortByModifiedTime
+		case gql.SortOptionCaptureDate:
+			// Sorted below once capture dates are known
+		case gql.SortOptionRating:
+			// Sorted below once ratings are known
 		default:
 			return nil, fmt.Errorf("invalid sortBy option: %s", *sortBy)
 		}
@@ -767,13 +1250,139 @@ func (r *queryResolver) ListFiles(ctx context.Context, path string, spaceID *str
 		}
 	}
 
-	result, err := stor.List(ctx, path, options)
-	if err != nil {
-		r.logger.Error("Failed to list files", zap.Error(err))
-		return nil, fmt.Errorf("failed to list files: %w", err)
+	if paginateAfterList {
+		options.Offset = 0
+		options.Limit = 0
+	}
+
+	var result storage.ListResult
+	var etag *string
+	var next listCursor
+	paged := false
+	if cursor != nil && r.listCache == nil && sortBy == nil && sortOrder == nil && !paginateAfterList {
+		// Listed in key order by the storage, continuing after the cursor
+		result, next, paged, err = listPage(ctx, stor, path, options, *cursor)
+		var gqlErr *gqlerror.Error
+		if errors.As(err, &gqlErr) {
+			return nil, err
+		}
+		if err != nil {
+			r.logger.Error("Failed to list files", zap.Error(err))
+			return nil, fmt.Errorf("failed to list files: %w", err)
+		}
+	}
+	switch {
+	case paged:
+	case r.listCache != nil:
+		// The full listing is cached, filtered and paged for each request
+		listing, err := r.listFolder(ctx, stor, spaceConfig, strings.Trim(path, "/"))
+		if err != nil {
+			r.logger.Error("Failed to list files", zap.Error(err))
+			return nil, fmt.Errorf("failed to list files: %w", err)
+		}
+		result = listcache.Apply(listing.Items, options)
+		etag = &listing.ETag
+		r.linkLivePhotos(ctx, spaceConfig, strings.Trim(path, "/"), listing.Items)
+	default:
+		result, err = stor.List(ctx, path, options)
+		if err != nil {
+			r.logger.Error("Failed to list files", zap.Error(err))
+			return nil, fmt.Errorf("failed to list files: %w", err)
+		}
+		if paginateAfterList && options.Extensions == nil && !options.OnlyFolders {
+			r.linkLivePhotos(ctx, spaceConfig, strings.Trim(path, "/"), result.Items)
+		}
+	}
+
+	classifier := r.getMediaClassifier(ctx)
+	classified := r.classificationMetadata(ctx, spaceConfig, classifier, result.Items)
+	itemTags := make([][]string, len(result.Items))
+	for i, item := range result.Items {
+		itemTags[i] = classifyFileInfo(classifier, item, classified[item.Path])
+	}
+
+	if filterBySystemTags || tagged != nil || len(hiddenFolders) > 0 {
+		var filtered []storage.FileInfo
+		var filteredTags [][]string
+		for i, item := range result.Items {
+			if (item.IsDir && !hiddenFolders[item.Path]) || (!item.IsDir && mediaclass.Filter(itemTags[i], systemTags, excludeSystemTags) && (tagged == nil || tagged[item.Path])) {
+				filtered = append(filtered, item)
+				filteredTags = append(filteredTags, itemTags[i])
+			}
+		}
+		result.Items = filtered
+		itemTags = filteredTags
+	}
+
+	// Ratings of the whole listing are needed to filter and sort by them,
+	// otherwise only those of the page are looked up below
+	var ratings map[string]int
+	if minRating != nil || sortByRatings {
+		paths := make([]string, len(result.Items))
+		for i, item := range result.Items {
+			paths[i] = item.Path
+		}
+		if ratings, err = r.fileRatings(ctx, spaceConfig, paths); err != nil {
+			return nil, fmt.Errorf("failed to get ratings: %w", err)
+		}
+	}
+	if minRating != nil {
+		var filtered []storage.FileInfo
+		var filteredTags [][]string
+		for i, item := range result.Items {
+			if item.IsDir || ratings[item.Path] >= *minRating {
+				filtered = append(filtered, item)
+				filteredTags = append(filteredTags, itemTags[i])
+			}
+		}
+		result.Items = filtered
+		itemTags = filteredTags
+	}
+
+	// Live Photo videos are shown with their images. Without the whole
+	// listing, videos of images on other pages are known from recorded
+	// links, and the storage total still counts them.
+	complete := paginateAfterList && !paged
+	livePhotos := r.livePhotos(ctx, spaceConfig, result.Items, complete)
+	result.Items, itemTags = hideLivePhotoVideos(result.Items, itemTags, livePhotos, complete || options.Extensions != nil)
+
+	var captureTimes map[string]string
+	if sortByCaptureDate {
+		captureTimes = r.sortByCaptureDate(ctx, spaceConfig, result.Items, itemTags, options.SortOrder)
+	}
+	if sortByRatings {
+		sortByRating(result.Items, itemTags, ratings, options.SortOrder)
+	}
+	if len(settings.Pinned) > 0 {
+		pinFirst(result.Items, itemTags, settings.Pinned)
+	}
+
+	if paginateAfterList {
+		result.TotalCount = len(result.Items)
+		start, end := paginateBounds(len(result.Items), offsetValue, limitValue)
+		result.Items = result.Items[start:end]
+		itemTags = itemTags[start:end]
 	}
 
 	videoThumbnailPos := r.getEffectiveVideoThumbnailPosition(ctx, spaceConfig)
+	itemPaths := make([]string, len(result.Items))
+	for i, item := range result.Items {
+		itemPaths[i] = item.Path
+	}
+	favorites := r.favoritePaths(ctx, spaceConfig, itemPaths)
+	var folderPaths []string
+	for _, item := range result.Items {
+		if item.IsDir {
+			folderPaths = append(folderPaths, item.Path)
+		}
+	}
+	covers := r.folderCovers(ctx, spaceConfig, folderPaths)
+	if ratings == nil {
+		// Ratings never fail a listing that does not filter or sort by them
+		if ratings, err = r.fileRatings(ctx, spaceConfig, itemPaths); err != nil {
+			r.logger.Warn("Failed to get ratings", zap.Error(err))
+		}
+	}
 
 	files := make([]*gql.FileItem, len(result.Items))
 	for i, item := range result.Items {
@@ -783,29 +1392,64 @@ func (r *queryResolver) ListFiles(ctx context.Context, path string, spaceID *str
 			Size:         int(item.Size),
 			IsDirectory:  item.IsDir,
 			ModifiedTime: item.ModifiedTime.Format(time.RFC3339),
+			SystemTags:   itemTags[i],
+			IsFavorite:   favorites[item.Path],
+			IsRaw:        !item.IsDir && rawpreview.IsRaw(item.Path),
+		}
+		if video, ok := livePhotos[item.Path]; ok && !item.IsDir {
+			fileItem.LivePhotoVideoURL = r.generateLivePhotoVideoURL(ctx, video, spaceConfig)
+		}
+		if captureTime, ok := captureTimes[item.Path]; ok {
+			fileItem.CaptureTime = &captureTime
+		}
+		if rating, ok := ratings[item.Path]; ok {
+			fileItem.Rating = &rating
+		}
+
+		var resolvedSpaceKey *string
+		if spaceConfig != nil {
+			resolvedSpaceKey = &spaceConfig.Key
+		}
+		if cover, ok := covers[item.Path]; ok && item.IsDir {
+			fileItem.CoverPath = &cover
+			fileItem.CoverThumbnailUrls = r.generateThumbnailUrlsForResolvedSpace(ctx, cover, videoThumbnailPos, resolvedSpaceKey, spaceConfig)
 		}
 
 		// Generate thumbnail URLs for image files
 		if !item.IsDir {
-			var resolvedSpaceKey *string
-			if spaceConfig != nil {
-				resolvedSpaceKey = &spaceConfig.Key
-			}
 			thumbnailUrls := r.generateThumbnailUrlsForResolvedSpace(ctx, item.Path, videoThumbnailPos, resolvedSpaceKey, spaceConfig)
 			fileItem.ThumbnailUrls = thumbnailUrls
+			fileItem.PreviewSpriteURL = r.generatePreviewSpriteURL(ctx, item.Path, resolvedSpaceKey, spaceConfig)
 		}
 
 		files[i] = fileItem
 	}
 
+	var endCursor *string
+	if cursor != nil {
+		if !paged {
+			next = listCursor{Path: cursor.Path, Offset: offsetValue + len(result.Items)}
+		}
+		if next.Cursor != "" || (!paged && next.Offset < result.TotalCount) {
+			encoded := next.encode()
+			endCursor = &encoded
+		}
+	}
+
 	return &gql.FileList{
 		Items:      files,
 		TotalCount: result.TotalCount,
+		Etag:       etag,
+		EndCursor:  endCursor,
 	}, nil
 }
 
 // StatFile is the resolver for the statFile field.
 func (r *queryResolver) StatFile(ctx context.Context, path string, spaceID *string) (*gql.FileStat, error) {
+	path, err := ScopePath(ctx, path)
+	if err != nil {
+		return nil, err
+	}
 	// Check read permissions and path access
 	if err := RequireReadPermission(ctx, path); err != nil {
 		return nil, err
@@ -834,6 +1478,8 @@ func (r *queryResolver) StatFile(ctx context.Context, path string, spaceID *stri
 	}
 
 	videoThumbnailPos := r.getEffectiveVideoThumbnailPosition(ctx, spaceConfig)
+	classifier := r.getMediaClassifier(ctx)
+	classified := r.classificationMetadata(ctx, spaceConfig, classifier, []storage.FileInfo{fileInfo})
 
 	fileStat := &gql.FileStat{
 		Name:         fileInfo.Name,
@@ -842,6 +1488,7 @@ func (r *queryResolver) StatFile(ctx context.Context, path string, spaceID *stri
 		IsDirectory:  fileInfo.IsDir,
 		ModifiedTime: fileInfo.ModifiedTime.Format(time.RFC3339),
 		Etag:         &fileInfo.ETag,
+		SystemTags:   classifyFileInfo(classifier, fileInfo, classified[fileInfo.Path]),
 	}
 
 	// Generate thumbnail URLs for image files
@@ -866,6 +1513,11 @@ func (r *queryResolver) StorageStatus(ctx context.Context) (*gql.StorageStatus,
 		"config.storage_config_updated_at",
 		"config.file_storage_base_dir",
 		"config.s3_storage_bucket",
+		"config.sftp_storage_host",
+		storageprovider.PendingConfigKey,
+		storageprovider.PendingConfigUpdatedAtKey,
+		storageprovider.RollbackReasonKey,
+		storageprovider.RollbackAtKey,
 	)
 
 	// Create a map for easy lookup
@@ -890,7 +1542,8 @@ func (r *queryResolver) StorageStatus(ctx context.Context) (*gql.StorageStatus,
 	var storageType *string
 	var fileConfig *gql.FileStorageConfig
 	var s3Config *gql.S3StorageConfig
-	var isFileOverridden, isS3Overridden bool
+	var sftpConfig *gql.SFTPStorageConfig
+	var isFileOverridden, isS3Overridden, isSFTPOverridden bool
 
 	// Determine storage type and load configuration
 	if typeResult := resultMap["config.storage_type"]; typeResult.Exists {
@@ -902,6 +1555,8 @@ func (r *queryResolver) StorageStatus(ctx context.Context) (*gql.StorageStatus,
 			fileConfig, isFileOverridden = r.getFileStorageConfig(ctx)
 		case "s3":
 			s3Config, isS3Overridden = r.getS3StorageConfig(ctx)
+		case "sftp":
+			sftpConfig, isSFTPOverridden = r.getSFTPStorageConfig(ctx)
 		}
 	} else if isConfigOverridden {
 		// If no explicit type but config is overridden, try to detect from available config
@@ -913,28 +1568,124 @@ func (r *queryResolver) StorageStatus(ctx context.Context) (*gql.StorageStatus,
 			s3Type := "s3"
 			storageType = &s3Type
 			s3Config, isS3Overridden = r.getS3StorageConfig(ctx)
+		} else if resultMap["config.sftp_storage_host"].Exists {
+			sftpType := "sftp"
+			storageType = &sftpType
+			sftpConfig, isSFTPOverridden = r.getSFTPStorageConfig(ctx)
 		}
 	}
 
 	// Update override status based on type-specific checks
-	isConfigOverridden = isFileOverridden || isS3Overridden
+	isConfigOverridden = isFileOverridden || isS3Overridden || isSFTPOverridden
 
 	var lastUpdated *string
 	if timestampResult := resultMap["config.storage_config_updated_at"]; timestampResult.Exists {
 		lastUpdated = &timestampResult.Value
 	}
 
+	var lastRollback *gql.StorageConfigRollback
+	if reasonResult := resultMap[storageprovider.RollbackReasonKey]; reasonResult.Exists && reasonResult.Value != "" {
+		lastRollback = &gql.StorageConfigRollback{Reason: reasonResult.Value}
+		if atResult := resultMap[storageprovider.RollbackAtKey]; atResult.Exists {
+			lastRollback.Timestamp = &atResult.Value
+		}
+	}
+
 	return &gql.StorageStatus{
 		Configured:              isConfigured,
-		SupportsPresignedUpload: supportsPresignedUpload(r.getStorage()),
+		SupportsPresignedUpload: supportsPresignedUpload(r.getStorage()) && r.uploadScanner == nil,
 		Type:                    storageType,
 		LastUpdated:             lastUpdated,
 		IsOverriddenByConfig:    isConfigOverridden,
 		FileConfig:              fileConfig,
 		S3Config:                s3Config,
+		SftpConfig:              sftpConfig,
+		PendingConfig:           pendingStorageConfigFromResults(resultMap),
+		LastRollback:            lastRollback,
 	}, nil
 }
 
+// pendingStorageConfigFromResults describes the staged storage configuration,
+// omitting credentials. Returns nil when nothing is pending.
+func pendingStorageConfigFromResults(resultMap map[string]registryutil.EffectiveValueResult) *gql.PendingStorageConfig {
+	pendingResult := resultMap[storageprovider.PendingConfigKey]
+	if !pendingResult.Exists || pendingResult.Value == "" {
+		return nil
+	}
+	entries, err := storageprovider.ParsePendingConfig(pendingResult.Value)
+	if err != nil {
+		return nil
+	}
+
+	pending := &gql.PendingStorageConfig{Type: entries["config.storage_type"]}
+	if updatedAtResult := resultMap[storageprovider.PendingConfigUpdatedAtKey]; updatedAtResult.Exists {
+		pending.UpdatedAt = &updatedAtResult.Value
+	}
+	switch pending.Type {
+	case "s3":
+		pending.S3Config = s3ConfigFromEntries(entries)
+	case "sftp":
+		pending.SftpConfig = sftpConfigFromEntries(entries)
+	}
+	return pending
+}
+
+// fileConfigFromEntries describes a file storage from its registry entries
+func fileConfigFromEntries(entries map[string]string) *gql.FileStorageConfig {
+	c := &gql.FileStorageConfig{
+		BaseDir:          entries["config.file_storage_base_dir"],
+		MkdirPermissions: "0755",
+		WritePermissions: "0644",
+	}
+	if v, ok := entries["config.file_storage_mkdir_permissions"]; ok {
+		c.MkdirPermissions = v
+	}
+	if v, ok := entries["config.file_storage_write_permissions"]; ok {
+		c.WritePermissions = v
+	}
+	return c
+}
+
+// s3ConfigFromEntries describes an S3 storage from its registry entries,
+// omitting credentials
+func s3ConfigFromEntries(entries map[string]string) *gql.S3StorageConfig {
+	c := &gql.S3StorageConfig{Bucket: entries["config.s3_storage_bucket"]}
+	if v, ok := entries["config.s3_storage_region"]; ok {
+		c.Region = &v
+	}
+	if v, ok := entries["config.s3_storage_endpoint"]; ok {
+		c.Endpoint = &v
+	}
+	if v, ok := entries["config.s3_storage_force_path_style"]; ok {
+		forcePathStyle := v == "true"
+		c.ForcePathStyle = &forcePathStyle
+	}
+	if v, ok := entries["config.s3_storage_base_dir"]; ok {
+		c.BaseDir = &v
+	}
+	return c
+}
+
+// sftpConfigFromEntries describes an SFTP storage from its registry entries,
+// omitting credentials
+func sftpConfigFromEntries(entries map[string]string) *gql.SFTPStorageConfig {
+	c := &gql.SFTPStorageConfig{
+		Host: entries["config.sftp_storage_host"],
+		Port: 22,
+		User: entries["config.sftp_storage_user"],
+	}
+	if port, err := strconv.Atoi(entries["config.sftp_storage_port"]); err == nil {
+		c.Port = port
+	}
+	if v, ok := entries["config.sftp_storage_host_key"]; ok {
+		c.HostKey = &v
+	}
+	if v, ok := entries["config.sftp_storage_base_dir"]; ok {
+		c.BaseDir = &v
+	}
+	return c
+}
+
 // Helper function to get file storage configuration
 func (r *queryResolver) getFileStorageConfig(ctx context.Context) (*gql.FileStorageConfig, bool) {
 	// Use batch operation for better performance
@@ -1037,6 +1788,49 @@ func (r *queryResolver) getS3StorageConfig(ctx context.Context) (*gql.S3StorageC
 	return c, isOverridden
 }
 
+// Helper function to get SFTP storage configuration, credentials are omitted
+func (r *queryResolver) getSFTPStorageConfig(ctx context.Context) (*gql.SFTPStorageConfig, bool) {
+	results := registryutil.GetEffectiveValues(ctx, r.registryStore, r.config,
+		"config.sftp_storage_host",
+		"config.sftp_storage_port",
+		"config.sftp_storage_user",
+		"config.sftp_storage_host_key",
+		"config.sftp_storage_base_dir")
+
+	resultMap := make(map[string]registryutil.EffectiveValueResult)
+	isOverridden := false
+	for _, result := range results {
+		resultMap[result.Key] = result
+		if result.IsOverriddenByConfig {
+			isOverridden = true
+		}
+	}
+
+	hostResult := resultMap["config.sftp_storage_host"]
+	if !hostResult.Exists {
+		return nil, false
+	}
+
+	c := &gql.SFTPStorageConfig{
+		Host: hostResult.Value,
+		Port: 22,
+		User: resultMap["config.sftp_storage_user"].Value,
+	}
+	if portResult := resultMap["config.sftp_storage_port"]; portResult.Exists {
+		if port, err := strconv.Atoi(portResult.Value); err == nil {
+			c.Port = port
+		}
+	}
+	if hostKeyResult := resultMap["config.sftp_storage_host_key"]; hostKeyResult.Exists && hostKeyResult.Value != "" {
+		c.HostKey = &hostKeyResult.Value
+	}
+	if baseDirResult := resultMap["config.sftp_storage_base_dir"]; baseDirResult.Exists && baseDirResult.Value != "" {
+		c.BaseDir = &baseDirResult.Value
+	}
+
+	return c, isOverridden
+}
+
 // ConfigureFileStorage is the resolver for the configureFileStorage field.
 func (r *mutationResolver) ConfigureFileStorage(ctx context.Context, input gql.FileStorageInput) (*gql.StorageConfigResult, error) {
 	// Check admin permissions
@@ -1096,6 +1890,7 @@ func (r *mutationResolver) ConfigureFileStorage(ctx context.Context, input gql.F
 			Message:   &[]string{"Failed to save configuration"}[0],
 		}, nil
 	}
+	r.publishStorageChange()
 
 	return &gql.StorageConfigResult{
 		Success:   true,
@@ -1133,53 +1928,46 @@ func (r *mutationResolver) ConfigureS3Storage(ctx context.Context, input gql.S3S
 	timestamp := time.Now().UnixMilli()
 	timestampStr := fmt.Sprintf("%d", timestamp)
 
-	// Prepare registry entries
-	entries := []gql.RegistryEntryInput{
-		{Key: "config.storage_type", Value: "s3", IsEncrypted: false},
-		{Key: "config.storage_configured", Value: "true", IsEncrypted: false},
-		{Key: "config.s3_storage_bucket", Value: input.Bucket, IsEncrypted: false},
-		{Key: "config.storage_config_updated_at", Value: timestampStr, IsEncrypted: false},
+	// Stage the configuration instead of activating it right away. The storage
+	// provider validates the pending snapshot on the next startup or sync tick
+	// and rolls back to the last known good configuration if it fails.
+	pending := map[string]string{
+		"config.storage_type":      "s3",
+		"config.s3_storage_bucket": input.Bucket,
 	}
-
-	// Add optional S3 configuration
 	if input.Region != nil {
-		entries = append(entries, gql.RegistryEntryInput{
-			Key: "config.s3_storage_region", Value: *input.Region, IsEncrypted: false,
-		})
+		pending["config.s3_storage_region"] = *input.Region
 	}
 	if input.Endpoint != nil {
-		entries = append(entries, gql.RegistryEntryInput{
-			Key: "config.s3_storage_endpoint", Value: *input.Endpoint, IsEncrypted: false,
-		})
+		pending["config.s3_storage_endpoint"] = *input.Endpoint
 	}
 	if input.AccessKeyID != nil {
-		entries = append(entries, gql.RegistryEntryInput{
-			Key: "config.s3_storage_access_key_id", Value: *input.AccessKeyID, IsEncrypted: true,
-		})
+		pending["config.s3_storage_access_key_id"] = *input.AccessKeyID
 	}
 	if input.SecretAccessKey != nil {
-		entries = append(entries, gql.RegistryEntryInput{
-			Key: "config.s3_storage_secret_access_key", Value: *input.SecretAccessKey, IsEncrypted: true,
-		})
+		pending["config.s3_storage_secret_access_key"] = *input.SecretAccessKey
 	}
 	if input.SessionToken != nil {
-		entries = append(entries, gql.RegistryEntryInput{
-			Key: "config.s3_storage_session_token", Value: *input.SessionToken, IsEncrypted: true,
-		})
+		pending["config.s3_storage_session_token"] = *input.SessionToken
 	}
 	if input.ForcePathStyle != nil {
-		entries = append(entries, gql.RegistryEntryInput{
-			Key: "config.s3_storage_force_path_style", Value: fmt.Sprintf("%t", *input.ForcePathStyle), IsEncrypted: false,
-		})
+		pending["config.s3_storage_force_path_style"] = fmt.Sprintf("%t", *input.ForcePathStyle)
 	}
 	if input.BaseDir != nil {
-		entries = append(entries, gql.RegistryEntryInput{
-			Key: "config.s3_storage_base_dir", Value: *input.BaseDir, IsEncrypted: false,
-		})
+		pending["config.s3_storage_base_dir"] = *input.BaseDir
+	}
+	pendingJSON, err := json.Marshal(pending)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode pending storage configuration: %w", err)
+	}
+
+	entries := []gql.RegistryEntryInput{
+		{Key: storageprovider.PendingConfigKey, Value: string(pendingJSON), IsEncrypted: true},
+		{Key: storageprovider.PendingConfigUpdatedAtKey, Value: timestampStr, IsEncrypted: false},
 	}
 
 	// Save to registry
-	_, err := r.setSystemRegistryEntries(ctx, entries)
+	_, err = r.setSystemRegistryEntries(ctx, entries)
 	if err != nil {
 		r.logger.Error("Failed to save S3 storage configuration", zap.Error(err))
 		return &gql.StorageConfigResult{
@@ -1188,11 +1976,87 @@ func (r *mutationResolver) ConfigureS3Storage(ctx context.Context, input gql.S3S
 			Message:   &[]string{"Failed to save configuration"}[0],
 		}, nil
 	}
+	r.publishStorageChange()
+
+	return &gql.StorageConfigResult{
+		Success:   true,
+		Timestamp: timestampStr,
+		Message:   &[]string{"S3 storage configuration saved, it will be applied once validated"}[0],
+	}, nil
+}
+
+// ConfigureSFTPStorage is the resolver for the configureSFTPStorage field.
+func (r *mutationResolver) ConfigureSFTPStorage(ctx context.Context, input gql.SFTPStorageInput) (*gql.StorageConfigResult, error) {
+	if err := RequireAdminPermission(ctx); err != nil {
+		return nil, err
+	}
+
+	r.logger.Debug("Configuring SFTP storage", zap.String("host", input.Host), zap.String("user", input.User))
+
+	if input.Port != nil && (*input.Port <= 0 || *input.Port > 65535) {
+		return nil, &gqlerror.Error{
+			Message:    "port must be between 1 and 65535",
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+
+	testResult := r.validateStorageConfig(ctx, gql.StorageConfigInput{
+		Type:       gql.StorageTypeSftp,
+		SftpConfig: &input,
+	})
+	if !testResult.Success {
+		return &gql.StorageConfigResult{
+			Success:   false,
+			Timestamp: fmt.Sprintf("%d", time.Now().UnixMilli()),
+			Message:   &testResult.Message,
+		}, nil
+	}
+
+	timestampStr := fmt.Sprintf("%d", time.Now().UnixMilli())
+
+	// Staged like S3, the private key is encrypted with the snapshot and
+	// again when promoted to config.sftp_storage_private_key
+	pending := map[string]string{
+		"config.storage_type":             "sftp",
+		"config.sftp_storage_host":        input.Host,
+		"config.sftp_storage_user":        input.User,
+		"config.sftp_storage_private_key": input.PrivateKey,
+	}
+	if input.Port != nil {
+		pending["config.sftp_storage_port"] = strconv.Itoa(*input.Port)
+	}
+	if input.PrivateKeyPassphrase != nil {
+		pending["config.sftp_storage_private_key_passphrase"] = *input.PrivateKeyPassphrase
+	}
+	if input.HostKey != nil {
+		pending["config.sftp_storage_host_key"] = *input.HostKey
+	}
+	if input.BaseDir != nil {
+		pending["config.sftp_storage_base_dir"] = *input.BaseDir
+	}
+	pendingJSON, err := json.Marshal(pending)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode pending storage configuration: %w", err)
+	}
+
+	entries := []gql.RegistryEntryInput{
+		{Key: storageprovider.PendingConfigKey, Value: string(pendingJSON), IsEncrypted: true},
+		{Key: storageprovider.PendingConfigUpdatedAtKey, Value: timestampStr, IsEncrypted: false},
+	}
+	if _, err := r.setSystemRegistryEntries(ctx, entries); err != nil {
+		r.logger.Error("Failed to save SFTP storage configuration", zap.Error(err))
+		return &gql.StorageConfigResult{
+			Success:   false,
+			Timestamp: timestampStr,
+			Message:   &[]string{"Failed to save configuration"}[0],
+		}, nil
+	}
+	r.publishStorageChange()
 
 	return &gql.StorageConfigResult{
 		Success:   true,
 		Timestamp: timestampStr,
-		Message:   &[]string{"S3 storage configured successfully"}[0],
+		Message:   &[]string{"SFTP storage configuration saved, it will be applied once validated"}[0],
 	}, nil
 }
 
@@ -567,7 +567,7 @@ func TestHostedUploadFile_IntegrationFlow(t *testing.T) {
 		File:     strings.NewReader(content),
 		Filename: "direct.txt",
 		Size:     int64(len(content)),
-	})
+	}, nil)
 	require.NoError(t, err)
 	assert.True(t, result)
 
@@ -591,7 +591,7 @@ func TestHostedUploadFile_IntegrationFlow(t *testing.T) {
 		File:     strings.NewReader("changed"),
 		Filename: "direct.txt",
 		Size:     int64(len("changed")),
-	})
+	}, nil)
 	assert.False(t, result)
 	assert.Error(t, err)
 	gqlErr, ok := err.(*gqlerror.Error)
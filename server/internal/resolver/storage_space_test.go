@@ -446,7 +446,7 @@ func TestUploadFile_PlatformManagedFinalizesHostedUpload(t *testing.T) {
 	r := newSpaceTestResolverWithHostedStorageAndSpaceStorage(mockSpaceStore, management.CloudConfig{}, mockHostedStorage, mockSpaceStorage)
 	ctx := createAdminContextWithOrg("user-1", "org-a")
 	upload := graphql.Upload{File: strings.NewReader("test content"), Filename: "test.txt", Size: 128}
-	result, err := r.Mutation().UploadFile(ctx, "test.txt", ptrStr("space-1"), upload)
+	result, err := r.Mutation().UploadFile(ctx, "test.txt", ptrStr("space-1"), upload, nil)
 
 	assert.NoError(t, err)
 	assert.True(t, result)
@@ -471,7 +471,7 @@ func TestUploadFile_PlatformManagedRejectsOverwrite(t *testing.T) {
 	r := newSpaceTestResolverWithHostedStorageAndSpaceStorage(mockSpaceStore, management.CloudConfig{}, mockHostedStorage, mockSpaceStorage)
 	ctx := createAdminContextWithOrg("user-1", "org-a")
 	upload := graphql.Upload{File: strings.NewReader("test content"), Filename: "test.txt", Size: 128}
-	result, err := r.Mutation().UploadFile(ctx, "test.txt", ptrStr("space-1"), upload)
+	result, err := r.Mutation().UploadFile(ctx, "test.txt", ptrStr("space-1"), upload, nil)
 
 	assert.False(t, result)
 	assert.Error(t, err)
@@ -501,7 +501,7 @@ func TestUploadFile_SuspendedSpaceForbidden(t *testing.T) {
 	r := newSpaceTestResolverWithHostedStorageAndSpaceStorage(mockSpaceStore, management.CloudConfig{}, mockHostedStorage, mockSpaceStorage)
 	ctx := createAdminContextWithOrg("user-1", "org-a")
 	upload := graphql.Upload{File: strings.NewReader("test content"), Filename: "test.txt", Size: 128}
-	result, err := r.Mutation().UploadFile(ctx, "test.txt", ptrStr("space-1"), upload)
+	result, err := r.Mutation().UploadFile(ctx, "test.txt", ptrStr("space-1"), upload, nil)
 
 	assert.False(t, result)
 	assert.Error(t, err)
@@ -542,7 +542,7 @@ func TestUploadFile_PlatformManagedBeginPendingFailureDeletesUploadedObject(t *t
 	r := newSpaceTestResolverWithHostedStorageAndSpaceStorage(mockSpaceStore, management.CloudConfig{}, mockHostedStorage, mockSpaceStorage)
 	ctx := createAdminContextWithOrg("user-1", "org-a")
 	upload := graphql.Upload{File: strings.NewReader("test content"), Filename: "test.txt", Size: 128}
-	result, err := r.Mutation().UploadFile(ctx, "test.txt", ptrStr("space-1"), upload)
+	result, err := r.Mutation().UploadFile(ctx, "test.txt", ptrStr("space-1"), upload, nil)
 
 	assert.False(t, result)
 	assert.Error(t, err)
@@ -578,7 +578,7 @@ func TestUploadFile_PlatformManagedFinalizeFailureDeletesUploadedObject(t *testi
 	r := newSpaceTestResolverWithHostedStorageAndSpaceStorage(mockSpaceStore, management.CloudConfig{}, mockHostedStorage, mockSpaceStorage)
 	ctx := createAdminContextWithOrg("user-1", "org-a")
 	upload := graphql.Upload{File: strings.NewReader("test content"), Filename: "test.txt", Size: 128}
-	result, err := r.Mutation().UploadFile(ctx, "test.txt", ptrStr("space-1"), upload)
+	result, err := r.Mutation().UploadFile(ctx, "test.txt", ptrStr("space-1"), upload, nil)
 
 	assert.False(t, result)
 	assert.Error(t, err)
@@ -900,7 +900,7 @@ func TestListFiles_SpaceIDNotFound(t *testing.T) {
 
 	result, err := r.Query().ListFiles(
 		ctx, "some/path", ptrStr("missing-space"),
-		nil, nil, nil, nil, nil, nil, nil, nil,
+		nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil,
 	)
 	assert.Nil(t, result)
 	assert.Error(t, err)
@@ -928,7 +928,7 @@ func TestListFiles_SpaceIDForbidden(t *testing.T) {
 
 	result, err := r.Query().ListFiles(
 		ctx, "some/path", ptrStr("other-space"),
-		nil, nil, nil, nil, nil, nil, nil, nil,
+		nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil,
 	)
 	assert.Nil(t, result)
 	assert.Error(t, err)
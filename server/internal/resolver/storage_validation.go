@@ -31,11 +31,16 @@ func validateStorageConfigInput(ctx context.Context, input gql.StorageConfigInpu
 	testStorage, err := storageFromValidationInput(input, logger, registryStore)
 	if err != nil {
 		switch {
-		case errors.Is(err, errMissingFileStorageConfig), errors.Is(err, errMissingS3StorageConfig):
+		case errors.Is(err, errMissingFileStorageConfig), errors.Is(err, errMissingS3StorageConfig),
+			errors.Is(err, errMissingSFTPStorageConfig):
 			return &gql.StorageTestResult{Success: false, Message: err.Error()}
 		}
 		return storageTestFailure("Failed to create storage instance", err)
 	}
+	if closer, ok := testStorage.(io.Closer); ok {
+		// SFTP storage holds a connection open
+		defer closer.Close()
+	}
 
 	_, err = testStorage.List(ctx, "", storagepkg.ListOptions{Limit: 1})
 	if err != nil {
@@ -102,6 +107,26 @@ func configFromStorageInput(input gql.StorageConfigInput) (*config.Config, error
 		if input.S3Config.BaseDir != nil {
 			cfg.S3StorageBaseDir = *input.S3Config.BaseDir
 		}
+	case gql.StorageTypeSftp:
+		if input.SftpConfig == nil {
+			return nil, errMissingSFTPStorageConfig
+		}
+		cfg.StorageType = "sftp"
+		cfg.SFTPStorageHost = input.SftpConfig.Host
+		cfg.SFTPStorageUser = input.SftpConfig.User
+		cfg.SFTPStoragePrivateKey = input.SftpConfig.PrivateKey
+		if input.SftpConfig.Port != nil {
+			cfg.SFTPStoragePort = *input.SftpConfig.Port
+		}
+		if input.SftpConfig.PrivateKeyPassphrase != nil {
+			cfg.SFTPStoragePrivateKeyPassphrase = *input.SftpConfig.PrivateKeyPassphrase
+		}
+		if input.SftpConfig.HostKey != nil {
+			cfg.SFTPStorageHostKey = *input.SftpConfig.HostKey
+		}
+		if input.SftpConfig.BaseDir != nil {
+			cfg.SFTPStorageBaseDir = *input.SftpConfig.BaseDir
+		}
 	default:
 		return nil, errUnsupportedStorageType
 	}
@@ -345,6 +370,7 @@ func optionalCode(value string) *string {
 var (
 	errMissingFileStorageConfig     = errors.New("File configuration is required for file storage type")
 	errMissingS3StorageConfig       = errors.New("S3 configuration is required for S3 storage type")
+	errMissingSFTPStorageConfig     = errors.New("SFTP configuration is required for SFTP storage type")
 	errUnsupportedStorageType       = errors.New("unsupported storage type")
 	errStorageDoesNotSupportPresign = errors.New("storage backend does not support presigned uploads")
 )
@@ -0,0 +1,127 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/storagecost"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+// maxCostEstimateObjects bounds the storage walk of a cost estimate
+const maxCostEstimateObjects = 1000000
+
+var errCostEstimateLimit = errors.New("cost estimate object limit reached")
+
+// StorageCostEstimate is the resolver for the storageCostEstimate field.
+func (r *queryResolver) StorageCostEstimate(ctx context.Context, spaceID *string, pricing *gql.StoragePricingInput) (*gql.StorageCostReport, error) {
+	if err := RequireAdminPermission(ctx); err != nil {
+		return nil, err
+	}
+	unitPrices := storagePricingFromInput(pricing)
+	if err := unitPrices.Validate(); err != nil {
+		return nil, &gqlerror.Error{
+			Message:    err.Error(),
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+
+	spaceConfig, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	var stor storage.Storage
+	if spaceConfig != nil {
+		stor, err = r.storageFromSpaceConfig(spaceConfig)
+	} else {
+		stor, err = r.getSpaceStorageByID(ctx, spaceID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	estimator := storagecost.NewEstimator(unitPrices)
+	var count int
+	truncated := false
+	err = walkStorageFiles(ctx, stor, "", func(item storage.FileInfo) error {
+		if count >= maxCostEstimateObjects {
+			return errCostEstimateLimit
+		}
+		count++
+		estimator.Add(item.Path, item.Size, item.StorageClass)
+		return nil
+	})
+	if errors.Is(err, errCostEstimateLimit) {
+		truncated = true
+	} else if err != nil {
+		r.logger.Error("Failed to walk storage for cost estimate", zap.Error(err))
+		return nil, err
+	}
+	return toGQLStorageCostReport(estimator.Report(), truncated), nil
+}
+
+// storagePricingFromInput applies the given unit prices over the defaults
+func storagePricingFromInput(input *gql.StoragePricingInput) storagecost.Pricing {
+	pricing := storagecost.DefaultPricing()
+	if input == nil {
+		return pricing
+	}
+	if input.StoragePerGbMonth != nil {
+		pricing.StoragePerGBMonth = *input.StoragePerGbMonth
+	}
+	if input.EgressPerGb != nil {
+		pricing.EgressPerGB = *input.EgressPerGb
+	}
+	if input.MonthlyReadRatio != nil {
+		pricing.MonthlyReadRatio = *input.MonthlyReadRatio
+	}
+	for _, price := range input.StorageClassPrices {
+		if price == nil {
+			continue
+		}
+		if pricing.ClassPerGBMonth == nil {
+			pricing.ClassPerGBMonth = make(map[string]float64)
+		}
+		pricing.ClassPerGBMonth[strings.ToUpper(strings.TrimSpace(price.StorageClass))] = price.PerGbMonth
+	}
+	return pricing
+}
+
+func toGQLStorageCostReport(report storagecost.Report, truncated bool) *gql.StorageCostReport {
+	folders := make([]*gql.FolderCostEstimate, 0, len(report.Folders))
+	for _, folder := range report.Folders {
+		classes := make([]*gql.StorageClassUsage, 0, len(folder.Classes))
+		for _, class := range folder.Classes {
+			classes = append(classes, &gql.StorageClassUsage{
+				StorageClass: class.StorageClass,
+				ObjectCount:  int(class.Objects),
+				Bytes:        int(class.Bytes),
+				StorageCost:  class.StorageCost,
+			})
+		}
+		folders = append(folders, &gql.FolderCostEstimate{
+			Folder:         folder.Folder,
+			ObjectCount:    int(folder.Objects),
+			Bytes:          int(folder.Bytes),
+			StorageClasses: classes,
+			StorageCost:    folder.StorageCost,
+			EgressCost:     folder.EgressCost,
+			TotalCost:      folder.TotalCost(),
+		})
+	}
+	return &gql.StorageCostReport{
+		Folders:      folders,
+		TotalObjects: int(report.Objects),
+		TotalBytes:   int(report.Bytes),
+		StorageCost:  report.StorageCost,
+		EgressCost:   report.EgressCost,
+		TotalCost:    report.TotalCost(),
+		Truncated:    truncated,
+		GeneratedAt:  time.Now().UTC().Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,58 @@
+package resolver
+
+import (
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func TestStorageCostEstimate(t *testing.T) {
+	baseDir := t.TempDir()
+	writeTestFile(t, baseDir, "archive/2024/a.jpg")
+	writeTestFile(t, baseDir, "archive/b.jpg")
+	writeTestFile(t, baseDir, "photos/c.jpg")
+	writeTestFile(t, baseDir, "root.jpg")
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+	resolver := newTestResolver(NewMockStorageProvider(stor), new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop())
+
+	_, err = resolver.Query().StorageCostEstimate(createReadWriteContext("user-1"), nil, nil)
+	require.Error(t, err)
+
+	report, err := resolver.Query().StorageCostEstimate(createAdminContext("admin-1"), nil, &gql.StoragePricingInput{
+		MonthlyReadRatio: floatPtr(1),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 4, report.TotalObjects)
+	assert.False(t, report.Truncated)
+	require.Len(t, report.Folders, 3)
+	assert.Equal(t, "archive", report.Folders[0].Folder)
+	assert.Equal(t, 2, report.Folders[0].ObjectCount)
+	assert.Equal(t, "STANDARD", report.Folders[0].StorageClasses[0].StorageClass)
+	assert.Positive(t, report.StorageCost)
+	assert.Positive(t, report.EgressCost)
+	assert.InDelta(t, report.StorageCost+report.EgressCost, report.TotalCost, 1e-12)
+
+	var gqlErr *gqlerror.Error
+	_, err = resolver.Query().StorageCostEstimate(createAdminContext("admin-1"), nil, &gql.StoragePricingInput{
+		StoragePerGbMonth: floatPtr(-1),
+	})
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+}
+
+func TestStoragePricingFromInput(t *testing.T) {
+	pricing := storagePricingFromInput(&gql.StoragePricingInput{
+		EgressPerGb:        floatPtr(0),
+		StorageClassPrices: []*gql.StorageClassPriceInput{{StorageClass: " glacier ", PerGbMonth: 0.004}},
+	})
+	assert.Equal(t, 0.023, pricing.StoragePerGBMonth)
+	assert.Zero(t, pricing.EgressPerGB)
+	assert.Equal(t, map[string]float64{"GLACIER": 0.004}, pricing.ClassPerGBMonth)
+}
@@ -0,0 +1,170 @@
+package resolver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/registryutil"
+	"github.com/cshum/imagor-studio/server/internal/storageprovider"
+	"github.com/cshum/imagor-studio/server/pkg/storage/mountstorage"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+// StorageMounts is the resolver for the storageMounts field.
+func (r *queryResolver) StorageMounts(ctx context.Context) ([]*gql.StorageMount, error) {
+	if err := RequireAdminPermission(ctx); err != nil {
+		return nil, err
+	}
+	mounts, err := r.getStorageMounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*gql.StorageMount, 0, len(mounts))
+	for _, mount := range mounts {
+		result = append(result, toGQLStorageMount(mount))
+	}
+	return result, nil
+}
+
+// ConfigureStorageMount is the resolver for the configureStorageMount field.
+func (r *mutationResolver) ConfigureStorageMount(ctx context.Context, name string, input gql.StorageConfigInput) (*gql.StorageConfigResult, error) {
+	if err := RequireAdminPermission(ctx); err != nil {
+		return nil, err
+	}
+	name = strings.TrimSpace(name)
+	if !mountstorage.ValidName(name) {
+		return nil, &gqlerror.Error{
+			Message:    "mount name must be lower case letters, digits, dots, dashes or underscores",
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+
+	r.logger.Debug("Configuring storage mount", zap.String("name", name), zap.String("type", string(input.Type)))
+
+	testResult := r.validateStorageConfig(ctx, input)
+	if !testResult.Success {
+		return &gql.StorageConfigResult{
+			Success:   false,
+			Timestamp: fmt.Sprintf("%d", time.Now().UnixMilli()),
+			Message:   &testResult.Message,
+		}, nil
+	}
+	cfg, err := configFromStorageInput(input)
+	if err != nil {
+		return nil, &gqlerror.Error{
+			Message:    err.Error(),
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+
+	mounts, err := r.getStorageMounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	mount := storageprovider.Mount{Name: name, Config: storageprovider.ConfigEntries(cfg)}
+	replaced := false
+	for i := range mounts {
+		if mounts[i].Name == name {
+			mounts[i] = mount
+			replaced = true
+		}
+	}
+	if !replaced {
+		mounts = append(mounts, mount)
+	}
+
+	timestampStr := fmt.Sprintf("%d", time.Now().UnixMilli())
+	if err := r.saveStorageMounts(ctx, mounts); err != nil {
+		r.logger.Error("Failed to save storage mount", zap.String("name", name), zap.Error(err))
+		return &gql.StorageConfigResult{
+			Success:   false,
+			Timestamp: timestampStr,
+			Message:   &[]string{"Failed to save configuration"}[0],
+		}, nil
+	}
+
+	return &gql.StorageConfigResult{
+		Success:   true,
+		Timestamp: timestampStr,
+		Message:   &[]string{fmt.Sprintf("Storage mount /%s saved, it will be available shortly", name)}[0],
+	}, nil
+}
+
+// RemoveStorageMount is the resolver for the removeStorageMount field.
+func (r *mutationResolver) RemoveStorageMount(ctx context.Context, name string) (bool, error) {
+	if err := RequireAdminPermission(ctx); err != nil {
+		return false, err
+	}
+	name = strings.TrimSpace(name)
+	mounts, err := r.getStorageMounts(ctx)
+	if err != nil {
+		return false, err
+	}
+	kept := mounts[:0]
+	for _, mount := range mounts {
+		if mount.Name != name {
+			kept = append(kept, mount)
+		}
+	}
+	if len(kept) == len(mounts) {
+		return false, &gqlerror.Error{
+			Message:    fmt.Sprintf("storage mount %s not found", name),
+			Extensions: map[string]interface{}{"code": "NOT_FOUND"},
+		}
+	}
+	if err := r.saveStorageMounts(ctx, kept); err != nil {
+		return false, fmt.Errorf("failed to remove storage mount: %w", err)
+	}
+	r.logger.Info("Storage mount removed", zap.String("name", name))
+	return true, nil
+}
+
+// getStorageMounts returns the configured mounts ordered by name
+func (r *Resolver) getStorageMounts(ctx context.Context) ([]storageprovider.Mount, error) {
+	result := registryutil.GetEffectiveValue(ctx, r.registryStore, r.config, storageprovider.MountsKey)
+	if !result.Exists {
+		return nil, nil
+	}
+	mounts, err := storageprovider.ParseMounts(result.Value)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(mounts, func(i, j int) bool { return mounts[i].Name < mounts[j].Name })
+	return mounts, nil
+}
+
+// saveStorageMounts stores the mounts encrypted, as their configs carry
+// credentials. The storage provider picks them up on its next sync.
+func (r *mutationResolver) saveStorageMounts(ctx context.Context, mounts []storageprovider.Mount) error {
+	if mounts == nil {
+		mounts = []storageprovider.Mount{}
+	}
+	sort.Slice(mounts, func(i, j int) bool { return mounts[i].Name < mounts[j].Name })
+	value, err := json.Marshal(mounts)
+	if err != nil {
+		return err
+	}
+	_, err = r.setSystemRegistryEntries(ctx, []gql.RegistryEntryInput{
+		{Key: storageprovider.MountsKey, Value: string(value), IsEncrypted: true},
+	})
+	return err
+}
+
+func toGQLStorageMount(mount storageprovider.Mount) *gql.StorageMount {
+	result := &gql.StorageMount{Name: mount.Name, Type: mount.Config["config.storage_type"]}
+	switch result.Type {
+	case "file", "filesystem":
+		result.FileConfig = fileConfigFromEntries(mount.Config)
+	case "s3":
+		result.S3Config = s3ConfigFromEntries(mount.Config)
+	case "sftp":
+		result.SftpConfig = sftpConfigFromEntries(mount.Config)
+	}
+	return result
+}
@@ -0,0 +1,116 @@
+package resolver
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/internal/storageprovider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const existingMounts = `[{"name":"nas","config":{"config.storage_type":"file","config.file_storage_base_dir":"/mnt/nas"}},` +
+	`{"name":"archive","config":{"config.storage_type":"s3","config.s3_storage_bucket":"archive","config.s3_storage_secret_access_key":"secret"}}]`
+
+func TestStorageMounts_OmitsCredentials(t *testing.T) {
+	mockRegistryStore := new(MockRegistryStore)
+	logger, _ := zap.NewDevelopment()
+	resolver := newTestResolver(NewMockStorageProvider(new(MockStorage)), mockRegistryStore, new(MockUserStore), nil, &config.Config{}, nil, logger)
+
+	mockRegistryStore.On("GetMulti", mock.Anything, "system:global", []string{storageprovider.MountsKey}).
+		Return([]*registrystore.Registry{{Key: storageprovider.MountsKey, Value: existingMounts, IsEncrypted: true}}, nil)
+
+	result, err := resolver.Query().StorageMounts(createAdminContext("admin-user-id"))
+	require.NoError(t, err)
+	require.Len(t, result, 2)
+	assert.Equal(t, "archive", result[0].Name)
+	assert.Equal(t, "s3", result[0].Type)
+	assert.Equal(t, "archive", result[0].S3Config.Bucket)
+	assert.Equal(t, "nas", result[1].Name)
+	assert.Equal(t, "/mnt/nas", result[1].FileConfig.BaseDir)
+
+	_, err = resolver.Query().StorageMounts(createReadOnlyContext("test-user-id"))
+	assert.Error(t, err)
+}
+
+func TestConfigureStorageMount_UpsertsMount(t *testing.T) {
+	mockRegistryStore := new(MockRegistryStore)
+	logger, _ := zap.NewDevelopment()
+	resolver := newTestResolver(NewMockStorageProvider(new(MockStorage)), mockRegistryStore, new(MockUserStore), nil, &config.Config{}, nil, logger,
+		WithStorageConfigValidator(func(ctx context.Context, input gql.StorageConfigInput) *gql.StorageTestResult {
+			return &gql.StorageTestResult{Success: true}
+		}))
+	ctx := createAdminContext("admin-user-id")
+
+	mockRegistryStore.On("GetMulti", mock.Anything, "system:global", []string{storageprovider.MountsKey}).
+		Return([]*registrystore.Registry{{Key: storageprovider.MountsKey, Value: existingMounts, IsEncrypted: true}}, nil)
+	var saved []storageprovider.Mount
+	mockRegistryStore.On("SetMulti", ctx, "system:global", mock.MatchedBy(func(entries []*registrystore.Registry) bool {
+		return len(entries) == 1 && entries[0].Key == storageprovider.MountsKey && entries[0].IsEncrypted &&
+			json.Unmarshal([]byte(entries[0].Value), &saved) == nil
+	})).Return([]*registrystore.Registry{}, nil)
+
+	result, err := resolver.Mutation().ConfigureStorageMount(ctx, "nas", gql.StorageConfigInput{
+		Type:       gql.StorageTypeFile,
+		FileConfig: &gql.FileStorageInput{BaseDir: "/mnt/nas2"},
+	})
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	require.Len(t, saved, 2)
+	assert.Equal(t, "archive", saved[0].Name)
+	assert.Equal(t, "secret", saved[0].Config["config.s3_storage_secret_access_key"])
+	assert.Equal(t, "nas", saved[1].Name)
+	assert.Equal(t, "/mnt/nas2", saved[1].Config["config.file_storage_base_dir"])
+
+	_, err = resolver.Mutation().ConfigureStorageMount(ctx, "../etc", gql.StorageConfigInput{Type: gql.StorageTypeFile})
+	assert.Error(t, err)
+	_, err = resolver.Mutation().ConfigureStorageMount(createReadWriteContext("test-user-id"), "nas", gql.StorageConfigInput{Type: gql.StorageTypeFile})
+	assert.Error(t, err)
+}
+
+func TestConfigureStorageMount_ValidationFailure(t *testing.T) {
+	mockRegistryStore := new(MockRegistryStore)
+	logger, _ := zap.NewDevelopment()
+	resolver := newTestResolver(NewMockStorageProvider(new(MockStorage)), mockRegistryStore, new(MockUserStore), nil, &config.Config{}, nil, logger,
+		WithStorageConfigValidator(func(ctx context.Context, input gql.StorageConfigInput) *gql.StorageTestResult {
+			return &gql.StorageTestResult{Success: false, Message: "Directory does not exist"}
+		}))
+
+	result, err := resolver.Mutation().ConfigureStorageMount(createAdminContext("admin-user-id"), "nas", gql.StorageConfigInput{
+		Type:       gql.StorageTypeFile,
+		FileConfig: &gql.FileStorageInput{BaseDir: "/missing"},
+	})
+	require.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.Equal(t, "Directory does not exist", *result.Message)
+	mockRegistryStore.AssertNotCalled(t, "SetMulti", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRemoveStorageMount(t *testing.T) {
+	mockRegistryStore := new(MockRegistryStore)
+	logger, _ := zap.NewDevelopment()
+	resolver := newTestResolver(NewMockStorageProvider(new(MockStorage)), mockRegistryStore, new(MockUserStore), nil, &config.Config{}, nil, logger)
+	ctx := createAdminContext("admin-user-id")
+
+	mockRegistryStore.On("GetMulti", mock.Anything, "system:global", []string{storageprovider.MountsKey}).
+		Return([]*registrystore.Registry{{Key: storageprovider.MountsKey, Value: existingMounts, IsEncrypted: true}}, nil)
+	var saved []storageprovider.Mount
+	mockRegistryStore.On("SetMulti", ctx, "system:global", mock.MatchedBy(func(entries []*registrystore.Registry) bool {
+		return len(entries) == 1 && json.Unmarshal([]byte(entries[0].Value), &saved) == nil
+	})).Return([]*registrystore.Registry{}, nil)
+
+	ok, err := resolver.Mutation().RemoveStorageMount(ctx, "nas")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	require.Len(t, saved, 1)
+	assert.Equal(t, "archive", saved[0].Name)
+
+	_, err = resolver.Mutation().RemoveStorageMount(ctx, "missing")
+	assert.Error(t, err)
+}
@@ -0,0 +1,277 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/storagestats"
+	"github.com/cshum/imagor-studio/server/pkg/apperror"
+	"github.com/cshum/imagor-studio/server/pkg/space"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+// StorageStats is the resolver for the storageStats field.
+func (r *queryResolver) StorageStats(ctx context.Context, path string, spaceID *string) (*gql.StorageStats, error) {
+	path, err := ScopePath(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if err := RequireReadPermission(ctx, path); err != nil {
+		return nil, err
+	}
+	if r.storageStatsStore == nil {
+		return nil, storageStatsNotAvailableError()
+	}
+	path = strings.Trim(path, "/")
+	spaceConfig, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := r.storageStatsStore.Get(ctx, fileMetadataScope(spaceConfig), path)
+	if err != nil || stats == nil {
+		return nil, err
+	}
+	extensions := make([]*gql.ExtensionUsage, len(stats.Extensions))
+	for i, usage := range stats.Extensions {
+		extensions[i] = &gql.ExtensionUsage{
+			Extension: usage.Extension,
+			FileCount: int(usage.FileCount),
+			TotalSize: int(usage.TotalSize),
+		}
+	}
+	return &gql.StorageStats{
+		Path:       path,
+		FileCount:  int(stats.FileCount),
+		TotalSize:  int(stats.TotalSize),
+		Extensions: extensions,
+		ScannedAt:  stats.ScannedAt.Format(time.RFC3339),
+	}, nil
+}
+
+// StorageQuotas is the resolver for the storageQuotas field.
+func (r *queryResolver) StorageQuotas(ctx context.Context, spaceID *string) ([]*gql.StorageQuota, error) {
+	if err := RequireAdminPermission(ctx); err != nil {
+		return nil, err
+	}
+	if r.storageStatsStore == nil {
+		return nil, storageStatsNotAvailableError()
+	}
+	spaceConfig, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	scope := fileMetadataScope(spaceConfig)
+	quotas, err := r.storageStatsStore.Quotas(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*gql.StorageQuota, len(quotas))
+	for i, quota := range quotas {
+		used, err := r.storageQuotaUsage(ctx, scope, quota)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = toGQLStorageQuota(quota, used)
+	}
+	return result, nil
+}
+
+// SetStorageQuota is the resolver for the setStorageQuota field.
+func (r *mutationResolver) SetStorageQuota(ctx context.Context, kind gql.StorageQuotaKind, target string, limitBytes *int, spaceID *string) (*gql.StorageQuota, error) {
+	if err := RequireAdminPermission(ctx); err != nil {
+		return nil, err
+	}
+	if r.storageStatsStore == nil {
+		return nil, storageStatsNotAvailableError()
+	}
+	limit := int64(0)
+	if limitBytes != nil {
+		limit = int64(*limitBytes)
+	}
+	if limit < 0 {
+		return nil, &gqlerror.Error{
+			Message:    "limitBytes must not be negative",
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	storeKind := storagestats.KindPath
+	if kind == gql.StorageQuotaKindUser {
+		storeKind = storagestats.KindUser
+		if target == "" {
+			return nil, &gqlerror.Error{
+				Message:    "target must be a user ID",
+				Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+			}
+		}
+	} else {
+		target = strings.Trim(target, "/")
+	}
+	spaceConfig, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	userID, err := GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	scope := fileMetadataScope(spaceConfig)
+	if err := r.storageStatsStore.SetQuota(ctx, scope, storeKind, target, limit, userID); err != nil {
+		return nil, err
+	}
+	if limit == 0 {
+		return nil, nil
+	}
+	quota := storagestats.Quota{Kind: storeKind, Target: target, LimitBytes: limit, UpdatedBy: userID, UpdatedAt: time.Now().UTC()}
+	used, err := r.storageQuotaUsage(ctx, scope, quota)
+	if err != nil {
+		return nil, err
+	}
+	return toGQLStorageQuota(quota, used), nil
+}
+
+func storageStatsNotAvailableError() error {
+	return &gqlerror.Error{
+		Message:    "storage stats are not available",
+		Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+	}
+}
+
+func toGQLStorageQuota(quota storagestats.Quota, used int64) *gql.StorageQuota {
+	kind := gql.StorageQuotaKindPath
+	if quota.Kind == storagestats.KindUser {
+		kind = gql.StorageQuotaKindUser
+	}
+	return &gql.StorageQuota{
+		Kind:       kind,
+		Target:     quota.Target,
+		LimitBytes: int(quota.LimitBytes),
+		UsedBytes:  int(used),
+		UpdatedBy:  quota.UpdatedBy,
+		UpdatedAt:  quota.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+func (r *Resolver) storageQuotaUsage(ctx context.Context, scope string, quota storagestats.Quota) (int64, error) {
+	if quota.Kind == storagestats.KindUser {
+		return r.storageStatsStore.UserUsage(ctx, scope, quota.Target)
+	}
+	return r.storageStatsStore.PathUsage(ctx, scope, quota.Target)
+}
+
+// enforceStorageQuotas rejects an upload of incomingBytes to path that
+// would exceed a quota of the uploader or of a folder containing path
+func (r *Resolver) enforceStorageQuotas(ctx context.Context, sp *space.Space, path string, incomingBytes int64) error {
+	if r.storageStatsStore == nil {
+		return nil
+	}
+	scope := fileMetadataScope(sp)
+	quotas, err := r.storageStatsStore.Quotas(ctx, scope)
+	if err != nil {
+		return fmt.Errorf("failed to load storage quotas: %w", err)
+	}
+	if len(quotas) == 0 {
+		return nil
+	}
+	userID, _ := GetUserIDFromContext(ctx)
+	path = strings.Trim(path, "/")
+	for _, quota := range quotas {
+		if !quota.Applies(userID, path) {
+			continue
+		}
+		used, err := r.storageQuotaUsage(ctx, scope, quota)
+		if err != nil {
+			return fmt.Errorf("failed to get storage usage: %w", err)
+		}
+		if used+incomingBytes <= quota.LimitBytes {
+			continue
+		}
+		owner := "your uploads"
+		if quota.Kind == storagestats.KindPath {
+			owner = "the whole storage"
+			if quota.Target != "" {
+				owner = "folder " + quota.Target
+			}
+		}
+		return apperror.BadRequest(
+			fmt.Sprintf("storage quota exceeded: %s use %s of %s, uploading %s more is not allowed",
+				owner, formatBytes(used), formatBytes(quota.LimitBytes), formatBytes(incomingBytes)),
+			map[string]interface{}{
+				"reason":     "storage_quota_exceeded",
+				"quotaKind":  quota.Kind,
+				"quotaPath":  quota.Target,
+				"usedBytes":  used,
+				"limitBytes": quota.LimitBytes,
+			})
+	}
+	return nil
+}
+
+// recordUpload counts an upload toward the quotas of its uploader and
+// folders. Failures are logged rather than failing the stored upload.
+func (r *Resolver) recordUpload(ctx context.Context, stor storage.Storage, sp *space.Space, path string, size int64) {
+	if r.storageStatsStore == nil {
+		return
+	}
+	if size <= 0 {
+		info, err := stor.Stat(ctx, path)
+		if err != nil {
+			r.logger.Warn("Failed to stat upload", zap.String("path", path), zap.Error(err))
+			return
+		}
+		size = info.Size
+	}
+	userID, _ := GetUserIDFromContext(ctx)
+	if err := r.storageStatsStore.RecordUpload(ctx, fileMetadataScope(sp), strings.Trim(path, "/"), userID, size); err != nil {
+		r.logger.Warn("Failed to record upload", zap.String("path", path), zap.Error(err))
+	}
+}
+
+// moveStorageUsage keeps uploads and folder quotas following a moved file
+// or folder. Failures are logged rather than failing the move that already
+// happened.
+func (r *Resolver) moveStorageUsage(ctx context.Context, spaceID *string, sourcePath, destPath string) {
+	if r.storageStatsStore == nil {
+		return
+	}
+	spaceConfig, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err == nil {
+		err = r.storageStatsStore.MoveFilePath(ctx, fileMetadataScope(spaceConfig), strings.Trim(sourcePath, "/"), strings.Trim(destPath, "/"))
+	}
+	if err != nil {
+		r.logger.Warn("Failed to move storage usage", zap.String("source", sourcePath), zap.String("dest", destPath), zap.Error(err))
+	}
+}
+
+// removeStorageUsage drops the uploads of a deleted file or folder, and the
+// quotas of deleted folders
+func (r *Resolver) removeStorageUsage(ctx context.Context, spaceID *string, path string) {
+	if r.storageStatsStore == nil {
+		return
+	}
+	spaceConfig, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err == nil {
+		err = r.storageStatsStore.RemoveFilePath(ctx, fileMetadataScope(spaceConfig), strings.Trim(path, "/"))
+	}
+	if err != nil {
+		r.logger.Warn("Failed to remove storage usage", zap.String("path", path), zap.Error(err))
+	}
+}
+
+// formatBytes formats n in binary units, e.g. 1.5 GiB
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
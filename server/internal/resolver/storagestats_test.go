@@ -0,0 +1,127 @@
+package resolver
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/internal/storagestats"
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func newStorageStatsTestResolver(t *testing.T) (*Resolver, storagestats.Store) {
+	t.Helper()
+	db := testutil.NewDB(t)
+
+	stor, err := filestorage.New(t.TempDir())
+	require.NoError(t, err)
+
+	mockImagorProvider := new(MockImagorProvider)
+	mockImagorProvider.On("Imagor").Return((*imagor.Imagor)(nil))
+	mockImagorProvider.On("GenerateURL", mock.Anything, mock.Anything).Return("/imagor/thumbnail.webp", nil)
+	mockRegistryStore := new(MockRegistryStore)
+	mockRegistryStore.On("GetMulti", mock.Anything, mock.Anything, mock.Anything).Return([]*registrystore.Registry{}, nil)
+	mockRegistryStore.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil)
+	logger := zap.NewNop()
+	statsStore := storagestats.NewStore(db, logger)
+	return newTestResolver(NewMockStorageProvider(stor), mockRegistryStore, new(MockUserStore), mockImagorProvider, &config.Config{}, nil, logger,
+		WithStorageStatsStore(statsStore)), statsStore
+}
+
+func upload(content string) graphql.Upload {
+	return graphql.Upload{File: strings.NewReader(content), Size: int64(len(content))}
+}
+
+func TestStorageStats(t *testing.T) {
+	resolver, statsStore := newStorageStatsTestResolver(t)
+	ctx := createReadOnlyContext("user-1")
+
+	stats, err := resolver.Query().StorageStats(ctx, "photos", nil)
+	require.NoError(t, err)
+	assert.Nil(t, stats, "no stats before the first scan")
+
+	require.NoError(t, statsStore.Replace(context.Background(), "system:global", storagestats.Compute([]storage.FileInfo{
+		{Name: "a.jpg", Path: "photos/a.jpg", Size: 10},
+		{Name: "b.mp4", Path: "photos/b.mp4", Size: 30},
+	}, []string{"", "photos"}, time.Now())))
+
+	stats, err = resolver.Query().StorageStats(ctx, "/photos/", nil)
+	require.NoError(t, err)
+	require.NotNil(t, stats)
+	assert.Equal(t, "photos", stats.Path)
+	assert.Equal(t, 2, stats.FileCount)
+	assert.Equal(t, 40, stats.TotalSize)
+	require.Len(t, stats.Extensions, 2)
+	assert.Equal(t, gql.ExtensionUsage{Extension: "mp4", FileCount: 1, TotalSize: 30}, *stats.Extensions[0])
+	assert.NotEmpty(t, stats.ScannedAt)
+}
+
+func TestStorageQuotas(t *testing.T) {
+	resolver, _ := newStorageStatsTestResolver(t)
+	adminCtx := createAdminContext("admin-1")
+	ctx := createReadWriteContext("user-1")
+
+	_, err := resolver.Mutation().SetStorageQuota(ctx, gql.StorageQuotaKindUser, "user-1", intPtr(10), nil)
+	assert.Error(t, err, "quotas are set by admins")
+
+	quota, err := resolver.Mutation().SetStorageQuota(adminCtx, gql.StorageQuotaKindUser, "user-1", intPtr(10), nil)
+	require.NoError(t, err)
+	assert.Equal(t, 10, quota.LimitBytes)
+	assert.Equal(t, 0, quota.UsedBytes)
+	_, err = resolver.Mutation().SetStorageQuota(adminCtx, gql.StorageQuotaKindPath, "/shared/", intPtr(8), nil)
+	require.NoError(t, err)
+
+	_, err = resolver.Mutation().UploadFile(ctx, "photos/a.txt", nil, upload("123456"), nil)
+	require.NoError(t, err)
+
+	// The user quota counts earlier uploads
+	_, err = resolver.Mutation().UploadFile(ctx, "photos/b.txt", nil, upload("123456"), nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "storage_quota_exceeded", gqlErr.Extensions["reason"])
+	assert.Contains(t, gqlErr.Message, "your uploads use 6 B of 10 B")
+
+	// Folder quotas count the uploads of every user
+	_, err = resolver.Mutation().UploadFile(createReadWriteContext("user-2"), "shared/a.txt", nil, upload("12345"), nil)
+	require.NoError(t, err)
+	_, err = resolver.Mutation().UploadFile(createReadWriteContext("user-2"), "shared/b.txt", nil, upload("12345"), nil)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Contains(t, gqlErr.Message, "folder shared")
+
+	quotas, err := resolver.Query().StorageQuotas(adminCtx, nil)
+	require.NoError(t, err)
+	require.Len(t, quotas, 2)
+	assert.Equal(t, gql.StorageQuotaKindPath, quotas[0].Kind)
+	assert.Equal(t, "shared", quotas[0].Target)
+	assert.Equal(t, 5, quotas[0].UsedBytes)
+	assert.Equal(t, 6, quotas[1].UsedBytes)
+
+	// Deleted files no longer count toward the quota of their uploader
+	_, err = resolver.Mutation().DeleteFile(ctx, "photos/a.txt", nil)
+	require.NoError(t, err)
+	_, err = resolver.Mutation().UploadFile(ctx, "photos/b.txt", nil, upload("123456"), nil)
+	require.NoError(t, err)
+
+	quota, err = resolver.Mutation().SetStorageQuota(adminCtx, gql.StorageQuotaKindUser, "user-1", nil, nil)
+	require.NoError(t, err)
+	assert.Nil(t, quota)
+	quotas, err = resolver.Query().StorageQuotas(adminCtx, nil)
+	require.NoError(t, err)
+	assert.Len(t, quotas, 1)
+
+	_, err = resolver.Query().StorageQuotas(ctx, nil)
+	assert.Error(t, err)
+}
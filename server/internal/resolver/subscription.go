@@ -0,0 +1,213 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/events"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/operation"
+	"github.com/cshum/imagor-studio/server/pkg/space"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+// storageStatusPollInterval picks up storage changes made elsewhere, such as
+// a staged configuration applied by the registry sync of another replica
+const storageStatusPollInterval = 30 * time.Second
+
+// OperationUpdated is the resolver for the operationUpdated field.
+func (r *subscriptionResolver) OperationUpdated(ctx context.Context, id string) (<-chan *gql.Operation, error) {
+	if err := RequirePermission(ctx, "read"); err != nil {
+		return nil, err
+	}
+	if _, err := r.getOwnedOperation(ctx, id); err != nil {
+		if errors.Is(err, operation.ErrNotFound) {
+			return nil, operationNotFoundError(id)
+		}
+		return nil, err
+	}
+	updates, err := r.operations.Watch(ctx, id)
+	if err != nil {
+		return nil, operationNotFoundError(id)
+	}
+	ch := make(chan *gql.Operation)
+	go func() {
+		defer close(ch)
+		for op := range updates {
+			select {
+			case ch <- toGQLOperation(op):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// FileChanged is the resolver for the fileChanged field.
+func (r *subscriptionResolver) FileChanged(ctx context.Context, path string, spaceID *string) (<-chan *gql.FileChange, error) {
+	path, err := ScopePath(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if err := RequireReadPermission(ctx, path); err != nil {
+		return nil, err
+	}
+	if r.events == nil {
+		return nil, &gqlerror.Error{
+			Message:    "file change notifications are not available",
+			Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+		}
+	}
+	folder := strings.Trim(path, "/")
+	spaceConfig, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	scope := fileMetadataScope(spaceConfig)
+	changes := r.events.Subscribe(ctx, func(e events.Event) bool {
+		return e.Kind != events.StorageChanged && e.Scope == scope && e.Within(folder)
+	})
+	ch := make(chan *gql.FileChange)
+	go func() {
+		defer close(ch)
+		for e := range changes {
+			select {
+			case ch <- toGQLFileChange(e, folder):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// StorageStatusChanged is the resolver for the storageStatusChanged field.
+func (r *subscriptionResolver) StorageStatusChanged(ctx context.Context) (<-chan *gql.StorageStatus, error) {
+	query := &queryResolver{r.Resolver}
+	status, err := query.StorageStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var changes <-chan events.Event
+	if r.events != nil {
+		changes = r.events.Subscribe(ctx, func(e events.Event) bool {
+			return e.Kind == events.StorageChanged
+		})
+	}
+	ch := make(chan *gql.StorageStatus, 1)
+	ch <- status
+	go func() {
+		defer close(ch)
+		ticker := time.NewTicker(storageStatusPollInterval)
+		defer ticker.Stop()
+		last := status
+		for {
+			select {
+			case _, ok := <-changes:
+				if !ok {
+					return
+				}
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+			status, err := query.StorageStatus(ctx)
+			if err != nil {
+				r.logger.Warn("Failed to resolve storage status", zap.Error(err))
+				continue
+			}
+			if reflect.DeepEqual(status, last) {
+				continue
+			}
+			select {
+			case ch <- status:
+				last = status
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// publishFileChange notifies fileChanged subscribers of a change made in the
+// storage of spaceID, and drops the cached listings it affects
+func (r *Resolver) publishFileChange(ctx context.Context, spaceID *string, kind events.Kind, path, oldPath string) {
+	if r.events == nil && r.listCache == nil {
+		return
+	}
+	spaceConfig, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err != nil {
+		r.logger.Warn("Failed to publish file change", zap.String("path", path), zap.Error(err))
+		return
+	}
+	r.publishSpaceFileChange(spaceConfig, kind, path, oldPath)
+}
+
+// publishSpaceFileChange is publishFileChange for a resolved space
+func (r *Resolver) publishSpaceFileChange(spaceConfig *space.Space, kind events.Kind, path, oldPath string) {
+	r.publishSpaceEvent(spaceConfig, events.Event{
+		Kind:    kind,
+		Path:    strings.Trim(path, "/"),
+		OldPath: strings.Trim(oldPath, "/"),
+	})
+}
+
+// publishSpaceUpload is publishSpaceFileChange for a file uploaded by a
+// client, which webhooks tell apart from other created files
+func (r *Resolver) publishSpaceUpload(spaceConfig *space.Space, path string) {
+	r.publishSpaceEvent(spaceConfig, events.Event{
+		Kind:     events.FileCreated,
+		Path:     strings.Trim(path, "/"),
+		Uploaded: true,
+	})
+}
+
+func (r *Resolver) publishSpaceEvent(spaceConfig *space.Space, e events.Event) {
+	r.invalidateListing(spaceConfig, e.Path)
+	r.invalidateListing(spaceConfig, e.OldPath)
+	if r.events == nil {
+		return
+	}
+	e.Scope = fileMetadataScope(spaceConfig)
+	r.events.Publish(e)
+}
+
+// publishStorageChange notifies storageStatusChanged subscribers, and drops
+// the cached listings of the previous storage
+func (r *Resolver) publishStorageChange() {
+	if r.listCache != nil {
+		r.listCache.Purge(context.Background(), fileMetadataScope(nil))
+	}
+	if r.events != nil {
+		r.events.Publish(events.Event{Kind: events.StorageChanged})
+	}
+}
+
+// toGQLFileChange reports a change as seen from folder: a move out of it is a
+// deletion and a move into it a creation, so paths outside of the folder are
+// never revealed
+func toGQLFileChange(e events.Event, folder string) *gql.FileChange {
+	switch e.Kind {
+	case events.FileMoved:
+		switch {
+		case !events.PathWithin(e.Path, folder):
+			return &gql.FileChange{Kind: gql.FileChangeKindDeleted, Path: e.OldPath}
+		case !events.PathWithin(e.OldPath, folder):
+			return &gql.FileChange{Kind: gql.FileChangeKindCreated, Path: e.Path}
+		}
+		oldPath := e.OldPath
+		return &gql.FileChange{Kind: gql.FileChangeKindMoved, Path: e.Path, OldPath: &oldPath}
+	case events.FileDeleted:
+		return &gql.FileChange{Kind: gql.FileChangeKindDeleted, Path: e.Path}
+	case events.FileUpdated:
+		return &gql.FileChange{Kind: gql.FileChangeKindUpdated, Path: e.Path}
+	default:
+		return &gql.FileChange{Kind: gql.FileChangeKindCreated, Path: e.Path}
+	}
+}
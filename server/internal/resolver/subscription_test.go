@@ -0,0 +1,112 @@
+package resolver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/events"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/operation"
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func receiveFileChange(t *testing.T, ch <-chan *gql.FileChange) *gql.FileChange {
+	t.Helper()
+	select {
+	case change := <-ch:
+		require.NotNil(t, change)
+		return change
+	case <-time.After(5 * time.Second):
+		t.Fatal("no file change received")
+		return nil
+	}
+}
+
+func TestFileChanged(t *testing.T) {
+	baseDir := t.TempDir()
+	writeTestFile(t, baseDir, "photos/a.jpg")
+	writeTestFile(t, baseDir, "docs/b.pdf")
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+	resolver := newTestResolver(NewMockStorageProvider(stor), new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop(),
+		WithEvents(events.NewBroker()))
+	ctx := createReadWriteContext("user-1")
+
+	subCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	changes, err := resolver.Subscription().FileChanged(subCtx, "/photos", nil)
+	require.NoError(t, err)
+
+	_, err = resolver.Mutation().CreateFolder(ctx, "photos/trips", nil)
+	require.NoError(t, err)
+	assert.Equal(t, &gql.FileChange{Kind: gql.FileChangeKindCreated, Path: "photos/trips"}, receiveFileChange(t, changes))
+
+	// Changes outside of the folder are not sent
+	_, err = resolver.Mutation().DeleteFile(ctx, "docs/b.pdf", nil)
+	require.NoError(t, err)
+
+	_, err = resolver.Mutation().MoveFile(ctx, "photos/a.jpg", "photos/trips/a.jpg", nil)
+	require.NoError(t, err)
+	change := receiveFileChange(t, changes)
+	assert.Equal(t, gql.FileChangeKindMoved, change.Kind)
+	assert.Equal(t, "photos/trips/a.jpg", change.Path)
+	assert.Equal(t, "photos/a.jpg", *change.OldPath)
+
+	// Moving out of the folder is seen as a deletion
+	_, err = resolver.Mutation().MoveFile(ctx, "photos/trips/a.jpg", "docs/a.jpg", nil)
+	require.NoError(t, err)
+	assert.Equal(t, &gql.FileChange{Kind: gql.FileChangeKindDeleted, Path: "photos/trips/a.jpg"}, receiveFileChange(t, changes))
+
+	cancel()
+	select {
+	case _, ok := <-changes:
+		assert.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("subscription not closed")
+	}
+
+	// Without a broker there is nothing to subscribe to
+	disabled := newTestResolver(NewMockStorageProvider(stor), new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop())
+	_, err = disabled.Subscription().FileChanged(ctx, "photos", nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_AVAILABLE", gqlErr.Extensions["code"])
+}
+
+func TestOperationUpdated(t *testing.T) {
+	resolver, manager, _ := newOperationTestResolver(t)
+	release := make(chan struct{})
+	started := manager.Start(context.Background(), "test", "user-1", func(ctx context.Context, progress *operation.Progress) error {
+		progress.SetTotal(1)
+		<-release
+		progress.Advance(1)
+		return nil
+	})
+
+	_, err := resolver.Subscription().OperationUpdated(createReadOnlyContext("user-2"), started.ID)
+	assert.Error(t, err)
+
+	ctx, cancel := context.WithTimeout(createReadOnlyContext("user-1"), 5*time.Second)
+	defer cancel()
+	updates, err := resolver.Subscription().OperationUpdated(ctx, started.ID)
+	require.NoError(t, err)
+	first := <-updates
+	require.NotNil(t, first)
+	assert.Equal(t, gql.OperationStatusRunning, first.Status)
+
+	close(release)
+	var last *gql.Operation
+	for op := range updates {
+		last = op
+	}
+	require.NoError(t, ctx.Err())
+	require.NotNil(t, last)
+	assert.Equal(t, gql.OperationStatusSucceeded, last.Status)
+	assert.Equal(t, 1, last.Completed)
+}
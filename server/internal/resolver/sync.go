@@ -0,0 +1,95 @@
+package resolver
+
+import (
+	"context"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/events"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/pagination"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// syncToken is the position of a sync client in the file journal
+type syncToken struct {
+	Seq int64 `json:"s"`
+	// IssuedAt is no later than the changes after Seq were recorded, so
+	// none of them is pruned while it is within the retention
+	IssuedAt time.Time `json:"t"`
+}
+
+// ChangesSince is the resolver for the changesSince field.
+func (r *queryResolver) ChangesSince(ctx context.Context, token string, path *string, limit *int) (*gql.FileChangeSet, error) {
+	folder := ""
+	if path != nil {
+		folder = *path
+	}
+	folder, err := ScopePath(ctx, folder)
+	if err != nil {
+		return nil, err
+	}
+	// Checked even for the storage root, which sessions limited to a path
+	// prefix cannot sync
+	if err := RequirePathPermission(ctx, folder, "read"); err != nil {
+		return nil, err
+	}
+	if r.fileJournal == nil {
+		return nil, &gqlerror.Error{
+			Message:    "incremental sync is not available on this server",
+			Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+		}
+	}
+	// Read first, so changes recorded while listing are left for the next
+	// call rather than skipped
+	latest, err := r.fileJournal.Latest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	current := &gql.FileChangeSet{
+		Changes:   []*gql.FileChange{},
+		NextToken: pagination.Encode(syncToken{Seq: latest, IssuedAt: now}),
+	}
+	if token == "" {
+		return current, nil
+	}
+	var since syncToken
+	if err := pagination.Decode(token, &since); err != nil || since.IssuedAt.IsZero() {
+		return nil, &gqlerror.Error{
+			Message:    "invalid sync token, start again with an empty token",
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	// Tokens ahead of the journal are from before the database was
+	// restored or replaced
+	if since.Seq > latest || (r.journalRetention > 0 && since.IssuedAt.Before(now.Add(-r.journalRetention))) {
+		current.ResyncRequired = true
+		return current, nil
+	}
+	limitValue := 0
+	if limit != nil {
+		limitValue = *limit
+	}
+	entries, more, err := r.fileJournal.List(ctx, since.Seq, latest, folder, limitValue)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.Kind == events.StorageChanged {
+			current.Changes = []*gql.FileChange{}
+			current.ResyncRequired = true
+			return current, nil
+		}
+		current.Changes = append(current.Changes, toGQLFileChange(events.Event{
+			Kind:    entry.Kind,
+			Path:    entry.Path,
+			OldPath: entry.OldPath,
+		}, folder))
+	}
+	if more {
+		last := entries[len(entries)-1]
+		current.NextToken = pagination.Encode(syncToken{Seq: last.Seq, IssuedAt: last.ChangedAt})
+		current.HasMore = true
+	}
+	return current, nil
+}
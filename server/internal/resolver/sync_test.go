@@ -0,0 +1,144 @@
+package resolver
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/events"
+	"github.com/cshum/imagor-studio/server/internal/filejournal"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/pagination"
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func newSyncTestResolver(t *testing.T, retention time.Duration) (*Resolver, filejournal.Store) {
+	t.Helper()
+	db := testutil.NewDB(t)
+
+	logger := zap.NewNop()
+	store := filejournal.New(db, logger)
+	return newTestResolver(nil, new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, logger,
+		WithFileJournal(store, retention)), store
+}
+
+func TestChangesSince(t *testing.T) {
+	resolver, store := newSyncTestResolver(t, 0)
+	ctx := createReadOnlyContext("user-1")
+	require.NoError(t, store.Record(ctx, events.Event{Kind: events.FileCreated, Path: "photos/old.jpg"}))
+
+	// An empty token starts from the current changes
+	start, err := resolver.Query().ChangesSince(ctx, "", nil, nil)
+	require.NoError(t, err)
+	assert.Empty(t, start.Changes)
+	assert.False(t, start.HasMore)
+
+	require.NoError(t, store.Record(ctx, events.Event{Kind: events.FileCreated, Path: "photos/a.jpg"}))
+	require.NoError(t, store.Record(ctx, events.Event{Kind: events.FileUpdated, Path: "docs/b.pdf"}))
+	require.NoError(t, store.Record(ctx, events.Event{Kind: events.FileMoved, Path: "archive/a.jpg", OldPath: "photos/a.jpg"}))
+	require.NoError(t, store.Record(ctx, events.Event{Kind: events.FileDeleted, Path: "photos/c.jpg"}))
+
+	limit := 2
+	page, err := resolver.Query().ChangesSince(ctx, start.NextToken, nil, &limit)
+	require.NoError(t, err)
+	assert.True(t, page.HasMore)
+	assert.Equal(t, []*gql.FileChange{
+		{Kind: gql.FileChangeKindCreated, Path: "photos/a.jpg"},
+		{Kind: gql.FileChangeKindUpdated, Path: "docs/b.pdf"},
+	}, page.Changes)
+	page, err = resolver.Query().ChangesSince(ctx, page.NextToken, nil, &limit)
+	require.NoError(t, err)
+	assert.False(t, page.HasMore)
+	oldPath := "photos/a.jpg"
+	assert.Equal(t, []*gql.FileChange{
+		{Kind: gql.FileChangeKindMoved, Path: "archive/a.jpg", OldPath: &oldPath},
+		{Kind: gql.FileChangeKindDeleted, Path: "photos/c.jpg"},
+	}, page.Changes)
+
+	// Moves out of the folder synced are deletions
+	page, err = resolver.Query().ChangesSince(ctx, start.NextToken, stringPtr("photos"), nil)
+	require.NoError(t, err)
+	assert.Equal(t, []*gql.FileChange{
+		{Kind: gql.FileChangeKindCreated, Path: "photos/a.jpg"},
+		{Kind: gql.FileChangeKindDeleted, Path: "photos/a.jpg"},
+		{Kind: gql.FileChangeKindDeleted, Path: "photos/c.jpg"},
+	}, page.Changes)
+
+	// Caught up
+	page, err = resolver.Query().ChangesSince(ctx, page.NextToken, stringPtr("photos"), nil)
+	require.NoError(t, err)
+	assert.Empty(t, page.Changes)
+	assert.False(t, page.ResyncRequired)
+}
+
+func TestChangesSince_ResyncRequired(t *testing.T) {
+	resolver, store := newSyncTestResolver(t, 24*time.Hour)
+	ctx := createReadOnlyContext("user-1")
+	start, err := resolver.Query().ChangesSince(ctx, "", nil, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, store.Record(ctx, events.Event{Kind: events.FileCreated, Path: "a.jpg"}))
+	require.NoError(t, store.Record(ctx, events.Event{Kind: events.StorageChanged}))
+	page, err := resolver.Query().ChangesSince(ctx, start.NextToken, nil, nil)
+	require.NoError(t, err)
+	assert.True(t, page.ResyncRequired)
+	assert.Empty(t, page.Changes)
+
+	page, err = resolver.Query().ChangesSince(ctx, page.NextToken, nil, nil)
+	require.NoError(t, err)
+	assert.False(t, page.ResyncRequired)
+
+	// Changes after tokens older than the retention may have been pruned
+	stale := pagination.Encode(syncToken{Seq: 2, IssuedAt: time.Now().Add(-48 * time.Hour)})
+	page, err = resolver.Query().ChangesSince(ctx, stale, nil, nil)
+	require.NoError(t, err)
+	assert.True(t, page.ResyncRequired)
+
+	ahead := pagination.Encode(syncToken{Seq: 100, IssuedAt: time.Now()})
+	page, err = resolver.Query().ChangesSince(ctx, ahead, nil, nil)
+	require.NoError(t, err)
+	assert.True(t, page.ResyncRequired)
+
+	_, err = resolver.Query().ChangesSince(ctx, "not a token", nil, nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+}
+
+func TestChangesSince_Permissions(t *testing.T) {
+	resolver, store := newSyncTestResolver(t, 0)
+	require.NoError(t, store.Record(context.Background(), events.Event{Kind: events.FileCreated, Path: "photos/a.jpg"}))
+	require.NoError(t, store.Record(context.Background(), events.Event{Kind: events.FileCreated, Path: "teams/alice/b.jpg"}))
+	start := pagination.Encode(syncToken{IssuedAt: time.Now()})
+
+	_, err := resolver.Query().ChangesSince(createUserContext("user-1", "user", []string{"write"}), start, nil, nil)
+	assert.Error(t, err)
+
+	// Sessions limited to a path prefix sync below it only
+	guest := createEmbeddedReadWriteContext("guest", "/photos")
+	_, err = resolver.Query().ChangesSince(guest, start, nil, nil)
+	assert.Error(t, err)
+	page, err := resolver.Query().ChangesSince(guest, start, stringPtr("photos"), nil)
+	require.NoError(t, err)
+	assert.Len(t, page.Changes, 1)
+
+	// Users with a home path sync it from the root
+	home := WithHomePath(createReadOnlyContext("alice"), "teams/alice")
+	page, err = resolver.Query().ChangesSince(home, start, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, page.Changes, 1)
+	assert.Equal(t, "teams/alice/b.jpg", page.Changes[0].Path)
+}
+
+func TestChangesSince_NotAvailableWithoutJournal(t *testing.T) {
+	resolver := newTestResolver(nil, new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop())
+	_, err := resolver.Query().ChangesSince(createReadOnlyContext("user-1"), "", nil, nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_AVAILABLE", gqlErr.Extensions["code"])
+}
@@ -0,0 +1,83 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/updatecheck"
+	"github.com/cshum/imagor-studio/server/internal/version"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// ServerInfo is the resolver for the serverInfo field.
+func (r *queryResolver) ServerInfo(ctx context.Context) (*gql.ServerInfo, error) {
+	if err := RequirePermission(ctx, "read"); err != nil {
+		return nil, err
+	}
+
+	info := &gql.ServerInfo{Version: version.Get()}
+	if r.updateChecker == nil {
+		return info, nil
+	}
+	info.Version = r.updateChecker.CurrentVersion()
+
+	// Update details are only relevant to admins who can act on them
+	if RequireAdminPermission(ctx) != nil {
+		return info, nil
+	}
+	info.UpdateCheckEnabled = r.updateChecker.Enabled(ctx)
+	if advisory := r.updateChecker.Latest(ctx); advisory != nil {
+		info.Update = toGQLUpdateAdvisory(advisory)
+	}
+	return info, nil
+}
+
+// CheckForUpdates is the resolver for the checkForUpdates field.
+func (r *mutationResolver) CheckForUpdates(ctx context.Context) (*gql.UpdateAdvisory, error) {
+	if err := RequireAdminPermission(ctx); err != nil {
+		return nil, err
+	}
+	if r.updateChecker == nil || !r.updateChecker.Enabled(ctx) {
+		return nil, &gqlerror.Error{
+			Message:    "update checks are disabled, set config.update_check_enabled to enable them",
+			Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+		}
+	}
+	advisory, err := r.updateChecker.Check(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for updates: %w", err)
+	}
+	return toGQLUpdateAdvisory(advisory), nil
+}
+
+func toGQLUpdateAdvisory(advisory *updatecheck.Advisory) *gql.UpdateAdvisory {
+	result := &gql.UpdateAdvisory{
+		CurrentVersion:  advisory.CurrentVersion,
+		LatestVersion:   advisory.LatestVersion,
+		UpdateAvailable: advisory.UpdateAvailable,
+		Notes:           advisory.Notes,
+		CheckedAt:       advisory.CheckedAt.Format(time.RFC3339),
+	}
+	if result.Notes == nil {
+		result.Notes = []string{}
+	}
+	switch advisory.Severity {
+	case updatecheck.SeveritySecurity:
+		result.Severity = gql.UpdateSeveritySecurity
+	case updatecheck.SeverityFeature:
+		result.Severity = gql.UpdateSeverityFeature
+	default:
+		result.Severity = gql.UpdateSeverityNone
+	}
+	if advisory.ReleaseURL != "" {
+		releaseURL := advisory.ReleaseURL
+		result.ReleaseURL = &releaseURL
+	}
+	if !advisory.PublishedAt.IsZero() {
+		publishedAt := advisory.PublishedAt.Format(time.RFC3339)
+		result.PublishedAt = &publishedAt
+	}
+	return result
+}
@@ -0,0 +1,113 @@
+package resolver
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/jobqueue"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/internal/updatecheck"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func TestServerInfo_AdvisoryOnlyForAdmins(t *testing.T) {
+	mockRegistryStore := new(MockRegistryStore)
+	logger, _ := zap.NewDevelopment()
+
+	advisory := updatecheck.Advisory{
+		CurrentVersion:  "v1.0.0",
+		LatestVersion:   "v1.1.0",
+		UpdateAvailable: true,
+		Severity:        updatecheck.SeveritySecurity,
+		ReleaseURL:      "https://example.com/v1.1.0",
+		Notes:           []string{"Fix path traversal"},
+		CheckedAt:       time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	value, err := json.Marshal(advisory)
+	require.NoError(t, err)
+
+	mockRegistryStore.On("GetMulti", mock.Anything, registrystore.SystemOwnerID, []string{updatecheck.EnabledKey}).
+		Return([]*registrystore.Registry{{Key: updatecheck.EnabledKey, Value: "true"}}, nil)
+	mockRegistryStore.On("Get", mock.Anything, registrystore.SystemOwnerID, updatecheck.LatestKey).
+		Return(&registrystore.Registry{Key: updatecheck.LatestKey, Value: string(value)}, nil)
+
+	checker := updatecheck.New(logger, mockRegistryStore, nil, "v1.0.0")
+	resolver := newTestResolver(nil, mockRegistryStore, nil, nil, nil, nil, logger, WithUpdateChecker(checker))
+
+	info, err := resolver.Query().ServerInfo(createReadOnlyContext("user-1"))
+	require.NoError(t, err)
+	assert.Equal(t, "v1.0.0", info.Version)
+	assert.False(t, info.UpdateCheckEnabled)
+	assert.Nil(t, info.Update)
+
+	info, err = resolver.Query().ServerInfo(createAdminContext("admin-1"))
+	require.NoError(t, err)
+	assert.True(t, info.UpdateCheckEnabled)
+	require.NotNil(t, info.Update)
+	assert.Equal(t, "v1.1.0", info.Update.LatestVersion)
+	assert.Equal(t, gql.UpdateSeveritySecurity, info.Update.Severity)
+	assert.Equal(t, []string{"Fix path traversal"}, info.Update.Notes)
+	assert.Equal(t, "2026-01-01T00:00:00Z", info.Update.CheckedAt)
+}
+
+func TestCheckForUpdates_Disabled(t *testing.T) {
+	mockRegistryStore := new(MockRegistryStore)
+	logger, _ := zap.NewDevelopment()
+
+	mockRegistryStore.On("GetMulti", mock.Anything, registrystore.SystemOwnerID, []string{updatecheck.EnabledKey}).
+		Return([]*registrystore.Registry{}, nil)
+
+	checker := updatecheck.New(logger, mockRegistryStore, nil, "v1.0.0")
+	resolver := newTestResolver(nil, mockRegistryStore, nil, nil, nil, nil, logger, WithUpdateChecker(checker))
+
+	_, err := resolver.Mutation().CheckForUpdates(createReadOnlyContext("user-1"))
+	assert.Error(t, err)
+
+	_, err = resolver.Mutation().CheckForUpdates(createAdminContext("admin-1"))
+	require.Error(t, err)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_AVAILABLE", gqlErr.Extensions["code"])
+}
+
+func TestServerInfo_WithoutChecker(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	resolver := newTestResolver(nil, nil, nil, nil, nil, nil, logger)
+
+	info, err := resolver.Query().ServerInfo(createAdminContext("admin-1"))
+	require.NoError(t, err)
+	assert.NotEmpty(t, info.Version)
+	assert.False(t, info.UpdateCheckEnabled)
+	assert.Nil(t, info.Update)
+
+	_, err = resolver.Query().ServerInfo(context.Background())
+	assert.Error(t, err)
+}
+
+func TestProcessingQueue(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	resolver := newTestResolver(nil, nil, nil, nil, nil, nil, logger)
+	_, err := resolver.Query().ProcessingQueue(createAdminContext("admin-1"))
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_AVAILABLE", gqlErr.Extensions["code"])
+
+	resolver = newTestResolver(nil, nil, nil, nil, nil, nil, logger, WithProcessingScheduler(jobqueue.New(4)))
+	_, err = resolver.Query().ProcessingQueue(createReadWriteContext("user-1"))
+	assert.Error(t, err)
+
+	status, err := resolver.Query().ProcessingQueue(createAdminContext("admin-1"))
+	require.NoError(t, err)
+	assert.Equal(t, 4, status.Slots)
+	assert.Equal(t, 1, status.ReservedSlots)
+	require.Len(t, status.Classes, 3)
+	assert.Equal(t, "interactive", status.Classes[0].Class)
+	assert.Equal(t, "background", status.Classes[2].Class)
+}
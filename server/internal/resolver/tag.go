@@ -0,0 +1,333 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/tagstore"
+	"github.com/cshum/imagor-studio/server/pkg/space"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+// tagScope returns the tag namespace of a space, falling back to the tenant
+// or global scope for the default storage
+func (r *Resolver) tagScope(ctx context.Context, spaceID *string) (string, error) {
+	if r.tagStore == nil {
+		return "", taggingNotAvailableError()
+	}
+	spaceConfig, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err != nil {
+		return "", err
+	}
+	return tenantScope(ctx, spaceConfig), nil
+}
+
+func taggingNotAvailableError() error {
+	return &gqlerror.Error{
+		Message:    "tagging is not available",
+		Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+	}
+}
+
+func tagError(err error) error {
+	switch {
+	case errors.Is(err, tagstore.ErrNotFound):
+		return &gqlerror.Error{
+			Message:    err.Error(),
+			Extensions: map[string]interface{}{"code": "NOT_FOUND"},
+		}
+	case errors.Is(err, tagstore.ErrConflict), errors.Is(err, tagstore.ErrInvalid):
+		return &gqlerror.Error{
+			Message:    err.Error(),
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	return fmt.Errorf("tag operation failed: %w", err)
+}
+
+func toGQLTag(tag *tagstore.Tag) *gql.Tag {
+	aliases := tag.Aliases
+	if aliases == nil {
+		aliases = []string{}
+	}
+	return &gql.Tag{
+		ID:        tag.ID,
+		Name:      tag.Name,
+		Path:      tag.Path,
+		ParentID:  tag.ParentID,
+		Aliases:   aliases,
+		FileCount: tag.FileCount,
+	}
+}
+
+func toGQLTags(tags []*tagstore.Tag) []*gql.Tag {
+	result := make([]*gql.Tag, 0, len(tags))
+	for _, tag := range tags {
+		result = append(result, toGQLTag(tag))
+	}
+	return result
+}
+
+// Tags is the resolver for the tags field.
+func (r *queryResolver) Tags(ctx context.Context, spaceID *string) ([]*gql.Tag, error) {
+	if err := RequirePermission(ctx, "read"); err != nil {
+		return nil, err
+	}
+	scope, err := r.tagScope(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	// File counts only cover the files the request may read
+	tags, err := r.tagStore.List(ctx, scope, accessRoot(ctx))
+	if err != nil {
+		return nil, tagError(err)
+	}
+	return toGQLTags(tags), nil
+}
+
+// FileTags is the resolver for the fileTags field.
+func (r *queryResolver) FileTags(ctx context.Context, path string, spaceID *string) ([]*gql.Tag, error) {
+	path, err := ScopePath(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if err := RequireReadPermission(ctx, path); err != nil {
+		return nil, err
+	}
+	scope, err := r.tagScope(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := r.tagStore.FileTags(ctx, scope, path)
+	if err != nil {
+		return nil, tagError(err)
+	}
+	return toGQLTags(tags), nil
+}
+
+// FilesByTag is the resolver for the filesByTag field.
+func (r *queryResolver) FilesByTag(ctx context.Context, tag string, includeDescendants *bool, spaceID *string) ([]string, error) {
+	if err := RequirePermission(ctx, "read"); err != nil {
+		return nil, err
+	}
+	scope, err := r.tagScope(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	descendants := includeDescendants == nil || *includeDescendants
+	files, err := r.tagStore.FilesWithTag(ctx, scope, tag, descendants, accessRoot(ctx))
+	if err != nil {
+		return nil, tagError(err)
+	}
+	accessible := make([]string, 0, len(files))
+	for _, file := range files {
+		if ValidatePathAccess(ctx, file) == nil {
+			accessible = append(accessible, file)
+		}
+	}
+	return accessible, nil
+}
+
+// CreateTag is the resolver for the createTag field.
+func (r *mutationResolver) CreateTag(ctx context.Context, path string, spaceID *string) (*gql.Tag, error) {
+	if err := RequirePermission(ctx, "write"); err != nil {
+		return nil, err
+	}
+	scope, err := r.tagScope(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	tag, err := r.tagStore.Create(ctx, scope, path)
+	if err != nil {
+		return nil, tagError(err)
+	}
+	return toGQLTag(tag), nil
+}
+
+// RenameTag is the resolver for the renameTag field.
+func (r *mutationResolver) RenameTag(ctx context.Context, id string, path string, spaceID *string) (*gql.Tag, error) {
+	if err := RequirePermission(ctx, "write"); err != nil {
+		return nil, err
+	}
+	scope, err := r.tagScope(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	tag, err := r.tagStore.Rename(ctx, scope, id, path)
+	if err != nil {
+		return nil, tagError(err)
+	}
+	return toGQLTag(tag), nil
+}
+
+// MergeTags is the resolver for the mergeTags field.
+func (r *mutationResolver) MergeTags(ctx context.Context, sourceID string, targetID string, spaceID *string) (*gql.Tag, error) {
+	if err := RequirePermission(ctx, "write"); err != nil {
+		return nil, err
+	}
+	scope, err := r.tagScope(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	tag, err := r.tagStore.Merge(ctx, scope, sourceID, targetID)
+	if err != nil {
+		return nil, tagError(err)
+	}
+	return toGQLTag(tag), nil
+}
+
+// DeleteTag is the resolver for the deleteTag field.
+func (r *mutationResolver) DeleteTag(ctx context.Context, id string, spaceID *string) (bool, error) {
+	if err := RequirePermission(ctx, "write"); err != nil {
+		return false, err
+	}
+	scope, err := r.tagScope(ctx, spaceID)
+	if err != nil {
+		return false, err
+	}
+	if err := r.tagStore.Delete(ctx, scope, id); err != nil {
+		return false, tagError(err)
+	}
+	return true, nil
+}
+
+// AddTagAlias is the resolver for the addTagAlias field.
+func (r *mutationResolver) AddTagAlias(ctx context.Context, id string, alias string, spaceID *string) (*gql.Tag, error) {
+	if err := RequirePermission(ctx, "write"); err != nil {
+		return nil, err
+	}
+	scope, err := r.tagScope(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	tag, err := r.tagStore.AddAlias(ctx, scope, id, alias)
+	if err != nil {
+		return nil, tagError(err)
+	}
+	return toGQLTag(tag), nil
+}
+
+// RemoveTagAlias is the resolver for the removeTagAlias field.
+func (r *mutationResolver) RemoveTagAlias(ctx context.Context, id string, alias string, spaceID *string) (*gql.Tag, error) {
+	if err := RequirePermission(ctx, "write"); err != nil {
+		return nil, err
+	}
+	scope, err := r.tagScope(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	tag, err := r.tagStore.RemoveAlias(ctx, scope, id, alias)
+	if err != nil {
+		return nil, tagError(err)
+	}
+	return toGQLTag(tag), nil
+}
+
+// SetFileTags is the resolver for the setFileTags field.
+func (r *mutationResolver) SetFileTags(ctx context.Context, path string, tags []string, spaceID *string) ([]*gql.Tag, error) {
+	path, err := ScopePath(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if err := RequireWritePermission(ctx, path); err != nil {
+		return nil, err
+	}
+	scope, err := r.tagScope(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	result, err := r.tagStore.SetFileTags(ctx, scope, path, tags)
+	if err != nil {
+		return nil, tagError(err)
+	}
+	return toGQLTags(result), nil
+}
+
+// TagFile is the resolver for the tagFile field.
+func (r *mutationResolver) TagFile(ctx context.Context, path string, tags []string, spaceID *string) ([]*gql.Tag, error) {
+	path, err := ScopePath(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if err := RequireWritePermission(ctx, path); err != nil {
+		return nil, err
+	}
+	scope, err := r.tagScope(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	result, err := r.tagStore.TagFile(ctx, scope, path, tags)
+	if err != nil {
+		return nil, tagError(err)
+	}
+	return toGQLTags(result), nil
+}
+
+// UntagFile is the resolver for the untagFile field.
+func (r *mutationResolver) UntagFile(ctx context.Context, path string, tags []string, spaceID *string) ([]*gql.Tag, error) {
+	path, err := ScopePath(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if err := RequireWritePermission(ctx, path); err != nil {
+		return nil, err
+	}
+	scope, err := r.tagScope(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	result, err := r.tagStore.UntagFile(ctx, scope, path, tags)
+	if err != nil {
+		return nil, tagError(err)
+	}
+	return toGQLTags(result), nil
+}
+
+// filesWithTags returns the set of files tagged with any of tags or their
+// descendants, for filtering listings and searches
+func (r *Resolver) filesWithTags(ctx context.Context, spaceConfig *space.Space, tags []string) (map[string]bool, error) {
+	if r.tagStore == nil {
+		return nil, taggingNotAvailableError()
+	}
+	paths, err := r.tagStore.FilesWithTags(ctx, fileMetadataScope(spaceConfig), tags)
+	if err != nil {
+		return nil, tagError(err)
+	}
+	tagged := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		tagged[p] = true
+	}
+	return tagged, nil
+}
+
+// moveFileTags keeps tag associations following a moved file or folder.
+// Failures are logged rather than failing the move that already happened.
+func (r *Resolver) moveFileTags(ctx context.Context, spaceID *string, sourcePath, destPath string) {
+	if r.tagStore == nil {
+		return
+	}
+	scope, err := r.tagScope(ctx, spaceID)
+	if err == nil {
+		err = r.tagStore.MoveFilePath(ctx, scope, sourcePath, destPath)
+	}
+	if err != nil {
+		r.logger.Warn("Failed to move file tags", zap.String("source", sourcePath), zap.String("dest", destPath), zap.Error(err))
+	}
+}
+
+// removeFileTags drops tag associations of a deleted file or folder
+func (r *Resolver) removeFileTags(ctx context.Context, spaceID *string, path string) {
+	if r.tagStore == nil {
+		return
+	}
+	scope, err := r.tagScope(ctx, spaceID)
+	if err == nil {
+		err = r.tagStore.RemoveFilePath(ctx, scope, path)
+	}
+	if err != nil {
+		r.logger.Warn("Failed to remove file tags", zap.String("path", path), zap.Error(err))
+	}
+}
@@ -0,0 +1,225 @@
+package resolver
+
+import (
+	"testing"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/internal/tagstore"
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func newTagTestResolver(t *testing.T) (*Resolver, string) {
+	t.Helper()
+	db := testutil.NewDB(t)
+
+	baseDir := t.TempDir()
+	writeTestFile(t, baseDir, "album/a.jpg")
+	writeTestFile(t, baseDir, "album/b.jpg")
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+	mockImagorProvider := new(MockImagorProvider)
+	mockImagorProvider.On("Imagor").Return((*imagor.Imagor)(nil))
+	mockImagorProvider.On("GenerateURL", mock.Anything, mock.Anything).Return("/imagor/thumbnail.webp", nil)
+	mockRegistryStore := new(MockRegistryStore)
+	mockRegistryStore.On("GetMulti", mock.Anything, "system:global", mock.Anything).Return([]*registrystore.Registry{}, nil)
+	logger := zap.NewNop()
+	return newTestResolver(NewMockStorageProvider(stor), mockRegistryStore, new(MockUserStore), mockImagorProvider, &config.Config{}, nil, logger,
+		WithTagStore(tagstore.New(db, logger))), baseDir
+}
+
+func TestTags_NotAvailableWithoutStore(t *testing.T) {
+	logger := zap.NewNop()
+	resolver := newTestResolver(nil, new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, logger)
+
+	_, err := resolver.Query().Tags(createReadOnlyContext("user-1"), nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_AVAILABLE", gqlErr.Extensions["code"])
+}
+
+func TestTags_HierarchyAndQueries(t *testing.T) {
+	resolver, _ := newTagTestResolver(t)
+	ctx := createReadWriteContext("user-1")
+
+	_, err := resolver.Mutation().SetFileTags(ctx, "album/a.jpg", []string{"Animals/Dogs/Beagle"}, nil)
+	require.NoError(t, err)
+	tags, err := resolver.Mutation().SetFileTags(ctx, "album/b.jpg", []string{"Animals"}, nil)
+	require.NoError(t, err)
+	require.Len(t, tags, 1)
+	assert.Equal(t, 1, tags[0].FileCount)
+	assert.Empty(t, tags[0].Aliases)
+
+	all, err := resolver.Query().Tags(createReadOnlyContext("user-2"), nil)
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+	assert.Equal(t, "Animals/Dogs/Beagle", all[2].Path)
+	assert.Equal(t, all[1].ID, *all[2].ParentID)
+
+	files, err := resolver.Query().FilesByTag(ctx, "animals", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"album/a.jpg", "album/b.jpg"}, files)
+
+	files, err = resolver.Query().FilesByTag(ctx, "animals", boolPtr(false), nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"album/b.jpg"}, files)
+}
+
+func TestTags_HomePath(t *testing.T) {
+	resolver, baseDir := newTagTestResolver(t)
+	writeTestFile(t, baseDir, "teams/alice/c.jpg")
+	ctx := createReadWriteContext("user-1")
+	_, err := resolver.Mutation().SetFileTags(ctx, "album/a.jpg", []string{"Animals"}, nil)
+	require.NoError(t, err)
+
+	// Users with a home path tag with root relative paths, and only see
+	// the tagged files below their home path
+	home := WithHomePath(createReadWriteContext("alice"), "teams/alice")
+	_, err = resolver.Mutation().TagFile(home, "c.jpg", []string{"Animals"}, nil)
+	require.NoError(t, err)
+	tags, err := resolver.Query().FileTags(home, "/c.jpg", nil)
+	require.NoError(t, err)
+	require.Len(t, tags, 1)
+
+	files, err := resolver.Query().FilesByTag(home, "Animals", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"teams/alice/c.jpg"}, files)
+	all, err := resolver.Query().Tags(home, nil)
+	require.NoError(t, err)
+	require.Len(t, all, 1)
+	assert.Equal(t, 1, all[0].FileCount)
+
+	files, err = resolver.Query().FilesByTag(ctx, "Animals", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"album/a.jpg", "teams/alice/c.jpg"}, files)
+}
+
+func TestTags_RenameMergeAndAliases(t *testing.T) {
+	resolver, _ := newTagTestResolver(t)
+	ctx := createReadWriteContext("user-1")
+
+	_, err := resolver.Mutation().SetFileTags(ctx, "album/a.jpg", []string{"Doggos"}, nil)
+	require.NoError(t, err)
+	dogs, err := resolver.Mutation().CreateTag(ctx, "Animals/Dogs", nil)
+	require.NoError(t, err)
+	doggos, err := resolver.Mutation().AddTagAlias(ctx, dogs.ID, "Canines", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Canines"}, doggos.Aliases)
+
+	all, err := resolver.Query().Tags(ctx, nil)
+	require.NoError(t, err)
+	var sourceID string
+	for _, tag := range all {
+		if tag.Path == "Doggos" {
+			sourceID = tag.ID
+		}
+	}
+	require.NotEmpty(t, sourceID)
+
+	merged, err := resolver.Mutation().MergeTags(ctx, sourceID, dogs.ID, nil)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"Canines", "Doggos"}, merged.Aliases)
+	assert.Equal(t, 1, merged.FileCount)
+
+	renamed, err := resolver.Mutation().RenameTag(ctx, dogs.ID, "Pets/Dogs", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Pets/Dogs", renamed.Path)
+
+	fileTags, err := resolver.Query().FileTags(ctx, "album/a.jpg", nil)
+	require.NoError(t, err)
+	require.Len(t, fileTags, 1)
+	assert.Equal(t, "Pets/Dogs", fileTags[0].Path)
+
+	_, err = resolver.Mutation().RenameTag(ctx, "missing", "Other", nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_FOUND", gqlErr.Extensions["code"])
+
+	_, err = resolver.Mutation().AddTagAlias(ctx, dogs.ID, "pets", nil)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+
+	ok, err := resolver.Mutation().DeleteTag(ctx, dogs.ID, nil)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	fileTags, err = resolver.Query().FileTags(ctx, "album/a.jpg", nil)
+	require.NoError(t, err)
+	assert.Empty(t, fileTags)
+}
+
+func TestTags_MutationsRequireWrite(t *testing.T) {
+	resolver, _ := newTagTestResolver(t)
+
+	_, err := resolver.Mutation().CreateTag(createReadOnlyContext("user-1"), "Animals", nil)
+	assert.Error(t, err)
+	_, err = resolver.Mutation().SetFileTags(createReadOnlyContext("user-1"), "album/a.jpg", []string{"Animals"}, nil)
+	assert.Error(t, err)
+}
+
+func TestTags_FollowMovedAndDeletedFiles(t *testing.T) {
+	resolver, _ := newTagTestResolver(t)
+	ctx := createReadWriteContext("user-1")
+
+	_, err := resolver.Mutation().SetFileTags(ctx, "album/a.jpg", []string{"Travel"}, nil)
+	require.NoError(t, err)
+	_, err = resolver.Mutation().SetFileTags(ctx, "album/b.jpg", []string{"Travel"}, nil)
+	require.NoError(t, err)
+
+	_, err = resolver.Mutation().MoveFile(ctx, "album", "trips", nil)
+	require.NoError(t, err)
+	files, err := resolver.Query().FilesByTag(ctx, "Travel", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"trips/a.jpg", "trips/b.jpg"}, files)
+
+	_, err = resolver.Mutation().DeleteFile(ctx, "trips/a.jpg", nil)
+	require.NoError(t, err)
+	files, err = resolver.Query().FilesByTag(ctx, "Travel", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"trips/b.jpg"}, files)
+}
+
+func TestTags_TagUntagAndFilter(t *testing.T) {
+	resolver, baseDir := newTagTestResolver(t)
+	writeTestFile(t, baseDir, "album/c.jpg")
+	writeTestFile(t, baseDir, "album/sub/d.jpg")
+	ctx := createReadWriteContext("user-1")
+
+	tags, err := resolver.Mutation().TagFile(ctx, "album/a.jpg", []string{"Animals/Dogs"}, nil)
+	require.NoError(t, err)
+	require.Len(t, tags, 1)
+	tags, err = resolver.Mutation().TagFile(ctx, "album/a.jpg", []string{"Travel"}, nil)
+	require.NoError(t, err)
+	assert.Len(t, tags, 2)
+	_, err = resolver.Mutation().TagFile(ctx, "album/b.jpg", []string{"Travel"}, nil)
+	require.NoError(t, err)
+
+	result, err := resolver.Query().ListFiles(ctx, "album", nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, []string{"Animals"}, nil, nil, nil)
+	require.NoError(t, err)
+	names := make([]string, len(result.Items))
+	for i, item := range result.Items {
+		names[i] = item.Name
+	}
+	assert.ElementsMatch(t, []string{"a.jpg", "sub"}, names, "folders are kept")
+
+	search, err := resolver.Query().SearchFiles(ctx, "", nil, nil, nil, nil, []string{"travel"})
+	require.NoError(t, err)
+	require.Len(t, search.Items, 2)
+	assert.Equal(t, "album/a.jpg", search.Items[0].Path)
+	assert.Equal(t, "album/b.jpg", search.Items[1].Path)
+
+	tags, err = resolver.Mutation().UntagFile(ctx, "album/a.jpg", []string{"Travel", "Unknown"}, nil)
+	require.NoError(t, err)
+	require.Len(t, tags, 1)
+	assert.Equal(t, "Animals/Dogs", tags[0].Path)
+	search, err = resolver.Query().SearchFiles(ctx, "jpg", nil, nil, nil, nil, []string{"Travel"})
+	require.NoError(t, err)
+	require.Len(t, search.Items, 1)
+	assert.Equal(t, "album/b.jpg", search.Items[0].Path)
+}
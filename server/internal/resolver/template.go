@@ -9,8 +9,10 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/cshum/imagor-studio/server/internal/events"
 	"github.com/cshum/imagor-studio/server/internal/generated/gql"
 	"github.com/cshum/imagor-studio/server/internal/imagortemplate"
+	"github.com/cshum/imagor-studio/server/internal/jobqueue"
 	"github.com/cshum/imagor-studio/server/pkg/apperror"
 	"github.com/cshum/imagor-studio/server/pkg/processing"
 	"github.com/cshum/imagor-studio/server/pkg/space"
@@ -98,6 +100,8 @@ func (r *mutationResolver) SaveTemplate(ctx context.Context, input gql.SaveTempl
 		}, nil
 	}
 
+	r.publishFileChange(ctx, spaceID, events.FileCreated, templateFilePath, "")
+
 	r.logger.Info("Template saved successfully",
 		zap.String("templatePath", templateFilePath),
 		zap.String("name", input.Name))
@@ -157,6 +161,8 @@ func derivePreviewParamsFromTemplateJSON(templateJSON string) imagorpath.Params
 func (r *mutationResolver) generateTemplatePreview(ctx context.Context, sourceImagePath, templateJSON string, params imagorpath.Params, spaceConfig *space.Space, spaceKey *string) ([]byte, error) {
 	if r.templatePreviewRenderer != nil {
 		r.logger.Debug("Generating preview using configured template preview renderer")
+		// Previews follow saves, keep them behind thumbnails a user is waiting on
+		ctx = jobqueue.WithClass(ctx, jobqueue.RecentUpload)
 
 		resolvedSpaceKey := ""
 		if spaceKey != nil {
@@ -187,6 +193,10 @@ func (r *mutationResolver) generateTemplatePreview(ctx context.Context, sourceIm
 // via imagor, and writes the result back as a .imagor.preview file.
 // Returns true on success, false if the template JSON cannot be read or preview generation fails.
 func (r *mutationResolver) RegenerateTemplatePreview(ctx context.Context, templatePath string, spaceID *string) (bool, error) {
+	templatePath, err := ScopePath(ctx, templatePath)
+	if err != nil {
+		return false, err
+	}
 	if err := RequireWritePermission(ctx, templatePath); err != nil {
 		return false, err
 	}
@@ -266,6 +276,8 @@ func (r *mutationResolver) RegenerateTemplatePreview(ctx context.Context, templa
 		return false, nil
 	}
 
+	r.publishSpaceFileChange(spaceConfig, events.FileCreated, previewPath, "")
+
 	r.logger.Info("Template preview regenerated successfully",
 		zap.String("templatePath", templatePath),
 		zap.String("previewPath", previewPath))
@@ -0,0 +1,238 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/internal/tenantstore"
+	"github.com/cshum/imagor-studio/server/internal/userstore"
+	"github.com/cshum/imagor-studio/server/pkg/space"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+// tenantRegistryKeys are the system settings tenant admins may override for
+// their tenant. Other settings apply to the whole server.
+var tenantRegistryKeys = map[string]bool{
+	"config.app_title":                    true,
+	"config.app_home_title":               true,
+	"config.app_default_language":         true,
+	"config.app_default_sort_by":          true,
+	"config.app_default_sort_order":       true,
+	"config.app_show_file_names":          true,
+	"config.app_video_thumbnail_position": true,
+}
+
+// isTenantMember reports whether user is a member of the request's tenant,
+// always true outside of tenants
+func isTenantMember(ctx context.Context, user *userstore.User) bool {
+	tenantID := GetTenantIDFromContext(ctx)
+	return tenantID == "" || (user.TenantID != nil && *user.TenantID == tenantID)
+}
+
+// effectiveTargetUserID is GetEffectiveTargetUserID, also requiring other
+// users to be members of the request's tenant
+func (r *Resolver) effectiveTargetUserID(ctx context.Context, providedUserID *string) (string, error) {
+	targetUserID, err := GetEffectiveTargetUserID(ctx, providedUserID)
+	if err != nil {
+		return "", err
+	}
+	if currentUserID, _ := GetUserIDFromContext(ctx); targetUserID == currentUserID || GetTenantIDFromContext(ctx) == "" {
+		return targetUserID, nil
+	}
+	user, err := r.userStore.GetByIDAdmin(ctx, targetUserID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get target user: %w", err)
+	}
+	if user == nil || !isTenantMember(ctx, user) {
+		return "", &gqlerror.Error{
+			Message:    "user not found",
+			Extensions: map[string]interface{}{"code": "NOT_FOUND"},
+		}
+	}
+	return targetUserID, nil
+}
+
+// tenantScope returns the namespace of the tags and albums of a space. Unlike
+// path keyed data, which the tenant's storage root already isolates, they
+// are namespaced by tenant.
+func tenantScope(ctx context.Context, spaceConfig *space.Space) string {
+	if tenantID := GetTenantIDFromContext(ctx); tenantID != "" && spaceConfig == nil {
+		return registrystore.TenantOwnerID(tenantID)
+	}
+	return fileMetadataScope(spaceConfig)
+}
+
+// overlayTenantRegistry replaces system registry entries with those of the
+// tenant for the same keys, adding tenant entries the system does not have
+func overlayTenantRegistry(system, tenant []*registrystore.Registry) []*registrystore.Registry {
+	overrides := make(map[string]*registrystore.Registry, len(tenant))
+	for _, entry := range tenant {
+		if tenantRegistryKeys[entry.Key] {
+			overrides[entry.Key] = entry
+		}
+	}
+	if len(overrides) == 0 {
+		return system
+	}
+	result := make([]*registrystore.Registry, 0, len(system)+len(overrides))
+	for _, entry := range system {
+		if override, ok := overrides[entry.Key]; ok {
+			entry = override
+			delete(overrides, entry.Key)
+		}
+		result = append(result, entry)
+	}
+	for _, entry := range tenant {
+		if overrides[entry.Key] != nil {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+func tenantsNotAvailableError() error {
+	return &gqlerror.Error{
+		Message:    "tenants are not enabled on this server",
+		Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+	}
+}
+
+func tenantError(err error) error {
+	switch {
+	case errors.Is(err, tenantstore.ErrNotFound):
+		return &gqlerror.Error{
+			Message:    err.Error(),
+			Extensions: map[string]interface{}{"code": "NOT_FOUND"},
+		}
+	case errors.Is(err, tenantstore.ErrInvalid), errors.Is(err, tenantstore.ErrConflict), errors.Is(err, tenantstore.ErrNotEmpty):
+		return &gqlerror.Error{
+			Message:    err.Error(),
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	return fmt.Errorf("tenant operation failed: %w", err)
+}
+
+func toGQLTenant(tenant *tenantstore.Tenant) *gql.Tenant {
+	return &gql.Tenant{
+		ID:          tenant.ID,
+		Name:        tenant.Name,
+		StorageRoot: tenant.StorageRoot,
+		UserCount:   tenant.UserCount,
+		CreatedAt:   tenant.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:   tenant.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+// Tenants is the resolver for the tenants field.
+func (r *queryResolver) Tenants(ctx context.Context) ([]*gql.Tenant, error) {
+	if err := RequireAdminPermission(ctx); err != nil {
+		return nil, err
+	}
+	if r.tenantStore == nil {
+		return nil, tenantsNotAvailableError()
+	}
+	tenants, err := r.tenantStore.List(ctx)
+	if err != nil {
+		return nil, tenantError(err)
+	}
+	result := make([]*gql.Tenant, 0, len(tenants))
+	for _, tenant := range tenants {
+		result = append(result, toGQLTenant(tenant))
+	}
+	return result, nil
+}
+
+// CreateTenant is the resolver for the createTenant field.
+func (r *mutationResolver) CreateTenant(ctx context.Context, name string, storageRoot string) (*gql.Tenant, error) {
+	if err := RequireAdminPermission(ctx); err != nil {
+		return nil, err
+	}
+	if r.tenantStore == nil {
+		return nil, tenantsNotAvailableError()
+	}
+	tenant, err := r.tenantStore.Create(ctx, name, storageRoot)
+	if err != nil {
+		return nil, tenantError(err)
+	}
+	currentUserID, _ := GetUserIDFromContext(ctx)
+	r.logger.Info("Tenant created",
+		zap.String("tenantID", tenant.ID),
+		zap.String("storageRoot", tenant.StorageRoot),
+		zap.String("createdByUserID", currentUserID))
+	return toGQLTenant(tenant), nil
+}
+
+// DeleteTenant is the resolver for the deleteTenant field.
+func (r *mutationResolver) DeleteTenant(ctx context.Context, id string) (bool, error) {
+	if err := RequireAdminPermission(ctx); err != nil {
+		return false, err
+	}
+	if r.tenantStore == nil {
+		return false, tenantsNotAvailableError()
+	}
+	if err := r.tenantStore.Delete(ctx, id); err != nil {
+		return false, tenantError(err)
+	}
+	currentUserID, _ := GetUserIDFromContext(ctx)
+	r.logger.Info("Tenant deleted",
+		zap.String("tenantID", id),
+		zap.String("deletedByUserID", currentUserID))
+	return true, nil
+}
+
+// SetUserTenant is the resolver for the setUserTenant field.
+func (r *mutationResolver) SetUserTenant(ctx context.Context, userID string, tenantID *string) (*gql.User, error) {
+	if err := RequireAdminPermission(ctx); err != nil {
+		return nil, err
+	}
+	if r.tenantStore == nil {
+		return nil, tenantsNotAvailableError()
+	}
+	if tenantID != nil {
+		if _, err := r.tenantStore.Get(ctx, *tenantID); err != nil {
+			return nil, tenantError(err)
+		}
+	}
+
+	targetUser, err := r.userStore.GetByIDAdmin(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get target user: %w", err)
+	}
+	if targetUser == nil {
+		return nil, fmt.Errorf("target user not found")
+	}
+	if err := r.userStore.UpdateTenant(ctx, userID, tenantID); err != nil {
+		return nil, fmt.Errorf("failed to update tenant: %w", err)
+	}
+	targetUser.TenantID = tenantID
+
+	currentUserID, _ := GetUserIDFromContext(ctx)
+	r.logger.Info("User tenant updated",
+		zap.String("targetUserID", userID),
+		zap.Stringp("tenantID", tenantID),
+		zap.String("updatedByUserID", currentUserID))
+
+	return &gql.User{
+		ID:            targetUser.ID,
+		DisplayName:   targetUser.DisplayName,
+		Username:      targetUser.Username,
+		Role:          targetUser.Role,
+		IsActive:      targetUser.IsActive,
+		CreatedAt:     targetUser.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:     targetUser.UpdatedAt.Format(time.RFC3339),
+		Email:         targetUser.Email,
+		PendingEmail:  targetUser.PendingEmail,
+		EmailVerified: targetUser.EmailVerified,
+		HasPassword:   targetUser.HasPassword,
+		AvatarURL:     targetUser.AvatarUrl,
+		HomePath:      targetUser.HomePath,
+		TenantID:      targetUser.TenantID,
+		AuthProviders: toGQLAuthProviders(r.userStore, r.logger, ctx, targetUser.ID),
+	}, nil
+}
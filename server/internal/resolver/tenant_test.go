@@ -0,0 +1,194 @@
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/internal/tenantstore"
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/cshum/imagor-studio/server/internal/userstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func createTenantAdminContext(userID, tenantID string) context.Context {
+	return WithTenantID(createAdminContext(userID), tenantID)
+}
+
+func TestRequireAdminPermission_TenantAdmin(t *testing.T) {
+	ctx := createTenantAdminContext("admin-1", "acme")
+	assert.Error(t, RequireAdminPermission(ctx))
+	assert.NoError(t, RequireTenantAdminPermission(ctx))
+	assert.NoError(t, RequireAdminPermission(createAdminContext("admin-1")))
+}
+
+func TestUsers_Tenant(t *testing.T) {
+	mockUserStore := new(MockUserStore)
+	resolver := newTestResolver(nil, new(MockRegistryStore), mockUserStore, nil, &MockConfig{}, nil, zap.NewNop())
+	ctx := createTenantAdminContext("admin-1", "acme")
+
+	tenantID := "acme"
+	mockUserStore.On("ListByTenant", ctx, "acme", 0, 0, "").Return([]*userstore.User{
+		{ID: "user-1", DisplayName: "User", Username: "user", Role: "user", IsActive: true, TenantID: &tenantID},
+	}, 1, nil)
+	mockUserStore.On("ListAuthProviders", ctx, "user-1").Return([]*userstore.AuthProvider{}, nil)
+
+	result, err := resolver.Query().Users(ctx, nil, nil, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.TotalCount)
+	require.Len(t, result.Items, 1)
+	assert.Equal(t, &tenantID, result.Items[0].TenantID)
+	mockUserStore.AssertNotCalled(t, "List", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCreateUser_Tenant(t *testing.T) {
+	mockUserStore := new(MockUserStore)
+	resolver := newTestResolver(nil, new(MockRegistryStore), mockUserStore, nil, &MockConfig{}, nil, zap.NewNop())
+	ctx := createTenantAdminContext("admin-1", "acme")
+
+	tenantID := "acme"
+	mockUserStore.On("CreateInTenant", ctx, "acme", "New User", "newuser", mock.AnythingOfType("string"), "user").
+		Return(&userstore.User{ID: "user-2", DisplayName: "New User", Username: "newuser", Role: "user", IsActive: true, TenantID: &tenantID}, nil)
+
+	result, err := resolver.Mutation().CreateUser(ctx, gql.CreateUserInput{
+		DisplayName: "New User",
+		Username:    "newuser",
+		Password:    "password123",
+		Role:        "user",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &tenantID, result.TenantID)
+	mockUserStore.AssertExpectations(t)
+}
+
+func TestUser_OtherTenant(t *testing.T) {
+	mockUserStore := new(MockUserStore)
+	resolver := newTestResolver(nil, new(MockRegistryStore), mockUserStore, nil, &MockConfig{}, nil, zap.NewNop())
+	ctx := createTenantAdminContext("admin-1", "acme")
+
+	otherTenantID := "globex"
+	otherUser := &userstore.User{ID: "user-3", Username: "other", Role: "user", TenantID: &otherTenantID}
+	globalUser := &userstore.User{ID: "user-4", Username: "global", Role: "admin"}
+	mockUserStore.On("GetByID", ctx, "user-3").Return(otherUser, nil)
+	mockUserStore.On("GetByID", ctx, "user-4").Return(globalUser, nil)
+	mockUserStore.On("GetByIDAdmin", ctx, "user-3").Return(otherUser, nil)
+
+	for _, id := range []string{"user-3", "user-4"} {
+		result, err := resolver.Query().User(ctx, id)
+		assert.Error(t, err, id)
+		assert.Nil(t, result, id)
+	}
+
+	// Profile settings of users outside the tenant are hidden too
+	userID := "user-3"
+	_, err := resolver.Query().ListUserRegistry(ctx, nil, &userID, nil, nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_FOUND", gqlErr.Extensions["code"])
+}
+
+func TestSetSystemRegistry_Tenant(t *testing.T) {
+	mockRegistryStore := new(MockRegistryStore)
+	resolver := newTestResolver(nil, mockRegistryStore, new(MockUserStore), nil, &MockConfig{}, nil, zap.NewNop())
+	ctx := createTenantAdminContext("admin-1", "acme")
+
+	entries := []*registrystore.Registry{{Key: "config.app_title", Value: "Acme"}}
+	mockRegistryStore.On("SetMulti", ctx, "tenant:acme", entries).Return(entries, nil)
+
+	result, err := resolver.Mutation().SetSystemRegistry(ctx, nil, []*gql.RegistryEntryInput{{Key: "config.app_title", Value: "Acme"}})
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.Equal(t, "Acme", result[0].Value)
+
+	_, err = resolver.Mutation().SetSystemRegistry(ctx, nil, []*gql.RegistryEntryInput{{Key: "config.allow_guest_mode", Value: "true"}})
+	assert.ErrorContains(t, err, "only server admins can change this setting")
+	mockRegistryStore.AssertNumberOfCalls(t, "SetMulti", 1)
+}
+
+func TestListSystemRegistry_Tenant(t *testing.T) {
+	mockRegistryStore := new(MockRegistryStore)
+	resolver := newTestResolver(nil, mockRegistryStore, new(MockUserStore), nil, &MockConfig{}, nil, zap.NewNop())
+	ctx := WithTenantID(createReadWriteContext("user-1"), "acme")
+
+	mockRegistryStore.On("List", ctx, registrystore.SystemOwnerID, (*string)(nil)).Return([]*registrystore.Registry{
+		{Key: "config.app_title", Value: "Studio"},
+		{Key: "config.allow_guest_mode", Value: "false"},
+	}, nil)
+	mockRegistryStore.On("List", ctx, "tenant:acme", (*string)(nil)).Return([]*registrystore.Registry{
+		{Key: "config.app_title", Value: "Acme"},
+		{Key: "config.allow_guest_mode", Value: "true"},
+	}, nil)
+
+	result, err := resolver.Query().ListSystemRegistry(ctx, nil, nil, nil)
+	require.NoError(t, err)
+	values := make(map[string]string)
+	for _, entry := range result {
+		values[entry.Key] = entry.Value
+	}
+	assert.Equal(t, map[string]string{
+		"config.app_title":        "Acme",
+		"config.allow_guest_mode": "false",
+	}, values)
+}
+
+func TestTenants(t *testing.T) {
+	db := testutil.NewDB(t)
+
+	logger := zap.NewNop()
+	userStore := userstore.New(db, logger)
+	resolver := newTestResolver(nil, new(MockRegistryStore), userStore, nil, &MockConfig{}, nil, logger,
+		WithTenantStore(tenantstore.New(db, logger)))
+	ctx := createAdminContext("admin-1")
+
+	_, err := resolver.Query().Tenants(createTenantAdminContext("admin-1", "acme"))
+	assert.Error(t, err, "tenant admins cannot manage tenants")
+
+	tenant, err := resolver.Mutation().CreateTenant(ctx, "Acme", "/acme/")
+	require.NoError(t, err)
+	assert.Equal(t, "acme", tenant.StorageRoot)
+
+	_, err = resolver.Mutation().CreateTenant(ctx, "Acme Photos", "acme/photos")
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+
+	user, err := userStore.Create(ctx, "Member", "member", "hash", "user")
+	require.NoError(t, err)
+	updated, err := resolver.Mutation().SetUserTenant(ctx, user.ID, &tenant.ID)
+	require.NoError(t, err)
+	assert.Equal(t, &tenant.ID, updated.TenantID)
+
+	tenants, err := resolver.Query().Tenants(ctx)
+	require.NoError(t, err)
+	require.Len(t, tenants, 1)
+	assert.Equal(t, 1, tenants[0].UserCount)
+
+	_, err = resolver.Mutation().DeleteTenant(ctx, tenant.ID)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+
+	_, err = resolver.Mutation().SetUserTenant(ctx, user.ID, nil)
+	require.NoError(t, err)
+	deleted, err := resolver.Mutation().DeleteTenant(ctx, tenant.ID)
+	require.NoError(t, err)
+	assert.True(t, deleted)
+
+	missing := "missing"
+	_, err = resolver.Mutation().SetUserTenant(ctx, user.ID, &missing)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_FOUND", gqlErr.Extensions["code"])
+}
+
+func TestTenants_NotAvailable(t *testing.T) {
+	resolver := newTestResolver(nil, new(MockRegistryStore), new(MockUserStore), nil, &MockConfig{}, nil, zap.NewNop())
+
+	_, err := resolver.Query().Tenants(createAdminContext("admin-1"))
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_AVAILABLE", gqlErr.Extensions["code"])
+}
@@ -0,0 +1,74 @@
+package resolver
+
+import (
+	"context"
+
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/thumbnailpreset"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+// ThumbnailPresets is the resolver for the thumbnailPresets field.
+func (r *queryResolver) ThumbnailPresets(ctx context.Context) ([]*gql.ThumbnailPreset, error) {
+	return toGQLThumbnailPresets(r.thumbnailPresets.Presets()), nil
+}
+
+// SetThumbnailPresets is the resolver for the setThumbnailPresets field.
+func (r *mutationResolver) SetThumbnailPresets(ctx context.Context, presets []*gql.ThumbnailPresetInput) ([]*gql.ThumbnailPreset, error) {
+	if err := RequireAdminPermission(ctx); err != nil {
+		return nil, err
+	}
+	if r.thumbnailPresets == nil {
+		return nil, &gqlerror.Error{
+			Message:    "thumbnail presets are not available on this server",
+			Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+		}
+	}
+	input := make([]thumbnailpreset.Preset, len(presets))
+	for i, p := range presets {
+		input[i] = thumbnailpreset.Preset{Name: p.Name}
+		if p.Width != nil {
+			input[i].Width = *p.Width
+		}
+		if p.Height != nil {
+			input[i].Height = *p.Height
+		}
+		if p.Fit != nil {
+			input[i].Fit = *p.Fit
+		}
+		if p.Format != nil {
+			input[i].Format = *p.Format
+		}
+		if p.Quality != nil {
+			input[i].Quality = *p.Quality
+		}
+	}
+	if _, err := thumbnailpreset.Validate(input); err != nil {
+		return nil, &gqlerror.Error{
+			Message:    err.Error(),
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	effective, err := r.thumbnailPresets.Set(ctx, input)
+	if err != nil {
+		r.logger.Error("Failed to set thumbnail presets", zap.Error(err))
+		return nil, err
+	}
+	return toGQLThumbnailPresets(effective), nil
+}
+
+func toGQLThumbnailPresets(presets []thumbnailpreset.Preset) []*gql.ThumbnailPreset {
+	result := make([]*gql.ThumbnailPreset, len(presets))
+	for i, p := range presets {
+		result[i] = &gql.ThumbnailPreset{
+			Name:    p.Name,
+			Width:   p.Width,
+			Height:  p.Height,
+			Fit:     p.Fit,
+			Format:  p.Format,
+			Quality: p.Quality,
+		}
+	}
+	return result
+}
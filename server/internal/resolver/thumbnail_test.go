@@ -0,0 +1,87 @@
+package resolver
+
+import (
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/internal/thumbnailpreset"
+	"github.com/cshum/imagor/imagorpath"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func TestThumbnailPresets(t *testing.T) {
+	mockRegistryStore := new(MockRegistryStore)
+	mockRegistryStore.On("GetMulti", mock.Anything, registrystore.SystemOwnerID, []string{thumbnailpreset.RegistryKey}).Return([]*registrystore.Registry{}, nil)
+	mockRegistryStore.On("Set", mock.Anything, registrystore.SystemOwnerID, thumbnailpreset.RegistryKey, mock.Anything, false).
+		Return(&registrystore.Registry{Key: thumbnailpreset.RegistryKey}, nil)
+	store := thumbnailpreset.New(mockRegistryStore, zap.NewNop())
+	require.NoError(t, store.Sync())
+
+	mockImagorProvider := new(MockImagorProvider)
+	mockImagorProvider.On("GenerateURL", "photos/a.jpg", imagorpath.Params{
+		Width:   400,
+		Height:  400,
+		Smart:   true,
+		Filters: imagorpath.Filters{{Name: "quality", Args: "70"}, {Name: "format", Args: "avif"}},
+	}).Return("/imagor/grid/photos/a.jpg", nil)
+	mockImagorProvider.On("GenerateURL", "photos/a.jpg", imagorpath.Params{
+		Width:   512,
+		Height:  512,
+		Filters: imagorpath.Filters{{Name: "quality", Args: "80"}, {Name: "format", Args: "webp"}},
+	}).Return("/imagor/square/photos/a.jpg", nil)
+	mockImagorProvider.On("GenerateURL", mock.Anything, mock.Anything).Return("/imagor/other", nil)
+	resolver := newTestResolver(nil, mockRegistryStore, new(MockUserStore), mockImagorProvider, &config.Config{}, nil, zap.NewNop(),
+		WithThumbnailPresets(store))
+
+	width, height, quality := 400, 400, 70
+	fit, format := "smart", "avif"
+	input := []*gql.ThumbnailPresetInput{
+		{Name: "grid", Width: &width, Height: &height, Fit: &fit, Format: &format, Quality: &quality},
+		{Name: "square", Width: intPtr(512), Height: intPtr(512)},
+	}
+
+	// Only admins set presets
+	_, err := resolver.Mutation().SetThumbnailPresets(createReadWriteContext("user-1"), input)
+	assert.Error(t, err)
+	presets, err := resolver.Mutation().SetThumbnailPresets(createAdminContext("admin-1"), input)
+	require.NoError(t, err)
+	require.Len(t, presets, 4)
+	assert.Equal(t, "avif", presets[0].Format)
+	assert.Equal(t, "square", presets[3].Name)
+
+	listed, err := resolver.Query().ThumbnailPresets(createReadOnlyContext("user-1"))
+	require.NoError(t, err)
+	assert.Equal(t, presets, listed)
+
+	urls := resolver.generateThumbnailUrls("photos/a.jpg", "first_frame")
+	require.NotNil(t, urls)
+	assert.Equal(t, "/imagor/grid/photos/a.jpg", *urls.Grid)
+	require.Len(t, urls.Presets, 4)
+	assert.Equal(t, &gql.ThumbnailPresetURL{Name: "square", URL: "/imagor/square/photos/a.jpg"}, urls.Presets[3])
+
+	_, err = resolver.Mutation().SetThumbnailPresets(createAdminContext("admin-1"), []*gql.ThumbnailPresetInput{{Name: "Bad Name", Width: intPtr(10)}})
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+}
+
+func TestThumbnailPresets_Defaults(t *testing.T) {
+	resolver := newTestResolver(nil, new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop())
+
+	presets, err := resolver.Query().ThumbnailPresets(createReadOnlyContext("user-1"))
+	require.NoError(t, err)
+	require.Len(t, presets, 3)
+	assert.Equal(t, "grid", presets[0].Name)
+	assert.Equal(t, 300, presets[0].Width)
+
+	_, err = resolver.Mutation().SetThumbnailPresets(createAdminContext("admin-1"), nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_AVAILABLE", gqlErr.Extensions["code"])
+}
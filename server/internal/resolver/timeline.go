@@ -0,0 +1,112 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/cshum/imagor-studio/server/internal/filemeta"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultTimelineLimit is the page of timeline items returned by default
+	defaultTimelineLimit = 100
+	// maxTimelineLimit bounds the page of timeline items
+	maxTimelineLimit = 1000
+)
+
+var timelineGranularities = map[gql.TimelineGranularity]filemeta.Granularity{
+	gql.TimelineGranularityDay:   filemeta.GranularityDay,
+	gql.TimelineGranularityMonth: filemeta.GranularityMonth,
+	gql.TimelineGranularityYear:  filemeta.GranularityYear,
+}
+
+// Timeline is the resolver for the timeline field.
+func (r *queryResolver) Timeline(ctx context.Context, folder *string, granularity gql.TimelineGranularity, offset *int, limit *int, spaceID *string) (*gql.Timeline, error) {
+	root := ""
+	if folder != nil {
+		root = strings.Trim(*folder, "/")
+	}
+	root, err := ScopePath(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+	if err := RequireReadPermission(ctx, root); err != nil {
+		return nil, err
+	}
+	if r.fileMetaStore == nil {
+		return nil, &gqlerror.Error{
+			Message:    "the timeline is not available",
+			Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+		}
+	}
+	offsetValue := 0
+	if offset != nil {
+		if *offset < 0 {
+			return nil, &gqlerror.Error{
+				Message:    "offset must not be negative",
+				Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+			}
+		}
+		offsetValue = *offset
+	}
+	limitValue := defaultTimelineLimit
+	if limit != nil {
+		if *limit < 0 || *limit > maxTimelineLimit {
+			return nil, &gqlerror.Error{
+				Message:    fmt.Sprintf("limit must be between 0 and %d", maxTimelineLimit),
+				Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+			}
+		}
+		limitValue = *limit
+	}
+
+	spaceConfig, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	scope := fileMetadataScope(spaceConfig)
+	buckets, err := r.fileMetaStore.Timeline(ctx, scope, root, timelineGranularities[granularity])
+	if err != nil {
+		r.logger.Error("Failed to get timeline", zap.String("path", root), zap.Error(err))
+		return nil, fmt.Errorf("failed to get timeline: %w", err)
+	}
+	result := &gql.Timeline{
+		Buckets: make([]*gql.TimelineBucket, len(buckets)),
+		Items:   []*gql.TimelineItem{},
+	}
+	for i, bucket := range buckets {
+		result.Buckets[i] = &gql.TimelineBucket{Key: bucket.Key, Count: bucket.Count, Offset: result.TotalCount}
+		result.TotalCount += bucket.Count
+	}
+	if limitValue == 0 || offsetValue >= result.TotalCount {
+		return result, nil
+	}
+
+	entries, err := r.fileMetaStore.TimelineEntries(ctx, scope, root, offsetValue, limitValue)
+	if err != nil {
+		r.logger.Error("Failed to list timeline", zap.String("path", root), zap.Error(err))
+		return nil, fmt.Errorf("failed to list timeline: %w", err)
+	}
+	videoThumbnailPos := r.getEffectiveVideoThumbnailPosition(ctx, spaceConfig)
+	var resolvedSpaceKey *string
+	if spaceConfig != nil {
+		resolvedSpaceKey = &spaceConfig.Key
+	}
+	for _, entry := range entries {
+		result.Items = append(result.Items, &gql.TimelineItem{
+			Name:          path.Base(entry.FilePath),
+			Path:          entry.FilePath,
+			CaptureTime:   entry.Metadata.CaptureTime,
+			Width:         optionalInt(entry.Metadata.Width),
+			Height:        optionalInt(entry.Metadata.Height),
+			Orientation:   optionalInt(entry.Metadata.Orientation),
+			ThumbnailUrls: r.generateThumbnailUrlsForResolvedSpace(ctx, entry.FilePath, videoThumbnailPos, resolvedSpaceKey, spaceConfig),
+		})
+	}
+	return result, nil
+}
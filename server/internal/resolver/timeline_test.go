@@ -0,0 +1,88 @@
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cshum/imagor"
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/filemeta"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func newTimelineTestResolver(t *testing.T) (*Resolver, filemeta.Store) {
+	t.Helper()
+	db := testutil.NewDB(t)
+
+	mockImagorProvider := new(MockImagorProvider)
+	mockImagorProvider.On("Imagor").Return((*imagor.Imagor)(nil))
+	mockImagorProvider.On("GenerateURL", mock.Anything, mock.Anything).Return("/imagor/thumbnail.webp", nil)
+	mockRegistryStore := new(MockRegistryStore)
+	mockRegistryStore.On("GetMulti", mock.Anything, "system:global", mock.Anything).Return([]*registrystore.Registry{}, nil)
+	store := filemeta.NewStore(db, zap.NewNop())
+	return newTestResolver(nil, mockRegistryStore, new(MockUserStore), mockImagorProvider, &config.Config{}, nil, zap.NewNop(),
+		WithFileMetaStore(store)), store
+}
+
+func TestTimeline(t *testing.T) {
+	resolver, store := newTimelineTestResolver(t)
+	ctx := context.Background()
+	for p, captureTime := range map[string]string{
+		"trips/a.jpg": "2024-05-01T08:00:00",
+		"trips/b.jpg": "2024-05-01T20:00:00",
+		"trips/c.jpg": "2024-06-15T12:00:00+02:00",
+		"trips/d.jpg": "",
+		"other/e.jpg": "2023-01-01T00:00:00",
+	} {
+		require.NoError(t, store.Put(ctx, registrystore.SystemOwnerID, p, "v1", &filemeta.Metadata{CaptureTime: captureTime, Width: 400, Height: 300}))
+	}
+	readCtx := createReadOnlyContext("user-1")
+
+	timeline, err := resolver.Query().Timeline(readCtx, stringPtr("/trips/"), gql.TimelineGranularityDay, nil, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 3, timeline.TotalCount)
+	require.Len(t, timeline.Buckets, 2)
+	assert.Equal(t, gql.TimelineBucket{Key: "2024-06-15", Count: 1, Offset: 0}, *timeline.Buckets[0])
+	assert.Equal(t, gql.TimelineBucket{Key: "2024-05-01", Count: 2, Offset: 1}, *timeline.Buckets[1])
+	require.Len(t, timeline.Items, 3)
+	assert.Equal(t, "trips/c.jpg", timeline.Items[0].Path)
+	assert.Equal(t, "c.jpg", timeline.Items[0].Name)
+	assert.Equal(t, "2024-06-15T12:00:00+02:00", timeline.Items[0].CaptureTime)
+	assert.Equal(t, 400, *timeline.Items[0].Width)
+	assert.NotNil(t, timeline.Items[0].ThumbnailUrls)
+
+	// Paging through the second bucket
+	timeline, err = resolver.Query().Timeline(readCtx, nil, gql.TimelineGranularityYear, intPtr(2), intPtr(1), nil)
+	require.NoError(t, err)
+	assert.Equal(t, 4, timeline.TotalCount)
+	assert.Len(t, timeline.Buckets, 2)
+	require.Len(t, timeline.Items, 1)
+	assert.Equal(t, "trips/a.jpg", timeline.Items[0].Path)
+
+	timeline, err = resolver.Query().Timeline(readCtx, nil, gql.TimelineGranularityMonth, nil, intPtr(0), nil)
+	require.NoError(t, err)
+	assert.Len(t, timeline.Buckets, 3)
+	assert.Empty(t, timeline.Items)
+
+	var gqlErr *gqlerror.Error
+	_, err = resolver.Query().Timeline(readCtx, nil, gql.TimelineGranularityMonth, nil, intPtr(maxTimelineLimit+1), nil)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+	_, err = resolver.Query().Timeline(context.Background(), nil, gql.TimelineGranularityMonth, nil, nil, nil)
+	assert.Error(t, err)
+}
+
+func TestTimeline_NotAvailable(t *testing.T) {
+	resolver := newTestResolver(nil, new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop())
+	_, err := resolver.Query().Timeline(createReadOnlyContext("user-1"), nil, gql.TimelineGranularityDay, nil, nil, nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_AVAILABLE", gqlErr.Extensions["code"])
+}
@@ -0,0 +1,99 @@
+package resolver
+
+import (
+	"context"
+	"strings"
+
+	"github.com/cshum/imagor-studio/server/internal/mediaclass"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/internal/registryutil"
+	"github.com/cshum/imagor-studio/server/internal/uploadroute"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+// UploadDestination is the resolver for the uploadDestination field.
+func (r *queryResolver) UploadDestination(ctx context.Context, filename string, contentType *string, spaceID *string) (string, error) {
+	if err := RequirePermission(ctx, "write"); err != nil {
+		return "", err
+	}
+	if strings.Trim(filename, "/") == "" || uploadroute.IsExplicit(filename) {
+		return "", &gqlerror.Error{
+			Message:    "filename must not contain a folder",
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	var ct string
+	if contentType != nil {
+		ct = *contentType
+	}
+	return r.routeUploadPath(ctx, filename, ct)
+}
+
+// routeUploadPath places an upload without an explicit destination folder
+// according to the user's routing settings, then scopes the path to the home
+// path of the request with ScopePath. Explicit paths are only scoped. Paths
+// are routed as given by the client, as scoping makes every path of users
+// with a home path explicit.
+func (r *Resolver) routeUploadPath(ctx context.Context, path, contentType string) (string, error) {
+	// Guest uploads stay in their upload folder. Callers without write access
+	// are rejected by the write check that follows, so settings are not read.
+	if !uploadroute.IsExplicit(path) && guestWritePrefix(ctx) == "" && RequirePermission(ctx, "write") == nil {
+		settings := r.getUploadRouteSettings(ctx)
+		var classifier *mediaclass.Classifier
+		for _, rule := range settings.Rules {
+			if len(rule.SystemTags) > 0 {
+				classifier = r.getMediaClassifier(ctx)
+				break
+			}
+		}
+		path = settings.Route(path, contentType, classifier)
+	}
+	return ScopePath(ctx, path)
+}
+
+// getUploadRouteSettings resolves the upload routing settings, preferring the
+// user registry and falling back to the system registry set by admins.
+// Invalid values are logged and ignored.
+func (r *Resolver) getUploadRouteSettings(ctx context.Context) uploadroute.Settings {
+	keys := []string{uploadroute.DefaultFolderKey, uploadroute.RulesKey}
+	values := make(map[string]string, len(keys))
+
+	if userID, err := GetUserIDFromContext(ctx); err == nil && r.registryStore != nil {
+		entries, err := r.registryStore.GetMulti(ctx, registrystore.UserOwnerID(userID), keys)
+		if err != nil {
+			r.logger.Warn("Failed to read upload routing settings", zap.String("userID", userID), zap.Error(err))
+		}
+		for _, entry := range entries {
+			if entry != nil && strings.TrimSpace(entry.Value) != "" {
+				values[entry.Key] = entry.Value
+			}
+		}
+	}
+	var fallbackKeys []string
+	for _, key := range keys {
+		if _, ok := values[key]; !ok {
+			fallbackKeys = append(fallbackKeys, key)
+		}
+	}
+	if len(fallbackKeys) > 0 {
+		for _, result := range registryutil.GetEffectiveValues(ctx, r.registryStore, r.config, fallbackKeys...) {
+			if result.Exists {
+				values[result.Key] = result.Value
+			}
+		}
+	}
+
+	var settings uploadroute.Settings
+	folder, err := uploadroute.NormalizeFolder(values[uploadroute.DefaultFolderKey])
+	if err != nil {
+		r.logger.Warn("Invalid default upload folder, ignoring", zap.Error(err))
+	}
+	settings.DefaultFolder = folder
+	rules, err := uploadroute.ParseRules(values[uploadroute.RulesKey])
+	if err != nil {
+		r.logger.Warn("Invalid upload routing rules, ignoring", zap.Error(err))
+	}
+	settings.Rules = rules
+	return settings
+}
@@ -0,0 +1,120 @@
+package resolver
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/internal/uploadroute"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+var uploadRouteKeys = []string{uploadroute.DefaultFolderKey, uploadroute.RulesKey}
+
+// expectNoUploadRoutes stubs the upload routing lookups with no settings
+func expectNoUploadRoutes(m *MockRegistryStore) {
+	m.On("GetMulti", mock.Anything, mock.Anything, uploadRouteKeys).Return([]*registrystore.Registry{}, nil).Maybe()
+}
+
+func TestUploadFile_RoutesBareFilename(t *testing.T) {
+	mockStorage := new(MockStorage)
+	mockRegistryStore := new(MockRegistryStore)
+	logger, _ := zap.NewDevelopment()
+	resolver := newTestResolver(NewMockStorageProvider(mockStorage), mockRegistryStore, new(MockUserStore), nil, &config.Config{}, nil, logger)
+
+	// User rules take precedence; the default folder falls back to the admin default
+	mockRegistryStore.On("GetMulti", mock.Anything, "user:user-1", uploadRouteKeys).Return([]*registrystore.Registry{
+		{Key: uploadroute.RulesKey, Value: `[{"folder": "Videos", "contentTypes": ["video/*"]}]`},
+	}, nil)
+	mockRegistryStore.On("GetMulti", mock.Anything, registrystore.SystemOwnerID, []string{uploadroute.DefaultFolderKey}).Return([]*registrystore.Registry{
+		{Key: uploadroute.DefaultFolderKey, Value: "Inbox"},
+	}, nil)
+
+	ctx := createReadWriteContext("user-1")
+	mockStorage.On("Put", ctx, "Videos/clip.mp4", mock.Anything).Return(nil).Once()
+	mockStorage.On("Put", ctx, "Inbox/photo.jpg", mock.Anything).Return(nil).Once()
+	mockStorage.On("Put", ctx, "album/clip.mp4", mock.Anything).Return(nil).Once()
+
+	ok, err := resolver.Mutation().UploadFile(ctx, "clip.mp4", nil, graphql.Upload{File: strings.NewReader("x"), Filename: "clip.mp4", ContentType: "video/mp4"}, nil)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = resolver.Mutation().UploadFile(ctx, "photo.jpg", nil, graphql.Upload{File: strings.NewReader("x"), Filename: "photo.jpg"}, nil)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	// Explicit destinations are never routed
+	ok, err = resolver.Mutation().UploadFile(ctx, "album/clip.mp4", nil, graphql.Upload{File: strings.NewReader("x"), Filename: "clip.mp4", ContentType: "video/mp4"}, nil)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	dest, err := resolver.Query().UploadDestination(ctx, "clip.mov", nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Videos/clip.mov", dest)
+
+	mockStorage.AssertExpectations(t)
+}
+
+func TestUploadFile_RoutesScreenshotsBySystemTag(t *testing.T) {
+	mockStorage := new(MockStorage)
+	mockRegistryStore := new(MockRegistryStore)
+	logger, _ := zap.NewDevelopment()
+	resolver := newTestResolver(NewMockStorageProvider(mockStorage), mockRegistryStore, new(MockUserStore), nil, &config.Config{}, nil, logger)
+
+	mockRegistryStore.On("GetMulti", mock.Anything, "user:user-1", uploadRouteKeys).Return([]*registrystore.Registry{
+		{Key: uploadroute.RulesKey, Value: `[{"folder": "Screenshots", "systemTags": ["screenshot"]}]`},
+	}, nil)
+	mockRegistryStore.On("GetMulti", mock.Anything, registrystore.SystemOwnerID, mock.Anything).Return([]*registrystore.Registry{}, nil)
+
+	ctx := createReadWriteContext("user-1")
+	dest, err := resolver.Query().UploadDestination(ctx, "Screenshot 2024-01-01.png", stringPtr("image/png"), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Screenshots/Screenshot 2024-01-01.png", dest)
+
+	dest, err = resolver.Query().UploadDestination(ctx, "IMG_0001.png", stringPtr("image/png"), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "IMG_0001.png", dest)
+}
+
+func TestUploadDestination_RejectsFolders(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	resolver := newTestResolver(nil, new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, logger)
+
+	_, err := resolver.Query().UploadDestination(createReadWriteContext("user-1"), "album/a.jpg", nil, nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+
+	_, err = resolver.Query().UploadDestination(createReadOnlyContext("user-1"), "a.jpg", nil, nil)
+	assert.Error(t, err)
+}
+
+func TestUploadFile_RoutesBareFilenameInHomePath(t *testing.T) {
+	mockStorage := new(MockStorage)
+	mockRegistryStore := new(MockRegistryStore)
+	logger, _ := zap.NewDevelopment()
+	resolver := newTestResolver(NewMockStorageProvider(mockStorage), mockRegistryStore, new(MockUserStore), nil, &config.Config{}, nil, logger)
+
+	mockRegistryStore.On("GetMulti", mock.Anything, "user:user-1", uploadRouteKeys).Return([]*registrystore.Registry{
+		{Key: uploadroute.DefaultFolderKey, Value: "Inbox"},
+	}, nil)
+	mockRegistryStore.On("GetMulti", mock.Anything, registrystore.SystemOwnerID, mock.Anything).Return([]*registrystore.Registry{}, nil)
+
+	// Routing happens before the path is scoped to the home path
+	ctx := WithHomePath(createReadWriteContext("user-1"), "users/bob")
+	mockStorage.On("Put", ctx, "users/bob/Inbox/photo.jpg", mock.Anything).Return(nil).Once()
+	mockStorage.On("Put", ctx, "users/bob/album/photo.jpg", mock.Anything).Return(nil).Once()
+
+	ok, err := resolver.Mutation().UploadFile(ctx, "photo.jpg", nil, graphql.Upload{File: strings.NewReader("x"), Filename: "photo.jpg"}, nil)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = resolver.Mutation().UploadFile(ctx, "album/photo.jpg", nil, graphql.Upload{File: strings.NewReader("x"), Filename: "photo.jpg"}, nil)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	mockStorage.AssertExpectations(t)
+}
@@ -0,0 +1,128 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/notify"
+	"github.com/cshum/imagor-studio/server/internal/virusscan"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+// scanUpload scans content bound for path with the upload scanner, before
+// it is stored. It returns the content to store in its place, rewound, and
+// a func releasing it. Content that cannot seek is spooled to a temporary
+// file to be read twice. Infected uploads are quarantined, reported to
+// admins and fail with a malware_found error. Uploads fail closed: a
+// scanner that cannot be reached fails them.
+func (r *Resolver) scanUpload(ctx context.Context, path string, content io.Reader) (io.Reader, func(), error) {
+	if r.uploadScanner == nil {
+		return content, func() {}, nil
+	}
+	release := func() {}
+	seeker, ok := content.(io.ReadSeeker)
+	if !ok {
+		file, err := os.CreateTemp("", "imagor-studio-upload-*")
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to scan upload: %w", err)
+		}
+		release = func() {
+			_ = file.Close()
+			_ = os.Remove(file.Name())
+		}
+		if _, err := io.Copy(file, content); err != nil {
+			release()
+			return nil, nil, fmt.Errorf("failed to read upload: %w", err)
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			release()
+			return nil, nil, fmt.Errorf("failed to rewind upload: %w", err)
+		}
+		seeker = file
+	}
+	offset, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		release()
+		return nil, nil, fmt.Errorf("failed to scan upload: %w", err)
+	}
+	rewind := func() error {
+		if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+			return fmt.Errorf("failed to rewind upload: %w", err)
+		}
+		return nil
+	}
+
+	result, err := r.uploadScanner.Scan(ctx, seeker)
+	if err != nil {
+		release()
+		r.logger.Error("Failed to scan upload", zap.String("path", path), zap.Error(err))
+		return nil, nil, fmt.Errorf("failed to scan upload: %w", err)
+	}
+	if err := rewind(); err != nil {
+		release()
+		return nil, nil, err
+	}
+	if result.Infected {
+		defer release()
+		r.reportInfectedUpload(ctx, path, seeker, result.Signature)
+		return nil, nil, malwareFoundError(result.Signature)
+	}
+	return seeker, release, nil
+}
+
+// reportInfectedUpload keeps an infected upload in quarantine when
+// configured and notifies admins of the finding
+func (r *Resolver) reportInfectedUpload(ctx context.Context, path string, content io.Reader, signature string) {
+	userID, _ := GetUserIDFromContext(ctx)
+	r.logger.Warn("Malware found in upload", zap.String("path", path), zap.String("userID", userID), zap.String("signature", signature))
+
+	kept := "The upload was discarded."
+	if r.uploadQuarantine != nil {
+		name, err := r.uploadQuarantine.Keep(content, virusscan.Finding{
+			Path:      path,
+			UserID:    userID,
+			Signature: signature,
+			FoundAt:   time.Now().UTC(),
+		})
+		if err != nil {
+			r.logger.Error("Failed to quarantine upload", zap.String("path", path), zap.Error(err))
+			kept = "The upload could not be quarantined and was discarded."
+		} else {
+			kept = fmt.Sprintf("The upload was quarantined as %s.", name)
+		}
+	}
+	uploader := "an anonymous user"
+	if userID != "" {
+		uploader = "user " + userID
+	}
+	r.notifier.Notify(notify.Message{
+		Event: notify.EventUploadInfected,
+		Title: "Malware found in upload",
+		Body:  fmt.Sprintf("%s was found in an upload to /%s by %s. %s", signature, strings.TrimPrefix(path, "/"), uploader, kept),
+	})
+}
+
+func malwareFoundError(signature string) error {
+	return &gqlerror.Error{
+		Message: fmt.Sprintf("failed to upload file: malware found (%s)", signature),
+		Extensions: map[string]interface{}{
+			"code":      "BAD_USER_INPUT",
+			"reason":    "malware_found",
+			"signature": signature,
+		},
+	}
+}
+
+// scannedDirectUploadError fails direct uploads, which reach the storage
+// without passing the upload scanner
+func scannedDirectUploadError() error {
+	return &gqlerror.Error{
+		Message:    "direct uploads are not available while uploads are scanned for malware, use chunked uploads",
+		Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+	}
+}
@@ -0,0 +1,123 @@
+package resolver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/virusscan"
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+// fakeUploadScanner finds "malware" in content containing it
+type fakeUploadScanner struct {
+	err error
+}
+
+func (s fakeUploadScanner) Scan(_ context.Context, content io.Reader) (virusscan.Result, error) {
+	if s.err != nil {
+		return virusscan.Result{}, s.err
+	}
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return virusscan.Result{}, err
+	}
+	if strings.Contains(string(data), "malware") {
+		return virusscan.Result{Infected: true, Signature: "Test-Signature"}, nil
+	}
+	return virusscan.Result{}, nil
+}
+
+func TestUploadFile_Scanned(t *testing.T) {
+	baseDir := t.TempDir()
+	quarantineDir := t.TempDir()
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+	mockRegistryStore := new(MockRegistryStore)
+	expectNoUploadRoutes(mockRegistryStore)
+	resolver := newTestResolver(NewMockStorageProvider(stor), mockRegistryStore, new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop(),
+		WithUploadScanner(fakeUploadScanner{}, virusscan.NewQuarantine(quarantineDir)))
+	ctx := createReadWriteContext("user-1")
+
+	ok, err := resolver.Mutation().UploadFile(ctx, "photos/clean.jpg", nil, upload("clean"), nil)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	content, err := os.ReadFile(filepath.Join(baseDir, "photos/clean.jpg"))
+	require.NoError(t, err)
+	assert.Equal(t, "clean", string(content), "scanned content is stored rewound")
+
+	_, err = resolver.Mutation().UploadFile(ctx, "photos/infected.jpg", nil, upload("some malware"), nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "malware_found", gqlErr.Extensions["reason"])
+	assert.Equal(t, "Test-Signature", gqlErr.Extensions["signature"])
+	assert.NoFileExists(t, filepath.Join(baseDir, "photos/infected.jpg"))
+
+	// The infected upload is kept in quarantine with its finding
+	matches, err := filepath.Glob(filepath.Join(quarantineDir, "*-infected.jpg"))
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	content, err = os.ReadFile(matches[0])
+	require.NoError(t, err)
+	assert.Equal(t, "some malware", string(content))
+	data, err := os.ReadFile(matches[0] + ".json")
+	require.NoError(t, err)
+	var finding virusscan.Finding
+	require.NoError(t, json.Unmarshal(data, &finding))
+	assert.Equal(t, "photos/infected.jpg", finding.Path)
+	assert.Equal(t, "user-1", finding.UserID)
+	assert.Equal(t, "Test-Signature", finding.Signature)
+}
+
+func TestStoreUpload_ScansStreams(t *testing.T) {
+	baseDir := t.TempDir()
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+	resolver := newTestResolver(NewMockStorageProvider(stor), new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop(),
+		WithUploadScanner(fakeUploadScanner{}, nil))
+	ctx := createReadWriteContext("user-1")
+
+	// Content that cannot seek is spooled to be scanned, then stored whole
+	stream := io.MultiReader(strings.NewReader("part one, "), strings.NewReader("part two"))
+	_, err = resolver.storeUpload(ctx, stor, nil, "stream.txt", uploadCollisionOverwrite, stream, 0)
+	require.NoError(t, err)
+	content, err := os.ReadFile(filepath.Join(baseDir, "stream.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "part one, part two", string(content))
+
+	// Without a quarantine, infected uploads are discarded
+	stream = io.MultiReader(strings.NewReader("mal"), strings.NewReader("ware"))
+	_, err = resolver.storeUpload(ctx, stor, nil, "infected.txt", uploadCollisionOverwrite, stream, 0)
+	assert.ErrorContains(t, err, "malware found")
+	assert.NoFileExists(t, filepath.Join(baseDir, "infected.txt"))
+}
+
+func TestStoreUpload_ScannerUnavailable(t *testing.T) {
+	baseDir := t.TempDir()
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+	resolver := newTestResolver(NewMockStorageProvider(stor), new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop(),
+		WithUploadScanner(fakeUploadScanner{err: errors.New("connection refused")}, nil))
+	ctx := createReadWriteContext("user-1")
+
+	// Uploads fail closed
+	_, err = resolver.storeUpload(ctx, stor, nil, "a.txt", uploadCollisionOverwrite, strings.NewReader("clean"), 5)
+	assert.ErrorContains(t, err, "failed to scan upload")
+	assert.NoFileExists(t, filepath.Join(baseDir, "a.txt"))
+
+	// Direct uploads would bypass the scanner
+	_, _, _, err = resolver.presignPut(ctx, stor, nil, "a.txt", "text/plain", 5)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_AVAILABLE", gqlErr.Extensions["code"])
+}
@@ -0,0 +1,135 @@
+package resolver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/cshum/imagor-studio/server/internal/filemeta"
+	"github.com/cshum/imagor-studio/server/internal/mimetype"
+	"github.com/cshum/imagor-studio/server/pkg/space"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+// verifyUploadType checks the extension of path against the allowed upload
+// extensions and the content bound for it against its extension. It returns
+// the content to store in its place, its leading bytes read for detection
+// put back, and the canonical content type to record for it.
+func (r *Resolver) verifyUploadType(path string, content io.Reader) (io.Reader, string, error) {
+	if err := r.checkUploadExtension(path); err != nil {
+		return nil, "", err
+	}
+	head := make([]byte, mimetype.SniffLen)
+	var offset int64
+	seeker, seekable := content.(io.ReadSeeker)
+	if seekable {
+		var err error
+		if offset, err = seeker.Seek(0, io.SeekCurrent); err != nil {
+			return nil, "", fmt.Errorf("failed to read upload: %w", err)
+		}
+	}
+	n, err := io.ReadFull(content, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, "", fmt.Errorf("failed to read upload: %w", err)
+	}
+	head = head[:n]
+	if seekable {
+		if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+			return nil, "", fmt.Errorf("failed to rewind upload: %w", err)
+		}
+	} else {
+		content = io.MultiReader(bytes.NewReader(head), content)
+	}
+
+	contentType, err := mimetype.Verify(path, head)
+	if err != nil {
+		r.logger.Warn("Rejected upload of unexpected content", zap.String("path", path), zap.Error(err))
+		return nil, "", uploadTypeError(err)
+	}
+	return content, contentType, nil
+}
+
+// verifyStoredUploadType is verifyUploadType for content already written to
+// path by the client, as direct uploads are
+func (r *Resolver) verifyStoredUploadType(ctx context.Context, stor storage.Storage, path string) (string, error) {
+	reader, err := stor.Get(ctx, path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read upload: %w", err)
+	}
+	defer reader.Close()
+	_, contentType, err := r.verifyUploadType(path, reader)
+	return contentType, err
+}
+
+// checkUploadExtension fails uploads to path when upload extensions are
+// restricted and its extension is not one of them
+func (r *Resolver) checkUploadExtension(path string) error {
+	if len(r.uploadExtensions) == 0 {
+		return nil
+	}
+	ext := mimetype.Extension(path)
+	if slices.Contains(r.uploadExtensions, ext) {
+		return nil
+	}
+	message := fmt.Sprintf("failed to upload file: %s files are not allowed", ext)
+	if ext == "" {
+		message = "failed to upload file: files without an extension are not allowed"
+	}
+	return &gqlerror.Error{
+		Message: message,
+		Extensions: map[string]interface{}{
+			"code":              "BAD_USER_INPUT",
+			"reason":            "extension_not_allowed",
+			"allowedExtensions": r.uploadExtensions,
+		},
+	}
+}
+
+// recordUploadContentType records the content type detected for an upload
+// in the metadata index, for downloads to be served with. Failures are
+// logged rather than failing the upload already stored.
+func (r *Resolver) recordUploadContentType(ctx context.Context, stor storage.Storage, sp *space.Space, path, contentType string) {
+	if r.fileMetaStore == nil || contentType == "" {
+		return
+	}
+	info, err := stor.Stat(ctx, path)
+	if err == nil {
+		err = r.fileMetaStore.PutContentType(ctx, fileMetadataScope(sp), strings.Trim(path, "/"), filemeta.Fingerprint(info), contentType)
+	}
+	if err != nil {
+		r.logger.Warn("Failed to record upload content type", zap.String("path", path), zap.Error(err))
+	}
+}
+
+// storedContentType returns the content type recorded for a file when it
+// was uploaded, "" when none is recorded for its current version
+func (r *Resolver) storedContentType(ctx context.Context, sp *space.Space, path string, info storage.FileInfo) string {
+	if r.fileMetaStore == nil {
+		return ""
+	}
+	contentType, err := r.fileMetaStore.ContentType(ctx, fileMetadataScope(sp), strings.Trim(path, "/"), filemeta.Fingerprint(info))
+	if err != nil {
+		r.logger.Warn("Failed to read file content type", zap.String("path", path), zap.Error(err))
+	}
+	return contentType
+}
+
+func uploadTypeError(err error) error {
+	reason := "content_type_mismatch"
+	if errors.Is(err, mimetype.ErrExecutable) {
+		reason = "executable_content"
+	}
+	return &gqlerror.Error{
+		Message: fmt.Sprintf("failed to upload file: %v", err),
+		Extensions: map[string]interface{}{
+			"code":   "BAD_USER_INPUT",
+			"reason": reason,
+		},
+	}
+}
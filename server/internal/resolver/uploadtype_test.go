@@ -0,0 +1,101 @@
+package resolver
+
+import (
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func TestUploadFile_VerifiesContentType(t *testing.T) {
+	baseDir := t.TempDir()
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+	mockRegistryStore := new(MockRegistryStore)
+	expectNoUploadRoutes(mockRegistryStore)
+	resolver := newTestResolver(NewMockStorageProvider(stor), mockRegistryStore, new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop())
+	ctx := createReadWriteContext("user-1")
+
+	for _, tc := range []struct {
+		name    string
+		path    string
+		content string
+		reason  string
+	}{
+		{"executable named jpg", "photos/invoice.jpg", "MZ\x90\x00\x03\x00\x00\x00", "executable_content"},
+		{"script named txt", "notes/readme.txt", "#!/bin/sh\nrm -rf /\n", "executable_content"},
+		{"html named jpg", "photos/page.jpg", "<html><script>alert(1)</script></html>", "content_type_mismatch"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := resolver.Mutation().UploadFile(ctx, tc.path, nil, upload(tc.content), nil)
+			var gqlErr *gqlerror.Error
+			require.ErrorAs(t, err, &gqlErr)
+			assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+			assert.Equal(t, tc.reason, gqlErr.Extensions["reason"])
+			assert.NoFileExists(t, filepath.Join(baseDir, tc.path))
+		})
+	}
+}
+
+func TestUploadFile_AllowedExtensions(t *testing.T) {
+	baseDir := t.TempDir()
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+	mockRegistryStore := new(MockRegistryStore)
+	expectNoUploadRoutes(mockRegistryStore)
+	resolver := newTestResolver(NewMockStorageProvider(stor), mockRegistryStore, new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop(),
+		WithUploadExtensions([]string{".jpg", ".png"}))
+	ctx := createReadWriteContext("user-1")
+
+	ok, err := resolver.Mutation().UploadFile(ctx, "photos/a.JPG", nil, upload("\xff\xd8\xff\xe0\x00\x10JFIF\x00"), nil)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	for _, p := range []string{"docs/report.pdf", "bin/tool"} {
+		_, err = resolver.Mutation().UploadFile(ctx, p, nil, upload("data"), nil)
+		var gqlErr *gqlerror.Error
+		require.ErrorAs(t, err, &gqlErr, p)
+		assert.Equal(t, "extension_not_allowed", gqlErr.Extensions["reason"])
+		assert.Equal(t, []string{".jpg", ".png"}, gqlErr.Extensions["allowedExtensions"])
+		assert.NoFileExists(t, filepath.Join(baseDir, p))
+	}
+}
+
+func TestStoreUpload_RecordsContentType(t *testing.T) {
+	baseDir := t.TempDir()
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+	store := &memoryFileMetaStore{entries: map[string]memoryFileMetaEntry{}}
+	resolver := newTestResolver(NewMockStorageProvider(stor), new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop(),
+		WithFileMetaStore(store))
+	ctx := createReadWriteContext("user-1")
+
+	// A PNG named .jpg is stored whole and recorded as what it is
+	png := "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"
+	stream := io.MultiReader(strings.NewReader(png[:4]), strings.NewReader(png[4:]))
+	_, err = resolver.storeUpload(ctx, stor, nil, "photo.jpg", uploadCollisionOverwrite, stream, 0)
+	require.NoError(t, err)
+	content, err := stor.Get(ctx, "photo.jpg")
+	require.NoError(t, err)
+	data, err := io.ReadAll(content)
+	require.NoError(t, content.Close())
+	require.NoError(t, err)
+	assert.Equal(t, png, string(data))
+
+	info, err := stor.Stat(ctx, "photo.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", resolver.storedContentType(ctx, nil, "photo.jpg", info))
+
+	// Content types recorded for a replaced version are not served
+	require.NoError(t, stor.Put(ctx, "photo.jpg", strings.NewReader(png+"more")))
+	info, err = stor.Stat(ctx, "photo.jpg")
+	require.NoError(t, err)
+	assert.Empty(t, resolver.storedContentType(ctx, nil, "photo.jpg", info))
+}
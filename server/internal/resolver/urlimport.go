@@ -0,0 +1,116 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cshum/imagor-studio/server/internal/events"
+	"github.com/cshum/imagor-studio/server/internal/urlimport"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+// ImportFromURL is the resolver for the importFromUrl field.
+func (r *mutationResolver) ImportFromURL(ctx context.Context, url string, destinationPath *string, validate *bool, spaceID *string) (string, error) {
+	// Without a path this also turns guests away, downloads on their behalf
+	// are not allowed
+	if err := RequireWritePermission(ctx); err != nil {
+		return "", err
+	}
+	if r.urlImporter == nil {
+		return "", &gqlerror.Error{
+			Message:    "importing from URLs is not available",
+			Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+		}
+	}
+	destination := ""
+	if destinationPath != nil {
+		destination = strings.TrimLeft(strings.TrimSpace(*destinationPath), "/")
+	}
+	// Checked up front so nothing is downloaded for a path the user cannot
+	// write, and again once the file is named
+	scoped, err := ScopePath(ctx, strings.TrimSuffix(destination, "/"))
+	if err != nil {
+		return "", err
+	}
+	if err := RequireWritePermission(ctx, scoped); err != nil {
+		return "", err
+	}
+	stor, sp, err := r.resolveUploadStorageTarget(ctx, spaceID)
+	if err != nil {
+		return "", err
+	}
+	if err := ensureSpaceUploadAllowed(sp); err != nil {
+		return "", err
+	}
+
+	download, err := r.urlImporter.Fetch(ctx, url)
+	if err != nil {
+		r.logger.Debug("Failed to import from URL", zap.String("url", url), zap.Error(err))
+		return "", r.urlImportError(err)
+	}
+	defer download.Close()
+
+	path := destination
+	if path == "" || strings.HasSuffix(path, "/") {
+		path += download.FileName
+	}
+	path, err = normalizeNewPath(path)
+	if err != nil {
+		return "", err
+	}
+	path, err = r.routeUploadPath(ctx, path, download.ContentType)
+	if err != nil {
+		return "", err
+	}
+	if err := RequireWritePermission(ctx, path); err != nil {
+		return "", err
+	}
+	// Imports never replace a file, a failed validation would lose it
+	if taken, err := pathTaken(ctx, stor, path); err != nil {
+		return "", err
+	} else if taken {
+		return "", fileAlreadyExistsError("import file")
+	}
+	if err := r.enforceHostedStorageQuota(ctx, sp, download.Size); err != nil {
+		return "", err
+	}
+	r.logger.Debug("Importing file from URL", zap.String("path", path), zap.String("contentType", download.ContentType), zap.Int64("size", download.Size))
+	if _, err := r.storeUpload(ctx, stor, sp, path, uploadCollisionReject, download, download.Size); err != nil {
+		return "", err
+	}
+
+	if validate != nil && *validate && strings.HasPrefix(download.ContentType, "image/") {
+		if _, err := r.fetchImageMeta(ctx, path, sp); err != nil {
+			r.logger.Debug("Imported image failed validation", zap.String("path", path), zap.Error(err))
+			if err := r.deleteStoragePath(ctx, stor, sp, path); err != nil {
+				r.logger.Warn("Failed to delete invalid imported image", zap.String("path", path), zap.Error(err))
+			} else {
+				r.publishSpaceFileChange(sp, events.FileDeleted, path, "")
+			}
+			return "", &gqlerror.Error{
+				Message:    "imported file is not a readable image",
+				Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+			}
+		}
+	}
+	return path, nil
+}
+
+// urlImportError reports the downloads refused by the importer as bad input
+func (r *Resolver) urlImportError(err error) error {
+	message := err.Error()
+	switch {
+	case errors.Is(err, urlimport.ErrTooLarge):
+		message = fmt.Sprintf("%s of %d bytes", message, r.urlImporter.MaxBytes())
+	case errors.Is(err, urlimport.ErrInvalidURL), errors.Is(err, urlimport.ErrForbiddenAddress), errors.Is(err, urlimport.ErrUnsupportedType):
+	default:
+		return err
+	}
+	return &gqlerror.Error{
+		Message:    message,
+		Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+	}
+}
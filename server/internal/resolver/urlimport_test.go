@@ -0,0 +1,97 @@
+package resolver
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/urlimport"
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func newURLImportServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 4))))
+	photo := buf.Bytes()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/cat.png", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(photo)
+	})
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("<html><body>not an image</body></html>"))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestImportFromURL(t *testing.T) {
+	srv := newURLImportServer(t)
+	dir := t.TempDir()
+	stor, err := filestorage.New(dir)
+	require.NoError(t, err)
+	mockRegistryStore := new(MockRegistryStore)
+	expectNoUploadRoutes(mockRegistryStore)
+	resolver := newTestResolver(NewMockStorageProvider(stor), mockRegistryStore, new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop(),
+		WithURLImporter(urlimport.New(urlimport.WithAllowPrivateNetworks(true), urlimport.WithTempDir(t.TempDir()))))
+	ctx := createReadWriteContext("user-1")
+
+	path, err := resolver.Mutation().ImportFromURL(ctx, srv.URL+"/cat.png", stringPtr("album/"), nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "album/cat.png", path)
+	info, err := stor.Stat(context.Background(), "album/cat.png")
+	require.NoError(t, err)
+	assert.Positive(t, info.Size)
+
+	path, err = resolver.Mutation().ImportFromURL(ctx, srv.URL+"/cat.png", stringPtr("album/kitten.png"), nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "album/kitten.png", path)
+
+	// Existing files are never replaced
+	_, err = resolver.Mutation().ImportFromURL(ctx, srv.URL+"/cat.png", stringPtr("album/"), nil, nil)
+	assert.Error(t, err)
+
+	var gqlErr *gqlerror.Error
+	_, err = resolver.Mutation().ImportFromURL(ctx, srv.URL+"/page", nil, nil, nil)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+	_, err = resolver.Mutation().ImportFromURL(ctx, "file:///etc/passwd", nil, nil, nil)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+
+	_, err = resolver.Mutation().ImportFromURL(createReadOnlyContext("user-2"), srv.URL+"/cat.png", nil, nil, nil)
+	assert.Error(t, err)
+	_, err = resolver.Mutation().ImportFromURL(createGuestContext("guest-1"), srv.URL+"/cat.png", nil, nil, nil)
+	assert.Error(t, err)
+}
+
+func TestImportFromURL_PrivateAddressRejected(t *testing.T) {
+	srv := newURLImportServer(t)
+	stor, err := filestorage.New(t.TempDir())
+	require.NoError(t, err)
+	resolver := newTestResolver(NewMockStorageProvider(stor), new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop(),
+		WithURLImporter(urlimport.New()))
+
+	_, err = resolver.Mutation().ImportFromURL(createReadWriteContext("user-1"), srv.URL+"/cat.png", stringPtr("album/"), nil, nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+}
+
+func TestImportFromURL_NotAvailable(t *testing.T) {
+	resolver := newTestResolver(nil, new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop())
+	_, err := resolver.Mutation().ImportFromURL(createReadWriteContext("user-1"), "https://example.com/cat.png", nil, nil, nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_AVAILABLE", gqlErr.Extensions["code"])
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/pagination"
 	"github.com/cshum/imagor-studio/server/internal/userstore"
 	"github.com/cshum/imagor-studio/server/pkg/apperror"
 	"github.com/cshum/imagor-studio/server/pkg/auth"
@@ -60,6 +61,8 @@ func (r *queryResolver) Me(ctx context.Context) (*gql.User, error) {
 		EmailVerified: user.EmailVerified,
 		HasPassword:   user.HasPassword,
 		AvatarURL:     user.AvatarUrl,
+		HomePath:      user.HomePath,
+		TenantID:      user.TenantID,
 		AuthProviders: toGQLAuthProviders(r.userStore, r.logger, ctx, user.ID),
 	}, nil
 }
@@ -67,7 +70,7 @@ func (r *queryResolver) Me(ctx context.Context) (*gql.User, error) {
 // User returns a user by ID (admin only)
 func (r *queryResolver) User(ctx context.Context, id string) (*gql.User, error) {
 	// Check admin permissions
-	if err := RequireAdminPermission(ctx); err != nil {
+	if err := RequireTenantAdminPermission(ctx); err != nil {
 		return nil, err
 	}
 
@@ -77,7 +80,7 @@ func (r *queryResolver) User(ctx context.Context, id string) (*gql.User, error)
 		return nil, fmt.Errorf("failed to get user information")
 	}
 
-	if user == nil {
+	if user == nil || !isTenantMember(ctx, user) {
 		return nil, fmt.Errorf("user not found")
 	}
 
@@ -94,14 +97,20 @@ func (r *queryResolver) User(ctx context.Context, id string) (*gql.User, error)
 		EmailVerified: user.EmailVerified,
 		HasPassword:   user.HasPassword,
 		AvatarURL:     user.AvatarUrl,
+		HomePath:      user.HomePath,
+		TenantID:      user.TenantID,
 		AuthProviders: toGQLAuthProviders(r.userStore, r.logger, ctx, user.ID),
 	}, nil
 }
 
-// Users returns a list of users (admin only)
-func (r *queryResolver) Users(ctx context.Context, offset *int, limit *int, search *string) (*gql.UserList, error) {
+// Users returns a list of users (admin only), the members of the tenant
+// for tenant admins
+func (r *queryResolver) Users(ctx context.Context, offset *int, limit *int, search *string, after *string) (*gql.UserList, error) {
 	// Check admin permissions
-	if err := RequireAdminPermission(ctx); err != nil {
+	if err := RequireTenantAdminPermission(ctx); err != nil {
+		return nil, err
+	}
+	if err := requireAfterWithoutOffset(after, offset); err != nil {
 		return nil, err
 	}
 
@@ -129,7 +138,27 @@ func (r *queryResolver) Users(ctx context.Context, offset *int, limit *int, sear
 		limitVal = 0 // 0 means no limit
 	}
 
-	users, totalCount, err := r.userStore.List(ctx, offsetVal, limitVal, searchVal)
+	var users []*userstore.User
+	var totalCount int
+	var endCursor *string
+	var err error
+	if after != nil {
+		// A cursor listing continues after the last user of the previous page
+		var key *pagination.Key
+		if key, err = decodeAfterKey(*after); err != nil {
+			return nil, err
+		}
+		var more bool
+		users, totalCount, more, err = r.userStore.ListAfter(ctx, GetTenantIDFromContext(ctx), key, limitVal, searchVal)
+		if err == nil && len(users) > 0 {
+			last := users[len(users)-1]
+			endCursor = keyEndCursor(more, last.CreatedAt, last.ID)
+		}
+	} else if tenantID := GetTenantIDFromContext(ctx); tenantID != "" {
+		users, totalCount, err = r.userStore.ListByTenant(ctx, tenantID, offsetVal, limitVal, searchVal)
+	} else {
+		users, totalCount, err = r.userStore.List(ctx, offsetVal, limitVal, searchVal)
+	}
 	if err != nil {
 		r.logger.Error("Failed to list users", zap.Error(err))
 		return nil, fmt.Errorf("failed to list users")
@@ -150,6 +179,8 @@ func (r *queryResolver) Users(ctx context.Context, offset *int, limit *int, sear
 			EmailVerified: user.EmailVerified,
 			HasPassword:   user.HasPassword,
 			AvatarURL:     user.AvatarUrl,
+			HomePath:      user.HomePath,
+			TenantID:      user.TenantID,
 			AuthProviders: toGQLAuthProviders(r.userStore, r.logger, ctx, user.ID),
 		}
 	}
@@ -157,12 +188,13 @@ func (r *queryResolver) Users(ctx context.Context, offset *int, limit *int, sear
 	return &gql.UserList{
 		Items:      gqlUsers,
 		TotalCount: totalCount,
+		EndCursor:  endCursor,
 	}, nil
 }
 
 // UpdateProfile updates a user's profile (self or admin operation)
 func (r *mutationResolver) UpdateProfile(ctx context.Context, input gql.UpdateProfileInput, userID *string) (*gql.User, error) {
-	targetUserID, err := GetEffectiveTargetUserID(ctx, userID)
+	targetUserID, err := r.effectiveTargetUserID(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -234,12 +266,14 @@ func (r *mutationResolver) UpdateProfile(ctx context.Context, input gql.UpdatePr
 		EmailVerified: updatedUser.EmailVerified,
 		HasPassword:   updatedUser.HasPassword,
 		AvatarURL:     updatedUser.AvatarUrl,
+		HomePath:      updatedUser.HomePath,
+		TenantID:      updatedUser.TenantID,
 		AuthProviders: toGQLAuthProviders(r.userStore, r.logger, ctx, updatedUser.ID),
 	}, nil
 }
 
 func (r *mutationResolver) RequestEmailChange(ctx context.Context, email string, userID *string) (*gql.EmailChangeRequestResult, error) {
-	targetUserID, err := GetEffectiveTargetUserID(ctx, userID)
+	targetUserID, err := r.effectiveTargetUserID(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -286,7 +320,7 @@ func (r *mutationResolver) RequestEmailChange(ctx context.Context, email string,
 }
 
 func (r *mutationResolver) UnlinkAuthProvider(ctx context.Context, provider string, userID *string) (bool, error) {
-	targetUserID, err := GetEffectiveTargetUserID(ctx, userID)
+	targetUserID, err := r.effectiveTargetUserID(ctx, userID)
 	if err != nil {
 		return false, err
 	}
@@ -331,7 +365,7 @@ func toGQLAuthProviders(userStore userstore.Store, logger *zap.Logger, ctx conte
 // ChangePassword changes a user's password (self or admin operation)
 func (r *mutationResolver) ChangePassword(ctx context.Context, input gql.ChangePasswordInput, userID *string) (bool, error) {
 	isAdminOperation := userID != nil
-	targetUserID, err := GetEffectiveTargetUserID(ctx, userID)
+	targetUserID, err := r.effectiveTargetUserID(ctx, userID)
 	if err != nil {
 		return false, err
 	}
@@ -380,7 +414,7 @@ func (r *mutationResolver) ChangePassword(ctx context.Context, input gql.ChangeP
 
 // DeactivateAccount deactivates a user's account (self or admin operation)
 func (r *mutationResolver) DeactivateAccount(ctx context.Context, userID *string) (bool, error) {
-	targetUserID, err := GetEffectiveTargetUserID(ctx, userID)
+	targetUserID, err := r.effectiveTargetUserID(ctx, userID)
 	if err != nil {
 		return false, err
 	}
@@ -418,7 +452,7 @@ func (r *mutationResolver) DeactivateAccount(ctx context.Context, userID *string
 // ReactivateAccount reactivates a deactivated user's account (admin only)
 func (r *mutationResolver) ReactivateAccount(ctx context.Context, userID string) (bool, error) {
 	// Only admins can reactivate accounts
-	if err := RequireAdminPermission(ctx); err != nil {
+	if err := RequireTenantAdminPermission(ctx); err != nil {
 		return false, err
 	}
 
@@ -432,7 +466,7 @@ func (r *mutationResolver) ReactivateAccount(ctx context.Context, userID string)
 	if err != nil {
 		return false, fmt.Errorf("failed to get target user: %w", err)
 	}
-	if targetUser == nil {
+	if targetUser == nil || !isTenantMember(ctx, targetUser) {
 		return false, fmt.Errorf("target user not found")
 	}
 
@@ -447,10 +481,67 @@ func (r *mutationResolver) ReactivateAccount(ctx context.Context, userID string)
 	return true, nil
 }
 
-// CreateUser creates a new user (admin only)
+// SetUserHomePath scopes a user's storage access to a home path (admin
+// only). The home path of tenant members is relative to the tenant's
+// storage root.
+func (r *mutationResolver) SetUserHomePath(ctx context.Context, userID string, homePath *string) (*gql.User, error) {
+	if err := RequireTenantAdminPermission(ctx); err != nil {
+		return nil, err
+	}
+
+	var normalized *string
+	if homePath != nil {
+		cleaned, err := NormalizeHomePath(*homePath)
+		if err != nil {
+			return nil, apperror.BadRequest(err.Error(), nil, "homePath")
+		}
+		if cleaned != "" {
+			normalized = &cleaned
+		}
+	}
+
+	targetUser, err := r.userStore.GetByIDAdmin(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get target user: %w", err)
+	}
+	if targetUser == nil || !isTenantMember(ctx, targetUser) {
+		return nil, fmt.Errorf("target user not found")
+	}
+
+	if err := r.userStore.UpdateHomePath(ctx, userID, normalized); err != nil {
+		return nil, fmt.Errorf("failed to update home path: %w", err)
+	}
+	targetUser.HomePath = normalized
+
+	currentUserID, _ := GetUserIDFromContext(ctx)
+	r.logger.Info("User home path updated",
+		zap.String("targetUserID", userID),
+		zap.Stringp("homePath", normalized),
+		zap.String("updatedByUserID", currentUserID))
+
+	return &gql.User{
+		ID:            targetUser.ID,
+		DisplayName:   targetUser.DisplayName,
+		Username:      targetUser.Username,
+		Role:          targetUser.Role,
+		IsActive:      targetUser.IsActive,
+		CreatedAt:     targetUser.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:     targetUser.UpdatedAt.Format(time.RFC3339),
+		Email:         targetUser.Email,
+		PendingEmail:  targetUser.PendingEmail,
+		EmailVerified: targetUser.EmailVerified,
+		HasPassword:   targetUser.HasPassword,
+		AvatarURL:     targetUser.AvatarUrl,
+		HomePath:      targetUser.HomePath,
+		TenantID:      targetUser.TenantID,
+		AuthProviders: toGQLAuthProviders(r.userStore, r.logger, ctx, targetUser.ID),
+	}, nil
+}
+
+// CreateUser creates a new user (admin only), in the tenant of tenant admins
 func (r *mutationResolver) CreateUser(ctx context.Context, input gql.CreateUserInput) (*gql.User, error) {
 	// Check admin permissions
-	if err := RequireAdminPermission(ctx); err != nil {
+	if err := RequireTenantAdminPermission(ctx); err != nil {
 		return nil, err
 	}
 
@@ -483,7 +574,12 @@ func (r *mutationResolver) CreateUser(ctx context.Context, input gql.CreateUserI
 	}
 
 	// Create user
-	user, err := r.userStore.Create(ctx, normalizedDisplayName, normalizedUsername, hashedPassword, normalizedRole)
+	var user *userstore.User
+	if tenantID := GetTenantIDFromContext(ctx); tenantID != "" {
+		user, err = r.userStore.CreateInTenant(ctx, tenantID, normalizedDisplayName, normalizedUsername, hashedPassword, normalizedRole)
+	} else {
+		user, err = r.userStore.Create(ctx, normalizedDisplayName, normalizedUsername, hashedPassword, normalizedRole)
+	}
 	if err != nil {
 		if errors.Is(err, userstore.ErrUsernameAlreadyExists) {
 			return nil, apperror.Conflict("Username already exists", "username", "input.username")
@@ -508,6 +604,7 @@ func (r *mutationResolver) CreateUser(ctx context.Context, input gql.CreateUserI
 		UpdatedAt:   user.UpdatedAt.Format(time.RFC3339),
 		Email:       user.Email,
 		AvatarURL:   user.AvatarUrl,
+		TenantID:    user.TenantID,
 	}, nil
 }
 
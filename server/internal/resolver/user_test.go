@@ -10,6 +10,7 @@ import (
 	"github.com/cshum/imagor-studio/server/internal/config"
 	"github.com/cshum/imagor-studio/server/internal/generated/gql"
 	"github.com/cshum/imagor-studio/server/internal/model"
+	"github.com/cshum/imagor-studio/server/internal/pagination"
 	"github.com/cshum/imagor-studio/server/internal/userstore"
 	"github.com/cshum/imagor-studio/server/pkg/auth"
 	"github.com/stretchr/testify/assert"
@@ -176,6 +177,51 @@ func TestReactivateAccount_NonAdminForbidden(t *testing.T) {
 	mockUserStore.AssertExpectations(t)
 }
 
+func TestSetUserHomePath(t *testing.T) {
+	mockStorage := new(MockStorage)
+	mockRegistryStore := new(MockRegistryStore)
+	mockUserStore := new(MockUserStore)
+	logger, _ := zap.NewDevelopment()
+	cfg := &config.Config{}
+	mockStorageProvider := NewMockStorageProvider(mockStorage)
+	resolver := newTestResolver(mockStorageProvider, mockRegistryStore, mockUserStore, nil, cfg, nil, logger)
+
+	ctx := createAdminContext("admin-user-id")
+
+	now := time.Now()
+	targetUser := &userstore.User{
+		ID:          "target-user-id",
+		DisplayName: "targetuser",
+		Username:    "targetuser",
+		Role:        "user",
+		IsActive:    true,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	mockUserStore.On("GetByIDAdmin", ctx, "target-user-id").Return(targetUser, nil)
+	mockUserStore.On("UpdateHomePath", ctx, "target-user-id", stringPtr("teams/alice")).Return(nil).Once()
+	mockUserStore.On("UpdateHomePath", ctx, "target-user-id", (*string)(nil)).Return(nil).Once()
+	mockUserStore.On("ListAuthProviders", ctx, "target-user-id").Return([]*userstore.AuthProvider{}, nil)
+
+	result, err := resolver.Mutation().SetUserHomePath(ctx, "target-user-id", stringPtr("/teams/alice/"))
+	assert.NoError(t, err)
+	assert.Equal(t, stringPtr("teams/alice"), result.HomePath)
+
+	result, err = resolver.Mutation().SetUserHomePath(ctx, "target-user-id", stringPtr("/"))
+	assert.NoError(t, err)
+	assert.Nil(t, result.HomePath)
+
+	_, err = resolver.Mutation().SetUserHomePath(ctx, "target-user-id", stringPtr("../etc"))
+	assert.Error(t, err)
+
+	_, err = resolver.Mutation().SetUserHomePath(createReadWriteContext("regular-user-id"), "target-user-id", stringPtr("teams/alice"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "insufficient permission")
+
+	mockUserStore.AssertExpectations(t)
+}
+
 func TestUpdateProfile_SelfOperation(t *testing.T) {
 	mockStorage := new(MockStorage)
 	mockRegistryStore := new(MockRegistryStore)
@@ -926,7 +972,7 @@ func TestUsers_AdminOnly(t *testing.T) {
 				mockUserStore.On("ListAuthProviders", ctx, "user1").Return([]*userstore.AuthProvider{}, nil)
 			}
 
-			result, err := resolver.Query().Users(ctx, tt.offset, tt.limit, nil)
+			result, err := resolver.Query().Users(ctx, tt.offset, tt.limit, nil, nil)
 
 			if tt.expectError {
 				assert.Error(t, err)
@@ -1025,7 +1071,7 @@ func TestUsers_LimitValidation(t *testing.T) {
 			mockUserStore.On("List", ctx, tt.expectedOffset, tt.expectedLimit, mock.Anything).Return(users, 1, nil)
 			mockUserStore.On("ListAuthProviders", ctx, "user1").Return([]*userstore.AuthProvider{}, nil)
 
-			result, err := resolver.Query().Users(ctx, tt.offset, tt.limit, nil)
+			result, err := resolver.Query().Users(ctx, tt.offset, tt.limit, nil, nil)
 
 			assert.NoError(t, err)
 			assert.NotNil(t, result)
@@ -1037,6 +1083,38 @@ func TestUsers_LimitValidation(t *testing.T) {
 	}
 }
 
+func TestUsers_Cursor(t *testing.T) {
+	mockUserStore := new(MockUserStore)
+	resolver := newTestResolver(nil, new(MockRegistryStore), mockUserStore, nil, &config.Config{}, nil, zap.NewNop())
+	ctx := createAdminContext("admin-1")
+
+	now := time.Now().UTC()
+	newest := &userstore.User{ID: "user-2", Username: "bob", CreatedAt: now, UpdatedAt: now}
+	oldest := &userstore.User{ID: "user-1", Username: "alice", CreatedAt: now.Add(-time.Hour), UpdatedAt: now}
+	mockUserStore.On("ListAuthProviders", ctx, mock.Anything).Return([]*userstore.AuthProvider{}, nil)
+	mockUserStore.On("ListAfter", ctx, "", (*pagination.Key)(nil), 1, "").Return([]*userstore.User{newest}, 2, true, nil)
+	mockUserStore.On("ListAfter", ctx, "", mock.MatchedBy(func(key *pagination.Key) bool {
+		return key != nil && key.ID == newest.ID && key.CreatedAt.Equal(newest.CreatedAt)
+	}), 1, "").Return([]*userstore.User{oldest}, 2, false, nil)
+
+	result, err := resolver.Query().Users(ctx, nil, intPtr(1), nil, stringPtr(""))
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.TotalCount)
+	require.Len(t, result.Items, 1)
+	assert.Equal(t, "user-2", result.Items[0].ID)
+	require.NotNil(t, result.EndCursor)
+
+	result, err = resolver.Query().Users(ctx, nil, intPtr(1), nil, result.EndCursor)
+	require.NoError(t, err)
+	require.Len(t, result.Items, 1)
+	assert.Equal(t, "user-1", result.Items[0].ID)
+	assert.Nil(t, result.EndCursor)
+
+	_, err = resolver.Query().Users(ctx, intPtr(1), nil, nil, stringPtr(""))
+	assert.Error(t, err)
+	mockUserStore.AssertExpectations(t)
+}
+
 func TestUserOperations_UserNotFound(t *testing.T) {
 	mockStorage := new(MockStorage)
 	mockRegistryStore := new(MockRegistryStore)
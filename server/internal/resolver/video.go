@@ -0,0 +1,145 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/hls"
+	"github.com/cshum/imagor-studio/server/internal/registryutil"
+	"github.com/cshum/imagor-studio/server/internal/videostream"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+const (
+	// hlsBasePath is where the HLS session handler is mounted
+	hlsBasePath = "/api/hls/"
+	// videoStreamBasePath is where the video stream handler is mounted
+	videoStreamBasePath = "/api/stream/"
+)
+
+// VideoPlayback is the resolver for the videoPlayback field.
+func (r *queryResolver) VideoPlayback(ctx context.Context, path string, spaceID *string, codecs []string) (*gql.VideoPlayback, error) {
+	path, err := ScopePath(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if err := RequireReadPermission(ctx, path); err != nil {
+		return nil, err
+	}
+	if r.hlsManager == nil {
+		return nil, &gqlerror.Error{
+			Message:    "video transcoding is not enabled",
+			Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+		}
+	}
+	spaceConfig, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	var stor storage.Storage
+	if spaceConfig != nil {
+		stor, err = r.storageFromSpaceConfig(spaceConfig)
+	} else {
+		stor, err = r.getSpaceStorageByID(ctx, spaceID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	src := hls.Source{Storage: stor, Path: path}
+	if spaceConfig != nil {
+		src.Namespace = spaceConfig.ID
+	}
+	userID, _ := GetUserIDFromContext(ctx)
+	playback, err := r.hlsManager.Negotiate(ctx, userID, src, codecs)
+	if err != nil {
+		if errors.Is(err, hls.ErrTooManyTranscodes) {
+			return nil, &gqlerror.Error{
+				Message:    "too many videos are being transcoded, try again shortly",
+				Extensions: map[string]interface{}{"code": "TOO_MANY_REQUESTS"},
+			}
+		}
+		r.logger.Warn("Failed to negotiate video playback", zap.String("path", path), zap.Error(err))
+		return nil, fmt.Errorf("failed to prepare video playback: %w", err)
+	}
+
+	result := &gql.VideoPlayback{
+		Mode:        gql.VideoPlaybackModeDirect,
+		SourceCodec: playback.SourceCodec,
+	}
+	if playback.Mode == hls.ModeHLS {
+		result.Mode = gql.VideoPlaybackModeHls
+		playlistURL := hlsBasePath + playback.SessionID + "/" + hls.PlaylistName
+		expiresAt := playback.ExpiresAt.Format(time.RFC3339)
+		result.PlaylistURL = &playlistURL
+		result.ExpiresAt = &expiresAt
+	}
+	return result, nil
+}
+
+// VideoStream is the resolver for the videoStream field.
+func (r *queryResolver) VideoStream(ctx context.Context, path string, spaceID *string) (*gql.VideoStream, error) {
+	path, err := ScopePath(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if err := RequireReadPermission(ctx, path); err != nil {
+		return nil, err
+	}
+	if r.videoStreams == nil {
+		return nil, &gqlerror.Error{
+			Message:    "video streaming is not enabled",
+			Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+		}
+	}
+	spaceConfig, err := r.getAccessibleSpaceByID(ctx, spaceID)
+	if err != nil {
+		return nil, err
+	}
+	var stor storage.Storage
+	if spaceConfig != nil {
+		stor, err = r.storageFromSpaceConfig(spaceConfig)
+	} else {
+		stor, err = r.getSpaceStorageByID(ctx, spaceID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	src := videostream.Source{Storage: stor, Path: path}
+	if spaceConfig != nil {
+		src.Namespace = spaceConfig.ID
+	}
+	stream, err := r.videoStreams.Open(ctx, src, r.getVideoStreamProfile(ctx))
+	if err != nil {
+		if errors.Is(err, videostream.ErrTooManyProcesses) {
+			return nil, &gqlerror.Error{
+				Message:    "too many videos are being transcoded, try again shortly",
+				Extensions: map[string]interface{}{"code": "TOO_MANY_REQUESTS"},
+			}
+		}
+		r.logger.Warn("Failed to open video stream", zap.String("path", path), zap.Error(err))
+		return nil, fmt.Errorf("failed to open video stream: %w", err)
+	}
+	return &gql.VideoStream{
+		URL:       videoStreamBasePath + stream.SessionID + "/" + videostream.StreamName,
+		Cached:    stream.Cached,
+		ExpiresAt: stream.ExpiresAt.Format(time.RFC3339),
+	}, nil
+}
+
+// getVideoStreamProfile returns the profile set in the registry, falling
+// back to the default when it is unset or invalid.
+func (r *Resolver) getVideoStreamProfile(ctx context.Context) videostream.Profile {
+	result := registryutil.GetEffectiveValue(ctx, r.registryStore, r.config, videostream.RegistryKey)
+	profile, err := videostream.FromRegistryValue(result.Value)
+	if err != nil {
+		r.logger.Warn("Invalid video stream profile, using defaults", zap.Error(err))
+	}
+	return profile
+}
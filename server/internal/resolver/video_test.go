@@ -0,0 +1,135 @@
+package resolver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/hls"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/internal/videostream"
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+type stubTranscoder struct {
+	codec string
+}
+
+func (s *stubTranscoder) Probe(ctx context.Context, sourcePath string) (string, error) {
+	return s.codec, nil
+}
+
+func (s *stubTranscoder) Transcode(ctx context.Context, sourcePath, outDir string, segmentDuration time.Duration) error {
+	return os.WriteFile(filepath.Join(outDir, hls.PlaylistName), []byte("#EXTM3U\n#EXT-X-ENDLIST\n"), 0644)
+}
+
+func newVideoTestResolver(t *testing.T, codec string) *Resolver {
+	t.Helper()
+	baseDir := t.TempDir()
+	writeTestFile(t, baseDir, "clips/video.mp4")
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+	logger, _ := zap.NewDevelopment()
+	manager, err := hls.NewManager(logger, &stubTranscoder{codec: codec}, t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(manager.Close)
+	return newTestResolver(NewMockStorageProvider(stor), new(MockRegistryStore), new(MockUserStore), nil, &config.Config{}, nil, logger,
+		WithHLSManager(manager))
+}
+
+func TestVideoPlayback_Direct(t *testing.T) {
+	resolver := newVideoTestResolver(t, "h264")
+
+	playback, err := resolver.Query().VideoPlayback(createReadOnlyContext("user-1"), "clips/video.mp4", nil, []string{"avc1.64001F"})
+	require.NoError(t, err)
+	assert.Equal(t, gql.VideoPlaybackModeDirect, playback.Mode)
+	assert.Equal(t, "h264", playback.SourceCodec)
+	assert.Nil(t, playback.PlaylistURL)
+}
+
+func TestVideoPlayback_HLS(t *testing.T) {
+	resolver := newVideoTestResolver(t, "hevc")
+
+	playback, err := resolver.Query().VideoPlayback(createReadOnlyContext("user-1"), "clips/video.mp4", nil, []string{"h264"})
+	require.NoError(t, err)
+	assert.Equal(t, gql.VideoPlaybackModeHls, playback.Mode)
+	assert.Equal(t, "hevc", playback.SourceCodec)
+	require.NotNil(t, playback.PlaylistURL)
+	assert.Regexp(t, `^/api/hls/[^/]+/index\.m3u8$`, *playback.PlaylistURL)
+	assert.NotNil(t, playback.ExpiresAt)
+}
+
+func TestVideoPlayback_NotEnabled(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	resolver := newTestResolver(nil, nil, nil, nil, nil, nil, logger)
+
+	_, err := resolver.Query().VideoPlayback(createReadOnlyContext("user-1"), "clips/video.mp4", nil, nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_AVAILABLE", gqlErr.Extensions["code"])
+}
+
+type stubStreamTranscoder struct {
+	profiles chan videostream.Profile
+}
+
+func (s *stubStreamTranscoder) Probe(ctx context.Context, sourcePath string) (string, error) {
+	return "hevc", nil
+}
+
+func (s *stubStreamTranscoder) Transcode(ctx context.Context, sourcePath, outPath string, profile videostream.Profile, copyCodec string) error {
+	s.profiles <- profile
+	return os.WriteFile(outPath, []byte("mp4"), 0644)
+}
+
+func TestVideoStream(t *testing.T) {
+	baseDir := t.TempDir()
+	writeTestFile(t, baseDir, "clips/video.mkv")
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+	transcoder := &stubStreamTranscoder{profiles: make(chan videostream.Profile, 1)}
+	manager, err := videostream.NewManager(zap.NewNop(), transcoder, t.TempDir())
+	require.NoError(t, err)
+	t.Cleanup(manager.Close)
+	mockRegistryStore := new(MockRegistryStore)
+	mockRegistryStore.On("GetMulti", mock.Anything, registrystore.SystemOwnerID, []string{videostream.RegistryKey}).Return([]*registrystore.Registry{
+		{Key: videostream.RegistryKey, Value: `{"codec": "hevc", "videoBitrate": "2M"}`},
+	}, nil)
+	resolver := newTestResolver(NewMockStorageProvider(stor), mockRegistryStore, new(MockUserStore), nil, &config.Config{}, nil, zap.NewNop(),
+		WithVideoStreamManager(manager))
+
+	stream, err := resolver.Query().VideoStream(createReadOnlyContext("user-1"), "clips/video.mkv", nil)
+	require.NoError(t, err)
+	assert.Regexp(t, `^/api/stream/[^/]+/video\.mp4$`, stream.URL)
+	assert.False(t, stream.Cached)
+	assert.NotEmpty(t, stream.ExpiresAt)
+	select {
+	case profile := <-transcoder.profiles:
+		assert.Equal(t, "hevc", profile.Codec)
+		assert.Equal(t, "2M", profile.VideoBitrate)
+	case <-time.After(5 * time.Second):
+		t.Fatal("video not transcoded")
+	}
+	require.Eventually(t, func() bool {
+		entries, _ := os.ReadDir(filepath.Join(baseDir, videostream.RenditionFolder))
+		return len(entries) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestVideoStream_NotEnabled(t *testing.T) {
+	resolver := newTestResolver(nil, nil, nil, nil, nil, nil, zap.NewNop())
+
+	_, err := resolver.Query().VideoStream(createReadOnlyContext("user-1"), "clips/video.mkv", nil)
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_AVAILABLE", gqlErr.Extensions["code"])
+}
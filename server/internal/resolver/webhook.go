@@ -0,0 +1,202 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/webhook"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func webhooksNotAvailableError() error {
+	return &gqlerror.Error{
+		Message:    "webhooks are not available on this server",
+		Extensions: map[string]interface{}{"code": "NOT_AVAILABLE"},
+	}
+}
+
+func webhookError(err error) error {
+	switch {
+	case errors.Is(err, webhook.ErrNotFound):
+		return &gqlerror.Error{
+			Message:    err.Error(),
+			Extensions: map[string]interface{}{"code": "NOT_FOUND"},
+		}
+	case errors.Is(err, webhook.ErrInvalid):
+		return &gqlerror.Error{
+			Message:    err.Error(),
+			Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+		}
+	}
+	return fmt.Errorf("webhook operation failed: %w", err)
+}
+
+func toGQLWebhook(w *webhook.Webhook) *gql.Webhook {
+	return &gql.Webhook{
+		ID:        w.ID,
+		URL:       w.URL,
+		Events:    w.Events,
+		Active:    w.Active,
+		HasSecret: w.Secret != "",
+		CreatedBy: w.CreatedBy,
+		CreatedAt: w.CreatedAt.Format(time.RFC3339),
+		UpdatedAt: w.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+// notifyWebhooks queues event for the webhooks subscribed to it
+func (r *Resolver) notifyWebhooks(event string, data any) {
+	if r.webhooks != nil {
+		r.webhooks.Notify(event, data)
+	}
+}
+
+// Webhooks is the resolver for the webhooks field.
+func (r *queryResolver) Webhooks(ctx context.Context) ([]*gql.Webhook, error) {
+	if err := RequireAdminPermission(ctx); err != nil {
+		return nil, err
+	}
+	if r.webhookStore == nil {
+		return nil, webhooksNotAvailableError()
+	}
+	webhooks, err := r.webhookStore.List(ctx)
+	if err != nil {
+		return nil, webhookError(err)
+	}
+	result := make([]*gql.Webhook, 0, len(webhooks))
+	for _, w := range webhooks {
+		result = append(result, toGQLWebhook(w))
+	}
+	return result, nil
+}
+
+// WebhookDeliveries is the resolver for the webhookDeliveries field.
+func (r *queryResolver) WebhookDeliveries(ctx context.Context, webhookID *string, offset *int, limit *int, after *string) (*gql.WebhookDeliveryPage, error) {
+	if err := RequireAdminPermission(ctx); err != nil {
+		return nil, err
+	}
+	if r.webhookStore == nil {
+		return nil, webhooksNotAvailableError()
+	}
+	id, offsetValue, limitValue := "", 0, 0
+	if webhookID != nil {
+		id = *webhookID
+	}
+	if offset != nil {
+		offsetValue = *offset
+	}
+	if limit != nil {
+		limitValue = *limit
+	}
+	if err := requireAfterWithoutOffset(after, offset); err != nil {
+		return nil, err
+	}
+	var deliveries []*webhook.Delivery
+	var total int
+	var endCursor *string
+	if after != nil {
+		key, err := decodeAfterKey(*after)
+		if err != nil {
+			return nil, err
+		}
+		var more bool
+		deliveries, total, more, err = r.webhookStore.ListDeliveriesAfter(ctx, id, key, limitValue)
+		if err != nil {
+			return nil, webhookError(err)
+		}
+		if len(deliveries) > 0 {
+			last := deliveries[len(deliveries)-1]
+			endCursor = keyEndCursor(more, last.CreatedAt, last.ID)
+		}
+	} else {
+		var err error
+		deliveries, total, err = r.webhookStore.ListDeliveries(ctx, id, offsetValue, limitValue)
+		if err != nil {
+			return nil, webhookError(err)
+		}
+	}
+	items := make([]*gql.WebhookDelivery, 0, len(deliveries))
+	for _, d := range deliveries {
+		items = append(items, &gql.WebhookDelivery{
+			ID:             d.ID,
+			WebhookID:      d.WebhookID,
+			Event:          d.Event,
+			Payload:        d.Payload,
+			Status:         gql.WebhookDeliveryStatus(strings.ToUpper(d.Status)),
+			Attempts:       d.Attempts,
+			ResponseStatus: optionalInt(d.ResponseStatus),
+			Error:          optionalString(d.Error),
+			CreatedAt:      d.CreatedAt.Format(time.RFC3339),
+			UpdatedAt:      d.UpdatedAt.Format(time.RFC3339),
+		})
+	}
+	return &gql.WebhookDeliveryPage{Items: items, TotalCount: total, EndCursor: endCursor}, nil
+}
+
+// CreateWebhook is the resolver for the createWebhook field.
+func (r *mutationResolver) CreateWebhook(ctx context.Context, input gql.WebhookInput) (*gql.Webhook, error) {
+	if err := RequireAdminPermission(ctx); err != nil {
+		return nil, err
+	}
+	if r.webhookStore == nil {
+		return nil, webhooksNotAvailableError()
+	}
+	secret := ""
+	if input.Secret != nil {
+		secret = *input.Secret
+	}
+	currentUserID, _ := GetUserIDFromContext(ctx)
+	w, err := r.webhookStore.Create(ctx, input.URL, secret, input.Events, input.Active == nil || *input.Active, currentUserID)
+	if err != nil {
+		return nil, webhookError(err)
+	}
+	r.logger.Info("Webhook created",
+		zap.String("webhookID", w.ID),
+		zap.Strings("events", w.Events),
+		zap.String("createdByUserID", currentUserID))
+	return toGQLWebhook(w), nil
+}
+
+// UpdateWebhook is the resolver for the updateWebhook field.
+func (r *mutationResolver) UpdateWebhook(ctx context.Context, id string, input gql.WebhookInput) (*gql.Webhook, error) {
+	if err := RequireAdminPermission(ctx); err != nil {
+		return nil, err
+	}
+	if r.webhookStore == nil {
+		return nil, webhooksNotAvailableError()
+	}
+	w, err := r.webhookStore.Update(ctx, id, input.URL, input.Secret, input.Events, input.Active == nil || *input.Active)
+	if err != nil {
+		return nil, webhookError(err)
+	}
+	currentUserID, _ := GetUserIDFromContext(ctx)
+	r.logger.Info("Webhook updated",
+		zap.String("webhookID", w.ID),
+		zap.Strings("events", w.Events),
+		zap.Bool("active", w.Active),
+		zap.String("updatedByUserID", currentUserID))
+	return toGQLWebhook(w), nil
+}
+
+// DeleteWebhook is the resolver for the deleteWebhook field.
+func (r *mutationResolver) DeleteWebhook(ctx context.Context, id string) (bool, error) {
+	if err := RequireAdminPermission(ctx); err != nil {
+		return false, err
+	}
+	if r.webhookStore == nil {
+		return false, webhooksNotAvailableError()
+	}
+	if err := r.webhookStore.Delete(ctx, id); err != nil {
+		return false, webhookError(err)
+	}
+	currentUserID, _ := GetUserIDFromContext(ctx)
+	r.logger.Info("Webhook deleted",
+		zap.String("webhookID", id),
+		zap.String("deletedByUserID", currentUserID))
+	return true, nil
+}
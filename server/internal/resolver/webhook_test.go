@@ -0,0 +1,91 @@
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/cshum/imagor-studio/server/internal/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.uber.org/zap"
+)
+
+func TestWebhooks(t *testing.T) {
+	db := testutil.NewDB(t)
+
+	store := webhook.New(db, zap.NewNop())
+	resolver := newTestResolver(nil, new(MockRegistryStore), new(MockUserStore), nil, &MockConfig{}, nil, zap.NewNop(),
+		WithWebhooks(store, nil))
+	ctx := createAdminContext("admin-1")
+	secret := "s3cret"
+	input := gql.WebhookInput{
+		URL:    "https://example.com/hook",
+		Secret: &secret,
+		Events: []string{webhook.EventFileUploaded, webhook.EventAlbumCreated},
+	}
+
+	_, err := resolver.Mutation().CreateWebhook(createReadWriteContext("user-1"), input)
+	assert.Error(t, err, "admin only")
+	_, err = resolver.Mutation().CreateWebhook(createTenantAdminContext("admin-2", "acme"), input)
+	assert.Error(t, err, "server admin only")
+
+	created, err := resolver.Mutation().CreateWebhook(ctx, input)
+	require.NoError(t, err)
+	assert.True(t, created.Active)
+	assert.True(t, created.HasSecret)
+	assert.Equal(t, "admin-1", created.CreatedBy)
+	assert.Equal(t, []string{webhook.EventAlbumCreated, webhook.EventFileUploaded}, created.Events)
+
+	_, err = resolver.Mutation().CreateWebhook(ctx, gql.WebhookInput{URL: "https://example.com/hook", Events: []string{"file.moved"}})
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "BAD_USER_INPUT", gqlErr.Extensions["code"])
+
+	inactive := false
+	updated, err := resolver.Mutation().UpdateWebhook(ctx, created.ID, gql.WebhookInput{
+		URL:    "https://example.com/other",
+		Events: []string{webhook.EventShareAccessed},
+		Active: &inactive,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/other", updated.URL)
+	assert.False(t, updated.Active)
+	assert.True(t, updated.HasSecret, "a null secret keeps the current one")
+
+	_, err = resolver.Mutation().UpdateWebhook(ctx, "missing", input)
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_FOUND", gqlErr.Extensions["code"])
+
+	require.NoError(t, store.CreateDelivery(context.Background(), &webhook.Delivery{
+		WebhookID: created.ID, Event: webhook.EventShareAccessed, Payload: `{"event":"share.accessed"}`,
+	}))
+	page, err := resolver.Query().WebhookDeliveries(ctx, &created.ID, nil, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, page.TotalCount)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, gql.WebhookDeliveryStatusPending, page.Items[0].Status)
+	assert.Nil(t, page.Items[0].ResponseStatus)
+
+	webhooks, err := resolver.Query().Webhooks(ctx)
+	require.NoError(t, err)
+	require.Len(t, webhooks, 1)
+
+	deleted, err := resolver.Mutation().DeleteWebhook(ctx, created.ID)
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	webhooks, err = resolver.Query().Webhooks(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, webhooks)
+}
+
+func TestWebhooks_NotAvailable(t *testing.T) {
+	resolver := newTestResolver(nil, new(MockRegistryStore), new(MockUserStore), nil, &MockConfig{}, nil, zap.NewNop())
+
+	_, err := resolver.Query().Webhooks(createAdminContext("admin-1"))
+	var gqlErr *gqlerror.Error
+	require.ErrorAs(t, err, &gqlErr)
+	assert.Equal(t, "NOT_AVAILABLE", gqlErr.Extensions["code"])
+}
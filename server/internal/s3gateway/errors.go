@@ -0,0 +1,72 @@
+package s3gateway
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+)
+
+// Error is an S3 error response
+type Error struct {
+	Code    string
+	Message string
+	Status  int
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// withMessage returns a copy of e with another message
+func (e *Error) withMessage(message string) *Error {
+	return &Error{Code: e.Code, Message: message, Status: e.Status}
+}
+
+var (
+	ErrAccessDenied              = &Error{"AccessDenied", "Access Denied", http.StatusForbidden}
+	ErrInvalidAccessKeyID        = &Error{"InvalidAccessKeyId", "The access key ID you provided does not exist in our records.", http.StatusForbidden}
+	ErrSignatureDoesNotMatch     = &Error{"SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided.", http.StatusForbidden}
+	ErrRequestTimeTooSkewed      = &Error{"RequestTimeTooSkewed", "The difference between the request time and the server's time is too large.", http.StatusForbidden}
+	ErrMissingSecurityHeader     = &Error{"MissingSecurityHeader", "Your request is missing a required header.", http.StatusBadRequest}
+	ErrAuthorizationMalformed    = &Error{"AuthorizationHeaderMalformed", "The authorization header is malformed.", http.StatusBadRequest}
+	ErrContentSHA256Mismatch     = &Error{"XAmzContentSHA256Mismatch", "The provided 'x-amz-content-sha256' header does not match what was computed.", http.StatusBadRequest}
+	ErrIncompleteBody            = &Error{"IncompleteBody", "You did not provide the number of bytes specified by the Content-Length HTTP header.", http.StatusBadRequest}
+	ErrInvalidArgument           = &Error{"InvalidArgument", "Invalid Argument", http.StatusBadRequest}
+	ErrNoSuchBucket              = &Error{"NoSuchBucket", "The specified bucket does not exist.", http.StatusNotFound}
+	ErrNoSuchKey                 = &Error{"NoSuchKey", "The specified key does not exist.", http.StatusNotFound}
+	ErrMethodNotAllowed          = &Error{"MethodNotAllowed", "The specified method is not allowed against this resource.", http.StatusMethodNotAllowed}
+	ErrNotImplemented            = &Error{"NotImplemented", "A header or operation you provided implies functionality that is not implemented.", http.StatusNotImplemented}
+	ErrInternalError             = &Error{"InternalError", "We encountered an internal error. Please try again.", http.StatusInternalServerError}
+	ErrEntityTooLarge            = &Error{"EntityTooLarge", "Your proposed upload exceeds the maximum allowed object size.", http.StatusBadRequest}
+	ErrBucketAlreadyOwnedByYou   = &Error{"BucketAlreadyOwnedByYou", "Your previous request to create the named bucket succeeded and you already own it.", http.StatusConflict}
+	ErrInvalidPresignedExpiresIn = &Error{"AuthorizationQueryParametersError", "X-Amz-Expires must be between 1 and 604800 seconds.", http.StatusBadRequest}
+)
+
+type errorResponse struct {
+	XMLName  xml.Name `xml:"Error"`
+	Code     string   `xml:"Code"`
+	Message  string   `xml:"Message"`
+	Resource string   `xml:"Resource"`
+}
+
+// WriteError writes err as an S3 error response, InternalError unless it
+// is an *Error
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	var s3err *Error
+	if !errors.As(err, &s3err) {
+		s3err = ErrInternalError
+	}
+	if r.Method == http.MethodHead {
+		w.WriteHeader(s3err.Status)
+		return
+	}
+	writeXML(w, s3err.Status, errorResponse{Code: s3err.Code, Message: s3err.Message, Resource: r.URL.Path})
+}
+
+// writeXML writes an XML response
+func writeXML(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(xml.Header))
+	_ = xml.NewEncoder(w).Encode(v)
+}
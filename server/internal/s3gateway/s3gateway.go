@@ -0,0 +1,553 @@
+// Package s3gateway serves the gallery storage over a subset of the S3 API,
+// so photo tools that only speak S3 can index the library, whatever the
+// storage backend is.
+//
+// The storage is one bucket, addressed path-style. Requests are signed with
+// AWS Signature Version 4, see Verify, using access keys of users. Reads
+// cover listing buckets and objects, heads and ranged gets. Writes, when
+// enabled, cover single part uploads, folder markers and deletes, and go
+// through a Gallery keeping tags, albums and other records in step.
+package s3gateway
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxKeys is the most objects a listing returns
+	maxKeys = 1000
+	// maxObjectSize is the largest single part upload, as on S3
+	maxObjectSize = 5 << 30
+	// s3Namespace is the XML namespace of S3 responses
+	s3Namespace = "http://s3.amazonaws.com/doc/2006-03-01/"
+)
+
+// Gallery is the storage as seen by the session of a request. Names are
+// slash separated paths from the root the session sees, without leading
+// slash, the root being empty.
+type Gallery interface {
+	// Storage checks read access to name and returns the storage and key
+	// it is read at
+	Storage(ctx context.Context, name string) (storage.Storage, string, error)
+	// Writable checks write access to name
+	Writable(ctx context.Context, name string) error
+	// Put writes size bytes of content to the file name
+	Put(ctx context.Context, name string, content io.Reader, size int64) error
+	// Mkdir creates the folder name
+	Mkdir(ctx context.Context, name string) error
+	// Remove deletes the file or folder name with its contents
+	Remove(ctx context.Context, name string) error
+}
+
+// Handler serves the S3 API of a gallery
+type Handler struct {
+	gallery  Gallery
+	bucket   string
+	writable bool
+	logger   *zap.Logger
+}
+
+// NewHandler returns a handler serving gallery as bucket, read only unless
+// writable is set
+func NewHandler(gallery Gallery, bucket string, writable bool, logger *zap.Logger) *Handler {
+	return &Handler{gallery: gallery, bucket: bucket, writable: writable, logger: logger}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Whole libraries are copied, taking longer than the server timeouts
+	// meant for API requests
+	rc := http.NewResponseController(w)
+	_ = rc.SetReadDeadline(time.Time{})
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	if err := h.serve(w, r); err != nil {
+		var s3err *Error
+		if !errors.As(err, &s3err) {
+			h.logger.Warn("S3 gateway request failed",
+				zap.String("method", r.Method), zap.String("path", r.URL.Path), zap.Error(err))
+		}
+		WriteError(w, r, err)
+	}
+}
+
+// serve routes a request by bucket, key and subresource
+func (h *Handler) serve(w http.ResponseWriter, r *http.Request) error {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	query := r.URL.Query()
+	if bucket == "" {
+		if r.Method != http.MethodGet {
+			return ErrMethodNotAllowed
+		}
+		return h.listBuckets(w, r)
+	}
+	if bucket != h.bucket {
+		return ErrNoSuchBucket
+	}
+	// ACLs, tagging, multipart uploads and other subresources are not
+	// supported. AWS SDKs name operations in x-id.
+	for name := range query {
+		if !listParams[name] && !strings.HasPrefix(name, "X-Amz-") && !strings.HasPrefix(name, "response-") && name != "location" && name != "x-id" {
+			return ErrNotImplemented.withMessage("The " + name + " subresource is not supported")
+		}
+	}
+	if key == "" {
+		switch {
+		case r.Method == http.MethodHead:
+			_, _, err := h.gallery.Storage(r.Context(), "")
+			return accessError(err)
+		case r.Method == http.MethodGet && query.Has("location"):
+			writeXML(w, http.StatusOK, locationResponse{Namespace: s3Namespace})
+			return nil
+		case r.Method == http.MethodGet:
+			return h.listObjects(w, r)
+		case r.Method == http.MethodPut:
+			return ErrBucketAlreadyOwnedByYou
+		}
+		return ErrMethodNotAllowed
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		return h.getObject(w, r, key)
+	case http.MethodPut:
+		return h.putObject(w, r, key)
+	case http.MethodDelete:
+		return h.deleteObject(w, r, key)
+	}
+	return ErrMethodNotAllowed
+}
+
+// accessError reports a permission error of the gallery as AccessDenied
+func accessError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return ErrAccessDenied.withMessage(err.Error())
+}
+
+type bucketsResponse struct {
+	XMLName   xml.Name `xml:"ListAllMyBucketsResult"`
+	Namespace string   `xml:"xmlns,attr"`
+	Owner     owner    `xml:"Owner"`
+	Buckets   []bucket `xml:"Buckets>Bucket"`
+}
+
+type owner struct {
+	ID          string `xml:"ID"`
+	DisplayName string `xml:"DisplayName"`
+}
+
+type bucket struct {
+	Name         string `xml:"Name"`
+	CreationDate string `xml:"CreationDate"`
+}
+
+type locationResponse struct {
+	XMLName   xml.Name `xml:"LocationConstraint"`
+	Namespace string   `xml:"xmlns,attr"`
+}
+
+func (h *Handler) listBuckets(w http.ResponseWriter, r *http.Request) error {
+	if _, _, err := h.gallery.Storage(r.Context(), ""); err != nil {
+		return accessError(err)
+	}
+	writeXML(w, http.StatusOK, bucketsResponse{
+		Namespace: s3Namespace,
+		Buckets:   []bucket{{Name: h.bucket, CreationDate: time.Unix(0, 0).UTC().Format(time.RFC3339)}},
+	})
+	return nil
+}
+
+// stat returns the info of the file name
+func (h *Handler) stat(ctx context.Context, name string) (storage.Storage, storage.FileInfo, error) {
+	stor, key, err := h.gallery.Storage(ctx, name)
+	if err != nil {
+		return nil, storage.FileInfo{}, accessError(err)
+	}
+	info, err := stor.Stat(ctx, key)
+	if err != nil || info.IsDir {
+		return nil, storage.FileInfo{}, ErrNoSuchKey
+	}
+	info.Path = key
+	return stor, info, nil
+}
+
+func (h *Handler) getObject(w http.ResponseWriter, r *http.Request, name string) error {
+	stor, info, err := h.stat(r.Context(), name)
+	if err != nil {
+		return err
+	}
+	header := w.Header()
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	header.Set("Content-Type", contentType)
+	if info.ETag != "" {
+		header.Set("ETag", `"`+info.ETag+`"`)
+	}
+	// Presigned URLs may override response headers
+	for param, name := range responseHeaders {
+		if value := r.URL.Query().Get(param); value != "" {
+			header.Set(name, value)
+		}
+	}
+	content := storage.NewLazyReadSeeker(r.Context(), func(ctx context.Context) (io.ReadCloser, error) {
+		return stor.Get(ctx, info.Path)
+	}, info.Size)
+	defer content.Close()
+	http.ServeContent(w, r, "", info.ModifiedTime, content)
+	return nil
+}
+
+// responseHeaders are the response headers presigned URLs may override by
+// query parameter
+var responseHeaders = map[string]string{
+	"response-content-type":        "Content-Type",
+	"response-content-language":    "Content-Language",
+	"response-expires":             "Expires",
+	"response-cache-control":       "Cache-Control",
+	"response-content-disposition": "Content-Disposition",
+	"response-content-encoding":    "Content-Encoding",
+}
+
+// checkWritable checks that name can be written
+func (h *Handler) checkWritable(ctx context.Context, name string) error {
+	if !h.writable {
+		return ErrAccessDenied.withMessage("The S3 gateway is read only")
+	}
+	return accessError(h.gallery.Writable(ctx, name))
+}
+
+func (h *Handler) putObject(w http.ResponseWriter, r *http.Request, name string) error {
+	if r.Header.Get("X-Amz-Copy-Source") != "" {
+		return ErrNotImplemented.withMessage("Copying objects is not supported")
+	}
+	if err := h.checkWritable(r.Context(), name); err != nil {
+		return err
+	}
+	if r.ContentLength > maxObjectSize {
+		return ErrEntityTooLarge
+	}
+	// Keys ending with a slash are folder markers
+	if strings.HasSuffix(name, "/") {
+		if _, err := io.Copy(io.Discard, r.Body); err != nil {
+			return err
+		}
+		if err := h.gallery.Mkdir(r.Context(), strings.TrimSuffix(name, "/")); err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}
+	// The storage needs the size up front, unknown for streaming uploads
+	// and not trusted until the content is verified
+	tmp, err := os.CreateTemp("", "imagor-studio-s3-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	size, err := io.Copy(tmp, io.LimitReader(r.Body, maxObjectSize+1))
+	if err != nil {
+		return err
+	}
+	if size > maxObjectSize {
+		return ErrEntityTooLarge
+	}
+	if r.ContentLength >= 0 && size != r.ContentLength {
+		return ErrIncompleteBody
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := h.gallery.Put(r.Context(), name, tmp, size); err != nil {
+		return err
+	}
+	if _, info, err := h.stat(r.Context(), name); err == nil && info.ETag != "" {
+		w.Header().Set("ETag", `"`+info.ETag+`"`)
+	}
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (h *Handler) deleteObject(w http.ResponseWriter, r *http.Request, name string) error {
+	if err := h.checkWritable(r.Context(), name); err != nil {
+		return err
+	}
+	folder := strings.HasSuffix(name, "/")
+	name = strings.TrimSuffix(name, "/")
+	stor, key, err := h.gallery.Storage(r.Context(), name)
+	if err != nil {
+		return accessError(err)
+	}
+	info, err := stor.Stat(r.Context(), key)
+	// Deleting a missing object succeeds, and deleting a folder marker only
+	// removes empty folders, as folders are implied by their objects on S3
+	if err == nil && info.IsDir == folder {
+		if folder {
+			result, err := stor.List(r.Context(), key, storage.ListOptions{Limit: 1, ShowHidden: true, SkipMetadata: true})
+			if err != nil || len(result.Items) > 0 {
+				w.WriteHeader(http.StatusNoContent)
+				return nil
+			}
+		}
+		if err := h.gallery.Remove(r.Context(), name); err != nil {
+			return err
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// listParams are the query parameters of ListObjects and ListObjectsV2
+var listParams = map[string]bool{
+	"prefix": true, "delimiter": true, "max-keys": true, "encoding-type": true,
+	"marker": true, "list-type": true, "continuation-token": true, "start-after": true,
+	"fetch-owner": true,
+}
+
+type listResponse struct {
+	XMLName        xml.Name       `xml:"ListBucketResult"`
+	Namespace      string         `xml:"xmlns,attr"`
+	Name           string         `xml:"Name"`
+	Prefix         string         `xml:"Prefix"`
+	Delimiter      string         `xml:"Delimiter,omitempty"`
+	MaxKeys        int            `xml:"MaxKeys"`
+	EncodingType   string         `xml:"EncodingType,omitempty"`
+	IsTruncated    bool           `xml:"IsTruncated"`
+	Contents       []object       `xml:"Contents"`
+	CommonPrefixes []commonPrefix `xml:"CommonPrefixes"`
+
+	// ListObjects
+	Marker     *string `xml:"Marker"`
+	NextMarker string  `xml:"NextMarker,omitempty"`
+
+	// ListObjectsV2
+	KeyCount              *int   `xml:"KeyCount"`
+	ContinuationToken     string `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string `xml:"NextContinuationToken,omitempty"`
+	StartAfter            string `xml:"StartAfter,omitempty"`
+}
+
+type object struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag,omitempty"`
+	Size         int64  `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type commonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+func (h *Handler) listObjects(w http.ResponseWriter, r *http.Request) error {
+	query := r.URL.Query()
+	l := &listing{
+		ctx:       r.Context(),
+		gallery:   h.gallery,
+		prefix:    query.Get("prefix"),
+		delimiter: query.Get("delimiter"),
+		max:       maxKeys,
+	}
+	if l.delimiter != "" && l.delimiter != "/" {
+		return ErrNotImplemented.withMessage("Only / is supported as delimiter")
+	}
+	if value := query.Get("max-keys"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return ErrInvalidArgument.withMessage("max-keys must be a non-negative integer")
+		}
+		l.max = min(n, maxKeys)
+	}
+	encodingType := query.Get("encoding-type")
+	if encodingType != "" && encodingType != "url" {
+		return ErrInvalidArgument.withMessage("Invalid encoding-type: " + encodingType)
+	}
+	v2 := query.Get("list-type") == "2"
+	if v2 {
+		l.after = query.Get("start-after")
+		if token := query.Get("continuation-token"); token != "" {
+			l.after = token
+		}
+	} else {
+		l.after = query.Get("marker")
+	}
+	if err := l.run(); err != nil {
+		return err
+	}
+
+	encode := func(s string) string { return s }
+	if encodingType == "url" {
+		encode = func(s string) string { return uriEncode(s, false) }
+	}
+	result := listResponse{
+		Namespace:    s3Namespace,
+		Name:         h.bucket,
+		Prefix:       encode(l.prefix),
+		Delimiter:    encode(l.delimiter),
+		MaxKeys:      l.max,
+		EncodingType: encodingType,
+		IsTruncated:  l.truncated,
+	}
+	for _, info := range l.objects {
+		obj := object{
+			Key:          encode(info.Path),
+			LastModified: info.ModifiedTime.UTC().Format("2006-01-02T15:04:05.000Z"),
+			Size:         info.Size,
+			StorageClass: "STANDARD",
+		}
+		if info.ETag != "" {
+			obj.ETag = `"` + info.ETag + `"`
+		}
+		result.Contents = append(result.Contents, obj)
+	}
+	for _, prefix := range l.prefixes {
+		result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: encode(prefix)})
+	}
+	if v2 {
+		keyCount := len(l.objects) + len(l.prefixes)
+		result.KeyCount = &keyCount
+		result.ContinuationToken = query.Get("continuation-token")
+		result.StartAfter = encode(query.Get("start-after"))
+		if l.truncated {
+			result.NextContinuationToken = l.last
+		}
+	} else {
+		marker := encode(query.Get("marker"))
+		result.Marker = &marker
+		if l.truncated {
+			result.NextMarker = encode(l.last)
+		}
+	}
+	writeXML(w, http.StatusOK, result)
+	return nil
+}
+
+// listing walks the gallery in key order for a page of objects and, with
+// a delimiter, common prefixes of the keys after a given one
+type listing struct {
+	ctx       context.Context
+	gallery   Gallery
+	prefix    string
+	delimiter string
+	after     string
+	max       int
+
+	// objects have their key as Path
+	objects   []storage.FileInfo
+	prefixes  []string
+	truncated bool
+	// last is the last key or common prefix listed
+	last string
+}
+
+// errPageFull stops the walk once a page is full
+var errPageFull = errors.New("page full")
+
+func (l *listing) run() error {
+	if l.max == 0 {
+		return nil
+	}
+	// Start in the folder of the prefix
+	folder := ""
+	if i := strings.LastIndex(l.prefix, "/"); i >= 0 {
+		folder = l.prefix[:i]
+	}
+	err := l.walk(folder)
+	if errors.Is(err, errPageFull) {
+		return nil
+	}
+	return err
+}
+
+// add lists a key or common prefix, failing with errPageFull when the page
+// is full
+func (l *listing) add(key string, info *storage.FileInfo) error {
+	if len(l.objects)+len(l.prefixes) == l.max {
+		l.truncated = true
+		return errPageFull
+	}
+	if info != nil {
+		info.Path = key
+		l.objects = append(l.objects, *info)
+	} else {
+		l.prefixes = append(l.prefixes, key)
+	}
+	l.last = key
+	return nil
+}
+
+// walk lists the keys of the folder name, its subfolders included unless
+// they are common prefixes
+func (l *listing) walk(name string) error {
+	stor, key, err := l.gallery.Storage(l.ctx, name)
+	if err != nil {
+		return accessError(err)
+	}
+	result, err := stor.List(l.ctx, key, storage.ListOptions{})
+	if err != nil {
+		// Missing folders have no keys
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	// Keys of subfolders sort as if they ended with a slash
+	entries := result.Items
+	sortKey := func(info storage.FileInfo) string {
+		if info.IsDir {
+			return info.Name + "/"
+		}
+		return info.Name
+	}
+	sort.Slice(entries, func(i, j int) bool { return sortKey(entries[i]) < sortKey(entries[j]) })
+
+	base := ""
+	if name != "" {
+		base = name + "/"
+	}
+	for i := range entries {
+		entry := &entries[i]
+		entryKey := base + sortKey(*entry)
+		if !entry.IsDir {
+			if entryKey > l.after && strings.HasPrefix(entryKey, l.prefix) {
+				if err := l.add(entryKey, entry); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		switch {
+		case strings.HasPrefix(entryKey, l.prefix) && l.delimiter != "":
+			if entryKey > l.after {
+				if err := l.add(entryKey, nil); err != nil {
+					return err
+				}
+			}
+		case strings.HasPrefix(entryKey, l.prefix) || strings.HasPrefix(l.prefix, entryKey):
+			// Skip folders whose keys all sort up to the last one listed
+			if entryKey <= l.after && !strings.HasPrefix(l.after, entryKey) {
+				continue
+			}
+			if err := l.walk(base + entry.Name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
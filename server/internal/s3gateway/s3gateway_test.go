@@ -0,0 +1,292 @@
+package s3gateway
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const (
+	testAccessKeyID = "ISAKTESTTESTTESTTEST"
+	testSecret      = "test-secret"
+)
+
+// testGallery serves a file storage, read only below "locked"
+type testGallery struct {
+	stor storage.Storage
+}
+
+func (g *testGallery) Storage(_ context.Context, name string) (storage.Storage, string, error) {
+	return g.stor, name, nil
+}
+
+func (g *testGallery) Writable(_ context.Context, name string) error {
+	if strings.HasPrefix(name, "locked") {
+		return errors.New("insufficient permission")
+	}
+	return nil
+}
+
+func (g *testGallery) Put(ctx context.Context, name string, content io.Reader, _ int64) error {
+	return g.stor.Put(ctx, name, content)
+}
+
+func (g *testGallery) Mkdir(ctx context.Context, name string) error {
+	return g.stor.CreateFolder(ctx, name)
+}
+
+func (g *testGallery) Remove(ctx context.Context, name string) error {
+	return g.stor.Delete(ctx, name)
+}
+
+// newTestServer serves the S3 gateway of a file storage at /s3
+func newTestServer(t *testing.T, writable bool) (*httptest.Server, string) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"a.jpg":            "0123456789",
+		"album/b.jpg":      "b",
+		"album/c.jpg":      "c",
+		"album/.hidden":    "x",
+		"album/2024/d.jpg": "d",
+		"album.jpg":        "e",
+		"locked/f.jpg":     "f",
+	} {
+		full := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(full), 0755))
+		require.NoError(t, os.WriteFile(full, []byte(content), 0644))
+	}
+	stor, err := filestorage.New(dir)
+	require.NoError(t, err)
+	handler := http.StripPrefix("/s3", NewHandler(&testGallery{stor: stor}, "gallery", writable, zap.NewNop()))
+	// Over TLS, as AWS SDKs stream uploads with trailing checksums then
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := Verify(r, func(accessKeyID string) (string, error) {
+			if accessKeyID != testAccessKeyID {
+				return "", ErrInvalidAccessKeyID
+			}
+			return testSecret, nil
+		})
+		if err != nil {
+			WriteError(w, r, err)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(ts.Close)
+	return ts, dir
+}
+
+func newTestClient(ts *httptest.Server, secret string) *s3.Client {
+	return s3.New(s3.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(ts.URL + "/s3"),
+		UsePathStyle: true,
+		Credentials:  credentials.NewStaticCredentialsProvider(testAccessKeyID, secret, ""),
+		HTTPClient:   ts.Client(),
+	})
+}
+
+func errorCode(err error) string {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrorCode()
+	}
+	return ""
+}
+
+func TestGateway_Read(t *testing.T) {
+	ts, _ := newTestServer(t, false)
+	client := newTestClient(ts, testSecret)
+	ctx := context.Background()
+
+	buckets, err := client.ListBuckets(ctx, &s3.ListBucketsInput{})
+	require.NoError(t, err)
+	require.Len(t, buckets.Buckets, 1)
+	assert.Equal(t, "gallery", aws.ToString(buckets.Buckets[0].Name))
+
+	// Keys are listed in order across pages, hidden files left out
+	var keys []string
+	paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{Bucket: aws.String("gallery"), MaxKeys: aws.Int32(2)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		require.NoError(t, err)
+		for _, obj := range page.Contents {
+			keys = append(keys, aws.ToString(obj.Key))
+		}
+	}
+	assert.Equal(t, []string{"a.jpg", "album.jpg", "album/2024/d.jpg", "album/b.jpg", "album/c.jpg", "locked/f.jpg"}, keys)
+
+	list, err := client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:    aws.String("gallery"),
+		Prefix:    aws.String("album"),
+		Delimiter: aws.String("/"),
+	})
+	require.NoError(t, err)
+	require.Len(t, list.Contents, 1)
+	assert.Equal(t, "album.jpg", aws.ToString(list.Contents[0].Key))
+	assert.Equal(t, int64(1), aws.ToInt64(list.Contents[0].Size))
+	require.Len(t, list.CommonPrefixes, 1)
+	assert.Equal(t, "album/", aws.ToString(list.CommonPrefixes[0].Prefix))
+
+	list, err = client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:     aws.String("gallery"),
+		Prefix:     aws.String("album/"),
+		Delimiter:  aws.String("/"),
+		StartAfter: aws.String("album/2024/d.jpg"),
+	})
+	require.NoError(t, err)
+	require.Len(t, list.Contents, 2)
+	assert.Equal(t, "album/b.jpg", aws.ToString(list.Contents[0].Key))
+	assert.Empty(t, list.CommonPrefixes)
+
+	v1, err := client.ListObjects(ctx, &s3.ListObjectsInput{Bucket: aws.String("gallery"), Prefix: aws.String("album/2")})
+	require.NoError(t, err)
+	require.Len(t, v1.Contents, 1)
+	assert.Equal(t, "album/2024/d.jpg", aws.ToString(v1.Contents[0].Key))
+
+	obj, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String("gallery"), Key: aws.String("a.jpg"), Range: aws.String("bytes=2-4")})
+	require.NoError(t, err)
+	data, err := io.ReadAll(obj.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "234", string(data))
+	assert.Equal(t, "image/jpeg", aws.ToString(obj.ContentType))
+
+	_, err = client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String("gallery"), Key: aws.String("album")})
+	assert.Error(t, err, "folders are not objects")
+	_, err = client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String("gallery"), Key: aws.String("missing.jpg")})
+	var noSuchKey *types.NoSuchKey
+	assert.ErrorAs(t, err, &noSuchKey)
+	_, err = client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: aws.String("photos")})
+	assert.Equal(t, "NoSuchBucket", errorCode(err))
+
+	// Presigned URLs need no other credentials
+	presigned, err := s3.NewPresignClient(client).PresignGetObject(ctx, &s3.GetObjectInput{Bucket: aws.String("gallery"), Key: aws.String("album/b.jpg")})
+	require.NoError(t, err)
+	resp, err := ts.Client().Get(presigned.URL)
+	require.NoError(t, err)
+	data, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "b", string(data))
+	resp, err = ts.Client().Get(strings.Replace(presigned.URL, "b.jpg", "c.jpg", 1))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	_, err = client.PutObject(ctx, &s3.PutObjectInput{Bucket: aws.String("gallery"), Key: aws.String("new.jpg"), Body: strings.NewReader("new")})
+	assert.Equal(t, "AccessDenied", errorCode(err), "the gateway is read only")
+}
+
+func TestGateway_Unauthenticated(t *testing.T) {
+	ts, _ := newTestServer(t, true)
+	ctx := context.Background()
+
+	_, err := newTestClient(ts, "wrong-secret").ListObjectsV2(ctx, &s3.ListObjectsV2Input{Bucket: aws.String("gallery")})
+	assert.Equal(t, "SignatureDoesNotMatch", errorCode(err))
+
+	resp, err := ts.Client().Get(ts.URL + "/s3/gallery/a.jpg")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestGateway_Write(t *testing.T) {
+	ts, dir := newTestServer(t, true)
+	client := newTestClient(ts, testSecret)
+	ctx := context.Background()
+
+	_, err := client.PutObject(ctx, &s3.PutObjectInput{Bucket: aws.String("gallery"), Key: aws.String("trip/new.jpg"), Body: strings.NewReader("uploaded")})
+	require.NoError(t, err)
+	data, err := os.ReadFile(filepath.Join(dir, "trip", "new.jpg"))
+	require.NoError(t, err)
+	assert.Equal(t, "uploaded", string(data))
+
+	_, err = client.PutObject(ctx, &s3.PutObjectInput{Bucket: aws.String("gallery"), Key: aws.String("locked/new.jpg"), Body: strings.NewReader("denied")})
+	assert.Equal(t, "AccessDenied", errorCode(err))
+	assert.NoFileExists(t, filepath.Join(dir, "locked", "new.jpg"))
+
+	_, err = client.PutObject(ctx, &s3.PutObjectInput{Bucket: aws.String("gallery"), Key: aws.String("empty/"), Body: strings.NewReader("")})
+	require.NoError(t, err)
+	assert.DirExists(t, filepath.Join(dir, "empty"))
+
+	_, err = client.CopyObject(ctx, &s3.CopyObjectInput{Bucket: aws.String("gallery"), Key: aws.String("copy.jpg"), CopySource: aws.String("gallery/a.jpg")})
+	assert.Equal(t, "NotImplemented", errorCode(err))
+
+	_, err = client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String("gallery"), Key: aws.String("trip/new.jpg")})
+	require.NoError(t, err)
+	assert.NoFileExists(t, filepath.Join(dir, "trip", "new.jpg"))
+	// Folder markers only remove empty folders
+	_, err = client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String("gallery"), Key: aws.String("album/")})
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(dir, "album", "b.jpg"))
+	_, err = client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String("gallery"), Key: aws.String("empty/")})
+	require.NoError(t, err)
+	assert.NoDirExists(t, filepath.Join(dir, "empty"))
+	_, err = client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String("gallery"), Key: aws.String("missing.jpg")})
+	assert.NoError(t, err)
+}
+
+// TestChunkedReader_Signed decodes the example upload of the AWS Signature
+// Version 4 documentation on streaming uploads
+func TestChunkedReader_Signed(t *testing.T) {
+	date, _ := time.Parse(amzDateFormat, "20130524T000000Z")
+	scope := "20130524/us-east-1/s3/aws4_request"
+	body := func(signatures ...string) string {
+		return "10000;chunk-signature=" + signatures[0] + "\r\n" + strings.Repeat("a", 65536) + "\r\n" +
+			"400;chunk-signature=" + signatures[1] + "\r\n" + strings.Repeat("a", 1024) + "\r\n" +
+			"0;chunk-signature=" + signatures[2] + "\r\n\r\n"
+	}
+	signatures := []string{
+		"ad80c730a21e5b8d04586a2213dd63b9a0e99e0e2307b0ade35a65485a288648",
+		"0055627c9e194cb4542bae2aa5492e3c1575bbb81b612b7d234b86a503ef5497",
+		"b6c6ea8a5354eaf15b3cb7646744f4275b71ea724fed81ceb9323e279d449df9",
+	}
+	decode := func(encoded string) ([]byte, error) {
+		return io.ReadAll(&chunkedReader{
+			body:       io.NopCloser(nil),
+			r:          bufio.NewReader(strings.NewReader(encoded)),
+			signingKey: deriveSigningKey("wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY", scope),
+			prefix:     "AWS4-HMAC-SHA256-PAYLOAD\n" + date.Format(amzDateFormat) + "\n" + scope + "\n",
+			previous:   "4f232c4386841ef735655705268965c44a0e4690baa4adea153f7db9fa80a0a9",
+		})
+	}
+
+	data, err := decode(body(signatures...))
+	require.NoError(t, err)
+	assert.Equal(t, strings.Repeat("a", 66560), string(data))
+
+	_, err = decode(body(signatures[1], signatures[0], signatures[2]))
+	assert.ErrorIs(t, err, ErrSignatureDoesNotMatch)
+	_, err = decode(body(signatures...)[:1000])
+	assert.ErrorIs(t, err, ErrIncompleteBody)
+}
+
+func TestChunkedReader_UnsignedTrailer(t *testing.T) {
+	data, err := io.ReadAll(&chunkedReader{
+		body:    io.NopCloser(nil),
+		r:       bufio.NewReader(strings.NewReader("5\r\nhello\r\n6\r\n world\r\n0\r\nx-amz-checksum-crc32:DUoRhQ==\r\n\r\n")),
+		trailer: true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", string(data))
+}
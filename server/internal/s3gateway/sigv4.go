@@ -0,0 +1,388 @@
+package s3gateway
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	signingAlgorithm = "AWS4-HMAC-SHA256"
+	amzDateFormat    = "20060102T150405Z"
+	// maxClockSkew is how far the time of a signed request may be from now
+	maxClockSkew = 15 * time.Minute
+	// maxPresignedExpires is the longest validity of presigned URLs
+	maxPresignedExpires = 7 * 24 * time.Hour
+	// maxChunkSize bounds the chunks of streaming uploads held in memory
+	maxChunkSize = 16 << 20
+
+	unsignedPayload          = "UNSIGNED-PAYLOAD"
+	streamingPayload         = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	streamingUnsignedTrailer = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+)
+
+// emptySHA256 is the hex SHA-256 of no content
+var emptySHA256 = hexSHA256(nil)
+
+// SecretFunc returns the secret of an access key, ErrInvalidAccessKeyID
+// for unknown keys
+type SecretFunc func(accessKeyID string) (string, error)
+
+// signature is the AWS Signature Version 4 of a request
+type signature struct {
+	accessKeyID   string
+	date          time.Time
+	scope         string
+	signedHeaders []string
+	signature     string
+	payloadHash   string
+	// expires is the validity of presigned URLs, zero otherwise
+	expires time.Duration
+}
+
+// Verify checks the AWS Signature Version 4 of r, signed in the
+// Authorization header or presigned in the query, with the secret of the
+// access key it names. The body of r is replaced by one that fails to read
+// on content not matching the signed payload, decoded from the aws-chunked
+// encoding of streaming uploads.
+func Verify(r *http.Request, secret SecretFunc) error {
+	sig, err := parseSignature(r)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if sig.expires > 0 {
+		if now.Before(sig.date.Add(-maxClockSkew)) {
+			return ErrRequestTimeTooSkewed
+		}
+		if now.After(sig.date.Add(sig.expires)) {
+			return ErrAccessDenied.withMessage("Request has expired")
+		}
+	} else if d := now.Sub(sig.date); d > maxClockSkew || d < -maxClockSkew {
+		return ErrRequestTimeTooSkewed
+	}
+	key, err := secret(sig.accessKeyID)
+	if err != nil {
+		return err
+	}
+	signingKey := deriveSigningKey(key, sig.scope)
+	stringToSign := strings.Join([]string{
+		signingAlgorithm,
+		sig.date.Format(amzDateFormat),
+		sig.scope,
+		hexSHA256([]byte(canonicalRequest(r, sig))),
+	}, "\n")
+	expected := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+	if !hmac.Equal([]byte(expected), []byte(sig.signature)) {
+		return ErrSignatureDoesNotMatch
+	}
+
+	switch sig.payloadHash {
+	case unsignedPayload:
+	case streamingPayload, streamingUnsignedTrailer:
+		decoded := &chunkedReader{body: r.Body, r: bufio.NewReader(r.Body), trailer: sig.payloadHash == streamingUnsignedTrailer}
+		if !decoded.trailer {
+			decoded.signingKey = signingKey
+			decoded.prefix = "AWS4-HMAC-SHA256-PAYLOAD\n" + sig.date.Format(amzDateFormat) + "\n" + sig.scope + "\n"
+			decoded.previous = sig.signature
+		}
+		r.Body = decoded
+		r.ContentLength = -1
+		if length, err := strconv.ParseInt(r.Header.Get("X-Amz-Decoded-Content-Length"), 10, 64); err == nil {
+			r.ContentLength = length
+		}
+	default:
+		if _, err := hex.DecodeString(sig.payloadHash); err != nil || len(sig.payloadHash) != sha256.Size*2 {
+			return ErrNotImplemented.withMessage("Unsupported x-amz-content-sha256: " + sig.payloadHash)
+		}
+		r.Body = &hashVerifier{body: r.Body, hash: sha256.New(), expected: sig.payloadHash}
+	}
+	return nil
+}
+
+// parseSignature reads the signature of the Authorization header, or the
+// query of presigned URLs
+func parseSignature(r *http.Request) (*signature, error) {
+	query := r.URL.Query()
+	sig := &signature{}
+	var credential, signedHeaders, date string
+	if authorization := r.Header.Get("Authorization"); authorization != "" {
+		fields, ok := strings.CutPrefix(authorization, signingAlgorithm+" ")
+		if !ok {
+			return nil, ErrAuthorizationMalformed.withMessage("Only " + signingAlgorithm + " signatures are supported")
+		}
+		for _, field := range strings.Split(fields, ",") {
+			name, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+			switch name {
+			case "Credential":
+				credential = value
+			case "SignedHeaders":
+				signedHeaders = value
+			case "Signature":
+				sig.signature = value
+			}
+		}
+		date = r.Header.Get("X-Amz-Date")
+		if date == "" {
+			if t, err := http.ParseTime(r.Header.Get("Date")); err == nil {
+				date = t.UTC().Format(amzDateFormat)
+			}
+		}
+		sig.payloadHash = r.Header.Get("X-Amz-Content-Sha256")
+		if sig.payloadHash == "" {
+			return nil, ErrMissingSecurityHeader.withMessage("Missing required header for this request: x-amz-content-sha256")
+		}
+	} else if query.Has("X-Amz-Signature") {
+		if query.Get("X-Amz-Algorithm") != signingAlgorithm {
+			return nil, ErrAuthorizationMalformed.withMessage("Only " + signingAlgorithm + " signatures are supported")
+		}
+		credential = query.Get("X-Amz-Credential")
+		signedHeaders = query.Get("X-Amz-SignedHeaders")
+		sig.signature = query.Get("X-Amz-Signature")
+		date = query.Get("X-Amz-Date")
+		expires, err := strconv.Atoi(query.Get("X-Amz-Expires"))
+		if err != nil || expires < 1 || time.Duration(expires)*time.Second > maxPresignedExpires {
+			return nil, ErrInvalidPresignedExpiresIn
+		}
+		sig.expires = time.Duration(expires) * time.Second
+		sig.payloadHash = unsignedPayload
+	} else {
+		return nil, ErrAccessDenied.withMessage("Anonymous access is not allowed, sign requests with an access key")
+	}
+
+	// Credential is <access key ID>/<date>/<region>/s3/aws4_request
+	parts := strings.Split(credential, "/")
+	if len(parts) != 5 || parts[0] == "" || parts[3] != "s3" || parts[4] != "aws4_request" {
+		return nil, ErrAuthorizationMalformed.withMessage("The credential is malformed, expected <key>/<date>/<region>/s3/aws4_request")
+	}
+	t, err := time.Parse(amzDateFormat, date)
+	if err != nil {
+		return nil, ErrAccessDenied.withMessage("AWS authentication requires a valid Date or x-amz-date header")
+	}
+	if parts[1] != t.Format("20060102") {
+		return nil, ErrAuthorizationMalformed.withMessage("The credential date does not match the request date")
+	}
+	sig.accessKeyID = parts[0]
+	sig.scope = strings.Join(parts[1:], "/")
+	sig.date = t
+	sig.signedHeaders = strings.Split(signedHeaders, ";")
+	if sig.signature == "" || !sort.StringsAreSorted(sig.signedHeaders) {
+		return nil, ErrAuthorizationMalformed
+	}
+	hasHost := false
+	for _, name := range sig.signedHeaders {
+		hasHost = hasHost || name == "host"
+	}
+	if !hasHost {
+		return nil, ErrAccessDenied.withMessage("The host header must be signed")
+	}
+	return sig, nil
+}
+
+// canonicalRequest returns the canonical form of r that is signed
+func canonicalRequest(r *http.Request, sig *signature) string {
+	var b strings.Builder
+	b.WriteString(r.Method)
+	b.WriteByte('\n')
+	path := r.URL.Path
+	if path == "" {
+		path = "/"
+	}
+	b.WriteString(uriEncode(path, false))
+	b.WriteByte('\n')
+	b.WriteString(canonicalQuery(r.URL.Query()))
+	b.WriteByte('\n')
+	for _, name := range sig.signedHeaders {
+		value := r.Host
+		if name != "host" {
+			value = strings.Join(r.Header.Values(name), ",")
+		}
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(strings.Join(strings.Fields(value), " "))
+		b.WriteByte('\n')
+	}
+	b.WriteByte('\n')
+	b.WriteString(strings.Join(sig.signedHeaders, ";"))
+	b.WriteByte('\n')
+	b.WriteString(sig.payloadHash)
+	return b.String()
+}
+
+// canonicalQuery returns the query sorted and encoded as signed, without
+// the signature of presigned URLs
+func canonicalQuery(query url.Values) string {
+	params := make([]string, 0, len(query))
+	for name, values := range query {
+		if name == "X-Amz-Signature" {
+			continue
+		}
+		for _, value := range values {
+			params = append(params, uriEncode(name, true)+"="+uriEncode(value, true))
+		}
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+// uriEncode percent-encodes s as AWS does, keeping unreserved characters
+// and, unless encodeSlash is set, slashes
+func uriEncode(s string, encodeSlash bool) string {
+	const hexDigits = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			b.WriteByte('%')
+			b.WriteByte(hexDigits[c>>4])
+			b.WriteByte(hexDigits[c&15])
+		}
+	}
+	return b.String()
+}
+
+// deriveSigningKey returns the key signing requests of scope
+func deriveSigningKey(secret, scope string) []byte {
+	key := []byte("AWS4" + secret)
+	for _, part := range strings.Split(scope, "/") {
+		key = hmacSHA256(key, part)
+	}
+	return key
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hashVerifier fails the last read of a body not matching its signed
+// SHA-256
+type hashVerifier struct {
+	body     io.ReadCloser
+	hash     hash.Hash
+	expected string
+}
+
+func (v *hashVerifier) Read(p []byte) (int, error) {
+	n, err := v.body.Read(p)
+	v.hash.Write(p[:n])
+	if errors.Is(err, io.EOF) && hex.EncodeToString(v.hash.Sum(nil)) != v.expected {
+		return n, ErrContentSHA256Mismatch
+	}
+	return n, err
+}
+
+func (v *hashVerifier) Close() error {
+	return v.body.Close()
+}
+
+// chunkedReader decodes the aws-chunked encoding of streaming uploads,
+// verifying the signature of every chunk unless it is of an unsigned
+// upload with trailing checksums, which are left unchecked
+type chunkedReader struct {
+	body    io.Closer
+	r       *bufio.Reader
+	trailer bool
+	// signingKey, prefix and previous sign chunks of signed uploads
+	signingKey []byte
+	prefix     string
+	previous   string
+
+	chunk []byte
+	done  bool
+	err   error
+}
+
+func (c *chunkedReader) Read(p []byte) (int, error) {
+	for len(c.chunk) == 0 {
+		if c.err != nil {
+			return 0, c.err
+		}
+		if c.done {
+			return 0, io.EOF
+		}
+		c.err = c.next()
+	}
+	n := copy(p, c.chunk)
+	c.chunk = c.chunk[n:]
+	return n, nil
+}
+
+// next reads the next chunk
+func (c *chunkedReader) next() error {
+	line, err := c.readLine()
+	if err != nil {
+		return err
+	}
+	sizeField, extension, _ := strings.Cut(line, ";")
+	size, err := strconv.ParseInt(strings.TrimSpace(sizeField), 16, 64)
+	if err != nil || size < 0 || size > maxChunkSize {
+		return ErrIncompleteBody.withMessage("Malformed aws-chunked encoding")
+	}
+	chunk := make([]byte, size)
+	if _, err := io.ReadFull(c.r, chunk); err != nil {
+		return ErrIncompleteBody
+	}
+	if c.signingKey != nil {
+		chunkSignature, ok := strings.CutPrefix(extension, "chunk-signature=")
+		expected := hex.EncodeToString(hmacSHA256(c.signingKey, c.prefix+c.previous+"\n"+emptySHA256+"\n"+hexSHA256(chunk)))
+		if !ok || !hmac.Equal([]byte(expected), []byte(chunkSignature)) {
+			return ErrSignatureDoesNotMatch
+		}
+		c.previous = expected
+	}
+	if size == 0 {
+		// The last chunk is followed by trailing headers, if any, and an
+		// empty line
+		for {
+			line, err := c.readLine()
+			if err != nil {
+				return err
+			}
+			if line == "" {
+				break
+			}
+		}
+		c.done = true
+		return nil
+	}
+	if line, err := c.readLine(); err != nil || line != "" {
+		return ErrIncompleteBody.withMessage("Malformed aws-chunked encoding")
+	}
+	c.chunk = chunk
+	return nil
+}
+
+// readLine reads a CRLF terminated line
+func (c *chunkedReader) readLine() (string, error) {
+	line, err := c.r.ReadSlice('\n')
+	if err != nil {
+		return "", ErrIncompleteBody
+	}
+	return string(bytes.TrimRight(line, "\r\n")), nil
+}
+
+func (c *chunkedReader) Close() error {
+	return c.body.Close()
+}
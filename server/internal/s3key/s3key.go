@@ -0,0 +1,240 @@
+// Package s3key persists the access keys users sign requests to the S3
+// gateway with, as S3 clients do with AWS access keys.
+//
+// Unlike API tokens, the secret of a key is stored as is, as verifying an
+// AWS Signature Version 4 takes the secret rather than a hash of it. The
+// secret is still only returned when the key is created. A key session acts
+// as its user with the role the user has when the request is made, limited
+// to the scopes granted to the key.
+package s3key
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cshum/imagor-studio/server/internal/model"
+	"github.com/cshum/imagor-studio/server/pkg/auth"
+	"github.com/uptrace/bun"
+	"go.uber.org/zap"
+)
+
+const (
+	// IDPrefix starts every access key ID
+	IDPrefix = "ISAK"
+	// MaxKeysPerUser caps the keys a user can hold at once
+	MaxKeysPerUser = 20
+	// MaxNameLength is the longest key name in characters
+	MaxNameLength = 100
+
+	// lastUsedInterval throttles last use updates to one per interval
+	lastUsedInterval = time.Minute
+)
+
+// Scopes lists the scopes a key can be granted
+var Scopes = []string{"read", "write"}
+
+var (
+	ErrNotFound = errors.New("S3 access key not found")
+	ErrInvalid  = errors.New("invalid S3 access key")
+	// ErrUnauthorized is returned by Authenticate for unknown and revoked
+	// keys and keys of deactivated users
+	ErrUnauthorized = errors.New("invalid or revoked S3 access key")
+)
+
+// AccessKey is an S3 access key, without its secret
+type AccessKey struct {
+	// ID is the access key ID
+	ID         string
+	UserID     string
+	Name       string
+	Scopes     []string
+	LastUsedAt *time.Time
+	CreatedAt  time.Time
+}
+
+type Store interface {
+	// Create issues a key for a user, returning it with its secret. orgID is
+	// the organization of the session creating it, empty when self-hosted.
+	Create(ctx context.Context, userID, orgID, name string, scopes []string) (*AccessKey, string, error)
+	// List returns the keys of a user, newest first
+	List(ctx context.Context, userID string) ([]*AccessKey, error)
+	// Revoke deletes a key of a user
+	Revoke(ctx context.Context, userID, id string) error
+	// Authenticate returns the secret of a key and the session claims of
+	// requests it signs
+	Authenticate(ctx context.Context, id string) (string, *auth.Claims, error)
+}
+
+type store struct {
+	db     *bun.DB
+	logger *zap.Logger
+}
+
+func New(db *bun.DB, logger *zap.Logger) Store {
+	return &store{db: db, logger: logger}
+}
+
+// generateKey returns an access key ID of 20 characters as AWS ones, and a
+// secret of 40
+func generateKey() (string, string, error) {
+	buf := make([]byte, 40)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", fmt.Errorf("generate S3 access key: %w", err)
+	}
+	id := IDPrefix + base32.StdEncoding.EncodeToString(buf[:10])
+	return id, base64.RawURLEncoding.EncodeToString(buf[10:]), nil
+}
+
+// normalizeScopes validates and de-duplicates scopes, keeping the order of
+// Scopes
+func normalizeScopes(scopes []string) ([]string, error) {
+	requested := make(map[string]bool, len(scopes))
+	for _, scope := range scopes {
+		requested[strings.TrimSpace(scope)] = true
+	}
+	normalized := make([]string, 0, len(requested))
+	for _, scope := range Scopes {
+		if requested[scope] {
+			normalized = append(normalized, scope)
+			delete(requested, scope)
+		}
+	}
+	for scope := range requested {
+		return nil, fmt.Errorf("%w: unknown scope %q", ErrInvalid, scope)
+	}
+	if len(normalized) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", ErrInvalid)
+	}
+	return normalized, nil
+}
+
+func (s *store) Create(ctx context.Context, userID, orgID, name string, scopes []string) (*AccessKey, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", fmt.Errorf("%w: name must not be empty", ErrInvalid)
+	}
+	if utf8.RuneCountInString(name) > MaxNameLength {
+		return nil, "", fmt.Errorf("%w: name must be at most %d characters", ErrInvalid, MaxNameLength)
+	}
+	scopes, err := normalizeScopes(scopes)
+	if err != nil {
+		return nil, "", err
+	}
+	count, err := s.db.NewSelect().Model((*model.S3AccessKey)(nil)).Where("user_id = ?", userID).Count(ctx)
+	if err != nil {
+		return nil, "", fmt.Errorf("error counting S3 access keys: %w", err)
+	}
+	if count >= MaxKeysPerUser {
+		return nil, "", fmt.Errorf("%w: at most %d keys per user, revoke unused ones", ErrInvalid, MaxKeysPerUser)
+	}
+	id, secret, err := generateKey()
+	if err != nil {
+		return nil, "", err
+	}
+	row := &model.S3AccessKey{
+		ID:        id,
+		UserID:    userID,
+		OrgID:     orgID,
+		Name:      name,
+		Secret:    secret,
+		Scopes:    strings.Join(scopes, ","),
+		CreatedAt: time.Now().UTC(),
+	}
+	if _, err := s.db.NewInsert().Model(row).Exec(ctx); err != nil {
+		return nil, "", fmt.Errorf("error creating S3 access key: %w", err)
+	}
+	return toAccessKey(row), secret, nil
+}
+
+func (s *store) List(ctx context.Context, userID string) ([]*AccessKey, error) {
+	var rows []model.S3AccessKey
+	if err := s.db.NewSelect().Model(&rows).
+		Where("user_id = ?", userID).
+		Order("created_at DESC", "id DESC").
+		Scan(ctx); err != nil {
+		return nil, fmt.Errorf("error listing S3 access keys: %w", err)
+	}
+	keys := make([]*AccessKey, 0, len(rows))
+	for i := range rows {
+		keys = append(keys, toAccessKey(&rows[i]))
+	}
+	return keys, nil
+}
+
+func (s *store) Revoke(ctx context.Context, userID, id string) error {
+	res, err := s.db.NewDelete().Model((*model.S3AccessKey)(nil)).
+		Where("id = ?", id).
+		Where("user_id = ?", userID).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("error revoking S3 access key: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *store) Authenticate(ctx context.Context, id string) (string, *auth.Claims, error) {
+	if !strings.HasPrefix(id, IDPrefix) {
+		return "", nil, ErrUnauthorized
+	}
+	var row model.S3AccessKey
+	if err := s.db.NewSelect().Model(&row).Where("id = ?", id).Scan(ctx); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil, ErrUnauthorized
+		}
+		return "", nil, fmt.Errorf("error authenticating S3 access key: %w", err)
+	}
+	var user model.User
+	if err := s.db.NewSelect().Model(&user).
+		Column("id", "role", "is_active").
+		Where("id = ?", row.UserID).
+		Scan(ctx); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil, ErrUnauthorized
+		}
+		return "", nil, fmt.Errorf("error authenticating S3 access key: %w", err)
+	}
+	if !user.IsActive || user.Role == "guest" {
+		return "", nil, ErrUnauthorized
+	}
+	now := time.Now().UTC()
+	if row.LastUsedAt == nil || now.Sub(*row.LastUsedAt) >= lastUsedInterval {
+		if _, err := s.db.NewUpdate().Model((*model.S3AccessKey)(nil)).
+			Set("last_used_at = ?", now).
+			Where("id = ?", row.ID).
+			Exec(ctx); err != nil {
+			s.logger.Warn("Failed to record S3 access key use", zap.String("id", row.ID), zap.Error(err))
+		}
+	}
+	claims := &auth.Claims{
+		UserID: row.UserID,
+		OrgID:  row.OrgID,
+		Role:   user.Role,
+		Scopes: strings.Split(row.Scopes, ","),
+		Kind:   auth.S3AccessKeyKind,
+	}
+	claims.Subject = row.UserID
+	claims.ID = row.ID
+	return row.Secret, claims, nil
+}
+
+func toAccessKey(row *model.S3AccessKey) *AccessKey {
+	return &AccessKey{
+		ID:         row.ID,
+		UserID:     row.UserID,
+		Name:       row.Name,
+		Scopes:     strings.Split(row.Scopes, ","),
+		LastUsedAt: row.LastUsedAt,
+		CreatedAt:  row.CreatedAt,
+	}
+}
@@ -0,0 +1,75 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr string
+	}{
+		{expr: "* * * * *"},
+		{expr: "*/15 0-6 1,15 jan-jun mon-fri"},
+		{expr: "30 2 * * 7"},
+		{expr: "@daily"},
+		{expr: "@Weekly"},
+		{expr: "5/10 * * * *"},
+		{expr: "", wantErr: "must have 5 fields"},
+		{expr: "* * * *", wantErr: "must have 5 fields"},
+		{expr: "60 * * * *", wantErr: "minute: value 60 out of range 0-59"},
+		{expr: "* 24 * * *", wantErr: "hour"},
+		{expr: "* * 0 * *", wantErr: "day of month"},
+		{expr: "* * * foo *", wantErr: "month: invalid value"},
+		{expr: "* * * * 8", wantErr: "day of week"},
+		{expr: "*/0 * * * *", wantErr: "invalid step"},
+		{expr: "10-5 * * * *", wantErr: "invalid range"},
+		{expr: "1,,2 * * * *", wantErr: "invalid value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := Parse(tt.expr)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	// A Wednesday
+	from := time.Date(2026, time.January, 14, 10, 20, 30, 0, time.UTC)
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2026, time.January, 14, 10, 21, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, time.January, 14, 10, 30, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2026, time.January, 15, 3, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, time.January, 14, 11, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2026, time.January, 18, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2026, time.January, 18, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2026, time.February, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 * jun *", time.Date(2026, time.June, 1, 12, 0, 0, 0, time.UTC)},
+		{"0 9 * * mon-fri", time.Date(2026, time.January, 15, 9, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		// Either the 1st or a Friday when both days are restricted
+		{"0 0 1 * fri", time.Date(2026, time.January, 16, 0, 0, 0, 0, time.UTC)},
+		// Never matches
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			s, err := Parse(tt.expr)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, s.Next(from))
+		})
+	}
+}
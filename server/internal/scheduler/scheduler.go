@@ -0,0 +1,148 @@
+// Package scheduler runs background jobs on cron schedules kept in the
+// registry, so schedules can be changed from the admin settings without a
+// restart.
+//
+// Sync checks the schedules and must run about once a minute. A job runs
+// once when its schedule matched since the previous check; runs missed while
+// the server was down are not caught up.
+package scheduler
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/internal/registryutil"
+	"go.uber.org/zap"
+)
+
+// Status is the schedule of a job
+type Status struct {
+	// Expression is the cron expression, empty when not scheduled
+	Expression string
+	// Valid is false when Expression does not parse
+	Valid bool
+	// NextRun is zero when not scheduled
+	NextRun time.Time
+	// LastRun is when the schedule last ran the job, zero when it has not
+	// since the server started
+	LastRun time.Time
+}
+
+type job struct {
+	name string
+	key  string
+	run  func() error
+
+	expression string
+	schedule   *Schedule
+	lastCheck  time.Time
+	lastRun    time.Time
+}
+
+// Scheduler runs jobs on the schedules read from registry keys
+type Scheduler struct {
+	registryStore registrystore.Store
+	config        registryutil.ConfigProvider
+	logger        *zap.Logger
+	now           func() time.Time
+
+	mu   sync.Mutex
+	jobs map[string]*job
+	// order runs jobs due on the same check in the order they were added
+	order []*job
+}
+
+// New returns a scheduler reading schedules from registryStore, with cfg
+// overriding them when set by flag or environment
+func New(registryStore registrystore.Store, cfg registryutil.ConfigProvider, logger *zap.Logger) *Scheduler {
+	return &Scheduler{
+		registryStore: registryStore,
+		config:        cfg,
+		logger:        logger,
+		now:           time.Now,
+		jobs:          make(map[string]*job),
+	}
+}
+
+// Add schedules run by the cron expression of registry key key. An empty
+// expression leaves the job unscheduled.
+func (s *Scheduler) Add(name, key string, run func() error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j := &job{name: name, key: key, run: run}
+	s.jobs[name] = j
+	s.order = append(s.order, j)
+}
+
+// Sync reloads the schedules and runs the jobs due since the previous call,
+// for use with the server sync loop
+func (s *Scheduler) Sync() error {
+	ctx := context.Background()
+	now := s.now()
+	var due []*job
+	s.mu.Lock()
+	for _, j := range s.order {
+		s.reloadLocked(ctx, j)
+		if j.schedule != nil && !j.lastCheck.IsZero() {
+			if next := j.schedule.Next(j.lastCheck); !next.IsZero() && !next.After(now) {
+				j.lastRun = now
+				due = append(due, j)
+			}
+		}
+		j.lastCheck = now
+	}
+	s.mu.Unlock()
+
+	for _, j := range due {
+		s.logger.Info("Running scheduled job", zap.String("job", j.name), zap.String("schedule", j.expression))
+		if err := j.run(); err != nil {
+			s.logger.Warn("Scheduled job failed", zap.String("job", j.name), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+// reloadLocked reads the expression of j, parsing it when changed. A changed
+// schedule starts counting from now.
+func (s *Scheduler) reloadLocked(ctx context.Context, j *job) {
+	expression := strings.TrimSpace(registryutil.GetEffectiveValue(ctx, s.registryStore, s.config, j.key).Value)
+	if expression == j.expression && !j.lastCheck.IsZero() {
+		return
+	}
+	j.expression = expression
+	j.schedule = nil
+	j.lastCheck = time.Time{}
+	if expression == "" {
+		return
+	}
+	schedule, err := Parse(expression)
+	if err != nil {
+		s.logger.Warn("Invalid job schedule, job is not scheduled",
+			zap.String("job", j.name), zap.String("key", j.key), zap.Error(err))
+		return
+	}
+	j.schedule = schedule
+}
+
+// Status returns the schedule of the job name, false when there is no such
+// job. It reflects the registry as of the last Sync.
+func (s *Scheduler) Status(name string) (Status, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[name]
+	if !ok {
+		return Status{}, false
+	}
+	status := Status{Expression: j.expression, Valid: j.expression == "" || j.schedule != nil, LastRun: j.lastRun}
+	if j.schedule != nil {
+		from := j.lastCheck
+		if from.IsZero() {
+			from = s.now()
+		}
+		status.NextRun = j.schedule.Next(from)
+	}
+	return status, true
+}
@@ -0,0 +1,87 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// fakeConfig overrides registry keys as flags or environment variables do
+type fakeConfig map[string]string
+
+func (c fakeConfig) GetByRegistryKey(key string) (string, bool) {
+	value, ok := c[key]
+	return value, ok
+}
+
+func (c fakeConfig) IsEmbeddedMode() bool {
+	return false
+}
+
+func TestScheduler(t *testing.T) {
+	cfg := fakeConfig{"config.test_schedule": "*/10 * * * *"}
+	now := time.Date(2026, time.January, 14, 10, 5, 0, 0, time.UTC)
+	s := New(nil, cfg, zap.NewNop())
+	s.now = func() time.Time { return now }
+	runs := 0
+	s.Add("test", "config.test_schedule", func() error {
+		runs++
+		return nil
+	})
+
+	// The first check only starts counting
+	assert.NoError(t, s.Sync())
+	assert.Equal(t, 0, runs)
+	status, ok := s.Status("test")
+	assert.True(t, ok)
+	assert.Equal(t, "*/10 * * * *", status.Expression)
+	assert.True(t, status.Valid)
+	assert.Equal(t, time.Date(2026, time.January, 14, 10, 10, 0, 0, time.UTC), status.NextRun)
+	assert.True(t, status.LastRun.IsZero())
+
+	now = now.Add(4 * time.Minute)
+	assert.NoError(t, s.Sync())
+	assert.Equal(t, 0, runs)
+
+	now = now.Add(time.Minute)
+	assert.NoError(t, s.Sync())
+	assert.Equal(t, 1, runs)
+	status, _ = s.Status("test")
+	assert.Equal(t, now, status.LastRun)
+	assert.Equal(t, time.Date(2026, time.January, 14, 10, 20, 0, 0, time.UTC), status.NextRun)
+
+	// Several missed runs run once
+	now = now.Add(time.Hour)
+	assert.NoError(t, s.Sync())
+	assert.Equal(t, 2, runs)
+
+	// A changed schedule starts counting again
+	cfg["config.test_schedule"] = "* * * * *"
+	now = now.Add(time.Minute)
+	assert.NoError(t, s.Sync())
+	assert.Equal(t, 2, runs)
+	now = now.Add(time.Minute)
+	assert.NoError(t, s.Sync())
+	assert.Equal(t, 3, runs)
+
+	cfg["config.test_schedule"] = "not a schedule"
+	now = now.Add(time.Minute)
+	assert.NoError(t, s.Sync())
+	status, _ = s.Status("test")
+	assert.False(t, status.Valid)
+	assert.True(t, status.NextRun.IsZero())
+	now = now.Add(time.Minute)
+	assert.NoError(t, s.Sync())
+	assert.Equal(t, 3, runs)
+
+	cfg["config.test_schedule"] = ""
+	assert.NoError(t, s.Sync())
+	status, _ = s.Status("test")
+	assert.Empty(t, status.Expression)
+	assert.True(t, status.Valid)
+
+	_, ok = s.Status("missing")
+	assert.False(t, ok)
+}
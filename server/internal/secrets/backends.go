@@ -0,0 +1,208 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+)
+
+// maxSecretSize bounds the responses of the vault and awssm backends
+const maxSecretSize = 1 << 20
+
+// fileBackend reads file:// references, such as Docker secrets mounted in
+// /run/secrets, without the trailing newline
+type fileBackend struct{}
+
+func (fileBackend) Fetch(_ context.Context, ref Reference) (string, error) {
+	data, err := os.ReadFile(ref.Path)
+	if err != nil {
+		return "", err
+	}
+	return selectKey(strings.TrimRight(string(data), "\r\n"), ref.Key)
+}
+
+// vaultBackend reads vault:// references through the Vault HTTP API
+type vaultBackend struct {
+	addr      string
+	token     string
+	namespace string
+	client    *http.Client
+}
+
+func (b *vaultBackend) Fetch(ctx context.Context, ref Reference) (string, error) {
+	if b.addr == "" {
+		return "", fmt.Errorf("vault-addr is not set")
+	}
+	if b.token == "" {
+		return "", fmt.Errorf("vault-token is not set")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(b.addr, "/")+"/v1/"+ref.Path, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", b.token)
+	if b.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", b.namespace)
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSecretSize))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var out struct {
+			Errors []string `json:"errors"`
+		}
+		_ = json.Unmarshal(body, &out)
+		return "", fmt.Errorf("vault returned %s: %s", resp.Status, strings.Join(out.Errors, ", "))
+	}
+
+	var out struct {
+		Data map[string]any `json:"data"`
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&out); err != nil {
+		return "", fmt.Errorf("invalid vault response: %w", err)
+	}
+	data := out.Data
+	// KV v2 nests the secret next to its metadata
+	if inner, ok := data["data"].(map[string]any); ok {
+		if _, ok := data["metadata"]; ok {
+			data = inner
+		}
+	}
+	return pickKey(data, ref.Key)
+}
+
+// awsBackend reads awssm:// references with the GetSecretValue API of AWS
+// Secrets Manager, using the default AWS credential chain. The region comes
+// from ?region=, the secret ARN or the AWS config.
+type awsBackend struct {
+	client *http.Client
+}
+
+func (b *awsBackend) Fetch(ctx context.Context, ref Reference) (string, error) {
+	region := ref.Query.Get("region")
+	if parts := strings.Split(ref.Path, ":"); region == "" && strings.HasPrefix(ref.Path, "arn:") && len(parts) > 3 {
+		region = parts[3]
+	}
+	var optFns []func(*awsconfig.LoadOptions) error
+	if region != "" {
+		optFns = append(optFns, awsconfig.WithRegion(region))
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, optFns...)
+	if err != nil {
+		return "", fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	if cfg.Region == "" {
+		return "", fmt.Errorf("AWS region is not set, add ?region= to the reference or set AWS_REGION")
+	}
+	creds, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve AWS credentials: %w", err)
+	}
+
+	endpoint := os.Getenv("AWS_ENDPOINT_URL_SECRETS_MANAGER")
+	if endpoint == "" && cfg.BaseEndpoint != nil {
+		endpoint = *cfg.BaseEndpoint
+	}
+	if endpoint == "" {
+		endpoint = "https://secretsmanager." + cfg.Region + ".amazonaws.com"
+	}
+	payload, err := json.Marshal(map[string]string{"SecretId": ref.Path})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(endpoint, "/")+"/", bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	sum := sha256.Sum256(payload)
+	if err := v4.NewSigner().SignHTTP(ctx, creds, req, hex.EncodeToString(sum[:]), "secretsmanager", cfg.Region, time.Now()); err != nil {
+		return "", fmt.Errorf("failed to sign request: %w", err)
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSecretSize))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var out struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(body, &out)
+		return "", fmt.Errorf("secrets manager returned %s: %s %s", resp.Status, out.Type, out.Message)
+	}
+
+	var out struct {
+		SecretString *string `json:"SecretString"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return "", fmt.Errorf("invalid secrets manager response: %w", err)
+	}
+	if out.SecretString == nil {
+		return "", fmt.Errorf("binary secrets are not supported")
+	}
+	return selectKey(*out.SecretString, ref.Key)
+}
+
+// selectKey returns secret, or the key of secret parsed as a JSON object
+func selectKey(secret, key string) (string, error) {
+	if key == "" {
+		return secret, nil
+	}
+	var data map[string]any
+	decoder := json.NewDecoder(strings.NewReader(secret))
+	decoder.UseNumber()
+	if err := decoder.Decode(&data); err != nil {
+		return "", fmt.Errorf("secret is not a JSON object, required by #%s", key)
+	}
+	return pickKey(data, key)
+}
+
+// pickKey returns the key of data, or its only value when key is empty
+func pickKey(data map[string]any, key string) (string, error) {
+	if key == "" {
+		if len(data) != 1 {
+			return "", fmt.Errorf("secret has %d keys, select one with #key", len(data))
+		}
+		for k := range data {
+			key = k
+		}
+	}
+	value, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("key %q not found", key)
+	}
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case json.Number, bool:
+		return fmt.Sprint(v), nil
+	default:
+		return "", fmt.Errorf("key %q is not a string", key)
+	}
+}
@@ -0,0 +1,200 @@
+// Package secrets resolves config values that reference a secret kept
+// elsewhere instead of holding it:
+//
+//	file:///run/secrets/jwt_secret               Docker and Kubernetes secrets
+//	vault://secret/data/imagor-studio#jwt_secret HashiCorp Vault, KV v1 or v2
+//	awssm://imagor-studio/prod#jwt_secret        AWS Secrets Manager
+//
+// The fragment selects a key of Vault data or of a JSON secret string, and
+// may be left out when there is a single key or the secret is a plain string.
+//
+// Resolved values are cached for the TTL, after which the next Resolve
+// fetches them again so rotated secrets are picked up. When the refetch
+// fails, the stale value is kept rather than breaking a running server.
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTTL is how long resolved values are cached by default
+const DefaultTTL = 5 * time.Minute
+
+// Reference is a parsed secret reference
+type Reference struct {
+	Scheme string
+	// Path is the file path, Vault path or Secrets Manager secret ID
+	Path  string
+	Query url.Values
+	// Key selects a key of structured secrets, from the URL fragment
+	Key string
+}
+
+func (r Reference) String() string {
+	s := r.Scheme + "://" + r.Path
+	if len(r.Query) > 0 {
+		s += "?" + r.Query.Encode()
+	}
+	if r.Key != "" {
+		s += "#" + r.Key
+	}
+	return s
+}
+
+// Backend fetches the secret of a reference
+type Backend interface {
+	Fetch(ctx context.Context, ref Reference) (string, error)
+}
+
+// Options configure the built-in backends and caching of a Resolver
+type Options struct {
+	// TTL of cached values, 0 caches them until the process exits
+	TTL time.Duration
+	// VaultAddr, VaultToken and VaultNamespace configure the vault backend
+	VaultAddr      string
+	VaultToken     string
+	VaultNamespace string
+	// HTTPClient used by the vault and awssm backends, http.DefaultClient if nil
+	HTTPClient *http.Client
+}
+
+// Resolver resolves secret references with caching
+type Resolver struct {
+	mu       sync.Mutex
+	opts     Options
+	backends map[string]Backend
+	cache    map[string]cachedSecret
+	now      func() time.Time
+}
+
+type cachedSecret struct {
+	value     string
+	fetchedAt time.Time
+}
+
+// NewResolver creates a Resolver with the file, vault and awssm backends
+func NewResolver(opts Options) *Resolver {
+	return &Resolver{
+		opts:     opts,
+		backends: make(map[string]Backend),
+		cache:    make(map[string]cachedSecret),
+		now:      time.Now,
+	}
+}
+
+// SetOptions replaces the options, keeping cached values
+func (r *Resolver) SetOptions(opts Options) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.opts = opts
+}
+
+// RegisterBackend adds or replaces the backend of a scheme
+func (r *Resolver) RegisterBackend(scheme string, backend Backend) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.backends[scheme] = backend
+}
+
+// IsReference reports whether value is a reference of a built-in backend
+func IsReference(value string) bool {
+	scheme, _, ok := strings.Cut(value, "://")
+	return ok && (scheme == "file" || scheme == "vault" || scheme == "awssm")
+}
+
+// Parse parses a secret reference
+func Parse(value string) (Reference, error) {
+	scheme, rest, ok := strings.Cut(value, "://")
+	if !ok || scheme == "" {
+		return Reference{}, fmt.Errorf("invalid secret reference %q", value)
+	}
+	ref := Reference{Scheme: scheme}
+	rest, ref.Key, _ = strings.Cut(rest, "#")
+	rest, query, _ := strings.Cut(rest, "?")
+	if query != "" {
+		q, err := url.ParseQuery(query)
+		if err != nil {
+			return Reference{}, fmt.Errorf("invalid secret reference %q: %w", value, err)
+		}
+		ref.Query = q
+	}
+	path, err := url.PathUnescape(rest)
+	if err != nil {
+		return Reference{}, fmt.Errorf("invalid secret reference %q: %w", value, err)
+	}
+	if scheme != "file" {
+		path = strings.Trim(path, "/")
+	}
+	if path == "" {
+		return Reference{}, fmt.Errorf("invalid secret reference %q: empty path", value)
+	}
+	ref.Path = path
+	return ref, nil
+}
+
+// Resolve returns the secret referenced by value, or value itself when it is
+// not a reference of a registered or built-in backend
+func (r *Resolver) Resolve(ctx context.Context, value string) (string, error) {
+	scheme, _, ok := strings.Cut(value, "://")
+	if !ok {
+		return value, nil
+	}
+	r.mu.Lock()
+	backend := r.backend(scheme)
+	cached, isCached := r.cache[value]
+	ttl := r.opts.TTL
+	r.mu.Unlock()
+	if backend == nil {
+		return value, nil
+	}
+	if isCached && (ttl == 0 || r.now().Sub(cached.fetchedAt) < ttl) {
+		return cached.value, nil
+	}
+
+	ref, err := Parse(value)
+	if err != nil {
+		return "", err
+	}
+	secret, err := backend.Fetch(ctx, ref)
+	if err != nil {
+		if isCached {
+			return cached.value, nil
+		}
+		return "", fmt.Errorf("failed to resolve %s: %w", value, err)
+	}
+	r.mu.Lock()
+	r.cache[value] = cachedSecret{value: secret, fetchedAt: r.now()}
+	r.mu.Unlock()
+	return secret, nil
+}
+
+// backend returns the backend of scheme, nil if unknown. r.mu must be held.
+func (r *Resolver) backend(scheme string) Backend {
+	if backend, ok := r.backends[scheme]; ok {
+		return backend
+	}
+	client := r.opts.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	switch scheme {
+	case "file":
+		return fileBackend{}
+	case "vault":
+		return &vaultBackend{
+			addr:      r.opts.VaultAddr,
+			token:     r.opts.VaultToken,
+			namespace: r.opts.VaultNamespace,
+			client:    client,
+		}
+	case "awssm":
+		return &awsBackend{client: client}
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	ref, err := Parse("vault://secret/data/imagor-studio?version=2#jwt_secret")
+	require.NoError(t, err)
+	assert.Equal(t, "vault", ref.Scheme)
+	assert.Equal(t, "secret/data/imagor-studio", ref.Path)
+	assert.Equal(t, "2", ref.Query.Get("version"))
+	assert.Equal(t, "jwt_secret", ref.Key)
+
+	ref, err = Parse("file:///run/secrets/jwt_secret")
+	require.NoError(t, err)
+	assert.Equal(t, "/run/secrets/jwt_secret", ref.Path)
+
+	ref, err = Parse("awssm://arn:aws:secretsmanager:eu-west-1:123456789012:secret:imagor-AbCdEf#s3")
+	require.NoError(t, err)
+	assert.Equal(t, "arn:aws:secretsmanager:eu-west-1:123456789012:secret:imagor-AbCdEf", ref.Path)
+	assert.Equal(t, "s3", ref.Key)
+
+	_, err = Parse("vault://")
+	assert.Error(t, err)
+
+	assert.True(t, IsReference("file:///run/secrets/x"))
+	assert.False(t, IsReference("https://example.com"))
+	assert.False(t, IsReference("s3cret"))
+}
+
+func TestResolveFile(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "jwt"), []byte("s3cret\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "s3.json"), []byte(`{"access_key_id": "AKIA", "port": 22}`), 0o600))
+	r := NewResolver(Options{})
+
+	value, err := r.Resolve(context.Background(), "file://"+filepath.Join(dir, "jwt"))
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", value)
+
+	value, err = r.Resolve(context.Background(), "file://"+filepath.Join(dir, "s3.json")+"#access_key_id")
+	require.NoError(t, err)
+	assert.Equal(t, "AKIA", value)
+	value, err = r.Resolve(context.Background(), "file://"+filepath.Join(dir, "s3.json")+"#port")
+	require.NoError(t, err)
+	assert.Equal(t, "22", value)
+
+	_, err = r.Resolve(context.Background(), "file://"+filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+
+	// Values other than references are returned as they are
+	value, err = r.Resolve(context.Background(), "https://example.com/x")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/x", value)
+}
+
+func TestResolveVault(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors": ["permission denied"]}`))
+			return
+		}
+		assert.Equal(t, "team", r.Header.Get("X-Vault-Namespace"))
+		switch r.URL.Path {
+		case "/v1/secret/data/imagor-studio":
+			_, _ = w.Write([]byte(`{"data": {"data": {"jwt_secret": "s3cret", "imagor_secret": "imagor"}, "metadata": {"version": 3}}}`))
+		case "/v1/kv/imagor-studio":
+			_, _ = w.Write([]byte(`{"data": {"value": "v1-secret"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors": []}`))
+		}
+	}))
+	defer server.Close()
+
+	r := NewResolver(Options{VaultAddr: server.URL, VaultToken: "root", VaultNamespace: "team"})
+	value, err := r.Resolve(context.Background(), "vault://secret/data/imagor-studio#jwt_secret")
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", value)
+
+	value, err = r.Resolve(context.Background(), "vault://kv/imagor-studio")
+	require.NoError(t, err)
+	assert.Equal(t, "v1-secret", value, "the only key is selected")
+
+	_, err = r.Resolve(context.Background(), "vault://secret/data/imagor-studio")
+	assert.ErrorContains(t, err, "select one with #key")
+	_, err = r.Resolve(context.Background(), "vault://secret/data/missing#x")
+	assert.ErrorContains(t, err, "404")
+
+	r.SetOptions(Options{VaultAddr: server.URL, VaultToken: "wrong", VaultNamespace: "team"})
+	_, err = r.Resolve(context.Background(), "vault://kv/other")
+	assert.ErrorContains(t, err, "permission denied")
+}
+
+func TestResolveAWSSecretsManager(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secretsmanager.GetSecretValue", r.Header.Get("X-Amz-Target"))
+		assert.Contains(t, r.Header.Get("Authorization"), "/eu-west-1/secretsmanager/aws4_request")
+		body, _ := io.ReadAll(r.Body)
+		var in struct{ SecretId string }
+		require.NoError(t, json.Unmarshal(body, &in))
+		switch in.SecretId {
+		case "imagor-studio/prod":
+			_, _ = w.Write([]byte(`{"Name": "imagor-studio/prod", "SecretString": "{\"jwt_secret\": \"s3cret\"}"}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"__type": "ResourceNotFoundException", "message": "not found"}`))
+		}
+	}))
+	defer server.Close()
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIA")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+	t.Setenv("AWS_ENDPOINT_URL_SECRETS_MANAGER", server.URL)
+
+	r := NewResolver(Options{})
+	value, err := r.Resolve(context.Background(), "awssm://imagor-studio/prod?region=eu-west-1#jwt_secret")
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", value)
+
+	_, err = r.Resolve(context.Background(), "awssm://arn:aws:secretsmanager:eu-west-1:123456789012:secret:missing")
+	assert.ErrorContains(t, err, "ResourceNotFoundException")
+}
+
+type countingBackend struct {
+	calls int
+	value string
+	err   error
+}
+
+func (b *countingBackend) Fetch(_ context.Context, ref Reference) (string, error) {
+	b.calls++
+	return b.value + ref.Path, b.err
+}
+
+func TestResolveCache(t *testing.T) {
+	backend := &countingBackend{value: "v1:"}
+	r := NewResolver(Options{TTL: time.Minute})
+	r.RegisterBackend("test", backend)
+	now := time.Now()
+	r.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		value, err := r.Resolve(context.Background(), "test://a")
+		require.NoError(t, err)
+		assert.Equal(t, "v1:a", value)
+	}
+	assert.Equal(t, 1, backend.calls)
+
+	// Expired values are fetched again
+	now = now.Add(2 * time.Minute)
+	backend.value = "v2:"
+	value, err := r.Resolve(context.Background(), "test://a")
+	require.NoError(t, err)
+	assert.Equal(t, "v2:a", value)
+	assert.Equal(t, 2, backend.calls)
+
+	// A failed refresh keeps the stale value
+	now = now.Add(2 * time.Minute)
+	backend.err = errors.New("unavailable")
+	value, err = r.Resolve(context.Background(), "test://a")
+	require.NoError(t, err)
+	assert.Equal(t, "v2:a", value)
+	_, err = r.Resolve(context.Background(), "test://b")
+	assert.True(t, strings.Contains(err.Error(), "unavailable"))
+}
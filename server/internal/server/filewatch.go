@@ -0,0 +1,56 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/events"
+	"github.com/cshum/imagor-studio/server/internal/filemeta"
+	"github.com/cshum/imagor-studio/server/internal/fswatch"
+	"github.com/cshum/imagor-studio/server/internal/listcache"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"go.uber.org/zap"
+)
+
+// fileWatchCleanupTimeout bounds the cache cleanup of a batch of changes
+const fileWatchCleanupTimeout = 30 * time.Second
+
+// fileWatchHandler publishes the changes made directly in the file storage
+// directory to fileChanged subscribers, drops the cached listings they affect
+// and the cached metadata of the files changed or removed. Changes belong to
+// the primary storage, scoped as the resolver scopes it. listCache is nil when
+// listings are not cached.
+func fileWatchHandler(broker *events.Broker, fileMetaStore filemeta.Store, listCache *listcache.Cache, logger *zap.Logger) fswatch.Handler {
+	return func(changes []fswatch.Change) {
+		ctx, cancel := context.WithTimeout(context.Background(), fileWatchCleanupTimeout)
+		defer cancel()
+		for _, c := range changes {
+			kind := events.FileCreated
+			switch c.Op {
+			case fswatch.Updated:
+				kind = events.FileUpdated
+			case fswatch.Removed:
+				kind = events.FileDeleted
+			}
+			if listCache != nil {
+				listCache.Invalidate(ctx, registrystore.SystemOwnerID, c.Path)
+			}
+			if kind != events.FileCreated && fileMetaStore != nil {
+				if err := fileMetaStore.RemoveFilePath(ctx, registrystore.SystemOwnerID, c.Path); err != nil {
+					logger.Warn("Failed to drop metadata of changed file", zap.String("path", c.Path), zap.Error(err))
+				}
+			}
+			broker.Publish(events.Event{Kind: kind, Scope: registrystore.SystemOwnerID, Path: c.Path})
+		}
+	}
+}
+
+// newFileWatchSync returns a sync function keeping watcher on the current
+// file storage directory, following storage configuration changes. Watching
+// stops while the storage is not file storage.
+func newFileWatchSync(ctx context.Context, watcher *fswatch.Watcher, fileStorageDir func() string) func() error {
+	return func() error {
+		watcher.Watch(ctx, fileStorageDir())
+		return nil
+	}
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/events"
+	"github.com/cshum/imagor-studio/server/internal/filemeta"
+	"github.com/cshum/imagor-studio/server/internal/fswatch"
+	"github.com/cshum/imagor-studio/server/internal/listcache"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type removedPathsStore struct {
+	filemeta.Store
+	removed []string
+}
+
+func (s *removedPathsStore) RemoveFilePath(ctx context.Context, scope, path string) error {
+	s.removed = append(s.removed, scope+":"+path)
+	return nil
+}
+
+func TestFileWatchHandler(t *testing.T) {
+	broker := events.NewBroker()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	received := broker.Subscribe(ctx, func(events.Event) bool { return true })
+	store := &removedPathsStore{}
+	listCache := listcache.New()
+	for _, folder := range []string{"photos", "docs"} {
+		_, err := listCache.Load(ctx, registrystore.SystemOwnerID, folder, func() ([]storage.FileInfo, error) { return nil, nil })
+		require.NoError(t, err)
+	}
+
+	fileWatchHandler(broker, store, listCache, zap.NewNop())([]fswatch.Change{
+		{Op: fswatch.Created, Path: "photos/new.jpg"},
+		{Op: fswatch.Updated, Path: "photos/edit.jpg"},
+		{Op: fswatch.Removed, Path: "photos/old"},
+	})
+
+	var got []events.Event
+	for range 3 {
+		select {
+		case e := <-received:
+			got = append(got, e)
+		case <-time.After(time.Second):
+			t.Fatal("event not published")
+		}
+	}
+	scope := registrystore.SystemOwnerID
+	assert.Equal(t, []events.Event{
+		{Kind: events.FileCreated, Scope: scope, Path: "photos/new.jpg"},
+		{Kind: events.FileUpdated, Scope: scope, Path: "photos/edit.jpg"},
+		{Kind: events.FileDeleted, Scope: scope, Path: "photos/old"},
+	}, got)
+	assert.Equal(t, []string{scope + ":photos/edit.jpg", scope + ":photos/old"}, store.removed)
+	_, ok := listCache.Get(ctx, scope, "photos")
+	assert.False(t, ok)
+	_, ok = listCache.Get(ctx, scope, "docs")
+	assert.True(t, ok)
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/config"
+)
+
+// grpcEnabled reports whether the storage gRPC service is served
+func grpcEnabled(cfg *config.Config) bool {
+	return cfg.GRPCPort != 0 && !cfg.EmbeddedMode
+}
+
+// newGRPCServer returns the server of the gRPC port, speaking HTTP/2 only,
+// over TLS when tlsConfig is set and in cleartext otherwise, as gRPC
+// clients do without transport credentials
+func newGRPCServer(cfg *config.Config, handler http.Handler, tlsConfig *tls.Config) *http.Server {
+	protocols := new(http.Protocols)
+	if tlsConfig != nil {
+		protocols.SetHTTP2(true)
+		tlsConfig = tlsConfig.Clone()
+	} else {
+		protocols.SetUnencryptedHTTP2(true)
+	}
+	// Calls stream for as long as they take, so unlike the HTTP server
+	// there are no read and write timeouts
+	return &http.Server{
+		Addr:              fmt.Sprintf(":%d", cfg.GRPCPort),
+		Handler:           handler,
+		TLSConfig:         tlsConfig,
+		Protocols:         protocols,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       5 * time.Minute,
+	}
+}
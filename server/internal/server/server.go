@@ -2,24 +2,70 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io/fs"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"time"
 
 	"github.com/99designs/gqlgen/graphql/handler"
 	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
 	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/cshum/imagor-studio/server/internal/adminrecovery"
+	"github.com/cshum/imagor-studio/server/internal/allowlist"
+	"github.com/cshum/imagor-studio/server/internal/apiversion"
+	"github.com/cshum/imagor-studio/server/internal/auditlog"
 	"github.com/cshum/imagor-studio/server/internal/bootstrap"
+	"github.com/cshum/imagor-studio/server/internal/bulkdownload"
+	"github.com/cshum/imagor-studio/server/internal/chunkupload"
+	"github.com/cshum/imagor-studio/server/internal/clientip"
+	"github.com/cshum/imagor-studio/server/internal/compression"
 	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/davserver"
+	"github.com/cshum/imagor-studio/server/internal/dbmaintenance"
+	"github.com/cshum/imagor-studio/server/internal/dedupe"
+	"github.com/cshum/imagor-studio/server/internal/events"
+	"github.com/cshum/imagor-studio/server/internal/faces"
+	"github.com/cshum/imagor-studio/server/internal/filejournal"
+	"github.com/cshum/imagor-studio/server/internal/fswatch"
 	"github.com/cshum/imagor-studio/server/internal/generated/gql"
+	"github.com/cshum/imagor-studio/server/internal/grpcapi"
+	"github.com/cshum/imagor-studio/server/internal/hls"
 	"github.com/cshum/imagor-studio/server/internal/httphandler"
+	"github.com/cshum/imagor-studio/server/internal/imagorprovider"
+	"github.com/cshum/imagor-studio/server/internal/jobqueue"
+	"github.com/cshum/imagor-studio/server/internal/labelhook"
+	"github.com/cshum/imagor-studio/server/internal/libraryscan"
+	"github.com/cshum/imagor-studio/server/internal/listcache"
 	"github.com/cshum/imagor-studio/server/internal/middleware"
+	"github.com/cshum/imagor-studio/server/internal/mimetype"
+	"github.com/cshum/imagor-studio/server/internal/netacl"
+	"github.com/cshum/imagor-studio/server/internal/notify"
+	"github.com/cshum/imagor-studio/server/internal/operation"
+	"github.com/cshum/imagor-studio/server/internal/querylimit"
+	"github.com/cshum/imagor-studio/server/internal/ratelimit"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
 	"github.com/cshum/imagor-studio/server/internal/resolver"
+	"github.com/cshum/imagor-studio/server/internal/s3gateway"
+	"github.com/cshum/imagor-studio/server/internal/s3key"
+	"github.com/cshum/imagor-studio/server/internal/scheduler"
+	"github.com/cshum/imagor-studio/server/internal/sessionstore"
+	"github.com/cshum/imagor-studio/server/internal/thumbnailpreset"
+	"github.com/cshum/imagor-studio/server/internal/tracing"
+	"github.com/cshum/imagor-studio/server/internal/updatecheck"
+	"github.com/cshum/imagor-studio/server/internal/urlimport"
+	"github.com/cshum/imagor-studio/server/internal/version"
+	"github.com/cshum/imagor-studio/server/internal/videostream"
+	"github.com/cshum/imagor-studio/server/internal/virusscan"
+	"github.com/cshum/imagor-studio/server/internal/webhook"
 	"github.com/cshum/imagor-studio/server/pkg/management"
 	"github.com/cshum/imagor-studio/server/pkg/processing"
 	"github.com/cshum/imagor-studio/server/pkg/space"
+	"github.com/cshum/imagor/imagorpath"
+	"github.com/gorilla/websocket"
 	"github.com/uptrace/bun"
 	"github.com/uptrace/bun/dialect"
 	"go.uber.org/zap"
@@ -38,7 +84,17 @@ type Server struct {
 	cfg        *config.Config
 	services   *bootstrap.Services
 	httpServer *http.Server
+	// redirectServer answers ACME challenges and redirects plain HTTP to
+	// HTTPS, nil unless TLS is on
+	redirectServer *http.Server
+	// grpcServer serves the storage gRPC service, nil unless enabled
+	grpcServer *http.Server
 	syncCancel context.CancelFunc // stops the background 30s sync loop
+	hlsManager *hls.Manager       // nil unless HLS transcoding is enabled
+	// videoStreams is nil unless video streams are enabled
+	videoStreams *videostream.Manager
+	// shutdownTracing flushes pending spans, nil when tracing is off
+	shutdownTracing func(context.Context) error
 }
 
 // startSyncLoop runs syncFuncs every interval in a background goroutine until
@@ -62,6 +118,326 @@ func startSyncLoop(ctx context.Context, interval time.Duration, logger *zap.Logg
 	}()
 }
 
+// authProviders lists the external sign-in providers configured for the SPA
+func authProviders(cloudConfig management.CloudConfig) []string {
+	if strings.TrimSpace(cloudConfig.GoogleClientID) != "" {
+		return []string{"google"}
+	}
+	return nil
+}
+
+// serverCapabilities lists the optional features enabled on this server,
+// so the SPA can hide what is unavailable without probing
+func serverCapabilities(cfg *config.Config, services *bootstrap.Services, hlsManager *hls.Manager, videoStreams *videostream.Manager, chunkUploads *chunkupload.Manager, dbMaintenance *dbmaintenance.Job, libraryScan *libraryscan.Job, labelHook *labelhook.Hook, faceScanner *faces.Scanner) []string {
+	capabilities := []string{"bulk_download", "notifications", "subscriptions", "url_import"}
+	if chunkUploads != nil {
+		capabilities = append(capabilities, "chunked_upload")
+	}
+	if hlsManager != nil {
+		capabilities = append(capabilities, "hls")
+	}
+	if videoStreams != nil {
+		capabilities = append(capabilities, "video_stream")
+	}
+	if services.TagStore != nil {
+		capabilities = append(capabilities, "tags")
+	}
+	if services.FavoriteStore != nil {
+		capabilities = append(capabilities, "favorites")
+	}
+	if services.AlbumStore != nil {
+		capabilities = append(capabilities, "albums")
+	}
+	if services.CommentStore != nil {
+		capabilities = append(capabilities, "comments")
+	}
+	if services.RatingStore != nil {
+		capabilities = append(capabilities, "ratings")
+	}
+	if services.AuditLog != nil {
+		capabilities = append(capabilities, "audit_log")
+	}
+	if services.WebhookStore != nil {
+		capabilities = append(capabilities, "webhooks")
+	}
+	if services.FileJournal != nil {
+		capabilities = append(capabilities, "incremental_sync")
+	}
+	if services.AutoUploadStore != nil {
+		capabilities = append(capabilities, "auto_upload")
+	}
+	if services.APITokenStore != nil {
+		capabilities = append(capabilities, "api_tokens")
+	}
+	if services.SessionStore != nil {
+		capabilities = append(capabilities, "sessions")
+	}
+	if webdavEnabled(cfg, services) {
+		capabilities = append(capabilities, "webdav")
+	}
+	if s3GatewayEnabled(cfg, services) {
+		capabilities = append(capabilities, "s3_gateway")
+	}
+	if grpcEnabled(cfg) {
+		capabilities = append(capabilities, "grpc")
+	}
+	if services.ShareStore != nil {
+		capabilities = append(capabilities, "share_links")
+	}
+	if services.ImageEditStore != nil {
+		capabilities = append(capabilities, "image_edits")
+	}
+	if services.FolderCoverStore != nil {
+		capabilities = append(capabilities, "folder_covers")
+	}
+	if services.FolderSettingsStore != nil {
+		capabilities = append(capabilities, "folder_settings")
+	}
+	if services.StorageStatsStore != nil {
+		capabilities = append(capabilities, "storage_quotas")
+	}
+	if dbMaintenance != nil {
+		capabilities = append(capabilities, "database_maintenance")
+	}
+	if services.DB != nil {
+		capabilities = append(capabilities, "backups")
+	}
+	if services.FileMetaStore != nil {
+		capabilities = append(capabilities, "timeline", "geo_clusters")
+	}
+	if services.DuplicateStore != nil {
+		capabilities = append(capabilities, "duplicate_detection")
+	}
+	if libraryScan != nil {
+		capabilities = append(capabilities, "library_scan")
+	}
+	if labelHook != nil {
+		capabilities = append(capabilities, "label_hook")
+	}
+	if faceScanner != nil {
+		capabilities = append(capabilities, "faces")
+	}
+	if cfg.UploadScanner != "" {
+		capabilities = append(capabilities, "upload_scan")
+	}
+	if cfg.UpdateCheckEnabled {
+		capabilities = append(capabilities, "update_check")
+	}
+	return capabilities
+}
+
+// newHLSManager returns nil when HLS transcoding is disabled or ffmpeg is
+// missing. Finished transcodes are kept in the imagor result storage when
+// one is configured.
+func newHLSManager(cfg *config.Config, logger *zap.Logger, imagorProvider *imagorprovider.Provider) *hls.Manager {
+	if !cfg.HLSEnabled {
+		return nil
+	}
+	transcoder, err := hls.NewFFmpeg(cfg.HLSFFmpegPath)
+	if err != nil {
+		logger.Warn("HLS transcoding disabled", zap.Error(err))
+		return nil
+	}
+	options := []hls.Option{
+		hls.WithMaxTranscodes(cfg.HLSMaxTranscodes),
+		hls.WithMaxTranscodesPerUser(cfg.HLSMaxTranscodesPerUser),
+	}
+	if imagorProvider != nil {
+		if results := imagorProvider.ResultStorage(); results != nil {
+			options = append(options, hls.WithResultStorage(results))
+		}
+	}
+	manager, err := hls.NewManager(logger, transcoder, cfg.HLSCacheDir, options...)
+	if err != nil {
+		logger.Warn("HLS transcoding disabled", zap.Error(err))
+		return nil
+	}
+	return manager
+}
+
+// newPerceptualHasher hashes default storage images from the 9x8 grayscale
+// thumbnails rendered by the embedded imagor
+func newPerceptualHasher(provider *imagorprovider.Provider) dedupe.PerceptualHasher {
+	if provider == nil {
+		return nil
+	}
+	return func(ctx context.Context, imagePath string) (uint64, error) {
+		thumbnail, err := serveImagor(ctx, provider, imagePath, dedupe.PerceptualParams())
+		if err != nil {
+			return 0, err
+		}
+		return dedupe.DHash(thumbnail)
+	}
+}
+
+// newMetadataReader reads the metadata of default storage images with the
+// embedded imagor
+func newMetadataReader(provider *imagorprovider.Provider) libraryscan.MetadataReader {
+	if provider == nil {
+		return nil
+	}
+	return func(ctx context.Context, imagePath string) ([]byte, error) {
+		return serveImagor(ctx, provider, imagePath, imagorpath.Params{Meta: true})
+	}
+}
+
+// newLabelHook returns the hook sending new default storage images to the
+// classification service, nil when not configured
+func newLabelHook(cfg *config.Config, services *bootstrap.Services) *labelhook.Hook {
+	if cfg.LabelHookURL == "" || services.StorageProvider == nil || services.ImagorProvider == nil {
+		return nil
+	}
+	baseURL := cfg.LabelHookImageBaseURL
+	if baseURL == "" {
+		baseURL = cfg.AppUrl
+	}
+	baseURL = strings.TrimRight(baseURL, "/") + "/imagor"
+	imageURL := func(imagePath string) (string, error) {
+		// Large enough for classifiers, small enough to keep downloads quick
+		u, err := services.ImagorProvider.GenerateURL(imagePath, imagorpath.Params{
+			FitIn:   true,
+			Width:   1024,
+			Height:  1024,
+			Filters: imagorpath.Filters{{Name: "format", Args: "jpeg"}},
+		})
+		if err != nil {
+			return "", err
+		}
+		return baseURL + u, nil
+	}
+	return labelhook.New(cfg.LabelHookURL, imageURL,
+		labelhook.WithSecret(cfg.LabelHookSecret),
+		labelhook.WithLogger(services.Logger))
+}
+
+// newFaceScanner returns the scanner detecting faces with the configured
+// backend, nil when face detection is disabled or fails to start
+func newFaceScanner(cfg *config.Config, services *bootstrap.Services) *faces.Scanner {
+	if cfg.FaceDetector == "" || services.FaceStore == nil || services.StorageProvider == nil || services.ImagorProvider == nil {
+		return nil
+	}
+	detector, err := faces.NewDetector(cfg.FaceDetector, faces.Options{URL: cfg.FaceDetectorURL})
+	if err != nil {
+		services.Logger.Warn("Face detection disabled", zap.Error(err))
+		return nil
+	}
+	return faces.NewScanner(services.FaceStore, detector, cfg.FaceMatchThreshold, services.Logger)
+}
+
+// newUploadScanner returns the scanner checking uploads for malware with
+// the configured backend and the quarantine keeping infected ones, nil when
+// upload scanning is disabled
+func newUploadScanner(cfg *config.Config) (virusscan.Scanner, *virusscan.Quarantine, error) {
+	if cfg.UploadScanner == "" {
+		return nil, nil, nil
+	}
+	scanner, err := virusscan.NewScanner(cfg.UploadScanner, virusscan.Options{URL: cfg.UploadScannerURL})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create upload scanner: %w", err)
+	}
+	var quarantine *virusscan.Quarantine
+	if cfg.UploadQuarantineDir != "" {
+		quarantine = virusscan.NewQuarantine(cfg.UploadQuarantineDir)
+	}
+	return scanner, quarantine, nil
+}
+
+// newFaceRenderer renders default storage images for face detection with
+// the embedded imagor
+func newFaceRenderer(provider *imagorprovider.Provider) faces.Renderer {
+	return func(ctx context.Context, imagePath string) ([]byte, error) {
+		return serveImagor(ctx, provider, imagePath, faces.RenderParams())
+	}
+}
+
+// serveImagor renders a default storage image with the embedded imagor
+// in-process. Its callers are bulk scans, rendering in the background class
+// so they never take the slots reserved for interactive requests.
+func serveImagor(ctx context.Context, provider *imagorprovider.Provider, imagePath string, params imagorpath.Params) ([]byte, error) {
+	ctx = jobqueue.WithClass(ctx, jobqueue.Background)
+	app := provider.Imagor()
+	if app == nil {
+		return nil, fmt.Errorf("imagor is not available")
+	}
+	imagorURL, err := provider.GenerateURL(imagePath, params)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imagorURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	rec := httptest.NewRecorder()
+	app.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		return nil, fmt.Errorf("imagor returned status %d", rec.Code)
+	}
+	return rec.Body.Bytes(), nil
+}
+
+// newLibraryScan returns the library scan job refreshing the listings,
+// metadata and hashes of the default storage, and the scheduler running it
+// on config.library_scan_schedule. Both are nil without a default storage.
+func newLibraryScan(services *bootstrap.Services, listCache *listcache.Cache, duplicateScanner *dedupe.Scanner, operations *operation.Manager, notifier *notify.Notifier) (*libraryscan.Job, *scheduler.Scheduler) {
+	if services.StorageProvider == nil {
+		return nil, nil
+	}
+	options := []libraryscan.Option{
+		libraryscan.WithListCache(listCache),
+		libraryscan.WithNotifier(notifier),
+		libraryscan.WithLogger(services.Logger),
+	}
+	if services.StorageStatsStore != nil {
+		options = append(options, libraryscan.WithStats(services.StorageStatsStore))
+	}
+	if services.FileJournal != nil {
+		options = append(options, libraryscan.WithJournal(services.FileJournal))
+	}
+	if services.FileMetaStore != nil {
+		options = append(options, libraryscan.WithMetadata(services.FileMetaStore, newMetadataReader(services.ImagorProvider)))
+	}
+	if duplicateScanner != nil {
+		options = append(options, libraryscan.WithHashes(duplicateScanner, newPerceptualHasher(services.ImagorProvider)))
+	}
+	job := libraryscan.NewJob(libraryscan.NewScanner(options...), services.StorageProvider.GetStorage, operations, services.Logger)
+	schedule := scheduler.New(services.RegistryStore, services.Config, services.Logger)
+	schedule.Add(libraryscan.OperationKind, libraryscan.ScheduleKey, job.Sync)
+	return job, schedule
+}
+
+// webdavEnabled reports whether the storage is served over WebDAV, which
+// needs API tokens to sign in with
+func webdavEnabled(cfg *config.Config, services *bootstrap.Services) bool {
+	return cfg.WebDAVEnabled && !cfg.EmbeddedMode && services.APITokenStore != nil
+}
+
+// s3GatewayEnabled reports whether the storage is served over the S3 API,
+// which needs S3 access keys to sign requests with
+func s3GatewayEnabled(cfg *config.Config, services *bootstrap.Services) bool {
+	return cfg.S3GatewayEnabled && !cfg.EmbeddedMode && services.S3KeyStore != nil
+}
+
+// newVideoStreamManager returns nil when video streams are disabled or ffmpeg
+// is missing
+func newVideoStreamManager(cfg *config.Config, logger *zap.Logger) *videostream.Manager {
+	if !cfg.VideoStreamEnabled {
+		return nil
+	}
+	transcoder, err := videostream.NewFFmpeg(cfg.HLSFFmpegPath)
+	if err != nil {
+		logger.Warn("Video streams disabled", zap.Error(err))
+		return nil
+	}
+	manager, err := videostream.NewManager(logger, transcoder, cfg.VideoStreamCacheDir,
+		videostream.WithMaxProcesses(cfg.VideoStreamMaxProcesses),
+	)
+	if err != nil {
+		logger.Warn("Video streams disabled", zap.Error(err))
+		return nil
+	}
+	return manager
+}
+
 func processingUsageCleanupLoopConfig(services *bootstrap.Services, mode Mode, cloudConfig management.CloudConfig) (time.Duration, time.Duration, bool) {
 	if mode != ModeCloud || services == nil || services.ProcessingUsageStore == nil || !cloudConfig.ManagementJobsEnabled {
 		return 0, 0, false
@@ -116,6 +492,17 @@ func newPostgresAdvisoryLockSyncFunc(ctx context.Context, db *bun.DB, logger *za
 	}
 }
 
+// setupTracing starts exporting traces when configured. Tracing stays off
+// on an invalid exporter configuration rather than failing startup.
+func setupTracing(services *bootstrap.Services) func(context.Context) error {
+	shutdown, err := tracing.Setup(services.Config, services.Logger)
+	if err != nil {
+		services.Logger.Warn("Tracing disabled", zap.Error(err))
+		return nil
+	}
+	return shutdown
+}
+
 func New(cfg *config.Config, embedFS fs.FS, logger *zap.Logger, args []string, mode Mode) (*Server, error) {
 	// Initialize all services using bootstrap package
 	var (
@@ -135,6 +522,8 @@ func New(cfg *config.Config, embedFS fs.FS, logger *zap.Logger, args []string, m
 }
 
 func NewFromServices(cfg *config.Config, embedFS fs.FS, logger *zap.Logger, services *bootstrap.Services, mode Mode, cloudConfig management.CloudConfig, cloudFactories management.CloudFactories) (*Server, error) {
+	shutdownTracing := setupTracing(services)
+
 	// Create auth handler. services.OrgStore is nil for self-hosted deployments
 	// and non-nil only for cloud multi-tenant deployments.
 	multiTenant := mode == ModeCloud && services.OrgStore != nil && services.SpaceStore != nil
@@ -156,9 +545,100 @@ func NewFromServices(cfg *config.Config, embedFS fs.FS, logger *zap.Logger, serv
 		}
 	}
 
+	// Update checks stay offline unless config.update_check_enabled is set
+	updateChecker := updatecheck.New(services.Logger, services.RegistryStore, services.Config, version.Get())
+	notifier := notify.New(services.Logger, services.RegistryStore, services.Config)
+
+	fileEvents := events.NewBroker()
+	var listCache *listcache.Cache
+	if cfg.ListCacheTTL > 0 {
+		listCacheOptions := []listcache.Option{
+			listcache.WithTTL(cfg.ListCacheTTL),
+			listcache.WithMaxItems(cfg.ListCacheMaxItems),
+			listcache.WithLogger(services.Logger),
+		}
+		if cfg.ListCachePersist && services.DB != nil {
+			listCacheOptions = append(listCacheOptions, listcache.WithStore(listcache.NewStore(services.DB, services.Logger)))
+		}
+		listCache = listcache.New(listCacheOptions...)
+	}
+	// Shared so scheduled maintenance runs show up in the operations API
+	operations := operation.NewManager(services.Logger,
+		operation.WithWorkers(cfg.OperationWorkers),
+		operation.WithStore(services.OperationStore))
+	dbMaintenance := dbmaintenance.New(services.DB, operations, services.Logger)
+	var duplicateScanner *dedupe.Scanner
+	if services.DuplicateStore != nil {
+		duplicateScanner = dedupe.NewScanner(services.DuplicateStore, services.Logger)
+	}
+	libraryScan, scanSchedule := newLibraryScan(services, listCache, duplicateScanner, operations, notifier)
+	labelHook := newLabelHook(cfg, services)
+	var webhooks *webhook.Dispatcher
+	if services.WebhookStore != nil {
+		webhooks = webhook.NewDispatcher(services.WebhookStore, webhook.WithLogger(services.Logger))
+	}
+	faceScanner := newFaceScanner(cfg, services)
+	// Uploads are never stored unscanned when scanning is configured
+	uploadScanner, uploadQuarantine, err := newUploadScanner(cfg)
+	if err != nil {
+		return nil, err
+	}
+	hlsManager := newHLSManager(cfg, services.Logger, services.ImagorProvider)
+	videoStreams := newVideoStreamManager(cfg, services.Logger)
+	// Loaded up front so restrictions apply from the first request
+	operationAllowList := allowlist.New(services.RegistryStore, services.Logger)
+	if err := operationAllowList.Sync(); err != nil {
+		services.Logger.Warn("Failed to load GraphQL allow-lists", zap.Error(err))
+	}
+	networkACL := netacl.New(services.RegistryStore, services.Logger)
+	if err := networkACL.Sync(); err != nil {
+		services.Logger.Warn("Failed to load network ACLs", zap.Error(err))
+	}
+	queryLimits := querylimit.New(services.RegistryStore, services.Logger)
+	if err := queryLimits.Sync(); err != nil {
+		services.Logger.Warn("Failed to load GraphQL query limits", zap.Error(err))
+	}
+	thumbnailPresets := thumbnailpreset.New(services.RegistryStore, services.Logger)
+	if err := thumbnailPresets.Sync(); err != nil {
+		services.Logger.Warn("Failed to load thumbnail presets", zap.Error(err))
+	}
+	bulkDownloads := bulkdownload.NewHandler(bulkdownload.NewManager(bulkdownload.WithTTL(cfg.BulkDownloadTTL)), "/api/downloads")
+	chunkUploads, err := chunkupload.NewManager(cfg.ChunkUploadDir, chunkupload.WithTTL(cfg.ChunkUploadTTL))
+	if err != nil {
+		services.Logger.Warn("Chunked uploads disabled", zap.Error(err))
+		chunkUploads = nil
+	}
+	// Access keys are only managed while the S3 gateway accepts them
+	var s3KeyStore s3key.Store
+	if s3GatewayEnabled(cfg, services) {
+		s3KeyStore = services.S3KeyStore
+	}
+
+	// Requests and bandwidth of each user, shared link and anonymous client.
+	// Image URLs carry the signed key of the session they were issued to.
+	var (
+		rateLimiter     *ratelimit.Limiter
+		rateLimitSecret string
+	)
+	if limits := (ratelimit.Limits{
+		RequestsPerMinute: cfg.RateLimitRequests,
+		BytesPerHour:      int64(cfg.RateLimitBandwidth) << 20,
+	}); limits.Enabled() {
+		rateLimitSecret = services.Config.JWTSecret
+		rateLimiter = ratelimit.New(limits, rateLimitSecret)
+	}
+
+	// Client addresses behind trusted reverse proxies, for every client
+	// address read from RemoteAddr: rate limits, network ACLs, audit entries
+	trustedProxies, err := clientip.Parse(cfg.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+
 	storageResolver := resolver.NewResolver(
 		services.StorageProvider,
-		services.RegistryStore,
+		// Reads settings once per operation, see resolver.LoaderMiddleware
+		registrystore.NewRequestCached(services.RegistryStore),
 		services.UserStore,
 		services.ImagorProvider,
 		services.Config, // Use enhanced config from services
@@ -176,6 +656,55 @@ func NewFromServices(cfg *config.Config, embedFS fs.FS, logger *zap.Logger, serv
 		resolver.WithBillingService(services.BillingService),
 		resolver.WithProcessingOriginResolver(processingOriginResolver),
 		resolver.WithSignupRuntime(services.SignupVerification),
+		resolver.WithUpdateChecker(updateChecker),
+		resolver.WithNotifier(notifier),
+		resolver.WithOperationManager(operations),
+		resolver.WithDatabaseMaintenance(dbMaintenance),
+		resolver.WithBackups(services.DB),
+		resolver.WithEncryptionKeyRotation(services.DB, services.Encryption),
+		resolver.WithLibraryScan(libraryScan, scanSchedule),
+		resolver.WithURLImporter(urlimport.New(
+			urlimport.WithMaxBytes(cfg.URLImportMaxBytes),
+			urlimport.WithTimeout(cfg.URLImportTimeout),
+			urlimport.WithAllowPrivateNetworks(cfg.URLImportAllowPrivateNetworks))),
+		resolver.WithHLSManager(hlsManager),
+		resolver.WithVideoStreamManager(videoStreams),
+		resolver.WithTagStore(services.TagStore),
+		resolver.WithFavoriteStore(services.FavoriteStore),
+		resolver.WithAlbumStore(services.AlbumStore),
+		resolver.WithTenantStore(services.TenantStore),
+		resolver.WithCommentStore(services.CommentStore),
+		resolver.WithRatingStore(services.RatingStore),
+		resolver.WithAuditLog(services.AuditLog),
+		resolver.WithWebhooks(services.WebhookStore, webhooks),
+		resolver.WithFileJournal(services.FileJournal, cfg.FileJournalRetention),
+		resolver.WithAutoUploadStore(services.AutoUploadStore),
+		resolver.WithAPITokenStore(services.APITokenStore),
+		resolver.WithS3KeyStore(s3KeyStore),
+		resolver.WithSessionStore(services.SessionStore),
+		resolver.WithShareStore(services.ShareStore, cfg.AppUrl),
+		resolver.WithFileMetaStore(services.FileMetaStore),
+		resolver.WithDuplicateScanner(duplicateScanner),
+		resolver.WithUploadDedupe(cfg.UploadDedupe),
+		resolver.WithUploadCollision(cfg.UploadCollision),
+		resolver.WithUploadScanner(uploadScanner, uploadQuarantine),
+		resolver.WithUploadExtensions(mimetype.ParseExtensions(cfg.UploadAllowedExtensions)),
+		resolver.WithFaceScanner(faceScanner),
+		resolver.WithListCache(listCache),
+		resolver.WithImageEditStore(services.ImageEditStore),
+		resolver.WithFolderCoverStore(services.FolderCoverStore),
+		resolver.WithFolderSettingsStore(services.FolderSettingsStore),
+		resolver.WithStorageStatsStore(services.StorageStatsStore),
+		resolver.WithEvents(fileEvents),
+		resolver.WithBulkDownloads(bulkDownloads),
+		resolver.WithChunkUploads(chunkUploads),
+		resolver.WithProcessingScheduler(services.ProcessingScheduler),
+		resolver.WithOperationAllowList(operationAllowList),
+		resolver.WithNetworkACL(networkACL),
+		resolver.WithQueryLimits(queryLimits),
+		resolver.WithThumbnailPresets(thumbnailPresets),
+		resolver.WithRateLimitKeys(rateLimitSecret),
+		resolver.WithAPICompatMode(cfg.APICompatMode),
 		templatePreviewRenderer,
 	)
 	schema := gql.NewExecutableSchema(gql.Config{Resolvers: storageResolver})
@@ -187,13 +716,52 @@ func NewFromServices(cfg *config.Config, embedFS fs.FS, logger *zap.Logger, serv
 	gqlHandler.AddTransport(transport.POST{})
 	gqlHandler.AddTransport(transport.MultipartForm{})
 
-	// Add WebSocket transport for subscriptions if needed
-	// gqlHandler.AddTransport(transport.Websocket{
-	//     KeepAlivePingInterval: 10 * time.Second,
-	// })
+	// Subscriptions are authenticated by the connection_init payload rather
+	// than cookies, so cross-origin connections carry no ambient credentials
+	var homePathUsers middleware.UserLookup
+	var homePathTenants middleware.TenantLookup
+	if !cfg.EmbeddedMode && services.UserStore != nil {
+		homePathUsers = services.UserStore
+		if services.TenantStore != nil {
+			homePathTenants = services.TenantStore
+		}
+	}
+	gqlHandler.AddTransport(transport.Websocket{
+		Upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+		InitFunc:              middleware.WebsocketInit(services.TokenManager, services.SessionStore, homePathUsers, homePathTenants),
+		KeepAlivePingInterval: 10 * time.Second,
+	})
 
 	// Add useful extensions
 	gqlHandler.Use(extension.Introspection{})
+	// Clients may send the hash of a query they sent before instead of the query
+	gqlHandler.Use(extension.AutomaticPersistedQuery{Cache: lru.New[string](persistedQueryCacheSize)})
+	// Refuses operations over the complexity and depth limits of the registry
+	gqlHandler.Use(queryLimits.Extension())
+
+	// Outermost so resolver spans include the time spent in the checks below
+	if tracing.Enabled(services.Config) {
+		gqlHandler.AroundResponses(tracing.ResponseMiddleware())
+		gqlHandler.AroundFields(tracing.FieldMiddleware())
+	}
+
+	// Cancels queries and mutations running past the timeout of the registry
+	gqlHandler.AroundResponses(queryLimits.ResponseMiddleware())
+	// Batches and caches the lookups repeated for every item of a listing
+	gqlHandler.AroundOperations(resolver.LoaderMiddleware())
+
+	// Strict API mode rejects deprecated fields so integrators catch them before removal
+	if !cfg.APICompatMode {
+		gqlHandler.AroundFields(apiversion.FieldMiddleware(false))
+	}
+	// Per-role allow-lists shrink what guest and user tokens can reach
+	gqlHandler.AroundFields(operationAllowList.FieldMiddleware())
+	// Records every mutation that passed the allow-list
+	if services.AuditLog != nil {
+		gqlHandler.AroundFields(auditlog.FieldMiddleware(services.AuditLog, services.Logger))
+	}
 
 	authHandler := httphandler.NewAuthHandler(
 		services.TokenManager,
@@ -211,6 +779,11 @@ func NewFromServices(cfg *config.Config, embedFS fs.FS, logger *zap.Logger, serv
 			SignupRuntime:            services.SignupVerification,
 			PreviewTTL:               15 * time.Minute,
 			ProcessingOriginResolver: processingOriginResolver,
+			ShareStore:               services.ShareStore,
+			SessionStore:             services.SessionStore,
+			TenantsEnabled:           cfg.TenantsEnabled,
+			Webhooks:                 webhooks,
+			Notifier:                 notifier,
 		},
 	)
 
@@ -241,7 +814,11 @@ func NewFromServices(cfg *config.Config, embedFS fs.FS, logger *zap.Logger, serv
 	mux.HandleFunc("/api/auth/public-preview-session", authHandler.PublicPreviewSession())
 	mux.HandleFunc("/api/auth/preview-session", authHandler.PreviewSession())
 	mux.HandleFunc("/api/auth/guest", authHandler.GuestLogin())
+	mux.HandleFunc("/api/auth/share", authHandler.ShareLinkLogin())
 	mux.HandleFunc("/api/auth/embedded-guest", authHandler.EmbeddedGuestLogin())
+	if cfg.EmbeddedMode && cfg.EmbedTokenSecret != "" {
+		mux.HandleFunc("/api/embed/token", httphandler.NewEmbedTokenHandler(services.TokenManager, cfg.EmbedTokenSecret, services.Logger).Token())
+	}
 
 	// Add the new endpoints
 	mux.HandleFunc("/api/auth/first-run", authHandler.CheckFirstRun())
@@ -280,15 +857,102 @@ func NewFromServices(cfg *config.Config, embedFS fs.FS, logger *zap.Logger, serv
 		registerSelfHostedAuthRoutes(mux)
 	}
 
+	// Admin recovery tokens only ever appear in the server log, so the
+	// endpoint is served on self-hosted installs with a user database
+	var adminRecovery *adminrecovery.Manager
+	if !multiTenant && !cfg.EmbeddedMode && services.UserStore != nil {
+		adminRecovery = adminrecovery.New(services.Logger, adminrecovery.WithFlagFile(cfg.AdminRecoveryFile))
+		mux.HandleFunc("/api/auth/admin-recovery", httphandler.NewAdminRecoveryHandler(adminRecovery, services.UserStore, services.Logger).Recover())
+		if cfg.AdminRecovery {
+			if _, err := adminRecovery.Issue("--admin-recovery"); err != nil {
+				services.Logger.Error("Failed to issue admin recovery token", zap.Error(err))
+			}
+		}
+	}
+
 	// License endpoints (public - no auth required)
 	licenseHandler := httphandler.NewLicenseHandler(services.LicenseService, services.RegistryStore, services.Logger)
 	mux.HandleFunc("/api/public/license-status", licenseHandler.GetPublicStatus())
 	mux.HandleFunc("/api/public/activate-license", licenseHandler.ActivateLicense())
 
+	// Everything the SPA needs before login in one call (public - no auth required)
+	bootstrapHandler := httphandler.NewBootstrapHandler(authHandler, licenseHandler, httphandler.BootstrapConfig{
+		AppURL:        cfg.AppUrl,
+		AppHomeTitle:  cfg.AppHomeTitle,
+		AuthProviders: authProviders(cloudConfig),
+		ServerVersion: version.Get(),
+		APIVersion:    apiversion.Current,
+		APICompatMode: cfg.APICompatMode,
+		Capabilities:  serverCapabilities(cfg, services, hlsManager, videoStreams, chunkUploads, dbMaintenance, libraryScan, labelHook, faceScanner),
+	})
+	mux.HandleFunc("/api/bootstrap", bootstrapHandler.Get())
+
 	// Protected endpoints
-	protectedHandler := middleware.JWTMiddleware(services.TokenManager)(gqlHandler)
+	var protectedHandler http.Handler = gqlHandler
+	if !cfg.EmbeddedMode && services.UserStore != nil {
+		// Scope users with a home path or tenant, resolved per request from their user record
+		protectedHandler = middleware.HomePathMiddleware(homePathUsers, homePathTenants)(protectedHandler)
+	}
+	protectedHandler = rateLimiter.Middleware(protectedHandler)
+	protectedHandler = middleware.JWTMiddleware(services.TokenManager, services.APITokenStore, services.SessionStore)(protectedHandler)
+	protectedHandler = auditlog.ClientIPMiddleware(protectedHandler)
 	mux.Handle("/api/query", protectedHandler)
 
+	// HLS sessions are capability URLs issued by the videoPlayback query
+	if hlsManager != nil {
+		mux.Handle("/api/hls/", rateLimiter.Middleware(http.StripPrefix("/api/hls", hlsManager)))
+	}
+	// Video stream sessions are capability URLs issued by the videoStream query
+	if videoStreams != nil {
+		mux.Handle("/api/stream/", rateLimiter.Middleware(http.StripPrefix("/api/stream", videoStreams)))
+	}
+	// WebDAV clients sign in with an API token, their sessions scoped like
+	// those of the GraphQL API
+	if webdavEnabled(cfg, services) {
+		var davHandler http.Handler = davserver.NewHandler(storageResolver.DAVGallery(), "/dav", services.Logger)
+		if services.UserStore != nil {
+			davHandler = middleware.HomePathMiddleware(homePathUsers, homePathTenants)(davHandler)
+		}
+		davHandler = rateLimiter.Middleware(davHandler)
+		davHandler = middleware.APITokenBasicAuthMiddleware("Imagor Studio", services.TokenManager, services.APITokenStore, services.SessionStore)(davHandler)
+		davHandler = auditlog.ClientIPMiddleware(davHandler)
+		mux.Handle("/dav", davHandler)
+		mux.Handle("/dav/", davHandler)
+	}
+	// S3 clients sign requests with an access key, verified against the
+	// path before the prefix is stripped
+	if s3GatewayEnabled(cfg, services) {
+		var s3Handler http.Handler = http.StripPrefix("/s3", s3gateway.NewHandler(storageResolver.S3Gallery(), cfg.S3GatewayBucket, cfg.S3GatewayWritable, services.Logger))
+		if services.UserStore != nil {
+			s3Handler = middleware.HomePathMiddleware(homePathUsers, homePathTenants)(s3Handler)
+		}
+		s3Handler = rateLimiter.Middleware(s3Handler)
+		s3Handler = middleware.S3SignatureMiddleware(services.S3KeyStore)(s3Handler)
+		s3Handler = auditlog.ClientIPMiddleware(s3Handler)
+		mux.Handle("/s3", s3Handler)
+		mux.Handle("/s3/", s3Handler)
+	}
+	// gRPC calls are served on a port of their own, authenticated and
+	// scoped like GraphQL requests
+	var grpcHandler http.Handler
+	if grpcEnabled(cfg) {
+		grpcHandler = grpcapi.NewHandler(storageResolver.Query(), storageResolver.Mutation(), services.Logger,
+			grpcapi.WithAllowList(operationAllowList),
+			grpcapi.WithAuditLog(services.AuditLog),
+		)
+		if services.UserStore != nil {
+			grpcHandler = middleware.HomePathMiddleware(homePathUsers, homePathTenants)(grpcHandler)
+		}
+		grpcHandler = rateLimiter.Middleware(grpcHandler)
+		grpcHandler = middleware.JWTMiddleware(services.TokenManager, services.APITokenStore, services.SessionStore)(grpcHandler)
+		grpcHandler = auditlog.ClientIPMiddleware(grpcHandler)
+		grpcHandler = middleware.ErrorMiddleware(services.Logger)(networkACL.Middleware(services.AuditLog)(grpcHandler))
+		grpcHandler = trustedProxies.Middleware(grpcHandler)
+	}
+	// Bulk download tokens are capability URLs issued by createBulkDownload
+	// and prepareDownload
+	mux.Handle("/api/downloads/", rateLimiter.Middleware(http.StripPrefix("/api/downloads", bulkDownloads)))
+
 	if mode == ModeCloud && multiTenant && cloudFactories.InternalRoutes != nil {
 		cloudFactories.InternalRoutes(mux, cloudServices)
 	}
@@ -298,11 +962,18 @@ func NewFromServices(cfg *config.Config, embedFS fs.FS, logger *zap.Logger, serv
 		registerProcessingPreviewRoutes(mux, services)
 	}
 
-	if err := registerProcessingOrSPA(mux, cfg, embedFS, services, cloudConfig); err != nil {
+	if err := registerProcessingOrSPA(mux, cfg, embedFS, services, cloudConfig, rateLimiter); err != nil {
 		return nil, err
 	}
 
-	baseHandler := middleware.ErrorMiddleware(services.Logger)(mux)
+	compressionGroups, err := compression.ParseGroups(cfg.Compression)
+	if err != nil {
+		return nil, err
+	}
+
+	// Refuses clients outside the network ACL of the route group
+	baseHandler := middleware.ErrorMiddleware(services.Logger)(networkACL.Middleware(services.AuditLog)(mux))
+	baseHandler = compression.Middleware(compressionGroups)(baseHandler)
 	baseHandler = middleware.FrameAncestorsMiddleware(
 		middleware.NewFrameAncestorsConfig(cfg.AppUrl, cfg.CORSOrigins, cfg.AppFrameAncestors),
 	)(baseHandler)
@@ -327,6 +998,10 @@ func NewFromServices(cfg *config.Config, embedFS fs.FS, logger *zap.Logger, serv
 		}
 		h = middleware.CORSMiddleware(corsConfig)(baseHandler)
 	}
+	if tracing.Enabled(services.Config) {
+		h = tracing.Middleware(h)
+	}
+	h = trustedProxies.Middleware(h)
 
 	// Create HTTP server instance
 	addr := fmt.Sprintf(":%d", cfg.Port)
@@ -338,6 +1013,19 @@ func NewFromServices(cfg *config.Config, embedFS fs.FS, logger *zap.Logger, serv
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
+	var redirectServer *http.Server
+	if cfg.TLSEnabled() {
+		tlsConfig, rs, err := newTLS(cfg)
+		if err != nil {
+			return nil, err
+		}
+		httpServer.TLSConfig = tlsConfig
+		redirectServer = rs
+	}
+	var grpcServer *http.Server
+	if grpcHandler != nil {
+		grpcServer = newGRPCServer(cfg, grpcHandler, httpServer.TLSConfig)
+	}
 
 	// Start background 30-second sync loop: pulls registry → imagor signer + storage.
 	syncCtx, syncCancel := context.WithCancel(context.Background())
@@ -349,16 +1037,73 @@ func NewFromServices(cfg *config.Config, embedFS fs.FS, logger *zap.Logger, serv
 
 	// Build the sync functions list. StorageProvider is nil in processing mode
 	// (no management storage on processing nodes), so guard against nil.
-	syncFuncs := []func() error{services.ImagorProvider.Sync}
+	syncFuncs := []func() error{services.ImagorProvider.Sync, operationAllowList.Sync, networkACL.Sync, queryLimits.Sync, thumbnailPresets.Sync}
 	if services.ProcessingUsageRecorder != nil {
 		syncFuncs = append(syncFuncs, func() error {
 			return services.ProcessingUsageRecorder.Flush(syncCtx)
 		})
 	}
 	if services.StorageProvider != nil {
-		syncFuncs = append(syncFuncs, services.StorageProvider.ReloadFromRegistry)
+		syncFuncs = append(syncFuncs, services.StorageProvider.ReloadFromRegistry, services.StorageProvider.SyncMounts)
+		if cfg.FileStorageWatch {
+			// Runs after the storage reload so a new base directory is picked up
+			// on the same tick
+			fileWatcher := fswatch.New(fileWatchHandler(fileEvents, services.FileMetaStore, listCache, services.Logger),
+				fswatch.WithLogger(services.Logger),
+				fswatch.WithPollInterval(cfg.FileStorageWatchInterval))
+			fileWatchSync := newFileWatchSync(syncCtx, fileWatcher, services.StorageProvider.FileStorageDir)
+			_ = fileWatchSync()
+			syncFuncs = append(syncFuncs, fileWatchSync)
+		}
+	}
+	if adminRecovery != nil {
+		syncFuncs = append(syncFuncs, adminRecovery.Sync)
 	}
 	startSyncLoop(syncCtx, 30*time.Second, services.Logger, syncFuncs...)
+	if chunkUploads != nil {
+		startSyncLoop(syncCtx, 10*time.Minute, services.Logger, chunkUploads.Sweep)
+	}
+	// Heartbeats running operations, fails those abandoned by a stopped replica
+	startSyncLoop(syncCtx, time.Minute, services.Logger, operations.Sweep)
+	// Checker.Sync is a no-op when disabled or checked within the last day
+	startSyncLoop(syncCtx, time.Hour, services.Logger, updateChecker.Sync)
+	if dbMaintenance != nil && cfg.SQLiteMaintenanceInterval > 0 {
+		startSyncLoop(syncCtx, cfg.SQLiteMaintenanceInterval, services.Logger, dbMaintenance.Sync)
+	}
+	if services.AuditLog != nil && cfg.AuditLogRetention > 0 {
+		startSyncLoop(syncCtx, time.Hour, services.Logger, auditlog.NewPruneFunc(services.AuditLog, cfg.AuditLogRetention, services.Logger))
+	}
+	if services.SessionStore != nil {
+		startSyncLoop(syncCtx, time.Hour, services.Logger, sessionstore.NewPruneFunc(services.SessionStore, services.Logger))
+	}
+	if duplicateScanner != nil && cfg.DuplicateScanInterval > 0 {
+		duplicateScan := dedupe.NewJob(duplicateScanner, services.StorageProvider.GetStorage, newPerceptualHasher(services.ImagorProvider), operations, services.Logger)
+		startSyncLoop(syncCtx, cfg.DuplicateScanInterval, services.Logger, duplicateScan.Sync)
+	}
+	if faceScanner != nil && cfg.FaceScanInterval > 0 {
+		faceScan := faces.NewJob(faceScanner, services.StorageProvider.GetStorage, newFaceRenderer(services.ImagorProvider), operations, services.Logger)
+		startSyncLoop(syncCtx, cfg.FaceScanInterval, services.Logger, faceScan.Sync)
+	}
+	if labelHook != nil {
+		go labelHook.Run(syncCtx, fileEvents, registrystore.SystemOwnerID)
+	}
+	if webhooks != nil {
+		go webhooks.Run(syncCtx, fileEvents, registrystore.SystemOwnerID)
+		if cfg.WebhookDeliveryRetention > 0 {
+			startSyncLoop(syncCtx, time.Hour, services.Logger, webhook.NewPruneFunc(services.WebhookStore, cfg.WebhookDeliveryRetention, services.Logger))
+		}
+	}
+	if services.FileJournal != nil {
+		go filejournal.NewRecorder(services.FileJournal, services.Logger).Run(syncCtx, fileEvents, registrystore.SystemOwnerID)
+		if cfg.FileJournalRetention > 0 {
+			startSyncLoop(syncCtx, time.Hour, services.Logger, filejournal.NewPruneFunc(services.FileJournal, cfg.FileJournalRetention, services.Logger))
+		}
+	}
+	if scanSchedule != nil {
+		// Checked every minute, the finest cron resolution
+		_ = scanSchedule.Sync()
+		startSyncLoop(syncCtx, time.Minute, services.Logger, scanSchedule.Sync)
+	}
 	if cleanupInterval, cleanupRetention, ok := processingUsageCleanupLoopConfig(services, mode, cloudConfig); ok {
 		cleanupSyncFunc := newPostgresAdvisoryLockSyncFunc(
 			syncCtx,
@@ -376,14 +1121,20 @@ func NewFromServices(cfg *config.Config, embedFS fs.FS, logger *zap.Logger, serv
 	}
 
 	return &Server{
-		cfg:        cfg,
-		services:   services,
-		httpServer: httpServer,
-		syncCancel: syncCancel,
+		cfg:             cfg,
+		services:        services,
+		httpServer:      httpServer,
+		redirectServer:  redirectServer,
+		grpcServer:      grpcServer,
+		syncCancel:      syncCancel,
+		hlsManager:      hlsManager,
+		videoStreams:    videoStreams,
+		shutdownTracing: shutdownTracing,
 	}, nil
 }
 
 func NewProcessingFromServices(cfg *config.Config, embedFS fs.FS, logger *zap.Logger, services *bootstrap.Services) (*Server, error) {
+	shutdownTracing := setupTracing(services)
 	mux := http.NewServeMux()
 
 	// Public processing endpoints.
@@ -404,7 +1155,7 @@ func NewProcessingFromServices(cfg *config.Config, embedFS fs.FS, logger *zap.Lo
 		registerProcessingPreviewRoutes(mux, services)
 	}
 
-	if err := registerProcessingOrSPA(mux, cfg, embedFS, services, management.CloudConfig{}); err != nil {
+	if err := registerProcessingOrSPA(mux, cfg, embedFS, services, management.CloudConfig{}, nil); err != nil {
 		return nil, err
 	}
 
@@ -428,6 +1179,9 @@ func NewProcessingFromServices(cfg *config.Config, embedFS fs.FS, logger *zap.Lo
 		}
 		h = middleware.CORSMiddleware(corsConfig)(baseHandler)
 	}
+	if tracing.Enabled(services.Config) {
+		h = tracing.Middleware(h)
+	}
 
 	addr := fmt.Sprintf(":%d", cfg.Port)
 	httpServer := &http.Server{
@@ -452,15 +1206,16 @@ func NewProcessingFromServices(cfg *config.Config, embedFS fs.FS, logger *zap.Lo
 		})
 	}
 	if services.StorageProvider != nil {
-		syncFuncs = append(syncFuncs, services.StorageProvider.ReloadFromRegistry)
+		syncFuncs = append(syncFuncs, services.StorageProvider.ReloadFromRegistry, services.StorageProvider.SyncMounts)
 	}
 	startSyncLoop(syncCtx, 30*time.Second, services.Logger, syncFuncs...)
 
 	return &Server{
-		cfg:        cfg,
-		services:   services,
-		httpServer: httpServer,
-		syncCancel: syncCancel,
+		cfg:             cfg,
+		services:        services,
+		httpServer:      httpServer,
+		syncCancel:      syncCancel,
+		shutdownTracing: shutdownTracing,
 	}, nil
 }
 
@@ -471,12 +1226,21 @@ func registerSelfHostedAuthRoutes(mux *http.ServeMux) {
 	})
 }
 
+// persistedQueryCacheSize is the number of automatic persisted queries kept,
+// clients send the full query again when theirs was evicted
+const persistedQueryCacheSize = 1000
+
+// thumbnailETagMaxSize is the largest imagor response held back to hash
+// its ETag, larger ones are streamed
+const thumbnailETagMaxSize = 4 << 20
+
 func registerProcessingOrSPA(
 	mux *http.ServeMux,
 	cfg *config.Config,
 	embedFS fs.FS,
 	services *bootstrap.Services,
 	cloudConfig management.CloudConfig,
+	rateLimiter *ratelimit.Limiter,
 ) error {
 	if services.SpaceConfigStore != nil {
 		baseDomain := ""
@@ -493,16 +1257,15 @@ func registerProcessingOrSPA(
 		return nil
 	}
 
-	mux.Handle("/imagor/", http.StripPrefix("/imagor", services.ImagorProvider.Imagor()))
+	// Thumbnails without a result storage stat get their ETag from the body
+	thumbnails := middleware.ETagMiddleware(thumbnailETagMaxSize)(jobqueue.ClassifyRequests(services.ImagorProvider.Imagor()))
+	mux.Handle("/imagor/", rateLimiter.Middleware(http.StripPrefix("/imagor", thumbnails)))
 
 	staticFS, err := fs.Sub(embedFS, "static")
 	if err != nil {
 		return err
 	}
-	bootstrap := httphandler.AppBootstrap{}
-	if strings.TrimSpace(cloudConfig.GoogleClientID) != "" {
-		bootstrap.AuthProviders = []string{"google"}
-	}
+	bootstrap := httphandler.AppBootstrap{AuthProviders: authProviders(cloudConfig)}
 	mux.Handle("/", httphandler.SPAHandler(staticFS, services.ImagorProvider.Imagor(), services.Logger, bootstrap))
 	return nil
 }
@@ -515,8 +1278,36 @@ func startProcessingSyncIfNeeded(ctx context.Context, services *bootstrap.Servic
 }
 
 func (s *Server) Run() error {
-	s.services.Logger.Info("Server is running", zap.String("address", fmt.Sprintf("http://localhost%s", s.httpServer.Addr)))
-	return s.httpServer.ListenAndServe()
+	if s.grpcServer != nil {
+		go func() {
+			var err error
+			if s.grpcServer.TLSConfig != nil {
+				s.services.Logger.Info("gRPC server is running", zap.String("address", fmt.Sprintf("https://localhost%s", s.grpcServer.Addr)))
+				err = s.grpcServer.ListenAndServeTLS("", "")
+			} else {
+				s.services.Logger.Info("gRPC server is running", zap.String("address", fmt.Sprintf("http://localhost%s", s.grpcServer.Addr)))
+				err = s.grpcServer.ListenAndServe()
+			}
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				s.services.Logger.Error("gRPC server failed", zap.Error(err))
+			}
+		}()
+	}
+	if s.httpServer.TLSConfig == nil {
+		s.services.Logger.Info("Server is running", zap.String("address", fmt.Sprintf("http://localhost%s", s.httpServer.Addr)))
+		return s.httpServer.ListenAndServe()
+	}
+	if s.redirectServer != nil {
+		go func() {
+			s.services.Logger.Info("Redirecting HTTP to HTTPS", zap.String("address", s.redirectServer.Addr))
+			if err := s.redirectServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				s.services.Logger.Error("HTTP redirect server failed", zap.Error(err))
+			}
+		}()
+	}
+	s.services.Logger.Info("Server is running", zap.String("address", fmt.Sprintf("https://localhost%s", s.httpServer.Addr)))
+	// Certificates are set in TLSConfig
+	return s.httpServer.ListenAndServeTLS("", "")
 }
 
 func (s *Server) Handler() http.Handler {
@@ -529,6 +1320,17 @@ func (s *Server) Handler() http.Handler {
 func (s *Server) Shutdown(ctx context.Context) error {
 	s.services.Logger.Debug("Starting graceful shutdown...")
 
+	if s.redirectServer != nil {
+		if err := s.redirectServer.Shutdown(ctx); err != nil {
+			s.services.Logger.Warn("HTTP redirect server shutdown error", zap.Error(err))
+		}
+	}
+	if s.grpcServer != nil {
+		if err := s.grpcServer.Shutdown(ctx); err != nil {
+			s.services.Logger.Warn("gRPC server shutdown error", zap.Error(err))
+		}
+	}
+
 	// Shutdown HTTP server gracefully
 	if err := s.httpServer.Shutdown(ctx); err != nil {
 		s.services.Logger.Error("HTTP server shutdown error", zap.Error(err))
@@ -547,6 +1349,13 @@ func (s *Server) Close() error {
 		s.syncCancel()
 	}
 
+	if s.hlsManager != nil {
+		s.hlsManager.Close()
+	}
+	if s.videoStreams != nil {
+		s.videoStreams.Close()
+	}
+
 	// Shutdown imagor first (includes libvips cleanup)
 	ctx := context.Background()
 	if err := s.services.ImagorProvider.Shutdown(ctx); err != nil {
@@ -554,6 +1363,13 @@ func (s *Server) Close() error {
 		// Continue with other cleanup even if imagor shutdown fails
 	}
 
+	// Flush spans of the requests that just completed
+	if s.shutdownTracing != nil {
+		if err := s.shutdownTracing(ctx); err != nil {
+			s.services.Logger.Warn("Tracing shutdown error", zap.Error(err))
+		}
+	}
+
 	// Close database connection (only if not in embedded mode)
 	if s.services.DB != nil {
 		s.services.Logger.Debug("Closing database connection...")
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// newTLS returns the TLS configuration of the server and the plain HTTP
+// server answering ACME HTTP-01 challenges and redirecting to HTTPS, nil
+// when the plain HTTP port is disabled. Certificates of ACME domains are
+// obtained on the first handshake, by the TLS-ALPN-01 challenge on the
+// HTTPS port or the HTTP-01 challenge on the plain HTTP port.
+func newTLS(cfg *config.Config) (*tls.Config, *http.Server, error) {
+	var (
+		tlsConfig *tls.Config
+		challenge func(http.Handler) http.Handler
+	)
+	if cfg.TLSACMEDomains != "" {
+		var domains []string
+		for _, domain := range strings.Split(cfg.TLSACMEDomains, ",") {
+			if domain = strings.TrimSpace(domain); domain != "" {
+				domains = append(domains, domain)
+			}
+		}
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(domains...),
+			Cache:      autocert.DirCache(cfg.TLSACMECacheDir),
+			Email:      cfg.TLSACMEEmail,
+		}
+		if cfg.TLSACMEDirectoryURL != "" {
+			manager.Client = &acme.Client{DirectoryURL: cfg.TLSACMEDirectoryURL}
+		}
+		tlsConfig = manager.TLSConfig()
+		challenge = manager.HTTPHandler
+	} else {
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+	tlsConfig.MinVersion = tls.VersionTLS12
+
+	if cfg.TLSHTTPPort == 0 {
+		return tlsConfig, nil, nil
+	}
+	var handler http.Handler = httpsRedirect(cfg.Port)
+	if challenge != nil {
+		handler = challenge(handler)
+	}
+	return tlsConfig, &http.Server{
+		Addr:              fmt.Sprintf(":%d", cfg.TLSHTTPPort),
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       60 * time.Second,
+	}, nil
+}
+
+// httpsRedirect redirects requests to the same URL over HTTPS on httpsPort
+func httpsRedirect(httpsPort int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+		if host == "" {
+			http.Error(w, "Host header is required", http.StatusBadRequest)
+			return
+		}
+		if httpsPort != 443 {
+			host = net.JoinHostPort(host, strconv.Itoa(httpsPort))
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
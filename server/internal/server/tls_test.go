@@ -0,0 +1,97 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestCertificate(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certFile, keyFile
+}
+
+func TestNewTLS_StaticCertificate(t *testing.T) {
+	certFile, keyFile := writeTestCertificate(t, t.TempDir())
+	cfg := &config.Config{Port: 8443, TLSCertFile: certFile, TLSKeyFile: keyFile, TLSHTTPPort: 8080}
+
+	tlsConfig, redirectServer, err := newTLS(cfg)
+	require.NoError(t, err)
+	require.Len(t, tlsConfig.Certificates, 1)
+	require.NotNil(t, redirectServer)
+	assert.Equal(t, ":8080", redirectServer.Addr)
+
+	rr := httptest.NewRecorder()
+	redirectServer.Handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "http://studio.example.com:8080/gallery?sort=name", nil))
+	assert.Equal(t, http.StatusPermanentRedirect, rr.Code)
+	assert.Equal(t, "https://studio.example.com:8443/gallery?sort=name", rr.Header().Get("Location"))
+
+	cfg.TLSHTTPPort = 0
+	_, redirectServer, err = newTLS(cfg)
+	require.NoError(t, err)
+	assert.Nil(t, redirectServer)
+
+	cfg.TLSKeyFile = filepath.Join(t.TempDir(), "missing.pem")
+	_, _, err = newTLS(cfg)
+	assert.Error(t, err)
+}
+
+func TestNewTLS_ACME(t *testing.T) {
+	cfg := &config.Config{
+		Port:            443,
+		TLSACMEDomains:  "studio.example.com, photos.example.com",
+		TLSACMECacheDir: t.TempDir(),
+		TLSHTTPPort:     80,
+	}
+	tlsConfig, redirectServer, err := newTLS(cfg)
+	require.NoError(t, err)
+	assert.NotNil(t, tlsConfig.GetCertificate)
+	assert.Contains(t, tlsConfig.NextProtos, "acme-tls/1", "TLS-ALPN-01 challenges are answered")
+	require.NotNil(t, redirectServer)
+
+	rr := httptest.NewRecorder()
+	redirectServer.Handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "http://studio.example.com/gallery", nil))
+	assert.Equal(t, http.StatusPermanentRedirect, rr.Code)
+	assert.Equal(t, "https://studio.example.com/gallery", rr.Header().Get("Location"))
+
+	// Unknown HTTP-01 challenge tokens are not redirected
+	rr = httptest.NewRecorder()
+	redirectServer.Handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "http://studio.example.com/.well-known/acme-challenge/unknown", nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestHTTPSRedirect_IPv6(t *testing.T) {
+	rr := httptest.NewRecorder()
+	httpsRedirect(443).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "http://[2001:db8::1]/", nil))
+	assert.Equal(t, "https://[2001:db8::1]/", rr.Header().Get("Location"))
+}
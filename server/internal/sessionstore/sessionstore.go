@@ -0,0 +1,301 @@
+// Package sessionstore persists login sessions and their refresh tokens.
+//
+// Logging in starts a session and returns a refresh token alongside the
+// access JWT, which carries the session ID. Refreshing rotates the token:
+// the presented one is replaced and stops working. Only SHA-256 hashes of
+// the current and previous refresh tokens are stored; presenting the
+// previous token again means it was copied, so the session is revoked.
+// Revoked sessions also stop their access JWTs from being accepted.
+package sessionstore
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cshum/imagor-studio/server/internal/model"
+	"github.com/cshum/imagor-studio/server/pkg/uuid"
+	"github.com/uptrace/bun"
+	"go.uber.org/zap"
+)
+
+// maxUserAgentLength truncates recorded user agents
+const maxUserAgentLength = 255
+
+var (
+	ErrNotFound = errors.New("session not found")
+	// ErrUnauthorized is returned by Rotate for unknown, expired, revoked
+	// and reused refresh tokens
+	ErrUnauthorized = errors.New("invalid or expired refresh token")
+)
+
+// Client describes where a session is used from
+type Client struct {
+	UserAgent string
+	IP        string
+}
+
+// ClientFromRequest returns the client of r. Behind a reverse proxy the
+// proxy address is recorded unless the proxy is trusted, see clientip.
+func ClientFromRequest(r *http.Request) Client {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	return Client{UserAgent: r.UserAgent(), IP: ip}
+}
+
+type contextKey struct{}
+
+// WithClient returns a context carrying the client starting a session
+func WithClient(ctx context.Context, client Client) context.Context {
+	return context.WithValue(ctx, contextKey{}, client)
+}
+
+// ClientFromContext returns the client set by WithClient
+func ClientFromContext(ctx context.Context) Client {
+	client, _ := ctx.Value(contextKey{}).(Client)
+	return client
+}
+
+// Session is a login session of a user, without its refresh token
+type Session struct {
+	ID         string
+	UserID     string
+	OrgID      string
+	UserAgent  string
+	ClientIP   string
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+	ExpiresAt  time.Time
+}
+
+type Store interface {
+	// Create starts a session for a user, returning it with its refresh
+	// token. orgID is the organization of the login, empty when self-hosted.
+	Create(ctx context.Context, userID, orgID string, client Client) (*Session, string, error)
+	// Rotate exchanges a refresh token for a new one, extending its session
+	Rotate(ctx context.Context, refreshToken string, client Client) (*Session, string, error)
+	// Get returns an unexpired session
+	Get(ctx context.Context, id string) (*Session, error)
+	// Active reports whether a session exists and has not expired
+	Active(ctx context.Context, id string) (bool, error)
+	// List returns the unexpired sessions of a user, most recently used first
+	List(ctx context.Context, userID string) ([]*Session, error)
+	// Revoke ends a session
+	Revoke(ctx context.Context, id string) error
+	// Prune deletes sessions expired before cutoff
+	Prune(ctx context.Context, cutoff time.Time) (int, error)
+}
+
+type store struct {
+	db     *bun.DB
+	logger *zap.Logger
+	ttl    time.Duration
+}
+
+// New returns a Store whose sessions expire after ttl without a refresh
+func New(db *bun.DB, logger *zap.Logger, ttl time.Duration) Store {
+	return &store{db: db, logger: logger, ttl: ttl}
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func generateToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate refresh token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func truncateUserAgent(userAgent string) string {
+	if len(userAgent) <= maxUserAgentLength {
+		return userAgent
+	}
+	userAgent = userAgent[:maxUserAgentLength]
+	for !utf8.ValidString(userAgent) {
+		userAgent = userAgent[:len(userAgent)-1]
+	}
+	return userAgent
+}
+
+func (s *store) Create(ctx context.Context, userID, orgID string, client Client) (*Session, string, error) {
+	token, err := generateToken()
+	if err != nil {
+		return nil, "", err
+	}
+	now := time.Now().UTC()
+	row := &model.Session{
+		ID:         uuid.GenerateUUID(),
+		UserID:     userID,
+		OrgID:      orgID,
+		TokenHash:  hashToken(token),
+		UserAgent:  truncateUserAgent(client.UserAgent),
+		ClientIP:   client.IP,
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(s.ttl),
+	}
+	if _, err := s.db.NewInsert().Model(row).Exec(ctx); err != nil {
+		return nil, "", fmt.Errorf("error creating session: %w", err)
+	}
+	return toSession(row), token, nil
+}
+
+func (s *store) Rotate(ctx context.Context, refreshToken string, client Client) (*Session, string, error) {
+	if refreshToken == "" {
+		return nil, "", ErrUnauthorized
+	}
+	next, err := generateToken()
+	if err != nil {
+		return nil, "", err
+	}
+	hash := hashToken(refreshToken)
+	now := time.Now().UTC()
+	// Matching on the current hash makes concurrent rotations of the same
+	// token succeed only once
+	res, err := s.db.NewUpdate().Model((*model.Session)(nil)).
+		Set("token_hash = ?", hashToken(next)).
+		Set("previous_hash = ?", hash).
+		Set("user_agent = ?", truncateUserAgent(client.UserAgent)).
+		Set("client_ip = ?", client.IP).
+		Set("last_used_at = ?", now).
+		Set("expires_at = ?", now.Add(s.ttl)).
+		Where("token_hash = ?", hash).
+		Where("expires_at > ?", now).
+		Exec(ctx)
+	if err != nil {
+		return nil, "", fmt.Errorf("error rotating refresh token: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		s.revokeReused(ctx, hash)
+		return nil, "", ErrUnauthorized
+	}
+	var row model.Session
+	if err := s.db.NewSelect().Model(&row).
+		Where("token_hash = ?", hashToken(next)).
+		Scan(ctx); err != nil {
+		return nil, "", fmt.Errorf("error loading session: %w", err)
+	}
+	return toSession(&row), next, nil
+}
+
+// revokeReused ends the session a rotated refresh token belonged to, as
+// either its owner or whoever copied it is replaying it
+func (s *store) revokeReused(ctx context.Context, hash string) {
+	res, err := s.db.NewDelete().Model((*model.Session)(nil)).
+		Where("previous_hash = ?", hash).
+		Exec(ctx)
+	if err != nil {
+		s.logger.Warn("Failed to revoke session of reused refresh token", zap.Error(err))
+		return
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		s.logger.Warn("Revoked session after refresh token reuse")
+	}
+}
+
+func (s *store) Get(ctx context.Context, id string) (*Session, error) {
+	var row model.Session
+	if err := s.db.NewSelect().Model(&row).
+		Where("id = ?", id).
+		Where("expires_at > ?", time.Now().UTC()).
+		Scan(ctx); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error getting session: %w", err)
+	}
+	return toSession(&row), nil
+}
+
+func (s *store) Active(ctx context.Context, id string) (bool, error) {
+	exists, err := s.db.NewSelect().Model((*model.Session)(nil)).
+		Where("id = ?", id).
+		Where("expires_at > ?", time.Now().UTC()).
+		Exists(ctx)
+	if err != nil {
+		return false, fmt.Errorf("error checking session: %w", err)
+	}
+	return exists, nil
+}
+
+func (s *store) List(ctx context.Context, userID string) ([]*Session, error) {
+	var rows []model.Session
+	if err := s.db.NewSelect().Model(&rows).
+		Where("user_id = ?", userID).
+		Where("expires_at > ?", time.Now().UTC()).
+		Order("last_used_at DESC", "id DESC").
+		Scan(ctx); err != nil {
+		return nil, fmt.Errorf("error listing sessions: %w", err)
+	}
+	sessions := make([]*Session, 0, len(rows))
+	for i := range rows {
+		sessions = append(sessions, toSession(&rows[i]))
+	}
+	return sessions, nil
+}
+
+func (s *store) Revoke(ctx context.Context, id string) error {
+	res, err := s.db.NewDelete().Model((*model.Session)(nil)).
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("error revoking session: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *store) Prune(ctx context.Context, cutoff time.Time) (int, error) {
+	res, err := s.db.NewDelete().Model((*model.Session)(nil)).
+		Where("expires_at <= ?", cutoff.UTC()).
+		Exec(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error pruning sessions: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// NewPruneFunc returns a sync function deleting expired sessions
+func NewPruneFunc(store Store, logger *zap.Logger) func() error {
+	return func() error {
+		pruned, err := store.Prune(context.Background(), time.Now())
+		if err != nil {
+			return err
+		}
+		if pruned > 0 {
+			logger.Info("Pruned expired sessions", zap.Int("sessions", pruned))
+		}
+		return nil
+	}
+}
+
+func toSession(row *model.Session) *Session {
+	return &Session{
+		ID:         row.ID,
+		UserID:     row.UserID,
+		OrgID:      row.OrgID,
+		UserAgent:  row.UserAgent,
+		ClientIP:   row.ClientIP,
+		CreatedAt:  row.CreatedAt,
+		LastUsedAt: row.LastUsedAt,
+		ExpiresAt:  row.ExpiresAt,
+	}
+}
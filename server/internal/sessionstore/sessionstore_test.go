@@ -0,0 +1,130 @@
+package sessionstore
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/model"
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+	"go.uber.org/zap"
+)
+
+func setupTestStore(t *testing.T) (Store, *bun.DB) {
+	t.Helper()
+	db := testutil.NewDB(t)
+	return New(db, zap.NewNop(), time.Hour), db
+}
+
+func TestCreateRotate(t *testing.T) {
+	s, _ := setupTestStore(t)
+	ctx := context.Background()
+
+	session, token, err := s.Create(ctx, "alice", "org-1", Client{UserAgent: "Firefox", IP: "192.0.2.1"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, token)
+	assert.Equal(t, "alice", session.UserID)
+	assert.Equal(t, "org-1", session.OrgID)
+	assert.Equal(t, "Firefox", session.UserAgent)
+
+	rotated, next, err := s.Rotate(ctx, token, Client{UserAgent: "Firefox", IP: "192.0.2.2"})
+	require.NoError(t, err)
+	assert.NotEqual(t, token, next)
+	assert.Equal(t, session.ID, rotated.ID)
+	assert.Equal(t, "192.0.2.2", rotated.ClientIP)
+	assert.False(t, rotated.ExpiresAt.Before(session.ExpiresAt))
+
+	_, _, err = s.Rotate(ctx, "unknown", Client{})
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	_, _, err = s.Rotate(ctx, "", Client{})
+	assert.ErrorIs(t, err, ErrUnauthorized)
+
+	active, err := s.Active(ctx, session.ID)
+	require.NoError(t, err)
+	assert.True(t, active)
+}
+
+func TestRotate_ReuseRevokesSession(t *testing.T) {
+	s, _ := setupTestStore(t)
+	ctx := context.Background()
+
+	session, token, err := s.Create(ctx, "alice", "", Client{})
+	require.NoError(t, err)
+	_, next, err := s.Rotate(ctx, token, Client{})
+	require.NoError(t, err)
+
+	// Replaying the rotated token ends the session for both holders
+	_, _, err = s.Rotate(ctx, token, Client{})
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	_, _, err = s.Rotate(ctx, next, Client{})
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	active, err := s.Active(ctx, session.ID)
+	require.NoError(t, err)
+	assert.False(t, active)
+}
+
+func TestListRevoke(t *testing.T) {
+	s, _ := setupTestStore(t)
+	ctx := context.Background()
+
+	first, _, err := s.Create(ctx, "alice", "", Client{})
+	require.NoError(t, err)
+	_, _, err = s.Create(ctx, "alice", "", Client{})
+	require.NoError(t, err)
+	_, _, err = s.Create(ctx, "bob", "", Client{})
+	require.NoError(t, err)
+
+	sessions, err := s.List(ctx, "alice")
+	require.NoError(t, err)
+	assert.Len(t, sessions, 2)
+
+	require.NoError(t, s.Revoke(ctx, first.ID))
+	assert.ErrorIs(t, s.Revoke(ctx, first.ID), ErrNotFound)
+	_, err = s.Get(ctx, first.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	sessions, err = s.List(ctx, "alice")
+	require.NoError(t, err)
+	assert.Len(t, sessions, 1)
+}
+
+func TestExpiredAndPrune(t *testing.T) {
+	s, db := setupTestStore(t)
+	ctx := context.Background()
+
+	session, token, err := s.Create(ctx, "alice", "", Client{})
+	require.NoError(t, err)
+	_, err = db.NewUpdate().Model((*model.Session)(nil)).Set("expires_at = ?", time.Now().Add(-time.Minute).UTC()).Where("id = ?", session.ID).Exec(ctx)
+	require.NoError(t, err)
+
+	_, _, err = s.Rotate(ctx, token, Client{})
+	assert.ErrorIs(t, err, ErrUnauthorized)
+	active, err := s.Active(ctx, session.ID)
+	require.NoError(t, err)
+	assert.False(t, active)
+	sessions, err := s.List(ctx, "alice")
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+
+	pruned, err := s.Prune(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 1, pruned)
+}
+
+func TestClientFromRequest(t *testing.T) {
+	r := httptest.NewRequest("POST", "/api/auth/login", nil)
+	r.RemoteAddr = "192.0.2.1:4242"
+	r.Header.Set("User-Agent", strings.Repeat("é", 200))
+	client := ClientFromRequest(r)
+	assert.Equal(t, "192.0.2.1", client.IP)
+
+	s, _ := setupTestStore(t)
+	session, _, err := s.Create(context.Background(), "alice", "", client)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, len(session.UserAgent), maxUserAgentLength)
+	assert.True(t, strings.HasPrefix(client.UserAgent, session.UserAgent))
+}
@@ -0,0 +1,125 @@
+// Package sharestore persists shared links: tokens granting unauthenticated
+// read-only access to a file or folder of the storage until they expire.
+package sharestore
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/model"
+	"github.com/cshum/imagor-studio/server/pkg/uuid"
+	"github.com/uptrace/bun"
+	"go.uber.org/zap"
+)
+
+// ShareLink is a shared link to the file or folder at Path
+type ShareLink struct {
+	ID            string
+	Token         string
+	Path          string
+	CreatedBy     string
+	AllowDownload bool
+	// PasswordHash is the bcrypt hash of the link password, empty when the
+	// link opens without one
+	PasswordHash string
+	ExpiresAt    time.Time
+	CreatedAt    time.Time
+}
+
+// PasswordProtected reports whether opening the link requires a password
+func (l *ShareLink) PasswordProtected() bool {
+	return l.PasswordHash != ""
+}
+
+// Expired reports whether the link can no longer be opened at now
+func (l *ShareLink) Expired(now time.Time) bool {
+	return !now.Before(l.ExpiresAt)
+}
+
+type Store interface {
+	// Create issues a link with a new random token. passwordHash is the
+	// hashed link password, empty for links opening without one.
+	Create(ctx context.Context, path, createdBy string, allowDownload bool, expiresAt time.Time, passwordHash string) (*ShareLink, error)
+	// GetByToken returns the link of token, nil when there is none or it
+	// has expired
+	GetByToken(ctx context.Context, token string) (*ShareLink, error)
+}
+
+type store struct {
+	db     *bun.DB
+	logger *zap.Logger
+}
+
+func New(db *bun.DB, logger *zap.Logger) Store {
+	return &store{db: db, logger: logger}
+}
+
+func generateToken() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate share link token: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func (s *store) Create(ctx context.Context, path, createdBy string, allowDownload bool, expiresAt time.Time, passwordHash string) (*ShareLink, error) {
+	token, err := generateToken()
+	if err != nil {
+		return nil, err
+	}
+	row := &model.ShareLink{
+		ID:            uuid.GenerateUUID(),
+		Token:         token,
+		Path:          path,
+		CreatedBy:     createdBy,
+		AllowDownload: allowDownload,
+		ExpiresAt:     expiresAt.UTC(),
+		CreatedAt:     time.Now().UTC(),
+	}
+	if passwordHash != "" {
+		row.PasswordHash = &passwordHash
+	}
+	if _, err := s.db.NewInsert().Model(row).Exec(ctx); err != nil {
+		return nil, fmt.Errorf("error creating share link: %w", err)
+	}
+	return toShareLink(row), nil
+}
+
+func (s *store) GetByToken(ctx context.Context, token string) (*ShareLink, error) {
+	var row model.ShareLink
+	err := s.db.NewSelect().Model(&row).
+		Where("token = ?", token).
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting share link: %w", err)
+	}
+	link := toShareLink(&row)
+	if link.Expired(time.Now()) {
+		return nil, nil
+	}
+	return link, nil
+}
+
+func toShareLink(row *model.ShareLink) *ShareLink {
+	link := &ShareLink{
+		ID:            row.ID,
+		Token:         row.Token,
+		Path:          row.Path,
+		CreatedBy:     row.CreatedBy,
+		AllowDownload: row.AllowDownload,
+		ExpiresAt:     row.ExpiresAt,
+		CreatedAt:     row.CreatedAt,
+	}
+	if row.PasswordHash != nil {
+		link.PasswordHash = *row.PasswordHash
+	}
+	return link
+}
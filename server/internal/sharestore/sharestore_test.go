@@ -0,0 +1,73 @@
+package sharestore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func setupTestStore(t *testing.T) Store {
+	t.Helper()
+	db := testutil.NewDB(t)
+
+	return New(db, zap.NewNop())
+}
+
+func TestCreate_GetByToken(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+	expiresAt := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+
+	link, err := s.Create(ctx, "albums/vacation", "user-1", true, expiresAt, "")
+	require.NoError(t, err)
+	assert.Len(t, link.Token, 48)
+
+	found, err := s.GetByToken(ctx, link.Token)
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	assert.Equal(t, link.ID, found.ID)
+	assert.Equal(t, "albums/vacation", found.Path)
+	assert.Equal(t, "user-1", found.CreatedBy)
+	assert.True(t, found.AllowDownload)
+	assert.True(t, expiresAt.Equal(found.ExpiresAt))
+
+	other, err := s.Create(ctx, "albums/vacation", "user-1", false, expiresAt, "")
+	require.NoError(t, err)
+	assert.NotEqual(t, link.Token, other.Token)
+
+	missing, err := s.GetByToken(ctx, "unknown")
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+}
+
+func TestGetByToken_Expired(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	link, err := s.Create(ctx, "albums/vacation", "user-1", false, time.Now().Add(-time.Minute), "")
+	require.NoError(t, err)
+
+	found, err := s.GetByToken(ctx, link.Token)
+	require.NoError(t, err)
+	assert.Nil(t, found)
+}
+
+func TestCreate_PasswordHash(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	link, err := s.Create(ctx, "albums/private", "user-1", false, time.Now().Add(time.Hour), "$2a$12$hash")
+	require.NoError(t, err)
+	assert.True(t, link.PasswordProtected())
+
+	found, err := s.GetByToken(ctx, link.Token)
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	assert.Equal(t, "$2a$12$hash", found.PasswordHash)
+	assert.True(t, found.PasswordProtected())
+}
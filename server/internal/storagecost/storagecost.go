@@ -0,0 +1,198 @@
+// Package storagecost estimates the monthly bill of an object storage bucket
+// from object sizes and storage classes, grouped by top-level folder, so
+// admins can see which folders are worth archiving or cleaning up.
+package storagecost
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// DefaultStorageClass is assumed for objects whose backend reports no class
+const DefaultStorageClass = "STANDARD"
+
+// bytesPerGB follows the binary gigabyte S3 providers bill by
+const bytesPerGB = 1 << 30
+
+// Default unit prices, in USD, of S3 Standard in us-east-1
+const (
+	DefaultStoragePerGBMonth = 0.023
+	DefaultEgressPerGB       = 0.09
+)
+
+var ErrInvalidPricing = errors.New("unit prices and read ratio must not be negative")
+
+// Pricing holds the unit prices of a provider. Values are in whatever
+// currency the caller uses; the estimate is reported in the same currency.
+type Pricing struct {
+	// StoragePerGBMonth is the price of classes without an override
+	StoragePerGBMonth float64
+	// ClassPerGBMonth overrides the storage price per storage class
+	ClassPerGBMonth map[string]float64
+	EgressPerGB     float64
+	// MonthlyReadRatio is the share of stored bytes assumed to be
+	// downloaded each month, e.g. 0.1 for a tenth; egress is 0 by default
+	MonthlyReadRatio float64
+}
+
+// DefaultPricing returns S3 Standard prices with no egress assumed
+func DefaultPricing() Pricing {
+	return Pricing{
+		StoragePerGBMonth: DefaultStoragePerGBMonth,
+		EgressPerGB:       DefaultEgressPerGB,
+	}
+}
+
+// Validate rejects negative prices
+func (p Pricing) Validate() error {
+	if p.StoragePerGBMonth < 0 || p.EgressPerGB < 0 || p.MonthlyReadRatio < 0 {
+		return ErrInvalidPricing
+	}
+	for _, price := range p.ClassPerGBMonth {
+		if price < 0 {
+			return ErrInvalidPricing
+		}
+	}
+	return nil
+}
+
+func (p Pricing) storagePrice(class string) float64 {
+	if price, ok := p.ClassPerGBMonth[class]; ok {
+		return price
+	}
+	return p.StoragePerGBMonth
+}
+
+// ClassUsage is the usage of one storage class within a folder
+type ClassUsage struct {
+	StorageClass string
+	Objects      int64
+	Bytes        int64
+	StorageCost  float64
+}
+
+// FolderEstimate is the estimated monthly cost of a top-level folder.
+// Folder is empty for objects at the bucket root.
+type FolderEstimate struct {
+	Folder      string
+	Objects     int64
+	Bytes       int64
+	Classes     []ClassUsage
+	StorageCost float64
+	EgressCost  float64
+}
+
+// TotalCost returns the storage and egress cost combined
+func (f FolderEstimate) TotalCost() float64 {
+	return f.StorageCost + f.EgressCost
+}
+
+// Report is the estimate for a whole bucket, folders by descending cost
+type Report struct {
+	Folders     []FolderEstimate
+	Objects     int64
+	Bytes       int64
+	StorageCost float64
+	EgressCost  float64
+}
+
+// TotalCost returns the storage and egress cost combined
+func (r Report) TotalCost() float64 {
+	return r.StorageCost + r.EgressCost
+}
+
+type classCounter struct {
+	objects int64
+	bytes   int64
+}
+
+// Estimator accumulates objects and prices them on Report
+type Estimator struct {
+	pricing Pricing
+	folders map[string]map[string]*classCounter
+}
+
+// NewEstimator creates an estimator for the given prices
+func NewEstimator(pricing Pricing) *Estimator {
+	return &Estimator{
+		pricing: pricing,
+		folders: make(map[string]map[string]*classCounter),
+	}
+}
+
+// Add counts an object under its top-level folder
+func (e *Estimator) Add(objectPath string, size int64, storageClass string) {
+	folder := TopLevelFolder(objectPath)
+	class := strings.ToUpper(strings.TrimSpace(storageClass))
+	if class == "" {
+		class = DefaultStorageClass
+	}
+	classes, ok := e.folders[folder]
+	if !ok {
+		classes = make(map[string]*classCounter)
+		e.folders[folder] = classes
+	}
+	counter, ok := classes[class]
+	if !ok {
+		counter = &classCounter{}
+		classes[class] = counter
+	}
+	counter.objects++
+	counter.bytes += size
+}
+
+// Report prices the accumulated objects
+func (e *Estimator) Report() Report {
+	var report Report
+	for folder, classes := range e.folders {
+		estimate := FolderEstimate{Folder: folder}
+		for class, counter := range classes {
+			usage := ClassUsage{
+				StorageClass: class,
+				Objects:      counter.objects,
+				Bytes:        counter.bytes,
+				StorageCost:  gigabytes(counter.bytes) * e.pricing.storagePrice(class),
+			}
+			estimate.Classes = append(estimate.Classes, usage)
+			estimate.Objects += usage.Objects
+			estimate.Bytes += usage.Bytes
+			estimate.StorageCost += usage.StorageCost
+		}
+		sort.Slice(estimate.Classes, func(i, j int) bool {
+			return estimate.Classes[i].StorageClass < estimate.Classes[j].StorageClass
+		})
+		estimate.EgressCost = gigabytes(estimate.Bytes) * e.pricing.MonthlyReadRatio * e.pricing.EgressPerGB
+
+		report.Folders = append(report.Folders, estimate)
+		report.Objects += estimate.Objects
+		report.Bytes += estimate.Bytes
+		report.StorageCost += estimate.StorageCost
+		report.EgressCost += estimate.EgressCost
+	}
+	sort.Slice(report.Folders, func(i, j int) bool {
+		a, b := report.Folders[i], report.Folders[j]
+		if a.TotalCost() != b.TotalCost() {
+			return a.TotalCost() > b.TotalCost()
+		}
+		if a.Bytes != b.Bytes {
+			return a.Bytes > b.Bytes
+		}
+		return a.Folder < b.Folder
+	})
+	return report
+}
+
+// TopLevelFolder returns the first path segment of a nested object, or ""
+// for objects at the root
+func TopLevelFolder(objectPath string) string {
+	folder, _, nested := strings.Cut(strings.Trim(objectPath, "/"), "/")
+	if !nested {
+		return ""
+	}
+	return folder
+}
+
+func gigabytes(bytes int64) float64 {
+	return float64(bytes) / bytesPerGB
+}
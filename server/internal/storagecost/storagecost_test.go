@@ -0,0 +1,65 @@
+package storagecost
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopLevelFolder(t *testing.T) {
+	assert.Equal(t, "", TopLevelFolder("photo.jpg"))
+	assert.Equal(t, "archive", TopLevelFolder("archive/2024/photo.jpg"))
+	assert.Equal(t, "videos", TopLevelFolder("/videos/clip.mp4"))
+}
+
+func TestEstimator_Report(t *testing.T) {
+	pricing := Pricing{
+		StoragePerGBMonth: 0.02,
+		ClassPerGBMonth:   map[string]float64{"GLACIER": 0.004},
+		EgressPerGB:       0.1,
+		MonthlyReadRatio:  0.5,
+	}
+	e := NewEstimator(pricing)
+	e.Add("archive/a.raw", 10*bytesPerGB, "GLACIER")
+	e.Add("archive/b.jpg", 2*bytesPerGB, "")
+	e.Add("videos/clip.mp4", 4*bytesPerGB, "standard")
+	e.Add("readme.txt", 100, "")
+
+	report := e.Report()
+	require.Len(t, report.Folders, 3)
+	assert.Equal(t, int64(4), report.Objects)
+
+	archive := report.Folders[0]
+	assert.Equal(t, "archive", archive.Folder)
+	assert.Equal(t, int64(2), archive.Objects)
+	require.Len(t, archive.Classes, 2)
+	assert.Equal(t, "GLACIER", archive.Classes[0].StorageClass)
+	assert.InDelta(t, 0.04, archive.Classes[0].StorageCost, 1e-9)
+	assert.Equal(t, DefaultStorageClass, archive.Classes[1].StorageClass)
+	assert.InDelta(t, 0.08, archive.StorageCost, 1e-9)
+	assert.InDelta(t, 0.6, archive.EgressCost, 1e-9)
+
+	videos := report.Folders[1]
+	assert.Equal(t, "videos", videos.Folder)
+	assert.Equal(t, DefaultStorageClass, videos.Classes[0].StorageClass)
+	assert.InDelta(t, 0.08, videos.StorageCost, 1e-9)
+	assert.InDelta(t, 0.2, videos.EgressCost, 1e-9)
+
+	assert.Equal(t, "", report.Folders[2].Folder)
+	assert.InDelta(t, archive.TotalCost()+videos.TotalCost()+report.Folders[2].TotalCost(), report.TotalCost(), 1e-9)
+}
+
+func TestDefaultPricing_NoEgress(t *testing.T) {
+	e := NewEstimator(DefaultPricing())
+	e.Add("photos/a.jpg", bytesPerGB, "")
+	report := e.Report()
+	assert.InDelta(t, DefaultStoragePerGBMonth, report.StorageCost, 1e-9)
+	assert.Zero(t, report.EgressCost)
+}
+
+func TestPricing_Validate(t *testing.T) {
+	assert.NoError(t, DefaultPricing().Validate())
+	assert.ErrorIs(t, Pricing{EgressPerGB: -1}.Validate(), ErrInvalidPricing)
+	assert.ErrorIs(t, Pricing{ClassPerGBMonth: map[string]float64{"GLACIER": -0.1}}.Validate(), ErrInvalidPricing)
+}
@@ -0,0 +1,182 @@
+package storageprovider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/registryutil"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/cshum/imagor-studio/server/pkg/storage/mountstorage"
+	"github.com/cshum/imagor-studio/server/pkg/storage/noopstorage"
+	"go.uber.org/zap"
+)
+
+// MountsKey is the registry key of the storage mounts, a JSON array of
+// Mount stored encrypted as mount configs carry credentials
+const MountsKey = "config.storage_mounts"
+
+// Mount is a named storage root served below /<Name> next to the primary
+// storage. Config holds the same registry keys and values as the primary
+// storage configuration, e.g. "config.storage_type" and "config.s3_storage_bucket".
+type Mount struct {
+	Name   string            `json:"name"`
+	Config map[string]string `json:"config"`
+}
+
+// ParseMounts decodes the storage mounts, an empty value has no mounts
+func ParseMounts(value string) ([]Mount, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var mounts []Mount
+	if err := json.Unmarshal([]byte(value), &mounts); err != nil {
+		return nil, fmt.Errorf("invalid storage mounts: %w", err)
+	}
+	seen := make(map[string]bool, len(mounts))
+	for _, mount := range mounts {
+		if !mountstorage.ValidName(mount.Name) {
+			return nil, fmt.Errorf("invalid storage mount name: %q", mount.Name)
+		}
+		if seen[mount.Name] {
+			return nil, fmt.Errorf("duplicate storage mount name: %q", mount.Name)
+		}
+		seen[mount.Name] = true
+		if mount.Config["config.storage_type"] == "" {
+			return nil, fmt.Errorf("storage mount %q: storage type is required", mount.Name)
+		}
+	}
+	return mounts, nil
+}
+
+// ConfigEntries returns the registry keys and values describing the storage
+// configured by cfg, the inverse of building a config from the registry
+func ConfigEntries(cfg *config.Config) map[string]string {
+	entries := map[string]string{"config.storage_type": cfg.StorageType}
+	set := func(key, value string) {
+		if value != "" {
+			entries[key] = value
+		}
+	}
+	switch cfg.StorageType {
+	case "file", "filesystem":
+		set("config.file_storage_base_dir", cfg.FileStorageBaseDir)
+		if cfg.FileStorageMkdirPermissions != 0 {
+			set("config.file_storage_mkdir_permissions", fmt.Sprintf("%#o", uint32(cfg.FileStorageMkdirPermissions)))
+		}
+		if cfg.FileStorageWritePermissions != 0 {
+			set("config.file_storage_write_permissions", fmt.Sprintf("%#o", uint32(cfg.FileStorageWritePermissions)))
+		}
+	case "s3":
+		set("config.s3_storage_bucket", cfg.S3StorageBucket)
+		set("config.s3_storage_region", cfg.AWSRegion)
+		set("config.s3_storage_endpoint", cfg.S3Endpoint)
+		set("config.s3_storage_access_key_id", cfg.AWSAccessKeyID)
+		set("config.s3_storage_secret_access_key", cfg.AWSSecretAccessKey)
+		set("config.s3_storage_session_token", cfg.AWSSessionToken)
+		set("config.s3_storage_base_dir", cfg.S3StorageBaseDir)
+		if cfg.S3ForcePathStyle {
+			set("config.s3_storage_force_path_style", "true")
+		}
+	case "sftp":
+		set("config.sftp_storage_host", cfg.SFTPStorageHost)
+		if cfg.SFTPStoragePort > 0 {
+			set("config.sftp_storage_port", strconv.Itoa(cfg.SFTPStoragePort))
+		}
+		set("config.sftp_storage_user", cfg.SFTPStorageUser)
+		set("config.sftp_storage_private_key", cfg.SFTPStoragePrivateKey)
+		set("config.sftp_storage_private_key_passphrase", cfg.SFTPStoragePrivateKeyPassphrase)
+		set("config.sftp_storage_host_key", cfg.SFTPStorageHostKey)
+		set("config.sftp_storage_base_dir", cfg.SFTPStorageBaseDir)
+	}
+	return entries
+}
+
+// ConfigFromEntries builds the storage configuration described by registry
+// keys and values, such as a pending configuration or a mount
+func (p *Provider) ConfigFromEntries(entries map[string]string) (*config.Config, error) {
+	resultMap := make(map[string]registryutil.EffectiveValueResult, len(entries))
+	for key, value := range entries {
+		resultMap[key] = registryutil.EffectiveValueResult{Key: key, Value: value, Exists: true}
+	}
+	return p.buildConfigFromResults(resultMap)
+}
+
+// SyncMounts loads the storage mounts from the registry and rebuilds them
+// when their configuration changed. Called at startup and by the sync loop.
+// A mount that fails to build is left out and logged.
+func (p *Provider) SyncMounts() error {
+	value := ""
+	if p.registryStore != nil {
+		if result := registryutil.GetEffectiveValue(context.Background(), p.registryStore, p.config, MountsKey); result.Exists {
+			value = result.Value
+		}
+	}
+	key := ""
+	if value != "" {
+		sum := sha256.Sum256([]byte(value))
+		key = fmt.Sprintf("%x", sum[:8])
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if key == p.mountsKey {
+		return nil
+	}
+
+	mounts, err := ParseMounts(value)
+	if err != nil {
+		return err
+	}
+	built := make(map[string]storage.Storage, len(mounts))
+	for _, mount := range mounts {
+		cfg, err := p.ConfigFromEntries(mount.Config)
+		if err == nil {
+			built[mount.Name], err = p.NewStorageFromConfig(cfg)
+		}
+		if err != nil {
+			delete(built, mount.Name)
+			p.logger.Warn("Failed to load storage mount", zap.String("mount", mount.Name), zap.Error(err))
+		}
+	}
+
+	for _, s := range p.mounts {
+		closeStorage(s)
+	}
+	p.mounts = built
+	p.mountsKey = key
+	p.compose()
+	p.logger.Info("Storage mounts loaded", zap.Int("count", len(built)))
+	return nil
+}
+
+// setPrimary replaces the primary storage keeping the mounts, the caller
+// holds the lock
+func (p *Provider) setPrimary(s storage.Storage) {
+	if p.primary != nil && p.primary != s {
+		closeStorage(p.primary)
+	}
+	p.primary = s
+	p.compose()
+}
+
+// compose sets the storage served to callers: the primary storage alone, or
+// with the mounts overlaid at the top level. The caller holds the lock.
+func (p *Provider) compose() {
+	if len(p.mounts) == 0 {
+		p.currentStorage = p.traced(p.primary)
+		return
+	}
+	primary := p.primary
+	if primary == nil {
+		primary = noopstorage.New()
+	}
+	mounts := make(map[string]storage.Storage, len(p.mounts))
+	for name, s := range p.mounts {
+		mounts[name] = p.traced(s)
+	}
+	p.currentStorage = mountstorage.New(p.traced(primary), mounts)
+}
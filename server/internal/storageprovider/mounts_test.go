@@ -0,0 +1,92 @@
+package storageprovider
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/cshum/imagor-studio/server/pkg/storage/mountstorage"
+	"github.com/cshum/imagor-studio/server/pkg/storage/noopstorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestParseMounts(t *testing.T) {
+	mounts, err := ParseMounts("")
+	assert.NoError(t, err)
+	assert.Empty(t, mounts)
+
+	mounts, err = ParseMounts(`[{"name":"nas","config":{"config.storage_type":"file"}}]`)
+	require.NoError(t, err)
+	require.Len(t, mounts, 1)
+	assert.Equal(t, "nas", mounts[0].Name)
+
+	for _, value := range []string{
+		`not json`,
+		`[{"name":"NAS","config":{"config.storage_type":"file"}}]`,
+		`[{"name":"nas","config":{}}]`,
+		`[{"name":"nas","config":{"config.storage_type":"file"}},{"name":"nas","config":{"config.storage_type":"s3"}}]`,
+	} {
+		_, err := ParseMounts(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestConfigEntries_RoundTrip(t *testing.T) {
+	provider := New(zap.NewNop(), nil, &config.Config{})
+	cfg := &config.Config{
+		StorageType:        "s3",
+		S3StorageBucket:    "archive",
+		AWSRegion:          "eu-west-1",
+		AWSSecretAccessKey: "secret",
+		S3ForcePathStyle:   true,
+	}
+
+	entries := ConfigEntries(cfg)
+	assert.Equal(t, "s3", entries["config.storage_type"])
+	assert.Equal(t, "true", entries["config.s3_storage_force_path_style"])
+
+	built, err := provider.ConfigFromEntries(entries)
+	require.NoError(t, err)
+	assert.Equal(t, "archive", built.S3StorageBucket)
+	assert.Equal(t, "eu-west-1", built.AWSRegion)
+	assert.Equal(t, "secret", built.AWSSecretAccessKey)
+	assert.True(t, built.S3ForcePathStyle)
+}
+
+func TestProvider_SyncMounts(t *testing.T) {
+	registryStore := new(MockRegistryStore)
+	provider := New(zap.NewNop(), registryStore, &config.Config{})
+	provider.currentStorage = noopstorage.New()
+	provider.primary = provider.currentStorage
+	provider.configured = true
+
+	value, err := json.Marshal([]Mount{{Name: "nas", Config: map[string]string{
+		"config.storage_type":          "file",
+		"config.file_storage_base_dir": t.TempDir(),
+	}}})
+	require.NoError(t, err)
+	registryStore.On("GetMulti", mock.Anything, "system:global", []string{MountsKey}).
+		Return([]*registrystore.Registry{{Key: MountsKey, Value: string(value), IsEncrypted: true}}, nil).Twice()
+
+	require.NoError(t, provider.SyncMounts())
+	composed, ok := provider.GetStorage().(*mountstorage.MountStorage)
+	require.True(t, ok)
+	assert.Equal(t, []string{"nas"}, composed.Mounts())
+	nas, _ := composed.Mount("nas")
+	assert.IsType(t, &filestorage.FileStorage{}, nas)
+
+	// Unchanged configuration keeps the mounts as they are
+	require.NoError(t, provider.SyncMounts())
+	assert.Same(t, composed, provider.GetStorage())
+
+	// Removing every mount serves the primary storage alone
+	registryStore.On("GetMulti", mock.Anything, "system:global", []string{MountsKey}).
+		Return([]*registrystore.Registry{}, nil).Once()
+	require.NoError(t, provider.SyncMounts())
+	assert.IsType(t, &noopstorage.NoOpStorage{}, provider.GetStorage())
+}
@@ -5,9 +5,11 @@ import (
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/cshum/imagor-studio/server/internal/config"
 	"github.com/cshum/imagor-studio/server/internal/registrystore"
@@ -16,6 +18,7 @@ import (
 	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
 	"github.com/cshum/imagor-studio/server/pkg/storage/noopstorage"
 	"github.com/cshum/imagor-studio/server/pkg/storage/s3storage"
+	"github.com/cshum/imagor-studio/server/pkg/storage/sftpstorage"
 	"go.uber.org/zap"
 )
 
@@ -35,9 +38,13 @@ type Provider struct {
 	registryStore registrystore.Store
 	config        *config.Config
 
-	currentStorage storage.Storage
-	configured     bool   // true once real (non-noop) storage is loaded
-	configKey      string // fingerprint of the currently-loaded config; empty = noop/unconfigured
+	currentStorage storage.Storage // primary storage, with mounts overlaid when any are configured
+	configured     bool            // true once real (non-noop) storage is loaded
+	configKey      string          // fingerprint of the currently-loaded config; empty = noop/unconfigured
+
+	primary   storage.Storage
+	mounts    map[string]storage.Storage
+	mountsKey string // fingerprint of the loaded mounts configuration; empty = no mounts
 
 	mutex sync.RWMutex
 }
@@ -62,6 +69,14 @@ type storageConfigSnapshot struct {
 	Secret    string
 	Token     string
 	S3BaseDir string
+	// sftp storage
+	SFTPHost       string
+	SFTPPort       int
+	SFTPUser       string
+	SFTPKey        string
+	SFTPPassphrase string
+	SFTPHostKey    string
+	SFTPBaseDir    string
 }
 
 // storageConfigKey returns a 16-char hex fingerprint of the storage config.
@@ -69,24 +84,57 @@ type storageConfigSnapshot struct {
 // The raw values of sensitive fields are never readable from the key itself.
 func storageConfigKey(cfg *config.Config) string {
 	snap := storageConfigSnapshot{
-		Type:      cfg.StorageType,
-		BaseDir:   cfg.FileStorageBaseDir,
-		MkdirPerm: uint32(cfg.FileStorageMkdirPermissions),
-		WritePerm: uint32(cfg.FileStorageWritePermissions),
-		Bucket:    cfg.S3StorageBucket,
-		Region:    cfg.AWSRegion,
-		Endpoint:  cfg.S3Endpoint,
-		PathStyle: cfg.S3ForcePathStyle,
-		KeyID:     cfg.AWSAccessKeyID,
-		Secret:    cfg.AWSSecretAccessKey,
-		Token:     cfg.AWSSessionToken,
-		S3BaseDir: cfg.S3StorageBaseDir,
+		Type:           cfg.StorageType,
+		BaseDir:        cfg.FileStorageBaseDir,
+		MkdirPerm:      uint32(cfg.FileStorageMkdirPermissions),
+		WritePerm:      uint32(cfg.FileStorageWritePermissions),
+		Bucket:         cfg.S3StorageBucket,
+		Region:         cfg.AWSRegion,
+		Endpoint:       cfg.S3Endpoint,
+		PathStyle:      cfg.S3ForcePathStyle,
+		KeyID:          cfg.AWSAccessKeyID,
+		Secret:         cfg.AWSSecretAccessKey,
+		Token:          cfg.AWSSessionToken,
+		S3BaseDir:      cfg.S3StorageBaseDir,
+		SFTPHost:       cfg.SFTPStorageHost,
+		SFTPPort:       cfg.SFTPStoragePort,
+		SFTPUser:       cfg.SFTPStorageUser,
+		SFTPKey:        cfg.SFTPStoragePrivateKey,
+		SFTPPassphrase: cfg.SFTPStoragePrivateKeyPassphrase,
+		SFTPHostKey:    cfg.SFTPStorageHostKey,
+		SFTPBaseDir:    cfg.SFTPStorageBaseDir,
 	}
 	b, _ := json.Marshal(snap)
 	sum := sha256.Sum256(b)
 	return fmt.Sprintf("%x", sum[:8]) // 16 hex chars
 }
 
+// Registry keys for staged storage configuration.
+//
+// Storage settings saved from the admin UI are first written as a pending
+// JSON snapshot. The provider validates the snapshot on the next startup or
+// sync tick and only then promotes it to the active config.* keys, so the
+// active keys always hold the last-known-good configuration. A snapshot that
+// fails validation is discarded and the failure is recorded for StorageStatus.
+const (
+	PendingConfigKey          = "config.storage_pending_config"
+	PendingConfigUpdatedAtKey = "config.storage_pending_updated_at"
+	RollbackReasonKey         = "config.storage_rollback_reason"
+	RollbackAtKey             = "config.storage_rollback_at"
+)
+
+// pendingConfigProbeTimeout bounds the validation of a staged configuration
+const pendingConfigProbeTimeout = 15 * time.Second
+
+// encryptedStorageKeys are storage registry keys stored encrypted at rest
+var encryptedStorageKeys = map[string]bool{
+	"config.s3_storage_access_key_id":            true,
+	"config.s3_storage_secret_access_key":        true,
+	"config.s3_storage_session_token":            true,
+	"config.sftp_storage_private_key":            true,
+	"config.sftp_storage_private_key_passphrase": true,
+}
+
 // New creates a new storage provider
 func New(logger *zap.Logger, registryStore registrystore.Store, cfg *config.Config) *Provider {
 	return &Provider{
@@ -136,6 +184,8 @@ func (p *Provider) NewStorageFromConfig(cfg *config.Config) (storage.Storage, er
 		return p.NewFileStorage(cfg)
 	case "s3":
 		return p.NewS3Storage(cfg)
+	case "sftp":
+		return p.NewSFTPStorage(cfg)
 	default:
 		return nil, fmt.Errorf("unsupported storage type: %s", cfg.StorageType)
 	}
@@ -202,6 +252,35 @@ func (p *Provider) NewS3Storage(cfg *config.Config) (storage.Storage, error) {
 	return s3storage.New(cfg.S3StorageBucket, options...)
 }
 
+// NewSFTPStorage creates an SFTP storage instance
+func (p *Provider) NewSFTPStorage(cfg *config.Config) (storage.Storage, error) {
+	if cfg.SFTPStorageHost == "" {
+		return nil, fmt.Errorf("sftp-storage-host is required when storage-type is sftp")
+	}
+
+	p.logger.Debug("Creating SFTP storage",
+		zap.String("host", cfg.SFTPStorageHost),
+		zap.Int("port", cfg.SFTPStoragePort),
+		zap.String("user", cfg.SFTPStorageUser),
+		zap.String("baseDir", cfg.SFTPStorageBaseDir),
+	)
+
+	options := []sftpstorage.Option{
+		sftpstorage.WithUser(cfg.SFTPStorageUser),
+		sftpstorage.WithPrivateKey(cfg.SFTPStoragePrivateKey, cfg.SFTPStoragePrivateKeyPassphrase),
+		sftpstorage.WithHostKey(cfg.SFTPStorageHostKey),
+		sftpstorage.WithLogger(p.logger),
+	}
+	if cfg.SFTPStoragePort > 0 {
+		options = append(options, sftpstorage.WithPort(cfg.SFTPStoragePort))
+	}
+	if cfg.SFTPStorageBaseDir != "" {
+		options = append(options, sftpstorage.WithBaseDir(cfg.SFTPStorageBaseDir))
+	}
+
+	return sftpstorage.New(cfg.SFTPStorageHost, options...)
+}
+
 // GetStorage returns the current storage instance.
 // When not yet configured (e.g. a fresh install), it attempts a one-time lazy
 // load from the registry so new storage config takes effect on the next request
@@ -218,6 +297,17 @@ func (p *Provider) GetStorage() storage.Storage {
 	return p.loadStorageFromRegistry()
 }
 
+// FileStorageDir returns the base directory of the primary storage when it is
+// file storage, "" otherwise. Mounts are not included.
+func (p *Provider) FileStorageDir() string {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	if fs, ok := p.primary.(*filestorage.FileStorage); ok {
+		return fs.BaseDir()
+	}
+	return ""
+}
+
 // InitializeWithConfig initializes storage with the given configuration
 func (p *Provider) InitializeWithConfig(cfg *config.Config) error {
 	p.mutex.Lock()
@@ -227,7 +317,7 @@ func (p *Provider) InitializeWithConfig(cfg *config.Config) error {
 	s, err := p.NewStorageFromConfig(cfg)
 	if s != nil && err == nil {
 		// Env/CLI config worked - use it immediately
-		p.currentStorage = s
+		p.setPrimary(s)
 		p.configured = true
 		p.configKey = storageConfigKey(cfg)
 		p.logger.Info("Storage initialized successfully", zap.String("type", cfg.StorageType))
@@ -237,12 +327,12 @@ func (p *Provider) InitializeWithConfig(cfg *config.Config) error {
 	// Env config failed, check if we should enable lazy loading
 	if p.isStorageConfiguredInRegistry() {
 		// Registry has config - set up for lazy loading
-		p.currentStorage = noopstorage.New()
+		p.setPrimary(noopstorage.New())
 		p.configured = false
 		p.logger.Info("Storage will be lazy loaded from registry")
 	} else {
 		// No config anywhere - use NoOp
-		p.currentStorage = noopstorage.New()
+		p.setPrimary(noopstorage.New())
 		p.configured = false
 		p.logger.Info("No storage configuration found, using NoOp storage")
 	}
@@ -257,6 +347,11 @@ func (p *Provider) InitializeWithConfig(cfg *config.Config) error {
 // If the config fingerprint is identical to what is already loaded, the call
 // is a complete no-op (no log, no allocation) — so frequent polling is safe.
 func (p *Provider) ReloadFromRegistry() error {
+	// Validate staged config outside the lock, probing remote storage can be slow
+	if err := p.applyPendingConfig(); err != nil {
+		p.logger.Warn("Failed to apply pending storage configuration", zap.Error(err))
+	}
+
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
 
@@ -287,7 +382,7 @@ func (p *Provider) ReloadFromRegistry() error {
 		return fmt.Errorf("failed to create storage from registry config: %w", err)
 	}
 
-	p.currentStorage = s
+	p.setPrimary(s)
 	p.configured = true
 	p.configKey = newKey
 	p.logger.Info("Storage reloaded from registry", zap.String("type", cfg.StorageType))
@@ -297,6 +392,10 @@ func (p *Provider) ReloadFromRegistry() error {
 
 // loadStorageFromRegistry attempts to load storage configuration from registry (lazy init)
 func (p *Provider) loadStorageFromRegistry() storage.Storage {
+	if err := p.applyPendingConfig(); err != nil {
+		p.logger.Warn("Failed to apply pending storage configuration", zap.Error(err))
+	}
+
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
 
@@ -322,7 +421,7 @@ func (p *Provider) loadStorageFromRegistry() storage.Storage {
 		return p.currentStorage
 	}
 
-	p.currentStorage = s
+	p.setPrimary(s)
 	p.configured = true
 	p.configKey = storageConfigKey(cfg)
 	p.logger.Info("Storage configured from registry", zap.String("type", cfg.StorageType))
@@ -337,10 +436,110 @@ func (p *Provider) isStorageConfiguredInRegistry() bool {
 	return result.Exists && result.Value == "true"
 }
 
+// applyPendingConfig validates a staged storage configuration, if any, and
+// either promotes it to the active registry keys or discards it so the last
+// known good configuration stays in effect.
+func (p *Provider) applyPendingConfig() error {
+	ctx := context.Background()
+	result := registryutil.GetEffectiveValue(ctx, p.registryStore, p.config, PendingConfigKey)
+	if !result.Exists || result.Value == "" {
+		return nil
+	}
+
+	entries, err := ParsePendingConfig(result.Value)
+	if err != nil {
+		return p.rollbackPendingConfig(ctx, err.Error())
+	}
+
+	cfg, err := p.ConfigFromEntries(entries)
+	if err == nil {
+		err = p.probeStorage(ctx, cfg)
+	}
+	if err != nil {
+		p.logger.Warn("Pending storage configuration failed validation, keeping last known good configuration",
+			zap.String("type", entries["config.storage_type"]),
+			zap.Error(err))
+		return p.rollbackPendingConfig(ctx, err.Error())
+	}
+
+	registryEntries := make([]*registrystore.Registry, 0, len(entries)+2)
+	for key, value := range entries {
+		registryEntries = append(registryEntries, &registrystore.Registry{
+			Key: key, Value: value, IsEncrypted: encryptedStorageKeys[key],
+		})
+	}
+	registryEntries = append(registryEntries,
+		&registrystore.Registry{Key: "config.storage_configured", Value: "true"},
+		&registrystore.Registry{Key: "config.storage_config_updated_at", Value: fmt.Sprintf("%d", time.Now().UnixMilli())},
+	)
+	if _, err := p.registryStore.SetMulti(ctx, registrystore.SystemOwnerID, registryEntries); err != nil {
+		return fmt.Errorf("failed to promote pending storage configuration: %w", err)
+	}
+	if err := p.registryStore.DeleteMulti(ctx, registrystore.SystemOwnerID, []string{
+		PendingConfigKey, PendingConfigUpdatedAtKey, RollbackReasonKey, RollbackAtKey,
+	}); err != nil {
+		return fmt.Errorf("failed to clear pending storage configuration: %w", err)
+	}
+
+	p.logger.Info("Pending storage configuration validated and applied", zap.String("type", cfg.StorageType))
+	return nil
+}
+
+// rollbackPendingConfig discards the staged configuration and records why
+func (p *Provider) rollbackPendingConfig(ctx context.Context, reason string) error {
+	if _, err := p.registryStore.SetMulti(ctx, registrystore.SystemOwnerID, []*registrystore.Registry{
+		{Key: RollbackReasonKey, Value: reason},
+		{Key: RollbackAtKey, Value: fmt.Sprintf("%d", time.Now().UnixMilli())},
+	}); err != nil {
+		return fmt.Errorf("failed to record storage configuration rollback: %w", err)
+	}
+	if err := p.registryStore.DeleteMulti(ctx, registrystore.SystemOwnerID, []string{
+		PendingConfigKey, PendingConfigUpdatedAtKey,
+	}); err != nil {
+		return fmt.Errorf("failed to discard pending storage configuration: %w", err)
+	}
+	return nil
+}
+
+// probeStorage builds a storage instance for cfg and checks it is reachable
+func (p *Provider) probeStorage(ctx context.Context, cfg *config.Config) error {
+	s, err := p.NewStorageFromConfig(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeStorage(s)
+	ctx, cancel := context.WithTimeout(ctx, pendingConfigProbeTimeout)
+	defer cancel()
+	if _, err := s.List(ctx, "", storage.ListOptions{Limit: 1}); err != nil {
+		return fmt.Errorf("failed to access storage: %w", err)
+	}
+	return nil
+}
+
+// closeStorage releases backends holding connections open, such as SFTP.
+// A closed SFTP storage reconnects if still in use.
+func closeStorage(s storage.Storage) {
+	if closer, ok := s.(io.Closer); ok {
+		closer.Close()
+	}
+}
+
+// ParsePendingConfig decodes a staged storage configuration snapshot,
+// a JSON object of registry key to value.
+func ParsePendingConfig(value string) (map[string]string, error) {
+	var entries map[string]string
+	if err := json.Unmarshal([]byte(value), &entries); err != nil {
+		return nil, fmt.Errorf("invalid pending storage configuration: %w", err)
+	}
+	if entries["config.storage_type"] == "" {
+		return nil, fmt.Errorf("invalid pending storage configuration: storage type is required")
+	}
+	return entries, nil
+}
+
 // buildConfigFromRegistry builds a config object from registry values using batch operations
 func (p *Provider) buildConfigFromRegistry() (*config.Config, error) {
 	ctx := context.Background()
-	cfg := &config.Config{}
 
 	// Get all possible storage configuration keys in one batch call
 	results := registryutil.GetEffectiveValues(ctx, p.registryStore, p.config,
@@ -357,7 +556,15 @@ func (p *Provider) buildConfigFromRegistry() (*config.Config, error) {
 		"config.s3_storage_access_key_id",
 		"config.s3_storage_secret_access_key",
 		"config.s3_storage_session_token",
-		"config.s3_storage_base_dir")
+		"config.s3_storage_base_dir",
+		// SFTP storage keys
+		"config.sftp_storage_host",
+		"config.sftp_storage_port",
+		"config.sftp_storage_user",
+		"config.sftp_storage_private_key",
+		"config.sftp_storage_private_key_passphrase",
+		"config.sftp_storage_host_key",
+		"config.sftp_storage_base_dir")
 
 	// Create a map for easy lookup
 	resultMap := make(map[string]registryutil.EffectiveValueResult)
@@ -365,6 +572,13 @@ func (p *Provider) buildConfigFromRegistry() (*config.Config, error) {
 		resultMap[result.Key] = result
 	}
 
+	return p.buildConfigFromResults(resultMap)
+}
+
+// buildConfigFromResults builds a config object from pre-fetched registry values
+func (p *Provider) buildConfigFromResults(resultMap map[string]registryutil.EffectiveValueResult) (*config.Config, error) {
+	cfg := &config.Config{}
+
 	// Get storage type
 	storageTypeResult := resultMap["config.storage_type"]
 	if !storageTypeResult.Exists {
@@ -382,6 +596,10 @@ func (p *Provider) buildConfigFromRegistry() (*config.Config, error) {
 		if err := p.loadS3ConfigFromResults(resultMap, cfg); err != nil {
 			return nil, err
 		}
+	case "sftp":
+		if err := p.loadSFTPConfigFromResults(resultMap, cfg); err != nil {
+			return nil, err
+		}
 	default:
 		return nil, fmt.Errorf("unsupported storage type: %s", cfg.StorageType)
 	}
@@ -462,3 +680,43 @@ func (p *Provider) loadS3ConfigFromResults(resultMap map[string]registryutil.Eff
 
 	return nil
 }
+
+// loadSFTPConfigFromResults loads SFTP storage configuration from pre-fetched results
+func (p *Provider) loadSFTPConfigFromResults(resultMap map[string]registryutil.EffectiveValueResult, cfg *config.Config) error {
+	if result := resultMap["config.sftp_storage_host"]; result.Exists {
+		cfg.SFTPStorageHost = result.Value
+	} else {
+		return fmt.Errorf("sftp host is required")
+	}
+
+	cfg.SFTPStoragePort = 22
+	if result := resultMap["config.sftp_storage_port"]; result.Exists && result.Value != "" {
+		port, err := strconv.Atoi(result.Value)
+		if err != nil || port <= 0 || port > 65535 {
+			return fmt.Errorf("invalid sftp port value: %s", result.Value)
+		}
+		cfg.SFTPStoragePort = port
+	}
+
+	if result := resultMap["config.sftp_storage_user"]; result.Exists {
+		cfg.SFTPStorageUser = result.Value
+	}
+
+	if result := resultMap["config.sftp_storage_private_key"]; result.Exists {
+		cfg.SFTPStoragePrivateKey = result.Value
+	}
+
+	if result := resultMap["config.sftp_storage_private_key_passphrase"]; result.Exists {
+		cfg.SFTPStoragePrivateKeyPassphrase = result.Value
+	}
+
+	if result := resultMap["config.sftp_storage_host_key"]; result.Exists {
+		cfg.SFTPStorageHostKey = result.Value
+	}
+
+	if result := resultMap["config.sftp_storage_base_dir"]; result.Exists {
+		cfg.SFTPStorageBaseDir = result.Value
+	}
+
+	return nil
+}
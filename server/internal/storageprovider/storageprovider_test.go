@@ -2,16 +2,24 @@ package storageprovider
 
 import (
 	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"path/filepath"
 	"testing"
 
 	"github.com/cshum/imagor-studio/server/internal/config"
 	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/internal/registryutil"
 	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
 	"github.com/cshum/imagor-studio/server/pkg/storage/noopstorage"
 	"github.com/cshum/imagor-studio/server/pkg/storage/s3storage"
+	"github.com/cshum/imagor-studio/server/pkg/storage/sftpstorage"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh"
 )
 
 // MockRegistryStore is a mock implementation of registrystore.Store for testing
@@ -258,6 +266,10 @@ func TestProvider_InitializeWithConfig_ValidFileEnvConfig(t *testing.T) {
 
 	// Mock should not be called since env config worked
 	mockRegistry.AssertNotCalled(t, "Get")
+
+	baseDir, err := filepath.Abs("./test-storage")
+	require.NoError(t, err)
+	assert.Equal(t, baseDir, provider.FileStorageDir())
 }
 
 // Test InitializeWithConfig with valid environment S3 storage configuration
@@ -486,6 +498,11 @@ func TestStorageConfigKey_Deterministic(t *testing.T) {
 func buildFileRegistryMock() *MockRegistryStore {
 	mockRegistry := &MockRegistryStore{}
 
+	// applyPendingConfig — nothing staged
+	mockRegistry.On("GetMulti", mock.Anything, registrystore.SystemOwnerID,
+		[]string{"config.storage_pending_config"}).
+		Return([]*registrystore.Registry{}, nil)
+
 	// isStorageConfiguredInRegistry — uses GetMulti with just ["config.storage_configured"]
 	mockRegistry.On("GetMulti", mock.Anything, registrystore.SystemOwnerID,
 		[]string{"config.storage_configured"}).
@@ -535,6 +552,9 @@ func TestReloadFromRegistry_ReloadsWhenChanged(t *testing.T) {
 
 	// First registry answers: /tmp/old-dir
 	mockRegistry1 := &MockRegistryStore{}
+	mockRegistry1.On("GetMulti", mock.Anything, registrystore.SystemOwnerID,
+		[]string{"config.storage_pending_config"}).
+		Return([]*registrystore.Registry{}, nil)
 	mockRegistry1.On("GetMulti", mock.Anything, registrystore.SystemOwnerID,
 		[]string{"config.storage_configured"}).
 		Return([]*registrystore.Registry{{Key: "config.storage_configured", Value: "true"}}, nil)
@@ -562,6 +582,9 @@ func TestReloadFromRegistry_ReloadsWhenChanged(t *testing.T) {
 
 	// Switch to a registry that returns a different base dir.
 	mockRegistry2 := &MockRegistryStore{}
+	mockRegistry2.On("GetMulti", mock.Anything, registrystore.SystemOwnerID,
+		[]string{"config.storage_pending_config"}).
+		Return([]*registrystore.Registry{}, nil)
 	mockRegistry2.On("GetMulti", mock.Anything, registrystore.SystemOwnerID,
 		[]string{"config.storage_configured"}).
 		Return([]*registrystore.Registry{{Key: "config.storage_configured", Value: "true"}}, nil)
@@ -591,6 +614,9 @@ func TestReloadFromRegistry_ReloadsWhenChanged(t *testing.T) {
 func TestReloadFromRegistry_NoOpNoLog_WhenNotConfigured(t *testing.T) {
 	logger := zap.NewNop()
 	mockRegistry := &MockRegistryStore{}
+	mockRegistry.On("GetMulti", mock.Anything, registrystore.SystemOwnerID,
+		[]string{"config.storage_pending_config"}).
+		Return([]*registrystore.Registry{}, nil)
 	mockRegistry.On("GetMulti", mock.Anything, registrystore.SystemOwnerID,
 		[]string{"config.storage_configured"}).
 		Return([]*registrystore.Registry{}, nil) // not configured
@@ -690,3 +716,148 @@ func TestNewStorageFromSpaceConfig_R2Type(t *testing.T) {
 	_, isS3 := stor.(*s3storage.S3Storage)
 	assert.True(t, isS3, "expected *s3storage.S3Storage for r2 type")
 }
+
+// ── Staged storage configuration ─────────────────────────────────────────────
+
+func TestApplyPendingConfig_PromotesValidConfig(t *testing.T) {
+	baseDir := t.TempDir()
+	mockRegistry := &MockRegistryStore{}
+	mockRegistry.On("GetMulti", mock.Anything, registrystore.SystemOwnerID,
+		[]string{"config.storage_pending_config"}).
+		Return([]*registrystore.Registry{{
+			Key:   "config.storage_pending_config",
+			Value: `{"config.storage_type":"file","config.file_storage_base_dir":"` + baseDir + `"}`,
+		}}, nil).Once()
+	mockRegistry.On("SetMulti", mock.Anything, registrystore.SystemOwnerID, mock.MatchedBy(func(entries []*registrystore.Registry) bool {
+		values := map[string]string{}
+		for _, e := range entries {
+			values[e.Key] = e.Value
+		}
+		return values["config.storage_type"] == "file" &&
+			values["config.file_storage_base_dir"] == baseDir &&
+			values["config.storage_configured"] == "true"
+	})).Return([]*registrystore.Registry{}, nil).Once()
+	mockRegistry.On("DeleteMulti", mock.Anything, registrystore.SystemOwnerID, []string{
+		"config.storage_pending_config",
+		"config.storage_pending_updated_at",
+		"config.storage_rollback_reason",
+		"config.storage_rollback_at",
+	}).Return(nil).Once()
+
+	provider := New(zap.NewNop(), mockRegistry, &config.Config{})
+	err := provider.applyPendingConfig()
+
+	assert.NoError(t, err)
+	mockRegistry.AssertExpectations(t)
+}
+
+func TestApplyPendingConfig_RollsBackInvalidConfig(t *testing.T) {
+	mockRegistry := &MockRegistryStore{}
+	mockRegistry.On("GetMulti", mock.Anything, registrystore.SystemOwnerID,
+		[]string{"config.storage_pending_config"}).
+		Return([]*registrystore.Registry{{
+			Key:   "config.storage_pending_config",
+			Value: `{"config.storage_type":"file","config.file_storage_base_dir":"/non/existent/directory"}`,
+		}}, nil).Once()
+	mockRegistry.On("SetMulti", mock.Anything, registrystore.SystemOwnerID, mock.MatchedBy(func(entries []*registrystore.Registry) bool {
+		return len(entries) == 2 && entries[0].Key == "config.storage_rollback_reason" && entries[0].Value != ""
+	})).Return([]*registrystore.Registry{}, nil).Once()
+	mockRegistry.On("DeleteMulti", mock.Anything, registrystore.SystemOwnerID, []string{
+		"config.storage_pending_config",
+		"config.storage_pending_updated_at",
+	}).Return(nil).Once()
+
+	provider := New(zap.NewNop(), mockRegistry, &config.Config{})
+	err := provider.applyPendingConfig()
+
+	assert.NoError(t, err)
+	mockRegistry.AssertExpectations(t)
+	// Active config keys must never be written for a failed snapshot
+	mockRegistry.AssertNumberOfCalls(t, "SetMulti", 1)
+}
+
+func TestApplyPendingConfig_NothingPending(t *testing.T) {
+	mockRegistry := &MockRegistryStore{}
+	mockRegistry.On("GetMulti", mock.Anything, registrystore.SystemOwnerID,
+		[]string{"config.storage_pending_config"}).
+		Return([]*registrystore.Registry{}, nil).Once()
+
+	provider := New(zap.NewNop(), mockRegistry, &config.Config{})
+	err := provider.applyPendingConfig()
+
+	assert.NoError(t, err)
+	mockRegistry.AssertExpectations(t)
+	mockRegistry.AssertNotCalled(t, "SetMulti", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestParsePendingConfig(t *testing.T) {
+	entries, err := ParsePendingConfig(`{"config.storage_type":"s3","config.s3_storage_bucket":"b"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, "b", entries["config.s3_storage_bucket"])
+
+	_, err = ParsePendingConfig(`{`)
+	assert.Error(t, err)
+
+	_, err = ParsePendingConfig(`{"config.s3_storage_bucket":"b"}`)
+	assert.ErrorContains(t, err, "storage type is required")
+}
+
+func testSFTPPrivateKey(t *testing.T) string {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	block, err := ssh.MarshalPrivateKey(key, "")
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(block))
+}
+
+func TestProvider_NewStorageFromConfig_SFTP(t *testing.T) {
+	provider := New(zap.NewNop(), nil, nil)
+
+	s, err := provider.NewStorageFromConfig(&config.Config{
+		StorageType:           "sftp",
+		SFTPStorageHost:       "sftp.example.com",
+		SFTPStoragePort:       2222,
+		SFTPStorageUser:       "imagor",
+		SFTPStoragePrivateKey: testSFTPPrivateKey(t),
+	})
+	assert.NoError(t, err)
+	assert.IsType(t, &sftpstorage.SFTPStorage{}, s)
+
+	_, err = provider.NewStorageFromConfig(&config.Config{StorageType: "sftp", SFTPStorageUser: "imagor"})
+	assert.Error(t, err)
+	_, err = provider.NewStorageFromConfig(&config.Config{StorageType: "sftp", SFTPStorageHost: "sftp.example.com", SFTPStorageUser: "imagor", SFTPStoragePrivateKey: "invalid"})
+	assert.Error(t, err)
+}
+
+func TestProvider_BuildConfigFromResults_SFTP(t *testing.T) {
+	provider := New(zap.NewNop(), nil, nil)
+	results := map[string]registryutil.EffectiveValueResult{
+		"config.storage_type":                        {Key: "config.storage_type", Value: "sftp", Exists: true},
+		"config.sftp_storage_host":                   {Key: "config.sftp_storage_host", Value: "sftp.example.com", Exists: true},
+		"config.sftp_storage_port":                   {Key: "config.sftp_storage_port", Value: "2222", Exists: true},
+		"config.sftp_storage_user":                   {Key: "config.sftp_storage_user", Value: "imagor", Exists: true},
+		"config.sftp_storage_private_key":            {Key: "config.sftp_storage_private_key", Value: "key", Exists: true},
+		"config.sftp_storage_private_key_passphrase": {Key: "config.sftp_storage_private_key_passphrase", Value: "secret", Exists: true},
+		"config.sftp_storage_base_dir":               {Key: "config.sftp_storage_base_dir", Value: "photos", Exists: true},
+	}
+
+	cfg, err := provider.buildConfigFromResults(results)
+	require.NoError(t, err)
+	assert.Equal(t, "sftp.example.com", cfg.SFTPStorageHost)
+	assert.Equal(t, 2222, cfg.SFTPStoragePort)
+	assert.Equal(t, "imagor", cfg.SFTPStorageUser)
+	assert.Equal(t, "key", cfg.SFTPStoragePrivateKey)
+	assert.Equal(t, "secret", cfg.SFTPStoragePrivateKeyPassphrase)
+	assert.Equal(t, "photos", cfg.SFTPStorageBaseDir)
+
+	results["config.sftp_storage_port"] = registryutil.EffectiveValueResult{Key: "config.sftp_storage_port", Value: "ssh", Exists: true}
+	_, err = provider.buildConfigFromResults(results)
+	assert.Error(t, err)
+
+	delete(results, "config.sftp_storage_host")
+	_, err = provider.buildConfigFromResults(results)
+	assert.Error(t, err)
+
+	assert.True(t, encryptedStorageKeys["config.sftp_storage_private_key"])
+	assert.True(t, encryptedStorageKeys["config.sftp_storage_private_key_passphrase"])
+}
@@ -0,0 +1,34 @@
+package storageprovider
+
+import (
+	"github.com/cshum/imagor-studio/server/internal/tracing"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/cshum/imagor-studio/server/pkg/storage/noopstorage"
+	"github.com/cshum/imagor-studio/server/pkg/storage/s3storage"
+	"github.com/cshum/imagor-studio/server/pkg/storage/sftpstorage"
+	"github.com/cshum/imagor-studio/server/pkg/storage/tracedstorage"
+)
+
+// traced wraps s to trace its calls when tracing is configured
+func (p *Provider) traced(s storage.Storage) storage.Storage {
+	if s == nil || !tracing.Enabled(p.config) {
+		return s
+	}
+	return tracedstorage.New(s, backendName(s))
+}
+
+// backendName names the backend of s in traces
+func backendName(s storage.Storage) string {
+	switch s.(type) {
+	case *filestorage.FileStorage:
+		return "file"
+	case *s3storage.S3Storage:
+		return "s3"
+	case *sftpstorage.SFTPStorage:
+		return "sftp"
+	case *noopstorage.NoOpStorage:
+		return "noop"
+	}
+	return "other"
+}
@@ -0,0 +1,421 @@
+// Package storagestats keeps the storage usage of folders and the quotas
+// bounding it.
+//
+// Usage is counted by library scans walking the storage, see Compute, so
+// the stats of a folder are as of the latest scan. Files uploaded through
+// the server are recorded as they are stored: they count toward the quota
+// of their uploader, and toward the quotas of their folders until the next
+// scan counts them. Deletions are reflected by the next scan.
+package storagestats
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/database"
+	"github.com/cshum/imagor-studio/server/internal/model"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/cshum/imagor-studio/server/pkg/uuid"
+	"github.com/uptrace/bun"
+	"go.uber.org/zap"
+)
+
+// Quota kinds
+const (
+	// KindUser bounds the bytes a user uploaded, Target is the user ID
+	KindUser = "user"
+	// KindPath bounds the bytes stored below a folder, Target is the folder
+	// path, empty for the whole storage
+	KindPath = "path"
+)
+
+// insertChunkSize keeps inserts below the SQLite parameter limit
+const insertChunkSize = 100
+
+// ExtensionUsage is the usage of the files of one extension
+type ExtensionUsage struct {
+	// Extension is lower case without the dot, empty for files without one
+	Extension string `json:"extension"`
+	FileCount int64  `json:"fileCount"`
+	TotalSize int64  `json:"totalSize"`
+}
+
+// Stats is the usage of a folder and everything below it
+type Stats struct {
+	FolderPath string
+	FileCount  int64
+	TotalSize  int64
+	// Extensions sorted by descending size
+	Extensions []ExtensionUsage
+	ScannedAt  time.Time
+}
+
+// Quota bounds the bytes uploaded by a user or stored below a folder
+type Quota struct {
+	Kind       string
+	Target     string
+	LimitBytes int64
+	UpdatedBy  string
+	UpdatedAt  time.Time
+}
+
+// Applies reports whether an upload of filePath by userID counts toward q
+func (q Quota) Applies(userID, filePath string) bool {
+	switch q.Kind {
+	case KindUser:
+		return userID != "" && q.Target == userID
+	case KindPath:
+		return q.Target == "" || strings.HasPrefix(filePath, q.Target+"/")
+	}
+	return false
+}
+
+// Compute returns the stats of folders, each counting the files below it
+// at any depth. The root folder "" is always included.
+func Compute(files []storage.FileInfo, folders []string, scannedAt time.Time) []Stats {
+	byFolder := map[string]*Stats{"": {ScannedAt: scannedAt}}
+	for _, folder := range folders {
+		byFolder[strings.Trim(folder, "/")] = &Stats{FolderPath: strings.Trim(folder, "/"), ScannedAt: scannedAt}
+	}
+	extensions := make(map[string]map[string]*ExtensionUsage)
+	for _, file := range files {
+		ext := strings.ToLower(strings.TrimPrefix(path.Ext(file.Name), "."))
+		for folder := parent(strings.Trim(file.Path, "/")); ; folder = parent(folder) {
+			stats, ok := byFolder[folder]
+			if !ok {
+				stats = &Stats{FolderPath: folder, ScannedAt: scannedAt}
+				byFolder[folder] = stats
+			}
+			stats.FileCount++
+			stats.TotalSize += file.Size
+			if extensions[folder] == nil {
+				extensions[folder] = make(map[string]*ExtensionUsage)
+			}
+			usage, ok := extensions[folder][ext]
+			if !ok {
+				usage = &ExtensionUsage{Extension: ext}
+				extensions[folder][ext] = usage
+			}
+			usage.FileCount++
+			usage.TotalSize += file.Size
+			if folder == "" {
+				break
+			}
+		}
+	}
+	result := make([]Stats, 0, len(byFolder))
+	for folder, stats := range byFolder {
+		for _, usage := range extensions[folder] {
+			stats.Extensions = append(stats.Extensions, *usage)
+		}
+		sortExtensions(stats.Extensions)
+		result = append(result, *stats)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].FolderPath < result[j].FolderPath })
+	return result
+}
+
+func sortExtensions(extensions []ExtensionUsage) {
+	sort.Slice(extensions, func(i, j int) bool {
+		if extensions[i].TotalSize != extensions[j].TotalSize {
+			return extensions[i].TotalSize > extensions[j].TotalSize
+		}
+		return extensions[i].Extension < extensions[j].Extension
+	})
+}
+
+// parent returns the folder containing p, "" for the root
+func parent(p string) string {
+	if i := strings.LastIndex(p, "/"); i >= 0 {
+		return p[:i]
+	}
+	return ""
+}
+
+// Store keeps folder stats, uploads and quotas per scope
+type Store interface {
+	// Get returns the stats of folderPath, nil when the latest scan did
+	// not find the folder or before the first scan
+	Get(ctx context.Context, scope, folderPath string) (*Stats, error)
+	// Replace replaces the stats of scope with those of a scan
+	Replace(ctx context.Context, scope string, stats []Stats) error
+	// RecordUpload records a file uploaded by userID, replacing the record
+	// of a file uploaded at the same path
+	RecordUpload(ctx context.Context, scope, filePath, userID string, size int64) error
+	// UserUsage returns the bytes of the recorded uploads of userID
+	UserUsage(ctx context.Context, scope, userID string) (int64, error)
+	// PathUsage returns the bytes below folderPath as of the latest scan,
+	// plus those of the uploads recorded since
+	PathUsage(ctx context.Context, scope, folderPath string) (int64, error)
+	// Quotas returns the quotas of scope
+	Quotas(ctx context.Context, scope string) ([]Quota, error)
+	// SetQuota sets the quota of a user or folder, a limit of 0 removes it
+	SetQuota(ctx context.Context, scope, kind, target string, limitBytes int64, updatedBy string) error
+	// MoveFilePath keeps uploads and folder quotas following a moved file
+	// or folder
+	MoveFilePath(ctx context.Context, scope, oldPath, newPath string) error
+	// RemoveFilePath drops the uploads of a deleted file or of the files
+	// below a deleted folder, and the quotas of deleted folders
+	RemoveFilePath(ctx context.Context, scope, path string) error
+}
+
+type store struct {
+	db     *bun.DB
+	logger *zap.Logger
+}
+
+func NewStore(db *bun.DB, logger *zap.Logger) Store {
+	return &store{db: db, logger: logger}
+}
+
+func (s *store) Get(ctx context.Context, scope, folderPath string) (*Stats, error) {
+	var row model.StorageStat
+	err := s.db.NewSelect().Model(&row).
+		Where("scope = ?", scope).
+		Where("folder_path = ?", folderPath).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting storage stats: %w", err)
+	}
+	stats := &Stats{
+		FolderPath: row.FolderPath,
+		FileCount:  row.FileCount,
+		TotalSize:  row.TotalSize,
+		ScannedAt:  row.ScannedAt,
+	}
+	if row.Extensions != "" {
+		if err := json.Unmarshal([]byte(row.Extensions), &stats.Extensions); err != nil {
+			return nil, fmt.Errorf("error decoding storage stats: %w", err)
+		}
+	}
+	return stats, nil
+}
+
+func (s *store) Replace(ctx context.Context, scope string, stats []Stats) error {
+	rows := make([]model.StorageStat, len(stats))
+	for i, st := range stats {
+		extensions, err := json.Marshal(st.Extensions)
+		if err != nil {
+			return fmt.Errorf("error encoding storage stats: %w", err)
+		}
+		rows[i] = model.StorageStat{
+			ID:         uuid.GenerateUUID(),
+			Scope:      scope,
+			FolderPath: st.FolderPath,
+			FileCount:  st.FileCount,
+			TotalSize:  st.TotalSize,
+			Extensions: string(extensions),
+			ScannedAt:  st.ScannedAt.UTC(),
+		}
+	}
+	return s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewDelete().Model((*model.StorageStat)(nil)).
+			Where("scope = ?", scope).
+			Exec(ctx); err != nil {
+			return fmt.Errorf("error replacing storage stats: %w", err)
+		}
+		for start := 0; start < len(rows); start += insertChunkSize {
+			chunk := rows[start:min(start+insertChunkSize, len(rows))]
+			if _, err := tx.NewInsert().Model(&chunk).Exec(ctx); err != nil {
+				return fmt.Errorf("error saving storage stats: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+func (s *store) RecordUpload(ctx context.Context, scope, filePath, userID string, size int64) error {
+	if _, err := database.OnConflictUpdate(s.db.NewInsert().Model(&model.StorageUpload{
+		ID:         uuid.GenerateUUID(),
+		Scope:      scope,
+		FilePath:   filePath,
+		UserID:     userID,
+		Size:       size,
+		UploadedAt: time.Now().UTC(),
+	}), "scope, file_path", "user_id", "size", "uploaded_at").Exec(ctx); err != nil {
+		return fmt.Errorf("error recording upload: %w", err)
+	}
+	return nil
+}
+
+func (s *store) UserUsage(ctx context.Context, scope, userID string) (int64, error) {
+	var total sql.NullInt64
+	if err := s.db.NewSelect().Model((*model.StorageUpload)(nil)).
+		ColumnExpr("SUM(size)").
+		Where("scope = ?", scope).
+		Where("user_id = ?", userID).
+		Scan(ctx, &total); err != nil {
+		return 0, fmt.Errorf("error getting user storage usage: %w", err)
+	}
+	return total.Int64, nil
+}
+
+func (s *store) PathUsage(ctx context.Context, scope, folderPath string) (int64, error) {
+	var scanned int64
+	var scannedAt time.Time
+	stats, err := s.Get(ctx, scope, folderPath)
+	if err != nil {
+		return 0, err
+	}
+	if stats == nil && folderPath != "" {
+		// Folders the latest scan did not find hold only later uploads
+		stats, err = s.Get(ctx, scope, "")
+		if err != nil {
+			return 0, err
+		}
+		if stats != nil {
+			stats = &Stats{ScannedAt: stats.ScannedAt}
+		}
+	}
+	if stats != nil {
+		scanned = stats.TotalSize
+		scannedAt = stats.ScannedAt
+	}
+	var total sql.NullInt64
+	q := s.db.NewSelect().Model((*model.StorageUpload)(nil)).
+		ColumnExpr("SUM(size)").
+		Where("scope = ?", scope).
+		Where("uploaded_at > ?", scannedAt)
+	if folderPath != "" {
+		q = q.Where("substr(file_path, 1, ?) = ?", len(folderPath)+1, folderPath+"/")
+	}
+	if err := q.Scan(ctx, &total); err != nil {
+		return 0, fmt.Errorf("error getting folder storage usage: %w", err)
+	}
+	return scanned + total.Int64, nil
+}
+
+func (s *store) Quotas(ctx context.Context, scope string) ([]Quota, error) {
+	var rows []model.StorageQuota
+	if err := s.db.NewSelect().Model(&rows).
+		Where("scope = ?", scope).
+		Order("kind ASC", "target ASC").
+		Scan(ctx); err != nil {
+		return nil, fmt.Errorf("error getting storage quotas: %w", err)
+	}
+	quotas := make([]Quota, len(rows))
+	for i, row := range rows {
+		quotas[i] = Quota{
+			Kind:       row.Kind,
+			Target:     row.Target,
+			LimitBytes: row.LimitBytes,
+			UpdatedBy:  row.UpdatedBy,
+			UpdatedAt:  row.UpdatedAt,
+		}
+	}
+	return quotas, nil
+}
+
+func (s *store) SetQuota(ctx context.Context, scope, kind, target string, limitBytes int64, updatedBy string) error {
+	if kind != KindUser && kind != KindPath {
+		return fmt.Errorf("unknown storage quota kind: %s", kind)
+	}
+	if limitBytes < 0 {
+		return fmt.Errorf("storage quota limit must not be negative")
+	}
+	return s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewDelete().Model((*model.StorageQuota)(nil)).
+			Where("scope = ?", scope).
+			Where("kind = ?", kind).
+			Where("target = ?", target).
+			Exec(ctx); err != nil {
+			return fmt.Errorf("error replacing storage quota: %w", err)
+		}
+		if limitBytes == 0 {
+			return nil
+		}
+		if _, err := tx.NewInsert().Model(&model.StorageQuota{
+			ID:         uuid.GenerateUUID(),
+			Scope:      scope,
+			Kind:       kind,
+			Target:     target,
+			LimitBytes: limitBytes,
+			UpdatedBy:  updatedBy,
+			UpdatedAt:  time.Now().UTC(),
+		}).Exec(ctx); err != nil {
+			return fmt.Errorf("error saving storage quota: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *store) MoveFilePath(ctx context.Context, scope, oldPath, newPath string) error {
+	return s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var uploads []model.StorageUpload
+		if err := tx.NewSelect().Model(&uploads).
+			Where("scope = ?", scope).
+			Where("(file_path = ? OR substr(file_path, 1, ?) = ?)", oldPath, len(oldPath)+1, oldPath+"/").
+			Scan(ctx); err != nil {
+			return fmt.Errorf("error moving uploads: %w", err)
+		}
+		for _, upload := range uploads {
+			moved := newPath + strings.TrimPrefix(upload.FilePath, oldPath)
+			if _, err := tx.NewDelete().Model((*model.StorageUpload)(nil)).
+				Where("scope = ?", scope).
+				Where("file_path = ?", moved).
+				Exec(ctx); err != nil {
+				return fmt.Errorf("error moving uploads: %w", err)
+			}
+			if _, err := tx.NewUpdate().Model((*model.StorageUpload)(nil)).
+				Set("file_path = ?", moved).
+				Where("id = ?", upload.ID).
+				Exec(ctx); err != nil {
+				return fmt.Errorf("error moving uploads: %w", err)
+			}
+		}
+		var quotas []model.StorageQuota
+		if err := tx.NewSelect().Model(&quotas).
+			Where("scope = ?", scope).
+			Where("kind = ?", KindPath).
+			Where("(target = ? OR substr(target, 1, ?) = ?)", oldPath, len(oldPath)+1, oldPath+"/").
+			Scan(ctx); err != nil {
+			return fmt.Errorf("error moving storage quotas: %w", err)
+		}
+		for _, quota := range quotas {
+			moved := newPath + strings.TrimPrefix(quota.Target, oldPath)
+			if _, err := tx.NewDelete().Model((*model.StorageQuota)(nil)).
+				Where("scope = ?", scope).
+				Where("kind = ?", KindPath).
+				Where("target = ?", moved).
+				Exec(ctx); err != nil {
+				return fmt.Errorf("error moving storage quotas: %w", err)
+			}
+			if _, err := tx.NewUpdate().Model((*model.StorageQuota)(nil)).
+				Set("target = ?", moved).
+				Where("id = ?", quota.ID).
+				Exec(ctx); err != nil {
+				return fmt.Errorf("error moving storage quotas: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+func (s *store) RemoveFilePath(ctx context.Context, scope, path string) error {
+	return s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewDelete().Model((*model.StorageUpload)(nil)).
+			Where("scope = ?", scope).
+			Where("(file_path = ? OR substr(file_path, 1, ?) = ?)", path, len(path)+1, path+"/").
+			Exec(ctx); err != nil {
+			return fmt.Errorf("error removing uploads: %w", err)
+		}
+		if _, err := tx.NewDelete().Model((*model.StorageQuota)(nil)).
+			Where("scope = ?", scope).
+			Where("kind = ?", KindPath).
+			Where("(target = ? OR substr(target, 1, ?) = ?)", path, len(path)+1, path+"/").
+			Exec(ctx); err != nil {
+			return fmt.Errorf("error removing storage quotas: %w", err)
+		}
+		return nil
+	})
+}
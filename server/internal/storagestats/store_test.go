@@ -0,0 +1,184 @@
+package storagestats
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const scope = "system:global"
+
+func setupTestStore(t *testing.T) Store {
+	t.Helper()
+	db := testutil.NewDB(t)
+
+	return NewStore(db, zap.NewNop())
+}
+
+func file(path string, size int64) storage.FileInfo {
+	name := path
+	if i := len(parent(path)); i > 0 {
+		name = path[i+1:]
+	}
+	return storage.FileInfo{Name: name, Path: path, Size: size}
+}
+
+func TestCompute(t *testing.T) {
+	scannedAt := time.Now()
+	stats := Compute([]storage.FileInfo{
+		file("a.jpg", 10),
+		file("photos/b.JPG", 20),
+		file("photos/trip/c.mp4", 100),
+		file("photos/trip/README", 1),
+	}, []string{"photos", "photos/trip", "empty"}, scannedAt)
+
+	byFolder := make(map[string]Stats)
+	for _, st := range stats {
+		byFolder[st.FolderPath] = st
+	}
+	require.Len(t, byFolder, 4)
+	assert.Equal(t, int64(4), byFolder[""].FileCount)
+	assert.Equal(t, int64(131), byFolder[""].TotalSize)
+	assert.Equal(t, []ExtensionUsage{
+		{Extension: "mp4", FileCount: 1, TotalSize: 100},
+		{Extension: "jpg", FileCount: 2, TotalSize: 30},
+		{Extension: "", FileCount: 1, TotalSize: 1},
+	}, byFolder[""].Extensions)
+	assert.Equal(t, int64(3), byFolder["photos"].FileCount)
+	assert.Equal(t, int64(121), byFolder["photos"].TotalSize)
+	assert.Equal(t, int64(101), byFolder["photos/trip"].TotalSize)
+	assert.Equal(t, int64(0), byFolder["empty"].FileCount)
+	assert.Empty(t, byFolder["empty"].Extensions)
+	assert.Equal(t, scannedAt, byFolder["empty"].ScannedAt)
+}
+
+func TestStore_Stats(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	stats, err := s.Get(ctx, scope, "")
+	require.NoError(t, err)
+	assert.Nil(t, stats, "no stats before the first scan")
+
+	scannedAt := time.Now().Add(-time.Minute)
+	require.NoError(t, s.Replace(ctx, scope, Compute([]storage.FileInfo{
+		file("photos/a.jpg", 10),
+		file("photos/b.png", 20),
+	}, []string{"photos"}, scannedAt)))
+
+	stats, err = s.Get(ctx, scope, "photos")
+	require.NoError(t, err)
+	require.NotNil(t, stats)
+	assert.Equal(t, int64(2), stats.FileCount)
+	assert.Equal(t, int64(30), stats.TotalSize)
+	assert.Len(t, stats.Extensions, 2)
+	assert.WithinDuration(t, scannedAt, stats.ScannedAt, time.Second)
+
+	// A scan replaces the previous one
+	require.NoError(t, s.Replace(ctx, scope, Compute([]storage.FileInfo{file("docs/a.pdf", 5)}, []string{"docs"}, scannedAt)))
+	stats, err = s.Get(ctx, scope, "photos")
+	require.NoError(t, err)
+	assert.Nil(t, stats)
+	stats, err = s.Get(ctx, scope, "")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), stats.TotalSize)
+
+	stats, err = s.Get(ctx, "other", "")
+	require.NoError(t, err)
+	assert.Nil(t, stats, "stats are per scope")
+}
+
+func TestStore_Usage(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	require.NoError(t, s.RecordUpload(ctx, scope, "photos/a.jpg", "user-1", 100))
+	require.NoError(t, s.RecordUpload(ctx, scope, "photos/trip/b.jpg", "user-1", 50))
+	require.NoError(t, s.RecordUpload(ctx, scope, "docs/c.pdf", "user-2", 7))
+	// Uploads replace the record of the same path
+	require.NoError(t, s.RecordUpload(ctx, scope, "photos/a.jpg", "user-2", 10))
+
+	used, err := s.UserUsage(ctx, scope, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(50), used)
+	used, err = s.UserUsage(ctx, scope, "user-2")
+	require.NoError(t, err)
+	assert.Equal(t, int64(17), used)
+	used, err = s.UserUsage(ctx, scope, "user-3")
+	require.NoError(t, err)
+	assert.Zero(t, used)
+
+	// Without a scan, folders hold the uploads below them
+	used, err = s.PathUsage(ctx, scope, "photos")
+	require.NoError(t, err)
+	assert.Equal(t, int64(60), used)
+	used, err = s.PathUsage(ctx, scope, "")
+	require.NoError(t, err)
+	assert.Equal(t, int64(67), used)
+
+	// A scan counts the uploads before it, later uploads are added
+	require.NoError(t, s.Replace(ctx, scope, Compute([]storage.FileInfo{
+		file("photos/a.jpg", 10),
+		file("photos/trip/b.jpg", 50),
+		file("photos/x.jpg", 1000),
+	}, []string{"photos", "photos/trip"}, time.Now())))
+	require.NoError(t, s.RecordUpload(ctx, scope, "photos/new.jpg", "user-1", 3))
+	require.NoError(t, s.RecordUpload(ctx, scope, "music/d.mp3", "user-1", 4))
+	used, err = s.PathUsage(ctx, scope, "photos")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1063), used)
+	used, err = s.PathUsage(ctx, scope, "music")
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), used, "folders the scan did not find hold later uploads")
+
+	// Uploads follow moves and are dropped with deleted files
+	require.NoError(t, s.MoveFilePath(ctx, scope, "photos/trip", "archive/trip"))
+	require.NoError(t, s.RemoveFilePath(ctx, scope, "music"))
+	used, err = s.UserUsage(ctx, scope, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(53), used)
+	used, err = s.PathUsage(ctx, scope, "archive")
+	require.NoError(t, err)
+	assert.Zero(t, used, "moved uploads counted by the scan are not counted again")
+}
+
+func TestStore_Quotas(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	require.NoError(t, s.SetQuota(ctx, scope, KindUser, "user-1", 1000, "admin"))
+	require.NoError(t, s.SetQuota(ctx, scope, KindPath, "photos/trip", 500, "admin"))
+	require.NoError(t, s.SetQuota(ctx, scope, KindPath, "photos/trip", 600, "admin"))
+	assert.Error(t, s.SetQuota(ctx, scope, "group", "x", 1, "admin"))
+	assert.Error(t, s.SetQuota(ctx, scope, KindUser, "user-1", -1, "admin"))
+
+	quotas, err := s.Quotas(ctx, scope)
+	require.NoError(t, err)
+	require.Len(t, quotas, 2)
+	assert.Equal(t, Quota{Kind: KindPath, Target: "photos/trip", LimitBytes: 600, UpdatedBy: "admin", UpdatedAt: quotas[0].UpdatedAt}, quotas[0])
+	assert.Equal(t, KindUser, quotas[1].Kind)
+
+	assert.True(t, quotas[0].Applies("user-2", "photos/trip/a.jpg"))
+	assert.False(t, quotas[0].Applies("user-2", "photos/trips/a.jpg"))
+	assert.True(t, quotas[1].Applies("user-1", "docs/a.jpg"))
+	assert.False(t, quotas[1].Applies("user-2", "docs/a.jpg"))
+	assert.True(t, Quota{Kind: KindPath}.Applies("", "a.jpg"), "the root quota covers the whole storage")
+
+	// Folder quotas follow moved folders
+	require.NoError(t, s.MoveFilePath(ctx, scope, "photos", "archive"))
+	quotas, err = s.Quotas(ctx, scope)
+	require.NoError(t, err)
+	assert.Equal(t, "archive/trip", quotas[0].Target)
+
+	require.NoError(t, s.RemoveFilePath(ctx, scope, "archive"))
+	require.NoError(t, s.SetQuota(ctx, scope, KindUser, "user-1", 0, "admin"))
+	quotas, err = s.Quotas(ctx, scope)
+	require.NoError(t, err)
+	assert.Empty(t, quotas)
+}
@@ -0,0 +1,881 @@
+// Package tagstore persists user tags: a per-scope hierarchy of tags such as
+// "Animals/Dogs/Beagle", alternative names resolving to a tag, and the files
+// each tag is attached to.
+package tagstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/model"
+	"github.com/cshum/imagor-studio/server/pkg/uuid"
+	"github.com/uptrace/bun"
+	"go.uber.org/zap"
+)
+
+const (
+	// Separator splits a tag path into hierarchy levels
+	Separator = "/"
+
+	maxSegmentLength = 100
+	maxDepth         = 10
+)
+
+var (
+	ErrNotFound = errors.New("tag not found")
+	// ErrConflict is returned when a name is already used by another tag or alias
+	ErrConflict = errors.New("tag name already in use")
+	ErrInvalid  = errors.New("invalid tag")
+)
+
+// Tag is a tag with its aliases and the number of files directly tagged
+type Tag struct {
+	ID        string
+	ParentID  *string
+	Name      string
+	Path      string
+	Aliases   []string
+	FileCount int
+}
+
+type Store interface {
+	// List returns every tag in scope ordered by path, counting the files
+	// below root, every file when root is empty
+	List(ctx context.Context, scope, root string) ([]*Tag, error)
+	Get(ctx context.Context, scope, id string) (*Tag, error)
+	// Resolve finds a tag by path or alias, case-insensitively. Returns nil
+	// when nothing matches.
+	Resolve(ctx context.Context, scope, name string) (*Tag, error)
+	// Create creates the tag and any missing ancestors, returning the
+	// existing tag when the path is already taken by a tag
+	Create(ctx context.Context, scope, path string) (*Tag, error)
+	// Rename moves a tag, and its descendants with it, to a new path
+	Rename(ctx context.Context, scope, id, newPath string) (*Tag, error)
+	// Merge folds source into target: files, aliases and children move to
+	// target and the source path becomes an alias of target
+	Merge(ctx context.Context, scope, sourceID, targetID string) (*Tag, error)
+	// Delete removes a tag with its descendants and their file associations
+	Delete(ctx context.Context, scope, id string) error
+	AddAlias(ctx context.Context, scope, id, alias string) (*Tag, error)
+	RemoveAlias(ctx context.Context, scope, id, alias string) (*Tag, error)
+
+	// SetFileTags replaces the tags of a file, resolving names by path or
+	// alias and creating missing tags
+	SetFileTags(ctx context.Context, scope, filePath string, names []string) ([]*Tag, error)
+	// TagFile adds tags to a file, resolving names by path or alias and
+	// creating missing tags. Returns every tag of the file.
+	TagFile(ctx context.Context, scope, filePath string, names []string) ([]*Tag, error)
+	// UntagFile removes tags from a file, ignoring names matching no tag.
+	// Returns the remaining tags of the file.
+	UntagFile(ctx context.Context, scope, filePath string, names []string) ([]*Tag, error)
+	FileTags(ctx context.Context, scope, filePath string) ([]*Tag, error)
+	// FilesWithTag returns tagged file paths below root, every tagged file
+	// when root is empty, including files tagged with a descendant when
+	// includeDescendants is set
+	FilesWithTag(ctx context.Context, scope, name string, includeDescendants bool, root string) ([]string, error)
+	// FilesWithTags returns file paths tagged with any of names or their
+	// descendants, ignoring names matching no tag
+	FilesWithTags(ctx context.Context, scope string, names []string) ([]string, error)
+	// MoveFilePath rewrites associations of a file, or of every file below a
+	// folder, after it has been moved
+	MoveFilePath(ctx context.Context, scope, oldPath, newPath string) error
+	// RemoveFilePath drops associations of a file, or of every file below a
+	// folder, after it has been deleted
+	RemoveFilePath(ctx context.Context, scope, path string) error
+}
+
+type store struct {
+	db     *bun.DB
+	logger *zap.Logger
+}
+
+func New(db *bun.DB, logger *zap.Logger) Store {
+	return &store{db: db, logger: logger}
+}
+
+// NormalizePath trims every level of a tag path and collapses repeated
+// whitespace, e.g. " Animals / Dogs " becomes "Animals/Dogs"
+func NormalizePath(path string) (string, error) {
+	var segments []string
+	for _, segment := range strings.Split(path, Separator) {
+		segment = strings.Join(strings.Fields(segment), " ")
+		if segment == "" {
+			continue
+		}
+		if len(segment) > maxSegmentLength {
+			return "", fmt.Errorf("%w: %q exceeds %d characters", ErrInvalid, segment, maxSegmentLength)
+		}
+		segments = append(segments, segment)
+	}
+	if len(segments) == 0 {
+		return "", fmt.Errorf("%w: name is empty", ErrInvalid)
+	}
+	if len(segments) > maxDepth {
+		return "", fmt.Errorf("%w: more than %d levels", ErrInvalid, maxDepth)
+	}
+	return strings.Join(segments, Separator), nil
+}
+
+func pathKey(path string) string {
+	return strings.ToLower(path)
+}
+
+// isDescendantKey reports whether key is strictly below ancestorKey
+func isDescendantKey(key, ancestorKey string) bool {
+	return strings.HasPrefix(key, ancestorKey+Separator)
+}
+
+func (s *store) List(ctx context.Context, scope, root string) ([]*Tag, error) {
+	var rows []model.Tag
+	if err := s.db.NewSelect().Model(&rows).
+		Where("scope = ?", scope).
+		OrderExpr("path_key ASC").
+		Scan(ctx); err != nil {
+		return nil, fmt.Errorf("error listing tags: %w", err)
+	}
+	return s.hydrate(ctx, s.db, scope, root, rows)
+}
+
+func (s *store) Get(ctx context.Context, scope, id string) (*Tag, error) {
+	row, err := getTag(ctx, s.db, scope, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.hydrateOne(ctx, s.db, scope, row)
+}
+
+func (s *store) Resolve(ctx context.Context, scope, name string) (*Tag, error) {
+	row, err := resolveTag(ctx, s.db, scope, name)
+	if err != nil || row == nil {
+		return nil, err
+	}
+	return s.hydrateOne(ctx, s.db, scope, row)
+}
+
+func (s *store) Create(ctx context.Context, scope, path string) (*Tag, error) {
+	normalized, err := NormalizePath(path)
+	if err != nil {
+		return nil, err
+	}
+	var row *model.Tag
+	err = s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		row, err = ensurePath(ctx, tx, scope, normalized)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.hydrateOne(ctx, s.db, scope, row)
+}
+
+func (s *store) Rename(ctx context.Context, scope, id, newPath string) (*Tag, error) {
+	normalized, err := NormalizePath(newPath)
+	if err != nil {
+		return nil, err
+	}
+	newKey := pathKey(normalized)
+	err = s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		tag, err := getTag(ctx, tx, scope, id)
+		if err != nil {
+			return err
+		}
+		if newKey == tag.PathKey {
+			// Case-only change
+			return retag(ctx, tx, tag, normalized, tag.ParentID)
+		}
+		if isDescendantKey(newKey, tag.PathKey) {
+			return fmt.Errorf("%w: cannot move a tag below itself", ErrInvalid)
+		}
+		if err := ensureNameFree(ctx, tx, scope, newKey); err != nil {
+			return err
+		}
+		var parentID *string
+		if i := strings.LastIndex(normalized, Separator); i >= 0 {
+			parent, err := ensurePath(ctx, tx, scope, normalized[:i])
+			if err != nil {
+				return err
+			}
+			parentID = &parent.ID
+		}
+		return retag(ctx, tx, tag, normalized, parentID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, scope, id)
+}
+
+func (s *store) Merge(ctx context.Context, scope, sourceID, targetID string) (*Tag, error) {
+	if sourceID == targetID {
+		return nil, fmt.Errorf("%w: cannot merge a tag into itself", ErrInvalid)
+	}
+	err := s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		source, err := getTag(ctx, tx, scope, sourceID)
+		if err != nil {
+			return err
+		}
+		target, err := getTag(ctx, tx, scope, targetID)
+		if err != nil {
+			return err
+		}
+		if isDescendantKey(target.PathKey, source.PathKey) {
+			return fmt.Errorf("%w: cannot merge a tag into its own descendant", ErrInvalid)
+		}
+		return mergeInto(ctx, tx, source, target)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, scope, targetID)
+}
+
+func (s *store) Delete(ctx context.Context, scope, id string) error {
+	return s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		tag, err := getTag(ctx, tx, scope, id)
+		if err != nil {
+			return err
+		}
+		ids, err := subtreeIDs(ctx, tx, scope, tag)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.NewDelete().Model((*model.FileTag)(nil)).
+			Where("scope = ?", scope).Where("tag_id IN (?)", bun.In(ids)).Exec(ctx); err != nil {
+			return fmt.Errorf("error deleting file tags: %w", err)
+		}
+		if _, err := tx.NewDelete().Model((*model.TagAlias)(nil)).
+			Where("scope = ?", scope).Where("tag_id IN (?)", bun.In(ids)).Exec(ctx); err != nil {
+			return fmt.Errorf("error deleting tag aliases: %w", err)
+		}
+		if _, err := tx.NewDelete().Model((*model.Tag)(nil)).
+			Where("scope = ?", scope).Where("id IN (?)", bun.In(ids)).Exec(ctx); err != nil {
+			return fmt.Errorf("error deleting tags: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *store) AddAlias(ctx context.Context, scope, id, alias string) (*Tag, error) {
+	normalized, err := NormalizePath(alias)
+	if err != nil {
+		return nil, err
+	}
+	err = s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := getTag(ctx, tx, scope, id); err != nil {
+			return err
+		}
+		if err := ensureNameFree(ctx, tx, scope, pathKey(normalized)); err != nil {
+			return err
+		}
+		return insertAlias(ctx, tx, scope, id, normalized)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, scope, id)
+}
+
+func (s *store) RemoveAlias(ctx context.Context, scope, id, alias string) (*Tag, error) {
+	normalized, err := NormalizePath(alias)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.db.NewDelete().Model((*model.TagAlias)(nil)).
+		Where("scope = ?", scope).
+		Where("tag_id = ?", id).
+		Where("alias_key = ?", pathKey(normalized)).
+		Exec(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error removing tag alias: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, fmt.Errorf("%w: alias %q", ErrNotFound, normalized)
+	}
+	return s.Get(ctx, scope, id)
+}
+
+func (s *store) SetFileTags(ctx context.Context, scope, filePath string, names []string) ([]*Tag, error) {
+	var rows []model.Tag
+	err := s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var err error
+		if rows, err = resolveNames(ctx, tx, scope, names, true); err != nil {
+			return err
+		}
+		if _, err := tx.NewDelete().Model((*model.FileTag)(nil)).
+			Where("scope = ?", scope).Where("file_path = ?", filePath).Exec(ctx); err != nil {
+			return fmt.Errorf("error clearing file tags: %w", err)
+		}
+		return insertFileTags(ctx, tx, scope, filePath, rows)
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].PathKey < rows[j].PathKey })
+	return s.hydrate(ctx, s.db, scope, "", rows)
+}
+
+func (s *store) TagFile(ctx context.Context, scope, filePath string, names []string) ([]*Tag, error) {
+	err := s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		rows, err := resolveNames(ctx, tx, scope, names, true)
+		if err != nil || len(rows) == 0 {
+			return err
+		}
+		var existing []string
+		if err := tx.NewSelect().Model((*model.FileTag)(nil)).
+			Column("tag_id").
+			Where("scope = ?", scope).
+			Where("file_path = ?", filePath).
+			Scan(ctx, &existing); err != nil {
+			return fmt.Errorf("error getting file tags: %w", err)
+		}
+		tagged := make(map[string]bool, len(existing))
+		for _, id := range existing {
+			tagged[id] = true
+		}
+		added := rows[:0]
+		for _, row := range rows {
+			if !tagged[row.ID] {
+				added = append(added, row)
+			}
+		}
+		return insertFileTags(ctx, tx, scope, filePath, added)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.FileTags(ctx, scope, filePath)
+}
+
+func (s *store) UntagFile(ctx context.Context, scope, filePath string, names []string) ([]*Tag, error) {
+	rows, err := resolveNames(ctx, s.db, scope, names, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) > 0 {
+		ids := make([]string, 0, len(rows))
+		for _, row := range rows {
+			ids = append(ids, row.ID)
+		}
+		if _, err := s.db.NewDelete().Model((*model.FileTag)(nil)).
+			Where("scope = ?", scope).
+			Where("file_path = ?", filePath).
+			Where("tag_id IN (?)", bun.In(ids)).
+			Exec(ctx); err != nil {
+			return nil, fmt.Errorf("error untagging file: %w", err)
+		}
+	}
+	return s.FileTags(ctx, scope, filePath)
+}
+
+func (s *store) FileTags(ctx context.Context, scope, filePath string) ([]*Tag, error) {
+	var rows []model.Tag
+	if err := s.db.NewSelect().Model(&rows).
+		Where("t.scope = ?", scope).
+		Where("t.id IN (?)", s.db.NewSelect().Model((*model.FileTag)(nil)).
+			Column("tag_id").
+			Where("scope = ?", scope).
+			Where("file_path = ?", filePath)).
+		OrderExpr("t.path_key ASC").
+		Scan(ctx); err != nil {
+		return nil, fmt.Errorf("error getting file tags: %w", err)
+	}
+	return s.hydrate(ctx, s.db, scope, "", rows)
+}
+
+func (s *store) FilesWithTag(ctx context.Context, scope, name string, includeDescendants bool, root string) ([]string, error) {
+	tag, err := resolveTag(ctx, s.db, scope, name)
+	if err != nil {
+		return nil, err
+	}
+	if tag == nil {
+		return []string{}, nil
+	}
+	ids := []string{tag.ID}
+	if includeDescendants {
+		if ids, err = subtreeIDs(ctx, s.db, scope, tag); err != nil {
+			return nil, err
+		}
+	}
+	var paths []string
+	if err := below(s.db.NewSelect().Model((*model.FileTag)(nil)).
+		Distinct().
+		Column("file_path").
+		Where("scope = ?", scope).
+		Where("tag_id IN (?)", bun.In(ids)), root).
+		OrderExpr("file_path ASC").
+		Scan(ctx, &paths); err != nil {
+		return nil, fmt.Errorf("error listing tagged files: %w", err)
+	}
+	if paths == nil {
+		paths = []string{}
+	}
+	return paths, nil
+}
+
+func (s *store) FilesWithTags(ctx context.Context, scope string, names []string) ([]string, error) {
+	var ids []string
+	for _, name := range names {
+		tag, err := resolveTag(ctx, s.db, scope, name)
+		if err != nil {
+			return nil, err
+		}
+		if tag == nil {
+			continue
+		}
+		subtree, err := subtreeIDs(ctx, s.db, scope, tag)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, subtree...)
+	}
+	if len(ids) == 0 {
+		return []string{}, nil
+	}
+	var paths []string
+	if err := s.db.NewSelect().Model((*model.FileTag)(nil)).
+		Distinct().
+		Column("file_path").
+		Where("scope = ?", scope).
+		Where("tag_id IN (?)", bun.In(ids)).
+		OrderExpr("file_path ASC").
+		Scan(ctx, &paths); err != nil {
+		return nil, fmt.Errorf("error listing tagged files: %w", err)
+	}
+	if paths == nil {
+		paths = []string{}
+	}
+	return paths, nil
+}
+
+func (s *store) MoveFilePath(ctx context.Context, scope, oldPath, newPath string) error {
+	return s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		rows, err := fileTagsUnder(ctx, tx, scope, oldPath)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			moved := newPath + strings.TrimPrefix(row.FilePath, oldPath)
+			// The destination may already carry the tag
+			exists, err := tx.NewSelect().Model((*model.FileTag)(nil)).
+				Where("scope = ?", scope).
+				Where("file_path = ?", moved).
+				Where("tag_id = ?", row.TagID).
+				Exists(ctx)
+			if err != nil {
+				return fmt.Errorf("error moving file tags: %w", err)
+			}
+			if exists {
+				_, err = tx.NewDelete().Model((*model.FileTag)(nil)).
+					Where("id = ?", row.ID).
+					Exec(ctx)
+			} else {
+				_, err = tx.NewUpdate().Model((*model.FileTag)(nil)).
+					Set("file_path = ?", moved).
+					Where("id = ?", row.ID).
+					Exec(ctx)
+			}
+			if err != nil {
+				return fmt.Errorf("error moving file tags: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+func (s *store) RemoveFilePath(ctx context.Context, scope, path string) error {
+	rows, err := fileTagsUnder(ctx, s.db, scope, path)
+	if err != nil || len(rows) == 0 {
+		return err
+	}
+	ids := make([]string, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+	}
+	if _, err := s.db.NewDelete().Model((*model.FileTag)(nil)).
+		Where("id IN (?)", bun.In(ids)).Exec(ctx); err != nil {
+		return fmt.Errorf("error removing file tags: %w", err)
+	}
+	return nil
+}
+
+// fileTagsUnder returns associations for path itself and any file below it
+func fileTagsUnder(ctx context.Context, db bun.IDB, scope, path string) ([]model.FileTag, error) {
+	var rows []model.FileTag
+	if err := db.NewSelect().Model(&rows).
+		Where("scope = ?", scope).
+		Where("(file_path = ? OR substr(file_path, 1, ?) = ?)", path, len(path)+1, path+"/").
+		Scan(ctx); err != nil {
+		return nil, fmt.Errorf("error listing file tags: %w", err)
+	}
+	return rows, nil
+}
+
+func getTag(ctx context.Context, db bun.IDB, scope, id string) (*model.Tag, error) {
+	var row model.Tag
+	err := db.NewSelect().Model(&row).
+		Where("scope = ?", scope).
+		Where("id = ?", id).
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error getting tag: %w", err)
+	}
+	return &row, nil
+}
+
+func getTagByKey(ctx context.Context, db bun.IDB, scope, key string) (*model.Tag, error) {
+	var row model.Tag
+	err := db.NewSelect().Model(&row).
+		Where("scope = ?", scope).
+		Where("path_key = ?", key).
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting tag: %w", err)
+	}
+	return &row, nil
+}
+
+// resolveTag matches a tag path first, then an alias
+func resolveTag(ctx context.Context, db bun.IDB, scope, name string) (*model.Tag, error) {
+	normalized, err := NormalizePath(name)
+	if err != nil {
+		return nil, err
+	}
+	key := pathKey(normalized)
+	row, err := getTagByKey(ctx, db, scope, key)
+	if err != nil || row != nil {
+		return row, err
+	}
+	var alias model.TagAlias
+	err = db.NewSelect().Model(&alias).
+		Where("scope = ?", scope).
+		Where("alias_key = ?", key).
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error resolving tag alias: %w", err)
+	}
+	return getTag(ctx, db, scope, alias.TagID)
+}
+
+// ensureNameFree fails when key is used by a tag path or alias
+func ensureNameFree(ctx context.Context, db bun.IDB, scope, key string) error {
+	existing, err := getTagByKey(ctx, db, scope, key)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("%w: %q is a tag, merge instead", ErrConflict, existing.Path)
+	}
+	aliasExists, err := db.NewSelect().Model((*model.TagAlias)(nil)).
+		Where("scope = ?", scope).
+		Where("alias_key = ?", key).
+		Exists(ctx)
+	if err != nil {
+		return fmt.Errorf("error checking tag aliases: %w", err)
+	}
+	if aliasExists {
+		return fmt.Errorf("%w: alias of another tag", ErrConflict)
+	}
+	return nil
+}
+
+// ensurePath returns the tag at path, creating it and missing ancestors
+func ensurePath(ctx context.Context, db bun.IDB, scope, path string) (*model.Tag, error) {
+	segments := strings.Split(path, Separator)
+	var parent *model.Tag
+	for i := range segments {
+		current := strings.Join(segments[:i+1], Separator)
+		key := pathKey(current)
+		row, err := getTagByKey(ctx, db, scope, key)
+		if err != nil {
+			return nil, err
+		}
+		if row == nil {
+			if err := ensureNameFree(ctx, db, scope, key); err != nil {
+				return nil, err
+			}
+			now := time.Now()
+			row = &model.Tag{
+				ID:        uuid.GenerateUUID(),
+				Scope:     scope,
+				Name:      segments[i],
+				Path:      current,
+				PathKey:   key,
+				CreatedAt: now,
+				UpdatedAt: now,
+			}
+			if parent != nil {
+				row.ParentID = &parent.ID
+			}
+			if _, err := db.NewInsert().Model(row).Exec(ctx); err != nil {
+				return nil, fmt.Errorf("error creating tag: %w", err)
+			}
+		}
+		parent = row
+	}
+	return parent, nil
+}
+
+// resolveNames resolves tag names by path or alias, deduplicated in the
+// given order. Missing tags are created when create is set, and skipped
+// otherwise.
+func resolveNames(ctx context.Context, db bun.IDB, scope string, names []string, create bool) ([]model.Tag, error) {
+	var rows []model.Tag
+	seen := make(map[string]bool)
+	for _, name := range names {
+		row, err := resolveTag(ctx, db, scope, name)
+		if err != nil {
+			return nil, err
+		}
+		if row == nil {
+			if !create {
+				continue
+			}
+			normalized, err := NormalizePath(name)
+			if err != nil {
+				return nil, err
+			}
+			if row, err = ensurePath(ctx, db, scope, normalized); err != nil {
+				return nil, err
+			}
+		}
+		if !seen[row.ID] {
+			seen[row.ID] = true
+			rows = append(rows, *row)
+		}
+	}
+	return rows, nil
+}
+
+// insertFileTags attaches tags to a file
+func insertFileTags(ctx context.Context, db bun.IDB, scope, filePath string, rows []model.Tag) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	now := time.Now()
+	fileTags := make([]model.FileTag, 0, len(rows))
+	for _, row := range rows {
+		fileTags = append(fileTags, model.FileTag{
+			ID:        uuid.GenerateUUID(),
+			Scope:     scope,
+			FilePath:  filePath,
+			TagID:     row.ID,
+			CreatedAt: now,
+		})
+	}
+	if _, err := db.NewInsert().Model(&fileTags).Exec(ctx); err != nil {
+		return fmt.Errorf("error tagging file: %w", err)
+	}
+	return nil
+}
+
+// retag moves tag to newPath under parentID and rewrites descendant paths
+func retag(ctx context.Context, db bun.IDB, tag *model.Tag, newPath string, parentID *string) error {
+	var descendants []model.Tag
+	if err := db.NewSelect().Model(&descendants).
+		Where("scope = ?", tag.Scope).
+		Where("substr(path_key, 1, ?) = ?", len(tag.PathKey)+1, tag.PathKey+Separator).
+		Scan(ctx); err != nil {
+		return fmt.Errorf("error listing descendant tags: %w", err)
+	}
+
+	now := time.Now()
+	name := newPath
+	if i := strings.LastIndex(newPath, Separator); i >= 0 {
+		name = newPath[i+1:]
+	}
+	if _, err := db.NewUpdate().Model((*model.Tag)(nil)).
+		Set("name = ?", name).
+		Set("path = ?", newPath).
+		Set("path_key = ?", pathKey(newPath)).
+		Set("parent_id = ?", parentID).
+		Set("updated_at = ?", now).
+		Where("id = ?", tag.ID).
+		Exec(ctx); err != nil {
+		return fmt.Errorf("error renaming tag: %w", err)
+	}
+	for _, d := range descendants {
+		path := newPath + d.Path[len(tag.Path):]
+		if _, err := db.NewUpdate().Model((*model.Tag)(nil)).
+			Set("path = ?", path).
+			Set("path_key = ?", pathKey(path)).
+			Set("updated_at = ?", now).
+			Where("id = ?", d.ID).
+			Exec(ctx); err != nil {
+			return fmt.Errorf("error renaming descendant tag: %w", err)
+		}
+	}
+	return nil
+}
+
+// mergeInto folds source into target, recursing into children that exist
+// under both
+func mergeInto(ctx context.Context, db bun.IDB, source, target *model.Tag) error {
+	var children []model.Tag
+	if err := db.NewSelect().Model(&children).
+		Where("scope = ?", source.Scope).
+		Where("parent_id = ?", source.ID).
+		Scan(ctx); err != nil {
+		return fmt.Errorf("error listing child tags: %w", err)
+	}
+	for i := range children {
+		child := &children[i]
+		movedPath := target.Path + Separator + child.Name
+		existing, err := getTagByKey(ctx, db, source.Scope, pathKey(movedPath))
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			if err := mergeInto(ctx, db, child, existing); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := retag(ctx, db, child, movedPath, &target.ID); err != nil {
+			return err
+		}
+	}
+
+	// Files tagged with both keep a single association
+	if _, err := db.NewDelete().Model((*model.FileTag)(nil)).
+		Where("scope = ?", source.Scope).
+		Where("tag_id = ?", source.ID).
+		Where("file_path IN (?)", db.NewSelect().Model((*model.FileTag)(nil)).
+			Column("file_path").
+			Where("scope = ?", source.Scope).
+			Where("tag_id = ?", target.ID)).
+		Exec(ctx); err != nil {
+		return fmt.Errorf("error merging file tags: %w", err)
+	}
+	if _, err := db.NewUpdate().Model((*model.FileTag)(nil)).
+		Set("tag_id = ?", target.ID).
+		Where("scope = ?", source.Scope).
+		Where("tag_id = ?", source.ID).
+		Exec(ctx); err != nil {
+		return fmt.Errorf("error merging file tags: %w", err)
+	}
+	if _, err := db.NewUpdate().Model((*model.TagAlias)(nil)).
+		Set("tag_id = ?", target.ID).
+		Where("scope = ?", source.Scope).
+		Where("tag_id = ?", source.ID).
+		Exec(ctx); err != nil {
+		return fmt.Errorf("error merging tag aliases: %w", err)
+	}
+	if _, err := db.NewDelete().Model((*model.Tag)(nil)).
+		Where("id = ?", source.ID).
+		Exec(ctx); err != nil {
+		return fmt.Errorf("error deleting merged tag: %w", err)
+	}
+	// Keep the old name resolving to the merged tag
+	return insertAlias(ctx, db, source.Scope, target.ID, source.Path)
+}
+
+func insertAlias(ctx context.Context, db bun.IDB, scope, tagID, alias string) error {
+	row := &model.TagAlias{
+		ID:        uuid.GenerateUUID(),
+		Scope:     scope,
+		TagID:     tagID,
+		Alias:     alias,
+		AliasKey:  pathKey(alias),
+		CreatedAt: time.Now(),
+	}
+	if _, err := db.NewInsert().Model(row).Exec(ctx); err != nil {
+		return fmt.Errorf("error adding tag alias: %w", err)
+	}
+	return nil
+}
+
+// subtreeIDs returns the IDs of tag and all its descendants
+func subtreeIDs(ctx context.Context, db bun.IDB, scope string, tag *model.Tag) ([]string, error) {
+	var ids []string
+	if err := db.NewSelect().Model((*model.Tag)(nil)).
+		Column("id").
+		Where("scope = ?", scope).
+		Where("substr(path_key, 1, ?) = ?", len(tag.PathKey)+1, tag.PathKey+Separator).
+		Scan(ctx, &ids); err != nil {
+		return nil, fmt.Errorf("error listing descendant tags: %w", err)
+	}
+	return append([]string{tag.ID}, ids...), nil
+}
+
+func (s *store) hydrateOne(ctx context.Context, db bun.IDB, scope string, row *model.Tag) (*Tag, error) {
+	tags, err := s.hydrate(ctx, db, scope, "", []model.Tag{*row})
+	if err != nil {
+		return nil, err
+	}
+	return tags[0], nil
+}
+
+// hydrate attaches aliases and counts of the files below root to rows,
+// keeping their order
+func (s *store) hydrate(ctx context.Context, db bun.IDB, scope, root string, rows []model.Tag) ([]*Tag, error) {
+	result := make([]*Tag, 0, len(rows))
+	if len(rows) == 0 {
+		return result, nil
+	}
+	byID := make(map[string]*Tag, len(rows))
+	ids := make([]string, 0, len(rows))
+	for _, row := range rows {
+		tag := &Tag{
+			ID:       row.ID,
+			ParentID: row.ParentID,
+			Name:     row.Name,
+			Path:     row.Path,
+			Aliases:  []string{},
+		}
+		byID[row.ID] = tag
+		ids = append(ids, row.ID)
+		result = append(result, tag)
+	}
+
+	var aliases []model.TagAlias
+	if err := db.NewSelect().Model(&aliases).
+		Where("scope = ?", scope).
+		Where("tag_id IN (?)", bun.In(ids)).
+		OrderExpr("alias_key ASC").
+		Scan(ctx); err != nil {
+		return nil, fmt.Errorf("error listing tag aliases: %w", err)
+	}
+	for _, alias := range aliases {
+		byID[alias.TagID].Aliases = append(byID[alias.TagID].Aliases, alias.Alias)
+	}
+
+	var counts []struct {
+		TagID string `bun:"tag_id"`
+		Count int    `bun:"count"`
+	}
+	if err := below(db.NewSelect().Model((*model.FileTag)(nil)).
+		Column("tag_id").
+		ColumnExpr("COUNT(*) AS count").
+		Where("scope = ?", scope).
+		Where("tag_id IN (?)", bun.In(ids)), root).
+		Group("tag_id").
+		Scan(ctx, &counts); err != nil {
+		return nil, fmt.Errorf("error counting tagged files: %w", err)
+	}
+	for _, c := range counts {
+		byID[c.TagID].FileCount = c.Count
+	}
+	return result, nil
+}
+
+// below restricts q to file paths below the root folder, or at it
+func below(q *bun.SelectQuery, root string) *bun.SelectQuery {
+	if root == "" {
+		return q
+	}
+	return q.Where("(file_path = ? OR substr(file_path, 1, ?) = ?)", root, len(root)+1, root+"/")
+}
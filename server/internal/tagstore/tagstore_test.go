@@ -0,0 +1,315 @@
+package tagstore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const scope = "system:global"
+
+func setupTestStore(t *testing.T) Store {
+	t.Helper()
+	db := testutil.NewDB(t)
+
+	return New(db, zap.NewNop())
+}
+
+func tagPaths(tags []*Tag) []string {
+	paths := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		paths = append(paths, tag.Path)
+	}
+	return paths
+}
+
+func TestNormalizePath(t *testing.T) {
+	path, err := NormalizePath("  Animals /  Dogs//Beagle  ")
+	require.NoError(t, err)
+	assert.Equal(t, "Animals/Dogs/Beagle", path)
+
+	path, err = NormalizePath("Big   Cats")
+	require.NoError(t, err)
+	assert.Equal(t, "Big Cats", path)
+
+	_, err = NormalizePath(" / ")
+	assert.ErrorIs(t, err, ErrInvalid)
+}
+
+func TestCreate_BuildsHierarchy(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	beagle, err := s.Create(ctx, scope, "Animals/Dogs/Beagle")
+	require.NoError(t, err)
+	assert.Equal(t, "Beagle", beagle.Name)
+	require.NotNil(t, beagle.ParentID)
+
+	tags, err := s.List(ctx, scope, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Animals", "Animals/Dogs", "Animals/Dogs/Beagle"}, tagPaths(tags))
+	assert.Equal(t, tags[1].ID, *beagle.ParentID)
+
+	// Idempotent and case-insensitive
+	again, err := s.Create(ctx, scope, "animals/dogs/BEAGLE")
+	require.NoError(t, err)
+	assert.Equal(t, beagle.ID, again.ID)
+
+	// Scopes are independent
+	other, err := s.List(ctx, "space:other", "")
+	require.NoError(t, err)
+	assert.Empty(t, other)
+}
+
+func TestAliases(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	dogs, err := s.Create(ctx, scope, "Animals/Dogs")
+	require.NoError(t, err)
+
+	dogs, err = s.AddAlias(ctx, scope, dogs.ID, "Puppies")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Puppies"}, dogs.Aliases)
+
+	resolved, err := s.Resolve(ctx, scope, "puppies")
+	require.NoError(t, err)
+	require.NotNil(t, resolved)
+	assert.Equal(t, dogs.ID, resolved.ID)
+
+	// Names already used by a tag or alias are rejected
+	_, err = s.AddAlias(ctx, scope, dogs.ID, "animals")
+	assert.ErrorIs(t, err, ErrConflict)
+	_, err = s.Create(ctx, scope, "Puppies")
+	assert.ErrorIs(t, err, ErrConflict)
+
+	dogs, err = s.RemoveAlias(ctx, scope, dogs.ID, "PUPPIES")
+	require.NoError(t, err)
+	assert.Empty(t, dogs.Aliases)
+	_, err = s.RemoveAlias(ctx, scope, dogs.ID, "Puppies")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	missing, err := s.Resolve(ctx, scope, "Puppies")
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+}
+
+func TestFileTags_AndDescendantQueries(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	dogs, err := s.Create(ctx, scope, "Animals/Dogs")
+	require.NoError(t, err)
+	_, err = s.AddAlias(ctx, scope, dogs.ID, "Puppies")
+	require.NoError(t, err)
+
+	tags, err := s.SetFileTags(ctx, scope, "a.jpg", []string{"Animals/Dogs/Beagle", "puppies", "Animals/Dogs"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Animals/Dogs", "Animals/Dogs/Beagle"}, tagPaths(tags))
+	_, err = s.SetFileTags(ctx, scope, "b.jpg", []string{"Animals"})
+	require.NoError(t, err)
+	_, err = s.SetFileTags(ctx, scope, "c.jpg", []string{"Animals/Cats"})
+	require.NoError(t, err)
+
+	files, err := s.FilesWithTag(ctx, scope, "Animals", true, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.jpg", "b.jpg", "c.jpg"}, files)
+
+	files, err = s.FilesWithTag(ctx, scope, "Animals", false, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b.jpg"}, files)
+
+	files, err = s.FilesWithTag(ctx, scope, "puppies", true, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.jpg"}, files)
+
+	files, err = s.FilesWithTag(ctx, scope, "Birds", true, "")
+	require.NoError(t, err)
+	assert.Empty(t, files)
+
+	// Files and counts are limited to a folder
+	_, err = s.SetFileTags(ctx, scope, "alice/d.jpg", []string{"Animals"})
+	require.NoError(t, err)
+	files, err = s.FilesWithTag(ctx, scope, "Animals", true, "alice")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alice/d.jpg"}, files)
+	tags, err = s.List(ctx, scope, "alice")
+	require.NoError(t, err)
+	for _, tag := range tags {
+		if tag.Path == "Animals" {
+			assert.Equal(t, 1, tag.FileCount)
+		} else {
+			assert.Zero(t, tag.FileCount, tag.Path)
+		}
+	}
+	require.NoError(t, s.RemoveFilePath(ctx, scope, "alice"))
+
+	// Replacing tags drops the old associations
+	_, err = s.SetFileTags(ctx, scope, "a.jpg", []string{"Animals/Cats"})
+	require.NoError(t, err)
+	fileTags, err := s.FileTags(ctx, scope, "a.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Animals/Cats"}, tagPaths(fileTags))
+	assert.Equal(t, 2, fileTags[0].FileCount)
+}
+
+func TestTagAndUntagFile(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	tags, err := s.TagFile(ctx, scope, "a.jpg", []string{"Animals/Dogs"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Animals/Dogs"}, tagPaths(tags))
+	tags, err = s.TagFile(ctx, scope, "a.jpg", []string{"animals/dogs", "Travel"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Animals/Dogs", "Travel"}, tagPaths(tags))
+	_, err = s.TagFile(ctx, scope, "b.jpg", []string{"Animals/Cats"})
+	require.NoError(t, err)
+	_, err = s.TagFile(ctx, scope, "c.jpg", []string{"Food"})
+	require.NoError(t, err)
+
+	files, err := s.FilesWithTags(ctx, scope, []string{"Animals", "Food", "Birds"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.jpg", "b.jpg", "c.jpg"}, files)
+	files, err = s.FilesWithTags(ctx, scope, []string{"travel"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.jpg"}, files)
+
+	tags, err = s.UntagFile(ctx, scope, "a.jpg", []string{"Travel", "Birds"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Animals/Dogs"}, tagPaths(tags))
+	files, err = s.FilesWithTags(ctx, scope, []string{"Travel"})
+	require.NoError(t, err)
+	assert.Empty(t, files)
+
+	// Untagging leaves the tag itself in place
+	travel, err := s.Resolve(ctx, scope, "Travel")
+	require.NoError(t, err)
+	require.NotNil(t, travel)
+	assert.Equal(t, 0, travel.FileCount)
+}
+
+func TestRename_MovesDescendants(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	_, err := s.SetFileTags(ctx, scope, "a.jpg", []string{"Animals/Dogs/Beagle"})
+	require.NoError(t, err)
+	dogs, err := s.Resolve(ctx, scope, "Animals/Dogs")
+	require.NoError(t, err)
+
+	renamed, err := s.Rename(ctx, scope, dogs.ID, "Pets/Canines")
+	require.NoError(t, err)
+	assert.Equal(t, "Canines", renamed.Name)
+	assert.Equal(t, "Pets/Canines", renamed.Path)
+
+	tags, err := s.List(ctx, scope, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Animals", "Pets", "Pets/Canines", "Pets/Canines/Beagle"}, tagPaths(tags))
+
+	files, err := s.FilesWithTag(ctx, scope, "Pets", true, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.jpg"}, files)
+
+	// Case-only rename
+	renamed, err = s.Rename(ctx, scope, dogs.ID, "pets/canines")
+	require.NoError(t, err)
+	assert.Equal(t, "pets/canines", renamed.Path)
+
+	_, err = s.Rename(ctx, scope, dogs.ID, "Animals")
+	assert.ErrorIs(t, err, ErrConflict)
+	_, err = s.Rename(ctx, scope, dogs.ID, "pets/canines/beagle/puppy")
+	assert.ErrorIs(t, err, ErrInvalid)
+}
+
+func TestMerge_MovesFilesAliasesAndChildren(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	_, err := s.SetFileTags(ctx, scope, "a.jpg", []string{"Doggos", "Dogs"})
+	require.NoError(t, err)
+	_, err = s.SetFileTags(ctx, scope, "b.jpg", []string{"Doggos/Beagle"})
+	require.NoError(t, err)
+	_, err = s.SetFileTags(ctx, scope, "c.jpg", []string{"Doggos/Poodle", "Dogs/Poodle"})
+	require.NoError(t, err)
+	doggos, err := s.Resolve(ctx, scope, "Doggos")
+	require.NoError(t, err)
+	_, err = s.AddAlias(ctx, scope, doggos.ID, "Pupper")
+	require.NoError(t, err)
+	dogs, err := s.Resolve(ctx, scope, "Dogs")
+	require.NoError(t, err)
+
+	merged, err := s.Merge(ctx, scope, doggos.ID, dogs.ID)
+	require.NoError(t, err)
+	assert.Equal(t, dogs.ID, merged.ID)
+	assert.ElementsMatch(t, []string{"Doggos", "Pupper"}, merged.Aliases)
+	// a.jpg carried both tags and keeps a single association
+	assert.Equal(t, 1, merged.FileCount)
+
+	tags, err := s.List(ctx, scope, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Dogs", "Dogs/Beagle", "Dogs/Poodle"}, tagPaths(tags))
+	assert.Equal(t, 1, tags[2].FileCount)
+	assert.Equal(t, []string{"Doggos/Poodle"}, tags[2].Aliases)
+
+	resolved, err := s.Resolve(ctx, scope, "doggos")
+	require.NoError(t, err)
+	assert.Equal(t, dogs.ID, resolved.ID)
+
+	files, err := s.FilesWithTag(ctx, scope, "Dogs", true, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.jpg", "b.jpg", "c.jpg"}, files)
+
+	_, err = s.Merge(ctx, scope, dogs.ID, tags[1].ID)
+	assert.ErrorIs(t, err, ErrInvalid)
+	_, err = s.Merge(ctx, scope, dogs.ID, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestDelete_RemovesSubtree(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	_, err := s.SetFileTags(ctx, scope, "a.jpg", []string{"Animals/Dogs", "Travel"})
+	require.NoError(t, err)
+	animals, err := s.Resolve(ctx, scope, "Animals")
+	require.NoError(t, err)
+
+	require.NoError(t, s.Delete(ctx, scope, animals.ID))
+	tags, err := s.List(ctx, scope, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Travel"}, tagPaths(tags))
+	fileTags, err := s.FileTags(ctx, scope, "a.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Travel"}, tagPaths(fileTags))
+
+	assert.ErrorIs(t, s.Delete(ctx, scope, animals.ID), ErrNotFound)
+}
+
+func TestMoveAndRemoveFilePath(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	_, err := s.SetFileTags(ctx, scope, "album/a.jpg", []string{"Travel"})
+	require.NoError(t, err)
+	_, err = s.SetFileTags(ctx, scope, "album/nested/b.jpg", []string{"Travel"})
+	require.NoError(t, err)
+	_, err = s.SetFileTags(ctx, scope, "album2/c.jpg", []string{"Travel"})
+	require.NoError(t, err)
+	_, err = s.SetFileTags(ctx, scope, "archive/a.jpg", []string{"Travel"})
+	require.NoError(t, err)
+
+	require.NoError(t, s.MoveFilePath(ctx, scope, "album", "archive"))
+	files, err := s.FilesWithTag(ctx, scope, "Travel", false, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"album2/c.jpg", "archive/a.jpg", "archive/nested/b.jpg"}, files)
+
+	require.NoError(t, s.RemoveFilePath(ctx, scope, "archive"))
+	files, err = s.FilesWithTag(ctx, scope, "Travel", false, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"album2/c.jpg"}, files)
+}
@@ -0,0 +1,211 @@
+// Package tenantstore persists the tenants of a multi-tenant deployment.
+// Each tenant is an isolated library with its own storage root, which its
+// members are confined to, its own users and its own settings.
+package tenantstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cshum/imagor-studio/server/internal/model"
+	"github.com/cshum/imagor-studio/server/pkg/safepath"
+	"github.com/cshum/imagor-studio/server/pkg/uuid"
+	"github.com/uptrace/bun"
+	"go.uber.org/zap"
+)
+
+const maxNameLength = 100
+
+var (
+	ErrNotFound = errors.New("tenant not found")
+	ErrInvalid  = errors.New("invalid tenant")
+	// ErrConflict is returned when the name or storage root of a tenant is
+	// taken, or its storage root overlaps with that of another tenant
+	ErrConflict = errors.New("tenant conflict")
+	// ErrNotEmpty is returned when deleting a tenant that still has members
+	ErrNotEmpty = errors.New("tenant has members")
+)
+
+// Tenant is a tenant with its member count
+type Tenant struct {
+	ID   string
+	Name string
+	// StorageRoot is the storage path the tenant is confined to, relative to
+	// the storage root of the deployment
+	StorageRoot string
+	UserCount   int
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type Store interface {
+	// List returns every tenant ordered by name
+	List(ctx context.Context) ([]*Tenant, error)
+	Get(ctx context.Context, id string) (*Tenant, error)
+	Create(ctx context.Context, name, storageRoot string) (*Tenant, error)
+	// Delete removes a tenant without members, leaving its files and
+	// settings in place
+	Delete(ctx context.Context, id string) error
+}
+
+type store struct {
+	db     *bun.DB
+	logger *zap.Logger
+}
+
+func New(db *bun.DB, logger *zap.Logger) Store {
+	return &store{db: db, logger: logger}
+}
+
+// NormalizeStorageRoot cleans a storage root to its relative form, rejecting
+// the storage root of the deployment and paths escaping it
+func NormalizeStorageRoot(storageRoot string) (string, error) {
+	if err := safepath.Validate(storageRoot); err != nil {
+		return "", fmt.Errorf("%w: storage root: %v", ErrInvalid, err)
+	}
+	storageRoot = strings.Trim(path.Clean("/"+strings.TrimSpace(storageRoot)), "/")
+	if storageRoot == "" {
+		return "", fmt.Errorf("%w: storage root must not be empty", ErrInvalid)
+	}
+	return storageRoot, nil
+}
+
+// overlaps reports whether one of the storage roots is within the other
+func overlaps(a, b string) bool {
+	return a == b || strings.HasPrefix(a, b+"/") || strings.HasPrefix(b, a+"/")
+}
+
+func (s *store) List(ctx context.Context) ([]*Tenant, error) {
+	var rows []model.Tenant
+	if err := s.db.NewSelect().Model(&rows).
+		OrderExpr("LOWER(name) ASC, created_at ASC").
+		Scan(ctx); err != nil {
+		return nil, fmt.Errorf("error listing tenants: %w", err)
+	}
+	var counts []struct {
+		TenantID string `bun:"tenant_id"`
+		Count    int    `bun:"count"`
+	}
+	if err := s.db.NewSelect().Model((*model.User)(nil)).
+		Column("tenant_id").
+		ColumnExpr("COUNT(*) AS count").
+		Where("tenant_id IS NOT NULL").
+		Group("tenant_id").
+		Scan(ctx, &counts); err != nil {
+		return nil, fmt.Errorf("error counting tenant members: %w", err)
+	}
+	userCounts := make(map[string]int, len(counts))
+	for _, count := range counts {
+		userCounts[count.TenantID] = count.Count
+	}
+	tenants := make([]*Tenant, 0, len(rows))
+	for _, row := range rows {
+		tenants = append(tenants, toTenant(row, userCounts[row.ID]))
+	}
+	return tenants, nil
+}
+
+func (s *store) Get(ctx context.Context, id string) (*Tenant, error) {
+	var row model.Tenant
+	if err := s.db.NewSelect().Model(&row).Where("id = ?", id).Scan(ctx); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error getting tenant: %w", err)
+	}
+	userCount, err := countMembers(ctx, s.db, id)
+	if err != nil {
+		return nil, err
+	}
+	return toTenant(row, userCount), nil
+}
+
+func (s *store) Create(ctx context.Context, name, storageRoot string) (*Tenant, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: name must not be empty", ErrInvalid)
+	}
+	if utf8.RuneCountInString(name) > maxNameLength {
+		return nil, fmt.Errorf("%w: name must be at most %d characters", ErrInvalid, maxNameLength)
+	}
+	storageRoot, err := NormalizeStorageRoot(storageRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	row := &model.Tenant{
+		ID:          uuid.GenerateUUID(),
+		Name:        name,
+		StorageRoot: storageRoot,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	err = s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		var existing []model.Tenant
+		if err := tx.NewSelect().Model(&existing).Scan(ctx); err != nil {
+			return fmt.Errorf("error listing tenants: %w", err)
+		}
+		for _, tenant := range existing {
+			if strings.EqualFold(tenant.Name, name) {
+				return fmt.Errorf("%w: a tenant named %q already exists", ErrConflict, tenant.Name)
+			}
+			if overlaps(tenant.StorageRoot, storageRoot) {
+				return fmt.Errorf("%w: storage root overlaps with that of tenant %q", ErrConflict, tenant.Name)
+			}
+		}
+		if _, err := tx.NewInsert().Model(row).Exec(ctx); err != nil {
+			return fmt.Errorf("error creating tenant: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return toTenant(*row, 0), nil
+}
+
+func (s *store) Delete(ctx context.Context, id string) error {
+	return s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		userCount, err := countMembers(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if userCount > 0 {
+			return fmt.Errorf("%w: move or remove its %d users first", ErrNotEmpty, userCount)
+		}
+		res, err := tx.NewDelete().Model((*model.Tenant)(nil)).Where("id = ?", id).Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("error deleting tenant: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+func countMembers(ctx context.Context, db bun.IDB, id string) (int, error) {
+	count, err := db.NewSelect().Model((*model.User)(nil)).Where("tenant_id = ?", id).Count(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error counting tenant members: %w", err)
+	}
+	return count, nil
+}
+
+func toTenant(row model.Tenant, userCount int) *Tenant {
+	return &Tenant{
+		ID:          row.ID,
+		Name:        row.Name,
+		StorageRoot: row.StorageRoot,
+		UserCount:   userCount,
+		CreatedAt:   row.CreatedAt,
+		UpdatedAt:   row.UpdatedAt,
+	}
+}
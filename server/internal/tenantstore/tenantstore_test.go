@@ -0,0 +1,71 @@
+package tenantstore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/cshum/imagor-studio/server/internal/userstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestNormalizeStorageRoot(t *testing.T) {
+	for input, expected := range map[string]string{
+		"acme":             "acme",
+		" /tenants/acme/ ": "tenants/acme",
+		"tenants//acme":    "tenants/acme",
+	} {
+		storageRoot, err := NormalizeStorageRoot(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, expected, storageRoot, input)
+	}
+	for _, input := range []string{"", "/", "../acme", "tenants/../acme"} {
+		_, err := NormalizeStorageRoot(input)
+		assert.ErrorIs(t, err, ErrInvalid, input)
+	}
+}
+
+func TestCreateListDelete(t *testing.T) {
+	db := testutil.NewDB(t)
+	s := New(db, zap.NewNop())
+	users := userstore.New(db, zap.NewNop())
+	ctx := context.Background()
+
+	_, err := s.Create(ctx, " ", "acme")
+	assert.ErrorIs(t, err, ErrInvalid)
+	acme, err := s.Create(ctx, " Acme ", "/tenants/acme/")
+	require.NoError(t, err)
+	assert.Equal(t, "Acme", acme.Name)
+	assert.Equal(t, "tenants/acme", acme.StorageRoot)
+
+	_, err = s.Create(ctx, "acme", "tenants/other")
+	assert.ErrorIs(t, err, ErrConflict, "names are unique")
+	_, err = s.Create(ctx, "Nested", "tenants/acme/nested")
+	assert.ErrorIs(t, err, ErrConflict, "storage roots must not overlap")
+	_, err = s.Create(ctx, "Parent", "tenants")
+	assert.ErrorIs(t, err, ErrConflict, "storage roots must not overlap")
+	smith, err := s.Create(ctx, "Smith Family", "tenants/acme-smith")
+	require.NoError(t, err)
+
+	_, err = users.CreateInTenant(ctx, acme.ID, "Alice", "alice", "hashedpass", "admin")
+	require.NoError(t, err)
+	tenants, err := s.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, tenants, 2)
+	assert.Equal(t, "Acme", tenants[0].Name)
+	assert.Equal(t, 1, tenants[0].UserCount)
+	assert.Equal(t, "Smith Family", tenants[1].Name)
+	assert.Equal(t, 0, tenants[1].UserCount)
+
+	got, err := s.Get(ctx, acme.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, got.UserCount)
+	_, err = s.Get(ctx, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.ErrorIs(t, s.Delete(ctx, acme.ID), ErrNotEmpty)
+	require.NoError(t, s.Delete(ctx, smith.ID))
+	assert.ErrorIs(t, s.Delete(ctx, smith.ID), ErrNotFound)
+}
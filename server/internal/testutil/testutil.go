@@ -0,0 +1,33 @@
+// Package testutil holds fixtures shared by the tests of other packages.
+package testutil
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/migrations"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	"github.com/uptrace/bun/driver/sqliteshim"
+	"github.com/uptrace/bun/migrate"
+)
+
+// NewDB returns an in-memory SQLite database with all migrations applied,
+// closed when the test ends
+func NewDB(t testing.TB) *bun.DB {
+	t.Helper()
+	sqldb, err := sql.Open(sqliteshim.ShimName, ":memory:")
+	require.NoError(t, err)
+	// Each connection would open a database of its own
+	sqldb.SetMaxOpenConns(1)
+	db := bun.NewDB(sqldb, sqlitedialect.New())
+	t.Cleanup(func() { _ = db.Close() })
+
+	migrator := migrate.NewMigrator(db, migrations.Migrations)
+	require.NoError(t, migrator.Init(context.Background()))
+	_, err = migrator.Migrate(context.Background())
+	require.NoError(t, err)
+	return db
+}
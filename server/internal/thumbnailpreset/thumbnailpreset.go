@@ -0,0 +1,282 @@
+// Package thumbnailpreset holds the thumbnail sizes and formats listings
+// link to. Presets are stored in the system registry so admins can tune
+// them per instance, every replica loading them with Sync.
+//
+// The grid, preview and full presets back the thumbnails of the web app and
+// always exist: configured presets of the same name replace them, other
+// names are added after them.
+package thumbnailpreset
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor/imagorpath"
+	"go.uber.org/zap"
+)
+
+// RegistryKey is the system registry key holding the JSON encoded preset
+// list. When the key is unset or empty, DefaultPresets are used.
+const RegistryKey = "config.thumbnail_presets"
+
+// Names of the built-in presets
+const (
+	Grid    = "grid"
+	Preview = "preview"
+	Full    = "full"
+)
+
+// Fit modes
+const (
+	// FitCrop fills the size exactly, cropping the center
+	FitCrop = "crop"
+	// FitSmart fills the size exactly, cropping around the detected subject
+	FitSmart = "smart"
+	// FitIn scales the image to fit within the size, keeping all of it
+	FitIn = "fit-in"
+)
+
+const (
+	// MaxPresets bounds the presets, each adding a URL to every listed file
+	MaxPresets = 16
+	// MaxSize bounds the width and height of presets
+	MaxSize = 8000
+)
+
+var (
+	fits        = map[string]bool{FitCrop: true, FitSmart: true, FitIn: true}
+	formats     = map[string]bool{"webp": true, "avif": true, "jpeg": true}
+	namePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,31}$`)
+)
+
+// Preset is a thumbnail size and encoding
+type Preset struct {
+	Name string `json:"name"`
+	// Width and Height bound the thumbnail, 0 scales with the other
+	Width  int `json:"width,omitempty"`
+	Height int `json:"height,omitempty"`
+	// Fit is crop, smart or fit-in, crop by default
+	Fit string `json:"fit,omitempty"`
+	// Format is webp, avif or jpeg, webp by default
+	Format string `json:"format,omitempty"`
+	// Quality from 1 to 100, 80 by default
+	Quality int `json:"quality,omitempty"`
+}
+
+// DefaultPresets are the thumbnails of the web app
+var DefaultPresets = []Preset{
+	{Name: Grid, Width: 300, Height: 225, Fit: FitCrop, Format: "webp", Quality: 80},
+	{Name: Preview, Width: 1200, Height: 900, Fit: FitIn, Format: "webp", Quality: 90},
+	{Name: Full, Width: 2400, Height: 1800, Fit: FitIn, Format: "webp", Quality: 95},
+}
+
+// Params returns the imagor params rendering the preset, followed by
+// filters
+func (p Preset) Params(filters ...imagorpath.Filter) imagorpath.Params {
+	return imagorpath.Params{
+		Width:  p.Width,
+		Height: p.Height,
+		FitIn:  p.Fit == FitIn,
+		Smart:  p.Fit == FitSmart,
+		Filters: append(imagorpath.Filters{
+			{Name: "quality", Args: fmt.Sprint(p.Quality)},
+			{Name: "format", Args: p.Format},
+		}, filters...),
+	}
+}
+
+// Normalize fills in the defaults of p and validates it
+func Normalize(p Preset) (Preset, error) {
+	p.Name = strings.TrimSpace(p.Name)
+	p.Fit = strings.ToLower(strings.TrimSpace(p.Fit))
+	p.Format = strings.ToLower(strings.TrimSpace(p.Format))
+	if p.Fit == "" {
+		p.Fit = FitCrop
+	}
+	if p.Format == "" {
+		p.Format = "webp"
+	} else if p.Format == "jpg" {
+		p.Format = "jpeg"
+	}
+	if p.Quality == 0 {
+		p.Quality = 80
+	}
+	switch {
+	case !namePattern.MatchString(p.Name):
+		return Preset{}, fmt.Errorf("invalid thumbnail preset name %q, use up to 32 lower case letters, digits and underscores", p.Name)
+	case p.Width < 0 || p.Height < 0 || p.Width > MaxSize || p.Height > MaxSize:
+		return Preset{}, fmt.Errorf("invalid thumbnail preset %s: width and height must be between 0 and %d", p.Name, MaxSize)
+	case p.Width == 0 && p.Height == 0:
+		return Preset{}, fmt.Errorf("invalid thumbnail preset %s: width or height is required", p.Name)
+	case !fits[p.Fit]:
+		return Preset{}, fmt.Errorf("invalid thumbnail preset %s: fit must be crop, smart or fit-in", p.Name)
+	case p.Fit != FitIn && (p.Width == 0 || p.Height == 0):
+		return Preset{}, fmt.Errorf("invalid thumbnail preset %s: cropping requires both width and height", p.Name)
+	case !formats[p.Format]:
+		return Preset{}, fmt.Errorf("invalid thumbnail preset %s: format must be webp, avif or jpeg", p.Name)
+	case p.Quality < 1 || p.Quality > 100:
+		return Preset{}, fmt.Errorf("invalid thumbnail preset %s: quality must be between 1 and 100", p.Name)
+	}
+	return p, nil
+}
+
+// Validate normalizes presets, rejecting invalid and duplicate ones
+func Validate(presets []Preset) ([]Preset, error) {
+	if len(presets) > MaxPresets {
+		return nil, fmt.Errorf("at most %d thumbnail presets can be set", MaxPresets)
+	}
+	seen := make(map[string]bool, len(presets))
+	result := make([]Preset, len(presets))
+	for i, p := range presets {
+		p, err := Normalize(p)
+		if err != nil {
+			return nil, err
+		}
+		if seen[p.Name] {
+			return nil, fmt.Errorf("duplicate thumbnail preset %s", p.Name)
+		}
+		seen[p.Name] = true
+		result[i] = p
+	}
+	return result, nil
+}
+
+// ParsePresets decodes a JSON preset list and validates it
+func ParsePresets(data string) ([]Preset, error) {
+	var presets []Preset
+	if err := json.Unmarshal([]byte(data), &presets); err != nil {
+		return nil, fmt.Errorf("invalid thumbnail presets: %w", err)
+	}
+	return Validate(presets)
+}
+
+// Merge returns DefaultPresets replaced by the presets of the same name,
+// followed by the other presets
+func Merge(presets []Preset) []Preset {
+	byName := make(map[string]Preset, len(presets))
+	for _, p := range presets {
+		byName[p.Name] = p
+	}
+	result := make([]Preset, 0, len(DefaultPresets)+len(presets))
+	for _, p := range DefaultPresets {
+		if configured, ok := byName[p.Name]; ok {
+			p = configured
+		}
+		result = append(result, p)
+	}
+	for _, p := range presets {
+		if !isDefault(p.Name) {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+func isDefault(name string) bool {
+	for _, p := range DefaultPresets {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Store caches the presets of the system registry
+type Store struct {
+	registryStore registrystore.Store
+	logger        *zap.Logger
+
+	mu         sync.RWMutex
+	configured []Preset
+	presets    []Preset
+}
+
+// New creates a preset store, call Sync to load the stored presets
+func New(registryStore registrystore.Store, logger *zap.Logger) *Store {
+	return &Store{registryStore: registryStore, logger: logger, presets: DefaultPresets}
+}
+
+// Sync reloads the presets from the registry, so changes made on other
+// replicas or through the registry API take effect
+func (s *Store) Sync() error {
+	return s.Load(context.Background())
+}
+
+// Load reads the presets from the registry. Invalid presets are logged and
+// replaced by the defaults.
+func (s *Store) Load(ctx context.Context) error {
+	entries, err := s.registryStore.GetMulti(ctx, registrystore.SystemOwnerID, []string{RegistryKey})
+	if err != nil {
+		return fmt.Errorf("failed to load thumbnail presets: %w", err)
+	}
+	var configured []Preset
+	for _, entry := range entries {
+		if entry == nil || entry.Key != RegistryKey || strings.TrimSpace(entry.Value) == "" {
+			continue
+		}
+		if configured, err = ParsePresets(entry.Value); err != nil {
+			s.logger.Warn("Invalid thumbnail presets, using defaults", zap.Error(err))
+			configured = nil
+		}
+	}
+	s.set(configured)
+	return nil
+}
+
+// Presets returns the effective presets, the defaults merged with the
+// configured ones
+func (s *Store) Presets() []Preset {
+	if s == nil {
+		return DefaultPresets
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.presets
+}
+
+// Configured returns the presets set by admins, without the defaults
+func (s *Store) Configured() []Preset {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.configured
+}
+
+// Set validates and stores presets. An empty list restores the defaults.
+func (s *Store) Set(ctx context.Context, presets []Preset) ([]Preset, error) {
+	presets, err := Validate(presets)
+	if err != nil {
+		return nil, err
+	}
+	if len(presets) == 0 {
+		// DeleteMulti is idempotent, resetting the defaults is not an error
+		if err := s.registryStore.DeleteMulti(ctx, registrystore.SystemOwnerID, []string{RegistryKey}); err != nil {
+			return nil, fmt.Errorf("failed to clear thumbnail presets: %w", err)
+		}
+	} else {
+		data, err := json.Marshal(presets)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := s.registryStore.Set(ctx, registrystore.SystemOwnerID, RegistryKey, string(data), false); err != nil {
+			return nil, fmt.Errorf("failed to save thumbnail presets: %w", err)
+		}
+	}
+	s.set(presets)
+	return s.Presets(), nil
+}
+
+func (s *Store) set(configured []Preset) {
+	presets := DefaultPresets
+	if len(configured) > 0 {
+		presets = Merge(configured)
+	}
+	s.mu.Lock()
+	s.configured = configured
+	s.presets = presets
+	s.mu.Unlock()
+}
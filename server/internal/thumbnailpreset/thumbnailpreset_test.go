@@ -0,0 +1,115 @@
+package thumbnailpreset
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor/imagorpath"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// memoryRegistryStore keeps the system registry in memory
+type memoryRegistryStore struct {
+	registrystore.Store
+	data map[string]string
+}
+
+func (m *memoryRegistryStore) GetMulti(_ context.Context, _ string, keys []string) ([]*registrystore.Registry, error) {
+	var result []*registrystore.Registry
+	for _, key := range keys {
+		if value, ok := m.data[key]; ok {
+			result = append(result, &registrystore.Registry{Key: key, Value: value})
+		}
+	}
+	return result, nil
+}
+
+func (m *memoryRegistryStore) Set(_ context.Context, _, key, value string, _ bool) (*registrystore.Registry, error) {
+	m.data[key] = value
+	return &registrystore.Registry{Key: key, Value: value}, nil
+}
+
+func (m *memoryRegistryStore) DeleteMulti(_ context.Context, _ string, keys []string) error {
+	for _, key := range keys {
+		delete(m.data, key)
+	}
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	presets, err := Validate([]Preset{{Name: "square", Width: 512, Height: 512, Format: "JPG"}, {Name: "wide", Width: 1600, Fit: "fit-in"}})
+	require.NoError(t, err)
+	assert.Equal(t, Preset{Name: "square", Width: 512, Height: 512, Fit: FitCrop, Format: "jpeg", Quality: 80}, presets[0])
+	assert.Equal(t, FitIn, presets[1].Fit)
+
+	for _, invalid := range [][]Preset{
+		{{Name: "Square", Width: 10, Height: 10}},
+		{{Name: "a", Width: 10}},
+		{{Name: "a", Fit: "fit-in"}},
+		{{Name: "a", Width: MaxSize + 1, Fit: "fit-in"}},
+		{{Name: "a", Width: 10, Height: 10, Format: "gif"}},
+		{{Name: "a", Width: 10, Height: 10, Quality: 101}},
+		{{Name: "a", Width: 10, Height: 10, Fit: "stretch"}},
+		{{Name: "a", Width: 10, Height: 10}, {Name: "a", Width: 20, Height: 20}},
+	} {
+		_, err := Validate(invalid)
+		assert.Error(t, err, "%+v", invalid)
+	}
+}
+
+func TestPresetParams(t *testing.T) {
+	params := Preset{Name: "a", Width: 400, Height: 300, Fit: FitSmart, Format: "avif", Quality: 60}.
+		Params(imagorpath.Filter{Name: "seek", Args: "1s"})
+	assert.Equal(t, imagorpath.Params{
+		Width:  400,
+		Height: 300,
+		Smart:  true,
+		Filters: imagorpath.Filters{
+			{Name: "quality", Args: "60"},
+			{Name: "format", Args: "avif"},
+			{Name: "seek", Args: "1s"},
+		},
+	}, params)
+}
+
+func TestStore(t *testing.T) {
+	registry := &memoryRegistryStore{data: map[string]string{}}
+	store := New(registry, zap.NewNop())
+	require.NoError(t, store.Sync())
+	assert.Equal(t, DefaultPresets, store.Presets())
+
+	// Configured presets replace the defaults of the same name
+	presets, err := store.Set(context.Background(), []Preset{{Name: "square", Width: 512, Height: 512}, {Name: Grid, Width: 400, Height: 400, Format: "avif"}})
+	require.NoError(t, err)
+	require.Len(t, presets, 4)
+	assert.Equal(t, []string{Grid, Preview, Full, "square"}, names(presets))
+	assert.Equal(t, "avif", presets[0].Format)
+	assert.Contains(t, registry.data[RegistryKey], "square")
+
+	// Replicas pick them up on sync
+	replica := New(registry, zap.NewNop())
+	require.NoError(t, replica.Sync())
+	assert.Equal(t, presets, replica.Presets())
+	assert.Len(t, replica.Configured(), 2)
+
+	// Invalid registry values fall back to the defaults
+	registry.data[RegistryKey] = `[{"name": "x"}]`
+	require.NoError(t, replica.Sync())
+	assert.Equal(t, DefaultPresets, replica.Presets())
+
+	_, err = store.Set(context.Background(), nil)
+	require.NoError(t, err)
+	assert.NotContains(t, registry.data, RegistryKey)
+	assert.Equal(t, DefaultPresets, store.Presets())
+}
+
+func names(presets []Preset) []string {
+	result := make([]string, len(presets))
+	for i, p := range presets {
+		result[i] = p.Name
+	}
+	return result
+}
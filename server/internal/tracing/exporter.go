@@ -0,0 +1,238 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// exportTimeout bounds a single export request
+const exportTimeout = 10 * time.Second
+
+// exporter sends spans to an OTLP/HTTP traces endpoint in the JSON encoding
+// of the OTLP protocol
+type exporter struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func newExporter(url string, headers map[string]string) *exporter {
+	return &exporter{url: url, headers: headers, client: &http.Client{Timeout: exportTimeout}}
+}
+
+// OTLP JSON messages, see opentelemetry-proto trace/v1/trace.proto. 64 bit
+// integers are strings and IDs are hex encoded.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name    string `json:"name"`
+		Version string `json:"version,omitempty"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Name              string         `json:"name"`
+		Kind              int            `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Events            []otlpEvent    `json:"events,omitempty"`
+		Status            otlpStatus     `json:"status"`
+	}
+	otlpEvent struct {
+		TimeUnixNano string         `json:"timeUnixNano"`
+		Name         string         `json:"name"`
+		Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code,omitempty"`
+		Message string `json:"message,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue *string         `json:"stringValue,omitempty"`
+		BoolValue   *bool           `json:"boolValue,omitempty"`
+		IntValue    *string         `json:"intValue,omitempty"`
+		DoubleValue *float64        `json:"doubleValue,omitempty"`
+		ArrayValue  *otlpArrayValue `json:"arrayValue,omitempty"`
+	}
+	otlpArrayValue struct {
+		Values []otlpValue `json:"values"`
+	}
+)
+
+// OTLP status codes, which differ from the numbering of codes.Code
+const (
+	otlpStatusOk    = 1
+	otlpStatusError = 2
+)
+
+func (e *exporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(toOTLP(spans))
+	if err != nil {
+		return fmt.Errorf("encode spans: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("export spans: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("export spans: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("export spans: collector responded %s", resp.Status)
+	}
+	return nil
+}
+
+func (e *exporter) Shutdown(context.Context) error {
+	return nil
+}
+
+// toOTLP groups spans by resource and instrumentation scope
+func toOTLP(spans []sdktrace.ReadOnlySpan) otlpRequest {
+	var req otlpRequest
+	resources := make(map[attribute.Distinct]int)
+	scopes := make(map[attribute.Distinct]map[instrumentation.Scope]int)
+	for _, span := range spans {
+		key := span.Resource().Equivalent()
+		ri, ok := resources[key]
+		if !ok {
+			ri = len(req.ResourceSpans)
+			resources[key] = ri
+			scopes[key] = make(map[instrumentation.Scope]int)
+			req.ResourceSpans = append(req.ResourceSpans, otlpResourceSpans{
+				Resource: otlpResource{Attributes: toKeyValues(span.Resource().Attributes())},
+			})
+		}
+		rs := &req.ResourceSpans[ri]
+		scope := span.InstrumentationScope()
+		si, ok := scopes[key][scope]
+		if !ok {
+			si = len(rs.ScopeSpans)
+			scopes[key][scope] = si
+			rs.ScopeSpans = append(rs.ScopeSpans, otlpScopeSpans{
+				Scope: otlpScope{Name: scope.Name, Version: scope.Version},
+			})
+		}
+		rs.ScopeSpans[si].Spans = append(rs.ScopeSpans[si].Spans, toSpan(span))
+	}
+	return req
+}
+
+func toSpan(span sdktrace.ReadOnlySpan) otlpSpan {
+	result := otlpSpan{
+		TraceID:           span.SpanContext().TraceID().String(),
+		SpanID:            span.SpanContext().SpanID().String(),
+		Name:              span.Name(),
+		Kind:              int(span.SpanKind()),
+		StartTimeUnixNano: unixNano(span.StartTime()),
+		EndTimeUnixNano:   unixNano(span.EndTime()),
+		Attributes:        toKeyValues(span.Attributes()),
+	}
+	if parent := span.Parent(); parent.HasSpanID() {
+		result.ParentSpanID = parent.SpanID().String()
+	}
+	for _, event := range span.Events() {
+		result.Events = append(result.Events, otlpEvent{
+			TimeUnixNano: unixNano(event.Time),
+			Name:         event.Name,
+			Attributes:   toKeyValues(event.Attributes),
+		})
+	}
+	switch span.Status().Code {
+	case codes.Ok:
+		result.Status.Code = otlpStatusOk
+	case codes.Error:
+		result.Status = otlpStatus{Code: otlpStatusError, Message: span.Status().Description}
+	}
+	return result
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func toKeyValues(attrs []attribute.KeyValue) []otlpKeyValue {
+	if len(attrs) == 0 {
+		return nil
+	}
+	result := make([]otlpKeyValue, 0, len(attrs))
+	for _, attr := range attrs {
+		result = append(result, otlpKeyValue{Key: string(attr.Key), Value: toValue(attr.Value)})
+	}
+	return result
+}
+
+func toValue(v attribute.Value) otlpValue {
+	switch v.Type() {
+	case attribute.BOOL:
+		b := v.AsBool()
+		return otlpValue{BoolValue: &b}
+	case attribute.INT64:
+		i := strconv.FormatInt(v.AsInt64(), 10)
+		return otlpValue{IntValue: &i}
+	case attribute.FLOAT64:
+		f := v.AsFloat64()
+		return otlpValue{DoubleValue: &f}
+	case attribute.BOOLSLICE:
+		return arrayValue(v.AsBoolSlice(), func(b bool) otlpValue { return otlpValue{BoolValue: &b} })
+	case attribute.INT64SLICE:
+		return arrayValue(v.AsInt64Slice(), func(i int64) otlpValue {
+			s := strconv.FormatInt(i, 10)
+			return otlpValue{IntValue: &s}
+		})
+	case attribute.FLOAT64SLICE:
+		return arrayValue(v.AsFloat64Slice(), func(f float64) otlpValue { return otlpValue{DoubleValue: &f} })
+	case attribute.STRINGSLICE:
+		return arrayValue(v.AsStringSlice(), func(s string) otlpValue { return otlpValue{StringValue: &s} })
+	}
+	s := v.Emit()
+	return otlpValue{StringValue: &s}
+}
+
+func arrayValue[T any](values []T, convert func(T) otlpValue) otlpValue {
+	array := &otlpArrayValue{Values: make([]otlpValue, 0, len(values))}
+	for _, value := range values {
+		array.Values = append(array.Values, convert(value))
+	}
+	return otlpValue{ArrayValue: array}
+}
@@ -0,0 +1,85 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware starts a server span per HTTP request, continuing the trace of
+// an incoming traceparent header
+func Middleware(next http.Handler) http.Handler {
+	return otelhttp.NewHandler(next, "http",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method + " " + route(r.URL.Path)
+		}))
+}
+
+// route shortens path for span names, which must not carry image paths,
+// signatures or capability tokens: API paths keep two segments, others one
+func route(path string) string {
+	if path == "" {
+		return "/"
+	}
+	segments := 1
+	if strings.HasPrefix(path, "/api/") {
+		segments = 2
+	}
+	end := 0
+	for i := 0; i < segments; i++ {
+		next := strings.IndexByte(path[end+1:], '/')
+		if next < 0 {
+			return path
+		}
+		end += next + 1
+	}
+	return path[:end]
+}
+
+// ResponseMiddleware wraps every GraphQL response in a span named after the
+// operation, the parent of the resolver spans
+func ResponseMiddleware() graphql.ResponseMiddleware {
+	return func(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+		name := "graphql"
+		var attrs []attribute.KeyValue
+		if oc := graphql.GetOperationContext(ctx); oc != nil {
+			if oc.Operation != nil {
+				name += " " + string(oc.Operation.Operation)
+				attrs = append(attrs, attribute.String("graphql.operation.type", string(oc.Operation.Operation)))
+			}
+			if oc.OperationName != "" {
+				name += " " + oc.OperationName
+				attrs = append(attrs, attribute.String("graphql.operation.name", oc.OperationName))
+			}
+		}
+		ctx, span := Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+		defer span.End()
+		resp := next(ctx)
+		if resp != nil && len(resp.Errors) > 0 {
+			span.SetAttributes(attribute.Int("graphql.errors", len(resp.Errors)))
+		}
+		return resp
+	}
+}
+
+// FieldMiddleware starts a span per resolver call. Fields read from already
+// resolved objects are not traced, they do no work worth a span.
+func FieldMiddleware() graphql.FieldMiddleware {
+	return func(ctx context.Context, next graphql.Resolver) (interface{}, error) {
+		fc := graphql.GetFieldContext(ctx)
+		if fc == nil || !fc.IsResolver {
+			return next(ctx)
+		}
+		ctx, span := Tracer().Start(ctx, fc.Object+"."+fc.Field.Name, trace.WithAttributes(
+			attribute.String("graphql.field.path", fc.Path().String()),
+		))
+		res, err := next(ctx)
+		End(span, err)
+		return res, err
+	}
+}
@@ -0,0 +1,121 @@
+// Package tracing records OpenTelemetry traces of HTTP requests, GraphQL
+// resolvers, storage calls and image processing, exported to an OTLP/HTTP
+// collector.
+//
+// Tracing is off unless an exporter endpoint is configured. Spans are sent
+// as OTLP JSON, accepted on the OTLP/HTTP port of the OpenTelemetry
+// Collector, Jaeger, Tempo and most tracing vendors. Incoming W3C
+// traceparent headers are honoured, so traces continue those of a proxy or
+// client.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/cshum/imagor-studio/server/internal/version"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// Name is the instrumentation scope of the spans of this server
+const Name = "github.com/cshum/imagor-studio/server"
+
+// Tracer returns the tracer of this server, backed by the global tracer
+// provider installed by Setup
+func Tracer() trace.Tracer {
+	return otel.Tracer(Name)
+}
+
+// Enabled reports whether cfg configures a trace exporter
+func Enabled(cfg *config.Config) bool {
+	return cfg != nil && cfg.OTelExporterOTLPEndpoint != ""
+}
+
+// Setup installs the global tracer provider exporting to the endpoint of
+// cfg. The returned function flushes pending spans and stops exporting; it
+// is a no-op when tracing is disabled.
+func Setup(cfg *config.Config, logger *zap.Logger) (func(context.Context) error, error) {
+	if !Enabled(cfg) {
+		return func(context.Context) error { return nil }, nil
+	}
+	endpoint, err := tracesURL(cfg.OTelExporterOTLPEndpoint)
+	if err != nil {
+		return nil, err
+	}
+	headers, err := parseHeaders(cfg.OTelExporterOTLPHeaders)
+	if err != nil {
+		return nil, err
+	}
+	serviceName := cfg.OTelServiceName
+	if serviceName == "" {
+		serviceName = "imagor-studio"
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(newExporter(endpoint, headers)),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.OTelTracesSampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", serviceName),
+			attribute.String("service.version", version.Get()),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		logger.Warn("Tracing error", zap.Error(err))
+	}))
+	logger.Info("Tracing enabled",
+		zap.String("endpoint", endpoint),
+		zap.String("serviceName", serviceName),
+		zap.Float64("sampleRatio", cfg.OTelTracesSampleRatio))
+	return provider.Shutdown, nil
+}
+
+// tracesURL returns the OTLP/HTTP traces URL below the base endpoint
+func tracesURL(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("otel-exporter-otlp-endpoint must be an http or https URL, got %q", endpoint)
+	}
+	return strings.TrimRight(endpoint, "/") + "/v1/traces", nil
+}
+
+// parseHeaders parses comma-separated key=value pairs with URL encoded
+// values, the format of OTEL_EXPORTER_OTLP_HEADERS
+func parseHeaders(s string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("otel-exporter-otlp-headers must be key=value pairs, got %q", pair)
+		}
+		decoded, err := url.PathUnescape(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("otel-exporter-otlp-headers: invalid value of %s: %w", key, err)
+		}
+		headers[key] = decoded
+	}
+	return headers, nil
+}
+
+// End ends span, recording err as its status when not nil
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,123 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestSetup_Disabled(t *testing.T) {
+	assert.False(t, Enabled(nil))
+	assert.False(t, Enabled(&config.Config{}))
+	shutdown, err := Setup(&config.Config{}, nil)
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+
+	_, err = Setup(&config.Config{OTelExporterOTLPEndpoint: "localhost:4318"}, nil)
+	assert.Error(t, err)
+}
+
+func TestParseHeaders(t *testing.T) {
+	headers, err := parseHeaders("api-key=secret, x-tenant = a%20b,")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"api-key": "secret", "x-tenant": "a b"}, headers)
+
+	_, err = parseHeaders("api-key")
+	assert.Error(t, err)
+}
+
+func TestTracesURL(t *testing.T) {
+	u, err := tracesURL("http://collector:4318/")
+	require.NoError(t, err)
+	assert.Equal(t, "http://collector:4318/v1/traces", u)
+	_, err = tracesURL("ftp://collector")
+	assert.Error(t, err)
+}
+
+func TestRoute(t *testing.T) {
+	assert.Equal(t, "/api/query", route("/api/query"))
+	assert.Equal(t, "/api/hls", route("/api/hls/session-token/index.m3u8"))
+	assert.Equal(t, "/imagor", route("/imagor/signature/fit-in/200x200/a.jpg"))
+	assert.Equal(t, "/", route("/"))
+	assert.Equal(t, "/", route(""))
+}
+
+func TestExporter(t *testing.T) {
+	var (
+		received otlpRequest
+		header   string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		header = r.Header.Get("api-key")
+		body, _ := io.ReadAll(r.Body)
+		assert.NoError(t, json.Unmarshal(body, &received))
+	}))
+	defer server.Close()
+
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(newExporter(server.URL+"/v1/traces", map[string]string{"api-key": "secret"})))
+	tracer := provider.Tracer(Name)
+	_, span := tracer.Start(context.Background(), "Query.listFiles")
+	span.End()
+	require.NoError(t, provider.Shutdown(context.Background()))
+
+	assert.Equal(t, "secret", header)
+	require.Len(t, received.ResourceSpans, 1)
+	require.Len(t, received.ResourceSpans[0].ScopeSpans, 1)
+	scope := received.ResourceSpans[0].ScopeSpans[0]
+	assert.Equal(t, Name, scope.Scope.Name)
+	require.Len(t, scope.Spans, 1)
+	assert.Equal(t, "Query.listFiles", scope.Spans[0].Name)
+	assert.Len(t, scope.Spans[0].TraceID, 32)
+}
+
+func TestToOTLP(t *testing.T) {
+	var spans []sdktrace.ReadOnlySpan
+	recorder := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanCollector(func(s sdktrace.ReadOnlySpan) {
+		spans = append(spans, s)
+	})))
+	tracer := recorder.Tracer(Name)
+	ctx, parent := tracer.Start(context.Background(), "Query.listFiles")
+	_, child := tracer.Start(ctx, "storage.List")
+	child.SetAttributes(attribute.String("storage.key", "photos"), attribute.Int("storage.items", 3), attribute.StringSlice("tags", []string{"a"}))
+	End(child, errors.New("timeout"))
+	parent.End()
+
+	req := toOTLP(spans)
+	require.Len(t, req.ResourceSpans, 1)
+	result := req.ResourceSpans[0].ScopeSpans[0].Spans
+	require.Len(t, result, 2)
+	assert.Equal(t, "storage.List", result[0].Name)
+	assert.Equal(t, result[1].SpanID, result[0].ParentSpanID)
+	assert.Equal(t, otlpStatusError, result[0].Status.Code)
+	assert.Equal(t, "timeout", result[0].Status.Message)
+	require.Len(t, result[0].Events, 1)
+	assert.Equal(t, "exception", result[0].Events[0].Name)
+
+	body, err := json.Marshal(result[0].Attributes)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"key":"storage.key","value":{"stringValue":"photos"}},
+		{"key":"storage.items","value":{"intValue":"3"}},
+		{"key":"tags","value":{"arrayValue":{"values":[{"stringValue":"a"}]}}}
+	]`, string(body))
+}
+
+// spanCollector is a span processor calling fn for every ended span
+type spanCollector func(sdktrace.ReadOnlySpan)
+
+func (c spanCollector) OnStart(context.Context, sdktrace.ReadWriteSpan) {}
+func (c spanCollector) OnEnd(s sdktrace.ReadOnlySpan)                   { c(s) }
+func (c spanCollector) Shutdown(context.Context) error                  { return nil }
+func (c spanCollector) ForceFlush(context.Context) error                { return nil }
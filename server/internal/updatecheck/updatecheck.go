@@ -0,0 +1,354 @@
+// Package updatecheck periodically looks up the latest imagor-studio release
+// on GitHub and produces an advisory describing whether an update is
+// available and how urgent it is.
+//
+// The checker is opt-in: no network call is made unless
+// config.update_check_enabled is true, so air-gapped installs stay offline.
+package updatecheck
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/registrystore"
+	"github.com/cshum/imagor-studio/server/internal/registryutil"
+	"go.uber.org/zap"
+)
+
+const (
+	// EnabledKey toggles network update checks
+	EnabledKey = "config.update_check_enabled"
+	// LatestKey stores the most recent advisory as JSON so it survives restarts
+	// and is shared across replicas
+	LatestKey = "config.update_check_latest"
+
+	// DefaultReleasesURL is the GitHub releases API endpoint for imagor-studio
+	DefaultReleasesURL = "https://api.github.com/repos/cshum/imagor-studio/releases?per_page=30"
+	// DefaultCheckInterval is the minimum time between two network checks
+	DefaultCheckInterval = 24 * time.Hour
+)
+
+// Severity classifies how urgent an update is
+type Severity string
+
+const (
+	SeverityNone     Severity = "none"
+	SeverityFeature  Severity = "feature"
+	SeveritySecurity Severity = "security"
+)
+
+// Advisory describes the newest release relative to the running version
+type Advisory struct {
+	CurrentVersion  string    `json:"currentVersion"`
+	LatestVersion   string    `json:"latestVersion"`
+	UpdateAvailable bool      `json:"updateAvailable"`
+	Severity        Severity  `json:"severity"`
+	ReleaseURL      string    `json:"releaseURL,omitempty"`
+	PublishedAt     time.Time `json:"publishedAt,omitempty"`
+	Notes           []string  `json:"notes"`
+	CheckedAt       time.Time `json:"checkedAt"`
+}
+
+// release is the subset of the GitHub release payload we use
+type release struct {
+	TagName     string    `json:"tag_name"`
+	Name        string    `json:"name"`
+	Body        string    `json:"body"`
+	HTMLURL     string    `json:"html_url"`
+	Draft       bool      `json:"draft"`
+	Prerelease  bool      `json:"prerelease"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+// Checker fetches releases and caches the latest advisory
+type Checker struct {
+	logger         *zap.Logger
+	registryStore  registrystore.Store
+	config         registryutil.ConfigProvider
+	httpClient     *http.Client
+	releasesURL    string
+	currentVersion string
+	interval       time.Duration
+	now            func() time.Time
+
+	mu     sync.RWMutex
+	latest *Advisory
+}
+
+// Option configures a Checker
+type Option func(*Checker)
+
+// WithHTTPClient sets the HTTP client used to query GitHub
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Checker) {
+		c.httpClient = client
+	}
+}
+
+// WithReleasesURL overrides the releases API endpoint
+func WithReleasesURL(url string) Option {
+	return func(c *Checker) {
+		c.releasesURL = url
+	}
+}
+
+// WithCheckInterval sets the minimum time between network checks
+func WithCheckInterval(interval time.Duration) Option {
+	return func(c *Checker) {
+		if interval > 0 {
+			c.interval = interval
+		}
+	}
+}
+
+// New creates an update checker for the given running version
+func New(logger *zap.Logger, registryStore registrystore.Store, cfg registryutil.ConfigProvider, currentVersion string, opts ...Option) *Checker {
+	c := &Checker{
+		logger:         logger,
+		registryStore:  registryStore,
+		config:         cfg,
+		httpClient:     &http.Client{Timeout: 15 * time.Second},
+		releasesURL:    DefaultReleasesURL,
+		currentVersion: currentVersion,
+		interval:       DefaultCheckInterval,
+		now:            time.Now,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// CurrentVersion returns the running version
+func (c *Checker) CurrentVersion() string {
+	return c.currentVersion
+}
+
+// Enabled reports whether network update checks are switched on
+func (c *Checker) Enabled(ctx context.Context) bool {
+	result := registryutil.GetEffectiveValue(ctx, c.registryStore, c.config, EnabledKey)
+	enabled, _ := strconv.ParseBool(result.Value)
+	return enabled
+}
+
+// Latest returns the most recent advisory, loading it from the registry when
+// this process has not checked yet. Returns nil if no check has run.
+func (c *Checker) Latest(ctx context.Context) *Advisory {
+	c.mu.RLock()
+	latest := c.latest
+	c.mu.RUnlock()
+	if latest != nil {
+		return latest
+	}
+	if c.registryStore == nil {
+		return nil
+	}
+	entry, err := c.registryStore.Get(ctx, registrystore.SystemOwnerID, LatestKey)
+	if err != nil || entry == nil || entry.Value == "" {
+		return nil
+	}
+	var advisory Advisory
+	if err := json.Unmarshal([]byte(entry.Value), &advisory); err != nil {
+		return nil
+	}
+	// Re-evaluate against the running version, the stored advisory may
+	// predate an upgrade
+	if advisory.CurrentVersion != c.currentVersion {
+		return nil
+	}
+	c.mu.Lock()
+	c.latest = &advisory
+	c.mu.Unlock()
+	return &advisory
+}
+
+// Sync runs a check when enabled and the last check is older than the
+// interval. It is meant to be called periodically from the server sync loop.
+func (c *Checker) Sync() error {
+	ctx := context.Background()
+	if !c.Enabled(ctx) {
+		return nil
+	}
+	if latest := c.Latest(ctx); latest != nil && c.now().Sub(latest.CheckedAt) < c.interval {
+		return nil
+	}
+	_, err := c.Check(ctx)
+	return err
+}
+
+// Check queries the releases API, stores and returns the resulting advisory.
+// It does not consult the enabled switch; callers decide when to check.
+func (c *Checker) Check(ctx context.Context) (*Advisory, error) {
+	releases, err := c.fetchReleases(ctx)
+	if err != nil {
+		return nil, err
+	}
+	advisory := buildAdvisory(c.currentVersion, releases, c.now())
+
+	c.mu.Lock()
+	c.latest = advisory
+	c.mu.Unlock()
+
+	if c.registryStore != nil {
+		if data, err := json.Marshal(advisory); err == nil {
+			if _, err := c.registryStore.Set(ctx, registrystore.SystemOwnerID, LatestKey, string(data), false); err != nil {
+				c.logger.Warn("Failed to store update check result", zap.Error(err))
+			}
+		}
+	}
+
+	if advisory.UpdateAvailable {
+		c.logger.Info("A newer imagor-studio release is available",
+			zap.String("current", advisory.CurrentVersion),
+			zap.String("latest", advisory.LatestVersion),
+			zap.String("severity", string(advisory.Severity)))
+	}
+	return advisory, nil
+}
+
+func (c *Checker) fetchReleases(ctx context.Context) ([]release, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.releasesURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create update check request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", "imagor-studio/"+c.currentVersion)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for updates: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to check for updates: unexpected status %d", resp.StatusCode)
+	}
+
+	var releases []release
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 4<<20)).Decode(&releases); err != nil {
+		return nil, fmt.Errorf("failed to decode releases: %w", err)
+	}
+	return releases, nil
+}
+
+// buildAdvisory compares the running version against published releases.
+// Severity is security when any release newer than the running version
+// mentions a security fix, so skipping a release never hides a CVE.
+func buildAdvisory(currentVersion string, releases []release, now time.Time) *Advisory {
+	advisory := &Advisory{
+		CurrentVersion: currentVersion,
+		LatestVersion:  currentVersion,
+		Severity:       SeverityNone,
+		Notes:          []string{},
+		CheckedAt:      now,
+	}
+
+	current, currentOK := parseVersion(currentVersion)
+	var newest *release
+	var newestVersion semver
+	for i := range releases {
+		rel := &releases[i]
+		if rel.Draft || rel.Prerelease {
+			continue
+		}
+		v, ok := parseVersion(rel.TagName)
+		if !ok {
+			continue
+		}
+		if newest == nil || v.compare(newestVersion) > 0 {
+			newest = rel
+			newestVersion = v
+		}
+		// Unknown running versions (dev builds) never report updates
+		if currentOK && v.compare(current) > 0 && isSecurityRelease(rel) {
+			advisory.Severity = SeveritySecurity
+		}
+	}
+	if newest == nil {
+		return advisory
+	}
+
+	advisory.LatestVersion = newest.TagName
+	advisory.ReleaseURL = newest.HTMLURL
+	advisory.PublishedAt = newest.PublishedAt
+	advisory.Notes = parseReleaseNotes(newest.Body)
+	advisory.UpdateAvailable = currentOK && newestVersion.compare(current) > 0
+	if !advisory.UpdateAvailable {
+		advisory.Severity = SeverityNone
+	} else if advisory.Severity != SeveritySecurity {
+		advisory.Severity = SeverityFeature
+	}
+	return advisory
+}
+
+var securityPattern = regexp.MustCompile(`(?i)\b(security|vulnerabilit(y|ies)|CVE-\d{4}-\d+|GHSA-[a-z0-9-]+)\b`)
+
+func isSecurityRelease(rel *release) bool {
+	return securityPattern.MatchString(rel.Name) || securityPattern.MatchString(rel.Body)
+}
+
+// maxReleaseNotes caps the number of note lines kept per advisory
+const maxReleaseNotes = 50
+
+// parseReleaseNotes extracts list items from a markdown release body
+func parseReleaseNotes(body string) []string {
+	notes := []string{}
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "- ") && !strings.HasPrefix(line, "* ") {
+			continue
+		}
+		note := strings.TrimSpace(line[2:])
+		if note == "" {
+			continue
+		}
+		notes = append(notes, note)
+		if len(notes) >= maxReleaseNotes {
+			break
+		}
+	}
+	return notes
+}
+
+// semver is a major.minor.patch triple; pre-release and build suffixes are ignored
+type semver [3]int
+
+func parseVersion(s string) (semver, bool) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexAny(s, "-+"); i >= 0 {
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) == 0 || len(parts) > 3 {
+		return semver{}, false
+	}
+	var v semver
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return semver{}, false
+		}
+		v[i] = n
+	}
+	return v, true
+}
+
+func (v semver) compare(other semver) int {
+	for i := range v {
+		if v[i] != other[i] {
+			if v[i] > other[i] {
+				return 1
+			}
+			return -1
+		}
+	}
+	return 0
+}
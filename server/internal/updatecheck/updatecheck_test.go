@@ -0,0 +1,141 @@
+package updatecheck
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type staticConfig map[string]string
+
+func (c staticConfig) GetByRegistryKey(key string) (string, bool) {
+	v, ok := c[key]
+	return v, ok
+}
+
+func (c staticConfig) IsEmbeddedMode() bool { return false }
+
+func newReleasesServer(t *testing.T, releases []release, hits *int) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits != nil {
+			*hits++
+		}
+		assert.Equal(t, "application/vnd.github+json", r.Header.Get("Accept"))
+		_ = json.NewEncoder(w).Encode(releases)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestBuildAdvisory(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	releases := []release{
+		{TagName: "v1.3.0", Body: "## Features\n- Albums\n* Tags\n\nSome text", HTMLURL: "https://example.com/v1.3.0"},
+		{TagName: "v1.2.1", Body: "- Fix path traversal (CVE-2025-1234)"},
+		{TagName: "v1.2.0", Body: "- Security hardening"},
+		{TagName: "v2.0.0-rc.1", Prerelease: true},
+		{TagName: "v9.0.0", Draft: true},
+		{TagName: "nightly"},
+	}
+
+	t.Run("security fix in skipped release", func(t *testing.T) {
+		advisory := buildAdvisory("v1.2.0", releases, now)
+		assert.True(t, advisory.UpdateAvailable)
+		assert.Equal(t, "v1.3.0", advisory.LatestVersion)
+		assert.Equal(t, SeveritySecurity, advisory.Severity)
+		assert.Equal(t, []string{"Albums", "Tags"}, advisory.Notes)
+		assert.Equal(t, "https://example.com/v1.3.0", advisory.ReleaseURL)
+		assert.Equal(t, now, advisory.CheckedAt)
+	})
+
+	t.Run("feature update", func(t *testing.T) {
+		advisory := buildAdvisory("1.2.1", releases, now)
+		assert.True(t, advisory.UpdateAvailable)
+		assert.Equal(t, SeverityFeature, advisory.Severity)
+	})
+
+	t.Run("up to date", func(t *testing.T) {
+		advisory := buildAdvisory("v1.3.0", releases, now)
+		assert.False(t, advisory.UpdateAvailable)
+		assert.Equal(t, SeverityNone, advisory.Severity)
+	})
+
+	t.Run("dev build never reports updates", func(t *testing.T) {
+		advisory := buildAdvisory("dev", releases, now)
+		assert.False(t, advisory.UpdateAvailable)
+		assert.Equal(t, SeverityNone, advisory.Severity)
+		assert.Equal(t, "v1.3.0", advisory.LatestVersion)
+	})
+}
+
+func TestParseVersion(t *testing.T) {
+	v, ok := parseVersion("v1.2.3-beta+build")
+	assert.True(t, ok)
+	assert.Equal(t, semver{1, 2, 3}, v)
+
+	v, ok = parseVersion("2")
+	assert.True(t, ok)
+	assert.Equal(t, semver{2, 0, 0}, v)
+
+	_, ok = parseVersion("dev")
+	assert.False(t, ok)
+	_, ok = parseVersion("1.2.3.4")
+	assert.False(t, ok)
+
+	assert.Equal(t, 1, semver{1, 10, 0}.compare(semver{1, 9, 9}))
+	assert.Equal(t, -1, semver{1, 0, 0}.compare(semver{1, 0, 1}))
+	assert.Equal(t, 0, semver{1, 0, 0}.compare(semver{1, 0, 0}))
+}
+
+func TestChecker_Check(t *testing.T) {
+	srv := newReleasesServer(t, []release{{TagName: "v1.1.0", Body: "- New"}}, nil)
+	checker := New(zap.NewNop(), nil, staticConfig{}, "v1.0.0", WithReleasesURL(srv.URL))
+
+	assert.Nil(t, checker.Latest(context.Background()))
+
+	advisory, err := checker.Check(context.Background())
+	require.NoError(t, err)
+	assert.True(t, advisory.UpdateAvailable)
+	assert.Same(t, advisory, checker.Latest(context.Background()))
+}
+
+func TestChecker_CheckHTTPError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+	checker := New(zap.NewNop(), nil, staticConfig{}, "v1.0.0", WithReleasesURL(srv.URL))
+
+	_, err := checker.Check(context.Background())
+	assert.ErrorContains(t, err, "unexpected status 403")
+}
+
+func TestChecker_SyncRespectsSwitchAndInterval(t *testing.T) {
+	hits := 0
+	srv := newReleasesServer(t, []release{{TagName: "v1.1.0"}}, &hits)
+
+	disabled := New(zap.NewNop(), nil, staticConfig{EnabledKey: "false"}, "v1.0.0", WithReleasesURL(srv.URL))
+	require.NoError(t, disabled.Sync())
+	assert.Equal(t, 0, hits, "disabled checker must not make network calls")
+
+	enabled := New(zap.NewNop(), nil, staticConfig{EnabledKey: "true"}, "v1.0.0",
+		WithReleasesURL(srv.URL), WithCheckInterval(time.Hour))
+	now := time.Now()
+	enabled.now = func() time.Time { return now }
+
+	require.NoError(t, enabled.Sync())
+	require.NoError(t, enabled.Sync())
+	assert.Equal(t, 1, hits, "second sync within the interval must be skipped")
+
+	now = now.Add(2 * time.Hour)
+	require.NoError(t, enabled.Sync())
+	assert.Equal(t, 2, hits)
+}
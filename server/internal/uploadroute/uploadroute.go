@@ -0,0 +1,210 @@
+// Package uploadroute picks the destination folder for uploads that don't
+// specify one. A user can set a default folder and routing rules such as
+// "videos go to Videos"; admins set the same keys in the system registry as
+// defaults for users that haven't configured their own.
+package uploadroute
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"path"
+	"strings"
+
+	"github.com/cshum/imagor-studio/server/internal/mediaclass"
+	"github.com/cshum/imagor-studio/server/pkg/safepath"
+)
+
+// Registry keys, read from the user registry first and the system registry
+// as the admin-provided default
+const (
+	DefaultFolderKey = "config.upload_default_folder"
+	RulesKey         = "config.upload_routing_rules"
+)
+
+// Rule routes matching uploads to Folder.
+//
+// Every non-empty criterion must match for the rule to apply; within a single
+// criterion any listed value may match. Content types accept a trailing
+// wildcard such as "video/*". System tags are the mediaclass tags derived
+// from the filename, e.g. "screenshot".
+type Rule struct {
+	Folder       string   `json:"folder"`
+	ContentTypes []string `json:"contentTypes,omitempty"`
+	Extensions   []string `json:"extensions,omitempty"`
+	NamePatterns []string `json:"namePatterns,omitempty"`
+	SystemTags   []string `json:"systemTags,omitempty"`
+}
+
+// Settings is the effective routing configuration of a user.
+// Rules are evaluated in order; the first match wins.
+type Settings struct {
+	DefaultFolder string
+	Rules         []Rule
+}
+
+// Upload describes a file being uploaded
+type Upload struct {
+	Filename    string
+	ContentType string
+}
+
+// IsExplicit reports whether an upload path names its destination folder.
+// Bare filenames are routed; anything with a folder component is kept as is.
+func IsExplicit(uploadPath string) bool {
+	return strings.Contains(strings.Trim(uploadPath, "/"), "/")
+}
+
+// NormalizeFolder cleans a folder path to the storage key form without
+// leading or trailing slashes. Folders escaping the root are rejected.
+func NormalizeFolder(folder string) (string, error) {
+	folder = strings.TrimSpace(folder)
+	if folder == "" {
+		return "", nil
+	}
+	if err := safepath.Validate(folder); err != nil {
+		return "", fmt.Errorf("invalid upload folder %q: %w", folder, err)
+	}
+	return strings.Trim(path.Clean("/"+folder), "/"), nil
+}
+
+// ParseRules decodes a JSON rule list and validates it.
+func ParseRules(data string) ([]Rule, error) {
+	if strings.TrimSpace(data) == "" {
+		return nil, nil
+	}
+	var rules []Rule
+	if err := json.Unmarshal([]byte(data), &rules); err != nil {
+		return nil, fmt.Errorf("invalid upload routing rules: %w", err)
+	}
+	for i := range rules {
+		folder, err := NormalizeFolder(rules[i].Folder)
+		if err != nil {
+			return nil, fmt.Errorf("invalid upload routing rule %d: %w", i, err)
+		}
+		if folder == "" {
+			return nil, fmt.Errorf("invalid upload routing rule %d: folder is required", i)
+		}
+		rules[i].Folder = folder
+		if !rules[i].hasCriteria() {
+			return nil, fmt.Errorf("invalid upload routing rule %d: at least one criterion is required", i)
+		}
+		for _, pattern := range rules[i].NamePatterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid upload routing rule %d: bad name pattern %q: %w", i, pattern, err)
+			}
+		}
+	}
+	return rules, nil
+}
+
+// Folder returns the folder an upload is routed to, or "" for the root.
+// Routing happens before the content is read, so classifier tags come from
+// filename rules only.
+func (s Settings) Folder(upload Upload, classifier *mediaclass.Classifier) string {
+	name := strings.ToLower(path.Base(upload.Filename))
+	ext := path.Ext(name)
+	contentType := normalizeContentType(upload.ContentType, ext)
+	var tags []string
+	if classifier != nil {
+		tags = classifier.Classify(mediaclass.Subject{Name: name})
+	}
+	for _, rule := range s.Rules {
+		if rule.matches(name, ext, contentType, tags) {
+			return rule.Folder
+		}
+	}
+	return s.DefaultFolder
+}
+
+// Route returns the storage path for an upload. Explicit paths are returned
+// unchanged; bare filenames are placed in the routed folder.
+func (s Settings) Route(uploadPath, contentType string, classifier *mediaclass.Classifier) string {
+	if IsExplicit(uploadPath) {
+		return uploadPath
+	}
+	filename := strings.Trim(uploadPath, "/")
+	folder := s.Folder(Upload{Filename: filename, ContentType: contentType}, classifier)
+	if folder == "" {
+		return uploadPath
+	}
+	return folder + "/" + filename
+}
+
+func (rule Rule) hasCriteria() bool {
+	return len(rule.ContentTypes) > 0 || len(rule.Extensions) > 0 ||
+		len(rule.NamePatterns) > 0 || len(rule.SystemTags) > 0
+}
+
+func (rule Rule) matches(name, ext, contentType string, tags []string) bool {
+	if !rule.hasCriteria() {
+		return false
+	}
+	if len(rule.ContentTypes) > 0 && !matchContentType(rule.ContentTypes, contentType) {
+		return false
+	}
+	if len(rule.Extensions) > 0 && !matchExtension(rule.Extensions, ext) {
+		return false
+	}
+	if len(rule.NamePatterns) > 0 && !matchPattern(rule.NamePatterns, name) {
+		return false
+	}
+	if len(rule.SystemTags) > 0 && !mediaclass.Filter(tags, rule.SystemTags, nil) {
+		return false
+	}
+	return true
+}
+
+// normalizeContentType strips parameters and falls back to the extension
+// when the client sent no meaningful content type
+func normalizeContentType(contentType, ext string) string {
+	contentType = strings.ToLower(strings.TrimSpace(contentType))
+	if i := strings.IndexByte(contentType, ';'); i >= 0 {
+		contentType = strings.TrimSpace(contentType[:i])
+	}
+	if contentType == "" || contentType == "application/octet-stream" {
+		if byExt := mime.TypeByExtension(ext); byExt != "" {
+			return normalizeContentType(byExt, "")
+		}
+	}
+	return contentType
+}
+
+func matchContentType(patterns []string, contentType string) bool {
+	if contentType == "" {
+		return false
+	}
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(contentType, prefix) {
+				return true
+			}
+		} else if pattern == contentType {
+			return true
+		}
+	}
+	return false
+}
+
+func matchExtension(extensions []string, ext string) bool {
+	for _, candidate := range extensions {
+		candidate = strings.ToLower(strings.TrimSpace(candidate))
+		if !strings.HasPrefix(candidate, ".") {
+			candidate = "." + candidate
+		}
+		if candidate == ext {
+			return true
+		}
+	}
+	return false
+}
+
+func matchPattern(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), name); ok {
+			return true
+		}
+	}
+	return false
+}
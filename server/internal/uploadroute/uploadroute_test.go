@@ -0,0 +1,73 @@
+package uploadroute
+
+import (
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/internal/mediaclass"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoute(t *testing.T) {
+	rules, err := ParseRules(`[
+		{"folder": "/Videos/", "contentTypes": ["video/*"]},
+		{"folder": "Screenshots", "systemTags": ["screenshot"]},
+		{"folder": "Raw", "extensions": ["cr2", ".NEF"]},
+		{"folder": "Receipts", "namePatterns": ["receipt*"], "contentTypes": ["image/jpeg"]}
+	]`)
+	require.NoError(t, err)
+	settings := Settings{DefaultFolder: "Inbox", Rules: rules}
+	classifier := mediaclass.New(mediaclass.DefaultRules)
+
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		want        string
+	}{
+		{"video by content type", "clip.bin", "video/mp4", "Videos/clip.bin"},
+		{"video by extension fallback", "clip.mp4", "application/octet-stream", "Videos/clip.mp4"},
+		{"screenshot by filename", "Screenshot_2024.png", "image/png", "Screenshots/Screenshot_2024.png"},
+		{"extension without dot", "IMG_1.CR2", "", "Raw/IMG_1.CR2"},
+		{"all criteria must match", "receipt-1.png", "image/png", "Inbox/receipt-1.png"},
+		{"content type parameters", "receipt-1.jpg", "image/jpeg; charset=binary", "Receipts/receipt-1.jpg"},
+		{"default folder", "photo.jpg", "image/jpeg", "Inbox/photo.jpg"},
+		{"leading slash is still a bare filename", "/photo.jpg", "image/jpeg", "Inbox/photo.jpg"},
+		{"explicit destination is kept", "album/clip.mp4", "video/mp4", "album/clip.mp4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, settings.Route(tt.path, tt.contentType, classifier))
+		})
+	}
+}
+
+func TestRoute_NoSettings(t *testing.T) {
+	assert.Equal(t, "clip.mp4", Settings{}.Route("clip.mp4", "video/mp4", nil))
+}
+
+func TestParseRules_Invalid(t *testing.T) {
+	for _, data := range []string{
+		`not json`,
+		`[{"contentTypes": ["video/*"]}]`,
+		`[{"folder": "Videos"}]`,
+		`[{"folder": "../outside", "contentTypes": ["video/*"]}]`,
+		`[{"folder": "Docs", "namePatterns": ["[bad"]}]`,
+	} {
+		_, err := ParseRules(data)
+		assert.Error(t, err, data)
+	}
+
+	rules, err := ParseRules("  ")
+	require.NoError(t, err)
+	assert.Nil(t, rules)
+}
+
+func TestNormalizeFolder(t *testing.T) {
+	folder, err := NormalizeFolder(" /Photos//2024/ ")
+	require.NoError(t, err)
+	assert.Equal(t, "Photos/2024", folder)
+
+	_, err = NormalizeFolder("a/../../b")
+	assert.Error(t, err)
+}
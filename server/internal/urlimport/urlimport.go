@@ -0,0 +1,330 @@
+// Package urlimport downloads remote images and videos for import into the
+// storage.
+//
+// Downloads are buffered to a temporary file so their size and type are
+// known before anything is written to storage. Since the server fetches URLs
+// chosen by users, connections to loopback, private, link-local and other
+// non-public addresses are refused unless allowed, checked on the resolved
+// address of every connection, redirects included.
+package urlimport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// DefaultMaxBytes bounds the size of a download
+	DefaultMaxBytes = 200 << 20
+	// DefaultTimeout bounds a whole download, redirects included
+	DefaultTimeout = 2 * time.Minute
+	// maxRedirects bounds the redirects followed
+	maxRedirects = 5
+	// sniffLen is the prefix read to detect the content type
+	sniffLen = 512
+)
+
+var (
+	// ErrInvalidURL is returned for URLs other than absolute http and https
+	ErrInvalidURL = errors.New("url must be an absolute http or https URL")
+	// ErrForbiddenAddress is returned when the URL resolves to an address
+	// imports may not reach
+	ErrForbiddenAddress = errors.New("url resolves to a private or reserved address")
+	// ErrTooLarge is returned when the download exceeds the size limit
+	ErrTooLarge = errors.New("download exceeds the size limit")
+	// ErrUnsupportedType is returned when the download is not an image or a
+	// video
+	ErrUnsupportedType = errors.New("download is not an image or a video")
+)
+
+// cgnat is the shared address space of carrier-grade NAT, not covered by
+// netip.Addr.IsPrivate
+var cgnat = netip.MustParsePrefix("100.64.0.0/10")
+
+// Download is a downloaded file buffered on disk. Close removes it.
+type Download struct {
+	file *os.File
+	// Size in bytes
+	Size int64
+	// ContentType is the media type without parameters, e.g. image/jpeg
+	ContentType string
+	// FileName is the name suggested by the server or the URL, "download"
+	// when neither names one, with an extension matching ContentType
+	FileName string
+}
+
+// Read reads the downloaded content
+func (d *Download) Read(p []byte) (int, error) {
+	return d.file.Read(p)
+}
+
+// Close removes the buffered file
+func (d *Download) Close() error {
+	err := d.file.Close()
+	if rmErr := os.Remove(d.file.Name()); err == nil {
+		err = rmErr
+	}
+	return err
+}
+
+// Importer downloads remote files
+type Importer struct {
+	client       *http.Client
+	maxBytes     int64
+	allowPrivate bool
+	tempDir      string
+}
+
+// Option configures an Importer
+type Option func(*Importer)
+
+// WithMaxBytes sets the largest download accepted
+func WithMaxBytes(n int64) Option {
+	return func(i *Importer) {
+		if n > 0 {
+			i.maxBytes = n
+		}
+	}
+}
+
+// WithTimeout bounds a whole download
+func WithTimeout(timeout time.Duration) Option {
+	return func(i *Importer) {
+		if timeout > 0 {
+			i.client.Timeout = timeout
+		}
+	}
+}
+
+// WithAllowPrivateNetworks lets imports reach loopback and private addresses,
+// for servers importing from hosts on their own network
+func WithAllowPrivateNetworks(allow bool) Option {
+	return func(i *Importer) {
+		i.allowPrivate = allow
+	}
+}
+
+// WithTempDir sets where downloads are buffered, the system default when
+// empty
+func WithTempDir(dir string) Option {
+	return func(i *Importer) {
+		i.tempDir = dir
+	}
+}
+
+// New returns an importer
+func New(options ...Option) *Importer {
+	i := &Importer{maxBytes: DefaultMaxBytes}
+	dialer := &net.Dialer{
+		Timeout: 30 * time.Second,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			return i.checkAddress(address)
+		},
+	}
+	i.client = &http.Client{
+		Timeout: DefaultTimeout,
+		Transport: &http.Transport{
+			// Proxies would hide the address actually connected to
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: 30 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return ErrInvalidURL
+			}
+			return nil
+		},
+	}
+	for _, option := range options {
+		option(i)
+	}
+	return i
+}
+
+// MaxBytes returns the largest download accepted
+func (i *Importer) MaxBytes() int64 {
+	return i.maxBytes
+}
+
+// checkAddress rejects connections to non-public addresses
+func (i *Importer) checkAddress(address string) error {
+	if i.allowPrivate {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	ip = ip.Unmap()
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || cgnat.Contains(ip) {
+		return ErrForbiddenAddress
+	}
+	return nil
+}
+
+// Fetch downloads rawURL. The caller must Close the download.
+func (i *Importer) Fetch(ctx context.Context, rawURL string) (*Download, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ErrInvalidURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, ErrInvalidURL
+	}
+	req.Header.Set("Accept", "image/*, video/*")
+	resp, err := i.client.Do(req)
+	if err != nil {
+		if errors.Is(err, ErrForbiddenAddress) {
+			return nil, ErrForbiddenAddress
+		}
+		return nil, fmt.Errorf("failed to download: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download: server responded %s", resp.Status)
+	}
+	if resp.ContentLength > i.maxBytes {
+		return nil, ErrTooLarge
+	}
+
+	file, err := os.CreateTemp(i.tempDir, "imagor-studio-import-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to buffer download: %w", err)
+	}
+	d := &Download{file: file}
+	d.Size, err = io.Copy(file, io.LimitReader(resp.Body, i.maxBytes+1))
+	if err == nil && d.Size > i.maxBytes {
+		err = ErrTooLarge
+	}
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		_ = d.Close()
+		if errors.Is(err, ErrTooLarge) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to download: %w", err)
+	}
+
+	name := fileName(resp)
+	d.ContentType, err = contentType(file, resp.Header.Get("Content-Type"), path.Ext(name))
+	if err != nil {
+		_ = d.Close()
+		return nil, err
+	}
+	if name == "" {
+		name = "download"
+	}
+	d.FileName = withExtension(name, d.ContentType)
+	return d, nil
+}
+
+// contentType returns the media type of file from the content, falling back to
+// the response header and then the extension for formats not sniffed, such
+// as HEIC
+func contentType(file *os.File, header, ext string) (string, error) {
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	sniffed, _, _ := mime.ParseMediaType(http.DetectContentType(head[:n]))
+	if isMedia(sniffed) {
+		return sniffed, nil
+	}
+	// Content recognised as something else, such as HTML, is not taken for
+	// media on the word of the header
+	if sniffed != "application/octet-stream" {
+		return "", ErrUnsupportedType
+	}
+	for _, candidate := range []string{header, mime.TypeByExtension(ext)} {
+		if mediaType, _, err := mime.ParseMediaType(candidate); err == nil && isMedia(mediaType) {
+			return mediaType, nil
+		}
+	}
+	return "", ErrUnsupportedType
+}
+
+func isMedia(mediaType string) bool {
+	return strings.HasPrefix(mediaType, "image/") || strings.HasPrefix(mediaType, "video/")
+}
+
+// fileName returns the name given by Content-Disposition, or else the last
+// segment of the final URL
+func fileName(resp *http.Response) string {
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+		if name := cleanName(params["filename"]); name != "" {
+			return name
+		}
+	}
+	return cleanName(path.Base(resp.Request.URL.Path))
+}
+
+// cleanName drops directories and names that are not file names
+func cleanName(name string) string {
+	name = path.Base(strings.ReplaceAll(strings.TrimSpace(name), "\\", "/"))
+	if name == "." || name == "/" || strings.HasPrefix(name, ".") {
+		return ""
+	}
+	return name
+}
+
+// extensions are the usual extensions of media types, where the first one
+// of mime.ExtensionsByType is not
+var extensions = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/tiff":      ".tiff",
+	"video/mp4":       ".mp4",
+	"video/quicktime": ".mov",
+	"video/mpeg":      ".mpg",
+}
+
+// withExtension gives name an extension of contentType when it has none of
+// them
+func withExtension(name, contentType string) string {
+	exts, _ := mime.ExtensionsByType(contentType)
+	ext := strings.ToLower(path.Ext(name))
+	for _, candidate := range exts {
+		if ext == candidate {
+			return name
+		}
+	}
+	preferred, ok := extensions[contentType]
+	if !ok {
+		if len(exts) == 0 {
+			return name
+		}
+		preferred = exts[0]
+	}
+	if ext == preferred {
+		return name
+	}
+	return strings.TrimSuffix(name, path.Ext(name)) + preferred
+}
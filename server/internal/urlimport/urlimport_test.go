@@ -0,0 +1,127 @@
+package urlimport
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func pngBytes(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 4))))
+	return buf.Bytes()
+}
+
+func newServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	photo := pngBytes(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/photos/cat.png", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(photo)
+	})
+	mux.HandleFunc("/download", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Disposition", `attachment; filename="../holiday.jpeg"`)
+		_, _ = w.Write(photo)
+	})
+	mux.HandleFunc("/noext", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(photo)
+	})
+	mux.HandleFunc("/photo.heic", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/heic")
+		_, _ = w.Write([]byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic"))
+	})
+	mux.HandleFunc("/page.png", func(w http.ResponseWriter, r *http.Request) {
+		// HTML claiming to be an image
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write([]byte("<!DOCTYPE html><html><body>hi</body></html>"))
+	})
+	mux.HandleFunc("/big.png", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(photo)
+		_, _ = w.Write(make([]byte, 2048))
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/photos/cat.png", http.StatusFound)
+	})
+	mux.HandleFunc("/missing", http.NotFound)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestFetch(t *testing.T) {
+	srv := newServer(t)
+	importer := New(WithAllowPrivateNetworks(true), WithMaxBytes(1024), WithTempDir(t.TempDir()))
+	ctx := context.Background()
+
+	tests := []struct {
+		path        string
+		contentType string
+		fileName    string
+	}{
+		{"/photos/cat.png", "image/png", "cat.png"},
+		{"/download", "image/png", "holiday.png"},
+		{"/noext", "image/png", "noext.png"},
+		{"/redirect", "image/png", "cat.png"},
+		{"/photo.heic", "image/heic", "photo.heic"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			d, err := importer.Fetch(ctx, srv.URL+tt.path)
+			require.NoError(t, err)
+			defer d.Close()
+			assert.Equal(t, tt.contentType, d.ContentType)
+			assert.Equal(t, tt.fileName, d.FileName)
+			content, err := io.ReadAll(d)
+			require.NoError(t, err)
+			assert.Equal(t, d.Size, int64(len(content)))
+		})
+	}
+
+	_, err := importer.Fetch(ctx, srv.URL+"/page.png")
+	assert.ErrorIs(t, err, ErrUnsupportedType)
+	_, err = importer.Fetch(ctx, srv.URL+"/big.png")
+	assert.ErrorIs(t, err, ErrTooLarge)
+	_, err = importer.Fetch(ctx, srv.URL+"/missing")
+	assert.ErrorContains(t, err, "404")
+	for _, rawURL := range []string{"ftp://example.com/a.png", "/local/a.png", "file:///etc/passwd", "http://"} {
+		_, err = importer.Fetch(ctx, rawURL)
+		assert.ErrorIs(t, err, ErrInvalidURL, rawURL)
+	}
+}
+
+func TestFetch_PrivateAddresses(t *testing.T) {
+	srv := newServer(t)
+	importer := New()
+	_, err := importer.Fetch(context.Background(), srv.URL+"/photos/cat.png")
+	assert.ErrorIs(t, err, ErrForbiddenAddress)
+
+	for address, forbidden := range map[string]bool{
+		"127.0.0.1:80":         true,
+		"10.1.2.3:80":          true,
+		"192.168.1.1:443":      true,
+		"169.254.169.254:80":   true,
+		"100.64.0.1:80":        true,
+		"0.0.0.0:80":           true,
+		"[::1]:80":             true,
+		"[fd00::1]:80":         true,
+		"[::ffff:10.0.0.1]:80": true,
+		"93.184.216.34:443":    false,
+		"[2606:4700::1]:443":   false,
+	} {
+		err := importer.checkAddress(address)
+		if forbidden {
+			assert.ErrorIs(t, err, ErrForbiddenAddress, address)
+		} else {
+			assert.NoError(t, err, address)
+		}
+	}
+}
@@ -51,6 +51,8 @@ func setupPostgresTestDB(t *testing.T) (*bun.DB, func()) {
 			pending_email TEXT,
 			email_verified BOOLEAN NOT NULL DEFAULT FALSE,
 			avatar_url TEXT,
+			home_path TEXT,
+			tenant_id TEXT,
 			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
 		)
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/cshum/imagor-studio/server/internal/model"
+	"github.com/cshum/imagor-studio/server/internal/pagination"
 	shareduser "github.com/cshum/imagor-studio/server/pkg/user"
 	"github.com/cshum/imagor-studio/server/pkg/uuid"
 	"github.com/cshum/imagor-studio/server/pkg/validation"
@@ -43,6 +44,17 @@ type Store interface {
 	List(ctx context.Context, offset, limit int, search string) ([]*User, int, error)
 	UpsertOAuth(ctx context.Context, provider, providerID, email, displayName, avatarURL string) (*User, error)
 	UpdateRole(ctx context.Context, id string, role string) error
+	UpdateHomePath(ctx context.Context, id string, homePath *string) error
+	// CreateInTenant creates a user who is a member of a tenant
+	CreateInTenant(ctx context.Context, tenantID, displayName, username, hashedPassword, role string) (*User, error)
+	// ListByTenant lists the members of a tenant like List
+	ListByTenant(ctx context.Context, tenantID string, offset, limit int, search string) ([]*User, int, error)
+	// ListAfter lists the users after the user at after, newest first, the
+	// members of a tenant unless tenantID is empty. It returns their total
+	// count and whether more follow the page, limit 0 lists them all.
+	ListAfter(ctx context.Context, tenantID string, after *pagination.Key, limit int, search string) ([]*User, int, bool, error)
+	// UpdateTenant moves the user into a tenant, nil moves them out of any
+	UpdateTenant(ctx context.Context, id string, tenantID *string) error
 }
 
 // oauthIdentity is the DB model for the oauth_identities table.
@@ -130,6 +142,34 @@ func (s *store) UpdateRole(ctx context.Context, id string, role string) error {
 	return nil
 }
 
+// UpdateHomePath sets the storage path the user is scoped to, nil clears it
+func (s *store) UpdateHomePath(ctx context.Context, id string, homePath *string) error {
+	_, err := s.db.NewUpdate().
+		Model((*model.User)(nil)).
+		Set("home_path = ?", homePath).
+		Set("updated_at = ?", time.Now()).
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("error updating home path: %w", err)
+	}
+	return nil
+}
+
+// UpdateTenant sets the tenant the user is a member of, nil clears it
+func (s *store) UpdateTenant(ctx context.Context, id string, tenantID *string) error {
+	_, err := s.db.NewUpdate().
+		Model((*model.User)(nil)).
+		Set("tenant_id = ?", tenantID).
+		Set("updated_at = ?", time.Now()).
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("error updating tenant: %w", err)
+	}
+	return nil
+}
+
 func modelUserToStore(user model.User) *User {
 	return &User{
 		ID:            user.ID,
@@ -142,6 +182,8 @@ func modelUserToStore(user model.User) *User {
 		EmailVerified: user.EmailVerified,
 		HasPassword:   hasUsablePassword(user.HashedPassword),
 		AvatarUrl:     user.AvatarUrl,
+		HomePath:      user.HomePath,
+		TenantID:      user.TenantID,
 		CreatedAt:     user.CreatedAt,
 		UpdatedAt:     user.UpdatedAt,
 	}
@@ -153,7 +195,14 @@ func hasUsablePassword(hashedPassword string) bool {
 }
 
 func (s *store) Create(ctx context.Context, displayName, username, hashedPassword, role string) (*User, error) {
-	return s.create(ctx, displayName, username, hashedPassword, role, nil)
+	return s.create(ctx, displayName, username, hashedPassword, role, nil, nil)
+}
+
+func (s *store) CreateInTenant(ctx context.Context, tenantID, displayName, username, hashedPassword, role string) (*User, error) {
+	if tenantID == "" {
+		return nil, fmt.Errorf("tenantID cannot be empty")
+	}
+	return s.create(ctx, displayName, username, hashedPassword, role, nil, &tenantID)
 }
 
 func (s *store) CreateWithEmail(ctx context.Context, displayName, username, hashedPassword, role, email string) (*User, error) {
@@ -162,10 +211,10 @@ func (s *store) CreateWithEmail(ctx context.Context, displayName, username, hash
 		return nil, fmt.Errorf("email cannot be empty")
 	}
 
-	return s.create(ctx, displayName, username, hashedPassword, role, &normalizedEmail)
+	return s.create(ctx, displayName, username, hashedPassword, role, &normalizedEmail, nil)
 }
 
-func (s *store) create(ctx context.Context, displayName, username, hashedPassword, role string, email *string) (*User, error) {
+func (s *store) create(ctx context.Context, displayName, username, hashedPassword, role string, email, tenantID *string) (*User, error) {
 	// Validate inputs
 	displayName = strings.TrimSpace(displayName)
 	username = strings.TrimSpace(username)
@@ -195,6 +244,7 @@ func (s *store) create(ctx context.Context, displayName, username, hashedPasswor
 		IsActive:       true,
 		Email:          email,
 		EmailVerified:  false,
+		TenantID:       tenantID,
 		CreatedAt:      now,
 		UpdatedAt:      now,
 	}
@@ -547,12 +597,23 @@ func (s *store) UpdateUsername(ctx context.Context, id string, username string)
 }
 
 func (s *store) List(ctx context.Context, offset, limit int, search string) ([]*User, int, error) {
+	return s.list(ctx, nil, offset, limit, search)
+}
+
+func (s *store) ListByTenant(ctx context.Context, tenantID string, offset, limit int, search string) ([]*User, int, error) {
+	return s.list(ctx, &tenantID, offset, limit, search)
+}
+
+func (s *store) list(ctx context.Context, tenantID *string, offset, limit int, search string) ([]*User, int, error) {
 	var users []model.User
 	search = strings.TrimSpace(search)
 	like := "%" + strings.ToLower(search) + "%"
 
 	// Build count query
 	countQ := s.db.NewSelect().Model((*model.User)(nil))
+	if tenantID != nil {
+		countQ = countQ.Where("tenant_id = ?", *tenantID)
+	}
 	if search != "" {
 		countQ = countQ.Where("LOWER(display_name) LIKE ? OR LOWER(username) LIKE ?", like, like)
 	}
@@ -565,6 +626,9 @@ func (s *store) List(ctx context.Context, offset, limit int, search string) ([]*
 	dataQ := s.db.NewSelect().
 		Model(&users).
 		OrderExpr("created_at DESC")
+	if tenantID != nil {
+		dataQ = dataQ.Where("tenant_id = ?", *tenantID)
+	}
 	if search != "" {
 		dataQ = dataQ.Where("LOWER(display_name) LIKE ? OR LOWER(username) LIKE ?", like, like)
 	}
@@ -590,6 +654,45 @@ func (s *store) List(ctx context.Context, offset, limit int, search string) ([]*
 	return result, totalCount, nil
 }
 
+func (s *store) ListAfter(ctx context.Context, tenantID string, after *pagination.Key, limit int, search string) ([]*User, int, bool, error) {
+	search = strings.TrimSpace(search)
+	like := "%" + strings.ToLower(search) + "%"
+	filter := func(q *bun.SelectQuery) *bun.SelectQuery {
+		if tenantID != "" {
+			q = q.Where("tenant_id = ?", tenantID)
+		}
+		if search != "" {
+			q = q.Where("LOWER(display_name) LIKE ? OR LOWER(username) LIKE ?", like, like)
+		}
+		return q
+	}
+	totalCount, err := filter(s.db.NewSelect().Model((*model.User)(nil))).Count(ctx)
+	if err != nil {
+		return nil, 0, false, fmt.Errorf("error counting users: %w", err)
+	}
+
+	// One more row than the page tells whether another page follows
+	var users []model.User
+	dataQ := after.After(filter(s.db.NewSelect().Model(&users))).
+		OrderExpr("created_at DESC, id DESC")
+	if limit > 0 {
+		dataQ = dataQ.Limit(limit + 1)
+	}
+	if err := dataQ.Scan(ctx); err != nil {
+		return nil, 0, false, fmt.Errorf("error listing users: %w", err)
+	}
+	more := limit > 0 && len(users) > limit
+	if more {
+		users = users[:limit]
+	}
+
+	result := make([]*User, len(users))
+	for i, user := range users {
+		result[i] = modelUserToStore(user)
+	}
+	return result, totalCount, more, nil
+}
+
 // UpsertOAuth finds or creates a user for a given OAuth provider identity.
 //
 // Logic:
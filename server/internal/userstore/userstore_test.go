@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/cshum/imagor-studio/server/internal/model"
+	"github.com/cshum/imagor-studio/server/internal/pagination"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/uptrace/bun"
@@ -42,6 +43,8 @@ func setupTestDB(t *testing.T) (*bun.DB, func()) {
 			pending_email TEXT,
 			email_verified BOOLEAN NOT NULL DEFAULT FALSE,
 			avatar_url TEXT,
+			home_path TEXT,
+			tenant_id TEXT,
 			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
 			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
 		)
@@ -415,6 +418,73 @@ func TestUserStore_SetActive(t *testing.T) {
 	assert.NoError(t, err) // Should not error, just not update anything
 }
 
+func TestUserStore_UpdateHomePath(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	logger, _ := zap.NewDevelopment()
+	store := New(db, logger)
+	ctx := context.Background()
+
+	user, err := store.Create(ctx, "testuser", "testuser", "hashedpass", "user")
+	require.NoError(t, err)
+	assert.Nil(t, user.HomePath)
+
+	homePath := "teams/alice"
+	require.NoError(t, store.UpdateHomePath(ctx, user.ID, &homePath))
+	updated, err := store.GetByID(ctx, user.ID)
+	require.NoError(t, err)
+	require.NotNil(t, updated.HomePath)
+	assert.Equal(t, "teams/alice", *updated.HomePath)
+
+	require.NoError(t, store.UpdateHomePath(ctx, user.ID, nil))
+	cleared, err := store.GetByIDAdmin(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Nil(t, cleared.HomePath)
+}
+
+func TestUserStore_Tenants(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	store := New(db, zap.NewNop())
+	ctx := context.Background()
+
+	_, err := store.CreateInTenant(ctx, "", "alice", "alice", "hashedpass", "user")
+	assert.Error(t, err)
+	alice, err := store.CreateInTenant(ctx, "tenant-1", "alice", "alice", "hashedpass", "admin")
+	require.NoError(t, err)
+	require.NotNil(t, alice.TenantID)
+	assert.Equal(t, "tenant-1", *alice.TenantID)
+	bob, err := store.Create(ctx, "bob", "bob", "hashedpass", "user")
+	require.NoError(t, err)
+	assert.Nil(t, bob.TenantID)
+	_, err = store.CreateInTenant(ctx, "tenant-2", "carol", "carol", "hashedpass", "user")
+	require.NoError(t, err)
+
+	users, total, err := store.ListByTenant(ctx, "tenant-1", 0, 10, "")
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	require.Len(t, users, 1)
+	assert.Equal(t, "alice", users[0].Username)
+
+	tenantID := "tenant-1"
+	require.NoError(t, store.UpdateTenant(ctx, bob.ID, &tenantID))
+	users, total, err = store.ListByTenant(ctx, "tenant-1", 0, 10, "bo")
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	require.Len(t, users, 1)
+	assert.Equal(t, "bob", users[0].Username)
+
+	require.NoError(t, store.UpdateTenant(ctx, bob.ID, nil))
+	updated, err := store.GetByIDAdmin(ctx, bob.ID)
+	require.NoError(t, err)
+	assert.Nil(t, updated.TenantID)
+	_, total, err = store.List(ctx, 0, 10, "")
+	require.NoError(t, err)
+	assert.Equal(t, 3, total)
+}
+
 func TestUserStore_IsolationAndSecurity(t *testing.T) {
 	db, cleanup := setupTestDB(t)
 	defer cleanup()
@@ -816,6 +886,59 @@ func TestUserStore_List(t *testing.T) {
 	}
 }
 
+func TestUserStore_ListAfter(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	logger, _ := zap.NewDevelopment()
+	store := New(db, logger)
+	ctx := context.Background()
+
+	for _, name := range []string{"alice", "bob", "carol", "dave", "erin"} {
+		_, err := store.Create(ctx, name, name, "hash", "user")
+		require.NoError(t, err)
+	}
+	all, totalCount, more, err := store.ListAfter(ctx, "", nil, 0, "")
+	require.NoError(t, err)
+	assert.Equal(t, 5, totalCount)
+	assert.False(t, more)
+	require.Len(t, all, 5)
+
+	// Pages continue after the last user of the previous one
+	var paged []*User
+	var after *pagination.Key
+	for {
+		users, totalCount, more, err := store.ListAfter(ctx, "", after, 2, "")
+		require.NoError(t, err)
+		assert.Equal(t, 5, totalCount)
+		paged = append(paged, users...)
+		if !more {
+			break
+		}
+		last := users[len(users)-1]
+		after = &pagination.Key{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+	assert.Equal(t, all, paged)
+
+	// A user created meanwhile, newest, does not shift the next page
+	first, _, _, err := store.ListAfter(ctx, "", nil, 2, "")
+	require.NoError(t, err)
+	_, err = store.Create(ctx, "frank", "frank", "hash", "user")
+	require.NoError(t, err)
+	last := first[len(first)-1]
+	next, totalCount, _, err := store.ListAfter(ctx, "", &pagination.Key{CreatedAt: last.CreatedAt, ID: last.ID}, 2, "")
+	require.NoError(t, err)
+	assert.Equal(t, 6, totalCount)
+	assert.Equal(t, all[2:4], next)
+
+	users, totalCount, more, err := store.ListAfter(ctx, "", nil, 1, "car")
+	require.NoError(t, err)
+	assert.Equal(t, 1, totalCount)
+	assert.False(t, more)
+	require.Len(t, users, 1)
+	assert.Equal(t, "carol", users[0].Username)
+}
+
 func TestUserStore_List_EmptyDatabase(t *testing.T) {
 	db, cleanup := setupTestDB(t)
 	defer cleanup()
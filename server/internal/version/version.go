@@ -0,0 +1,21 @@
+// Package version reports the version of the running server build.
+package version
+
+import "runtime/debug"
+
+// Version is set at build time, e.g.
+//
+//	go build -ldflags "-X github.com/cshum/imagor-studio/server/internal/version.Version=v1.2.3"
+var Version = ""
+
+// Get returns the build version, falling back to the Go module version
+// embedded in the binary and finally to "dev".
+func Get() string {
+	if Version != "" {
+		return Version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "dev"
+}
@@ -0,0 +1,91 @@
+package videostream
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/cshum/imagor-studio/server/internal/hls"
+)
+
+// FFmpeg renders streams with the ffmpeg binary, probing with the ffprobe
+// found alongside it as HLS does
+type FFmpeg struct {
+	*hls.FFmpeg
+}
+
+// NewFFmpeg looks up ffmpeg and ffprobe, ffmpegPath may be a name on PATH or
+// an absolute path
+func NewFFmpeg(ffmpegPath string) (*FFmpeg, error) {
+	f, err := hls.NewFFmpeg(ffmpegPath)
+	if err != nil {
+		return nil, err
+	}
+	return &FFmpeg{FFmpeg: f}, nil
+}
+
+// Transcode implements Transcoder
+func (f *FFmpeg) Transcode(ctx context.Context, sourcePath, outPath string, profile Profile, copyCodec string) error {
+	args, err := ffmpegArgs(sourcePath, outPath, profile, copyCodec)
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, f.FFmpegPath, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		lines := strings.Split(strings.TrimSpace(stderr.String()), "\n")
+		if len(lines) > 5 {
+			lines = lines[len(lines)-5:]
+		}
+		return fmt.Errorf("ffmpeg failed: %w: %s", err, strings.Join(lines, " | "))
+	}
+	return nil
+}
+
+func ffmpegArgs(sourcePath, outPath string, profile Profile, copyCodec string) ([]string, error) {
+	inputArgs, err := hls.InputArgs(sourcePath)
+	if err != nil {
+		return nil, err
+	}
+	args := append([]string{"-hide_banner", "-nostdin", "-y"}, inputArgs...)
+	args = append(args, "-map", "0:v:0", "-map", "0:a:0?")
+	switch {
+	case copyCodec != "":
+		args = append(args, "-c:v", "copy")
+	case profile.Codec == "hevc":
+		args = append(args, "-c:v", "libx265", "-preset", "fast", "-pix_fmt", "yuv420p")
+	default:
+		args = append(args, "-c:v", "libx264", "-preset", "veryfast", "-profile:v", "high", "-pix_fmt", "yuv420p")
+	}
+	if copyCodec == "" {
+		if profile.VideoBitrate != "" {
+			args = append(args, "-b:v", profile.VideoBitrate)
+		} else if profile.Codec == "hevc" {
+			args = append(args, "-crf", "28")
+		} else {
+			args = append(args, "-crf", "23")
+		}
+		if profile.MaxHeight > 0 {
+			args = append(args, "-vf", "scale=-2:'min(ih,"+strconv.Itoa(profile.MaxHeight)+")'")
+		}
+	}
+	if copyCodec == "hevc" || (copyCodec == "" && profile.Codec == "hevc") {
+		// Safari only plays HEVC in MP4 tagged hvc1
+		args = append(args, "-tag:v", "hvc1")
+	}
+	audioBitrate := profile.AudioBitrate
+	if audioBitrate == "" {
+		audioBitrate = DefaultProfile.AudioBitrate
+	}
+	return append(args,
+		"-c:a", "aac", "-b:a", audioBitrate, "-ac", "2",
+		// Fragments can be played while the rest is still being written
+		"-movflags", "+frag_keyframe+empty_moov+default_base_moof",
+		"-f", "mp4",
+		outPath,
+	), nil
+}
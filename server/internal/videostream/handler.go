@@ -0,0 +1,123 @@
+package videostream
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// fileWaitTimeout bounds how long a request waits for a rendition to
+	// start, while the source is downloaded and probed
+	fileWaitTimeout = 30 * time.Second
+	filePollPeriod  = 200 * time.Millisecond
+	tailBufferSize  = 256 << 10
+)
+
+// ServeHTTP serves /<sessionID>/video.mp4. Finished renditions are served
+// with byte-range support. A rendition still being made is streamed from
+// the start as it grows, ignoring ranges. Session IDs are unguessable and
+// short-lived, so the URL can be set as the src of a video element.
+func (m *Manager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	sessionID, name, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if !ok || name != StreamName {
+		http.NotFound(w, r)
+		return
+	}
+	key, running, err := m.lookup(sessionID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	dir := m.dir(key)
+	streamPath := filepath.Join(dir, StreamName)
+	w.Header().Set("Content-Type", "video/mp4")
+	if running == nil {
+		if _, err := os.Stat(streamPath); err != nil {
+			http.Error(w, "video rendition failed", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Cache-Control", "private, max-age=86400")
+		http.ServeFile(w, r, streamPath)
+		return
+	}
+
+	file, err := m.openPart(r, filepath.Join(dir, partName), streamPath, running)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	defer file.Close()
+	w.Header().Set("Cache-Control", "no-cache")
+	if r.Method == http.MethodHead {
+		return
+	}
+	m.tail(w, r, file, running)
+}
+
+// openPart opens the rendition being made once it is started, or the
+// finished rendition when the job completes meanwhile
+func (m *Manager) openPart(r *http.Request, partPath, streamPath string, running *job) (*os.File, error) {
+	timeout := time.NewTimer(fileWaitTimeout)
+	defer timeout.Stop()
+	ticker := time.NewTicker(filePollPeriod)
+	defer ticker.Stop()
+	for {
+		if file, err := os.Open(partPath); err == nil {
+			return file, nil
+		}
+		select {
+		case <-ticker.C:
+		case <-running.done:
+			if running.err != nil {
+				return nil, errors.New("video rendition failed")
+			}
+			return os.Open(streamPath)
+		case <-timeout.C:
+			return nil, errors.New("video rendition starting, retry later")
+		case <-r.Context().Done():
+			return nil, r.Context().Err()
+		}
+	}
+}
+
+// tail copies file to w as it grows until the job completes
+func (m *Manager) tail(w http.ResponseWriter, r *http.Request, file *os.File, running *job) {
+	flusher, _ := w.(http.Flusher)
+	buf := make([]byte, tailBufferSize)
+	for {
+		n, err := file.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return
+			}
+			continue
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		select {
+		case <-running.done:
+			if running.err == nil {
+				// Written completely before done was closed
+				_, _ = io.CopyBuffer(w, file, buf)
+			}
+			return
+		case <-time.After(filePollPeriod):
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
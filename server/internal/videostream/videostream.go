@@ -0,0 +1,490 @@
+// Package videostream serves videos browsers cannot play as a single MP4
+// stream with byte-range support, for players without HLS.
+//
+// A stream session is opened per video. Sources whose codec plays in MP4,
+// such as H.264 in MKV or AVI, are remuxed without re-encoding; others are
+// transcoded as the admin configured profile says. ffmpeg writes fragmented
+// MP4 that is served while it grows, runs in a bounded pool of processes,
+// and the finished rendition is kept on local disk and in the source
+// storage, so it is not transcoded again after the local cache expires.
+package videostream
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/hls"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/cshum/imagor-studio/server/pkg/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	DefaultMaxProcesses = 2
+	DefaultSessionTTL   = 2 * time.Hour
+	DefaultCacheTTL     = 7 * 24 * time.Hour
+
+	// StreamName is the name the stream is served at below its session
+	StreamName = "video.mp4"
+
+	// RenditionFolder is the hidden folder of the source storage keeping
+	// finished renditions
+	RenditionFolder = ".imagor-studio/renditions"
+
+	// RegistryKey is the system registry key holding the JSON encoded
+	// Profile. When the key is unset or empty, DefaultProfile is used.
+	RegistryKey = "config.video_stream_profile"
+
+	sourceName = "source"
+	partName   = StreamName + ".part"
+)
+
+var (
+	// ErrTooManyProcesses is returned when every ffmpeg process of the
+	// pool is busy
+	ErrTooManyProcesses = errors.New("too many concurrent video transcodes")
+	// ErrSessionNotFound is returned for unknown or expired sessions
+	ErrSessionNotFound = errors.New("stream session not found")
+)
+
+// Profile is how videos are transcoded, set by admins in the registry
+type Profile struct {
+	// Codec is the video codec of transcodes, h264 or hevc
+	Codec string `json:"codec"`
+	// VideoBitrate such as "4M", empty encodes at constant quality
+	VideoBitrate string `json:"videoBitrate,omitempty"`
+	// AudioBitrate such as "128k"
+	AudioBitrate string `json:"audioBitrate,omitempty"`
+	// MaxHeight scales taller videos down when transcoding, 0 keeps the size
+	MaxHeight int `json:"maxHeight,omitempty"`
+	// RemuxCodecs are source video codecs copied into MP4 without
+	// re-encoding
+	RemuxCodecs []string `json:"remuxCodecs,omitempty"`
+}
+
+// DefaultProfile transcodes to H.264 and remuxes H.264 sources
+var DefaultProfile = Profile{
+	Codec:        "h264",
+	AudioBitrate: "128k",
+	RemuxCodecs:  []string{"h264"},
+}
+
+// outputCodecs are the codecs a profile may transcode to
+var outputCodecs = map[string]bool{"h264": true, "hevc": true}
+
+var bitratePattern = regexp.MustCompile(`^\d+(\.\d+)?[kKmM]?$`)
+
+// ParseProfile decodes a JSON profile, normalises its codec names and
+// validates it. Unset fields keep their DefaultProfile value.
+func ParseProfile(data string) (Profile, error) {
+	profile := DefaultProfile
+	// Decoded into a copy, not the backing array of the default
+	profile.RemuxCodecs = slices.Clone(DefaultProfile.RemuxCodecs)
+	if err := json.Unmarshal([]byte(data), &profile); err != nil {
+		return Profile{}, fmt.Errorf("invalid video stream profile: %w", err)
+	}
+	profile.Codec = hls.NormalizeCodec(profile.Codec)
+	if !outputCodecs[profile.Codec] {
+		return Profile{}, fmt.Errorf("unsupported video stream codec %q, use h264 or hevc", profile.Codec)
+	}
+	for _, bitrate := range []string{profile.VideoBitrate, profile.AudioBitrate} {
+		if bitrate != "" && !bitratePattern.MatchString(bitrate) {
+			return Profile{}, fmt.Errorf("invalid bitrate %q, use a number with an optional k or M suffix", bitrate)
+		}
+	}
+	if profile.MaxHeight < 0 {
+		return Profile{}, fmt.Errorf("invalid max height %d", profile.MaxHeight)
+	}
+	for i, codec := range profile.RemuxCodecs {
+		profile.RemuxCodecs[i] = hls.NormalizeCodec(codec)
+	}
+	return profile, nil
+}
+
+// FromRegistryValue returns the profile of the registry value, falling back
+// to DefaultProfile when the value is empty or invalid.
+func FromRegistryValue(value string) (Profile, error) {
+	if strings.TrimSpace(value) == "" {
+		return DefaultProfile, nil
+	}
+	profile, err := ParseProfile(value)
+	if err != nil {
+		return DefaultProfile, err
+	}
+	return profile, nil
+}
+
+// remuxes reports whether sources of codec are copied without re-encoding
+func (p Profile) remuxes(codec string) bool {
+	return slices.Contains(p.RemuxCodecs, codec)
+}
+
+// Transcoder probes local videos and writes MP4 renditions of them
+type Transcoder interface {
+	// Probe returns the codec of the first video stream
+	Probe(ctx context.Context, sourcePath string) (string, error)
+	// Transcode writes a fragmented MP4 of sourcePath to outPath, in the
+	// order it is played. copyCodec is the source codec when the video
+	// stream is copied as is, empty when it is transcoded with profile.
+	Transcode(ctx context.Context, sourcePath, outPath string, profile Profile, copyCodec string) error
+}
+
+// Source identifies the video to stream
+type Source struct {
+	Storage storage.Storage
+	// Namespace separates storages, e.g. a space ID, empty when self-hosted
+	Namespace string
+	Path      string
+}
+
+// Stream is an opened stream session
+type Stream struct {
+	// SessionID serves the stream at <SessionID>/video.mp4 relative to the
+	// handler
+	SessionID string
+	// Cached is set when the rendition was already made
+	Cached    bool
+	ExpiresAt time.Time
+}
+
+type session struct {
+	key       string
+	expiresAt time.Time
+}
+
+// job makes the rendition of a cache key, by transcoding the source or
+// fetching the rendition kept in storage
+type job struct {
+	done chan struct{}
+	err  error
+}
+
+// Manager opens stream sessions, runs the ffmpeg pool and serves streams
+type Manager struct {
+	logger     *zap.Logger
+	transcoder Transcoder
+	cacheDir   string
+
+	sessionTTL time.Duration
+	cacheTTL   time.Duration
+	now        func() time.Time
+
+	// slots holds a token per running ffmpeg process
+	slots chan struct{}
+
+	// ctx is cancelled by Close to stop running jobs
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	sessions map[string]*session
+	jobs     map[string]*job
+}
+
+// Option configures a Manager
+type Option func(*Manager)
+
+// WithMaxProcesses limits concurrent ffmpeg processes
+func WithMaxProcesses(n int) Option {
+	return func(m *Manager) {
+		if n > 0 {
+			m.slots = make(chan struct{}, n)
+		}
+	}
+}
+
+// WithSessionTTL sets how long a stream session stays valid
+func WithSessionTTL(d time.Duration) Option {
+	return func(m *Manager) {
+		if d > 0 {
+			m.sessionTTL = d
+		}
+	}
+}
+
+// WithCacheTTL sets how long unused renditions are kept on local disk
+func WithCacheTTL(d time.Duration) Option {
+	return func(m *Manager) {
+		if d > 0 {
+			m.cacheTTL = d
+		}
+	}
+}
+
+// NewManager creates a manager caching renditions below cacheDir
+func NewManager(logger *zap.Logger, transcoder Transcoder, cacheDir string, opts ...Option) (*Manager, error) {
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create video stream cache directory: %w", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &Manager{
+		ctx:        ctx,
+		cancel:     cancel,
+		logger:     logger,
+		transcoder: transcoder,
+		cacheDir:   cacheDir,
+		sessionTTL: DefaultSessionTTL,
+		cacheTTL:   DefaultCacheTTL,
+		now:        time.Now,
+		slots:      make(chan struct{}, DefaultMaxProcesses),
+		sessions:   make(map[string]*session),
+		jobs:       make(map[string]*job),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m, nil
+}
+
+// Close stops running jobs
+func (m *Manager) Close() {
+	m.cancel()
+}
+
+// Open opens a stream session of src rendered with profile, starting the
+// rendition unless it is made or being made
+func (m *Manager) Open(ctx context.Context, src Source, profile Profile) (*Stream, error) {
+	info, err := src.Storage.Stat(ctx, src.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat video: %w", err)
+	}
+	if info.IsDir {
+		return nil, fmt.Errorf("%s is a folder", src.Path)
+	}
+	key := cacheKey(src, info, profile)
+
+	cached := false
+	if _, err := os.Stat(filepath.Join(m.dir(key), StreamName)); err == nil {
+		m.touch(m.dir(key))
+		cached = true
+	} else {
+		stored := false
+		if _, err := src.Storage.Stat(ctx, RenditionPath(key)); err == nil {
+			stored = true
+		}
+		if err := m.ensureJob(key, src, profile, stored); err != nil {
+			return nil, err
+		}
+		cached = stored
+	}
+	sessionID, expiresAt := m.createSession(key)
+	return &Stream{SessionID: sessionID, Cached: cached, ExpiresAt: expiresAt}, nil
+}
+
+// RenditionPath is where the rendition of a cache key is kept in storage
+func RenditionPath(key string) string {
+	return path.Join(RenditionFolder, key+".mp4")
+}
+
+// ensureJob starts making the rendition of key unless it is running. Stored
+// renditions are fetched, others take an ffmpeg process from the pool.
+func (m *Manager) ensureJob(key string, src Source, profile Profile, stored bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, running := m.jobs[key]; running {
+		return nil
+	}
+	if !stored {
+		select {
+		case m.slots <- struct{}{}:
+		default:
+			return ErrTooManyProcesses
+		}
+	}
+	j := &job{done: make(chan struct{})}
+	m.jobs[key] = j
+	go m.runJob(key, j, src, profile, stored)
+	return nil
+}
+
+func (m *Manager) runJob(key string, j *job, src Source, profile Profile, stored bool) {
+	defer close(j.done)
+	dir := m.dir(key)
+
+	m.pruneCache()
+	var err error
+	if stored {
+		err = m.fetchRendition(dir, key, src)
+	} else {
+		err = m.transcode(dir, key, src, profile)
+		<-m.slots
+	}
+	if err != nil {
+		m.logger.Warn("Video stream rendition failed", zap.String("key", key), zap.Error(err))
+		_ = os.RemoveAll(dir)
+	}
+
+	m.mu.Lock()
+	j.err = err
+	delete(m.jobs, key)
+	m.mu.Unlock()
+}
+
+// fetchRendition copies the rendition kept in storage to the local cache
+func (m *Manager) fetchRendition(dir, key string, src Source) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create video stream cache entry: %w", err)
+	}
+	reader, err := src.Storage.Get(m.ctx, RenditionPath(key))
+	if err != nil {
+		return fmt.Errorf("failed to read rendition: %w", err)
+	}
+	defer reader.Close()
+	file, err := os.Create(filepath.Join(dir, partName))
+	if err != nil {
+		return fmt.Errorf("failed to create rendition: %w", err)
+	}
+	_, copyErr := io.Copy(file, reader)
+	if err := errors.Join(copyErr, file.Close()); err != nil {
+		return fmt.Errorf("failed to copy rendition: %w", err)
+	}
+	return os.Rename(filepath.Join(dir, partName), filepath.Join(dir, StreamName))
+}
+
+// transcode renders the source into the local cache and keeps the result in
+// the source storage
+func (m *Manager) transcode(dir, key string, src Source, profile Profile) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create video stream cache entry: %w", err)
+	}
+	sourcePath := filepath.Join(dir, sourceName)
+	if err := downloadSource(m.ctx, src, sourcePath); err != nil {
+		return err
+	}
+	defer os.Remove(sourcePath)
+	codec, err := m.transcoder.Probe(m.ctx, sourcePath)
+	if err != nil {
+		return fmt.Errorf("failed to probe video: %w", err)
+	}
+	codec = hls.NormalizeCodec(codec)
+	copyCodec := ""
+	if profile.remuxes(codec) {
+		copyCodec = codec
+	}
+	m.logger.Info("Rendering video stream",
+		zap.String("path", src.Path), zap.String("codec", codec), zap.Bool("remux", copyCodec != ""))
+
+	partPath := filepath.Join(dir, partName)
+	if err := m.transcoder.Transcode(m.ctx, sourcePath, partPath, profile, copyCodec); err != nil {
+		return err
+	}
+	streamPath := filepath.Join(dir, StreamName)
+	if err := os.Rename(partPath, streamPath); err != nil {
+		return err
+	}
+	if err := storeRendition(m.ctx, src, streamPath, RenditionPath(key)); err != nil {
+		// Still served from the local cache
+		m.logger.Warn("Failed to keep video stream rendition in storage", zap.String("key", key), zap.Error(err))
+	}
+	return nil
+}
+
+func downloadSource(ctx context.Context, src Source, dest string) error {
+	reader, err := src.Storage.Get(ctx, src.Path)
+	if err != nil {
+		return fmt.Errorf("failed to read video: %w", err)
+	}
+	defer reader.Close()
+	file, err := os.Create(dest)
+	if err != nil {
+		return fmt.Errorf("failed to create source copy: %w", err)
+	}
+	_, copyErr := io.Copy(file, reader)
+	if err := errors.Join(copyErr, file.Close()); err != nil {
+		return fmt.Errorf("failed to copy video: %w", err)
+	}
+	return nil
+}
+
+func storeRendition(ctx context.Context, src Source, localPath, key string) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return src.Storage.Put(ctx, key, file)
+}
+
+func (m *Manager) createSession(key string) (string, time.Time) {
+	now := m.now()
+	expiresAt := now.Add(m.sessionTTL)
+	id := uuid.GenerateUUID()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for sid, s := range m.sessions {
+		if now.After(s.expiresAt) {
+			delete(m.sessions, sid)
+		}
+	}
+	m.sessions[id] = &session{key: key, expiresAt: expiresAt}
+	return id, expiresAt
+}
+
+// lookup returns the cache key and running job, if any, for a session
+func (m *Manager) lookup(sessionID string) (string, *job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[sessionID]
+	if !ok || m.now().After(s.expiresAt) {
+		return "", nil, ErrSessionNotFound
+	}
+	return s.key, m.jobs[s.key], nil
+}
+
+// pruneCache removes local renditions unused for longer than the cache TTL
+func (m *Manager) pruneCache() {
+	entries, err := os.ReadDir(m.cacheDir)
+	if err != nil {
+		return
+	}
+	cutoff := m.now().Add(-m.cacheTTL)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		m.mu.Lock()
+		_, running := m.jobs[entry.Name()]
+		m.mu.Unlock()
+		if running {
+			continue
+		}
+		info, err := entry.Info()
+		if err == nil && info.ModTime().Before(cutoff) {
+			_ = os.RemoveAll(filepath.Join(m.cacheDir, entry.Name()))
+		}
+	}
+}
+
+func (m *Manager) touch(dir string) {
+	now := m.now()
+	_ = os.Chtimes(dir, now, now)
+}
+
+func (m *Manager) dir(key string) string {
+	return filepath.Join(m.cacheDir, key)
+}
+
+// cacheKey changes whenever the source file or the profile changes
+func cacheKey(src Source, info storage.FileInfo, profile Profile) string {
+	version := info.ETag
+	if version == "" {
+		version = fmt.Sprintf("%d-%d", info.Size, info.ModifiedTime.UnixNano())
+	}
+	profileJSON, _ := json.Marshal(profile)
+	sum := sha256.Sum256([]byte(src.Namespace + "\x00" + src.Path + "\x00" + version + "\x00" + string(profileJSON)))
+	return hex.EncodeToString(sum[:16])
+}
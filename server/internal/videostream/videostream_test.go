@@ -0,0 +1,266 @@
+package videostream
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type fakeTranscoder struct {
+	codec string
+
+	mu         sync.Mutex
+	transcodes int
+	copyCodecs []string
+	// release, when set, blocks Transcode after the first fragment
+	release chan struct{}
+}
+
+func (f *fakeTranscoder) Probe(ctx context.Context, sourcePath string) (string, error) {
+	if _, err := os.Stat(sourcePath); err != nil {
+		return "", err
+	}
+	return f.codec, nil
+}
+
+func (f *fakeTranscoder) Transcode(ctx context.Context, sourcePath, outPath string, profile Profile, copyCodec string) error {
+	f.mu.Lock()
+	f.transcodes++
+	f.copyCodecs = append(f.copyCodecs, copyCodec)
+	f.mu.Unlock()
+	file, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := file.WriteString("fragment0;"); err != nil {
+		return err
+	}
+	if f.release != nil {
+		select {
+		case <-f.release:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	_, err = file.WriteString("fragment1;")
+	return err
+}
+
+func (f *fakeTranscoder) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.transcodes
+}
+
+func newTestManager(t *testing.T, transcoder Transcoder, baseDir string, opts ...Option) (*Manager, Source) {
+	t.Helper()
+	stor, err := filestorage.New(baseDir)
+	require.NoError(t, err)
+	m, err := NewManager(zap.NewNop(), transcoder, filepath.Join(t.TempDir(), "stream"), opts...)
+	require.NoError(t, err)
+	t.Cleanup(m.Close)
+	return m, Source{Storage: stor, Path: "clips/video.mkv"}
+}
+
+func writeVideo(t *testing.T, baseDir, path, content string) {
+	t.Helper()
+	fullPath := filepath.Join(baseDir, path)
+	require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0755))
+	require.NoError(t, os.WriteFile(fullPath, []byte(content), 0644))
+}
+
+func waitJobs(t *testing.T, m *Manager) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		return len(m.jobs) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func get(t *testing.T, m *Manager, stream *Stream, header http.Header) *http.Response {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/"+stream.SessionID+"/"+StreamName, nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	return rec.Result()
+}
+
+func body(t *testing.T, resp *http.Response) string {
+	t.Helper()
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func mustStat(t *testing.T, src Source) storage.FileInfo {
+	t.Helper()
+	info, err := src.Storage.Stat(context.Background(), src.Path)
+	require.NoError(t, err)
+	return info
+}
+
+func TestParseProfile(t *testing.T) {
+	profile, err := ParseProfile(`{"codec": "h265", "videoBitrate": "2.5M", "remuxCodecs": ["avc1", "hvc1"]}`)
+	require.NoError(t, err)
+	assert.Equal(t, Profile{Codec: "hevc", VideoBitrate: "2.5M", AudioBitrate: "128k", RemuxCodecs: []string{"h264", "hevc"}}, profile)
+	assert.Equal(t, []string{"h264"}, DefaultProfile.RemuxCodecs)
+
+	for _, value := range []string{`{"codec": "vp8"}`, `{"audioBitrate": "lots"}`, `{"maxHeight": -1}`, `not json`} {
+		_, err := ParseProfile(value)
+		assert.Error(t, err, value)
+		profile, err := FromRegistryValue(value)
+		assert.Error(t, err)
+		assert.Equal(t, DefaultProfile, profile)
+	}
+	profile, err = FromRegistryValue("")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultProfile, profile)
+}
+
+func TestManager_StreamWhileTranscoding(t *testing.T) {
+	baseDir := t.TempDir()
+	writeVideo(t, baseDir, "clips/video.mkv", "mkv-data")
+	transcoder := &fakeTranscoder{codec: "hevc", release: make(chan struct{})}
+	m, src := newTestManager(t, transcoder, baseDir)
+
+	stream, err := m.Open(context.Background(), src, DefaultProfile)
+	require.NoError(t, err)
+	assert.False(t, stream.Cached)
+
+	// The stream is served as it grows, ranges are ignored meanwhile
+	responses := make(chan *http.Response)
+	go func() {
+		responses <- get(t, m, stream, http.Header{"Range": {"bytes=5-"}})
+	}()
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(filepath.Join(m.dir(cacheKey(src, mustStat(t, src), DefaultProfile)), partName))
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	close(transcoder.release)
+	resp := <-responses
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "video/mp4", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "fragment0;fragment1;", body(t, resp))
+	waitJobs(t, m)
+	assert.Equal(t, []string{""}, transcoder.copyCodecs)
+
+	// Finished renditions are served with ranges
+	resp = get(t, m, stream, http.Header{"Range": {"bytes=10-"}})
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	assert.Equal(t, "fragment1;", body(t, resp))
+
+	again, err := m.Open(context.Background(), src, DefaultProfile)
+	require.NoError(t, err)
+	assert.True(t, again.Cached)
+	assert.Equal(t, 1, transcoder.count())
+}
+
+func TestManager_RenditionKeptInStorage(t *testing.T) {
+	baseDir := t.TempDir()
+	writeVideo(t, baseDir, "clips/video.mkv", "mkv-data")
+	transcoder := &fakeTranscoder{codec: "h264"}
+	m, src := newTestManager(t, transcoder, baseDir)
+
+	stream, err := m.Open(context.Background(), src, DefaultProfile)
+	require.NoError(t, err)
+	waitJobs(t, m)
+	assert.Equal(t, []string{"h264"}, transcoder.copyCodecs)
+	assert.Equal(t, "fragment0;fragment1;", body(t, get(t, m, stream, nil)))
+
+	entries, err := os.ReadDir(filepath.Join(baseDir, RenditionFolder))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	// Another instance, or this one once the local cache expired, fetches
+	// the rendition instead of transcoding again
+	other, src := newTestManager(t, transcoder, baseDir)
+	stream, err = other.Open(context.Background(), src, DefaultProfile)
+	require.NoError(t, err)
+	assert.True(t, stream.Cached)
+	assert.Equal(t, "fragment0;fragment1;", body(t, get(t, other, stream, nil)))
+	assert.Equal(t, 1, transcoder.count())
+
+	// A changed profile is rendered again
+	profile := DefaultProfile
+	profile.VideoBitrate = "1M"
+	_, err = other.Open(context.Background(), src, profile)
+	require.NoError(t, err)
+	waitJobs(t, other)
+	assert.Equal(t, 2, transcoder.count())
+}
+
+func TestManager_ProcessPool(t *testing.T) {
+	baseDir := t.TempDir()
+	writeVideo(t, baseDir, "clips/video.mkv", "one")
+	writeVideo(t, baseDir, "clips/other.mkv", "two")
+	transcoder := &fakeTranscoder{codec: "vp8", release: make(chan struct{})}
+	m, src := newTestManager(t, transcoder, baseDir, WithMaxProcesses(1))
+
+	_, err := m.Open(context.Background(), src, DefaultProfile)
+	require.NoError(t, err)
+	// Joining the running rendition takes no process
+	_, err = m.Open(context.Background(), src, DefaultProfile)
+	require.NoError(t, err)
+
+	other := src
+	other.Path = "clips/other.mkv"
+	_, err = m.Open(context.Background(), other, DefaultProfile)
+	assert.ErrorIs(t, err, ErrTooManyProcesses)
+
+	close(transcoder.release)
+	waitJobs(t, m)
+	_, err = m.Open(context.Background(), other, DefaultProfile)
+	require.NoError(t, err)
+	waitJobs(t, m)
+	assert.Equal(t, 2, transcoder.count())
+}
+
+func TestManager_ServeHTTP_NotFound(t *testing.T) {
+	m, _ := newTestManager(t, &fakeTranscoder{}, t.TempDir())
+	for _, target := range []string{"/missing/" + StreamName, "/missing", "/missing/other.mp4"} {
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		assert.Equal(t, http.StatusNotFound, rec.Code, target)
+	}
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/missing/"+StreamName, strings.NewReader("")))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestFFmpegArgs(t *testing.T) {
+	parts, err := ffmpegArgs("/cache/in", "out", DefaultProfile, "hevc")
+	require.NoError(t, err)
+	args := strings.Join(parts, " ")
+	assert.Contains(t, args, "-protocol_whitelist file -i file:/cache/in")
+	assert.Contains(t, args, "-c:v copy")
+	assert.Contains(t, args, "-tag:v hvc1")
+	assert.NotContains(t, args, "-crf")
+
+	profile := Profile{Codec: "h264", VideoBitrate: "3M", MaxHeight: 720}
+	parts, err = ffmpegArgs("/cache/in", "out", profile, "")
+	require.NoError(t, err)
+	args = strings.Join(parts, " ")
+	assert.Contains(t, args, "-c:v libx264")
+	assert.Contains(t, args, "-b:v 3M")
+	assert.Contains(t, args, "scale=-2:'min(ih,720)'")
+	assert.Contains(t, args, "-b:a 128k")
+	assert.NotContains(t, args, "hvc1")
+}
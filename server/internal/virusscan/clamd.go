@@ -0,0 +1,126 @@
+package virusscan
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// clamdChunkSize is the size of the chunks streamed to clamd
+const clamdChunkSize = 64 << 10
+
+// ClamdScanner streams content to clamd with the INSTREAM command. Content
+// larger than the StreamMaxLength of clamd fails to scan.
+type ClamdScanner struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// NewClamdScanner returns a scanner connecting to clamd at rawURL, either
+// unix:///run/clamav/clamd.ctl for its socket or tcp://host:3310
+func NewClamdScanner(rawURL string, timeout time.Duration) (*ClamdScanner, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid clamd URL %q: %w", rawURL, err)
+	}
+	scanner := &ClamdScanner{network: u.Scheme, timeout: timeout}
+	switch u.Scheme {
+	case "unix":
+		scanner.address = u.Path
+	case "tcp":
+		if u.Port() == "" {
+			return nil, fmt.Errorf("clamd URL must include a port: %q", rawURL)
+		}
+		scanner.address = u.Host
+	}
+	if scanner.address == "" {
+		return nil, fmt.Errorf("clamd URL must be unix:///path/to/socket or tcp://host:port: %q", rawURL)
+	}
+	if scanner.timeout <= 0 {
+		scanner.timeout = defaultTimeout
+	}
+	return scanner, nil
+}
+
+// Scan implements Scanner
+func (s *ClamdScanner) Scan(ctx context.Context, content io.Reader) (Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, s.network, s.address)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to connect to clamd: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	if err := s.stream(conn, content); err != nil {
+		// clamd may answer before closing the connection, such as when the
+		// stream exceeds its StreamMaxLength
+		if reply, replyErr := readClamdReply(conn); replyErr == nil && reply != "" {
+			return parseClamdReply(reply)
+		}
+		return Result{}, fmt.Errorf("failed to stream to clamd: %w", err)
+	}
+	reply, err := readClamdReply(conn)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to read clamd reply: %w", err)
+	}
+	return parseClamdReply(reply)
+}
+
+// stream sends content in length prefixed chunks, ended by an empty chunk
+func (s *ClamdScanner) stream(w io.Writer, content io.Reader) error {
+	if _, err := io.WriteString(w, "zINSTREAM\x00"); err != nil {
+		return err
+	}
+	buf := make([]byte, 4+clamdChunkSize)
+	for {
+		n, err := content.Read(buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(n))
+			if _, werr := w.Write(buf[:4+n]); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err := w.Write([]byte{0, 0, 0, 0})
+	return err
+}
+
+// readClamdReply reads the null terminated reply of clamd
+func readClamdReply(r io.Reader) (string, error) {
+	reply, err := bufio.NewReader(r).ReadString(0)
+	if err != nil && (err != io.EOF || reply == "") {
+		return "", err
+	}
+	return strings.TrimSpace(strings.TrimRight(reply, "\x00")), nil
+}
+
+// parseClamdReply parses replies such as "stream: OK" and
+// "stream: Eicar-Test-Signature FOUND"
+func parseClamdReply(reply string) (Result, error) {
+	verdict := strings.TrimSpace(strings.TrimPrefix(reply, "stream:"))
+	switch {
+	case verdict == "OK":
+		return Result{}, nil
+	case strings.HasSuffix(verdict, " FOUND"):
+		return Result{Infected: true, Signature: strings.TrimSuffix(verdict, " FOUND")}, nil
+	}
+	return Result{}, fmt.Errorf("clamd: %s", reply)
+}
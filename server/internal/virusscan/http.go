@@ -0,0 +1,64 @@
+package virusscan
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// maxResponseBytes bounds the scanner response read
+const maxResponseBytes = 1 << 20
+
+// HTTPScanner posts content to an external scanning service. The service
+// receives the file as the request body and responds with JSON:
+//
+//	{"infected": true, "signature": "Eicar-Test-Signature"}
+type HTTPScanner struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPScanner returns a scanner posting to serviceURL
+func NewHTTPScanner(serviceURL string, timeout time.Duration) (*HTTPScanner, error) {
+	u, err := url.Parse(serviceURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("upload scanner URL must be an absolute http(s) URL: %q", serviceURL)
+	}
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &HTTPScanner{url: serviceURL, client: &http.Client{Timeout: timeout}}, nil
+}
+
+type httpResponse struct {
+	Infected  bool   `json:"infected"`
+	Signature string `json:"signature"`
+}
+
+// Scan implements Scanner
+func (s *HTTPScanner) Scan(ctx context.Context, content io.Reader) (Result, error) {
+	// The transport closes request bodies, content is left to the caller
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, io.NopCloser(content))
+	if err != nil {
+		return Result{}, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Accept", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return Result{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Result{}, fmt.Errorf("upload scanner responded %s", resp.Status)
+	}
+	var result httpResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(&result); err != nil {
+		return Result{}, fmt.Errorf("invalid upload scanner response: %w", err)
+	}
+	return Result{Infected: result.Infected, Signature: result.Signature}, nil
+}
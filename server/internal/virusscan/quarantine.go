@@ -0,0 +1,68 @@
+package virusscan
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// Finding describes an infected upload kept in quarantine
+type Finding struct {
+	// Path is the storage path the upload was bound for
+	Path      string    `json:"path"`
+	UserID    string    `json:"userId,omitempty"`
+	Signature string    `json:"signature"`
+	FoundAt   time.Time `json:"foundAt"`
+}
+
+// Quarantine keeps infected uploads in a local directory for admins to
+// review, out of reach of the storage and its users. Each upload is kept
+// next to a .json file describing the finding.
+type Quarantine struct {
+	dir string
+}
+
+// NewQuarantine returns a quarantine keeping uploads in dir, created on
+// first use
+func NewQuarantine(dir string) *Quarantine {
+	return &Quarantine{dir: dir}
+}
+
+// Keep writes content and its finding to the quarantine directory,
+// returning the name of the file kept
+func (q *Quarantine) Keep(content io.Reader, finding Finding) (string, error) {
+	if err := os.MkdirAll(q.dir, 0o700); err != nil {
+		return "", fmt.Errorf("failed to create quarantine directory: %w", err)
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	name := finding.FoundAt.UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix) + "-" + path.Base("/"+finding.Path)
+	file, err := os.OpenFile(filepath.Join(q.dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return "", fmt.Errorf("failed to create quarantine file: %w", err)
+	}
+	_, err = io.Copy(file, content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+		return "", fmt.Errorf("failed to write quarantine file: %w", err)
+	}
+	data, err := json.MarshalIndent(finding, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(q.dir, name+".json"), data, 0o600); err != nil {
+		return "", fmt.Errorf("failed to write quarantine finding: %w", err)
+	}
+	return name, nil
+}
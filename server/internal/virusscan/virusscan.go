@@ -0,0 +1,94 @@
+// Package virusscan scans uploads for malware before they are stored, with
+// ClamAV through its clamd socket or an external HTTP service. Backends are
+// registered by name, more can be built in with RegisterScanner.
+package virusscan
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultTimeout bounds one scan when Options leave it unset
+const defaultTimeout = 2 * time.Minute
+
+// Result is the verdict on scanned content
+type Result struct {
+	Infected bool
+	// Signature names the malware found, as the scanner reports it
+	Signature string
+}
+
+// Scanner scans content for malware
+type Scanner interface {
+	Scan(ctx context.Context, content io.Reader) (Result, error)
+}
+
+// Options configures a scanner created by NewScanner
+type Options struct {
+	// URL is the scanner location, as the backend defines it
+	URL string
+	// Timeout bounds one scan, 0 for the backend default
+	Timeout time.Duration
+}
+
+// Factory creates a scanner backend
+type Factory func(options Options) (Scanner, error)
+
+var (
+	scannersMu sync.RWMutex
+	scanners   = map[string]Factory{
+		"clamd": func(options Options) (Scanner, error) {
+			scanner, err := NewClamdScanner(options.URL, options.Timeout)
+			if err != nil {
+				return nil, err
+			}
+			return scanner, nil
+		},
+		"http": func(options Options) (Scanner, error) {
+			scanner, err := NewHTTPScanner(options.URL, options.Timeout)
+			if err != nil {
+				return nil, err
+			}
+			return scanner, nil
+		},
+	}
+)
+
+// RegisterScanner makes a scanner backend available by name. It panics if
+// the name is taken.
+func RegisterScanner(name string, factory Factory) {
+	scannersMu.Lock()
+	defer scannersMu.Unlock()
+	if _, ok := scanners[name]; ok {
+		panic("virusscan: scanner already registered: " + name)
+	}
+	scanners[name] = factory
+}
+
+// Scanners returns the names of the registered scanner backends
+func Scanners() []string {
+	scannersMu.RLock()
+	defer scannersMu.RUnlock()
+	names := make([]string, 0, len(scanners))
+	for name := range scanners {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewScanner creates the scanner backend registered as name
+func NewScanner(name string, options Options) (Scanner, error) {
+	scannersMu.RLock()
+	factory, ok := scanners[name]
+	scannersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown upload scanner %q, available: %s", name, strings.Join(Scanners(), ", "))
+	}
+	return factory(options)
+}
@@ -0,0 +1,216 @@
+package virusscan
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// serveClamd answers INSTREAM commands like clamd, finding the EICAR test
+// string, and returns the tcp:// URL it listens on
+func serveClamd(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				command := make([]byte, len("zINSTREAM\x00"))
+				if _, err := io.ReadFull(conn, command); err != nil || string(command) != "zINSTREAM\x00" {
+					_, _ = conn.Write([]byte("UNKNOWN COMMAND\x00"))
+					return
+				}
+				var content bytes.Buffer
+				for {
+					var size uint32
+					if err := binary.Read(conn, binary.BigEndian, &size); err != nil {
+						return
+					}
+					if size == 0 {
+						break
+					}
+					if _, err := io.CopyN(&content, conn, int64(size)); err != nil {
+						return
+					}
+				}
+				if strings.Contains(content.String(), eicar) {
+					_, _ = conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+					return
+				}
+				_, _ = conn.Write([]byte("stream: OK\x00"))
+			}(conn)
+		}
+	}()
+	return "tcp://" + listener.Addr().String()
+}
+
+func TestClamdScanner(t *testing.T) {
+	scanner, err := NewScanner("clamd", Options{URL: serveClamd(t), Timeout: 5 * time.Second})
+	require.NoError(t, err)
+
+	result, err := scanner.Scan(context.Background(), strings.NewReader("hello world"))
+	require.NoError(t, err)
+	assert.False(t, result.Infected)
+
+	// Spans several chunks, with the test string in the last one
+	content := strings.Repeat("a", 3*clamdChunkSize) + eicar
+	result, err = scanner.Scan(context.Background(), strings.NewReader(content))
+	require.NoError(t, err)
+	assert.True(t, result.Infected)
+	assert.Equal(t, "Eicar-Test-Signature", result.Signature)
+}
+
+func TestClamdScanner_Unreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	scanner, err := NewClamdScanner("tcp://"+addr, time.Second)
+	require.NoError(t, err)
+	_, err = scanner.Scan(context.Background(), strings.NewReader("hello"))
+	assert.ErrorContains(t, err, "failed to connect to clamd")
+}
+
+func TestNewClamdScanner(t *testing.T) {
+	for _, rawURL := range []string{"unix:///run/clamav/clamd.ctl", "tcp://localhost:3310"} {
+		_, err := NewClamdScanner(rawURL, 0)
+		assert.NoError(t, err, rawURL)
+	}
+	for _, rawURL := range []string{"", "localhost:3310", "tcp://localhost", "http://localhost:3310", "unix://"} {
+		_, err := NewClamdScanner(rawURL, 0)
+		assert.Error(t, err, rawURL)
+	}
+}
+
+func TestParseClamdReply(t *testing.T) {
+	result, err := parseClamdReply("stream: OK")
+	require.NoError(t, err)
+	assert.False(t, result.Infected)
+
+	result, err = parseClamdReply("stream: Win.Test.EICAR_HDB-1 FOUND")
+	require.NoError(t, err)
+	assert.Equal(t, Result{Infected: true, Signature: "Win.Test.EICAR_HDB-1"}, result)
+
+	_, err = parseClamdReply("INSTREAM size limit exceeded. ERROR")
+	assert.ErrorContains(t, err, "size limit exceeded")
+}
+
+func TestHTTPScanner(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		resp := httpResponse{}
+		if strings.Contains(string(body), eicar) {
+			resp = httpResponse{Infected: true, Signature: "EICAR"}
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	scanner, err := NewScanner("http", Options{URL: server.URL})
+	require.NoError(t, err)
+
+	result, err := scanner.Scan(context.Background(), strings.NewReader("hello world"))
+	require.NoError(t, err)
+	assert.False(t, result.Infected)
+
+	result, err = scanner.Scan(context.Background(), strings.NewReader(eicar))
+	require.NoError(t, err)
+	assert.Equal(t, Result{Infected: true, Signature: "EICAR"}, result)
+}
+
+func TestHTTPScanner_Errors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/invalid" {
+			_, _ = w.Write([]byte("not json"))
+			return
+		}
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	scanner, err := NewHTTPScanner(server.URL, time.Second)
+	require.NoError(t, err)
+	_, err = scanner.Scan(context.Background(), strings.NewReader("hello"))
+	assert.ErrorContains(t, err, "503")
+
+	scanner, err = NewHTTPScanner(server.URL+"/invalid", time.Second)
+	require.NoError(t, err)
+	_, err = scanner.Scan(context.Background(), strings.NewReader("hello"))
+	assert.ErrorContains(t, err, "invalid upload scanner response")
+
+	_, err = NewHTTPScanner("localhost:8080/scan", 0)
+	assert.Error(t, err)
+}
+
+func TestNewScanner_Unknown(t *testing.T) {
+	_, err := NewScanner("unknown", Options{})
+	assert.ErrorContains(t, err, "clamd, http")
+}
+
+func TestRegisterScanner(t *testing.T) {
+	RegisterScanner("test", func(Options) (Scanner, error) { return nil, nil })
+	t.Cleanup(func() {
+		scannersMu.Lock()
+		delete(scanners, "test")
+		scannersMu.Unlock()
+	})
+	assert.Contains(t, Scanners(), "test")
+	assert.Panics(t, func() {
+		RegisterScanner("test", func(Options) (Scanner, error) { return nil, nil })
+	})
+}
+
+func TestQuarantine_Keep(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "quarantine")
+	quarantine := NewQuarantine(dir)
+	finding := Finding{
+		Path:      "/photos/invoice.jpg",
+		UserID:    "user-1",
+		Signature: "Eicar-Test-Signature",
+		FoundAt:   time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+
+	name, err := quarantine.Keep(strings.NewReader(eicar), finding)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(name, "20260102T030405Z-"))
+	assert.True(t, strings.HasSuffix(name, "-invoice.jpg"))
+
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	require.NoError(t, err)
+	assert.Equal(t, eicar, string(data))
+
+	data, err = os.ReadFile(filepath.Join(dir, name+".json"))
+	require.NoError(t, err)
+	var kept Finding
+	require.NoError(t, json.Unmarshal(data, &kept))
+	assert.Equal(t, finding, kept)
+
+	// The same upload found twice is kept twice
+	other, err := quarantine.Keep(strings.NewReader(eicar), finding)
+	require.NoError(t, err)
+	assert.NotEqual(t, name, other)
+}
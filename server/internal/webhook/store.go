@@ -0,0 +1,386 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/model"
+	"github.com/cshum/imagor-studio/server/internal/pagination"
+	"github.com/cshum/imagor-studio/server/pkg/uuid"
+	"github.com/uptrace/bun"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrNotFound = errors.New("webhook not found")
+	ErrInvalid  = errors.New("invalid webhook")
+)
+
+// MaxDeliveryLimit caps the deliveries returned by ListDeliveries
+const MaxDeliveryLimit = 500
+
+// Delivery statuses
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Webhook is a URL receiving the events it subscribes to
+type Webhook struct {
+	ID  string
+	URL string
+	// Secret signs the requests, see SignatureHeader. Empty sends them
+	// unsigned.
+	Secret    string
+	Events    []string
+	Active    bool
+	CreatedBy string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Subscribed reports whether the webhook receives event
+func (w *Webhook) Subscribed(event string) bool {
+	return w.Active && slices.Contains(w.Events, event)
+}
+
+// Delivery is one event posted, or being posted, to a webhook
+type Delivery struct {
+	ID        string
+	WebhookID string
+	Event     string
+	// Payload is the JSON body posted
+	Payload string
+	Status  string
+	// Attempts is the number of requests made so far
+	Attempts int
+	// ResponseStatus is the HTTP status of the last attempt, 0 when it got
+	// no response
+	ResponseStatus int
+	// Error of the last failed attempt
+	Error     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type Store interface {
+	List(ctx context.Context) ([]*Webhook, error)
+	Get(ctx context.Context, id string) (*Webhook, error)
+	Create(ctx context.Context, rawURL, secret string, events []string, active bool, createdBy string) (*Webhook, error)
+	// Update replaces the URL, events and active state of a webhook, and
+	// its secret unless nil
+	Update(ctx context.Context, id, rawURL string, secret *string, events []string, active bool) (*Webhook, error)
+	// Delete removes a webhook with its deliveries
+	Delete(ctx context.Context, id string) error
+
+	CreateDelivery(ctx context.Context, delivery *Delivery) error
+	// UpdateDelivery saves the status, attempts, response status and error
+	// of a delivery
+	UpdateDelivery(ctx context.Context, delivery *Delivery) error
+	// ListDeliveries returns the deliveries of webhookID, of every webhook
+	// when empty, newest first, and their total count
+	ListDeliveries(ctx context.Context, webhookID string, offset, limit int) ([]*Delivery, int, error)
+	// ListDeliveriesAfter returns the deliveries of webhookID after the
+	// delivery at after, from the newest when nil, their total count and
+	// whether more follow the page
+	ListDeliveriesAfter(ctx context.Context, webhookID string, after *pagination.Key, limit int) ([]*Delivery, int, bool, error)
+	// PruneDeliveries deletes deliveries created before cutoff, returning
+	// how many
+	PruneDeliveries(ctx context.Context, cutoff time.Time) (int, error)
+}
+
+type store struct {
+	db     *bun.DB
+	logger *zap.Logger
+}
+
+func New(db *bun.DB, logger *zap.Logger) Store {
+	return &store{db: db, logger: logger}
+}
+
+// ValidateURL checks that rawURL is an absolute http or https URL
+func ValidateURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalid)
+	}
+	return nil
+}
+
+// NormalizeEvents validates events, returning them sorted without repeats
+func NormalizeEvents(events []string) ([]string, error) {
+	if len(events) == 0 {
+		return nil, fmt.Errorf("%w: at least one event is required", ErrInvalid)
+	}
+	result := make([]string, 0, len(events))
+	for _, event := range events {
+		event = strings.TrimSpace(event)
+		if !slices.Contains(Events, event) {
+			return nil, fmt.Errorf("%w: unknown event %q, expected one of %s", ErrInvalid, event, strings.Join(Events, ", "))
+		}
+		if !slices.Contains(result, event) {
+			result = append(result, event)
+		}
+	}
+	slices.Sort(result)
+	return result, nil
+}
+
+func (s *store) List(ctx context.Context) ([]*Webhook, error) {
+	var rows []model.Webhook
+	if err := s.db.NewSelect().Model(&rows).Order("created_at ASC", "id ASC").Scan(ctx); err != nil {
+		return nil, fmt.Errorf("error listing webhooks: %w", err)
+	}
+	webhooks := make([]*Webhook, 0, len(rows))
+	for i := range rows {
+		webhooks = append(webhooks, toWebhook(&rows[i]))
+	}
+	return webhooks, nil
+}
+
+func (s *store) Get(ctx context.Context, id string) (*Webhook, error) {
+	row := new(model.Webhook)
+	if err := s.db.NewSelect().Model(row).Where("id = ?", id).Scan(ctx); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error getting webhook: %w", err)
+	}
+	return toWebhook(row), nil
+}
+
+func (s *store) Create(ctx context.Context, rawURL, secret string, events []string, active bool, createdBy string) (*Webhook, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	if err := ValidateURL(rawURL); err != nil {
+		return nil, err
+	}
+	events, err := NormalizeEvents(events)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	row := &model.Webhook{
+		ID:        uuid.GenerateUUID(),
+		URL:       rawURL,
+		Secret:    secret,
+		Events:    strings.Join(events, ","),
+		Active:    active,
+		CreatedBy: createdBy,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if _, err := s.db.NewInsert().Model(row).Exec(ctx); err != nil {
+		return nil, fmt.Errorf("error creating webhook: %w", err)
+	}
+	return toWebhook(row), nil
+}
+
+func (s *store) Update(ctx context.Context, id, rawURL string, secret *string, events []string, active bool) (*Webhook, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	if err := ValidateURL(rawURL); err != nil {
+		return nil, err
+	}
+	events, err := NormalizeEvents(events)
+	if err != nil {
+		return nil, err
+	}
+	row := &model.Webhook{
+		ID:        id,
+		URL:       rawURL,
+		Events:    strings.Join(events, ","),
+		Active:    active,
+		UpdatedAt: time.Now().UTC(),
+	}
+	columns := []string{"url", "events", "active", "updated_at"}
+	if secret != nil {
+		row.Secret = *secret
+		columns = append(columns, "secret")
+	}
+	res, err := s.db.NewUpdate().Model(row).Column(columns...).WherePK().Exec(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error updating webhook: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, ErrNotFound
+	}
+	return s.Get(ctx, id)
+}
+
+func (s *store) Delete(ctx context.Context, id string) error {
+	return s.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		res, err := tx.NewDelete().Model((*model.Webhook)(nil)).Where("id = ?", id).Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("error deleting webhook: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return ErrNotFound
+		}
+		if _, err := tx.NewDelete().Model((*model.WebhookDelivery)(nil)).Where("webhook_id = ?", id).Exec(ctx); err != nil {
+			return fmt.Errorf("error deleting webhook deliveries: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *store) CreateDelivery(ctx context.Context, delivery *Delivery) error {
+	if delivery.ID == "" {
+		delivery.ID = uuid.GenerateUUID()
+	}
+	if delivery.CreatedAt.IsZero() {
+		delivery.CreatedAt = time.Now().UTC()
+	}
+	if delivery.Status == "" {
+		delivery.Status = StatusPending
+	}
+	delivery.UpdatedAt = delivery.CreatedAt
+	row := toDeliveryModel(delivery)
+	if _, err := s.db.NewInsert().Model(row).Exec(ctx); err != nil {
+		return fmt.Errorf("error creating webhook delivery: %w", err)
+	}
+	return nil
+}
+
+func (s *store) UpdateDelivery(ctx context.Context, delivery *Delivery) error {
+	delivery.UpdatedAt = time.Now().UTC()
+	row := toDeliveryModel(delivery)
+	if _, err := s.db.NewUpdate().Model(row).
+		Column("status", "attempts", "response_status", "error", "updated_at").
+		WherePK().
+		Exec(ctx); err != nil {
+		return fmt.Errorf("error updating webhook delivery: %w", err)
+	}
+	return nil
+}
+
+func (s *store) ListDeliveries(ctx context.Context, webhookID string, offset, limit int) ([]*Delivery, int, error) {
+	if limit <= 0 || limit > MaxDeliveryLimit {
+		limit = MaxDeliveryLimit
+	}
+	var rows []model.WebhookDelivery
+	q := s.db.NewSelect().Model(&rows)
+	if webhookID != "" {
+		q = q.Where("webhook_id = ?", webhookID)
+	}
+	total, err := q.Order("created_at DESC", "id DESC").
+		Offset(max(offset, 0)).
+		Limit(limit).
+		ScanAndCount(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error listing webhook deliveries: %w", err)
+	}
+	return toDeliveries(rows), total, nil
+}
+
+func (s *store) ListDeliveriesAfter(ctx context.Context, webhookID string, after *pagination.Key, limit int) ([]*Delivery, int, bool, error) {
+	if limit <= 0 || limit > MaxDeliveryLimit {
+		limit = MaxDeliveryLimit
+	}
+	byWebhook := func(q *bun.SelectQuery) *bun.SelectQuery {
+		if webhookID != "" {
+			q = q.Where("webhook_id = ?", webhookID)
+		}
+		return q
+	}
+	total, err := byWebhook(s.db.NewSelect().Model((*model.WebhookDelivery)(nil))).Count(ctx)
+	if err != nil {
+		return nil, 0, false, fmt.Errorf("error counting webhook deliveries: %w", err)
+	}
+	// One more row than the page tells whether another page follows
+	var rows []model.WebhookDelivery
+	if err := after.After(byWebhook(s.db.NewSelect().Model(&rows))).
+		Order("created_at DESC", "id DESC").
+		Limit(limit + 1).
+		Scan(ctx); err != nil {
+		return nil, 0, false, fmt.Errorf("error listing webhook deliveries: %w", err)
+	}
+	more := len(rows) > limit
+	if more {
+		rows = rows[:limit]
+	}
+	return toDeliveries(rows), total, more, nil
+}
+
+func toDeliveries(rows []model.WebhookDelivery) []*Delivery {
+	deliveries := make([]*Delivery, 0, len(rows))
+	for i := range rows {
+		row := &rows[i]
+		deliveries = append(deliveries, &Delivery{
+			ID:             row.ID,
+			WebhookID:      row.WebhookID,
+			Event:          row.Event,
+			Payload:        row.Payload,
+			Status:         row.Status,
+			Attempts:       row.Attempts,
+			ResponseStatus: row.ResponseStatus,
+			Error:          row.Error,
+			CreatedAt:      row.CreatedAt,
+			UpdatedAt:      row.UpdatedAt,
+		})
+	}
+	return deliveries
+}
+
+func (s *store) PruneDeliveries(ctx context.Context, cutoff time.Time) (int, error) {
+	res, err := s.db.NewDelete().Model((*model.WebhookDelivery)(nil)).
+		Where("created_at < ?", cutoff.UTC()).
+		Exec(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error pruning webhook deliveries: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// NewPruneFunc returns a sync function deleting deliveries older than
+// retention, for use with the server sync loop
+func NewPruneFunc(store Store, retention time.Duration, logger *zap.Logger) func() error {
+	return func() error {
+		pruned, err := store.PruneDeliveries(context.Background(), time.Now().Add(-retention))
+		if err != nil {
+			return err
+		}
+		if pruned > 0 {
+			logger.Info("Pruned webhook deliveries", zap.Int("deliveries", pruned), zap.Duration("retention", retention))
+		}
+		return nil
+	}
+}
+
+func toWebhook(row *model.Webhook) *Webhook {
+	var events []string
+	if row.Events != "" {
+		events = strings.Split(row.Events, ",")
+	}
+	return &Webhook{
+		ID:        row.ID,
+		URL:       row.URL,
+		Secret:    row.Secret,
+		Events:    events,
+		Active:    row.Active,
+		CreatedBy: row.CreatedBy,
+		CreatedAt: row.CreatedAt,
+		UpdatedAt: row.UpdatedAt,
+	}
+}
+
+func toDeliveryModel(delivery *Delivery) *model.WebhookDelivery {
+	return &model.WebhookDelivery{
+		ID:             delivery.ID,
+		WebhookID:      delivery.WebhookID,
+		Event:          delivery.Event,
+		Payload:        delivery.Payload,
+		Status:         delivery.Status,
+		Attempts:       delivery.Attempts,
+		ResponseStatus: delivery.ResponseStatus,
+		Error:          delivery.Error,
+		CreatedAt:      delivery.CreatedAt,
+		UpdatedAt:      delivery.UpdatedAt,
+	}
+}
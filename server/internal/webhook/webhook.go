@@ -0,0 +1,334 @@
+// Package webhook notifies URLs registered by admins of events on the
+// server, such as uploads and deletions of files of the default storage,
+// albums created and shared links opened.
+//
+// Each event is posted as a JSON Payload to every active webhook subscribed
+// to it, signed with the webhook secret like label hook requests. Every
+// delivery is logged with its attempts and last response for admins to
+// review. Failed attempts are retried with exponential backoff; deliveries
+// are queued in memory, so those still pending when the server stops are
+// not resumed.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/events"
+	"github.com/cshum/imagor-studio/server/internal/labelhook"
+	"github.com/cshum/imagor-studio/server/pkg/uuid"
+	"go.uber.org/zap"
+)
+
+// Event names
+const (
+	EventFileUploaded  = "file.uploaded"
+	EventFileDeleted   = "file.deleted"
+	EventAlbumCreated  = "album.created"
+	EventShareAccessed = "share.accessed"
+)
+
+// Events are the event names webhooks can subscribe to
+var Events = []string{EventAlbumCreated, EventFileDeleted, EventFileUploaded, EventShareAccessed}
+
+const (
+	// SignatureHeader carries the HMAC-SHA256 of the body when the webhook
+	// has a secret, as "sha256=<hex>"
+	SignatureHeader = labelhook.SignatureHeader
+	// EventHeader and DeliveryHeader carry the event name and delivery ID
+	EventHeader    = "X-Imagor-Studio-Event"
+	DeliveryHeader = "X-Imagor-Studio-Delivery"
+
+	defaultQueueSize   = 1000
+	defaultWorkers     = 2
+	defaultRetryDelay  = 10 * time.Second
+	defaultMaxAttempts = 5
+	// maxErrorLength bounds the response body kept as delivery error
+	maxErrorLength = 512
+)
+
+// Payload is the JSON body posted to webhooks
+type Payload struct {
+	// ID is the delivery ID, the same across retries
+	ID    string `json:"id"`
+	Event string `json:"event"`
+	// CreatedAt is the RFC3339 time of the event
+	CreatedAt string `json:"createdAt"`
+	Data      any    `json:"data"`
+}
+
+// FileData is the data of file events. Paths are relative to the default
+// storage root.
+type FileData struct {
+	Path string `json:"path"`
+}
+
+// AlbumData is the data of album.created
+type AlbumData struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	CreatedBy string `json:"createdBy"`
+}
+
+// ShareData is the data of share.accessed
+type ShareData struct {
+	ShareID string `json:"shareId"`
+	Path    string `json:"path"`
+}
+
+type notification struct {
+	event string
+	data  any
+	at    time.Time
+}
+
+type job struct {
+	webhook  *Webhook
+	delivery *Delivery
+	// retryDelay is the wait before retrying the next failed attempt
+	retryDelay time.Duration
+}
+
+// Dispatcher delivers events to webhooks
+type Dispatcher struct {
+	store         Store
+	client        *http.Client
+	logger        *zap.Logger
+	notifications chan notification
+	jobs          chan job
+	workers       int
+	retryDelay    time.Duration
+	maxAttempts   int
+}
+
+// Option configures a Dispatcher
+type Option func(*Dispatcher)
+
+// WithLogger sets the logger
+func WithLogger(logger *zap.Logger) Option {
+	return func(d *Dispatcher) {
+		d.logger = logger
+	}
+}
+
+// WithClient sets the HTTP client posting requests
+func WithClient(client *http.Client) Option {
+	return func(d *Dispatcher) {
+		d.client = client
+	}
+}
+
+// WithRetryDelay sets the wait before the first retry, doubled on each
+// following one
+func WithRetryDelay(delay time.Duration) Option {
+	return func(d *Dispatcher) {
+		d.retryDelay = delay
+	}
+}
+
+// WithMaxAttempts sets the attempts made before a delivery fails
+func WithMaxAttempts(attempts int) Option {
+	return func(d *Dispatcher) {
+		if attempts > 0 {
+			d.maxAttempts = attempts
+		}
+	}
+}
+
+// WithQueueSize bounds the events and deliveries waiting to be sent
+func WithQueueSize(size int) Option {
+	return func(d *Dispatcher) {
+		if size > 0 {
+			d.notifications = make(chan notification, size)
+			d.jobs = make(chan job, size)
+		}
+	}
+}
+
+// NewDispatcher returns a dispatcher delivering to the webhooks of store
+func NewDispatcher(store Store, options ...Option) *Dispatcher {
+	d := &Dispatcher{
+		store:         store,
+		client:        &http.Client{Timeout: 30 * time.Second},
+		logger:        zap.NewNop(),
+		notifications: make(chan notification, defaultQueueSize),
+		jobs:          make(chan job, defaultQueueSize),
+		workers:       defaultWorkers,
+		retryDelay:    defaultRetryDelay,
+		maxAttempts:   defaultMaxAttempts,
+	}
+	for _, option := range options {
+		option(d)
+	}
+	return d
+}
+
+// Notify queues event to be delivered to the webhooks subscribed to it,
+// without blocking. It is dropped when the queue is full.
+func (d *Dispatcher) Notify(event string, data any) {
+	select {
+	case d.notifications <- notification{event: event, data: data, at: time.Now().UTC()}:
+	default:
+		d.logger.Warn("Webhook queue full, dropping event", zap.String("event", event))
+	}
+}
+
+// Run delivers queued events, and the uploads and deletions of files in
+// scope, until ctx is done
+func (d *Dispatcher) Run(ctx context.Context, broker *events.Broker, scope string) {
+	var changes <-chan events.Event
+	if broker != nil {
+		changes = broker.Subscribe(ctx, func(e events.Event) bool {
+			return e.Scope == scope && ((e.Kind == events.FileCreated && e.Uploaded) || e.Kind == events.FileDeleted)
+		})
+	}
+	var wg sync.WaitGroup
+	for range d.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case j := <-d.jobs:
+					d.deliver(ctx, j)
+				}
+			}
+		}()
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case n := <-d.notifications:
+			d.dispatch(ctx, n)
+		case e, ok := <-changes:
+			if !ok {
+				changes = nil
+				continue
+			}
+			event := EventFileUploaded
+			if e.Kind == events.FileDeleted {
+				event = EventFileDeleted
+			}
+			d.dispatch(ctx, notification{event: event, data: FileData{Path: e.Path}, at: time.Now().UTC()})
+		}
+	}
+}
+
+// dispatch logs a pending delivery of n for each subscribed webhook and
+// queues it
+func (d *Dispatcher) dispatch(ctx context.Context, n notification) {
+	webhooks, err := d.store.List(ctx)
+	if err != nil {
+		d.logger.Warn("Failed to list webhooks", zap.String("event", n.event), zap.Error(err))
+		return
+	}
+	for _, w := range webhooks {
+		if !w.Subscribed(n.event) {
+			continue
+		}
+		delivery := &Delivery{ID: uuid.GenerateUUID(), WebhookID: w.ID, Event: n.event, CreatedAt: n.at}
+		body, err := json.Marshal(Payload{
+			ID:        delivery.ID,
+			Event:     n.event,
+			CreatedAt: n.at.Format(time.RFC3339),
+			Data:      n.data,
+		})
+		if err != nil {
+			d.logger.Warn("Failed to encode webhook payload", zap.String("event", n.event), zap.Error(err))
+			return
+		}
+		delivery.Payload = string(body)
+		if err := d.store.CreateDelivery(ctx, delivery); err != nil {
+			d.logger.Warn("Failed to log webhook delivery", zap.String("webhookID", w.ID), zap.Error(err))
+			continue
+		}
+		select {
+		case d.jobs <- job{webhook: w, delivery: delivery, retryDelay: d.retryDelay}:
+		default:
+			delivery.Status = StatusFailed
+			delivery.Error = "delivery queue full"
+			d.saveDelivery(delivery)
+		}
+	}
+}
+
+// deliver makes an attempt of a delivery and logs its outcome. A failed
+// attempt is queued again after the retry delay, leaving the worker free
+// for other deliveries meanwhile.
+func (d *Dispatcher) deliver(ctx context.Context, j job) {
+	status, err := d.post(ctx, j.webhook, j.delivery)
+	j.delivery.Attempts++
+	j.delivery.ResponseStatus = status
+	switch {
+	case err == nil:
+		j.delivery.Status = StatusSucceeded
+		j.delivery.Error = ""
+	case j.delivery.Attempts >= d.maxAttempts:
+		j.delivery.Status = StatusFailed
+		j.delivery.Error = err.Error()
+		d.logger.Warn("Webhook delivery failed", zap.String("webhookID", j.webhook.ID), zap.String("event", j.delivery.Event), zap.Error(err))
+	default:
+		j.delivery.Error = err.Error()
+	}
+	d.saveDelivery(j.delivery)
+	if j.delivery.Status != StatusPending {
+		return
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(j.retryDelay):
+		}
+		j.retryDelay *= 2
+		select {
+		case <-ctx.Done():
+		case d.jobs <- j:
+		}
+	}()
+}
+
+// saveDelivery updates a delivery outside of the request context, so the
+// outcome is kept while the server stops
+func (d *Dispatcher) saveDelivery(delivery *Delivery) {
+	if err := d.store.UpdateDelivery(context.Background(), delivery); err != nil {
+		d.logger.Warn("Failed to update webhook delivery", zap.String("deliveryID", delivery.ID), zap.Error(err))
+	}
+}
+
+func (d *Dispatcher) post(ctx context.Context, w *Webhook, delivery *Delivery) (int, error) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, delivery.ID)
+	if w.Secret != "" {
+		req.Header.Set(SignatureHeader, labelhook.Sign(w.Secret, body))
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorLength))
+		if len(detail) > 0 {
+			return resp.StatusCode, fmt.Errorf("webhook responded %s: %s", resp.Status, bytes.TrimSpace(detail))
+		}
+		return resp.StatusCode, fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,199 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/internal/events"
+	"github.com/cshum/imagor-studio/server/internal/labelhook"
+	"github.com/cshum/imagor-studio/server/internal/pagination"
+	"github.com/cshum/imagor-studio/server/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func setupTestStore(t *testing.T) Store {
+	t.Helper()
+	db := testutil.NewDB(t)
+
+	return New(db, zap.NewNop())
+}
+
+func TestStore(t *testing.T) {
+	s := setupTestStore(t)
+	ctx := context.Background()
+
+	_, err := s.Create(ctx, "ftp://example.com", "", []string{EventFileUploaded}, true, "admin-1")
+	assert.ErrorIs(t, err, ErrInvalid)
+	_, err = s.Create(ctx, "https://example.com/hook", "", nil, true, "admin-1")
+	assert.ErrorIs(t, err, ErrInvalid)
+	_, err = s.Create(ctx, "https://example.com/hook", "", []string{"file.renamed"}, true, "admin-1")
+	assert.ErrorIs(t, err, ErrInvalid)
+
+	w, err := s.Create(ctx, " https://example.com/hook ", "s3cret", []string{EventFileUploaded, EventAlbumCreated, EventFileUploaded}, true, "admin-1")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/hook", w.URL)
+	assert.Equal(t, []string{EventAlbumCreated, EventFileUploaded}, w.Events)
+	assert.True(t, w.Subscribed(EventFileUploaded))
+	assert.False(t, w.Subscribed(EventFileDeleted))
+
+	// A nil secret keeps the current one
+	w, err = s.Update(ctx, w.ID, "https://example.com/other", nil, []string{EventFileDeleted}, false)
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", w.Secret)
+	assert.False(t, w.Active)
+	assert.False(t, w.Subscribed(EventFileDeleted))
+	_, err = s.Update(ctx, "missing", "https://example.com/other", nil, []string{EventFileDeleted}, true)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	old := &Delivery{WebhookID: w.ID, Event: EventFileDeleted, Payload: "{}", CreatedAt: time.Now().Add(-48 * time.Hour)}
+	require.NoError(t, s.CreateDelivery(ctx, old))
+	recent := &Delivery{WebhookID: w.ID, Event: EventFileDeleted, Payload: "{}"}
+	require.NoError(t, s.CreateDelivery(ctx, recent))
+	recent.Status, recent.Attempts, recent.ResponseStatus = StatusSucceeded, 2, http.StatusOK
+	require.NoError(t, s.UpdateDelivery(ctx, recent))
+
+	deliveries, total, err := s.ListDeliveries(ctx, w.ID, 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	require.Len(t, deliveries, 2)
+	assert.Equal(t, recent.ID, deliveries[0].ID)
+	assert.Equal(t, StatusSucceeded, deliveries[0].Status)
+	assert.Equal(t, 2, deliveries[0].Attempts)
+	assert.Equal(t, StatusPending, deliveries[1].Status)
+
+	deliveries, total, more, err := s.ListDeliveriesAfter(ctx, w.ID, nil, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.True(t, more)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, recent.ID, deliveries[0].ID)
+	deliveries, _, more, err = s.ListDeliveriesAfter(ctx, w.ID, &pagination.Key{CreatedAt: deliveries[0].CreatedAt, ID: deliveries[0].ID}, 1)
+	require.NoError(t, err)
+	assert.False(t, more)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, old.ID, deliveries[0].ID)
+
+	pruned, err := s.PruneDeliveries(ctx, time.Now().Add(-24*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, pruned)
+
+	require.NoError(t, s.Delete(ctx, w.ID))
+	assert.ErrorIs(t, s.Delete(ctx, w.ID), ErrNotFound)
+	_, total, err = s.ListDeliveries(ctx, "", 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 0, total, "deliveries are deleted with their webhook")
+}
+
+func TestDispatcher(t *testing.T) {
+	var attempts atomic.Int32
+	received := make(chan Payload, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, labelhook.Sign("s3cret", body), r.Header.Get(SignatureHeader))
+		// The first attempt fails, the retry goes through
+		if attempts.Add(1) == 1 {
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return
+		}
+		var payload Payload
+		assert.NoError(t, json.Unmarshal(body, &payload))
+		assert.Equal(t, payload.Event, r.Header.Get(EventHeader))
+		assert.Equal(t, payload.ID, r.Header.Get(DeliveryHeader))
+		received <- payload
+	}))
+	defer srv.Close()
+
+	s := setupTestStore(t)
+	w, err := s.Create(context.Background(), srv.URL, "s3cret", []string{EventFileUploaded, EventShareAccessed}, true, "admin-1")
+	require.NoError(t, err)
+
+	d := NewDispatcher(s, WithRetryDelay(time.Millisecond))
+	broker := events.NewBroker()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx, broker, "system:global")
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// Wait for the subscription before publishing
+	time.Sleep(50 * time.Millisecond)
+	broker.Publish(events.Event{Kind: events.FileCreated, Scope: "system:global", Path: "photos/copy.jpg"})
+	broker.Publish(events.Event{Kind: events.FileDeleted, Scope: "system:global", Path: "photos/old.jpg"})
+	broker.Publish(events.Event{Kind: events.FileCreated, Scope: "space:other", Path: "photos/b.jpg", Uploaded: true})
+	broker.Publish(events.Event{Kind: events.FileCreated, Scope: "system:global", Path: "photos/a.jpg", Uploaded: true})
+
+	select {
+	case payload := <-received:
+		assert.Equal(t, EventFileUploaded, payload.Event)
+		assert.Equal(t, map[string]any{"path": "photos/a.jpg"}, payload.Data)
+	case <-time.After(5 * time.Second):
+		t.Fatal("upload not delivered")
+	}
+
+	d.Notify(EventAlbumCreated, AlbumData{ID: "album-1", Name: "Trip"})
+	d.Notify(EventShareAccessed, ShareData{ShareID: "share-1", Path: "photos"})
+	select {
+	case payload := <-received:
+		assert.Equal(t, EventShareAccessed, payload.Event)
+		assert.Equal(t, map[string]any{"shareId": "share-1", "path": "photos"}, payload.Data)
+	case <-time.After(5 * time.Second):
+		t.Fatal("share access not delivered")
+	}
+
+	deliveries, total, err := s.ListDeliveries(context.Background(), w.ID, 0, 0)
+	require.NoError(t, err)
+	require.Equal(t, 2, total, "only subscribed events are delivered")
+	upload := deliveries[1]
+	assert.Equal(t, EventFileUploaded, upload.Event)
+	assert.Equal(t, StatusSucceeded, upload.Status)
+	assert.Equal(t, 2, upload.Attempts)
+	assert.Equal(t, http.StatusOK, upload.ResponseStatus)
+}
+
+func TestDispatcher_Failed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "gone", http.StatusGone)
+	}))
+	defer srv.Close()
+
+	s := setupTestStore(t)
+	w, err := s.Create(context.Background(), srv.URL, "", []string{EventAlbumCreated}, true, "admin-1")
+	require.NoError(t, err)
+
+	d := NewDispatcher(s, WithRetryDelay(time.Millisecond), WithMaxAttempts(3))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx, nil, "system:global")
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	d.Notify(EventAlbumCreated, AlbumData{ID: "album-1", Name: "Trip"})
+	require.Eventually(t, func() bool {
+		deliveries, _, err := s.ListDeliveries(context.Background(), w.ID, 0, 0)
+		return err == nil && len(deliveries) == 1 && deliveries[0].Status == StatusFailed
+	}, 5*time.Second, 10*time.Millisecond)
+
+	deliveries, _, err := s.ListDeliveries(context.Background(), w.ID, 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 3, deliveries[0].Attempts)
+	assert.Equal(t, http.StatusGone, deliveries[0].ResponseStatus)
+	assert.Contains(t, deliveries[0].Error, "gone")
+}
@@ -3,17 +3,22 @@ package auth
 import (
 	"context"
 	"fmt"
+	"regexp"
 	"strings"
 	"time"
 
+	"github.com/cshum/imagor-studio/server/pkg/safepath"
 	"github.com/golang-jwt/jwt/v5"
 )
 
 // Claims represents JWT claims structure
 type Claims struct {
 	jwt.RegisteredClaims
-	UserID     string   `json:"user_id"`
-	OrgID      string   `json:"org_id,omitempty"` // owning org; "" for self-hosted / guest tokens
+	UserID string `json:"user_id"`
+	OrgID  string `json:"org_id,omitempty"` // owning org; "" for self-hosted / guest tokens
+	// TenantID is the tenant the user was a member of when the token was
+	// issued, empty outside of tenants
+	TenantID   string   `json:"tenant_id,omitempty"`
 	Role       string   `json:"role"`
 	Scopes     []string `json:"scopes"`
 	PathPrefix string   `json:"path_prefix,omitempty"`
@@ -21,10 +26,79 @@ type Claims struct {
 	Mode       string   `json:"mode,omitempty"`
 	Kind       string   `json:"kind,omitempty"`
 	SpaceKey   string   `json:"space_key,omitempty"`
+	// SessionID is the login session the token was issued for, whose
+	// revocation invalidates the token
+	SessionID string `json:"sid,omitempty"`
+	// WritePrefix is the folder a guest without the write scope may still
+	// write below
+	WritePrefix string `json:"write_prefix,omitempty"`
+	// Watermark is overlaid on the images rendered for an embedded viewer,
+	// who may not download originals
+	Watermark *Watermark `json:"watermark,omitempty"`
+	// ShareID is the shared link a ShareLinkTokenKind session was opened
+	// from
+	ShareID string `json:"share_id,omitempty"`
+}
+
+// Watermark are the arguments of the imagor watermark filter
+type Watermark struct {
+	// Image is the storage path of the watermark image
+	Image string `json:"image"`
+	// X and Y position the watermark: left, right, center or repeat, top
+	// or bottom for Y, pixels, negative from the right or bottom, or a
+	// percentage such as 20p. Both default to center.
+	X string `json:"x,omitempty"`
+	Y string `json:"y,omitempty"`
+	// Alpha is the transparency from 0, opaque, to 100
+	Alpha int `json:"alpha,omitempty"`
+	// WRatio and HRatio scale the watermark to a percentage of the image
+	// width and height, 0 keeps its size
+	WRatio int `json:"w_ratio,omitempty"`
+	HRatio int `json:"h_ratio,omitempty"`
+}
+
+var watermarkPosition = regexp.MustCompile(`^(left|right|center|top|bottom|repeat|-?\d+p?)$`)
+
+// Validate checks the watermark arguments
+func (w *Watermark) Validate() error {
+	image := strings.Trim(w.Image, "/")
+	if image == "" {
+		return fmt.Errorf("watermark image is required")
+	}
+	if err := safepath.Validate(image); err != nil {
+		return fmt.Errorf("watermark image: %w", err)
+	}
+	for _, position := range []string{w.X, w.Y} {
+		if position != "" && !watermarkPosition.MatchString(position) {
+			return fmt.Errorf("invalid watermark position %q", position)
+		}
+	}
+	for _, value := range []int{w.Alpha, w.WRatio, w.HRatio} {
+		if value < 0 || value > 100 {
+			return fmt.Errorf("watermark alpha and ratios must be between 0 and 100")
+		}
+	}
+	return nil
 }
 
 const ExperienceModePublicPreview = "public-preview"
 
+// ShareLinkTokenKind marks guest sessions opened from a shared link, scoped
+// read-only to the shared path
+const ShareLinkTokenKind = "share-link"
+
+// EmbedTokenKind marks tokens minted by /api/embed/token for a viewer of
+// the embedded editor
+const EmbedTokenKind = "embed"
+
+// APITokenKind marks sessions authenticated by a personal access token
+// instead of a signed JWT
+const APITokenKind = "api-token"
+
+// S3AccessKeyKind marks sessions of requests to the S3 gateway signed with
+// an access key
+const S3AccessKeyKind = "s3-access-key"
+
 // TokenManager handles JWT operations
 type TokenManager struct {
 	secret        []byte
@@ -131,15 +205,20 @@ func (tm *TokenManager) RefreshToken(claims *Claims) (string, error) {
 			IssuedAt:  jwt.NewNumericDate(now),
 			ID:        fmt.Sprintf("%d", now.UnixNano()),
 		},
-		UserID:     claims.UserID,
-		OrgID:      claims.OrgID, // propagate org_id on refresh (never changes in Phase 1)
-		Role:       claims.Role,
-		Scopes:     claims.Scopes,
-		PathPrefix: claims.PathPrefix,
-		IsEmbedded: claims.IsEmbedded,
-		Mode:       claims.Mode,
-		Kind:       claims.Kind,
-		SpaceKey:   claims.SpaceKey,
+		UserID:      claims.UserID,
+		OrgID:       claims.OrgID, // propagate org_id on refresh (never changes in Phase 1)
+		TenantID:    claims.TenantID,
+		Role:        claims.Role,
+		Scopes:      claims.Scopes,
+		PathPrefix:  claims.PathPrefix,
+		IsEmbedded:  claims.IsEmbedded,
+		Mode:        claims.Mode,
+		Kind:        claims.Kind,
+		SpaceKey:    claims.SpaceKey,
+		SessionID:   claims.SessionID,
+		WritePrefix: claims.WritePrefix,
+		Watermark:   claims.Watermark,
+		ShareID:     claims.ShareID,
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, newClaims)
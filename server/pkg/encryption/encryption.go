@@ -1,6 +1,7 @@
 package encryption
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
@@ -8,14 +9,22 @@ import (
 	"encoding/base64"
 	"fmt"
 	"path/filepath"
+	"sync"
 
 	"golang.org/x/crypto/pbkdf2"
 )
 
+// DataKeyRegistryKey is the system registry key of the data key, which is
+// encrypted with the master key like the JWT secret
+const DataKeyRegistryKey = "config.encryption_key"
+
 // Service provides encryption and decryption functionality
 type Service struct {
+	mu        sync.RWMutex
 	masterKey []byte
 	jwtKey    []byte
+	// dataKey replaces the JWT-derived key once a key rotation took place
+	dataKey []byte
 }
 
 // NewService creates encryption service with only master key (for JWT secret bootstrap)
@@ -41,9 +50,48 @@ func NewServiceWithJwtLey(dbPath string, jwtSecret string) *Service {
 
 // SetJWTKey sets the JWT key after it's been loaded/generated
 func (s *Service) SetJWTKey(jwtSecret string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.jwtKey = deriveJWTKey(jwtSecret)
 }
 
+// SetDataKey sets the data key stored under DataKeyRegistryKey. Once set, it
+// is used instead of the JWT-derived key for other secrets.
+func (s *Service) SetDataKey(dataKey string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dataKey = deriveDataKey(dataKey)
+}
+
+// HasDataKey reports whether the data key is the one in use
+func (s *Service) HasDataKey(dataKey string) bool {
+	derived := deriveDataKey(dataKey)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return bytes.Equal(s.dataKey, derived)
+}
+
+// WithDataKey returns a copy of the service using the data key, leaving s
+// unchanged
+func (s *Service) WithDataKey(dataKey string) *Service {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return &Service{
+		masterKey: s.masterKey,
+		jwtKey:    s.jwtKey,
+		dataKey:   deriveDataKey(dataKey),
+	}
+}
+
+// GenerateDataKey returns a random data key for SetDataKey
+func GenerateDataKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("failed to generate data key: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
 // deriveMasterKey creates a deterministic master key from database path
 func deriveMasterKey(dbPath string) []byte {
 	// Use absolute path for consistency
@@ -62,6 +110,25 @@ func deriveJWTKey(jwtSecret string) []byte {
 	return pbkdf2.Key([]byte(jwtSecret), []byte(salt), 4096, 32, sha256.New)
 }
 
+// deriveDataKey creates encryption key from a data key
+func deriveDataKey(dataKey string) []byte {
+	salt := "imagor-studio-data-encryption-v1"
+	return pbkdf2.Key([]byte(dataKey), []byte(salt), 4096, 32, sha256.New)
+}
+
+// secretKey returns the key for secrets other than the JWT secret
+func (s *Service) secretKey() ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.dataKey != nil {
+		return s.dataKey, nil
+	}
+	if s.jwtKey == nil {
+		return nil, fmt.Errorf("JWT key not set")
+	}
+	return s.jwtKey, nil
+}
+
 // EncryptWithMaster encrypts data using master key (for JWT secret)
 func (s *Service) EncryptWithMaster(plaintext string) (string, error) {
 	return s.encrypt(plaintext, s.masterKey)
@@ -72,20 +139,24 @@ func (s *Service) DecryptWithMaster(ciphertext string) (string, error) {
 	return s.decrypt(ciphertext, s.masterKey)
 }
 
-// EncryptWithJWT encrypts data using JWT-derived key (for other secrets)
+// EncryptWithJWT encrypts data using JWT-derived key, or the data key once
+// set (for other secrets)
 func (s *Service) EncryptWithJWT(plaintext string) (string, error) {
-	if s.jwtKey == nil {
-		return "", fmt.Errorf("JWT key not set")
+	key, err := s.secretKey()
+	if err != nil {
+		return "", err
 	}
-	return s.encrypt(plaintext, s.jwtKey)
+	return s.encrypt(plaintext, key)
 }
 
-// DecryptWithJWT decrypts data using JWT-derived key (for other secrets)
+// DecryptWithJWT decrypts data using JWT-derived key, or the data key once
+// set (for other secrets)
 func (s *Service) DecryptWithJWT(ciphertext string) (string, error) {
-	if s.jwtKey == nil {
-		return "", fmt.Errorf("JWT key not set")
+	key, err := s.secretKey()
+	if err != nil {
+		return "", err
 	}
-	return s.decrypt(ciphertext, s.jwtKey)
+	return s.decrypt(ciphertext, key)
 }
 
 // encrypt encrypts plaintext using AES-256-GCM with the provided key
@@ -148,7 +219,13 @@ func (s *Service) decrypt(encodedCiphertext string, key []byte) (string, error)
 	return string(plaintext), nil
 }
 
-// IsJWTSecret checks if a key should be encrypted with master key (JWT secret only)
+// IsJWTSecret checks if a key is the JWT secret
 func IsJWTSecret(key string) bool {
 	return key == "config.jwt_secret"
 }
+
+// UsesMasterKey checks if a key should be encrypted with master key (JWT
+// secret and data key)
+func UsesMasterKey(key string) bool {
+	return IsJWTSecret(key) || key == DataKeyRegistryKey
+}
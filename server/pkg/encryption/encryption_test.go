@@ -498,3 +498,36 @@ func BenchmarkDecryptWithJWT(b *testing.B) {
 		}
 	}
 }
+
+func TestDataKey(t *testing.T) {
+	service := NewServiceWithJwtLey("/test/path", "jwt-key")
+	encrypted, err := service.EncryptWithJWT("secret")
+	require.NoError(t, err)
+
+	dataKey, err := GenerateDataKey()
+	require.NoError(t, err)
+	rotated := service.WithDataKey(dataKey)
+	assert.False(t, service.HasDataKey(dataKey), "WithDataKey leaves the service unchanged")
+	assert.True(t, rotated.HasDataKey(dataKey))
+
+	reencrypted, err := rotated.EncryptWithJWT("secret")
+	require.NoError(t, err)
+	_, err = rotated.DecryptWithJWT(encrypted)
+	assert.Error(t, err, "the data key replaces the JWT-derived key")
+
+	service.SetDataKey(dataKey)
+	decrypted, err := service.DecryptWithJWT(reencrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "secret", decrypted)
+
+	// The data key works without the JWT key
+	withoutJWT := NewService("/test/path")
+	withoutJWT.SetDataKey(dataKey)
+	decrypted, err = withoutJWT.DecryptWithJWT(reencrypted)
+	require.NoError(t, err)
+	assert.Equal(t, "secret", decrypted)
+
+	assert.True(t, UsesMasterKey("config.jwt_secret"))
+	assert.True(t, UsesMasterKey(DataKeyRegistryKey))
+	assert.False(t, UsesMasterKey("config.imagor_secret"))
+}
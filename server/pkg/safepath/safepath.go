@@ -0,0 +1,93 @@
+// Package safepath checks the storage paths clients send, so they cannot
+// escape the storage root or name files a storage backend cannot store.
+// Paths are slash separated and relative to the storage root, a leading
+// slash is allowed.
+package safepath
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// MaxPathBytes is the longest path, the object key limit of S3
+	MaxPathBytes = 1024
+	// MaxNameBytes is the longest file or folder name of common filesystems
+	MaxNameBytes = 255
+)
+
+var (
+	// ErrTraversal is returned for paths with ".." segments
+	ErrTraversal = errors.New("path traversal not allowed")
+	// ErrInvalid is returned for paths storage backends cannot store
+	ErrInvalid = errors.New("invalid path")
+)
+
+// Validate checks a path to read or write: it must be valid UTF-8 of at
+// most MaxPathBytes, without control characters or ".." segments.
+// Backslashes separate segments for the ".." check, as they do on Windows
+// and in some S3 tools. The path is not changed, so files named in any
+// Unicode form stay reachable.
+func Validate(p string) error {
+	if !utf8.ValidString(p) {
+		return fmt.Errorf("%w: not valid UTF-8", ErrInvalid)
+	}
+	if len(p) > MaxPathBytes {
+		return fmt.Errorf("%w: longer than %d bytes", ErrInvalid, MaxPathBytes)
+	}
+	for _, r := range p {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("%w: control character %U", ErrInvalid, r)
+		}
+	}
+	for _, segment := range strings.FieldsFunc(p, isSeparator) {
+		if segment == ".." {
+			return ErrTraversal
+		}
+	}
+	return nil
+}
+
+// Normalize returns the path of a file or folder to create in the form its
+// names are stored, Unicode NFC. On top of Validate, names must be at most
+// MaxNameBytes and must not contain a backslash. Slashes are left for the
+// storage backends to trim.
+func Normalize(p string) (string, error) {
+	if err := Validate(p); err != nil {
+		return "", err
+	}
+	p = norm.NFC.String(p)
+	if len(p) > MaxPathBytes {
+		return "", fmt.Errorf("%w: longer than %d bytes", ErrInvalid, MaxPathBytes)
+	}
+	for _, name := range strings.Split(p, "/") {
+		if len(name) > MaxNameBytes {
+			return "", fmt.Errorf("%w: name longer than %d bytes", ErrInvalid, MaxNameBytes)
+		}
+		if strings.ContainsRune(name, '\\') {
+			return "", fmt.Errorf("%w: name %q contains a backslash", ErrInvalid, name)
+		}
+	}
+	return p, nil
+}
+
+// NormalizeName is Normalize for a single file or folder name, which must
+// not be empty, "." or contain a slash
+func NormalizeName(name string) (string, error) {
+	if name == "" || name == "." {
+		return "", fmt.Errorf("%w: %q is not a name", ErrInvalid, name)
+	}
+	if strings.Contains(name, "/") {
+		return "", fmt.Errorf("%w: name %q contains a slash", ErrInvalid, name)
+	}
+	return Normalize(name)
+}
+
+func isSeparator(r rune) bool {
+	return r == '/' || r == '\\'
+}
@@ -0,0 +1,94 @@
+package safepath
+
+import (
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/unicode/norm"
+)
+
+func TestValidate(t *testing.T) {
+	for _, valid := range []string{"", "/", "photos/a.jpg", "/photos/a.jpg/", "photos/a..b.jpg", "..photos/.hidden", "Café/été.jpg"} {
+		assert.NoError(t, Validate(valid), valid)
+	}
+	for _, traversal := range []string{"..", "../etc/passwd", "photos/../../etc", "photos/..", `photos\..\..\etc`, "/.."} {
+		assert.ErrorIs(t, Validate(traversal), ErrTraversal, traversal)
+	}
+	for _, invalid := range []string{"photos/a\x00.jpg", "photos/a\n.jpg", "a\x7f", "a\u0085", "\xff.jpg", strings.Repeat("a", MaxPathBytes+1)} {
+		assert.ErrorIs(t, Validate(invalid), ErrInvalid, invalid)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := map[string]string{
+		"":                 "",
+		"photos/a.jpg":     "photos/a.jpg",
+		"/photos/a.jpg/":   "/photos/a.jpg/",
+		"Cafe\u0301/a.jpg": "Caf\u00e9/a.jpg",
+		"photos/a..b.jpg":  "photos/a..b.jpg",
+	}
+	for p, want := range tests {
+		normalized, err := Normalize(p)
+		require.NoError(t, err, p)
+		assert.Equal(t, want, normalized, p)
+	}
+
+	_, err := Normalize("photos/../a.jpg")
+	assert.ErrorIs(t, err, ErrTraversal)
+	for _, invalid := range []string{`photos\a.jpg`, strings.Repeat("a", MaxNameBytes+1), "a\tb"} {
+		_, err := Normalize(invalid)
+		assert.ErrorIs(t, err, ErrInvalid, invalid)
+	}
+}
+
+func TestNormalizeName(t *testing.T) {
+	name, err := NormalizeName(" e\u0301.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, " \u00e9.jpg", name)
+
+	for _, invalid := range []string{"", ".", "a/b", "..", `a\b`} {
+		_, err := NormalizeName(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func FuzzValidate(f *testing.F) {
+	for _, seed := range []string{"photos/a.jpg", "../a", `a\..\b`, "a/./b", "\x00", "a..b"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, p string) {
+		if Validate(p) != nil {
+			return
+		}
+		// Valid paths stay below the root wherever they are joined
+		joined := path.Join("/root", strings.ReplaceAll(p, `\`, "/"))
+		if joined != "/root" && !strings.HasPrefix(joined, "/root/") {
+			t.Fatalf("%q escapes the root as %q", p, joined)
+		}
+	})
+}
+
+func FuzzNormalize(f *testing.F) {
+	for _, seed := range []string{"photos/a.jpg", "//a/./b/", "Cafe\u0301", "../a", `a\b`, "a/e\u0301"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, p string) {
+		normalized, err := Normalize(p)
+		if err != nil {
+			return
+		}
+		if !norm.NFC.IsNormalString(normalized) {
+			t.Fatalf("Normalize(%q) = %q is not NFC", p, normalized)
+		}
+		if err := Validate(normalized); err != nil {
+			t.Fatalf("Normalize(%q) = %q is invalid: %v", p, normalized, err)
+		}
+		again, err := Normalize(normalized)
+		if err != nil || again != normalized {
+			t.Fatalf("Normalize(%q) = %q, normalized again %q, %v", p, normalized, again, err)
+		}
+	})
+}
@@ -53,6 +53,11 @@ func New(baseDir string, options ...Option) (*FileStorage, error) {
 	return fs, nil
 }
 
+// BaseDir returns the absolute directory the storage is rooted at
+func (fs *FileStorage) BaseDir() string {
+	return fs.baseDir
+}
+
 func (fs *FileStorage) List(ctx context.Context, path string, options storage.ListOptions) (storage.ListResult, error) {
 	fullPath := filepath.Join(fs.baseDir, path)
 
@@ -63,65 +68,66 @@ func (fs *FileStorage) List(ctx context.Context, path string, options storage.Li
 	}
 
 	var filteredEntries []os.DirEntry
-	var skippedCount int
-
-	// Filter entries and handle individual file access errors gracefully
 	for _, entry := range entries {
-		// Try to check if we should include this file - this may require accessing file info
-		// For basic name-based filtering, this should work even if the file is inaccessible
-		if !storage.ShouldIncludeFile(entry.Name(), entry.IsDir(), options) {
-			continue
+		if storage.ShouldIncludeFile(entry.Name(), entry.IsDir(), options) {
+			filteredEntries = append(filteredEntries, entry)
 		}
-
-		// Try to get basic info to verify the entry is accessible
-		_, err := entry.Info()
-		if err != nil {
-			// Individual file/directory is inaccessible - skip it and log
-			if fs.logger != nil {
-				fs.logger.Debug("Skipping inaccessible entry",
-					zap.String("name", entry.Name()),
-					zap.String("path", fullPath),
-					zap.Error(err))
-			}
-			skippedCount++
-			continue
-		}
-
-		filteredEntries = append(filteredEntries, entry)
 	}
 
-	// Log summary if files were skipped
-	if skippedCount > 0 && fs.logger != nil {
-		fs.logger.Debug("Skipped inaccessible entries during listing",
-			zap.Int("count", skippedCount),
-			zap.String("directory", fullPath))
+	allItems := make([]storage.FileInfo, len(filteredEntries))
+	for i, entry := range filteredEntries {
+		allItems[i] = storage.FileInfo{
+			Name:  entry.Name(),
+			Path:  filepath.Join(path, entry.Name()),
+			IsDir: entry.IsDir(),
+		}
 	}
 
-	totalCount := len(filteredEntries)
-
-	// Convert filtered entries to FileInfo for sorting
-	var allItems []storage.FileInfo
-	for _, entry := range filteredEntries {
-		info, err := entry.Info()
+	if options.NeedsMetadata() {
+		// Each entry costs a stat, run them concurrently
+		infoErrs := make([]error, len(filteredEntries))
+		err := storage.Hydrate(ctx, len(filteredEntries), storage.DefaultHydrateConcurrency, func(ctx context.Context, i int) error {
+			info, err := filteredEntries[i].Info()
+			if err != nil {
+				infoErrs[i] = err
+				return nil
+			}
+			allItems[i].Size = info.Size()
+			allItems[i].ModifiedTime = info.ModTime()
+			return nil
+		})
 		if err != nil {
-			// This should not happen since we already checked above, but handle gracefully
-			if fs.logger != nil {
-				fs.logger.Debug("Skipping entry that became inaccessible",
-					zap.String("name", entry.Name()),
-					zap.Error(err))
+			return storage.ListResult{}, err
+		}
+
+		// Individual files or directories that are inaccessible are skipped
+		accessible := allItems[:0]
+		var skippedCount int
+		for i, item := range allItems {
+			if infoErrs[i] != nil {
+				if fs.logger != nil {
+					fs.logger.Debug("Skipping inaccessible entry",
+						zap.String("name", item.Name),
+						zap.String("path", fullPath),
+						zap.Error(infoErrs[i]))
+				}
+				skippedCount++
+				continue
 			}
-			continue
+			accessible = append(accessible, item)
 		}
+		allItems = accessible
 
-		allItems = append(allItems, storage.FileInfo{
-			Name:         entry.Name(),
-			Path:         filepath.Join(path, entry.Name()),
-			Size:         info.Size(),
-			IsDir:        entry.IsDir(),
-			ModifiedTime: info.ModTime(),
-		})
+		// Log summary if files were skipped
+		if skippedCount > 0 && fs.logger != nil {
+			fs.logger.Debug("Skipped inaccessible entries during listing",
+				zap.Int("count", skippedCount),
+				zap.String("directory", fullPath))
+		}
 	}
 
+	totalCount := len(allItems)
+
 	// Sort the items using common sorting function
 	storage.SortFileInfos(allItems, options.SortBy, options.SortOrder)
 
@@ -168,6 +174,33 @@ func (fs *FileStorage) Put(ctx context.Context, path string, content io.Reader)
 	return err
 }
 
+// PutIfAbsent writes content to a hidden temporary file next to path and
+// hard links it into place, so path appears complete and only when free
+func (fs *FileStorage) PutIfAbsent(ctx context.Context, path string, content io.Reader) error {
+	fullPath := filepath.Join(fs.baseDir, path)
+	dir := filepath.Dir(fullPath)
+	if err := os.MkdirAll(dir, fs.mkdirPermission); err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(dir, "."+filepath.Base(fullPath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err := io.Copy(file, content); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(file.Name(), fs.writePermission); err != nil {
+		return err
+	}
+	return os.Link(file.Name(), fullPath)
+}
+
 func (fs *FileStorage) Delete(ctx context.Context, path string) error {
 	fullPath := filepath.Join(fs.baseDir, path)
 	fileInfo, err := os.Stat(fullPath)
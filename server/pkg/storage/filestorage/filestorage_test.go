@@ -44,6 +44,25 @@ func TestFileStorage_Put(t *testing.T) {
 	assert.Equal(t, content, string(data))
 }
 
+func TestFileStorage_PutIfAbsent(t *testing.T) {
+	fs, tempDir := setupTestFileStorage(t)
+	defer os.RemoveAll(tempDir)
+	ctx := context.Background()
+
+	require.NoError(t, fs.PutIfAbsent(ctx, "folder/test.txt", bytes.NewReader([]byte("first"))))
+	err := fs.PutIfAbsent(ctx, "folder/test.txt", bytes.NewReader([]byte("second")))
+	assert.ErrorIs(t, err, storage.ErrExists)
+
+	data, err := os.ReadFile(filepath.Join(tempDir, "folder", "test.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "first", string(data))
+
+	// No temporary files are left behind
+	entries, err := os.ReadDir(filepath.Join(tempDir, "folder"))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
 func TestFileStorage_Get(t *testing.T) {
 	fs, tempDir := setupTestFileStorage(t)
 	defer os.RemoveAll(tempDir)
@@ -128,6 +147,30 @@ func TestFileStorage_List(t *testing.T) {
 	assert.Len(t, foldersResult.Items, len(folders))
 }
 
+func TestFileStorage_ListSkipMetadata(t *testing.T) {
+	fs, tempDir := setupTestFileStorage(t)
+	defer os.RemoveAll(tempDir)
+	ctx := context.Background()
+
+	require.NoError(t, fs.Put(ctx, "file1.txt", bytes.NewReader([]byte("content"))))
+	require.NoError(t, fs.CreateFolder(ctx, "folder1"))
+
+	result, err := fs.List(ctx, "", storage.ListOptions{SkipMetadata: true, SortBy: storage.SortByName})
+	require.NoError(t, err)
+	require.Len(t, result.Items, 2)
+	assert.Equal(t, "file1.txt", result.Items[0].Name)
+	assert.Zero(t, result.Items[0].Size)
+	assert.True(t, result.Items[0].ModifiedTime.IsZero())
+	assert.True(t, result.Items[1].IsDir)
+
+	// Sorting by size needs the sizes anyway
+	result, err = fs.List(ctx, "", storage.ListOptions{SkipMetadata: true, SortBy: storage.SortBySize, OnlyFiles: true})
+	require.NoError(t, err)
+	require.Len(t, result.Items, 1)
+	assert.Equal(t, int64(len("content")), result.Items[0].Size)
+	assert.False(t, result.Items[0].ModifiedTime.IsZero())
+}
+
 func TestFileStorage_CreateFolder(t *testing.T) {
 	fs, tempDir := setupTestFileStorage(t)
 	defer os.RemoveAll(tempDir)
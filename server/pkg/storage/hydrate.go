@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"context"
+	"sync"
+)
+
+// DefaultHydrateConcurrency bounds the lookups Hydrate runs at once
+const DefaultHydrateConcurrency = 16
+
+// Hydrate calls fn with the index of each of n listed entries, running at
+// most concurrency calls at once, for backends looking up the metadata of
+// entries one by one. The first error returned by fn, or the cancellation of
+// ctx, stops the calls not started yet and is returned.
+func Hydrate(ctx context.Context, n int, concurrency int, fn func(ctx context.Context, i int) error) error {
+	if n == 0 {
+		return ctx.Err()
+	}
+	if concurrency <= 0 {
+		concurrency = DefaultHydrateConcurrency
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg      sync.WaitGroup
+		errOnce sync.Once
+		err     error
+	)
+	fail := func(e error) {
+		errOnce.Do(func() {
+			err = e
+			cancel()
+		})
+	}
+	next := make(chan int)
+	for range min(concurrency, n) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				if ctx.Err() != nil {
+					continue
+				}
+				if e := fn(ctx, i); e != nil {
+					fail(e)
+				}
+			}
+		}()
+	}
+feed:
+	for i := range n {
+		select {
+		case next <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(next)
+	wg.Wait()
+	if err != nil {
+		return err
+	}
+	return ctx.Err()
+}
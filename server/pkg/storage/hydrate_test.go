@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHydrate_BoundsConcurrency(t *testing.T) {
+	items := make([]FileInfo, 100)
+	var running, peak atomic.Int32
+	err := Hydrate(context.Background(), len(items), 4, func(ctx context.Context, i int) error {
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		items[i].Size = int64(i)
+		running.Add(-1)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.LessOrEqual(t, peak.Load(), int32(4))
+	for i, item := range items {
+		assert.Equal(t, int64(i), item.Size)
+	}
+}
+
+func TestHydrate_StopsOnError(t *testing.T) {
+	fail := errors.New("lookup failed")
+	var calls atomic.Int32
+	err := Hydrate(context.Background(), 1000, 1, func(ctx context.Context, i int) error {
+		calls.Add(1)
+		if i == 2 {
+			return fail
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, fail)
+	assert.Less(t, calls.Load(), int32(1000))
+}
+
+func TestHydrate_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var calls atomic.Int32
+	err := Hydrate(ctx, 1000, 4, func(ctx context.Context, i int) error {
+		calls.Add(1)
+		return nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, calls.Load(), int32(1000))
+}
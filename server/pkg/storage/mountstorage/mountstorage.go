@@ -0,0 +1,266 @@
+package mountstorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+)
+
+// ErrMountRoot is returned when deleting, moving or overwriting a mount
+// root, mounts are added and removed through configuration only
+var ErrMountRoot = fmt.Errorf("storage mount root cannot be modified: %w", os.ErrPermission)
+
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,62}$`)
+
+// ValidName reports whether name can be used as a mount name: lower case
+// letters, digits, dots, dashes and underscores, starting with a letter or digit
+func ValidName(name string) bool {
+	return namePattern.MatchString(name)
+}
+
+// MountStorage combines a root storage with named mounts. A path whose first
+// segment is a mount name is routed to that mount with the segment stripped,
+// everything else goes to the root storage. Mounts are listed as folders at
+// the top level and shadow root folders of the same name.
+type MountStorage struct {
+	root   storage.Storage
+	mounts map[string]storage.Storage
+	names  []string
+}
+
+// New returns a storage serving root with mounts overlaid at the top level
+func New(root storage.Storage, mounts map[string]storage.Storage) *MountStorage {
+	m := &MountStorage{root: root, mounts: make(map[string]storage.Storage, len(mounts))}
+	for name, s := range mounts {
+		m.mounts[name] = s
+		m.names = append(m.names, name)
+	}
+	sort.Strings(m.names)
+	return m
+}
+
+// Mounts returns the mount names in order
+func (m *MountStorage) Mounts() []string {
+	return append([]string(nil), m.names...)
+}
+
+// Mount returns the storage mounted at name
+func (m *MountStorage) Mount(name string) (storage.Storage, bool) {
+	s, ok := m.mounts[name]
+	return s, ok
+}
+
+// route returns the storage serving key, the key within it and the mount
+// name, empty for the root storage
+func (m *MountStorage) route(key string) (storage.Storage, string, string) {
+	key = strings.Trim(key, "/")
+	name, rest, _ := strings.Cut(key, "/")
+	if s, ok := m.mounts[name]; ok {
+		return s, rest, name
+	}
+	return m.root, key, ""
+}
+
+// isMountRoot reports whether key is the root of a mount
+func (m *MountStorage) isMountRoot(key string) bool {
+	_, ok := m.mounts[strings.Trim(key, "/")]
+	return ok
+}
+
+func prefixPath(mount, key string) string {
+	if mount == "" {
+		return key
+	}
+	return path.Join(mount, key)
+}
+
+func (m *MountStorage) List(ctx context.Context, key string, options storage.ListOptions) (storage.ListResult, error) {
+	if strings.Trim(key, "/") == "" {
+		return m.listTop(ctx, key, options)
+	}
+	s, sub, mount := m.route(key)
+	result, err := s.List(ctx, sub, options)
+	if err != nil {
+		return storage.ListResult{}, err
+	}
+	if mount != "" {
+		for i := range result.Items {
+			result.Items[i].Path = prefixPath(mount, result.Items[i].Path)
+		}
+	}
+	return result, nil
+}
+
+// listTop merges the root listing with the mount folders, paginating the
+// merged result
+func (m *MountStorage) listTop(ctx context.Context, key string, options storage.ListOptions) (storage.ListResult, error) {
+	rootOptions := options
+	rootOptions.Offset = 0
+	rootOptions.Limit = 0
+	result, err := m.root.List(ctx, key, rootOptions)
+	if err != nil {
+		return storage.ListResult{}, err
+	}
+
+	items := make([]storage.FileInfo, 0, len(result.Items)+len(m.names))
+	for _, item := range result.Items {
+		if _, shadowed := m.mounts[item.Name]; !shadowed {
+			items = append(items, item)
+		}
+	}
+	for _, name := range m.names {
+		if storage.ShouldIncludeFile(name, true, options) {
+			items = append(items, storage.FileInfo{Name: name, Path: name, IsDir: true})
+		}
+	}
+	storage.SortFileInfos(items, options.SortBy, options.SortOrder)
+
+	start := min(options.Offset, len(items))
+	end := len(items)
+	if options.Limit > 0 {
+		end = min(start+options.Limit, len(items))
+	}
+	return storage.ListResult{Items: items[start:end], TotalCount: len(items)}, nil
+}
+
+func (m *MountStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	s, sub, _ := m.route(key)
+	return s.Get(ctx, sub)
+}
+
+func (m *MountStorage) Put(ctx context.Context, key string, content io.Reader) error {
+	if m.isMountRoot(key) {
+		return ErrMountRoot
+	}
+	s, sub, _ := m.route(key)
+	return s.Put(ctx, sub, content)
+}
+
+// PutIfAbsent writes content to key unless it exists, see
+// storage.ExclusiveStorage
+func (m *MountStorage) PutIfAbsent(ctx context.Context, key string, content io.Reader) error {
+	if m.isMountRoot(key) {
+		return ErrMountRoot
+	}
+	s, sub, _ := m.route(key)
+	exclusive, ok := s.(storage.ExclusiveStorage)
+	if !ok {
+		return fmt.Errorf("storage of %s cannot write exclusively: %w", key, errors.ErrUnsupported)
+	}
+	return exclusive.PutIfAbsent(ctx, sub, content)
+}
+
+func (m *MountStorage) Delete(ctx context.Context, key string) error {
+	if m.isMountRoot(key) {
+		return ErrMountRoot
+	}
+	s, sub, _ := m.route(key)
+	return s.Delete(ctx, sub)
+}
+
+func (m *MountStorage) CreateFolder(ctx context.Context, folder string) error {
+	if m.isMountRoot(folder) {
+		return nil
+	}
+	s, sub, _ := m.route(folder)
+	return s.CreateFolder(ctx, sub)
+}
+
+func (m *MountStorage) Stat(ctx context.Context, key string) (storage.FileInfo, error) {
+	if name := strings.Trim(key, "/"); m.isMountRoot(name) {
+		return storage.FileInfo{Name: name, Path: name, IsDir: true}, nil
+	}
+	s, sub, mount := m.route(key)
+	info, err := s.Stat(ctx, sub)
+	if err != nil {
+		return storage.FileInfo{}, err
+	}
+	info.Path = prefixPath(mount, info.Path)
+	return info, nil
+}
+
+func (m *MountStorage) Copy(ctx context.Context, sourcePath string, destPath string) error {
+	if m.isMountRoot(sourcePath) || m.isMountRoot(destPath) {
+		return ErrMountRoot
+	}
+	source, sourceSub, sourceMount := m.route(sourcePath)
+	dest, destSub, destMount := m.route(destPath)
+	if sourceMount == destMount {
+		return source.Copy(ctx, sourceSub, destSub)
+	}
+	return copyAcross(ctx, source, sourceSub, dest, destSub)
+}
+
+func (m *MountStorage) Move(ctx context.Context, sourcePath string, destPath string) error {
+	if m.isMountRoot(sourcePath) || m.isMountRoot(destPath) {
+		return ErrMountRoot
+	}
+	source, sourceSub, sourceMount := m.route(sourcePath)
+	dest, destSub, destMount := m.route(destPath)
+	if sourceMount == destMount {
+		return source.Move(ctx, sourceSub, destSub)
+	}
+	// Across backends a move is a copy followed by a delete
+	if err := copyAcross(ctx, source, sourceSub, dest, destSub); err != nil {
+		return err
+	}
+	return source.Delete(ctx, sourceSub)
+}
+
+// copyAcross copies sourcePath of source to destPath of dest, recursively
+// for folders, streaming every file through the server
+func copyAcross(ctx context.Context, source storage.Storage, sourcePath string, dest storage.Storage, destPath string) error {
+	info, err := source.Stat(ctx, sourcePath)
+	if err != nil {
+		return err
+	}
+	if _, err := dest.Stat(ctx, destPath); err == nil {
+		return os.ErrExist
+	}
+	if !info.IsDir {
+		return copyFile(ctx, source, sourcePath, dest, destPath)
+	}
+	return copyDir(ctx, source, sourcePath, dest, destPath)
+}
+
+func copyFile(ctx context.Context, source storage.Storage, sourcePath string, dest storage.Storage, destPath string) error {
+	reader, err := source.Get(ctx, sourcePath)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	return dest.Put(ctx, destPath, reader)
+}
+
+func copyDir(ctx context.Context, source storage.Storage, sourcePath string, dest storage.Storage, destPath string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := dest.CreateFolder(ctx, destPath); err != nil {
+		return err
+	}
+	result, err := source.List(ctx, sourcePath, storage.ListOptions{ShowHidden: true})
+	if err != nil {
+		return err
+	}
+	for _, item := range result.Items {
+		destItemPath := path.Join(destPath, item.Name)
+		if item.IsDir {
+			err = copyDir(ctx, source, item.Path, dest, destItemPath)
+		} else {
+			err = copyFile(ctx, source, item.Path, dest, destItemPath)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
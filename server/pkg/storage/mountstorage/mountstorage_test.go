@@ -0,0 +1,152 @@
+package mountstorage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/cshum/imagor-studio/server/pkg/storage/noopstorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFileStorage(t *testing.T) (storage.Storage, string) {
+	dir := t.TempDir()
+	s, err := filestorage.New(dir)
+	require.NoError(t, err)
+	return s, dir
+}
+
+func writeFile(t *testing.T, dir, name, content string) {
+	full := filepath.Join(dir, name)
+	require.NoError(t, os.MkdirAll(filepath.Dir(full), 0755))
+	require.NoError(t, os.WriteFile(full, []byte(content), 0644))
+}
+
+func TestValidName(t *testing.T) {
+	assert.True(t, ValidName("nas"))
+	assert.True(t, ValidName("s3-archive"))
+	assert.True(t, ValidName("photos_2024.v2"))
+	assert.False(t, ValidName(""))
+	assert.False(t, ValidName("NAS"))
+	assert.False(t, ValidName("-nas"))
+	assert.False(t, ValidName("a/b"))
+	assert.False(t, ValidName(".hidden"))
+}
+
+func TestMountStorage_ListRoutesByPrefix(t *testing.T) {
+	root, rootDir := newFileStorage(t)
+	nas, nasDir := newFileStorage(t)
+	writeFile(t, rootDir, "local.jpg", "l")
+	writeFile(t, rootDir, "nas/shadowed.jpg", "s")
+	writeFile(t, nasDir, "album/a.jpg", "a")
+	m := New(root, map[string]storage.Storage{"nas": nas})
+	ctx := context.Background()
+
+	result, err := m.List(ctx, "", storage.ListOptions{SortBy: storage.SortByName, SortOrder: storage.SortOrderAsc})
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.TotalCount)
+	require.Len(t, result.Items, 2)
+	assert.Equal(t, "local.jpg", result.Items[0].Path)
+	assert.Equal(t, "nas", result.Items[1].Path)
+	assert.True(t, result.Items[1].IsDir)
+
+	result, err = m.List(ctx, "", storage.ListOptions{OnlyFiles: true})
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.TotalCount)
+
+	result, err = m.List(ctx, "", storage.ListOptions{SortBy: storage.SortByName, SortOrder: storage.SortOrderAsc, Offset: 1, Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.TotalCount)
+	require.Len(t, result.Items, 1)
+	assert.Equal(t, "nas", result.Items[0].Name)
+
+	result, err = m.List(ctx, "/nas/album", storage.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, result.Items, 1)
+	assert.Equal(t, "nas/album/a.jpg", result.Items[0].Path)
+
+	info, err := m.Stat(ctx, "nas/album/a.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, "nas/album/a.jpg", info.Path)
+	info, err = m.Stat(ctx, "nas")
+	require.NoError(t, err)
+	assert.True(t, info.IsDir)
+
+	reader, err := m.Get(ctx, "nas/album/a.jpg")
+	require.NoError(t, err)
+	content, _ := io.ReadAll(reader)
+	reader.Close()
+	assert.Equal(t, "a", string(content))
+}
+
+func TestMountStorage_Writes(t *testing.T) {
+	root, rootDir := newFileStorage(t)
+	nas, nasDir := newFileStorage(t)
+	m := New(root, map[string]storage.Storage{"nas": nas})
+	ctx := context.Background()
+
+	require.NoError(t, m.Put(ctx, "nas/new.jpg", strings.NewReader("n")))
+	assert.FileExists(t, filepath.Join(nasDir, "new.jpg"))
+	require.NoError(t, m.CreateFolder(ctx, "nas/folder"))
+	assert.DirExists(t, filepath.Join(nasDir, "folder"))
+	require.NoError(t, m.CreateFolder(ctx, "nas"))
+
+	// Mount roots are managed by configuration
+	assert.ErrorIs(t, m.Delete(ctx, "nas"), ErrMountRoot)
+	assert.ErrorIs(t, m.Delete(ctx, "/nas/"), os.ErrPermission)
+	assert.ErrorIs(t, m.Move(ctx, "nas", "elsewhere"), ErrMountRoot)
+	assert.ErrorIs(t, m.Copy(ctx, "local", "nas"), ErrMountRoot)
+	assert.ErrorIs(t, m.Put(ctx, "nas", strings.NewReader("x")), ErrMountRoot)
+
+	require.NoError(t, m.Delete(ctx, "nas/new.jpg"))
+	assert.NoFileExists(t, filepath.Join(nasDir, "new.jpg"))
+	require.NoError(t, m.Put(ctx, "local.jpg", strings.NewReader("l")))
+	assert.FileExists(t, filepath.Join(rootDir, "local.jpg"))
+}
+
+func TestMountStorage_PutIfAbsent(t *testing.T) {
+	root, rootDir := newFileStorage(t)
+	nas, nasDir := newFileStorage(t)
+	m := New(root, map[string]storage.Storage{"nas": nas, "noop": noopstorage.New()})
+	ctx := context.Background()
+
+	require.NoError(t, m.PutIfAbsent(ctx, "nas/new.jpg", strings.NewReader("n")))
+	assert.FileExists(t, filepath.Join(nasDir, "new.jpg"))
+	assert.ErrorIs(t, m.PutIfAbsent(ctx, "nas/new.jpg", strings.NewReader("x")), storage.ErrExists)
+	require.NoError(t, m.PutIfAbsent(ctx, "local.jpg", strings.NewReader("l")))
+	assert.FileExists(t, filepath.Join(rootDir, "local.jpg"))
+
+	assert.ErrorIs(t, m.PutIfAbsent(ctx, "nas", strings.NewReader("x")), ErrMountRoot)
+	assert.ErrorIs(t, m.PutIfAbsent(ctx, "noop/new.jpg", strings.NewReader("x")), errors.ErrUnsupported)
+}
+
+func TestMountStorage_CopyMoveAcrossMounts(t *testing.T) {
+	root, rootDir := newFileStorage(t)
+	nas, nasDir := newFileStorage(t)
+	writeFile(t, rootDir, "album/a.jpg", "a")
+	writeFile(t, rootDir, "album/nested/b.jpg", "b")
+	writeFile(t, rootDir, "album/.hidden", "h")
+	m := New(root, map[string]storage.Storage{"nas": nas})
+	ctx := context.Background()
+
+	require.NoError(t, m.Copy(ctx, "album", "nas/backup"))
+	for _, name := range []string{"backup/a.jpg", "backup/nested/b.jpg", "backup/.hidden"} {
+		assert.FileExists(t, filepath.Join(nasDir, name))
+	}
+	assert.ErrorIs(t, m.Copy(ctx, "album", "nas/backup"), os.ErrExist)
+
+	require.NoError(t, m.Move(ctx, "album/a.jpg", "nas/a.jpg"))
+	assert.FileExists(t, filepath.Join(nasDir, "a.jpg"))
+	assert.NoFileExists(t, filepath.Join(rootDir, "album/a.jpg"))
+
+	// Within one mount the backend moves natively
+	require.NoError(t, m.Move(ctx, "nas/a.jpg", "nas/backup/moved.jpg"))
+	assert.FileExists(t, filepath.Join(nasDir, "backup/moved.jpg"))
+}
@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// nopSeekCloser hides the Seek method of a reader
+type nopSeekCloser struct{ io.Reader }
+
+func (nopSeekCloser) Close() error { return nil }
+
+func TestLazyReadSeeker_SkipsWithoutSeeker(t *testing.T) {
+	opens := 0
+	l := NewLazyReadSeeker(context.Background(), func(ctx context.Context) (io.ReadCloser, error) {
+		opens++
+		return nopSeekCloser{strings.NewReader("0123456789")}, nil
+	}, 10)
+	defer l.Close()
+
+	// Size probes don't open the file
+	end, err := l.Seek(0, io.SeekEnd)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), end)
+	assert.Equal(t, 0, opens)
+
+	_, err = l.Seek(6, io.SeekStart)
+	require.NoError(t, err)
+	buf := make([]byte, 2)
+	_, err = io.ReadFull(l, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "67", string(buf))
+
+	// Seeking backwards reopens the reader
+	_, err = l.Seek(1, io.SeekStart)
+	require.NoError(t, err)
+	_, err = io.ReadFull(l, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "12", string(buf))
+	assert.Equal(t, 2, opens)
+
+	rest, err := io.ReadAll(l)
+	require.NoError(t, err)
+	assert.Equal(t, "3456789", string(rest))
+}
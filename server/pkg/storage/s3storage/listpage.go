@@ -0,0 +1,267 @@
+package s3storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+)
+
+// listPageSize is the MaxKeys of paged listings, the S3 maximum. Cursors
+// resume within a page, so every request of a listing uses the same size.
+var listPageSize int32 = 1000
+
+// countWorkers bounds the concurrent listings of CountFiles
+const countWorkers = 8
+
+var errCountLimit = errors.New("count limit reached")
+
+// listPrefix is the object prefix listing the folder key
+func (s *S3Storage) listPrefix(key string) string {
+	prefix := s.fullPath(key)
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return prefix
+}
+
+// listEntry is a folder prefix or an object of a listing page
+type listEntry struct {
+	key    string
+	folder bool
+	object types.Object
+}
+
+// pageEntries merges the folders and objects of a page in key order
+func pageEntries(page *s3.ListObjectsV2Output) []listEntry {
+	entries := make([]listEntry, 0, len(page.CommonPrefixes)+len(page.Contents))
+	for _, prefix := range page.CommonPrefixes {
+		entries = append(entries, listEntry{key: aws.ToString(prefix.Prefix), folder: true})
+	}
+	for _, object := range page.Contents {
+		entries = append(entries, listEntry{key: aws.ToString(object.Key), object: object})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+	return entries
+}
+
+// fileInfo converts an entry as List does, false for folder placeholders
+func (s *S3Storage) fileInfo(e listEntry) (storage.FileInfo, bool) {
+	relativePath := s.relativePath(e.key)
+	if e.folder {
+		return storage.FileInfo{
+			Name:  path.Base(strings.TrimSuffix(relativePath, "/")),
+			Path:  relativePath,
+			IsDir: true,
+		}, true
+	}
+	if strings.HasSuffix(e.key, folderSuffix) {
+		return storage.FileInfo{}, false
+	}
+	return storage.FileInfo{
+		Name:         path.Base(relativePath),
+		Path:         relativePath,
+		Size:         aws.ToInt64(e.object.Size),
+		ModifiedTime: aws.ToTime(e.object.LastModified),
+		ETag:         strings.Trim(aws.ToString(e.object.ETag), "\""),
+		StorageClass: string(e.object.StorageClass),
+	}, true
+}
+
+// ListPage lists a page of key with ListObjectsV2 continuation tokens. The
+// cursor is the continuation token of the S3 page to resume from, and the
+// entries of that page already returned.
+func (s *S3Storage) ListPage(ctx context.Context, key string, options storage.ListOptions, cursor string) (storage.ListPage, error) {
+	page, err := s.listPage(ctx, key, options, cursor)
+	if err != nil {
+		return storage.ListPage{}, err
+	}
+	if err := s.hydrateFolders(ctx, page.Items, options); err != nil {
+		return storage.ListPage{}, err
+	}
+	return page, nil
+}
+
+func (s *S3Storage) listPage(ctx context.Context, key string, options storage.ListOptions, cursor string) (storage.ListPage, error) {
+	skip, token, err := parseCursor(cursor)
+	if err != nil {
+		return storage.ListPage{}, err
+	}
+	input := &s3.ListObjectsV2Input{
+		Bucket:  aws.String(s.bucket),
+		Prefix:  aws.String(s.listPrefix(key)),
+		MaxKeys: aws.Int32(listPageSize),
+	}
+	if !options.OnlyFiles {
+		input.Delimiter = aws.String("/")
+	}
+	var items []storage.FileInfo
+	for {
+		if token != "" {
+			input.ContinuationToken = aws.String(token)
+		}
+		page, err := s.client.ListObjectsV2(ctx, input)
+		if err != nil {
+			return storage.ListPage{}, err
+		}
+		entries := pageEntries(page)
+		next := aws.ToString(page.NextContinuationToken)
+		truncated := aws.ToBool(page.IsTruncated) && next != ""
+		if skip > len(entries) {
+			return storage.ListPage{}, storage.ErrInvalidCursor
+		}
+		for i := skip; i < len(entries); i++ {
+			info, ok := s.fileInfo(entries[i])
+			if !ok || !storage.ShouldIncludeFile(info.Name, info.IsDir, options) {
+				continue
+			}
+			items = append(items, info)
+			if options.Limit <= 0 || len(items) < options.Limit {
+				continue
+			}
+			switch {
+			case i+1 < len(entries):
+				return storage.ListPage{Items: items, Cursor: formatCursor(i+1, token)}, nil
+			case truncated:
+				return storage.ListPage{Items: items, Cursor: formatCursor(0, next)}, nil
+			default:
+				return storage.ListPage{Items: items}, nil
+			}
+		}
+		if !truncated {
+			return storage.ListPage{Items: items}, nil
+		}
+		token, skip = next, 0
+	}
+}
+
+func formatCursor(skip int, token string) string {
+	return strconv.Itoa(skip) + ":" + token
+}
+
+func parseCursor(cursor string) (int, string, error) {
+	if cursor == "" {
+		return 0, "", nil
+	}
+	skipValue, token, ok := strings.Cut(cursor, ":")
+	skip, err := strconv.Atoi(skipValue)
+	if !ok || err != nil || skip < 0 {
+		return 0, "", storage.ErrInvalidCursor
+	}
+	return skip, token, nil
+}
+
+// CountFiles counts the objects below key. The top level is listed first,
+// then the folders found are counted concurrently with flat listings, so a
+// folder of many subfolders is not listed one request after another.
+func (s *S3Storage) CountFiles(ctx context.Context, key string, limit int) (int, bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var count atomic.Int64
+	add := func(n int) error {
+		if limit > 0 && count.Add(int64(n)) > int64(limit) {
+			return errCountLimit
+		}
+		return nil
+	}
+
+	var folders []string
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket:    aws.String(s.bucket),
+		Prefix:    aws.String(s.listPrefix(key)),
+		Delimiter: aws.String("/"),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return 0, false, err
+		}
+		for _, prefix := range page.CommonPrefixes {
+			folders = append(folders, aws.ToString(prefix.Prefix))
+		}
+		if err := add(countObjects(page.Contents)); err != nil {
+			return limit, true, nil
+		}
+	}
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	queue := make(chan string)
+	for range min(countWorkers, len(folders)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for prefix := range queue {
+				if err := s.countPrefix(ctx, prefix, add); err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+feed:
+	for _, prefix := range folders {
+		select {
+		case queue <- prefix:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	if errors.Is(firstErr, errCountLimit) {
+		return limit, true, nil
+	}
+	if firstErr != nil {
+		return 0, false, fmt.Errorf("failed to count files: %w", firstErr)
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, false, err
+	}
+	return int(count.Load()), false, nil
+}
+
+// countPrefix counts every object below prefix with a flat listing
+func (s *S3Storage) countPrefix(ctx context.Context, prefix string, add func(int) error) error {
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		if err := add(countObjects(page.Contents)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// countObjects counts the objects of a page that are files
+func countObjects(objects []types.Object) int {
+	n := 0
+	for _, object := range objects {
+		if !strings.HasSuffix(aws.ToString(object.Key), folderSuffix) {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,79 @@
+package s3storage
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestS3Storage_ListPage(t *testing.T) {
+	s3Storage := setupFakeS3(t)
+	ctx := context.Background()
+	defer func(size int32) { listPageSize = size }(listPageSize)
+	listPageSize = 3
+
+	for _, key := range []string{"album/a.jpg", "album/b.txt", "album/c.jpg", "album/d.jpg", "album/e.jpg", "album/sub/f.jpg", "album/.hidden.jpg"} {
+		require.NoError(t, s3Storage.Put(ctx, key, bytes.NewReader([]byte("content"))))
+	}
+	require.NoError(t, s3Storage.CreateFolder(ctx, "album/empty"))
+
+	listAll := func(options storage.ListOptions) ([]string, int) {
+		var paths []string
+		cursor, pages := "", 0
+		for {
+			page, err := s3Storage.ListPage(ctx, "album", options, cursor)
+			require.NoError(t, err)
+			pages++
+			for _, item := range page.Items {
+				paths = append(paths, item.Path)
+			}
+			if page.Cursor == "" {
+				return paths, pages
+			}
+			cursor = page.Cursor
+		}
+	}
+
+	paths, pages := listAll(storage.ListOptions{Limit: 2})
+	assert.Equal(t, []string{"album/a.jpg", "album/b.txt", "album/c.jpg", "album/d.jpg", "album/e.jpg", "album/empty/", "album/sub/"}, paths)
+	assert.Equal(t, 4, pages)
+
+	paths, _ = listAll(storage.ListOptions{Limit: 2, Extensions: []string{".jpg"}, OnlyFiles: true})
+	assert.Equal(t, []string{"album/a.jpg", "album/c.jpg", "album/d.jpg", "album/e.jpg", "album/sub/f.jpg"}, paths)
+
+	paths, pages = listAll(storage.ListOptions{ShowHidden: true})
+	assert.Len(t, paths, 8)
+	assert.Equal(t, 1, pages)
+
+	_, err := s3Storage.ListPage(ctx, "album", storage.ListOptions{}, "not-a-cursor")
+	assert.ErrorIs(t, err, storage.ErrInvalidCursor)
+}
+
+func TestS3Storage_CountFiles(t *testing.T) {
+	s3Storage := setupFakeS3(t)
+	ctx := context.Background()
+
+	for _, key := range []string{"count/a.txt", "count/one/b.txt", "count/one/deep/c.txt", "count/two/d.txt", "count/three/e.txt", "other/f.txt"} {
+		require.NoError(t, s3Storage.Put(ctx, key, bytes.NewReader([]byte("content"))))
+	}
+	require.NoError(t, s3Storage.CreateFolder(ctx, "count/empty"))
+
+	count, more, err := s3Storage.CountFiles(ctx, "count", 100)
+	require.NoError(t, err)
+	assert.Equal(t, 5, count)
+	assert.False(t, more)
+
+	count, more, err = s3Storage.CountFiles(ctx, "count", 3)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+	assert.True(t, more)
+
+	count, more, err = s3Storage.CountFiles(ctx, "missing", 100)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+	assert.False(t, more)
+}
@@ -2,7 +2,10 @@ package s3storage
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"os"
 	"path"
@@ -237,6 +240,7 @@ func (s *S3Storage) List(ctx context.Context, key string, options storage.ListOp
 						IsDir:        false,
 						ModifiedTime: *object.LastModified,
 						ETag:         strings.Trim(*object.ETag, "\""),
+						StorageClass: string(object.StorageClass),
 					})
 				}
 				currentOffset++
@@ -250,6 +254,9 @@ func (s *S3Storage) List(ctx context.Context, key string, options storage.ListOp
 		}
 	}
 
+	if err := s.hydrateFolders(ctx, items, options); err != nil {
+		return storage.ListResult{}, err
+	}
 	storage.SortFileInfos(items, options.SortBy, options.SortOrder)
 
 	return storage.ListResult{
@@ -258,13 +265,74 @@ func (s *S3Storage) List(ctx context.Context, key string, options storage.ListOp
 	}, nil
 }
 
+// hydrateFolders sets the modified time of listed folders to the time their
+// placeholder object was created, looking placeholders up concurrently.
+// Folders without one, created implicitly by their files, are left as is.
+func (s *S3Storage) hydrateFolders(ctx context.Context, items []storage.FileInfo, options storage.ListOptions) error {
+	if !options.NeedsMetadata() || options.OnlyFiles {
+		return nil
+	}
+	return storage.Hydrate(ctx, len(items), storage.DefaultHydrateConcurrency, func(ctx context.Context, i int) error {
+		item := &items[i]
+		if !item.IsDir {
+			return nil
+		}
+		result, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(s.bucket),
+			Key:    aws.String(s.fullPath(strings.TrimSuffix(item.Path, "/")) + folderSuffix),
+		})
+		if err != nil {
+			// Missing or unreadable placeholders leave the folder undated
+			return ctx.Err()
+		}
+		item.ModifiedTime = aws.ToTime(result.LastModified)
+		return nil
+	})
+}
+
+// Walk calls fn for every object below key, paging through a flat listing
+// instead of listing folder by folder
+func (s *S3Storage) Walk(ctx context.Context, key string, fn func(storage.FileInfo) error) error {
+	prefix := s.fullPath(key)
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, object := range page.Contents {
+			if strings.HasSuffix(*object.Key, folderSuffix) {
+				continue // Skip directory placeholders
+			}
+			relativePath := s.relativePath(*object.Key)
+			if err := fn(storage.FileInfo{
+				Name:         path.Base(relativePath),
+				Path:         relativePath,
+				Size:         *object.Size,
+				ModifiedTime: *object.LastModified,
+				ETag:         strings.Trim(*object.ETag, "\""),
+				StorageClass: string(object.StorageClass),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func (s *S3Storage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
 	result, err := s.client.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(s.bucket),
 		Key:    aws.String(s.fullPath(key)),
 	})
 	if err != nil {
-		return nil, err
+		return nil, notFound(err)
 	}
 	return result.Body, nil
 }
@@ -278,6 +346,25 @@ func (s *S3Storage) Put(ctx context.Context, key string, content io.Reader) erro
 	return err
 }
 
+// PutIfAbsent writes content to key with an If-None-Match precondition, which
+// S3 fails with 412 for existing keys and 409 for a conflicting write in
+// progress
+func (s *S3Storage) PutIfAbsent(ctx context.Context, key string, content io.Reader) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(s.fullPath(key)),
+		Body:        content,
+		IfNoneMatch: aws.String("*"),
+	})
+	var resp *awshttp.ResponseError
+	if errors.As(err, &resp) {
+		if code := resp.HTTPStatusCode(); code == http.StatusPreconditionFailed || code == http.StatusConflict {
+			return fmt.Errorf("%w: %w", storage.ErrExists, err)
+		}
+	}
+	return err
+}
+
 func (s *S3Storage) PresignedPutURL(ctx context.Context, key string, contentType string, sizeBytes int64, ttl time.Duration) (string, error) {
 	presignClient := s3.NewPresignClient(s.client)
 	input := &s3.PutObjectInput{
@@ -313,6 +400,71 @@ func (s *S3Storage) PresignedPutURLNoOverwrite(ctx context.Context, key string,
 	return req.URL, nil
 }
 
+func (s *S3Storage) PresignedGetURL(ctx context.Context, key string, filename string, ttl time.Duration) (string, error) {
+	presignClient := s3.NewPresignClient(s.client)
+	req, err := presignClient.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket:                     aws.String(s.bucket),
+		Key:                        aws.String(s.fullPath(key)),
+		ResponseContentDisposition: aws.String(mime.FormatMediaType("attachment", map[string]string{"filename": filename})),
+	}, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return "", err
+	}
+	return req.URL, nil
+}
+
+func (s *S3Storage) CreateMultipartUpload(ctx context.Context, key string, contentType string) (string, error) {
+	result, err := s.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(s.fullPath(key)),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(result.UploadId), nil
+}
+
+func (s *S3Storage) PresignedUploadPartURL(ctx context.Context, key string, uploadID string, partNumber int, ttl time.Duration) (string, error) {
+	presignClient := s3.NewPresignClient(s.client)
+	req, err := presignClient.PresignUploadPart(ctx, &s3.UploadPartInput{
+		Bucket:     aws.String(s.bucket),
+		Key:        aws.String(s.fullPath(key)),
+		UploadId:   aws.String(uploadID),
+		PartNumber: aws.Int32(int32(partNumber)),
+	}, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return "", err
+	}
+	return req.URL, nil
+}
+
+func (s *S3Storage) CompleteMultipartUpload(ctx context.Context, key string, uploadID string, parts []storage.UploadPart) error {
+	completed := make([]types.CompletedPart, len(parts))
+	for i, part := range parts {
+		completed[i] = types.CompletedPart{
+			PartNumber: aws.Int32(int32(part.Number)),
+			ETag:       aws.String(part.ETag),
+		}
+	}
+	_, err := s.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(s.bucket),
+		Key:             aws.String(s.fullPath(key)),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: completed},
+	})
+	return err
+}
+
+func (s *S3Storage) AbortMultipartUpload(ctx context.Context, key string, uploadID string) error {
+	_, err := s.client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(s.bucket),
+		Key:      aws.String(s.fullPath(key)),
+		UploadId: aws.String(uploadID),
+	})
+	return err
+}
+
 func (s *S3Storage) CreateFolder(ctx context.Context, folder string) error {
 	fullPath := s.fullPath(folder)
 	if !strings.HasSuffix(fullPath, "/") {
@@ -332,7 +484,7 @@ func (s *S3Storage) Stat(ctx context.Context, key string) (storage.FileInfo, err
 		Key:    aws.String(s.fullPath(key)),
 	})
 	if err != nil {
-		return storage.FileInfo{}, err
+		return storage.FileInfo{}, notFound(err)
 	}
 	relativePath := s.relativePath(key)
 	return storage.FileInfo{
@@ -345,6 +497,17 @@ func (s *S3Storage) Stat(ctx context.Context, key string) (storage.FileInfo, err
 	}, nil
 }
 
+// notFound wraps the errors of missing objects, which HeadObject reports as
+// NotFound and GetObject as NoSuchKey, with storage.ErrNotFound
+func notFound(err error) error {
+	var head *types.NotFound
+	var get *types.NoSuchKey
+	if errors.As(err, &head) || errors.As(err, &get) {
+		return fmt.Errorf("%w: %w", storage.ErrNotFound, err)
+	}
+	return err
+}
+
 func (s *S3Storage) Delete(ctx context.Context, key string) error {
 	fullPath := s.fullPath(key)
 
@@ -385,6 +548,33 @@ func (s *S3Storage) Delete(ctx context.Context, key string) error {
 	return nil
 }
 
+// maxDeleteObjects is the S3 limit of keys per DeleteObjects request
+const maxDeleteObjects = 1000
+
+// DeleteBatch deletes files with DeleteObjects, up to 1000 keys per request
+func (s *S3Storage) DeleteBatch(ctx context.Context, keys []string) error {
+	for start := 0; start < len(keys); start += maxDeleteObjects {
+		chunk := keys[start:min(start+maxDeleteObjects, len(keys))]
+		objectsToDelete := make([]types.ObjectIdentifier, len(chunk))
+		for i, key := range chunk {
+			objectsToDelete[i] = types.ObjectIdentifier{Key: aws.String(s.fullPath(key))}
+		}
+		output, err := s.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(s.bucket),
+			Delete: &types.Delete{Objects: objectsToDelete, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return err
+		}
+		// DeleteObjects succeeds as a whole and reports failed keys individually
+		if len(output.Errors) > 0 {
+			failed := output.Errors[0]
+			return fmt.Errorf("failed to delete %s: %s", aws.ToString(failed.Key), aws.ToString(failed.Message))
+		}
+	}
+	return nil
+}
+
 func (s *S3Storage) deleteFolder(ctx context.Context, prefix string) error {
 	var continuationToken *string
 
@@ -3,7 +3,10 @@ package s3storage
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"io"
+	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
@@ -206,6 +209,35 @@ func TestS3Storage_List(t *testing.T) {
 	assert.Len(t, foldersResult.Items, len(folders))
 }
 
+func TestS3Storage_ListHydratesFolders(t *testing.T) {
+	s3Storage := setupFakeS3(t)
+	ctx := context.Background()
+
+	require.NoError(t, s3Storage.CreateFolder(ctx, "created"))
+	// Folders holding files without a placeholder have no time to report
+	require.NoError(t, s3Storage.Put(ctx, "implicit/a.jpg", bytes.NewReader([]byte("content"))))
+
+	result, err := s3Storage.List(ctx, "", storage.ListOptions{OnlyFolders: true, SortBy: storage.SortByName})
+	require.NoError(t, err)
+	require.Len(t, result.Items, 2)
+	assert.Equal(t, "created", result.Items[0].Name)
+	assert.False(t, result.Items[0].ModifiedTime.IsZero())
+	assert.Equal(t, "implicit", result.Items[1].Name)
+	assert.True(t, result.Items[1].ModifiedTime.IsZero())
+
+	page, err := s3Storage.ListPage(ctx, "", storage.ListOptions{OnlyFolders: true}, "")
+	require.NoError(t, err)
+	require.Len(t, page.Items, 2)
+	assert.False(t, page.Items[0].ModifiedTime.IsZero())
+
+	result, err = s3Storage.List(ctx, "", storage.ListOptions{OnlyFolders: true, SkipMetadata: true})
+	require.NoError(t, err)
+	require.Len(t, result.Items, 2)
+	for _, item := range result.Items {
+		assert.True(t, item.ModifiedTime.IsZero())
+	}
+}
+
 func TestS3Storage_CreateFolder(t *testing.T) {
 	s3Storage := setupFakeS3(t)
 	ctx := context.Background()
@@ -346,7 +378,9 @@ func TestS3Storage_StatNonExistent(t *testing.T) {
 	ctx := context.Background()
 
 	_, err := s3Storage.Stat(ctx, "non_existent.txt")
-	assert.Error(t, err)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	_, err = s3Storage.Get(ctx, "non_existent.txt")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
 }
 
 func TestS3Storage_ListWithBaseDir(t *testing.T) {
@@ -543,6 +577,22 @@ func TestS3Storage_CopyDestinationExists(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestS3Storage_PutIfAbsent(t *testing.T) {
+	s3Storage := setupFakeS3(t)
+	ctx := context.Background()
+
+	require.NoError(t, s3Storage.PutIfAbsent(ctx, "test.txt", strings.NewReader("first")))
+	err := s3Storage.PutIfAbsent(ctx, "test.txt", strings.NewReader("second"))
+	assert.ErrorIs(t, err, storage.ErrExists)
+
+	result, err := s3Storage.Get(ctx, "test.txt")
+	require.NoError(t, err)
+	defer result.Close()
+	data, err := io.ReadAll(result)
+	require.NoError(t, err)
+	assert.Equal(t, "first", string(data))
+}
+
 func TestS3Storage_PresignedPutURLNoOverwrite_SignsIfNoneMatch(t *testing.T) {
 	s3Storage := setupFakeS3(t)
 	ctx := context.Background()
@@ -556,6 +606,65 @@ func TestS3Storage_PresignedPutURLNoOverwrite_SignsIfNoneMatch(t *testing.T) {
 	assert.True(t, strings.Contains(signedHeaders, "if-none-match"))
 }
 
+func TestS3Storage_PresignedGetURL(t *testing.T) {
+	s3Storage := setupFakeS3(t)
+	ctx := context.Background()
+	require.NoError(t, s3Storage.Put(ctx, "photos/a b.jpg", strings.NewReader("content")))
+
+	downloadURL, err := s3Storage.PresignedGetURL(ctx, "photos/a b.jpg", "a b.jpg", time.Minute)
+	require.NoError(t, err)
+	parsedURL, err := url.Parse(downloadURL)
+	require.NoError(t, err)
+	assert.Equal(t, `attachment; filename="a b.jpg"`, parsedURL.Query().Get("response-content-disposition"))
+
+	resp, err := http.Get(downloadURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(body))
+}
+
+func TestS3Storage_MultipartUpload(t *testing.T) {
+	s3Storage := setupFakeS3(t)
+	ctx := context.Background()
+
+	uploadID, err := s3Storage.CreateMultipartUpload(ctx, "videos/big.mp4", "video/mp4")
+	require.NoError(t, err)
+	require.NotEmpty(t, uploadID)
+
+	// Parts are sent straight to the presigned URLs, as clients do
+	var parts []storage.UploadPart
+	for i, content := range []string{strings.Repeat("a", 5*1024*1024), "end"} {
+		partURL, err := s3Storage.PresignedUploadPartURL(ctx, "videos/big.mp4", uploadID, i+1, time.Minute)
+		require.NoError(t, err)
+		parsedURL, err := url.Parse(partURL)
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprint(i+1), parsedURL.Query().Get("partNumber"))
+		assert.Equal(t, uploadID, parsedURL.Query().Get("uploadId"))
+
+		req, err := http.NewRequest(http.MethodPut, partURL, strings.NewReader(content))
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		parts = append(parts, storage.UploadPart{Number: i + 1, ETag: resp.Header.Get("ETag")})
+	}
+	require.NoError(t, s3Storage.CompleteMultipartUpload(ctx, "videos/big.mp4", uploadID, parts))
+
+	info, err := s3Storage.Stat(ctx, "videos/big.mp4")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5*1024*1024+3), info.Size)
+
+	// Aborted uploads leave nothing behind
+	uploadID, err = s3Storage.CreateMultipartUpload(ctx, "videos/aborted.mp4", "video/mp4")
+	require.NoError(t, err)
+	require.NoError(t, s3Storage.AbortMultipartUpload(ctx, "videos/aborted.mp4", uploadID))
+	_, err = s3Storage.Stat(ctx, "videos/aborted.mp4")
+	assert.Error(t, err)
+}
+
 func TestS3Storage_MoveFile(t *testing.T) {
 	s3Storage := setupFakeS3(t)
 	ctx := context.Background()
@@ -632,3 +741,40 @@ func TestS3Storage_MoveDestinationExists(t *testing.T) {
 	assert.Error(t, err)
 	assert.ErrorIs(t, err, os.ErrExist)
 }
+
+func TestS3Storage_DeleteBatch(t *testing.T) {
+	s3Storage := setupFakeS3(t)
+	ctx := context.Background()
+
+	for _, key := range []string{"batch/a.txt", "batch/b.txt", "batch/keep.txt"} {
+		require.NoError(t, s3Storage.Put(ctx, key, bytes.NewReader([]byte("content"))))
+	}
+	require.NoError(t, s3Storage.DeleteBatch(ctx, []string{"batch/a.txt", "batch/b.txt"}))
+
+	result, err := s3Storage.List(ctx, "batch", storage.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, result.Items, 1)
+	assert.Equal(t, "keep.txt", result.Items[0].Name)
+	assert.NoError(t, s3Storage.DeleteBatch(ctx, nil))
+}
+
+func TestS3Storage_Walk(t *testing.T) {
+	s3Storage := setupFakeS3(t)
+	ctx := context.Background()
+
+	for _, key := range []string{"walk/a.txt", "walk/sub/b.txt", "walk/sub/deep/c.txt", "other/d.txt"} {
+		require.NoError(t, s3Storage.Put(ctx, key, bytes.NewReader([]byte("content"))))
+	}
+	require.NoError(t, s3Storage.CreateFolder(ctx, "walk/empty"))
+
+	var paths []string
+	require.NoError(t, s3Storage.Walk(ctx, "walk", func(info storage.FileInfo) error {
+		assert.False(t, info.IsDir)
+		paths = append(paths, info.Path)
+		return nil
+	}))
+	assert.ElementsMatch(t, []string{"walk/a.txt", "walk/sub/b.txt", "walk/sub/deep/c.txt"}, paths)
+
+	stop := errors.New("stop")
+	assert.ErrorIs(t, s3Storage.Walk(ctx, "walk", func(storage.FileInfo) error { return stop }), stop)
+}
@@ -0,0 +1,475 @@
+package sftpstorage
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+)
+
+// SFTP protocol version 3 (draft-ietf-secsh-filexfer-02), the version
+// implemented by OpenSSH and most other servers
+const sftpVersion = 3
+
+const (
+	fxpInit      = 1
+	fxpVersion   = 2
+	fxpOpen      = 3
+	fxpClose     = 4
+	fxpRead      = 5
+	fxpWrite     = 6
+	fxpOpendir   = 11
+	fxpReaddir   = 12
+	fxpRemove    = 13
+	fxpMkdir     = 14
+	fxpRmdir     = 15
+	fxpStat      = 17
+	fxpRename    = 18
+	fxpStatus    = 101
+	fxpHandle    = 102
+	fxpData      = 103
+	fxpName      = 104
+	fxpAttrs     = 105
+	fxfRead      = 0x01
+	fxfWrite     = 0x02
+	fxfCreat     = 0x08
+	fxfTrunc     = 0x10
+	attrSize     = 0x01
+	attrUIDGID   = 0x02
+	attrPerms    = 0x04
+	attrTimes    = 0x08
+	attrExtended = 0x80000000
+)
+
+const (
+	fxOK               = 0
+	fxEOF              = 1
+	fxNoSuchFile       = 2
+	fxPermissionDenied = 3
+)
+
+const (
+	// maxPacket bounds packets read from the server, servers must accept
+	// at least 34000 bytes so data is sent in chunks of maxData
+	maxPacket = 256 * 1024
+	maxData   = 32 * 1024
+)
+
+// StatusError is an SFTP status response other than OK
+type StatusError struct {
+	Code    uint32
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("sftp: %s (code %d)", e.Message, e.Code)
+	}
+	return fmt.Sprintf("sftp: status code %d", e.Code)
+}
+
+// Is maps SFTP status codes to the os errors returned by other backends
+func (e *StatusError) Is(target error) bool {
+	switch e.Code {
+	case fxNoSuchFile:
+		return target == fs.ErrNotExist
+	case fxPermissionDenied:
+		return target == fs.ErrPermission
+	}
+	return false
+}
+
+// fileAttrs is the subset of SFTP attributes used by the storage
+type fileAttrs struct {
+	size    int64
+	mode    uint32
+	modTime time.Time
+}
+
+func (a fileAttrs) isDir() bool {
+	return a.mode&0170000 == 0040000
+}
+
+func (a fileAttrs) isSymlink() bool {
+	return a.mode&0170000 == 0120000
+}
+
+type dirEntry struct {
+	name  string
+	attrs fileAttrs
+}
+
+// client is a minimal SFTP client over a byte stream, usually the stdin and
+// stdout of an SSH "sftp" subsystem session. Requests are serialized.
+type client struct {
+	r      io.Reader
+	w      io.Writer
+	closer io.Closer
+
+	mu     sync.Mutex
+	nextID uint32
+	err    error // transport error, the client is unusable once set
+}
+
+// newClient performs the version handshake over r and w
+func newClient(r io.Reader, w io.Writer, closer io.Closer) (*client, error) {
+	c := &client{r: r, w: w, closer: closer}
+	var b buffer
+	b.byte(fxpInit)
+	b.uint32(sftpVersion)
+	if err := c.writePacket(b.bytes()); err != nil {
+		return nil, err
+	}
+	packet, err := c.readPacket()
+	if err != nil {
+		return nil, err
+	}
+	d := &decoder{data: packet}
+	if typ := d.byte(); typ != fxpVersion {
+		return nil, fmt.Errorf("sftp: unexpected packet type %d during handshake", typ)
+	}
+	if version := d.uint32(); d.err != nil || version < sftpVersion {
+		return nil, fmt.Errorf("sftp: unsupported server version %d", version)
+	}
+	return c, nil
+}
+
+// broken reports whether the transport failed and the client must be replaced
+func (c *client) broken() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err != nil
+}
+
+func (c *client) Close() error {
+	c.mu.Lock()
+	if c.err == nil {
+		c.err = errors.New("sftp: client closed")
+	}
+	c.mu.Unlock()
+	if c.closer != nil {
+		return c.closer.Close()
+	}
+	return nil
+}
+
+func (c *client) writePacket(payload []byte) error {
+	header := make([]byte, 4, 4+len(payload))
+	binary.BigEndian.PutUint32(header, uint32(len(payload)))
+	_, err := c.w.Write(append(header, payload...))
+	return err
+}
+
+func (c *client) readPacket() ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(header[:])
+	if length == 0 || length > maxPacket {
+		return nil, fmt.Errorf("sftp: invalid packet length %d", length)
+	}
+	packet := make([]byte, length)
+	if _, err := io.ReadFull(c.r, packet); err != nil {
+		return nil, err
+	}
+	return packet, nil
+}
+
+// request sends a request of type typ built by build and returns the
+// response type and payload after the request id
+func (c *client) request(typ byte, build func(*buffer)) (byte, *decoder, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return 0, nil, c.err
+	}
+	c.nextID++
+	id := c.nextID
+	var b buffer
+	b.byte(typ)
+	b.uint32(id)
+	build(&b)
+	if err := c.writePacket(b.bytes()); err != nil {
+		c.err = err
+		return 0, nil, err
+	}
+	packet, err := c.readPacket()
+	if err != nil {
+		c.err = err
+		return 0, nil, err
+	}
+	d := &decoder{data: packet}
+	respType := d.byte()
+	if respID := d.uint32(); d.err != nil || respID != id {
+		c.err = fmt.Errorf("sftp: unexpected response id %d for request %d", respID, id)
+		return 0, nil, c.err
+	}
+	return respType, d, nil
+}
+
+// status decodes a status response, returning nil for OK
+func status(typ byte, d *decoder) error {
+	if typ != fxpStatus {
+		return fmt.Errorf("sftp: unexpected packet type %d", typ)
+	}
+	code := d.uint32()
+	message := d.string()
+	if d.err != nil {
+		return d.err
+	}
+	switch code {
+	case fxOK:
+		return nil
+	case fxEOF:
+		return io.EOF
+	}
+	return &StatusError{Code: code, Message: message}
+}
+
+func (c *client) pathRequest(typ byte, path string) error {
+	respType, d, err := c.request(typ, func(b *buffer) { b.string(path) })
+	if err != nil {
+		return err
+	}
+	return status(respType, d)
+}
+
+func (c *client) attrsRequest(typ byte, path string) (fileAttrs, error) {
+	respType, d, err := c.request(typ, func(b *buffer) { b.string(path) })
+	if err != nil {
+		return fileAttrs{}, err
+	}
+	if respType != fxpAttrs {
+		return fileAttrs{}, status(respType, d)
+	}
+	attrs := d.attrs()
+	return attrs, d.err
+}
+
+func (c *client) stat(path string) (fileAttrs, error) {
+	return c.attrsRequest(fxpStat, path)
+}
+
+func (c *client) handleRequest(typ byte, build func(*buffer)) (string, error) {
+	respType, d, err := c.request(typ, build)
+	if err != nil {
+		return "", err
+	}
+	if respType != fxpHandle {
+		return "", status(respType, d)
+	}
+	handle := d.string()
+	return handle, d.err
+}
+
+// open opens path with the fxf flags, new files get perm
+func (c *client) open(path string, flags uint32, perm os.FileMode) (string, error) {
+	return c.handleRequest(fxpOpen, func(b *buffer) {
+		b.string(path)
+		b.uint32(flags)
+		b.uint32(attrPerms)
+		b.uint32(uint32(perm.Perm()))
+	})
+}
+
+func (c *client) closeHandle(handle string) error {
+	return c.pathRequest(fxpClose, handle)
+}
+
+// read reads up to n bytes at offset, returning io.EOF at the end of file
+func (c *client) read(handle string, offset uint64, n int) ([]byte, error) {
+	respType, d, err := c.request(fxpRead, func(b *buffer) {
+		b.string(handle)
+		b.uint64(offset)
+		b.uint32(uint32(min(n, maxData)))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if respType != fxpData {
+		return nil, status(respType, d)
+	}
+	data := d.string()
+	return []byte(data), d.err
+}
+
+func (c *client) write(handle string, offset uint64, data []byte) error {
+	respType, d, err := c.request(fxpWrite, func(b *buffer) {
+		b.string(handle)
+		b.uint64(offset)
+		b.uint32(uint32(len(data)))
+		b.raw(data)
+	})
+	if err != nil {
+		return err
+	}
+	return status(respType, d)
+}
+
+// readDir lists path, excluding the "." and ".." entries
+func (c *client) readDir(path string) ([]dirEntry, error) {
+	handle, err := c.handleRequest(fxpOpendir, func(b *buffer) { b.string(path) })
+	if err != nil {
+		return nil, err
+	}
+	defer c.closeHandle(handle)
+
+	var entries []dirEntry
+	for {
+		respType, d, err := c.request(fxpReaddir, func(b *buffer) { b.string(handle) })
+		if err != nil {
+			return nil, err
+		}
+		if respType != fxpName {
+			if err := status(respType, d); err == io.EOF {
+				return entries, nil
+			} else if err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("sftp: unexpected status reading %s", path)
+		}
+		count := d.uint32()
+		for i := uint32(0); i < count && d.err == nil; i++ {
+			name := d.string()
+			d.string() // long name
+			attrs := d.attrs()
+			if name != "." && name != ".." {
+				entries = append(entries, dirEntry{name: name, attrs: attrs})
+			}
+		}
+		if d.err != nil {
+			return nil, d.err
+		}
+	}
+}
+
+func (c *client) mkdir(path string, perm os.FileMode) error {
+	respType, d, err := c.request(fxpMkdir, func(b *buffer) {
+		b.string(path)
+		b.uint32(attrPerms)
+		b.uint32(uint32(perm.Perm()))
+	})
+	if err != nil {
+		return err
+	}
+	return status(respType, d)
+}
+
+func (c *client) remove(path string) error {
+	return c.pathRequest(fxpRemove, path)
+}
+
+func (c *client) rmdir(path string) error {
+	return c.pathRequest(fxpRmdir, path)
+}
+
+func (c *client) rename(oldPath, newPath string) error {
+	respType, d, err := c.request(fxpRename, func(b *buffer) {
+		b.string(oldPath)
+		b.string(newPath)
+	})
+	if err != nil {
+		return err
+	}
+	return status(respType, d)
+}
+
+// buffer encodes SFTP packet fields
+type buffer struct {
+	buf []byte
+}
+
+func (b *buffer) bytes() []byte { return b.buf }
+
+func (b *buffer) byte(v byte) { b.buf = append(b.buf, v) }
+
+func (b *buffer) raw(v []byte) { b.buf = append(b.buf, v...) }
+
+func (b *buffer) uint32(v uint32) { b.buf = binary.BigEndian.AppendUint32(b.buf, v) }
+
+func (b *buffer) uint64(v uint64) { b.buf = binary.BigEndian.AppendUint64(b.buf, v) }
+
+func (b *buffer) string(v string) {
+	b.uint32(uint32(len(v)))
+	b.buf = append(b.buf, v...)
+}
+
+// decoder decodes SFTP packet fields, recording the first error
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if len(d.data) < n {
+		d.err = errors.New("sftp: short packet")
+		return nil
+	}
+	v := d.data[:n]
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *decoder) byte() byte {
+	if v := d.next(1); v != nil {
+		return v[0]
+	}
+	return 0
+}
+
+func (d *decoder) uint32() uint32 {
+	if v := d.next(4); v != nil {
+		return binary.BigEndian.Uint32(v)
+	}
+	return 0
+}
+
+func (d *decoder) uint64() uint64 {
+	if v := d.next(8); v != nil {
+		return binary.BigEndian.Uint64(v)
+	}
+	return 0
+}
+
+func (d *decoder) string() string {
+	n := d.uint32()
+	if n > uint32(len(d.data)) {
+		d.err = errors.New("sftp: short packet")
+		return ""
+	}
+	return string(d.next(int(n)))
+}
+
+func (d *decoder) attrs() fileAttrs {
+	var attrs fileAttrs
+	flags := d.uint32()
+	if flags&attrSize != 0 {
+		attrs.size = int64(d.uint64())
+	}
+	if flags&attrUIDGID != 0 {
+		d.uint32()
+		d.uint32()
+	}
+	if flags&attrPerms != 0 {
+		attrs.mode = d.uint32()
+	}
+	if flags&attrTimes != 0 {
+		d.uint32() // atime
+		attrs.modTime = time.Unix(int64(d.uint32()), 0)
+	}
+	if flags&attrExtended != 0 {
+		for n := d.uint32(); n > 0 && d.err == nil; n-- {
+			d.string()
+			d.string()
+		}
+	}
+	return attrs
+}
@@ -0,0 +1,549 @@
+package sftpstorage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/ssh"
+)
+
+const defaultDialTimeout = 30 * time.Second
+
+type SFTPStorage struct {
+	host            string
+	port            int
+	user            string
+	privateKey      string
+	passphrase      string
+	hostKey         string
+	baseDir         string
+	mkdirPermission os.FileMode
+	writePermission os.FileMode
+	logger          *zap.Logger
+
+	// dial opens a new session, replaced in tests
+	dial func() (*client, error)
+
+	mu     sync.Mutex
+	client *client
+}
+
+type Option func(*SFTPStorage)
+
+func WithPort(port int) Option {
+	return func(s *SFTPStorage) {
+		s.port = port
+	}
+}
+
+func WithUser(user string) Option {
+	return func(s *SFTPStorage) {
+		s.user = user
+	}
+}
+
+// WithPrivateKey sets the PEM encoded private key used to authenticate,
+// passphrase is only needed for encrypted keys
+func WithPrivateKey(privateKey, passphrase string) Option {
+	return func(s *SFTPStorage) {
+		s.privateKey = privateKey
+		s.passphrase = passphrase
+	}
+}
+
+// WithHostKey pins the server host key by its SHA256 fingerprint as printed
+// by ssh-keygen -l, e.g. "SHA256:nThbg6kXUpJWGl7E1IGOCspRomTxdCARLviKw6E5SY8".
+// Without it any host key is accepted and its fingerprint is logged.
+func WithHostKey(fingerprint string) Option {
+	return func(s *SFTPStorage) {
+		s.hostKey = fingerprint
+	}
+}
+
+func WithBaseDir(baseDir string) Option {
+	return func(s *SFTPStorage) {
+		s.baseDir = strings.TrimRight(baseDir, "/")
+	}
+}
+
+func WithMkdirPermission(perm os.FileMode) Option {
+	return func(s *SFTPStorage) {
+		s.mkdirPermission = perm
+	}
+}
+
+func WithWritePermission(perm os.FileMode) Option {
+	return func(s *SFTPStorage) {
+		s.writePermission = perm
+	}
+}
+
+func WithLogger(logger *zap.Logger) Option {
+	return func(s *SFTPStorage) {
+		s.logger = logger
+	}
+}
+
+// New returns a storage on the SFTP server at host. The connection is
+// opened on first use and reopened after it fails.
+func New(host string, options ...Option) (*SFTPStorage, error) {
+	s := &SFTPStorage{
+		host:            host,
+		port:            22,
+		mkdirPermission: 0755,
+		writePermission: 0644,
+		logger:          zap.NewNop(),
+	}
+	for _, option := range options {
+		option(s)
+	}
+	if s.host == "" {
+		return nil, errors.New("sftp: host is required")
+	}
+	if s.user == "" {
+		return nil, errors.New("sftp: user is required")
+	}
+	if s.privateKey == "" {
+		return nil, errors.New("sftp: private key is required")
+	}
+
+	var signer ssh.Signer
+	var err error
+	if s.passphrase != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(s.privateKey), []byte(s.passphrase))
+	} else {
+		signer, err = ssh.ParsePrivateKey([]byte(s.privateKey))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("sftp: invalid private key: %w", err)
+	}
+
+	sshConfig := &ssh.ClientConfig{
+		User:            s.user,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: s.checkHostKey,
+		Timeout:         defaultDialTimeout,
+	}
+	addr := net.JoinHostPort(s.host, strconv.Itoa(s.port))
+	s.dial = func() (*client, error) {
+		return dialSSH(addr, sshConfig)
+	}
+	return s, nil
+}
+
+func (s *SFTPStorage) checkHostKey(hostname string, _ net.Addr, key ssh.PublicKey) error {
+	fingerprint := ssh.FingerprintSHA256(key)
+	if s.hostKey == "" {
+		s.logger.Warn("Accepting unverified SFTP host key, pin it with the host key setting",
+			zap.String("host", hostname),
+			zap.String("fingerprint", fingerprint))
+		return nil
+	}
+	if fingerprint != s.hostKey {
+		return fmt.Errorf("sftp: host key mismatch for %s: got %s", hostname, fingerprint)
+	}
+	return nil
+}
+
+// dialSSH opens an SSH connection to addr and starts the sftp subsystem
+func dialSSH(addr string, config *ssh.ClientConfig) (*client, error) {
+	conn, err := ssh.Dial("tcp", addr, config)
+	if err != nil {
+		return nil, err
+	}
+	session, err := conn.NewSession()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	w, err := session.StdinPipe()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	r, err := session.StdoutPipe()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := session.RequestSubsystem("sftp"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	c, err := newClient(r, w, conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// conn returns the open client, dialing when there is none or the last one
+// failed
+func (s *SFTPStorage) conn() (*client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client != nil && !s.client.broken() {
+		return s.client, nil
+	}
+	if s.client != nil {
+		s.client.Close()
+		s.client = nil
+	}
+	c, err := s.dial()
+	if err != nil {
+		return nil, fmt.Errorf("sftp: failed to connect to %s: %w", s.host, err)
+	}
+	s.client = c
+	return c, nil
+}
+
+// Close closes the connection, a later call reconnects
+func (s *SFTPStorage) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client == nil {
+		return nil
+	}
+	err := s.client.Close()
+	s.client = nil
+	return err
+}
+
+func (s *SFTPStorage) fullPath(key string) string {
+	key = strings.Trim(key, "/")
+	if s.baseDir == "" {
+		if key == "" {
+			return "."
+		}
+		return path.Clean(key)
+	}
+	return path.Join(s.baseDir, key)
+}
+
+func (s *SFTPStorage) List(ctx context.Context, key string, options storage.ListOptions) (storage.ListResult, error) {
+	c, err := s.conn()
+	if err != nil {
+		return storage.ListResult{}, err
+	}
+	fullPath := s.fullPath(key)
+	entries, err := c.readDir(fullPath)
+	if err != nil {
+		return storage.ListResult{}, err
+	}
+
+	var allItems []storage.FileInfo
+	for _, entry := range entries {
+		attrs := entry.attrs
+		if attrs.isSymlink() {
+			// Directory listings report the link itself, follow it
+			if attrs, err = c.stat(path.Join(fullPath, entry.name)); err != nil {
+				s.logger.Debug("Skipping inaccessible entry",
+					zap.String("name", entry.name),
+					zap.String("path", fullPath),
+					zap.Error(err))
+				continue
+			}
+		}
+		if !storage.ShouldIncludeFile(entry.name, attrs.isDir(), options) {
+			continue
+		}
+		item := storage.FileInfo{
+			Name:         entry.name,
+			Path:         path.Join(strings.Trim(key, "/"), entry.name),
+			IsDir:        attrs.isDir(),
+			ModifiedTime: attrs.modTime,
+		}
+		if !item.IsDir {
+			item.Size = attrs.size
+		}
+		allItems = append(allItems, item)
+	}
+	totalCount := len(allItems)
+
+	storage.SortFileInfos(allItems, options.SortBy, options.SortOrder)
+
+	start := min(options.Offset, len(allItems))
+	end := len(allItems)
+	if options.Limit > 0 {
+		end = min(start+options.Limit, len(allItems))
+	}
+
+	return storage.ListResult{
+		Items:      allItems[start:end],
+		TotalCount: totalCount,
+	}, nil
+}
+
+func (s *SFTPStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	c, err := s.conn()
+	if err != nil {
+		return nil, err
+	}
+	handle, err := c.open(s.fullPath(key), fxfRead, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &fileReader{client: c, handle: handle}, nil
+}
+
+func (s *SFTPStorage) Put(ctx context.Context, key string, content io.Reader) error {
+	c, err := s.conn()
+	if err != nil {
+		return err
+	}
+	fullPath := s.fullPath(key)
+	if err := s.mkdirAll(c, path.Dir(fullPath)); err != nil {
+		return err
+	}
+	return s.writeFile(ctx, c, fullPath, content)
+}
+
+func (s *SFTPStorage) writeFile(ctx context.Context, c *client, fullPath string, content io.Reader) error {
+	handle, err := c.open(fullPath, fxfWrite|fxfCreat|fxfTrunc, s.writePermission)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, maxData)
+	var offset uint64
+	for {
+		if err := ctx.Err(); err != nil {
+			c.closeHandle(handle)
+			return err
+		}
+		n, readErr := io.ReadFull(content, buf)
+		if n > 0 {
+			if err := c.write(handle, offset, buf[:n]); err != nil {
+				c.closeHandle(handle)
+				return err
+			}
+			offset += uint64(n)
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			c.closeHandle(handle)
+			return readErr
+		}
+	}
+	return c.closeHandle(handle)
+}
+
+func (s *SFTPStorage) Delete(ctx context.Context, key string) error {
+	c, err := s.conn()
+	if err != nil {
+		return err
+	}
+	fullPath := s.fullPath(key)
+	attrs, err := c.stat(fullPath)
+	if err != nil {
+		return err
+	}
+	if attrs.isDir() {
+		return s.removeAll(ctx, c, fullPath)
+	}
+	return c.remove(fullPath)
+}
+
+// removeAll removes the directory fullPath and everything below it
+func (s *SFTPStorage) removeAll(ctx context.Context, c *client, fullPath string) error {
+	entries, err := c.readDir(fullPath)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		entryPath := path.Join(fullPath, entry.name)
+		if entry.attrs.isDir() {
+			err = s.removeAll(ctx, c, entryPath)
+		} else {
+			err = c.remove(entryPath)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return c.rmdir(fullPath)
+}
+
+func (s *SFTPStorage) CreateFolder(ctx context.Context, folder string) error {
+	c, err := s.conn()
+	if err != nil {
+		return err
+	}
+	return s.mkdirAll(c, s.fullPath(folder))
+}
+
+// mkdirAll creates fullPath and any missing parents
+func (s *SFTPStorage) mkdirAll(c *client, fullPath string) error {
+	attrs, err := c.stat(fullPath)
+	if err == nil {
+		if !attrs.isDir() {
+			return fmt.Errorf("sftp: %s is not a directory", fullPath)
+		}
+		return nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if parent := path.Dir(fullPath); parent != fullPath && parent != "." && parent != "/" {
+		if err := s.mkdirAll(c, parent); err != nil {
+			return err
+		}
+	}
+	if err := c.mkdir(fullPath, s.mkdirPermission); err != nil {
+		// Lost a race with a concurrent create
+		if attrs, statErr := c.stat(fullPath); statErr == nil && attrs.isDir() {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *SFTPStorage) Stat(ctx context.Context, key string) (storage.FileInfo, error) {
+	c, err := s.conn()
+	if err != nil {
+		return storage.FileInfo{}, err
+	}
+	fullPath := s.fullPath(key)
+	attrs, err := c.stat(fullPath)
+	if err != nil {
+		return storage.FileInfo{}, err
+	}
+	info := storage.FileInfo{
+		Name:         path.Base(fullPath),
+		Path:         key,
+		IsDir:        attrs.isDir(),
+		ModifiedTime: attrs.modTime,
+	}
+	if !info.IsDir {
+		info.Size = attrs.size
+	}
+	return info, nil
+}
+
+func (s *SFTPStorage) Copy(ctx context.Context, sourcePath string, destPath string) error {
+	c, err := s.conn()
+	if err != nil {
+		return err
+	}
+	sourceFullPath := s.fullPath(sourcePath)
+	destFullPath := s.fullPath(destPath)
+
+	sourceAttrs, err := c.stat(sourceFullPath)
+	if err != nil {
+		return err
+	}
+	if _, err := c.stat(destFullPath); err == nil {
+		return os.ErrExist
+	}
+	if sourceAttrs.isDir() {
+		return s.copyDir(ctx, c, sourceFullPath, destFullPath)
+	}
+	if err := s.mkdirAll(c, path.Dir(destFullPath)); err != nil {
+		return err
+	}
+	return s.copyFile(ctx, c, sourceFullPath, destFullPath)
+}
+
+// copyFile streams sourceFullPath through the server connection, SFTP v3
+// has no server side copy
+func (s *SFTPStorage) copyFile(ctx context.Context, c *client, sourceFullPath, destFullPath string) error {
+	handle, err := c.open(sourceFullPath, fxfRead, 0)
+	if err != nil {
+		return err
+	}
+	reader := &fileReader{client: c, handle: handle}
+	defer reader.Close()
+	return s.writeFile(ctx, c, destFullPath, reader)
+}
+
+func (s *SFTPStorage) copyDir(ctx context.Context, c *client, sourceFullPath, destFullPath string) error {
+	if err := s.mkdirAll(c, destFullPath); err != nil {
+		return err
+	}
+	entries, err := c.readDir(sourceFullPath)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		sourceEntryPath := path.Join(sourceFullPath, entry.name)
+		destEntryPath := path.Join(destFullPath, entry.name)
+		if entry.attrs.isDir() {
+			err = s.copyDir(ctx, c, sourceEntryPath, destEntryPath)
+		} else {
+			err = s.copyFile(ctx, c, sourceEntryPath, destEntryPath)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SFTPStorage) Move(ctx context.Context, sourcePath string, destPath string) error {
+	c, err := s.conn()
+	if err != nil {
+		return err
+	}
+	sourceFullPath := s.fullPath(sourcePath)
+	destFullPath := s.fullPath(destPath)
+
+	if _, err := c.stat(sourceFullPath); err != nil {
+		return err
+	}
+	if _, err := c.stat(destFullPath); err == nil {
+		return os.ErrExist
+	}
+	if err := s.mkdirAll(c, path.Dir(destFullPath)); err != nil {
+		return err
+	}
+	return c.rename(sourceFullPath, destFullPath)
+}
+
+// fileReader reads an open remote file sequentially
+type fileReader struct {
+	client *client
+	handle string
+	offset uint64
+	closed bool
+}
+
+func (r *fileReader) Read(p []byte) (int, error) {
+	if r.closed {
+		return 0, os.ErrClosed
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	data, err := r.client.read(r.handle, r.offset, len(p))
+	if err != nil {
+		return 0, err
+	}
+	n := copy(p, data)
+	r.offset += uint64(n)
+	return n, nil
+}
+
+func (r *fileReader) Close() error {
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	return r.client.closeHandle(r.handle)
+}
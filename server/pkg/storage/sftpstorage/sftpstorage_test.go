@@ -0,0 +1,417 @@
+package sftpstorage
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+// testServer serves the subset of SFTP v3 used by the storage from a local
+// directory, paths are resolved relative to root
+type testServer struct {
+	root string
+	r    io.Reader
+	w    io.Writer
+
+	mu      sync.Mutex
+	handles map[string]*testHandle
+	next    int
+}
+
+type testHandle struct {
+	file    *os.File
+	entries []os.DirEntry
+	dir     string
+	listed  bool
+}
+
+func newTestStorage(t *testing.T, options ...Option) (*SFTPStorage, string) {
+	root := t.TempDir()
+	s, err := New("localhost", append([]Option{
+		WithUser("test"),
+		WithPrivateKey(testPrivateKey(t), ""),
+	}, options...)...)
+	require.NoError(t, err)
+	s.dial = func() (*client, error) {
+		clientR, serverW := io.Pipe()
+		serverR, clientW := io.Pipe()
+		srv := &testServer{root: root, r: serverR, w: serverW, handles: map[string]*testHandle{}}
+		go srv.serve()
+		return newClient(clientR, clientW, multiCloser{clientW, serverW})
+	}
+	t.Cleanup(func() { s.Close() })
+	return s, root
+}
+
+type multiCloser []io.Closer
+
+func (m multiCloser) Close() error {
+	for _, c := range m {
+		c.Close()
+	}
+	return nil
+}
+
+func testPrivateKey(t *testing.T) string {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	block, err := ssh.MarshalPrivateKey(key, "")
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(block))
+}
+
+func (srv *testServer) serve() {
+	for {
+		var header [4]byte
+		if _, err := io.ReadFull(srv.r, header[:]); err != nil {
+			return
+		}
+		packet := make([]byte, binary.BigEndian.Uint32(header[:]))
+		if _, err := io.ReadFull(srv.r, packet); err != nil {
+			return
+		}
+		d := &decoder{data: packet}
+		typ := d.byte()
+		var resp buffer
+		if typ == fxpInit {
+			resp.byte(fxpVersion)
+			resp.uint32(sftpVersion)
+		} else {
+			id := d.uint32()
+			srv.handle(typ, id, d, &resp)
+		}
+		out := binary.BigEndian.AppendUint32(nil, uint32(len(resp.bytes())))
+		if _, err := srv.w.Write(append(out, resp.bytes()...)); err != nil {
+			return
+		}
+	}
+}
+
+func (srv *testServer) path(p string) string {
+	return filepath.Join(srv.root, filepath.FromSlash(p))
+}
+
+func (srv *testServer) handle(typ byte, id uint32, d *decoder, resp *buffer) {
+	writeStatus := func(err error) {
+		resp.byte(fxpStatus)
+		resp.uint32(id)
+		switch {
+		case err == nil:
+			resp.uint32(fxOK)
+		case err == io.EOF:
+			resp.uint32(fxEOF)
+		case errors.Is(err, os.ErrNotExist):
+			resp.uint32(fxNoSuchFile)
+		case errors.Is(err, os.ErrPermission):
+			resp.uint32(fxPermissionDenied)
+		default:
+			resp.uint32(4) // failure
+		}
+		if err != nil {
+			resp.string(err.Error())
+		} else {
+			resp.string("")
+		}
+		resp.string("")
+	}
+	writeAttrs := func(info os.FileInfo) {
+		mode := uint32(info.Mode().Perm())
+		if info.IsDir() {
+			mode |= 0040000
+		} else {
+			mode |= 0100000
+		}
+		resp.uint32(attrSize | attrPerms | attrTimes)
+		resp.uint64(uint64(info.Size()))
+		resp.uint32(mode)
+		resp.uint32(uint32(info.ModTime().Unix()))
+		resp.uint32(uint32(info.ModTime().Unix()))
+	}
+	newHandle := func(h *testHandle) {
+		srv.mu.Lock()
+		srv.next++
+		name := string(rune('a' + srv.next%26))
+		name = strings.Repeat(name, 1+srv.next/26)
+		srv.handles[name] = h
+		srv.mu.Unlock()
+		resp.byte(fxpHandle)
+		resp.uint32(id)
+		resp.string(name)
+	}
+	getHandle := func(name string) *testHandle {
+		srv.mu.Lock()
+		defer srv.mu.Unlock()
+		return srv.handles[name]
+	}
+
+	switch typ {
+	case fxpStat:
+		info, err := os.Stat(srv.path(d.string()))
+		if err != nil {
+			writeStatus(err)
+			return
+		}
+		resp.byte(fxpAttrs)
+		resp.uint32(id)
+		writeAttrs(info)
+	case fxpOpen:
+		name := d.string()
+		pflags := d.uint32()
+		flag := os.O_RDONLY
+		if pflags&fxfWrite != 0 {
+			flag = os.O_WRONLY
+		}
+		if pflags&fxfCreat != 0 {
+			flag |= os.O_CREATE
+		}
+		if pflags&fxfTrunc != 0 {
+			flag |= os.O_TRUNC
+		}
+		file, err := os.OpenFile(srv.path(name), flag, 0644)
+		if err != nil {
+			writeStatus(err)
+			return
+		}
+		newHandle(&testHandle{file: file})
+	case fxpOpendir:
+		dir := d.string()
+		entries, err := os.ReadDir(srv.path(dir))
+		if err != nil {
+			writeStatus(err)
+			return
+		}
+		newHandle(&testHandle{entries: entries, dir: dir})
+	case fxpReaddir:
+		h := getHandle(d.string())
+		if h == nil || h.listed {
+			writeStatus(io.EOF)
+			return
+		}
+		h.listed = true
+		resp.byte(fxpName)
+		resp.uint32(id)
+		resp.uint32(uint32(len(h.entries)))
+		for _, entry := range h.entries {
+			info, _ := entry.Info()
+			resp.string(entry.Name())
+			resp.string(entry.Name())
+			writeAttrs(info)
+		}
+	case fxpRead:
+		h := getHandle(d.string())
+		offset := d.uint64()
+		buf := make([]byte, d.uint32())
+		n, err := h.file.ReadAt(buf, int64(offset))
+		if n == 0 {
+			writeStatus(err)
+			return
+		}
+		resp.byte(fxpData)
+		resp.uint32(id)
+		resp.string(string(buf[:n]))
+	case fxpWrite:
+		h := getHandle(d.string())
+		offset := d.uint64()
+		_, err := h.file.WriteAt([]byte(d.string()), int64(offset))
+		writeStatus(err)
+	case fxpClose:
+		name := d.string()
+		h := getHandle(name)
+		srv.mu.Lock()
+		delete(srv.handles, name)
+		srv.mu.Unlock()
+		if h != nil && h.file != nil {
+			writeStatus(h.file.Close())
+			return
+		}
+		writeStatus(nil)
+	case fxpMkdir:
+		writeStatus(os.Mkdir(srv.path(d.string()), 0755))
+	case fxpRemove:
+		name := srv.path(d.string())
+		if info, err := os.Stat(name); err == nil && info.IsDir() {
+			writeStatus(errors.New("is a directory"))
+			return
+		}
+		writeStatus(os.Remove(name))
+	case fxpRmdir:
+		writeStatus(os.Remove(srv.path(d.string())))
+	case fxpRename:
+		oldPath := srv.path(d.string())
+		writeStatus(os.Rename(oldPath, srv.path(d.string())))
+	default:
+		writeStatus(errors.New("unsupported"))
+	}
+}
+
+func TestNew_Validation(t *testing.T) {
+	key := testPrivateKey(t)
+	_, err := New("", WithUser("test"), WithPrivateKey(key, ""))
+	assert.Error(t, err)
+	_, err = New("localhost", WithPrivateKey(key, ""))
+	assert.Error(t, err)
+	_, err = New("localhost", WithUser("test"))
+	assert.Error(t, err)
+	_, err = New("localhost", WithUser("test"), WithPrivateKey("not a key", ""))
+	assert.Error(t, err)
+	_, err = New("localhost", WithUser("test"), WithPrivateKey(key, ""))
+	assert.NoError(t, err)
+}
+
+func TestSFTPStorage_PutGetStat(t *testing.T) {
+	s, root := newTestStorage(t)
+	ctx := context.Background()
+
+	content := bytes.Repeat([]byte("0123456789"), 10000) // spans several packets
+	require.NoError(t, s.Put(ctx, "album/nested/photo.jpg", bytes.NewReader(content)))
+	onDisk, err := os.ReadFile(filepath.Join(root, "album/nested/photo.jpg"))
+	require.NoError(t, err)
+	assert.Equal(t, content, onDisk)
+
+	reader, err := s.Get(ctx, "album/nested/photo.jpg")
+	require.NoError(t, err)
+	got, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+	assert.Equal(t, content, got)
+
+	info, err := s.Stat(ctx, "album/nested/photo.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, "photo.jpg", info.Name)
+	assert.Equal(t, int64(len(content)), info.Size)
+	assert.False(t, info.IsDir)
+
+	// Put truncates existing files
+	require.NoError(t, s.Put(ctx, "album/nested/photo.jpg", strings.NewReader("short")))
+	onDisk, err = os.ReadFile(filepath.Join(root, "album/nested/photo.jpg"))
+	require.NoError(t, err)
+	assert.Equal(t, "short", string(onDisk))
+
+	_, err = s.Stat(ctx, "missing.jpg")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	_, err = s.Get(ctx, "missing.jpg")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestSFTPStorage_List(t *testing.T) {
+	s, _ := newTestStorage(t)
+	ctx := context.Background()
+	for _, name := range []string{"b.jpg", "a.png", "c.txt", ".hidden.jpg"} {
+		require.NoError(t, s.Put(ctx, "gallery/"+name, strings.NewReader(name)))
+	}
+	require.NoError(t, s.CreateFolder(ctx, "gallery/sub"))
+
+	result, err := s.List(ctx, "gallery", storage.ListOptions{SortBy: storage.SortByName, SortOrder: storage.SortOrderAsc})
+	require.NoError(t, err)
+	assert.Equal(t, 4, result.TotalCount)
+	var paths []string
+	for _, item := range result.Items {
+		paths = append(paths, item.Path)
+	}
+	assert.Equal(t, []string{"gallery/a.png", "gallery/b.jpg", "gallery/c.txt", "gallery/sub"}, paths)
+
+	result, err = s.List(ctx, "gallery", storage.ListOptions{
+		Extensions: []string{".jpg", ".png"},
+		OnlyFiles:  true,
+		SortBy:     storage.SortByName,
+		SortOrder:  storage.SortOrderAsc,
+		Offset:     1,
+		Limit:      1,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.TotalCount)
+	require.Len(t, result.Items, 1)
+	assert.Equal(t, "b.jpg", result.Items[0].Name)
+
+	result, err = s.List(ctx, "", storage.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, result.Items, 1)
+	assert.Equal(t, "gallery", result.Items[0].Path)
+	assert.True(t, result.Items[0].IsDir)
+}
+
+func TestSFTPStorage_BaseDir(t *testing.T) {
+	s, root := newTestStorage(t, WithBaseDir("photos/"))
+	ctx := context.Background()
+	require.NoError(t, s.Put(ctx, "/a.jpg", strings.NewReader("a")))
+	assert.FileExists(t, filepath.Join(root, "photos/a.jpg"))
+
+	result, err := s.List(ctx, "/", storage.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, result.Items, 1)
+	assert.Equal(t, "a.jpg", result.Items[0].Path)
+}
+
+func TestSFTPStorage_CopyMoveDelete(t *testing.T) {
+	s, root := newTestStorage(t)
+	ctx := context.Background()
+	require.NoError(t, s.Put(ctx, "src/a.jpg", strings.NewReader("a")))
+	require.NoError(t, s.Put(ctx, "src/nested/b.jpg", strings.NewReader("b")))
+
+	require.NoError(t, s.Copy(ctx, "src", "copy"))
+	got, err := os.ReadFile(filepath.Join(root, "copy/nested/b.jpg"))
+	require.NoError(t, err)
+	assert.Equal(t, "b", string(got))
+	assert.ErrorIs(t, s.Copy(ctx, "src/a.jpg", "copy/a.jpg"), os.ErrExist)
+
+	require.NoError(t, s.Move(ctx, "src/a.jpg", "moved/deep/a.jpg"))
+	assert.FileExists(t, filepath.Join(root, "moved/deep/a.jpg"))
+	assert.NoFileExists(t, filepath.Join(root, "src/a.jpg"))
+	assert.ErrorIs(t, s.Move(ctx, "copy/a.jpg", "moved/deep/a.jpg"), os.ErrExist)
+	assert.ErrorIs(t, s.Move(ctx, "src/a.jpg", "elsewhere.jpg"), os.ErrNotExist)
+
+	require.NoError(t, s.Delete(ctx, "copy"))
+	assert.NoDirExists(t, filepath.Join(root, "copy"))
+	require.NoError(t, s.Delete(ctx, "moved/deep/a.jpg"))
+	assert.NoFileExists(t, filepath.Join(root, "moved/deep/a.jpg"))
+	assert.ErrorIs(t, s.Delete(ctx, "copy"), os.ErrNotExist)
+}
+
+func TestSFTPStorage_Reconnect(t *testing.T) {
+	s, _ := newTestStorage(t)
+	ctx := context.Background()
+	require.NoError(t, s.Put(ctx, "a.jpg", strings.NewReader("a")))
+
+	// Break the transport under the open client
+	first, err := s.conn()
+	require.NoError(t, err)
+	first.closer.Close()
+	_, err = s.Stat(ctx, "a.jpg")
+	assert.Error(t, err)
+
+	info, err := s.Stat(ctx, "a.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), info.Size)
+}
+
+func TestSFTPStorage_HostKey(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(key)
+	require.NoError(t, err)
+	fingerprint := ssh.FingerprintSHA256(signer.PublicKey())
+
+	s, _ := newTestStorage(t)
+	assert.NoError(t, s.checkHostKey("localhost", nil, signer.PublicKey()))
+
+	s, _ = newTestStorage(t, WithHostKey(fingerprint))
+	assert.NoError(t, s.checkHostKey("localhost", nil, signer.PublicKey()))
+
+	s, _ = newTestStorage(t, WithHostKey("SHA256:other"))
+	assert.Error(t, s.checkHostKey("localhost", nil, signer.PublicKey()))
+}
@@ -2,7 +2,9 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"io"
+	"io/fs"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -18,6 +20,16 @@ type ListOptions struct {
 	ShowHidden  bool     // whether to show hidden files (default false)
 	SortBy      SortOption
 	SortOrder   SortOrder
+	// SkipMetadata lists entries without the size and modified time that
+	// backends look up one entry at a time, such as folders on S3 and every
+	// entry of a local folder. Ignored when sorting by size or modified time.
+	SkipMetadata bool
+}
+
+// NeedsMetadata reports whether listing with options has to look up the size
+// and modified time of entries
+func (o ListOptions) NeedsMetadata() bool {
+	return !o.SkipMetadata || o.SortBy == SortBySize || o.SortBy == SortByModifiedTime
 }
 
 type SortOption string
@@ -39,6 +51,9 @@ type FileInfo struct {
 	IsDir        bool      `json:"isDir"`
 	ModifiedTime time.Time `json:"modifiedTime"`
 	ETag         string    `json:"etag,omitempty"`
+	// StorageClass is the object storage class reported by S3 compatible
+	// backends on List, e.g. "STANDARD" or "GLACIER"; empty elsewhere
+	StorageClass string `json:"storageClass,omitempty"`
 }
 
 type ListResult struct {
@@ -57,6 +72,57 @@ type Storage interface {
 	Move(ctx context.Context, sourcePath string, destPath string) error
 }
 
+// BatchDeleter is an optional extension for backends that can delete many
+// files per request, such as S3 DeleteObjects. Keys are files, not folders.
+type BatchDeleter interface {
+	DeleteBatch(ctx context.Context, keys []string) error
+}
+
+// Walker is an optional extension for backends that can enumerate every file
+// below a key in one flat listing, such as S3 ListObjectsV2 without a
+// delimiter. fn is called for files only, returning an error stops the walk.
+type Walker interface {
+	Walk(ctx context.Context, key string, fn func(FileInfo) error) error
+}
+
+// ErrNotFound is matched by the errors of Stat and Get for keys that do not
+// exist. It is fs.ErrNotExist, so the os errors of local backends match it.
+var ErrNotFound = fs.ErrNotExist
+
+// ErrExists is matched by the errors of PutIfAbsent for keys that exist
+// already. It is fs.ErrExist, so the os errors of local backends match it.
+var ErrExists = fs.ErrExist
+
+// ErrPagingUnsupported is returned by Pager for listings it cannot page,
+// callers fall back to List with an offset
+var ErrPagingUnsupported = errors.New("listing cannot be paged with a cursor")
+
+// ErrInvalidCursor is returned by Pager for a cursor it did not issue
+var ErrInvalidCursor = errors.New("invalid listing cursor")
+
+// ListPage is a page of a listing
+type ListPage struct {
+	Items []FileInfo
+	// Cursor continues the listing after Items, empty on the last page
+	Cursor string
+}
+
+// Pager is an optional extension for backends that can continue a listing
+// where the previous page ended, such as S3 ListObjectsV2 with continuation
+// tokens, instead of listing everything before an offset. Items are in key
+// order. Options filter them and Limit is the page size, Offset and sorting
+// are ignored. An empty cursor starts from the beginning.
+type Pager interface {
+	ListPage(ctx context.Context, key string, options ListOptions, cursor string) (ListPage, error)
+}
+
+// Counter is an optional extension for backends that count the files below
+// a key faster than walking it folder by folder. Counting stops past limit,
+// more reports whether files were left uncounted.
+type Counter interface {
+	CountFiles(ctx context.Context, key string, limit int) (count int, more bool, err error)
+}
+
 // PresignableStorage is an optional extension for backends that can generate
 // direct-upload presigned PUT URLs, such as S3-compatible object stores.
 type PresignableStorage interface {
@@ -69,6 +135,39 @@ type ConditionalPresignableStorage interface {
 	PresignedPutURLNoOverwrite(ctx context.Context, key string, contentType string, sizeBytes int64, ttl time.Duration) (string, error)
 }
 
+// ExclusiveStorage is an optional extension for backends that can write a
+// key only when it does not exist, atomically with concurrent writes. Writes
+// to existing keys fail with an error matching ErrExists. Storages routing to
+// others fail with errors.ErrUnsupported, before reading content, for keys of
+// backends without it.
+type ExclusiveStorage interface {
+	PutIfAbsent(ctx context.Context, key string, content io.Reader) error
+}
+
+// MultipartPresignableStorage is an optional extension for backends that can
+// generate presigned URLs for the parts of a multipart upload, for files too
+// large for a single PUT.
+type MultipartPresignableStorage interface {
+	CreateMultipartUpload(ctx context.Context, key string, contentType string) (uploadID string, err error)
+	PresignedUploadPartURL(ctx context.Context, key string, uploadID string, partNumber int, ttl time.Duration) (string, error)
+	CompleteMultipartUpload(ctx context.Context, key string, uploadID string, parts []UploadPart) error
+	AbortMultipartUpload(ctx context.Context, key string, uploadID string) error
+}
+
+// DownloadPresignableStorage is an optional extension for backends that can
+// generate presigned GET URLs, letting clients download straight from the
+// backend. The download is saved as filename.
+type DownloadPresignableStorage interface {
+	PresignedGetURL(ctx context.Context, key string, filename string, ttl time.Duration) (string, error)
+}
+
+// UploadPart is a part of a multipart upload, the ETag being returned by the
+// backend when the part was uploaded
+type UploadPart struct {
+	Number int
+	ETag   string
+}
+
 // Helper functions for common filtering logic
 
 // MatchesExtensions checks if a filename matches any of the provided extensions
@@ -0,0 +1,202 @@
+// Package tracedstorage records an OpenTelemetry span for every call to a
+// storage backend.
+package tracedstorage
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/cshum/imagor-studio/server/pkg/storage"
+
+// extendedStorage is a backend implementing every optional extension, such
+// as S3
+type extendedStorage interface {
+	storage.Storage
+	storage.BatchDeleter
+	storage.Walker
+	storage.Pager
+	storage.Counter
+	storage.PresignableStorage
+	storage.ConditionalPresignableStorage
+	storage.ExclusiveStorage
+}
+
+// TracedStorage traces the calls to a storage. backend names the backend in
+// the storage.backend span attribute.
+type TracedStorage struct {
+	next    storage.Storage
+	backend string
+	tracer  trace.Tracer
+}
+
+// New returns s with its calls traced under the global tracer provider.
+// Backends implementing every optional extension keep them, others are
+// wrapped with the Storage methods only, and PutIfAbsent when they have it.
+func New(s storage.Storage, backend string) storage.Storage {
+	traced := &TracedStorage{next: s, backend: backend, tracer: otel.Tracer(tracerName)}
+	if extended, ok := s.(extendedStorage); ok {
+		return &tracedExtendedStorage{TracedStorage: traced, next: extended}
+	}
+	if exclusive, ok := s.(storage.ExclusiveStorage); ok {
+		return &tracedExclusiveStorage{TracedStorage: traced, next: exclusive}
+	}
+	return traced
+}
+
+func (s *TracedStorage) start(ctx context.Context, op string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, attribute.String("storage.backend", s.backend))
+	return s.tracer.Start(ctx, "storage."+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...))
+}
+
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func keyAttr(key string) attribute.KeyValue {
+	return attribute.String("storage.key", key)
+}
+
+func (s *TracedStorage) List(ctx context.Context, key string, options storage.ListOptions) (storage.ListResult, error) {
+	ctx, span := s.start(ctx, "List", keyAttr(key),
+		attribute.Int("storage.offset", options.Offset),
+		attribute.Int("storage.limit", options.Limit))
+	result, err := s.next.List(ctx, key, options)
+	span.SetAttributes(attribute.Int("storage.items", len(result.Items)))
+	end(span, err)
+	return result, err
+}
+
+// Get traces opening the file, reading it is not part of the span
+func (s *TracedStorage) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	ctx, span := s.start(ctx, "Get", keyAttr(key))
+	reader, err := s.next.Get(ctx, key)
+	end(span, err)
+	return reader, err
+}
+
+func (s *TracedStorage) Put(ctx context.Context, key string, content io.Reader) error {
+	ctx, span := s.start(ctx, "Put", keyAttr(key))
+	err := s.next.Put(ctx, key, content)
+	end(span, err)
+	return err
+}
+
+func (s *TracedStorage) Delete(ctx context.Context, key string) error {
+	ctx, span := s.start(ctx, "Delete", keyAttr(key))
+	err := s.next.Delete(ctx, key)
+	end(span, err)
+	return err
+}
+
+func (s *TracedStorage) CreateFolder(ctx context.Context, folder string) error {
+	ctx, span := s.start(ctx, "CreateFolder", keyAttr(folder))
+	err := s.next.CreateFolder(ctx, folder)
+	end(span, err)
+	return err
+}
+
+func (s *TracedStorage) Stat(ctx context.Context, key string) (storage.FileInfo, error) {
+	ctx, span := s.start(ctx, "Stat", keyAttr(key))
+	info, err := s.next.Stat(ctx, key)
+	end(span, err)
+	return info, err
+}
+
+func (s *TracedStorage) Copy(ctx context.Context, sourcePath string, destPath string) error {
+	ctx, span := s.start(ctx, "Copy", keyAttr(sourcePath), attribute.String("storage.dest_key", destPath))
+	err := s.next.Copy(ctx, sourcePath, destPath)
+	end(span, err)
+	return err
+}
+
+func (s *TracedStorage) Move(ctx context.Context, sourcePath string, destPath string) error {
+	ctx, span := s.start(ctx, "Move", keyAttr(sourcePath), attribute.String("storage.dest_key", destPath))
+	err := s.next.Move(ctx, sourcePath, destPath)
+	end(span, err)
+	return err
+}
+
+func (s *TracedStorage) putIfAbsent(ctx context.Context, next storage.ExclusiveStorage, key string, content io.Reader) error {
+	ctx, span := s.start(ctx, "PutIfAbsent", keyAttr(key))
+	err := next.PutIfAbsent(ctx, key, content)
+	end(span, err)
+	return err
+}
+
+type tracedExclusiveStorage struct {
+	*TracedStorage
+	next storage.ExclusiveStorage
+}
+
+func (s *tracedExclusiveStorage) PutIfAbsent(ctx context.Context, key string, content io.Reader) error {
+	return s.putIfAbsent(ctx, s.next, key, content)
+}
+
+type tracedExtendedStorage struct {
+	*TracedStorage
+	next extendedStorage
+}
+
+func (s *tracedExtendedStorage) DeleteBatch(ctx context.Context, keys []string) error {
+	ctx, span := s.start(ctx, "DeleteBatch", attribute.Int("storage.keys", len(keys)))
+	err := s.next.DeleteBatch(ctx, keys)
+	end(span, err)
+	return err
+}
+
+// Walk traces the whole walk, including the time spent in fn
+func (s *tracedExtendedStorage) Walk(ctx context.Context, key string, fn func(storage.FileInfo) error) error {
+	ctx, span := s.start(ctx, "Walk", keyAttr(key))
+	err := s.next.Walk(ctx, key, fn)
+	end(span, err)
+	return err
+}
+
+func (s *tracedExtendedStorage) ListPage(ctx context.Context, key string, options storage.ListOptions, cursor string) (storage.ListPage, error) {
+	ctx, span := s.start(ctx, "ListPage", keyAttr(key),
+		attribute.Int("storage.limit", options.Limit),
+		attribute.Bool("storage.continued", cursor != ""))
+	page, err := s.next.ListPage(ctx, key, options, cursor)
+	span.SetAttributes(attribute.Int("storage.items", len(page.Items)))
+	end(span, err)
+	return page, err
+}
+
+func (s *tracedExtendedStorage) CountFiles(ctx context.Context, key string, limit int) (int, bool, error) {
+	ctx, span := s.start(ctx, "CountFiles", keyAttr(key))
+	count, more, err := s.next.CountFiles(ctx, key, limit)
+	end(span, err)
+	return count, more, err
+}
+
+func (s *tracedExtendedStorage) PresignedPutURL(ctx context.Context, key string, contentType string, sizeBytes int64, ttl time.Duration) (string, error) {
+	ctx, span := s.start(ctx, "PresignedPutURL", keyAttr(key))
+	url, err := s.next.PresignedPutURL(ctx, key, contentType, sizeBytes, ttl)
+	end(span, err)
+	return url, err
+}
+
+func (s *tracedExtendedStorage) PresignedPutURLNoOverwrite(ctx context.Context, key string, contentType string, sizeBytes int64, ttl time.Duration) (string, error) {
+	ctx, span := s.start(ctx, "PresignedPutURLNoOverwrite", keyAttr(key))
+	url, err := s.next.PresignedPutURLNoOverwrite(ctx, key, contentType, sizeBytes, ttl)
+	end(span, err)
+	return url, err
+}
+
+func (s *tracedExtendedStorage) PutIfAbsent(ctx context.Context, key string, content io.Reader) error {
+	return s.putIfAbsent(ctx, s.next, key, content)
+}
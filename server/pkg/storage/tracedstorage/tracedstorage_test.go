@@ -0,0 +1,67 @@
+package tracedstorage
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cshum/imagor-studio/server/pkg/storage"
+	"github.com/cshum/imagor-studio/server/pkg/storage/filestorage"
+	"github.com/cshum/imagor-studio/server/pkg/storage/s3storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func setupRecorder(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func TestTracedStorage(t *testing.T) {
+	recorder := setupRecorder(t)
+	fs, err := filestorage.New(t.TempDir())
+	require.NoError(t, err)
+	s := New(fs, "file")
+	_, extended := s.(storage.Pager)
+	assert.False(t, extended)
+	_, ok := s.(storage.ExclusiveStorage)
+	assert.True(t, ok)
+
+	ctx := context.Background()
+	require.NoError(t, s.Put(ctx, "a.jpg", strings.NewReader("data")))
+	result, err := s.List(ctx, "", storage.ListOptions{Limit: 10})
+	require.NoError(t, err)
+	assert.Len(t, result.Items, 1)
+	_, err = s.Stat(ctx, "missing.jpg")
+	require.Error(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+	assert.Equal(t, "storage.Put", spans[0].Name())
+	assert.Equal(t, "storage.List", spans[1].Name())
+	assert.Contains(t, spans[1].Attributes(), keyAttr(""))
+	assert.Equal(t, "storage.Stat", spans[2].Name())
+	assert.Equal(t, codes.Error, spans[2].Status().Code)
+	assert.Equal(t, codes.Unset, spans[0].Status().Code)
+}
+
+func TestTracedStorage_KeepsExtensions(t *testing.T) {
+	s3, err := s3storage.New("bucket", s3storage.WithRegion("us-east-1"))
+	require.NoError(t, err)
+	s := New(s3, "s3")
+	_, ok := s.(storage.Pager)
+	assert.True(t, ok)
+	_, ok = s.(storage.PresignableStorage)
+	assert.True(t, ok)
+	_, ok = s.(storage.Walker)
+	assert.True(t, ok)
+	_, ok = s.(storage.ExclusiveStorage)
+	assert.True(t, ok)
+}
@@ -16,6 +16,8 @@ type User struct {
 	EmailVerified bool      `json:"emailVerified"`
 	HasPassword   bool      `json:"hasPassword"`
 	AvatarUrl     *string   `json:"avatarUrl,omitempty"`
+	HomePath      *string   `json:"homePath,omitempty"`
+	TenantID      *string   `json:"tenantId,omitempty"`
 	CreatedAt     time.Time `json:"createdAt"`
 	UpdatedAt     time.Time `json:"updatedAt"`
 }
@@ -1,4 +1,5 @@
 import path from 'path'
+import { brotliCompressSync, constants, gzipSync } from 'zlib'
 import react from '@vitejs/plugin-react-swc'
 import { defineConfig, type Plugin } from 'vite'
 
@@ -12,8 +13,37 @@ function htmlTitlePlugin(): Plugin {
   }
 }
 
+// Writes brotli and gzip variants of the compressible assets next to them,
+// the server sends them to clients accepting the encoding
+function precompressPlugin(): Plugin {
+  return {
+    name: 'precompress',
+    apply: 'build',
+    generateBundle(_, bundle) {
+      for (const file of Object.values(bundle)) {
+        if (!/\.(js|mjs|css|svg|json|txt|wasm)$/.test(file.fileName)) continue
+        const source = file.type === 'chunk' ? file.code : file.source
+        const content = typeof source === 'string' ? Buffer.from(source, 'utf8') : Buffer.from(source)
+        if (content.length < 1024) continue
+        this.emitFile({
+          type: 'asset',
+          fileName: `${file.fileName}.br`,
+          source: brotliCompressSync(content, {
+            params: { [constants.BROTLI_PARAM_QUALITY]: constants.BROTLI_MAX_QUALITY },
+          }),
+        })
+        this.emitFile({
+          type: 'asset',
+          fileName: `${file.fileName}.gz`,
+          source: gzipSync(content, { level: 9 }),
+        })
+      }
+    },
+  }
+}
+
 export default defineConfig({
-  plugins: [react(), htmlTitlePlugin()],
+  plugins: [react(), htmlTitlePlugin(), precompressPlugin()],
   resolve: {
     alias: {
       '@': path.resolve(__dirname, './src'),